@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// requestTimeout bounds every API call; imports and applies are the slowest
+const requestTimeout = 2 * time.Minute
+
+// client calls the REST API with the stored credentials
+type client struct {
+	creds *credentials
+	http  *http.Client
+}
+
+func newClient(creds *credentials) *client {
+	return &client{creds: creds, http: &http.Client{Timeout: requestTimeout}}
+}
+
+// apiError is a non-2xx response
+type apiError struct {
+	Status  int
+	Message string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.Status, http.StatusText(e.Status), e.Message)
+}
+
+// teamPath is a path under the selected team
+func (c *client) teamPath(format string, args ...interface{}) (string, error) {
+	if c.creds.TeamID == "" {
+		return "", errNoTeam
+	}
+	return "/api/teams/" + c.creds.TeamID + fmt.Sprintf(format, args...), nil
+}
+
+var errNoTeam = errors.New("no team selected (use 'baseplate team use <id>', --team or BASEPLATE_TEAM)")
+
+// do sends a JSON request and decodes a JSON response into out, which may be
+// nil. body may be nil, an io.Reader sent as contentType, or a value encoded
+// as JSON.
+func (c *client) do(method, path string, body interface{}, out interface{}) error {
+	return c.send(method, path, "application/json", body, out)
+}
+
+func (c *client) send(method, path, contentType string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case io.Reader:
+		reader = b
+	default:
+		encoded, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(method, c.creds.Server+path, reader)
+	if err != nil {
+		return err
+	}
+	if reader != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	if auth := c.creds.authorization(); auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	if c.creds.TeamID != "" {
+		// Blueprint and entity routes take the team from this header
+		req.Header.Set("X-Team-ID", c.creds.TeamID)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return responseError(resp.StatusCode, data)
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// responseError turns an error response into an apiError, using the
+// {"error": "..."} message the server sends when there is one
+func responseError(status int, data []byte) error {
+	var body struct {
+		Error string `json:"error"`
+	}
+	message := string(bytes.TrimSpace(data))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		message = body.Error
+	}
+	if status == http.StatusUnauthorized {
+		message += " (run 'baseplate login')"
+	}
+	return &apiError{Status: status, Message: message}
+}
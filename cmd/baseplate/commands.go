@@ -0,0 +1,489 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/baseplate/baseplate/internal/buildinfo"
+	"github.com/baseplate/baseplate/internal/passwordinput"
+)
+
+func runLogin(c *client, args []string) error {
+	fs := newFlags("login", "--email EMAIL [--password-file FILE] [--code CODE]")
+	email := fs.String("email", os.Getenv("BASEPLATE_EMAIL"), "account email (or BASEPLATE_EMAIL)")
+	passwordFile := fs.String("password-file", "", "read the password from this file, or from stdin when set to '-' (default: BASEPLATE_PASSWORD)")
+	code := fs.String("code", "", "authenticator or backup code, for accounts with two-factor authentication")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *email == "" {
+		return errors.New("--email is required")
+	}
+	password, err := resolvePassword(*passwordFile)
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		Token             string          `json:"token"`
+		User              json.RawMessage `json:"user"`
+		TwoFactorRequired bool            `json:"two_factor_required"`
+		TwoFactorToken    string          `json:"two_factor_token"`
+	}
+	body := map[string]string{"email": *email, "password": password}
+	if err := c.do(http.MethodPost, "/api/auth/login", body, &resp); err != nil {
+		return err
+	}
+	if resp.TwoFactorRequired {
+		if *code == "" {
+			return errors.New("two-factor authentication is enabled for this account; pass --code")
+		}
+		body := map[string]string{"two_factor_token": resp.TwoFactorToken, secondFactorField(*code): *code}
+		if err := c.do(http.MethodPost, "/api/auth/login/2fa", body, &resp); err != nil {
+			return err
+		}
+	}
+
+	stored, err := readCredentials()
+	if err != nil {
+		return err
+	}
+	stored.Server, stored.Token, stored.APIKey = c.creds.Server, resp.Token, ""
+	c.creds.Token, c.creds.APIKey = resp.Token, ""
+
+	// A user with a single team does not need to pick one
+	if stored.TeamID == "" {
+		var teams struct {
+			Teams []struct {
+				ID string `json:"id"`
+			} `json:"teams"`
+		}
+		if err := c.do(http.MethodGet, "/api/teams", nil, &teams); err == nil && len(teams.Teams) == 1 {
+			stored.TeamID = teams.Teams[0].ID
+		}
+	}
+	if err := stored.save(); err != nil {
+		return fmt.Errorf("logged in but could not store credentials: %w", err)
+	}
+
+	path, _ := credentialsPath()
+	fmt.Fprintf(os.Stderr, "Logged in to %s; credentials stored in %s\n", stored.Server, path)
+	return printJSON(resp.User)
+}
+
+// secondFactorField tells authenticator codes (six digits) from backup codes
+func secondFactorField(code string) string {
+	if len(code) == 6 && strings.Trim(code, "0123456789") == "" {
+		return "code"
+	}
+	return "backup_code"
+}
+
+// resolvePassword reads the password from a file, stdin or BASEPLATE_PASSWORD.
+// There is no interactive prompt because input would be echoed.
+func resolvePassword(passwordFile string) (string, error) {
+	switch passwordFile {
+	case "":
+		if password := os.Getenv("BASEPLATE_PASSWORD"); password != "" {
+			return password, nil
+		}
+		return "", errors.New("no password provided (use --password-file or BASEPLATE_PASSWORD)")
+	case "-":
+		return passwordinput.Read(os.Stdin)
+	}
+	f, err := os.Open(passwordFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to open password file: %w", err)
+	}
+	defer f.Close()
+	return passwordinput.Read(f)
+}
+
+func runLogout(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("logout", ""), args, 0); err != nil {
+		return err
+	}
+	stored, err := readCredentials()
+	if err != nil {
+		return err
+	}
+	stored.Token, stored.APIKey = "", ""
+	return stored.save()
+}
+
+func runWhoami(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("whoami", ""), args, 0); err != nil {
+		return err
+	}
+	var me json.RawMessage
+	if err := c.do(http.MethodGet, "/api/auth/me", nil, &me); err != nil {
+		return err
+	}
+	return printJSON(me)
+}
+
+// runVersion prints the build of the CLI and, when it is reachable, of the server
+func runVersion(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("version", ""), args, 0); err != nil {
+		return err
+	}
+	out := map[string]interface{}{"client": buildinfo.Get()}
+	var server json.RawMessage
+	if err := c.do(http.MethodGet, "/api/version", nil, &server); err != nil {
+		out["server_error"] = err.Error()
+	} else {
+		out["server"] = server
+	}
+	return printJSON(out)
+}
+
+func runTeamList(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("team list", ""), args, 0); err != nil {
+		return err
+	}
+	return getAndPrint(c, "/api/teams")
+}
+
+func runTeamCreate(c *client, args []string) error {
+	fs := newFlags("team create", "--name NAME --slug SLUG")
+	name := fs.String("name", "", "team name")
+	slug := fs.String("slug", "", "team slug")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" || *slug == "" {
+		return errors.New("--name and --slug are required")
+	}
+	var team json.RawMessage
+	if err := c.do(http.MethodPost, "/api/teams", map[string]string{"name": *name, "slug": *slug}, &team); err != nil {
+		return err
+	}
+	return printJSON(team)
+}
+
+// runTeamUse stores the team that later commands act on
+func runTeamUse(c *client, args []string) error {
+	positional, err := parseArgs(newFlags("team use", "TEAM_ID"), args, 1)
+	if err != nil {
+		return err
+	}
+	c.creds.TeamID = positional[0]
+	var team json.RawMessage
+	if err := c.do(http.MethodGet, "/api/teams/"+url.PathEscape(positional[0]), nil, &team); err != nil {
+		return err
+	}
+
+	stored, err := readCredentials()
+	if err != nil {
+		return err
+	}
+	stored.TeamID = positional[0]
+	if stored.Server == "" {
+		stored.Server = c.creds.Server
+	}
+	if err := stored.save(); err != nil {
+		return err
+	}
+	return printJSON(team)
+}
+
+func runTeamMembers(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("team members", ""), args, 0); err != nil {
+		return err
+	}
+	path, err := c.teamPath("/members")
+	if err != nil {
+		return err
+	}
+	return getAndPrint(c, path)
+}
+
+func runTeamRoles(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("team roles", ""), args, 0); err != nil {
+		return err
+	}
+	path, err := c.teamPath("/roles")
+	if err != nil {
+		return err
+	}
+	return getAndPrint(c, path)
+}
+
+func runTeamAddMember(c *client, args []string) error {
+	fs := newFlags("team add-member", "--email EMAIL --role ROLE_ID")
+	email := fs.String("email", "", "email of an existing user")
+	role := fs.String("role", "", "role ID (see 'team roles')")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *email == "" || *role == "" {
+		return errors.New("--email and --role are required")
+	}
+	path, err := c.teamPath("/members")
+	if err != nil {
+		return err
+	}
+	var membership json.RawMessage
+	if err := c.do(http.MethodPost, path, map[string]string{"email": *email, "role_id": *role}, &membership); err != nil {
+		return err
+	}
+	return printJSON(membership)
+}
+
+func runTeamSetRole(c *client, args []string) error {
+	fs := newFlags("team set-role", "--role ROLE_ID USER_ID")
+	role := fs.String("role", "", "role ID (see 'team roles')")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if *role == "" {
+		return errors.New("--role is required")
+	}
+	path, err := c.teamPath("/members/%s", url.PathEscape(positional[0]))
+	if err != nil {
+		return err
+	}
+	var membership json.RawMessage
+	if err := c.do(http.MethodPut, path, map[string]string{"role_id": *role}, &membership); err != nil {
+		return err
+	}
+	return printJSON(membership)
+}
+
+func runTeamRemoveMember(c *client, args []string) error {
+	positional, err := parseArgs(newFlags("team remove-member", "USER_ID"), args, 1)
+	if err != nil {
+		return err
+	}
+	path, err := c.teamPath("/members/%s", url.PathEscape(positional[0]))
+	if err != nil {
+		return err
+	}
+	return c.do(http.MethodDelete, path, nil, nil)
+}
+
+func runBlueprintList(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("blueprint list", ""), args, 0); err != nil {
+		return err
+	}
+	return getAndPrint(c, "/api/blueprints")
+}
+
+func runBlueprintGet(c *client, args []string) error {
+	positional, err := parseArgs(newFlags("blueprint get", "BLUEPRINT_ID"), args, 1)
+	if err != nil {
+		return err
+	}
+	return getAndPrint(c, "/api/blueprints/"+url.PathEscape(positional[0]))
+}
+
+// runBlueprintSchema prints a blueprint's entity schema as standalone JSON Schema
+func runBlueprintSchema(c *client, args []string) error {
+	positional, err := parseArgs(newFlags("blueprint schema", "BLUEPRINT_ID"), args, 1)
+	if err != nil {
+		return err
+	}
+	return getAndPrint(c, "/api/blueprints/"+url.PathEscape(positional[0])+"/schema.json")
+}
+
+// runBlueprintApply applies a manifest of blueprints, roles and scorecards
+func runBlueprintApply(c *client, args []string) error {
+	fs := newFlags("blueprint apply", "-f MANIFEST [--dry-run]")
+	file := fs.String("f", "", "manifest file, or '-' for stdin")
+	dryRun := fs.Bool("dry-run", false, "show the changes without applying them")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	manifest, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	path, err := c.teamPath("/apply")
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		path += "?dry_run=true"
+	}
+	var result json.RawMessage
+	if err := c.do(http.MethodPost, path, bytes.NewReader(manifest), &result); err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+func runEntityList(c *client, args []string) error {
+	fs := newFlags("entity list", "[--limit N] [--offset N] [--view ID] BLUEPRINT_ID")
+	limit := fs.Int("limit", 50, "maximum number of entities")
+	offset := fs.Int("offset", 0, "number of entities to skip")
+	view := fs.String("view", "", "saved view ID, or 'default'")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	query.Set("limit", fmt.Sprint(*limit))
+	query.Set("offset", fmt.Sprint(*offset))
+	if *view != "" {
+		query.Set("view", *view)
+	}
+	return getAndPrint(c, "/api/blueprints/"+url.PathEscape(positional[0])+"/entities?"+query.Encode())
+}
+
+func runEntityGet(c *client, args []string) error {
+	positional, err := parseArgs(newFlags("entity get", "BLUEPRINT_ID IDENTIFIER"), args, 2)
+	if err != nil {
+		return err
+	}
+	return getAndPrint(c, "/api/blueprints/"+url.PathEscape(positional[0])+"/entities/by-identifier/"+url.PathEscape(positional[1]))
+}
+
+// runEntitySearch searches one blueprint, or every blueprint of the team when
+// none is given
+func runEntitySearch(c *client, args []string) error {
+	fs := newFlags("entity search", "[--filter PROPERTY:OPERATOR[:VALUE]]... [BLUEPRINT_ID]")
+	var filters filterFlags
+	fs.Var(&filters, "filter", "filter as property:operator:value, e.g. tier:eq:1 (repeatable)")
+	orderBy := fs.String("order-by", "", "property to sort by")
+	orderDir := fs.String("order-dir", "", "asc or desc")
+	limit := fs.Int("limit", 0, "maximum number of entities (server default when 0)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{
+		"filters":   []searchFilter(filters),
+		"order_by":  *orderBy,
+		"order_dir": *orderDir,
+		"limit":     *limit,
+	}
+	var path string
+	switch fs.NArg() {
+	case 0:
+		var err error
+		if path, err = c.teamPath("/entities/search"); err != nil {
+			return err
+		}
+	case 1:
+		path = "/api/blueprints/" + url.PathEscape(fs.Arg(0)) + "/entities/search"
+	default:
+		fs.Usage()
+		return flag.ErrHelp
+	}
+
+	var result json.RawMessage
+	if err := c.do(http.MethodPost, path, body, &result); err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+// runEntityUpsert creates or updates entities by identifier through the
+// import endpoint, so a whole file is applied in one request
+func runEntityUpsert(c *client, args []string) error {
+	fs := newFlags("entity upsert", "-f FILE [--dry-run] BLUEPRINT_ID")
+	file := fs.String("f", "", "JSON object, array or NDJSON of {identifier, title, data}, or '-' for stdin")
+	dryRun := fs.Bool("dry-run", false, "validate without writing")
+	positional, err := parseArgs(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	input, err := readInput(*file)
+	if err != nil {
+		return err
+	}
+	lines, err := toNDJSON(input)
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{"format": {"ndjson"}, "mode": {"upsert"}}
+	if *dryRun {
+		query.Set("dry_run", "true")
+	}
+	path := "/api/blueprints/" + url.PathEscape(positional[0]) + "/entities/import?" + query.Encode()
+	var result json.RawMessage
+	if err := c.send(http.MethodPost, path, "application/x-ndjson", bytes.NewReader(lines), &result); err != nil {
+		return err
+	}
+	return printJSON(result)
+}
+
+func runAPIKeyList(c *client, args []string) error {
+	if _, err := parseArgs(newFlags("apikey list", ""), args, 0); err != nil {
+		return err
+	}
+	path, err := c.teamPath("/api-keys")
+	if err != nil {
+		return err
+	}
+	return getAndPrint(c, path)
+}
+
+func runAPIKeyCreate(c *client, args []string) error {
+	fs := newFlags("apikey create", "--name NAME [--permission PERM]... [--expires-at RFC3339]")
+	name := fs.String("name", "", "key name")
+	var permissions stringList
+	fs.Var(&permissions, "permission", "permission granted to the key, e.g. entity:read (repeatable)")
+	expiresAt := fs.String("expires-at", "", "expiry time in RFC 3339 format")
+	if _, err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("--name is required")
+	}
+	path, err := c.teamPath("/api-keys")
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"name": *name, "permissions": []string(permissions)}
+	if *expiresAt != "" {
+		body["expires_at"] = *expiresAt
+	}
+	var created json.RawMessage
+	if err := c.do(http.MethodPost, path, body, &created); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "The key is shown only once; store it securely.")
+	return printJSON(created)
+}
+
+func runAPIKeyDelete(c *client, args []string) error {
+	positional, err := parseArgs(newFlags("apikey delete", "KEY_ID"), args, 1)
+	if err != nil {
+		return err
+	}
+	if c.creds.TeamID == "" {
+		return errNoTeam
+	}
+	return c.do(http.MethodDelete, "/api/api-keys/"+url.PathEscape(positional[0]), nil, nil)
+}
+
+func getAndPrint(c *client, path string) error {
+	var out json.RawMessage
+	if err := c.do(http.MethodGet, path, nil, &out); err != nil {
+		return err
+	}
+	return printJSON(out)
+}
+
+// readInput reads a file, or stdin when name is "-"
+func readInput(name string) ([]byte, error) {
+	switch name {
+	case "":
+		return nil, errors.New("-f is required")
+	case "-":
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultServer is used until login stores another one
+const defaultServer = "http://localhost:8080"
+
+// credentials are what login stores between invocations. The file is only
+// readable by its owner because it holds a bearer token.
+type credentials struct {
+	Server string `json:"server"`
+	Token  string `json:"token,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+	TeamID string `json:"team_id,omitempty"`
+}
+
+// credentialsPath is $BASEPLATE_CONFIG, or credentials.json in the user's
+// config directory (~/.config/baseplate on Linux)
+func credentialsPath() (string, error) {
+	if path := os.Getenv("BASEPLATE_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "baseplate", "credentials.json"), nil
+}
+
+// loadCredentials returns the stored credentials with the BASEPLATE_URL,
+// BASEPLATE_TOKEN, BASEPLATE_API_KEY and BASEPLATE_TEAM overrides applied
+func loadCredentials() (*credentials, error) {
+	creds, err := readCredentials()
+	if err != nil {
+		return nil, err
+	}
+	creds.applyEnv(os.Getenv)
+	return creds, nil
+}
+
+// readCredentials reads the credentials file; a missing file is empty
+func readCredentials() (*credentials, error) {
+	creds := &credentials{}
+	path, err := credentialsPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := json.Unmarshal(data, creds); err != nil {
+			return nil, fmt.Errorf("invalid credentials file %s: %w", path, err)
+		}
+	}
+	return creds, nil
+}
+
+func (c *credentials) applyEnv(getenv func(string) string) {
+	if v := getenv("BASEPLATE_URL"); v != "" {
+		c.Server = v
+	}
+	if v := getenv("BASEPLATE_TOKEN"); v != "" {
+		c.Token, c.APIKey = v, ""
+	}
+	if v := getenv("BASEPLATE_API_KEY"); v != "" {
+		c.APIKey, c.Token = v, ""
+	}
+	if v := getenv("BASEPLATE_TEAM"); v != "" {
+		c.TeamID = v
+	}
+	if c.Server == "" {
+		c.Server = defaultServer
+	}
+	c.Server = strings.TrimRight(c.Server, "/")
+}
+
+// save writes the credentials with owner-only permissions
+func (c *credentials) save() error {
+	path, err := credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0o600)
+}
+
+// authorization is the Authorization header value, or "" when not logged in
+func (c *credentials) authorization() string {
+	switch {
+	case c.APIKey != "":
+		return "ApiKey " + c.APIKey
+	case c.Token != "":
+		return "Bearer " + c.Token
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// searchFilter is entity.SearchFilter as sent over the wire
+type searchFilter struct {
+	Property string      `json:"property"`
+	Operator string      `json:"operator"`
+	Value    interface{} `json:"value,omitempty"`
+}
+
+// filterFlags collects repeated --filter flags
+type filterFlags []searchFilter
+
+func (f *filterFlags) String() string { return fmt.Sprint(*f) }
+
+func (f *filterFlags) Set(value string) error {
+	filter, err := parseFilter(value)
+	if err != nil {
+		return err
+	}
+	*f = append(*f, filter)
+	return nil
+}
+
+// parseFilter reads "property:operator[:value]". The value is decoded as JSON
+// when it is valid JSON, so tier:eq:1 compares a number and tier:eq:"1" a
+// string; anything else is taken as a string. Values may contain colons.
+func parseFilter(s string) (searchFilter, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return searchFilter{}, fmt.Errorf("invalid filter %q (want property:operator[:value])", s)
+	}
+	filter := searchFilter{Property: parts[0], Operator: parts[1]}
+	if len(parts) == 3 {
+		var value interface{}
+		if err := json.Unmarshal([]byte(parts[2]), &value); err == nil {
+			filter.Value = value
+		} else {
+			filter.Value = parts[2]
+		}
+	}
+	return filter, nil
+}
+
+// stringList collects a repeated string flag
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// toNDJSON rewrites a JSON object, an array of objects, or a stream of
+// objects (NDJSON) as one compact object per line
+func toNDJSON(input []byte) ([]byte, error) {
+	var out bytes.Buffer
+	dec := json.NewDecoder(bytes.NewReader(input))
+	write := func(raw json.RawMessage) error {
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) == 0 || trimmed[0] != '{' {
+			return errors.New("each entity must be a JSON object")
+		}
+		if err := json.Compact(&out, raw); err != nil {
+			return err
+		}
+		out.WriteByte('\n')
+		return nil
+	}
+
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid input: %w", err)
+		}
+		if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+			var items []json.RawMessage
+			if err := json.Unmarshal(raw, &items); err != nil {
+				return nil, fmt.Errorf("invalid input: %w", err)
+			}
+			for _, item := range items {
+				if err := write(item); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		if err := write(raw); err != nil {
+			return nil, err
+		}
+	}
+	if out.Len() == 0 {
+		return nil, errors.New("input has no entities")
+	}
+	return out.Bytes(), nil
+}
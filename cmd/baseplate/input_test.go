@@ -0,0 +1,90 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want searchFilter
+	}{
+		{"tier:eq:1", searchFilter{Property: "tier", Operator: "eq", Value: 1.0}},
+		{`tier:eq:"1"`, searchFilter{Property: "tier", Operator: "eq", Value: "1"}},
+		{"owner:eq:platform", searchFilter{Property: "owner", Operator: "eq", Value: "platform"}},
+		{"url:contains:https://example.com", searchFilter{Property: "url", Operator: "contains", Value: "https://example.com"}},
+		{"oncall:exists", searchFilter{Property: "oncall", Operator: "exists"}},
+	}
+	for _, tt := range tests {
+		got, err := parseFilter(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "tier", ":eq:1", "tier::1"} {
+		if _, err := parseFilter(in); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestToNDJSON(t *testing.T) {
+	want := "{\"identifier\":\"a\"}\n{\"identifier\":\"b\"}\n"
+	inputs := map[string]string{
+		"array":  `[{"identifier": "a"}, {"identifier": "b"}]`,
+		"ndjson": "{\"identifier\": \"a\"}\n{\"identifier\": \"b\"}\n",
+		"mixed":  "{\"identifier\": \"a\"}\n[{\"identifier\": \"b\"}]",
+	}
+	for name, in := range inputs {
+		got, err := toNDJSON([]byte(in))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	for _, in := range []string{"", "[]", `"service"`, `[1, 2]`, `{"identifier": `} {
+		if _, err := toNDJSON([]byte(in)); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+}
+
+func TestCredentialsEnv(t *testing.T) {
+	env := map[string]string{"BASEPLATE_URL": "https://baseplate.example.com/", "BASEPLATE_API_KEY": "bp_key"}
+	creds := &credentials{Token: "stored-jwt", TeamID: "team"}
+	creds.applyEnv(func(key string) string { return env[key] })
+
+	if creds.Server != "https://baseplate.example.com" {
+		t.Errorf("server = %q", creds.Server)
+	}
+	if got := creds.authorization(); got != "ApiKey bp_key" {
+		t.Errorf("authorization = %q, want the API key to replace the stored token", got)
+	}
+	if creds.TeamID != "team" {
+		t.Errorf("team = %q, want the stored team", creds.TeamID)
+	}
+
+	empty := &credentials{}
+	empty.applyEnv(func(string) string { return "" })
+	if empty.Server != defaultServer || empty.authorization() != "" {
+		t.Errorf("empty credentials = %+v", empty)
+	}
+}
+
+func TestSecondFactorField(t *testing.T) {
+	for code, want := range map[string]string{"287082": "code", "k7dq-2mfa": "backup_code", "12345": "backup_code", "k7dq2mfa": "backup_code"} {
+		if got := secondFactorField(code); got != want {
+			t.Errorf("secondFactorField(%q) = %s, want %s", code, got, want)
+		}
+	}
+}
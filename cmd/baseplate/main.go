@@ -0,0 +1,147 @@
+// Command baseplate is a command-line client for the Baseplate REST API.
+//
+// Usage:
+//
+//	baseplate [--server URL] [--team ID] <command> <subcommand> [flags]
+//
+// Credentials stored by 'baseplate login' are reused by every other command.
+// Results are printed as JSON so that they can be piped into other tools.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command runs one subcommand with the arguments after its name
+type command func(c *client, args []string) error
+
+// commands maps "<group> <subcommand>" to its implementation
+var commands = map[string]command{
+	"login":              runLogin,
+	"logout":             runLogout,
+	"whoami":             runWhoami,
+	"version":            runVersion,
+	"team list":          runTeamList,
+	"team create":        runTeamCreate,
+	"team use":           runTeamUse,
+	"team members":       runTeamMembers,
+	"team roles":         runTeamRoles,
+	"team add-member":    runTeamAddMember,
+	"team set-role":      runTeamSetRole,
+	"team remove-member": runTeamRemoveMember,
+	"blueprint list":     runBlueprintList,
+	"blueprint get":      runBlueprintGet,
+	"blueprint schema":   runBlueprintSchema,
+	"blueprint apply":    runBlueprintApply,
+	"entity list":        runEntityList,
+	"entity get":         runEntityGet,
+	"entity search":      runEntitySearch,
+	"entity upsert":      runEntityUpsert,
+	"apikey list":        runAPIKeyList,
+	"apikey create":      runAPIKeyCreate,
+	"apikey delete":      runAPIKeyDelete,
+}
+
+func main() {
+	flag.Usage = usage
+	server := flag.String("server", "", "API base URL (default: stored by login, or BASEPLATE_URL)")
+	team := flag.String("team", "", "team ID (default: selected by 'team use', or BASEPLATE_TEAM)")
+	flag.Parse()
+
+	name, run, args := lookup(flag.Args())
+	if run == nil {
+		usage()
+		os.Exit(2)
+	}
+
+	creds, err := loadCredentials()
+	if err != nil {
+		fatal(err)
+	}
+	if *server != "" {
+		creds.Server = strings.TrimRight(*server, "/")
+	}
+	if *team != "" {
+		creds.TeamID = *team
+	}
+
+	if err := run(newClient(creds), args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		fatal(fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+// lookup finds the command named by the first one or two arguments
+func lookup(args []string) (string, command, []string) {
+	if len(args) == 0 {
+		return "", nil, nil
+	}
+	if run, ok := commands[args[0]]; ok {
+		return args[0], run, args[1:]
+	}
+	if len(args) > 1 {
+		name := args[0] + " " + args[1]
+		if run, ok := commands[name]; ok {
+			return name, run, args[2:]
+		}
+	}
+	return "", nil, nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: baseplate [--server URL] [--team ID] <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %s\n", name)
+	}
+	fmt.Fprintln(os.Stderr, "\nRun 'baseplate <command> --help' for the flags of a command.")
+	fmt.Fprintln(os.Stderr, "\nGlobal flags:")
+	flag.PrintDefaults()
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "baseplate: %v\n", err)
+	os.Exit(1)
+}
+
+// printJSON writes v to stdout as indented JSON
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// newFlags returns a flag set for a command that reports errors instead of exiting
+func newFlags(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: baseplate %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags and checks the number of positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, want int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != want {
+		fs.Usage()
+		return nil, flag.ErrHelp
+	}
+	return fs.Args(), nil
+}
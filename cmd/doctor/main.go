@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/diagnostics"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "print findings as JSON")
+	timeout := flag.Duration("timeout", 10*time.Second, "overall timeout for database checks")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[FAIL] config.file  %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var findings []diagnostics.Finding
+	db, err := postgres.NewClient(&cfg.Database)
+	if err != nil {
+		findings = diagnostics.RunAll(ctx, cfg, nil)
+		findings = append(findings, diagnostics.Finding{
+			Check:    "database.connectivity",
+			Severity: diagnostics.SeverityFail,
+			Message:  err.Error(),
+			Hint:     "check DB_HOST, DB_PORT, DB_USER, DB_PASSWORD and DB_NAME",
+		})
+	} else {
+		defer db.Close()
+		findings = diagnostics.RunAll(ctx, cfg, db)
+	}
+
+	if *jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(findings)
+	} else {
+		printFindings(findings)
+	}
+
+	if diagnostics.HasFailures(findings) {
+		os.Exit(1)
+	}
+}
+
+func printFindings(findings []diagnostics.Finding) {
+	labels := map[diagnostics.Severity]string{
+		diagnostics.SeverityOK:   "[ OK ]",
+		diagnostics.SeverityWarn: "[WARN]",
+		diagnostics.SeverityFail: "[FAIL]",
+	}
+
+	counts := make(map[diagnostics.Severity]int)
+	for _, f := range findings {
+		counts[f.Severity]++
+		fmt.Printf("%s %-24s %s\n", labels[f.Severity], f.Check, f.Message)
+		if f.Hint != "" && f.Severity != diagnostics.SeverityOK {
+			fmt.Printf("       %-24s -> %s\n", "", f.Hint)
+		}
+	}
+	fmt.Printf("\n%d ok, %d warnings, %d failures\n",
+		counts[diagnostics.SeverityOK], counts[diagnostics.SeverityWarn], counts[diagnostics.SeverityFail])
+}
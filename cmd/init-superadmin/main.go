@@ -2,6 +2,10 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
@@ -9,27 +13,52 @@ import (
 
 	"github.com/baseplate/baseplate/config"
 	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/passwordinput"
 	"github.com/baseplate/baseplate/internal/storage/postgres"
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// minPasswordLength is the minimum password length accepted for super admin accounts
+const minPasswordLength = 12
+
+// generatedPasswordBytes is the amount of randomness used by --generate-password (32 base64 characters)
+const generatedPasswordBytes = 24
+
+type options struct {
+	email            string
+	passwordFile     string
+	generatePassword bool
+	rotatePassword   bool
+	check            bool
+}
+
 func main() {
-	// Read environment variables
-	superAdminEmail := os.Getenv("SUPER_ADMIN_EMAIL")
-	superAdminPassword := os.Getenv("SUPER_ADMIN_PASSWORD")
+	opts := parseFlags()
+
+	if opts.check {
+		os.Exit(runCheck(opts))
+	}
+
+	if opts.email == "" {
+		log.Fatal("super admin email is required (use --email or SUPER_ADMIN_EMAIL)")
+	}
 
-	if superAdminEmail == "" || superAdminPassword == "" {
-		log.Fatal("SUPER_ADMIN_EMAIL and SUPER_ADMIN_PASSWORD environment variables are required")
+	password, generated, err := resolvePassword(opts)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// Validate password strength for super admin account
-	if len(superAdminPassword) < 12 {
-		log.Fatal("SUPER_ADMIN_PASSWORD must be at least 12 characters long")
+	if len(password) < minPasswordLength {
+		log.Fatalf("super admin password must be at least %d characters long", minPasswordLength)
 	}
 
 	// Load database configuration
-	cfg := config.Load()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
 	// Connect to database
 	db, err := postgres.NewClient(&cfg.Database)
@@ -44,26 +73,38 @@ func main() {
 	authRepo := auth.NewRepository(db)
 
 	// Check if super admin already exists
-	existing, err := authRepo.GetUserByEmail(ctx, superAdminEmail)
+	existing, err := authRepo.GetUserByEmail(ctx, opts.email)
 	if err != nil {
 		log.Fatalf("Failed to check for existing user: %v", err)
 	}
 
+	if opts.rotatePassword {
+		if existing == nil || !existing.IsSuperAdmin {
+			log.Fatalf("Cannot rotate password: '%s' is not an existing super admin", opts.email)
+		}
+		if err := setPassword(ctx, authRepo, existing, password); err != nil {
+			log.Fatalf("Failed to rotate password: %v", err)
+		}
+		fmt.Printf("Rotated password for super admin '%s'\n", opts.email)
+		printGenerated(generated, password)
+		return
+	}
+
 	if existing != nil {
 		if existing.IsSuperAdmin {
-			fmt.Printf("Super admin user '%s' already exists\n", superAdminEmail)
+			fmt.Printf("Super admin user '%s' already exists (use --rotate-password to change its password)\n", opts.email)
 			os.Exit(0)
 		}
 		// Promote existing user to super admin
 		if err := promoteSuperAdmin(ctx, authRepo, existing.ID); err != nil {
 			log.Fatalf("Failed to promote existing user to super admin: %v", err)
 		}
-		fmt.Printf("Promoted existing user '%s' to super admin\n", superAdminEmail)
+		fmt.Printf("Promoted existing user '%s' to super admin (existing password unchanged)\n", opts.email)
 		return
 	}
 
 	// Create password hash
-	hash, err := bcrypt.GenerateFromPassword([]byte(superAdminPassword), bcrypt.DefaultCost)
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		log.Fatalf("Failed to hash password: %v", err)
 	}
@@ -72,7 +113,7 @@ func main() {
 	now := time.Now()
 	user := &auth.User{
 		ID:                   uuid.New(),
-		Email:                superAdminEmail,
+		Email:                opts.email,
 		PasswordHash:         string(hash),
 		Name:                 "Super Admin",
 		Status:               "active",
@@ -84,7 +125,132 @@ func main() {
 		log.Fatalf("Failed to create super admin user: %v", err)
 	}
 
-	fmt.Printf("Successfully created super admin user: %s\n", superAdminEmail)
+	fmt.Printf("Successfully created super admin user: %s\n", opts.email)
+	printGenerated(generated, password)
+}
+
+func parseFlags() *options {
+	opts := &options{}
+	flag.StringVar(&opts.email, "email", os.Getenv("SUPER_ADMIN_EMAIL"), "super admin email (defaults to SUPER_ADMIN_EMAIL)")
+	flag.StringVar(&opts.passwordFile, "password-file", "", "read the password from this file, or from stdin when set to '-'")
+	flag.BoolVar(&opts.generatePassword, "generate-password", false, "generate a random password and print it once")
+	flag.BoolVar(&opts.rotatePassword, "rotate-password", false, "replace the password of an existing super admin")
+	flag.BoolVar(&opts.check, "check", false, "only verify that a super admin exists; exits non-zero otherwise")
+	flag.Parse()
+	return opts
+}
+
+// resolvePassword determines the password source. Passwords passed through the
+// environment are still accepted for backwards compatibility but are visible in
+// process listings, so file/stdin input or generation is preferred.
+func resolvePassword(opts *options) (string, bool, error) {
+	sources := 0
+	if opts.passwordFile != "" {
+		sources++
+	}
+	if opts.generatePassword {
+		sources++
+	}
+	if sources > 1 {
+		return "", false, errors.New("--password-file and --generate-password are mutually exclusive")
+	}
+
+	switch {
+	case opts.generatePassword:
+		password, err := generatePassword()
+		return password, true, err
+	case opts.passwordFile == "-":
+		password, err := passwordinput.Read(os.Stdin)
+		return password, false, err
+	case opts.passwordFile != "":
+		f, err := os.Open(opts.passwordFile)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to open password file: %w", err)
+		}
+		defer f.Close()
+		password, err := passwordinput.Read(f)
+		return password, false, err
+	}
+
+	if password := os.Getenv("SUPER_ADMIN_PASSWORD"); password != "" {
+		log.Println("WARNING: reading SUPER_ADMIN_PASSWORD from the environment is deprecated; use --password-file or --generate-password")
+		return password, false, nil
+	}
+
+	return "", false, errors.New("no password provided (use --password-file, --generate-password, or SUPER_ADMIN_PASSWORD)")
+}
+
+func generatePassword() (string, error) {
+	raw := make([]byte, generatedPasswordBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate password: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// printGenerated prints a generated password exactly once; it is never stored in plaintext
+func printGenerated(generated bool, password string) {
+	if !generated {
+		return
+	}
+	fmt.Println("Generated password (shown only once, store it securely):")
+	fmt.Println(password)
+}
+
+// runCheck verifies super admin presence without modifying anything and returns the exit code.
+// When an email is provided, that specific user must be a super admin.
+func runCheck(opts *options) int {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check failed: %v\n", err)
+		return 2
+	}
+
+	db, err := postgres.NewClient(&cfg.Database)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check failed: cannot connect to database: %v\n", err)
+		return 2
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	authRepo := auth.NewRepository(db)
+
+	if opts.email != "" {
+		user, err := authRepo.GetUserByEmail(ctx, opts.email)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "check failed: %v\n", err)
+			return 2
+		}
+		if user == nil || !user.IsSuperAdmin {
+			fmt.Fprintf(os.Stderr, "check failed: '%s' is not a super admin\n", opts.email)
+			return 1
+		}
+		fmt.Printf("ok: '%s' is a super admin\n", opts.email)
+		return 0
+	}
+
+	count, err := authRepo.CountSuperAdmins(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "check failed: %v\n", err)
+		return 2
+	}
+	if count == 0 {
+		fmt.Fprintln(os.Stderr, "check failed: no super admin exists")
+		return 1
+	}
+	fmt.Printf("ok: %d super admin(s) exist\n", count)
+	return 0
+}
+
+// setPassword replaces the password hash of an existing user
+func setPassword(ctx context.Context, repo *auth.Repository, user *auth.User, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = string(hash)
+	return repo.UpdateUser(ctx, user)
 }
 
 // promoteSuperAdmin promotes an existing user to super admin
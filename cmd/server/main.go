@@ -1,29 +1,83 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/baseplate/baseplate/config"
 	"github.com/baseplate/baseplate/internal/api"
 	"github.com/baseplate/baseplate/internal/api/handlers"
+	"github.com/baseplate/baseplate/internal/artifacts"
+	"github.com/baseplate/baseplate/internal/buildinfo"
+	"github.com/baseplate/baseplate/internal/cache"
+	"github.com/baseplate/baseplate/internal/core/advisor"
+	"github.com/baseplate/baseplate/internal/core/archive"
 	"github.com/baseplate/baseplate/internal/core/auth"
 	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/bundle"
+	"github.com/baseplate/baseplate/internal/core/docs"
 	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/export"
+	"github.com/baseplate/baseplate/internal/core/integration"
+	"github.com/baseplate/baseplate/internal/core/notification"
+	"github.com/baseplate/baseplate/internal/core/presentation"
+	"github.com/baseplate/baseplate/internal/core/runner"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+	"github.com/baseplate/baseplate/internal/core/search"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/core/stats"
 	"github.com/baseplate/baseplate/internal/core/validation"
+	"github.com/baseplate/baseplate/internal/core/view"
+	"github.com/baseplate/baseplate/internal/diagnostics"
+	"github.com/baseplate/baseplate/internal/dlq"
+	"github.com/baseplate/baseplate/internal/events"
+	"github.com/baseplate/baseplate/internal/jobs"
+	"github.com/baseplate/baseplate/internal/logging"
+	"github.com/baseplate/baseplate/internal/metrics"
+	"github.com/baseplate/baseplate/internal/outbox"
+	"github.com/baseplate/baseplate/internal/status"
 	"github.com/baseplate/baseplate/internal/storage/postgres"
+	"github.com/baseplate/baseplate/internal/tasks"
 )
 
 func main() {
+	startedAt := time.Now()
+	configFile := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML config file (environment variables take precedence)")
+	showVersion := flag.Bool("version", false, "print the build version and exit")
+	flag.Parse()
+
+	build := buildinfo.Get()
+	if *showVersion {
+		fmt.Println(build)
+		return
+	}
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.LoadFrom(*configFile)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
-	// Validate critical configuration
-	if cfg.JWT.Secret == "" {
-		log.Fatalf("JWT_SECRET environment variable is required")
+	// Validate configuration, reporting every invalid field at once
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	// Route all logs through the runtime-adjustable logger
+	logs, err := logging.New(os.Stderr, cfg.Log.Level, cfg.Log.Format)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
 	}
+	logs.Install()
+	log.Printf("Baseplate %s", build)
 
 	// Connect to database
 	db, err := postgres.NewClient(&cfg.Database)
@@ -34,23 +88,203 @@ func main() {
 
 	log.Println("Connected to database")
 
+	// Verify extensions, migrations and secrets before serving requests, so a
+	// broken deployment fails here instead of with 500s later
+	if cfg.Server.StartupChecks != config.StartupChecksOff {
+		runStartupChecks(cfg, db)
+	}
+
 	// Initialize repositories
 	authRepo := auth.NewRepository(db)
-	blueprintRepo := blueprint.NewRepository(db)
 	entityRepo := entity.NewRepository(db)
+	blueprintRepo := blueprint.NewRepository(db, entityRepo)
+	scorecardRepo := scorecard.NewRepository(db)
 
 	// Initialize services
-	authService := auth.NewService(authRepo, &cfg.JWT)
-	blueprintService := blueprint.NewService(blueprintRepo)
+	bus := events.NewBus()
+	var permissionCache *auth.PermissionCache
+	if cfg.Permissions.CacheTTLSeconds > 0 {
+		permissionCache = auth.NewPermissionCache(cfg.Permissions.CacheTTL(), cfg.Permissions.CacheMaxEntries)
+		permissionCache.Subscribe(bus)
+	}
+	lookupCache, err := cache.New(cfg.Cache)
+	if err != nil {
+		log.Fatalf("Failed to set up the %s cache: %v", cfg.Cache.Backend, err)
+	}
+	authLookups := auth.NewLookupCache(lookupCache, cfg.Cache.APIKeyTTL(), cfg.Cache.RoleTTL())
+	authLookups.Subscribe(bus)
+	keyUsage := auth.NewKeyUsage(authRepo, cfg.APIKeys.LastUsedFlushInterval())
+	// Account emails share the notification SMTP settings; without them email
+	// changes are refused
+	authService := auth.NewService(authRepo, &cfg.JWT, &cfg.TwoFactor, permissionCache, authLookups, keyUsage, bus, notification.NewAccountMailer(cfg.Notifications))
+	var indexMaintainer *blueprint.IndexMaintainer
+	if cfg.Search.IndexMaintenanceSeconds > 0 {
+		indexMaintainer = blueprint.NewIndexMaintainer(db, blueprintRepo)
+		bus.Subscribe("blueprint.*", func(ctx context.Context, e events.Event) { indexMaintainer.Notify() })
+	}
+	blueprintService := blueprint.NewService(blueprintRepo, bus, lookupCache, cfg.Cache.BlueprintTTL())
 	validator := validation.NewValidator()
-	entityService := entity.NewService(entityRepo, blueprintService, validator)
+	searchGuard := entity.NewSearchGuard(entityRepo, cfg.Search)
+	var searchCache *entity.SearchCache
+	if cfg.Search.CacheTTLSeconds > 0 {
+		searchCache = entity.NewSearchCache(cfg.Search.CacheTTL(), cfg.Search.CacheMaxEntries)
+		searchCache.Subscribe(bus)
+	}
+	var rollups *entity.RollupMaintainer
+	if cfg.Rollups.RebuildSeconds > 0 {
+		rollups = entity.NewRollupMaintainer(entityRepo, blueprintRepo, scorecardRepo)
+		rollups.Subscribe(bus)
+	}
+	var usage *entity.UsageRecorder
+	if cfg.Search.UsageRetentionDays > 0 {
+		usage = entity.NewUsageRecorder(entityRepo, cfg.Search.UsageRetentionDays)
+	}
+	entityService := entity.NewService(entityRepo, blueprintService, validator, searchGuard, searchCache, rollups, usage, bus)
+	var expiry *entity.ExpirySweeper
+	if cfg.Expiry.SweepSeconds > 0 {
+		expiry = entity.NewExpirySweeper(entityService)
+		expiry.Subscribe(bus)
+	}
+	var rollupProperties *entity.RollupPropertyUpdater
+	if cfg.Rollups.PropertySeconds > 0 {
+		rollupProperties = entity.NewRollupPropertyUpdater(entityService, blueprintRepo)
+		rollupProperties.Subscribe(bus)
+	}
+
+	// Background jobs; handlers are registered before the workers start
+	jobQueue := jobs.NewQueue(jobs.NewRepository(db), cfg.Jobs)
 
 	// Initialize handlers
 	authHandler := handlers.NewAuthHandler(authService)
-	teamHandler := handlers.NewTeamHandler(authService)
+	bundleService := bundle.NewService(bundle.NewRepository(db), blueprintService, scorecardRepo, bus)
+	teamHandler := handlers.NewTeamHandler(authService, archive.NewService(bundleService, entityService))
 	blueprintHandler := handlers.NewBlueprintHandler(blueprintService)
-	entityHandler := handlers.NewEntityHandler(entityService)
-	adminHandler := handlers.NewAdminHandler(authService)
+	viewService := view.NewService(view.NewRepository(db), blueprintService)
+	entityHandler := handlers.NewEntityHandler(entityService, viewService)
+	exportStore, err := artifacts.New(cfg.Exports)
+	if err != nil {
+		log.Fatalf("Failed to initialize export storage: %v", err)
+	}
+	exportService := export.NewService(export.NewRepository(db), entityService, exportStore, jobQueue, cfg.Exports)
+	exportHandler := handlers.NewExportHandler(exportService)
+	viewHandler := handlers.NewViewHandler(viewService)
+	presentationHandler := handlers.NewPresentationHandler(presentation.NewService(presentation.NewRepository(db), blueprintService))
+	docsHandler := handlers.NewDocsHandler(docs.NewService(docs.NewRepository(db), blueprintService, entityService))
+	secretService, err := secret.NewService(secret.NewRepository(db), cfg.Secrets.Key(), authService)
+	if err != nil {
+		log.Fatalf("Failed to initialize secrets store: %v", err)
+	}
+	secretHandler := handlers.NewSecretHandler(secretService)
+	runnerService := runner.NewService(runner.NewRepository(db), secretService, entityService, authService)
+	scheduler := runner.NewScheduler(runnerService, jobQueue)
+	runnerHandler := handlers.NewRunnerHandler(runnerService)
+	integrationService := integration.NewService(integration.NewRepository(db), entityService, secretService, jobQueue)
+	integrationHandler := handlers.NewIntegrationHandler(integrationService)
+	bundleHandler := handlers.NewBundleHandler(bundleService)
+	reloader := config.NewReloader(*configFile, cfg)
+	reloader.Subscribe(func(c *config.Config) { searchGuard.UpdateLimits(c.Search) })
+	// Only a changed log section overrides a level set through the admin API
+	logLevel, logFormat := cfg.Log.Level, cfg.Log.Format
+	reloader.Subscribe(func(c *config.Config) {
+		if c.Log.Level == logLevel && c.Log.Format == logFormat {
+			return
+		}
+		logLevel, logFormat = c.Log.Level, c.Log.Format
+		if err := logs.Set(c.Log.Level, c.Log.Format); err != nil {
+			log.Printf("ERROR: %v", err)
+		}
+	})
+	adminHandler := handlers.NewAdminHandler(authService, reloader, logs)
+	grafanaHandler := handlers.NewGrafanaHandler(blueprintService, entityService)
+	jobHandler := handlers.NewJobHandler(jobQueue)
+
+	var metricsHandler *handlers.MetricsHandler
+	if cfg.Metrics.Enabled {
+		registry := metrics.NewRegistry()
+		var rollupRepo *entity.Repository
+		if rollups != nil {
+			rollupRepo = entityRepo
+		}
+		catalog := metrics.NewCatalogCollector(db, scorecardRepo, rollupRepo, cfg.Metrics.CatalogRefresh())
+		metricsHandler = handlers.NewMetricsHandler(registry, cfg.Metrics.Token, catalog)
+		if cfg.Metrics.Token == "" {
+			log.Println("WARNING: METRICS_TOKEN is not set; /metrics serves server metrics only, without catalog metrics")
+		}
+	}
+
+	statusService := status.NewService(build.Version, startedAt)
+	statusService.Register("database", true, status.Database(db))
+	statusService.Register("cache", false, status.Cache(searchCache, permissionCache, lookupCache))
+	statusService.Register("queue", false, status.Queue(rollups))
+	statusService.Register("search", false, status.Search(indexMaintainer))
+	statusService.Register("jobs", false, status.Jobs(jobQueue))
+	statusService.Register("schedules", false, status.Schedules(scheduler))
+	statusService.Register("integrations", false, status.Integrations(map[string]bool{
+		"grafana": true,
+		"metrics": cfg.Metrics.Enabled,
+	}))
+	statusHandler := handlers.NewStatusHandler(statusService)
+
+	// Usage statistics; requests are only counted when they are kept
+	statsRepo := stats.NewRepository(db)
+	var requestRecorder *stats.RequestRecorder
+	if cfg.Stats.RequestRetentionDays > 0 {
+		requestRecorder = stats.NewRequestRecorder(statsRepo, cfg.Stats.RequestRetentionDays)
+	}
+	statsService := stats.NewService(statsRepo, requestRecorder != nil)
+	statsHandler := handlers.NewStatsHandler(statsService, requestRecorder)
+	searchHandler := handlers.NewSearchHandler(search.NewService(search.NewRepository(db)))
+	indexAdvisor := advisor.NewService(advisor.NewRepository(db), blueprintService, cfg.Search)
+	advisorHandler := handlers.NewIndexAdvisorHandler(indexAdvisor)
+
+	scorecardService := scorecard.NewService(scorecardRepo)
+	scorecardHandler := handlers.NewScorecardHandler(scorecardService)
+
+	notificationService := notification.NewService(notification.NewRepository(db), secretService,
+		notification.NewEmailSender(cfg.Notifications), notification.NewSlackSender())
+	notificationHandler := handlers.NewNotificationHandler(notificationService)
+
+	// Built-in maintenance tasks
+	taskEngine := tasks.NewEngine(tasks.NewRepository(db), jobQueue)
+	taskEngine.Register(tasks.TaskScorecards, "Record changed scorecard results and recalculate the levels in the entity rollups",
+		cfg.Tasks.ScorecardsCron, tasks.RecalculateScorecards(scorecardService, rollups))
+	taskEngine.Register(tasks.TaskIntegrations, "Mark integrations whose exporter stopped syncing as stale",
+		cfg.Tasks.IntegrationsCron, tasks.CheckIntegrationSyncs(integrationService, cfg.Tasks.IntegrationStaleAfter()))
+	taskEngine.Register(tasks.TaskGitHubSync, "Sync the repositories of every GitHub integration into their blueprints",
+		cfg.Tasks.GitHubSyncCron, tasks.SyncIntegrations(integrationService, integration.TypeGitHub))
+	taskEngine.Register(tasks.TaskKubernetesSync, "Sync the namespaces, deployments and services of every Kubernetes integration's cluster",
+		cfg.Tasks.KubernetesSyncCron, tasks.SyncIntegrations(integrationService, integration.TypeKubernetes))
+	taskEngine.Register(tasks.TaskPrometheusSync, "Write the PromQL query results of every Prometheus integration into its entities",
+		cfg.Tasks.PrometheusSyncCron, tasks.SyncIntegrations(integrationService, integration.TypePrometheus))
+	taskEngine.Register(tasks.TaskPagerDutySync, "Sync the services of every PagerDuty integration and who is on call for them",
+		cfg.Tasks.PagerDutySyncCron, tasks.SyncIntegrations(integrationService, integration.TypePagerDuty))
+	taskEngine.Register(tasks.TaskUsageReport, "Generate the platform usage report of the last week",
+		cfg.Tasks.ReportsCron, tasks.UsageReport(statsService))
+	taskEngine.Register(tasks.TaskExports, "Delete entity exports past their expiry with their files",
+		cfg.Tasks.ExportsCron, tasks.DeleteExpiredExports(exportService))
+	if usage != nil {
+		taskEngine.Register(tasks.TaskIndexAdvisor, "Recommend indexes for much-searched properties, applying them when auto-apply is on",
+			cfg.Tasks.IndexAdvisorCron, tasks.AdviseIndexes(indexAdvisor))
+	}
+	taskEngine.Register(tasks.TaskNotifications, "Collect action failures and scorecard degradations and deliver due notifications",
+		cfg.Tasks.NotificationsCron, tasks.DeliverNotifications(notificationService))
+	taskHandler := handlers.NewTaskHandler(taskEngine)
+	statusService.Register("tasks", false, status.Tasks(taskEngine))
+
+	// Delivery of the events committed with entity and blueprint writes
+	outboxRepo := outbox.NewRepository(db)
+	dispatcher := outbox.NewDispatcher(outboxRepo, jobQueue, cfg.Outbox)
+	if cfg.Outbox.NotifyChannel != "" {
+		dispatcher.Register(outbox.ConsumerNotify, outbox.Notify(outboxRepo, cfg.Outbox.NotifyChannel))
+	}
+	dispatcher.Register(notification.ConsumerName, notificationService.Consumer())
+	outboxHandler := handlers.NewOutboxHandler(dispatcher)
+	statusService.Register("outbox", false, status.Outbox(dispatcher))
+
+	// Dead jobs and events, kept until a super admin retries or discards them
+	dlqService := dlq.NewService(jobQueue, dispatcher, cfg.DLQ.AlertAfter())
+	dlqHandler := handlers.NewDLQHandler(dlqService)
+	statusService.Register("dlq", false, status.DeadLetters(dlqService))
 
 	// Setup router
 	router := api.NewRouter(
@@ -59,24 +293,131 @@ func main() {
 		teamHandler,
 		blueprintHandler,
 		entityHandler,
+		exportHandler,
+		viewHandler,
+		presentationHandler,
+		docsHandler,
+		bundleHandler,
+		integrationHandler,
 		adminHandler,
+		grafanaHandler,
+		metricsHandler,
+		statusHandler,
+		statsHandler,
+		searchHandler,
+		advisorHandler,
+		secretHandler,
+		runnerHandler,
+		jobHandler,
+		taskHandler,
+		outboxHandler,
+		dlqHandler,
+		scorecardHandler,
+		notificationHandler,
 	)
 
-	engine := router.Setup(cfg.Server.Mode)
+	engine := router.Setup(cfg)
+	reloader.Subscribe(router.ApplyConfig)
 
-	// Graceful shutdown
+	// Background workers stop when ctx is cancelled; workers tracks them so
+	// shutdown waits for their final writes before closing the database
+	ctx, cancel := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	start := func(run func()) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run()
+		}()
+	}
+	if indexMaintainer != nil {
+		start(func() { indexMaintainer.Run(ctx, cfg.Search.IndexMaintenanceInterval()) })
+	}
+	if usage != nil {
+		start(func() { usage.Run(ctx) })
+	}
+	if requestRecorder != nil {
+		start(func() { requestRecorder.Run(ctx) })
+	}
+	if rollups != nil {
+		start(func() { rollups.Run(ctx, cfg.Rollups.RebuildInterval()) })
+	}
+	if expiry != nil {
+		start(func() { expiry.Run(ctx, cfg.Expiry.SweepInterval()) })
+	}
+	if rollupProperties != nil {
+		start(func() { rollupProperties.Run(ctx, cfg.Rollups.PropertyInterval()) })
+	}
+	start(func() { keyUsage.Run(ctx) })
+	start(func() { jobQueue.Run(ctx) })
+	start(func() { scheduler.Run(ctx, runner.SchedulerInterval) })
+	start(func() { taskEngine.Run(ctx, tasks.Interval) })
+	if cfg.Outbox.PollSeconds > 0 {
+		start(func() { dispatcher.Run(ctx) })
+	}
+
+	// Reload non-critical settings on SIGHUP
 	go func() {
-		quit := make(chan os.Signal, 1)
-		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-		<-quit
-		log.Println("Shutting down server...")
-		db.Close()
-		os.Exit(0)
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			result, err := reloader.Reload()
+			if err != nil {
+				log.Printf("ERROR: configuration reload failed, keeping the current configuration: %v", err)
+				continue
+			}
+			log.Printf("Configuration reloaded: applied %v, restart required for %v", result.Applied, result.RestartRequired)
+		}
 	}()
 
 	// Start server
-	log.Printf("Starting server on port %s", cfg.Server.Port)
-	if err := engine.Run(":" + cfg.Server.Port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	srv := &http.Server{Addr: ":" + cfg.Server.Port, Handler: engine}
+	go func() {
+		log.Printf("Starting server on port %s", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Graceful shutdown: finish in-flight requests, then stop the workers and
+	// wait for their final writes; the deferred db.Close runs last
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+	shutdownCtx, stop := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer stop()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("ERROR: server shutdown: %v", err)
+	}
+	cancel()
+	workers.Wait()
+	log.Println("Server stopped")
+}
+
+// shutdownTimeout bounds how long shutdown waits for in-flight requests
+const shutdownTimeout = 30 * time.Second
+
+// startupCheckTimeout bounds the database queries run by the startup checks
+const startupCheckTimeout = 10 * time.Second
+
+// runStartupChecks logs warnings and exits on failures unless STARTUP_CHECKS=warn
+func runStartupChecks(cfg *config.Config, db *postgres.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), startupCheckTimeout)
+	defer cancel()
+
+	findings := diagnostics.Startup(ctx, cfg, db)
+	if warnings := diagnostics.Summarize(findings, diagnostics.SeverityWarn); warnings != "" {
+		log.Printf("WARNING: startup checks:%s", warnings)
+	}
+	if !diagnostics.HasFailures(findings) {
+		log.Println("Startup checks passed")
+		return
+	}
+	failures := diagnostics.Summarize(findings, diagnostics.SeverityFail)
+	if cfg.Server.StartupChecks == config.StartupChecksWarn {
+		log.Printf("ERROR: startup checks failed (STARTUP_CHECKS=warn, starting anyway):%s", failures)
+		return
 	}
+	log.Fatalf("Startup checks failed:%s\nFix the problems above, or set STARTUP_CHECKS=warn to start anyway", failures)
 }
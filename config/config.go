@@ -1,62 +1,1058 @@
 package config
 
 import (
+	"encoding/base64"
+	"fmt"
+	"net/mail"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/baseplate/baseplate/internal/cron"
 )
 
+// MinJWTSecretLength is the minimum accepted length of the HS256 signing secret in bytes
+const MinJWTSecretLength = 32
+
+// MaxImpersonationMinutes bounds JWT_IMPERSONATION_MINUTES so impersonation stays short-lived
+const MaxImpersonationMinutes = 240
+
+// MaxMembershipClaimTeams bounds JWT_MEMBERSHIP_CLAIM_TEAMS so tokens stay small enough for headers
+const MaxMembershipClaimTeams = 50
+
+// notifyChannelPattern matches channel names LISTEN accepts without quoting
+var notifyChannelPattern = regexp.MustCompile(`^[a-z_][a-z0-9_]{0,62}$`)
+
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
+	Server        ServerConfig        `yaml:"server"`
+	Database      DatabaseConfig      `yaml:"database"`
+	JWT           JWTConfig           `yaml:"jwt"`
+	APIKeys       APIKeyConfig        `yaml:"api_keys"`
+	Metrics       MetricsConfig       `yaml:"metrics"`
+	CORS          CORSConfig          `yaml:"cors"`
+	Search        SearchConfig        `yaml:"search"`
+	Rollups       RollupConfig        `yaml:"rollups"`
+	Expiry        ExpiryConfig        `yaml:"expiry"`
+	Jobs          JobsConfig          `yaml:"jobs"`
+	Tasks         TasksConfig         `yaml:"tasks"`
+	Outbox        OutboxConfig        `yaml:"outbox"`
+	DLQ           DLQConfig           `yaml:"dlq"`
+	Permissions   PermissionConfig    `yaml:"permissions"`
+	Cache         CacheConfig         `yaml:"cache"`
+	Log           LogConfig           `yaml:"log"`
+	Audit         AuditConfig         `yaml:"audit"`
+	TwoFactor     TwoFactorConfig     `yaml:"two_factor"`
+	Stats         StatsConfig         `yaml:"stats"`
+	Secrets       SecretsConfig       `yaml:"secrets"`
+	Exports       ExportsConfig       `yaml:"exports"`
+	Notifications NotificationsConfig `yaml:"notifications"`
+
+	// problems collects values that could not be parsed while loading.
+	// They are reported by Validate together with any other invalid fields.
+	problems []FieldError
 }
 
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port string `yaml:"port"`
+	Mode string `yaml:"mode"`
+	// StartupChecks decides what failed startup checks do: "enforce" refuses
+	// to start, "warn" only logs them and "off" skips the checks
+	StartupChecks string `yaml:"startup_checks"`
+	// TrustedProxies are the addresses or CIDR ranges of the proxies whose
+	// X-Forwarded-For headers give the client IP; empty trusts none and
+	// uses the connecting address
+	TrustedProxies []string `yaml:"trusted_proxies"`
 }
 
+// Startup check modes
+const (
+	StartupChecksEnforce = "enforce"
+	StartupChecksWarn    = "warn"
+	StartupChecksOff     = "off"
+)
+
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host     string `yaml:"host"`
+	Port     string `yaml:"port"`
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+	DBName   string `yaml:"name"`
+	SSLMode  string `yaml:"ssl_mode"`
+	// ReplicaURL is a libpq connection string or URL for a read replica.
+	// Read-only catalog queries go to it when set.
+	ReplicaURL string `yaml:"replica_url"`
+	// StatementCacheSize is how many prepared statements each connection
+	// keeps. 0 prepares nothing, for poolers such as PgBouncer in transaction
+	// mode that cannot keep statements between transactions.
+	StatementCacheSize int `yaml:"statement_cache_size"`
+	// StatementTimeoutSeconds is the statement_timeout of every connection,
+	// cancelling any query that runs longer on the server; 0 leaves the
+	// server's setting
+	StatementTimeoutSeconds int `yaml:"statement_timeout_seconds"`
+	// QueryTimeoutSeconds bounds catalog reads (entity lists, searches,
+	// aggregates and history) from the application side; 0 disables it
+	QueryTimeoutSeconds int `yaml:"query_timeout_seconds"`
 }
 
 type JWTConfig struct {
-	Secret          string
-	ExpirationHours int
+	Secret          string `yaml:"secret"`
+	ExpirationHours int    `yaml:"expiration_hours"`
+	// MembershipClaimTeams is how many team memberships are embedded in issued
+	// tokens so team requests can skip the permission lookup; 0 disables it
+	MembershipClaimTeams int `yaml:"membership_claim_teams"`
+	// MembershipClaimTTLMinutes is how long embedded memberships are trusted
+	// before requests fall back to the database again
+	MembershipClaimTTLMinutes int `yaml:"membership_claim_ttl_minutes"`
+	// ImpersonationMinutes is the longest a super admin impersonation token
+	// may live; admins can ask for less
+	ImpersonationMinutes int `yaml:"impersonation_minutes"`
+}
+
+// APIKeyConfig controls how API key requests are validated
+type APIKeyConfig struct {
+	// LastUsedFlushSeconds is how often the last use of keys is written
+	LastUsedFlushSeconds int `yaml:"last_used_flush_seconds"`
+	// FailuresPerMinute and FailureBurst limit invalid keys per client IP:
+	// after FailureBurst invalid keys, FailuresPerMinute more are accepted
+	// each minute. 0 disables the limit.
+	FailuresPerMinute int `yaml:"failures_per_minute"`
+	FailureBurst      int `yaml:"failure_burst"`
+}
+
+func (a *APIKeyConfig) LastUsedFlushInterval() time.Duration {
+	return time.Duration(a.LastUsedFlushSeconds) * time.Second
+}
+
+type MetricsConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Token, when set, is required as a bearer token to scrape /metrics.
+	// Catalog metrics, labelled with team IDs, are served only with a token.
+	Token string `yaml:"token"`
+	// CatalogRefreshSeconds is how long catalog metrics (entity counts, scorecards) are cached
+	CatalogRefreshSeconds int `yaml:"catalog_refresh_seconds"`
+}
+
+func (m *MetricsConfig) CatalogRefresh() time.Duration {
+	return time.Duration(m.CatalogRefreshSeconds) * time.Second
+}
+
+// CORSConfig controls cross-origin access for browser clients. With no allowed
+// origins configured, no CORS headers are sent and browsers block cross-origin calls.
+type CORSConfig struct {
+	// AllowedOrigins are exact origins ("https://app.example.com"), subdomain
+	// wildcards ("https://*.example.com") or "*" for any origin
+	AllowedOrigins   []string `yaml:"allowed_origins"`
+	AllowedMethods   []string `yaml:"allowed_methods"`
+	AllowedHeaders   []string `yaml:"allowed_headers"`
+	ExposedHeaders   []string `yaml:"exposed_headers"`
+	AllowCredentials bool     `yaml:"allow_credentials"`
+	MaxAgeSeconds    int      `yaml:"max_age_seconds"`
+}
+
+// SearchConfig limits expensive entity searches and aggregates so a single
+// dashboard cannot saturate the database. Limits apply per team.
+type SearchConfig struct {
+	// LargeBlueprintEntities is the entity count above which unindexable
+	// operators (contains, neq, property ordering, aggregates) count as expensive
+	LargeBlueprintEntities int `yaml:"large_blueprint_entities"`
+	// ExpensivePerMinute and ExpensiveConcurrency limit expensive searches; 0 disables the limit
+	ExpensivePerMinute   int `yaml:"expensive_per_minute"`
+	ExpensiveConcurrency int `yaml:"expensive_concurrency"`
+	// MaxOffset is the largest offset accepted by search; deeper pages must narrow the filters
+	MaxOffset int `yaml:"max_offset"`
+	// IndexMaintenanceSeconds is how often JSONB indexes are reconciled with
+	// blueprint schemas (in addition to after every schema change); 0 disables maintenance
+	IndexMaintenanceSeconds int `yaml:"index_maintenance_seconds"`
+	// CacheTTLSeconds is how long identical search and aggregate results are
+	// reused; 0 disables the cache. CacheMaxEntries bounds its size.
+	CacheTTLSeconds int `yaml:"cache_ttl_seconds"`
+	CacheMaxEntries int `yaml:"cache_max_entries"`
+	// UsageRetentionDays is how long daily property usage counts are kept;
+	// 0 disables usage tracking
+	UsageRetentionDays int `yaml:"usage_retention_days"`
+	// IndexAdvisorMinUses is how often over the last 30 days searches must
+	// filter or sort on a property before the index advisor recommends
+	// indexing it
+	IndexAdvisorMinUses int `yaml:"index_advisor_min_uses"`
+	// IndexAdvisorMinEntities is the entity count below which blueprints get
+	// no index recommendations
+	IndexAdvisorMinEntities int `yaml:"index_advisor_min_entities"`
+	// IndexAdvisorAutoApply marks recommended properties indexed whenever the
+	// advisor task runs, instead of only reporting them
+	IndexAdvisorAutoApply bool `yaml:"index_advisor_auto_apply"`
+}
+
+func (s *SearchConfig) IndexMaintenanceInterval() time.Duration {
+	return time.Duration(s.IndexMaintenanceSeconds) * time.Second
+}
+
+func (s *SearchConfig) CacheTTL() time.Duration {
+	return time.Duration(s.CacheTTLSeconds) * time.Second
+}
+
+// RollupConfig controls the precomputed counts behind aggregations
+type RollupConfig struct {
+	// RebuildSeconds is how often rollups are recomputed from scratch to correct
+	// drift; 0 disables rollups and aggregations always query entities
+	RebuildSeconds int `yaml:"rebuild_seconds"`
+	// PropertySeconds is how often rollup properties are recomputed for
+	// every entity, picking up relation changes; 0 disables rollup properties
+	PropertySeconds int `yaml:"property_seconds"`
+}
+
+func (r *RollupConfig) RebuildInterval() time.Duration {
+	return time.Duration(r.RebuildSeconds) * time.Second
+}
+
+func (r *RollupConfig) PropertyInterval() time.Duration {
+	return time.Duration(r.PropertySeconds) * time.Second
+}
+
+// ExpiryConfig controls the sweeper that expires entities by their
+// blueprint's expiry policy
+type ExpiryConfig struct {
+	// SweepSeconds is how often expired entities are deleted or archived;
+	// 0 disables expiry, and entities then only record when they expire
+	SweepSeconds int `yaml:"sweep_seconds"`
+}
+
+func (e *ExpiryConfig) SweepInterval() time.Duration {
+	return time.Duration(e.SweepSeconds) * time.Second
+}
+
+// JobsConfig controls the workers of the background job queue
+type JobsConfig struct {
+	// Workers is how many jobs this instance runs at once; 0 runs none, so
+	// jobs queued here are left to other instances
+	Workers int `yaml:"workers"`
+	// PollSeconds is how often idle workers look for due jobs. Jobs queued
+	// by this instance wake them at once.
+	PollSeconds int `yaml:"poll_seconds"`
+	// MaxAttempts is how often a failing job runs before it is dead
+	MaxAttempts int `yaml:"max_attempts"`
+	// RetentionDays is how long succeeded jobs are kept; 0 keeps them.
+	// Dead jobs are kept until they are retried.
+	RetentionDays int `yaml:"retention_days"`
+}
+
+func (j *JobsConfig) PollInterval() time.Duration {
+	return time.Duration(j.PollSeconds) * time.Second
+}
+
+// TasksConfig schedules the built-in maintenance tasks with cron expressions
+// in UTC; "off" disables a task. A schedule set through the admin API
+// overrides these.
+type TasksConfig struct {
+	// ScorecardsCron records changed scorecard results for their history and
+	// recalculates scorecard levels in the entity rollups, if enabled
+	ScorecardsCron string `yaml:"scorecards_cron"`
+	// IntegrationsCron checks for integrations whose exporter stopped syncing
+	IntegrationsCron string `yaml:"integrations_cron"`
+	// GitHubSyncCron queues a full sync of every GitHub integration, which
+	// webhooks otherwise keep up to date
+	GitHubSyncCron string `yaml:"github_sync_cron"`
+	// KubernetesSyncCron syncs every Kubernetes integration's cluster
+	KubernetesSyncCron string `yaml:"kubernetes_sync_cron"`
+	// PrometheusSyncCron writes the query results of every Prometheus
+	// integration into its entities
+	PrometheusSyncCron string `yaml:"prometheus_sync_cron"`
+	// PagerDutySyncCron syncs every PagerDuty integration's services and
+	// who is on call for them
+	PagerDutySyncCron string `yaml:"pagerduty_sync_cron"`
+	// ReportsCron generates the weekly platform usage report
+	ReportsCron string `yaml:"reports_cron"`
+	// ExportsCron deletes export files past their expiry
+	ExportsCron string `yaml:"exports_cron"`
+	// NotificationsCron collects action failures and scorecard degradations
+	// and delivers due notifications and digests
+	NotificationsCron string `yaml:"notifications_cron"`
+	// IndexAdvisorCron computes index recommendations, applying them when
+	// auto-apply is on
+	IndexAdvisorCron string `yaml:"index_advisor_cron"`
+	// IntegrationStaleHours is how long an active integration may go without
+	// a sync before it is marked stale
+	IntegrationStaleHours int `yaml:"integration_stale_hours"`
+}
+
+func (t *TasksConfig) IntegrationStaleAfter() time.Duration {
+	return time.Duration(t.IntegrationStaleHours) * time.Hour
+}
+
+// OutboxConfig controls the dispatcher of the event outbox, which delivers
+// the events of committed entity and blueprint writes to its consumers
+type OutboxConfig struct {
+	// PollSeconds is how often the dispatcher looks for pending events; 0
+	// runs no dispatcher on this instance, leaving events to other instances
+	PollSeconds int `yaml:"poll_seconds"`
+	// BatchSize is how many events the dispatcher claims at once
+	BatchSize int `yaml:"batch_size"`
+	// MaxAttempts is how often delivery of an event is tried before it is dead
+	MaxAttempts int `yaml:"max_attempts"`
+	// RetentionDays is how long dispatched events are kept; 0 keeps them.
+	// Dead events are kept for inspection.
+	RetentionDays int `yaml:"retention_days"`
+	// NotifyChannel, when set, is the PostgreSQL channel every event is
+	// announced on with NOTIFY
+	NotifyChannel string `yaml:"notify_channel"`
+}
+
+func (o *OutboxConfig) PollInterval() time.Duration {
+	return time.Duration(o.PollSeconds) * time.Second
+}
+
+// DLQConfig controls the alerts on dead jobs and outbox events
+type DLQConfig struct {
+	// AlertHours is how long an item may stay dead before the status
+	// endpoint reports it; 0 turns the alert off
+	AlertHours int `yaml:"alert_hours"`
+}
+
+func (d *DLQConfig) AlertAfter() time.Duration {
+	return time.Duration(d.AlertHours) * time.Hour
+}
+
+// PermissionConfig controls the cache of team permissions resolved per request
+type PermissionConfig struct {
+	// CacheTTLSeconds is how long a user's permissions in a team are reused;
+	// 0 disables the cache. Changes made through this instance invalidate
+	// entries immediately, so the TTL bounds staleness across instances.
+	CacheTTLSeconds int `yaml:"cache_ttl_seconds"`
+	CacheMaxEntries int `yaml:"cache_max_entries"`
+}
+
+func (p *PermissionConfig) CacheTTL() time.Duration {
+	return time.Duration(p.CacheTTLSeconds) * time.Second
+}
+
+// Cache backends
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+	CacheBackendOff    = "off"
+)
+
+// CacheConfig controls the lookup cache for blueprints, API keys and roles.
+// Writes made through any instance drop their entries at once; with the
+// memory backend, TTLs bound how long other instances serve the old value.
+type CacheConfig struct {
+	// Backend is memory (per instance), redis (shared) or off
+	Backend string `yaml:"backend"`
+	// RedisURL is a redis:// or rediss:// URL, required for the redis backend
+	RedisURL string `yaml:"redis_url"`
+	// KeyPrefix is prepended to every Redis key
+	KeyPrefix string `yaml:"key_prefix"`
+	// MaxEntries bounds the memory backend
+	MaxEntries int `yaml:"max_entries"`
+	// TTLs per lookup in seconds; 0 stops caching that lookup
+	BlueprintTTLSeconds int `yaml:"blueprint_ttl_seconds"`
+	APIKeyTTLSeconds    int `yaml:"api_key_ttl_seconds"`
+	RoleTTLSeconds      int `yaml:"role_ttl_seconds"`
+}
+
+func (c *CacheConfig) BlueprintTTL() time.Duration {
+	return time.Duration(c.BlueprintTTLSeconds) * time.Second
 }
 
-func Load() *Config {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		panic("JWT_SECRET environment variable is required and cannot be empty")
+func (c *CacheConfig) APIKeyTTL() time.Duration {
+	return time.Duration(c.APIKeyTTLSeconds) * time.Second
+}
+
+func (c *CacheConfig) RoleTTL() time.Duration {
+	return time.Duration(c.RoleTTLSeconds) * time.Second
+}
+
+// LogConfig sets the initial log level and format; both can be changed at
+// runtime through the admin API
+type LogConfig struct {
+	// Level is debug, info, warn or error
+	Level string `yaml:"level"`
+	// Format is json (one object per line) or console (key=value text)
+	Format string `yaml:"format"`
+	// AccessSamplePercent is the share of 2xx responses written to the access
+	// log; other responses are always logged
+	AccessSamplePercent int `yaml:"access_sample_percent"`
+	// AccessPayloads adds scrubbed request headers and JSON bodies to the
+	// access log
+	AccessPayloads bool `yaml:"access_payloads"`
+}
+
+// AuditConfig controls what the audit trail records besides the built-in
+// super admin actions
+type AuditConfig struct {
+	// CaptureAdminBodies records every super admin API request together with
+	// its request and response bodies, secrets redacted
+	CaptureAdminBodies bool `yaml:"capture_admin_bodies"`
+	// MaxBodyBytes caps each captured body; larger bodies are recorded by size
+	MaxBodyBytes int `yaml:"max_body_bytes"`
+}
+
+// TwoFactorConfig controls TOTP two-factor authentication
+type TwoFactorConfig struct {
+	// Issuer names the account in authenticator apps
+	Issuer string `yaml:"issuer"`
+	// RequireForManagers makes every member holding team:manage enroll in
+	// two-factor authentication before using that team. Teams can also opt
+	// in individually.
+	RequireForManagers bool `yaml:"require_for_managers"`
+}
+
+// StatsConfig controls the usage statistics shown to super admins
+type StatsConfig struct {
+	// RequestRetentionDays is how long daily per-team request counts are
+	// kept; 0 disables request counting
+	RequestRetentionDays int `yaml:"request_retention_days"`
+}
+
+// SecretsConfig controls the team secrets store
+type SecretsConfig struct {
+	// EncryptionKey is the base64 encoded 32-byte AES-256 key secret values
+	// are encrypted with. Without it the secrets store is unavailable.
+	EncryptionKey string `yaml:"encryption_key"`
+}
+
+// Key returns the decoded encryption key, or nil when none is set
+func (s *SecretsConfig) Key() []byte {
+	key, err := base64.StdEncoding.DecodeString(s.EncryptionKey)
+	if err != nil || len(key) == 0 {
+		return nil
 	}
+	return key
+}
 
+// Export storage backends
+const (
+	ExportStorageLocal = "local"
+	ExportStorageS3    = "s3"
+)
+
+// ExportsConfig controls entity exports too large to stream in a response.
+// They run as background jobs writing a file to the configured storage,
+// which clients download until it expires.
+type ExportsConfig struct {
+	// AsyncThreshold is how many entities an export may have and still be
+	// streamed in the response; 0 streams every export
+	AsyncThreshold int `yaml:"async_threshold"`
+	// ExpiryHours is how long an export file can be downloaded
+	ExpiryHours int `yaml:"expiry_hours"`
+	// Storage is local (a directory shared by every instance) or s3
+	Storage string `yaml:"storage"`
+	// Dir holds the files of the local storage
+	Dir string `yaml:"dir"`
+	// S3 storage: any S3-compatible service. Endpoint defaults to AWS;
+	// path-style addressing is needed by most other services.
+	S3Bucket          string `yaml:"s3_bucket"`
+	S3Region          string `yaml:"s3_region"`
+	S3Endpoint        string `yaml:"s3_endpoint"`
+	S3AccessKeyID     string `yaml:"s3_access_key_id"`
+	S3SecretAccessKey string `yaml:"s3_secret_access_key"`
+	S3PathStyle       bool   `yaml:"s3_path_style"`
+}
+
+func (e *ExportsConfig) Expiry() time.Duration {
+	return time.Duration(e.ExpiryHours) * time.Hour
+}
+
+// NotificationsConfig controls the email provider of notifications. Without an
+// SMTP host, emails are written to the server log instead of being sent.
+type NotificationsConfig struct {
+	SMTPHost     string `yaml:"smtp_host"`
+	SMTPPort     int    `yaml:"smtp_port"`
+	SMTPUsername string `yaml:"smtp_username"`
+	SMTPPassword string `yaml:"smtp_password"`
+	// SMTPFrom is the sender address of notification emails
+	SMTPFrom string `yaml:"smtp_from"`
+}
+
+// FieldError describes a single invalid configuration value
+type FieldError struct {
+	Field   string // dotted config path, e.g. "jwt.secret"
+	Env     string // environment variable that sets the field
+	Message string
+}
+
+// ValidationError lists every invalid configuration field
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Fields)+1)
+	lines = append(lines, fmt.Sprintf("%d invalid configuration value(s):", len(e.Fields)))
+	for _, f := range e.Fields {
+		lines = append(lines, fmt.Sprintf("  - %s (%s): %s", f.Field, f.Env, f.Message))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Defaults returns the configuration used when neither a config file nor
+// environment variables provide a value
+func Defaults() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: getEnv("SERVER_PORT", "8080"),
-			Mode: getEnv("GIN_MODE", "debug"),
+			Port:          "8080",
+			Mode:          "debug",
+			StartupChecks: StartupChecksEnforce,
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "user"),
-			Password: getEnv("DB_PASSWORD", "password"),
-			DBName:   getEnv("DB_NAME", "baseplate"),
-			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
+			Host:                    "localhost",
+			Port:                    "5432",
+			User:                    "user",
+			Password:                "password",
+			DBName:                  "baseplate",
+			SSLMode:                 "disable",
+			StatementCacheSize:      512,
+			StatementTimeoutSeconds: 60,
+			QueryTimeoutSeconds:     30,
 		},
 		JWT: JWTConfig{
-			Secret:          jwtSecret,
-			ExpirationHours: getEnvInt("JWT_EXPIRATION_HOURS", 24),
+			ExpirationHours:           24,
+			MembershipClaimTTLMinutes: 5,
+			ImpersonationMinutes:      30,
+		},
+		APIKeys: APIKeyConfig{
+			LastUsedFlushSeconds: 60,
+			FailuresPerMinute:    10,
+			FailureBurst:         20,
+		},
+		Metrics: MetricsConfig{
+			Enabled:               true,
+			CatalogRefreshSeconds: 60,
+		},
+		CORS: CORSConfig{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Team-ID", "If-Match"},
+			ExposedHeaders: []string{"ETag", "X-Request-ID"},
+			MaxAgeSeconds:  600,
+		},
+		Search: SearchConfig{
+			LargeBlueprintEntities:  10000,
+			ExpensivePerMinute:      30,
+			ExpensiveConcurrency:    2,
+			MaxOffset:               10000,
+			IndexMaintenanceSeconds: 900,
+			CacheTTLSeconds:         5,
+			CacheMaxEntries:         1000,
+			UsageRetentionDays:      90,
+			IndexAdvisorMinUses:     100,
+			IndexAdvisorMinEntities: 1000,
+		},
+		Rollups: RollupConfig{
+			RebuildSeconds:  3600,
+			PropertySeconds: 900,
+		},
+		Expiry: ExpiryConfig{
+			SweepSeconds: 60,
+		},
+		Jobs: JobsConfig{
+			Workers:       4,
+			PollSeconds:   5,
+			MaxAttempts:   5,
+			RetentionDays: 7,
+		},
+		Tasks: TasksConfig{
+			ScorecardsCron:        "30 2 * * *",
+			IntegrationsCron:      "*/15 * * * *",
+			GitHubSyncCron:        "0 4 * * *",
+			KubernetesSyncCron:    "*/10 * * * *",
+			PrometheusSyncCron:    "*/5 * * * *",
+			PagerDutySyncCron:     "*/5 * * * *",
+			ReportsCron:           "0 6 * * mon",
+			ExportsCron:           "15 * * * *",
+			IndexAdvisorCron:      "45 3 * * *",
+			NotificationsCron:     "* * * * *",
+			IntegrationStaleHours: 24,
+		},
+		Outbox: OutboxConfig{
+			PollSeconds:   1,
+			BatchSize:     100,
+			MaxAttempts:   10,
+			RetentionDays: 7,
+		},
+		DLQ: DLQConfig{
+			AlertHours: 24,
+		},
+		Permissions: PermissionConfig{
+			CacheTTLSeconds: 30,
+			CacheMaxEntries: 10000,
+		},
+		Cache: CacheConfig{
+			Backend:             CacheBackendMemory,
+			KeyPrefix:           "baseplate:",
+			MaxEntries:          10000,
+			BlueprintTTLSeconds: 60,
+			APIKeyTTLSeconds:    30,
+			RoleTTLSeconds:      30,
+		},
+		Log: LogConfig{
+			Level:               "info",
+			Format:              "console",
+			AccessSamplePercent: 100,
+		},
+		Audit: AuditConfig{
+			MaxBodyBytes: 64 << 10,
+		},
+		TwoFactor: TwoFactorConfig{
+			Issuer: "Baseplate",
+		},
+		Stats: StatsConfig{
+			RequestRetentionDays: 90,
+		},
+		Exports: ExportsConfig{
+			AsyncThreshold: 50000,
+			ExpiryHours:    24,
+			Storage:        ExportStorageLocal,
+			Dir:            "data/exports",
+			S3Region:       "us-east-1",
+		},
+		Notifications: NotificationsConfig{
+			SMTPPort: 587,
 		},
 	}
 }
 
+// Load reads the configuration from the YAML file named by CONFIG_FILE (if set)
+// and then applies environment variable overrides. It does not validate values;
+// call Validate before using the configuration.
+func Load() (*Config, error) {
+	return LoadFrom(os.Getenv("CONFIG_FILE"))
+}
+
+// LoadFrom is like Load but reads the YAML file at path. An empty path skips the file.
+func LoadFrom(path string) (*Config, error) {
+	cfg := Defaults()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.UnmarshalWithOptions(data, cfg, yaml.DisallowUnknownField()); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	cfg.applyEnv()
+	return cfg, nil
+}
+
+func (c *Config) applyEnv() {
+	setString(&c.Server.Port, "SERVER_PORT")
+	setString(&c.Server.Mode, "GIN_MODE")
+	setString(&c.Server.StartupChecks, "STARTUP_CHECKS")
+	setList(&c.Server.TrustedProxies, "TRUSTED_PROXIES")
+
+	setString(&c.Database.Host, "DB_HOST")
+	setString(&c.Database.Port, "DB_PORT")
+	setString(&c.Database.User, "DB_USER")
+	setString(&c.Database.Password, "DB_PASSWORD")
+	setString(&c.Database.DBName, "DB_NAME")
+	setString(&c.Database.SSLMode, "DB_SSL_MODE")
+	setString(&c.Database.ReplicaURL, "DB_REPLICA_URL")
+	c.setInt(&c.Database.StatementCacheSize, "database.statement_cache_size", "DB_STATEMENT_CACHE_SIZE")
+	c.setInt(&c.Database.StatementTimeoutSeconds, "database.statement_timeout_seconds", "DB_STATEMENT_TIMEOUT_SECONDS")
+	c.setInt(&c.Database.QueryTimeoutSeconds, "database.query_timeout_seconds", "DB_QUERY_TIMEOUT_SECONDS")
+
+	setString(&c.JWT.Secret, "JWT_SECRET")
+	c.setInt(&c.JWT.ExpirationHours, "jwt.expiration_hours", "JWT_EXPIRATION_HOURS")
+	c.setInt(&c.JWT.MembershipClaimTeams, "jwt.membership_claim_teams", "JWT_MEMBERSHIP_CLAIM_TEAMS")
+	c.setInt(&c.JWT.MembershipClaimTTLMinutes, "jwt.membership_claim_ttl_minutes", "JWT_MEMBERSHIP_CLAIM_TTL_MINUTES")
+	c.setInt(&c.JWT.ImpersonationMinutes, "jwt.impersonation_minutes", "JWT_IMPERSONATION_MINUTES")
+	c.setInt(&c.APIKeys.LastUsedFlushSeconds, "api_keys.last_used_flush_seconds", "API_KEY_LAST_USED_FLUSH_SECONDS")
+	c.setInt(&c.APIKeys.FailuresPerMinute, "api_keys.failures_per_minute", "API_KEY_FAILURES_PER_MINUTE")
+	c.setInt(&c.APIKeys.FailureBurst, "api_keys.failure_burst", "API_KEY_FAILURE_BURST")
+
+	c.setBool(&c.Metrics.Enabled, "metrics.enabled", "METRICS_ENABLED")
+	setString(&c.Metrics.Token, "METRICS_TOKEN")
+	c.setInt(&c.Metrics.CatalogRefreshSeconds, "metrics.catalog_refresh_seconds", "METRICS_CATALOG_REFRESH_SECONDS")
+
+	setList(&c.CORS.AllowedOrigins, "CORS_ALLOWED_ORIGINS")
+	setList(&c.CORS.AllowedMethods, "CORS_ALLOWED_METHODS")
+	setList(&c.CORS.AllowedHeaders, "CORS_ALLOWED_HEADERS")
+	setList(&c.CORS.ExposedHeaders, "CORS_EXPOSED_HEADERS")
+	c.setBool(&c.CORS.AllowCredentials, "cors.allow_credentials", "CORS_ALLOW_CREDENTIALS")
+	c.setInt(&c.CORS.MaxAgeSeconds, "cors.max_age_seconds", "CORS_MAX_AGE_SECONDS")
+
+	c.setInt(&c.Search.LargeBlueprintEntities, "search.large_blueprint_entities", "SEARCH_LARGE_BLUEPRINT_ENTITIES")
+	c.setInt(&c.Search.ExpensivePerMinute, "search.expensive_per_minute", "SEARCH_EXPENSIVE_PER_MINUTE")
+	c.setInt(&c.Search.ExpensiveConcurrency, "search.expensive_concurrency", "SEARCH_EXPENSIVE_CONCURRENCY")
+	c.setInt(&c.Search.MaxOffset, "search.max_offset", "SEARCH_MAX_OFFSET")
+	c.setInt(&c.Search.IndexMaintenanceSeconds, "search.index_maintenance_seconds", "SEARCH_INDEX_MAINTENANCE_SECONDS")
+	c.setInt(&c.Search.CacheTTLSeconds, "search.cache_ttl_seconds", "SEARCH_CACHE_TTL_SECONDS")
+	c.setInt(&c.Search.CacheMaxEntries, "search.cache_max_entries", "SEARCH_CACHE_MAX_ENTRIES")
+	c.setInt(&c.Search.UsageRetentionDays, "search.usage_retention_days", "SEARCH_USAGE_RETENTION_DAYS")
+	c.setInt(&c.Search.IndexAdvisorMinUses, "search.index_advisor_min_uses", "SEARCH_INDEX_ADVISOR_MIN_USES")
+	c.setInt(&c.Search.IndexAdvisorMinEntities, "search.index_advisor_min_entities", "SEARCH_INDEX_ADVISOR_MIN_ENTITIES")
+	c.setBool(&c.Search.IndexAdvisorAutoApply, "search.index_advisor_auto_apply", "SEARCH_INDEX_ADVISOR_AUTO_APPLY")
+	c.setInt(&c.Rollups.RebuildSeconds, "rollups.rebuild_seconds", "ROLLUP_REBUILD_SECONDS")
+	c.setInt(&c.Rollups.PropertySeconds, "rollups.property_seconds", "ROLLUP_PROPERTY_SECONDS")
+	c.setInt(&c.Expiry.SweepSeconds, "expiry.sweep_seconds", "EXPIRY_SWEEP_SECONDS")
+	c.setInt(&c.Jobs.Workers, "jobs.workers", "JOBS_WORKERS")
+	c.setInt(&c.Jobs.PollSeconds, "jobs.poll_seconds", "JOBS_POLL_SECONDS")
+	c.setInt(&c.Jobs.MaxAttempts, "jobs.max_attempts", "JOBS_MAX_ATTEMPTS")
+	c.setInt(&c.Jobs.RetentionDays, "jobs.retention_days", "JOBS_RETENTION_DAYS")
+	setString(&c.Tasks.ScorecardsCron, "TASKS_SCORECARDS_CRON")
+	setString(&c.Tasks.IntegrationsCron, "TASKS_INTEGRATIONS_CRON")
+	setString(&c.Tasks.GitHubSyncCron, "TASKS_GITHUB_SYNC_CRON")
+	setString(&c.Tasks.KubernetesSyncCron, "TASKS_KUBERNETES_SYNC_CRON")
+	setString(&c.Tasks.PrometheusSyncCron, "TASKS_PROMETHEUS_SYNC_CRON")
+	setString(&c.Tasks.PagerDutySyncCron, "TASKS_PAGERDUTY_SYNC_CRON")
+	setString(&c.Tasks.ReportsCron, "TASKS_REPORTS_CRON")
+	setString(&c.Tasks.ExportsCron, "TASKS_EXPORTS_CRON")
+	setString(&c.Tasks.IndexAdvisorCron, "TASKS_INDEX_ADVISOR_CRON")
+	setString(&c.Tasks.NotificationsCron, "TASKS_NOTIFICATIONS_CRON")
+	c.setInt(&c.Tasks.IntegrationStaleHours, "tasks.integration_stale_hours", "TASKS_INTEGRATION_STALE_HOURS")
+	c.setInt(&c.Outbox.PollSeconds, "outbox.poll_seconds", "OUTBOX_POLL_SECONDS")
+	c.setInt(&c.Outbox.BatchSize, "outbox.batch_size", "OUTBOX_BATCH_SIZE")
+	c.setInt(&c.Outbox.MaxAttempts, "outbox.max_attempts", "OUTBOX_MAX_ATTEMPTS")
+	c.setInt(&c.Outbox.RetentionDays, "outbox.retention_days", "OUTBOX_RETENTION_DAYS")
+	setString(&c.Outbox.NotifyChannel, "OUTBOX_NOTIFY_CHANNEL")
+	c.setInt(&c.DLQ.AlertHours, "dlq.alert_hours", "DLQ_ALERT_HOURS")
+	c.setInt(&c.Permissions.CacheTTLSeconds, "permissions.cache_ttl_seconds", "PERMISSION_CACHE_TTL_SECONDS")
+	c.setInt(&c.Permissions.CacheMaxEntries, "permissions.cache_max_entries", "PERMISSION_CACHE_MAX_ENTRIES")
+	setString(&c.Cache.Backend, "CACHE_BACKEND")
+	setString(&c.Cache.RedisURL, "CACHE_REDIS_URL")
+	setString(&c.Cache.KeyPrefix, "CACHE_KEY_PREFIX")
+	c.setInt(&c.Cache.MaxEntries, "cache.max_entries", "CACHE_MAX_ENTRIES")
+	c.setInt(&c.Cache.BlueprintTTLSeconds, "cache.blueprint_ttl_seconds", "CACHE_BLUEPRINT_TTL_SECONDS")
+	c.setInt(&c.Cache.APIKeyTTLSeconds, "cache.api_key_ttl_seconds", "CACHE_API_KEY_TTL_SECONDS")
+	c.setInt(&c.Cache.RoleTTLSeconds, "cache.role_ttl_seconds", "CACHE_ROLE_TTL_SECONDS")
+
+	setString(&c.Log.Level, "LOG_LEVEL")
+	setString(&c.Log.Format, "LOG_FORMAT")
+	c.setInt(&c.Log.AccessSamplePercent, "log.access_sample_percent", "LOG_ACCESS_SAMPLE_PERCENT")
+	c.setBool(&c.Log.AccessPayloads, "log.access_payloads", "LOG_ACCESS_PAYLOADS")
+
+	c.setBool(&c.Audit.CaptureAdminBodies, "audit.capture_admin_bodies", "AUDIT_CAPTURE_ADMIN_BODIES")
+	c.setInt(&c.Audit.MaxBodyBytes, "audit.max_body_bytes", "AUDIT_MAX_BODY_BYTES")
+
+	setString(&c.TwoFactor.Issuer, "TWO_FACTOR_ISSUER")
+	c.setBool(&c.TwoFactor.RequireForManagers, "two_factor.require_for_managers", "TWO_FACTOR_REQUIRE_FOR_MANAGERS")
+
+	c.setInt(&c.Stats.RequestRetentionDays, "stats.request_retention_days", "STATS_REQUEST_RETENTION_DAYS")
+	setString(&c.Secrets.EncryptionKey, "SECRETS_ENCRYPTION_KEY")
+
+	c.setInt(&c.Exports.AsyncThreshold, "exports.async_threshold", "EXPORT_ASYNC_THRESHOLD")
+	c.setInt(&c.Exports.ExpiryHours, "exports.expiry_hours", "EXPORT_EXPIRY_HOURS")
+	setString(&c.Exports.Storage, "EXPORT_STORAGE")
+	setString(&c.Exports.Dir, "EXPORT_DIR")
+	setString(&c.Exports.S3Bucket, "EXPORT_S3_BUCKET")
+	setString(&c.Exports.S3Region, "EXPORT_S3_REGION")
+	setString(&c.Exports.S3Endpoint, "EXPORT_S3_ENDPOINT")
+	setString(&c.Exports.S3AccessKeyID, "EXPORT_S3_ACCESS_KEY_ID")
+	setString(&c.Exports.S3SecretAccessKey, "EXPORT_S3_SECRET_ACCESS_KEY")
+	c.setBool(&c.Exports.S3PathStyle, "exports.s3_path_style", "EXPORT_S3_PATH_STYLE")
+
+	setString(&c.Notifications.SMTPHost, "NOTIFICATIONS_SMTP_HOST")
+	c.setInt(&c.Notifications.SMTPPort, "notifications.smtp_port", "NOTIFICATIONS_SMTP_PORT")
+	setString(&c.Notifications.SMTPUsername, "NOTIFICATIONS_SMTP_USERNAME")
+	setString(&c.Notifications.SMTPPassword, "NOTIFICATIONS_SMTP_PASSWORD")
+	setString(&c.Notifications.SMTPFrom, "NOTIFICATIONS_SMTP_FROM")
+}
+
+// Validate checks every field and returns a *ValidationError listing all problems
+func (c *Config) Validate() error {
+	fields := append([]FieldError{}, c.problems...)
+	invalid := func(field, env, format string, args ...interface{}) {
+		fields = append(fields, FieldError{Field: field, Env: env, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if !validPort(c.Server.Port) {
+		invalid("server.port", "SERVER_PORT", "%q is not a port number between 1 and 65535", c.Server.Port)
+	}
+	switch c.Server.Mode {
+	case "debug", "release", "test":
+	default:
+		invalid("server.mode", "GIN_MODE", "%q must be one of debug, release, test", c.Server.Mode)
+	}
+	switch c.Server.StartupChecks {
+	case StartupChecksEnforce, StartupChecksWarn, StartupChecksOff:
+	default:
+		invalid("server.startup_checks", "STARTUP_CHECKS", "%q must be one of enforce, warn, off", c.Server.StartupChecks)
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		if !validProxy(proxy) {
+			invalid("server.trusted_proxies", "TRUSTED_PROXIES", "%q is not an IP address or CIDR range", proxy)
+		}
+	}
+
+	if c.Database.Host == "" {
+		invalid("database.host", "DB_HOST", "is required")
+	}
+	if !validPort(c.Database.Port) {
+		invalid("database.port", "DB_PORT", "%q is not a port number between 1 and 65535", c.Database.Port)
+	}
+	if c.Database.User == "" {
+		invalid("database.user", "DB_USER", "is required")
+	}
+	if c.Database.DBName == "" {
+		invalid("database.name", "DB_NAME", "is required")
+	}
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		invalid("database.ssl_mode", "DB_SSL_MODE", "%q is not a valid libpq sslmode", c.Database.SSLMode)
+	}
+	if c.Database.StatementCacheSize < 0 {
+		invalid("database.statement_cache_size", "DB_STATEMENT_CACHE_SIZE", "must not be negative")
+	}
+	if c.Database.StatementTimeoutSeconds < 0 {
+		invalid("database.statement_timeout_seconds", "DB_STATEMENT_TIMEOUT_SECONDS", "must not be negative")
+	}
+	if c.Database.QueryTimeoutSeconds < 0 {
+		invalid("database.query_timeout_seconds", "DB_QUERY_TIMEOUT_SECONDS", "must not be negative")
+	}
+
+	if c.JWT.Secret == "" {
+		invalid("jwt.secret", "JWT_SECRET", "is required (generate one with: openssl rand -base64 48)")
+	} else if len(c.JWT.Secret) < MinJWTSecretLength {
+		invalid("jwt.secret", "JWT_SECRET", "must be at least %d bytes, got %d", MinJWTSecretLength, len(c.JWT.Secret))
+	}
+	if c.JWT.ExpirationHours <= 0 {
+		invalid("jwt.expiration_hours", "JWT_EXPIRATION_HOURS", "must be a positive number of hours")
+	}
+	if c.JWT.MembershipClaimTeams < 0 || c.JWT.MembershipClaimTeams > MaxMembershipClaimTeams {
+		invalid("jwt.membership_claim_teams", "JWT_MEMBERSHIP_CLAIM_TEAMS", "must be between 0 and %d", MaxMembershipClaimTeams)
+	}
+	if c.JWT.MembershipClaimTeams > 0 && c.JWT.MembershipClaimTTLMinutes <= 0 {
+		invalid("jwt.membership_claim_ttl_minutes", "JWT_MEMBERSHIP_CLAIM_TTL_MINUTES", "must be a positive number of minutes when membership claims are enabled")
+	}
+	if c.JWT.ImpersonationMinutes <= 0 || c.JWT.ImpersonationMinutes > MaxImpersonationMinutes {
+		invalid("jwt.impersonation_minutes", "JWT_IMPERSONATION_MINUTES", "must be between 1 and %d", MaxImpersonationMinutes)
+	}
+	if c.APIKeys.LastUsedFlushSeconds <= 0 {
+		invalid("api_keys.last_used_flush_seconds", "API_KEY_LAST_USED_FLUSH_SECONDS", "must be a positive number of seconds")
+	}
+	if c.APIKeys.FailuresPerMinute < 0 {
+		invalid("api_keys.failures_per_minute", "API_KEY_FAILURES_PER_MINUTE", "must not be negative")
+	}
+	if c.APIKeys.FailuresPerMinute > 0 && c.APIKeys.FailureBurst <= 0 {
+		invalid("api_keys.failure_burst", "API_KEY_FAILURE_BURST", "must be a positive number when the failure limit is enabled")
+	}
+
+	if c.Metrics.CatalogRefreshSeconds <= 0 {
+		invalid("metrics.catalog_refresh_seconds", "METRICS_CATALOG_REFRESH_SECONDS", "must be a positive number of seconds")
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.CORS.AllowCredentials {
+				invalid("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "\"*\" cannot be combined with CORS_ALLOW_CREDENTIALS=true; list origins explicitly")
+			}
+			continue
+		}
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			invalid("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "%q must start with http:// or https://", origin)
+		} else if strings.HasSuffix(origin, "/") {
+			invalid("cors.allowed_origins", "CORS_ALLOWED_ORIGINS", "%q must not have a trailing slash", origin)
+		}
+	}
+	if c.CORS.MaxAgeSeconds < 0 {
+		invalid("cors.max_age_seconds", "CORS_MAX_AGE_SECONDS", "must not be negative")
+	}
+
+	if c.Search.LargeBlueprintEntities < 0 {
+		invalid("search.large_blueprint_entities", "SEARCH_LARGE_BLUEPRINT_ENTITIES", "must not be negative")
+	}
+	if c.Search.ExpensivePerMinute < 0 {
+		invalid("search.expensive_per_minute", "SEARCH_EXPENSIVE_PER_MINUTE", "must not be negative")
+	}
+	if c.Search.ExpensiveConcurrency < 0 {
+		invalid("search.expensive_concurrency", "SEARCH_EXPENSIVE_CONCURRENCY", "must not be negative")
+	}
+	if c.Search.MaxOffset <= 0 {
+		invalid("search.max_offset", "SEARCH_MAX_OFFSET", "must be a positive number")
+	}
+	if c.Search.IndexMaintenanceSeconds < 0 {
+		invalid("search.index_maintenance_seconds", "SEARCH_INDEX_MAINTENANCE_SECONDS", "must not be negative")
+	}
+	if c.Search.CacheTTLSeconds < 0 {
+		invalid("search.cache_ttl_seconds", "SEARCH_CACHE_TTL_SECONDS", "must not be negative")
+	}
+	if c.Search.CacheTTLSeconds > 0 && c.Search.CacheMaxEntries <= 0 {
+		invalid("search.cache_max_entries", "SEARCH_CACHE_MAX_ENTRIES", "must be a positive number when the cache is enabled")
+	}
+	if c.Search.UsageRetentionDays < 0 {
+		invalid("search.usage_retention_days", "SEARCH_USAGE_RETENTION_DAYS", "must not be negative")
+	}
+	if c.Search.IndexAdvisorMinUses <= 0 {
+		invalid("search.index_advisor_min_uses", "SEARCH_INDEX_ADVISOR_MIN_USES", "must be a positive number")
+	}
+	if c.Search.IndexAdvisorMinEntities < 0 {
+		invalid("search.index_advisor_min_entities", "SEARCH_INDEX_ADVISOR_MIN_ENTITIES", "must not be negative")
+	}
+	if c.Search.IndexAdvisorAutoApply && (c.Search.UsageRetentionDays == 0 || c.Search.IndexMaintenanceSeconds == 0) {
+		invalid("search.index_advisor_auto_apply", "SEARCH_INDEX_ADVISOR_AUTO_APPLY", "needs usage tracking and index maintenance enabled")
+	}
+	if c.Rollups.RebuildSeconds < 0 {
+		invalid("rollups.rebuild_seconds", "ROLLUP_REBUILD_SECONDS", "must not be negative")
+	}
+	if c.Rollups.PropertySeconds < 0 {
+		invalid("rollups.property_seconds", "ROLLUP_PROPERTY_SECONDS", "must not be negative")
+	}
+	if c.Expiry.SweepSeconds < 0 {
+		invalid("expiry.sweep_seconds", "EXPIRY_SWEEP_SECONDS", "must not be negative")
+	}
+	if c.Jobs.Workers < 0 {
+		invalid("jobs.workers", "JOBS_WORKERS", "must not be negative")
+	}
+	if c.Jobs.Workers > 0 && c.Jobs.PollSeconds <= 0 {
+		invalid("jobs.poll_seconds", "JOBS_POLL_SECONDS", "must be a positive number when workers are enabled")
+	}
+	if c.Jobs.MaxAttempts <= 0 {
+		invalid("jobs.max_attempts", "JOBS_MAX_ATTEMPTS", "must be a positive number")
+	}
+	if c.Jobs.RetentionDays < 0 {
+		invalid("jobs.retention_days", "JOBS_RETENTION_DAYS", "must not be negative")
+	}
+	for _, task := range []struct{ field, env, schedule string }{
+		{"tasks.scorecards_cron", "TASKS_SCORECARDS_CRON", c.Tasks.ScorecardsCron},
+		{"tasks.integrations_cron", "TASKS_INTEGRATIONS_CRON", c.Tasks.IntegrationsCron},
+		{"tasks.github_sync_cron", "TASKS_GITHUB_SYNC_CRON", c.Tasks.GitHubSyncCron},
+		{"tasks.kubernetes_sync_cron", "TASKS_KUBERNETES_SYNC_CRON", c.Tasks.KubernetesSyncCron},
+		{"tasks.prometheus_sync_cron", "TASKS_PROMETHEUS_SYNC_CRON", c.Tasks.PrometheusSyncCron},
+		{"tasks.pagerduty_sync_cron", "TASKS_PAGERDUTY_SYNC_CRON", c.Tasks.PagerDutySyncCron},
+		{"tasks.reports_cron", "TASKS_REPORTS_CRON", c.Tasks.ReportsCron},
+		{"tasks.exports_cron", "TASKS_EXPORTS_CRON", c.Tasks.ExportsCron},
+		{"tasks.index_advisor_cron", "TASKS_INDEX_ADVISOR_CRON", c.Tasks.IndexAdvisorCron},
+		{"tasks.notifications_cron", "TASKS_NOTIFICATIONS_CRON", c.Tasks.NotificationsCron},
+	} {
+		if task.schedule == "off" {
+			continue
+		}
+		if _, err := cron.Parse(task.schedule); err != nil {
+			invalid(task.field, task.env, "must be a cron expression or off: %v", err)
+		}
+	}
+	if c.Tasks.IntegrationStaleHours <= 0 {
+		invalid("tasks.integration_stale_hours", "TASKS_INTEGRATION_STALE_HOURS", "must be a positive number")
+	}
+	if c.Outbox.PollSeconds < 0 {
+		invalid("outbox.poll_seconds", "OUTBOX_POLL_SECONDS", "must not be negative")
+	}
+	if c.Outbox.BatchSize <= 0 {
+		invalid("outbox.batch_size", "OUTBOX_BATCH_SIZE", "must be a positive number")
+	}
+	if c.Outbox.MaxAttempts <= 0 {
+		invalid("outbox.max_attempts", "OUTBOX_MAX_ATTEMPTS", "must be a positive number")
+	}
+	if c.Outbox.RetentionDays < 0 {
+		invalid("outbox.retention_days", "OUTBOX_RETENTION_DAYS", "must not be negative")
+	}
+	if c.Outbox.NotifyChannel != "" && !notifyChannelPattern.MatchString(c.Outbox.NotifyChannel) {
+		invalid("outbox.notify_channel", "OUTBOX_NOTIFY_CHANNEL", "must be a lowercase identifier of at most 63 characters")
+	}
+	if c.DLQ.AlertHours < 0 {
+		invalid("dlq.alert_hours", "DLQ_ALERT_HOURS", "must not be negative")
+	}
+	if c.Permissions.CacheTTLSeconds < 0 {
+		invalid("permissions.cache_ttl_seconds", "PERMISSION_CACHE_TTL_SECONDS", "must not be negative")
+	}
+	if c.Permissions.CacheTTLSeconds > 0 && c.Permissions.CacheMaxEntries <= 0 {
+		invalid("permissions.cache_max_entries", "PERMISSION_CACHE_MAX_ENTRIES", "must be a positive number when the cache is enabled")
+	}
+	switch c.Cache.Backend {
+	case CacheBackendMemory:
+		if c.Cache.MaxEntries <= 0 {
+			invalid("cache.max_entries", "CACHE_MAX_ENTRIES", "must be a positive number with the memory backend")
+		}
+	case CacheBackendRedis:
+		if !strings.HasPrefix(c.Cache.RedisURL, "redis://") && !strings.HasPrefix(c.Cache.RedisURL, "rediss://") {
+			invalid("cache.redis_url", "CACHE_REDIS_URL", "must be a redis:// or rediss:// URL with the redis backend")
+		}
+	case CacheBackendOff:
+	default:
+		invalid("cache.backend", "CACHE_BACKEND", "must be memory, redis or off")
+	}
+	for _, ttl := range []struct {
+		field, env string
+		seconds    int
+	}{
+		{"cache.blueprint_ttl_seconds", "CACHE_BLUEPRINT_TTL_SECONDS", c.Cache.BlueprintTTLSeconds},
+		{"cache.api_key_ttl_seconds", "CACHE_API_KEY_TTL_SECONDS", c.Cache.APIKeyTTLSeconds},
+		{"cache.role_ttl_seconds", "CACHE_ROLE_TTL_SECONDS", c.Cache.RoleTTLSeconds},
+	} {
+		if ttl.seconds < 0 {
+			invalid(ttl.field, ttl.env, "must not be negative")
+		}
+	}
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		invalid("log.level", "LOG_LEVEL", "%q must be one of debug, info, warn, error", c.Log.Level)
+	}
+	switch c.Log.Format {
+	case "json", "console":
+	default:
+		invalid("log.format", "LOG_FORMAT", "%q must be one of json, console", c.Log.Format)
+	}
+	if c.Log.AccessSamplePercent < 0 || c.Log.AccessSamplePercent > 100 {
+		invalid("log.access_sample_percent", "LOG_ACCESS_SAMPLE_PERCENT", "must be between 0 and 100")
+	}
+	if c.Audit.CaptureAdminBodies && c.Audit.MaxBodyBytes <= 0 {
+		invalid("audit.max_body_bytes", "AUDIT_MAX_BODY_BYTES", "must be a positive number of bytes when admin body capture is enabled")
+	}
+	if strings.TrimSpace(c.TwoFactor.Issuer) == "" || strings.Contains(c.TwoFactor.Issuer, ":") {
+		invalid("two_factor.issuer", "TWO_FACTOR_ISSUER", "must be non-empty and must not contain ':'")
+	}
+	if c.Stats.RequestRetentionDays < 0 {
+		invalid("stats.request_retention_days", "STATS_REQUEST_RETENTION_DAYS", "must not be negative")
+	}
+	if c.Secrets.EncryptionKey != "" {
+		if key, err := base64.StdEncoding.DecodeString(c.Secrets.EncryptionKey); err != nil || len(key) != 32 {
+			invalid("secrets.encryption_key", "SECRETS_ENCRYPTION_KEY", "must be 32 base64 encoded bytes (generate one with: openssl rand -base64 32)")
+		}
+	}
+	if c.Exports.AsyncThreshold < 0 {
+		invalid("exports.async_threshold", "EXPORT_ASYNC_THRESHOLD", "must not be negative")
+	}
+	if c.Exports.ExpiryHours <= 0 {
+		invalid("exports.expiry_hours", "EXPORT_EXPIRY_HOURS", "must be a positive number")
+	}
+	switch c.Exports.Storage {
+	case ExportStorageLocal:
+		if c.Exports.Dir == "" {
+			invalid("exports.dir", "EXPORT_DIR", "is required with local storage")
+		}
+	case ExportStorageS3:
+		for _, f := range []struct{ field, env, value string }{
+			{"exports.s3_bucket", "EXPORT_S3_BUCKET", c.Exports.S3Bucket},
+			{"exports.s3_region", "EXPORT_S3_REGION", c.Exports.S3Region},
+			{"exports.s3_access_key_id", "EXPORT_S3_ACCESS_KEY_ID", c.Exports.S3AccessKeyID},
+			{"exports.s3_secret_access_key", "EXPORT_S3_SECRET_ACCESS_KEY", c.Exports.S3SecretAccessKey},
+		} {
+			if f.value == "" {
+				invalid(f.field, f.env, "is required with s3 storage")
+			}
+		}
+		if c.Exports.S3Endpoint != "" {
+			if u, err := url.Parse(c.Exports.S3Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				invalid("exports.s3_endpoint", "EXPORT_S3_ENDPOINT", "must be an http or https URL")
+			}
+		}
+	default:
+		invalid("exports.storage", "EXPORT_STORAGE", "%q must be one of local, s3", c.Exports.Storage)
+	}
+	if c.Notifications.SMTPHost != "" {
+		if c.Notifications.SMTPPort <= 0 || c.Notifications.SMTPPort > 65535 {
+			invalid("notifications.smtp_port", "NOTIFICATIONS_SMTP_PORT", "must be a port number when an SMTP host is set")
+		}
+		if _, err := mail.ParseAddress(c.Notifications.SMTPFrom); err != nil {
+			invalid("notifications.smtp_from", "NOTIFICATIONS_SMTP_FROM", "must be an email address when an SMTP host is set")
+		}
+	}
+
+	if len(fields) > 0 {
+		return &ValidationError{Fields: fields}
+	}
+	return nil
+}
+
 func (d *DatabaseConfig) ConnectionString() string {
 	return "host=" + d.Host +
 		" port=" + d.Port +
@@ -66,22 +1062,83 @@ func (d *DatabaseConfig) ConnectionString() string {
 		" sslmode=" + d.SSLMode
 }
 
+func (d *DatabaseConfig) StatementTimeout() time.Duration {
+	return time.Duration(d.StatementTimeoutSeconds) * time.Second
+}
+
+func (d *DatabaseConfig) QueryTimeout() time.Duration {
+	return time.Duration(d.QueryTimeoutSeconds) * time.Second
+}
+
 func (j *JWTConfig) ExpirationDuration() time.Duration {
 	return time.Duration(j.ExpirationHours) * time.Hour
 }
 
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
+func (j *JWTConfig) MembershipClaimTTL() time.Duration {
+	return time.Duration(j.MembershipClaimTTLMinutes) * time.Minute
+}
+
+func (j *JWTConfig) ImpersonationTTL() time.Duration {
+	return time.Duration(j.ImpersonationMinutes) * time.Minute
+}
+
+func validPort(value string) bool {
+	port, err := strconv.Atoi(value)
+	return err == nil && port >= 1 && port <= 65535
+}
+
+// validProxy reports whether value is an IP address or CIDR range
+func validProxy(value string) bool {
+	if _, err := netip.ParsePrefix(value); err == nil {
+		return true
 	}
-	return defaultValue
+	_, err := netip.ParseAddr(value)
+	return err == nil
 }
 
-func getEnvInt(key string, defaultValue int) int {
+func setString(target *string, key string) {
 	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
+		*target = value
+	}
+}
+
+// setList reads a comma-separated list, trimming whitespace around items
+func setList(target *[]string, key string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
 		}
 	}
-	return defaultValue
+	*target = items
+}
+
+func (c *Config) setInt(target *int, field, key string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		c.problems = append(c.problems, FieldError{Field: field, Env: key, Message: fmt.Sprintf("%q is not an integer", value)})
+		return
+	}
+	*target = intValue
+}
+
+func (c *Config) setBool(target *bool, field, key string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		c.problems = append(c.problems, FieldError{Field: field, Env: key, Message: fmt.Sprintf("%q is not a boolean", value)})
+		return
+	}
+	*target = boolValue
 }
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidate_ReportsEveryInvalidField(t *testing.T) {
+	cfg := Defaults()
+	cfg.Server.Port = "abc"
+	cfg.Server.Mode = "prod"
+	cfg.Database.SSLMode = "sometimes"
+	cfg.JWT.Secret = "short"
+	cfg.TwoFactor.Issuer = "Acme: Portal"
+	cfg.Secrets.EncryptionKey = "c2hvcnQ="
+	cfg.Tasks.ReportsCron = "weekly"
+	cfg.Outbox.NotifyChannel = "baseplate-events"
+	cfg.Cache.Backend = "redis"
+
+	err := cfg.Validate()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("expected *ValidationError, got %v", err)
+	}
+
+	fields := map[string]bool{}
+	for _, f := range verr.Fields {
+		fields[f.Field] = true
+	}
+	for _, want := range []string{"server.port", "server.mode", "database.ssl_mode", "jwt.secret", "two_factor.issuer", "secrets.encryption_key", "tasks.reports_cron", "outbox.notify_channel", "cache.redis_url"} {
+		if !fields[want] {
+			t.Errorf("expected %s to be reported, got %v", want, verr.Fields)
+		}
+	}
+}
+
+func TestValidate_ValidConfig(t *testing.T) {
+	cfg := Defaults()
+	cfg.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+	cfg.Tasks.ScorecardsCron = "off"
+	cfg.Outbox.NotifyChannel = "baseplate_events"
+
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("expected valid config, got %v", err)
+	}
+}
+
+func TestValidate_CORSOrigins(t *testing.T) {
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		wantErr     bool
+	}{
+		{"explicit origins", []string{"https://app.example.com", "https://*.example.com"}, true, false},
+		{"any origin", []string{"*"}, false, false},
+		{"any origin with credentials", []string{"*"}, true, true},
+		{"missing scheme", []string{"app.example.com"}, false, true},
+		{"trailing slash", []string{"https://app.example.com/"}, false, true},
+	}
+
+	for _, tt := range tests {
+		cfg := Defaults()
+		cfg.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+		cfg.CORS.AllowedOrigins = tt.origins
+		cfg.CORS.AllowCredentials = tt.credentials
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidate_ExportStorage(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(e *ExportsConfig)
+		wantErr bool
+	}{
+		{"local", func(e *ExportsConfig) {}, false},
+		{"local without dir", func(e *ExportsConfig) { e.Dir = "" }, true},
+		{"s3", func(e *ExportsConfig) {
+			e.Storage, e.S3Bucket, e.S3AccessKeyID, e.S3SecretAccessKey = ExportStorageS3, "exports", "key", "secret"
+			e.S3Endpoint = "http://minio:9000"
+		}, false},
+		{"s3 without credentials", func(e *ExportsConfig) { e.Storage, e.S3Bucket = ExportStorageS3, "exports" }, true},
+		{"unknown storage", func(e *ExportsConfig) { e.Storage = "gcs" }, true},
+	}
+
+	for _, tt := range tests {
+		cfg := Defaults()
+		cfg.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+		tt.modify(&cfg.Exports)
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidate_IndexAdvisor(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(s *SearchConfig)
+		wantErr bool
+	}{
+		{"defaults", func(s *SearchConfig) {}, false},
+		{"auto apply", func(s *SearchConfig) { s.IndexAdvisorAutoApply = true }, false},
+		{"auto apply without usage", func(s *SearchConfig) { s.IndexAdvisorAutoApply, s.UsageRetentionDays = true, 0 }, true},
+		{"auto apply without maintenance", func(s *SearchConfig) { s.IndexAdvisorAutoApply, s.IndexMaintenanceSeconds = true, 0 }, true},
+		{"no minimum uses", func(s *SearchConfig) { s.IndexAdvisorMinUses = 0 }, true},
+	}
+
+	for _, tt := range tests {
+		cfg := Defaults()
+		cfg.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+		tt.modify(&cfg.Search)
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidate_Notifications(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(n *NotificationsConfig)
+		wantErr bool
+	}{
+		{"log only", func(n *NotificationsConfig) {}, false},
+		{"smtp", func(n *NotificationsConfig) {
+			n.SMTPHost, n.SMTPFrom = "smtp.example.com", "Baseplate <baseplate@example.com>"
+		}, false},
+		{"smtp without sender", func(n *NotificationsConfig) { n.SMTPHost = "smtp.example.com" }, true},
+		{"smtp without port", func(n *NotificationsConfig) {
+			n.SMTPHost, n.SMTPFrom, n.SMTPPort = "smtp.example.com", "baseplate@example.com", 0
+		}, true},
+	}
+
+	for _, tt := range tests {
+		cfg := Defaults()
+		cfg.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+		tt.modify(&cfg.Notifications)
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidate_TrustedProxies(t *testing.T) {
+	tests := []struct {
+		proxies []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"10.0.0.0/8", "192.0.2.1", "2001:db8::/32"}, false},
+		{[]string{"proxy.internal"}, true},
+		{[]string{"10.0.0.0/33"}, true},
+	}
+
+	for _, tt := range tests {
+		cfg := Defaults()
+		cfg.JWT.Secret = strings.Repeat("s", MinJWTSecretLength)
+		cfg.Server.TrustedProxies = tt.proxies
+
+		if err := cfg.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%v: Validate() error = %v, wantErr %v", tt.proxies, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLoad_CORSOriginsFromEnv(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://a.example.com , https://b.example.com,")
+
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+	want := []string{"https://a.example.com", "https://b.example.com"}
+	if strings.Join(cfg.CORS.AllowedOrigins, "|") != strings.Join(want, "|") {
+		t.Errorf("AllowedOrigins = %v, want %v", cfg.CORS.AllowedOrigins, want)
+	}
+}
+
+func TestLoad_MalformedIntegerIsReported(t *testing.T) {
+	t.Setenv("JWT_SECRET", strings.Repeat("s", MinJWTSecretLength))
+	t.Setenv("JWT_EXPIRATION_HOURS", "one-day")
+
+	cfg, err := LoadFrom("")
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "JWT_EXPIRATION_HOURS") {
+		t.Errorf("expected malformed JWT_EXPIRATION_HOURS to be reported, got %v", err)
+	}
+}
+
+func TestLoadFrom_FileWithEnvOverride(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseplate.yaml")
+	content := `
+server:
+  port: 9090
+  mode: release
+database:
+  host: db.internal
+jwt:
+  secret: from-file-secret-that-is-long-enough!!
+  expiration_hours: 12
+`
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DB_HOST", "db.override")
+
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+
+	if cfg.Server.Port != "9090" || cfg.Server.Mode != "release" {
+		t.Errorf("server values not read from file: %+v", cfg.Server)
+	}
+	if cfg.Database.Host != "db.override" {
+		t.Errorf("expected env to override file, got %s", cfg.Database.Host)
+	}
+	if cfg.Database.Port != "5432" {
+		t.Errorf("expected default database port, got %s", cfg.Database.Port)
+	}
+	if cfg.JWT.ExpirationHours != 12 {
+		t.Errorf("expected expiration 12, got %d", cfg.JWT.ExpirationHours)
+	}
+}
+
+func TestLoadFrom_UnknownKeyRejected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseplate.yaml")
+	if err := os.WriteFile(path, []byte("server:\n  prot: 8080\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadFrom(path); err == nil {
+		t.Error("expected unknown key to be rejected")
+	}
+}
+
+func TestReloader_AppliesReloadableSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseplate.yaml")
+	write := func(content string) {
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("jwt:\n  secret: from-file-secret-that-is-long-enough!!\n")
+
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("unexpected load error: %v", err)
+	}
+	r := NewReloader(path, cfg)
+	var notified *Config
+	r.Subscribe(func(c *Config) { notified = c })
+
+	write(`
+server:
+  port: 9090
+cors:
+  allowed_origins: ["https://app.example.com"]
+search:
+  max_offset: 500
+  cache_ttl_seconds: 60
+jwt:
+  secret: from-file-secret-that-is-long-enough!!
+`)
+	result, err := r.Reload()
+	if err != nil {
+		t.Fatalf("unexpected reload error: %v", err)
+	}
+
+	if strings.Join(result.Applied, ",") != "cors,search limits" {
+		t.Errorf("unexpected applied settings %v", result.Applied)
+	}
+	if strings.Join(result.RestartRequired, ",") != "server,search" {
+		t.Errorf("unexpected restart-required sections %v", result.RestartRequired)
+	}
+	if notified == nil || notified != r.Current() {
+		t.Fatal("expected subscribers to receive the new configuration")
+	}
+	if notified.Search.MaxOffset != 500 || len(notified.CORS.AllowedOrigins) != 1 {
+		t.Errorf("reloadable settings not applied: %+v %+v", notified.Search, notified.CORS)
+	}
+	if notified.Server.Port != cfg.Server.Port || notified.Search.CacheTTLSeconds != cfg.Search.CacheTTLSeconds {
+		t.Error("expected settings that require a restart to keep their startup values")
+	}
+}
+
+func TestReloader_InvalidConfigKeepsCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseplate.yaml")
+	if err := os.WriteFile(path, []byte("search:\n  max_offset: 500\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := Defaults()
+	r := NewReloader(path, cfg)
+
+	// Missing JWT secret
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("expected invalid configuration to be rejected")
+	}
+	if r.Current() != cfg {
+		t.Error("expected the current configuration to stay in effect")
+	}
+}
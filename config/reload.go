@@ -0,0 +1,159 @@
+package config
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ReloadResult describes what a reload changed
+type ReloadResult struct {
+	// Applied lists the reloadable settings that changed and are now in effect
+	Applied []string `json:"applied"`
+	// RestartRequired lists sections that changed but only take effect after a restart
+	RestartRequired []string `json:"restart_required"`
+}
+
+// Reloader re-reads the configuration at runtime and hands the reloadable
+// settings (CORS policy, search limits, logging and access log) to subscribers. Everything else,
+// such as the database connection, JWT secret or port, keeps the value the
+// server started with.
+type Reloader struct {
+	path string
+
+	mu          sync.Mutex
+	current     *Config
+	subscribers []func(*Config)
+}
+
+func NewReloader(path string, current *Config) *Reloader {
+	return &Reloader{path: path, current: current}
+}
+
+// Subscribe registers fn to be called with the new configuration after every
+// reload that changes a reloadable setting. Subscribers must not block.
+func (r *Reloader) Subscribe(fn func(*Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Current returns the configuration in effect
+func (r *Reloader) Current() *Config {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// Reload loads the config file and environment again. Invalid configuration
+// is rejected as a whole and the current configuration stays in effect.
+func (r *Reloader) Reload() (*ReloadResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loaded, err := LoadFrom(r.path)
+	if err != nil {
+		return nil, err
+	}
+	if err := loaded.Validate(); err != nil {
+		return nil, err
+	}
+
+	next, result := merge(r.current, loaded)
+	if len(result.Applied) > 0 {
+		r.current = next
+		for _, fn := range r.subscribers {
+			fn(next)
+		}
+	}
+	return result, nil
+}
+
+// merge copies the reloadable settings of loaded onto a copy of current and
+// reports which settings differ
+func merge(current, loaded *Config) (*Config, *ReloadResult) {
+	next := *current
+	result := &ReloadResult{Applied: []string{}, RestartRequired: []string{}}
+
+	if !reflect.DeepEqual(current.CORS, loaded.CORS) {
+		next.CORS = loaded.CORS
+		result.Applied = append(result.Applied, "cors")
+	}
+	if search := withSearchLimits(current.Search, loaded.Search); search != current.Search {
+		next.Search = search
+		result.Applied = append(result.Applied, "search limits")
+	}
+	if current.Log != loaded.Log {
+		next.Log = loaded.Log
+		result.Applied = append(result.Applied, "log")
+	}
+
+	// Sections that are wired into long-lived connections and workers
+	if !reflect.DeepEqual(current.Server, loaded.Server) {
+		result.RestartRequired = append(result.RestartRequired, "server")
+	}
+	if current.Database != loaded.Database {
+		result.RestartRequired = append(result.RestartRequired, "database")
+	}
+	if current.JWT != loaded.JWT {
+		result.RestartRequired = append(result.RestartRequired, "jwt")
+	}
+	if current.APIKeys != loaded.APIKeys {
+		result.RestartRequired = append(result.RestartRequired, "api_keys")
+	}
+	if current.Metrics != loaded.Metrics {
+		result.RestartRequired = append(result.RestartRequired, "metrics")
+	}
+	if withSearchLimits(current.Search, loaded.Search) != loaded.Search {
+		result.RestartRequired = append(result.RestartRequired, "search")
+	}
+	if current.Rollups != loaded.Rollups {
+		result.RestartRequired = append(result.RestartRequired, "rollups")
+	}
+	if current.Expiry != loaded.Expiry {
+		result.RestartRequired = append(result.RestartRequired, "expiry")
+	}
+	if current.Jobs != loaded.Jobs {
+		result.RestartRequired = append(result.RestartRequired, "jobs")
+	}
+	if current.Tasks != loaded.Tasks {
+		result.RestartRequired = append(result.RestartRequired, "tasks")
+	}
+	if current.Outbox != loaded.Outbox {
+		result.RestartRequired = append(result.RestartRequired, "outbox")
+	}
+	if current.DLQ != loaded.DLQ {
+		result.RestartRequired = append(result.RestartRequired, "dlq")
+	}
+	if current.Permissions != loaded.Permissions {
+		result.RestartRequired = append(result.RestartRequired, "permissions")
+	}
+	if current.Cache != loaded.Cache {
+		result.RestartRequired = append(result.RestartRequired, "cache")
+	}
+	if current.Audit != loaded.Audit {
+		result.RestartRequired = append(result.RestartRequired, "audit")
+	}
+	if current.TwoFactor != loaded.TwoFactor {
+		result.RestartRequired = append(result.RestartRequired, "two_factor")
+	}
+	if current.Stats != loaded.Stats {
+		result.RestartRequired = append(result.RestartRequired, "stats")
+	}
+	if current.Secrets != loaded.Secrets {
+		result.RestartRequired = append(result.RestartRequired, "secrets")
+	}
+	if current.Exports != loaded.Exports {
+		result.RestartRequired = append(result.RestartRequired, "exports")
+	}
+	return &next, result
+}
+
+// withSearchLimits returns base with the search limits of from, the only
+// search settings that can change without a restart
+func withSearchLimits(base, from SearchConfig) SearchConfig {
+	base.LargeBlueprintEntities = from.LargeBlueprintEntities
+	base.ExpensivePerMinute = from.ExpensivePerMinute
+	base.ExpensiveConcurrency = from.ExpensiveConcurrency
+	base.MaxOffset = from.MaxOffset
+	return base
+}
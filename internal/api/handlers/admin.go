@@ -9,16 +9,22 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/baseplate/baseplate/config"
 	"github.com/baseplate/baseplate/internal/api/middleware"
 	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/logging"
 )
 
 type AdminHandler struct {
 	authService *auth.Service
+	reloader    *config.Reloader
+	logs        *logging.Controller
 }
 
-func NewAdminHandler(authService *auth.Service) *AdminHandler {
-	return &AdminHandler{authService: authService}
+// NewAdminHandler creates the admin handler. reloader and logs may be nil, in
+// which case configuration reloads and log control are unavailable.
+func NewAdminHandler(authService *auth.Service, reloader *config.Reloader, logs *logging.Controller) *AdminHandler {
+	return &AdminHandler{authService: authService, reloader: reloader, logs: logs}
 }
 
 // getAuditContext extracts IP address and user agent from the request context for audit logging.
@@ -35,6 +41,14 @@ func getAuditContext(c *gin.Context) (*string, *string) {
 	return ipPtr, uaPtr
 }
 
+// optionalUserID returns the calling user, or nil for API key requests
+func optionalUserID(c *gin.Context) *uuid.UUID {
+	if id, ok := middleware.GetUserID(c); ok {
+		return &id
+	}
+	return nil
+}
+
 // ListTeams returns all teams in the system (super admin only)
 func (h *AdminHandler) ListTeams(c *gin.Context) {
 	limit := 50
@@ -157,7 +171,7 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 
 	var req UpdateUserRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -173,14 +187,24 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
+	if user.Status == auth.UserStatusDeleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "deleted users cannot be changed"})
+		return
+	}
+
 	// Update allowed fields
 	if req.Name != "" {
 		user.Name = req.Name
 	}
-	if req.Status != "" {
-		// Validate status value
-		if req.Status != "active" && req.Status != "deleted" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status value, must be 'active' or 'deleted'"})
+	if req.Status != "" && req.Status != user.Status {
+		// Only reactivation goes through here; deactivation and deletion
+		// revoke access and have their own endpoints
+		if req.Status != auth.UserStatusActive {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status value, must be 'active'; use the deactivate and delete endpoints otherwise"})
+			return
+		}
+		if user.Status != auth.UserStatusDeactivated {
+			c.JSON(http.StatusConflict, gin.H{"error": "only deactivated users can be reactivated"})
 			return
 		}
 		user.Status = req.Status
@@ -195,6 +219,217 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// CreateUser creates a user with a temporary password or an invite token (super admin only)
+func (h *AdminHandler) CreateUser(c *gin.Context) {
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user id"})
+		return
+	}
+
+	var req auth.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.CreateUser(c.Request.Context(), actorID, &req, ipPtr, uaPtr)
+	if err != nil {
+		respondUserLifecycleError(c, "create", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// DeactivateUser blocks a user and revokes their sessions and API keys (super admin only)
+func (h *AdminHandler) DeactivateUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user id"})
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.DeactivateUser(c.Request.Context(), actorID, userID, ipPtr, uaPtr)
+	if err != nil {
+		respondUserLifecycleError(c, "deactivate", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteUser anonymizes a user and removes their access (super admin only)
+func (h *AdminHandler) DeleteUser(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user id"})
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	if err := h.authService.DeleteUser(c.Request.Context(), actorID, userID, ipPtr, uaPtr); err != nil {
+		respondUserLifecycleError(c, "delete", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondUserLifecycleError(c *gin.Context, action string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, auth.ErrInvalidName):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, auth.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "email is already in use"})
+	case errors.Is(err, auth.ErrSelfModification), errors.Is(err, auth.ErrIsSuperAdmin), errors.Is(err, auth.ErrLastAdmin),
+		errors.Is(err, auth.ErrTwoFactorDisabled), errors.Is(err, auth.ErrImpersonationNotAllowed):
+		respondError(c, http.StatusConflict, err)
+	case errors.Is(err, auth.ErrInvalidImpersonation):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a reason is required and duration_minutes must not exceed the configured maximum"})
+	default:
+		log.Printf("ERROR: failed to %s user: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
+// ResetTwoFactor turns off two-factor authentication for a user who lost
+// their second factor (super admin only)
+func (h *AdminHandler) ResetTwoFactor(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user id"})
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	if err := h.authService.ResetTwoFactor(c.Request.Context(), actorID, userID, ipPtr, uaPtr); err != nil {
+		respondUserLifecycleError(c, "reset two-factor authentication of", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Impersonate mints a short-lived token acting as a user, for debugging
+// team issues (super admin only)
+func (h *AdminHandler) Impersonate(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user id"})
+		return
+	}
+	var req auth.ImpersonateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	twoFactor := false
+	if token := middleware.GetToken(c); token != nil {
+		twoFactor = token.TwoFactor
+	}
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.Impersonate(c.Request.Context(), actorID, userID, &req, twoFactor, ipPtr, uaPtr)
+	if err != nil {
+		respondUserLifecycleError(c, "impersonate", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// ListImpersonations returns impersonation sessions, newest first (super admin only)
+func (h *AdminHandler) ListImpersonations(c *gin.Context) {
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+	activeOnly := c.Query("active") == "true"
+
+	sessions, err := h.authService.ListImpersonations(c.Request.Context(), activeOnly, limit, offset)
+	if err != nil {
+		log.Printf("ERROR: failed to list impersonations: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"impersonations": sessions,
+		"limit":          limit,
+		"offset":         offset,
+	})
+}
+
+// RevokeImpersonation ends an impersonation session so its token stops
+// working (super admin only)
+func (h *AdminHandler) RevokeImpersonation(c *gin.Context) {
+	sessionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid impersonation id"})
+		return
+	}
+	actorID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing user id"})
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	session, err := h.authService.RevokeImpersonation(c.Request.Context(), actorID, sessionID, ipPtr, uaPtr)
+	if err != nil {
+		respondImpersonationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+func respondImpersonationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "impersonation session not found"})
+	case errors.Is(err, auth.ErrImpersonationEnded):
+		respondError(c, http.StatusConflict, err)
+	default:
+		log.Printf("ERROR: failed to revoke impersonation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
+
 // PromoteUser promotes a user to super admin (super admin only)
 func (h *AdminHandler) PromoteUser(c *gin.Context) {
 	userIDStr := c.Param("userId")
@@ -315,3 +550,80 @@ type UpdateUserRequest struct {
 	Name   string `json:"name"`
 	Status string `json:"status"`
 }
+
+// ReloadConfig re-reads the configuration and applies the settings that can
+// change without a restart (super admin only)
+func (h *AdminHandler) ReloadConfig(c *gin.Context) {
+	if h.reloader == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "configuration reload is not enabled"})
+		return
+	}
+
+	result, err := h.reloader.Reload()
+	if err != nil {
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": verr.Error()})
+			return
+		}
+		log.Printf("ERROR: failed to reload configuration: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read configuration"})
+		return
+	}
+
+	if actorID, ok := middleware.GetUserID(c); ok {
+		log.Printf("Configuration reloaded by %s: applied %v, restart required for %v", actorID, result.Applied, result.RestartRequired)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetLogging returns the current log level and format (super admin only)
+func (h *AdminHandler) GetLogging(c *gin.Context) {
+	if h.logs == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "log control is not enabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"level": h.logs.Level(), "format": h.logs.Format()})
+}
+
+// UpdateLoggingRequest changes the log level, format or both
+type UpdateLoggingRequest struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+}
+
+// UpdateLogging changes the log level and format until the next restart or
+// configuration reload that changes them (super admin only)
+func (h *AdminHandler) UpdateLogging(c *gin.Context) {
+	if h.logs == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "log control is not enabled"})
+		return
+	}
+
+	var req UpdateLoggingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Level == "" && req.Format == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level or format is required"})
+		return
+	}
+
+	level, format := h.logs.Level(), h.logs.Format()
+	if req.Level != "" {
+		level = req.Level
+	}
+	if req.Format != "" {
+		format = req.Format
+	}
+	if err := h.logs.Set(level, format); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if actorID, ok := middleware.GetUserID(c); ok {
+		log.Printf("Logging changed by %s: level %s, format %s", actorID, h.logs.Level(), h.logs.Format())
+	}
+	c.JSON(http.StatusOK, gin.H{"level": h.logs.Level(), "format": h.logs.Format()})
+}
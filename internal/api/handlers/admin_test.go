@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -9,6 +10,7 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/auth"
 )
 
 func init() {
@@ -310,3 +312,76 @@ func TestUpdateUserRequest_PartialUpdate(t *testing.T) {
 		t.Error("Status should be empty for partial update")
 	}
 }
+
+func TestRespondUserLifecycleError_Impersonation(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{auth.ErrNotFound, http.StatusNotFound},
+		{auth.ErrInvalidImpersonation, http.StatusBadRequest},
+		{auth.ErrSelfModification, http.StatusConflict},
+		{auth.ErrIsSuperAdmin, http.StatusConflict},
+		{auth.ErrImpersonationNotAllowed, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondUserLifecycleError(c, "impersonate", tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondUserLifecycleError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondImpersonationError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{auth.ErrNotFound, http.StatusNotFound},
+		{auth.ErrImpersonationEnded, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondImpersonationError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondImpersonationError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestStatsDays(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", 30},
+		{"?days=7", 7},
+		{"?days=365", 365},
+		{"?days=0", 30},
+		{"?days=366", 30},
+		{"?days=week", 30},
+	}
+	for _, tt := range tests {
+		c, _ := createAdminTestContext()
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/admin/stats"+tt.query, nil)
+		if got := statsDays(c); got != tt.want {
+			t.Errorf("statsDays(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestStatsTeam_InvalidTeamID(t *testing.T) {
+	c, w := createAdminTestContext()
+	c.Params = gin.Params{{Key: "teamId", Value: "not-a-uuid"}}
+
+	NewStatsHandler(nil, nil).Team(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"errors"
+	"io"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 
 	"github.com/baseplate/baseplate/internal/api/middleware"
 	"github.com/baseplate/baseplate/internal/core/auth"
@@ -21,7 +23,7 @@ func NewAuthHandler(authService *auth.Service) *AuthHandler {
 func (h *AuthHandler) Register(c *gin.Context) {
 	var req auth.RegisterRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -41,7 +43,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 func (h *AuthHandler) Login(c *gin.Context) {
 	var req auth.LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -51,6 +53,50 @@ func (h *AuthHandler) Login(c *gin.Context) {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
 			return
 		}
+		if errors.Is(err, auth.ErrInactiveUser) {
+			respondError(c, http.StatusForbidden, err)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// LoginTwoFactor completes a login with an authenticator or backup code
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req auth.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.LoginTwoFactor(c.Request.Context(), &req, ipPtr, uaPtr)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// AcceptInvite sets an invited user's password and logs them in
+func (h *AuthHandler) AcceptInvite(c *gin.Context) {
+	var req auth.AcceptInviteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.AcceptInvite(c.Request.Context(), &req, ipPtr, uaPtr)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired invite token"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
 		return
 	}
@@ -58,22 +104,245 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+// Me returns the caller with their team memberships, roles and token metadata
 func (h *AuthHandler) Me(c *gin.Context) {
+	var userID *uuid.UUID
+	if id, ok := middleware.GetUserID(c); ok {
+		userID = &id
+	}
+
+	resp, err := h.authService.WhoAmI(c.Request.Context(), userID, middleware.GetToken(c))
+	if err != nil {
+		if errors.Is(err, auth.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateMe changes the caller's name or starts an email change
+func (h *AuthHandler) UpdateMe(c *gin.Context) {
 	userID, ok := middleware.GetUserID(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		c.JSON(http.StatusForbidden, gin.H{"error": "only users have a profile"})
+		return
+	}
+
+	var req auth.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	user, err := h.authService.GetUserByID(c.Request.Context(), userID)
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.UpdateProfile(c.Request.Context(), userID, &req, ipPtr, uaPtr)
 	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ChangePassword replaces the caller's password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	userID, ok := middleware.GetUserID(c)
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only users have a password"})
+		return
+	}
+
+	var req auth.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	if err := h.authService.ChangePassword(c.Request.Context(), userID, &req, ipPtr, uaPtr); err != nil {
+		respondProfileError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// VerifyEmail confirms a pending email change with the mailed token
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req auth.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	user, err := h.authService.VerifyEmail(c.Request.Context(), req.Token, ipPtr, uaPtr)
+	if err != nil {
+		respondProfileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+func respondProfileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, auth.ErrWrongPassword):
+		respondError(c, http.StatusForbidden, err)
+	case errors.Is(err, auth.ErrInvalidName), errors.Is(err, auth.ErrPasswordUnchanged), errors.Is(err, auth.ErrInvalidToken):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, auth.ErrUserExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "email is already in use"})
+	case errors.Is(err, auth.ErrMailUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+	default:
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
+
+// twoFactorUser returns the caller of a two-factor endpoint. Only user
+// tokens qualify: API keys never go through the login second step.
+func twoFactorUser(c *gin.Context) (uuid.UUID, *auth.TokenInfo, bool) {
+	token := middleware.GetToken(c)
+	userID, ok := middleware.GetUserID(c)
+	if token == nil || token.Type != auth.TokenTypeJWT || token.ExpiresAt == nil || !ok {
+		c.JSON(http.StatusForbidden, gin.H{"error": "two-factor authentication is managed with a user token"})
+		return uuid.Nil, nil, false
+	}
+	return userID, token, true
+}
+
+// EnrollTwoFactor generates a TOTP secret to load into an authenticator app
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	userID, _, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	resp, err := h.authService.EnrollTwoFactor(c.Request.Context(), userID)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ConfirmTwoFactor enables two-factor authentication with a first code and
+// returns the backup codes
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	userID, token, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	var req auth.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.ConfirmTwoFactor(c.Request.Context(), userID, &req, *token.ExpiresAt, ipPtr, uaPtr)
+	if err != nil {
+		respondTwoFactorError(c, err)
 		return
 	}
-	if user == nil {
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RegenerateBackupCodes replaces the caller's backup codes
+func (h *AuthHandler) RegenerateBackupCodes(c *gin.Context) {
+	userID, _, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	var req auth.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	resp, err := h.authService.RegenerateBackupCodes(c.Request.Context(), userID, &req, ipPtr, uaPtr)
+	if err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DisableTwoFactor turns off two-factor authentication for the caller
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	userID, _, ok := twoFactorUser(c)
+	if !ok {
+		return
+	}
+
+	var req auth.DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipPtr, uaPtr := getAuditContext(c)
+	if err := h.authService.DisableTwoFactor(c.Request.Context(), userID, &req, ipPtr, uaPtr); err != nil {
+		respondTwoFactorError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondTwoFactorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, auth.ErrInvalidChallenge), errors.Is(err, auth.ErrInvalidCode):
+		respondError(c, http.StatusUnauthorized, err)
+	case errors.Is(err, auth.ErrWrongPassword), errors.Is(err, auth.ErrInactiveUser):
+		respondError(c, http.StatusForbidden, err)
+	case errors.Is(err, auth.ErrTwoFactorEnabled), errors.Is(err, auth.ErrTwoFactorDisabled), errors.Is(err, auth.ErrTwoFactorPending):
+		respondError(c, http.StatusConflict, err)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+	}
+}
+
+// Refresh reissues the caller's JWT with current memberships, optionally
+// embedding the teams listed in the body. The expiry is unchanged.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	token := middleware.GetToken(c)
+	userID, ok := middleware.GetUserID(c)
+	if token == nil || token.Type != auth.TokenTypeJWT || token.ExpiresAt == nil || !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "only user tokens can be refreshed"})
+		return
+	}
+
+	var req auth.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, user)
+	resp, err := h.authService.RefreshToken(c.Request.Context(), userID, *token.ExpiresAt, req.TeamIDs, token.TwoFactor)
+	if err != nil {
+		if errors.Is(err, auth.ErrNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Something went wrong"})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
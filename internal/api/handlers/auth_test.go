@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+)
+
+func TestRespondProfileError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{auth.ErrNotFound, http.StatusNotFound},
+		{auth.ErrWrongPassword, http.StatusForbidden},
+		{auth.ErrInvalidName, http.StatusBadRequest},
+		{auth.ErrPasswordUnchanged, http.StatusBadRequest},
+		{auth.ErrInvalidToken, http.StatusBadRequest},
+		{auth.ErrUserExists, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondProfileError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondProfileError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondTwoFactorError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{auth.ErrNotFound, http.StatusNotFound},
+		{auth.ErrInvalidChallenge, http.StatusUnauthorized},
+		{auth.ErrInvalidCode, http.StatusUnauthorized},
+		{auth.ErrWrongPassword, http.StatusForbidden},
+		{auth.ErrInactiveUser, http.StatusForbidden},
+		{auth.ErrTwoFactorEnabled, http.StatusConflict},
+		{auth.ErrTwoFactorDisabled, http.StatusConflict},
+		{auth.ErrTwoFactorPending, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondTwoFactorError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondTwoFactorError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"encoding/json"
 	"errors"
 	"net/http"
 
@@ -8,6 +9,7 @@ import (
 
 	"github.com/baseplate/baseplate/internal/api/middleware"
 	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/validation"
 )
 
 type BlueprintHandler struct {
@@ -27,17 +29,26 @@ func (h *BlueprintHandler) Create(c *gin.Context) {
 
 	var req blueprint.CreateBlueprintRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	bp, err := h.blueprintService.Create(c.Request.Context(), teamID, &req)
 	if err != nil {
+		if validation.IsValidationError(err) {
+			c.Error(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": validation.GetValidationErrors(err)})
+			return
+		}
 		if errors.Is(err, blueprint.ErrAlreadyExists) {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		if errors.Is(err, blueprint.ErrInvalidMergePolicy) || errors.Is(err, blueprint.ErrInvalidExpiryPolicy) || errors.Is(err, blueprint.ErrInvalidDefault) || errors.Is(err, blueprint.ErrInvalidRollup) {
+			respondError(c, http.StatusBadRequest, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -53,7 +64,7 @@ func (h *BlueprintHandler) List(c *gin.Context) {
 
 	resp, err := h.blueprintService.List(c.Request.Context(), teamID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -71,10 +82,10 @@ func (h *BlueprintHandler) Get(c *gin.Context) {
 	bp, err := h.blueprintService.Get(c.Request.Context(), teamID, id)
 	if err != nil {
 		if errors.Is(err, blueprint.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -92,17 +103,21 @@ func (h *BlueprintHandler) Update(c *gin.Context) {
 
 	var req blueprint.UpdateBlueprintRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	bp, err := h.blueprintService.Update(c.Request.Context(), teamID, id, &req)
 	if err != nil {
 		if errors.Is(err, blueprint.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, blueprint.ErrInvalidMergePolicy) || errors.Is(err, blueprint.ErrInvalidExpiryPolicy) || errors.Is(err, blueprint.ErrInvalidDefault) || errors.Is(err, blueprint.ErrInvalidRollup) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -118,14 +133,56 @@ func (h *BlueprintHandler) Delete(c *gin.Context) {
 
 	id := c.Param("id")
 
-	if err := h.blueprintService.Delete(c.Request.Context(), teamID, id); err != nil {
-		if errors.Is(err, blueprint.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.blueprintService.Delete(c.Request.Context(), teamID, id, c.Query("force") == "true"); err != nil {
+		respondBlueprintDeleteError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
+
+// Schema serves the blueprint's entity schema as a standalone JSON Schema
+// that tools outside Baseplate can validate entity data against
+func (h *BlueprintHandler) Schema(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	schema, err := h.blueprintService.GetStandaloneSchema(c.Request.Context(), teamID, c.Param("id"))
+	if err != nil {
+		respondBlueprintSchemaError(c, err)
+		return
+	}
+
+	body, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/schema+json", body)
+}
+
+func respondBlueprintSchemaError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, blueprint.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, blueprint.ErrUnresolvableSchema):
+		respondError(c, http.StatusUnprocessableEntity, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
+
+func respondBlueprintDeleteError(c *gin.Context, err error) {
+	var hasEntities *blueprint.HasEntitiesError
+	switch {
+	case errors.Is(err, blueprint.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.As(err, &hasEntities):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "entities": hasEntities.Entities})
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+func TestRespondBlueprintDeleteError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{blueprint.ErrNotFound, http.StatusNotFound},
+		{&blueprint.HasEntitiesError{Entities: 1200}, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondBlueprintDeleteError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondBlueprintDeleteError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondBlueprintDeleteError_EntityCount(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	respondBlueprintDeleteError(c, &blueprint.HasEntitiesError{Entities: 1200})
+
+	var body struct {
+		Entities int64 `json:"entities"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Entities != 1200 {
+		t.Errorf("entities = %d, want 1200", body.Entities)
+	}
+}
+
+func TestRespondBlueprintSchemaError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{blueprint.ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: #/$defs/node is recursive", blueprint.ErrUnresolvableSchema), http.StatusUnprocessableEntity},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondBlueprintSchemaError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondBlueprintSchemaError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/bundle"
+)
+
+type BundleHandler struct {
+	bundleService *bundle.Service
+}
+
+func NewBundleHandler(bundleService *bundle.Service) *BundleHandler {
+	return &BundleHandler{bundleService: bundleService}
+}
+
+// Export returns the team's blueprints, relations, scorecards and actions as
+// one bundle; ?blueprints=a,b limits it to those blueprints
+func (h *BundleHandler) Export(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var blueprintIDs []string
+	if param := c.Query("blueprints"); param != "" {
+		for _, id := range strings.Split(param, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				blueprintIDs = append(blueprintIDs, id)
+			}
+		}
+	}
+
+	b, err := h.bundleService.Export(c.Request.Context(), teamID, blueprintIDs)
+	if err != nil {
+		if errors.Is(err, bundle.ErrBlueprintNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, b)
+}
+
+// ExportCode returns the team's blueprints, roles, actions and API keys as
+// YAML or Terraform; ?include=roles,api_keys limits it to those kinds
+func (h *BundleHandler) ExportCode(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var kinds []string
+	if param := c.Query("include"); param != "" {
+		for _, kind := range strings.Split(param, ",") {
+			if kind = strings.TrimSpace(kind); kind != "" {
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+	format := c.DefaultQuery("format", bundle.FormatYAML)
+
+	code, err := h.bundleService.ExportCode(c.Request.Context(), teamID, format, kinds)
+	if err != nil {
+		if errors.Is(err, bundle.ErrInvalidExport) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	contentType, filename := "application/yaml", "baseplate.yaml"
+	if format == bundle.FormatTerraform {
+		contentType, filename = "text/plain; charset=utf-8", "baseplate.tf"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Data(http.StatusOK, contentType, code)
+}
+
+// Import applies a bundle to the team
+func (h *BundleHandler) Import(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var b bundle.Bundle
+	if err := c.ShouldBindJSON(&b); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	strategy := c.DefaultQuery("strategy", bundle.StrategySkip)
+	dryRun := c.Query("dry_run") == "true"
+
+	result, err := h.bundleService.Import(c.Request.Context(), teamID, &b, strategy, dryRun)
+	if err != nil {
+		respondBundleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Apply brings the team's blueprints, roles and scorecards in line with a
+// manifest; ?dry_run=true only returns the plan
+func (h *BundleHandler) Apply(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var m bundle.Manifest
+	if err := c.ShouldBindJSON(&m); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.bundleService.Apply(c.Request.Context(), teamID, &m, c.Query("dry_run") == "true")
+	if err != nil {
+		respondBundleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// respondBundleError maps import and apply errors; taking team:manage from
+// the team's last managers is a conflict, as it is for role updates
+func respondBundleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, bundle.ErrInvalidBundle):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, bundle.ErrConflict), errors.Is(err, auth.ErrLastAdmin):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
+
+// Check validates manifest files for a pre-merge check and returns GitHub
+// Checks API annotations. Problems are part of the result, not errors.
+func (h *BundleHandler) Check(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req bundle.CheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.bundleService.Check(c.Request.Context(), teamID, &req)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/bundle"
+)
+
+func TestRespondBundleError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: duplicate role %q", bundle.ErrInvalidBundle, "viewer"), http.StatusBadRequest},
+		{bundle.ErrConflict, http.StatusConflict},
+		{auth.ErrLastAdmin, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondBundleError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondBundleError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/dlq"
+)
+
+// DLQHandler reports the dead jobs and outbox events awaiting a super admin
+type DLQHandler struct {
+	service *dlq.Service
+}
+
+func NewDLQHandler(service *dlq.Service) *DLQHandler {
+	return &DLQHandler{service: service}
+}
+
+// Get returns the dead letters by source and whether they are past the alert threshold
+func (h *DLQHandler) Get(c *gin.Context) {
+	summary, err := h.service.Summary(c.Request.Context())
+	if err != nil {
+		log.Printf("ERROR: dlq: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
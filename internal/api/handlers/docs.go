@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/docs"
+)
+
+// DocsHandler serves the markdown documentation pages of blueprints and
+// entities
+type DocsHandler struct {
+	docsService *docs.Service
+}
+
+func NewDocsHandler(docsService *docs.Service) *DocsHandler {
+	return &DocsHandler{docsService: docsService}
+}
+
+// GetBlueprintDoc returns a blueprint's page, at ?version=N if given
+func (h *DocsHandler) GetBlueprintDoc(c *gin.Context) {
+	if subject, ok := h.blueprintSubject(c); ok {
+		h.get(c, subject)
+	}
+}
+
+// PutBlueprintDoc saves a new version of a blueprint's page
+func (h *DocsHandler) PutBlueprintDoc(c *gin.Context) {
+	if subject, ok := h.blueprintSubject(c); ok {
+		h.put(c, subject)
+	}
+}
+
+// DeleteBlueprintDoc removes a blueprint's page with its versions
+func (h *DocsHandler) DeleteBlueprintDoc(c *gin.Context) {
+	if subject, ok := h.blueprintSubject(c); ok {
+		h.delete(c, subject)
+	}
+}
+
+// BlueprintDocVersions lists the versions of a blueprint's page
+func (h *DocsHandler) BlueprintDocVersions(c *gin.Context) {
+	if subject, ok := h.blueprintSubject(c); ok {
+		h.versions(c, subject)
+	}
+}
+
+// RenderBlueprintDoc returns a blueprint's page rendered to HTML
+func (h *DocsHandler) RenderBlueprintDoc(c *gin.Context) {
+	if subject, ok := h.blueprintSubject(c); ok {
+		h.render(c, subject)
+	}
+}
+
+// GetEntityDoc returns an entity's page, at ?version=N if given
+func (h *DocsHandler) GetEntityDoc(c *gin.Context) {
+	if subject, ok := h.entitySubject(c); ok {
+		h.get(c, subject)
+	}
+}
+
+// PutEntityDoc saves a new version of an entity's page
+func (h *DocsHandler) PutEntityDoc(c *gin.Context) {
+	if subject, ok := h.entitySubject(c); ok {
+		h.put(c, subject)
+	}
+}
+
+// DeleteEntityDoc removes an entity's page with its versions
+func (h *DocsHandler) DeleteEntityDoc(c *gin.Context) {
+	if subject, ok := h.entitySubject(c); ok {
+		h.delete(c, subject)
+	}
+}
+
+// EntityDocVersions lists the versions of an entity's page
+func (h *DocsHandler) EntityDocVersions(c *gin.Context) {
+	if subject, ok := h.entitySubject(c); ok {
+		h.versions(c, subject)
+	}
+}
+
+// RenderEntityDoc returns an entity's page rendered to HTML
+func (h *DocsHandler) RenderEntityDoc(c *gin.Context) {
+	if subject, ok := h.entitySubject(c); ok {
+		h.render(c, subject)
+	}
+}
+
+// Search finds the team's pages matching ?q=, best match first
+func (h *DocsHandler) Search(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	resp, err := h.docsService.Search(c.Request.Context(), teamID, c.Query("q"), limit)
+	if err != nil {
+		respondDocsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *DocsHandler) blueprintSubject(c *gin.Context) (docs.Subject, bool) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return docs.Subject{}, false
+	}
+
+	subject, err := h.docsService.BlueprintSubject(c.Request.Context(), teamID, c.Param("id"))
+	if err != nil {
+		respondDocsError(c, err)
+		return docs.Subject{}, false
+	}
+	return subject, true
+}
+
+func (h *DocsHandler) entitySubject(c *gin.Context) (docs.Subject, bool) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return docs.Subject{}, false
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+		return docs.Subject{}, false
+	}
+
+	subject, err := h.docsService.EntitySubject(c.Request.Context(), teamID, id)
+	if err != nil {
+		respondDocsError(c, err)
+		return docs.Subject{}, false
+	}
+	return subject, true
+}
+
+func (h *DocsHandler) get(c *gin.Context, subject docs.Subject) {
+	version, ok := docVersion(c)
+	if !ok {
+		return
+	}
+
+	d, err := h.docsService.Get(c.Request.Context(), subject, version)
+	if err != nil {
+		respondDocsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
+
+func (h *DocsHandler) put(c *gin.Context, subject docs.Subject) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, 2*docs.MaxContentBytes)
+	var req docs.PutDocRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var userID *uuid.UUID
+	if id, ok := middleware.GetUserID(c); ok {
+		userID = &id
+	}
+	d, err := h.docsService.Put(c.Request.Context(), subject, userID, &req)
+	if err != nil {
+		respondDocsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
+
+func (h *DocsHandler) delete(c *gin.Context, subject docs.Subject) {
+	if err := h.docsService.Delete(c.Request.Context(), subject); err != nil {
+		respondDocsError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *DocsHandler) versions(c *gin.Context, subject docs.Subject) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	resp, err := h.docsService.Versions(c.Request.Context(), subject, limit, offset)
+	if err != nil {
+		respondDocsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *DocsHandler) render(c *gin.Context, subject docs.Subject) {
+	version, ok := docVersion(c)
+	if !ok {
+		return
+	}
+
+	rendered, err := h.docsService.Render(c.Request.Context(), subject, version)
+	if err != nil {
+		respondDocsError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rendered)
+}
+
+// docVersion parses the ?version=N of a page request, 0 for the current one
+func docVersion(c *gin.Context) (int, bool) {
+	raw := c.Query("version")
+	if raw == "" {
+		return 0, true
+	}
+	version, err := strconv.Atoi(raw)
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version must be a positive integer"})
+		return 0, false
+	}
+	return version, true
+}
+
+func respondDocsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, docs.ErrInvalidDoc):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, docs.ErrSearchForbidden):
+		respondError(c, http.StatusForbidden, err)
+	case errors.Is(err, docs.ErrNotFound), errors.Is(err, docs.ErrBlueprintNotFound), errors.Is(err, docs.ErrEntityNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, docs.ErrVersionConflict):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/docs"
+)
+
+func TestRespondDocsError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: content is required", docs.ErrInvalidDoc), http.StatusBadRequest},
+		{docs.ErrSearchForbidden, http.StatusForbidden},
+		{docs.ErrNotFound, http.StatusNotFound},
+		{docs.ErrBlueprintNotFound, http.StatusNotFound},
+		{docs.ErrEntityNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: the page is at version 3", docs.ErrVersionConflict), http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondDocsError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondDocsError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
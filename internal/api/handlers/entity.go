@@ -1,24 +1,48 @@
 package handlers
 
 import (
+	"context"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
 	"net/http"
+	"path"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/apierror"
+	"github.com/baseplate/baseplate/internal/core/auth"
 	"github.com/baseplate/baseplate/internal/core/entity"
 	"github.com/baseplate/baseplate/internal/core/validation"
+	"github.com/baseplate/baseplate/internal/core/view"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
 )
 
+// maxImportBytes bounds the size of an import upload
+const maxImportBytes = 32 << 20
+
+// maxPatchBytes bounds the size of a patch document
+const maxPatchBytes = 1 << 20
+
+// integrationHeader names the integration an exporter writes entities for
+const integrationHeader = "X-Integration-ID"
+
 type EntityHandler struct {
 	entityService *entity.Service
+	viewService   *view.Service
 }
 
-func NewEntityHandler(entityService *entity.Service) *EntityHandler {
-	return &EntityHandler{entityService: entityService}
+func NewEntityHandler(entityService *entity.Service, viewService *view.Service) *EntityHandler {
+	return &EntityHandler{entityService: entityService, viewService: viewService}
 }
 
 func (h *EntityHandler) Create(c *gin.Context) {
@@ -28,33 +52,55 @@ func (h *EntityHandler) Create(c *gin.Context) {
 		return
 	}
 
-	blueprintID := c.Param("blueprintId")
+	blueprintID := c.Param("id")
 
 	var req entity.CreateEntityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	ent, err := h.entityService.Create(c.Request.Context(), teamID, blueprintID, &req)
-	if err != nil {
-		if errors.Is(err, entity.ErrAlreadyExists) {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
-			return
-		}
-		if errors.Is(err, entity.ErrBlueprintNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-			return
-		}
-		if validation.IsValidationError(err) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": validation.GetValidationErrors(err)})
+	ctx, ok := h.writeContext(c)
+	if !ok {
+		return
+	}
+
+	if checkOnly(c) {
+		result, err := h.entityService.ValidateCreate(ctx, teamID, blueprintID, &req)
+		if err != nil {
+			respondCreateError(c, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		h.respondValidation(c, result)
 		return
 	}
 
-	c.JSON(http.StatusCreated, ent)
+	ent, err := h.entityService.Create(ctx, teamID, blueprintID, &req)
+	if err != nil {
+		respondCreateError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(ent.Version))
+	h.respondEntity(c, http.StatusCreated, ent)
+}
+
+func respondCreateError(c *gin.Context, err error) {
+	if validation.IsValidationError(err) {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": validation.GetValidationErrors(err)})
+		return
+	}
+	switch {
+	case errors.Is(err, entity.ErrAlreadyExists), errors.Is(err, entity.ErrRollupProperty):
+		respondError(c, http.StatusConflict, err)
+	case errors.Is(err, entity.ErrBlueprintNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, entity.ErrRestrictedProperty):
+		respondError(c, http.StatusForbidden, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
 }
 
 func (h *EntityHandler) List(c *gin.Context) {
@@ -64,7 +110,7 @@ func (h *EntityHandler) List(c *gin.Context) {
 		return
 	}
 
-	blueprintID := c.Param("blueprintId")
+	blueprintID := c.Param("id")
 
 	limitStr := c.DefaultQuery("limit", "50")
 	limit, err := strconv.Atoi(limitStr)
@@ -72,14 +118,78 @@ func (h *EntityHandler) List(c *gin.Context) {
 		limit = 50
 	}
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	fields, include := c.Query("fields"), c.Query("include")
 
-	resp, err := h.entityService.List(c.Request.Context(), teamID, blueprintID, limit, offset)
+	// ?view=<id> applies a saved view, ?view=none lists without one; otherwise the default view applies
+	var v *view.View
+	switch param := c.Query("view"); param {
+	case "none":
+	case "":
+		v, err = h.viewService.Default(c.Request.Context(), teamID, blueprintID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+	default:
+		id, err := uuid.Parse(param)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid view id"})
+			return
+		}
+		v, err = h.viewService.Get(c.Request.Context(), teamID, blueprintID, id, viewerFromContext(c))
+		if err != nil {
+			respondViewError(c, err)
+			return
+		}
+	}
+
+	if v != nil {
+		req := v.SearchRequest(limit, offset)
+		req.Fields = fields
+		resp, err := h.entityService.Search(c.Request.Context(), teamID, blueprintID, req)
+		switch {
+		case err == nil:
+			h.entityService.RecordColumns(teamID, blueprintID, v.Columns)
+			// Search results may be cached and shared, so annotate a copy
+			out := *resp
+			out.View = v.Applied()
+			h.respondList(c, &out, teamID, blueprintID, fields, include)
+			return
+		case respondThrottled(c, err), respondTimeout(c, err):
+			return
+		case errors.Is(err, entity.ErrInvalidFilter) && c.Query("view") == "":
+			// A schema change broke the default view; keep the list usable
+			log.Printf("WARNING: default view %s of blueprint %s no longer applies: %v", v.ID, blueprintID, err)
+		case errors.Is(err, entity.ErrInvalidFilter):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "view no longer matches the blueprint schema: " + err.Error()})
+			return
+		case errors.Is(err, entity.ErrBlueprintNotFound):
+			respondError(c, http.StatusNotFound, err)
+			return
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	resp, err := h.entityService.List(c.Request.Context(), teamID, blueprintID, limit, offset, fields)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondTimeout(c, err) {
+			return
+		}
+		if errors.Is(err, entity.ErrInvalidFilter) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, entity.ErrBlueprintNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	h.respondList(c, resp, teamID, blueprintID, fields, include)
 }
 
 func (h *EntityHandler) Search(c *gin.Context) {
@@ -89,21 +199,260 @@ func (h *EntityHandler) Search(c *gin.Context) {
 		return
 	}
 
-	blueprintID := c.Param("blueprintId")
+	blueprintID := c.Param("id")
 
 	var req entity.SearchRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
+	if fields := c.Query("fields"); fields != "" {
+		req.Fields = fields
+	}
 
 	resp, err := h.entityService.Search(c.Request.Context(), teamID, blueprintID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if respondThrottled(c, err) || respondTimeout(c, err) {
+			return
+		}
+		if errors.Is(err, entity.ErrInvalidFilter) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, entity.ErrBlueprintNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	h.respondList(c, resp, teamID, blueprintID, req.Fields, c.Query("include"))
+}
+
+// SearchAll searches every blueprint of the team and groups matches per blueprint
+func (h *EntityHandler) SearchAll(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req entity.CrossSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	resp, err := h.entityService.SearchAll(c.Request.Context(), teamID, &req)
+	if err != nil {
+		if respondThrottled(c, err) || respondTimeout(c, err) {
+			return
+		}
+		if errors.Is(err, entity.ErrInvalidFilter) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		if errors.Is(err, entity.ErrBlueprintNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, resp)
+	// Results may be cached and shared, so redact copies
+	out := *resp
+	out.Results = make([]entity.BlueprintResults, len(resp.Results))
+	for i, result := range resp.Results {
+		result.Entities, err = h.entityService.Redact(c.Request.Context(), result.Entities...)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		out.Results[i] = result
+	}
+	c.JSON(http.StatusOK, &out)
+}
+
+// ImportTemplate serves a CSV file with the columns expected by the import
+// endpoint and, unless example=false, one example row
+func (h *EntityHandler) ImportTemplate(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	blueprintID := c.Param("id")
+	withExample := c.DefaultQuery("example", "true") != "false"
+
+	records, err := h.entityService.CSVTemplate(c.Request.Context(), teamID, blueprintID, withExample)
+	if err != nil {
+		if errors.Is(err, entity.ErrBlueprintNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-import-template.csv"`, blueprintID))
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	if err := w.WriteAll(records); err != nil {
+		c.Error(err)
+	}
+}
+
+// Import creates or updates entities from a CSV or NDJSON upload, sent either
+// as the "file" field of a multipart form or as the raw request body
+func (h *EntityHandler) Import(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	blueprintID := c.Param("id")
+	body, format, err := importFile(c)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	defer body.Close()
+
+	ctx, ok := h.writeContext(c)
+	if !ok {
+		return
+	}
+	result, err := h.entityService.Import(ctx, teamID, blueprintID, format, body, importOptions(c))
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ImportCatalog imports entities of several blueprints with the relations
+// between them from one NDJSON file
+func (h *EntityHandler) ImportCatalog(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	body, format, err := importFile(c)
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+	defer body.Close()
+	if format == "" {
+		format = entity.FormatNDJSON
+	}
+
+	ctx, ok := h.writeContext(c)
+	if !ok {
+		return
+	}
+	result, err := h.entityService.ImportCatalog(ctx, teamID, format, body, importOptions(c))
+	if err != nil {
+		respondImportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// importFile returns the file of an import request and its format: the
+// "file" field of a multipart upload, or the raw body. The format comes from
+// ?format=, or else the file name or content type; it is empty when unknown.
+func importFile(c *gin.Context) (io.ReadCloser, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	format := c.Query("format")
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	if mediaType != "multipart/form-data" {
+		if format == "" {
+			format = importFormat("", mediaType)
+		}
+		return c.Request.Body, format, nil
+	}
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: %w", entity.ErrInvalidImport, err)
+	}
+	if format == "" {
+		format = importFormat(path.Ext(header.Filename), header.Header.Get("Content-Type"))
+	}
+	return file, format, nil
+}
+
+// importFormat infers the import format from a file extension or media type
+func importFormat(ext, mediaType string) string {
+	switch {
+	case ext == ".csv", mediaType == "text/csv":
+		return entity.FormatCSV
+	case ext == ".ndjson", ext == ".jsonl", mediaType == "application/x-ndjson", mediaType == "application/jsonl":
+		return entity.FormatNDJSON
+	}
+	return ""
+}
+
+func importOptions(c *gin.Context) entity.ImportOptions {
+	return entity.ImportOptions{
+		Mode:   c.DefaultQuery("mode", entity.ImportCreate),
+		DryRun: c.Query("dry_run") == "true",
+	}
+}
+
+func respondImportError(c *gin.Context, err error) {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("import files are limited to %d bytes", tooLarge.Limit)})
+	case errors.Is(err, entity.ErrInvalidImport), errors.Is(err, entity.ErrUnsupportedFormat):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, entity.ErrBlueprintNotFound):
+		respondError(c, http.StatusNotFound, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
+
+// respondThrottled writes a 429 with Retry-After for throttled searches and reports whether it did
+func respondThrottled(c *gin.Context, err error) bool {
+	var throttled *entity.ThrottledError
+	if !errors.As(err, &throttled) {
+		return false
+	}
+	retryAfter := int(math.Ceil(throttled.RetryAfter.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.Error(err)
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "retry_after_seconds": retryAfter})
+	return true
+}
+
+// respondTimeout writes a 504 for queries that ran out of time and reports whether it did
+func respondTimeout(c *gin.Context, err error) bool {
+	if !postgres.IsTimeout(err) {
+		return false
+	}
+	c.JSON(http.StatusGatewayTimeout, gin.H{
+		"error":  "query timed out",
+		"code":   apierror.CodeQueryTimeout,
+		"detail": "the query ran longer than the database allows; narrow the filters or lower the limit",
+	})
+	return true
 }
 
 func (h *EntityHandler) Get(c *gin.Context) {
@@ -117,14 +466,105 @@ func (h *EntityHandler) Get(c *gin.Context) {
 	ent, err := h.entityService.Get(c.Request.Context(), id)
 	if err != nil {
 		if errors.Is(err, entity.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("ETag", etag(ent.Version))
+	h.respondRead(c, ent, c.Query("include"))
+}
+
+// History returns an entity's revisions, or with ?property= the timeline of
+// one data property
+func (h *EntityHandler) History(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	resp, err := h.entityService.History(c.Request.Context(), teamID, id, c.Query("property"), limit, offset)
+	if err != nil {
+		if errors.Is(err, entity.ErrNotFound) {
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, entity.ErrRestrictedProperty) {
+			respondError(c, http.StatusForbidden, err)
+			return
+		}
+		if respondTimeout(c, err) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, ent)
+	c.JSON(http.StatusOK, resp)
+}
+
+// PropertyUsage reports how often each blueprint property was filtered,
+// sorted, grouped, aggregated and displayed over the last ?days=N days
+func (h *EntityHandler) PropertyUsage(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	days, _ := strconv.Atoi(c.DefaultQuery("days", "30"))
+
+	resp, err := h.entityService.PropertyUsage(c.Request.Context(), teamID, c.Param("id"), days)
+	if err != nil {
+		switch {
+		case errors.Is(err, entity.ErrBlueprintNotFound), errors.Is(err, entity.ErrUsageDisabled):
+			respondError(c, http.StatusNotFound, err)
+		default:
+			respondError(c, http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ColumnStats summarizes the values of a blueprint's properties, to judge
+// which are worth indexing (super admin only)
+func (h *EntityHandler) ColumnStats(c *gin.Context) {
+	teamID, err := uuid.Parse(c.Param("teamId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team id"})
+		return
+	}
+	sample, _ := strconv.Atoi(c.Query("sample"))
+	top, _ := strconv.Atoi(c.Query("top"))
+
+	resp, err := h.entityService.ColumnStats(c.Request.Context(), teamID, c.Param("blueprintId"), sample, top)
+	if err != nil {
+		if respondTimeout(c, err) {
+			return
+		}
+		if errors.Is(err, entity.ErrBlueprintNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 func (h *EntityHandler) GetByIdentifier(c *gin.Context) {
@@ -134,20 +574,21 @@ func (h *EntityHandler) GetByIdentifier(c *gin.Context) {
 		return
 	}
 
-	blueprintID := c.Param("blueprintId")
+	blueprintID := c.Param("id")
 	identifier := c.Param("identifier")
 
 	ent, err := h.entityService.GetByIdentifier(c.Request.Context(), teamID, blueprintID, identifier)
 	if err != nil {
 		if errors.Is(err, entity.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, ent)
+	c.Header("ETag", etag(ent.Version))
+	h.respondRead(c, ent, c.Query("include"))
 }
 
 func (h *EntityHandler) Update(c *gin.Context) {
@@ -158,27 +599,422 @@ func (h *EntityHandler) Update(c *gin.Context) {
 		return
 	}
 
+	ifVersion, err := ifMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
 	var req entity.UpdateEntityRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, ok := h.writeContext(c)
+	if !ok {
+		return
+	}
+
+	if checkOnly(c) {
+		result, err := h.entityService.ValidateUpdate(ctx, id, &req, ifVersion)
+		if err != nil {
+			respondUpdateError(c, err)
+			return
+		}
+		h.respondValidation(c, result)
+		return
+	}
+
+	ent, err := h.entityService.Update(ctx, id, &req, ifVersion)
+	if err != nil {
+		respondUpdateError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(ent.Version))
+	h.respondEntity(c, http.StatusOK, ent)
+}
+
+// Rename changes an entity's identifier, if its blueprint allows it
+func (h *EntityHandler) Rename(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+		return
+	}
+
+	ifVersion, err := ifMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var req entity.RenameEntityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ent, err := h.entityService.Rename(c.Request.Context(), id, &req, ifVersion)
+	if err != nil {
+		respondUpdateError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(ent.Version))
+	h.respondEntity(c, http.StatusOK, ent)
+}
+
+// Patch applies an RFC 7386 merge patch or RFC 6902 JSON patch, chosen by the
+// Content-Type, to an entity's title and data
+func (h *EntityHandler) Patch(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := uuid.Parse(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+		return
+	}
+
+	ifVersion, err := ifMatchVersion(c.GetHeader("If-Match"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPatchBytes))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("patches are limited to %d bytes", maxPatchBytes)})
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	patch, err := entity.ParsePatch(mediaType, body)
+	if err != nil {
+		if errors.Is(err, entity.ErrUnsupportedFormat) {
+			respondError(c, http.StatusUnsupportedMediaType, err)
+			return
+		}
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx, ok := h.writeContext(c)
+	if !ok {
+		return
+	}
+
+	if checkOnly(c) {
+		result, err := h.entityService.ValidatePatch(ctx, id, patch, ifVersion)
+		if err != nil {
+			respondUpdateError(c, err)
+			return
+		}
+		h.respondValidation(c, result)
+		return
+	}
+
+	ent, err := h.entityService.Patch(ctx, id, patch, ifVersion)
+	if err != nil {
+		respondUpdateError(c, err)
+		return
+	}
+
+	c.Header("ETag", etag(ent.Version))
+	h.respondEntity(c, http.StatusOK, ent)
+}
+
+func respondUpdateError(c *gin.Context, err error) {
+	if errors.Is(err, entity.ErrNotFound) {
+		respondError(c, http.StatusNotFound, err)
+		return
+	}
+	var conflict *entity.VersionConflictError
+	if errors.As(err, &conflict) {
+		c.Header("ETag", etag(conflict.Current.Version))
+		c.Error(err)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current_version": conflict.Current.Version, "entity": conflict.Current})
+		return
+	}
+	if validation.IsValidationError(err) {
+		c.Error(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": validation.GetValidationErrors(err)})
+		return
+	}
+	switch {
+	case errors.Is(err, entity.ErrInvalidPatch), errors.Is(err, entity.ErrIdentifierChange):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, entity.ErrRestrictedProperty):
+		respondError(c, http.StatusForbidden, err)
+	case errors.Is(err, entity.ErrPatchTestFailed), errors.Is(err, entity.ErrIdentifierImmutable), errors.Is(err, entity.ErrAlreadyExists),
+		errors.Is(err, entity.ErrPropertyManaged), errors.Is(err, entity.ErrRollupProperty):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
+
+// checkOnly reports whether a write asks to be checked without being
+// applied, with validate_only or dry_run
+func checkOnly(c *gin.Context) bool {
+	return c.Query("validate_only") == "true" || c.Query("dry_run") == "true"
+}
+
+// respondValidation writes the result of a write checked without being
+// applied, without the restricted properties the caller may not read
+func (h *EntityHandler) respondValidation(c *gin.Context, result *entity.ValidationResult) {
+	if result.Entity != nil {
+		redacted, err := h.entityService.Redact(c.Request.Context(), result.Entity)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		result.Entity = redacted[0]
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// respondEntity writes an entity without the restricted properties the
+// caller may not read
+func (h *EntityHandler) respondEntity(c *gin.Context, status int, ent *entity.Entity) {
+	redacted, err := h.entityService.Redact(c.Request.Context(), ent)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	c.JSON(status, redacted[0])
+}
+
+// respondRead writes an entity that was read, without the restricted
+// properties the caller may not read and with the related entities of
+// include
+func (h *EntityHandler) respondRead(c *gin.Context, ent *entity.Entity, include string) {
+	redacted, err := h.entityService.Redact(c.Request.Context(), ent)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
+	rendered, err := h.render(c.Request.Context(), ent.TeamID, ent.BlueprintID, redacted, "", include)
+	switch {
+	case err != nil:
+		respondRenderError(c, err)
+	case rendered != nil:
+		c.JSON(http.StatusOK, rendered[0])
+	default:
+		c.JSON(http.StatusOK, redacted[0])
+	}
+}
 
-	ent, err := h.entityService.Update(c.Request.Context(), id, &req)
+// respondList writes a page of entities without the restricted properties
+// the caller may not read, with only the fields of a sparse fieldset and
+// with the related entities of include. Lists may be cached and shared, so
+// a copy is redacted.
+func (h *EntityHandler) respondList(c *gin.Context, resp *entity.ListEntitiesResponse, teamID uuid.UUID, blueprintID, fields, include string) {
+	out := *resp
+	var err error
+	out.Entities, err = h.entityService.Redact(c.Request.Context(), resp.Entities...)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	rendered, err := h.render(c.Request.Context(), teamID, blueprintID, out.Entities, fields, include)
+	if err != nil {
+		respondRenderError(c, err)
+		return
+	}
+	if rendered == nil {
+		c.JSON(http.StatusOK, &out)
+		return
+	}
+	c.JSON(http.StatusOK, struct {
+		*entity.ListEntitiesResponse
+		Entities []interface{} `json:"entities"`
+	}{&out, rendered})
+}
+
+// includedEntity is an entity with its related entities and the sources of
+// its properties, each left out unless included
+type includedEntity struct {
+	*entity.Entity
+	Relations interface{} `json:"relations,omitempty"`
+	Sources   interface{} `json:"sources,omitempty"`
+}
+
+// inclusions returns the "relations" and "sources" objects of an entity
+// that include asked for
+func inclusions(inclusion *entity.Inclusion, id uuid.UUID) map[string]interface{} {
+	included := map[string]interface{}{}
+	if related := inclusion.Related(id); related != nil {
+		included["relations"] = related
+	}
+	if sources := inclusion.Sources(id); sources != nil {
+		included["sources"] = sources
+	}
+	return included
+}
+
+// render returns entities of a blueprint with only the fields of a sparse
+// fieldset and with the related entities and property sources of include,
+// or nil when neither is asked for. The service has already checked the
+// fieldset.
+func (h *EntityHandler) render(ctx context.Context, teamID uuid.UUID, blueprintID string, entities []*entity.Entity, fields, include string) ([]interface{}, error) {
+	fieldset, err := entity.ParseFields(fields)
+	if err != nil {
+		return nil, err
+	}
+	inclusion, err := h.entityService.Include(ctx, teamID, blueprintID, entities, include)
+	if err != nil {
+		return nil, err
+	}
+	if fieldset == nil && inclusion == nil {
+		return nil, nil
+	}
+
+	var projected []map[string]interface{}
+	if fieldset != nil {
+		if projected, err = fieldset.Project(entities); err != nil {
+			return nil, err
+		}
+	}
+	rendered := make([]interface{}, len(entities))
+	for i, e := range entities {
+		switch {
+		case projected == nil:
+			included := inclusions(inclusion, e.ID)
+			rendered[i] = includedEntity{e, included["relations"], included["sources"]}
+		case inclusion != nil:
+			for key, v := range inclusions(inclusion, e.ID) {
+				projected[i][key] = v
+			}
+			rendered[i] = projected[i]
+		default:
+			rendered[i] = projected[i]
+		}
+	}
+	return rendered, nil
+}
+
+// respondRenderError maps errors of render to responses
+func respondRenderError(c *gin.Context, err error) {
+	switch {
+	case respondTimeout(c, err):
+	case errors.Is(err, entity.ErrInvalidInclude), errors.Is(err, entity.ErrInvalidFilter):
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
+
+// writeContext returns the context of an entity write. Exporters syncing
+// through the entity API name their integration in X-Integration-ID, which
+// needs integration:write, so merge policies can tell their writes from
+// manual edits. It writes the error response and reports false on failure.
+func (h *EntityHandler) writeContext(c *gin.Context) (context.Context, bool) {
+	header := c.GetHeader(integrationHeader)
+	if header == "" {
+		return c.Request.Context(), true
+	}
+	integrationID, err := uuid.Parse(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + integrationHeader})
+		return nil, false
+	}
+	if !middleware.IsSuperAdmin(c) && !slices.Contains(middleware.GetPermissions(c), auth.PermIntegrationWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied: " + integrationHeader + " requires " + auth.PermIntegrationWrite})
+		return nil, false
+	}
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return nil, false
+	}
+
+	ctx, err := h.entityService.AsIntegration(c.Request.Context(), teamID, integrationID)
+	if err != nil {
+		if errors.Is(err, entity.ErrIntegrationNotFound) {
+			respondError(c, http.StatusNotFound, err)
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	return ctx, true
+}
+
+// Sources lists who last wrote each of an entity's data properties
+func (h *EntityHandler) Sources(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+		return
+	}
+
+	resp, err := h.entityService.Sources(c.Request.Context(), teamID, id)
 	if err != nil {
 		if errors.Is(err, entity.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		if validation.IsValidationError(err) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": validation.GetValidationErrors(err)})
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ReleaseSource forgets who last wrote a property, so the next write goes
+// through whatever the blueprint's merge policy
+func (h *EntityHandler) ReleaseSource(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+		return
+	}
+
+	if err := h.entityService.ReleaseSource(c.Request.Context(), teamID, id, c.Param("property")); err != nil {
+		if errors.Is(err, entity.ErrNotFound) {
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, ent)
+	c.Status(http.StatusNoContent)
+}
+
+// etag is the entity tag of an entity version
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ifMatchVersion reads an If-Match header holding one entity tag. It returns
+// 0, which matches any version, when the header is absent or "*".
+func ifMatchVersion(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) || len(header) < 2 {
+		return 0, errors.New(`If-Match must be a single entity tag, e.g. "3"`)
+	}
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, errors.New(`If-Match must be a single entity tag, e.g. "3"`)
+	}
+	return version, nil
 }
 
 func (h *EntityHandler) Delete(c *gin.Context) {
@@ -191,10 +1027,10 @@ func (h *EntityHandler) Delete(c *gin.Context) {
 
 	if err := h.entityService.Delete(c.Request.Context(), id); err != nil {
 		if errors.Is(err, entity.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
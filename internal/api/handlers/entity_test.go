@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/validation"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header  string
+		want    int64
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"*", 0, false},
+		{`"3"`, 3, false},
+		{` "12" `, 12, false},
+		{"3", 0, true},
+		{`W/"3"`, 0, true},
+		{`"3", "4"`, 0, true},
+		{`"0"`, 0, true},
+		{`"abc"`, 0, true},
+		{`"`, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := ifMatchVersion(tt.header)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ifMatchVersion(%q) = %d, %v; want %d, error %v", tt.header, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if got := etag(7); got != `"7"` {
+		t.Errorf("etag(7) = %s", got)
+	}
+}
+
+func TestRespondUpdateError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{entity.ErrNotFound, http.StatusNotFound},
+		{&entity.VersionConflictError{Current: &entity.Entity{Version: 4}}, http.StatusConflict},
+		{fmt.Errorf("%w: only /title and /data can be patched", entity.ErrInvalidPatch), http.StatusBadRequest},
+		{entity.ErrIdentifierChange, http.StatusBadRequest},
+		{entity.ErrIdentifierImmutable, http.StatusConflict},
+		{entity.ErrAlreadyExists, http.StatusConflict},
+		{fmt.Errorf("%w: owner", entity.ErrPropertyManaged), http.StatusConflict},
+		{fmt.Errorf("%w: health", entity.ErrRollupProperty), http.StatusConflict},
+		{fmt.Errorf("%w: cost", entity.ErrRestrictedProperty), http.StatusForbidden},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondUpdateError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondUpdateError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondCreateError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{entity.ErrAlreadyExists, http.StatusConflict},
+		{fmt.Errorf("%w: health", entity.ErrRollupProperty), http.StatusConflict},
+		{entity.ErrBlueprintNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: cost", entity.ErrRestrictedProperty), http.StatusForbidden},
+		{&validation.ValidationErrors{Errors: []validation.ValidationError{{Field: "owner", Message: "owner is required"}}}, http.StatusBadRequest},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondCreateError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondCreateError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondRenderError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: blueprint service has no relation \"x\"", entity.ErrInvalidInclude), http.StatusBadRequest},
+		{fmt.Errorf("%w: unknown field \"x\"", entity.ErrInvalidFilter), http.StatusBadRequest},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondRenderError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondRenderError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("search: %w", context.DeadlineExceeded), true},
+		{&pgconn.PgError{Code: "57014", Message: "canceling statement due to statement timeout"}, true},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{context.Canceled, false},
+		{errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		if got := respondTimeout(c, tt.err); got != tt.want {
+			t.Errorf("respondTimeout(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+		if tt.want && (w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), `"code":"QUERY_TIMEOUT"`)) {
+			t.Errorf("respondTimeout(%v) wrote %d %s", tt.err, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package handlers
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// respondError answers with status and err's message. err is recorded with
+// c.Error so the error middleware can give the response the code of err and
+// keep the message of unexpected server errors from the client.
+func respondError(c *gin.Context, status int, err error) {
+	c.Error(err)
+	c.JSON(status, gin.H{"error": err.Error()})
+}
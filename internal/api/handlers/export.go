@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/export"
+)
+
+// ExportHandler exports the entities of a blueprint: streamed in the
+// response when small, otherwise as a background export whose file is
+// downloaded once it is ready
+type ExportHandler struct {
+	exportService *export.Service
+}
+
+func NewExportHandler(exportService *export.Service) *ExportHandler {
+	return &ExportHandler{exportService: exportService}
+}
+
+// Export exports every entity of a blueprint as CSV or NDJSON (the default)
+func (h *ExportHandler) Export(c *gin.Context) {
+	req := entity.ExportRequest{Format: c.DefaultQuery("format", entity.FormatNDJSON)}
+	h.start(c, &req)
+}
+
+// ExportMatching exports the entities of a blueprint matching the filters of
+// the request body, in its order
+func (h *ExportHandler) ExportMatching(c *gin.Context) {
+	var req entity.ExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Format == "" {
+		req.Format = c.DefaultQuery("format", entity.FormatNDJSON)
+	}
+	h.start(c, &req)
+}
+
+// start streams an export, or answers 202 with the background export when it
+// is over the threshold or async=true was asked for
+func (h *ExportHandler) start(c *gin.Context, req *entity.ExportRequest) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+	blueprintID := c.Param("id")
+	async, _ := strconv.ParseBool(c.Query("async"))
+	var requestedBy *uuid.UUID
+	if userID, ok := middleware.GetUserID(c); ok {
+		requestedBy = &userID
+	}
+
+	prepared, e, err := h.exportService.Start(c.Request.Context(), teamID, blueprintID, req, async, requestedBy)
+	if err != nil {
+		if respondTimeout(c, err) {
+			return
+		}
+		respondExportError(c, err)
+		return
+	}
+	if e != nil {
+		c.Header("Location", fmt.Sprintf("/api/teams/%s/exports/%s", teamID, e.ID))
+		c.JSON(http.StatusAccepted, e)
+		return
+	}
+
+	contentType := "application/x-ndjson"
+	if req.Format == entity.FormatCSV {
+		contentType = "text/csv; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, blueprintID, req.Format))
+	c.Status(http.StatusOK)
+
+	if _, err := prepared.Stream(c.Request.Context(), c.Writer); err != nil {
+		// The status is already sent; the response simply ends early
+		log.Printf("ERROR: export of blueprint %s stopped: %v", blueprintID, err)
+	}
+}
+
+// List returns the team's background exports, newest first
+func (h *ExportHandler) List(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	req := export.ListRequest{BlueprintID: c.Query("blueprint"), Limit: 50}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			req.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			req.Offset = parsed
+		}
+	}
+
+	resp, err := h.exportService.List(c.Request.Context(), teamID, &req)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ExportHandler) Get(c *gin.Context) {
+	teamID, id, ok := exportID(c)
+	if !ok {
+		return
+	}
+
+	e, err := h.exportService.Get(c.Request.Context(), teamID, id)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, e)
+}
+
+// Download redirects to the file of a finished export, or serves it when the
+// storage has no download URLs
+func (h *ExportHandler) Download(c *gin.Context) {
+	teamID, id, ok := exportID(c)
+	if !ok {
+		return
+	}
+
+	download, err := h.exportService.Download(c.Request.Context(), teamID, id)
+	if err != nil {
+		respondExportError(c, err)
+		return
+	}
+	if download.URL != "" {
+		c.Header("Cache-Control", "no-store")
+		c.Redirect(http.StatusFound, download.URL)
+		return
+	}
+	defer download.Body.Close()
+
+	e := download.Export
+	c.Header("Content-Type", e.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, e.Filename()))
+	c.Header("Content-Length", strconv.FormatInt(e.SizeBytes, 10))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, download.Body); err != nil {
+		log.Printf("ERROR: download of export %s stopped: %v", e.ID, err)
+	}
+}
+
+func exportID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("exportId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return teamID, id, true
+}
+
+func respondExportError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, entity.ErrUnsupportedFormat), errors.Is(err, entity.ErrInvalidFilter):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, entity.ErrBlueprintNotFound), errors.Is(err, export.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, export.ErrNotReady), errors.Is(err, export.ErrFailed):
+		respondError(c, http.StatusConflict, err)
+	case errors.Is(err, export.ErrExpired):
+		respondError(c, http.StatusGone, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/export"
+)
+
+func TestRespondExportError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: %q", entity.ErrUnsupportedFormat, "xml"), http.StatusBadRequest},
+		{entity.ErrInvalidFilter, http.StatusBadRequest},
+		{entity.ErrBlueprintNotFound, http.StatusNotFound},
+		{export.ErrNotFound, http.StatusNotFound},
+		{export.ErrNotReady, http.StatusConflict},
+		{fmt.Errorf("%w: disk full", export.ErrFailed), http.StatusConflict},
+		{export.ErrExpired, http.StatusGone},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondExportError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondExportError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+// GrafanaHandler implements the JSON datasource protocol used by Grafana's
+// JSON API plugin (and the older SimpleJSON plugin). Each query target names a
+// blueprint; its payload selects the aggregation.
+type GrafanaHandler struct {
+	blueprintService *blueprint.Service
+	entityService    *entity.Service
+}
+
+func NewGrafanaHandler(blueprintService *blueprint.Service, entityService *entity.Service) *GrafanaHandler {
+	return &GrafanaHandler{
+		blueprintService: blueprintService,
+		entityService:    entityService,
+	}
+}
+
+type grafanaQueryRequest struct {
+	Range struct {
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	} `json:"range"`
+	Targets []grafanaTarget `json:"targets"`
+}
+
+type grafanaTarget struct {
+	RefID  string `json:"refId"`
+	Target string `json:"target"` // blueprint ID
+	Type   string `json:"type"`   // "timeseries" (default) or "table"
+	Hide   bool   `json:"hide"`
+	// Payload is sent by the JSON API plugin, Data by SimpleJSON
+	Payload *entity.AggregateRequest `json:"payload"`
+	Data    *entity.AggregateRequest `json:"data"`
+}
+
+func (t *grafanaTarget) aggregate() *entity.AggregateRequest {
+	switch {
+	case t.Payload != nil:
+		return t.Payload
+	case t.Data != nil:
+		return t.Data
+	}
+	return &entity.AggregateRequest{Function: entity.AggregateCount}
+}
+
+type grafanaTimeseries struct {
+	Target     string       `json:"target"`
+	RefID      string       `json:"refId,omitempty"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+type grafanaColumn struct {
+	Text string `json:"text"`
+	Type string `json:"type"`
+}
+
+type grafanaTable struct {
+	Type    string          `json:"type"`
+	RefID   string          `json:"refId,omitempty"`
+	Columns []grafanaColumn `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// Health answers the datasource "Save & test" probe
+func (h *GrafanaHandler) Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Search lists blueprints as query targets (SimpleJSON /search)
+func (h *GrafanaHandler) Search(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.blueprintService.List(c.Request.Context(), teamID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	targets := make([]gin.H, 0, len(resp.Blueprints))
+	for _, bp := range resp.Blueprints {
+		targets = append(targets, gin.H{"text": bp.Title, "value": bp.ID})
+	}
+	c.JSON(http.StatusOK, targets)
+}
+
+// Metrics lists blueprints together with the payload editor definition (JSON API plugin /metrics)
+func (h *GrafanaHandler) Metrics(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.blueprintService.List(c.Request.Context(), teamID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	functions := []gin.H{}
+	for _, fn := range []string{entity.AggregateCount, entity.AggregateSum, entity.AggregateAvg, entity.AggregateMin, entity.AggregateMax} {
+		functions = append(functions, gin.H{"label": fn, "value": fn})
+	}
+	payloads := []gin.H{
+		{"label": "Function", "name": "function", "type": "select", "options": functions},
+		{"label": "Property", "name": "property", "type": "input", "placeholder": "numeric property, e.g. metrics.coverage"},
+		{"label": "Group by", "name": "group_by", "type": "input", "placeholder": "e.g. lifecycle"},
+	}
+
+	result := make([]gin.H, 0, len(resp.Blueprints))
+	for _, bp := range resp.Blueprints {
+		result = append(result, gin.H{"label": bp.Title, "value": bp.ID, "payloads": payloads})
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Query evaluates each target. Catalog data is a snapshot, so time series
+// contain a single datapoint stamped at the end of the requested range.
+func (h *GrafanaHandler) Query(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req grafanaQueryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	at := req.Range.To
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	results := []interface{}{}
+	for i := range req.Targets {
+		target := &req.Targets[i]
+		if target.Hide || target.Target == "" {
+			continue
+		}
+
+		agg := target.aggregate()
+		buckets, err := h.entityService.Aggregate(c.Request.Context(), teamID, target.Target, agg)
+		if err != nil {
+			if respondThrottled(c, err) || respondTimeout(c, err) {
+				return
+			}
+			if errors.Is(err, entity.ErrInvalidAggregate) || errors.Is(err, entity.ErrInvalidFilter) {
+				respondError(c, http.StatusBadRequest, err)
+				return
+			}
+			if errors.Is(err, entity.ErrBlueprintNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "blueprint not found: " + target.Target})
+				return
+			}
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+
+		if target.Type == "table" {
+			results = append(results, grafanaTableResult(target, agg, buckets))
+		} else {
+			results = append(results, grafanaSeriesResults(target, buckets, at)...)
+		}
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+func grafanaSeriesResults(target *grafanaTarget, buckets []entity.AggregateBucket, at time.Time) []interface{} {
+	ts := float64(at.UnixMilli())
+	series := make([]interface{}, 0, len(buckets))
+	for _, b := range buckets {
+		name := target.Target
+		if b.Key != "" {
+			name = b.Key
+		}
+		series = append(series, grafanaTimeseries{
+			Target:     name,
+			RefID:      target.RefID,
+			Datapoints: [][2]float64{{b.Value, ts}},
+		})
+	}
+	return series
+}
+
+func grafanaTableResult(target *grafanaTarget, agg *entity.AggregateRequest, buckets []entity.AggregateBucket) grafanaTable {
+	keyColumn := "blueprint"
+	if agg.GroupBy != "" {
+		keyColumn = agg.GroupBy
+	}
+	valueColumn := agg.Function
+	if agg.Property != "" && agg.Function != entity.AggregateCount {
+		valueColumn = agg.Function + "(" + agg.Property + ")"
+	}
+
+	rows := make([][]interface{}, 0, len(buckets))
+	for _, b := range buckets {
+		key := b.Key
+		if agg.GroupBy == "" {
+			key = target.Target
+		}
+		rows = append(rows, []interface{}{key, b.Value})
+	}
+
+	return grafanaTable{
+		Type:  "table",
+		RefID: target.RefID,
+		Columns: []grafanaColumn{
+			{Text: keyColumn, Type: "string"},
+			{Text: valueColumn, Type: "number"},
+		},
+		Rows: rows,
+	}
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+func TestGrafanaSeriesResults(t *testing.T) {
+	at := time.UnixMilli(1705363200000)
+	target := &grafanaTarget{RefID: "A", Target: "service"}
+
+	series := grafanaSeriesResults(target, []entity.AggregateBucket{{Key: "", Value: 3}}, at)
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+	s := series[0].(grafanaTimeseries)
+	if s.Target != "service" || s.Datapoints[0] != [2]float64{3, 1705363200000} {
+		t.Errorf("unexpected ungrouped series: %+v", s)
+	}
+
+	series = grafanaSeriesResults(target, []entity.AggregateBucket{{Key: "production", Value: 2}, {Key: "beta", Value: 1}}, at)
+	if len(series) != 2 || series[1].(grafanaTimeseries).Target != "beta" {
+		t.Errorf("expected one series per group, got %+v", series)
+	}
+}
+
+func TestGrafanaTableResult(t *testing.T) {
+	target := &grafanaTarget{RefID: "B", Target: "service", Type: "table"}
+	agg := &entity.AggregateRequest{Function: entity.AggregateAvg, Property: "coverage", GroupBy: "language"}
+
+	table := grafanaTableResult(target, agg, []entity.AggregateBucket{{Key: "Go", Value: 80}})
+	if table.Columns[0].Text != "language" || table.Columns[1].Text != "avg(coverage)" {
+		t.Errorf("unexpected columns: %+v", table.Columns)
+	}
+	if len(table.Rows) != 1 || table.Rows[0][0] != "Go" || table.Rows[0][1] != 80.0 {
+		t.Errorf("unexpected rows: %+v", table.Rows)
+	}
+
+	table = grafanaTableResult(target, &entity.AggregateRequest{Function: entity.AggregateCount}, []entity.AggregateBucket{{Value: 5}})
+	if table.Columns[1].Text != "count" || table.Rows[0][0] != "service" {
+		t.Errorf("ungrouped table should be keyed by blueprint: %+v", table)
+	}
+}
+
+func TestGrafanaTargetDefaultsToCount(t *testing.T) {
+	target := &grafanaTarget{Target: "service"}
+	if fn := target.aggregate().Function; fn != entity.AggregateCount {
+		t.Errorf("expected count, got %q", fn)
+	}
+	target.Data = &entity.AggregateRequest{Function: entity.AggregateSum, Property: "cost"}
+	if fn := target.aggregate().Function; fn != entity.AggregateSum {
+		t.Errorf("expected SimpleJSON data to be used, got %q", fn)
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/advisor"
+)
+
+// IndexAdvisorHandler serves the index recommendations derived from search
+// telemetry (super admin only)
+type IndexAdvisorHandler struct {
+	advisor *advisor.Service
+}
+
+func NewIndexAdvisorHandler(advisor *advisor.Service) *IndexAdvisorHandler {
+	return &IndexAdvisorHandler{advisor: advisor}
+}
+
+// Recommendations lists the properties worth indexing, of all teams or of
+// the team_id query parameter
+func (h *IndexAdvisorHandler) Recommendations(c *gin.Context) {
+	var teamID *uuid.UUID
+	if t := c.Query("team_id"); t != "" {
+		parsed, err := uuid.Parse(t)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team_id"})
+			return
+		}
+		teamID = &parsed
+	}
+
+	resp, err := h.advisor.Recommendations(c.Request.Context(), teamID)
+	if err != nil {
+		if respondTimeout(c, err) {
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Apply marks a property indexed; its index is built in the background
+func (h *IndexAdvisorHandler) Apply(c *gin.Context) {
+	var req advisor.ApplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.advisor.Apply(c.Request.Context(), &req)
+	if err != nil {
+		respondIndexAdvisorError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func respondIndexAdvisorError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, advisor.ErrBlueprintNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, advisor.ErrNotIndexable):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, advisor.ErrAlreadyIndexed), errors.Is(err, advisor.ErrIndexLimit):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
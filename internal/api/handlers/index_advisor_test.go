@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/advisor"
+)
+
+func TestRespondIndexAdvisorError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{advisor.ErrBlueprintNotFound, http.StatusNotFound},
+		{advisor.ErrNotIndexable, http.StatusBadRequest},
+		{advisor.ErrAlreadyIndexed, http.StatusConflict},
+		{advisor.ErrIndexLimit, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondIndexAdvisorError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondIndexAdvisorError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/integration"
+	"github.com/baseplate/baseplate/internal/core/secret"
+)
+
+type IntegrationHandler struct {
+	integrationService *integration.Service
+}
+
+func NewIntegrationHandler(integrationService *integration.Service) *IntegrationHandler {
+	return &IntegrationHandler{integrationService: integrationService}
+}
+
+func (h *IntegrationHandler) List(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.integrationService.List(c.Request.Context(), teamID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *IntegrationHandler) Create(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req integration.CreateIntegrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	in, err := h.integrationService.Create(c.Request.Context(), teamID, &req)
+	if err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, in)
+}
+
+func (h *IntegrationHandler) Get(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid integration id"})
+		return
+	}
+
+	in, err := h.integrationService.Get(c.Request.Context(), teamID, id)
+	if err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, in)
+}
+
+// Config returns the integration's configuration with secret references
+// replaced by their values, for the exporter that runs it
+func (h *IntegrationHandler) Config(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid integration id"})
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	config, err := h.integrationService.ResolvedConfig(c.Request.Context(), teamID, id, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	// Resolved values must not be kept by proxies or browsers
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"config": config})
+}
+
+func (h *IntegrationHandler) Delete(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid integration id"})
+		return
+	}
+
+	if err := h.integrationService.Delete(c.Request.Context(), teamID, id); err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Reconcile takes an exporter's full set of identifiers for a blueprint and
+// returns, or deletes, the integration's entities that are gone upstream
+func (h *IntegrationHandler) Reconcile(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid integration id"})
+		return
+	}
+
+	var req entity.ReconcileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// Deleting entities needs the same permission as deleting them directly
+	if req.Delete && !middleware.IsSuperAdmin(c) && !slices.Contains(middleware.GetPermissions(c), auth.PermEntityDelete) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "permission denied: delete requires " + auth.PermEntityDelete})
+		return
+	}
+
+	resp, err := h.integrationService.Reconcile(c.Request.Context(), teamID, id, &req)
+	if err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// maxWebhookBytes is the size GitHub caps webhook payloads at
+const maxWebhookBytes = 25 << 20
+
+// maxIngestBytes bounds the payloads pushed to ingest integrations
+const maxIngestBytes = 25 << 20
+
+// Sync queues a full sync of an integration Baseplate syncs itself
+func (h *IntegrationHandler) Sync(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid integration id"})
+		return
+	}
+
+	job, err := h.integrationService.Sync(c.Request.Context(), teamID, id)
+	if err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// GitHubWebhook receives the webhooks of a GitHub integration. It needs no
+// authentication: deliveries are checked against the webhook secret.
+func (h *IntegrationHandler) GitHubWebhook(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("integrationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid integration id"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("webhooks are limited to %d bytes", tooLarge.Limit)})
+			return
+		}
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	queued, err := h.integrationService.GitHubWebhook(c.Request.Context(), id,
+		c.GetHeader("X-GitHub-Event"), c.GetHeader("X-Hub-Signature-256"), body)
+	if err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"queued": queued})
+}
+
+// Ingest receives JSON pushed to an ingest integration and upserts the
+// entities its mapping makes of it. It needs no user authentication: the
+// bearer token must be the integration's.
+func (h *IntegrationHandler) Ingest(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("integrationId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid integration id"})
+		return
+	}
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing ingest token"})
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIngestBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("ingested payloads are limited to %d bytes", tooLarge.Limit)})
+			return
+		}
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := h.integrationService.Ingest(c.Request.Context(), id, token, body, c.Query("dry_run") == "true")
+	if err != nil {
+		respondIntegrationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func respondIntegrationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, entity.ErrInvalidReconcile), errors.Is(err, secret.ErrUnknownSecret),
+		errors.Is(err, integration.ErrInvalidConfig), errors.Is(err, integration.ErrNotGitHub), errors.Is(err, integration.ErrNotSynced),
+		errors.Is(err, integration.ErrInvalidWebhook), errors.Is(err, integration.ErrNotIngest):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, integration.ErrInvalidSignature), errors.Is(err, integration.ErrInvalidToken):
+		respondError(c, http.StatusUnauthorized, err)
+	case errors.Is(err, secret.ErrUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+	case errors.Is(err, integration.ErrNotFound), errors.Is(err, entity.ErrBlueprintNotFound):
+		respondError(c, http.StatusNotFound, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+// JobHandler lets super admins inspect the background job queue and retry
+// or discard dead jobs
+type JobHandler struct {
+	queue *jobs.Queue
+}
+
+func NewJobHandler(queue *jobs.Queue) *JobHandler {
+	return &JobHandler{queue: queue}
+}
+
+// List returns jobs, newest first, filtered by status and kind
+func (h *JobHandler) List(c *gin.Context) {
+	req := jobs.ListRequest{Status: c.Query("status"), Kind: c.Query("kind"), Limit: 50}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			req.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			req.Offset = parsed
+		}
+	}
+
+	resp, err := h.queue.List(c.Request.Context(), &req)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *JobHandler) Get(c *gin.Context) {
+	id, ok := jobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.queue.Get(c.Request.Context(), id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Retry queues a dead job again with fresh attempts
+func (h *JobHandler) Retry(c *gin.Context) {
+	id, ok := jobID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.queue.Retry(c.Request.Context(), id)
+	if err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// Discard deletes a dead job instead of retrying it
+func (h *JobHandler) Discard(c *gin.Context) {
+	id, ok := jobID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.queue.Discard(c.Request.Context(), id); err != nil {
+		respondJobError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func jobID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("jobId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid job id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondJobError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, jobs.ErrInvalidStatus):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, jobs.ErrNotDead):
+		respondError(c, http.StatusConflict, err)
+	default:
+		log.Printf("ERROR: jobs: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
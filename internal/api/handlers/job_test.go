@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+func TestRespondJobError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{jobs.ErrNotFound, http.StatusNotFound},
+		{jobs.ErrInvalidStatus, http.StatusBadRequest},
+		{jobs.ErrNotDead, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondJobError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondJobError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
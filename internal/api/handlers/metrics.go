@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/metrics"
+)
+
+type MetricsHandler struct {
+	registry *metrics.Registry
+	http     *metrics.HTTPMetrics
+	token    string
+}
+
+// NewMetricsHandler creates the Prometheus scrape handler. When token is non-empty,
+// scrapers must send "Authorization: Bearer <token>". The catalog collector
+// labels series with every team's ID, so it is registered only with a token;
+// without one, /metrics serves the server metrics alone.
+func NewMetricsHandler(registry *metrics.Registry, token string, catalog metrics.Collector) *MetricsHandler {
+	if token != "" && catalog != nil {
+		registry.Register(catalog)
+	}
+	return &MetricsHandler{
+		registry: registry,
+		http:     metrics.NewHTTPMetrics(registry),
+		token:    token,
+	}
+}
+
+// HTTPMetrics returns the request instruments recorded by the metrics middleware
+func (h *MetricsHandler) HTTPMetrics() *metrics.HTTPMetrics {
+	return h.http
+}
+
+// Scrape renders all metrics in the Prometheus text exposition format
+func (h *MetricsHandler) Scrape(c *gin.Context) {
+	if h.token != "" {
+		expected := "Bearer " + h.token
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte(expected)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := metrics.WriteText(c.Writer, h.registry.Gather(c.Request.Context())); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/notification"
+	"github.com/baseplate/baseplate/internal/core/secret"
+)
+
+type NotificationHandler struct {
+	notificationService *notification.Service
+}
+
+func NewNotificationHandler(notificationService *notification.Service) *NotificationHandler {
+	return &NotificationHandler{notificationService: notificationService}
+}
+
+// subscriber describes the caller; team managers manage team-wide
+// subscriptions
+func subscriber(c *gin.Context) notification.Subscriber {
+	return notification.Subscriber{
+		UserID:  optionalUserID(c),
+		Manager: slices.Contains(middleware.GetPermissions(c), auth.PermTeamManage),
+	}
+}
+
+// ListSubscriptions returns the team-wide subscriptions and the caller's own
+func (h *NotificationHandler) ListSubscriptions(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.notificationService.ListSubscriptions(c.Request.Context(), teamID, subscriber(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *NotificationHandler) CreateSubscription(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req notification.CreateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	sub, err := h.notificationService.CreateSubscription(c.Request.Context(), teamID, subscriber(c), &req)
+	if err != nil {
+		respondNotificationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, sub)
+}
+
+func (h *NotificationHandler) DeleteSubscription(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("subscriptionId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid subscription id"})
+		return
+	}
+
+	if err := h.notificationService.DeleteSubscription(c.Request.Context(), teamID, id, subscriber(c)); err != nil {
+		respondNotificationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListDeliveries returns the team's delivery log, newest first, optionally
+// of one ?status=
+func (h *NotificationHandler) ListDeliveries(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	req := &notification.ListDeliveriesRequest{Status: c.Query("status")}
+	switch req.Status {
+	case "", notification.StatusSent, notification.StatusFailed, notification.StatusSkipped:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be sent, failed or skipped"})
+		return
+	}
+	req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	req.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	resp, err := h.notificationService.ListDeliveries(c.Request.Context(), teamID, req)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondNotificationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, notification.ErrInvalidSubscription), errors.Is(err, notification.ErrUserRequired),
+		errors.Is(err, secret.ErrUnknownSecret):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, notification.ErrForbidden):
+		respondError(c, http.StatusForbidden, err)
+	case errors.Is(err, notification.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, secret.ErrUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/notification"
+	"github.com/baseplate/baseplate/internal/core/secret"
+)
+
+func TestRespondNotificationError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: unknown event", notification.ErrInvalidSubscription), http.StatusBadRequest},
+		{notification.ErrUserRequired, http.StatusBadRequest},
+		{fmt.Errorf("%w: %q", secret.ErrUnknownSecret, "slack-hook"), http.StatusBadRequest},
+		{notification.ErrForbidden, http.StatusForbidden},
+		{notification.ErrNotFound, http.StatusNotFound},
+		{secret.ErrUnavailable, http.StatusServiceUnavailable},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondNotificationError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondNotificationError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/outbox"
+)
+
+// OutboxHandler lets super admins inspect the event outbox, replay its events
+// to a consumer that missed them, and retry or discard dead events
+type OutboxHandler struct {
+	dispatcher *outbox.Dispatcher
+}
+
+func NewOutboxHandler(dispatcher *outbox.Dispatcher) *OutboxHandler {
+	return &OutboxHandler{dispatcher: dispatcher}
+}
+
+// Get returns the consumers, the events by status and the oldest event
+func (h *OutboxHandler) Get(c *gin.Context) {
+	overview, err := h.dispatcher.Overview(c.Request.Context())
+	if err != nil {
+		respondOutboxError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, overview)
+}
+
+// Replay queues a background job delivering a time range of events to a consumer
+func (h *OutboxHandler) Replay(c *gin.Context) {
+	var req outbox.ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	job, err := h.dispatcher.Replay(c.Request.Context(), &req)
+	if err != nil {
+		respondOutboxError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListEvents returns outbox events, newest first, filtered by status, type and team
+func (h *OutboxHandler) ListEvents(c *gin.Context) {
+	req := outbox.ListRequest{Status: c.Query("status"), Type: c.Query("type"), Limit: 50}
+	if t := c.Query("team_id"); t != "" {
+		teamID, err := uuid.Parse(t)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team id"})
+			return
+		}
+		req.TeamID = &teamID
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			req.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			req.Offset = parsed
+		}
+	}
+
+	resp, err := h.dispatcher.ListEvents(c.Request.Context(), &req)
+	if err != nil {
+		respondOutboxError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *OutboxHandler) GetEvent(c *gin.Context) {
+	id, ok := eventID(c)
+	if !ok {
+		return
+	}
+
+	rec, err := h.dispatcher.GetEvent(c.Request.Context(), id)
+	if err != nil {
+		respondOutboxError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rec)
+}
+
+// RetryEvent delivers a dead event again to the consumers that failed it
+func (h *OutboxHandler) RetryEvent(c *gin.Context) {
+	id, ok := eventID(c)
+	if !ok {
+		return
+	}
+
+	rec, err := h.dispatcher.Retry(c.Request.Context(), id)
+	if err != nil {
+		respondOutboxError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, rec)
+}
+
+// DiscardEvent deletes a dead event instead of retrying it
+func (h *OutboxHandler) DiscardEvent(c *gin.Context) {
+	id, ok := eventID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.dispatcher.Discard(c.Request.Context(), id); err != nil {
+		respondOutboxError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func eventID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("eventId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid event id"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func respondOutboxError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, outbox.ErrUnknownConsumer), errors.Is(err, outbox.ErrInvalidRange),
+		errors.Is(err, outbox.ErrInvalidStatus):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, outbox.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, outbox.ErrNotDead):
+		respondError(c, http.StatusConflict, err)
+	default:
+		log.Printf("ERROR: outbox: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/outbox"
+)
+
+func TestRespondOutboxError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: webhooks", outbox.ErrUnknownConsumer), http.StatusBadRequest},
+		{outbox.ErrInvalidRange, http.StatusBadRequest},
+		{outbox.ErrInvalidStatus, http.StatusBadRequest},
+		{outbox.ErrNotFound, http.StatusNotFound},
+		{outbox.ErrNotDead, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondOutboxError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondOutboxError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
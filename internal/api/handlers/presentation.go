@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/presentation"
+)
+
+// PresentationHandler serves the presentation hints of blueprints
+type PresentationHandler struct {
+	presentationService *presentation.Service
+}
+
+func NewPresentationHandler(presentationService *presentation.Service) *PresentationHandler {
+	return &PresentationHandler{presentationService: presentationService}
+}
+
+// Get returns a blueprint's presentation, or the defaults
+func (h *PresentationHandler) Get(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	p, err := h.presentationService.Get(c.Request.Context(), teamID, c.Param("id"))
+	if err != nil {
+		respondPresentationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// Put replaces a blueprint's presentation
+func (h *PresentationHandler) Put(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req presentation.PutPresentationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var userID *uuid.UUID
+	if id, ok := middleware.GetUserID(c); ok {
+		userID = &id
+	}
+	p, err := h.presentationService.Put(c.Request.Context(), teamID, c.Param("id"), userID, &req)
+	if err != nil {
+		respondPresentationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// Delete resets a blueprint's presentation to the defaults
+func (h *PresentationHandler) Delete(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	if err := h.presentationService.Delete(c.Request.Context(), teamID, c.Param("id")); err != nil {
+		respondPresentationError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondPresentationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, presentation.ErrInvalidPresentation):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, presentation.ErrNotFound), errors.Is(err, presentation.ErrBlueprintNotFound):
+		respondError(c, http.StatusNotFound, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/presentation"
+)
+
+func TestRespondPresentationError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: columns: unknown property", presentation.ErrInvalidPresentation), http.StatusBadRequest},
+		{presentation.ErrNotFound, http.StatusNotFound},
+		{presentation.ErrBlueprintNotFound, http.StatusNotFound},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondPresentationError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondPresentationError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,548 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/runner"
+)
+
+type RunnerHandler struct {
+	runnerService *runner.Service
+}
+
+func NewRunnerHandler(runnerService *runner.Service) *RunnerHandler {
+	return &RunnerHandler{runnerService: runnerService}
+}
+
+// Service returns the runner service, which authenticates runner agents
+func (h *RunnerHandler) Service() *runner.Service {
+	return h.runnerService
+}
+
+func (h *RunnerHandler) ListRunners(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.runnerService.ListRunners(c.Request.Context(), teamID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateRunner registers a runner; its token is only returned here
+func (h *RunnerHandler) CreateRunner(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req runner.CreateRunnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	resp, err := h.runnerService.CreateRunner(c.Request.Context(), teamID, &req, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// GetRunner returns a runner with its health and active runs
+func (h *RunnerHandler) GetRunner(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("runnerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid runner id"})
+		return
+	}
+
+	r, err := h.runnerService.GetRunner(c.Request.Context(), teamID, id)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
+
+// UpdateRunner renames a runner or replaces its labels
+func (h *RunnerHandler) UpdateRunner(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("runnerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid runner id"})
+		return
+	}
+
+	var req runner.UpdateRunnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	r, err := h.runnerService.UpdateRunner(c.Request.Context(), teamID, id, &req, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, r)
+}
+
+func (h *RunnerHandler) DeleteRunner(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("runnerId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid runner id"})
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	if err := h.runnerService.DeleteRunner(c.Request.Context(), teamID, id, optionalUserID(c), ipAddress, userAgent); err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// TriggerRun queues a run of an action for the team's runners
+func (h *RunnerHandler) TriggerRun(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req runner.TriggerRunRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	run, err := h.runnerService.Trigger(c.Request.Context(), teamID, c.Param("identifier"), &req, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, run)
+}
+
+func (h *RunnerHandler) ListRuns(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	req := runner.ListRunsRequest{Status: c.Query("status"), Limit: 50}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			req.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			req.Offset = parsed
+		}
+	}
+
+	resp, err := h.runnerService.ListRuns(c.Request.Context(), teamID, &req)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *RunnerHandler) GetRun(c *gin.Context) {
+	teamID, runID, ok := teamAndRunID(c)
+	if !ok {
+		return
+	}
+
+	run, err := h.runnerService.GetRun(c.Request.Context(), teamID, runID)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// RunLogs pages through a run's log lines; pass the previous page's next
+// as after to continue
+func (h *RunnerHandler) RunLogs(c *gin.Context) {
+	teamID, runID, ok := teamAndRunID(c)
+	if !ok {
+		return
+	}
+
+	var after int64
+	if a := c.Query("after"); a != "" {
+		parsed, err := strconv.ParseInt(a, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "after must be a non-negative integer"})
+			return
+		}
+		after = parsed
+	}
+	limit := 1000
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 5000 {
+			limit = parsed
+		}
+	}
+
+	resp, err := h.runnerService.Logs(c.Request.Context(), teamID, runID, after, limit)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *RunnerHandler) CancelRun(c *gin.Context) {
+	teamID, runID, ok := teamAndRunID(c)
+	if !ok {
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	run, err := h.runnerService.Cancel(c.Request.Context(), teamID, runID, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// Fleet lists runners across all teams with their health (super admin only)
+func (h *RunnerHandler) Fleet(c *gin.Context) {
+	req := runner.FleetRequest{Health: c.Query("health"), Limit: 50}
+	if t := c.Query("team_id"); t != "" {
+		teamID, err := uuid.Parse(t)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team_id"})
+			return
+		}
+		req.TeamID = &teamID
+	}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			req.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			req.Offset = parsed
+		}
+	}
+
+	resp, err := h.runnerService.Fleet(c.Request.Context(), &req)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Heartbeat records the calling runner's version and host and keeps the
+// leases of its runs
+func (h *RunnerHandler) Heartbeat(c *gin.Context) {
+	r, ok := middleware.GetRunner(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing runner token"})
+		return
+	}
+
+	var req runner.HeartbeatRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	resp, err := h.runnerService.Heartbeat(c.Request.Context(), r, &req)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// Claim hands the calling runner its next run, or 204 when there is none
+func (h *RunnerHandler) Claim(c *gin.Context) {
+	r, ok := middleware.GetRunner(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing runner token"})
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	job, err := h.runnerService.Claim(c.Request.Context(), r, ipAddress, userAgent)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+	if job == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	// Jobs may carry resolved secrets
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, job)
+}
+
+// Report records the calling runner's progress on a run it claimed
+func (h *RunnerHandler) Report(c *gin.Context) {
+	r, ok := middleware.GetRunner(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "missing runner token"})
+		return
+	}
+
+	runID, err := uuid.Parse(c.Param("runId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return
+	}
+
+	var req runner.ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	run, err := h.runnerService.Report(c.Request.Context(), r, runID, &req)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// CreateSchedule schedules runs of an action, once or by cron
+func (h *RunnerHandler) CreateSchedule(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req runner.CreateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	schedule, err := h.runnerService.CreateSchedule(c.Request.Context(), teamID, c.Param("identifier"), &req, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// ListSchedules lists the team's schedules, only those of one action with
+// ?action=
+func (h *RunnerHandler) ListSchedules(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.runnerService.ListSchedules(c.Request.Context(), teamID, c.Query("action"))
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *RunnerHandler) GetSchedule(c *gin.Context) {
+	teamID, id, ok := teamAndScheduleID(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.runnerService.GetSchedule(c.Request.Context(), teamID, id)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *RunnerHandler) UpdateSchedule(c *gin.Context) {
+	teamID, id, ok := teamAndScheduleID(c)
+	if !ok {
+		return
+	}
+
+	var req runner.UpdateScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	schedule, err := h.runnerService.UpdateSchedule(c.Request.Context(), teamID, id, &req, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+func (h *RunnerHandler) DeleteSchedule(c *gin.Context) {
+	teamID, id, ok := teamAndScheduleID(c)
+	if !ok {
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	if err := h.runnerService.DeleteSchedule(c.Request.Context(), teamID, id, optionalUserID(c), ipAddress, userAgent); err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ScheduleRuns lists the runs a schedule created, newest first
+func (h *RunnerHandler) ScheduleRuns(c *gin.Context) {
+	teamID, id, ok := teamAndScheduleID(c)
+	if !ok {
+		return
+	}
+
+	req := runner.ListRunsRequest{Status: c.Query("status"), Limit: 50}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			req.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			req.Offset = parsed
+		}
+	}
+
+	resp, err := h.runnerService.ScheduleRuns(c.Request.Context(), teamID, id, &req)
+	if err != nil {
+		respondRunnerError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func teamAndScheduleID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("scheduleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid schedule id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return teamID, id, true
+}
+
+func teamAndRunID(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	runID, err := uuid.Parse(c.Param("runId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid run id"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return teamID, runID, true
+}
+
+func respondRunnerError(c *gin.Context, err error) {
+	var limited *runner.RunLimitError
+	switch {
+	case errors.As(err, &limited):
+		body := gin.H{"error": err.Error(), "limit": limited.Limit, "max": limited.Max, "runs": limited.RunIDs()}
+		if limited.RetryAfter > 0 {
+			retryAfter := int(math.Ceil(limited.RetryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			body["retry_after_seconds"] = retryAfter
+		}
+		c.JSON(http.StatusTooManyRequests, body)
+	case errors.Is(err, runner.ErrRunnerNotFound), errors.Is(err, runner.ErrActionNotFound), errors.Is(err, runner.ErrRunNotFound),
+		errors.Is(err, runner.ErrScheduleNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, runner.ErrUnauthorized):
+		respondError(c, http.StatusUnauthorized, err)
+	case errors.Is(err, runner.ErrInvalidName), errors.Is(err, runner.ErrInvalidHealth),
+		errors.Is(err, runner.ErrInvalidLabel), errors.Is(err, runner.ErrEntityRequired), errors.Is(err, runner.ErrInvalidEntity),
+		errors.Is(err, runner.ErrInvalidStatus), errors.Is(err, runner.ErrTooManyLines), errors.Is(err, runner.ErrInvalidSchedule):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, runner.ErrRunnerExists), errors.Is(err, runner.ErrScheduleExists), errors.Is(err, runner.ErrRunFinished),
+		errors.Is(err, runner.ErrRunCancelled), errors.Is(err, runner.ErrLeaseExpired), errors.Is(err, runner.ErrInvalidRunLimits):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
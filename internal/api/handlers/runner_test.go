@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/runner"
+)
+
+func TestRespondRunnerError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{runner.ErrRunnerNotFound, http.StatusNotFound},
+		{runner.ErrActionNotFound, http.StatusNotFound},
+		{runner.ErrRunNotFound, http.StatusNotFound},
+		{runner.ErrScheduleNotFound, http.StatusNotFound},
+		{runner.ErrUnauthorized, http.StatusUnauthorized},
+		{runner.ErrInvalidName, http.StatusBadRequest},
+		{runner.ErrInvalidHealth, http.StatusBadRequest},
+		{fmt.Errorf("%w: %q", runner.ErrInvalidLabel, "a b"), http.StatusBadRequest},
+		{runner.ErrEntityRequired, http.StatusBadRequest},
+		{runner.ErrInvalidEntity, http.StatusBadRequest},
+		{runner.ErrInvalidStatus, http.StatusBadRequest},
+		{fmt.Errorf("%w (max 500)", runner.ErrTooManyLines), http.StatusBadRequest},
+		{fmt.Errorf("%w: set either cron or run_at", runner.ErrInvalidSchedule), http.StatusBadRequest},
+		{runner.ErrRunnerExists, http.StatusConflict},
+		{runner.ErrScheduleExists, http.StatusConflict},
+		{runner.ErrRunFinished, http.StatusConflict},
+		{runner.ErrRunCancelled, http.StatusConflict},
+		{runner.ErrLeaseExpired, http.StatusConflict},
+		{fmt.Errorf("%w: cooldown_seconds must be a non-negative whole number", runner.ErrInvalidRunLimits), http.StatusConflict},
+		{&runner.RunLimitError{Limit: runner.LimitConcurrent, Max: 1, Runs: []uuid.UUID{uuid.New()}}, http.StatusTooManyRequests},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondRunnerError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondRunnerError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondRunnerError_Cooldown(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	run := uuid.New()
+	respondRunnerError(c, &runner.RunLimitError{Limit: runner.LimitCooldown, Max: 300, Runs: []uuid.UUID{run}, RetryAfter: 41500 * time.Millisecond})
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "42" {
+		t.Errorf("Retry-After = %q, want 42", got)
+	}
+	var body struct {
+		Limit      string   `json:"limit"`
+		Runs       []string `json:"runs"`
+		RetryAfter int      `json:"retry_after_seconds"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Limit != runner.LimitCooldown || len(body.Runs) != 1 || body.Runs[0] != run.String() || body.RetryAfter != 42 {
+		t.Errorf("body = %+v", body)
+	}
+}
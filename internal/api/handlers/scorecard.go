@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+)
+
+type ScorecardHandler struct {
+	scorecardService *scorecard.Service
+}
+
+func NewScorecardHandler(scorecardService *scorecard.Service) *ScorecardHandler {
+	return &ScorecardHandler{scorecardService: scorecardService}
+}
+
+// List returns the team's scorecards
+func (h *ScorecardHandler) List(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	scorecards, err := h.scorecardService.List(c.Request.Context(), teamID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"scorecards": scorecards})
+}
+
+// Summary returns the team's scorecard dashboard: entities by level with the
+// change over the last week, and the rules failed most
+func (h *ScorecardHandler) Summary(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	summary, err := h.scorecardService.Summary(c.Request.Context(), teamID)
+	if err != nil {
+		respondScorecardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// History returns the recorded results of a scorecard, newest first,
+// optionally of one ?entity= and since an RFC 3339 ?since=
+func (h *ScorecardHandler) History(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scorecard id"})
+		return
+	}
+
+	req := &scorecard.HistoryRequest{}
+	if raw := c.Query("entity"); raw != "" {
+		entityID, err := uuid.Parse(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+			return
+		}
+		req.EntityID = &entityID
+	}
+	if raw := c.Query("since"); raw != "" {
+		since, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		req.Since = &since
+	}
+	req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	req.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	resp, err := h.scorecardService.History(c.Request.Context(), teamID, id, req)
+	if err != nil {
+		respondScorecardError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func respondScorecardError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, scorecard.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
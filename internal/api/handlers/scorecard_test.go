@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+)
+
+func TestRespondScorecardError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{scorecard.ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("history: %w", scorecard.ErrNotFound), http.StatusNotFound},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondScorecardError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondScorecardError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/search"
+)
+
+type SearchHandler struct {
+	service *search.Service
+}
+
+// NewSearchHandler creates the admin search handler
+func NewSearchHandler(service *search.Service) *SearchHandler {
+	return &SearchHandler{service: service}
+}
+
+// Search finds teams, users and entities across every team (super admin only)
+func (h *SearchHandler) Search(c *gin.Context) {
+	q := &search.Query{Text: c.Query("q"), Limit: 20}
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			q.Limit = parsed
+		}
+	}
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			q.Offset = parsed
+		}
+	}
+	if t := c.Query("type"); t != "" {
+		q.Types = strings.Split(t, ",")
+	}
+
+	results, err := h.service.Search(c.Request.Context(), q)
+	if err != nil {
+		if errors.Is(err, search.ErrInvalidQuery) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		log.Printf("ERROR: failed to search: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/secret"
+)
+
+type SecretHandler struct {
+	secretService *secret.Service
+}
+
+func NewSecretHandler(secretService *secret.Service) *SecretHandler {
+	return &SecretHandler{secretService: secretService}
+}
+
+// List returns the team's secrets without their values
+func (h *SecretHandler) List(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.secretService.List(c.Request.Context(), teamID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *SecretHandler) Get(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	s, err := h.secretService.Get(c.Request.Context(), teamID, c.Param("name"))
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *SecretHandler) Create(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req secret.CreateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	s, err := h.secretService.Create(c.Request.Context(), teamID, &req, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+// Rotate replaces a secret's value
+func (h *SecretHandler) Rotate(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req secret.RotateSecretRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	s, err := h.secretService.Rotate(c.Request.Context(), teamID, c.Param("name"), &req, optionalUserID(c), ipAddress, userAgent)
+	if err != nil {
+		respondSecretError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s)
+}
+
+func (h *SecretHandler) Delete(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	ipAddress, userAgent := getAuditContext(c)
+	if err := h.secretService.Delete(c.Request.Context(), teamID, c.Param("name"), optionalUserID(c), ipAddress, userAgent); err != nil {
+		var inUse *secret.InUseError
+		if errors.As(err, &inUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "references": inUse.References})
+			return
+		}
+		respondSecretError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondSecretError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, secret.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, secret.ErrInvalidName), errors.Is(err, secret.ErrValueTooLarge):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, secret.ErrSecretExists):
+		respondError(c, http.StatusConflict, err)
+	case errors.Is(err, secret.ErrUnavailable):
+		respondError(c, http.StatusServiceUnavailable, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/secret"
+)
+
+func TestRespondSecretError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{secret.ErrNotFound, http.StatusNotFound},
+		{secret.ErrInvalidName, http.StatusBadRequest},
+		{fmt.Errorf("%w (max 65536 bytes)", secret.ErrValueTooLarge), http.StatusBadRequest},
+		{secret.ErrSecretExists, http.StatusConflict},
+		{secret.ErrUnavailable, http.StatusServiceUnavailable},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondSecretError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondSecretError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondIntegrationError_Secrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{fmt.Errorf("%w: %q", secret.ErrUnknownSecret, "api-token"), http.StatusBadRequest},
+		{secret.ErrUnavailable, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondIntegrationError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondIntegrationError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
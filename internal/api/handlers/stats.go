@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/stats"
+)
+
+type StatsHandler struct {
+	service  *stats.Service
+	recorder *stats.RequestRecorder
+}
+
+// NewStatsHandler creates the admin usage statistics handler. recorder may be
+// nil when request counting is disabled.
+func NewStatsHandler(service *stats.Service, recorder *stats.RequestRecorder) *StatsHandler {
+	return &StatsHandler{service: service, recorder: recorder}
+}
+
+// Recorder returns the request recorder fed by the request counting
+// middleware, or nil when requests are not counted
+func (h *StatsHandler) Recorder() *stats.RequestRecorder {
+	return h.recorder
+}
+
+// Team returns the usage statistics of one team (super admin only)
+func (h *StatsHandler) Team(c *gin.Context) {
+	teamID, err := uuid.Parse(c.Param("teamId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid team id"})
+		return
+	}
+
+	result, err := h.service.Team(c.Request.Context(), teamID, statsDays(c))
+	if err != nil {
+		if errors.Is(err, stats.ErrTeamNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+			return
+		}
+		log.Printf("ERROR: failed to get stats of team %s: %v", teamID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Platform returns the usage statistics of the whole installation (super admin only)
+func (h *StatsHandler) Platform(c *gin.Context) {
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 500 {
+			limit = parsed
+		}
+	}
+
+	result, err := h.service.Platform(c.Request.Context(), statsDays(c), limit)
+	if err != nil {
+		log.Printf("ERROR: failed to get platform stats: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// statsDays is how many days of request history to return, 30 by default
+func statsDays(c *gin.Context) int {
+	days := 30
+	if d := c.Query("days"); d != "" {
+		if parsed, err := strconv.Atoi(d); err == nil && parsed > 0 && parsed <= stats.MaxDays {
+			days = parsed
+		}
+	}
+	return days
+}
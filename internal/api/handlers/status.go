@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/status"
+)
+
+type StatusHandler struct {
+	statusService *status.Service
+}
+
+func NewStatusHandler(statusService *status.Service) *StatusHandler {
+	return &StatusHandler{statusService: statusService}
+}
+
+// Get reports version, uptime and component health. It answers 503 when the
+// server is down so that uptime monitors can alert on the status code alone.
+func (h *StatusHandler) Get(c *gin.Context) {
+	report := h.statusService.Report(c.Request.Context())
+
+	code := http.StatusOK
+	if report.Status == status.StateDown {
+		code = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(code, report)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/tasks"
+)
+
+// TaskHandler lets super admins inspect the built-in maintenance tasks,
+// change their schedules and run them now
+type TaskHandler struct {
+	engine *tasks.Engine
+}
+
+func NewTaskHandler(engine *tasks.Engine) *TaskHandler {
+	return &TaskHandler{engine: engine}
+}
+
+// List returns every task with its schedule and last run
+func (h *TaskHandler) List(c *gin.Context) {
+	resp, err := h.engine.List(c.Request.Context())
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *TaskHandler) Get(c *gin.Context) {
+	task, err := h.engine.Get(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// Update overrides a task's schedule or enables and disables it
+func (h *TaskHandler) Update(c *gin.Context) {
+	var req tasks.UpdateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	task, err := h.engine.Update(c.Request.Context(), c.Param("name"), &req)
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// Run queues a run of a task now, outside its schedule
+func (h *TaskHandler) Run(c *gin.Context) {
+	task, err := h.engine.Trigger(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		respondTaskError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, task)
+}
+
+func respondTaskError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, tasks.ErrNotFound):
+		respondError(c, http.StatusNotFound, err)
+	case errors.Is(err, tasks.ErrInvalid):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, tasks.ErrRunning):
+		respondError(c, http.StatusConflict, err)
+	default:
+		log.Printf("ERROR: tasks: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+	}
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/tasks"
+)
+
+func TestRespondTaskError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{tasks.ErrNotFound, http.StatusNotFound},
+		{tasks.ErrInvalid, http.StatusBadRequest},
+		{tasks.ErrRunning, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondTaskError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondTaskError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
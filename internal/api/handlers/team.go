@@ -2,21 +2,30 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
 	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/archive"
 	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/validation"
 )
 
 type TeamHandler struct {
 	authService *auth.Service
+	archive     *archive.Service
 }
 
-func NewTeamHandler(authService *auth.Service) *TeamHandler {
-	return &TeamHandler{authService: authService}
+// NewTeamHandler creates the team handler. archive writes the final export
+// of a team being deleted; without it, deletion cannot export.
+func NewTeamHandler(authService *auth.Service, archive *archive.Service) *TeamHandler {
+	return &TeamHandler{authService: authService, archive: archive}
 }
 
 func (h *TeamHandler) Create(c *gin.Context) {
@@ -28,17 +37,22 @@ func (h *TeamHandler) Create(c *gin.Context) {
 
 	var req auth.CreateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	team, err := h.authService.CreateTeam(c.Request.Context(), userID, &req)
 	if err != nil {
+		if validation.IsValidationError(err) {
+			c.Error(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": validation.GetValidationErrors(err)})
+			return
+		}
 		if errors.Is(err, auth.ErrTeamExists) {
-			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			respondError(c, http.StatusConflict, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -54,7 +68,7 @@ func (h *TeamHandler) List(c *gin.Context) {
 
 	teams, err := h.authService.GetTeamsByUser(c.Request.Context(), userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -71,10 +85,10 @@ func (h *TeamHandler) Get(c *gin.Context) {
 	team, err := h.authService.GetTeam(c.Request.Context(), teamID)
 	if err != nil {
 		if errors.Is(err, auth.ErrNotFound) {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			respondError(c, http.StatusNotFound, err)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -96,21 +110,55 @@ func (h *TeamHandler) Update(c *gin.Context) {
 
 	var req auth.CreateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
+	// Slugs from before the format was enforced may be kept
+	if req.Slug != team.Slug {
+		if err := validation.ValidateSlug("slug", req.Slug); err != nil {
+			c.Error(err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validation failed", "details": validation.GetValidationErrors(err)})
+			return
+		}
+	}
+
 	team.Name = req.Name
 	team.Slug = req.Slug
+	if req.RequireTwoFactor != nil {
+		team.RequireTwoFactor = *req.RequireTwoFactor
+	}
 
 	if err := h.authService.UpdateTeam(c.Request.Context(), team); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, team)
 }
 
+// RequestDeletion returns the confirmation token that deleting the team
+// requires, with what the deletion would remove
+func (h *TeamHandler) RequestDeletion(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.authService.RequestTeamDeletion(c.Request.Context(), teamID, optionalUserID(c))
+	if err != nil {
+		respondTeamDeletionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
+// Delete deletes the team with everything it owns. It requires the
+// confirmation_token from RequestDeletion; with export=true the response is
+// a zip archive of the team's blueprints and entities, written before the
+// team is deleted.
 func (h *TeamHandler) Delete(c *gin.Context) {
 	teamID, ok := middleware.GetTeamID(c)
 	if !ok {
@@ -118,12 +166,76 @@ func (h *TeamHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	if err := h.authService.DeleteTeam(c.Request.Context(), teamID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	token := c.Query("confirmation_token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirmation_token is required; request one with POST /api/teams/:teamId/deletion"})
 		return
 	}
+	actorID := optionalUserID(c)
+	ipAddress, userAgent := getAuditContext(c)
 
-	c.JSON(http.StatusNoContent, nil)
+	if c.Query("export") != "true" {
+		summary, err := h.authService.DeleteTeam(c.Request.Context(), teamID, actorID, token, ipAddress, userAgent)
+		if err != nil {
+			respondTeamDeletionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"deleted": summary})
+		return
+	}
+
+	if h.archive == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "team export is not available"})
+		return
+	}
+	// Do not spend an export on a token the deletion would reject
+	if err := h.authService.CheckTeamDeletion(c.Request.Context(), teamID, token); err != nil {
+		respondTeamDeletionError(c, err)
+		return
+	}
+	// The archive must be complete before the team is deleted, and can be
+	// larger than is reasonable to hold in memory
+	file, err := os.CreateTemp("", "team-export-*.zip")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := h.archive.Write(c.Request.Context(), teamID, file); err != nil {
+		log.Printf("ERROR: final export of team %s failed: %v", teamID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export failed; the team was not deleted"})
+		return
+	}
+	if _, err := h.authService.DeleteTeam(c.Request.Context(), teamID, actorID, token, ipAddress, userAgent); err != nil {
+		respondTeamDeletionError(c, err)
+		return
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		log.Printf("ERROR: final export of deleted team %s is lost: %v", teamID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "the team was deleted but its export could not be sent"})
+		return
+	}
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="team-%s.zip"`, teamID))
+	c.Status(http.StatusOK)
+	if _, err := io.Copy(c.Writer, file); err != nil {
+		// The status is already sent; the response simply ends early
+		log.Printf("ERROR: final export of deleted team %s stopped: %v", teamID, err)
+	}
+}
+
+func respondTeamDeletionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "team not found"})
+	case errors.Is(err, auth.ErrInvalidDeletionToken):
+		respondError(c, http.StatusBadRequest, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
 }
 
 // Role endpoints
@@ -136,7 +248,7 @@ func (h *TeamHandler) ListRoles(c *gin.Context) {
 
 	roles, err := h.authService.GetRoles(c.Request.Context(), teamID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -155,19 +267,98 @@ func (h *TeamHandler) CreateRole(c *gin.Context) {
 		Permissions []string `json:"permissions" binding:"required"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	role, err := h.authService.CreateRole(c.Request.Context(), teamID, req.Name, req.Permissions)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondRoleError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, role)
 }
 
+func (h *TeamHandler) UpdateRole(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role id"})
+		return
+	}
+
+	var req auth.UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	role, err := h.authService.UpdateRole(c.Request.Context(), teamID, roleID, &req)
+	if err != nil {
+		respondRoleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole deletes a custom role. Members holding it must be moved to
+// another role with ?reassign_to=<roleId>, or the request fails.
+func (h *TeamHandler) DeleteRole(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	roleID, err := uuid.Parse(c.Param("roleId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role id"})
+		return
+	}
+
+	var reassignTo *uuid.UUID
+	if v := c.Query("reassign_to"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid reassign_to role id"})
+			return
+		}
+		reassignTo = &id
+	}
+
+	if err := h.authService.DeleteRole(c.Request.Context(), teamID, roleID, reassignTo); err != nil {
+		var inUse *auth.RoleInUseError
+		if errors.As(err, &inUse) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "members": inUse.Members})
+			return
+		}
+		respondRoleError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondRoleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+	case errors.Is(err, auth.ErrInvalidRole):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, auth.ErrRoleExists), errors.Is(err, auth.ErrBuiltinRole), errors.Is(err, auth.ErrLastAdmin):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
+
 // Member endpoints
 func (h *TeamHandler) ListMembers(c *gin.Context) {
 	teamID, ok := middleware.GetTeamID(c)
@@ -178,7 +369,7 @@ func (h *TeamHandler) ListMembers(c *gin.Context) {
 
 	memberships, err := h.authService.GetMemberships(c.Request.Context(), teamID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -194,7 +385,7 @@ func (h *TeamHandler) AddMember(c *gin.Context) {
 
 	var req auth.InviteMemberRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
@@ -210,13 +401,52 @@ func (h *TeamHandler) AddMember(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		if errors.Is(err, auth.ErrAlreadyMember) {
+			respondError(c, http.StatusConflict, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, membership)
 }
 
+// UpdateMember changes a member's role
+func (h *TeamHandler) UpdateMember(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	userID, err := uuid.Parse(c.Param("userId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid user id"})
+		return
+	}
+
+	var req auth.UpdateMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	roleID, err := uuid.Parse(req.RoleID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role id"})
+		return
+	}
+
+	membership, err := h.authService.UpdateMemberRole(c.Request.Context(), teamID, userID, roleID)
+	if err != nil {
+		respondMemberError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, membership)
+}
+
 func (h *TeamHandler) RemoveMember(c *gin.Context) {
 	teamID, ok := middleware.GetTeamID(c)
 	if !ok {
@@ -232,13 +462,40 @@ func (h *TeamHandler) RemoveMember(c *gin.Context) {
 	}
 
 	if err := h.authService.RemoveMember(c.Request.Context(), teamID, userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondMemberError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
 
+func respondMemberError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "member not found"})
+	case errors.Is(err, auth.ErrInvalidRole):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, auth.ErrLastAdmin):
+		respondError(c, http.StatusConflict, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
+
+// CheckPermissions answers a batch of (action, resource) checks for the caller,
+// so UIs can decide which controls to enable in one request
+func (h *TeamHandler) CheckPermissions(c *gin.Context) {
+	var req auth.BulkPermissionCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	// RequireTeam resolves the caller's permissions; super admins receive all of them
+	decisions := auth.CheckPermissions(middleware.GetPermissions(c), req.Checks)
+	c.JSON(http.StatusOK, gin.H{"results": decisions})
+}
+
 // API Key endpoints
 func (h *TeamHandler) ListAPIKeys(c *gin.Context) {
 	teamID, ok := middleware.GetTeamID(c)
@@ -249,7 +506,7 @@ func (h *TeamHandler) ListAPIKeys(c *gin.Context) {
 
 	keys, err := h.authService.GetAPIKeys(c.Request.Context(), teamID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -271,13 +528,13 @@ func (h *TeamHandler) CreateAPIKey(c *gin.Context) {
 
 	var req auth.CreateAPIKeyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
 	resp, err := h.authService.CreateAPIKey(c.Request.Context(), teamID, &userID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
@@ -299,7 +556,7 @@ func (h *TeamHandler) DeleteAPIKey(c *gin.Context) {
 	}
 
 	if err := h.authService.DeleteAPIKey(c.Request.Context(), teamID, keyID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
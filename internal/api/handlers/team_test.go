@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/auth"
+)
+
+func TestRespondMemberError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{auth.ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: role does not belong to this team", auth.ErrInvalidRole), http.StatusBadRequest},
+		{auth.ErrLastAdmin, http.StatusConflict},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondMemberError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondMemberError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestRespondTeamDeletionError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{auth.ErrNotFound, http.StatusNotFound},
+		{auth.ErrInvalidDeletionToken, http.StatusBadRequest},
+		{errors.New("connection reset"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		respondTeamDeletionError(c, tt.err)
+		if w.Code != tt.want {
+			t.Errorf("respondTeamDeletionError(%v) = %d, want %d", tt.err, w.Code, tt.want)
+		}
+	}
+}
+
+func TestDeleteTeam_RequiresConfirmationToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodDelete, "/api/teams/x", nil)
+	c.Set(middleware.ContextTeamID, uuid.New())
+
+	NewTeamHandler(nil, nil).Delete(c)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/view"
+)
+
+type ViewHandler struct {
+	viewService *view.Service
+}
+
+func NewViewHandler(viewService *view.Service) *ViewHandler {
+	return &ViewHandler{viewService: viewService}
+}
+
+// viewerFromContext describes the caller for view visibility and curation checks
+func viewerFromContext(c *gin.Context) view.Viewer {
+	viewer := view.Viewer{Curator: slices.Contains(middleware.GetPermissions(c), auth.PermBlueprintWrite)}
+	if userID, ok := middleware.GetUserID(c); ok {
+		viewer.UserID = &userID
+	}
+	return viewer
+}
+
+func (h *ViewHandler) List(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	resp, err := h.viewService.List(c.Request.Context(), teamID, c.Param("id"), viewerFromContext(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func (h *ViewHandler) Create(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	var req view.CreateViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	v, err := h.viewService.Create(c.Request.Context(), teamID, c.Param("id"), viewerFromContext(c), &req)
+	if err != nil {
+		respondViewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, v)
+}
+
+func (h *ViewHandler) Get(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("viewId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid view id"})
+		return
+	}
+
+	v, err := h.viewService.Get(c.Request.Context(), teamID, c.Param("id"), id, viewerFromContext(c))
+	if err != nil {
+		respondViewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+func (h *ViewHandler) Update(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("viewId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid view id"})
+		return
+	}
+
+	var req view.UpdateViewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	v, err := h.viewService.Update(c.Request.Context(), teamID, c.Param("id"), id, viewerFromContext(c), &req)
+	if err != nil {
+		respondViewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+func (h *ViewHandler) Delete(c *gin.Context) {
+	teamID, ok := middleware.GetTeamID(c)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "team id required"})
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("viewId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid view id"})
+		return
+	}
+
+	if err := h.viewService.Delete(c.Request.Context(), teamID, c.Param("id"), id, viewerFromContext(c)); err != nil {
+		respondViewError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func respondViewError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, view.ErrInvalidView):
+		respondError(c, http.StatusBadRequest, err)
+	case errors.Is(err, view.ErrForbidden):
+		respondError(c, http.StatusForbidden, err)
+	case errors.Is(err, view.ErrNotFound), errors.Is(err, view.ErrBlueprintNotFound):
+		respondError(c, http.StatusNotFound, err)
+	default:
+		respondError(c, http.StatusInternalServerError, err)
+	}
+}
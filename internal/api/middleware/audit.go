@@ -1,29 +1,33 @@
 package middleware
 
 import (
+	"bytes"
+	"context"
+	"log"
+
 	"github.com/gin-gonic/gin"
-)
+	"github.com/google/uuid"
 
-const (
-	ContextIPAddress = "ip_address"
-	ContextUserAgent = "user_agent"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/logging"
+	"github.com/baseplate/baseplate/internal/reqctx"
 )
 
-// AuditMiddleware extracts and sets audit information in context
+// AuditMiddleware adds the client's address and user agent to the request
+// context, for audit entries
 func AuditMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Use Gin's ClientIP which respects TrustedProxies configuration.
-		// NOTE: IP spoofing prevention requires proper TrustedProxies setup in router.
-		// Without explicit SetTrustedProxies() configuration, Gin trusts all proxy headers by default.
+		// Use Gin's ClientIP, which honours X-Forwarded-For only from the
+		// TRUSTED_PROXIES the router passes to SetTrustedProxies.
 		// See: https://pkg.go.dev/github.com/gin-gonic/gin#Engine.SetTrustedProxies
 		ipAddress := c.ClientIP()
 
 		// Extract user agent
 		userAgent := c.GetHeader("User-Agent")
 
-		// Set in context
-		c.Set(ContextIPAddress, ipAddress)
-		c.Set(ContextUserAgent, userAgent)
+		c.Request = c.Request.WithContext(reqctx.Update(c.Request.Context(), func(rc *reqctx.RequestContext) {
+			rc.ClientIP, rc.UserAgent = ipAddress, userAgent
+		}))
 
 		c.Next()
 	}
@@ -31,24 +35,123 @@ func AuditMiddleware() gin.HandlerFunc {
 
 // GetIPAddress retrieves IP address from context
 func GetIPAddress(c *gin.Context) string {
-	val, exists := c.Get(ContextIPAddress)
-	if !exists {
-		return ""
-	}
-	if ip, ok := val.(string); ok {
-		return ip
-	}
-	return ""
+	rc, _ := reqctx.From(c.Request.Context())
+	return rc.ClientIP
 }
 
 // GetUserAgent retrieves user agent from context
 func GetUserAgent(c *gin.Context) string {
-	val, exists := c.Get(ContextUserAgent)
-	if !exists {
-		return ""
+	rc, _ := reqctx.From(c.Request.Context())
+	return rc.UserAgent
+}
+
+// AuditRecorder stores audit log entries; *auth.Service implements it
+type AuditRecorder interface {
+	CreateAuditLog(ctx context.Context, log *auth.AuditLog) error
+}
+
+// CaptureAdminRequests records each request in the audit trail together with
+// its request and response bodies. Secrets are redacted as in the access log.
+// JSON bodies up to maxBytes are stored; others are recorded by size only.
+// Entries are written in the background so the response is not delayed.
+func CaptureAdminRequests(recorder AuditRecorder, maxBytes int) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+		requestBody := peekBody(c.Request, maxBytes)
+		capture := &capturingWriter{ResponseWriter: c.Writer, limit: maxBytes}
+		c.Writer = capture
+		c.Next()
+
+		status := c.Writer.Status()
+		result := "success"
+		if status >= 400 {
+			result = "failure"
+		}
+		requestContext := map[string]any{
+			"route":  c.FullPath(),
+			"status": status,
+		}
+		if query != "" {
+			requestContext["query"] = logging.ScrubQuery(query)
+		}
+		addBody(requestContext, "request", requestBody, c.Request.ContentLength)
+		addBody(requestContext, "response", capture.captured(), int64(capture.Size()))
+
+		entry := &auth.AuditLog{
+			ID:             uuid.New(),
+			ActorType:      "super_admin",
+			EntityType:     "admin_request",
+			EntityID:       truncate(path, 255),
+			Action:         c.Request.Method,
+			ResultStatus:   &result,
+			RequestContext: requestContext,
+		}
+		if userID, ok := GetUserID(c); ok {
+			entry.UserID = &userID
+		}
+		if ip := GetIPAddress(c); ip != "" {
+			entry.IPAddress = &ip
+		}
+		if ua := GetUserAgent(c); ua != "" {
+			entry.UserAgent = &ua
+		}
+		go func() {
+			if err := recorder.CreateAuditLog(context.Background(), entry); err != nil {
+				log.Printf("ERROR: failed to record admin request %s %s: %v", entry.Action, entry.EntityID, err)
+			}
+		}()
+	}
+}
+
+// addBody stores a scrubbed JSON body under "<kind>_body", or only its size
+// under "<kind>_bytes" when the body was not captured whole
+func addBody(requestContext map[string]any, kind string, body []byte, size int64) {
+	if len(body) > 0 {
+		if payload, ok := logging.ScrubJSON(body); ok {
+			requestContext[kind+"_body"] = payload
+			return
+		}
 	}
-	if ua, ok := val.(string); ok {
-		return ua
+	if size > 0 {
+		requestContext[kind+"_bytes"] = size
+	}
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// capturingWriter keeps a copy of the first limit+1 bytes of the response
+type capturingWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+}
+
+func (w *capturingWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// captured returns the whole response body, or nil when it exceeded the limit
+func (w *capturingWriter) captured() []byte {
+	if w.body.Len() > w.limit {
+		return nil
+	}
+	return w.body.Bytes()
+}
+
+func (w *capturingWriter) keep(b []byte) {
+	if room := w.limit + 1 - w.body.Len(); room > 0 {
+		w.body.Write(b[:min(room, len(b))])
 	}
-	return ""
 }
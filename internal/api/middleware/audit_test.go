@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/logging"
+)
+
+type recorderFunc func(ctx context.Context, log *auth.AuditLog) error
+
+func (f recorderFunc) CreateAuditLog(ctx context.Context, log *auth.AuditLog) error {
+	return f(ctx, log)
+}
+
+func TestCaptureAdminRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	entries := make(chan *auth.AuditLog, 1)
+	recorder := recorderFunc(func(ctx context.Context, log *auth.AuditLog) error {
+		entries <- log
+		return nil
+	})
+
+	engine := gin.New()
+	engine.Use(AuditMiddleware(), CaptureAdminRequests(recorder, 1024))
+	engine.PUT("/api/admin/users/:userId", func(c *gin.Context) {
+		var body map[string]any
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"name": body["name"], "api_key": "bp_secret"})
+	})
+	engine.GET("/api/admin/users", func(c *gin.Context) {
+		c.String(http.StatusOK, strings.Repeat("x", 2048))
+	})
+
+	req := httptest.NewRequest(http.MethodPut, "/api/admin/users/42?token=abc", strings.NewReader(`{"name":"Ada","password":"hunter2"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("handler did not receive the body: %d %s", w.Code, w.Body.String())
+	}
+
+	entry := receive(t, entries)
+	if entry.ActorType != "super_admin" || entry.Action != http.MethodPut || entry.EntityID != "/api/admin/users/42" || *entry.ResultStatus != "success" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	ctx := entry.RequestContext
+	if ctx["route"] != "/api/admin/users/:userId" || ctx["query"] != "token="+logging.Redacted {
+		t.Errorf("unexpected request context %v", ctx)
+	}
+	requestBody := ctx["request_body"].(map[string]any)
+	if requestBody["name"] != "Ada" || requestBody["password"] != logging.Redacted {
+		t.Errorf("request body not captured and scrubbed: %v", requestBody)
+	}
+	responseBody := ctx["response_body"].(map[string]any)
+	if responseBody["api_key"] != logging.Redacted {
+		t.Errorf("response body not scrubbed: %v", responseBody)
+	}
+
+	// Bodies over the limit are recorded by size only
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/admin/users", nil))
+	entry = receive(t, entries)
+	if _, ok := entry.RequestContext["response_body"]; ok || entry.RequestContext["response_bytes"] != int64(2048) {
+		t.Errorf("unexpected request context %v", entry.RequestContext)
+	}
+}
+
+func receive(t *testing.T, entries <-chan *auth.AuditLog) *auth.AuditLog {
+	t.Helper()
+	select {
+	case entry := <-entries:
+		return entry
+	case <-time.After(time.Second):
+		t.Fatal("no audit entry recorded")
+		return nil
+	}
+}
@@ -1,7 +1,10 @@
 package middleware
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -9,7 +12,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"github.com/baseplate/baseplate/config"
 	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/ratelimit"
+	"github.com/baseplate/baseplate/internal/reqctx"
 )
 
 // superAdminCache provides a simple TTL cache for super admin status checks.
@@ -66,22 +72,32 @@ const (
 	ContextTeamID       = "team_id"
 	ContextPermissions  = "permissions"
 	ContextIsSuperAdmin = "is_super_admin"
+	ContextToken        = "token"
+
+	// contextClaims holds the validated JWT claims for RequireTeam
+	contextClaims = "jwt_claims"
 )
 
 type AuthMiddleware struct {
 	authService     *auth.Service
 	superAdminCache *superAdminCache
+	// keyFailures limits invalid API keys per client IP; nil when unlimited
+	keyFailures *ratelimit.Limiter
 }
 
 // SuperAdminCacheTTL is the duration super admin status is cached before re-checking the database.
 // After demotion, a user will lose super admin access within this time window.
 const SuperAdminCacheTTL = 1 * time.Minute
 
-func NewAuthMiddleware(authService *auth.Service) *AuthMiddleware {
-	return &AuthMiddleware{
+func NewAuthMiddleware(authService *auth.Service, apiKeys config.APIKeyConfig) *AuthMiddleware {
+	m := &AuthMiddleware{
 		authService:     authService,
 		superAdminCache: newSuperAdminCache(SuperAdminCacheTTL),
 	}
+	if apiKeys.FailuresPerMinute > 0 {
+		m.keyFailures = ratelimit.NewLimiter(apiKeys.FailuresPerMinute, apiKeys.FailureBurst)
+	}
+	return m
 }
 
 func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
@@ -116,8 +132,31 @@ func (m *AuthMiddleware) handleJWT(c *gin.Context, token string) {
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 		return
 	}
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	if err := m.authService.CheckSession(c.Request.Context(), claims.UserID, issuedAt); err != nil {
+		if errors.Is(err, auth.ErrSessionRevoked) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify session"})
+		return
+	}
+	if claims.Impersonation != nil && !m.checkImpersonation(c, claims.Impersonation) {
+		return
+	}
 
 	c.Set(ContextUserID, claims.UserID)
+	c.Set(ContextToken, claims.TokenInfo())
+	c.Set(contextClaims, claims)
+	c.Request = c.Request.WithContext(reqctx.Update(c.Request.Context(), func(rc *reqctx.RequestContext) {
+		rc.ActorType, rc.UserID = reqctx.ActorUser, &claims.UserID
+		if claims.Impersonation != nil {
+			rc.ImpersonatorID = &claims.Impersonation.ActorID
+		}
+	}))
 
 	// Set is_super_admin flag in context
 	isSuperAdmin := false
@@ -127,23 +166,55 @@ func (m *AuthMiddleware) handleJWT(c *gin.Context, token string) {
 	c.Set(ContextIsSuperAdmin, isSuperAdmin)
 
 	c.Next()
+
+	if claims.Impersonation != nil {
+		recordImpersonatedRequest(m.authService, c, claims)
+	}
 }
 
 func (m *AuthMiddleware) handleAPIKey(c *gin.Context, key string) {
+	// Clients that sent too many invalid keys are refused before the key is
+	// checked, so guessing cannot go on behind the 429s
+	ip := c.ClientIP()
+	if m.keyFailures != nil {
+		if ok, wait := m.keyFailures.Ready(ip); !ok {
+			abortTooManyFailures(c, wait)
+			return
+		}
+	}
+
 	apiKey, err := m.authService.ValidateAPIKey(c.Request.Context(), key)
 	if err != nil {
+		if m.keyFailures != nil && errors.Is(err, auth.ErrUnauthorized) {
+			m.keyFailures.Allow(ip)
+		}
 		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid api key"})
 		return
 	}
 
 	c.Set(ContextTeamID, apiKey.TeamID)
 	c.Set(ContextPermissions, apiKey.Permissions)
+	c.Set(ContextToken, apiKey.TokenInfo())
 	if apiKey.UserID != nil {
 		c.Set(ContextUserID, *apiKey.UserID)
 	}
+	c.Request = c.Request.WithContext(reqctx.Update(c.Request.Context(), func(rc *reqctx.RequestContext) {
+		rc.ActorType, rc.UserID, rc.APIKeyID, rc.TeamID = reqctx.ActorAPIKey, apiKey.UserID, &apiKey.ID, &apiKey.TeamID
+		rc.Permissions = apiKey.Permissions
+	}))
 	c.Next()
 }
 
+// abortTooManyFailures writes a 429 with Retry-After
+func abortTooManyFailures(c *gin.Context, wait time.Duration) {
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many invalid api keys", "retry_after_seconds": retryAfter})
+}
+
 func (m *AuthMiddleware) RequireTeam() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		teamIDStr := c.Param("teamId")
@@ -181,10 +252,22 @@ func (m *AuthMiddleware) RequireTeam() gin.HandlerFunc {
 			if IsSuperAdmin(c) {
 				c.Set(ContextPermissions, auth.AllPermissions)
 			} else {
-				permissions, err := m.authService.GetUserPermissions(c.Request.Context(), teamID, userUUID)
-
+				// The token may carry a fresh membership digest for this team
+				permissions, ok := claimPermissions(c, teamID)
+				if !ok {
+					permissions, err = m.authService.GetUserPermissions(c.Request.Context(), teamID, userUUID)
+					if err != nil {
+						c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access denied"})
+						return
+					}
+				}
+				missing, err := m.missingTwoFactor(c, teamID, permissions)
 				if err != nil {
-					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access denied"})
+					c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal server error"})
+					return
+				}
+				if missing {
+					c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": auth.ErrTwoFactorRequired.Error()})
 					return
 				}
 				c.Set(ContextPermissions, permissions)
@@ -192,10 +275,39 @@ func (m *AuthMiddleware) RequireTeam() gin.HandlerFunc {
 		}
 
 		c.Set(ContextTeamID, teamID)
+		permissions := GetPermissions(c)
+		c.Request = c.Request.WithContext(reqctx.Update(c.Request.Context(), func(rc *reqctx.RequestContext) {
+			rc.TeamID, rc.Permissions = &teamID, permissions
+		}))
 		c.Next()
 	}
 }
 
+// missingTwoFactor reports whether the team requires the caller to have
+// logged in with a second factor and their token was issued without one.
+// API keys are not held to the policy.
+func (m *AuthMiddleware) missingTwoFactor(c *gin.Context, teamID uuid.UUID, permissions []string) (bool, error) {
+	token := GetToken(c)
+	if token == nil || token.Type != auth.TokenTypeJWT || token.TwoFactor {
+		return false, nil
+	}
+	return m.authService.RequiresTwoFactor(c.Request.Context(), teamID, permissions)
+}
+
+// claimPermissions returns the team's permissions from the JWT membership
+// digest, if the token has a trusted one that includes the team
+func claimPermissions(c *gin.Context, teamID uuid.UUID) ([]string, bool) {
+	val, exists := c.Get(contextClaims)
+	if !exists {
+		return nil, false
+	}
+	claims, ok := val.(*auth.JWTClaims)
+	if !ok {
+		return nil, false
+	}
+	return claims.TeamPermissions(teamID, time.Now())
+}
+
 func (m *AuthMiddleware) RequirePermission(permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Super admins bypass all permission checks
@@ -264,6 +376,20 @@ func GetPermissions(c *gin.Context) []string {
 	return nil
 }
 
+// GetToken returns the credential the request was authenticated with
+func GetToken(c *gin.Context) *auth.TokenInfo {
+	val, exists := c.Get(ContextToken)
+	if !exists {
+		return nil
+	}
+
+	if token, ok := val.(*auth.TokenInfo); ok {
+		return token
+	}
+
+	return nil
+}
+
 func IsSuperAdmin(c *gin.Context) bool {
 	val, exists := c.Get(ContextIsSuperAdmin)
 	if !exists {
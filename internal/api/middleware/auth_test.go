@@ -8,6 +8,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/core/auth"
 )
 
 func init() {
@@ -386,3 +389,42 @@ func TestSuperAdminCache_Expiration(t *testing.T) {
 		t.Error("Cache entry should have expired")
 	}
 }
+
+func TestForbidImpersonation(t *testing.T) {
+	c, w := createTestContext()
+	c.Set(ContextToken, &auth.TokenInfo{Type: auth.TokenTypeJWT})
+	ForbidImpersonation()(c)
+	if c.IsAborted() {
+		t.Fatal("regular tokens should pass")
+	}
+
+	c, w = createTestContext()
+	c.Set(ContextToken, &auth.TokenInfo{
+		Type:          auth.TokenTypeJWT,
+		Impersonation: &auth.ImpersonationClaims{SessionID: uuid.New(), ActorID: uuid.New()},
+	})
+	ForbidImpersonation()(c)
+	if !c.IsAborted() || w.Code != http.StatusForbidden {
+		t.Errorf("impersonation tokens should be rejected with 403, got %d", w.Code)
+	}
+}
+
+func TestHandleAPIKey_RefusesAfterTooManyFailures(t *testing.T) {
+	m := NewAuthMiddleware(nil, config.APIKeyConfig{FailuresPerMinute: 1, FailureBurst: 1})
+	m.keyFailures.Allow("192.0.2.1")
+
+	// The limit applies before the key is checked, so no service is needed
+	c, w := createTestContext()
+	c.Request.RemoteAddr = "192.0.2.1:1234"
+	m.handleAPIKey(c, "bp_guess")
+	if !c.IsAborted() || w.Code != http.StatusTooManyRequests {
+		t.Fatalf("got %d, want 429", w.Code)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After should be set")
+	}
+
+	if NewAuthMiddleware(nil, config.APIKeyConfig{}).keyFailures != nil {
+		t.Error("a zero limit should disable the failure limiter")
+	}
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+// corsPolicy is a CORSConfig prepared for matching requests
+type corsPolicy struct {
+	cfg           config.CORSConfig
+	methods       map[string]bool
+	headers       map[string]bool
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	maxAge        string
+}
+
+func newCORSPolicy(cfg config.CORSConfig) *corsPolicy {
+	p := &corsPolicy{
+		cfg:           cfg,
+		methods:       make(map[string]bool, len(cfg.AllowedMethods)),
+		headers:       make(map[string]bool, len(cfg.AllowedHeaders)),
+		allowMethods:  strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:  strings.Join(cfg.AllowedHeaders, ", "),
+		exposeHeaders: strings.Join(cfg.ExposedHeaders, ", "),
+		maxAge:        strconv.Itoa(cfg.MaxAgeSeconds),
+	}
+	for _, m := range cfg.AllowedMethods {
+		p.methods[strings.ToUpper(m)] = true
+	}
+	for _, h := range cfg.AllowedHeaders {
+		p.headers[strings.ToLower(h)] = true
+	}
+	return p
+}
+
+// CORSPolicy is a CORS policy that can be replaced while the server runs
+type CORSPolicy struct {
+	policy atomic.Pointer[corsPolicy]
+}
+
+func NewCORSPolicy(cfg config.CORSConfig) *CORSPolicy {
+	p := &CORSPolicy{}
+	p.Update(cfg)
+	return p
+}
+
+// Update replaces the policy; requests already in flight finish with the old one
+func (p *CORSPolicy) Update(cfg config.CORSConfig) {
+	p.policy.Store(newCORSPolicy(cfg))
+}
+
+// CORS handles cross-origin requests and preflights according to the configured policy.
+// Requests from origins that are not allowed get no CORS headers, so browsers block them;
+// disallowed preflights are rejected with 403.
+func CORS(cfg config.CORSConfig) gin.HandlerFunc {
+	return NewCORSPolicy(cfg).Handler()
+}
+
+// Handler applies the current policy to every request
+func (p *CORSPolicy) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+
+		// Responses differ per origin, so caches must key on it
+		c.Writer.Header().Add("Vary", "Origin")
+
+		policy := p.policy.Load()
+		cfg := policy.cfg
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		allowed, wildcard := matchOrigin(cfg.AllowedOrigins, origin)
+		if !allowed {
+			if preflight {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if wildcard && !cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		if cfg.AllowCredentials {
+			c.Header("Access-Control-Allow-Credentials", "true")
+		}
+
+		if !preflight {
+			if policy.exposeHeaders != "" {
+				c.Header("Access-Control-Expose-Headers", policy.exposeHeaders)
+			}
+			c.Next()
+			return
+		}
+
+		if !policy.methods[strings.ToUpper(c.GetHeader("Access-Control-Request-Method"))] {
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		for _, h := range strings.Split(c.GetHeader("Access-Control-Request-Headers"), ",") {
+			if h = strings.ToLower(strings.TrimSpace(h)); h != "" && !policy.headers[h] {
+				c.AbortWithStatus(http.StatusForbidden)
+				return
+			}
+		}
+
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+		c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		c.Header("Access-Control-Allow-Methods", policy.allowMethods)
+		c.Header("Access-Control-Allow-Headers", policy.allowHeaders)
+		if cfg.MaxAgeSeconds > 0 {
+			c.Header("Access-Control-Max-Age", policy.maxAge)
+		}
+		c.AbortWithStatus(http.StatusNoContent)
+	}
+}
+
+// matchOrigin reports whether origin is allowed and whether it matched the "*" entry
+func matchOrigin(allowed []string, origin string) (bool, bool) {
+	for _, a := range allowed {
+		if a == "*" {
+			return true, true
+		}
+		if strings.EqualFold(a, origin) {
+			return true, false
+		}
+		// Subdomain wildcard: "https://*.example.com" matches "https://app.example.com"
+		if i := strings.Index(a, "://*."); i >= 0 {
+			scheme, suffix := a[:i+3], a[i+4:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, suffix) && len(origin) > len(scheme)+len(suffix) {
+				return true, false
+			}
+		}
+	}
+	return false, false
+}
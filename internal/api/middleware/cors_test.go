@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+func corsEngine(cfg config.CORSConfig) *gin.Engine {
+	engine := gin.New()
+	engine.Use(CORS(cfg))
+	engine.GET("/api/things", func(c *gin.Context) { c.Status(http.StatusOK) })
+	return engine
+}
+
+func TestCORS(t *testing.T) {
+	cfg := config.Defaults().CORS
+	cfg.AllowedOrigins = []string{"https://app.example.com", "https://*.internal.example.com"}
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		reqMethod   string
+		reqHeaders  string
+		wantStatus  int
+		wantAllowed string
+	}{
+		{"no origin", http.MethodGet, "", "", "", http.StatusOK, ""},
+		{"allowed origin", http.MethodGet, "https://app.example.com", "", "", http.StatusOK, "https://app.example.com"},
+		{"wildcard subdomain", http.MethodGet, "https://ui.internal.example.com", "", "", http.StatusOK, "https://ui.internal.example.com"},
+		{"wildcard excludes bare domain", http.MethodGet, "https://internal.example.com", "", "", http.StatusOK, ""},
+		{"disallowed origin", http.MethodGet, "https://evil.example.com", "", "", http.StatusOK, ""},
+		{"preflight allowed", http.MethodOptions, "https://app.example.com", "PATCH", "authorization, x-team-id", http.StatusNoContent, "https://app.example.com"},
+		{"preflight bad origin", http.MethodOptions, "https://evil.example.com", "GET", "", http.StatusForbidden, ""},
+		{"preflight bad method", http.MethodOptions, "https://app.example.com", "TRACE", "", http.StatusForbidden, "https://app.example.com"},
+		{"preflight bad header", http.MethodOptions, "https://app.example.com", "GET", "X-Custom", http.StatusForbidden, "https://app.example.com"},
+	}
+
+	engine := corsEngine(cfg)
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/api/things", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if tt.reqMethod != "" {
+			req.Header.Set("Access-Control-Request-Method", tt.reqMethod)
+		}
+		if tt.reqHeaders != "" {
+			req.Header.Set("Access-Control-Request-Headers", tt.reqHeaders)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantAllowed {
+			t.Errorf("%s: Access-Control-Allow-Origin = %q, want %q", tt.name, got, tt.wantAllowed)
+		}
+	}
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	cfg := config.Defaults().CORS
+	cfg.AllowedOrigins = []string{"*"}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/things", nil)
+	req.Header.Set("Origin", "https://anything.example")
+	w := httptest.NewRecorder()
+	corsEngine(cfg).ServeHTTP(w, req)
+
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := w.Header().Get("Access-Control-Allow-Credentials"); got != "" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want empty", got)
+	}
+}
+
+func TestCORSPolicy_Update(t *testing.T) {
+	cfg := config.Defaults().CORS
+	policy := NewCORSPolicy(cfg)
+	engine := gin.New()
+	engine.Use(policy.Handler())
+	engine.GET("/api/things", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	allowed := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/api/things", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	if got := allowed(); got != "" {
+		t.Fatalf("expected no CORS headers before the update, got %q", got)
+	}
+	cfg.AllowedOrigins = []string{"https://app.example.com"}
+	policy.Update(cfg)
+	if got := allowed(); got != "https://app.example.com" {
+		t.Errorf("expected the updated policy to allow the origin, got %q", got)
+	}
+}
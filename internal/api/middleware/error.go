@@ -1,20 +1,308 @@
 package middleware
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/apierror"
+	"github.com/baseplate/baseplate/internal/core/advisor"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/bundle"
+	"github.com/baseplate/baseplate/internal/core/docs"
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/export"
+	"github.com/baseplate/baseplate/internal/core/integration"
+	"github.com/baseplate/baseplate/internal/core/presentation"
+	"github.com/baseplate/baseplate/internal/core/runner"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/core/stats"
+	"github.com/baseplate/baseplate/internal/core/validation"
+	"github.com/baseplate/baseplate/internal/core/view"
+	"github.com/baseplate/baseplate/internal/cron"
+	"github.com/baseplate/baseplate/internal/jobs"
+	"github.com/baseplate/baseplate/internal/outbox"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+	"github.com/baseplate/baseplate/internal/tasks"
 )
 
+// internalErrorMessage replaces the message of unexpected server errors
+const internalErrorMessage = "internal server error"
+
+// errorCodes maps domain errors to the status and code they are answered
+// with. Errors that reject well-formed input are VALIDATION_FAILED; errors
+// not listed get the generic code of their response status.
+var errorCodes = []struct {
+	err    error
+	status int
+	code   apierror.Code
+}{
+	{auth.ErrInvalidCredentials, http.StatusUnauthorized, apierror.CodeInvalidCredentials},
+	{auth.ErrTwoFactorRequired, http.StatusUnauthorized, apierror.CodeTwoFactorRequired},
+	{auth.ErrSessionRevoked, http.StatusUnauthorized, apierror.CodeSessionRevoked},
+	{auth.ErrUserExists, http.StatusConflict, apierror.CodeUserExists},
+	{auth.ErrTeamExists, http.StatusConflict, apierror.CodeTeamExists},
+	{auth.ErrRoleExists, http.StatusConflict, apierror.CodeRoleExists},
+	{auth.ErrAlreadyMember, http.StatusConflict, apierror.CodeAlreadyMember},
+	{auth.ErrInvalidRole, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{auth.ErrInvalidName, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{stats.ErrTeamNotFound, http.StatusNotFound, apierror.CodeTeamNotFound},
+
+	{blueprint.ErrNotFound, http.StatusNotFound, apierror.CodeBlueprintNotFound},
+	{blueprint.ErrAlreadyExists, http.StatusConflict, apierror.CodeBlueprintExists},
+	{blueprint.ErrInvalidExpiryPolicy, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{blueprint.ErrInvalidDefault, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{blueprint.ErrInvalidRollup, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{blueprint.ErrInvalidMergePolicy, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{blueprint.ErrUnresolvableSchema, http.StatusBadRequest, apierror.CodeValidationFailed},
+
+	{entity.ErrNotFound, http.StatusNotFound, apierror.CodeEntityNotFound},
+	{entity.ErrAlreadyExists, http.StatusConflict, apierror.CodeEntityExists},
+	{entity.ErrBlueprintNotFound, http.StatusNotFound, apierror.CodeBlueprintNotFound},
+	{entity.ErrVersionConflict, http.StatusConflict, apierror.CodeVersionConflict},
+	{entity.ErrPropertyManaged, http.StatusConflict, apierror.CodePropertyManaged},
+	{entity.ErrRollupProperty, http.StatusConflict, apierror.CodeRollupProperty},
+	{entity.ErrRestrictedProperty, http.StatusForbidden, apierror.CodeRestrictedProperty},
+	{entity.ErrIntegrationNotFound, http.StatusNotFound, apierror.CodeIntegrationNotFound},
+	{entity.ErrSearchThrottled, http.StatusTooManyRequests, apierror.CodeRateLimited},
+	{entity.ErrValidation, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{entity.ErrInvalidFilter, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{entity.ErrInvalidInclude, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{entity.ErrInvalidPatch, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{entity.ErrInvalidAggregate, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{entity.ErrInvalidImport, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{entity.ErrInvalidReconcile, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{entity.ErrUnsupportedFormat, http.StatusBadRequest, apierror.CodeUnsupportedFormat},
+
+	{export.ErrNotFound, http.StatusNotFound, apierror.CodeExportNotFound},
+	{export.ErrNotReady, http.StatusConflict, apierror.CodeExportNotReady},
+	{export.ErrFailed, http.StatusConflict, apierror.CodeExportFailed},
+	{export.ErrExpired, http.StatusGone, apierror.CodeExportExpired},
+
+	{advisor.ErrBlueprintNotFound, http.StatusNotFound, apierror.CodeBlueprintNotFound},
+	{advisor.ErrNotIndexable, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{advisor.ErrAlreadyIndexed, http.StatusConflict, apierror.CodeConflict},
+	{advisor.ErrIndexLimit, http.StatusConflict, apierror.CodeConflict},
+
+	{integration.ErrNotFound, http.StatusNotFound, apierror.CodeIntegrationNotFound},
+	{view.ErrNotFound, http.StatusNotFound, apierror.CodeViewNotFound},
+	{view.ErrBlueprintNotFound, http.StatusNotFound, apierror.CodeBlueprintNotFound},
+	{view.ErrInvalidView, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{presentation.ErrNotFound, http.StatusNotFound, apierror.CodePresentationNotFound},
+	{presentation.ErrBlueprintNotFound, http.StatusNotFound, apierror.CodeBlueprintNotFound},
+	{presentation.ErrInvalidPresentation, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{docs.ErrNotFound, http.StatusNotFound, apierror.CodeDocNotFound},
+	{docs.ErrBlueprintNotFound, http.StatusNotFound, apierror.CodeBlueprintNotFound},
+	{docs.ErrEntityNotFound, http.StatusNotFound, apierror.CodeEntityNotFound},
+	{docs.ErrInvalidDoc, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{docs.ErrVersionConflict, http.StatusConflict, apierror.CodeVersionConflict},
+	{docs.ErrSearchForbidden, http.StatusForbidden, apierror.CodeForbidden},
+	{bundle.ErrBlueprintNotFound, http.StatusNotFound, apierror.CodeBlueprintNotFound},
+	{bundle.ErrInvalidBundle, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{bundle.ErrInvalidExport, http.StatusBadRequest, apierror.CodeValidationFailed},
+
+	{secret.ErrNotFound, http.StatusNotFound, apierror.CodeSecretNotFound},
+	{secret.ErrSecretExists, http.StatusConflict, apierror.CodeSecretExists},
+	{secret.ErrInvalidName, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{secret.ErrUnavailable, http.StatusServiceUnavailable, apierror.CodeUnavailable},
+
+	{runner.ErrRunnerNotFound, http.StatusNotFound, apierror.CodeRunnerNotFound},
+	{runner.ErrRunnerExists, http.StatusConflict, apierror.CodeRunnerExists},
+	{runner.ErrActionNotFound, http.StatusNotFound, apierror.CodeActionNotFound},
+	{runner.ErrRunNotFound, http.StatusNotFound, apierror.CodeRunNotFound},
+	{runner.ErrRunFinished, http.StatusConflict, apierror.CodeRunFinished},
+	{runner.ErrScheduleNotFound, http.StatusNotFound, apierror.CodeScheduleNotFound},
+	{runner.ErrScheduleExists, http.StatusConflict, apierror.CodeScheduleExists},
+	{runner.ErrInvalidSchedule, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{runner.ErrInvalidRunLimits, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{runner.ErrInvalidName, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{runner.ErrInvalidLabel, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{runner.ErrInvalidHealth, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{runner.ErrInvalidStatus, http.StatusBadRequest, apierror.CodeValidationFailed},
+
+	{jobs.ErrNotFound, http.StatusNotFound, apierror.CodeJobNotFound},
+	{tasks.ErrNotFound, http.StatusNotFound, apierror.CodeTaskNotFound},
+	{tasks.ErrInvalid, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{cron.ErrInvalid, http.StatusBadRequest, apierror.CodeValidationFailed},
+	{outbox.ErrNotFound, http.StatusNotFound, apierror.CodeEventNotFound},
+}
+
+// MapError returns the status and code an error is answered with when the
+// handler did not choose a status itself
+func MapError(err error) (int, apierror.Code) {
+	var apiErr *apierror.Error
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr.Status, apiErr.Code
+	case errors.As(err, &tooLarge):
+		return http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge
+	case validation.IsValidationError(err):
+		return http.StatusBadRequest, apierror.CodeValidationFailed
+	case postgres.IsTimeout(err):
+		return http.StatusGatewayTimeout, apierror.CodeQueryTimeout
+	}
+	for _, m := range errorCodes {
+		if errors.Is(err, m.err) {
+			return m.status, m.code
+		}
+	}
+	return http.StatusInternalServerError, apierror.CodeInternal
+}
+
+// errorCode returns the code of an error answered with status. The mapped
+// code is used when the handler chose the status the error maps to, the
+// generic code of the status otherwise.
+func errorCode(err error, status int) apierror.Code {
+	if err != nil {
+		if mapped, code := MapError(err); mapped == status {
+			return code
+		}
+	}
+	return apierror.ForStatus(status)
+}
+
+// ErrorHandler gives every error response the envelope of package apierror.
+// JSON error bodies written by handlers keep their fields and gain the code
+// and request_id; the code comes from the last error recorded with c.Error
+// when the body has none. Requests that recorded an error without writing a
+// response are answered from the error. Server errors whose message is the
+// text of a recorded error are logged and answered with a generic message, so
+// internal details do not reach clients.
 func ErrorHandler() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		w := &errorWriter{ResponseWriter: c.Writer}
+		c.Writer = w
 		c.Next()
+		c.Writer = w.ResponseWriter
 
-		if len(c.Errors) > 0 {
+		switch {
+		case w.held:
+			writeError(c, w.Status(), w.body.Bytes())
+		case !c.Writer.Written() && (c.Writer.Status() >= 400 || len(c.Errors) > 0):
+			writeError(c, c.Writer.Status(), nil)
+		}
+	}
+}
 
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Something wrong happened",
-			})
+// writeError writes the envelope of an error response with the body the
+// handler wrote, if any. Bodies that are not JSON objects with an error
+// message are written unchanged.
+func writeError(c *gin.Context, status int, body []byte) {
+	var last error
+	if len(c.Errors) > 0 {
+		last = c.Errors.Last().Err
+	}
+
+	fields := map[string]json.RawMessage{}
+	var message string
+	if len(body) > 0 {
+		if !strings.HasPrefix(c.Writer.Header().Get("Content-Type"), "application/json") ||
+			json.Unmarshal(body, &fields) != nil || json.Unmarshal(fields["error"], &message) != nil {
+			c.Writer.WriteHeader(status)
+			c.Writer.Write(body)
+			return
+		}
+	} else if last != nil {
+		if status < 400 {
+			status, _ = MapError(last)
+		}
+		message = last.Error()
+	} else {
+		message = strings.ToLower(http.StatusText(status))
+	}
+
+	if _, ok := fields["code"]; !ok {
+		fields["code"] = marshal(errorCode(last, status))
+	}
+	if status >= 500 && len(c.Errors) > 0 {
+		log.Printf("ERROR: api: %s %s (request %s): %v", c.Request.Method, c.Request.URL.Path, GetRequestID(c), c.Errors.Last().Err)
+		if recorded(c, message) {
+			message = internalErrorMessage
 		}
 	}
+	fields["error"] = marshal(message)
+	if id := GetRequestID(c); id != "" {
+		fields["request_id"] = marshal(id)
+	}
+
+	c.Writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	c.Writer.WriteHeader(status)
+	c.Writer.Write(marshal(fields))
+}
+
+// recorded reports whether message is the text of an error recorded with
+// c.Error, other than an *apierror.Error meant for the client
+func recorded(c *gin.Context, message string) bool {
+	for _, e := range c.Errors {
+		var apiErr *apierror.Error
+		if e.Err.Error() == message && !errors.As(e.Err, &apiErr) {
+			return true
+		}
+	}
+	return false
+}
+
+func marshal(v any) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// errorWriter holds back error responses, from the first write with a status
+// of 400 or above, so ErrorHandler can complete them. Other responses pass
+// through, including streams.
+type errorWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+	held bool
+}
+
+func (w *errorWriter) holds() bool {
+	if !w.held && !w.ResponseWriter.Written() && w.Status() >= 400 {
+		w.held = true
+	}
+	return w.held
+}
+
+func (w *errorWriter) WriteHeaderNow() {
+	if !w.holds() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *errorWriter) Write(b []byte) (int, error) {
+	if w.holds() {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *errorWriter) WriteString(s string) (int, error) {
+	if w.holds() {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *errorWriter) Flush() {
+	if !w.held {
+		w.ResponseWriter.Flush()
+	}
+}
+
+func (w *errorWriter) Written() bool {
+	return w.held || w.ResponseWriter.Written()
+}
+
+func (w *errorWriter) Size() int {
+	if w.held {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
 }
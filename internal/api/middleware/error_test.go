@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/apierror"
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		status  int
+		want    map[string]any
+	}{
+		{
+			name: "mapped domain error",
+			handler: func(c *gin.Context) {
+				err := fmt.Errorf("get: %w", entity.ErrNotFound)
+				c.Error(err)
+				c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			},
+			status: http.StatusNotFound,
+			want:   map[string]any{"error": "get: entity not found", "code": "ENTITY_NOT_FOUND"},
+		},
+		{
+			name: "status other than the mapped one",
+			handler: func(c *gin.Context) {
+				c.Error(entity.ErrNotFound)
+				c.JSON(http.StatusBadRequest, gin.H{"error": "entity not found"})
+			},
+			status: http.StatusBadRequest,
+			want:   map[string]any{"error": "entity not found", "code": "BAD_REQUEST"},
+		},
+		{
+			name: "ad-hoc message",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid entity id"})
+			},
+			status: http.StatusBadRequest,
+			want:   map[string]any{"error": "invalid entity id", "code": "BAD_REQUEST"},
+		},
+		{
+			name: "handler code and extra fields kept",
+			handler: func(c *gin.Context) {
+				c.JSON(http.StatusGatewayTimeout, gin.H{"error": "query timed out", "code": "CUSTOM", "detail": "narrow the filters"})
+			},
+			status: http.StatusGatewayTimeout,
+			want:   map[string]any{"error": "query timed out", "code": "CUSTOM", "detail": "narrow the filters"},
+		},
+		{
+			name: "internal error hidden",
+			handler: func(c *gin.Context) {
+				err := errors.New("pq: connection refused")
+				c.Error(err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			},
+			status: http.StatusInternalServerError,
+			want:   map[string]any{"error": "internal server error", "code": "INTERNAL_ERROR"},
+		},
+		{
+			name: "error without response",
+			handler: func(c *gin.Context) {
+				c.Error(apierror.New(http.StatusConflict, apierror.CodeVersionConflict, "entity was modified"))
+			},
+			status: http.StatusConflict,
+			want:   map[string]any{"error": "entity was modified", "code": "VERSION_CONFLICT"},
+		},
+		{
+			name: "status without body",
+			handler: func(c *gin.Context) {
+				c.AbortWithStatus(http.StatusUnauthorized)
+			},
+			status: http.StatusUnauthorized,
+			want:   map[string]any{"error": "unauthorized", "code": "UNAUTHORIZED"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(RequestID(), ErrorHandler())
+			r.GET("/", tt.handler)
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body %q: %v", w.Body.String(), err)
+			}
+			tt.want["request_id"] = "req-1"
+			if fmt.Sprint(body) != fmt.Sprint(tt.want) {
+				t.Errorf("body = %v, want %v", body, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorHandler_PassesThrough(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ErrorHandler())
+	r.GET("/ok", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"error": "not an error"})
+	})
+	r.GET("/text", func(c *gin.Context) {
+		c.String(http.StatusNotFound, "missing")
+	})
+
+	for path, want := range map[string]string{"/ok": `{"error":"not an error"}`, "/text": "missing"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != want {
+			t.Errorf("%s: body = %q, want %q", path, w.Body.String(), want)
+		}
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := map[string]bool{
+		"req-1":                   true,
+		"":                        false,
+		"has space":               false,
+		string(make([]byte, 200)): false,
+	}
+	for header, kept := range tests {
+		r := gin.New()
+		r.Use(RequestID())
+		var got string
+		r.GET("/", func(c *gin.Context) { got = GetRequestID(c) })
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, header)
+		r.ServeHTTP(w, req)
+
+		if got == "" || w.Header().Get(RequestIDHeader) != got {
+			t.Errorf("%q: request id %q, header %q", header, got, w.Header().Get(RequestIDHeader))
+		}
+		if (got == header) != kept {
+			t.Errorf("%q: request id = %q, kept = %v", header, got, kept)
+		}
+	}
+}
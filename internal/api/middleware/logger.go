@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/logging"
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+// maxLoggedPayload caps the request body kept for the access log. Larger
+// bodies are not logged since a truncated document cannot be scrubbed.
+const maxLoggedPayload = 4 << 10
+
+// AccessLog logs one line per request through the default slog logger, so
+// access logs follow the configured level and format. Server errors are
+// logged at error level, everything else at info. Its settings can be
+// replaced while the server runs.
+type AccessLog struct {
+	settings atomic.Pointer[config.LogConfig]
+}
+
+func NewAccessLog(cfg config.LogConfig) *AccessLog {
+	a := &AccessLog{}
+	a.Update(cfg)
+	return a
+}
+
+// Update replaces the sampling and payload settings
+func (a *AccessLog) Update(cfg config.LogConfig) {
+	a.settings.Store(&cfg)
+}
+
+// Handler samples successful responses and always logs the rest. Query
+// parameters, headers and payloads are scrubbed of secrets before logging.
+func (a *AccessLog) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		path := c.Request.URL.Path
+		query := c.Request.URL.RawQuery
+		cfg := a.settings.Load()
+		var body []byte
+		if cfg.AccessPayloads {
+			body = peekBody(c.Request, maxLoggedPayload)
+		}
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= 200 && status < 300 && !sampled(cfg.AccessSamplePercent) {
+			return
+		}
+		level := slog.LevelInfo
+		if status >= 500 {
+			level = slog.LevelError
+		}
+		logger := slog.Default()
+		if !logger.Enabled(c.Request.Context(), level) {
+			return
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("path", path),
+			slog.Int("status", status),
+			slog.Duration("latency", time.Since(start)),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		rc, _ := reqctx.From(c.Request.Context())
+		attrs = append(attrs, rc.LogAttrs()...)
+		if query != "" {
+			attrs = append(attrs, slog.String("query", logging.ScrubQuery(query)))
+		}
+		if cfg.AccessPayloads {
+			attrs = append(attrs, slog.Any("headers", logging.ScrubHeaders(c.Request.Header)))
+			if payload, ok := logging.ScrubJSON(body); ok {
+				attrs = append(attrs, slog.Any("payload", payload))
+			}
+		}
+		logger.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
+
+func sampled(percent int) bool {
+	return percent >= 100 || rand.IntN(100) < percent
+}
+
+// peekBody returns a JSON request body of up to limit bytes and leaves the
+// body readable for the handler. It returns nil for other content types and
+// for larger bodies.
+func peekBody(r *http.Request, limit int) []byte {
+	if r.Body == nil || !strings.Contains(r.Header.Get("Content-Type"), "json") {
+		return nil
+	}
+	head, err := io.ReadAll(io.LimitReader(r.Body, int64(limit)+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil || len(head) > limit {
+		return nil
+	}
+	return head
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+func TestAccessLog(t *testing.T) {
+	var out bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&out, nil)))
+	defer slog.SetDefault(previous)
+
+	cfg := config.Defaults().Log
+	cfg.AccessSamplePercent = 0
+	cfg.AccessPayloads = true
+	access := NewAccessLog(cfg)
+
+	engine := gin.New()
+	engine.Use(access.Handler())
+	var received string
+	engine.POST("/api/auth/login", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		received = string(body)
+		c.Status(http.StatusUnauthorized)
+	})
+	engine.GET("/api/things", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// Successful responses are dropped at 0%
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/things", nil))
+	if out.Len() != 0 {
+		t.Fatalf("sampled-out request was logged: %s", out.String())
+	}
+
+	// Errors are always logged, with secrets scrubbed
+	body := `{"email":"a@b.c","password":"hunter2"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login?token=abc", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer abc")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	if received != body {
+		t.Errorf("handler received %q, want %q", received, body)
+	}
+	logged := out.String()
+	for _, secret := range []string{"hunter2", "Bearer abc", "token=abc"} {
+		if strings.Contains(logged, secret) {
+			t.Errorf("access log contains %q: %s", secret, logged)
+		}
+	}
+	if !strings.Contains(logged, `"status":401`) || !strings.Contains(logged, `"email":"a@b.c"`) {
+		t.Errorf("unexpected access log: %s", logged)
+	}
+
+	// Sampling changes without rebuilding the middleware
+	cfg.AccessSamplePercent = 100
+	access.Update(cfg)
+	out.Reset()
+	engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/things", nil))
+	if !strings.Contains(out.String(), `"status":200`) {
+		t.Errorf("request was not logged at 100%%: %s", out.String())
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/metrics"
+)
+
+// Metrics records request counts and latencies. The matched route pattern is used
+// as label (not the raw path) to keep label cardinality bounded.
+func Metrics(m *metrics.HTTPMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		m.Requests.Inc(method, route, strconv.Itoa(c.Writer.Status()))
+		m.Duration.Observe(time.Since(start).Seconds(), method, route)
+	}
+}
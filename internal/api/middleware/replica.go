@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+// PrimaryForWrites sends every read of a request that may write to the
+// primary database, so handlers that read before or after writing never see
+// replication lag. GET, HEAD and OPTIONS requests may use the read replica.
+func PrimaryForWrites() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			c.Request = c.Request.WithContext(postgres.WithPrimary(c.Request.Context()))
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+func TestPrimaryForWrites(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := map[string]bool{
+		http.MethodGet:    false,
+		http.MethodHead:   false,
+		http.MethodPost:   true,
+		http.MethodPut:    true,
+		http.MethodPatch:  true,
+		http.MethodDelete: true,
+	}
+	for method, want := range tests {
+		var got bool
+		r := gin.New()
+		r.Use(PrimaryForWrites())
+		r.Handle(method, "/", func(c *gin.Context) {
+			got = postgres.UsesPrimary(c.Request.Context())
+		})
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/", nil))
+		if got != want {
+			t.Errorf("%s: primary = %v, want %v", method, got, want)
+		}
+	}
+}
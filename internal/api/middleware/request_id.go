@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength caps client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID tags each request with an ID, echoed in the X-Request-ID response
+// header, the access log and error responses. A well-formed ID sent by the
+// client or a proxy is kept so a request can be traced across services;
+// otherwise a new one is generated. It starts the request's
+// reqctx.RequestContext, with the ID and the client's preferred locale, for
+// the authentication middleware to complete.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Request = c.Request.WithContext(reqctx.With(c.Request.Context(), reqctx.RequestContext{
+			RequestID: id,
+			ActorType: reqctx.ActorAnonymous,
+			Locale:    reqctx.ParseLocale(c.GetHeader("Accept-Language")),
+		}))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID RequestID assigned to the request
+func GetRequestID(c *gin.Context) string {
+	return reqctx.RequestID(c.Request.Context())
+}
+
+// validRequestID accepts up to maxRequestIDLength printable ASCII characters
+// without spaces, so IDs cannot break log lines or headers
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
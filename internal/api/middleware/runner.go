@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/runner"
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+// contextRunner holds the runner an agent request was authenticated as
+const contextRunner = "runner"
+
+// AuthenticateRunner authenticates action runner agents by the runner token
+// in "Authorization: Bearer bpr_...". The runner's team becomes the
+// request's team.
+func AuthenticateRunner(runners *runner.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+		if !ok || !strings.EqualFold(scheme, "bearer") {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing runner token"})
+			return
+		}
+
+		r, err := runners.Authenticate(c.Request.Context(), token)
+		if errors.Is(err, runner.ErrUnauthorized) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.Error(err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Set(contextRunner, r)
+		c.Set(ContextTeamID, r.TeamID)
+		c.Request = c.Request.WithContext(reqctx.Update(c.Request.Context(), func(rc *reqctx.RequestContext) {
+			rc.ActorType, rc.RunnerID, rc.TeamID = reqctx.ActorRunner, &r.ID, &r.TeamID
+		}))
+		c.Next()
+	}
+}
+
+// GetRunner returns the runner set by AuthenticateRunner
+func GetRunner(c *gin.Context) (*runner.Runner, bool) {
+	r, ok := c.Get(contextRunner)
+	if !ok {
+		return nil, false
+	}
+	rr, ok := r.(*runner.Runner)
+	return rr, ok
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/baseplate/baseplate/internal/core/runner"
+)
+
+func TestAuthenticateRunner_RejectsWithoutRunnerToken(t *testing.T) {
+	handler := AuthenticateRunner(runner.NewService(nil, nil, nil, nil))
+	tests := []struct {
+		name   string
+		header string
+	}{
+		{"no header", ""},
+		{"no scheme", "bpr_0123"},
+		{"api key scheme", "ApiKey bpr_0123"},
+		{"user token", "Bearer eyJhbGciOiJIUzI1NiJ9.e30.sig"},
+		{"api key", "Bearer bp_0123"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, w := createTestContext()
+			if tt.header != "" {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+			handler(c)
+			if w.Code != http.StatusUnauthorized || !c.IsAborted() {
+				t.Errorf("status = %d, aborted = %v; want 401, aborted", w.Code, c.IsAborted())
+			}
+			if _, ok := GetRunner(c); ok {
+				t.Error("runner set on a rejected request")
+			}
+		})
+	}
+}
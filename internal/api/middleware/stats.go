@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/internal/core/stats"
+)
+
+// CountTeamRequests counts each request made in a team's context, for the
+// admin usage statistics. Requests without a team are not counted.
+func CountTeamRequests(recorder *stats.RequestRecorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if teamID, ok := GetTeamID(c); ok {
+			recorder.Record(teamID, c.Writer.Status())
+		}
+	}
+}
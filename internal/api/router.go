@@ -3,19 +3,44 @@ package api
 import (
 	"github.com/gin-gonic/gin"
 
+	"github.com/baseplate/baseplate/config"
 	"github.com/baseplate/baseplate/internal/api/handlers"
 	"github.com/baseplate/baseplate/internal/api/middleware"
+	"github.com/baseplate/baseplate/internal/buildinfo"
 	"github.com/baseplate/baseplate/internal/core/auth"
 )
 
 type Router struct {
-	engine           *gin.Engine
-	authMiddleware   *middleware.AuthMiddleware
-	authHandler      *handlers.AuthHandler
-	teamHandler      *handlers.TeamHandler
-	blueprintHandler *handlers.BlueprintHandler
-	entityHandler    *handlers.EntityHandler
-	adminHandler     *handlers.AdminHandler
+	engine              *gin.Engine
+	cors                *middleware.CORSPolicy
+	access              *middleware.AccessLog
+	authMiddleware      *middleware.AuthMiddleware
+	authHandler         *handlers.AuthHandler
+	teamHandler         *handlers.TeamHandler
+	blueprintHandler    *handlers.BlueprintHandler
+	entityHandler       *handlers.EntityHandler
+	exportHandler       *handlers.ExportHandler
+	viewHandler         *handlers.ViewHandler
+	presentationHandler *handlers.PresentationHandler
+	docsHandler         *handlers.DocsHandler
+	bundleHandler       *handlers.BundleHandler
+	integrationHandler  *handlers.IntegrationHandler
+	adminHandler        *handlers.AdminHandler
+	grafanaHandler      *handlers.GrafanaHandler
+	metricsHandler      *handlers.MetricsHandler
+	statusHandler       *handlers.StatusHandler
+	statsHandler        *handlers.StatsHandler
+	searchHandler       *handlers.SearchHandler
+	advisorHandler      *handlers.IndexAdvisorHandler
+	secretHandler       *handlers.SecretHandler
+	runnerHandler       *handlers.RunnerHandler
+	jobHandler          *handlers.JobHandler
+	taskHandler         *handlers.TaskHandler
+	outboxHandler       *handlers.OutboxHandler
+	dlqHandler          *handlers.DLQHandler
+	scorecardHandler    *handlers.ScorecardHandler
+	notificationHandler *handlers.NotificationHandler
+	authService         *auth.Service
 }
 
 func NewRouter(
@@ -24,31 +49,92 @@ func NewRouter(
 	teamHandler *handlers.TeamHandler,
 	blueprintHandler *handlers.BlueprintHandler,
 	entityHandler *handlers.EntityHandler,
+	exportHandler *handlers.ExportHandler,
+	viewHandler *handlers.ViewHandler,
+	presentationHandler *handlers.PresentationHandler,
+	docsHandler *handlers.DocsHandler,
+	bundleHandler *handlers.BundleHandler,
+	integrationHandler *handlers.IntegrationHandler,
 	adminHandler *handlers.AdminHandler,
+	grafanaHandler *handlers.GrafanaHandler,
+	metricsHandler *handlers.MetricsHandler,
+	statusHandler *handlers.StatusHandler,
+	statsHandler *handlers.StatsHandler,
+	searchHandler *handlers.SearchHandler,
+	advisorHandler *handlers.IndexAdvisorHandler,
+	secretHandler *handlers.SecretHandler,
+	runnerHandler *handlers.RunnerHandler,
+	jobHandler *handlers.JobHandler,
+	taskHandler *handlers.TaskHandler,
+	outboxHandler *handlers.OutboxHandler,
+	dlqHandler *handlers.DLQHandler,
+	scorecardHandler *handlers.ScorecardHandler,
+	notificationHandler *handlers.NotificationHandler,
 ) *Router {
 	return &Router{
-		authMiddleware:   middleware.NewAuthMiddleware(authService),
-		authHandler:      authHandler,
-		teamHandler:      teamHandler,
-		blueprintHandler: blueprintHandler,
-		entityHandler:    entityHandler,
-		adminHandler:     adminHandler,
+		authHandler:         authHandler,
+		teamHandler:         teamHandler,
+		blueprintHandler:    blueprintHandler,
+		entityHandler:       entityHandler,
+		exportHandler:       exportHandler,
+		viewHandler:         viewHandler,
+		presentationHandler: presentationHandler,
+		docsHandler:         docsHandler,
+		bundleHandler:       bundleHandler,
+		integrationHandler:  integrationHandler,
+		adminHandler:        adminHandler,
+		grafanaHandler:      grafanaHandler,
+		metricsHandler:      metricsHandler,
+		statusHandler:       statusHandler,
+		statsHandler:        statsHandler,
+		searchHandler:       searchHandler,
+		advisorHandler:      advisorHandler,
+		secretHandler:       secretHandler,
+		runnerHandler:       runnerHandler,
+		jobHandler:          jobHandler,
+		taskHandler:         taskHandler,
+		outboxHandler:       outboxHandler,
+		dlqHandler:          dlqHandler,
+		scorecardHandler:    scorecardHandler,
+		notificationHandler: notificationHandler,
+		authService:         authService,
 	}
 }
 
-func (r *Router) Setup(mode string) *gin.Engine {
-	gin.SetMode(mode)
+func (r *Router) Setup(cfg *config.Config) *gin.Engine {
+	gin.SetMode(cfg.Server.Mode)
 	r.engine = gin.New()
+	// Validate has checked the proxies parse
+	_ = r.engine.SetTrustedProxies(cfg.Server.TrustedProxies)
 	r.engine.Use(gin.Recovery())
-	r.engine.Use(gin.Logger())
+	r.engine.Use(middleware.RequestID())
+	r.access = middleware.NewAccessLog(cfg.Log)
+	r.engine.Use(r.access.Handler())
+	r.cors = middleware.NewCORSPolicy(cfg.CORS)
+	r.engine.Use(r.cors.Handler())
 	r.engine.Use(middleware.ErrorHandler())
 	r.engine.Use(middleware.AuditMiddleware())
+	r.engine.Use(middleware.PrimaryForWrites())
+	r.authMiddleware = middleware.NewAuthMiddleware(r.authService, cfg.APIKeys)
+	if r.metricsHandler != nil {
+		r.engine.Use(middleware.Metrics(r.metricsHandler.HTTPMetrics()))
+		r.engine.GET("/metrics", r.metricsHandler.Scrape)
+	}
+	if r.statsHandler != nil && r.statsHandler.Recorder() != nil {
+		r.engine.Use(middleware.CountTeamRequests(r.statsHandler.Recorder()))
+	}
 
-	r.setupRoutes()
+	r.setupRoutes(cfg)
 	return r.engine
 }
 
-func (r *Router) setupRoutes() {
+// ApplyConfig applies reloaded settings to the middleware built by Setup
+func (r *Router) ApplyConfig(cfg *config.Config) {
+	r.cors.Update(cfg.CORS)
+	r.access.Update(cfg.Log)
+}
+
+func (r *Router) setupRoutes(cfg *config.Config) {
 	api := r.engine.Group("/api")
 
 	// Health check
@@ -56,19 +142,53 @@ func (r *Router) setupRoutes() {
 		c.JSON(200, gin.H{"status": "ok"})
 	})
 
+	// Build version (public, for bug reports)
+	api.GET("/version", func(c *gin.Context) {
+		c.JSON(200, buildinfo.Get())
+	})
+
+	// Component status (public, for uptime monitors)
+	api.GET("/status", r.statusHandler.Get)
+
 	// Auth routes (public)
 	authRoutes := api.Group("/auth")
 	{
 		authRoutes.POST("/register", r.authHandler.Register)
 		authRoutes.POST("/login", r.authHandler.Login)
+		authRoutes.POST("/login/2fa", r.authHandler.LoginTwoFactor)
+		authRoutes.POST("/verify-email", r.authHandler.VerifyEmail)
+		authRoutes.POST("/accept-invite", r.authHandler.AcceptInvite)
+	}
+
+	// Runner agents pull and report action runs with a runner token
+	if r.runnerHandler != nil {
+		runnerRoutes := api.Group("/runner")
+		runnerRoutes.Use(middleware.AuthenticateRunner(r.runnerHandler.Service()))
+		{
+			runnerRoutes.POST("/heartbeat", r.runnerHandler.Heartbeat)
+			runnerRoutes.POST("/claim", r.runnerHandler.Claim)
+			runnerRoutes.POST("/runs/:runId/report", r.runnerHandler.Report)
+		}
 	}
 
+	// GitHub webhooks, authenticated by their signature
+	api.POST("/webhooks/github/:integrationId", r.integrationHandler.GitHubWebhook)
+	// Ingest integrations, authenticated by their token
+	api.POST("/ingest/:integrationId", r.integrationHandler.Ingest)
+
 	// Protected routes
 	protected := api.Group("")
 	protected.Use(r.authMiddleware.Authenticate())
 	{
 		// Current user
 		protected.GET("/auth/me", r.authHandler.Me)
+		protected.PUT("/auth/me", middleware.ForbidImpersonation(), r.authHandler.UpdateMe)
+		protected.POST("/auth/me/change-password", middleware.ForbidImpersonation(), r.authHandler.ChangePassword)
+		protected.POST("/auth/me/2fa/enroll", middleware.ForbidImpersonation(), r.authHandler.EnrollTwoFactor)
+		protected.POST("/auth/me/2fa/confirm", middleware.ForbidImpersonation(), r.authHandler.ConfirmTwoFactor)
+		protected.POST("/auth/me/2fa/backup-codes", middleware.ForbidImpersonation(), r.authHandler.RegenerateBackupCodes)
+		protected.POST("/auth/me/2fa/disable", middleware.ForbidImpersonation(), r.authHandler.DisableTwoFactor)
+		protected.POST("/auth/refresh", middleware.ForbidImpersonation(), r.authHandler.Refresh)
 
 		// Teams (requires auth, no specific team)
 		teams := protected.Group("/teams")
@@ -83,20 +203,92 @@ func (r *Router) setupRoutes() {
 		{
 			team.GET("", r.teamHandler.Get)
 			team.PUT("", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.Update)
+			team.POST("/deletion", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.RequestDeletion)
 			team.DELETE("", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.Delete)
 
 			// Roles
 			team.GET("/roles", r.teamHandler.ListRoles)
 			team.POST("/roles", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.CreateRole)
+			team.PUT("/roles/:roleId", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.UpdateRole)
+			team.DELETE("/roles/:roleId", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.DeleteRole)
 
 			// Members
 			team.GET("/members", r.teamHandler.ListMembers)
 			team.POST("/members", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.AddMember)
+			team.PUT("/members/:userId", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.UpdateMember)
 			team.DELETE("/members/:userId", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.RemoveMember)
 
 			// API Keys
 			team.GET("/api-keys", r.teamHandler.ListAPIKeys)
-			team.POST("/api-keys", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.CreateAPIKey)
+			team.POST("/api-keys", middleware.ForbidImpersonation(), r.authMiddleware.RequirePermission(auth.PermTeamManage), r.teamHandler.CreateAPIKey)
+
+			// Secrets referenced by integration and action configurations
+			team.GET("/secrets", r.authMiddleware.RequirePermission(auth.PermIntegrationRead), r.secretHandler.List)
+			team.GET("/secrets/:name", r.authMiddleware.RequirePermission(auth.PermIntegrationRead), r.secretHandler.Get)
+			team.POST("/secrets", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.secretHandler.Create)
+			team.PUT("/secrets/:name", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.secretHandler.Rotate)
+			team.DELETE("/secrets/:name", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.secretHandler.Delete)
+
+			// Action runners and the runs they execute
+			team.GET("/runners", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.ListRunners)
+			team.POST("/runners", middleware.ForbidImpersonation(), r.authMiddleware.RequirePermission(auth.PermTeamManage), r.runnerHandler.CreateRunner)
+			team.GET("/runners/:runnerId", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.GetRunner)
+			team.PUT("/runners/:runnerId", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.runnerHandler.UpdateRunner)
+			team.DELETE("/runners/:runnerId", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.runnerHandler.DeleteRunner)
+			team.POST("/actions/:identifier/runs", r.authMiddleware.RequirePermission(auth.PermActionExecute), r.runnerHandler.TriggerRun)
+			team.GET("/runs", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.ListRuns)
+			team.GET("/runs/:runId", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.GetRun)
+			team.GET("/runs/:runId/logs", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.RunLogs)
+			team.POST("/runs/:runId/cancel", r.authMiddleware.RequirePermission(auth.PermActionExecute), r.runnerHandler.CancelRun)
+			team.POST("/actions/:identifier/schedules", r.authMiddleware.RequirePermission(auth.PermActionExecute), r.runnerHandler.CreateSchedule)
+			team.GET("/schedules", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.ListSchedules)
+			team.GET("/schedules/:scheduleId", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.GetSchedule)
+			team.PUT("/schedules/:scheduleId", r.authMiddleware.RequirePermission(auth.PermActionExecute), r.runnerHandler.UpdateSchedule)
+			team.DELETE("/schedules/:scheduleId", r.authMiddleware.RequirePermission(auth.PermActionExecute), r.runnerHandler.DeleteSchedule)
+			team.GET("/schedules/:scheduleId/runs", r.authMiddleware.RequirePermission(auth.PermActionRead), r.runnerHandler.ScheduleRuns)
+
+			// Bulk permission check for the caller
+			team.POST("/permissions/check", r.teamHandler.CheckPermissions)
+
+			// Blueprint bundles for promotion between teams and environments
+			team.GET("/blueprints/export", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.bundleHandler.Export)
+			team.POST("/blueprints/import", r.authMiddleware.RequirePermission(auth.PermBlueprintWrite), r.bundleHandler.Import)
+			// Configuration as code, to start managing a team from a repository
+			team.GET("/export/code",
+				r.authMiddleware.RequirePermission(auth.PermBlueprintRead),
+				r.authMiddleware.RequirePermission(auth.PermActionRead),
+				r.bundleHandler.ExportCode,
+			)
+			// Apply can change roles, blueprints and scorecards
+			team.POST("/apply",
+				r.authMiddleware.RequirePermission(auth.PermTeamManage),
+				r.authMiddleware.RequirePermission(auth.PermBlueprintWrite),
+				r.authMiddleware.RequirePermission(auth.PermScorecardWrite),
+				r.bundleHandler.Apply,
+			)
+			// Pre-merge validation of manifests, for CI checks
+			team.POST("/apply/check", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.bundleHandler.Check)
+
+			// Cross-blueprint entity search
+			team.POST("/entities/search", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.SearchAll)
+			// Blueprint and entity docs pages; results follow the caller's read permissions
+			team.GET("/docs/search", r.docsHandler.Search)
+			// Entities of several blueprints with the relations between them
+			team.POST("/entities/import", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.ImportCatalog)
+			// Scorecard dashboard
+			team.GET("/scorecards/summary", r.authMiddleware.RequirePermission(auth.PermScorecardRead), r.scorecardHandler.Summary)
+
+			// Email and Slack notifications of catalog events; members manage
+			// their own subscriptions, managers the team-wide ones
+			team.GET("/notifications/subscriptions", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.notificationHandler.ListSubscriptions)
+			team.POST("/notifications/subscriptions", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.notificationHandler.CreateSubscription)
+			team.DELETE("/notifications/subscriptions/:subscriptionId", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.notificationHandler.DeleteSubscription)
+			team.GET("/notifications/deliveries", r.authMiddleware.RequirePermission(auth.PermTeamManage), r.notificationHandler.ListDeliveries)
+
+			// Entity exports too large to stream, written in the background
+			team.GET("/exports", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.exportHandler.List)
+			team.GET("/exports/:exportId", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.exportHandler.Get)
+			team.GET("/exports/:exportId/download", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.exportHandler.Download)
 		}
 
 		// API key deletion (not team-scoped in URL)
@@ -111,12 +303,37 @@ func (r *Router) setupRoutes() {
 			blueprints.GET("/:id", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.blueprintHandler.Get)
 			blueprints.PUT("/:id", r.authMiddleware.RequirePermission(auth.PermBlueprintWrite), r.blueprintHandler.Update)
 			blueprints.DELETE("/:id", r.authMiddleware.RequirePermission(auth.PermBlueprintDelete), r.blueprintHandler.Delete)
+			blueprints.GET("/:id/schema.json", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.blueprintHandler.Schema)
+			blueprints.GET("/:id/property-usage", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.entityHandler.PropertyUsage)
+
+			// Entities under blueprint (gin requires the same wildcard name as the blueprint routes)
+			blueprints.POST("/:id/entities", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.Create)
+			blueprints.GET("/:id/entities", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.List)
+			blueprints.POST("/:id/entities/search", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.Search)
+			blueprints.GET("/:id/entities/import-template.csv", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.ImportTemplate)
+			blueprints.GET("/:id/entities/export", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.exportHandler.Export)
+			blueprints.POST("/:id/entities/export", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.exportHandler.ExportMatching)
+			blueprints.POST("/:id/entities/import", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.Import)
+			blueprints.GET("/:id/entities/by-identifier/:identifier", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.GetByIdentifier)
+
+			// Saved entity views (sharing and default rules are enforced by the view service)
+			blueprints.GET("/:id/views", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.viewHandler.List)
+			blueprints.POST("/:id/views", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.viewHandler.Create)
+			blueprints.GET("/:id/views/:viewId", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.viewHandler.Get)
+			blueprints.PUT("/:id/views/:viewId", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.viewHandler.Update)
+			blueprints.DELETE("/:id/views/:viewId", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.viewHandler.Delete)
 
-			// Entities under blueprint
-			blueprints.POST("/:blueprintId/entities", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.Create)
-			blueprints.GET("/:blueprintId/entities", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.List)
-			blueprints.POST("/:blueprintId/entities/search", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.Search)
-			blueprints.GET("/:blueprintId/entities/by-identifier/:identifier", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.GetByIdentifier)
+			// Presentation hints for frontends
+			blueprints.GET("/:id/presentation", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.presentationHandler.Get)
+			blueprints.PUT("/:id/presentation", r.authMiddleware.RequirePermission(auth.PermBlueprintWrite), r.presentationHandler.Put)
+			blueprints.DELETE("/:id/presentation", r.authMiddleware.RequirePermission(auth.PermBlueprintWrite), r.presentationHandler.Delete)
+
+			// Markdown documentation pages
+			blueprints.GET("/:id/docs", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.docsHandler.GetBlueprintDoc)
+			blueprints.PUT("/:id/docs", r.authMiddleware.RequirePermission(auth.PermBlueprintWrite), r.docsHandler.PutBlueprintDoc)
+			blueprints.DELETE("/:id/docs", r.authMiddleware.RequirePermission(auth.PermBlueprintWrite), r.docsHandler.DeleteBlueprintDoc)
+			blueprints.GET("/:id/docs/versions", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.docsHandler.BlueprintDocVersions)
+			blueprints.GET("/:id/docs/render", r.authMiddleware.RequirePermission(auth.PermBlueprintRead), r.docsHandler.RenderBlueprintDoc)
 		}
 
 		// Entity direct access (by ID)
@@ -124,27 +341,123 @@ func (r *Router) setupRoutes() {
 		entities.Use(r.authMiddleware.RequireTeam())
 		{
 			entities.GET("/:id", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.Get)
+			entities.GET("/:id/history", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.History)
+			entities.GET("/:id/sources", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.entityHandler.Sources)
+			entities.DELETE("/:id/sources/:property", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.ReleaseSource)
 			entities.PUT("/:id", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.Update)
+			entities.PATCH("/:id", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.Patch)
+			entities.POST("/:id/rename", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.entityHandler.Rename)
 			entities.DELETE("/:id", r.authMiddleware.RequirePermission(auth.PermEntityDelete), r.entityHandler.Delete)
+			entities.GET("/:id/docs", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.docsHandler.GetEntityDoc)
+			entities.PUT("/:id/docs", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.docsHandler.PutEntityDoc)
+			entities.DELETE("/:id/docs", r.authMiddleware.RequirePermission(auth.PermEntityWrite), r.docsHandler.DeleteEntityDoc)
+			entities.GET("/:id/docs/versions", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.docsHandler.EntityDocVersions)
+			entities.GET("/:id/docs/render", r.authMiddleware.RequirePermission(auth.PermEntityRead), r.docsHandler.RenderEntityDoc)
+		}
+
+		// Scorecards and their results recorded over time
+		scorecards := protected.Group("/scorecards")
+		scorecards.Use(r.authMiddleware.RequireTeam())
+		{
+			scorecards.GET("", r.authMiddleware.RequirePermission(auth.PermScorecardRead), r.scorecardHandler.List)
+			scorecards.GET("/:id/history", r.authMiddleware.RequirePermission(auth.PermScorecardRead), r.scorecardHandler.History)
+		}
+
+		// Integrations; exporters reconcile the entities they own
+		integrations := protected.Group("/integrations")
+		integrations.Use(r.authMiddleware.RequireTeam())
+		{
+			integrations.GET("", r.authMiddleware.RequirePermission(auth.PermIntegrationRead), r.integrationHandler.List)
+			integrations.POST("", r.authMiddleware.RequirePermission(auth.PermIntegrationWrite), r.integrationHandler.Create)
+			integrations.GET("/:id", r.authMiddleware.RequirePermission(auth.PermIntegrationRead), r.integrationHandler.Get)
+			integrations.GET("/:id/config", r.authMiddleware.RequirePermission(auth.PermIntegrationWrite), r.integrationHandler.Config)
+			integrations.DELETE("/:id", r.authMiddleware.RequirePermission(auth.PermIntegrationWrite), r.integrationHandler.Delete)
+			integrations.POST("/:id/reconcile", r.authMiddleware.RequirePermission(auth.PermIntegrationWrite), r.integrationHandler.Reconcile)
+			integrations.POST("/:id/sync", r.authMiddleware.RequirePermission(auth.PermIntegrationWrite), r.integrationHandler.Sync)
+		}
+
+		// Grafana JSON datasource (point the datasource URL at /api/grafana)
+		grafana := protected.Group("/grafana")
+		grafana.Use(r.authMiddleware.RequireTeam(), r.authMiddleware.RequirePermission(auth.PermEntityRead))
+		{
+			grafana.GET("", r.grafanaHandler.Health)
+			grafana.POST("/search", r.grafanaHandler.Search)
+			grafana.POST("/metrics", r.grafanaHandler.Metrics)
+			grafana.POST("/query", r.grafanaHandler.Query)
 		}
 
 		// Admin routes (super admin only)
 		admin := protected.Group("/admin")
 		admin.Use(r.authMiddleware.RequireSuperAdmin())
+		if cfg.Audit.CaptureAdminBodies && r.authService != nil {
+			admin.Use(middleware.CaptureAdminRequests(r.authService, cfg.Audit.MaxBodyBytes))
+		}
 		{
 			// Team management
 			admin.GET("/teams", r.adminHandler.ListTeams)
 			admin.GET("/teams/:teamId", r.adminHandler.GetTeamDetail)
+			admin.GET("/teams/:teamId/stats", r.statsHandler.Team)
+			// Property value statistics guiding which properties to index
+			admin.GET("/teams/:teamId/blueprints/:blueprintId/column-stats", r.entityHandler.ColumnStats)
+			// Index recommendations from search telemetry
+			admin.GET("/index-recommendations", r.advisorHandler.Recommendations)
+			admin.POST("/index-recommendations/apply", r.advisorHandler.Apply)
+
+			// Usage statistics
+			admin.GET("/stats", r.statsHandler.Platform)
+
+			// Teams, users and entities across every team
+			admin.GET("/search", r.searchHandler.Search)
+
+			// Action runners of all teams, online and offline
+			admin.GET("/runners", r.runnerHandler.Fleet)
+
+			// Background jobs
+			admin.GET("/jobs", r.jobHandler.List)
+			admin.GET("/jobs/:jobId", r.jobHandler.Get)
+			admin.POST("/jobs/:jobId/retry", r.jobHandler.Retry)
+			admin.DELETE("/jobs/:jobId", r.jobHandler.Discard)
+
+			// System tasks
+			admin.GET("/tasks", r.taskHandler.List)
+			admin.GET("/tasks/:name", r.taskHandler.Get)
+			admin.PUT("/tasks/:name", r.taskHandler.Update)
+			admin.POST("/tasks/:name/run", r.taskHandler.Run)
+
+			// Event outbox
+			admin.GET("/outbox", r.outboxHandler.Get)
+			admin.POST("/outbox/replay", r.outboxHandler.Replay)
+			admin.GET("/outbox/events", r.outboxHandler.ListEvents)
+			admin.GET("/outbox/events/:eventId", r.outboxHandler.GetEvent)
+			admin.POST("/outbox/events/:eventId/retry", r.outboxHandler.RetryEvent)
+			admin.DELETE("/outbox/events/:eventId", r.outboxHandler.DiscardEvent)
+
+			// Dead letters of jobs and the outbox
+			admin.GET("/dlq", r.dlqHandler.Get)
 
 			// User management
 			admin.GET("/users", r.adminHandler.ListUsers)
+			admin.POST("/users", r.adminHandler.CreateUser)
 			admin.GET("/users/:userId", r.adminHandler.GetUserDetail)
 			admin.PUT("/users/:userId", r.adminHandler.UpdateUser)
+			admin.DELETE("/users/:userId", r.adminHandler.DeleteUser)
+			admin.POST("/users/:userId/deactivate", r.adminHandler.DeactivateUser)
+			admin.DELETE("/users/:userId/2fa", r.adminHandler.ResetTwoFactor)
 			admin.POST("/users/:userId/promote", r.adminHandler.PromoteUser)
 			admin.POST("/users/:userId/demote", r.adminHandler.DemoteUser)
+			admin.POST("/users/:userId/impersonate", r.adminHandler.Impersonate)
+
+			// Impersonation sessions
+			admin.GET("/impersonations", r.adminHandler.ListImpersonations)
+			admin.DELETE("/impersonations/:id", r.adminHandler.RevokeImpersonation)
 
 			// Audit logs
 			admin.GET("/audit-logs", r.adminHandler.QueryAuditLogs)
+
+			// Runtime configuration
+			admin.POST("/config/reload", r.adminHandler.ReloadConfig)
+			admin.GET("/logging", r.adminHandler.GetLogging)
+			admin.PUT("/logging", r.adminHandler.UpdateLogging)
 		}
 	}
 }
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/api/handlers"
+	"github.com/baseplate/baseplate/internal/metrics"
+)
+
+// Route registration panics on conflicting wildcards, so building the engine
+// is enough to catch them
+func TestSetup_RoutesRegisterWithoutConflicts(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.Server.Mode = "test"
+
+	engine := NewRouter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, &handlers.MetricsHandler{}, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil).Setup(cfg)
+
+	want := map[string]bool{
+		"GET /api/blueprints/:id":                                               false,
+		"GET /api/blueprints/:id/entities":                                      false,
+		"GET /api/blueprints/:id/property-usage":                                false,
+		"GET /api/blueprints/:id/entities/import-template.csv":                  false,
+		"POST /api/blueprints/:id/entities/import":                              false,
+		"POST /api/blueprints/:id/entities/export":                              false,
+		"GET /api/teams/:teamId/exports/:exportId/download":                     false,
+		"PUT /api/blueprints/:id/views/:viewId":                                 false,
+		"PUT /api/blueprints/:id/presentation":                                  false,
+		"GET /api/blueprints/:id/docs/render":                                   false,
+		"PUT /api/entities/:id/docs":                                            false,
+		"GET /api/teams/:teamId/docs/search":                                    false,
+		"POST /api/teams/:teamId/blueprints/import":                             false,
+		"POST /api/integrations/:id/reconcile":                                  false,
+		"POST /api/integrations/:id/sync":                                       false,
+		"POST /api/webhooks/github/:integrationId":                              false,
+		"POST /api/ingest/:integrationId":                                       false,
+		"GET /api/status":                                                       false,
+		"GET /api/admin/teams/:teamId/blueprints/:blueprintId/column-stats":     false,
+		"POST /api/admin/index-recommendations/apply":                           false,
+		"GET /api/admin/search":                                                 false,
+		"GET /api/scorecards":                                                   false,
+		"GET /api/scorecards/:id/history":                                       false,
+		"GET /api/teams/:teamId/scorecards/summary":                             false,
+		"DELETE /api/teams/:teamId/notifications/subscriptions/:subscriptionId": false,
+	}
+	for _, route := range engine.Routes() {
+		key := route.Method + " " + route.Path
+		if _, ok := want[key]; ok {
+			want[key] = true
+		}
+	}
+	for route, found := range want {
+		if !found {
+			t.Errorf("route %s not registered", route)
+		}
+	}
+}
+
+// teamCollector stands in for the catalog collector with one team-labelled series
+type teamCollector struct{}
+
+func (teamCollector) Collect(context.Context) ([]metrics.Family, error) {
+	return []metrics.Family{{
+		Name:    "baseplate_catalog_blueprints",
+		Help:    "Blueprints per team",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Labels: []metrics.Label{{Name: "team_id", Value: "660e8400-e29b-41d4-a716-446655440001"}}, Value: 3}},
+	}}, nil
+}
+
+func TestSetup_MetricsWithoutTokenServeNoTeamSeries(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.Server.Mode = "test"
+	scrape := func(token, authorization string) *httptest.ResponseRecorder {
+		metricsHandler := handlers.NewMetricsHandler(metrics.NewRegistry(), token, teamCollector{})
+		engine := NewRouter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, metricsHandler, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil).Setup(cfg)
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	w := scrape("", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("anonymous scrape without a token: status %d, want 200", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "team_id") {
+		t.Errorf("anonymous scrape served team-labelled series:\n%s", w.Body.String())
+	}
+
+	if w := scrape("secret", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous scrape with a token: status %d, want 401", w.Code)
+	}
+	if w := scrape("secret", "Bearer secret"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "team_id") {
+		t.Errorf("authorized scrape: status %d, want 200 with catalog series:\n%s", w.Code, w.Body.String())
+	}
+}
+
+// The failed API key limiter and audit entries key on ClientIP, so a client
+// must not pick its own address with X-Forwarded-For
+func TestSetup_ClientIPTrustsOnlyConfiguredProxies(t *testing.T) {
+	clientIP := func(proxies []string) string {
+		cfg := config.Defaults()
+		cfg.Server.Mode = "test"
+		cfg.Server.TrustedProxies = proxies
+		engine := NewRouter(nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil).Setup(cfg)
+		engine.GET("/client-ip", func(c *gin.Context) { c.String(http.StatusOK, c.ClientIP()) })
+		req := httptest.NewRequest(http.MethodGet, "/client-ip", nil)
+		req.RemoteAddr = "203.0.113.7:41234"
+		req.Header.Set("X-Forwarded-For", "198.51.100.9")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	if got := clientIP(nil); got != "203.0.113.7" {
+		t.Errorf("without trusted proxies ClientIP = %q, want the connecting address", got)
+	}
+	if got := clientIP([]string{"203.0.113.0/24"}); got != "198.51.100.9" {
+		t.Errorf("behind a trusted proxy ClientIP = %q, want the forwarded address", got)
+	}
+}
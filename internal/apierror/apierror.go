@@ -0,0 +1,136 @@
+// Package apierror defines the machine-readable codes of API error
+// responses. Every error response carries the envelope
+//
+//	{"error": "entity not found", "code": "ENTITY_NOT_FOUND", "request_id": "..."}
+//
+// where error is a human-readable message that may change, and code is
+// stable for clients to branch on. Handlers may add fields next to them,
+// such as details for validation failures.
+package apierror
+
+import "net/http"
+
+// Code identifies the kind of an error response
+type Code string
+
+// Generic codes, used when no domain-specific code applies
+const (
+	CodeBadRequest        Code = "BAD_REQUEST"
+	CodeValidationFailed  Code = "VALIDATION_FAILED"
+	CodeUnauthorized      Code = "UNAUTHORIZED"
+	CodeForbidden         Code = "FORBIDDEN"
+	CodeNotFound          Code = "NOT_FOUND"
+	CodeConflict          Code = "CONFLICT"
+	CodeGone              Code = "GONE"
+	CodePayloadTooLarge   Code = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedFormat Code = "UNSUPPORTED_FORMAT"
+	CodeRateLimited       Code = "RATE_LIMITED"
+	CodeInternal          Code = "INTERNAL_ERROR"
+	CodeNotImplemented    Code = "NOT_IMPLEMENTED"
+	CodeUnavailable       Code = "SERVICE_UNAVAILABLE"
+	CodeQueryTimeout      Code = "QUERY_TIMEOUT"
+)
+
+// Domain codes
+const (
+	CodeInvalidCredentials   Code = "INVALID_CREDENTIALS"
+	CodeTwoFactorRequired    Code = "TWO_FACTOR_REQUIRED"
+	CodeSessionRevoked       Code = "SESSION_REVOKED"
+	CodeUserExists           Code = "USER_EXISTS"
+	CodeTeamNotFound         Code = "TEAM_NOT_FOUND"
+	CodeTeamExists           Code = "TEAM_EXISTS"
+	CodeRoleExists           Code = "ROLE_EXISTS"
+	CodeAlreadyMember        Code = "ALREADY_MEMBER"
+	CodeBlueprintNotFound    Code = "BLUEPRINT_NOT_FOUND"
+	CodeBlueprintExists      Code = "BLUEPRINT_EXISTS"
+	CodeEntityNotFound       Code = "ENTITY_NOT_FOUND"
+	CodeEntityExists         Code = "ENTITY_EXISTS"
+	CodeVersionConflict      Code = "VERSION_CONFLICT"
+	CodePropertyManaged      Code = "PROPERTY_MANAGED"
+	CodeRestrictedProperty   Code = "RESTRICTED_PROPERTY"
+	CodeRollupProperty       Code = "ROLLUP_PROPERTY"
+	CodeIntegrationNotFound  Code = "INTEGRATION_NOT_FOUND"
+	CodeViewNotFound         Code = "VIEW_NOT_FOUND"
+	CodePresentationNotFound Code = "PRESENTATION_NOT_FOUND"
+	CodeDocNotFound          Code = "DOC_NOT_FOUND"
+	CodeExportNotFound       Code = "EXPORT_NOT_FOUND"
+	CodeExportNotReady       Code = "EXPORT_NOT_READY"
+	CodeExportFailed         Code = "EXPORT_FAILED"
+	CodeExportExpired        Code = "EXPORT_EXPIRED"
+	CodeSecretNotFound       Code = "SECRET_NOT_FOUND"
+	CodeSecretExists         Code = "SECRET_EXISTS"
+	CodeRunnerNotFound       Code = "RUNNER_NOT_FOUND"
+	CodeRunnerExists         Code = "RUNNER_EXISTS"
+	CodeActionNotFound       Code = "ACTION_NOT_FOUND"
+	CodeRunNotFound          Code = "RUN_NOT_FOUND"
+	CodeRunFinished          Code = "RUN_FINISHED"
+	CodeScheduleNotFound     Code = "SCHEDULE_NOT_FOUND"
+	CodeScheduleExists       Code = "SCHEDULE_EXISTS"
+	CodeJobNotFound          Code = "JOB_NOT_FOUND"
+	CodeTaskNotFound         Code = "TASK_NOT_FOUND"
+	CodeEventNotFound        Code = "EVENT_NOT_FOUND"
+)
+
+// Error is an error with the status and code of its response. Handlers
+// return it through gin's c.Error to answer with a specific code; the
+// message is shown to the client as is.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Err     error
+}
+
+// New returns an error answered with status, code and message
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// Wrap returns an error answered with status and code, using err's message
+func Wrap(err error, status int, code Code) *Error {
+	return &Error{Status: status, Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ForStatus returns the generic code of an HTTP error status
+func ForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusUnsupportedMediaType:
+		return CodeUnsupportedFormat
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusNotImplemented:
+		return CodeNotImplemented
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	case http.StatusGatewayTimeout:
+		return CodeQueryTimeout
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
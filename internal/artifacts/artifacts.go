@@ -0,0 +1,47 @@
+// Package artifacts stores files produced in the background, such as large
+// entity exports, until clients download them. Files live in a local
+// directory or in an S3-compatible bucket.
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+var ErrNotFound = errors.New("artifact not found")
+
+// Store holds files by key. Keys are slash-separated paths chosen by the
+// caller, such as "exports/<team>/<id>.ndjson".
+type Store interface {
+	// Put stores size bytes read from body under key, replacing any file there
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	// Open returns the content of key, or ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// DownloadURL returns a URL that serves key as an attachment named
+	// filename for ttl without further credentials. It returns "" when the
+	// store has no such URLs and downloads go through Open.
+	DownloadURL(key, filename string, ttl time.Duration) (string, error)
+	// Delete removes key; deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+	// Backend names the store for logs and the status report
+	Backend() string
+}
+
+// New returns the store configured for exports
+func New(cfg config.ExportsConfig) (Store, error) {
+	if cfg.Storage == config.ExportStorageS3 {
+		return NewS3(S3Options{
+			Bucket:          cfg.S3Bucket,
+			Region:          cfg.S3Region,
+			Endpoint:        cfg.S3Endpoint,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			PathStyle:       cfg.S3PathStyle,
+		})
+	}
+	return NewLocal(cfg.Dir)
+}
@@ -0,0 +1,35 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+func TestFillFromVCS(t *testing.T) {
+	settings := []debug.BuildSetting{
+		{Key: "vcs.revision", Value: "0123456789abcdef0123"},
+		{Key: "vcs.time", Value: "2026-10-17T08:00:00Z"},
+		{Key: "vcs.modified", Value: "true"},
+	}
+
+	info := Info{Version: "dev"}
+	fillFromVCS(&info, settings)
+	if info.Commit != "0123456789abcdef0123" || info.BuildDate != "2026-10-17T08:00:00Z" || !info.Modified {
+		t.Errorf("info = %+v", info)
+	}
+
+	// Values set with ldflags win
+	info = Info{Version: "v1.2.0", Commit: "release", BuildDate: "2026-10-01T00:00:00Z"}
+	fillFromVCS(&info, settings)
+	if info.Commit != "release" || info.BuildDate != "2026-10-01T00:00:00Z" {
+		t.Errorf("ldflags values overwritten: %+v", info)
+	}
+}
+
+func TestInfo_String(t *testing.T) {
+	info := Info{Version: "v1.2.0", Commit: "0123456789abcdef0123", BuildDate: "2026-10-17T08:00:00Z", GoVersion: "go1.25.1", Modified: true}
+	want := "v1.2.0 (commit 0123456789ab-dirty, built 2026-10-17T08:00:00Z, go1.25.1)"
+	if got := info.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
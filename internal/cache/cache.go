@@ -0,0 +1,89 @@
+// Package cache stores lookups that most requests repeat, such as the
+// blueprint of an entity write, in memory or in Redis. Values are JSON, so
+// every hit decodes a copy its caller may modify. Stores treat failures as
+// misses: a cache outage slows requests down but does not fail them.
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+// Store holds values by key until their TTL ends or they are deleted
+type Store interface {
+	// Get returns the value of key; ok is false on a miss
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	// Ping reports whether the store is reachable
+	Ping(ctx context.Context) error
+	// Backend names the store for the status report
+	Backend() string
+}
+
+// New returns the store of the configured backend, nil when it is off
+func New(cfg config.CacheConfig) (Store, error) {
+	switch cfg.Backend {
+	case config.CacheBackendMemory:
+		return NewMemory(cfg.MaxEntries), nil
+	case config.CacheBackendRedis:
+		store, err := NewRedis(cfg.RedisURL, cfg.KeyPrefix)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	default:
+		return nil, nil
+	}
+}
+
+// GetJSON decodes the value of key into v and reports whether it was found.
+// Errors are logged and count as misses.
+func GetJSON(ctx context.Context, store Store, key string, v interface{}) bool {
+	if store == nil {
+		return false
+	}
+	value, ok, err := store.Get(ctx, key)
+	if err != nil {
+		log.Printf("WARNING: cache: reading %s failed: %v", key, err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		log.Printf("WARNING: cache: decoding %s failed: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// SetJSON stores v encoded as JSON under key. Errors are logged.
+func SetJSON(ctx context.Context, store Store, key string, v interface{}, ttl time.Duration) {
+	if store == nil || ttl <= 0 {
+		return
+	}
+	value, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("WARNING: cache: encoding %s failed: %v", key, err)
+		return
+	}
+	if err := store.Set(ctx, key, value, ttl); err != nil {
+		log.Printf("WARNING: cache: writing %s failed: %v", key, err)
+	}
+}
+
+// Invalidate deletes keys after a write. It runs even when the request was
+// cancelled, since a missed delete serves the old value until the TTL ends.
+func Invalidate(ctx context.Context, store Store, keys ...string) {
+	if store == nil || len(keys) == 0 {
+		return
+	}
+	if err := store.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		log.Printf("ERROR: cache: invalidating %v failed: %v", keys, err)
+	}
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory(2)
+	m.now = func() time.Time { return now }
+
+	m.Set(ctx, "a", []byte("1"), time.Minute)
+	m.Set(ctx, "b", []byte("2"), time.Second)
+	if value, ok, _ := m.Get(ctx, "a"); !ok || string(value) != "1" {
+		t.Fatalf("Get(a) = %q, %v", value, ok)
+	}
+
+	// Full: a third key is only stored once an entry expired
+	m.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := m.Get(ctx, "c"); ok {
+		t.Error("stored c in a full cache")
+	}
+	now = now.Add(2 * time.Second)
+	if _, ok, _ := m.Get(ctx, "b"); ok {
+		t.Error("expired b was returned")
+	}
+	m.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := m.Get(ctx, "c"); !ok {
+		t.Error("c was not stored after b expired")
+	}
+
+	m.Delete(ctx, "a", "c")
+	if m.Len() != 0 {
+		t.Errorf("Len() = %d after deleting every entry", m.Len())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	ctx := context.Background()
+	type value struct {
+		Name string `json:"name"`
+	}
+
+	var got value
+	if GetJSON(ctx, nil, "k", &got) {
+		t.Error("a nil store returned a value")
+	}
+	SetJSON(ctx, nil, "k", value{Name: "x"}, time.Minute)
+	Invalidate(ctx, nil, "k")
+
+	m := NewMemory(10)
+	SetJSON(ctx, m, "k", value{Name: "x"}, 0)
+	if m.Len() != 0 {
+		t.Error("a zero TTL stored a value")
+	}
+	SetJSON(ctx, m, "k", value{Name: "x"}, time.Minute)
+	if !GetJSON(ctx, m, "k", &got) || got.Name != "x" {
+		t.Errorf("GetJSON = %+v", got)
+	}
+	m.Set(ctx, "bad", []byte("{"), time.Minute)
+	if GetJSON(ctx, m, "bad", &got) {
+		t.Error("an undecodable value counted as a hit")
+	}
+	Invalidate(ctx, m, "k")
+	if GetJSON(ctx, m, "k", &got) {
+		t.Error("an invalidated value was returned")
+	}
+}
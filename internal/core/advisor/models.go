@@ -0,0 +1,82 @@
+package advisor
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBlueprintNotFound = errors.New("blueprint not found")
+	ErrNotIndexable      = errors.New("property cannot be indexed")
+	ErrAlreadyIndexed    = errors.New("property is already indexed")
+	ErrIndexLimit        = errors.New("blueprint has the maximum number of indexed properties")
+)
+
+// Recommendation is a property searches filter or sort on often enough, in a
+// blueprint large enough, that an index on it would pay off
+type Recommendation struct {
+	TeamID      uuid.UUID `json:"team_id"`
+	BlueprintID string    `json:"blueprint_id"`
+	Property    string    `json:"property"`
+	Type        string    `json:"type"`
+	Filters     int64     `json:"filters"`
+	Sorts       int64     `json:"sorts"`
+	LastUsed    time.Time `json:"last_used"`
+	Entities    int64     `json:"entities"`
+	// IndexName is the name of the partial index the property would get
+	IndexName string `json:"index_name"`
+}
+
+func (r *Recommendation) uses() int64 {
+	return r.Filters + r.Sorts
+}
+
+type RecommendationsResponse struct {
+	Recommendations []*Recommendation `json:"recommendations"`
+	// Days is the window of the usage counts
+	Days        int  `json:"days"`
+	MinUses     int  `json:"min_uses"`
+	MinEntities int  `json:"min_entities"`
+	AutoApply   bool `json:"auto_apply"`
+	// UsageTracking is false when property usage is not counted, so there
+	// is nothing to recommend from
+	UsageTracking bool      `json:"usage_tracking"`
+	GeneratedAt   time.Time `json:"generated_at"`
+}
+
+// ApplyRequest marks one property of a blueprint indexed
+type ApplyRequest struct {
+	TeamID      uuid.UUID `json:"team_id" binding:"required"`
+	BlueprintID string    `json:"blueprint_id" binding:"required"`
+	Property    string    `json:"property" binding:"required"`
+}
+
+// ApplyResult is a property marked indexed. The index is built in the
+// background by index maintenance.
+type ApplyResult struct {
+	TeamID            uuid.UUID `json:"team_id"`
+	BlueprintID       string    `json:"blueprint_id"`
+	Property          string    `json:"property"`
+	IndexName         string    `json:"index_name"`
+	IndexedProperties []string  `json:"indexed_properties"`
+}
+
+// AdviseResult is the outcome of a scheduled advisor run
+type AdviseResult struct {
+	Recommended int `json:"recommended"`
+	Applied     int `json:"applied"`
+}
+
+// candidate is the usage of one property with its blueprint
+type candidate struct {
+	TeamID      uuid.UUID
+	BlueprintID string
+	Property    string
+	Filters     int64
+	Sorts       int64
+	LastUsed    time.Time
+	Entities    int64
+	Schema      map[string]interface{}
+}
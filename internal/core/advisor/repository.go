@@ -0,0 +1,86 @@
+package advisor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+// Candidates returns the properties filtered and sorted on at least minUses
+// times since the given day, per existing blueprint, with the blueprint's
+// entity count and schema. teamID narrows them to one team.
+func (r *Repository) Candidates(ctx context.Context, since time.Time, minUses int, teamID *uuid.UUID) ([]*candidate, error) {
+	args := []interface{}{since.Format(time.DateOnly), minUses}
+	teamFilter := ""
+	if teamID != nil {
+		args = append(args, *teamID)
+		teamFilter = fmt.Sprintf(" AND team_id = $%d", len(args))
+	}
+
+	query := `
+		WITH used AS (
+			SELECT team_id, blueprint_id, property,
+				COALESCE(SUM(count) FILTER (WHERE usage = 'filter'), 0) AS filters,
+				COALESCE(SUM(count) FILTER (WHERE usage = 'sort'), 0) AS sorts,
+				MAX(day) AS last_used
+			FROM property_usage
+			WHERE day >= $1 AND usage IN ('filter', 'sort')` + teamFilter + `
+			GROUP BY team_id, blueprint_id, property
+			HAVING SUM(count) >= $2
+		), sizes AS (
+			SELECT e.team_id, e.blueprint_id, COUNT(*) AS entities
+			FROM entities e
+			JOIN (SELECT DISTINCT team_id, blueprint_id FROM used) b
+				ON b.team_id = e.team_id AND b.blueprint_id = e.blueprint_id
+			GROUP BY e.team_id, e.blueprint_id
+		)
+		SELECT u.team_id, u.blueprint_id, u.property, u.filters, u.sorts, u.last_used,
+			COALESCE(s.entities, 0), bp.schema
+		FROM used u
+		JOIN blueprints bp ON bp.id = u.blueprint_id AND bp.team_id = u.team_id
+		LEFT JOIN sizes s ON s.team_id = u.team_id AND s.blueprint_id = u.blueprint_id
+		ORDER BY u.filters + u.sorts DESC, u.team_id, u.blueprint_id, u.property`
+
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	// Each blueprint's schema is decoded once
+	schemas := make(map[string]map[string]interface{})
+	var candidates []*candidate
+	for rows.Next() {
+		c := &candidate{}
+		var schema []byte
+		if err := rows.Scan(&c.TeamID, &c.BlueprintID, &c.Property, &c.Filters, &c.Sorts, &c.LastUsed, &c.Entities, &schema); err != nil {
+			return nil, err
+		}
+		key := c.TeamID.String() + "/" + c.BlueprintID
+		if _, ok := schemas[key]; !ok {
+			var decoded map[string]interface{}
+			if err := json.Unmarshal(schema, &decoded); err != nil {
+				return nil, err
+			}
+			schemas[key] = decoded
+		}
+		c.Schema = schemas[key]
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
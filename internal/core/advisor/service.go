@@ -0,0 +1,234 @@
+// Package advisor recommends JSONB indexes from search telemetry: properties
+// that searches filter and sort on often, in blueprints large enough for an
+// index to matter. Applying a recommendation marks the property indexed in
+// its blueprint schema, so index maintenance builds the partial index like
+// for any other indexed property.
+package advisor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+// adviceDays is the window of the usage counts recommendations are based
+// on, shortened to the usage retention
+const adviceDays = 30
+
+// indexableTypes are the property types whose values an expression index
+// on the property serves equality filters and ordering for
+var indexableTypes = []string{"string", "number", "integer", "boolean"}
+
+type Service struct {
+	repo         *Repository
+	blueprintSvc *blueprint.Service
+	cfg          config.SearchConfig
+	now          func() time.Time
+}
+
+func NewService(repo *Repository, blueprintSvc *blueprint.Service, cfg config.SearchConfig) *Service {
+	return &Service{repo: repo, blueprintSvc: blueprintSvc, cfg: cfg, now: time.Now}
+}
+
+// Recommendations lists the properties worth indexing, most used first, of
+// all teams or of one
+func (s *Service) Recommendations(ctx context.Context, teamID *uuid.UUID) (*RecommendationsResponse, error) {
+	resp := &RecommendationsResponse{
+		Recommendations: []*Recommendation{},
+		Days:            s.days(),
+		MinUses:         s.cfg.IndexAdvisorMinUses,
+		MinEntities:     s.cfg.IndexAdvisorMinEntities,
+		AutoApply:       s.cfg.IndexAdvisorAutoApply,
+		UsageTracking:   s.cfg.UsageRetentionDays > 0,
+		GeneratedAt:     s.now().UTC(),
+	}
+	if !resp.UsageTracking {
+		return resp, nil
+	}
+
+	since := s.now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-resp.Days)
+	candidates, err := s.repo.Candidates(ctx, since, s.cfg.IndexAdvisorMinUses, teamID)
+	if err != nil {
+		return nil, err
+	}
+	resp.Recommendations = recommend(candidates, int64(s.cfg.IndexAdvisorMinEntities))
+	return resp, nil
+}
+
+// Apply marks a property indexed in its blueprint schema. Index maintenance
+// then builds its partial index concurrently.
+func (s *Service) Apply(ctx context.Context, req *ApplyRequest) (*ApplyResult, error) {
+	bp, err := s.blueprintSvc.Get(ctx, req.TeamID, req.BlueprintID)
+	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
+		return nil, err
+	}
+
+	schema, err := markIndexed(bp.Schema, req.Property)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.blueprintSvc.Update(ctx, req.TeamID, req.BlueprintID, &blueprint.UpdateBlueprintRequest{Schema: schema})
+	if err != nil {
+		return nil, err
+	}
+	return &ApplyResult{
+		TeamID:            req.TeamID,
+		BlueprintID:       req.BlueprintID,
+		Property:          req.Property,
+		IndexName:         blueprint.PropertyIndexName(req.TeamID, req.BlueprintID, req.Property),
+		IndexedProperties: blueprint.IndexedProperties(updated.Schema),
+	}, nil
+}
+
+// Advise computes the recommendations of all teams and, when auto-apply is
+// configured, applies them. A recommendation that no longer applies, e.g.
+// because its blueprint changed since, is skipped.
+func (s *Service) Advise(ctx context.Context) (*AdviseResult, error) {
+	resp, err := s.Recommendations(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := &AdviseResult{Recommended: len(resp.Recommendations)}
+	if !s.cfg.IndexAdvisorAutoApply {
+		return result, nil
+	}
+	for _, rec := range resp.Recommendations {
+		_, err := s.Apply(ctx, &ApplyRequest{TeamID: rec.TeamID, BlueprintID: rec.BlueprintID, Property: rec.Property})
+		switch {
+		case err == nil:
+			log.Printf("index advisor: marked %s of blueprint %s (team %s) indexed", rec.Property, rec.BlueprintID, rec.TeamID)
+			result.Applied++
+		case errors.Is(err, ErrBlueprintNotFound), errors.Is(err, ErrNotIndexable),
+			errors.Is(err, ErrAlreadyIndexed), errors.Is(err, ErrIndexLimit):
+		default:
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func (s *Service) days() int {
+	return max(1, min(adviceDays, s.cfg.UsageRetentionDays))
+}
+
+// recommend keeps the candidates that are indexable, not indexed yet and in
+// blueprints of at least minEntities entities. Each blueprint gets at most
+// as many recommendations as it has indexes left, for its most used
+// properties.
+func recommend(candidates []*candidate, minEntities int64) []*Recommendation {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Filters+candidates[i].Sorts > candidates[j].Filters+candidates[j].Sorts
+	})
+
+	slots := make(map[string]int)
+	recommendations := []*Recommendation{}
+	for _, c := range candidates {
+		if c.Entities < minEntities {
+			continue
+		}
+		key := c.TeamID.String() + "/" + c.BlueprintID
+		indexed := blueprint.IndexedProperties(c.Schema)
+		if _, ok := slots[key]; !ok {
+			slots[key] = blueprint.MaxIndexedProperties - len(indexed)
+		}
+		if slots[key] <= 0 || slices.Contains(indexed, c.Property) {
+			continue
+		}
+		prop := schemaProperty(c.Schema, c.Property)
+		if prop == nil || !indexable(prop) {
+			continue
+		}
+		slots[key]--
+		recommendations = append(recommendations, &Recommendation{
+			TeamID:      c.TeamID,
+			BlueprintID: c.BlueprintID,
+			Property:    c.Property,
+			Type:        propertyType(prop),
+			Filters:     c.Filters,
+			Sorts:       c.Sorts,
+			LastUsed:    c.LastUsed,
+			Entities:    c.Entities,
+			IndexName:   blueprint.PropertyIndexName(c.TeamID, c.BlueprintID, c.Property),
+		})
+	}
+	return recommendations
+}
+
+// markIndexed returns a copy of schema with the property at path marked
+// indexed
+func markIndexed(schema map[string]interface{}, path string) (map[string]interface{}, error) {
+	indexed := blueprint.IndexedProperties(schema)
+	if slices.Contains(indexed, path) {
+		return nil, ErrAlreadyIndexed
+	}
+	if len(indexed) >= blueprint.MaxIndexedProperties {
+		return nil, ErrIndexLimit
+	}
+
+	raw, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	var marked map[string]interface{}
+	if err := json.Unmarshal(raw, &marked); err != nil {
+		return nil, err
+	}
+	prop := schemaProperty(marked, path)
+	if prop == nil || !indexable(prop) {
+		return nil, ErrNotIndexable
+	}
+	prop["indexed"] = true
+	// Paths with segments index maintenance does not accept stay unindexed
+	if !slices.Contains(blueprint.IndexedProperties(marked), path) {
+		return nil, ErrNotIndexable
+	}
+	return marked, nil
+}
+
+// schemaProperty returns the schema of the property at a dot-separated path,
+// or nil when the schema does not declare it
+func schemaProperty(schema map[string]interface{}, path string) map[string]interface{} {
+	current := schema
+	for _, segment := range strings.Split(path, ".") {
+		props, _ := current["properties"].(map[string]interface{})
+		prop, ok := props[segment].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = prop
+	}
+	return current
+}
+
+// propertyType returns the type of a property, the non-null one of nullable
+// types
+func propertyType(prop map[string]interface{}) string {
+	switch t := prop["type"].(type) {
+	case string:
+		return t
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func indexable(prop map[string]interface{}) bool {
+	return slices.Contains(indexableTypes, propertyType(prop))
+}
@@ -0,0 +1,121 @@
+package advisor
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+func testSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"tier":   map[string]interface{}{"type": "integer", "indexed": true},
+			"owner":  map[string]interface{}{"type": "string"},
+			"region": map[string]interface{}{"type": []interface{}{"string", "null"}},
+			"tags":   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"cost center": map[string]interface{}{"type": "string"},
+					"team":        map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	teamID := uuid.New()
+	schema := testSchema()
+	used := func(property string, filters, entities int64) *candidate {
+		return &candidate{TeamID: teamID, BlueprintID: "service", Property: property, Filters: filters, Entities: entities, Schema: schema}
+	}
+
+	got := recommend([]*candidate{
+		used("owner", 150, 5000),
+		used("tier", 900, 5000),          // already indexed
+		used("tags", 400, 5000),          // array
+		used("unknown", 300, 5000),       // not in the schema
+		used("metadata.team", 200, 5000), // nested
+		used("region", 120, 5000),        // nullable string
+	}, 1000)
+
+	var properties []string
+	for _, rec := range got {
+		properties = append(properties, rec.Property)
+	}
+	if want := []string{"metadata.team", "owner", "region"}; !slices.Equal(properties, want) {
+		t.Fatalf("recommended %v, want %v", properties, want)
+	}
+	if got[2].Type != "string" {
+		t.Errorf("region type = %q, want string", got[2].Type)
+	}
+	if got[1].IndexName != blueprint.PropertyIndexName(teamID, "service", "owner") {
+		t.Errorf("index name = %q", got[1].IndexName)
+	}
+
+	if got := recommend([]*candidate{used("owner", 150, 999)}, 1000); len(got) != 0 {
+		t.Errorf("small blueprint got recommendations: %v", got)
+	}
+}
+
+func TestRecommend_LimitsToFreeIndexes(t *testing.T) {
+	teamID := uuid.New()
+	properties := map[string]interface{}{}
+	for i := 0; i < blueprint.MaxIndexedProperties-1; i++ {
+		properties[fmt.Sprintf("indexed%d", i)] = map[string]interface{}{"type": "string", "indexed": true}
+	}
+	properties["a"] = map[string]interface{}{"type": "string"}
+	properties["b"] = map[string]interface{}{"type": "string"}
+	schema := map[string]interface{}{"properties": properties}
+
+	got := recommend([]*candidate{
+		{TeamID: teamID, BlueprintID: "service", Property: "a", Filters: 200, Entities: 5000, Schema: schema},
+		{TeamID: teamID, BlueprintID: "service", Property: "b", Filters: 300, Entities: 5000, Schema: schema},
+	}, 1000)
+	if len(got) != 1 || got[0].Property != "b" {
+		t.Errorf("recommended %v, want only the most used b", got)
+	}
+}
+
+func TestMarkIndexed(t *testing.T) {
+	schema := testSchema()
+
+	marked, err := markIndexed(schema, "metadata.team")
+	if err != nil {
+		t.Fatalf("markIndexed: %v", err)
+	}
+	if got := blueprint.IndexedProperties(marked); !slices.Equal(got, []string{"metadata.team", "tier"}) {
+		t.Errorf("indexed properties = %v", got)
+	}
+	if got := blueprint.IndexedProperties(schema); !slices.Equal(got, []string{"tier"}) {
+		t.Errorf("original schema changed: %v", got)
+	}
+
+	tests := map[string]error{
+		"tier":                 ErrAlreadyIndexed,
+		"tags":                 ErrNotIndexable,
+		"missing":              ErrNotIndexable,
+		"metadata":             ErrNotIndexable,
+		"metadata.cost center": ErrNotIndexable,
+	}
+	for path, want := range tests {
+		if _, err := markIndexed(schema, path); !errors.Is(err, want) {
+			t.Errorf("markIndexed(%q) = %v, want %v", path, err, want)
+		}
+	}
+
+	full := map[string]interface{}{"properties": map[string]interface{}{"extra": map[string]interface{}{"type": "string"}}}
+	for i := 0; i < blueprint.MaxIndexedProperties; i++ {
+		full["properties"].(map[string]interface{})[fmt.Sprintf("p%d", i)] = map[string]interface{}{"type": "string", "indexed": true}
+	}
+	if _, err := markIndexed(full, "extra"); !errors.Is(err, ErrIndexLimit) {
+		t.Errorf("markIndexed on a full blueprint = %v, want ErrIndexLimit", err)
+	}
+}
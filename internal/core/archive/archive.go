@@ -0,0 +1,65 @@
+// Package archive writes a team's whole catalog as one zip file, for keeping
+// a copy of a team before it is deleted.
+package archive
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/bundle"
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+// BundleFile is the name of the blueprint bundle in an archive; each
+// blueprint's entities are in entities/<blueprint>.ndjson
+const BundleFile = "bundle.json"
+
+type Service struct {
+	bundles  *bundle.Service
+	entities *entity.Service
+}
+
+func NewService(bundles *bundle.Service, entities *entity.Service) *Service {
+	return &Service{bundles: bundles, entities: entities}
+}
+
+// Write writes the team's archive to w: its blueprint bundle, which
+// the blueprint import accepts, and every blueprint's entities as NDJSON,
+// which the entity import accepts
+func (s *Service) Write(ctx context.Context, teamID uuid.UUID, w io.Writer) error {
+	b, err := s.bundles.Export(ctx, teamID, nil)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	f, err := zw.Create(BundleFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+
+	for _, bp := range b.Blueprints {
+		write, err := s.entities.Export(ctx, teamID, bp.ID, entity.FormatNDJSON)
+		if err != nil {
+			return fmt.Errorf("export entities of %s: %w", bp.ID, err)
+		}
+		f, err := zw.Create("entities/" + bp.ID + ".ndjson")
+		if err != nil {
+			return err
+		}
+		if err := write(f); err != nil {
+			return fmt.Errorf("export entities of %s: %w", bp.ID, err)
+		}
+	}
+	return zw.Close()
+}
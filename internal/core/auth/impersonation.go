@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidImpersonation    = errors.New("invalid impersonation request")
+	ErrImpersonationNotAllowed = errors.New("user cannot be impersonated")
+	ErrImpersonationEnded      = errors.New("impersonation session has ended")
+)
+
+// Impersonate mints a token that acts as the target user for at most the
+// configured impersonation time. The token carries the target's memberships,
+// never super admin rights, and is marked with the admin and the session so
+// every request made with it is audited and can be revoked. twoFactor is
+// whether the admin's own session was started with a second factor.
+func (s *Service) Impersonate(ctx context.Context, actorID, targetUserID uuid.UUID, req *ImpersonateRequest, twoFactor bool, ipAddress, userAgent *string) (*ImpersonateResponse, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, ErrInvalidImpersonation
+	}
+	ttl := s.config.ImpersonationTTL()
+	if req.DurationMinutes < 0 || time.Duration(req.DurationMinutes)*time.Minute > ttl {
+		return nil, ErrInvalidImpersonation
+	}
+	if req.DurationMinutes > 0 {
+		ttl = time.Duration(req.DurationMinutes) * time.Minute
+	}
+	if actorID == targetUserID {
+		return nil, ErrSelfModification
+	}
+
+	actor, err := s.repo.GetUserByID(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil {
+		return nil, ErrUnauthorized
+	}
+	target, err := s.repo.GetUserByID(ctx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if target == nil || target.Status == UserStatusDeleted {
+		return nil, ErrNotFound
+	}
+	if target.IsSuperAdmin {
+		return nil, ErrIsSuperAdmin
+	}
+	if target.Status != UserStatusActive {
+		return nil, ErrImpersonationNotAllowed
+	}
+
+	memberships, err := s.membershipClaims(ctx, target.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	session := &ImpersonationSession{
+		ID:           uuid.New(),
+		AdminUserID:  actor.ID,
+		TargetUserID: target.ID,
+		Reason:       reason,
+		ExpiresAt:    time.Now().Add(ttl).UTC().Truncate(time.Second),
+	}
+	if err := s.repo.CreateImpersonationSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	if memberships != nil && memberships.ValidUntil > session.ExpiresAt.Unix() {
+		memberships.ValidUntil = session.ExpiresAt.Unix()
+	}
+	isSuperAdmin := false
+	token, err := s.signToken(&JWTClaims{
+		UserID:       target.ID,
+		Email:        target.Email,
+		IsSuperAdmin: &isSuperAdmin,
+		Memberships:  memberships,
+		TwoFactor:    twoFactor,
+		Impersonation: &ImpersonationClaims{
+			SessionID:  session.ID,
+			ActorID:    actor.ID,
+			ActorEmail: actor.Email,
+		},
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        session.ID.String(),
+			ExpiresAt: jwt.NewNumericDate(session.ExpiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.auditAdmin(actorID, targetUserID, "impersonate", nil, map[string]any{
+		"session_id": session.ID,
+		"reason":     reason,
+		"expires_at": session.ExpiresAt,
+	}, ipAddress, userAgent)
+	return &ImpersonateResponse{Token: token, Session: session}, nil
+}
+
+// CheckImpersonation rejects impersonation tokens whose session was revoked
+// or has expired, or whose admin has since lost super admin rights. Sessions
+// are looked up on every request so revocation takes effect at once.
+func (s *Service) CheckImpersonation(ctx context.Context, claims *ImpersonationClaims) error {
+	valid, err := s.repo.ImpersonationSessionValid(ctx, claims.SessionID)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrImpersonationEnded
+	}
+	return nil
+}
+
+// RevokeImpersonation ends an impersonation session; tokens minted for it
+// are rejected from the next request on
+func (s *Service) RevokeImpersonation(ctx context.Context, actorID, sessionID uuid.UUID, ipAddress, userAgent *string) (*ImpersonationSession, error) {
+	session, err := s.repo.RevokeImpersonationSession(ctx, sessionID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		existing, err := s.repo.GetImpersonationSession(ctx, sessionID)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, ErrNotFound
+		}
+		return nil, ErrImpersonationEnded
+	}
+
+	s.auditAdmin(actorID, session.TargetUserID, "revoke_impersonation", nil, map[string]any{
+		"session_id":    session.ID,
+		"admin_user_id": session.AdminUserID,
+	}, ipAddress, userAgent)
+	return session, nil
+}
+
+// ListImpersonations returns impersonation sessions newest first with the
+// admin and target users
+func (s *Service) ListImpersonations(ctx context.Context, activeOnly bool, limit, offset int) ([]*ImpersonationSession, error) {
+	sessions, err := s.repo.ListImpersonationSessions(ctx, activeOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	var userIDs []uuid.UUID
+	for _, session := range sessions {
+		userIDs = append(userIDs, session.AdminUserID, session.TargetUserID)
+	}
+	users, err := s.repo.GetUserSummaries(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Admin = users[session.AdminUserID]
+		session.Target = users[session.TargetUserID]
+	}
+	return sessions, nil
+}
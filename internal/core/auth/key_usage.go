@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// KeyUsage collects when API keys were last used and writes them to
+// api_keys.last_used_at in one statement per interval, so validating a key
+// never writes to the database. A nil *KeyUsage records nothing.
+type KeyUsage struct {
+	repo     *Repository
+	interval time.Duration
+
+	mu   sync.Mutex
+	used map[uuid.UUID]time.Time
+}
+
+func NewKeyUsage(repo *Repository, interval time.Duration) *KeyUsage {
+	return &KeyUsage{repo: repo, interval: interval, used: make(map[uuid.UUID]time.Time)}
+}
+
+// Touch records a use of the key at the given time
+func (u *KeyUsage) Touch(id uuid.UUID, at time.Time) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if at.After(u.used[id]) {
+		u.used[id] = at
+	}
+}
+
+// Run writes the recorded uses every interval until ctx is done. Uses
+// recorded since the last write are written before it returns.
+func (u *KeyUsage) Run(ctx context.Context) {
+	ticker := time.NewTicker(u.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// The run context is gone; give the final write its own
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := u.flush(final); err != nil {
+				log.Printf("ERROR: api key usage write failed: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			if err := u.flush(ctx); err != nil {
+				log.Printf("ERROR: api key usage write failed: %v", err)
+			}
+		}
+	}
+}
+
+// flush writes the recorded uses. On failure they are kept for the next attempt.
+func (u *KeyUsage) flush(ctx context.Context) error {
+	u.mu.Lock()
+	used := u.used
+	u.used = make(map[uuid.UUID]time.Time)
+	u.mu.Unlock()
+	if len(used) == 0 {
+		return nil
+	}
+
+	if err := u.repo.UpdateAPIKeysLastUsed(ctx, used); err != nil {
+		u.mu.Lock()
+		for id, at := range used {
+			if at.After(u.used[id]) {
+				u.used[id] = at
+			}
+		}
+		u.mu.Unlock()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestKeyUsage_TouchKeepsLatest(t *testing.T) {
+	u := NewKeyUsage(nil, time.Minute)
+	id := uuid.New()
+	first := time.Unix(100, 0)
+
+	u.Touch(id, first)
+	u.Touch(id, first.Add(-time.Second))
+	if got := u.used[id]; !got.Equal(first) {
+		t.Errorf("last use = %v, want %v", got, first)
+	}
+	u.Touch(id, first.Add(time.Second))
+	if got := u.used[id]; !got.Equal(first.Add(time.Second)) {
+		t.Errorf("last use = %v, want the later use", got)
+	}
+
+	var nilUsage *KeyUsage
+	nilUsage.Touch(id, first)
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/cache"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+// LookupCache keeps API keys by hash and roles by id in a cache store, so
+// that authenticating an API key and resolving a member's role skip the
+// database. Deleting a key and changing or deleting a role drop the entry
+// after the write commits. A nil *LookupCache caches nothing.
+type LookupCache struct {
+	store     cache.Store
+	apiKeyTTL time.Duration
+	roleTTL   time.Duration
+}
+
+// NewLookupCache returns nil when store is nil; a zero TTL stops caching that lookup
+func NewLookupCache(store cache.Store, apiKeyTTL, roleTTL time.Duration) *LookupCache {
+	if store == nil {
+		return nil
+	}
+	return &LookupCache{store: store, apiKeyTTL: apiKeyTTL, roleTTL: roleTTL}
+}
+
+// Subscribe drops cached roles on role events, including those of bundle applies
+func (c *LookupCache) Subscribe(bus *events.Bus) {
+	if c == nil || bus == nil {
+		return
+	}
+	invalidate := func(ctx context.Context, e events.Event) {
+		if role, ok := e.Payload.(*Role); ok && role.ID != uuid.Nil {
+			c.invalidateRole(ctx, role.ID)
+		}
+	}
+	bus.Subscribe(events.RoleUpdated, invalidate)
+	bus.Subscribe(events.RoleDeleted, invalidate)
+}
+
+func apiKeyCacheKey(keyHash string) string { return "apikey:" + keyHash }
+
+func roleCacheKey(id uuid.UUID) string { return "role:" + id.String() }
+
+func (c *LookupCache) apiKey(ctx context.Context, keyHash string) (*APIKey, bool) {
+	if c == nil || c.apiKeyTTL <= 0 {
+		return nil, false
+	}
+	var key APIKey
+	if !cache.GetJSON(ctx, c.store, apiKeyCacheKey(keyHash), &key) {
+		return nil, false
+	}
+	key.KeyHash = keyHash
+	return &key, true
+}
+
+func (c *LookupCache) putAPIKey(ctx context.Context, key *APIKey) {
+	if c == nil {
+		return
+	}
+	cache.SetJSON(ctx, c.store, apiKeyCacheKey(key.KeyHash), key, c.apiKeyTTL)
+}
+
+func (c *LookupCache) invalidateAPIKeys(ctx context.Context, keyHashes []string) {
+	if c == nil || len(keyHashes) == 0 {
+		return
+	}
+	keys := make([]string, len(keyHashes))
+	for i, hash := range keyHashes {
+		keys[i] = apiKeyCacheKey(hash)
+	}
+	cache.Invalidate(ctx, c.store, keys...)
+}
+
+func (c *LookupCache) role(ctx context.Context, id uuid.UUID) (*Role, bool) {
+	if c == nil || c.roleTTL <= 0 {
+		return nil, false
+	}
+	var role Role
+	if !cache.GetJSON(ctx, c.store, roleCacheKey(id), &role) {
+		return nil, false
+	}
+	return &role, true
+}
+
+func (c *LookupCache) putRole(ctx context.Context, role *Role) {
+	if c == nil {
+		return
+	}
+	cache.SetJSON(ctx, c.store, roleCacheKey(role.ID), role, c.roleTTL)
+}
+
+func (c *LookupCache) invalidateRole(ctx context.Context, id uuid.UUID) {
+	if c == nil {
+		return
+	}
+	cache.Invalidate(ctx, c.store, roleCacheKey(id))
+}
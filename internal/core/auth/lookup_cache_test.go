@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/cache"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+func TestLookupCache_APIKeys(t *testing.T) {
+	ctx := context.Background()
+	c := NewLookupCache(cache.NewMemory(10), time.Minute, time.Minute)
+	key := &APIKey{ID: uuid.New(), TeamID: uuid.New(), KeyHash: "abc", Permissions: []string{PermEntityRead}}
+
+	if _, ok := c.apiKey(ctx, "abc"); ok {
+		t.Fatal("empty cache returned a hit")
+	}
+	c.putAPIKey(ctx, key)
+	got, ok := c.apiKey(ctx, "abc")
+	if !ok || got.ID != key.ID || got.KeyHash != "abc" || len(got.Permissions) != 1 {
+		t.Errorf("got %+v, %v; want the cached key with its hash", got, ok)
+	}
+
+	c.invalidateAPIKeys(ctx, []string{"abc"})
+	if _, ok := c.apiKey(ctx, "abc"); ok {
+		t.Error("revoked key returned a hit")
+	}
+}
+
+func TestLookupCache_RoleEvents(t *testing.T) {
+	ctx := context.Background()
+	c := NewLookupCache(cache.NewMemory(10), time.Minute, time.Minute)
+	bus := events.NewBus()
+	c.Subscribe(bus)
+	role := &Role{ID: uuid.New(), TeamID: uuid.New(), Name: "deployer", Permissions: []string{PermEntityRead}}
+
+	c.putRole(ctx, role)
+	if _, ok := c.role(ctx, role.ID); !ok {
+		t.Fatal("role was not cached")
+	}
+	bus.Publish(ctx, events.Event{Type: events.RoleUpdated, TeamID: role.TeamID, Payload: role})
+	if _, ok := c.role(ctx, role.ID); ok {
+		t.Error("updated role returned a hit")
+	}
+}
+
+func TestLookupCache_Nil(t *testing.T) {
+	c := NewLookupCache(nil, time.Minute, time.Minute)
+	ctx := context.Background()
+	c.putAPIKey(ctx, &APIKey{KeyHash: "abc"})
+	if _, ok := c.apiKey(ctx, "abc"); ok {
+		t.Error("nil cache returned a hit")
+	}
+	c.Subscribe(events.NewBus())
+}
@@ -0,0 +1,12 @@
+package auth
+
+import (
+	"context"
+)
+
+// Mailer delivers account emails
+type Mailer interface {
+	// SendEmailVerification sends the token confirming an email change to
+	// the new address
+	SendEmailVerification(ctx context.Context, user *User, email, token string) error
+}
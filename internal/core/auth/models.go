@@ -15,14 +15,28 @@ type User struct {
 	IsSuperAdmin         bool       `json:"is_super_admin"`
 	SuperAdminPromotedAt *time.Time `json:"super_admin_promoted_at,omitempty"`
 	SuperAdminPromotedBy *uuid.UUID `json:"super_admin_promoted_by,omitempty"`
-	CreatedAt            time.Time  `json:"created_at"`
+	// Set for users created with a temporary password until they change it
+	PasswordChangeRequired bool      `json:"password_change_required,omitempty"`
+	TwoFactorEnabled       bool      `json:"two_factor_enabled"`
+	CreatedAt              time.Time `json:"created_at"`
 }
 
+// User statuses. Only active users can log in.
+const (
+	UserStatusActive      = "active"
+	UserStatusInvited     = "invited"
+	UserStatusDeactivated = "deactivated"
+	UserStatusDeleted     = "deleted"
+)
+
 type Team struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	Slug      string    `json:"slug"`
-	CreatedAt time.Time `json:"created_at"`
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+	// RequireTwoFactor makes members with team:manage enroll in two-factor
+	// authentication before they can use the team
+	RequireTwoFactor bool      `json:"require_two_factor"`
+	CreatedAt        time.Time `json:"created_at"`
 }
 
 type Role struct {
@@ -33,12 +47,40 @@ type Role struct {
 	CreatedAt   time.Time `json:"created_at"`
 }
 
+// UpdateRoleRequest changes a role; omitted fields are left unchanged
+type UpdateRoleRequest struct {
+	Name        *string  `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
 type TeamMembership struct {
 	ID        uuid.UUID `json:"id"`
 	TeamID    uuid.UUID `json:"team_id"`
 	UserID    uuid.UUID `json:"user_id"`
 	RoleID    uuid.UUID `json:"role_id"`
 	CreatedAt time.Time `json:"created_at"`
+	// Set by listings that hydrate the referenced records
+	User *UserSummary `json:"user,omitempty"`
+	Team *TeamSummary `json:"team,omitempty"`
+	Role *RoleSummary `json:"role,omitempty"`
+}
+
+// UserSummary, TeamSummary and RoleSummary identify a referenced record in listings
+type UserSummary struct {
+	ID    uuid.UUID `json:"id"`
+	Email string    `json:"email"`
+	Name  string    `json:"name"`
+}
+
+type TeamSummary struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+	Slug string    `json:"slug"`
+}
+
+type RoleSummary struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
 }
 
 type APIKey struct {
@@ -65,14 +107,195 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// AuthResponse carries the token of a completed login. When the user has
+// two-factor authentication enabled, a password login instead returns
+// TwoFactorRequired with a token for the second step.
 type AuthResponse struct {
-	Token string `json:"token"`
-	User  *User  `json:"user"`
+	Token             string `json:"token,omitempty"`
+	User              *User  `json:"user,omitempty"`
+	TwoFactorRequired bool   `json:"two_factor_required,omitempty"`
+	TwoFactorToken    string `json:"two_factor_token,omitempty"`
+}
+
+// TwoFactorLoginRequest completes a login with a code from the authenticator
+// app or one of the backup codes
+type TwoFactorLoginRequest struct {
+	TwoFactorToken string `json:"two_factor_token" binding:"required"`
+	Code           string `json:"code"`
+	BackupCode     string `json:"backup_code"`
+}
+
+// TwoFactorEnrollResponse holds a new TOTP secret. provisioning_uri is the
+// otpauth:// URI to render as a QR code.
+type TwoFactorEnrollResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// TwoFactorCodeRequest proves possession of the authenticator app
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DisableTwoFactorRequest turns two-factor authentication off. code may be
+// an authenticator code or a backup code.
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// BackupCodesResponse carries newly issued backup codes; they are stored
+// hashed and cannot be retrieved again. Token is a fresh token that counts
+// as a two-factor login.
+type BackupCodesResponse struct {
+	BackupCodes []string `json:"backup_codes"`
+	Token       string   `json:"token,omitempty"`
+}
+
+// Credential types reported in TokenInfo
+const (
+	TokenTypeJWT    = "jwt"
+	TokenTypeAPIKey = "api_key"
+)
+
+// TokenInfo describes the credential a request was authenticated with
+type TokenInfo struct {
+	Type      string     `json:"type"`
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// APIKeyID, TeamID and Scopes are set for API keys, which are bound to one
+	// team and limited to their own permissions
+	APIKeyID *uuid.UUID `json:"api_key_id,omitempty"`
+	TeamID   *uuid.UUID `json:"team_id,omitempty"`
+	Scopes   []string   `json:"scopes,omitempty"`
+	// MembershipTeams are the teams embedded in a JWT, trusted until
+	// MembershipsValidUntil; refresh the token to renew them
+	MembershipTeams       []uuid.UUID `json:"membership_teams,omitempty"`
+	MembershipsValidUntil *time.Time  `json:"memberships_valid_until,omitempty"`
+	// TwoFactor is set for JWTs issued after a second factor was checked
+	TwoFactor bool `json:"two_factor,omitempty"`
+	// Impersonation is set when a super admin is acting as the user
+	Impersonation *ImpersonationClaims `json:"impersonation,omitempty"`
+}
+
+// ImpersonationClaims mark a token a super admin minted to act as another
+// user. SessionID identifies the impersonation session that revokes it.
+type ImpersonationClaims struct {
+	SessionID  uuid.UUID `json:"session_id"`
+	ActorID    uuid.UUID `json:"actor_id"`
+	ActorEmail string    `json:"actor_email"`
+}
+
+// ImpersonateRequest starts an impersonation session. The reason is kept in
+// the audit trail; duration_minutes shortens the configured maximum.
+type ImpersonateRequest struct {
+	Reason          string `json:"reason" binding:"required"`
+	DurationMinutes int    `json:"duration_minutes"`
+}
+
+// ImpersonationSession is a super admin's session acting as another user
+type ImpersonationSession struct {
+	ID           uuid.UUID  `json:"id"`
+	AdminUserID  uuid.UUID  `json:"admin_user_id"`
+	TargetUserID uuid.UUID  `json:"target_user_id"`
+	Reason       string     `json:"reason"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy    *uuid.UUID `json:"revoked_by,omitempty"`
+	// Set by listings
+	Admin  *UserSummary `json:"admin,omitempty"`
+	Target *UserSummary `json:"target,omitempty"`
+}
+
+// ImpersonateResponse holds the impersonation token. It is shown once and
+// cannot be refreshed.
+type ImpersonateResponse struct {
+	Token   string                `json:"token"`
+	Session *ImpersonationSession `json:"session"`
+}
+
+// MembershipInfo is one of the user's teams with the role held there
+type MembershipInfo struct {
+	TeamID      uuid.UUID `json:"team_id"`
+	TeamName    string    `json:"team_name"`
+	TeamSlug    string    `json:"team_slug"`
+	RoleID      uuid.UUID `json:"role_id"`
+	RoleName    string    `json:"role_name"`
+	Permissions []string  `json:"permissions"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// WhoAmIResponse is the user with their memberships and the current token.
+// The user fields are absent for API keys that do not belong to a user.
+type WhoAmIResponse struct {
+	*User
+	Memberships []*MembershipInfo `json:"memberships"`
+	Token       *TokenInfo        `json:"token"`
+}
+
+// RefreshTokenRequest optionally selects the teams embedded in the new token
+// UpdateProfileRequest changes the caller's own profile. Changing the email
+// requires the current password and only takes effect once the new address
+// is verified.
+type UpdateProfileRequest struct {
+	Name            *string `json:"name" binding:"omitempty,max=100"`
+	Email           *string `json:"email" binding:"omitempty,email,max=255"`
+	CurrentPassword string  `json:"current_password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ProfileResponse is the caller's profile with any email change awaiting
+// verification
+type ProfileResponse struct {
+	User         *User   `json:"user"`
+	PendingEmail *string `json:"pending_email,omitempty"`
+}
+
+// CreateUserRequest creates a user as a super admin. Without invite the user
+// gets a temporary password that must be changed after the first login.
+type CreateUserRequest struct {
+	Email  string `json:"email" binding:"required,email,max=255"`
+	Name   string `json:"name" binding:"required,max=100"`
+	Invite bool   `json:"invite"`
+}
+
+// CreateUserResponse carries the one-time credential of a created user; it
+// is not stored in clear and cannot be retrieved again
+type CreateUserResponse struct {
+	User              *User      `json:"user"`
+	TemporaryPassword string     `json:"temporary_password,omitempty"`
+	InviteToken       string     `json:"invite_token,omitempty"`
+	InviteExpiresAt   *time.Time `json:"invite_expires_at,omitempty"`
+}
+
+type AcceptInviteRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// DeactivateUserResponse reports what deactivating a user revoked
+type DeactivateUserResponse struct {
+	User           *User `json:"user"`
+	APIKeysRevoked int64 `json:"api_keys_revoked"`
+}
+
+type RefreshTokenRequest struct {
+	TeamIDs []uuid.UUID `json:"team_ids"`
 }
 
 type CreateTeamRequest struct {
-	Name string `json:"name" binding:"required"`
-	Slug string `json:"slug" binding:"required"`
+	Name             string `json:"name" binding:"required"`
+	Slug             string `json:"slug" binding:"required"`
+	RequireTwoFactor *bool  `json:"require_two_factor"`
 }
 
 type InviteMemberRequest struct {
@@ -80,6 +303,10 @@ type InviteMemberRequest struct {
 	RoleID string `json:"role_id" binding:"required"`
 }
 
+type UpdateMemberRequest struct {
+	RoleID string `json:"role_id" binding:"required"`
+}
+
 type CreateAPIKeyRequest struct {
 	Name        string   `json:"name" binding:"required"`
 	Permissions []string `json:"permissions"`
@@ -106,6 +333,27 @@ type AuditLog struct {
 	ResultStatus   *string           `json:"result_status,omitempty"`
 	RequestContext map[string]any    `json:"request_context,omitempty"`
 	CreatedAt      time.Time         `json:"created_at"`
+	// Set by listings: the acting user, the team, and the user acted on
+	User   *UserSummary `json:"user,omitempty"`
+	Team   *TeamSummary `json:"team,omitempty"`
+	Target *UserSummary `json:"target,omitempty"`
+}
+
+// TeamDeletionSummary counts what deleting a team removes
+type TeamDeletionSummary struct {
+	Members    int64 `json:"members"`
+	Roles      int64 `json:"roles"`
+	APIKeys    int64 `json:"api_keys"`
+	Blueprints int64 `json:"blueprints"`
+	Entities   int64 `json:"entities"`
+}
+
+// TeamDeletionResponse is the confirmation token that deleting a team
+// requires, with what the deletion would remove
+type TeamDeletionResponse struct {
+	ConfirmationToken string               `json:"confirmation_token"`
+	ExpiresAt         time.Time            `json:"expires_at"`
+	Summary           *TeamDeletionSummary `json:"summary"`
 }
 
 // Permission constants
@@ -117,6 +365,7 @@ const (
 	PermEntityRead       = "entity:read"
 	PermEntityWrite      = "entity:write"
 	PermEntityDelete     = "entity:delete"
+	PermEntityRestricted = "entity:restricted" // read and write restricted properties
 	PermIntegrationRead  = "integration:read"
 	PermIntegrationWrite = "integration:write"
 	PermScorecardRead    = "scorecard:read"
@@ -129,7 +378,7 @@ const (
 var AllPermissions = []string{
 	PermTeamManage,
 	PermBlueprintRead, PermBlueprintWrite, PermBlueprintDelete,
-	PermEntityRead, PermEntityWrite, PermEntityDelete,
+	PermEntityRead, PermEntityWrite, PermEntityDelete, PermEntityRestricted,
 	PermIntegrationRead, PermIntegrationWrite,
 	PermScorecardRead, PermScorecardWrite,
 	PermActionRead, PermActionWrite, PermActionExecute,
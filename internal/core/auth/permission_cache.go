@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+// PermissionCache keeps the permissions a user holds in a team, and whether
+// the team requires its managers to use 2FA, so that team-scoped requests do
+// not look up the membership, role and team every time. Non-members are
+// cached too. Entries are dropped when memberships, roles or teams change; the TTL bounds staleness for changes made by other instances.
+// A nil *PermissionCache caches nothing.
+//
+// Cached permission slices are shared between callers and must not be modified.
+type PermissionCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu          sync.Mutex
+	entries     map[permissionKey]permissionEntry
+	policies    map[uuid.UUID]policyEntry
+	generations map[uuid.UUID]uint64 // team -> invalidation count
+}
+
+type permissionKey struct {
+	teamID uuid.UUID
+	userID uuid.UUID
+}
+
+type permissionEntry struct {
+	permissions []string
+	member      bool
+	expires     time.Time
+}
+
+type policyEntry struct {
+	requireTwoFactor bool
+	expires          time.Time
+}
+
+func NewPermissionCache(ttl time.Duration, maxEntries int) *PermissionCache {
+	return &PermissionCache{
+		ttl:         ttl,
+		maxEntries:  maxEntries,
+		now:         time.Now,
+		entries:     make(map[permissionKey]permissionEntry),
+		policies:    make(map[uuid.UUID]policyEntry),
+		generations: make(map[uuid.UUID]uint64),
+	}
+}
+
+// Subscribe invalidates cached permissions on membership, role and team events
+func (c *PermissionCache) Subscribe(bus *events.Bus) {
+	if c == nil || bus == nil {
+		return
+	}
+	bus.Subscribe("membership.*", func(ctx context.Context, e events.Event) {
+		if m, ok := e.Payload.(*TeamMembership); ok {
+			c.InvalidateMember(e.TeamID, m.UserID)
+			return
+		}
+		c.InvalidateTeam(e.TeamID)
+	})
+	// A role change affects every member holding the role
+	invalidateTeam := func(ctx context.Context, e events.Event) {
+		c.InvalidateTeam(e.TeamID)
+	}
+	bus.Subscribe(events.RoleUpdated, invalidateTeam)
+	bus.Subscribe(events.RoleDeleted, invalidateTeam)
+	bus.Subscribe(events.TeamUpdated, invalidateTeam)
+	bus.Subscribe(events.TeamDeleted, invalidateTeam)
+}
+
+// Len returns the number of cached entries, including expired ones not yet pruned
+func (c *PermissionCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries) + len(c.policies)
+}
+
+// InvalidateMember drops the cached permissions of one user in a team
+func (c *PermissionCache) InvalidateMember(teamID, userID uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, permissionKey{teamID: teamID, userID: userID})
+	c.generations[teamID]++
+}
+
+// InvalidateTeam drops the cached permissions of every user in a team and
+// the team's 2FA policy
+func (c *PermissionCache) InvalidateTeam(teamID uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.policies, teamID)
+	for key := range c.entries {
+		if key.teamID == teamID {
+			delete(c.entries, key)
+		}
+	}
+	c.generations[teamID]++
+}
+
+// get returns the cached permissions and whether the user is a member. On a
+// miss it returns the team's generation for put.
+func (c *PermissionCache) get(teamID, userID uuid.UUID) (permissions []string, member bool, generation uint64, ok bool) {
+	if c == nil {
+		return nil, false, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.entries[permissionKey{teamID: teamID, userID: userID}]
+	if !found || !c.now().Before(entry.expires) {
+		return nil, false, c.generations[teamID], false
+	}
+	return entry.permissions, entry.member, 0, true
+}
+
+// put stores permissions loaded after a missed get. Nothing is stored when the
+// team changed in the meantime, or when the cache is full and nothing expired.
+func (c *PermissionCache) put(teamID, userID uuid.UUID, generation uint64, permissions []string, member bool) {
+	if c == nil {
+		return
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[teamID] != generation {
+		return
+	}
+	key := permissionKey{teamID: teamID, userID: userID}
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxEntries {
+		for k, entry := range c.entries {
+			if !now.Before(entry.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			return
+		}
+	}
+	c.entries[key] = permissionEntry{permissions: permissions, member: member, expires: now.Add(c.ttl)}
+}
+
+// twoFactorPolicy returns whether the team requires its managers to use 2FA.
+// On a miss it returns the team's generation for putTwoFactorPolicy.
+func (c *PermissionCache) twoFactorPolicy(teamID uuid.UUID) (required bool, generation uint64, ok bool) {
+	if c == nil {
+		return false, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, found := c.policies[teamID]
+	if !found || !c.now().Before(entry.expires) {
+		return false, c.generations[teamID], false
+	}
+	return entry.requireTwoFactor, 0, true
+}
+
+// putTwoFactorPolicy stores a policy loaded after a missed twoFactorPolicy,
+// unless the team changed in the meantime or the cache is full
+func (c *PermissionCache) putTwoFactorPolicy(teamID uuid.UUID, generation uint64, required bool) {
+	if c == nil {
+		return
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[teamID] != generation {
+		return
+	}
+	if _, ok := c.policies[teamID]; !ok && len(c.policies) >= c.maxEntries {
+		for id, entry := range c.policies {
+			if !now.Before(entry.expires) {
+				delete(c.policies, id)
+			}
+		}
+		if len(c.policies) >= c.maxEntries {
+			return
+		}
+	}
+	c.policies[teamID] = policyEntry{requireTwoFactor: required, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+func TestPermissionCache_HitAndExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewPermissionCache(30*time.Second, 10)
+	c.now = func() time.Time { return now }
+	team, user := uuid.New(), uuid.New()
+
+	_, _, generation, ok := c.get(team, user)
+	if ok {
+		t.Fatal("empty cache returned a hit")
+	}
+	c.put(team, user, generation, ViewerPermissions, true)
+
+	if perms, member, _, ok := c.get(team, user); !ok || !member || len(perms) != len(ViewerPermissions) {
+		t.Errorf("got %v, %v, %v; want cached viewer permissions", perms, member, ok)
+	}
+	if _, _, _, ok := c.get(uuid.New(), user); ok {
+		t.Error("different team should miss")
+	}
+
+	outsider := uuid.New()
+	_, _, generation, _ = c.get(team, outsider)
+	c.put(team, outsider, generation, nil, false)
+	if _, member, _, ok := c.get(team, outsider); !ok || member {
+		t.Error("non-members should be cached as such")
+	}
+
+	now = now.Add(30 * time.Second)
+	if _, _, _, ok := c.get(team, user); ok {
+		t.Error("expired entry returned a hit")
+	}
+}
+
+func TestPermissionCache_EventInvalidation(t *testing.T) {
+	c := NewPermissionCache(time.Minute, 10)
+	bus := events.NewBus()
+	c.Subscribe(bus)
+	team, alice, bob := uuid.New(), uuid.New(), uuid.New()
+	ctx := context.Background()
+
+	fill := func() {
+		for _, user := range []uuid.UUID{alice, bob} {
+			_, _, generation, _ := c.get(team, user)
+			c.put(team, user, generation, EditorPermissions, true)
+		}
+	}
+	cached := func(user uuid.UUID) bool {
+		_, _, _, ok := c.get(team, user)
+		return ok
+	}
+
+	fill()
+	bus.Publish(ctx, events.Event{Type: events.MembershipDeleted, TeamID: team, Payload: &TeamMembership{TeamID: team, UserID: alice}})
+	if cached(alice) {
+		t.Error("removed member should be invalidated")
+	}
+	if !cached(bob) {
+		t.Error("other members should stay cached")
+	}
+
+	fill()
+	bus.Publish(ctx, events.Event{Type: events.RoleUpdated, TeamID: team, Payload: &Role{TeamID: team}})
+	if cached(alice) || cached(bob) {
+		t.Error("role changes should invalidate the whole team")
+	}
+
+	fill()
+	bus.Publish(ctx, events.Event{Type: events.TeamUpdated, TeamID: team, Payload: &Team{ID: team}})
+	if cached(alice) || cached(bob) {
+		t.Error("team updates should invalidate the whole team")
+	}
+
+	fill()
+	bus.Publish(ctx, events.Event{Type: events.TeamDeleted, TeamID: team})
+	if cached(alice) || cached(bob) {
+		t.Error("team deletion should invalidate the whole team")
+	}
+}
+
+func TestPermissionCache_TwoFactorPolicy(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewPermissionCache(30*time.Second, 10)
+	c.now = func() time.Time { return now }
+	team := uuid.New()
+
+	_, generation, ok := c.twoFactorPolicy(team)
+	if ok {
+		t.Fatal("empty cache returned a policy")
+	}
+	c.putTwoFactorPolicy(team, generation, true)
+	if required, _, ok := c.twoFactorPolicy(team); !ok || !required {
+		t.Errorf("got %v, %v; want the cached policy", required, ok)
+	}
+
+	c.InvalidateTeam(team)
+	if _, _, ok := c.twoFactorPolicy(team); ok {
+		t.Error("team invalidation should drop the policy")
+	}
+	// A policy loaded across the invalidation is not stored
+	c.putTwoFactorPolicy(team, generation, true)
+	if _, _, ok := c.twoFactorPolicy(team); ok {
+		t.Error("a policy loaded across an invalidation must not be cached")
+	}
+
+	_, generation, _ = c.twoFactorPolicy(team)
+	c.putTwoFactorPolicy(team, generation, false)
+	now = now.Add(30 * time.Second)
+	if _, _, ok := c.twoFactorPolicy(team); ok {
+		t.Error("expired policy returned a hit")
+	}
+}
+
+func TestPermissionCache_SkipsStaleLoads(t *testing.T) {
+	c := NewPermissionCache(time.Minute, 10)
+	team, user := uuid.New(), uuid.New()
+
+	_, _, generation, _ := c.get(team, user)
+	// The role changes while the permissions are being loaded
+	c.InvalidateTeam(team)
+	c.put(team, user, generation, AdminPermissions, true)
+
+	if _, _, _, ok := c.get(team, user); ok {
+		t.Error("permissions loaded across an invalidation must not be cached")
+	}
+}
+
+func TestPermissionCache_Full(t *testing.T) {
+	c := NewPermissionCache(time.Minute, 1)
+	team := uuid.New()
+	first, second := uuid.New(), uuid.New()
+
+	c.put(team, first, 0, ViewerPermissions, true)
+	c.put(team, second, 0, ViewerPermissions, true)
+	if _, _, _, ok := c.get(team, second); ok {
+		t.Error("a full cache should not store new entries")
+	}
+	if _, _, _, ok := c.get(team, first); !ok {
+		t.Error("existing entries should be kept")
+	}
+}
+
+func TestPermissionCache_Nil(t *testing.T) {
+	var c *PermissionCache
+	c.Subscribe(events.NewBus())
+	c.put(uuid.New(), uuid.New(), 0, nil, true)
+	c.InvalidateTeam(uuid.New())
+	if _, _, _, ok := c.get(uuid.New(), uuid.New()); ok {
+		t.Error("nil cache returned a hit")
+	}
+}
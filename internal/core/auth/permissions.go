@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+)
+
+// PermissionCheck asks whether the caller may perform an action on a resource.
+// Resource and action combine into a permission: resource "entity" with action
+// "write" checks "entity:write". Permissions are team-wide, so ResourceID does
+// not change the decision; it is echoed back so callers can match results.
+type PermissionCheck struct {
+	Action     string `json:"action" binding:"required"`
+	Resource   string `json:"resource" binding:"required"`
+	ResourceID string `json:"resource_id,omitempty"`
+}
+
+// BulkPermissionCheckRequest carries 1 to 100 checks
+type BulkPermissionCheckRequest struct {
+	Checks []PermissionCheck `json:"checks" binding:"required,min=1,max=100,dive"`
+}
+
+// PermissionDecision is the answer to one PermissionCheck
+type PermissionDecision struct {
+	PermissionCheck
+	Permission string `json:"permission"`
+	Allowed    bool   `json:"allowed"`
+	Reason     string `json:"reason,omitempty"` // set when denied: "not_granted" or "unknown_permission"
+}
+
+// CheckPermissions decides each check against the caller's granted permissions
+func CheckPermissions(granted []string, checks []PermissionCheck) []PermissionDecision {
+	decisions := make([]PermissionDecision, len(checks))
+	for i, check := range checks {
+		d := PermissionDecision{PermissionCheck: check, Permission: check.Resource + ":" + check.Action}
+		switch {
+		case !slices.Contains(AllPermissions, d.Permission):
+			d.Reason = "unknown_permission"
+		case slices.Contains(granted, d.Permission):
+			d.Allowed = true
+		default:
+			d.Reason = "not_granted"
+		}
+		decisions[i] = d
+	}
+	return decisions
+}
+
+// ValidatePermissions rejects permission strings that are not in AllPermissions
+func ValidatePermissions(permissions []string) error {
+	for _, p := range permissions {
+		if !slices.Contains(AllPermissions, p) {
+			return fmt.Errorf("unknown permission %q", p)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	checks := []PermissionCheck{
+		{Action: "read", Resource: "entity", ResourceID: "aa0e8400-e29b-41d4-a716-446655440008"},
+		{Action: "delete", Resource: "blueprint", ResourceID: "service"},
+		{Action: "fly", Resource: "entity"},
+	}
+
+	decisions := CheckPermissions(ViewerPermissions, checks)
+	if len(decisions) != len(checks) {
+		t.Fatalf("got %d decisions, want %d", len(decisions), len(checks))
+	}
+
+	tests := []struct {
+		permission string
+		allowed    bool
+		reason     string
+	}{
+		{PermEntityRead, true, ""},
+		{PermBlueprintDelete, false, "not_granted"},
+		{"entity:fly", false, "unknown_permission"},
+	}
+	for i, tt := range tests {
+		d := decisions[i]
+		if d.Permission != tt.permission || d.Allowed != tt.allowed || d.Reason != tt.reason {
+			t.Errorf("decision %d = {%s %v %q}, want {%s %v %q}", i, d.Permission, d.Allowed, d.Reason, tt.permission, tt.allowed, tt.reason)
+		}
+		if d.ResourceID != checks[i].ResourceID {
+			t.Errorf("decision %d resource_id = %q, want %q", i, d.ResourceID, checks[i].ResourceID)
+		}
+	}
+}
+
+func TestValidateRole(t *testing.T) {
+	tests := []struct {
+		name        string
+		roleName    string
+		permissions []string
+		valid       bool
+	}{
+		{"known permissions", "deployer", []string{PermEntityRead, PermActionExecute}, true},
+		{"no permissions", "observer", []string{}, true},
+		{"unknown permission", "deployer", []string{PermEntityRead, "entity:admin"}, false},
+		{"empty name", "", []string{PermEntityRead}, false},
+		{"name too long", strings.Repeat("r", maxRoleName+1), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRole(tt.roleName, tt.permissions)
+			if tt.valid && err != nil {
+				t.Errorf("expected valid, got %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidRole) {
+				t.Errorf("expected ErrInvalidRole, got %v", err)
+			}
+		})
+	}
+}
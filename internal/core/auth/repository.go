@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -21,21 +22,21 @@ func NewRepository(db *postgres.Client) *Repository {
 // User methods
 func (r *Repository) CreateUser(ctx context.Context, user *User) error {
 	query := `
-		INSERT INTO users (id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, password_change_required)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING created_at`
 	return r.db.DB.QueryRowContext(ctx, query,
 		user.ID, user.Email, user.PasswordHash, user.Name, user.Status,
-		user.IsSuperAdmin, user.SuperAdminPromotedAt, user.SuperAdminPromotedBy,
+		user.IsSuperAdmin, user.SuperAdminPromotedAt, user.SuperAdminPromotedBy, user.PasswordChangeRequired,
 	).Scan(&user.CreatedAt)
 }
 
 func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, created_at FROM users WHERE email = $1`
+	query := `SELECT id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, password_change_required, totp_enabled, created_at FROM users WHERE email = $1`
 	user := &User{}
 	err := r.db.DB.QueryRowContext(ctx, query, email).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Status,
-		&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.CreatedAt,
+		&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.PasswordChangeRequired, &user.TwoFactorEnabled, &user.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -44,11 +45,11 @@ func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*User, e
 }
 
 func (r *Repository) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
-	query := `SELECT id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, created_at FROM users WHERE id = $1`
+	query := `SELECT id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, password_change_required, totp_enabled, created_at FROM users WHERE id = $1`
 	user := &User{}
 	err := r.db.DB.QueryRowContext(ctx, query, id).Scan(
 		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Status,
-		&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.CreatedAt,
+		&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.PasswordChangeRequired, &user.TwoFactorEnabled, &user.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -60,18 +61,364 @@ func (r *Repository) UpdateUser(ctx context.Context, user *User) error {
 	query := `
 		UPDATE users
 		SET email = $2, password_hash = $3, name = $4, status = $5,
-		    is_super_admin = $6, super_admin_promoted_at = $7, super_admin_promoted_by = $8
+		    is_super_admin = $6, super_admin_promoted_at = $7, super_admin_promoted_by = $8,
+		    password_change_required = $9
 		WHERE id = $1`
 	_, err := r.db.DB.ExecContext(ctx, query,
 		user.ID, user.Email, user.PasswordHash, user.Name, user.Status,
-		user.IsSuperAdmin, user.SuperAdminPromotedAt, user.SuperAdminPromotedBy,
+		user.IsSuperAdmin, user.SuperAdminPromotedAt, user.SuperAdminPromotedBy, user.PasswordChangeRequired,
 	)
 	return err
 }
 
+// UpdateUserName changes a user's display name
+func (r *Repository) UpdateUserName(ctx context.Context, id uuid.UUID, name string) error {
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE users SET name = $2 WHERE id = $1`, id, name)
+	return err
+}
+
+// UpdateUserPassword replaces a user's password hash and clears a required
+// password change
+func (r *Repository) UpdateUserPassword(ctx context.Context, id uuid.UUID, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $2, password_change_required = FALSE WHERE id = $1`
+	_, err := r.db.DB.ExecContext(ctx, query, id, passwordHash)
+	return err
+}
+
+// CreateInvitedUser creates a user without a password who activates the
+// account with the invite token
+func (r *Repository) CreateInvitedUser(ctx context.Context, user *User, tokenHash string, expiresAt time.Time) error {
+	query := `
+		INSERT INTO users (id, email, password_hash, name, status, invite_token_hash, invite_expires_at)
+		VALUES ($1, $2, '', $3, $4, $5, $6)
+		RETURNING created_at`
+	return r.db.DB.QueryRowContext(ctx, query,
+		user.ID, user.Email, user.Name, user.Status, tokenHash, expiresAt,
+	).Scan(&user.CreatedAt)
+}
+
+// AcceptInvite sets the password of the invited user matching an unexpired
+// token and activates the account. It returns nil when no invite matches.
+func (r *Repository) AcceptInvite(ctx context.Context, tokenHash, passwordHash string) (*User, error) {
+	query := `
+		UPDATE users
+		SET password_hash = $2, status = 'active', invite_token_hash = NULL, invite_expires_at = NULL
+		WHERE invite_token_hash = $1 AND invite_expires_at > NOW() AND status = 'invited'
+		RETURNING id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, password_change_required, totp_enabled, created_at`
+	user := &User{}
+	err := r.db.DB.QueryRowContext(ctx, query, tokenHash, passwordHash).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Status,
+		&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.PasswordChangeRequired, &user.TwoFactorEnabled, &user.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// GetSessionState returns what token validation needs to know about a user.
+// found is false for unknown users.
+func (r *Repository) GetSessionState(ctx context.Context, id uuid.UUID) (status string, revokedAt *time.Time, found bool, err error) {
+	query := `SELECT status, sessions_revoked_at FROM users WHERE id = $1`
+	err = r.db.DB.QueryRowContext(ctx, query, id).Scan(&status, &revokedAt)
+	if err == sql.ErrNoRows {
+		return "", nil, false, nil
+	}
+	return status, revokedAt, err == nil, err
+}
+
+// LockUser locks a user row for a status change and returns it, or nil
+func (r *Repository) LockUser(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*User, error) {
+	query := `SELECT id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, password_change_required, totp_enabled, created_at FROM users WHERE id = $1 FOR UPDATE`
+	user := &User{}
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Status,
+		&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.PasswordChangeRequired, &user.TwoFactorEnabled, &user.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// LockUserTeams locks the teams the user belongs to, in id order, and
+// returns their ids
+func (r *Repository) LockUserTeams(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]uuid.UUID, error) {
+	query := `
+		SELECT t.id FROM teams t
+		JOIN team_memberships m ON m.team_id = t.id
+		WHERE m.user_id = $1
+		ORDER BY t.id
+		FOR UPDATE OF t`
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// DeactivateUser marks a user deactivated, revokes their sessions and drops
+// pending invites and email changes
+func (r *Repository) DeactivateUser(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET status = 'deactivated', sessions_revoked_at = NOW(),
+		    invite_token_hash = NULL, invite_expires_at = NULL,
+		    login_challenge_hash = NULL, login_challenge_expires_at = NULL,
+		    pending_email = NULL, email_verification_hash = NULL, email_verification_expires_at = NULL
+		WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+// AnonymizeUser replaces a user's personal data and marks them deleted. The
+// row is kept so audit logs and entity history still resolve.
+func (r *Repository) AnonymizeUser(ctx context.Context, tx *sql.Tx, id uuid.UUID, email, name string) error {
+	query := `
+		UPDATE users
+		SET email = $2, name = $3, password_hash = '', status = 'deleted',
+		    password_change_required = FALSE, sessions_revoked_at = NOW(),
+		    invite_token_hash = NULL, invite_expires_at = NULL,
+		    pending_email = NULL, email_verification_hash = NULL, email_verification_expires_at = NULL
+		WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, id, email, name)
+	return err
+}
+
+// DeleteUserAPIKeys revokes every API key created by the user and returns
+// their hashes
+func (r *Repository) DeleteUserAPIKeys(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	return queryKeyHashes(ctx, tx, `DELETE FROM api_keys WHERE user_id = $1 RETURNING key_hash`, userID)
+}
+
+// TeamAPIKeyHashes returns the hashes of a team's API keys
+func (r *Repository) TeamAPIKeyHashes(ctx context.Context, tx *sql.Tx, teamID uuid.UUID) ([]string, error) {
+	return queryKeyHashes(ctx, tx, `SELECT key_hash FROM api_keys WHERE team_id = $1`, teamID)
+}
+
+// queryKeyHashes runs a query returning API key hashes and collects them
+func queryKeyHashes(ctx context.Context, tx *sql.Tx, query string, id uuid.UUID) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// DeleteUserMemberships removes the user from every team
+func (r *Repository) DeleteUserMemberships(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM team_memberships WHERE user_id = $1`, userID)
+	return err
+}
+
+// GetPendingEmail returns the unconfirmed email change of a user, or nil
+func (r *Repository) GetPendingEmail(ctx context.Context, id uuid.UUID) (*string, error) {
+	var email *string
+	query := `SELECT pending_email FROM users WHERE id = $1 AND email_verification_expires_at > NOW()`
+	err := r.db.DB.QueryRowContext(ctx, query, id).Scan(&email)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return email, err
+}
+
+// SetPendingEmail records an email change awaiting confirmation, replacing
+// any earlier one
+func (r *Repository) SetPendingEmail(ctx context.Context, id uuid.UUID, email, tokenHash string, expiresAt time.Time) error {
+	query := `
+		UPDATE users
+		SET pending_email = $2, email_verification_hash = $3, email_verification_expires_at = $4
+		WHERE id = $1`
+	_, err := r.db.DB.ExecContext(ctx, query, id, email, tokenHash, expiresAt)
+	return err
+}
+
+// ConfirmPendingEmail applies the pending email change matching an unexpired
+// token and returns the user and their previous email. It returns a nil user
+// when no change matches.
+func (r *Repository) ConfirmPendingEmail(ctx context.Context, tokenHash string) (*User, string, error) {
+	query := `
+		UPDATE users u
+		SET email = u.pending_email, pending_email = NULL,
+		    email_verification_hash = NULL, email_verification_expires_at = NULL
+		FROM (SELECT id, email FROM users WHERE email_verification_hash = $1 FOR UPDATE) old
+		WHERE u.id = old.id AND u.email_verification_expires_at > NOW()
+		RETURNING u.id, u.email, u.password_hash, u.name, u.status, u.is_super_admin,
+		          u.super_admin_promoted_at, u.super_admin_promoted_by, u.password_change_required, u.totp_enabled, u.created_at, old.email`
+	user := &User{}
+	var oldEmail string
+	err := r.db.DB.QueryRowContext(ctx, query, tokenHash).Scan(
+		&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Status,
+		&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.PasswordChangeRequired, &user.TwoFactorEnabled, &user.CreatedAt, &oldEmail,
+	)
+	if err == sql.ErrNoRows {
+		return nil, "", nil
+	}
+	if isUniqueViolation(err) {
+		return nil, "", ErrUserExists
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return user, oldEmail, nil
+}
+
+// TwoFactorState is what a second factor is checked against
+type TwoFactorState struct {
+	Secret   *string
+	Enabled  bool
+	LastStep int64
+}
+
+// LockTwoFactorState locks a user row and returns its two-factor state
+func (r *Repository) LockTwoFactorState(ctx context.Context, tx *sql.Tx, id uuid.UUID) (*TwoFactorState, error) {
+	query := `SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = $1 FOR UPDATE`
+	state := &TwoFactorState{}
+	err := tx.QueryRowContext(ctx, query, id).Scan(&state.Secret, &state.Enabled, &state.LastStep)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return state, err
+}
+
+// SetLoginChallenge records the token that completes a password login with
+// a second factor, replacing any earlier one
+func (r *Repository) SetLoginChallenge(ctx context.Context, id uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	query := `
+		UPDATE users
+		SET login_challenge_hash = $2, login_challenge_expires_at = $3, login_challenge_attempts = 0
+		WHERE id = $1`
+	_, err := r.db.DB.ExecContext(ctx, query, id, tokenHash, expiresAt)
+	return err
+}
+
+// LockLoginChallenge locks the user holding an unexpired login challenge and
+// returns their id, or uuid.Nil when none matches
+func (r *Repository) LockLoginChallenge(ctx context.Context, tx *sql.Tx, tokenHash string) (uuid.UUID, error) {
+	query := `SELECT id FROM users WHERE login_challenge_hash = $1 AND login_challenge_expires_at > NOW() FOR UPDATE`
+	var id uuid.UUID
+	err := tx.QueryRowContext(ctx, query, tokenHash).Scan(&id)
+	if err == sql.ErrNoRows {
+		return uuid.Nil, nil
+	}
+	return id, err
+}
+
+// ClearLoginChallenge drops a user's login challenge once it has been used
+func (r *Repository) ClearLoginChallenge(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	query := `UPDATE users SET login_challenge_hash = NULL, login_challenge_expires_at = NULL WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, id)
+	return err
+}
+
+// FailLoginChallenge counts a wrong code against a login challenge and drops
+// the challenge once maxAttempts have failed
+func (r *Repository) FailLoginChallenge(ctx context.Context, tx *sql.Tx, id uuid.UUID, maxAttempts int) error {
+	query := `
+		UPDATE users
+		SET login_challenge_attempts = login_challenge_attempts + 1,
+		    login_challenge_hash = CASE WHEN login_challenge_attempts + 1 >= $2 THEN NULL ELSE login_challenge_hash END
+		WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, id, maxAttempts)
+	return err
+}
+
+// SetTOTPSecret stores a secret awaiting confirmation. It does nothing and
+// returns false when two-factor authentication is already enabled.
+func (r *Repository) SetTOTPSecret(ctx context.Context, id uuid.UUID, secret string) (bool, error) {
+	query := `UPDATE users SET totp_secret = $2, totp_last_step = 0 WHERE id = $1 AND NOT totp_enabled`
+	result, err := r.db.DB.ExecContext(ctx, query, id, secret)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// SetTOTPStep records the last time step accepted from the user
+func (r *Repository) SetTOTPStep(ctx context.Context, tx *sql.Tx, id uuid.UUID, step int64) error {
+	_, err := tx.ExecContext(ctx, `UPDATE users SET totp_last_step = $2 WHERE id = $1`, id, step)
+	return err
+}
+
+// EnableTOTP turns on two-factor authentication with the stored secret
+func (r *Repository) EnableTOTP(ctx context.Context, tx *sql.Tx, id uuid.UUID, step int64) error {
+	query := `UPDATE users SET totp_enabled = TRUE, totp_last_step = $2 WHERE id = $1`
+	_, err := tx.ExecContext(ctx, query, id, step)
+	return err
+}
+
+// DisableTOTP turns off two-factor authentication and removes the secret,
+// backup codes and any pending login challenge
+func (r *Repository) DisableTOTP(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	query := `
+		UPDATE users
+		SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0,
+		    login_challenge_hash = NULL, login_challenge_expires_at = NULL
+		WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM user_backup_codes WHERE user_id = $1`, id)
+	return err
+}
+
+// ReplaceBackupCodes swaps a user's backup codes for a new set
+func (r *Repository) ReplaceBackupCodes(ctx context.Context, tx *sql.Tx, userID uuid.UUID, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_backup_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	query := `INSERT INTO user_backup_codes (user_id, code_hash) SELECT $1, unnest($2::text[])`
+	_, err := tx.ExecContext(ctx, query, userID, codeHashes)
+	return err
+}
+
+// UseBackupCode marks an unused backup code as used and reports whether one
+// matched
+func (r *Repository) UseBackupCode(ctx context.Context, tx *sql.Tx, userID uuid.UUID, codeHash string) (bool, error) {
+	query := `UPDATE user_backup_codes SET used_at = NOW() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+	result, err := tx.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// TeamRequiresTwoFactor reports whether a team requires its managers to use
+// two-factor authentication
+func (r *Repository) TeamRequiresTwoFactor(ctx context.Context, teamID uuid.UUID) (bool, error) {
+	var required bool
+	err := r.db.DB.QueryRowContext(ctx, `SELECT require_two_factor FROM teams WHERE id = $1`, teamID).Scan(&required)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return required, err
+}
+
 func (r *Repository) GetAllUsers(ctx context.Context, limit int, offset int) ([]*User, error) {
 	query := `
-		SELECT id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, created_at
+		SELECT id, email, password_hash, name, status, is_super_admin, super_admin_promoted_at, super_admin_promoted_by, password_change_required, totp_enabled, created_at
 		FROM users
 		ORDER BY created_at DESC
 		LIMIT $1 OFFSET $2`
@@ -85,7 +432,7 @@ func (r *Repository) GetAllUsers(ctx context.Context, limit int, offset int) ([]
 	for rows.Next() {
 		user := &User{}
 		if err := rows.Scan(&user.ID, &user.Email, &user.PasswordHash, &user.Name, &user.Status,
-			&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.CreatedAt); err != nil {
+			&user.IsSuperAdmin, &user.SuperAdminPromotedAt, &user.SuperAdminPromotedBy, &user.PasswordChangeRequired, &user.TwoFactorEnabled, &user.CreatedAt); err != nil {
 			return nil, err
 		}
 		users = append(users, user)
@@ -142,6 +489,13 @@ func (r *Repository) CountSuperAdminsForUpdate(ctx context.Context, tx *sql.Tx)
 	return count, rows.Err()
 }
 
+func (r *Repository) CountSuperAdmins(ctx context.Context) (int, error) {
+	query := `SELECT COUNT(*) FROM users WHERE is_super_admin = true`
+	var count int
+	err := r.db.DB.QueryRowContext(ctx, query).Scan(&count)
+	return count, err
+}
+
 func (r *Repository) UpdateUserSuperAdminStatus(ctx context.Context, tx *sql.Tx, userID uuid.UUID, isSuperAdmin bool, promotedBy *uuid.UUID) error {
 	query := `
 		UPDATE users
@@ -152,6 +506,96 @@ func (r *Repository) UpdateUserSuperAdminStatus(ctx context.Context, tx *sql.Tx,
 	return err
 }
 
+// Impersonation methods
+
+const impersonationColumns = `id, admin_user_id, target_user_id, reason, created_at, expires_at, revoked_at, revoked_by`
+
+func (r *Repository) CreateImpersonationSession(ctx context.Context, session *ImpersonationSession) error {
+	query := `
+		INSERT INTO impersonation_sessions (id, admin_user_id, target_user_id, reason, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`
+	return r.db.DB.QueryRowContext(ctx, query, session.ID, session.AdminUserID, session.TargetUserID,
+		session.Reason, session.ExpiresAt).Scan(&session.CreatedAt)
+}
+
+// GetImpersonationSession returns a session, or nil
+func (r *Repository) GetImpersonationSession(ctx context.Context, id uuid.UUID) (*ImpersonationSession, error) {
+	query := `SELECT ` + impersonationColumns + ` FROM impersonation_sessions WHERE id = $1`
+	session, err := scanImpersonationSession(r.db.DB.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return session, err
+}
+
+// ImpersonationSessionValid reports whether a session is neither revoked nor
+// expired and its admin is still an active super admin who has not revoked
+// their own sessions since it started
+func (r *Repository) ImpersonationSessionValid(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM impersonation_sessions s
+			JOIN users u ON u.id = s.admin_user_id
+			WHERE s.id = $1 AND s.revoked_at IS NULL AND s.expires_at > NOW()
+			AND u.status = 'active' AND u.is_super_admin
+			AND (u.sessions_revoked_at IS NULL OR u.sessions_revoked_at < s.created_at)
+		)`
+	var valid bool
+	err := r.db.DB.QueryRowContext(ctx, query, id).Scan(&valid)
+	return valid, err
+}
+
+// RevokeImpersonationSession ends an active session and returns it, or nil
+// when it has already ended
+func (r *Repository) RevokeImpersonationSession(ctx context.Context, id, revokedBy uuid.UUID) (*ImpersonationSession, error) {
+	query := `
+		UPDATE impersonation_sessions SET revoked_at = NOW(), revoked_by = $2
+		WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		RETURNING ` + impersonationColumns
+	session, err := scanImpersonationSession(r.db.DB.QueryRowContext(ctx, query, id, revokedBy))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return session, err
+}
+
+// ListImpersonationSessions returns sessions newest first, optionally only
+// those still active
+func (r *Repository) ListImpersonationSessions(ctx context.Context, activeOnly bool, limit, offset int) ([]*ImpersonationSession, error) {
+	query := `
+		SELECT ` + impersonationColumns + `
+		FROM impersonation_sessions
+		WHERE NOT $1 OR (revoked_at IS NULL AND expires_at > NOW())
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+	rows, err := r.db.DB.QueryContext(ctx, query, activeOnly, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*ImpersonationSession{}
+	for rows.Next() {
+		session, err := scanImpersonationSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func scanImpersonationSession(row interface{ Scan(...any) error }) (*ImpersonationSession, error) {
+	session := &ImpersonationSession{}
+	err := row.Scan(&session.ID, &session.AdminUserID, &session.TargetUserID, &session.Reason,
+		&session.CreatedAt, &session.ExpiresAt, &session.RevokedAt, &session.RevokedBy)
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
 func (r *Repository) CreateAuditLog(ctx context.Context, log *AuditLog) error {
 	query := `
 		INSERT INTO audit_logs (id, team_id, user_id, actor_type, entity_type, entity_id, action, old_data, new_data, ip_address, user_agent, result_status, request_context)
@@ -244,19 +688,23 @@ func (r *Repository) GetSuperAdminAuditLogs(ctx context.Context, limit int, offs
 // Team methods
 func (r *Repository) CreateTeam(ctx context.Context, team *Team) error {
 	query := `
-		INSERT INTO teams (id, name, slug)
-		VALUES ($1, $2, $3)
+		INSERT INTO teams (id, name, slug, require_two_factor)
+		VALUES ($1, $2, $3, $4)
 		RETURNING created_at`
-	return r.db.DB.QueryRowContext(ctx, query,
-		team.ID, team.Name, team.Slug,
+	err := r.db.DB.QueryRowContext(ctx, query,
+		team.ID, team.Name, team.Slug, team.RequireTwoFactor,
 	).Scan(&team.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrTeamExists
+	}
+	return err
 }
 
 func (r *Repository) GetTeamByID(ctx context.Context, id uuid.UUID) (*Team, error) {
-	query := `SELECT id, name, slug, created_at FROM teams WHERE id = $1`
+	query := `SELECT id, name, slug, require_two_factor, created_at FROM teams WHERE id = $1`
 	team := &Team{}
 	err := r.db.DB.QueryRowContext(ctx, query, id).Scan(
-		&team.ID, &team.Name, &team.Slug, &team.CreatedAt,
+		&team.ID, &team.Name, &team.Slug, &team.RequireTwoFactor, &team.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -265,10 +713,10 @@ func (r *Repository) GetTeamByID(ctx context.Context, id uuid.UUID) (*Team, erro
 }
 
 func (r *Repository) GetTeamBySlug(ctx context.Context, slug string) (*Team, error) {
-	query := `SELECT id, name, slug, created_at FROM teams WHERE slug = $1`
+	query := `SELECT id, name, slug, require_two_factor, created_at FROM teams WHERE slug = $1`
 	team := &Team{}
 	err := r.db.DB.QueryRowContext(ctx, query, slug).Scan(
-		&team.ID, &team.Name, &team.Slug, &team.CreatedAt,
+		&team.ID, &team.Name, &team.Slug, &team.RequireTwoFactor, &team.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -278,7 +726,7 @@ func (r *Repository) GetTeamBySlug(ctx context.Context, slug string) (*Team, err
 
 func (r *Repository) GetTeamsByUserID(ctx context.Context, userID uuid.UUID) ([]*Team, error) {
 	query := `
-		SELECT t.id, t.name, t.slug, t.created_at
+		SELECT t.id, t.name, t.slug, t.require_two_factor, t.created_at
 		FROM teams t
 		INNER JOIN team_memberships tm ON t.id = tm.team_id
 		WHERE tm.user_id = $1
@@ -292,7 +740,7 @@ func (r *Repository) GetTeamsByUserID(ctx context.Context, userID uuid.UUID) ([]
 	var teams []*Team
 	for rows.Next() {
 		team := &Team{}
-		if err := rows.Scan(&team.ID, &team.Name, &team.Slug, &team.CreatedAt); err != nil {
+		if err := rows.Scan(&team.ID, &team.Name, &team.Slug, &team.RequireTwoFactor, &team.CreatedAt); err != nil {
 			return nil, err
 		}
 		teams = append(teams, team)
@@ -301,7 +749,7 @@ func (r *Repository) GetTeamsByUserID(ctx context.Context, userID uuid.UUID) ([]
 }
 
 func (r *Repository) GetAllTeams(ctx context.Context, limit, offset int) ([]*Team, error) {
-	query := `SELECT id, name, slug, created_at FROM teams ORDER BY created_at DESC LIMIT $1 OFFSET $2`
+	query := `SELECT id, name, slug, require_two_factor, created_at FROM teams ORDER BY created_at DESC LIMIT $1 OFFSET $2`
 	rows, err := r.db.DB.QueryContext(ctx, query, limit, offset)
 	if err != nil {
 		return nil, err
@@ -311,7 +759,7 @@ func (r *Repository) GetAllTeams(ctx context.Context, limit, offset int) ([]*Tea
 	var teams []*Team
 	for rows.Next() {
 		team := &Team{}
-		if err := rows.Scan(&team.ID, &team.Name, &team.Slug, &team.CreatedAt); err != nil {
+		if err := rows.Scan(&team.ID, &team.Name, &team.Slug, &team.RequireTwoFactor, &team.CreatedAt); err != nil {
 			return nil, err
 		}
 		teams = append(teams, team)
@@ -320,17 +768,99 @@ func (r *Repository) GetAllTeams(ctx context.Context, limit, offset int) ([]*Tea
 }
 
 func (r *Repository) UpdateTeam(ctx context.Context, team *Team) error {
-	query := `UPDATE teams SET name = $2, slug = $3 WHERE id = $1`
-	_, err := r.db.DB.ExecContext(ctx, query, team.ID, team.Name, team.Slug)
+	query := `UPDATE teams SET name = $2, slug = $3, require_two_factor = $4 WHERE id = $1`
+	_, err := r.db.DB.ExecContext(ctx, query, team.ID, team.Name, team.Slug, team.RequireTwoFactor)
 	return err
 }
 
-func (r *Repository) DeleteTeam(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM teams WHERE id = $1`
-	_, err := r.db.DB.ExecContext(ctx, query, id)
+// SetTeamDeletionToken stores the hash of a team's deletion confirmation
+// token, replacing any earlier one
+func (r *Repository) SetTeamDeletionToken(ctx context.Context, teamID uuid.UUID, tokenHash string, expiresAt time.Time, requestedBy *uuid.UUID) error {
+	query := `
+		UPDATE teams
+		SET deletion_token_hash = $2, deletion_token_expires_at = $3, deletion_requested_by = $4
+		WHERE id = $1`
+	_, err := r.db.DB.ExecContext(ctx, query, teamID, tokenHash, expiresAt, requestedBy)
 	return err
 }
 
+// TeamDeletionTokenValid reports whether a team holds the unexpired deletion
+// token with the given hash
+func (r *Repository) TeamDeletionTokenValid(ctx context.Context, teamID uuid.UUID, tokenHash string) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM teams
+			WHERE id = $1 AND deletion_token_hash = $2 AND deletion_token_expires_at > NOW()
+		)`
+	var ok bool
+	err := r.db.DB.QueryRowContext(ctx, query, teamID, tokenHash).Scan(&ok)
+	return ok, err
+}
+
+// LockTeamForDeletion locks a team holding the unexpired deletion token with
+// the given hash, or returns nil when it does not
+func (r *Repository) LockTeamForDeletion(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, tokenHash string) (*Team, error) {
+	query := `
+		SELECT id, name, slug, require_two_factor, created_at FROM teams
+		WHERE id = $1 AND deletion_token_hash = $2 AND deletion_token_expires_at > NOW()
+		FOR UPDATE`
+	team := &Team{}
+	err := tx.QueryRowContext(ctx, query, teamID, tokenHash).Scan(
+		&team.ID, &team.Name, &team.Slug, &team.RequireTwoFactor, &team.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return team, err
+}
+
+// CountTeamContents counts what deleting a team would remove
+func (r *Repository) CountTeamContents(ctx context.Context, teamID uuid.UUID) (*TeamDeletionSummary, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM team_memberships WHERE team_id = $1),
+			(SELECT COUNT(*) FROM roles WHERE team_id = $1),
+			(SELECT COUNT(*) FROM api_keys WHERE team_id = $1),
+			(SELECT COUNT(*) FROM blueprints WHERE team_id = $1),
+			(SELECT COUNT(*) FROM entities WHERE team_id = $1)`
+	summary := &TeamDeletionSummary{}
+	err := r.db.DB.QueryRowContext(ctx, query, teamID).Scan(
+		&summary.Members, &summary.Roles, &summary.APIKeys, &summary.Blueprints, &summary.Entities,
+	)
+	return summary, err
+}
+
+// DeleteTeamContents deletes a team and everything it owns. Entities go
+// before their blueprints and memberships before their roles, so no step
+// depends on a cascade from a row deleted later; the team's remaining rows
+// (views, integrations, counters) cascade from the team itself.
+func (r *Repository) DeleteTeamContents(ctx context.Context, tx *sql.Tx, teamID uuid.UUID) (*TeamDeletionSummary, error) {
+	summary := &TeamDeletionSummary{}
+	steps := []struct {
+		query string
+		count *int64
+	}{
+		{`DELETE FROM entities WHERE team_id = $1`, &summary.Entities},
+		{`DELETE FROM blueprints WHERE team_id = $1`, &summary.Blueprints},
+		{`DELETE FROM api_keys WHERE team_id = $1`, &summary.APIKeys},
+		{`DELETE FROM team_memberships WHERE team_id = $1`, &summary.Members},
+		{`DELETE FROM roles WHERE team_id = $1`, &summary.Roles},
+		{`DELETE FROM teams WHERE id = $1`, nil},
+	}
+	for _, step := range steps {
+		result, err := tx.ExecContext(ctx, step.query, teamID)
+		if err != nil {
+			return nil, err
+		}
+		if step.count != nil {
+			if *step.count, err = result.RowsAffected(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return summary, nil
+}
+
 // Role methods
 func (r *Repository) CreateRole(ctx context.Context, role *Role) error {
 	permissions, _ := json.Marshal(role.Permissions)
@@ -338,9 +868,13 @@ func (r *Repository) CreateRole(ctx context.Context, role *Role) error {
 		INSERT INTO roles (id, team_id, name, permissions)
 		VALUES ($1, $2, $3, $4)
 		RETURNING created_at`
-	return r.db.DB.QueryRowContext(ctx, query,
+	err := r.db.DB.QueryRowContext(ctx, query,
 		role.ID, role.TeamID, role.Name, permissions,
 	).Scan(&role.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrRoleExists
+	}
+	return err
 }
 
 func (r *Repository) GetRoleByID(ctx context.Context, id uuid.UUID) (*Role, error) {
@@ -400,10 +934,39 @@ func (r *Repository) GetRoleByTeamAndName(ctx context.Context, teamID uuid.UUID,
 	return role, nil
 }
 
-func (r *Repository) UpdateRole(ctx context.Context, role *Role) error {
+func (r *Repository) UpdateRole(ctx context.Context, tx *sql.Tx, role *Role) error {
 	permissions, _ := json.Marshal(role.Permissions)
 	query := `UPDATE roles SET name = $2, permissions = $3 WHERE id = $1`
-	_, err := r.db.DB.ExecContext(ctx, query, role.ID, role.Name, permissions)
+	_, err := tx.ExecContext(ctx, query, role.ID, role.Name, permissions)
+	if isUniqueViolation(err) {
+		return ErrRoleExists
+	}
+	return err
+}
+
+// LockRole locks a role row until the transaction ends. Adding a member with
+// the role takes a key-share lock on it, so no membership can be created for
+// the role while it is held.
+func (r *Repository) LockRole(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	var locked uuid.UUID
+	return tx.QueryRowContext(ctx, `SELECT id FROM roles WHERE id = $1 FOR UPDATE`, id).Scan(&locked)
+}
+
+func (r *Repository) CountRoleMembers(ctx context.Context, tx *sql.Tx, roleID uuid.UUID) (int, error) {
+	var count int
+	err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM team_memberships WHERE role_id = $1`, roleID).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) ReassignRoleMembers(ctx context.Context, tx *sql.Tx, fromRoleID, toRoleID uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, `UPDATE team_memberships SET role_id = $2 WHERE role_id = $1`, fromRoleID, toRoleID)
+	return err
+}
+
+// DeleteRole deletes a role. Memberships cascade, so callers must move or
+// refuse to delete members first.
+func (r *Repository) DeleteRole(ctx context.Context, tx *sql.Tx, id uuid.UUID) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM roles WHERE id = $1`, id)
 	return err
 }
 
@@ -413,9 +976,13 @@ func (r *Repository) CreateMembership(ctx context.Context, membership *TeamMembe
 		INSERT INTO team_memberships (id, team_id, user_id, role_id)
 		VALUES ($1, $2, $3, $4)
 		RETURNING created_at`
-	return r.db.DB.QueryRowContext(ctx, query,
+	err := r.db.DB.QueryRowContext(ctx, query,
 		membership.ID, membership.TeamID, membership.UserID, membership.RoleID,
 	).Scan(&membership.CreatedAt)
+	if isUniqueViolation(err) {
+		return ErrAlreadyMember
+	}
+	return err
 }
 
 func (r *Repository) GetMembership(ctx context.Context, teamID, userID uuid.UUID) (*TeamMembership, error) {
@@ -430,6 +997,36 @@ func (r *Repository) GetMembership(ctx context.Context, teamID, userID uuid.UUID
 	return m, err
 }
 
+// GetMembershipInfo returns the user's memberships with team and role details, ordered by team name
+func (r *Repository) GetMembershipInfo(ctx context.Context, userID uuid.UUID) ([]*MembershipInfo, error) {
+	query := `
+		SELECT t.id, t.name, t.slug, r.id, r.name, r.permissions, tm.created_at
+		FROM team_memberships tm
+		INNER JOIN teams t ON t.id = tm.team_id
+		INNER JOIN roles r ON r.id = tm.role_id
+		WHERE tm.user_id = $1
+		ORDER BY t.name`
+	rows, err := r.db.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var memberships []*MembershipInfo
+	for rows.Next() {
+		m := &MembershipInfo{}
+		var permissions []byte
+		if err := rows.Scan(&m.TeamID, &m.TeamName, &m.TeamSlug, &m.RoleID, &m.RoleName, &permissions, &m.JoinedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(permissions, &m.Permissions); err != nil {
+			return nil, err
+		}
+		memberships = append(memberships, m)
+	}
+	return memberships, rows.Err()
+}
+
 func (r *Repository) GetMembershipsByTeamID(ctx context.Context, teamID uuid.UUID) ([]*TeamMembership, error) {
 	query := `SELECT id, team_id, user_id, role_id, created_at FROM team_memberships WHERE team_id = $1`
 	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
@@ -449,12 +1046,69 @@ func (r *Repository) GetMembershipsByTeamID(ctx context.Context, teamID uuid.UUI
 	return memberships, rows.Err()
 }
 
-func (r *Repository) DeleteMembership(ctx context.Context, teamID, userID uuid.UUID) error {
+// UpdateMembershipRole changes a member's role and returns the membership,
+// or nil when the user is not a member of the team
+func (r *Repository) UpdateMembershipRole(ctx context.Context, tx *sql.Tx, teamID, userID, roleID uuid.UUID) (*TeamMembership, error) {
+	query := `UPDATE team_memberships SET role_id = $3 WHERE team_id = $1 AND user_id = $2
+		RETURNING id, team_id, user_id, role_id, created_at`
+	m := &TeamMembership{}
+	err := tx.QueryRowContext(ctx, query, teamID, userID, roleID).Scan(
+		&m.ID, &m.TeamID, &m.UserID, &m.RoleID, &m.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return m, err
+}
+
+func (r *Repository) DeleteMembership(ctx context.Context, tx *sql.Tx, teamID, userID uuid.UUID) error {
 	query := `DELETE FROM team_memberships WHERE team_id = $1 AND user_id = $2`
-	_, err := r.db.DB.ExecContext(ctx, query, teamID, userID)
+	_, err := tx.ExecContext(ctx, query, teamID, userID)
 	return err
 }
 
+// LockTeam serializes membership changes of a team until tx ends
+func (r *Repository) LockTeam(ctx context.Context, tx *sql.Tx, teamID uuid.UUID) error {
+	var locked uuid.UUID
+	return tx.QueryRowContext(ctx, `SELECT id FROM teams WHERE id = $1 FOR UPDATE`, teamID).Scan(&locked)
+}
+
+// IsLastTeamManager reports whether the user is the only active member of the
+// team whose role grants team:manage
+func (r *Repository) IsLastTeamManager(ctx context.Context, tx *sql.Tx, teamID, userID uuid.UUID) (bool, error) {
+	query := `
+		SELECT COUNT(*) FILTER (WHERE m.user_id = $2), COUNT(*) FILTER (WHERE m.user_id <> $2 AND u.status = 'active')
+		FROM team_memberships m
+		JOIN roles r ON r.id = m.role_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = $1 AND r.permissions @> $3::jsonb`
+	var self, others int
+	if err := tx.QueryRowContext(ctx, query, teamID, userID, teamManageJSON()).Scan(&self, &others); err != nil {
+		return false, err
+	}
+	return self > 0 && others == 0, nil
+}
+
+// CountTeamManagers counts the active members of the team whose role grants
+// team:manage, leaving out everyone holding exceptRoleID
+func (r *Repository) CountTeamManagers(ctx context.Context, tx *sql.Tx, teamID, exceptRoleID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM team_memberships m
+		JOIN roles r ON r.id = m.role_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = $1 AND m.role_id <> $2 AND r.permissions @> $3::jsonb AND u.status = 'active'`
+	var count int
+	err := tx.QueryRowContext(ctx, query, teamID, exceptRoleID, teamManageJSON()).Scan(&count)
+	return count, err
+}
+
+// teamManageJSON is a JSONB containment argument matching roles with team:manage
+func teamManageJSON() string {
+	manage, _ := json.Marshal([]string{PermTeamManage})
+	return string(manage)
+}
+
 // API Key methods
 func (r *Repository) CreateAPIKey(ctx context.Context, key *APIKey) error {
 	permissions, _ := json.Marshal(key.Permissions)
@@ -509,14 +1163,115 @@ func (r *Repository) GetAPIKeysByTeamID(ctx context.Context, teamID uuid.UUID) (
 	return keys, rows.Err()
 }
 
-func (r *Repository) UpdateAPIKeyLastUsed(ctx context.Context, id uuid.UUID) error {
-	query := `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP WHERE id = $1`
-	_, err := r.db.DB.ExecContext(ctx, query, id)
+// UpdateAPIKeysLastUsed sets when each key was last used, never moving it back
+func (r *Repository) UpdateAPIKeysLastUsed(ctx context.Context, used map[uuid.UUID]time.Time) error {
+	ids := make([]string, 0, len(used))
+	times := make([]string, 0, len(used))
+	for id, at := range used {
+		ids = append(ids, id.String())
+		times = append(times, at.UTC().Format(time.RFC3339Nano))
+	}
+
+	query := `
+		UPDATE api_keys k SET last_used_at = u.used_at
+		FROM unnest($1::uuid[], $2::timestamptz[]) AS u(id, used_at)
+		WHERE k.id = u.id AND (k.last_used_at IS NULL OR k.last_used_at < u.used_at)`
+	_, err := r.db.DB.ExecContext(ctx, query, ids, times)
 	return err
 }
 
-func (r *Repository) DeleteAPIKey(ctx context.Context, teamID, id uuid.UUID) error {
-	query := `DELETE FROM api_keys WHERE id = $1 AND team_id = $2`
-	_, err := r.db.DB.ExecContext(ctx, query, id, teamID)
-	return err
+// DeleteAPIKey deletes a team's API key and returns its hash, "" when there
+// was no such key
+func (r *Repository) DeleteAPIKey(ctx context.Context, teamID, id uuid.UUID) (string, error) {
+	query := `DELETE FROM api_keys WHERE id = $1 AND team_id = $2 RETURNING key_hash`
+	var keyHash string
+	err := r.db.DB.QueryRowContext(ctx, query, id, teamID).Scan(&keyHash)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return keyHash, err
+}
+
+// Batch lookups for hydrating listings. Each takes one query whatever the
+// number of IDs; IDs that do not exist are missing from the result.
+
+func (r *Repository) GetUserSummaries(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*UserSummary, error) {
+	users := make(map[uuid.UUID]*UserSummary, len(ids))
+	if len(ids) == 0 {
+		return users, nil
+	}
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT id, email, name FROM users WHERE id = ANY($1::uuid[])`, uuidArray(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := &UserSummary{}
+		if err := rows.Scan(&u.ID, &u.Email, &u.Name); err != nil {
+			return nil, err
+		}
+		users[u.ID] = u
+	}
+	return users, rows.Err()
+}
+
+func (r *Repository) GetTeamSummaries(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*TeamSummary, error) {
+	teams := make(map[uuid.UUID]*TeamSummary, len(ids))
+	if len(ids) == 0 {
+		return teams, nil
+	}
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT id, name, slug FROM teams WHERE id = ANY($1::uuid[])`, uuidArray(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t := &TeamSummary{}
+		if err := rows.Scan(&t.ID, &t.Name, &t.Slug); err != nil {
+			return nil, err
+		}
+		teams[t.ID] = t
+	}
+	return teams, rows.Err()
+}
+
+func (r *Repository) GetRoleSummaries(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*RoleSummary, error) {
+	roles := make(map[uuid.UUID]*RoleSummary, len(ids))
+	if len(ids) == 0 {
+		return roles, nil
+	}
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT id, name FROM roles WHERE id = ANY($1::uuid[])`, uuidArray(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		role := &RoleSummary{}
+		if err := rows.Scan(&role.ID, &role.Name); err != nil {
+			return nil, err
+		}
+		roles[role.ID] = role
+	}
+	return roles, rows.Err()
+}
+
+// uuidArray passes IDs as a text array, deduplicated
+func uuidArray(ids []uuid.UUID) interface{} {
+	seen := make(map[uuid.UUID]bool, len(ids))
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			values = append(values, id.String())
+		}
+	}
+	return values
+}
+
+// isUniqueViolation reports whether err is a PostgreSQL unique constraint violation
+func isUniqueViolation(err error) bool {
+	return postgres.IsUniqueViolation(err)
 }
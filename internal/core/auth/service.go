@@ -4,10 +4,16 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -15,6 +21,8 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/core/validation"
+	"github.com/baseplate/baseplate/internal/events"
 )
 
 var (
@@ -27,24 +35,150 @@ var (
 	ErrLastSuperAdmin     = errors.New("cannot demote the last super admin")
 	ErrAlreadySuperAdmin  = errors.New("user is already a super admin")
 	ErrNotSuperAdmin      = errors.New("user is not a super admin")
+	ErrInvalidRole        = errors.New("invalid role")
+	ErrRoleExists         = errors.New("a role with this name already exists")
+	ErrAlreadyMember      = errors.New("user is already a member of this team")
+	ErrBuiltinRole        = errors.New("built-in roles cannot be renamed or deleted")
+	ErrRoleInUse          = errors.New("role is assigned to members")
+	ErrLastAdmin          = errors.New("a team must keep at least one member with team:manage")
+	ErrWrongPassword      = errors.New("current password is incorrect")
+	ErrInvalidName        = errors.New("name cannot be empty")
+	ErrPasswordUnchanged  = errors.New("new password must differ from the current one")
+	ErrInvalidToken       = errors.New("invalid or expired verification token")
+	ErrInactiveUser       = errors.New("account is not active")
+	ErrSessionRevoked     = errors.New("session has been revoked")
+	ErrSelfModification   = errors.New("super admins cannot deactivate or delete themselves")
+	ErrIsSuperAdmin       = errors.New("demote the super admin before deactivating or deleting them")
+	ErrTwoFactorEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorDisabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorPending   = errors.New("start two-factor enrollment first")
+	ErrInvalidCode        = errors.New("invalid two-factor code")
+	ErrInvalidChallenge   = errors.New("invalid or expired two-factor token")
+	ErrTwoFactorRequired  = errors.New("two-factor authentication required")
+	ErrMailUnavailable    = errors.New("email changes are unavailable: no mail delivery is configured")
 )
 
+// emailVerificationTTL is how long an email change waits for confirmation
+const emailVerificationTTL = 24 * time.Hour
+
+// inviteTTL is how long an invited user has to set a password
+const inviteTTL = 7 * 24 * time.Hour
+
+// maxRoleName is the length of roles.name
+const maxRoleName = 50
+
+// builtinRoles are created with every team; bundles and clients refer to them by name
+var builtinRoles = map[string]bool{"admin": true, "editor": true, "viewer": true}
+
 type Service struct {
-	repo   *Repository
-	config *config.JWTConfig
+	repo            *Repository
+	config          *config.JWTConfig
+	twoFactor       *config.TwoFactorConfig
+	permissionCache *PermissionCache
+	lookups         *LookupCache
+	keyUsage        *KeyUsage
+	bus             *events.Bus
+	mailer          Mailer
+	sessions        *sessionCache
 }
 
-func NewService(repo *Repository, cfg *config.JWTConfig) *Service {
-	return &Service{repo: repo, config: cfg}
+// NewService creates the auth service. permissionCache, lookups, keyUsage,
+// bus and mailer may be nil; API key uses are recorded in keyUsage,
+// membership, role and team changes are announced on bus, and without a
+// mailer email changes fail with ErrMailUnavailable.
+func NewService(repo *Repository, cfg *config.JWTConfig, twoFactor *config.TwoFactorConfig, permissionCache *PermissionCache, lookups *LookupCache, keyUsage *KeyUsage, bus *events.Bus, mailer Mailer) *Service {
+	return &Service{
+		repo:            repo,
+		config:          cfg,
+		twoFactor:       twoFactor,
+		permissionCache: permissionCache,
+		lookups:         lookups,
+		keyUsage:        keyUsage,
+		bus:             bus,
+		mailer:          mailer,
+		sessions:        newSessionCache(SessionCheckTTL),
+	}
 }
 
 type JWTClaims struct {
-	UserID       uuid.UUID `json:"user_id"`
-	Email        string    `json:"email"`
-	IsSuperAdmin *bool     `json:"is_super_admin,omitempty"` // Pointer for graceful degradation with old tokens
+	UserID       uuid.UUID         `json:"user_id"`
+	Email        string            `json:"email"`
+	IsSuperAdmin *bool             `json:"is_super_admin,omitempty"` // Pointer for graceful degradation with old tokens
+	Memberships  *MembershipClaims `json:"memberships,omitempty"`
+	// TwoFactor is set when the session was started with a second factor
+	TwoFactor bool `json:"two_factor,omitempty"`
+	// Impersonation is set on tokens a super admin minted to act as the user
+	Impersonation *ImpersonationClaims `json:"impersonation,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// MembershipClaims is a digest of the user's teams and permissions taken when
+// the token was issued. It is trusted until ValidUntil so that role changes
+// and removals take effect without waiting for the token to expire.
+type MembershipClaims struct {
+	ValidUntil int64              `json:"valid_until"` // Unix seconds
+	Teams      []MembershipDigest `json:"teams"`
+}
+
+type MembershipDigest struct {
+	TeamID      uuid.UUID `json:"team_id"`
+	Permissions []string  `json:"permissions"`
+}
+
+// maxMembershipClaimBytes caps the encoded digest so tokens fit comfortably
+// in request headers whatever the roles look like
+const maxMembershipClaimBytes = 4096
+
+// TeamPermissions returns the permissions embedded for a team. ok is false
+// when the team is not in the token or the digest is no longer trusted, in
+// which case permissions must be looked up.
+func (c *JWTClaims) TeamPermissions(teamID uuid.UUID, now time.Time) (permissions []string, ok bool) {
+	if c.Memberships == nil || now.Unix() >= c.Memberships.ValidUntil {
+		return nil, false
+	}
+	for _, team := range c.Memberships.Teams {
+		if team.TeamID == teamID {
+			return team.Permissions, true
+		}
+	}
+	return nil, false
+}
+
+// TokenInfo describes a validated JWT
+func (c *JWTClaims) TokenInfo() *TokenInfo {
+	info := &TokenInfo{Type: TokenTypeJWT, TwoFactor: c.TwoFactor}
+	if c.IssuedAt != nil {
+		issued := c.IssuedAt.Time
+		info.IssuedAt = &issued
+	}
+	if c.ExpiresAt != nil {
+		expires := c.ExpiresAt.Time
+		info.ExpiresAt = &expires
+	}
+	if c.Memberships != nil {
+		validUntil := time.Unix(c.Memberships.ValidUntil, 0).UTC()
+		info.MembershipsValidUntil = &validUntil
+		for _, team := range c.Memberships.Teams {
+			info.MembershipTeams = append(info.MembershipTeams, team.TeamID)
+		}
+	}
+	info.Impersonation = c.Impersonation
+	return info
+}
+
+// TokenInfo describes a validated API key
+func (k *APIKey) TokenInfo() *TokenInfo {
+	id, teamID := k.ID, k.TeamID
+	return &TokenInfo{
+		Type:      TokenTypeAPIKey,
+		IssuedAt:  &k.CreatedAt,
+		ExpiresAt: k.ExpiresAt,
+		APIKeyID:  &id,
+		TeamID:    &teamID,
+		Scopes:    k.Permissions,
+	}
+}
+
 // User authentication
 func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*AuthResponse, error) {
 	existing, err := s.repo.GetUserByEmail(ctx, req.Email)
@@ -65,14 +199,15 @@ func (s *Service) Register(ctx context.Context, req *RegisterRequest) (*AuthResp
 		Email:        req.Email,
 		PasswordHash: string(hash),
 		Name:         req.Name,
-		Status:       "active",
+		Status:       UserStatusActive,
 	}
 
 	if err := s.repo.CreateUser(ctx, user); err != nil {
 		return nil, err
 	}
 
-	token, err := s.generateToken(user)
+	// A new user has no teams to embed yet
+	token, err := s.generateToken(user, nil, false, time.Now().Add(s.config.ExpirationDuration()))
 	if err != nil {
 		return nil, err
 	}
@@ -92,8 +227,18 @@ func (s *Service) Login(ctx context.Context, req *LoginRequest) (*AuthResponse,
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
 		return nil, ErrInvalidCredentials
 	}
+	if user.Status != UserStatusActive {
+		return nil, ErrInactiveUser
+	}
+	if user.TwoFactorEnabled {
+		return s.loginChallenge(ctx, user.ID)
+	}
 
-	token, err := s.generateToken(user)
+	memberships, err := s.membershipClaims(ctx, user.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.generateToken(user, memberships, false, time.Now().Add(s.config.ExpirationDuration()))
 	if err != nil {
 		return nil, err
 	}
@@ -101,10 +246,261 @@ func (s *Service) Login(ctx context.Context, req *LoginRequest) (*AuthResponse,
 	return &AuthResponse{Token: token, User: user}, nil
 }
 
+// RefreshToken reissues a user's token with current super admin status and
+// team memberships. The new token expires when the old one does, so a refresh
+// never extends a session. teamIDs selects which teams to embed; when empty
+// the user's teams are taken in name order. twoFactor carries over whether
+// the session was started with a second factor.
+func (s *Service) RefreshToken(ctx context.Context, userID uuid.UUID, expiresAt time.Time, teamIDs []uuid.UUID, twoFactor bool) (*AuthResponse, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Status != UserStatusActive {
+		return nil, ErrNotFound
+	}
+
+	memberships, err := s.membershipClaims(ctx, user.ID, teamIDs)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.generateToken(user, memberships, twoFactor, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{Token: token, User: user}, nil
+}
+
+// membershipClaims builds the digest embedded in a user's token, or nil when
+// membership claims are disabled. Teams are added until the configured count
+// or the size cap is reached; requests for the rest fall back to the database.
+func (s *Service) membershipClaims(ctx context.Context, userID uuid.UUID, teamIDs []uuid.UUID) (*MembershipClaims, error) {
+	if s.config.MembershipClaimTeams <= 0 {
+		return nil, nil
+	}
+
+	memberships, err := s.repo.GetMembershipInfo(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(teamIDs) > 0 {
+		byTeam := make(map[uuid.UUID]*MembershipInfo, len(memberships))
+		for _, m := range memberships {
+			byTeam[m.TeamID] = m
+		}
+		memberships = memberships[:0]
+		for _, id := range teamIDs {
+			if m, ok := byTeam[id]; ok {
+				memberships = append(memberships, m)
+				delete(byTeam, id) // ignore duplicates
+			}
+		}
+	}
+
+	claims := &MembershipClaims{
+		ValidUntil: time.Now().Add(s.config.MembershipClaimTTL()).Unix(),
+		Teams:      []MembershipDigest{},
+	}
+	size := 0
+	for _, m := range memberships {
+		if len(claims.Teams) == s.config.MembershipClaimTeams {
+			break
+		}
+		digest := MembershipDigest{TeamID: m.TeamID, Permissions: m.Permissions}
+		encoded, err := json.Marshal(digest)
+		if err != nil {
+			return nil, err
+		}
+		if size+len(encoded) > maxMembershipClaimBytes {
+			break
+		}
+		size += len(encoded)
+		claims.Teams = append(claims.Teams, digest)
+	}
+	return claims, nil
+}
+
 func (s *Service) GetUserByID(ctx context.Context, id uuid.UUID) (*User, error) {
 	return s.repo.GetUserByID(ctx, id)
 }
 
+// WhoAmI describes the caller: the user with their team memberships and
+// roles, and the token they authenticated with. userID is nil for API keys
+// that do not belong to a user.
+func (s *Service) WhoAmI(ctx context.Context, userID *uuid.UUID, token *TokenInfo) (*WhoAmIResponse, error) {
+	resp := &WhoAmIResponse{Memberships: []*MembershipInfo{}, Token: token}
+	if userID == nil {
+		return resp, nil
+	}
+
+	user, err := s.repo.GetUserByID(ctx, *userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	resp.User = user
+
+	memberships, err := s.repo.GetMembershipInfo(ctx, *userID)
+	if err != nil {
+		return nil, err
+	}
+	if memberships != nil {
+		resp.Memberships = memberships
+	}
+	return resp, nil
+}
+
+// UpdateProfile changes the caller's name and starts an email change. The new
+// email is held as pending and a verification token is mailed to it; the
+// account keeps its current email until VerifyEmail confirms the token.
+func (s *Service) UpdateProfile(ctx context.Context, userID uuid.UUID, req *UpdateProfileRequest, ipAddress, userAgent *string) (*ProfileResponse, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	changesEmail := req.Email != nil && !strings.EqualFold(*req.Email, user.Email)
+	// Refuse before changing anything, so the request fails as a whole
+	if changesEmail && s.mailer == nil {
+		log.Printf("WARNING: email change of user %s to %s refused: no mail delivery is configured", user.ID, *req.Email)
+		return nil, ErrMailUnavailable
+	}
+
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return nil, ErrInvalidName
+		}
+		if name != user.Name {
+			if err := s.repo.UpdateUserName(ctx, userID, name); err != nil {
+				return nil, err
+			}
+			s.auditSelf(userID, "update_profile", "success",
+				map[string]any{"name": user.Name}, map[string]any{"name": name}, ipAddress, userAgent)
+			user.Name = name
+		}
+	}
+
+	if changesEmail {
+		if err := s.requestEmailChange(ctx, user, *req.Email, req.CurrentPassword, ipAddress, userAgent); err != nil {
+			return nil, err
+		}
+	}
+
+	pending, err := s.repo.GetPendingEmail(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &ProfileResponse{User: user, PendingEmail: pending}, nil
+}
+
+func (s *Service) requestEmailChange(ctx context.Context, user *User, email, currentPassword string, ipAddress, userAgent *string) error {
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)) != nil {
+		s.auditSelf(user.ID, "request_email_change", "failure", nil, nil, ipAddress, userAgent)
+		return ErrWrongPassword
+	}
+	existing, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return ErrUserExists
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(raw)
+	if err := s.repo.SetPendingEmail(ctx, user.ID, email, hashVerificationToken(token), time.Now().Add(emailVerificationTTL)); err != nil {
+		return err
+	}
+	if err := s.mailer.SendEmailVerification(ctx, user, email, token); err != nil {
+		return fmt.Errorf("send verification email: %w", err)
+	}
+	s.auditSelf(user.ID, "request_email_change", "success",
+		map[string]any{"email": user.Email}, map[string]any{"pending_email": email}, ipAddress, userAgent)
+	return nil
+}
+
+// VerifyEmail confirms a pending email change with the token mailed to the
+// new address and returns the updated user
+func (s *Service) VerifyEmail(ctx context.Context, token string, ipAddress, userAgent *string) (*User, error) {
+	user, oldEmail, err := s.repo.ConfirmPendingEmail(ctx, hashVerificationToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	s.auditSelf(user.ID, "verify_email", "success",
+		map[string]any{"email": oldEmail}, map[string]any{"email": user.Email}, ipAddress, userAgent)
+	return user, nil
+}
+
+// ChangePassword replaces the caller's password after checking the current one
+func (s *Service) ChangePassword(ctx context.Context, userID uuid.UUID, req *ChangePasswordRequest, ipAddress, userAgent *string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)) != nil {
+		s.auditSelf(userID, "change_password", "failure", nil, nil, ipAddress, userAgent)
+		return ErrWrongPassword
+	}
+	if req.NewPassword == req.CurrentPassword {
+		return ErrPasswordUnchanged
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdateUserPassword(ctx, userID, string(hash)); err != nil {
+		return err
+	}
+	s.auditSelf(userID, "change_password", "success", nil, nil, ipAddress, userAgent)
+	return nil
+}
+
+func hashVerificationToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// auditSelf records a change users made to their own account. Passwords and
+// tokens are never included.
+func (s *Service) auditSelf(userID uuid.UUID, action, result string, oldData, newData map[string]any, ipAddress, userAgent *string) {
+	auditLog := &AuditLog{
+		ID:           uuid.New(),
+		UserID:       &userID,
+		ActorType:    "team_member",
+		EntityType:   "user",
+		EntityID:     userID.String(),
+		Action:       action,
+		OldData:      oldData,
+		NewData:      newData,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		ResultStatus: &result,
+	}
+	// Log asynchronously to not block the response
+	go func() {
+		if err := s.repo.CreateAuditLog(context.Background(), auditLog); err != nil {
+			log.Printf("ERROR: failed to create audit log for %s action on user %s: %v",
+				auditLog.Action, auditLog.EntityID, err)
+		}
+	}()
+}
+
 // CheckSuperAdminStatus verifies if a user is a super admin by checking the database.
 // This is used to validate JWT claims against the current DB state (for demotion detection).
 func (s *Service) CheckSuperAdminStatus(ctx context.Context, userID uuid.UUID) (bool, error) {
@@ -122,12 +518,25 @@ func (s *Service) GetAllUsers(ctx context.Context, limit int, offset int) ([]*Us
 	return s.repo.GetAllUsers(ctx, limit, offset)
 }
 
+// GetUserDetail returns a user with their memberships, including each team and role
 func (s *Service) GetUserDetail(ctx context.Context, userID uuid.UUID) (*User, []*TeamMembership, error) {
-	return s.repo.GetUserWithMemberships(ctx, userID)
+	user, memberships, err := s.repo.GetUserWithMemberships(ctx, userID)
+	if err != nil || user == nil {
+		return user, memberships, err
+	}
+	if err := s.hydrateMemberships(ctx, memberships, false, true); err != nil {
+		return nil, nil, err
+	}
+	return user, memberships, nil
 }
 
 func (s *Service) UpdateUser(ctx context.Context, user *User) error {
-	return s.repo.UpdateUser(ctx, user)
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return err
+	}
+	// The status may have changed
+	s.sessions.invalidate(user.ID)
+	return nil
 }
 
 func (s *Service) PromoteToSuperAdmin(ctx context.Context, actorID uuid.UUID, targetUserID uuid.UUID, ipAddress, userAgent *string) (*User, error) {
@@ -308,26 +717,338 @@ func (s *Service) DemoteFromSuperAdmin(ctx context.Context, actorID uuid.UUID, t
 }
 
 // GetSuperAdminAuditLogs returns audit logs for super admin actions
+// CreateUser creates an active user with a temporary password, or with
+// req.Invite an invited user who sets a password with the returned token.
+// The credential is only returned here.
+func (s *Service) CreateUser(ctx context.Context, actorID uuid.UUID, req *CreateUserRequest, ipAddress, userAgent *string) (*CreateUserResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	existing, err := s.repo.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrUserExists
+	}
+
+	user := &User{ID: uuid.New(), Email: req.Email, Name: name}
+	resp := &CreateUserResponse{User: user}
+	if req.Invite {
+		token, err := randomToken(32)
+		if err != nil {
+			return nil, err
+		}
+		expiresAt := time.Now().Add(inviteTTL)
+		user.Status = UserStatusInvited
+		err = s.repo.CreateInvitedUser(ctx, user, hashVerificationToken(token), expiresAt)
+		if err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrUserExists
+			}
+			return nil, err
+		}
+		resp.InviteToken = token
+		resp.InviteExpiresAt = &expiresAt
+	} else {
+		password, err := randomToken(12)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user.PasswordHash = string(hash)
+		user.Status = UserStatusActive
+		user.PasswordChangeRequired = true
+		if err := s.repo.CreateUser(ctx, user); err != nil {
+			if isUniqueViolation(err) {
+				return nil, ErrUserExists
+			}
+			return nil, err
+		}
+		resp.TemporaryPassword = password
+	}
+
+	s.auditAdmin(actorID, user.ID, "create", nil, map[string]any{
+		"email":  user.Email,
+		"name":   user.Name,
+		"status": user.Status,
+	}, ipAddress, userAgent)
+	return resp, nil
+}
+
+// AcceptInvite sets the password of an invited user and logs them in
+func (s *Service) AcceptInvite(ctx context.Context, req *AcceptInviteRequest, ipAddress, userAgent *string) (*AuthResponse, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.AcceptInvite(ctx, hashVerificationToken(req.Token), string(hash))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	s.sessions.invalidate(user.ID)
+	s.auditSelf(user.ID, "accept_invite", "success", nil, nil, ipAddress, userAgent)
+
+	memberships, err := s.membershipClaims(ctx, user.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.generateToken(user, memberships, false, time.Now().Add(s.config.ExpirationDuration()))
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{Token: token, User: user}, nil
+}
+
+// DeactivateUser blocks a user from logging in, rejects the tokens they hold
+// and revokes the API keys they created. Memberships are kept so the user can
+// be reactivated by setting their status back to active.
+func (s *Service) DeactivateUser(ctx context.Context, actorID, targetUserID uuid.UUID, ipAddress, userAgent *string) (*DeactivateUserResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	user, _, err := s.lockRemovableUser(ctx, tx, actorID, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.DeactivateUser(ctx, tx, targetUserID); err != nil {
+		return nil, err
+	}
+	revokedHashes, err := s.repo.DeleteUserAPIKeys(ctx, tx, targetUserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.sessions.invalidate(targetUserID)
+	s.lookups.invalidateAPIKeys(ctx, revokedHashes)
+	revoked := int64(len(revokedHashes))
+
+	oldStatus := user.Status
+	user.Status = UserStatusDeactivated
+	s.auditAdmin(actorID, targetUserID, "deactivate",
+		map[string]any{"status": oldStatus},
+		map[string]any{"status": user.Status, "api_keys_revoked": revoked},
+		ipAddress, userAgent)
+	return &DeactivateUserResponse{User: user, APIKeysRevoked: revoked}, nil
+}
+
+// DeleteUser removes a user from every team, revokes their API keys and
+// sessions, and replaces their email and name. The user row is kept so audit
+// logs and entity history keep a valid reference.
+func (s *Service) DeleteUser(ctx context.Context, actorID, targetUserID uuid.UUID, ipAddress, userAgent *string) error {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	user, teamIDs, err := s.lockRemovableUser(ctx, tx, actorID, targetUserID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteUserMemberships(ctx, tx, targetUserID); err != nil {
+		return err
+	}
+	revokedHashes, err := s.repo.DeleteUserAPIKeys(ctx, tx, targetUserID)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.AnonymizeUser(ctx, tx, targetUserID, anonymizedEmail(targetUserID), "Deleted user"); err != nil {
+		return err
+	}
+	if err := s.repo.DisableTOTP(ctx, tx, targetUserID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.sessions.invalidate(targetUserID)
+	s.lookups.invalidateAPIKeys(ctx, revokedHashes)
+	for _, teamID := range teamIDs {
+		s.bus.Publish(ctx, events.Event{
+			Type:    events.MembershipDeleted,
+			TeamID:  teamID,
+			Payload: &TeamMembership{TeamID: teamID, UserID: targetUserID},
+		})
+	}
+
+	// The audit entry records that the user was deleted, not who they were
+	s.auditAdmin(actorID, targetUserID, "delete",
+		map[string]any{"status": user.Status},
+		map[string]any{"status": UserStatusDeleted, "teams_left": len(teamIDs), "api_keys_revoked": len(revokedHashes)},
+		ipAddress, userAgent)
+	return nil
+}
+
+// lockRemovableUser locks a user about to be deactivated or deleted, with the
+// teams they belong to, and checks that it may be: super admins must be
+// demoted first, nobody can remove themselves, and no team may be left
+// without an active manager
+func (s *Service) lockRemovableUser(ctx context.Context, tx *sql.Tx, actorID, targetUserID uuid.UUID) (*User, []uuid.UUID, error) {
+	if actorID == targetUserID {
+		return nil, nil, ErrSelfModification
+	}
+	// Teams are locked before the user, as membership changes do
+	teamIDs, err := s.repo.LockUserTeams(ctx, tx, targetUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := s.repo.LockUser(ctx, tx, targetUserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || user.Status == UserStatusDeleted {
+		return nil, nil, ErrNotFound
+	}
+	if user.IsSuperAdmin {
+		return nil, nil, ErrIsSuperAdmin
+	}
+	for _, teamID := range teamIDs {
+		last, err := s.repo.IsLastTeamManager(ctx, tx, teamID, targetUserID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if last {
+			return nil, nil, fmt.Errorf("%w (team %s)", ErrLastAdmin, teamID)
+		}
+	}
+	return user, teamIDs, nil
+}
+
+// anonymizedEmail is the placeholder address of a deleted user. The .invalid
+// TLD is reserved, so it can never be registered or receive mail.
+func anonymizedEmail(id uuid.UUID) string {
+	return "deleted-" + id.String() + "@deleted.invalid"
+}
+
+// randomToken returns n random bytes, URL-safe base64 encoded
+func randomToken(n int) (string, error) {
+	raw := make([]byte, n)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// auditAdmin records a super admin's change to a user account
+func (s *Service) auditAdmin(actorID, targetUserID uuid.UUID, action string, oldData, newData map[string]any, ipAddress, userAgent *string) {
+	resultStatus := "success"
+	auditLog := &AuditLog{
+		ID:           uuid.New(),
+		UserID:       &actorID,
+		ActorType:    "super_admin",
+		EntityType:   "user",
+		EntityID:     targetUserID.String(),
+		Action:       action,
+		OldData:      oldData,
+		NewData:      newData,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		ResultStatus: &resultStatus,
+	}
+	// Log asynchronously to not block the response
+	go func() {
+		if err := s.repo.CreateAuditLog(context.Background(), auditLog); err != nil {
+			log.Printf("ERROR: failed to create audit log for %s action on user %s: %v",
+				auditLog.Action, auditLog.EntityID, err)
+		}
+	}()
+}
+
 func (s *Service) GetSuperAdminAuditLogs(ctx context.Context, limit int, offset int) ([]*AuditLog, error) {
-	return s.repo.GetSuperAdminAuditLogs(ctx, limit, offset)
+	logs, err := s.repo.GetSuperAdminAuditLogs(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.hydrateAuditLogs(ctx, logs); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+// hydrateAuditLogs attaches the acting user, the team and, for entries about
+// a user, the target user, with one query per kind
+func (s *Service) hydrateAuditLogs(ctx context.Context, logs []*AuditLog) error {
+	var userIDs, teamIDs []uuid.UUID
+	for _, l := range logs {
+		if l.UserID != nil {
+			userIDs = append(userIDs, *l.UserID)
+		}
+		if l.TeamID != nil {
+			teamIDs = append(teamIDs, *l.TeamID)
+		}
+		if target, ok := auditTarget(l); ok {
+			userIDs = append(userIDs, target)
+		}
+	}
+
+	users, err := s.repo.GetUserSummaries(ctx, userIDs)
+	if err != nil {
+		return err
+	}
+	teams, err := s.repo.GetTeamSummaries(ctx, teamIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, l := range logs {
+		if l.UserID != nil {
+			l.User = users[*l.UserID]
+		}
+		if l.TeamID != nil {
+			l.Team = teams[*l.TeamID]
+		}
+		if target, ok := auditTarget(l); ok {
+			l.Target = users[target]
+		}
+	}
+	return nil
+}
+
+func auditTarget(l *AuditLog) (uuid.UUID, bool) {
+	if l.EntityType != "user" {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(l.EntityID)
+	return id, err == nil
 }
 
 func (s *Service) CreateAuditLog(ctx context.Context, log *AuditLog) error {
 	return s.repo.CreateAuditLog(ctx, log)
 }
 
-func (s *Service) generateToken(user *User) (string, error) {
+func (s *Service) generateToken(user *User, memberships *MembershipClaims, twoFactor bool, expiresAt time.Time) (string, error) {
 	isSuperAdmin := user.IsSuperAdmin
+	if memberships != nil && memberships.ValidUntil > expiresAt.Unix() {
+		memberships.ValidUntil = expiresAt.Unix()
+	}
 	claims := JWTClaims{
 		UserID:       user.ID,
 		Email:        user.Email,
 		IsSuperAdmin: &isSuperAdmin,
+		Memberships:  memberships,
+		TwoFactor:    twoFactor,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.config.ExpirationDuration())),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	return s.signToken(&claims)
+}
 
+func (s *Service) signToken(claims *JWTClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString([]byte(s.config.Secret))
 }
@@ -357,6 +1078,9 @@ func (s *Service) ValidateToken(tokenString string) (*JWTClaims, error) {
 
 // Team management
 func (s *Service) CreateTeam(ctx context.Context, userID uuid.UUID, req *CreateTeamRequest) (*Team, error) {
+	if err := validation.ValidateSlug("slug", req.Slug); err != nil {
+		return nil, err
+	}
 	existing, err := s.repo.GetTeamBySlug(ctx, req.Slug)
 	if err != nil {
 		return nil, err
@@ -370,6 +1094,9 @@ func (s *Service) CreateTeam(ctx context.Context, userID uuid.UUID, req *CreateT
 		Name: req.Name,
 		Slug: req.Slug,
 	}
+	if req.RequireTwoFactor != nil {
+		team.RequireTwoFactor = *req.RequireTwoFactor
+	}
 
 	if err := s.repo.CreateTeam(ctx, team); err != nil {
 		return nil, err
@@ -416,6 +1143,7 @@ func (s *Service) CreateTeam(ctx context.Context, userID uuid.UUID, req *CreateT
 	if err := s.repo.CreateMembership(ctx, membership); err != nil {
 		return nil, err
 	}
+	s.bus.Publish(ctx, events.Event{Type: events.MembershipCreated, TeamID: team.ID, Payload: membership})
 
 	return team, nil
 }
@@ -440,11 +1168,11 @@ func (s *Service) GetAllTeams(ctx context.Context, limit, offset int) ([]*Team,
 }
 
 func (s *Service) UpdateTeam(ctx context.Context, team *Team) error {
-	return s.repo.UpdateTeam(ctx, team)
-}
-
-func (s *Service) DeleteTeam(ctx context.Context, id uuid.UUID) error {
-	return s.repo.DeleteTeam(ctx, id)
+	if err := s.repo.UpdateTeam(ctx, team); err != nil {
+		return err
+	}
+	s.bus.Publish(ctx, events.Event{Type: events.TeamUpdated, TeamID: team.ID, Payload: team})
+	return nil
 }
 
 // Role management
@@ -464,6 +1192,9 @@ func (s *Service) GetRole(ctx context.Context, id uuid.UUID) (*Role, error) {
 }
 
 func (s *Service) CreateRole(ctx context.Context, teamID uuid.UUID, name string, permissions []string) (*Role, error) {
+	if err := validateRole(name, permissions); err != nil {
+		return nil, err
+	}
 	role := &Role{
 		ID:          uuid.New(),
 		TeamID:      teamID,
@@ -476,8 +1207,168 @@ func (s *Service) CreateRole(ctx context.Context, teamID uuid.UUID, name string,
 	return role, nil
 }
 
-func (s *Service) UpdateRole(ctx context.Context, role *Role) error {
-	return s.repo.UpdateRole(ctx, role)
+// UpdateRole renames a role or replaces its permissions; nil fields are left
+// unchanged. Built-in roles keep their names, and the admin role keeps
+// team:manage so that someone can always manage the team. Taking team:manage
+// from a custom role fails with ErrLastAdmin when its members are the only
+// ones holding it.
+func (s *Service) UpdateRole(ctx context.Context, teamID, roleID uuid.UUID, req *UpdateRoleRequest) (*Role, error) {
+	role, err := s.teamRole(ctx, teamID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	managed := slices.Contains(role.Permissions, PermTeamManage)
+
+	if req.Name != nil && *req.Name != role.Name {
+		if builtinRoles[role.Name] {
+			return nil, ErrBuiltinRole
+		}
+		role.Name = *req.Name
+	}
+	if req.Permissions != nil {
+		if role.Name == "admin" && !slices.Contains(req.Permissions, PermTeamManage) {
+			return nil, fmt.Errorf("%w: the admin role must keep %s", ErrInvalidRole, PermTeamManage)
+		}
+		role.Permissions = req.Permissions
+	}
+	if err := validateRole(role.Name, role.Permissions); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := s.repo.LockTeam(ctx, tx, teamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if managed && !slices.Contains(role.Permissions, PermTeamManage) {
+		if err := s.checkRoleNotLastManager(ctx, tx, teamID, roleID); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.UpdateRole(ctx, tx, role); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.bus.Publish(ctx, events.Event{Type: events.RoleUpdated, TeamID: role.TeamID, Payload: role})
+	return role, nil
+}
+
+// DeleteRole deletes a custom role. Members holding it are moved to
+// reassignTo when set; otherwise deletion fails with ErrRoleInUse while any
+// member holds the role. Moving the team's only managers to a role without
+// team:manage fails with ErrLastAdmin.
+func (s *Service) DeleteRole(ctx context.Context, teamID, roleID uuid.UUID, reassignTo *uuid.UUID) error {
+	role, err := s.teamRole(ctx, teamID, roleID)
+	if err != nil {
+		return err
+	}
+	if builtinRoles[role.Name] {
+		return ErrBuiltinRole
+	}
+	demotes := false
+	if reassignTo != nil {
+		if *reassignTo == roleID {
+			return fmt.Errorf("%w: members cannot be reassigned to the role being deleted", ErrInvalidRole)
+		}
+		target, err := s.teamRole(ctx, teamID, *reassignTo)
+		if err != nil {
+			return err
+		}
+		demotes = slices.Contains(role.Permissions, PermTeamManage) && !slices.Contains(target.Permissions, PermTeamManage)
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the team before the role, in the same order as membership changes
+	if err := s.repo.LockTeam(ctx, tx, teamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	// Memberships cascade with the role, so hold it while checking them
+	if err := s.repo.LockRole(ctx, tx, roleID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	members, err := s.repo.CountRoleMembers(ctx, tx, roleID)
+	if err != nil {
+		return err
+	}
+	if members > 0 {
+		if reassignTo == nil {
+			return &RoleInUseError{Members: members}
+		}
+		if demotes {
+			if err := s.checkRoleNotLastManager(ctx, tx, teamID, roleID); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.ReassignRoleMembers(ctx, tx, roleID, *reassignTo); err != nil {
+			return err
+		}
+	}
+	if err := s.repo.DeleteRole(ctx, tx, roleID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	s.bus.Publish(ctx, events.Event{Type: events.RoleDeleted, TeamID: teamID, Payload: role})
+	return nil
+}
+
+// RoleInUseError reports how many members still hold a role being deleted
+type RoleInUseError struct {
+	Members int
+}
+
+func (e *RoleInUseError) Error() string {
+	return fmt.Sprintf("role is assigned to %d member(s); reassign them first", e.Members)
+}
+
+func (e *RoleInUseError) Unwrap() error {
+	return ErrRoleInUse
+}
+
+// teamRole returns a role of the team, or ErrNotFound when it belongs to another team
+func (s *Service) teamRole(ctx context.Context, teamID, roleID uuid.UUID) (*Role, error) {
+	role, err := s.repo.GetRoleByID(ctx, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil || role.TeamID != teamID {
+		return nil, ErrNotFound
+	}
+	return role, nil
+}
+
+// validateRole checks a role name against the roles.name column and every
+// permission against AllPermissions
+func validateRole(name string, permissions []string) error {
+	if name == "" || len(name) > maxRoleName {
+		return fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidRole, maxRoleName)
+	}
+	if err := ValidatePermissions(permissions); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRole, err)
+	}
+	return nil
 }
 
 // Membership management
@@ -485,8 +1376,56 @@ func (s *Service) GetMembership(ctx context.Context, teamID, userID uuid.UUID) (
 	return s.repo.GetMembership(ctx, teamID, userID)
 }
 
+// GetMemberships returns a team's memberships, including each user and role
 func (s *Service) GetMemberships(ctx context.Context, teamID uuid.UUID) ([]*TeamMembership, error) {
-	return s.repo.GetMembershipsByTeamID(ctx, teamID)
+	memberships, err := s.repo.GetMembershipsByTeamID(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.hydrateMemberships(ctx, memberships, true, false); err != nil {
+		return nil, err
+	}
+	return memberships, nil
+}
+
+// hydrateMemberships attaches roles, and users or teams when asked, with one
+// query per kind
+func (s *Service) hydrateMemberships(ctx context.Context, memberships []*TeamMembership, withUsers, withTeams bool) error {
+	if len(memberships) == 0 {
+		return nil
+	}
+	userIDs := make([]uuid.UUID, 0, len(memberships))
+	teamIDs := make([]uuid.UUID, 0, len(memberships))
+	roleIDs := make([]uuid.UUID, 0, len(memberships))
+	for _, m := range memberships {
+		userIDs = append(userIDs, m.UserID)
+		teamIDs = append(teamIDs, m.TeamID)
+		roleIDs = append(roleIDs, m.RoleID)
+	}
+
+	roles, err := s.repo.GetRoleSummaries(ctx, roleIDs)
+	if err != nil {
+		return err
+	}
+	var users map[uuid.UUID]*UserSummary
+	if withUsers {
+		if users, err = s.repo.GetUserSummaries(ctx, userIDs); err != nil {
+			return err
+		}
+	}
+	var teams map[uuid.UUID]*TeamSummary
+	if withTeams {
+		if teams, err = s.repo.GetTeamSummaries(ctx, teamIDs); err != nil {
+			return err
+		}
+	}
+
+	for _, m := range memberships {
+		m.Role = roles[m.RoleID]
+		m.User = users[m.UserID]
+		m.Team = teams[m.TeamID]
+	}
+	return nil
 }
 
 func (s *Service) AddMember(ctx context.Context, teamID uuid.UUID, userEmail string, roleID uuid.UUID) (*TeamMembership, error) {
@@ -507,30 +1446,148 @@ func (s *Service) AddMember(ctx context.Context, teamID uuid.UUID, userEmail str
 	if err := s.repo.CreateMembership(ctx, membership); err != nil {
 		return nil, err
 	}
+	s.bus.Publish(ctx, events.Event{Type: events.MembershipCreated, TeamID: teamID, Payload: membership})
 	return membership, nil
 }
 
+// UpdateMemberRole moves a member to another role of the team. Moving the
+// last member with team:manage to a role without it fails with ErrLastAdmin.
+func (s *Service) UpdateMemberRole(ctx context.Context, teamID, userID, roleID uuid.UUID) (*TeamMembership, error) {
+	role, err := s.teamRole(ctx, teamID, roleID)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, fmt.Errorf("%w: role %s does not belong to this team", ErrInvalidRole, roleID)
+		}
+		return nil, err
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if !slices.Contains(role.Permissions, PermTeamManage) {
+		if err := s.checkNotLastManager(ctx, tx, teamID, userID); err != nil {
+			return nil, err
+		}
+	}
+	membership, err := s.repo.UpdateMembershipRole(ctx, tx, teamID, userID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, ErrNotFound
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.bus.Publish(ctx, events.Event{Type: events.MembershipUpdated, TeamID: teamID, Payload: membership})
+	if err := s.hydrateMemberships(ctx, []*TeamMembership{membership}, true, false); err != nil {
+		return nil, err
+	}
+	return membership, nil
+}
+
+// RemoveMember removes a user from a team. The last member with team:manage
+// cannot be removed.
 func (s *Service) RemoveMember(ctx context.Context, teamID, userID uuid.UUID) error {
-	return s.repo.DeleteMembership(ctx, teamID, userID)
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := s.checkNotLastManager(ctx, tx, teamID, userID); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteMembership(ctx, tx, teamID, userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.bus.Publish(ctx, events.Event{
+		Type:    events.MembershipDeleted,
+		TeamID:  teamID,
+		Payload: &TeamMembership{TeamID: teamID, UserID: userID},
+	})
+	return nil
+}
+
+// checkNotLastManager locks the team's memberships and fails with
+// ErrLastAdmin when the user is the only member who can manage the team
+func (s *Service) checkNotLastManager(ctx context.Context, tx *sql.Tx, teamID, userID uuid.UUID) error {
+	if err := s.repo.LockTeam(ctx, tx, teamID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotFound
+		}
+		return err
+	}
+	last, err := s.repo.IsLastTeamManager(ctx, tx, teamID, userID)
+	if err != nil {
+		return err
+	}
+	if last {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// checkRoleNotLastManager fails with ErrLastAdmin when the members holding
+// roleID are the only ones who can manage the team. The caller must hold the
+// team lock.
+func (s *Service) checkRoleNotLastManager(ctx context.Context, tx *sql.Tx, teamID, roleID uuid.UUID) error {
+	members, err := s.repo.CountRoleMembers(ctx, tx, roleID)
+	if err != nil || members == 0 {
+		return err
+	}
+	others, err := s.repo.CountTeamManagers(ctx, tx, teamID, roleID)
+	if err != nil {
+		return err
+	}
+	if others == 0 {
+		return ErrLastAdmin
+	}
+	return nil
 }
 
+// GetUserPermissions returns the permissions of the user's role in the team,
+// or ErrForbidden when they are not a member. Results may come from the
+// permission cache and must not be modified.
 func (s *Service) GetUserPermissions(ctx context.Context, teamID, userID uuid.UUID) ([]string, error) {
+	permissions, member, generation, ok := s.permissionCache.get(teamID, userID)
+	if ok {
+		if !member {
+			return nil, ErrForbidden
+		}
+		return permissions, nil
+	}
+
 	membership, err := s.repo.GetMembership(ctx, teamID, userID)
 	if err != nil {
 		return nil, err
 	}
 	if membership == nil {
+		s.permissionCache.put(teamID, userID, generation, nil, false)
 		return nil, ErrForbidden
 	}
 
-	role, err := s.repo.GetRoleByID(ctx, membership.RoleID)
-	if err != nil {
-		return nil, err
-	}
-	if role == nil {
-		return nil, ErrForbidden
+	role, ok := s.lookups.role(ctx, membership.RoleID)
+	if !ok {
+		role, err = s.repo.GetRoleByID(ctx, membership.RoleID)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			s.permissionCache.put(teamID, userID, generation, nil, false)
+			return nil, ErrForbidden
+		}
+		s.lookups.putRole(ctx, role)
 	}
 
+	s.permissionCache.put(teamID, userID, generation, role.Permissions, true)
 	return role.Permissions, nil
 }
 
@@ -592,20 +1649,30 @@ func (s *Service) ValidateAPIKey(ctx context.Context, keyString string) (*APIKey
 	hash := sha256.Sum256([]byte(keyString))
 	keyHash := hex.EncodeToString(hash[:])
 
-	apiKey, err := s.repo.GetAPIKeyByHash(ctx, keyHash)
-	if err != nil {
-		return nil, err
+	apiKey, ok := s.lookups.apiKey(ctx, keyHash)
+	if !ok {
+		var err error
+		apiKey, err = s.repo.GetAPIKeyByHash(ctx, keyHash)
+		if err != nil {
+			return nil, err
+		}
+		if apiKey == nil {
+			return nil, ErrUnauthorized
+		}
+		s.lookups.putAPIKey(ctx, apiKey)
 	}
-	if apiKey == nil {
+	// The lookup is by hash, so its timing says nothing about the key. The
+	// stored hash is confirmed in constant time all the same, so a row that
+	// matched loosely can never authenticate.
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(keyHash)) != 1 {
 		return nil, ErrUnauthorized
 	}
 
-	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
+	now := time.Now()
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(now) {
 		return nil, ErrUnauthorized
 	}
-
-	// Update last used
-	go s.repo.UpdateAPIKeyLastUsed(context.Background(), apiKey.ID)
+	s.keyUsage.Touch(apiKey.ID, now)
 
 	return apiKey, nil
 }
@@ -615,5 +1682,12 @@ func (s *Service) GetAPIKeys(ctx context.Context, teamID uuid.UUID) ([]*APIKey,
 }
 
 func (s *Service) DeleteAPIKey(ctx context.Context, teamID, id uuid.UUID) error {
-	return s.repo.DeleteAPIKey(ctx, teamID, id)
+	keyHash, err := s.repo.DeleteAPIKey(ctx, teamID, id)
+	if err != nil {
+		return err
+	}
+	if keyHash != "" {
+		s.lookups.invalidateAPIKeys(ctx, []string{keyHash})
+	}
+	return nil
 }
@@ -3,10 +3,16 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
 )
 
 // MockRepository implements a mock repository for testing
@@ -438,3 +444,164 @@ func TestCheckSuperAdminStatus_UserNotFound(t *testing.T) {
 		t.Error("CheckSuperAdminStatus should return nil for non-existent user")
 	}
 }
+
+func TestTokenInfo(t *testing.T) {
+	issued := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+	claims := &JWTClaims{RegisteredClaims: jwt.RegisteredClaims{
+		IssuedAt:  jwt.NewNumericDate(issued),
+		ExpiresAt: jwt.NewNumericDate(issued.Add(24 * time.Hour)),
+	}}
+	info := claims.TokenInfo()
+	if info.Type != TokenTypeJWT || !info.IssuedAt.Equal(issued) || !info.ExpiresAt.Equal(issued.Add(24*time.Hour)) {
+		t.Errorf("jwt token info = %+v", info)
+	}
+	if info.Scopes != nil || info.TeamID != nil {
+		t.Error("jwt tokens should not report scopes or a team")
+	}
+
+	key := &APIKey{ID: uuid.New(), TeamID: uuid.New(), Permissions: []string{PermEntityRead}, CreatedAt: issued}
+	info = key.TokenInfo()
+	if info.Type != TokenTypeAPIKey || *info.APIKeyID != key.ID || *info.TeamID != key.TeamID || info.ExpiresAt != nil {
+		t.Errorf("api key token info = %+v", info)
+	}
+	if len(info.Scopes) != 1 || info.Scopes[0] != PermEntityRead {
+		t.Errorf("scopes = %v, want the key's permissions", info.Scopes)
+	}
+}
+
+func TestWhoAmIResponse_OmitsMissingUser(t *testing.T) {
+	resp := &WhoAmIResponse{Memberships: []*MembershipInfo{}, Token: &TokenInfo{Type: TokenTypeAPIKey}}
+	encoded, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"memberships":[],"token":{"type":"api_key"}}`; string(encoded) != want {
+		t.Errorf("json = %s, want %s", encoded, want)
+	}
+
+	resp.User = &User{Email: "jane@example.com"}
+	encoded, _ = json.Marshal(resp)
+	if !strings.Contains(string(encoded), `"email":"jane@example.com"`) {
+		t.Errorf("user fields should be inlined, got %s", encoded)
+	}
+}
+
+func TestJWTClaims_TeamPermissions(t *testing.T) {
+	now := time.Now()
+	teamID := uuid.New()
+	claims := &JWTClaims{Memberships: &MembershipClaims{
+		ValidUntil: now.Add(time.Minute).Unix(),
+		Teams:      []MembershipDigest{{TeamID: teamID, Permissions: []string{PermEntityRead}}},
+	}}
+
+	if perms, ok := claims.TeamPermissions(teamID, now); !ok || len(perms) != 1 || perms[0] != PermEntityRead {
+		t.Errorf("embedded team = %v, %v", perms, ok)
+	}
+	if _, ok := claims.TeamPermissions(uuid.New(), now); ok {
+		t.Error("teams missing from the digest must fall back to the database")
+	}
+	if _, ok := claims.TeamPermissions(teamID, now.Add(2*time.Minute)); ok {
+		t.Error("an expired digest must not be trusted")
+	}
+	if _, ok := (&JWTClaims{}).TeamPermissions(teamID, now); ok {
+		t.Error("tokens without a digest must fall back to the database")
+	}
+}
+
+func TestGenerateToken_MembershipClaims(t *testing.T) {
+	s := &Service{config: &config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+	teamID := uuid.New()
+	expiresAt := time.Now().Add(time.Minute).Truncate(time.Second)
+	memberships := &MembershipClaims{
+		ValidUntil: expiresAt.Add(time.Hour).Unix(),
+		Teams:      []MembershipDigest{{TeamID: teamID, Permissions: []string{PermBlueprintRead}}},
+	}
+
+	token, err := s.generateToken(&User{ID: uuid.New(), Email: "jane@example.com"}, memberships, false, expiresAt)
+	if err != nil {
+		t.Fatalf("generateToken: %v", err)
+	}
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.Memberships == nil || len(claims.Memberships.Teams) != 1 || claims.Memberships.Teams[0].TeamID != teamID {
+		t.Fatalf("memberships = %+v", claims.Memberships)
+	}
+	if claims.Memberships.ValidUntil != expiresAt.Unix() {
+		t.Errorf("digest valid until %d, want it capped at token expiry %d", claims.Memberships.ValidUntil, expiresAt.Unix())
+	}
+	if info := claims.TokenInfo(); len(info.MembershipTeams) != 1 || info.MembershipsValidUntil == nil {
+		t.Errorf("token info = %+v", info)
+	}
+}
+
+func TestAuditTarget(t *testing.T) {
+	target := uuid.New()
+	tests := []struct {
+		log  *AuditLog
+		want bool
+	}{
+		{&AuditLog{EntityType: "user", EntityID: target.String()}, true},
+		{&AuditLog{EntityType: "user", EntityID: "not-a-uuid"}, false},
+		{&AuditLog{EntityType: "team", EntityID: target.String()}, false},
+	}
+	for _, tt := range tests {
+		id, ok := auditTarget(tt.log)
+		if ok != tt.want || (ok && id != target) {
+			t.Errorf("auditTarget(%s %s) = %v, %v", tt.log.EntityType, tt.log.EntityID, id, ok)
+		}
+	}
+}
+
+func TestImpersonationClaims_RoundTrip(t *testing.T) {
+	s := &Service{config: &config.JWTConfig{Secret: strings.Repeat("s", config.MinJWTSecretLength)}}
+	isSuperAdmin := false
+	impersonation := &ImpersonationClaims{SessionID: uuid.New(), ActorID: uuid.New(), ActorEmail: "admin@example.com"}
+	token, err := s.signToken(&JWTClaims{
+		UserID:        uuid.New(),
+		IsSuperAdmin:  &isSuperAdmin,
+		Impersonation: impersonation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	})
+	if err != nil {
+		t.Fatalf("signToken: %v", err)
+	}
+	claims, err := s.ValidateToken(token)
+	if err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+	if claims.Impersonation == nil || *claims.Impersonation != *impersonation {
+		t.Fatalf("impersonation = %+v, want %+v", claims.Impersonation, impersonation)
+	}
+	if info := claims.TokenInfo(); info.Impersonation == nil || info.Impersonation.ActorID != impersonation.ActorID {
+		t.Errorf("token info should report the impersonating admin, got %+v", info.Impersonation)
+	}
+}
+
+func TestImpersonate_RejectsInvalidRequests(t *testing.T) {
+	s := &Service{config: &config.JWTConfig{ImpersonationMinutes: 30}}
+	actorID := uuid.New()
+
+	tests := []struct {
+		name   string
+		target uuid.UUID
+		req    ImpersonateRequest
+		want   error
+	}{
+		{"missing reason", uuid.New(), ImpersonateRequest{Reason: "  "}, ErrInvalidImpersonation},
+		{"too long", uuid.New(), ImpersonateRequest{Reason: "ticket 42", DurationMinutes: 31}, ErrInvalidImpersonation},
+		{"negative duration", uuid.New(), ImpersonateRequest{Reason: "ticket 42", DurationMinutes: -1}, ErrInvalidImpersonation},
+		{"self", actorID, ImpersonateRequest{Reason: "ticket 42"}, ErrSelfModification},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Impersonate(context.Background(), actorID, tt.target, &tt.req, false, nil, nil)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
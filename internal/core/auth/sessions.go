@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SessionCheckTTL is how long a user's status and revocation time are cached
+// for token validation. Deactivations on this instance apply at once; other
+// instances reject the user's tokens within this window.
+const SessionCheckTTL = 1 * time.Minute
+
+// sessionState is what token validation needs to know about a user
+type sessionState struct {
+	active    bool
+	revokedAt *time.Time
+	expires   time.Time
+}
+
+// sessionCache caches sessionState per user. A nil *sessionCache caches
+// nothing.
+type sessionCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[uuid.UUID]sessionState
+}
+
+func newSessionCache(ttl time.Duration) *sessionCache {
+	return &sessionCache{ttl: ttl, now: time.Now, entries: make(map[uuid.UUID]sessionState)}
+}
+
+func (c *sessionCache) get(userID uuid.UUID) (sessionState, bool) {
+	if c == nil {
+		return sessionState{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state, ok := c.entries[userID]
+	if !ok || c.now().After(state.expires) {
+		return sessionState{}, false
+	}
+	return state, true
+}
+
+func (c *sessionCache) set(userID uuid.UUID, state sessionState) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	// Lazy cleanup keeps the map bounded by recently active users
+	for id, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, id)
+		}
+	}
+	state.expires = now.Add(c.ttl)
+	c.entries[userID] = state
+}
+
+func (c *sessionCache) invalidate(userID uuid.UUID) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, userID)
+}
+
+// valid reports whether a token issued at issuedAt may still be used. JWT
+// issue times have second precision, so a token issued in the same second as
+// a revocation is treated as revoked.
+func (s sessionState) valid(issuedAt time.Time) bool {
+	if !s.active {
+		return false
+	}
+	return s.revokedAt == nil || issuedAt.After(*s.revokedAt)
+}
+
+// CheckSession rejects tokens of users that are no longer active or whose
+// sessions were revoked after the token was issued
+func (s *Service) CheckSession(ctx context.Context, userID uuid.UUID, issuedAt time.Time) error {
+	state, ok := s.sessions.get(userID)
+	if !ok {
+		status, revokedAt, found, err := s.repo.GetSessionState(ctx, userID)
+		if err != nil {
+			return err
+		}
+		state = sessionState{active: found && status == UserStatusActive, revokedAt: revokedAt}
+		s.sessions.set(userID, state)
+	}
+	if !state.valid(issuedAt) {
+		return ErrSessionRevoked
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSessionState_Valid(t *testing.T) {
+	revoked := time.Date(2024, 1, 15, 10, 30, 0, 500_000_000, time.UTC)
+	tests := []struct {
+		name     string
+		state    sessionState
+		issuedAt time.Time
+		want     bool
+	}{
+		{"active, never revoked", sessionState{active: true}, revoked, true},
+		{"inactive", sessionState{active: false}, revoked.Add(time.Hour), false},
+		{"issued before revocation", sessionState{active: true, revokedAt: &revoked}, revoked.Add(-time.Minute), false},
+		{"issued in the revocation second", sessionState{active: true, revokedAt: &revoked}, revoked.Truncate(time.Second), false},
+		{"issued after revocation", sessionState{active: true, revokedAt: &revoked}, revoked.Add(time.Second), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.valid(tt.issuedAt); got != tt.want {
+				t.Errorf("valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionCache(t *testing.T) {
+	now := time.Now()
+	c := newSessionCache(time.Minute)
+	c.now = func() time.Time { return now }
+	id := uuid.New()
+
+	c.set(id, sessionState{active: true})
+	if state, ok := c.get(id); !ok || !state.active {
+		t.Fatalf("get after set = %+v, %v", state, ok)
+	}
+	c.invalidate(id)
+	if _, ok := c.get(id); ok {
+		t.Error("entry survived invalidate")
+	}
+
+	c.set(id, sessionState{active: true})
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get(id); ok {
+		t.Error("entry survived its TTL")
+	}
+
+	var nilCache *sessionCache
+	nilCache.set(id, sessionState{active: true})
+	if _, ok := nilCache.get(id); ok {
+		t.Error("nil cache returned an entry")
+	}
+}
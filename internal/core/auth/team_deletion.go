@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+var ErrInvalidDeletionToken = errors.New("invalid or expired team deletion token")
+
+// teamDeletionTTL is how long a team deletion confirmation token is valid
+const teamDeletionTTL = 10 * time.Minute
+
+// RequestTeamDeletion starts the deletion of a team. It returns a
+// confirmation token that DeleteTeam must be given within teamDeletionTTL,
+// and what deleting the team would remove. A new request replaces any
+// earlier token.
+func (s *Service) RequestTeamDeletion(ctx context.Context, teamID uuid.UUID, userID *uuid.UUID) (*TeamDeletionResponse, error) {
+	team, err := s.repo.GetTeamByID(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, ErrNotFound
+	}
+
+	summary, err := s.repo.CountTeamContents(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(teamDeletionTTL)
+	if err := s.repo.SetTeamDeletionToken(ctx, teamID, hashVerificationToken(token), expiresAt, userID); err != nil {
+		return nil, err
+	}
+	return &TeamDeletionResponse{ConfirmationToken: token, ExpiresAt: expiresAt, Summary: summary}, nil
+}
+
+// CheckTeamDeletion verifies a confirmation token without using it, so work
+// that must precede the deletion, such as a final export, is not wasted on
+// a token DeleteTeam would reject
+func (s *Service) CheckTeamDeletion(ctx context.Context, teamID uuid.UUID, token string) error {
+	ok, err := s.repo.TeamDeletionTokenValid(ctx, teamID, hashVerificationToken(token))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidDeletionToken
+	}
+	return nil
+}
+
+// DeleteTeam deletes a team and everything it owns in one transaction:
+// entities, blueprints with their relations, scorecards and actions, API
+// keys, memberships, roles, and the team's views, integrations and
+// counters. Audit logs are kept with their team reference cleared. The
+// confirmation token from RequestTeamDeletion is required.
+func (s *Service) DeleteTeam(ctx context.Context, teamID uuid.UUID, actorID *uuid.UUID, token string, ipAddress, userAgent *string) (*TeamDeletionSummary, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	team, err := s.repo.LockTeamForDeletion(ctx, tx, teamID, hashVerificationToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, ErrInvalidDeletionToken
+	}
+	keyHashes, err := s.repo.TeamAPIKeyHashes(ctx, tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.repo.DeleteTeamContents(ctx, tx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.lookups.invalidateAPIKeys(ctx, keyHashes)
+	s.bus.Publish(ctx, events.Event{Type: events.TeamDeleted, TeamID: teamID})
+
+	s.auditTeamDeletion(team, summary, actorID, ipAddress, userAgent)
+	return summary, nil
+}
+
+// auditTeamDeletion records a team's deletion. The entry has no team
+// reference, which would be cleared with the team anyway, so the team is
+// named in its data.
+func (s *Service) auditTeamDeletion(team *Team, summary *TeamDeletionSummary, actorID *uuid.UUID, ipAddress, userAgent *string) {
+	result := "success"
+	auditLog := &AuditLog{
+		ID:         uuid.New(),
+		UserID:     actorID,
+		ActorType:  "team_member",
+		EntityType: "team",
+		EntityID:   team.ID.String(),
+		Action:     "delete",
+		OldData:    map[string]any{"name": team.Name, "slug": team.Slug},
+		NewData: map[string]any{
+			"members":    summary.Members,
+			"roles":      summary.Roles,
+			"api_keys":   summary.APIKeys,
+			"blueprints": summary.Blueprints,
+			"entities":   summary.Entities,
+		},
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		ResultStatus: &result,
+	}
+	// Log asynchronously to not block the response
+	go func() {
+		if err := s.repo.CreateAuditLog(context.Background(), auditLog); err != nil {
+			log.Printf("ERROR: failed to create audit log for deletion of team %s: %v", auditLog.EntityID, err)
+		}
+	}()
+}
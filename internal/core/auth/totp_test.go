@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key from the RFC 6238 test vectors
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestVerifyTOTP_RFCVectors(t *testing.T) {
+	// RFC 6238 appendix B lists 8 digit codes; the 6 digit code is their tail
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		step, ok := verifyTOTP(rfcSecret, tt.code, time.Unix(tt.unix, 0), 0)
+		if !ok {
+			t.Errorf("verifyTOTP(%s at %d) rejected a valid code", tt.code, tt.unix)
+			continue
+		}
+		if want := tt.unix / totpPeriod; step != want {
+			t.Errorf("verifyTOTP(%s at %d) step = %d, want %d", tt.code, tt.unix, step, want)
+		}
+	}
+}
+
+func TestVerifyTOTP_Window(t *testing.T) {
+	now := time.Unix(59, 0) // step 1; "287082" is the code for step 1
+	if _, ok := verifyTOTP(rfcSecret, "287082", now.Add(totpPeriod*time.Second), 0); !ok {
+		t.Error("code from the previous period should be accepted")
+	}
+	if _, ok := verifyTOTP(rfcSecret, "287082", now.Add(2*totpPeriod*time.Second), 0); ok {
+		t.Error("code from two periods ago should be rejected")
+	}
+	if _, ok := verifyTOTP(rfcSecret, "287082", now, 1); ok {
+		t.Error("code at or before the last accepted step should be rejected")
+	}
+	if _, ok := verifyTOTP(rfcSecret, "287083", now, 0); ok {
+		t.Error("wrong code should be rejected")
+	}
+	if _, ok := verifyTOTP("not base32!", "287082", now, 0); ok {
+		t.Error("invalid secret should reject every code")
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := newTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != totpSecretSize {
+		t.Errorf("key length = %d, want %d", len(key), totpSecretSize)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := totpURI("Baseplate", "jane@example.com", "ABC")
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Baseplate:jane@example.com" {
+		t.Errorf("uri = %s", uri)
+	}
+	q := u.Query()
+	if q.Get("secret") != "ABC" || q.Get("issuer") != "Baseplate" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestBackupCodes(t *testing.T) {
+	codes, err := newBackupCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != backupCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), backupCodeCount)
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 9 || code[4] != '-' {
+			t.Errorf("code %q is not formatted xxxx-xxxx", code)
+		}
+		seen[code] = true
+	}
+	if len(seen) != len(codes) {
+		t.Error("codes should be distinct")
+	}
+
+	code := codes[0]
+	for _, typed := range []string{code, strings.ToUpper(code), strings.ReplaceAll(code, "-", ""), " " + code[:4] + " " + code[5:]} {
+		if hashBackupCode(typed) != hashBackupCode(code) {
+			t.Errorf("hashBackupCode(%q) should match %q", typed, code)
+		}
+	}
+}
+
+func TestIsTOTPCode(t *testing.T) {
+	for code, want := range map[string]bool{"123456": true, " 123456 ": true, "12345": false, "abcd-efgh": false, "12345a": false} {
+		if got := isTOTPCode(code); got != want {
+			t.Errorf("isTOTPCode(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
@@ -0,0 +1,348 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// loginChallengeTTL is how long the second step of a login may take
+const loginChallengeTTL = 5 * time.Minute
+
+// maxChallengeAttempts is how many wrong codes a login challenge survives
+const maxChallengeAttempts = 5
+
+// loginChallenge starts the second step of a login for a user with
+// two-factor authentication enabled
+func (s *Service) loginChallenge(ctx context.Context, userID uuid.UUID) (*AuthResponse, error) {
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetLoginChallenge(ctx, userID, hashVerificationToken(token), time.Now().Add(loginChallengeTTL)); err != nil {
+		return nil, err
+	}
+	return &AuthResponse{TwoFactorRequired: true, TwoFactorToken: token}, nil
+}
+
+// LoginTwoFactor completes a login with an authenticator or backup code. The
+// issued token records that a second factor was checked.
+func (s *Service) LoginTwoFactor(ctx context.Context, req *TwoFactorLoginRequest, ipAddress, userAgent *string) (*AuthResponse, error) {
+	if (req.Code == "") == (req.BackupCode == "") {
+		return nil, ErrInvalidCode
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	userID, err := s.repo.LockLoginChallenge(ctx, tx, hashVerificationToken(req.TwoFactorToken))
+	if err != nil {
+		return nil, err
+	}
+	if userID == uuid.Nil {
+		return nil, ErrInvalidChallenge
+	}
+	state, err := s.repo.LockTwoFactorState(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || !state.Enabled {
+		return nil, ErrInvalidChallenge
+	}
+
+	method, ok, err := s.checkSecondFactor(ctx, tx, userID, state, req.Code, req.BackupCode)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		if err := s.repo.FailLoginChallenge(ctx, tx, userID, maxChallengeAttempts); err != nil {
+			return nil, err
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, err
+		}
+		s.auditSelf(userID, "login_2fa", "failure", nil, map[string]any{"method": method}, ipAddress, userAgent)
+		return nil, ErrInvalidCode
+	}
+	if err := s.repo.ClearLoginChallenge(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.auditSelf(userID, "login_2fa", "success", nil, map[string]any{"method": method}, ipAddress, userAgent)
+
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.Status != UserStatusActive {
+		return nil, ErrInactiveUser
+	}
+	memberships, err := s.membershipClaims(ctx, user.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.generateToken(user, memberships, true, time.Now().Add(s.config.ExpirationDuration()))
+	if err != nil {
+		return nil, err
+	}
+	return &AuthResponse{Token: token, User: user}, nil
+}
+
+// checkSecondFactor checks an authenticator code, or else a backup code,
+// and consumes it. method names which one was checked.
+func (s *Service) checkSecondFactor(ctx context.Context, tx *sql.Tx, userID uuid.UUID, state *TwoFactorState, code, backupCode string) (method string, ok bool, err error) {
+	if code != "" {
+		if state.Secret == nil {
+			return "totp", false, nil
+		}
+		step, ok := verifyTOTP(*state.Secret, code, time.Now(), state.LastStep)
+		if !ok {
+			return "totp", false, nil
+		}
+		return "totp", true, s.repo.SetTOTPStep(ctx, tx, userID, step)
+	}
+	ok, err = s.repo.UseBackupCode(ctx, tx, userID, hashBackupCode(backupCode))
+	return "backup_code", ok, err
+}
+
+// EnrollTwoFactor generates a TOTP secret for the caller. It takes effect
+// once ConfirmTwoFactor has seen a code generated from it; enrolling again
+// before that replaces the secret.
+func (s *Service) EnrollTwoFactor(ctx context.Context, userID uuid.UUID) (*TwoFactorEnrollResponse, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.repo.SetTOTPSecret(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !stored {
+		return nil, ErrTwoFactorEnabled
+	}
+	return &TwoFactorEnrollResponse{
+		Secret:          secret,
+		ProvisioningURI: totpURI(s.twoFactor.Issuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmTwoFactor enables two-factor authentication once the caller proves
+// the authenticator app works. It returns the backup codes and a token for
+// the current session, which expires when the old one does and counts as a
+// two-factor login.
+func (s *Service) ConfirmTwoFactor(ctx context.Context, userID uuid.UUID, req *TwoFactorCodeRequest, expiresAt time.Time, ipAddress, userAgent *string) (*BackupCodesResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	state, err := s.repo.LockTwoFactorState(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, ErrNotFound
+	}
+	if state.Enabled {
+		return nil, ErrTwoFactorEnabled
+	}
+	if state.Secret == nil {
+		return nil, ErrTwoFactorPending
+	}
+	step, ok := verifyTOTP(*state.Secret, req.Code, time.Now(), state.LastStep)
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+	if err := s.repo.EnableTOTP(ctx, tx, userID, step); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceBackupCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.auditSelf(userID, "enable_2fa", "success",
+		map[string]any{"two_factor_enabled": false}, map[string]any{"two_factor_enabled": true}, ipAddress, userAgent)
+
+	auth, err := s.RefreshToken(ctx, userID, expiresAt, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return &BackupCodesResponse{BackupCodes: codes, Token: auth.Token}, nil
+}
+
+// RegenerateBackupCodes replaces the caller's backup codes. It takes an
+// authenticator code so a lost set cannot be renewed with one of its own.
+func (s *Service) RegenerateBackupCodes(ctx context.Context, userID uuid.UUID, req *TwoFactorCodeRequest, ipAddress, userAgent *string) (*BackupCodesResponse, error) {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	state, err := s.repo.LockTwoFactorState(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if state == nil {
+		return nil, ErrNotFound
+	}
+	if !state.Enabled {
+		return nil, ErrTwoFactorDisabled
+	}
+	if _, ok, err := s.checkSecondFactor(ctx, tx, userID, state, req.Code, ""); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrInvalidCode
+	}
+	codes, err := s.replaceBackupCodes(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	s.auditSelf(userID, "regenerate_backup_codes", "success", nil, nil, ipAddress, userAgent)
+	return &BackupCodesResponse{BackupCodes: codes}, nil
+}
+
+// DisableTwoFactor turns off two-factor authentication for the caller after
+// checking their password and a current code. code may be a backup code.
+func (s *Service) DisableTwoFactor(ctx context.Context, userID uuid.UUID, req *DisableTwoFactorRequest, ipAddress, userAgent *string) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrNotFound
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		s.auditSelf(userID, "disable_2fa", "failure", nil, nil, ipAddress, userAgent)
+		return ErrWrongPassword
+	}
+
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	state, err := s.repo.LockTwoFactorState(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+	if state == nil {
+		return ErrNotFound
+	}
+	if !state.Enabled {
+		return ErrTwoFactorDisabled
+	}
+	code, backupCode := req.Code, ""
+	if !isTOTPCode(code) {
+		code, backupCode = "", req.Code
+	}
+	if _, ok, err := s.checkSecondFactor(ctx, tx, userID, state, code, backupCode); err != nil {
+		return err
+	} else if !ok {
+		return ErrInvalidCode
+	}
+	if err := s.repo.DisableTOTP(ctx, tx, userID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.auditSelf(userID, "disable_2fa", "success",
+		map[string]any{"two_factor_enabled": true}, map[string]any{"two_factor_enabled": false}, ipAddress, userAgent)
+	return nil
+}
+
+// ResetTwoFactor turns off two-factor authentication for a user who lost
+// their authenticator and backup codes
+func (s *Service) ResetTwoFactor(ctx context.Context, actorID, targetUserID uuid.UUID, ipAddress, userAgent *string) error {
+	tx, err := s.repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	user, err := s.repo.LockUser(ctx, tx, targetUserID)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Status == UserStatusDeleted {
+		return ErrNotFound
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorDisabled
+	}
+	if err := s.repo.DisableTOTP(ctx, tx, targetUserID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.auditAdmin(actorID, targetUserID, "reset_2fa",
+		map[string]any{"two_factor_enabled": true}, map[string]any{"two_factor_enabled": false}, ipAddress, userAgent)
+	return nil
+}
+
+// RequiresTwoFactor reports whether a member with the given permissions must
+// have logged in with a second factor to use the team. Only members who can
+// manage the team are held to the instance or team policy.
+func (s *Service) RequiresTwoFactor(ctx context.Context, teamID uuid.UUID, permissions []string) (bool, error) {
+	if !slices.Contains(permissions, PermTeamManage) {
+		return false, nil
+	}
+	if s.twoFactor != nil && s.twoFactor.RequireForManagers {
+		return true, nil
+	}
+	required, generation, ok := s.permissionCache.twoFactorPolicy(teamID)
+	if ok {
+		return required, nil
+	}
+	required, err := s.repo.TeamRequiresTwoFactor(ctx, teamID)
+	if err != nil {
+		return false, err
+	}
+	s.permissionCache.putTwoFactorPolicy(teamID, generation, required)
+	return required, nil
+}
+
+func (s *Service) replaceBackupCodes(ctx context.Context, tx *sql.Tx, userID uuid.UUID) ([]string, error) {
+	codes, err := newBackupCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashBackupCode(code)
+	}
+	if err := s.repo.ReplaceBackupCodes(ctx, tx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
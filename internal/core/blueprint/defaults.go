@@ -0,0 +1,103 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/baseplate/baseplate/internal/core/validation"
+)
+
+var ErrInvalidDefault = errors.New("invalid default")
+
+// Generators fill in a property the server generates on create
+const (
+	// GenerateUUID generates a random UUID string
+	GenerateUUID = "uuid"
+	// GenerateTimestamp generates the RFC 3339 time of creation, in UTC
+	GenerateTimestamp = "timestamp"
+	// GenerateDate generates the date of creation, in UTC
+	GenerateDate = "date"
+	// GenerateSequence generates the next number of a sequence per blueprint
+	// and property, starting at 1
+	GenerateSequence = "sequence"
+)
+
+// generatorTypes are the schema types each generator produces
+var generatorTypes = map[string]string{
+	GenerateUUID:      "string",
+	GenerateTimestamp: "string",
+	GenerateDate:      "string",
+	GenerateSequence:  "integer",
+}
+
+// PropertyDefault is how a top-level property is filled in when a create
+// leaves it out: with Value, or with a value of the Generated generator
+type PropertyDefault struct {
+	Property  string
+	Value     interface{}
+	Generated string
+}
+
+// Defaults returns the top-level properties of a schema with a `default`
+// or a `"generated"` generator, sorted by property
+func Defaults(schema map[string]interface{}) []PropertyDefault {
+	props, _ := schema["properties"].(map[string]interface{})
+	var defaults []PropertyDefault
+	for name, raw := range props {
+		prop, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if generated, ok := prop["generated"].(string); ok {
+			defaults = append(defaults, PropertyDefault{Property: name, Generated: generated})
+		} else if value, ok := prop["default"]; ok {
+			defaults = append(defaults, PropertyDefault{Property: name, Value: value})
+		}
+	}
+	sort.Slice(defaults, func(i, j int) bool { return defaults[i].Property < defaults[j].Property })
+	return defaults
+}
+
+// ValidateDefaults checks the generators of a schema's top-level properties,
+// and that their defaults are valid values of them
+func ValidateDefaults(schema map[string]interface{}) error {
+	props, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	validator := validation.NewValidator()
+	for _, name := range names {
+		prop, ok := props[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		value, hasDefault := prop["default"]
+		if raw, ok := prop["generated"]; ok {
+			generator, _ := raw.(string)
+			want, known := generatorTypes[generator]
+			if !known {
+				return fmt.Errorf("%w: %s: unknown generator %v, use uuid, timestamp, date or sequence", ErrInvalidDefault, name, raw)
+			}
+			if hasDefault {
+				return fmt.Errorf("%w: %s: a property is either generated or has a default", ErrInvalidDefault, name)
+			}
+			if typ, ok := prop["type"].(string); ok && typ != want && !(want == "integer" && typ == "number") {
+				return fmt.Errorf("%w: %s: the %s generator needs a %s property", ErrInvalidDefault, name, generator, want)
+			}
+			continue
+		}
+		if !hasDefault {
+			continue
+		}
+		// Schemas that do not compile are not checked here, as on create
+		err := validator.ValidatePartial(map[string]interface{}{name: value}, schema)
+		if ve := validation.GetValidationErrors(err); ve != nil {
+			return fmt.Errorf("%w: %s: %s", ErrInvalidDefault, name, ve.Error())
+		}
+	}
+	return nil
+}
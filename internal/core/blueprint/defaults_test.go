@@ -0,0 +1,67 @@
+package blueprint
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestDefaults(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"tier":    map[string]interface{}{"type": "string", "default": "bronze"},
+			"number":  map[string]interface{}{"type": "integer", "generated": "sequence"},
+			"owner":   map[string]interface{}{"type": "string"},
+			"tags":    map[string]interface{}{"type": "array", "default": []interface{}{}},
+			"billing": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"plan": map[string]interface{}{"default": "free"}}},
+		},
+	}
+	want := []PropertyDefault{
+		{Property: "number", Generated: GenerateSequence},
+		{Property: "tags", Value: []interface{}{}},
+		{Property: "tier", Value: "bronze"},
+	}
+	if got := Defaults(schema); !reflect.DeepEqual(got, want) {
+		t.Errorf("Defaults = %+v, want %+v", got, want)
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	schema := func(prop map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"type":       "object",
+			"required":   []interface{}{"owner"},
+			"properties": map[string]interface{}{"owner": map[string]interface{}{"type": "string"}, "p": prop},
+		}
+	}
+	valid := []map[string]interface{}{
+		{"type": "string", "default": "bronze"},
+		{"type": "string", "enum": []interface{}{"a", "b"}, "default": "b"},
+		{"type": "string", "generated": "uuid"},
+		{"type": "string", "format": "date-time", "generated": "timestamp"},
+		{"type": "string", "generated": "date"},
+		{"type": "integer", "generated": "sequence"},
+		{"type": "number", "generated": "sequence"},
+		{"generated": "sequence"},
+	}
+	for _, prop := range valid {
+		if err := ValidateDefaults(schema(prop)); err != nil {
+			t.Errorf("%v: %v", prop, err)
+		}
+	}
+
+	invalid := []map[string]interface{}{
+		{"type": "string", "default": 3},
+		{"type": "string", "enum": []interface{}{"a", "b"}, "default": "c"},
+		{"type": "string", "generated": "random"},
+		{"type": "string", "generated": true},
+		{"type": "integer", "generated": "uuid"},
+		{"type": "string", "generated": "sequence"},
+		{"type": "string", "generated": "uuid", "default": "x"},
+	}
+	for _, prop := range invalid {
+		if err := ValidateDefaults(schema(prop)); !errors.Is(err, ErrInvalidDefault) {
+			t.Errorf("%v: err = %v, want ErrInvalidDefault", prop, err)
+		}
+	}
+}
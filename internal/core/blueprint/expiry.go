@@ -0,0 +1,155 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidExpiryPolicy = errors.New("invalid expiry policy")
+
+// Expiry actions
+const (
+	// ExpireDelete deletes expired entities
+	ExpireDelete = "delete"
+	// ExpireArchive keeps expired entities and sets their archive property
+	// to true
+	ExpireArchive = "archive"
+)
+
+// MinExpiryTTL is the shortest TTL a policy may set; the sweeper only runs
+// every few seconds anyway
+const MinExpiryTTL = time.Minute
+
+// ExpiryPolicy makes a blueprint's entities expire, for ephemeral entities
+// such as preview environments. An entity expires at the date-time in its
+// Property when that is set and parses, and otherwise TTL after it was
+// created. Expired entities are deleted, or with the archive action kept with
+// the boolean ArchiveProperty set to true; archived entities do not expire.
+type ExpiryPolicy struct {
+	// TTL is a duration such as "72h"
+	TTL             string `json:"ttl,omitempty"`
+	Property        string `json:"property,omitempty"`
+	Action          string `json:"action,omitempty"` // delete when empty
+	ArchiveProperty string `json:"archive_property,omitempty"`
+}
+
+// IsZero reports whether the policy expires nothing, as no policy does
+func (p *ExpiryPolicy) IsZero() bool {
+	return p == nil || (p.TTL == "" && p.Property == "")
+}
+
+// ActionOrDefault returns the action taken on expired entities
+func (p *ExpiryPolicy) ActionOrDefault() string {
+	if p.Action == "" {
+		return ExpireDelete
+	}
+	return p.Action
+}
+
+// Validate checks the TTL and action, and that the properties are top-level
+// properties of the schema with the right types
+func (p *ExpiryPolicy) Validate(schema map[string]interface{}) error {
+	if p.IsZero() {
+		return nil
+	}
+	if p.TTL != "" {
+		ttl, err := time.ParseDuration(p.TTL)
+		if err != nil {
+			return fmt.Errorf("%w: ttl %q is not a duration such as 72h", ErrInvalidExpiryPolicy, p.TTL)
+		}
+		if ttl < MinExpiryTTL {
+			return fmt.Errorf("%w: ttl must be at least %s", ErrInvalidExpiryPolicy, MinExpiryTTL)
+		}
+	}
+	if p.Property != "" {
+		if err := expiryProperty(schema, p.Property, "string"); err != nil {
+			return err
+		}
+	}
+	switch p.ActionOrDefault() {
+	case ExpireDelete:
+		if p.ArchiveProperty != "" {
+			return fmt.Errorf("%w: archive_property is only used by the archive action", ErrInvalidExpiryPolicy)
+		}
+	case ExpireArchive:
+		if p.ArchiveProperty == "" {
+			return fmt.Errorf("%w: the archive action needs an archive_property", ErrInvalidExpiryPolicy)
+		}
+		if p.ArchiveProperty == p.Property {
+			return fmt.Errorf("%w: archive_property must differ from property", ErrInvalidExpiryPolicy)
+		}
+		if err := expiryProperty(schema, p.ArchiveProperty, "boolean"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: unknown action %q", ErrInvalidExpiryPolicy, p.Action)
+	}
+	return nil
+}
+
+// expiryProperty checks that the schema declares a top-level property of the
+// given type
+func expiryProperty(schema map[string]interface{}, name, wantType string) error {
+	if strings.Contains(name, ".") {
+		return fmt.Errorf("%w: %q is not a top-level property", ErrInvalidExpiryPolicy, name)
+	}
+	props, _ := schema["properties"].(map[string]interface{})
+	prop, ok := props[name].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: %s is not in the schema", ErrInvalidExpiryPolicy, name)
+	}
+	if t, _ := prop["type"].(string); t != wantType {
+		return fmt.Errorf("%w: %s must be a %s property", ErrInvalidExpiryPolicy, name, wantType)
+	}
+	return nil
+}
+
+// ExpiresAt returns when an entity created at createdAt with the given data
+// expires, or nil when it does not
+func (p *ExpiryPolicy) ExpiresAt(createdAt time.Time, data map[string]interface{}) *time.Time {
+	if p.IsZero() {
+		return nil
+	}
+	if p.ActionOrDefault() == ExpireArchive {
+		if archived, _ := data[p.ArchiveProperty].(bool); archived {
+			return nil
+		}
+	}
+	if p.Property != "" {
+		if value, ok := data[p.Property].(string); ok {
+			if at, ok := parseExpiry(value); ok {
+				return &at
+			}
+		}
+	}
+	if p.TTL != "" {
+		// Validate rejected unparseable TTLs
+		if ttl, err := time.ParseDuration(p.TTL); err == nil {
+			at := createdAt.Add(ttl)
+			return &at
+		}
+	}
+	return nil
+}
+
+// parseExpiry accepts RFC 3339 date-times and, as midnight UTC, dates
+func parseExpiry(value string) (time.Time, bool) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, true
+	}
+	if at, err := time.Parse(time.DateOnly, value); err == nil {
+		return at, true
+	}
+	return time.Time{}, false
+}
+
+// normalizeExpiryPolicy drops a policy that expires nothing, so blueprints
+// without one are stored alike
+func normalizeExpiryPolicy(p *ExpiryPolicy) *ExpiryPolicy {
+	if p.IsZero() {
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,85 @@
+package blueprint
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var expirySchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"expires":  map[string]interface{}{"type": "string", "format": "date-time"},
+		"archived": map[string]interface{}{"type": "boolean"},
+		"replicas": map[string]interface{}{"type": "integer"},
+	},
+}
+
+func TestExpiryPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy *ExpiryPolicy
+		valid  bool
+	}{
+		{"nil", nil, true},
+		{"empty", &ExpiryPolicy{}, true},
+		{"ttl", &ExpiryPolicy{TTL: "72h"}, true},
+		{"property", &ExpiryPolicy{Property: "expires"}, true},
+		{"archive", &ExpiryPolicy{TTL: "24h", Action: ExpireArchive, ArchiveProperty: "archived"}, true},
+		{"bad ttl", &ExpiryPolicy{TTL: "3 days"}, false},
+		{"short ttl", &ExpiryPolicy{TTL: "30s"}, false},
+		{"unknown property", &ExpiryPolicy{Property: "ends"}, false},
+		{"nested property", &ExpiryPolicy{Property: "meta.expires"}, false},
+		{"property not a string", &ExpiryPolicy{Property: "replicas"}, false},
+		{"unknown action", &ExpiryPolicy{TTL: "1h", Action: "hide"}, false},
+		{"archive without property", &ExpiryPolicy{TTL: "1h", Action: ExpireArchive}, false},
+		{"archive property not a boolean", &ExpiryPolicy{TTL: "1h", Action: ExpireArchive, ArchiveProperty: "replicas"}, false},
+		{"archive property on delete", &ExpiryPolicy{TTL: "1h", ArchiveProperty: "archived"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(expirySchema)
+			if tt.valid && err != nil {
+				t.Errorf("Validate() = %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidExpiryPolicy) {
+				t.Errorf("Validate() = %v, want ErrInvalidExpiryPolicy", err)
+			}
+		})
+	}
+}
+
+func TestExpiryPolicy_ExpiresAt(t *testing.T) {
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	at := func(s string) *time.Time {
+		v, _ := time.Parse(time.RFC3339, s)
+		return &v
+	}
+	tests := []struct {
+		name   string
+		policy *ExpiryPolicy
+		data   map[string]interface{}
+		want   *time.Time
+	}{
+		{"no policy", nil, nil, nil},
+		{"ttl", &ExpiryPolicy{TTL: "48h"}, nil, at("2026-03-03T12:00:00Z")},
+		{"property", &ExpiryPolicy{Property: "expires"}, map[string]interface{}{"expires": "2026-04-01T08:30:00+02:00"}, at("2026-04-01T06:30:00Z")},
+		{"date", &ExpiryPolicy{Property: "expires"}, map[string]interface{}{"expires": "2026-04-01"}, at("2026-04-01T00:00:00Z")},
+		{"property over ttl", &ExpiryPolicy{TTL: "48h", Property: "expires"}, map[string]interface{}{"expires": "2026-03-02T00:00:00Z"}, at("2026-03-02T00:00:00Z")},
+		{"unset property falls back to ttl", &ExpiryPolicy{TTL: "48h", Property: "expires"}, map[string]interface{}{}, at("2026-03-03T12:00:00Z")},
+		{"unparseable property falls back to ttl", &ExpiryPolicy{TTL: "48h", Property: "expires"}, map[string]interface{}{"expires": "soon"}, at("2026-03-03T12:00:00Z")},
+		{"unset property without ttl", &ExpiryPolicy{Property: "expires"}, map[string]interface{}{}, nil},
+		{"archived", &ExpiryPolicy{TTL: "48h", Action: ExpireArchive, ArchiveProperty: "archived"}, map[string]interface{}{"archived": true}, nil},
+		{"not archived", &ExpiryPolicy{TTL: "48h", Action: ExpireArchive, ArchiveProperty: "archived"}, map[string]interface{}{"archived": false}, at("2026-03-03T12:00:00Z")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.ExpiresAt(created, tt.data)
+			switch {
+			case got == nil && tt.want == nil:
+			case got == nil || tt.want == nil || !got.Equal(*tt.want):
+				t.Errorf("ExpiresAt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,248 @@
+package blueprint
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+const (
+	// PropertyIndexPrefix prefixes every managed per-blueprint expression index
+	PropertyIndexPrefix = "idx_entities_bp_"
+
+	// MaxIndexedProperties caps the expression indexes per blueprint; each one slows entity writes
+	MaxIndexedProperties = 10
+
+	// indexLockID is the advisory lock that keeps replicas from reconciling at the same time
+	indexLockID = 0x62706964 // "bpid"
+)
+
+var indexedSegment = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// IndexedProperties returns the dot-separated paths of properties marked
+// `"indexed": true` in a schema, sorted, capped at MaxIndexedProperties
+func IndexedProperties(schema map[string]interface{}) []string {
+	var paths []string
+	collectIndexed(schema, nil, &paths)
+	sort.Strings(paths)
+	if len(paths) > MaxIndexedProperties {
+		paths = paths[:MaxIndexedProperties]
+	}
+	return paths
+}
+
+func collectIndexed(schema map[string]interface{}, prefix []string, paths *[]string) {
+	props, _ := schema["properties"].(map[string]interface{})
+	for name, raw := range props {
+		prop, ok := raw.(map[string]interface{})
+		if !ok || !indexedSegment.MatchString(name) {
+			continue
+		}
+		path := append(append([]string{}, prefix...), name)
+		if indexed, _ := prop["indexed"].(bool); indexed {
+			*paths = append(*paths, strings.Join(path, "."))
+		}
+		collectIndexed(prop, path, paths)
+	}
+}
+
+// PropertyIndexName returns the deterministic name of the expression index for
+// one property of one blueprint. Names stay within PostgreSQL's 63 byte limit.
+func PropertyIndexName(teamID uuid.UUID, blueprintID, path string) string {
+	sum := sha256.Sum256([]byte(teamID.String() + "\x00" + blueprintID + "\x00" + path))
+	return PropertyIndexPrefix + hex.EncodeToString(sum[:12])
+}
+
+// propertyIndexDDL builds the CREATE INDEX statement. DDL cannot take bind
+// parameters, so every value is quoted; path segments are also restricted to
+// a safe character set. The expression matches the search filter compiler's
+// `data #> path` so equality, IN and ordering can use the index.
+func propertyIndexDDL(name string, teamID uuid.UUID, blueprintID, path string) string {
+	return fmt.Sprintf(
+		"CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON entities ((data #> %s::text[])) WHERE team_id = %s::uuid AND blueprint_id = %s",
+		postgres.QuoteIdentifier(name),
+		postgres.QuoteLiteral("{"+strings.ReplaceAll(path, ".", ",")+"}"),
+		postgres.QuoteLiteral(teamID.String()),
+		postgres.QuoteLiteral(blueprintID),
+	)
+}
+
+// IndexReport summarizes one reconciliation pass
+type IndexReport struct {
+	Created []string `json:"created"`
+	Dropped []string `json:"dropped"`
+	Skipped bool     `json:"skipped"` // another instance held the lock
+}
+
+// IndexMaintainer keeps the entities table's JSONB indexes in line with
+// blueprint schemas: the GIN index on data always exists, and every property
+// marked `"indexed": true` gets a partial expression index scoped to its blueprint.
+type IndexMaintainer struct {
+	db     *postgres.Client
+	repo   *Repository
+	notify chan struct{}
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+func NewIndexMaintainer(db *postgres.Client, repo *Repository) *IndexMaintainer {
+	return &IndexMaintainer{
+		db:     db,
+		repo:   repo,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// Notify requests a reconciliation soon, e.g. after a blueprint schema changed.
+// It never blocks; requests made while one is pending are merged.
+func (m *IndexMaintainer) Notify() {
+	if m == nil {
+		return
+	}
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run reconciles once at start, then on every Notify and every interval, until ctx is done
+func (m *IndexMaintainer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := m.Reconcile(ctx)
+		m.mu.Lock()
+		m.lastErr = err
+		m.mu.Unlock()
+		if err != nil {
+			log.Printf("ERROR: index maintenance failed: %v", err)
+		} else if len(report.Created) > 0 || len(report.Dropped) > 0 {
+			log.Printf("index maintenance: created %d, dropped %d indexes", len(report.Created), len(report.Dropped))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-m.notify:
+		}
+	}
+}
+
+// LastError returns the error of the last reconciliation, or nil when it succeeded
+func (m *IndexMaintainer) LastError() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastErr
+}
+
+// Reconcile creates missing indexes and drops managed indexes that no longer
+// correspond to an indexed property. Indexes are built CONCURRENTLY, so entity
+// writes are not blocked; invalid leftovers of failed builds are rebuilt.
+func (m *IndexMaintainer) Reconcile(ctx context.Context) (*IndexReport, error) {
+	// Session-level advisory locks need a pinned connection
+	conn, err := m.db.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", indexLockID).Scan(&locked); err != nil {
+		return nil, err
+	}
+	if !locked {
+		return &IndexReport{Skipped: true}, nil
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", indexLockID)
+
+	// Index builds on large tables outlast the statement timeout
+	if _, err := conn.ExecContext(ctx, "SET statement_timeout = 0"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "RESET statement_timeout")
+
+	report := &IndexReport{Created: []string{}, Dropped: []string{}}
+
+	if _, err := conn.ExecContext(ctx, "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_entities_data ON entities USING GIN (data)"); err != nil {
+		return nil, fmt.Errorf("failed to create GIN index: %w", err)
+	}
+
+	blueprints, err := m.repo.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	desired := make(map[string]string)
+	for _, bp := range blueprints {
+		for _, path := range IndexedProperties(bp.Schema) {
+			name := PropertyIndexName(bp.TeamID, bp.ID, path)
+			desired[name] = propertyIndexDDL(name, bp.TeamID, bp.ID, path)
+		}
+	}
+
+	rows, err := conn.QueryContext(ctx, `
+		SELECT c.relname, i.indisvalid
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE i.indrelid = 'entities'::regclass AND c.relname LIKE $1`, PropertyIndexPrefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		var valid bool
+		if err := rows.Scan(&name, &valid); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		existing[name] = valid
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for name, valid := range existing {
+		if _, ok := desired[name]; ok && valid {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, "DROP INDEX CONCURRENTLY IF EXISTS "+postgres.QuoteIdentifier(name)); err != nil {
+			return nil, fmt.Errorf("failed to drop index %s: %w", name, err)
+		}
+		if _, ok := desired[name]; !ok {
+			report.Dropped = append(report.Dropped, name)
+		}
+		delete(existing, name)
+	}
+
+	for name, ddl := range desired {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, ddl); err != nil {
+			return nil, fmt.Errorf("failed to create index %s: %w", name, err)
+		}
+		report.Created = append(report.Created, name)
+	}
+
+	sort.Strings(report.Created)
+	sort.Strings(report.Dropped)
+	return report, nil
+}
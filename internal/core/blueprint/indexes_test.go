@@ -0,0 +1,72 @@
+package blueprint
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestIndexedProperties(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"status":  map[string]interface{}{"type": "string", "indexed": true},
+			"owner":   map[string]interface{}{"type": "string"},
+			"bad key": map[string]interface{}{"type": "string", "indexed": true},
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tier": map[string]interface{}{"type": "integer", "indexed": true},
+				},
+			},
+		},
+	}
+
+	want := []string{"metadata.tier", "status"}
+	if got := IndexedProperties(schema); !reflect.DeepEqual(got, want) {
+		t.Errorf("IndexedProperties = %v, want %v", got, want)
+	}
+}
+
+func TestIndexedProperties_Capped(t *testing.T) {
+	props := map[string]interface{}{}
+	for i := 0; i < MaxIndexedProperties+5; i++ {
+		props[fmt.Sprintf("p%02d", i)] = map[string]interface{}{"indexed": true}
+	}
+	if got := IndexedProperties(map[string]interface{}{"properties": props}); len(got) != MaxIndexedProperties {
+		t.Errorf("expected %d indexed properties, got %d", MaxIndexedProperties, len(got))
+	}
+}
+
+func TestPropertyIndexName(t *testing.T) {
+	team := uuid.New()
+	a := PropertyIndexName(team, "service", "status")
+	if a != PropertyIndexName(team, "service", "status") {
+		t.Error("index names must be deterministic")
+	}
+	if a == PropertyIndexName(team, "service", "owner") || a == PropertyIndexName(uuid.New(), "service", "status") {
+		t.Error("index names must differ per team, blueprint and property")
+	}
+	if !strings.HasPrefix(a, PropertyIndexPrefix) || len(a) > 63 {
+		t.Errorf("invalid index name %q", a)
+	}
+}
+
+func TestPropertyIndexDDL_QuotesValues(t *testing.T) {
+	team := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	ddl := propertyIndexDDL("idx_entities_bp_x", team, "o'brien", "metadata.tier")
+
+	for _, want := range []string{
+		`"idx_entities_bp_x"`,
+		`(data #> '{metadata,tier}'::text[])`,
+		`team_id = '00000000-0000-0000-0000-000000000001'::uuid`,
+		`blueprint_id = 'o''brien'`,
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("DDL %q missing %q", ddl, want)
+		}
+	}
+}
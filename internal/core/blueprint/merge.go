@@ -0,0 +1,170 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidMergePolicy = errors.New("invalid merge policy")
+
+// Merge policies decide who may overwrite a property that both users and
+// integrations write
+const (
+	// MergeLastWriteWins lets every write through
+	MergeLastWriteWins = "last_write_wins"
+	// MergeManualWins keeps a property last written by a user or API key
+	// when an integration sync writes it
+	MergeManualWins = "manual_wins"
+	// MergeIntegrationWins rejects manual writes to a property last written
+	// by an integration
+	MergeIntegrationWins = "integration_wins"
+	// MergePrecedence ranks writers: a write to a property last written by a
+	// higher-ranked writer is dropped for integrations and rejected for users
+	// and API keys
+	MergePrecedence = "precedence"
+)
+
+// PrecedenceManual stands for users and API keys in a precedence ranking
+const PrecedenceManual = "manual"
+
+// MergePolicy configures per-property merge policies for a blueprint's
+// entities. Policies apply to top-level data properties; Default covers
+// those not listed and is last_write_wins when empty. Precedence ranks the
+// writers of properties under the precedence policy, highest first, as
+// integration IDs or PrecedenceManual; PropertyPrecedence gives single
+// properties their own ranking.
+type MergePolicy struct {
+	Default            string              `json:"default,omitempty"`
+	Properties         map[string]string   `json:"properties,omitempty"`
+	Precedence         []string            `json:"precedence,omitempty"`
+	PropertyPrecedence map[string][]string `json:"property_precedence,omitempty"`
+}
+
+// For returns the policy of a property
+func (p *MergePolicy) For(property string) string {
+	if p == nil {
+		return MergeLastWriteWins
+	}
+	if policy, ok := p.Properties[property]; ok {
+		return policy
+	}
+	if p.Default != "" {
+		return p.Default
+	}
+	return MergeLastWriteWins
+}
+
+// Ranking returns the precedence ranking of a property, highest first
+func (p *MergePolicy) Ranking(property string) []string {
+	if p == nil {
+		return nil
+	}
+	if ranking, ok := p.PropertyPrecedence[property]; ok {
+		return ranking
+	}
+	return p.Precedence
+}
+
+// Rank returns the position of a writer in a ranking; writers not listed
+// share the lowest rank
+func Rank(ranking []string, writer string) int {
+	if i := slices.Index(ranking, writer); i >= 0 {
+		return i
+	}
+	return len(ranking)
+}
+
+// IsZero reports whether the policy lets every write through, as no policy does
+func (p *MergePolicy) IsZero() bool {
+	if p == nil {
+		return true
+	}
+	if p.Default != "" && p.Default != MergeLastWriteWins {
+		return false
+	}
+	for _, policy := range p.Properties {
+		if policy != MergeLastWriteWins {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the policy names and that only top-level properties are listed
+func (p *MergePolicy) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Default != "" && !validMergePolicy(p.Default) {
+		return fmt.Errorf("%w: unknown default %q", ErrInvalidMergePolicy, p.Default)
+	}
+	for property, policy := range p.Properties {
+		if property == "" || strings.Contains(property, ".") {
+			return fmt.Errorf("%w: %q is not a top-level property", ErrInvalidMergePolicy, property)
+		}
+		if !validMergePolicy(policy) {
+			return fmt.Errorf("%w: unknown policy %q for %s", ErrInvalidMergePolicy, policy, property)
+		}
+		if policy == MergePrecedence && len(p.Ranking(property)) == 0 {
+			return fmt.Errorf("%w: %s uses precedence without a ranking", ErrInvalidMergePolicy, property)
+		}
+	}
+	if p.Default == MergePrecedence && len(p.Precedence) == 0 {
+		return fmt.Errorf("%w: the default uses precedence without a ranking", ErrInvalidMergePolicy)
+	}
+	if err := validRanking(p.Precedence); err != nil {
+		return fmt.Errorf("%w: precedence: %v", ErrInvalidMergePolicy, err)
+	}
+	for property, ranking := range p.PropertyPrecedence {
+		if property == "" || strings.Contains(property, ".") {
+			return fmt.Errorf("%w: %q is not a top-level property", ErrInvalidMergePolicy, property)
+		}
+		if p.For(property) != MergePrecedence {
+			return fmt.Errorf("%w: %s has a ranking but its policy is %s", ErrInvalidMergePolicy, property, p.For(property))
+		}
+		if err := validRanking(ranking); err != nil {
+			return fmt.Errorf("%w: precedence of %s: %v", ErrInvalidMergePolicy, property, err)
+		}
+	}
+	return nil
+}
+
+// validRanking checks that a ranking lists integration IDs or
+// PrecedenceManual, each once
+func validRanking(ranking []string) error {
+	seen := make(map[string]bool, len(ranking))
+	for _, writer := range ranking {
+		if writer != PrecedenceManual {
+			// Writers are matched by their canonical form
+			if id, err := uuid.Parse(writer); err != nil || id.String() != writer {
+				return fmt.Errorf("%q is neither a lowercase integration ID nor %q", writer, PrecedenceManual)
+			}
+		}
+		if seen[writer] {
+			return fmt.Errorf("%q is listed twice", writer)
+		}
+		seen[writer] = true
+	}
+	return nil
+}
+
+func validMergePolicy(policy string) bool {
+	switch policy {
+	case MergeLastWriteWins, MergeManualWins, MergeIntegrationWins, MergePrecedence:
+		return true
+	}
+	return false
+}
+
+// normalizeMergePolicy drops a policy that lets every write through, so
+// blueprints without one are stored alike
+func normalizeMergePolicy(p *MergePolicy) *MergePolicy {
+	if p.IsZero() {
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,91 @@
+package blueprint
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestMergePolicy_For(t *testing.T) {
+	var none *MergePolicy
+	if got := none.For("owner"); got != MergeLastWriteWins {
+		t.Errorf("nil policy For = %s", got)
+	}
+	p := &MergePolicy{Default: MergeManualWins, Properties: map[string]string{"replicas": MergeIntegrationWins}}
+	if got := p.For("replicas"); got != MergeIntegrationWins {
+		t.Errorf("For(replicas) = %s", got)
+	}
+	if got := p.For("owner"); got != MergeManualWins {
+		t.Errorf("For(owner) = %s", got)
+	}
+}
+
+func TestMergePolicy_Validate(t *testing.T) {
+	integration := uuid.New().String()
+	tests := []struct {
+		name   string
+		policy *MergePolicy
+		valid  bool
+	}{
+		{"nil", nil, true},
+		{"default only", &MergePolicy{Default: MergeIntegrationWins}, true},
+		{"properties", &MergePolicy{Properties: map[string]string{"owner": MergeManualWins}}, true},
+		{"unknown default", &MergePolicy{Default: "first_write_wins"}, false},
+		{"unknown policy", &MergePolicy{Properties: map[string]string{"owner": "manual"}}, false},
+		{"nested property", &MergePolicy{Properties: map[string]string{"metadata.tier": MergeManualWins}}, false},
+		{"empty property", &MergePolicy{Properties: map[string]string{"": MergeManualWins}}, false},
+		{"precedence", &MergePolicy{Default: MergePrecedence, Precedence: []string{integration, PrecedenceManual}}, true},
+		{"property precedence", &MergePolicy{
+			Properties:         map[string]string{"oncall": MergePrecedence},
+			PropertyPrecedence: map[string][]string{"oncall": {integration}},
+		}, true},
+		{"precedence without ranking", &MergePolicy{Properties: map[string]string{"oncall": MergePrecedence}}, false},
+		{"ranking without precedence", &MergePolicy{
+			Default:            MergeManualWins,
+			PropertyPrecedence: map[string][]string{"oncall": {integration}},
+		}, false},
+		{"unknown writer", &MergePolicy{Default: MergePrecedence, Precedence: []string{"github"}}, false},
+		{"uppercase integration", &MergePolicy{Default: MergePrecedence, Precedence: []string{strings.ToUpper(integration)}}, false},
+		{"duplicate writer", &MergePolicy{Default: MergePrecedence, Precedence: []string{integration, integration}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Validate() = %v", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidMergePolicy) {
+				t.Errorf("Validate() = %v, want ErrInvalidMergePolicy", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeMergePolicy(t *testing.T) {
+	if got := normalizeMergePolicy(&MergePolicy{Properties: map[string]string{"owner": MergeLastWriteWins}}); got != nil {
+		t.Errorf("a policy that lets every write through should be dropped, got %+v", got)
+	}
+	p := &MergePolicy{Properties: map[string]string{"owner": MergeManualWins}}
+	if got := normalizeMergePolicy(p); got != p {
+		t.Errorf("normalizeMergePolicy dropped %+v", p)
+	}
+}
+
+func TestMergePolicy_Ranking(t *testing.T) {
+	p := &MergePolicy{
+		Precedence:         []string{"a", "b"},
+		PropertyPrecedence: map[string][]string{"oncall": {"c"}},
+	}
+	if got := p.Ranking("repo"); len(got) != 2 || Rank(got, "b") != 1 || Rank(got, "c") != 2 {
+		t.Errorf("Ranking(repo) = %v", got)
+	}
+	if got := p.Ranking("oncall"); len(got) != 1 || Rank(got, "c") != 0 || Rank(got, "a") != 1 {
+		t.Errorf("Ranking(oncall) = %v", got)
+	}
+	var none *MergePolicy
+	if got := none.Ranking("repo"); got != nil {
+		t.Errorf("nil policy Ranking = %v", got)
+	}
+}
@@ -7,29 +7,43 @@ import (
 )
 
 type Blueprint struct {
-	ID          string                 `json:"id"`
-	TeamID      uuid.UUID              `json:"team_id"`
-	Title       string                 `json:"title"`
-	Description string                 `json:"description,omitempty"`
-	Icon        string                 `json:"icon,omitempty"`
-	Schema      map[string]interface{} `json:"schema"`
-	CreatedAt   time.Time              `json:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at"`
+	ID                string                 `json:"id"`
+	TeamID            uuid.UUID              `json:"team_id"`
+	Title             string                 `json:"title"`
+	Description       string                 `json:"description,omitempty"`
+	Icon              string                 `json:"icon,omitempty"`
+	Schema            map[string]interface{} `json:"schema"`
+	IdentifierMutable bool                   `json:"identifier_mutable"`
+	MergePolicy       *MergePolicy           `json:"merge_policy,omitempty"`
+	ExpiryPolicy      *ExpiryPolicy          `json:"expiry_policy,omitempty"`
+	StrictUpdates     bool                   `json:"strict_updates"`
+	CreatedAt         time.Time              `json:"created_at"`
+	UpdatedAt         time.Time              `json:"updated_at"`
 }
 
 type CreateBlueprintRequest struct {
-	ID          string                 `json:"id" binding:"required"`
-	Title       string                 `json:"title" binding:"required"`
-	Description string                 `json:"description"`
-	Icon        string                 `json:"icon"`
-	Schema      map[string]interface{} `json:"schema" binding:"required"`
+	ID                string                 `json:"id" binding:"required"`
+	Title             string                 `json:"title" binding:"required"`
+	Description       string                 `json:"description"`
+	Icon              string                 `json:"icon"`
+	Schema            map[string]interface{} `json:"schema" binding:"required"`
+	IdentifierMutable bool                   `json:"identifier_mutable"`
+	MergePolicy       *MergePolicy           `json:"merge_policy"`
+	ExpiryPolicy      *ExpiryPolicy          `json:"expiry_policy"`
+	StrictUpdates     bool                   `json:"strict_updates"`
 }
 
 type UpdateBlueprintRequest struct {
-	Title       string                 `json:"title"`
-	Description string                 `json:"description"`
-	Icon        string                 `json:"icon"`
-	Schema      map[string]interface{} `json:"schema"`
+	Title             string                 `json:"title"`
+	Description       string                 `json:"description"`
+	Icon              string                 `json:"icon"`
+	Schema            map[string]interface{} `json:"schema"`
+	IdentifierMutable *bool                  `json:"identifier_mutable"`
+	// MergePolicy replaces the blueprint's policy; an empty object removes it
+	MergePolicy *MergePolicy `json:"merge_policy"`
+	// ExpiryPolicy replaces the blueprint's policy; an empty object removes it
+	ExpiryPolicy  *ExpiryPolicy `json:"expiry_policy"`
+	StrictUpdates *bool         `json:"strict_updates"`
 }
 
 type ListBlueprintsResponse struct {
@@ -7,15 +7,25 @@ import (
 
 	"github.com/google/uuid"
 
+	"github.com/baseplate/baseplate/internal/events"
 	"github.com/baseplate/baseplate/internal/storage/postgres"
 )
 
 type Repository struct {
-	db *postgres.Client
+	db       *postgres.Client
+	entities EntityDeleter
 }
 
-func NewRepository(db *postgres.Client) *Repository {
-	return &Repository{db: db}
+// EntityDeleter deletes the entities of a blueprint in a transaction,
+// recording their history and events as any entity delete does
+type EntityDeleter interface {
+	DeleteByBlueprintTx(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, blueprintID string) (int64, error)
+}
+
+// NewRepository creates the blueprint repository; entities deletes the
+// entities of force-deleted blueprints
+func NewRepository(db *postgres.Client, entities EntityDeleter) *Repository {
+	return &Repository{db: db, entities: entities}
 }
 
 func (r *Repository) Create(ctx context.Context, bp *Blueprint) error {
@@ -24,28 +34,44 @@ func (r *Repository) Create(ctx context.Context, bp *Blueprint) error {
 		return err
 	}
 
+	policy, err := marshalMergePolicy(bp.MergePolicy)
+	if err != nil {
+		return err
+	}
+	expiry, err := marshalExpiryPolicy(bp.ExpiryPolicy)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO blueprints (id, team_id, title, description, icon, schema)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING created_at, updated_at`
+		WITH written AS (
+			INSERT INTO blueprints (id, team_id, title, description, icon, schema, identifier_mutable, merge_policy, expiry_policy, strict_updates)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING ` + EventColumns + `
+		), ` + RecordEvent(events.BlueprintCreated, "written", "$11", "$12") + `
+		SELECT created_at, updated_at FROM written`
 
+	userID, apiKeyID := eventActor(ctx)
 	return r.db.DB.QueryRowContext(ctx, query,
-		bp.ID, bp.TeamID, bp.Title, bp.Description, bp.Icon, schema,
+		bp.ID, bp.TeamID, bp.Title, bp.Description, bp.Icon, schema, bp.IdentifierMutable, policy, expiry, bp.StrictUpdates, userID, apiKeyID,
 	).Scan(&bp.CreatedAt, &bp.UpdatedAt)
 }
 
+// GetByID reads the primary even with a read replica: its result fills the
+// lookup cache, which must not keep a copy from before the latest write
 func (r *Repository) GetByID(ctx context.Context, teamID uuid.UUID, id string) (*Blueprint, error) {
 	query := `
-		SELECT id, team_id, title, description, icon, schema, created_at, updated_at
+		SELECT id, team_id, title, description, icon, schema, identifier_mutable, merge_policy, expiry_policy, strict_updates, created_at, updated_at
 		FROM blueprints
 		WHERE team_id = $1 AND id = $2`
 
 	bp := &Blueprint{}
 	var schema []byte
 	var description, icon sql.NullString
+	var policy, expiry []byte
 
 	err := r.db.DB.QueryRowContext(ctx, query, teamID, id).Scan(
-		&bp.ID, &bp.TeamID, &bp.Title, &description, &icon, &schema, &bp.CreatedAt, &bp.UpdatedAt,
+		&bp.ID, &bp.TeamID, &bp.Title, &description, &icon, &schema, &bp.IdentifierMutable, &policy, &expiry, &bp.StrictUpdates, &bp.CreatedAt, &bp.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -59,36 +85,69 @@ func (r *Repository) GetByID(ctx context.Context, teamID uuid.UUID, id string) (
 	if err := json.Unmarshal(schema, &bp.Schema); err != nil {
 		return nil, err
 	}
+	if err := unmarshalMergePolicy(policy, bp); err != nil {
+		return nil, err
+	}
+	if err := unmarshalExpiryPolicy(expiry, bp); err != nil {
+		return nil, err
+	}
 
 	return bp, nil
 }
 
 func (r *Repository) List(ctx context.Context, teamID uuid.UUID) ([]*Blueprint, error) {
 	query := `
-		SELECT id, team_id, title, description, icon, schema, created_at, updated_at
+		SELECT id, team_id, title, description, icon, schema, identifier_mutable, merge_policy, expiry_policy, strict_updates, created_at, updated_at
 		FROM blueprints
 		WHERE team_id = $1
 		ORDER BY created_at DESC`
 
-	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	return r.scanBlueprints(rows)
+}
+
+// ListAll returns the blueprints of every team
+func (r *Repository) ListAll(ctx context.Context) ([]*Blueprint, error) {
+	query := `
+		SELECT id, team_id, title, description, icon, schema, identifier_mutable, merge_policy, expiry_policy, strict_updates, created_at, updated_at
+		FROM blueprints
+		ORDER BY team_id, id`
+
+	rows, err := r.db.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanBlueprints(rows)
+}
+
+func (r *Repository) scanBlueprints(rows *sql.Rows) ([]*Blueprint, error) {
 	var blueprints []*Blueprint
 	for rows.Next() {
 		bp := &Blueprint{}
 		var schema []byte
 		var description, icon sql.NullString
+		var policy, expiry []byte
 
-		if err := rows.Scan(&bp.ID, &bp.TeamID, &bp.Title, &description, &icon, &schema, &bp.CreatedAt, &bp.UpdatedAt); err != nil {
+		if err := rows.Scan(&bp.ID, &bp.TeamID, &bp.Title, &description, &icon, &schema, &bp.IdentifierMutable, &policy, &expiry, &bp.StrictUpdates, &bp.CreatedAt, &bp.UpdatedAt); err != nil {
 			return nil, err
 		}
 
 		bp.Description = description.String
 		bp.Icon = icon.String
 		json.Unmarshal(schema, &bp.Schema)
+		if err := unmarshalMergePolicy(policy, bp); err != nil {
+			return nil, err
+		}
+		if err := unmarshalExpiryPolicy(expiry, bp); err != nil {
+			return nil, err
+		}
 		blueprints = append(blueprints, bp)
 	}
 
@@ -101,21 +160,103 @@ func (r *Repository) Update(ctx context.Context, bp *Blueprint) error {
 		return err
 	}
 
+	policy, err := marshalMergePolicy(bp.MergePolicy)
+	if err != nil {
+		return err
+	}
+	expiry, err := marshalExpiryPolicy(bp.ExpiryPolicy)
+	if err != nil {
+		return err
+	}
+
 	query := `
-		UPDATE blueprints
-		SET title = $3, description = $4, icon = $5, schema = $6, updated_at = CURRENT_TIMESTAMP
-		WHERE team_id = $1 AND id = $2
-		RETURNING updated_at`
+		WITH written AS (
+			UPDATE blueprints
+			SET title = $3, description = $4, icon = $5, schema = $6, identifier_mutable = $7, merge_policy = $8, expiry_policy = $9,
+				strict_updates = $10, updated_at = CURRENT_TIMESTAMP
+			WHERE team_id = $1 AND id = $2
+			RETURNING ` + EventColumns + `
+		), ` + RecordEvent(events.BlueprintUpdated, "written", "$11", "$12") + `
+		SELECT updated_at FROM written`
 
+	userID, apiKeyID := eventActor(ctx)
 	return r.db.DB.QueryRowContext(ctx, query,
-		bp.TeamID, bp.ID, bp.Title, bp.Description, bp.Icon, schema,
+		bp.TeamID, bp.ID, bp.Title, bp.Description, bp.Icon, schema, bp.IdentifierMutable, policy, expiry, bp.StrictUpdates, userID, apiKeyID,
 	).Scan(&bp.UpdatedAt)
 }
 
-func (r *Repository) Delete(ctx context.Context, teamID uuid.UUID, id string) error {
-	query := `DELETE FROM blueprints WHERE team_id = $1 AND id = $2`
-	_, err := r.db.DB.ExecContext(ctx, query, teamID, id)
-	return err
+// Delete deletes a blueprint, failing with HasEntitiesError while it has
+// entities unless force deletes them, with their history and events, too.
+// found is false when there is no such blueprint.
+func (r *Repository) Delete(ctx context.Context, teamID uuid.UUID, id string, force bool) (found bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// the lock keeps entities from being created between count and delete
+	err = tx.QueryRowContext(ctx,
+		`SELECT true FROM blueprints WHERE team_id = $1 AND id = $2 FOR UPDATE`, teamID, id,
+	).Scan(&found)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var entities int64
+	if err := tx.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM entities WHERE team_id = $1 AND blueprint_id = $2`, teamID, id,
+	).Scan(&entities); err != nil {
+		return true, err
+	}
+	if entities > 0 && !force {
+		return true, &HasEntitiesError{Entities: entities}
+	}
+
+	if entities > 0 {
+		if _, err := r.entities.DeleteByBlueprintTx(ctx, tx, teamID, id); err != nil {
+			return true, err
+		}
+	}
+	userID, apiKeyID := eventActor(ctx)
+	if _, err := tx.ExecContext(ctx, `
+		WITH deleted AS (
+			DELETE FROM blueprints WHERE team_id = $1 AND id = $2
+			RETURNING `+EventColumns+`
+		), `+RecordEvent(events.BlueprintDeleted, "deleted", "$3", "$4")+`
+		SELECT COUNT(*) FROM deleted`, teamID, id, userID, apiKeyID); err != nil {
+		return true, err
+	}
+	return true, tx.Commit()
+}
+
+// EventColumns are the blueprint columns a write returns for RecordEvent
+const EventColumns = `id, team_id, title, description, icon, schema, identifier_mutable, merge_policy, expiry_policy, strict_updates, created_at, updated_at`
+
+// RecordEvent returns a CTE that inserts an event_outbox row of eventType
+// for each blueprint returned by the CTE source, which returns EventColumns,
+// attributed to the actor in the given parameters. Being part of the
+// write's statement, the event is committed with it or not at all.
+func RecordEvent(eventType, source, userParam, apiKeyParam string) string {
+	return `outbox AS (
+			INSERT INTO event_outbox (type, team_id, blueprint_id, payload, actor_user_id, actor_api_key_id)
+			SELECT '` + eventType + `', team_id, id, jsonb_build_object(
+				'id', id, 'team_id', team_id, 'title', title, 'description', description, 'icon', icon,
+				'schema', schema, 'identifier_mutable', identifier_mutable, 'merge_policy', merge_policy,
+				'expiry_policy', expiry_policy, 'strict_updates', strict_updates, 'created_at', created_at, 'updated_at', updated_at
+			), ` + userParam + `::uuid, ` + apiKeyParam + `::uuid
+			FROM ` + source + `
+		)`
+}
+
+// eventActor returns the actor of the request as query parameters; writes
+// without one are recorded without an actor
+func eventActor(ctx context.Context) (userID, apiKeyID *uuid.UUID) {
+	actor, _ := events.ActorFrom(ctx)
+	return actor.UserID, actor.APIKeyID
 }
 
 func (r *Repository) Exists(ctx context.Context, teamID uuid.UUID, id string) (bool, error) {
@@ -124,3 +265,33 @@ func (r *Repository) Exists(ctx context.Context, teamID uuid.UUID, id string) (b
 	err := r.db.DB.QueryRowContext(ctx, query, teamID, id).Scan(&exists)
 	return exists, err
 }
+
+// marshalMergePolicy encodes a merge policy as a nullable JSONB parameter
+func marshalMergePolicy(p *MergePolicy) (interface{}, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+func unmarshalMergePolicy(data []byte, bp *Blueprint) error {
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &bp.MergePolicy)
+}
+
+// marshalExpiryPolicy encodes an expiry policy as a nullable JSONB parameter
+func marshalExpiryPolicy(p *ExpiryPolicy) (interface{}, error) {
+	if p == nil {
+		return nil, nil
+	}
+	return json.Marshal(p)
+}
+
+func unmarshalExpiryPolicy(data []byte, bp *Blueprint) error {
+	if data == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &bp.ExpiryPolicy)
+}
@@ -0,0 +1,22 @@
+package blueprint
+
+import "sort"
+
+// RestrictedProperties returns the top-level properties marked
+// `"restricted": true` in a schema, sorted. Their values are hidden from
+// callers without the entity:restricted permission.
+func RestrictedProperties(schema map[string]interface{}) []string {
+	props, _ := schema["properties"].(map[string]interface{})
+	var names []string
+	for name, raw := range props {
+		prop, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if restricted, _ := prop["restricted"].(bool); restricted {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,29 @@
+package blueprint
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRestrictedProperties(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"owner":  map[string]interface{}{"type": "string"},
+			"salary": map[string]interface{}{"type": "number", "restricted": true},
+			"cost":   map[string]interface{}{"type": "number", "restricted": true},
+			"public": map[string]interface{}{"type": "string", "restricted": false},
+			"billing": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"account": map[string]interface{}{"type": "string", "restricted": true},
+				},
+			},
+		},
+	}
+	if got, want := RestrictedProperties(schema), []string{"cost", "salary"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RestrictedProperties = %v, want %v", got, want)
+	}
+	if got := RestrictedProperties(nil); got != nil {
+		t.Errorf("RestrictedProperties(nil) = %v", got)
+	}
+}
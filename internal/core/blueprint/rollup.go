@@ -0,0 +1,188 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/baseplate/baseplate/internal/core/validation"
+)
+
+var ErrInvalidRollup = errors.New("invalid rollup")
+
+// Rollup aggregates
+const (
+	// RollupWorst takes the child value earliest in the order
+	RollupWorst = "worst"
+	// RollupBest takes the child value latest in the order
+	RollupBest = "best"
+)
+
+// PropertyRollup is a top-level property the server computes from the same
+// property of an entity's related children, e.g. a system's health as the
+// worst health of its services. Children are the targets of the entity's
+// Relation, or, when Blueprint is set, the entities of Blueprint whose
+// Relation targets the entity.
+type PropertyRollup struct {
+	Property  string        `json:"-"`
+	Relation  string        `json:"relation"`
+	Blueprint string        `json:"blueprint,omitempty"`
+	From      string        `json:"property"`
+	Order     []interface{} `json:"order"`
+	Aggregate string        `json:"aggregate,omitempty"`
+}
+
+// Inbound reports whether the children link to the entity, rather than the
+// entity to its children
+func (r *PropertyRollup) Inbound() bool {
+	return r.Blueprint != ""
+}
+
+// Compute returns the rolled-up value of the children's values, or false
+// when none of them is in the order
+func (r *PropertyRollup) Compute(values []interface{}) (interface{}, bool) {
+	at := -1
+	for _, v := range values {
+		i := r.rank(v)
+		switch {
+		case i < 0:
+		case at < 0,
+			r.Aggregate == RollupBest && i > at,
+			r.Aggregate != RollupBest && i < at:
+			at = i
+		}
+	}
+	if at < 0 {
+		return nil, false
+	}
+	return r.Order[at], true
+}
+
+func (r *PropertyRollup) rank(v interface{}) int {
+	for i, o := range r.Order {
+		if reflect.DeepEqual(o, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Rollups returns the top-level properties of a schema with a `"rollup"`,
+// sorted by property. Malformed rollups are left out; ValidateRollups
+// rejects them.
+func Rollups(schema map[string]interface{}) []*PropertyRollup {
+	props, _ := schema["properties"].(map[string]interface{})
+	var rollups []*PropertyRollup
+	for name, raw := range props {
+		prop, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if rollup, err := parseRollup(name, prop["rollup"]); err == nil && rollup != nil {
+			rollups = append(rollups, rollup)
+		}
+	}
+	sort.Slice(rollups, func(i, j int) bool { return rollups[i].Property < rollups[j].Property })
+	return rollups
+}
+
+func parseRollup(name string, raw interface{}) (*PropertyRollup, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	spec, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: %s: rollup must be an object", ErrInvalidRollup, name)
+	}
+	rollup := &PropertyRollup{Property: name}
+	for key, value := range spec {
+		var ok bool
+		switch key {
+		case "relation":
+			rollup.Relation, ok = value.(string)
+		case "blueprint":
+			rollup.Blueprint, ok = value.(string)
+		case "property":
+			rollup.From, ok = value.(string)
+		case "order":
+			rollup.Order, ok = value.([]interface{})
+		case "aggregate":
+			rollup.Aggregate, ok = value.(string)
+		default:
+			return nil, fmt.Errorf("%w: %s: unknown rollup field %q", ErrInvalidRollup, name, key)
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: %s: rollup field %q has the wrong type", ErrInvalidRollup, name, key)
+		}
+	}
+	switch {
+	case rollup.Relation == "":
+		return nil, fmt.Errorf("%w: %s: rollup needs a relation", ErrInvalidRollup, name)
+	case rollup.From == "":
+		return nil, fmt.Errorf("%w: %s: rollup needs the property of the children", ErrInvalidRollup, name)
+	case len(rollup.Order) == 0:
+		return nil, fmt.Errorf("%w: %s: rollup needs an order of values, worst first", ErrInvalidRollup, name)
+	case rollup.Aggregate != "" && rollup.Aggregate != RollupWorst && rollup.Aggregate != RollupBest:
+		return nil, fmt.Errorf("%w: %s: unknown aggregate %q, use worst or best", ErrInvalidRollup, name, rollup.Aggregate)
+	}
+	for i, v := range rollup.Order {
+		if rollup.rank(v) != i {
+			return nil, fmt.Errorf("%w: %s: rollup order lists %v twice", ErrInvalidRollup, name, v)
+		}
+	}
+	return rollup, nil
+}
+
+// ValidateRollups checks the rollups of a schema's top-level properties.
+// The server writes rollup properties, so they may be neither required nor
+// filled in on create, and every value of the order must be a valid value
+// of the property. Relations are resolved when rollups are computed, as
+// they may be created after the blueprint.
+func ValidateRollups(schema map[string]interface{}) error {
+	props, _ := schema["properties"].(map[string]interface{})
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	required := map[string]bool{}
+	if list, ok := schema["required"].([]interface{}); ok {
+		for _, name := range list {
+			if s, ok := name.(string); ok {
+				required[s] = true
+			}
+		}
+	}
+
+	validator := validation.NewValidator()
+	for _, name := range names {
+		prop, ok := props[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		rollup, err := parseRollup(name, prop["rollup"])
+		if err != nil {
+			return err
+		}
+		if rollup == nil {
+			continue
+		}
+		_, hasDefault := prop["default"]
+		_, generated := prop["generated"]
+		switch {
+		case required[name]:
+			return fmt.Errorf("%w: %s: a rollup property cannot be required", ErrInvalidRollup, name)
+		case hasDefault || generated:
+			return fmt.Errorf("%w: %s: a rollup property cannot have a default", ErrInvalidRollup, name)
+		}
+		// Schemas that do not compile are not checked here, as on create
+		for _, value := range rollup.Order {
+			err := validator.ValidatePartial(map[string]interface{}{name: value}, schema)
+			if ve := validation.GetValidationErrors(err); ve != nil {
+				return fmt.Errorf("%w: %s: %s", ErrInvalidRollup, name, ve.Error())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package blueprint
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRollups(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"health": map[string]interface{}{
+				"type":   "string",
+				"rollup": map[string]interface{}{"relation": "system", "blueprint": "service", "property": "health", "order": []interface{}{"down", "degraded", "up"}},
+			},
+			"broken": map[string]interface{}{"type": "string", "rollup": "worst"},
+			"owner":  map[string]interface{}{"type": "string"},
+		},
+	}
+	rollups := Rollups(schema)
+	if len(rollups) != 1 {
+		t.Fatalf("Rollups = %+v, want the health rollup", rollups)
+	}
+	r := rollups[0]
+	if r.Property != "health" || r.Relation != "system" || r.From != "health" || !r.Inbound() {
+		t.Errorf("rollup = %+v", r)
+	}
+}
+
+func TestRollupCompute(t *testing.T) {
+	worst := &PropertyRollup{Order: []interface{}{"down", "degraded", "up"}}
+	best := &PropertyRollup{Order: worst.Order, Aggregate: RollupBest}
+	tests := []struct {
+		values      []interface{}
+		worst, best interface{}
+	}{
+		{[]interface{}{"up", "degraded", "up"}, "degraded", "up"},
+		{[]interface{}{"up", "down", "unknown"}, "down", "up"},
+		{[]interface{}{"degraded"}, "degraded", "degraded"},
+		{[]interface{}{"unknown", 3.0}, nil, nil},
+		{nil, nil, nil},
+	}
+	for _, tt := range tests {
+		got, ok := worst.Compute(tt.values)
+		if got != tt.worst || ok != (tt.worst != nil) {
+			t.Errorf("worst of %v = %v, %v, want %v", tt.values, got, ok, tt.worst)
+		}
+		if got, _ := best.Compute(tt.values); got != tt.best {
+			t.Errorf("best of %v = %v, want %v", tt.values, got, tt.best)
+		}
+	}
+}
+
+func TestValidateRollups(t *testing.T) {
+	schema := func(prop map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"type":       "object",
+			"required":   []interface{}{"owner"},
+			"properties": map[string]interface{}{"owner": map[string]interface{}{"type": "string"}, "p": prop},
+		}
+	}
+	rollup := func(fields map[string]interface{}) map[string]interface{} {
+		spec := map[string]interface{}{"relation": "services", "property": "health", "order": []interface{}{"down", "up"}}
+		for k, v := range fields {
+			spec[k] = v
+		}
+		return spec
+	}
+	valid := []map[string]interface{}{
+		{"type": "string"},
+		{"type": "string", "rollup": rollup(nil)},
+		{"type": "string", "enum": []interface{}{"down", "up"}, "rollup": rollup(map[string]interface{}{"aggregate": "best"})},
+		{"type": "integer", "rollup": rollup(map[string]interface{}{"blueprint": "service", "order": []interface{}{1.0, 2.0, 3.0}})},
+	}
+	for _, prop := range valid {
+		if err := ValidateRollups(schema(prop)); err != nil {
+			t.Errorf("%v: %v", prop, err)
+		}
+	}
+
+	invalid := []map[string]interface{}{
+		{"type": "string", "rollup": "worst"},
+		{"type": "string", "rollup": rollup(map[string]interface{}{"relation": ""})},
+		{"type": "string", "rollup": rollup(map[string]interface{}{"property": 3})},
+		{"type": "string", "rollup": rollup(map[string]interface{}{"order": []interface{}{}})},
+		{"type": "string", "rollup": rollup(map[string]interface{}{"order": []interface{}{"up", "up"}})},
+		{"type": "string", "rollup": rollup(map[string]interface{}{"aggregate": "average"})},
+		{"type": "string", "rollup": rollup(map[string]interface{}{"depth": 2})},
+		{"type": "string", "enum": []interface{}{"up"}, "rollup": rollup(nil)},
+		{"type": "string", "default": "up", "rollup": rollup(nil)},
+		{"type": "string", "generated": "uuid", "rollup": rollup(nil)},
+	}
+	for _, prop := range invalid {
+		if err := ValidateRollups(schema(prop)); !errors.Is(err, ErrInvalidRollup) {
+			t.Errorf("%v: err = %v, want ErrInvalidRollup", prop, err)
+		}
+	}
+
+	required := schema(map[string]interface{}{"type": "string", "rollup": rollup(nil)})
+	required["required"] = []interface{}{"owner", "p"}
+	if err := ValidateRollups(required); !errors.Is(err, ErrInvalidRollup) {
+		t.Errorf("required rollup: err = %v, want ErrInvalidRollup", err)
+	}
+}
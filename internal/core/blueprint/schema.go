@@ -0,0 +1,203 @@
+package blueprint
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SchemaDialect is the JSON Schema draft standalone schemas declare
+const SchemaDialect = "http://json-schema.org/draft-07/schema#"
+
+var ErrUnresolvableSchema = errors.New("schema cannot be dereferenced")
+
+// Keywords whose value is a single subschema
+var subschemaKeywords = map[string]bool{
+	"additionalItems":      true,
+	"additionalProperties": true,
+	"contains":             true,
+	"propertyNames":        true,
+	"not":                  true,
+	"if":                   true,
+	"then":                 true,
+	"else":                 true,
+}
+
+// Keywords whose value maps names to subschemas
+var subschemaMapKeywords = map[string]bool{
+	"properties":        true,
+	"patternProperties": true,
+	"dependencies":      true,
+}
+
+// Keywords whose value is a list of subschemas
+var subschemaListKeywords = map[string]bool{
+	"allOf": true,
+	"anyOf": true,
+	"oneOf": true,
+}
+
+// StandaloneSchema returns a blueprint's entity schema for use outside
+// Baseplate: local $refs are inlined, $defs and definitions dropped, and
+// Baseplate extensions (`indexed`, `restricted` and `x-` keywords) stripped. Recursive or
+// non-local $refs cannot be inlined and return ErrUnresolvableSchema.
+func StandaloneSchema(bp *Blueprint) (map[string]interface{}, error) {
+	d := &dereferencer{root: bp.Schema}
+	out := map[string]interface{}{}
+	if len(bp.Schema) > 0 {
+		resolved, err := d.schema(bp.Schema)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := resolved.(map[string]interface{}); ok {
+			out = m
+		} else if resolved == false {
+			out["not"] = map[string]interface{}{}
+		}
+	}
+
+	out["$schema"] = SchemaDialect
+	if _, ok := out["title"]; !ok && bp.Title != "" {
+		out["title"] = bp.Title
+	}
+	if _, ok := out["description"]; !ok && bp.Description != "" {
+		out["description"] = bp.Description
+	}
+	return out, nil
+}
+
+type dereferencer struct {
+	root map[string]interface{}
+	// refs being inlined, to catch cycles
+	stack []string
+}
+
+// schema copies a subschema, inlining $refs and stripping extensions.
+// Values that are not objects (booleans, malformed keywords) are kept as is.
+func (d *dereferencer) schema(node interface{}) (interface{}, error) {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return node, nil
+	}
+
+	if ref, ok := m["$ref"].(string); ok {
+		// Draft 7 ignores the keywords next to $ref, so the target replaces it
+		return d.ref(ref)
+	}
+
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		switch {
+		case isExtension(k), k == "$defs", k == "definitions", k == "$schema":
+			continue
+		case subschemaKeywords[k]:
+			resolved, err := d.schema(v)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		case k == "items", subschemaListKeywords[k]:
+			resolved, err := d.schemaOrList(v)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		case subschemaMapKeywords[k]:
+			named, ok := v.(map[string]interface{})
+			if !ok {
+				out[k] = v
+				continue
+			}
+			resolved := make(map[string]interface{}, len(named))
+			for name, sub := range named {
+				r, err := d.schema(sub)
+				if err != nil {
+					return nil, err
+				}
+				resolved[name] = r
+			}
+			out[k] = resolved
+		default:
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+func (d *dereferencer) schemaOrList(v interface{}) (interface{}, error) {
+	list, ok := v.([]interface{})
+	if !ok {
+		return d.schema(v)
+	}
+	out := make([]interface{}, len(list))
+	for i, sub := range list {
+		r, err := d.schema(sub)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = r
+	}
+	return out, nil
+}
+
+func (d *dereferencer) ref(ref string) (interface{}, error) {
+	for _, seen := range d.stack {
+		if seen == ref {
+			return nil, fmt.Errorf("%w: %s is recursive", ErrUnresolvableSchema, ref)
+		}
+	}
+	target, err := d.pointer(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	d.stack = append(d.stack, ref)
+	defer func() { d.stack = d.stack[:len(d.stack)-1] }()
+	return d.schema(target)
+}
+
+// pointer resolves a local reference (`#/...`) against the root schema
+func (d *dereferencer) pointer(ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("%w: %s is not a local reference", ErrUnresolvableSchema, ref)
+	}
+	fragment, err := url.PathUnescape(ref[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrUnresolvableSchema, ref, err)
+	}
+
+	var node interface{} = d.root
+	if fragment == "" {
+		return node, nil
+	}
+	if !strings.HasPrefix(fragment, "/") {
+		return nil, fmt.Errorf("%w: %s is not a JSON pointer", ErrUnresolvableSchema, ref)
+	}
+	for _, token := range strings.Split(fragment[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch n := node.(type) {
+		case map[string]interface{}:
+			next, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s not found", ErrUnresolvableSchema, ref)
+			}
+			node = next
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(n) {
+				return nil, fmt.Errorf("%w: %s not found", ErrUnresolvableSchema, ref)
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("%w: %s not found", ErrUnresolvableSchema, ref)
+		}
+	}
+	return node, nil
+}
+
+// isExtension reports whether a keyword is Baseplate's own rather than JSON Schema's
+func isExtension(keyword string) bool {
+	return keyword == "indexed" || keyword == "restricted" || keyword == "generated" || keyword == "rollup" || strings.HasPrefix(keyword, "x-")
+}
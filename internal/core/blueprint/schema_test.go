@@ -0,0 +1,83 @@
+package blueprint
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func schemaFromJSON(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestStandaloneSchema(t *testing.T) {
+	bp := &Blueprint{
+		Title: "Service",
+		Schema: schemaFromJSON(t, `{
+			"type": "object",
+			"x-ui-order": ["name"],
+			"$defs": {"tier": {"type": "string", "enum": ["gold", "silver"], "x-color": "gold"}},
+			"properties": {
+				"name": {"type": "string", "indexed": true},
+				"indexed": {"type": "boolean"},
+				"tier": {"$ref": "#/$defs/tier"},
+				"backups": {"type": "array", "items": {"$ref": "#/$defs/tier"}},
+				"owner": {"anyOf": [{"$ref": "#/$defs/tier"}, {"type": "null"}]}
+			},
+			"required": ["name"]
+		}`),
+	}
+
+	got, err := StandaloneSchema(bp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := schemaFromJSON(t, `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "Service",
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"indexed": {"type": "boolean"},
+			"tier": {"type": "string", "enum": ["gold", "silver"]},
+			"backups": {"type": "array", "items": {"type": "string", "enum": ["gold", "silver"]}},
+			"owner": {"anyOf": [{"type": "string", "enum": ["gold", "silver"]}, {"type": "null"}]}
+		},
+		"required": ["name"]
+	}`)
+	if !reflect.DeepEqual(got, want) {
+		gotJSON, _ := json.Marshal(got)
+		t.Errorf("StandaloneSchema() = %s", gotJSON)
+	}
+}
+
+func TestStandaloneSchema_Empty(t *testing.T) {
+	got, err := StandaloneSchema(&Blueprint{Title: "Anything"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["$schema"] != SchemaDialect || got["title"] != "Anything" {
+		t.Errorf("StandaloneSchema() = %v", got)
+	}
+}
+
+func TestStandaloneSchema_Unresolvable(t *testing.T) {
+	tests := map[string]string{
+		"recursive": `{"$defs": {"node": {"type": "object", "properties": {"child": {"$ref": "#/$defs/node"}}}},
+			"properties": {"root": {"$ref": "#/$defs/node"}}}`,
+		"remote":  `{"properties": {"a": {"$ref": "https://example.com/schema.json"}}}`,
+		"missing": `{"properties": {"a": {"$ref": "#/$defs/nope"}}}`,
+	}
+	for name, schema := range tests {
+		_, err := StandaloneSchema(&Blueprint{Schema: schemaFromJSON(t, schema)})
+		if !errors.Is(err, ErrUnresolvableSchema) {
+			t.Errorf("%s: err = %v, want ErrUnresolvableSchema", name, err)
+		}
+	}
+}
@@ -3,8 +3,14 @@ package blueprint
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/cache"
+	"github.com/baseplate/baseplate/internal/core/validation"
+	"github.com/baseplate/baseplate/internal/events"
 )
 
 var (
@@ -12,15 +18,58 @@ var (
 	ErrAlreadyExists = errors.New("blueprint already exists")
 )
 
+// HasEntitiesError is returned when deleting a blueprint that still has
+// entities without forcing it
+type HasEntitiesError struct {
+	Entities int64
+}
+
+func (e *HasEntitiesError) Error() string {
+	return fmt.Sprintf("blueprint has %d entities; delete with force=true to delete them too", e.Entities)
+}
+
 type Service struct {
-	repo *Repository
+	repo  *Repository
+	bus   *events.Bus
+	cache cache.Store
+	ttl   time.Duration
 }
 
-func NewService(repo *Repository) *Service {
-	return &Service{repo: repo}
+// NewService creates the blueprint service. Changes are published on bus,
+// which may be nil. Get keeps blueprints in store for ttl when both are set;
+// blueprint events on bus drop them, including those of bundle applies.
+func NewService(repo *Repository, bus *events.Bus, store cache.Store, ttl time.Duration) *Service {
+	s := &Service{repo: repo, bus: bus}
+	if store != nil && ttl > 0 && bus != nil {
+		s.cache, s.ttl = store, ttl
+		bus.Subscribe("blueprint.*", func(ctx context.Context, e events.Event) {
+			cache.Invalidate(ctx, s.cache, cacheKey(e.TeamID, e.BlueprintID))
+		})
+	}
+	return s
+}
+
+func cacheKey(teamID uuid.UUID, id string) string {
+	return "blueprint:" + teamID.String() + ":" + id
 }
 
 func (s *Service) Create(ctx context.Context, teamID uuid.UUID, req *CreateBlueprintRequest) (*Blueprint, error) {
+	if err := validation.ValidateSlug("id", req.ID); err != nil {
+		return nil, err
+	}
+	if err := req.MergePolicy.Validate(); err != nil {
+		return nil, err
+	}
+	if err := req.ExpiryPolicy.Validate(req.Schema); err != nil {
+		return nil, err
+	}
+	if err := ValidateDefaults(req.Schema); err != nil {
+		return nil, err
+	}
+	if err := ValidateRollups(req.Schema); err != nil {
+		return nil, err
+	}
+
 	// Check if blueprint already exists
 	exists, err := s.repo.Exists(ctx, teamID, req.ID)
 	if err != nil {
@@ -31,22 +80,35 @@ func (s *Service) Create(ctx context.Context, teamID uuid.UUID, req *CreateBluep
 	}
 
 	bp := &Blueprint{
-		ID:          req.ID,
-		TeamID:      teamID,
-		Title:       req.Title,
-		Description: req.Description,
-		Icon:        req.Icon,
-		Schema:      req.Schema,
+		ID:                req.ID,
+		TeamID:            teamID,
+		Title:             req.Title,
+		Description:       req.Description,
+		Icon:              req.Icon,
+		Schema:            req.Schema,
+		IdentifierMutable: req.IdentifierMutable,
+		MergePolicy:       normalizeMergePolicy(req.MergePolicy),
+		ExpiryPolicy:      normalizeExpiryPolicy(req.ExpiryPolicy),
+		StrictUpdates:     req.StrictUpdates,
 	}
 
 	if err := s.repo.Create(ctx, bp); err != nil {
 		return nil, err
 	}
+	s.publish(ctx, events.BlueprintCreated, teamID, bp.ID, bp)
 
 	return bp, nil
 }
 
+// Get returns a blueprint, from the cache when it has one. Entity writes
+// look up their blueprint on every request, so this is the hot path.
 func (s *Service) Get(ctx context.Context, teamID uuid.UUID, id string) (*Blueprint, error) {
+	key := cacheKey(teamID, id)
+	var cached Blueprint
+	if cache.GetJSON(ctx, s.cache, key, &cached) {
+		return &cached, nil
+	}
+
 	bp, err := s.repo.GetByID(ctx, teamID, id)
 	if err != nil {
 		return nil, err
@@ -54,6 +116,7 @@ func (s *Service) Get(ctx context.Context, teamID uuid.UUID, id string) (*Bluepr
 	if bp == nil {
 		return nil, ErrNotFound
 	}
+	cache.SetJSON(ctx, s.cache, key, bp, s.ttl)
 	return bp, nil
 }
 
@@ -74,6 +137,10 @@ func (s *Service) List(ctx context.Context, teamID uuid.UUID) (*ListBlueprintsRe
 }
 
 func (s *Service) Update(ctx context.Context, teamID uuid.UUID, id string, req *UpdateBlueprintRequest) (*Blueprint, error) {
+	if err := req.MergePolicy.Validate(); err != nil {
+		return nil, err
+	}
+
 	bp, err := s.repo.GetByID(ctx, teamID, id)
 	if err != nil {
 		return nil, err
@@ -81,6 +148,7 @@ func (s *Service) Update(ctx context.Context, teamID uuid.UUID, id string, req *
 	if bp == nil {
 		return nil, ErrNotFound
 	}
+	previous := *bp
 
 	if req.Title != "" {
 		bp.Title = req.Title
@@ -94,24 +162,64 @@ func (s *Service) Update(ctx context.Context, teamID uuid.UUID, id string, req *
 	if req.Schema != nil {
 		bp.Schema = req.Schema
 	}
+	if req.IdentifierMutable != nil {
+		bp.IdentifierMutable = *req.IdentifierMutable
+	}
+	if req.StrictUpdates != nil {
+		bp.StrictUpdates = *req.StrictUpdates
+	}
+	if req.MergePolicy != nil {
+		bp.MergePolicy = normalizeMergePolicy(req.MergePolicy)
+	}
+	if req.ExpiryPolicy != nil {
+		bp.ExpiryPolicy = normalizeExpiryPolicy(req.ExpiryPolicy)
+	}
+	// A new schema may drop the properties of the current policy
+	if err := bp.ExpiryPolicy.Validate(bp.Schema); err != nil {
+		return nil, err
+	}
+	if err := ValidateDefaults(bp.Schema); err != nil {
+		return nil, err
+	}
+	if err := ValidateRollups(bp.Schema); err != nil {
+		return nil, err
+	}
 
 	if err := s.repo.Update(ctx, bp); err != nil {
 		return nil, err
 	}
+	s.bus.Publish(ctx, events.Event{
+		Type:        events.BlueprintUpdated,
+		TeamID:      teamID,
+		BlueprintID: bp.ID,
+		Payload:     bp,
+		Previous:    &previous,
+	})
 
 	return bp, nil
 }
 
-func (s *Service) Delete(ctx context.Context, teamID uuid.UUID, id string) error {
-	exists, err := s.repo.Exists(ctx, teamID, id)
+// Delete deletes a blueprint. A blueprint with entities is only deleted
+// with force, which deletes its entities along with it.
+func (s *Service) Delete(ctx context.Context, teamID uuid.UUID, id string, force bool) error {
+	found, err := s.repo.Delete(ctx, teamID, id, force)
 	if err != nil {
 		return err
 	}
-	if !exists {
+	if !found {
 		return ErrNotFound
 	}
+	s.publish(ctx, events.BlueprintDeleted, teamID, id, nil)
+	return nil
+}
 
-	return s.repo.Delete(ctx, teamID, id)
+func (s *Service) publish(ctx context.Context, eventType string, teamID uuid.UUID, blueprintID string, payload interface{}) {
+	s.bus.Publish(ctx, events.Event{
+		Type:        eventType,
+		TeamID:      teamID,
+		BlueprintID: blueprintID,
+		Payload:     payload,
+	})
 }
 
 func (s *Service) GetSchema(ctx context.Context, teamID uuid.UUID, id string) (map[string]interface{}, error) {
@@ -121,3 +229,13 @@ func (s *Service) GetSchema(ctx context.Context, teamID uuid.UUID, id string) (m
 	}
 	return bp.Schema, nil
 }
+
+// GetStandaloneSchema returns the blueprint's entity schema as a self-contained
+// JSON Schema, see StandaloneSchema
+func (s *Service) GetStandaloneSchema(ctx context.Context, teamID uuid.UUID, id string) (map[string]interface{}, error) {
+	bp, err := s.Get(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	return StandaloneSchema(bp)
+}
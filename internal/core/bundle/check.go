@@ -0,0 +1,372 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/goccy/go-yaml/ast"
+	"github.com/goccy/go-yaml/parser"
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/validation"
+)
+
+// MaxAnnotations is how many annotations a check returns, the most the
+// GitHub Checks API accepts in one request. The summary counts all problems.
+const MaxAnnotations = 50
+
+// Check conclusions and annotation levels, as named by the GitHub Checks API
+const (
+	ConclusionSuccess = "success"
+	ConclusionFailure = "failure"
+
+	AnnotationFailure = "failure"
+)
+
+// CheckRequest is a set of manifest files to validate before they are merged.
+// Files are YAML or JSON and together form one manifest.
+type CheckRequest struct {
+	Files []CheckFile `json:"files" binding:"required,min=1,dive"`
+}
+
+type CheckFile struct {
+	Path    string `json:"path" binding:"required"`
+	Content string `json:"content"`
+}
+
+// CheckEntity is an entity declared next to the manifest. Apply does not
+// write entities; the check validates them against their blueprint.
+type CheckEntity struct {
+	Blueprint  string                 `json:"blueprint"`
+	Identifier string                 `json:"identifier"`
+	Title      string                 `json:"title,omitempty"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// CheckResult has the shape of a GitHub check run's conclusion and output,
+// so it can be sent to the Checks API as is
+type CheckResult struct {
+	Conclusion string      `json:"conclusion"`
+	Output     CheckOutput `json:"output"`
+}
+
+type CheckOutput struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+type Annotation struct {
+	Path            string `json:"path"`
+	StartLine       int    `json:"start_line"`
+	EndLine         int    `json:"end_line"`
+	AnnotationLevel string `json:"annotation_level"`
+	Title           string `json:"title,omitempty"`
+	Message         string `json:"message"`
+}
+
+// checkDocument is one manifest file; entities are only read by the check
+type checkDocument struct {
+	Manifest
+	Entities []CheckEntity `json:"entities"`
+}
+
+// checkFile is a parsed manifest file, kept to find the lines of its items
+type checkFile struct {
+	path string
+	ast  *ast.File
+}
+
+// line returns the line of the node at path, or of its closest ancestor
+// that exists. Path segments are keys (string) and sequence indexes (int).
+func (f *checkFile) line(path []interface{}) int {
+	for n := len(path); n > 0 && f.ast != nil; n-- {
+		b := (&yaml.PathBuilder{}).Root()
+		for _, segment := range path[:n] {
+			switch s := segment.(type) {
+			case string:
+				b = b.Child(s)
+			case int:
+				b = b.Index(uint(s))
+			}
+		}
+		node, err := b.Build().FilterFile(f.ast)
+		if err == nil && node != nil && node.GetToken() != nil {
+			return node.GetToken().Position.Line
+		}
+	}
+	return 1
+}
+
+// site is where an item was declared: its file and path within it
+type site struct {
+	file *checkFile
+	path []interface{}
+}
+
+// checker collects the problems found in a set of manifest files
+type checker struct {
+	problems    int
+	annotations []Annotation
+}
+
+func (c *checker) fail(at site, title, message string, subpath ...interface{}) {
+	c.failAt(at.file.path, at.file.line(append(append([]interface{}{}, at.path...), subpath...)), title, message)
+}
+
+func (c *checker) failAt(path string, line int, title, message string) {
+	c.problems++
+	if len(c.annotations) == MaxAnnotations {
+		return
+	}
+	c.annotations = append(c.annotations, Annotation{
+		Path:            path,
+		StartLine:       line,
+		EndLine:         line,
+		AnnotationLevel: AnnotationFailure,
+		Title:           title,
+		Message:         message,
+	})
+}
+
+// bundleMessage drops the error prefix shared by every validation error
+func bundleMessage(err error) string {
+	return strings.TrimPrefix(err.Error(), ErrInvalidBundle.Error()+": ")
+}
+
+// checkSet is the merged content of the checked files, with where each item
+// was declared
+type checkSet struct {
+	files      int
+	manifest   Manifest
+	entities   []CheckEntity
+	blueprints []site
+	roles      []site
+	scorecards []site
+	entitySite []site
+}
+
+// Check validates manifest files the way Apply would, without writing
+// anything, and reports each problem at the line of the item it concerns.
+// Entities in the files are validated against their blueprint's schema.
+func (s *Service) Check(ctx context.Context, teamID uuid.UUID, req *CheckRequest) (*CheckResult, error) {
+	c := &checker{annotations: []Annotation{}}
+	set := c.read(req.Files)
+	current, err := s.currentState(ctx, teamID, &set.manifest)
+	if err != nil {
+		return nil, err
+	}
+	c.check(set, current)
+	return c.result(set), nil
+}
+
+// read parses the files and merges them, skipping those that do not parse
+func (c *checker) read(files []CheckFile) *checkSet {
+	set := &checkSet{files: len(files)}
+	for _, f := range files {
+		file := &checkFile{path: f.Path}
+		doc, ok := c.parse(file, []byte(f.Content))
+		if !ok {
+			continue
+		}
+		if len(doc.Webhooks) > 0 && string(doc.Webhooks) != "null" {
+			c.fail(site{file: file}, "webhooks", "webhooks are not supported by this server", "webhooks")
+		}
+		for i := range doc.Blueprints {
+			set.blueprints = append(set.blueprints, site{file, []interface{}{"blueprints", i}})
+		}
+		for i := range doc.Roles {
+			set.roles = append(set.roles, site{file, []interface{}{"roles", i}})
+		}
+		for i := range doc.Scorecards {
+			set.scorecards = append(set.scorecards, site{file, []interface{}{"scorecards", i}})
+		}
+		for i := range doc.Entities {
+			set.entitySite = append(set.entitySite, site{file, []interface{}{"entities", i}})
+		}
+		set.manifest.Blueprints = append(set.manifest.Blueprints, doc.Blueprints...)
+		set.manifest.Roles = append(set.manifest.Roles, doc.Roles...)
+		set.manifest.Scorecards = append(set.manifest.Scorecards, doc.Scorecards...)
+		set.entities = append(set.entities, doc.Entities...)
+	}
+	return set
+}
+
+// check validates the merged items against each other and the team
+func (c *checker) check(set *checkSet, current *currentState) {
+	m := &set.manifest
+
+	// Schemas entities are validated against: the manifest's, then the team's
+	schemas := map[string]map[string]interface{}{}
+	for id, bp := range current.blueprints {
+		schemas[id] = bp.Schema
+	}
+	seen := map[string]bool{}
+	for i, bp := range m.Blueprints {
+		at, title := set.blueprints[i], "blueprint "+bp.ID
+		if err := validate(&Bundle{Version: Version, Blueprints: []Blueprint{bp}}, &teamState{}); err != nil {
+			c.fail(at, title, bundleMessage(err))
+			continue
+		}
+		switch {
+		case seen[bp.ID]:
+			c.fail(at, title, fmt.Sprintf("duplicate blueprint %q", bp.ID), "id")
+		case current.takenIDs[bp.ID] && current.blueprints[bp.ID] == nil:
+			c.fail(at, title, fmt.Sprintf("blueprint id %q is used by another team", bp.ID), "id")
+		}
+		if err := validation.CheckSchema(bp.Schema); err != nil {
+			c.fail(at, title, "schema is not a valid JSON Schema: "+err.Error(), "schema")
+		}
+		seen[bp.ID] = true
+		schemas[bp.ID] = bp.Schema
+	}
+
+	seen = map[string]bool{}
+	for i, role := range m.Roles {
+		at, title := set.roles[i], "role "+role.Name
+		if seen[role.Name] {
+			c.fail(at, title, fmt.Sprintf("duplicate role %q", role.Name), "name")
+			continue
+		}
+		seen[role.Name] = true
+		if err := validateManifest(&Manifest{Roles: []Role{role}}, &currentState{}); err != nil {
+			c.fail(at, title, bundleMessage(err))
+		}
+	}
+
+	known := &teamState{blueprints: map[string]bool{}}
+	for id := range schemas {
+		known.blueprints[id] = true
+	}
+	seen = map[string]bool{}
+	for i, sc := range m.Scorecards {
+		key := sc.Blueprint + "/" + sc.Identifier
+		at, title := set.scorecards[i], "scorecard "+key
+		if seen[key] {
+			c.fail(at, title, fmt.Sprintf("duplicate scorecard %q", key), "identifier")
+			continue
+		}
+		seen[key] = true
+		if err := validate(&Bundle{Version: Version, Scorecards: []Scorecard{sc}}, known); err != nil {
+			c.fail(at, title, bundleMessage(err))
+		}
+	}
+
+	validator := validation.NewValidator()
+	seen = map[string]bool{}
+	for i, e := range set.entities {
+		key := e.Blueprint + "/" + e.Identifier
+		at, title := set.entitySite[i], "entity "+key
+		schema, ok := schemas[e.Blueprint]
+		switch {
+		case e.Blueprint == "":
+			c.fail(at, title, "entity has no blueprint")
+			continue
+		case !ok:
+			c.fail(at, title, fmt.Sprintf("entity refers to unknown blueprint %q", e.Blueprint), "blueprint")
+			continue
+		case e.Identifier == "":
+			c.fail(at, title, "entity has no identifier")
+			continue
+		case seen[key]:
+			c.fail(at, title, fmt.Sprintf("duplicate entity %q", key), "identifier")
+			continue
+		}
+		seen[key] = true
+
+		// Other errors come from a broken schema, which is reported on the blueprint
+		if verr := validation.GetValidationErrors(validator.Validate(e.Data, schema)); verr != nil {
+			for _, fe := range verr.Errors {
+				c.fail(at, title, fe.Field+": "+fe.Message, dataPath(fe.Field)...)
+			}
+		}
+	}
+}
+
+// result is the check run conclusion and output for what was found
+func (c *checker) result(set *checkSet) *CheckResult {
+	result := &CheckResult{
+		Conclusion: ConclusionSuccess,
+		Output: CheckOutput{
+			Title:       "Manifest is valid",
+			Annotations: c.annotations,
+		},
+	}
+	if c.problems > 0 {
+		result.Conclusion = ConclusionFailure
+		result.Output.Title = fmt.Sprintf("%d problem(s) found", c.problems)
+	}
+	result.Output.Summary = fmt.Sprintf("Checked %d file(s): %d blueprint(s), %d role(s), %d scorecard(s) and %d entity(ies). %d problem(s) found.",
+		set.files, len(set.manifest.Blueprints), len(set.manifest.Roles), len(set.manifest.Scorecards), len(set.entities), c.problems)
+	if c.problems > len(c.annotations) {
+		result.Output.Summary += fmt.Sprintf(" Only the first %d are annotated.", len(c.annotations))
+	}
+	return result
+}
+
+// parse reads a YAML or JSON manifest file, reporting syntax and type errors
+func (c *checker) parse(file *checkFile, content []byte) (*checkDocument, bool) {
+	parsed, err := parser.ParseBytes(content, 0)
+	if err != nil {
+		line, message := 1, err.Error()
+		var yerr yaml.Error
+		if errors.As(err, &yerr) {
+			message = yerr.GetMessage()
+			if tk := yerr.GetToken(); tk != nil {
+				line = tk.Position.Line
+			}
+		}
+		c.failAt(file.path, line, "syntax error", message)
+		return nil, false
+	}
+	file.ast = parsed
+
+	encoded, err := yaml.YAMLToJSON(content)
+	if err != nil {
+		c.failAt(file.path, 1, "syntax error", err.Error())
+		return nil, false
+	}
+	var doc checkDocument
+	if trimmed := strings.TrimSpace(string(encoded)); trimmed == "" || trimmed == "null" {
+		return &doc, true
+	}
+	if err := json.Unmarshal(encoded, &doc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			message := fmt.Sprintf("%s must be %s, not %s", typeErr.Field, typeErr.Type, typeErr.Value)
+			c.fail(site{file: file}, "invalid manifest", message, fieldPath(typeErr.Field)...)
+		} else {
+			c.failAt(file.path, 1, "invalid manifest", err.Error())
+		}
+		return nil, false
+	}
+	return &doc, true
+}
+
+// fieldPath splits a dotted field such as "owner.emails.0" into path
+// segments; numeric segments are sequence indexes
+func fieldPath(field string) []interface{} {
+	var path []interface{}
+	for _, segment := range strings.Split(field, ".") {
+		if i, err := strconv.Atoi(segment); err == nil {
+			path = append(path, i)
+		} else {
+			path = append(path, segment)
+		}
+	}
+	return path
+}
+
+// dataPath is the path of a validation error's field within an entity;
+// "(root)" is the data itself
+func dataPath(field string) []interface{} {
+	if field == "" || field == "(root)" {
+		return []interface{}{"data"}
+	}
+	return append([]interface{}{"data"}, fieldPath(field)...)
+}
@@ -0,0 +1,141 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+)
+
+func runCheck(current *currentState, files ...CheckFile) *CheckResult {
+	c := &checker{annotations: []Annotation{}}
+	set := c.read(files)
+	c.check(set, current)
+	return c.result(set)
+}
+
+func TestCheck_Valid(t *testing.T) {
+	manifest := `blueprints:
+  - id: service
+    title: Service
+    schema:
+      type: object
+      properties:
+        tier: {type: integer}
+roles:
+  - name: viewer
+    permissions: [entity:read]
+entities:
+  - blueprint: service
+    identifier: payments
+    data: {tier: 1}
+`
+	result := runCheck(emptyCurrent(), CheckFile{Path: "baseplate.yaml", Content: manifest})
+	if result.Conclusion != ConclusionSuccess || len(result.Output.Annotations) != 0 {
+		t.Fatalf("result = %+v, want success", result)
+	}
+}
+
+func TestCheck_AnnotatesLines(t *testing.T) {
+	manifest := `blueprints:
+  - id: service
+    title: Service
+    schema:
+      type: object
+      properties:
+        tier: {type: integer}
+  - id: team
+roles:
+  - name: viewer
+    permissions: [entity:launch]
+`
+	entities := `{
+  "entities": [
+    {"blueprint": "service", "identifier": "payments",
+     "data": {
+       "tier": "one"
+     }},
+    {"blueprint": "cluster", "identifier": "eu-1", "data": {}}
+  ]
+}`
+	result := runCheck(emptyCurrent(),
+		CheckFile{Path: "baseplate.yaml", Content: manifest},
+		CheckFile{Path: "catalog/entities.json", Content: entities},
+	)
+	if result.Conclusion != ConclusionFailure {
+		t.Fatalf("conclusion = %s, want failure", result.Conclusion)
+	}
+
+	want := []struct {
+		path  string
+		line  int
+		title string
+	}{
+		{"baseplate.yaml", 8, "blueprint team"},
+		{"baseplate.yaml", 10, "role viewer"},
+		{"catalog/entities.json", 5, "entity service/payments"},
+		{"catalog/entities.json", 7, "entity cluster/eu-1"},
+	}
+	got := result.Output.Annotations
+	if len(got) != len(want) {
+		t.Fatalf("annotations = %+v, want %d", got, len(want))
+	}
+	for i, w := range want {
+		a := got[i]
+		if a.Path != w.path || a.StartLine != w.line || a.EndLine != w.line || a.Title != w.title || a.AnnotationLevel != AnnotationFailure {
+			t.Errorf("annotation %d = %+v, want %s:%d %q", i, a, w.path, w.line, w.title)
+		}
+	}
+	if !strings.Contains(result.Output.Summary, "4 problem(s)") {
+		t.Errorf("summary = %q", result.Output.Summary)
+	}
+}
+
+func TestCheck_SyntaxError(t *testing.T) {
+	result := runCheck(emptyCurrent(), CheckFile{Path: "baseplate.yaml", Content: "blueprints:\n  - id: service\n   title: [Service\n"})
+	got := result.Output.Annotations
+	if result.Conclusion != ConclusionFailure || len(got) != 1 || got[0].Title != "syntax error" || got[0].StartLine < 2 {
+		t.Fatalf("result = %+v, want a syntax error past line 1", result)
+	}
+}
+
+func TestCheck_TeamState(t *testing.T) {
+	current := emptyCurrent()
+	current.blueprints["service"] = &Blueprint{ID: "service", Title: "Service", Schema: map[string]interface{}{
+		"type": "object", "required": []interface{}{"owner"},
+	}}
+	current.takenIDs["cluster"] = true
+
+	manifest := `blueprints:
+  - id: cluster
+    title: Cluster
+entities:
+  - blueprint: service
+    identifier: payments
+    data: {}
+`
+	result := runCheck(current, CheckFile{Path: "baseplate.yaml", Content: manifest})
+	got := result.Output.Annotations
+	if len(got) != 2 {
+		t.Fatalf("annotations = %+v, want 2", got)
+	}
+	if got[0].StartLine != 2 || !strings.Contains(got[0].Message, "used by another team") {
+		t.Errorf("taken ID annotation = %+v", got[0])
+	}
+	if got[1].StartLine != 7 || !strings.Contains(got[1].Message, "owner is required") {
+		t.Errorf("entity annotation = %+v", got[1])
+	}
+}
+
+func TestCheck_CapsAnnotations(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("roles:\n")
+	for i := 0; i < MaxAnnotations+5; i++ {
+		b.WriteString("  - name: \"\"\n")
+	}
+	result := runCheck(emptyCurrent(), CheckFile{Path: "baseplate.yaml", Content: b.String()})
+	if len(result.Output.Annotations) != MaxAnnotations {
+		t.Errorf("annotations = %d, want %d", len(result.Output.Annotations), MaxAnnotations)
+	}
+	if !strings.Contains(result.Output.Summary, "55 problem(s)") {
+		t.Errorf("summary = %q", result.Output.Summary)
+	}
+}
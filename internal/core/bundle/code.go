@@ -0,0 +1,404 @@
+package bundle
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/uuid"
+)
+
+// Code formats
+const (
+	FormatYAML      = "yaml"
+	FormatTerraform = "terraform"
+)
+
+// Kinds that can be exported as code, in the order they are written
+var CodeKinds = []string{"blueprints", "roles", "actions", "api_keys"}
+
+// APIKey describes an API key for code; the key itself is never exported
+type APIKey struct {
+	Name        string     `json:"name"`
+	Permissions []string   `json:"permissions"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// Code is a team's configuration written as code, to start managing it in a
+// repository. Like bundles it carries no team-specific IDs.
+type Code struct {
+	Blueprints []Blueprint `json:"blueprints,omitempty"`
+	Roles      []Role      `json:"roles,omitempty"`
+	Actions    []Action    `json:"actions,omitempty"`
+	APIKeys    []APIKey    `json:"api_keys,omitempty"`
+}
+
+// ExportCode writes the team's blueprints, roles, actions and API keys, or
+// the given kinds of them, as YAML or Terraform. The YAML lists the fields of
+// the bundle and manifest formats; Terraform declares a baseplate_* resource
+// per item with the same fields.
+func (s *Service) ExportCode(ctx context.Context, teamID uuid.UUID, format string, kinds []string) ([]byte, error) {
+	if format != FormatYAML && format != FormatTerraform {
+		return nil, fmt.Errorf("%w: format must be %s or %s", ErrInvalidExport, FormatYAML, FormatTerraform)
+	}
+	if len(kinds) == 0 {
+		kinds = CodeKinds
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(CodeKinds, kind) {
+			return nil, fmt.Errorf("%w: unknown kind %q, must be one of %s", ErrInvalidExport, kind, strings.Join(CodeKinds, ", "))
+		}
+	}
+
+	code := &Code{}
+	if slices.Contains(kinds, "blueprints") {
+		list, err := s.blueprintSvc.List(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+		// List is newest first; creation order reads better
+		for i := len(list.Blueprints) - 1; i >= 0; i-- {
+			code.Blueprints = append(code.Blueprints, exportBlueprint(list.Blueprints[i]))
+		}
+	}
+	if slices.Contains(kinds, "roles") {
+		roles, err := s.repo.ListRoles(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+		code.Roles = roles
+	}
+	if slices.Contains(kinds, "actions") {
+		actions, err := s.repo.ListActions(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+		code.Actions = actions
+	}
+	if slices.Contains(kinds, "api_keys") {
+		keys, err := s.repo.ListAPIKeys(ctx, teamID)
+		if err != nil {
+			return nil, err
+		}
+		code.APIKeys = keys
+	}
+
+	if format == FormatYAML {
+		return renderYAML(code)
+	}
+	return renderTerraform(code)
+}
+
+// renderYAML writes the code through JSON, so fields are named as in the API
+func renderYAML(code *Code) ([]byte, error) {
+	data, err := json.Marshal(code)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.JSONToYAML(data)
+}
+
+// attribute is one argument of a Terraform block; value is HCL
+type attribute struct {
+	name  string
+	value string
+}
+
+// renderTerraform writes a resource block per item. Schemas, policies and
+// action definitions are written with jsonencode, and actions refer to the
+// blueprints exported with them.
+func renderTerraform(code *Code) ([]byte, error) {
+	var buf bytes.Buffer
+	names := map[string]map[string]bool{}
+	blueprintRefs := map[string]string{}
+
+	for _, bp := range code.Blueprints {
+		name := resourceName(names, "baseplate_blueprint", bp.ID)
+		blueprintRefs[bp.ID] = "baseplate_blueprint." + name + ".id"
+		attrs := []attribute{
+			{"id", hclString(bp.ID)},
+			{"title", hclString(bp.Title)},
+		}
+		if bp.Description != "" {
+			attrs = append(attrs, attribute{"description", hclString(bp.Description)})
+		}
+		if bp.Icon != "" {
+			attrs = append(attrs, attribute{"icon", hclString(bp.Icon)})
+		}
+		if bp.IdentifierMutable {
+			attrs = append(attrs, attribute{"identifier_mutable", "true"})
+		}
+		if bp.StrictUpdates {
+			attrs = append(attrs, attribute{"strict_updates", "true"})
+		}
+		encoded, err := jsonencode(map[string]interface{}{
+			"schema":        bp.Schema,
+			"merge_policy":  bp.MergePolicy,
+			"expiry_policy": bp.ExpiryPolicy,
+		}, "schema", "merge_policy", "expiry_policy")
+		if err != nil {
+			return nil, err
+		}
+		writeResource(&buf, "baseplate_blueprint", name, append(attrs, encoded...))
+	}
+
+	for _, role := range code.Roles {
+		writeResource(&buf, "baseplate_role", resourceName(names, "baseplate_role", role.Name), []attribute{
+			{"name", hclString(role.Name)},
+			{"permissions", hclStrings(role.Permissions)},
+		})
+	}
+
+	for _, action := range code.Actions {
+		name := action.Identifier
+		if action.Blueprint != "" {
+			name = action.Blueprint + "_" + action.Identifier
+		}
+		var attrs []attribute
+		if ref, ok := blueprintRefs[action.Blueprint]; ok {
+			attrs = append(attrs, attribute{"blueprint", ref})
+		} else if action.Blueprint != "" {
+			attrs = append(attrs, attribute{"blueprint", hclString(action.Blueprint)})
+		}
+		attrs = append(attrs,
+			attribute{"identifier", hclString(action.Identifier)},
+			attribute{"title", hclString(action.Title)},
+		)
+		if action.Description != "" {
+			attrs = append(attrs, attribute{"description", hclString(action.Description)})
+		}
+		if action.TriggerType != "" {
+			attrs = append(attrs, attribute{"trigger_type", hclString(action.TriggerType)})
+		}
+		encoded, err := jsonencode(map[string]interface{}{
+			"trigger_config": action.TriggerConfig,
+			"user_inputs":    action.UserInputs,
+			"steps":          orEmptyList(action.Steps),
+		}, "trigger_config", "user_inputs", "steps")
+		if err != nil {
+			return nil, err
+		}
+		writeResource(&buf, "baseplate_action", resourceName(names, "baseplate_action", name), append(attrs, encoded...))
+	}
+
+	for _, key := range code.APIKeys {
+		attrs := []attribute{
+			{"name", hclString(key.Name)},
+			{"permissions", hclStrings(orEmptyStrings(key.Permissions))},
+		}
+		if key.ExpiresAt != nil {
+			attrs = append(attrs, attribute{"expires_at", hclString(key.ExpiresAt.UTC().Format(time.RFC3339))})
+		}
+		writeResource(&buf, "baseplate_api_key", resourceName(names, "baseplate_api_key", key.Name), attrs)
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonencode returns a jsonencode(...) attribute for each of the given
+// fields that is set, in that order
+func jsonencode(values map[string]interface{}, order ...string) ([]attribute, error) {
+	var attrs []attribute
+	for _, name := range order {
+		// Round-trip through JSON so typed values become maps and lists
+		data, err := json.Marshal(values[name])
+		if err != nil {
+			return nil, err
+		}
+		var plain interface{}
+		if err := json.Unmarshal(data, &plain); err != nil {
+			return nil, err
+		}
+		if plain == nil {
+			continue
+		}
+		attrs = append(attrs, attribute{name, "jsonencode(" + hclValue(plain, "") + ")"})
+	}
+	return attrs, nil
+}
+
+// writeResource writes a block, aligning the equals signs of consecutive
+// single-line arguments as terraform fmt does
+func writeResource(buf *bytes.Buffer, resourceType, name string, attrs []attribute) {
+	if buf.Len() > 0 {
+		buf.WriteString("\n")
+	}
+	fmt.Fprintf(buf, "resource %q %q {\n", resourceType, name)
+	indented := make([]attribute, len(attrs))
+	for i, attr := range attrs {
+		indented[i] = attribute{attr.name, indent(attr.value, "  ")}
+	}
+	buf.WriteString(aligned(indented, "  "))
+	buf.WriteString("}\n")
+}
+
+// aligned writes one line per attribute, padding the names of consecutive
+// single-line attributes to the same width
+func aligned(attrs []attribute, prefix string) string {
+	var b strings.Builder
+	for i := 0; i < len(attrs); {
+		end := i + 1
+		if !strings.Contains(attrs[i].value, "\n") {
+			for end < len(attrs) && !strings.Contains(attrs[end].value, "\n") {
+				end++
+			}
+		}
+		width := 0
+		for _, attr := range attrs[i:end] {
+			width = max(width, len(attr.name))
+		}
+		for _, attr := range attrs[i:end] {
+			fmt.Fprintf(&b, "%s%-*s = %s\n", prefix, width, attr.name, attr.value)
+		}
+		i = end
+	}
+	return b.String()
+}
+
+// resourceName turns an identifier into a Terraform name unique within its type
+func resourceName(taken map[string]map[string]bool, resourceType, identifier string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(identifier) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' || r == '-' {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	name := b.String()
+	if name == "" || (name[0] >= '0' && name[0] <= '9') || name[0] == '-' {
+		name = "_" + name
+	}
+
+	if taken[resourceType] == nil {
+		taken[resourceType] = map[string]bool{}
+	}
+	unique := name
+	for n := 2; taken[resourceType][unique]; n++ {
+		unique = name + "_" + strconv.Itoa(n)
+	}
+	taken[resourceType][unique] = true
+	return unique
+}
+
+func indent(value, prefix string) string {
+	return strings.ReplaceAll(value, "\n", "\n"+prefix)
+}
+
+// hclValue writes a JSON value as an HCL expression; nested lines are
+// indented relative to the first
+func hclValue(v interface{}, prefix string) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return hclString(v)
+	case []interface{}:
+		if len(v) == 0 {
+			return "[]"
+		}
+		scalars := true
+		for _, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				scalars = false
+			}
+		}
+		if scalars {
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = hclValue(item, prefix)
+			}
+			return "[" + strings.Join(items, ", ") + "]"
+		}
+		var b strings.Builder
+		b.WriteString("[\n")
+		for _, item := range v {
+			b.WriteString(prefix + "  " + hclValue(item, prefix+"  ") + ",\n")
+		}
+		b.WriteString(prefix + "]")
+		return b.String()
+	case map[string]interface{}:
+		if len(v) == 0 {
+			return "{}"
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		attrs := make([]attribute, len(keys))
+		for i, key := range keys {
+			attrs[i] = attribute{hclKey(key), hclValue(v[key], prefix+"  ")}
+		}
+		return "{\n" + aligned(attrs, prefix+"  ") + prefix + "}"
+	default:
+		return hclString(fmt.Sprint(v))
+	}
+}
+
+// hclKey writes an object key bare when it is an identifier, quoted otherwise
+func hclKey(key string) string {
+	for i, r := range key {
+		letter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_'
+		if !letter && (i == 0 || !((r >= '0' && r <= '9') || r == '-')) {
+			return hclString(key)
+		}
+	}
+	if key == "" {
+		return `""`
+	}
+	return key
+}
+
+// hclString quotes a string for HCL, escaping template sequences so values
+// are taken literally
+func hclString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			b.WriteString(`\\`)
+		case '"':
+			b.WriteString(`\"`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '$', '%':
+			b.WriteByte(c)
+			if i+1 < len(s) && s[i+1] == '{' {
+				b.WriteByte(c)
+			}
+		default:
+			if c < 0x20 {
+				fmt.Fprintf(&b, `\u%04x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+func hclStrings(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = hclString(v)
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
@@ -0,0 +1,91 @@
+package bundle
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderTerraform(t *testing.T) {
+	expires := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	code := &Code{
+		Blueprints: []Blueprint{{
+			ID:    "service",
+			Title: "Service",
+			Schema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"on-call": map[string]interface{}{"type": "string"}},
+				"required":   []interface{}{"on-call"},
+			},
+		}},
+		Roles: []Role{{Name: "sre team", Permissions: []string{"entity:read"}}},
+		Actions: []Action{
+			{Blueprint: "service", Identifier: "deploy", Title: "Deploy", Steps: []interface{}{map[string]interface{}{"run": "echo ${VERSION}"}}},
+			{Blueprint: "database", Identifier: "deploy", Title: "Deploy DB"},
+		},
+		APIKeys: []APIKey{{Name: "CI", Permissions: nil, ExpiresAt: &expires}},
+	}
+
+	out, err := renderTerraform(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		"resource \"baseplate_blueprint\" \"service\" {\n  id    = \"service\"\n  title = \"Service\"\n",
+		"  schema = jsonencode({\n    properties = {\n      on-call = {\n        type = \"string\"\n      }\n    }\n    required = [\"on-call\"]\n    type     = \"object\"\n  })\n",
+		"resource \"baseplate_role\" \"sre_team\" {\n  name        = \"sre team\"\n",
+		"resource \"baseplate_action\" \"service_deploy\" {\n  blueprint  = baseplate_blueprint.service.id\n",
+		"run = \"echo $${VERSION}\"",
+		"resource \"baseplate_action\" \"database_deploy\" {\n  blueprint  = \"database\"\n",
+		"  steps      = jsonencode([])\n",
+		"  permissions = []\n  expires_at  = \"2027-01-01T00:00:00Z\"\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output lacks %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestResourceName(t *testing.T) {
+	taken := map[string]map[string]bool{}
+	for _, tt := range []struct{ identifier, want string }{
+		{"service", "service"},
+		{"Service", "service_2"},
+		{"2fa-admins", "_2fa-admins"},
+		{"", "_"},
+	} {
+		if got := resourceName(taken, "baseplate_role", tt.identifier); got != tt.want {
+			t.Errorf("resourceName(%q) = %q, want %q", tt.identifier, got, tt.want)
+		}
+	}
+	if got := resourceName(taken, "baseplate_blueprint", "service"); got != "service" {
+		t.Errorf("names should be unique per type, got %q", got)
+	}
+}
+
+func TestHCLString(t *testing.T) {
+	tests := map[string]string{
+		`plain`:          `"plain"`,
+		`say "hi"`:       `"say \"hi\""`,
+		"a\nb":           `"a\nb"`,
+		`${var} %{if} $`: `"$${var} %%{if} $"`,
+		`C:\path`:        `"C:\\path"`,
+	}
+	for in, want := range tests {
+		if got := hclString(in); got != want {
+			t.Errorf("hclString(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestRenderYAML(t *testing.T) {
+	out, err := renderYAML(&Code{Roles: []Role{{Name: "editor", Permissions: []string{"entity:write"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "roles:\n- name: editor\n  permissions:\n  - entity:write\n"
+	if string(out) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out, want)
+	}
+}
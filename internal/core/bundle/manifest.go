@@ -0,0 +1,222 @@
+package bundle
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+)
+
+// maxRoleName is the length of roles.name
+const maxRoleName = 50
+
+// Manifest is the desired configuration of a team. Apply creates what is
+// missing and updates what differs; resources the manifest does not mention
+// are left alone.
+type Manifest struct {
+	Blueprints []Blueprint `json:"blueprints"`
+	Roles      []Role      `json:"roles"`
+	Scorecards []Scorecard `json:"scorecards"`
+	// Webhooks are not configurable on this server yet; a manifest that sets
+	// them is rejected rather than partially applied
+	Webhooks json.RawMessage `json:"webhooks,omitempty"`
+}
+
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+
+	// id is set once the role is applied, for its event
+	id uuid.UUID
+	// members counts the active members holding a current role
+	members int
+}
+
+// ApplyResult is the plan for a manifest, and what was applied unless DryRun
+type ApplyResult struct {
+	DryRun    bool         `json:"dry_run"`
+	Created   int          `json:"created"`
+	Updated   int          `json:"updated"`
+	Unchanged int          `json:"unchanged"`
+	Changes   []ItemResult `json:"changes"`
+}
+
+// currentState is the team's configuration as the manifest describes it
+type currentState struct {
+	blueprints map[string]*Blueprint
+	takenIDs   map[string]bool // blueprint IDs of every team
+	roles      map[string]*Role
+	scorecards map[string]*Scorecard // "<blueprint>/<identifier>"
+}
+
+// validateManifest rejects manifests that cannot be applied as a whole
+func validateManifest(m *Manifest, current *currentState) error {
+	if len(m.Webhooks) > 0 && string(m.Webhooks) != "null" {
+		return fmt.Errorf("%w: webhooks are not supported by this server", ErrInvalidBundle)
+	}
+
+	// Blueprints and scorecards follow the bundle rules
+	state := &teamState{blueprints: map[string]bool{}}
+	for id := range current.blueprints {
+		state.blueprints[id] = true
+	}
+	if err := validate(&Bundle{Version: Version, Blueprints: m.Blueprints, Scorecards: m.Scorecards}, state); err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, role := range m.Roles {
+		switch {
+		case role.Name == "" || len(role.Name) > maxRoleName:
+			return fmt.Errorf("%w: role name %q must be 1-%d characters", ErrInvalidBundle, role.Name, maxRoleName)
+		case seen[role.Name]:
+			return fmt.Errorf("%w: duplicate role %q", ErrInvalidBundle, role.Name)
+		case role.Name == "admin" && !slices.Contains(role.Permissions, auth.PermTeamManage):
+			return fmt.Errorf("%w: the admin role must keep %s", ErrInvalidBundle, auth.PermTeamManage)
+		}
+		seen[role.Name] = true
+		for _, p := range role.Permissions {
+			if !slices.Contains(auth.AllPermissions, p) {
+				return fmt.Errorf("%w: role %q has unknown permission %q", ErrInvalidBundle, role.Name, p)
+			}
+		}
+	}
+	return checkManagersKept(m, current)
+}
+
+// checkManagersKept fails with auth.ErrLastAdmin when the manifest takes
+// team:manage from every active member holding it, as UpdateRole refuses to.
+// Repository.Apply checks again under the team lock.
+func checkManagersKept(m *Manifest, current *currentState) error {
+	declared := map[string][]string{}
+	for _, role := range m.Roles {
+		declared[role.Name] = role.Permissions
+	}
+	before, after := 0, 0
+	for name, role := range current.roles {
+		if slices.Contains(role.Permissions, auth.PermTeamManage) {
+			before += role.members
+		}
+		permissions, ok := declared[name]
+		if !ok {
+			permissions = role.Permissions
+		}
+		if slices.Contains(permissions, auth.PermTeamManage) {
+			after += role.members
+		}
+	}
+	if before > 0 && after == 0 {
+		return auth.ErrLastAdmin
+	}
+	return nil
+}
+
+// diffManifest compares a validated manifest with the team and returns a
+// step for every resource it declares
+func diffManifest(m *Manifest, current *currentState) ([]*step, error) {
+	var steps []*step
+
+	for i := range m.Blueprints {
+		bp := m.Blueprints[i]
+		st := &step{ItemResult: ItemResult{Kind: KindBlueprint, Identifier: bp.ID}, blueprint: &bp}
+		existing, ok := current.blueprints[bp.ID]
+		switch {
+		case ok:
+			st.Fields = changedFields(
+				field{"title", existing.Title, bp.Title},
+				field{"description", existing.Description, bp.Description},
+				field{"icon", existing.Icon, bp.Icon},
+				field{"schema", existing.Schema, bp.Schema},
+				field{"identifier_mutable", existing.IdentifierMutable, bp.IdentifierMutable},
+				field{"merge_policy", existing.MergePolicy, bp.MergePolicy},
+				field{"expiry_policy", existing.ExpiryPolicy, bp.ExpiryPolicy},
+				field{"strict_updates", existing.StrictUpdates, bp.StrictUpdates},
+			)
+			st.Result = updatedOrUnchanged(st.Fields)
+		case current.takenIDs[bp.ID]:
+			return nil, fmt.Errorf("%w: blueprint id %q is used by another team", ErrConflict, bp.ID)
+		default:
+			st.Result = ResultCreated
+		}
+		steps = append(steps, st)
+	}
+
+	for i := range m.Roles {
+		role := m.Roles[i]
+		st := &step{ItemResult: ItemResult{Kind: KindRole, Identifier: role.Name}, role: &role}
+		if existing, ok := current.roles[role.Name]; ok {
+			st.Fields = changedFields(field{"permissions", sortedCopy(existing.Permissions), sortedCopy(role.Permissions)})
+			st.Result = updatedOrUnchanged(st.Fields)
+		} else {
+			st.Result = ResultCreated
+		}
+		steps = append(steps, st)
+	}
+
+	for i := range m.Scorecards {
+		sc := m.Scorecards[i]
+		key := sc.Blueprint + "/" + sc.Identifier
+		st := &step{ItemResult: ItemResult{Kind: KindScorecard, Identifier: key}, scorecard: &sc}
+		if existing, ok := current.scorecards[key]; ok {
+			st.Fields = changedFields(
+				field{"title", existing.Title, sc.Title},
+				field{"levels", existing.Levels, sc.Levels},
+				field{"rules", ruleSet(existing.Rules), ruleSet(sc.Rules)},
+			)
+			st.Result = updatedOrUnchanged(st.Fields)
+		} else {
+			st.Result = ResultCreated
+		}
+		steps = append(steps, st)
+	}
+
+	return steps, nil
+}
+
+type field struct {
+	name           string
+	current, value interface{}
+}
+
+// changedFields names the fields whose values differ. Values are compared as
+// JSON, so key order and number types do not matter.
+func changedFields(fields ...field) []string {
+	var changed []string
+	for _, f := range fields {
+		current, _ := json.Marshal(f.current)
+		value, _ := json.Marshal(f.value)
+		if string(current) != string(value) {
+			changed = append(changed, f.name)
+		}
+	}
+	return changed
+}
+
+func updatedOrUnchanged(fields []string) string {
+	if len(fields) == 0 {
+		return ResultUnchanged
+	}
+	return ResultUpdated
+}
+
+func sortedCopy(values []string) []string {
+	out := append([]string{}, values...)
+	sort.Strings(out)
+	return out
+}
+
+// ruleSet is the rules in a canonical order; rules have no identity, so
+// their order does not make a scorecard different
+func ruleSet(rules []Rule) []string {
+	out := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		encoded, _ := json.Marshal(rule)
+		out = append(out, string(encoded))
+	}
+	sort.Strings(out)
+	return out
+}
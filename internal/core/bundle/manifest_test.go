@@ -0,0 +1,165 @@
+package bundle
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+)
+
+func testManifest() *Manifest {
+	return &Manifest{
+		Blueprints: []Blueprint{
+			{ID: "service", Title: "Service", Schema: map[string]interface{}{"type": "object"}},
+			{ID: "team", Title: "Team"},
+		},
+		Roles: []Role{
+			{Name: "viewer", Permissions: []string{auth.PermEntityRead, auth.PermBlueprintRead}},
+			{Name: "oncall", Permissions: []string{auth.PermActionExecute}},
+		},
+		Scorecards: []Scorecard{{
+			Blueprint:  "service",
+			Identifier: "readiness",
+			Title:      "Readiness",
+			Levels:     []scorecard.Level{{Name: "Gold"}},
+			Rules: []Rule{
+				{Level: "Gold", PropertyPath: "tier", Operator: "eq", Value: 1.0},
+				{Level: "Gold", PropertyPath: "owner", Operator: "exists"},
+			},
+		}},
+	}
+}
+
+func emptyCurrent() *currentState {
+	return &currentState{
+		blueprints: map[string]*Blueprint{},
+		takenIDs:   map[string]bool{},
+		roles:      map[string]*Role{},
+		scorecards: map[string]*Scorecard{},
+	}
+}
+
+func TestDiffManifest(t *testing.T) {
+	current := emptyCurrent()
+	// Same schema decoded from the database
+	current.blueprints["service"] = &Blueprint{ID: "service", Title: "Service", Schema: map[string]interface{}{"type": "object"}}
+	current.takenIDs["service"] = true
+	current.roles["viewer"] = &Role{Name: "viewer", Permissions: []string{auth.PermBlueprintRead}}
+	current.scorecards["service/readiness"] = &Scorecard{
+		Blueprint:  "service",
+		Identifier: "readiness",
+		Title:      "Readiness",
+		Levels:     []scorecard.Level{{Name: "Gold"}},
+		Rules: []Rule{ // same rules in another order
+			{Level: "Gold", PropertyPath: "owner", Operator: "exists"},
+			{Level: "Gold", PropertyPath: "tier", Operator: "eq", Value: 1.0},
+		},
+	}
+
+	steps, err := diffManifest(testManifest(), current)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	want := map[string]string{
+		"blueprint:service":           ResultUnchanged,
+		"blueprint:team":              ResultCreated,
+		"role:viewer":                 ResultUpdated,
+		"role:oncall":                 ResultCreated,
+		"scorecard:service/readiness": ResultUnchanged,
+	}
+	got := results(steps)
+	for key, result := range want {
+		if got[key] != result {
+			t.Errorf("%s = %s, want %s", key, got[key], result)
+		}
+	}
+	for _, st := range steps {
+		if st.Kind == KindRole && st.Identifier == "viewer" && !slices.Equal(st.Fields, []string{"permissions"}) {
+			t.Errorf("viewer changed fields = %v", st.Fields)
+		}
+		if st.writes() != (st.Result == ResultCreated || st.Result == ResultUpdated) {
+			t.Errorf("%s:%s writes() = %v", st.Kind, st.Identifier, st.writes())
+		}
+	}
+}
+
+func TestDiffManifest_ChangedFields(t *testing.T) {
+	current := emptyCurrent()
+	current.blueprints["service"] = &Blueprint{ID: "service", Title: "Services", Icon: "server", Schema: map[string]interface{}{"type": "object"}}
+
+	m := &Manifest{Blueprints: []Blueprint{{ID: "service", Title: "Service", Icon: "server", Schema: map[string]interface{}{"type": "array"}}}}
+	steps, err := diffManifest(m, current)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if steps[0].Result != ResultUpdated || !slices.Equal(steps[0].Fields, []string{"title", "schema"}) {
+		t.Errorf("step = %+v", steps[0].ItemResult)
+	}
+}
+
+func TestDiffManifest_ForeignBlueprintID(t *testing.T) {
+	current := emptyCurrent()
+	current.takenIDs["service"] = true // owned by another team
+
+	if _, err := diffManifest(testManifest(), current); !errors.Is(err, ErrConflict) {
+		t.Errorf("err = %v, want ErrConflict", err)
+	}
+}
+
+func TestValidateManifest(t *testing.T) {
+	tests := map[string]func(m *Manifest){
+		"unknown permission": func(m *Manifest) { m.Roles[0].Permissions = append(m.Roles[0].Permissions, "entity:admin") },
+		"duplicate role":     func(m *Manifest) { m.Roles = append(m.Roles, Role{Name: "viewer"}) },
+		"unnamed role":       func(m *Manifest) { m.Roles[0].Name = "" },
+		"admin loses manage": func(m *Manifest) { m.Roles = append(m.Roles, Role{Name: "admin"}) },
+		"webhooks":           func(m *Manifest) { m.Webhooks = json.RawMessage(`[{"url":"https://example.com"}]`) },
+		"unknown blueprint":  func(m *Manifest) { m.Scorecards[0].Blueprint = "missing" },
+	}
+	for name, mutate := range tests {
+		m := testManifest()
+		mutate(m)
+		if err := validateManifest(m, emptyCurrent()); !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("%s: err = %v, want ErrInvalidBundle", name, err)
+		}
+	}
+
+	m := testManifest()
+	m.Webhooks = json.RawMessage(`null`)
+	if err := validateManifest(m, emptyCurrent()); err != nil {
+		t.Errorf("valid manifest: %v", err)
+	}
+}
+
+func TestValidateManifest_KeepsTeamManagers(t *testing.T) {
+	current := func() *currentState {
+		c := emptyCurrent()
+		c.roles["admin"] = &Role{Name: "admin", Permissions: []string{auth.PermTeamManage}}
+		c.roles["owners"] = &Role{Name: "owners", Permissions: []string{auth.PermTeamManage, auth.PermEntityRead}, members: 2}
+		c.roles["viewer"] = &Role{Name: "viewer", Permissions: []string{auth.PermEntityRead}, members: 5}
+		return c
+	}
+	demote := &Manifest{Roles: []Role{{Name: "owners", Permissions: []string{auth.PermEntityRead}}}}
+
+	// the owners are the only managers; the admin role has no members
+	if err := validateManifest(demote, current()); !errors.Is(err, auth.ErrLastAdmin) {
+		t.Errorf("demoting the only managers: err = %v, want ErrLastAdmin", err)
+	}
+
+	withAdmin := current()
+	withAdmin.roles["admin"].members = 1
+	if err := validateManifest(demote, withAdmin); err != nil {
+		t.Errorf("demoting with an admin left: %v", err)
+	}
+
+	promote := &Manifest{Roles: []Role{
+		{Name: "owners", Permissions: []string{auth.PermEntityRead}},
+		{Name: "viewer", Permissions: []string{auth.PermTeamManage}},
+	}}
+	if err := validateManifest(promote, current()); err != nil {
+		t.Errorf("moving team:manage to another held role: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package bundle
+
+import (
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+)
+
+// Version is the bundle format written by Export. Import accepts versions up to it.
+const Version = 1
+
+// Bundle is a team's catalog model without any team-specific IDs, so it can
+// be imported into another team or installation. Relations, scorecards and
+// actions refer to blueprints by ID.
+type Bundle struct {
+	Version    int         `json:"version"`
+	ExportedAt time.Time   `json:"exported_at"`
+	Blueprints []Blueprint `json:"blueprints"`
+	Relations  []Relation  `json:"relations"`
+	Scorecards []Scorecard `json:"scorecards"`
+	Actions    []Action    `json:"actions"`
+}
+
+type Blueprint struct {
+	ID                string                  `json:"id"`
+	Title             string                  `json:"title"`
+	Description       string                  `json:"description,omitempty"`
+	Icon              string                  `json:"icon,omitempty"`
+	Schema            map[string]interface{}  `json:"schema"`
+	IdentifierMutable bool                    `json:"identifier_mutable,omitempty"`
+	MergePolicy       *blueprint.MergePolicy  `json:"merge_policy,omitempty"`
+	ExpiryPolicy      *blueprint.ExpiryPolicy `json:"expiry_policy,omitempty"`
+	StrictUpdates     bool                    `json:"strict_updates,omitempty"`
+}
+
+type Relation struct {
+	Identifier string `json:"identifier"`
+	Title      string `json:"title,omitempty"`
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Type       string `json:"type,omitempty"` // defaults to many-to-many
+	Required   bool   `json:"required,omitempty"`
+}
+
+type Scorecard struct {
+	Blueprint  string            `json:"blueprint"`
+	Identifier string            `json:"identifier"`
+	Title      string            `json:"title"`
+	Levels     []scorecard.Level `json:"levels"`
+	Rules      []Rule            `json:"rules"`
+}
+
+type Rule struct {
+	Level        string      `json:"level"`
+	PropertyPath string      `json:"property_path"`
+	Operator     string      `json:"operator"`
+	Value        interface{} `json:"value,omitempty"`
+}
+
+type Action struct {
+	Blueprint     string                 `json:"blueprint,omitempty"` // empty for team-wide actions
+	Identifier    string                 `json:"identifier"`
+	Title         string                 `json:"title"`
+	Description   string                 `json:"description,omitempty"`
+	TriggerType   string                 `json:"trigger_type,omitempty"` // defaults to manual
+	TriggerConfig map[string]interface{} `json:"trigger_config,omitempty"`
+	UserInputs    []interface{}          `json:"user_inputs,omitempty"`
+	Steps         []interface{}          `json:"steps"`
+}
+
+// Conflict strategies for items that already exist in the target team
+const (
+	StrategySkip      = "skip"      // keep the existing item
+	StrategyOverwrite = "overwrite" // replace it with the bundle's
+	StrategyRename    = "rename"    // import the bundle's under a free identifier
+)
+
+// Item kinds reported by Import
+const (
+	KindBlueprint = "blueprint"
+	KindRelation  = "relation"
+	KindScorecard = "scorecard"
+	KindAction    = "action"
+	KindRole      = "role"
+)
+
+// Import results per item
+const (
+	ResultCreated   = "created"
+	ResultUpdated   = "updated"
+	ResultRenamed   = "renamed"
+	ResultSkipped   = "skipped"
+	ResultUnchanged = "unchanged" // apply only: the team already matches the manifest
+)
+
+// ItemResult is what an import did, or in a dry run would do, with one item.
+// Relations and scorecards are identified as "<blueprint>/<identifier>".
+type ItemResult struct {
+	Kind       string   `json:"kind"`
+	Identifier string   `json:"identifier"`
+	Result     string   `json:"result"`
+	Target     string   `json:"target,omitempty"` // identifier written when renamed
+	Reason     string   `json:"reason,omitempty"`
+	Fields     []string `json:"fields,omitempty"` // apply only: fields an update changes
+}
+
+type ImportResult struct {
+	DryRun   bool         `json:"dry_run"`
+	Strategy string       `json:"strategy"`
+	Created  int          `json:"created"`
+	Updated  int          `json:"updated"`
+	Renamed  int          `json:"renamed"`
+	Skipped  int          `json:"skipped"`
+	Items    []ItemResult `json:"items"`
+}
@@ -0,0 +1,300 @@
+package bundle
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/runner"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/core/validation"
+)
+
+// Column limits of the tables items are written to
+const (
+	maxBlueprintID = 50
+	maxIdentifier  = 100
+)
+
+// teamState is what the target team already has, keyed the way bundles refer to it
+type teamState struct {
+	blueprints map[string]bool // blueprint IDs of the team
+	takenIDs   map[string]bool // blueprint IDs of every team; the ID is a global key
+	relations  map[string]bool // "<source>/<identifier>"
+	scorecards map[string]bool // "<blueprint>/<identifier>"
+	actions    map[string]bool
+	secrets    map[string]bool // names of the team's secrets
+}
+
+// step is one item of an import with its references resolved to the target team
+type step struct {
+	ItemResult
+	blueprint *Blueprint
+	relation  *Relation
+	scorecard *Scorecard
+	action    *Action
+	role      *Role
+}
+
+func (s *step) writes() bool {
+	return s.Result != ResultSkipped && s.Result != ResultUnchanged
+}
+
+// validate rejects bundles that cannot be imported as a whole
+func validate(b *Bundle, state *teamState) error {
+	if b.Version < 1 || b.Version > Version {
+		return fmt.Errorf("%w: unsupported version %d (this server reads up to %d)", ErrInvalidBundle, b.Version, Version)
+	}
+
+	known := map[string]bool{}
+	for id := range state.blueprints {
+		known[id] = true
+	}
+	inBundle := map[string]bool{}
+	for i, bp := range b.Blueprints {
+		switch {
+		case bp.ID == "" || len(bp.ID) > maxBlueprintID:
+			return fmt.Errorf("%w: blueprint id %q must be 1-%d characters", ErrInvalidBundle, bp.ID, maxBlueprintID)
+		case bp.Title == "":
+			return fmt.Errorf("%w: blueprint %q has no title", ErrInvalidBundle, bp.ID)
+		case inBundle[bp.ID]:
+			return fmt.Errorf("%w: duplicate blueprint %q", ErrInvalidBundle, bp.ID)
+		}
+		// Blueprints the team has keep IDs from before the format was enforced
+		if !state.blueprints[bp.ID] {
+			if err := validation.ValidateSlug("id", bp.ID); err != nil {
+				return fmt.Errorf("%w: blueprint %q: %v", ErrInvalidBundle, bp.ID, err)
+			}
+		}
+		if err := bp.MergePolicy.Validate(); err != nil {
+			return fmt.Errorf("%w: blueprint %q: %v", ErrInvalidBundle, bp.ID, err)
+		}
+		// A policy that lets every write through is stored as none
+		if bp.MergePolicy.IsZero() {
+			b.Blueprints[i].MergePolicy = nil
+		}
+		if err := bp.ExpiryPolicy.Validate(bp.Schema); err != nil {
+			return fmt.Errorf("%w: blueprint %q: %v", ErrInvalidBundle, bp.ID, err)
+		}
+		if bp.ExpiryPolicy.IsZero() {
+			b.Blueprints[i].ExpiryPolicy = nil
+		}
+		if err := blueprint.ValidateDefaults(bp.Schema); err != nil {
+			return fmt.Errorf("%w: blueprint %q: %v", ErrInvalidBundle, bp.ID, err)
+		}
+		if err := blueprint.ValidateRollups(bp.Schema); err != nil {
+			return fmt.Errorf("%w: blueprint %q: %v", ErrInvalidBundle, bp.ID, err)
+		}
+		inBundle[bp.ID] = true
+		known[bp.ID] = true
+	}
+
+	seen := map[string]bool{}
+	for _, rel := range b.Relations {
+		key := rel.Source + "/" + rel.Identifier
+		switch {
+		case rel.Identifier == "" || len(rel.Identifier) > maxIdentifier:
+			return fmt.Errorf("%w: relation identifier %q must be 1-%d characters", ErrInvalidBundle, rel.Identifier, maxIdentifier)
+		case !known[rel.Source] || !known[rel.Target]:
+			return fmt.Errorf("%w: relation %q refers to an unknown blueprint", ErrInvalidBundle, key)
+		case seen[key]:
+			return fmt.Errorf("%w: duplicate relation %q", ErrInvalidBundle, key)
+		}
+		seen[key] = true
+	}
+
+	seen = map[string]bool{}
+	for _, sc := range b.Scorecards {
+		key := sc.Blueprint + "/" + sc.Identifier
+		switch {
+		case sc.Identifier == "" || len(sc.Identifier) > maxIdentifier:
+			return fmt.Errorf("%w: scorecard identifier %q must be 1-%d characters", ErrInvalidBundle, sc.Identifier, maxIdentifier)
+		case sc.Title == "":
+			return fmt.Errorf("%w: scorecard %q has no title", ErrInvalidBundle, key)
+		case !known[sc.Blueprint]:
+			return fmt.Errorf("%w: scorecard %q refers to an unknown blueprint", ErrInvalidBundle, key)
+		case seen[key]:
+			return fmt.Errorf("%w: duplicate scorecard %q", ErrInvalidBundle, key)
+		}
+		seen[key] = true
+
+		levels := map[string]bool{}
+		for _, level := range sc.Levels {
+			levels[level.Name] = true
+		}
+		for _, rule := range sc.Rules {
+			if !levels[rule.Level] {
+				return fmt.Errorf("%w: scorecard %q has a rule for unknown level %q", ErrInvalidBundle, key, rule.Level)
+			}
+		}
+	}
+
+	seen = map[string]bool{}
+	for _, action := range b.Actions {
+		switch {
+		case action.Identifier == "" || len(action.Identifier) > maxIdentifier:
+			return fmt.Errorf("%w: action identifier %q must be 1-%d characters", ErrInvalidBundle, action.Identifier, maxIdentifier)
+		case action.Title == "":
+			return fmt.Errorf("%w: action %q has no title", ErrInvalidBundle, action.Identifier)
+		case action.Blueprint != "" && !known[action.Blueprint]:
+			return fmt.Errorf("%w: action %q refers to an unknown blueprint", ErrInvalidBundle, action.Identifier)
+		case seen[action.Identifier]:
+			return fmt.Errorf("%w: duplicate action %q", ErrInvalidBundle, action.Identifier)
+		}
+		seen[action.Identifier] = true
+		if _, err := runner.ParseRunLimits(action.TriggerConfig); err != nil {
+			return fmt.Errorf("%w: action %q: %v", ErrInvalidBundle, action.Identifier, err)
+		}
+		// Secrets do not travel with bundles; the target team must have its own
+		for _, name := range secret.References([]interface{}{action.TriggerConfig, action.Steps}) {
+			if !state.secrets[name] {
+				return fmt.Errorf("%w: action %q refers to secret %q, which the team does not have", ErrInvalidBundle, action.Identifier, name)
+			}
+		}
+	}
+	return nil
+}
+
+// plan decides what happens to every item of a validated bundle. Blueprints
+// come first so that the others can follow renames. An item whose blueprint
+// ends up outside the team is skipped. With the overwrite strategy, a
+// blueprint ID owned by another team is a conflict that fails the import.
+func plan(b *Bundle, state *teamState, strategy string) ([]*step, error) {
+	var steps []*step
+
+	// Renamed blueprints must not take the ID of another blueprint in the bundle
+	inBundle := map[string]bool{}
+	for _, bp := range b.Blueprints {
+		inBundle[bp.ID] = true
+	}
+	taken := func(id string) bool { return state.takenIDs[id] || inBundle[id] }
+
+	// Bundle blueprint ID -> ID in the team, or "" when it is not available
+	ids := map[string]string{}
+	for i := range b.Blueprints {
+		bp := b.Blueprints[i]
+		st := &step{ItemResult: ItemResult{Kind: KindBlueprint, Identifier: bp.ID}}
+		switch {
+		case !state.takenIDs[bp.ID]:
+			st.Result = ResultCreated
+		case strategy == StrategySkip:
+			st.Result = ResultSkipped
+			st.Reason = "already exists"
+			if !state.blueprints[bp.ID] {
+				st.Reason = "id is used by another team"
+			}
+		case strategy == StrategyOverwrite && state.blueprints[bp.ID]:
+			st.Result = ResultUpdated
+		case strategy == StrategyOverwrite:
+			return nil, fmt.Errorf("%w: blueprint id %q is used by another team; import with the rename strategy", ErrConflict, bp.ID)
+		default:
+			bp.ID = freeIdentifier(bp.ID, maxBlueprintID, taken)
+			state.takenIDs[bp.ID] = true
+			st.Result = ResultRenamed
+			st.Target = bp.ID
+		}
+
+		ids[st.Identifier] = bp.ID
+		if st.Result == ResultSkipped && !state.blueprints[bp.ID] {
+			ids[st.Identifier] = ""
+		}
+		st.blueprint = &bp
+		steps = append(steps, st)
+	}
+	resolve := func(id string) string {
+		if target, ok := ids[id]; ok {
+			return target
+		}
+		return id // validate only lets through blueprints of the bundle or the team
+	}
+	unavailable := func(id string) string {
+		return fmt.Sprintf("blueprint %q was not imported", id)
+	}
+
+	for i := range b.Relations {
+		rel := b.Relations[i]
+		st := &step{ItemResult: ItemResult{Kind: KindRelation, Identifier: rel.Source + "/" + rel.Identifier}}
+		source, target := resolve(rel.Source), resolve(rel.Target)
+		switch {
+		case source == "":
+			st.Result, st.Reason = ResultSkipped, unavailable(rel.Source)
+		case target == "":
+			st.Result, st.Reason = ResultSkipped, unavailable(rel.Target)
+		default:
+			rel.Source, rel.Target = source, target
+			exists := func(identifier string) bool { return state.relations[source+"/"+identifier] }
+			rel.Identifier = resolveItem(st, rel.Identifier, strategy, exists)
+			state.relations[source+"/"+rel.Identifier] = true
+		}
+		st.relation = &rel
+		steps = append(steps, st)
+	}
+
+	for i := range b.Scorecards {
+		sc := b.Scorecards[i]
+		st := &step{ItemResult: ItemResult{Kind: KindScorecard, Identifier: sc.Blueprint + "/" + sc.Identifier}}
+		if blueprintID := resolve(sc.Blueprint); blueprintID == "" {
+			st.Result, st.Reason = ResultSkipped, unavailable(sc.Blueprint)
+		} else {
+			sc.Blueprint = blueprintID
+			exists := func(identifier string) bool { return state.scorecards[blueprintID+"/"+identifier] }
+			sc.Identifier = resolveItem(st, sc.Identifier, strategy, exists)
+			state.scorecards[blueprintID+"/"+sc.Identifier] = true
+		}
+		st.scorecard = &sc
+		steps = append(steps, st)
+	}
+
+	for i := range b.Actions {
+		action := b.Actions[i]
+		st := &step{ItemResult: ItemResult{Kind: KindAction, Identifier: action.Identifier}}
+		blueprintID := action.Blueprint
+		if blueprintID != "" {
+			blueprintID = resolve(blueprintID)
+		}
+		if action.Blueprint != "" && blueprintID == "" {
+			st.Result, st.Reason = ResultSkipped, unavailable(action.Blueprint)
+		} else {
+			action.Blueprint = blueprintID
+			exists := func(identifier string) bool { return state.actions[identifier] }
+			action.Identifier = resolveItem(st, action.Identifier, strategy, exists)
+			state.actions[action.Identifier] = true
+		}
+		st.action = &action
+		steps = append(steps, st)
+	}
+
+	return steps, nil
+}
+
+// resolveItem applies the conflict strategy to a relation, scorecard or
+// action and returns the identifier it is written under
+func resolveItem(st *step, identifier, strategy string, exists func(string) bool) string {
+	switch {
+	case !exists(identifier):
+		st.Result = ResultCreated
+	case strategy == StrategySkip:
+		st.Result, st.Reason = ResultSkipped, "already exists"
+	case strategy == StrategyOverwrite:
+		st.Result = ResultUpdated
+	default:
+		identifier = freeIdentifier(identifier, maxIdentifier, exists)
+		st.Result, st.Target = ResultRenamed, identifier
+	}
+	return identifier
+}
+
+// freeIdentifier returns the first of "<id>-2", "<id>-3", ... that is not
+// taken, shortening id to fit maxLen
+func freeIdentifier(id string, maxLen int, taken func(string) bool) string {
+	for n := 2; ; n++ {
+		suffix := "-" + strconv.Itoa(n)
+		base := id
+		if len(base)+len(suffix) > maxLen {
+			base = base[:maxLen-len(suffix)]
+		}
+		if candidate := base + suffix; !taken(candidate) {
+			return candidate
+		}
+	}
+}
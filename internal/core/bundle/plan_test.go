@@ -0,0 +1,222 @@
+package bundle
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+)
+
+func testBundle() *Bundle {
+	return &Bundle{
+		Version: Version,
+		Blueprints: []Blueprint{
+			{ID: "service", Title: "Service"},
+			{ID: "team", Title: "Team"},
+		},
+		Relations: []Relation{
+			{Identifier: "owner", Source: "service", Target: "team"},
+		},
+		Scorecards: []Scorecard{
+			{Blueprint: "service", Identifier: "readiness", Title: "Readiness", Levels: []scorecard.Level{{Name: "Gold"}}, Rules: []Rule{{Level: "Gold", PropertyPath: "tier", Operator: "eq", Value: 1.0}}},
+		},
+		Actions: []Action{
+			{Blueprint: "service", Identifier: "deploy", Title: "Deploy"},
+			{Identifier: "onboard", Title: "Onboard"},
+		},
+	}
+}
+
+func emptyState() *teamState {
+	return &teamState{
+		blueprints: map[string]bool{},
+		takenIDs:   map[string]bool{},
+		relations:  map[string]bool{},
+		scorecards: map[string]bool{},
+		actions:    map[string]bool{},
+		secrets:    map[string]bool{},
+	}
+}
+
+// existingState is a team that already has everything in testBundle
+func existingState() *teamState {
+	state := emptyState()
+	for _, id := range []string{"service", "team"} {
+		state.blueprints[id] = true
+		state.takenIDs[id] = true
+	}
+	state.relations["service/owner"] = true
+	state.scorecards["service/readiness"] = true
+	state.actions["deploy"] = true
+	state.actions["onboard"] = true
+	return state
+}
+
+func results(steps []*step) map[string]string {
+	out := map[string]string{}
+	for _, st := range steps {
+		out[st.Kind+":"+st.Identifier] = st.Result
+	}
+	return out
+}
+
+func TestPlan_CreatesIntoEmptyTeam(t *testing.T) {
+	steps, err := plan(testBundle(), emptyState(), StrategySkip)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if len(steps) != 6 {
+		t.Fatalf("expected 6 steps, got %d", len(steps))
+	}
+	for key, result := range results(steps) {
+		if result != ResultCreated {
+			t.Errorf("%s = %s, want created", key, result)
+		}
+	}
+}
+
+func TestPlan_Strategies(t *testing.T) {
+	tests := []struct {
+		strategy string
+		want     string
+	}{
+		{StrategySkip, ResultSkipped},
+		{StrategyOverwrite, ResultUpdated},
+		{StrategyRename, ResultRenamed},
+	}
+	for _, tt := range tests {
+		steps, err := plan(testBundle(), existingState(), tt.strategy)
+		if err != nil {
+			t.Fatalf("%s: plan: %v", tt.strategy, err)
+		}
+		for key, result := range results(steps) {
+			want := tt.want
+			if tt.strategy == StrategyRename && (strings.HasPrefix(key, KindRelation) || strings.HasPrefix(key, KindScorecard)) {
+				// They move to the renamed blueprint, which has none yet
+				want = ResultCreated
+			}
+			if result != want {
+				t.Errorf("%s: %s = %s, want %s", tt.strategy, key, result, want)
+			}
+		}
+	}
+}
+
+func TestPlan_RenameFollowsBlueprints(t *testing.T) {
+	state := existingState()
+	state.takenIDs["service-2"] = true // owned by another team
+
+	steps, err := plan(testBundle(), state, StrategyRename)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+
+	for _, st := range steps {
+		switch {
+		case st.blueprint != nil && st.Identifier == "service":
+			if st.Target != "service-3" {
+				t.Errorf("service renamed to %q, want service-3", st.Target)
+			}
+		case st.relation != nil:
+			// The renamed source has no relations yet, so the identifier is kept
+			if st.relation.Source != "service-3" || st.relation.Target != "team-2" || st.relation.Identifier != "owner" {
+				t.Errorf("relation = %+v", st.relation)
+			}
+		case st.scorecard != nil:
+			if st.scorecard.Blueprint != "service-3" || st.scorecard.Identifier != "readiness" {
+				t.Errorf("scorecard = %s/%s", st.scorecard.Blueprint, st.scorecard.Identifier)
+			}
+		case st.action != nil && st.Identifier == "deploy":
+			if st.action.Blueprint != "service-3" || st.action.Identifier != "deploy-2" {
+				t.Errorf("action = %s on %s", st.action.Identifier, st.action.Blueprint)
+			}
+		}
+	}
+}
+
+func TestPlan_ForeignBlueprintID(t *testing.T) {
+	state := emptyState()
+	state.takenIDs["service"] = true // owned by another team
+
+	if _, err := plan(testBundle(), state, StrategyOverwrite); !errors.Is(err, ErrConflict) {
+		t.Errorf("overwrite: err = %v, want ErrConflict", err)
+	}
+
+	steps, err := plan(testBundle(), state, StrategySkip)
+	if err != nil {
+		t.Fatalf("skip: %v", err)
+	}
+	got := results(steps)
+	for _, key := range []string{"blueprint:service", "relation:service/owner", "scorecard:service/readiness", "action:deploy"} {
+		if got[key] != ResultSkipped {
+			t.Errorf("%s = %s, want skipped with its blueprint", key, got[key])
+		}
+	}
+	if got["blueprint:team"] != ResultCreated || got["action:onboard"] != ResultCreated {
+		t.Errorf("unrelated items should still be created: %v", got)
+	}
+}
+
+func TestFreeIdentifier_FitsColumn(t *testing.T) {
+	long := strings.Repeat("a", maxBlueprintID)
+	taken := map[string]bool{}
+	got := freeIdentifier(long, maxBlueprintID, func(id string) bool { return taken[id] })
+	if len(got) != maxBlueprintID || !strings.HasSuffix(got, "-2") {
+		t.Errorf("freeIdentifier = %q (%d chars)", got, len(got))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]func(b *Bundle){
+		"version":               func(b *Bundle) { b.Version = Version + 1 },
+		"duplicate":             func(b *Bundle) { b.Blueprints = append(b.Blueprints, Blueprint{ID: "team", Title: "Team"}) },
+		"unknown target":        func(b *Bundle) { b.Relations[0].Target = "missing" },
+		"unknown level":         func(b *Bundle) { b.Scorecards[0].Rules[0].Level = "Platinum" },
+		"action blueprint":      func(b *Bundle) { b.Actions[0].Blueprint = "missing" },
+		"long blueprint id":     func(b *Bundle) { b.Blueprints[0].ID = strings.Repeat("x", maxBlueprintID+1) },
+		"blueprint id format":   func(b *Bundle) { b.Blueprints[0].ID = "My_Service" },
+		"reserved blueprint id": func(b *Bundle) { b.Blueprints[0].ID = "admin" },
+		"untitled scorecard":    func(b *Bundle) { b.Scorecards[0].Title = "" },
+		"unknown secret": func(b *Bundle) {
+			b.Actions[0].Steps = []interface{}{map[string]interface{}{"token": map[string]interface{}{"$secret": "deploy-token"}}}
+		},
+	}
+	for name, mutate := range tests {
+		b := testBundle()
+		mutate(b)
+		if err := validate(b, emptyState()); !errors.Is(err, ErrInvalidBundle) {
+			t.Errorf("%s: err = %v, want ErrInvalidBundle", name, err)
+		}
+	}
+
+	// References to blueprints the team already has are fine
+	b := testBundle()
+	b.Relations[0].Target = "group"
+	state := emptyState()
+	state.blueprints["group"] = true
+	if err := validate(b, state); err != nil {
+		t.Errorf("reference to team blueprint: %v", err)
+	}
+
+	// Blueprints the team has may keep IDs from before the format was enforced
+	b = testBundle()
+	b.Blueprints[0].ID = "legacy_service"
+	b.Relations[0].Source = "legacy_service"
+	b.Scorecards[0].Blueprint = "legacy_service"
+	b.Actions[0].Blueprint = "legacy_service"
+	state = emptyState()
+	state.blueprints["legacy_service"] = true
+	if err := validate(b, state); err != nil {
+		t.Errorf("legacy team blueprint id: %v", err)
+	}
+
+	// So are references to secrets the team has
+	b = testBundle()
+	b.Actions[0].TriggerConfig = map[string]interface{}{"auth": map[string]interface{}{"$secret": "deploy-token"}}
+	state = emptyState()
+	state.secrets["deploy-token"] = true
+	if err := validate(b, state); err != nil {
+		t.Errorf("reference to team secret: %v", err)
+	}
+}
@@ -0,0 +1,453 @@
+package bundle
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/events"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+// ListRelations returns a team's blueprint relations
+func (r *Repository) ListRelations(ctx context.Context, teamID uuid.UUID) ([]Relation, error) {
+	query := `
+		SELECT identifier, title, source_blueprint_id, target_blueprint_id, relation_type, required
+		FROM blueprint_relations
+		WHERE team_id = $1
+		ORDER BY source_blueprint_id, identifier`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var relations []Relation
+	for rows.Next() {
+		var rel Relation
+		var title sql.NullString
+		var required sql.NullBool
+		if err := rows.Scan(&rel.Identifier, &title, &rel.Source, &rel.Target, &rel.Type, &required); err != nil {
+			return nil, err
+		}
+		rel.Title = title.String
+		rel.Required = required.Bool
+		relations = append(relations, rel)
+	}
+	return relations, rows.Err()
+}
+
+// SecretNames returns the names of a team's secrets
+func (r *Repository) SecretNames(ctx context.Context, teamID uuid.UUID) (map[string]bool, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT name FROM team_secrets WHERE team_id = $1`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
+
+// ListActions returns a team's actions
+func (r *Repository) ListActions(ctx context.Context, teamID uuid.UUID) ([]Action, error) {
+	query := `
+		SELECT blueprint_id, identifier, title, description, trigger_type, trigger_config, user_inputs, steps
+		FROM actions
+		WHERE team_id = $1
+		ORDER BY identifier`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var actions []Action
+	for rows.Next() {
+		var action Action
+		var blueprintID, description sql.NullString
+		var triggerConfig, userInputs, steps []byte
+		if err := rows.Scan(&blueprintID, &action.Identifier, &action.Title, &description, &action.TriggerType, &triggerConfig, &userInputs, &steps); err != nil {
+			return nil, err
+		}
+		action.Blueprint = blueprintID.String
+		action.Description = description.String
+		for _, field := range []struct {
+			raw  []byte
+			dest interface{}
+		}{
+			{triggerConfig, &action.TriggerConfig},
+			{userInputs, &action.UserInputs},
+			{steps, &action.Steps},
+		} {
+			if len(field.raw) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(field.raw, field.dest); err != nil {
+				return nil, err
+			}
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+// ListRoles returns a team's roles with their active member counts
+func (r *Repository) ListRoles(ctx context.Context, teamID uuid.UUID) ([]Role, error) {
+	query := `
+		SELECT r.name, r.permissions,
+			(SELECT COUNT(*) FROM team_memberships m JOIN users u ON u.id = m.user_id
+			 WHERE m.role_id = r.id AND u.status = 'active')
+		FROM roles r
+		WHERE r.team_id = $1
+		ORDER BY r.name`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []Role
+	for rows.Next() {
+		var role Role
+		var permissions []byte
+		if err := rows.Scan(&role.Name, &permissions, &role.members); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(permissions, &role.Permissions); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// ListAPIKeys returns what describes a team's API keys, never their values
+func (r *Repository) ListAPIKeys(ctx context.Context, teamID uuid.UUID) ([]APIKey, error) {
+	rows, err := r.db.DB.QueryContext(ctx,
+		`SELECT name, permissions, expires_at FROM api_keys WHERE team_id = $1 ORDER BY name, created_at`, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []APIKey
+	for rows.Next() {
+		var key APIKey
+		var permissions []byte
+		if err := rows.Scan(&key.Name, &permissions, &key.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if len(permissions) > 0 {
+			if err := json.Unmarshal(permissions, &key.Permissions); err != nil {
+				return nil, err
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// TakenBlueprintIDs returns which blueprint IDs starting with any of the given
+// prefixes exist in any team. Blueprint IDs are unique across teams.
+func (r *Repository) TakenBlueprintIDs(ctx context.Context, prefixes []string) (map[string]bool, error) {
+	taken := map[string]bool{}
+	if len(prefixes) == 0 {
+		return taken, nil
+	}
+
+	patterns := make([]string, len(prefixes))
+	escape := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	for i, prefix := range prefixes {
+		patterns[i] = escape.Replace(prefix) + "%"
+	}
+
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT id FROM blueprints WHERE id LIKE ANY($1)`, patterns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		taken[id] = true
+	}
+	return taken, rows.Err()
+}
+
+// Apply writes the steps of an import in one transaction. A unique violation
+// means the team changed since the import was planned and yields ErrConflict.
+// Role updates lock the team, as membership changes do, and fail with
+// auth.ErrLastAdmin when they leave a team that had managers without one.
+func (r *Repository) Apply(ctx context.Context, teamID uuid.UUID, steps []*step) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	updatesRoles := slices.ContainsFunc(steps, func(st *step) bool {
+		return st.role != nil && st.Result == ResultUpdated
+	})
+	var managers int
+	if updatesRoles {
+		if _, err := tx.ExecContext(ctx, `SELECT id FROM teams WHERE id = $1 FOR UPDATE`, teamID); err != nil {
+			return err
+		}
+		if managers, err = countManagers(ctx, tx, teamID); err != nil {
+			return err
+		}
+	}
+
+	for _, st := range steps {
+		if !st.writes() {
+			continue
+		}
+		update := st.Result == ResultUpdated
+		switch {
+		case st.blueprint != nil:
+			err = applyBlueprint(ctx, tx, teamID, st.blueprint, update)
+		case st.relation != nil:
+			err = applyRelation(ctx, tx, teamID, st.relation, update)
+		case st.scorecard != nil:
+			err = applyScorecard(ctx, tx, teamID, st.scorecard, update)
+		case st.action != nil:
+			err = applyAction(ctx, tx, teamID, st.action, update)
+		case st.role != nil:
+			err = applyRole(ctx, tx, teamID, st.role, update)
+		}
+		if err != nil {
+			if postgres.IsUniqueViolation(err) {
+				return ErrConflict
+			}
+			return err
+		}
+	}
+	if updatesRoles && managers > 0 {
+		left, err := countManagers(ctx, tx, teamID)
+		if err != nil {
+			return err
+		}
+		if left == 0 {
+			return auth.ErrLastAdmin
+		}
+	}
+	return tx.Commit()
+}
+
+// countManagers counts the active members of the team whose role grants
+// team:manage, as auth.Repository.CountTeamManagers does
+func countManagers(ctx context.Context, tx *sql.Tx, teamID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM team_memberships m
+		JOIN roles r ON r.id = m.role_id
+		JOIN users u ON u.id = m.user_id
+		WHERE m.team_id = $1 AND r.permissions @> $2::jsonb AND u.status = 'active'`
+	manage, _ := json.Marshal([]string{auth.PermTeamManage})
+	var count int
+	err := tx.QueryRowContext(ctx, query, teamID, string(manage)).Scan(&count)
+	return count, err
+}
+
+func applyBlueprint(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, bp *Blueprint, update bool) error {
+	schema, err := json.Marshal(bp.Schema)
+	if err != nil {
+		return err
+	}
+
+	var policy, expiry interface{}
+	if bp.MergePolicy != nil {
+		if policy, err = json.Marshal(bp.MergePolicy); err != nil {
+			return err
+		}
+	}
+	if bp.ExpiryPolicy != nil {
+		if expiry, err = json.Marshal(bp.ExpiryPolicy); err != nil {
+			return err
+		}
+	}
+
+	write := `
+		INSERT INTO blueprints (id, team_id, title, description, icon, schema, identifier_mutable, merge_policy, expiry_policy, strict_updates)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	eventType := events.BlueprintCreated
+	if update {
+		write = `
+			UPDATE blueprints
+			SET title = $3, description = $4, icon = $5, schema = $6, identifier_mutable = $7, merge_policy = $8, expiry_policy = $9,
+				strict_updates = $10, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND team_id = $2`
+		eventType = events.BlueprintUpdated
+	}
+	query := `
+		WITH written AS (` + write + `
+			RETURNING ` + blueprint.EventColumns + `
+		), ` + blueprint.RecordEvent(eventType, "written", "$11", "$12") + `
+		SELECT COUNT(*) FROM written`
+
+	actor, _ := events.ActorFrom(ctx)
+	_, err = tx.ExecContext(ctx, query, bp.ID, teamID, bp.Title, bp.Description, bp.Icon, schema, bp.IdentifierMutable, policy, expiry, bp.StrictUpdates, actor.UserID, actor.APIKeyID)
+	return err
+}
+
+func applyRelation(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, rel *Relation, update bool) error {
+	relationType := rel.Type
+	if relationType == "" {
+		relationType = "many-to-many"
+	}
+
+	query := `
+		INSERT INTO blueprint_relations (team_id, source_blueprint_id, identifier, target_blueprint_id, title, relation_type, required)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+	if update {
+		query = `
+			UPDATE blueprint_relations
+			SET target_blueprint_id = $4, title = $5, relation_type = $6, required = $7
+			WHERE team_id = $1 AND source_blueprint_id = $2 AND identifier = $3`
+	}
+	_, err := tx.ExecContext(ctx, query, teamID, rel.Source, rel.Identifier, rel.Target, rel.Title, relationType, rel.Required)
+	return err
+}
+
+func applyScorecard(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, sc *Scorecard, update bool) error {
+	levels, err := json.Marshal(sc.Levels)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO scorecards (team_id, blueprint_id, identifier, title, levels)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+	if update {
+		query = `
+			UPDATE scorecards SET title = $4, levels = $5
+			WHERE team_id = $1 AND blueprint_id = $2 AND identifier = $3
+			RETURNING id`
+	}
+	var id uuid.UUID
+	if err := tx.QueryRowContext(ctx, query, teamID, sc.Blueprint, sc.Identifier, sc.Title, levels).Scan(&id); err != nil {
+		return err
+	}
+
+	// Rules have no identity of their own; an overwrite replaces them all
+	if update {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM scorecard_rules WHERE scorecard_id = $1`, id); err != nil {
+			return err
+		}
+	}
+	for _, rule := range sc.Rules {
+		value, err := json.Marshal(rule.Value)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO scorecard_rules (scorecard_id, level_name, property_path, operator, value)
+			VALUES ($1, $2, $3, $4, $5)`,
+			id, rule.Level, rule.PropertyPath, rule.Operator, value,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyAction(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, action *Action, update bool) error {
+	triggerType := action.TriggerType
+	if triggerType == "" {
+		triggerType = "manual"
+	}
+	triggerConfig, err := json.Marshal(orEmpty(action.TriggerConfig))
+	if err != nil {
+		return err
+	}
+	userInputs, err := json.Marshal(orEmptyList(action.UserInputs))
+	if err != nil {
+		return err
+	}
+	steps, err := json.Marshal(orEmptyList(action.Steps))
+	if err != nil {
+		return err
+	}
+	var blueprintID sql.NullString
+	if action.Blueprint != "" {
+		blueprintID = sql.NullString{String: action.Blueprint, Valid: true}
+	}
+
+	query := `
+		INSERT INTO actions (team_id, identifier, blueprint_id, title, description, trigger_type, trigger_config, user_inputs, steps)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+	if update {
+		query = `
+			UPDATE actions
+			SET blueprint_id = $3, title = $4, description = $5, trigger_type = $6, trigger_config = $7, user_inputs = $8, steps = $9
+			WHERE team_id = $1 AND identifier = $2`
+	}
+	_, err = tx.ExecContext(ctx, query, teamID, action.Identifier, blueprintID, action.Title, action.Description, triggerType, triggerConfig, userInputs, steps)
+	return err
+}
+
+func applyRole(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, role *Role, update bool) error {
+	permissions, err := json.Marshal(orEmptyStrings(role.Permissions))
+	if err != nil {
+		return err
+	}
+
+	if update {
+		return tx.QueryRowContext(ctx,
+			`UPDATE roles SET permissions = $3 WHERE team_id = $1 AND name = $2 RETURNING id`, teamID, role.Name, permissions,
+		).Scan(&role.id)
+	}
+	role.id = uuid.New()
+	_, err = tx.ExecContext(ctx, `INSERT INTO roles (id, team_id, name, permissions) VALUES ($1, $2, $3, $4)`, role.id, teamID, role.Name, permissions)
+	return err
+}
+
+func orEmpty(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
+
+func orEmptyStrings(list []string) []string {
+	if list == nil {
+		return []string{}
+	}
+	return list
+}
+
+func orEmptyList(list []interface{}) []interface{} {
+	if list == nil {
+		return []interface{}{}
+	}
+	return list
+}
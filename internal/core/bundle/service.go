@@ -0,0 +1,379 @@
+package bundle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+var (
+	ErrInvalidBundle     = errors.New("invalid bundle")
+	ErrInvalidExport     = errors.New("invalid export")
+	ErrConflict          = errors.New("bundle conflicts with the team")
+	ErrBlueprintNotFound = errors.New("blueprint not found")
+)
+
+type Service struct {
+	repo          *Repository
+	blueprintSvc  *blueprint.Service
+	scorecardRepo *scorecard.Repository
+	bus           *events.Bus
+}
+
+// NewService creates the bundle service. Imported blueprints are announced on
+// bus, which may be nil.
+func NewService(repo *Repository, blueprintSvc *blueprint.Service, scorecardRepo *scorecard.Repository, bus *events.Bus) *Service {
+	return &Service{repo: repo, blueprintSvc: blueprintSvc, scorecardRepo: scorecardRepo, bus: bus}
+}
+
+// Export bundles a team's blueprints with their relations, scorecards and
+// actions. When blueprintIDs is not empty only those blueprints are included,
+// with the relations between them and their scorecards and actions.
+func (s *Service) Export(ctx context.Context, teamID uuid.UUID, blueprintIDs []string) (*Bundle, error) {
+	list, err := s.blueprintSvc.List(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	include := func(id string) bool {
+		return len(blueprintIDs) == 0 || slices.Contains(blueprintIDs, id)
+	}
+	for _, id := range blueprintIDs {
+		if !slices.ContainsFunc(list.Blueprints, func(bp *blueprint.Blueprint) bool { return bp.ID == id }) {
+			return nil, fmt.Errorf("%w: %s", ErrBlueprintNotFound, id)
+		}
+	}
+
+	b := &Bundle{
+		Version:    Version,
+		ExportedAt: time.Now().UTC(),
+		Blueprints: []Blueprint{},
+		Relations:  []Relation{},
+		Scorecards: []Scorecard{},
+		Actions:    []Action{},
+	}
+	// List is newest first; creation order reads better and imports the same way
+	for i := len(list.Blueprints) - 1; i >= 0; i-- {
+		bp := list.Blueprints[i]
+		if include(bp.ID) {
+			b.Blueprints = append(b.Blueprints, exportBlueprint(bp))
+		}
+	}
+
+	relations, err := s.repo.ListRelations(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range relations {
+		if include(rel.Source) && include(rel.Target) {
+			b.Relations = append(b.Relations, rel)
+		}
+	}
+
+	scorecards, err := s.scorecardRepo.ListByTeam(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range scorecards {
+		if include(sc.BlueprintID) {
+			b.Scorecards = append(b.Scorecards, *exportScorecard(sc))
+		}
+	}
+
+	actions, err := s.repo.ListActions(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range actions {
+		// Team-wide actions only travel with a full export
+		if (action.Blueprint == "" && len(blueprintIDs) == 0) || (action.Blueprint != "" && include(action.Blueprint)) {
+			b.Actions = append(b.Actions, action)
+		}
+	}
+
+	return b, nil
+}
+
+// Import applies a bundle to a team in one transaction. Items that already
+// exist are handled by strategy; a dry run reports the outcome without
+// writing anything.
+func (s *Service) Import(ctx context.Context, teamID uuid.UUID, b *Bundle, strategy string, dryRun bool) (*ImportResult, error) {
+	switch strategy {
+	case "":
+		strategy = StrategySkip
+	case StrategySkip, StrategyOverwrite, StrategyRename:
+	default:
+		return nil, fmt.Errorf("%w: unknown strategy %q", ErrInvalidBundle, strategy)
+	}
+
+	state, err := s.teamState(ctx, teamID, b)
+	if err != nil {
+		return nil, err
+	}
+	if err := validate(b, state); err != nil {
+		return nil, err
+	}
+	steps, err := plan(b, state, strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{DryRun: dryRun, Strategy: strategy, Items: make([]ItemResult, 0, len(steps))}
+	for _, st := range steps {
+		result.Items = append(result.Items, st.ItemResult)
+		switch st.Result {
+		case ResultCreated:
+			result.Created++
+		case ResultUpdated:
+			result.Updated++
+		case ResultRenamed:
+			result.Renamed++
+		case ResultSkipped:
+			result.Skipped++
+		}
+	}
+	if dryRun {
+		return result, nil
+	}
+
+	if err := s.repo.Apply(ctx, teamID, steps); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, teamID, steps)
+	return result, nil
+}
+
+// Apply brings a team's configuration in line with a manifest in one
+// transaction. A dry run returns the plan without writing anything.
+func (s *Service) Apply(ctx context.Context, teamID uuid.UUID, m *Manifest, dryRun bool) (*ApplyResult, error) {
+	current, err := s.currentState(ctx, teamID, m)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateManifest(m, current); err != nil {
+		return nil, err
+	}
+	steps, err := diffManifest(m, current)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ApplyResult{DryRun: dryRun, Changes: make([]ItemResult, 0, len(steps))}
+	for _, st := range steps {
+		result.Changes = append(result.Changes, st.ItemResult)
+		switch st.Result {
+		case ResultCreated:
+			result.Created++
+		case ResultUpdated:
+			result.Updated++
+		case ResultUnchanged:
+			result.Unchanged++
+		}
+	}
+	if dryRun || result.Created+result.Updated == 0 {
+		return result, nil
+	}
+
+	if err := s.repo.Apply(ctx, teamID, steps); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, teamID, steps)
+	return result, nil
+}
+
+func (s *Service) currentState(ctx context.Context, teamID uuid.UUID, m *Manifest) (*currentState, error) {
+	current := &currentState{
+		blueprints: map[string]*Blueprint{},
+		roles:      map[string]*Role{},
+		scorecards: map[string]*Scorecard{},
+	}
+
+	list, err := s.blueprintSvc.List(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, bp := range list.Blueprints {
+		current.blueprints[bp.ID] = &Blueprint{
+			ID:                bp.ID,
+			Title:             bp.Title,
+			Description:       bp.Description,
+			Icon:              bp.Icon,
+			Schema:            bp.Schema,
+			IdentifierMutable: bp.IdentifierMutable,
+			MergePolicy:       bp.MergePolicy,
+			ExpiryPolicy:      bp.ExpiryPolicy,
+			StrictUpdates:     bp.StrictUpdates,
+		}
+	}
+
+	ids := make([]string, 0, len(m.Blueprints))
+	for _, bp := range m.Blueprints {
+		ids = append(ids, bp.ID)
+	}
+	if current.takenIDs, err = s.repo.TakenBlueprintIDs(ctx, ids); err != nil {
+		return nil, err
+	}
+
+	roles, err := s.repo.ListRoles(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range roles {
+		current.roles[roles[i].Name] = &roles[i]
+	}
+
+	scorecards, err := s.scorecardRepo.ListByTeam(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range scorecards {
+		current.scorecards[sc.BlueprintID+"/"+sc.Identifier] = exportScorecard(sc)
+	}
+	return current, nil
+}
+
+func (s *Service) teamState(ctx context.Context, teamID uuid.UUID, b *Bundle) (*teamState, error) {
+	state := &teamState{
+		blueprints: map[string]bool{},
+		relations:  map[string]bool{},
+		scorecards: map[string]bool{},
+		actions:    map[string]bool{},
+	}
+
+	list, err := s.blueprintSvc.List(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, bp := range list.Blueprints {
+		state.blueprints[bp.ID] = true
+	}
+
+	prefixes := make([]string, 0, len(b.Blueprints))
+	for _, bp := range b.Blueprints {
+		prefixes = append(prefixes, bp.ID)
+	}
+	if state.takenIDs, err = s.repo.TakenBlueprintIDs(ctx, prefixes); err != nil {
+		return nil, err
+	}
+
+	relations, err := s.repo.ListRelations(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rel := range relations {
+		state.relations[rel.Source+"/"+rel.Identifier] = true
+	}
+
+	scorecards, err := s.scorecardRepo.ListByTeam(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range scorecards {
+		state.scorecards[sc.BlueprintID+"/"+sc.Identifier] = true
+	}
+
+	actions, err := s.repo.ListActions(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, action := range actions {
+		state.actions[action.Identifier] = true
+	}
+
+	if state.secrets, err = s.repo.SecretNames(ctx, teamID); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func exportBlueprint(bp *blueprint.Blueprint) Blueprint {
+	return Blueprint{
+		ID:                bp.ID,
+		Title:             bp.Title,
+		Description:       bp.Description,
+		Icon:              bp.Icon,
+		Schema:            bp.Schema,
+		IdentifierMutable: bp.IdentifierMutable,
+		MergePolicy:       bp.MergePolicy,
+		ExpiryPolicy:      bp.ExpiryPolicy,
+		StrictUpdates:     bp.StrictUpdates,
+	}
+}
+
+func exportScorecard(sc *scorecard.Scorecard) *Scorecard {
+	out := &Scorecard{
+		Blueprint:  sc.BlueprintID,
+		Identifier: sc.Identifier,
+		Title:      sc.Title,
+		Levels:     sc.Levels,
+		Rules:      []Rule{},
+	}
+	for _, rule := range sc.Rules {
+		out.Rules = append(out.Rules, Rule{
+			Level:        rule.LevelName,
+			PropertyPath: rule.PropertyPath,
+			Operator:     rule.Operator,
+			Value:        rule.Value,
+		})
+	}
+	return out
+}
+
+// publish announces imported blueprints and changed roles. A blueprint whose
+// scorecards changed is announced as updated so that aggregations over them
+// are rebuilt.
+func (s *Service) publish(ctx context.Context, teamID uuid.UUID, steps []*step) {
+	announced := map[string]bool{}
+	for _, st := range steps {
+		if !st.writes() {
+			continue
+		}
+		switch {
+		case st.blueprint != nil:
+			eventType := events.BlueprintCreated
+			if st.Result == ResultUpdated {
+				eventType = events.BlueprintUpdated
+			}
+			bp := st.blueprint
+			s.bus.Publish(ctx, events.Event{
+				Type:        eventType,
+				TeamID:      teamID,
+				BlueprintID: bp.ID,
+				Payload: &blueprint.Blueprint{
+					ID:                bp.ID,
+					TeamID:            teamID,
+					Title:             bp.Title,
+					Description:       bp.Description,
+					Icon:              bp.Icon,
+					Schema:            bp.Schema,
+					IdentifierMutable: bp.IdentifierMutable,
+					MergePolicy:       bp.MergePolicy,
+					ExpiryPolicy:      bp.ExpiryPolicy,
+					StrictUpdates:     bp.StrictUpdates,
+				},
+			})
+			announced[bp.ID] = true
+		case st.scorecard != nil && !announced[st.scorecard.Blueprint]:
+			s.bus.Publish(ctx, events.Event{
+				Type:        events.BlueprintUpdated,
+				TeamID:      teamID,
+				BlueprintID: st.scorecard.Blueprint,
+			})
+			announced[st.scorecard.Blueprint] = true
+		case st.role != nil && st.Result == ResultUpdated:
+			s.bus.Publish(ctx, events.Event{
+				Type:    events.RoleUpdated,
+				TeamID:  teamID,
+				Payload: &auth.Role{ID: st.role.id, TeamID: teamID, Name: st.role.Name, Permissions: st.role.Permissions},
+			})
+		}
+	}
+}
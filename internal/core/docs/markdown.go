@@ -0,0 +1,461 @@
+package docs
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Render converts the markdown of a page to HTML. It covers the common
+// subset docs are written in: headings, paragraphs, emphasis, code spans
+// and fenced code, lists, block quotes, tables, rules, links and images.
+// Raw HTML is escaped rather than passed through, and links and images
+// only keep http, https, mailto and relative URLs, so the output is safe to
+// embed as is.
+func Render(markdown string) string {
+	lines := strings.Split(strings.ReplaceAll(markdown, "\r\n", "\n"), "\n")
+	return strings.Join(renderBlocks(lines, false), "\n")
+}
+
+var (
+	headingRe   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	ruleRe      = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	fenceRe     = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})[ \t]*([^`\\s]*)")
+	bulletRe    = regexp.MustCompile(`^( {0,3})([-*+])[ \t]+(.*)$`)
+	orderedRe   = regexp.MustCompile(`^( {0,3})(\d{1,9})[.)][ \t]+(.*)$`)
+	quoteRe     = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+	tableSepRe  = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	schemeRe    = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]*:`)
+	safeSchemes = []string{"http:", "https:", "mailto:"}
+)
+
+// renderBlocks renders lines as block elements. In a tight list item a
+// leading paragraph is not wrapped in <p>.
+func renderBlocks(lines []string, tight bool) []string {
+	var out, paragraph []string
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		// Two trailing spaces end a line with a hard break
+		for n, line := range paragraph {
+			trimmed := strings.TrimRight(line, " \t")
+			if n < len(paragraph)-1 && strings.HasSuffix(line, "  ") {
+				trimmed += "\\"
+			}
+			paragraph[n] = trimmed
+		}
+		text := renderInline(strings.Join(paragraph, "\n"))
+		if tight && len(out) == 0 {
+			out = append(out, text)
+		} else {
+			out = append(out, "<p>"+text+"</p>")
+		}
+		paragraph = nil
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.TrimSpace(line) == "":
+			flush()
+
+		case fenceRe.MatchString(line):
+			flush()
+			m := fenceRe.FindStringSubmatch(line)
+			fence := m[1]
+			var code []string
+			for i++; i < len(lines); i++ {
+				if t := strings.TrimSpace(lines[i]); strings.HasPrefix(t, fence[:3]) && strings.Trim(t, fence[:1]) == "" && len(t) >= len(fence) {
+					break
+				}
+				code = append(code, lines[i])
+			}
+			class := ""
+			if m[2] != "" {
+				class = ` class="language-` + html.EscapeString(m[2]) + `"`
+			}
+			body := html.EscapeString(strings.Join(code, "\n"))
+			if len(code) > 0 {
+				body += "\n"
+			}
+			out = append(out, "<pre><code"+class+">"+body+"</code></pre>")
+
+		case headingRe.MatchString(line):
+			flush()
+			m := headingRe.FindStringSubmatch(line)
+			level := string(rune('0' + len(m[1])))
+			out = append(out, "<h"+level+">"+renderInline(m[2])+"</h"+level+">")
+
+		case ruleRe.MatchString(line):
+			flush()
+			out = append(out, "<hr>")
+
+		case quoteRe.MatchString(line):
+			flush()
+			var quoted []string
+			for ; i < len(lines) && quoteRe.MatchString(lines[i]); i++ {
+				quoted = append(quoted, quoteRe.FindStringSubmatch(lines[i])[1])
+			}
+			i--
+			out = append(out, "<blockquote>\n"+strings.Join(renderBlocks(quoted, false), "\n")+"\n</blockquote>")
+
+		case bulletRe.MatchString(line) || orderedRe.MatchString(line):
+			flush()
+			var list string
+			list, i = renderList(lines, i)
+			out = append(out, list)
+
+		case len(paragraph) == 0 && i+1 < len(lines) && strings.Contains(line, "|") && tableSepRe.MatchString(lines[i+1]):
+			var table string
+			table, i = renderTable(lines, i)
+			out = append(out, table)
+
+		default:
+			paragraph = append(paragraph, strings.TrimLeft(line, " \t"))
+		}
+	}
+	flush()
+	return out
+}
+
+// renderList renders the list starting at lines[start] and returns it with
+// the index of its last line. Lines indented past the marker continue the
+// item, so nested lists and paragraphs render inside it.
+func renderList(lines []string, start int) (string, int) {
+	ordered := !bulletRe.MatchString(lines[start])
+	marker := func(line string) (indent int, text string, ok bool) {
+		re := bulletRe
+		if ordered {
+			re = orderedRe
+		}
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			return 0, "", false
+		}
+		return len(m[1]), m[3], true
+	}
+
+	tag, open := "ul", "<ul>"
+	if ordered {
+		tag = "ol"
+		if n := strings.TrimLeft(orderedRe.FindStringSubmatch(lines[start])[2], "0"); n != "" && n != "1" {
+			open = `<ol start="` + n + `">`
+		} else {
+			open = "<ol>"
+		}
+	}
+
+	// Markers indented past the first one start nested lists
+	base, _, _ := marker(lines[start])
+	var items [][]string
+	i := start
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if indent, text, ok := marker(line); ok && indent <= base+1 {
+			items = append(items, []string{text})
+			continue
+		}
+		trimmed := strings.TrimLeft(line, " \t")
+		indented := len(line)-len(trimmed) >= 2
+		switch {
+		case strings.TrimSpace(line) == "":
+			// A blank line continues the item only when more of it follows
+			if i+1 < len(lines) && (strings.HasPrefix(lines[i+1], "  ") || strings.HasPrefix(lines[i+1], "\t")) {
+				items[len(items)-1] = append(items[len(items)-1], "")
+				continue
+			}
+		case indented:
+			items[len(items)-1] = append(items[len(items)-1], dedent(line))
+			continue
+		case !isBlockStart(line) && strings.TrimSpace(lines[i-1]) != "":
+			// Lazy continuation of the item's paragraph
+			items[len(items)-1] = append(items[len(items)-1], trimmed)
+			continue
+		}
+		break
+	}
+
+	var b strings.Builder
+	b.WriteString(open)
+	for _, item := range items {
+		b.WriteString("\n<li>")
+		b.WriteString(strings.Join(renderBlocks(item, true), "\n"))
+		b.WriteString("</li>")
+	}
+	b.WriteString("\n</" + tag + ">")
+	return b.String(), i - 1
+}
+
+// isBlockStart reports whether a line starts a block other than a paragraph
+func isBlockStart(line string) bool {
+	return headingRe.MatchString(line) || ruleRe.MatchString(line) || fenceRe.MatchString(line) ||
+		quoteRe.MatchString(line) || bulletRe.MatchString(line) || orderedRe.MatchString(line)
+}
+
+// dedent removes up to four columns of indentation, a tab counting as four
+func dedent(line string) string {
+	for n := 0; n < 4 && line != ""; n++ {
+		if line[0] == '\t' {
+			return line[1:]
+		}
+		if line[0] != ' ' {
+			break
+		}
+		line = line[1:]
+	}
+	return line
+}
+
+// renderTable renders the pipe table whose header is lines[start] and
+// returns it with the index of its last line
+func renderTable(lines []string, start int) (string, int) {
+	header := tableCells(lines[start])
+	aligns := make([]string, len(header))
+	for n, cell := range tableCells(lines[start+1]) {
+		if n >= len(aligns) {
+			break
+		}
+		left, right := strings.HasPrefix(cell, ":"), strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			aligns[n] = ` style="text-align:center"`
+		case right:
+			aligns[n] = ` style="text-align:right"`
+		case left:
+			aligns[n] = ` style="text-align:left"`
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("<table>\n<thead>\n<tr>")
+	for n, cell := range header {
+		b.WriteString("<th" + aligns[n] + ">" + renderInline(cell) + "</th>")
+	}
+	b.WriteString("</tr>\n</thead>")
+
+	i := start + 2
+	if i < len(lines) && strings.TrimSpace(lines[i]) != "" && !isBlockStart(lines[i]) {
+		b.WriteString("\n<tbody>")
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != "" && !isBlockStart(lines[i]); i++ {
+			cells := tableCells(lines[i])
+			b.WriteString("\n<tr>")
+			for n := range header {
+				cell := ""
+				if n < len(cells) {
+					cell = cells[n]
+				}
+				b.WriteString("<td" + aligns[n] + ">" + renderInline(cell) + "</td>")
+			}
+			b.WriteString("</tr>")
+		}
+		b.WriteString("\n</tbody>")
+	}
+	b.WriteString("\n</table>")
+	return b.String(), i - 1
+}
+
+// tableCells splits a table row on unescaped pipes
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cell strings.Builder
+	for n := 0; n < len(line); n++ {
+		switch {
+		case line[n] == '\\' && n+1 < len(line) && line[n+1] == '|':
+			cell.WriteByte('|')
+			n++
+		case line[n] == '|':
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[n])
+		}
+	}
+	return append(cells, strings.TrimSpace(cell.String()))
+}
+
+// renderInline renders the inline markdown of a block: code spans, links,
+// images, autolinks, emphasis, strikethrough and hard line breaks. All other
+// text is escaped.
+func renderInline(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			b.WriteString("<br>\n")
+			i += 2
+			continue
+
+		case c == '\\' && i+1 < len(text) && strings.IndexByte("\\`*_{}[]()#+-.!|~<>\"'", text[i+1]) >= 0:
+			b.WriteString(html.EscapeString(text[i+1 : i+2]))
+			i += 2
+			continue
+
+		case c == '`':
+			run := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			fence := text[i : i+run]
+			if end := strings.Index(text[i+run:], fence); end >= 0 {
+				code := text[i+run : i+run+end]
+				if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.TrimSpace(code) != "" {
+					code = code[1 : len(code)-1]
+				}
+				b.WriteString("<code>" + html.EscapeString(strings.ReplaceAll(code, "\n", " ")) + "</code>")
+				i += run + end + run
+				continue
+			}
+			b.WriteString(fence)
+			i += run
+			continue
+
+		case c == '!' && strings.HasPrefix(text[i+1:], "["):
+			if label, url, n, ok := parseLink(text[i+1:]); ok {
+				if safeURL(url) {
+					b.WriteString(`<img src="` + html.EscapeString(url) + `" alt="` + html.EscapeString(label) + `">`)
+				} else {
+					b.WriteString(html.EscapeString(label))
+				}
+				i += 1 + n
+				continue
+			}
+
+		case c == '[':
+			if label, url, n, ok := parseLink(text[i:]); ok {
+				if safeURL(url) {
+					b.WriteString(`<a href="` + html.EscapeString(url) + `" rel="nofollow noopener">` + renderInline(label) + `</a>`)
+				} else {
+					b.WriteString(renderInline(label))
+				}
+				i += n
+				continue
+			}
+
+		case c == '<':
+			if end := strings.IndexByte(text[i:], '>'); end > 0 {
+				url := text[i+1 : i+end]
+				if !strings.ContainsAny(url, " \t\n<") && schemeRe.MatchString(url) && safeURL(url) {
+					b.WriteString(`<a href="` + html.EscapeString(url) + `" rel="nofollow noopener">` + html.EscapeString(url) + `</a>`)
+					i += end + 1
+					continue
+				}
+			}
+
+		case c == '*' || c == '_' || c == '~':
+			if n, ok := renderEmphasis(&b, text, i); ok {
+				i = n
+				continue
+			}
+		}
+		b.WriteString(html.EscapeString(text[i : i+1]))
+		i++
+	}
+	return b.String()
+}
+
+// renderEmphasis renders the emphasis, strong emphasis or strikethrough
+// opening at text[i] and returns the index after it. It reports false when
+// the delimiter is not closed.
+func renderEmphasis(b *strings.Builder, text string, i int) (int, bool) {
+	c := text[i]
+	run := len(text[i:]) - len(strings.TrimLeft(text[i:], string(c)))
+	var delim, tag string
+	switch {
+	case c == '~' && run >= 2:
+		delim, tag = "~~", "del"
+	case c == '~':
+		return 0, false
+	case run >= 2:
+		delim, tag = text[i:i+2], "strong"
+	default:
+		delim, tag = text[i:i+1], "em"
+	}
+
+	start := i + len(delim)
+	if start >= len(text) || text[start] == ' ' || text[start] == '\n' {
+		return 0, false
+	}
+	// Underscores inside words are not emphasis, as in snake_case names
+	if c == '_' && i > 0 && isWordByte(text[i-1]) {
+		return 0, false
+	}
+	for from := start; ; {
+		end := strings.Index(text[from:], delim)
+		if end < 0 {
+			return 0, false
+		}
+		end += from
+		after := end + len(delim)
+		if end > start && text[end-1] != ' ' && text[end-1] != '\\' &&
+			(c != '_' || after >= len(text) || !isWordByte(text[after])) &&
+			(len(delim) == 2 || after >= len(text) || text[after] != c) {
+			b.WriteString("<" + tag + ">" + renderInline(text[start:end]) + "</" + tag + ">")
+			return after, true
+		}
+		from = end + 1
+		if from >= len(text) {
+			return 0, false
+		}
+	}
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// parseLink parses a [label](url) link at the start of text and returns the
+// label, the url and the length of the link
+func parseLink(text string) (label, url string, n int, ok bool) {
+	depth := 0
+	closing := -1
+	for j := 0; j < len(text) && closing < 0; j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				closing = j
+			}
+		}
+	}
+	if closing < 0 || !strings.HasPrefix(text[closing+1:], "(") {
+		return "", "", 0, false
+	}
+	end := strings.IndexByte(text[closing+2:], ')')
+	if end < 0 {
+		return "", "", 0, false
+	}
+	target := strings.TrimSpace(text[closing+2 : closing+2+end])
+	// A title after the URL is dropped
+	if sp := strings.IndexAny(target, " \t\n"); sp >= 0 {
+		target = target[:sp]
+	}
+	target = strings.TrimSuffix(strings.TrimPrefix(target, "<"), ">")
+	return text[1:closing], target, closing + 3 + end, true
+}
+
+// safeURL reports whether a link or image URL is relative or uses a scheme
+// that cannot run script
+func safeURL(url string) bool {
+	if strings.ContainsAny(url, "\x00\t\n\r") {
+		return false
+	}
+	scheme := schemeRe.FindString(url)
+	if scheme == "" {
+		return true
+	}
+	scheme = strings.ToLower(scheme)
+	for _, s := range safeSchemes {
+		if scheme == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package docs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"heading", "# Payments *API*", "<h1>Payments <em>API</em></h1>"},
+		{"closed heading", "## Runbook ##", "<h2>Runbook</h2>"},
+		{"paragraphs", "one\ntwo\n\nthree", "<p>one\ntwo</p>\n<p>three</p>"},
+		{"hard break", "one  \ntwo", "<p>one<br>\ntwo</p>"},
+		{"emphasis", "**bold** and _em_ and ~~gone~~", "<p><strong>bold</strong> and <em>em</em> and <del>gone</del></p>"},
+		{"snake case", "set max_open_conns here", "<p>set max_open_conns here</p>"},
+		{"unclosed", "2 * 3 = 6", "<p>2 * 3 = 6</p>"},
+		{"code span", "run `make <all>`", "<p>run <code>make &lt;all&gt;</code></p>"},
+		{"escape", `\*not em\*`, "<p>*not em*</p>"},
+		{"fence", "```go\nfmt.Println(\"<hi>\")\n```", "<pre><code class=\"language-go\">fmt.Println(&#34;&lt;hi&gt;&#34;)\n</code></pre>"},
+		{"unclosed fence", "~~~\ncode", "<pre><code>code\n</code></pre>"},
+		{"rule", "a\n\n---\n\nb", "<p>a</p>\n<hr>\n<p>b</p>"},
+		{"quote", "> note\n> **this**", "<blockquote>\n<p>note\n<strong>this</strong></p>\n</blockquote>"},
+		{"bullets", "- one\n- two\n  more\n\nafter", "<ul>\n<li>one</li>\n<li>two\nmore</li>\n</ul>\n<p>after</p>"},
+		{"nested", "1. one\n   - a\n2. two", "<ol>\n<li>one\n<ul>\n<li>a</li>\n</ul></li>\n<li>two</li>\n</ol>"},
+		{"nested bullets", "- one\n  - a\n- two", "<ul>\n<li>one\n<ul>\n<li>a</li>\n</ul></li>\n<li>two</li>\n</ul>"},
+		{"ordered start", "3. three\n4. four", "<ol start=\"3\">\n<li>three</li>\n<li>four</li>\n</ol>"},
+		{"link", "[runbook](https://wiki.example.com/r?a=1&b=2 \"title\")", `<p><a href="https://wiki.example.com/r?a=1&amp;b=2" rel="nofollow noopener">runbook</a></p>`},
+		{"relative link", "[setup](./setup.md#install)", `<p><a href="./setup.md#install" rel="nofollow noopener">setup</a></p>`},
+		{"autolink", "<https://example.com>", `<p><a href="https://example.com" rel="nofollow noopener">https://example.com</a></p>`},
+		{"image", "![diagram](/img/a.png)", `<p><img src="/img/a.png" alt="diagram"></p>`},
+		{
+			"table",
+			"| Name | Port |\n|:-----|-----:|\n| api | 80 |\n| a \\| b |",
+			"<table>\n<thead>\n<tr><th style=\"text-align:left\">Name</th><th style=\"text-align:right\">Port</th></tr>\n</thead>\n<tbody>\n<tr><td style=\"text-align:left\">api</td><td style=\"text-align:right\">80</td></tr>\n<tr><td style=\"text-align:left\">a | b</td><td style=\"text-align:right\"></td></tr>\n</tbody>\n</table>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Render(tt.in); got != tt.want {
+				t.Errorf("Render(%q) =\n%s\nwant\n%s", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRender_Unsafe(t *testing.T) {
+	for _, in := range []string{
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"[click](javascript:alert(1))",
+		"[click](JavaScript:alert(1))",
+		"![x](data:text/html;base64,PHNjcmlwdD4=)",
+		"<javascript:alert(1)>",
+		"[x](\" onmouseover=\"alert(1))",
+		"```\"><script>\nx\n```",
+		"| <b> |\n|---|\n| <i> |",
+	} {
+		got := Render(in)
+		for _, bad := range []string{"<script", "<img src=x", `href="javascript`, `href="JavaScript`, `src="data`, "<b>", "<i>", `" onmouseover`} {
+			if strings.Contains(got, bad) {
+				t.Errorf("Render(%q) = %q, contains %q", in, got, bad)
+			}
+		}
+	}
+}
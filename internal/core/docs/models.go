@@ -0,0 +1,78 @@
+package docs
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Doc is the markdown documentation page of a blueprint, or of one of its
+// entities when EntityID is set
+type Doc struct {
+	ID          uuid.UUID  `json:"id"`
+	TeamID      uuid.UUID  `json:"team_id"`
+	BlueprintID string     `json:"blueprint_id"`
+	EntityID    *uuid.UUID `json:"entity_id,omitempty"`
+	// Version counts the saves of the page, starting at 1
+	Version   int        `json:"version"`
+	Content   string     `json:"content"`
+	UpdatedBy *uuid.UUID `json:"updated_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// Subject is what a page documents: a blueprint, or an entity of it
+type Subject struct {
+	TeamID      uuid.UUID
+	BlueprintID string
+	EntityID    *uuid.UUID
+}
+
+// Version is a saved version of a page, without its content in listings
+type Version struct {
+	Version   int        `json:"version"`
+	Size      int        `json:"size"`
+	Author    *uuid.UUID `json:"author,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// VersionsResponse is a page of a doc's versions, newest first
+type VersionsResponse struct {
+	Versions []*Version `json:"versions"`
+	Total    int        `json:"total"`
+	Limit    int        `json:"limit"`
+	Offset   int        `json:"offset"`
+}
+
+// PutDocRequest saves a new version of a page. BaseVersion, when set, is the
+// version the edit started from; saving over a newer one is a conflict.
+type PutDocRequest struct {
+	Content     string `json:"content"`
+	BaseVersion int    `json:"base_version"`
+}
+
+// Rendered is a page rendered to HTML
+type Rendered struct {
+	Version int    `json:"version"`
+	HTML    string `json:"html"`
+}
+
+// SearchResult is a page matching a docs search. Snippet is plain text with
+// the matching words in **bold**.
+type SearchResult struct {
+	BlueprintID      string     `json:"blueprint_id"`
+	EntityID         *uuid.UUID `json:"entity_id,omitempty"`
+	EntityIdentifier string     `json:"entity_identifier,omitempty"`
+	EntityTitle      string     `json:"entity_title,omitempty"`
+	Version          int        `json:"version"`
+	Snippet          string     `json:"snippet"`
+	Rank             float64    `json:"rank"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// SearchResponse lists the pages matching a docs search, best match first
+type SearchResponse struct {
+	Query   string          `json:"query"`
+	Results []*SearchResult `json:"results"`
+	Limit   int             `json:"limit"`
+}
@@ -0,0 +1,216 @@
+package docs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const docColumns = `d.id, d.team_id, d.blueprint_id, d.entity_id, d.version, d.content, d.updated_by, d.created_at, d.updated_at`
+
+// where returns the condition selecting the page of a subject in
+// catalog_docs d, with its arguments
+func where(s Subject) (string, []interface{}) {
+	if s.EntityID != nil {
+		return `d.team_id = $1 AND d.entity_id = $2`, []interface{}{s.TeamID, *s.EntityID}
+	}
+	return `d.team_id = $1 AND d.blueprint_id = $2 AND d.entity_id IS NULL`, []interface{}{s.TeamID, s.BlueprintID}
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDoc(row scanner) (*Doc, error) {
+	d := &Doc{}
+	var entityID, updatedBy uuid.NullUUID
+	if err := row.Scan(&d.ID, &d.TeamID, &d.BlueprintID, &entityID, &d.Version, &d.Content, &updatedBy, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if entityID.Valid {
+		d.EntityID = &entityID.UUID
+	}
+	if updatedBy.Valid {
+		d.UpdatedBy = &updatedBy.UUID
+	}
+	return d, nil
+}
+
+// Get returns the current version of a subject's page, or nil
+func (r *Repository) Get(ctx context.Context, s Subject) (*Doc, error) {
+	cond, args := where(s)
+	d, err := scanDoc(r.db.Reader(ctx).QueryRowContext(ctx, `SELECT `+docColumns+` FROM catalog_docs d WHERE `+cond, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// GetVersion returns a subject's page as it was at a version, or nil. The
+// author and time of the version take the place of the last update.
+func (r *Repository) GetVersion(ctx context.Context, s Subject, version int) (*Doc, error) {
+	cond, args := where(s)
+	query := `
+		SELECT d.id, d.team_id, d.blueprint_id, d.entity_id, v.version, v.content, v.author, d.created_at, v.created_at
+		FROM catalog_docs d
+		JOIN catalog_doc_versions v ON v.doc_id = d.id AND v.version = $3
+		WHERE ` + cond
+	d, err := scanDoc(r.db.Reader(ctx).QueryRowContext(ctx, query, append(args, version)...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// Put saves content as the next version of a subject's page, creating the
+// page at version 1. When baseVersion is set and the page is at another
+// version, nothing is saved and ErrVersionConflict is returned. Saving the
+// content the page already has keeps its version.
+func (r *Repository) Put(ctx context.Context, s Subject, content string, userID *uuid.UUID, baseVersion int) (*Doc, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cond, args := where(s)
+	current, err := scanDoc(tx.QueryRowContext(ctx, `SELECT `+docColumns+` FROM catalog_docs d WHERE `+cond+` FOR UPDATE`, args...))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var d *Doc
+	switch {
+	case current == nil:
+		if baseVersion != 0 {
+			return nil, fmt.Errorf("%w: the page does not exist", ErrVersionConflict)
+		}
+		// A concurrent first save wins the unique index; this one conflicts
+		d, err = scanDoc(tx.QueryRowContext(ctx, `
+			INSERT INTO catalog_docs AS d (team_id, blueprint_id, entity_id, content, updated_by)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT DO NOTHING
+			RETURNING `+docColumns,
+			s.TeamID, s.BlueprintID, s.EntityID, content, userID))
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("%w: the page was created concurrently", ErrVersionConflict)
+		}
+	case baseVersion != 0 && baseVersion != current.Version:
+		return nil, fmt.Errorf("%w: the page is at version %d", ErrVersionConflict, current.Version)
+	case current.Content == content:
+		return current, nil
+	default:
+		d, err = scanDoc(tx.QueryRowContext(ctx, `
+			UPDATE catalog_docs AS d SET version = version + 1, content = $2, updated_by = $3, updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+docColumns,
+			current.ID, content, userID))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO catalog_doc_versions (doc_id, version, content, author, created_at) VALUES ($1, $2, $3, $4, $5)`,
+		d.ID, d.Version, d.Content, d.UpdatedBy, d.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return d, tx.Commit()
+}
+
+// Versions returns a page of a subject's versions, newest first, and how
+// many there are
+func (r *Repository) Versions(ctx context.Context, s Subject, limit, offset int) ([]*Version, int, error) {
+	cond, args := where(s)
+	var total int
+	err := r.db.Reader(ctx).QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM catalog_doc_versions v JOIN catalog_docs d ON d.id = v.doc_id
+		WHERE `+cond, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, `
+		SELECT v.version, octet_length(v.content), v.author, v.created_at
+		FROM catalog_doc_versions v JOIN catalog_docs d ON d.id = v.doc_id
+		WHERE `+cond+`
+		ORDER BY v.version DESC
+		LIMIT $3 OFFSET $4`, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	versions := []*Version{}
+	for rows.Next() {
+		v := &Version{}
+		var author uuid.NullUUID
+		if err := rows.Scan(&v.Version, &v.Size, &author, &v.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		if author.Valid {
+			v.Author = &author.UUID
+		}
+		versions = append(versions, v)
+	}
+	return versions, total, rows.Err()
+}
+
+// Delete removes a subject's page with its versions and reports whether it
+// had one
+func (r *Repository) Delete(ctx context.Context, s Subject) (bool, error) {
+	cond, args := where(s)
+	res, err := r.db.DB.ExecContext(ctx, `DELETE FROM catalog_docs d WHERE `+cond, args...)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// Search returns the pages of a team matching a web search style query,
+// best match first. Blueprint pages are left out unless blueprints is set
+// and entity pages unless entities is.
+func (r *Repository) Search(ctx context.Context, teamID uuid.UUID, query string, blueprints, entities bool, limit int) ([]*SearchResult, error) {
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, `
+		SELECT d.blueprint_id, d.entity_id, COALESCE(e.identifier, ''), COALESCE(e.title, ''), d.version,
+		       ts_headline('english', d.content, q, 'StartSel=**, StopSel=**, MaxFragments=2, MaxWords=30, MinWords=10'),
+		       ts_rank(d.search, q) AS rank, d.updated_at
+		FROM catalog_docs d
+		CROSS JOIN websearch_to_tsquery('english', $2) q
+		LEFT JOIN entities e ON e.id = d.entity_id
+		WHERE d.team_id = $1 AND d.search @@ q
+		  AND ($3 OR d.entity_id IS NOT NULL) AND ($4 OR d.entity_id IS NULL)
+		ORDER BY rank DESC, d.updated_at DESC
+		LIMIT $5`, teamID, query, blueprints, entities, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*SearchResult{}
+	for rows.Next() {
+		res := &SearchResult{}
+		var entityID uuid.NullUUID
+		if err := rows.Scan(&res.BlueprintID, &entityID, &res.EntityIdentifier, &res.EntityTitle, &res.Version, &res.Snippet, &res.Rank, &res.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if entityID.Valid {
+			res.EntityID = &entityID.UUID
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,198 @@
+// Package docs stores the markdown documentation pages of blueprints and
+// entities, so catalog docs live next to what they describe rather than in
+// an external wiki. Every save is kept as a version, pages render to HTML
+// and the current versions are searchable.
+package docs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+var (
+	ErrNotFound          = errors.New("doc not found")
+	ErrBlueprintNotFound = errors.New("blueprint not found")
+	ErrEntityNotFound    = errors.New("entity not found")
+	ErrInvalidDoc        = errors.New("invalid doc")
+	ErrVersionConflict   = errors.New("doc was changed since the base version")
+	ErrSearchForbidden   = errors.New("docs search requires the blueprint:read or entity:read permission")
+)
+
+const (
+	// MaxContentBytes bounds the markdown of a page; schema properties are
+	// meant for short values, pages for long form text
+	MaxContentBytes = 512 << 10
+
+	// maxQueryLength bounds docs search queries
+	maxQueryLength = 200
+
+	// defaultSearchLimit and maxSearchLimit bound docs search results
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+type Service struct {
+	repo         *Repository
+	blueprintSvc *blueprint.Service
+	entitySvc    *entity.Service
+}
+
+func NewService(repo *Repository, blueprintSvc *blueprint.Service, entitySvc *entity.Service) *Service {
+	return &Service{repo: repo, blueprintSvc: blueprintSvc, entitySvc: entitySvc}
+}
+
+// BlueprintSubject returns the subject of a blueprint's page
+func (s *Service) BlueprintSubject(ctx context.Context, teamID uuid.UUID, blueprintID string) (Subject, error) {
+	_, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if errors.Is(err, blueprint.ErrNotFound) {
+		return Subject{}, ErrBlueprintNotFound
+	}
+	if err != nil {
+		return Subject{}, err
+	}
+	return Subject{TeamID: teamID, BlueprintID: blueprintID}, nil
+}
+
+// EntitySubject returns the subject of an entity's page. Entities of other
+// teams are not found.
+func (s *Service) EntitySubject(ctx context.Context, teamID, entityID uuid.UUID) (Subject, error) {
+	e, err := s.entitySvc.Get(ctx, entityID)
+	if errors.Is(err, entity.ErrNotFound) || (err == nil && e.TeamID != teamID) {
+		return Subject{}, ErrEntityNotFound
+	}
+	if err != nil {
+		return Subject{}, err
+	}
+	return Subject{TeamID: teamID, BlueprintID: e.BlueprintID, EntityID: &e.ID}, nil
+}
+
+// Get returns a subject's page at a version, or its current version when
+// version is 0
+func (s *Service) Get(ctx context.Context, subject Subject, version int) (*Doc, error) {
+	var d *Doc
+	var err error
+	if version == 0 {
+		d, err = s.repo.Get(ctx, subject)
+	} else {
+		d, err = s.repo.GetVersion(ctx, subject, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrNotFound
+	}
+	return d, nil
+}
+
+// Put saves a new version of a subject's page
+func (s *Service) Put(ctx context.Context, subject Subject, userID *uuid.UUID, req *PutDocRequest) (*Doc, error) {
+	content := strings.ReplaceAll(req.Content, "\r\n", "\n")
+	if err := validateContent(content); err != nil {
+		return nil, err
+	}
+	if req.BaseVersion < 0 {
+		return nil, fmt.Errorf("%w: base_version must not be negative", ErrInvalidDoc)
+	}
+	return s.repo.Put(ctx, subject, content, userID, req.BaseVersion)
+}
+
+// Versions returns a page of the versions of a subject's page, newest first
+func (s *Service) Versions(ctx context.Context, subject Subject, limit, offset int) (*VersionsResponse, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	versions, total, err := s.repo.Versions(ctx, subject, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, ErrNotFound
+	}
+	return &VersionsResponse{Versions: versions, Total: total, Limit: limit, Offset: offset}, nil
+}
+
+// Render returns a subject's page at a version, or its current version when
+// version is 0, rendered to HTML
+func (s *Service) Render(ctx context.Context, subject Subject, version int) (*Rendered, error) {
+	d, err := s.Get(ctx, subject, version)
+	if err != nil {
+		return nil, err
+	}
+	return &Rendered{Version: d.Version, HTML: Render(d.Content)}, nil
+}
+
+// Delete removes a subject's page with all of its versions
+func (s *Service) Delete(ctx context.Context, subject Subject) error {
+	deleted, err := s.repo.Delete(ctx, subject)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Search returns a team's pages matching a query, best match first. Callers
+// only find the blueprint pages they need blueprint:read for and the entity
+// pages they need entity:read for.
+func (s *Service) Search(ctx context.Context, teamID uuid.UUID, query string, limit int) (*SearchResponse, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("%w: q is required", ErrInvalidDoc)
+	}
+	if len(query) > maxQueryLength {
+		return nil, fmt.Errorf("%w: q must be at most %d characters", ErrInvalidDoc, maxQueryLength)
+	}
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = defaultSearchLimit
+	}
+
+	blueprints, entities := searchable(ctx)
+	if !blueprints && !entities {
+		return nil, ErrSearchForbidden
+	}
+	results, err := s.repo.Search(ctx, teamID, query, blueprints, entities, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &SearchResponse{Query: query, Results: results, Limit: limit}, nil
+}
+
+// searchable reports whether the caller behind ctx may find blueprint pages
+// and entity pages
+func searchable(ctx context.Context) (blueprints, entities bool) {
+	rc, ok := reqctx.From(ctx)
+	if !ok || rc.ActorType == reqctx.ActorSystem {
+		return true, true
+	}
+	return rc.Can(auth.PermBlueprintRead), rc.Can(auth.PermEntityRead)
+}
+
+// validateContent checks the markdown of a page
+func validateContent(content string) error {
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("%w: content is required; delete the page to remove it", ErrInvalidDoc)
+	}
+	if len(content) > MaxContentBytes {
+		return fmt.Errorf("%w: content must be at most %d bytes", ErrInvalidDoc, MaxContentBytes)
+	}
+	if !utf8.ValidString(content) || strings.ContainsRune(content, 0) {
+		return fmt.Errorf("%w: content must be UTF-8 text", ErrInvalidDoc)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package docs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+func TestValidateContent(t *testing.T) {
+	if err := validateContent("# Payments\n\nOwned by the payments team."); err != nil {
+		t.Errorf("valid content: %v", err)
+	}
+	for name, content := range map[string]string{
+		"empty":     " \n\t",
+		"too large": strings.Repeat("a", MaxContentBytes+1),
+		"not utf-8": "caf\xe9",
+		"nul byte":  "a\x00b",
+	} {
+		if err := validateContent(content); !errors.Is(err, ErrInvalidDoc) {
+			t.Errorf("%s: err = %v, want ErrInvalidDoc", name, err)
+		}
+	}
+}
+
+func TestSearchable(t *testing.T) {
+	user := func(permissions ...string) context.Context {
+		return reqctx.With(context.Background(), reqctx.RequestContext{ActorType: reqctx.ActorUser, Permissions: permissions})
+	}
+	tests := []struct {
+		name                 string
+		ctx                  context.Context
+		blueprints, entities bool
+	}{
+		{"background", context.Background(), true, true},
+		{"system", reqctx.System(context.Background()), true, true},
+		{"both", user(auth.PermBlueprintRead, auth.PermEntityRead), true, true},
+		{"blueprints only", user(auth.PermBlueprintRead), true, false},
+		{"entities only", user(auth.PermEntityRead), false, true},
+		{"neither", user(auth.PermTeamManage), false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blueprints, entities := searchable(tt.ctx)
+			if blueprints != tt.blueprints || entities != tt.entities {
+				t.Errorf("searchable() = %v, %v, want %v, %v", blueprints, entities, tt.blueprints, tt.entities)
+			}
+		})
+	}
+}
@@ -0,0 +1,420 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/validation"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+// catalogEntry is one row of a catalog import as it is checked and applied
+type catalogEntry struct {
+	importRow
+	blueprint string
+	// targets are the identifiers the row links to, by relation identifier
+	targets map[string][]string
+	// links are the same targets resolved to their relations, as entity keys
+	links map[*Relation][]string
+	// deps are the rows of the file the row links to
+	deps []catalogDep
+	// current is the existing entity; entity is what the row writes, nil
+	// when it changes nothing
+	current *Entity
+	entity  *Entity
+}
+
+type catalogDep struct {
+	entry    int
+	relation string
+	key      string
+}
+
+// entityKey names an entity across blueprints, as "<blueprint>/<identifier>"
+func entityKey(blueprintID, identifier string) string {
+	return blueprintID + "/" + identifier
+}
+
+// parseCatalogImport reads one CatalogImportRow per line. Blank lines are skipped.
+func parseCatalogImport(r io.Reader) ([]*catalogEntry, error) {
+	var entries []*catalogEntry
+	err := scanNDJSON(r, func(n int, line []byte) {
+		e := &catalogEntry{importRow: importRow{row: n}}
+		var req CatalogImportRow
+		if err := json.Unmarshal(line, &req); err != nil {
+			e.err = fmt.Errorf("invalid JSON: %v", err)
+		} else {
+			e.blueprint = strings.TrimSpace(req.Blueprint)
+			e.identifier = strings.TrimSpace(req.Identifier)
+			e.title = req.Title
+			e.data = req.Data
+			if e.data == nil {
+				e.data = map[string]interface{}{}
+			}
+			e.targets, e.err = parseRelationTargets(req.Relations)
+		}
+		entries = append(entries, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseRelationTargets reads the relations of a row: an identifier or a list
+// of identifiers each. An empty list clears the relation.
+func parseRelationTargets(relations map[string]json.RawMessage) (map[string][]string, error) {
+	targets := make(map[string][]string, len(relations))
+	for name, raw := range relations {
+		var one string
+		if err := json.Unmarshal(raw, &one); err == nil {
+			targets[name] = []string{one}
+			continue
+		}
+		var many []string
+		if err := json.Unmarshal(raw, &many); err != nil {
+			return nil, fmt.Errorf("relation %q: targets must be an identifier or a list of identifiers", name)
+		}
+		targets[name] = many
+	}
+	for name, ids := range targets {
+		for i, id := range ids {
+			ids[i] = strings.TrimSpace(id)
+			if ids[i] == "" {
+				return nil, fmt.Errorf("relation %q: target identifiers must not be empty", name)
+			}
+		}
+		targets[name] = slices.Compact(slices.Sorted(slices.Values(ids)))
+	}
+	return targets, nil
+}
+
+// ImportCatalog creates, and in upsert mode updates, entities of any of the
+// team's blueprints from an NDJSON file, and sets the relations each row
+// links by. Relation targets are entities of the file or of the catalog,
+// named by identifier, so rows may come in any order.
+//
+// The whole file is checked before anything is written: rows that are
+// invalid, and rows linking to them, are reported and skipped. The other rows
+// are then written targets first, and their relations set once every entity
+// exists, so cycles between rows import too.
+func (s *Service) ImportCatalog(ctx context.Context, teamID uuid.UUID, format string, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if err := opts.normalize(); err != nil {
+		return nil, err
+	}
+	if format != FormatNDJSON {
+		return nil, fmt.Errorf("%w: %q, catalog imports are NDJSON", ErrUnsupportedFormat, format)
+	}
+
+	entries, err := parseCatalogImport(r)
+	if err != nil {
+		return nil, err
+	}
+	relations, err := s.repo.ListRelations(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*Relation, len(relations))
+	for _, rel := range relations {
+		byKey[entityKey(rel.Source, rel.Identifier)] = rel
+	}
+
+	// Phase 1: check every row and resolve what it links to
+	blueprints := map[string]*blueprint.Blueprint{}
+	lookups := map[string]map[string]bool{}
+	lookup := func(blueprintID, identifier string) {
+		if lookups[blueprintID] == nil {
+			lookups[blueprintID] = map[string]bool{}
+		}
+		lookups[blueprintID][identifier] = true
+	}
+	for _, e := range entries {
+		if e.err != nil {
+			continue
+		}
+		if e.blueprint == "" {
+			e.err = errors.New("blueprint is required")
+			continue
+		}
+		if _, ok := blueprints[e.blueprint]; !ok {
+			bp, err := s.blueprintSvc.Get(ctx, teamID, e.blueprint)
+			if err != nil && !errors.Is(err, blueprint.ErrNotFound) {
+				return nil, err
+			}
+			blueprints[e.blueprint] = bp
+		}
+		if blueprints[e.blueprint] == nil {
+			e.err = ErrBlueprintNotFound
+			continue
+		}
+		if e.identifier == "" {
+			e.err = errors.New("identifier is required")
+			continue
+		}
+		lookup(e.blueprint, e.identifier)
+
+		e.links = make(map[*Relation][]string, len(e.targets))
+		for _, name := range slices.Sorted(maps.Keys(e.targets)) {
+			ids := e.targets[name]
+			rel := byKey[entityKey(e.blueprint, name)]
+			if rel == nil {
+				e.err = fmt.Errorf("unknown relation %q", name)
+				break
+			}
+			if rel.SingleTarget() && len(ids) > 1 {
+				e.err = fmt.Errorf("relation %q links to one entity at most", name)
+				break
+			}
+			keys := make([]string, len(ids))
+			for i, id := range ids {
+				keys[i] = entityKey(rel.Target, id)
+				lookup(rel.Target, id)
+			}
+			e.links[rel] = keys
+		}
+	}
+
+	existing := map[string]*Entity{}
+	for blueprintID, identifiers := range lookups {
+		found, err := s.repo.ListByIdentifiers(ctx, teamID, blueprintID, slices.Collect(maps.Keys(identifiers)))
+		if err != nil {
+			return nil, err
+		}
+		for identifier, entity := range found {
+			existing[entityKey(blueprintID, identifier)] = entity
+		}
+	}
+
+	source := writeSource(ctx)
+	inFile := map[string]int{}
+	for i, e := range entries {
+		key := entityKey(e.blueprint, e.identifier)
+		if e.blueprint != "" && e.identifier != "" {
+			if first, ok := inFile[key]; ok {
+				if e.err == nil {
+					e.err = fmt.Errorf("duplicate entity, first used in row %d", entries[first].row)
+				}
+				continue
+			}
+			inFile[key] = i
+		}
+		if e.err != nil {
+			continue
+		}
+		bp := blueprints[e.blueprint]
+		e.current = existing[key]
+		if e.current == nil {
+			if missing := missingRequired(relations, bp.ID, e.links); missing != "" {
+				e.err = fmt.Errorf("relation %q is required", missing)
+				continue
+			}
+		}
+		e.entity, e.err = s.planImportRow(ctx, bp, e.importRow, e.current, opts, source, hiddenProperties(ctx, bp))
+	}
+
+	for i, e := range entries {
+		if e.err != nil {
+			continue
+		}
+	resolve:
+		for _, rel := range sortedRelations(e.links) {
+			for _, key := range e.links[rel] {
+				if j, ok := inFile[key]; ok {
+					if j != i {
+						e.deps = append(e.deps, catalogDep{entry: j, relation: rel.Identifier, key: key})
+					}
+				} else if existing[key] == nil {
+					e.err = fmt.Errorf("relation %q: %s does not exist", rel.Identifier, key)
+					break resolve
+				}
+			}
+		}
+	}
+	// A row linking to a failed row fails too, and so on down the chain
+	for changed := true; changed; {
+		changed = false
+		for _, e := range entries {
+			if e.err == nil {
+				if err := failedDep(entries, e); err != nil {
+					e.err, changed = err, true
+				}
+			}
+		}
+	}
+
+	result := &ImportResult{DryRun: opts.DryRun, Mode: opts.Mode, Total: len(entries), Errors: []ImportRowError{}}
+	fail := func(e *catalogEntry, err error) {
+		rowErr := ImportRowError{Row: e.row, Blueprint: e.blueprint, Identifier: e.identifier, Error: err.Error()}
+		if ve := validation.GetValidationErrors(err); ve != nil {
+			rowErr.Error = ErrValidation.Error()
+			rowErr.Details = ve.Errors
+		}
+		result.Errors = append(result.Errors, rowErr)
+		result.Failed++
+	}
+	count := func(e *catalogEntry) {
+		switch {
+		case e.entity == nil:
+			result.Unchanged++
+		case e.current == nil:
+			result.Created++
+		default:
+			result.Updated++
+		}
+	}
+
+	if opts.DryRun {
+		for _, e := range entries {
+			if e.err != nil {
+				fail(e, e.err)
+				continue
+			}
+			count(e)
+			if e.entity != nil {
+				result.Changes = append(result.Changes, importChange(e.row, e.blueprint, e.current, e.entity, hiddenProperties(ctx, blueprints[e.blueprint])))
+			}
+			for _, keys := range e.links {
+				result.Relations += len(keys)
+			}
+		}
+		return result, nil
+	}
+
+	// Phase 2: write the entities, targets first, then their relations
+	ids := make(map[string]uuid.UUID, len(existing))
+	for key, entity := range existing {
+		ids[key] = entity.ID
+	}
+	order := catalogOrder(entries)
+	for _, i := range order {
+		e := entries[i]
+		if e.err == nil {
+			e.err = failedDep(entries, e)
+		}
+		if e.err == nil {
+			e.err = s.writeCatalogEntry(ctx, e)
+		}
+		if e.err != nil {
+			continue
+		}
+		count(e)
+		if e.entity != nil {
+			ids[entityKey(e.blueprint, e.identifier)] = e.entity.ID
+		}
+	}
+	for _, e := range entries {
+		if e.err != nil {
+			fail(e, e.err)
+		}
+	}
+
+	for _, i := range order {
+		e := entries[i]
+		if e.err != nil {
+			continue
+		}
+		sourceID := ids[entityKey(e.blueprint, e.identifier)]
+		for _, rel := range sortedRelations(e.links) {
+			if err := failedDep(entries, e); err != nil {
+				// Only rows of a cycle get here with a target that failed
+				result.Errors = append(result.Errors, ImportRowError{Row: e.row, Blueprint: e.blueprint, Identifier: e.identifier, Error: "relations not set: " + err.Error()})
+				break
+			}
+			targetIDs := make([]uuid.UUID, len(e.links[rel]))
+			for j, key := range e.links[rel] {
+				targetIDs[j] = ids[key]
+			}
+			if err := s.repo.SetRelations(ctx, rel.ID, sourceID, targetIDs); err != nil {
+				return nil, err
+			}
+			result.Relations += len(targetIDs)
+		}
+	}
+
+	slices.SortStableFunc(result.Errors, func(a, b ImportRowError) int { return a.Row - b.Row })
+	return result, nil
+}
+
+// writeCatalogEntry creates or updates the entity of a row
+func (s *Service) writeCatalogEntry(ctx context.Context, e *catalogEntry) error {
+	switch {
+	case e.entity == nil:
+		return nil
+	case e.current == nil:
+		if err := s.repo.Create(ctx, e.entity); err != nil {
+			return err
+		}
+		s.publish(ctx, events.EntityCreated, e.entity, nil)
+	default:
+		if err := s.repo.Update(ctx, e.entity); err != nil {
+			return err
+		}
+		s.publish(ctx, events.EntityUpdated, e.entity, e.current)
+	}
+	return nil
+}
+
+// failedDep returns the error of a row linking to a row that failed
+func failedDep(entries []*catalogEntry, e *catalogEntry) error {
+	for _, d := range e.deps {
+		if target := entries[d.entry]; target.err != nil {
+			return fmt.Errorf("relation %q: %s failed in row %d", d.relation, d.key, target.row)
+		}
+	}
+	return nil
+}
+
+// missingRequired returns the identifier of a required relation of a
+// blueprint that links is missing, or "" when none is
+func missingRequired(relations []*Relation, blueprintID string, links map[*Relation][]string) string {
+	for _, rel := range relations {
+		if rel.Source == blueprintID && rel.Required && len(links[rel]) == 0 {
+			return rel.Identifier
+		}
+	}
+	return ""
+}
+
+// catalogOrder returns the indexes of the entries with the rows each one
+// links to first, otherwise in file order. Rows of a cycle keep file order.
+func catalogOrder(entries []*catalogEntry) []int {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make([]int, len(entries))
+	order := make([]int, 0, len(entries))
+	var visit func(i int)
+	visit = func(i int) {
+		if state[i] != unvisited {
+			return
+		}
+		state[i] = visiting
+		for _, d := range entries[i].deps {
+			visit(d.entry)
+		}
+		state[i] = done
+		order = append(order, i)
+	}
+	for i := range entries {
+		visit(i)
+	}
+	return order
+}
+
+// sortedRelations returns the relations of links by identifier
+func sortedRelations(links map[*Relation][]string) []*Relation {
+	return slices.SortedFunc(maps.Keys(links), func(a, b *Relation) int {
+		return strings.Compare(a.Identifier, b.Identifier)
+	})
+}
@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCatalogImport(t *testing.T) {
+	input := `{"blueprint": "service", "identifier": "payments", "data": {"tier": 1}, "relations": {"owner": "platform", "depends_on": ["ledger", "auth", "ledger"]}}
+
+{"blueprint": "team", "identifier": "platform"}
+{"blueprint": "service", "identifier": "ledger", "relations": {"owner": 42}}
+{"blueprint": "service", "identifier": "auth", "relations": {"depends_on": [""]}}
+not json
+`
+	entries, err := parseCatalogImport(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("entries = %d, want 5", len(entries))
+	}
+
+	first := entries[0]
+	want := map[string][]string{"owner": {"platform"}, "depends_on": {"auth", "ledger"}}
+	if first.err != nil || first.blueprint != "service" || first.identifier != "payments" || !reflect.DeepEqual(first.targets, want) {
+		t.Errorf("first entry = %+v", first)
+	}
+	if entries[1].row != 3 || entries[1].err != nil || entries[1].data == nil {
+		t.Errorf("second entry = %+v, want row 3 with empty data", entries[1])
+	}
+	for _, i := range []int{2, 3, 4} {
+		if entries[i].err == nil {
+			t.Errorf("entry in row %d parsed, want an error", entries[i].row)
+		}
+	}
+
+	if _, err := parseCatalogImport(strings.NewReader("\n\n")); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("empty file: err = %v, want ErrInvalidImport", err)
+	}
+}
+
+func TestCatalogOrder(t *testing.T) {
+	// 0 links to 2, 2 links to 1; 3 and 4 link to each other
+	entries := []*catalogEntry{
+		{deps: []catalogDep{{entry: 2}}},
+		{},
+		{deps: []catalogDep{{entry: 1}}},
+		{deps: []catalogDep{{entry: 4}}},
+		{deps: []catalogDep{{entry: 3}}},
+	}
+	if got, want := catalogOrder(entries), []int{1, 2, 0, 4, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("catalogOrder() = %v, want %v", got, want)
+	}
+}
+
+func TestFailedDep(t *testing.T) {
+	entries := []*catalogEntry{
+		{importRow: importRow{row: 1, err: errors.New("invalid")}},
+		{importRow: importRow{row: 2}, deps: []catalogDep{{entry: 0, relation: "owner", key: "team/platform"}}},
+	}
+	err := failedDep(entries, entries[1])
+	if err == nil || err.Error() != `relation "owner": team/platform failed in row 1` {
+		t.Errorf("failedDep() = %v", err)
+	}
+
+	rel := &Relation{Identifier: "owner", Source: "service", Required: true}
+	if got := missingRequired([]*Relation{rel}, "service", nil); got != "owner" {
+		t.Errorf("missingRequired() = %q, want owner", got)
+	}
+	if got := missingRequired([]*Relation{rel}, "service", map[*Relation][]string{rel: {"team/platform"}}); got != "" {
+		t.Errorf("missingRequired() = %q, want none", got)
+	}
+}
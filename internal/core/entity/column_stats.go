@@ -0,0 +1,301 @@
+package entity
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+const (
+	// DefaultColumnStatsSample and MaxColumnStatsSample bound how many
+	// entities column statistics are computed from
+	DefaultColumnStatsSample = 10000
+	MaxColumnStatsSample     = 100000
+
+	// DefaultColumnStatsTop and MaxColumnStatsTop bound the most common
+	// values reported per property
+	DefaultColumnStatsTop = 5
+	MaxColumnStatsTop     = 50
+
+	// maxColumnStatsProperties bounds the properties summarized in one pass
+	maxColumnStatsProperties = 100
+
+	// columnStatsUsageDays is the window of the usage counts in column statistics
+	columnStatsUsageDays = 30
+
+	// lowCardinalityMax is the most distinct values a low-cardinality property has
+	lowCardinalityMax = 20
+
+	// uniqueRatio is the distinct ratio from which a property counts as unique
+	uniqueRatio = 0.95
+
+	// skewedFraction is the share of entities the most common value of an
+	// indexed property may have before equality filters on it stop benefiting
+	// from the index
+	skewedFraction = 0.5
+)
+
+// Cardinalities of a property in column statistics
+const (
+	CardinalityNone     = "none"
+	CardinalityConstant = "constant"
+	CardinalityLow      = "low"
+	CardinalityHigh     = "high"
+	CardinalityUnique   = "unique"
+)
+
+// HintLowSelectivity marks an indexed property whose most common value most
+// entities have, so the index rarely narrows a search
+const HintLowSelectivity = "low_selectivity"
+
+// columnStatsRow is one of the most common values of a property with the
+// property's totals over the sample
+type columnStatsRow struct {
+	Property string
+	Distinct int64
+	NonNull  int64
+	Min      sql.NullFloat64
+	Max      sql.NullFloat64
+	Value    []byte
+	Count    int64
+}
+
+// ColumnStats summarizes the entities of a blueprint for query planning:
+// how many there are and, per property, how selective a filter on it is.
+// Statistics are computed from a sample of at most sample entities with the
+// top most common values of each property. Schema properties are reported
+// with the properties searches used over the last 30 days, most used first.
+func (s *Service) ColumnStats(ctx context.Context, teamID uuid.UUID, blueprintID string, sample, top int) (*ColumnStatsResponse, error) {
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
+		return nil, err
+	}
+	if sample <= 0 {
+		sample = DefaultColumnStatsSample
+	}
+	sample = min(sample, MaxColumnStatsSample)
+	if top <= 0 {
+		top = DefaultColumnStatsTop
+	}
+	top = min(top, MaxColumnStatsTop)
+
+	resp := &ColumnStatsResponse{BlueprintID: blueprintID, GeneratedAt: time.Now().UTC()}
+	var usage []UsageRow
+	if s.usage != nil {
+		resp.UsageDays = min(columnStatsUsageDays, int(s.usage.retention/(24*time.Hour)))
+		since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-resp.UsageDays)
+		if usage, err = s.repo.PropertyUsage(ctx, teamID, blueprintID, since); err != nil {
+			return nil, err
+		}
+	}
+
+	properties := columnStatsProperties(bp.Schema, usage, s.usage != nil, hiddenProperties(ctx, bp))
+	total, rows, err := s.repo.ColumnStats(ctx, teamID, blueprintID, columnStatsPaths(properties), sample, top)
+	if err != nil {
+		return nil, err
+	}
+	resp.Entities = total
+	resp.Sampled = min(total, int64(sample))
+	resp.Exact = resp.Sampled == total
+	resp.Properties = summarizeColumns(properties, rows, resp.Sampled)
+	return resp, nil
+}
+
+// columnStatsProperties lists the schema's properties and the properties
+// searches used, most filtered and sorted on first, with their usage counts
+// when usage is tracked. Hidden properties are left out.
+func columnStatsProperties(schema map[string]interface{}, usage []UsageRow, tracked bool, hidden []string) []*ColumnStats {
+	report := buildUsageReport(schema, usage)
+	fc := NewFilterCompiler(schema)
+	properties := make([]*ColumnStats, 0, len(report))
+	for _, pu := range report {
+		top, _, _ := strings.Cut(pu.Property, ".")
+		if slices.Contains(hidden, top) {
+			continue
+		}
+		prop, err := fc.Property(pu.Property)
+		if err != nil && !errors.Is(err, errUnknownProperty) {
+			// Recorded from a search that failed validation
+			continue
+		}
+		cs := &ColumnStats{Property: pu.Property, InSchema: pu.InSchema, Indexed: pu.Indexed}
+		if prop != nil {
+			cs.Type = schemaType(prop)
+		}
+		if tracked {
+			uses := pu.Filter + pu.Sort
+			cs.Uses = &uses
+		}
+		properties = append(properties, cs)
+	}
+	sort.SliceStable(properties, func(i, j int) bool {
+		return properties[i].uses() > properties[j].uses()
+	})
+	if len(properties) > maxColumnStatsProperties {
+		properties = properties[:maxColumnStatsProperties]
+	}
+	return properties
+}
+
+func columnStatsPaths(properties []*ColumnStats) []string {
+	paths := make([]string, len(properties))
+	for i, cs := range properties {
+		paths[i] = cs.Property
+	}
+	return paths
+}
+
+// summarizeColumns fills in the statistics of each property from its most
+// common values over sampled entities
+func summarizeColumns(properties []*ColumnStats, rows []columnStatsRow, sampled int64) []*ColumnStats {
+	byProperty := make(map[string]*ColumnStats, len(properties))
+	for _, cs := range properties {
+		cs.TopValues = []ValueCount{}
+		byProperty[cs.Property] = cs
+	}
+
+	nonNull := make(map[string]int64)
+	for _, row := range rows {
+		cs, ok := byProperty[row.Property]
+		if !ok {
+			continue
+		}
+		cs.Distinct = row.Distinct
+		nonNull[row.Property] = row.NonNull
+		if row.Min.Valid {
+			cs.Min = &row.Min.Float64
+		}
+		if row.Max.Valid {
+			cs.Max = &row.Max.Float64
+		}
+		var value interface{}
+		if err := json.Unmarshal(row.Value, &value); err != nil {
+			continue
+		}
+		cs.TopValues = append(cs.TopValues, ValueCount{Value: value, Count: row.Count, Fraction: fraction(row.Count, sampled)})
+	}
+
+	for _, cs := range properties {
+		n := nonNull[cs.Property]
+		cs.NullFraction = 1 - fraction(n, sampled)
+		if sampled == 0 {
+			cs.NullFraction = 0
+		}
+		cs.DistinctRatio = fraction(cs.Distinct, n)
+		cs.Cardinality = cardinality(cs.Distinct, cs.DistinctRatio)
+		cs.Hint = columnHint(cs)
+	}
+	return properties
+}
+
+func cardinality(distinct int64, ratio float64) string {
+	switch {
+	case distinct == 0:
+		return CardinalityNone
+	case distinct == 1:
+		return CardinalityConstant
+	case ratio >= uniqueRatio && distinct > lowCardinalityMax:
+		return CardinalityUnique
+	case distinct <= lowCardinalityMax:
+		return CardinalityLow
+	}
+	return CardinalityHigh
+}
+
+// columnHint suggests indexing a much-searched selective property, and
+// flags indexes on properties most entities share a value of
+func columnHint(cs *ColumnStats) string {
+	selective := cs.Cardinality == CardinalityHigh || cs.Cardinality == CardinalityUnique
+	switch {
+	case !cs.Indexed && cs.InSchema && selective && cs.uses() >= usageIndexThreshold:
+		return HintIndex
+	case cs.Indexed && len(cs.TopValues) > 0 && cs.TopValues[0].Fraction >= skewedFraction:
+		return HintLowSelectivity
+	}
+	return ""
+}
+
+func (cs *ColumnStats) uses() int64 {
+	if cs.Uses == nil {
+		return 0
+	}
+	return *cs.Uses
+}
+
+func fraction(n, of int64) float64 {
+	if of == 0 {
+		return 0
+	}
+	return float64(n) / float64(of)
+}
+
+// ColumnStats counts a blueprint's entities and returns, for each path, the
+// top most common non-null values over a sample of at most sample entities
+// with the path's distinct values, non-null count and numeric range. Entity
+// IDs are random, so the first entities by ID are a uniform sample. Arrays
+// and objects are compared whole.
+func (r *Repository) ColumnStats(ctx context.Context, teamID uuid.UUID, blueprintID string, paths []string, sample, top int) (int64, []columnStatsRow, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var total int64
+	countQuery := `SELECT COUNT(*) FROM entities WHERE team_id = $1 AND blueprint_id = $2`
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, countQuery, teamID, blueprintID).Scan(&total); err != nil {
+		return 0, nil, err
+	}
+	if total == 0 || len(paths) == 0 {
+		return total, nil, nil
+	}
+
+	query := `
+		WITH sample AS (
+			SELECT data FROM entities
+			WHERE team_id = $1 AND blueprint_id = $2
+			ORDER BY id
+			LIMIT $3
+		), pairs AS (
+			SELECT p.path, s.data #> string_to_array(p.path, '.') AS value, COUNT(*) AS n
+			FROM sample s CROSS JOIN unnest($4::text[]) AS p(path)
+			GROUP BY 1, 2
+		), ranked AS (
+			SELECT path, value, n,
+				ROW_NUMBER() OVER (PARTITION BY path ORDER BY n DESC, value) AS rank,
+				COUNT(*) OVER (PARTITION BY path) AS distinct_values,
+				SUM(n) OVER (PARTITION BY path) AS non_null,
+				MIN(CASE WHEN jsonb_typeof(value) = 'number' THEN (value #>> '{}')::float8 END) OVER (PARTITION BY path) AS min_value,
+				MAX(CASE WHEN jsonb_typeof(value) = 'number' THEN (value #>> '{}')::float8 END) OVER (PARTITION BY path) AS max_value
+			FROM pairs
+			WHERE value IS NOT NULL AND jsonb_typeof(value) <> 'null'
+		)
+		SELECT path, distinct_values, non_null, min_value, max_value, value, n
+		FROM ranked
+		WHERE rank <= $5
+		ORDER BY path, rank`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, blueprintID, sample, paths, top)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var stats []columnStatsRow
+	for rows.Next() {
+		var row columnStatsRow
+		if err := rows.Scan(&row.Property, &row.Distinct, &row.NonNull, &row.Min, &row.Max, &row.Value, &row.Count); err != nil {
+			return 0, nil, err
+		}
+		stats = append(stats, row)
+	}
+	return total, stats, rows.Err()
+}
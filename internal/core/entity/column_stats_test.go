@@ -0,0 +1,128 @@
+package entity
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestColumnStatsProperties(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"lifecycle": map[string]interface{}{"type": "string", "indexed": true},
+			"owner":     map[string]interface{}{"type": "string"},
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tier": map[string]interface{}{"type": "integer"},
+				},
+			},
+		},
+	}
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	rows := []UsageRow{
+		{Property: "owner", Usage: UsageFilter, Count: 150, Day: day},
+		{Property: "lifecycle", Usage: UsageColumn, Count: 500, Day: day},
+		{Property: "removed", Usage: UsageSort, Count: 3, Day: day},
+		{Property: "bad path", Usage: UsageFilter, Count: 9, Day: day},
+	}
+
+	properties := columnStatsProperties(schema, rows, true, nil)
+
+	want := []struct {
+		property string
+		typ      string
+		uses     int64
+		inSchema bool
+	}{
+		{"owner", "string", 150, true},
+		{"removed", "", 3, false},
+		{"lifecycle", "string", 0, true},
+		{"metadata.tier", "integer", 0, true},
+	}
+	if len(properties) != len(want) {
+		t.Fatalf("got %d properties, want %d", len(properties), len(want))
+	}
+	for i, w := range want {
+		got := properties[i]
+		if got.Property != w.property || got.Type != w.typ || got.uses() != w.uses || got.InSchema != w.inSchema {
+			t.Errorf("properties[%d] = %s %q uses %d in schema %v, want %s %q uses %d in schema %v",
+				i, got.Property, got.Type, got.uses(), got.InSchema, w.property, w.typ, w.uses, w.inSchema)
+		}
+	}
+
+	if untracked := columnStatsProperties(schema, nil, false, nil); untracked[0].Uses != nil {
+		t.Errorf("uses reported without usage tracking")
+	}
+	for _, cs := range columnStatsProperties(schema, rows, true, []string{"metadata"}) {
+		if cs.Property == "metadata.tier" {
+			t.Errorf("hidden property %s reported", cs.Property)
+		}
+	}
+}
+
+func TestSummarizeColumns(t *testing.T) {
+	uses := func(n int64) *int64 { return &n }
+	properties := []*ColumnStats{
+		{Property: "owner", InSchema: true, Uses: uses(150)},
+		{Property: "lifecycle", InSchema: true, Indexed: true, Uses: uses(0)},
+		{Property: "metadata.tier", InSchema: true, Uses: uses(0)},
+		{Property: "deprecated", InSchema: true, Uses: uses(0)},
+	}
+	rows := []columnStatsRow{
+		{Property: "owner", Distinct: 90, NonNull: 95, Value: []byte(`"team-a"`), Count: 3},
+		{Property: "lifecycle", Distinct: 3, NonNull: 100, Value: []byte(`"production"`), Count: 70},
+		{Property: "lifecycle", Distinct: 3, NonNull: 100, Value: []byte(`"staging"`), Count: 20},
+		{Property: "metadata.tier", Distinct: 1, NonNull: 40, Value: []byte(`2`), Count: 40,
+			Min: sql.NullFloat64{Float64: 2, Valid: true}, Max: sql.NullFloat64{Float64: 2, Valid: true}},
+	}
+
+	summary := summarizeColumns(properties, rows, 100)
+
+	want := []struct {
+		nullFraction float64
+		cardinality  string
+		hint         string
+		top          int
+	}{
+		{0.05, CardinalityHigh, HintIndex, 1},
+		{0, CardinalityLow, HintLowSelectivity, 2},
+		{0.6, CardinalityConstant, "", 1},
+		{1, CardinalityNone, "", 0},
+	}
+	for i, w := range want {
+		got := summary[i]
+		if diff := got.NullFraction - w.nullFraction; diff > 1e-9 || diff < -1e-9 {
+			t.Errorf("%s: null fraction = %v, want %v", got.Property, got.NullFraction, w.nullFraction)
+		}
+		if got.Cardinality != w.cardinality || got.Hint != w.hint || len(got.TopValues) != w.top {
+			t.Errorf("%s: cardinality %q hint %q %d top values, want %q %q %d",
+				got.Property, got.Cardinality, got.Hint, len(got.TopValues), w.cardinality, w.hint, w.top)
+		}
+	}
+	if top := summary[1].TopValues[0]; top.Value != "production" || top.Fraction != 0.7 {
+		t.Errorf("lifecycle top value = %v (%v), want production (0.7)", top.Value, top.Fraction)
+	}
+	if tier := summary[2]; tier.Min == nil || *tier.Min != 2 || tier.TopValues[0].Value != float64(2) {
+		t.Errorf("metadata.tier min %v top %v", tier.Min, tier.TopValues)
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	tests := []struct {
+		distinct int64
+		ratio    float64
+		want     string
+	}{
+		{0, 0, CardinalityNone},
+		{1, 0.01, CardinalityConstant},
+		{12, 1, CardinalityLow},
+		{500, 0.5, CardinalityHigh},
+		{9800, 0.98, CardinalityUnique},
+	}
+	for _, tt := range tests {
+		if got := cardinality(tt.distinct, tt.ratio); got != tt.want {
+			t.Errorf("cardinality(%d, %v) = %q, want %q", tt.distinct, tt.ratio, got, tt.want)
+		}
+	}
+}
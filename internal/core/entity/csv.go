@@ -0,0 +1,319 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSV layout shared by the import template and CSV import:
+//   - the first two columns are always "identifier" and "title"
+//   - every schema property gets a column; nested object properties are
+//     flattened with dot notation ("metadata.owner")
+//   - arrays of scalars are written as values separated by CSVListSeparator
+//   - objects without declared properties and arrays of objects are JSON-encoded,
+//     as are lists whose items contain the separator or are not scalars
+//   - an empty cell leaves the property unset
+
+// CSVListSeparator separates array items within a single CSV cell
+const CSVListSeparator = ";"
+
+// CSVColumn maps a CSV header to a property in entity data
+type CSVColumn struct {
+	Header   string
+	Path     []string
+	Type     string
+	Format   string
+	Required bool
+	schema   map[string]interface{}
+}
+
+// CSVColumns derives the CSV columns for a blueprint schema. Required properties
+// come first in schema order, followed by the remaining properties alphabetically.
+func CSVColumns(schema map[string]interface{}) []CSVColumn {
+	columns := []CSVColumn{
+		{Header: "identifier", Type: "string", Required: true},
+		{Header: "title", Type: "string"},
+	}
+	return append(columns, objectColumns(schema, nil)...)
+}
+
+func objectColumns(schema map[string]interface{}, prefix []string) []CSVColumn {
+	props, _ := schema["properties"].(map[string]interface{})
+	if len(props) == 0 {
+		return nil
+	}
+
+	required := map[string]bool{}
+	var order []string
+	for _, name := range stringList(schema["required"]) {
+		if _, ok := props[name]; ok && !required[name] {
+			required[name] = true
+			order = append(order, name)
+		}
+	}
+	var rest []string
+	for name := range props {
+		if !required[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	order = append(order, rest...)
+
+	var columns []CSVColumn
+	for _, name := range order {
+		prop, _ := props[name].(map[string]interface{})
+		path := append(append([]string{}, prefix...), name)
+		propType, _ := prop["type"].(string)
+
+		if propType == "object" {
+			if nested := objectColumns(prop, path); len(nested) > 0 {
+				if !required[name] {
+					// A nested field is only mandatory when its parent is
+					for i := range nested {
+						nested[i].Required = false
+					}
+				}
+				columns = append(columns, nested...)
+				continue
+			}
+		}
+
+		format, _ := prop["format"].(string)
+		columns = append(columns, CSVColumn{
+			Header:   strings.Join(path, "."),
+			Path:     path,
+			Type:     propType,
+			Format:   format,
+			Required: required[name],
+			schema:   prop,
+		})
+	}
+	return columns
+}
+
+// CSVTemplate returns the header row and, when withExample is set, an example
+// row with plausible values for every column.
+func CSVTemplate(schema map[string]interface{}, withExample bool) [][]string {
+	columns := CSVColumns(schema)
+	header := make([]string, len(columns))
+	example := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Header
+		example[i] = col.exampleValue()
+	}
+	if !withExample {
+		return [][]string{header}
+	}
+	return [][]string{header, example}
+}
+
+func (col CSVColumn) exampleValue() string {
+	// identifier and title are entity fields rather than schema properties
+	if col.Path == nil {
+		if col.Header == "identifier" {
+			return "my-entity"
+		}
+		return "My Entity"
+	}
+
+	if examples, ok := col.schema["examples"].([]interface{}); ok && len(examples) > 0 {
+		return formatCSVValue(examples[0])
+	}
+	if def, ok := col.schema["default"]; ok {
+		return formatCSVValue(def)
+	}
+	if enum, ok := col.schema["enum"].([]interface{}); ok && len(enum) > 0 {
+		return formatCSVValue(enum[0])
+	}
+
+	switch col.Type {
+	case "integer":
+		return "1"
+	case "number":
+		return "1.5"
+	case "boolean":
+		return "true"
+	case "array":
+		items, _ := col.schema["items"].(map[string]interface{})
+		if itemType, _ := items["type"].(string); itemType == "object" {
+			return `[{"key":"value"}]`
+		}
+		return "first" + CSVListSeparator + "second"
+	case "object":
+		return `{"key":"value"}`
+	}
+
+	switch col.Format {
+	case "date-time":
+		return "2024-01-15T10:30:00Z"
+	case "date":
+		return "2024-01-15"
+	case "email":
+		return "owner@example.com"
+	case "uri", "url":
+		return "https://example.com"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	}
+	return "example"
+}
+
+// CSVRecord renders an entity as a CSV row for the given columns. Data
+// outside the columns is not exported.
+func CSVRecord(columns []CSVColumn, e *Entity) []string {
+	record := make([]string, len(columns))
+	for i, col := range columns {
+		switch {
+		case col.Path == nil && col.Header == "identifier":
+			record[i] = e.Identifier
+		case col.Path == nil:
+			record[i] = e.Title
+		default:
+			if v, ok := lookupPath(e.Data, col.Path); ok {
+				record[i] = formatCSVCell(v)
+			}
+		}
+	}
+	return record
+}
+
+// formatCSVCell is formatCSVValue, except that lists which would not survive
+// splitting on CSVListSeparator are JSON-encoded
+func formatCSVCell(v interface{}) string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return formatCSVValue(v)
+	}
+	for _, item := range list {
+		switch val := item.(type) {
+		case string:
+			if val == "" || strings.Contains(val, CSVListSeparator) || strings.HasPrefix(val, "[") {
+				encoded, _ := json.Marshal(v)
+				return string(encoded)
+			}
+		case float64, bool:
+		default:
+			encoded, _ := json.Marshal(v)
+			return string(encoded)
+		}
+	}
+	return formatCSVValue(v)
+}
+
+// parse converts a CSV cell into a value for the column's property
+func (col CSVColumn) parse(cell string) (interface{}, error) {
+	switch col.Type {
+	case "integer":
+		n, err := strconv.ParseInt(cell, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not an integer", col.Header, cell)
+		}
+		return float64(n), nil
+	case "number":
+		n, err := strconv.ParseFloat(cell, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a number", col.Header, cell)
+		}
+		return n, nil
+	case "boolean":
+		b, err := strconv.ParseBool(cell)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a boolean", col.Header, cell)
+		}
+		return b, nil
+	case "object":
+		var v map[string]interface{}
+		if err := json.Unmarshal([]byte(cell), &v); err != nil {
+			return nil, fmt.Errorf("%s: invalid JSON object: %v", col.Header, err)
+		}
+		return v, nil
+	case "array":
+		if strings.HasPrefix(cell, "[") {
+			var v []interface{}
+			if err := json.Unmarshal([]byte(cell), &v); err != nil {
+				return nil, fmt.Errorf("%s: invalid JSON array: %v", col.Header, err)
+			}
+			return v, nil
+		}
+		items, _ := col.schema["items"].(map[string]interface{})
+		itemType, _ := items["type"].(string)
+		item := CSVColumn{Header: col.Header, Type: itemType}
+		parts := strings.Split(cell, CSVListSeparator)
+		list := make([]interface{}, len(parts))
+		for i, part := range parts {
+			v, err := item.parse(strings.TrimSpace(part))
+			if err != nil {
+				return nil, err
+			}
+			list[i] = v
+		}
+		return list, nil
+	}
+	return cell, nil
+}
+
+func lookupPath(data map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = data
+	for _, key := range path {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+func setPath(data map[string]interface{}, path []string, v interface{}) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := data[key].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			data[key] = next
+		}
+		data = next
+	}
+	data[path[len(path)-1]] = v
+}
+
+func formatCSVValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []interface{}:
+		parts := make([]string, len(val))
+		for i, item := range val {
+			parts[i] = formatCSVValue(item)
+		}
+		return strings.Join(parts, CSVListSeparator)
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	}
+	encoded, _ := json.Marshal(v)
+	return string(encoded)
+}
+
+func stringList(v interface{}) []string {
+	var out []string
+	switch list := v.(type) {
+	case []string:
+		out = list
+	case []interface{}:
+		for _, item := range list {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
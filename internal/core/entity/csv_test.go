@@ -0,0 +1,88 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"owner", "language"},
+		"properties": map[string]interface{}{
+			"language": map[string]interface{}{"type": "string", "enum": []interface{}{"Go", "Python"}},
+			"owner":    map[string]interface{}{"type": "string", "format": "email"},
+			"tags":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"coverage": map[string]interface{}{"type": "number"},
+			"on_call":  map[string]interface{}{"type": "boolean", "default": false},
+			"repo_url": map[string]interface{}{"type": "string", "format": "uri", "examples": []interface{}{"https://git.example.com/svc"}},
+			"metadata": map[string]interface{}{
+				"type":     "object",
+				"required": []interface{}{"tier"},
+				"properties": map[string]interface{}{
+					"tier":   map[string]interface{}{"type": "integer"},
+					"labels": map[string]interface{}{"type": "object"},
+				},
+			},
+		},
+	}
+}
+
+func TestCSVColumns_Order(t *testing.T) {
+	var headers []string
+	for _, col := range CSVColumns(testSchema()) {
+		headers = append(headers, col.Header)
+	}
+
+	want := []string{
+		"identifier", "title", "owner", "language",
+		"coverage", "metadata.tier", "metadata.labels", "on_call", "repo_url", "tags",
+	}
+	if !reflect.DeepEqual(headers, want) {
+		t.Errorf("headers = %v, want %v", headers, want)
+	}
+}
+
+func TestCSVColumns_NestedRequiredOnlyWithParent(t *testing.T) {
+	for _, col := range CSVColumns(testSchema()) {
+		if col.Header == "metadata.tier" && col.Required {
+			t.Error("metadata.tier should not be required when metadata is optional")
+		}
+		if col.Header == "owner" && !col.Required {
+			t.Error("owner should be required")
+		}
+	}
+}
+
+func TestCSVTemplate_ExampleRow(t *testing.T) {
+	rows := CSVTemplate(testSchema(), true)
+	if len(rows) != 2 {
+		t.Fatalf("expected header and example row, got %d rows", len(rows))
+	}
+
+	example := map[string]string{}
+	for i, header := range rows[0] {
+		example[header] = rows[1][i]
+	}
+
+	tests := map[string]string{
+		"identifier":      "my-entity",
+		"owner":           "owner@example.com",
+		"language":        "Go",
+		"coverage":        "1.5",
+		"metadata.tier":   "1",
+		"metadata.labels": `{"key":"value"}`,
+		"on_call":         "false",
+		"repo_url":        "https://git.example.com/svc",
+		"tags":            "first;second",
+	}
+	for header, want := range tests {
+		if got := example[header]; got != want {
+			t.Errorf("example %s = %q, want %q", header, got, want)
+		}
+	}
+
+	if rows := CSVTemplate(testSchema(), false); len(rows) != 1 {
+		t.Errorf("expected header only without example, got %d rows", len(rows))
+	}
+}
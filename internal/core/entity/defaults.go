@@ -0,0 +1,44 @@
+package entity
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+// fillDefaults sets the top-level properties a create leaves out that have
+// a default or are generated. Sequence numbers are taken unless preview is
+// set, for dry runs, which show the next number without taking it.
+func (s *Service) fillDefaults(ctx context.Context, bp *blueprint.Blueprint, data map[string]interface{}, preview bool) error {
+	now := time.Now().UTC()
+	for _, d := range blueprint.Defaults(bp.Schema) {
+		if _, ok := data[d.Property]; ok {
+			continue
+		}
+		switch d.Generated {
+		case "":
+			data[d.Property] = clone(d.Value)
+		case blueprint.GenerateUUID:
+			data[d.Property] = uuid.NewString()
+		case blueprint.GenerateTimestamp:
+			data[d.Property] = now.Format(time.RFC3339)
+		case blueprint.GenerateDate:
+			data[d.Property] = now.Format(time.DateOnly)
+		case blueprint.GenerateSequence:
+			next := s.repo.NextSequence
+			if preview {
+				next = s.repo.PeekSequence
+			}
+			n, err := next(ctx, bp.TeamID, bp.ID, d.Property)
+			if err != nil {
+				return err
+			}
+			// Decoded JSON numbers are float64, as stored entities read back
+			data[d.Property] = float64(n)
+		}
+	}
+	return nil
+}
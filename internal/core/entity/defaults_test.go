@@ -0,0 +1,48 @@
+package entity
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+func TestFillDefaults(t *testing.T) {
+	bp := &blueprint.Blueprint{Schema: map[string]interface{}{
+		"properties": map[string]interface{}{
+			"tier":    map[string]interface{}{"type": "string", "default": "bronze"},
+			"tags":    map[string]interface{}{"type": "array", "default": []interface{}{"new"}},
+			"ref":     map[string]interface{}{"type": "string", "generated": "uuid"},
+			"created": map[string]interface{}{"type": "string", "generated": "timestamp"},
+			"day":     map[string]interface{}{"type": "string", "generated": "date"},
+		},
+	}}
+	s := &Service{}
+
+	data := map[string]interface{}{"tier": "gold"}
+	if err := s.fillDefaults(context.Background(), bp, data, false); err != nil {
+		t.Fatalf("fillDefaults: %v", err)
+	}
+	if data["tier"] != "gold" {
+		t.Errorf("tier = %v, want the value sent kept", data["tier"])
+	}
+	if _, err := uuid.Parse(data["ref"].(string)); err != nil {
+		t.Errorf("ref = %v, want a UUID", data["ref"])
+	}
+	if !regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}Z$`).MatchString(data["created"].(string)) {
+		t.Errorf("created = %v, want an RFC 3339 time", data["created"])
+	}
+	if !regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`).MatchString(data["day"].(string)) {
+		t.Errorf("day = %v, want a date", data["day"])
+	}
+
+	// Defaults are copied, so entities never share the blueprint's values
+	data["tags"].([]interface{})[0] = "changed"
+	tags := bp.Schema["properties"].(map[string]interface{})["tags"].(map[string]interface{})["default"].([]interface{})
+	if tags[0] != "new" {
+		t.Errorf("schema default changed to %v", tags[0])
+	}
+}
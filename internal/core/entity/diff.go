@@ -0,0 +1,91 @@
+package entity
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// Change ops, named as in JSON patch
+const (
+	ChangeAdd     = "add"
+	ChangeReplace = "replace"
+	ChangeRemove  = "remove"
+)
+
+// Change is one difference a write makes to an entity. Path is a JSON
+// pointer to what changes, /title or /data/<property>; Old and New are the
+// values before and after, left out where there is none.
+type Change struct {
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ImportRowChange is what one row of an import dry run would write: Op is
+// create or update, and Changes the differences to the current entity
+type ImportRowChange struct {
+	Row        int      `json:"row"`
+	Blueprint  string   `json:"blueprint,omitempty"` // set by catalog imports
+	Identifier string   `json:"identifier"`
+	Op         string   `json:"op"`
+	Changes    []Change `json:"changes"`
+}
+
+// Diff returns the changes from previous to next to the title and the
+// top-level data properties, ordered by path. previous is nil for a create.
+// The hidden properties are left out.
+func Diff(previous, next *Entity, hidden []string) []Change {
+	changes := []Change{}
+	var title string
+	var data map[string]interface{}
+	if previous != nil {
+		title, data = previous.Title, previous.Data
+	}
+	switch {
+	case title == next.Title:
+	case title == "":
+		changes = append(changes, Change{Op: ChangeAdd, Path: "/title", New: next.Title})
+	case next.Title == "":
+		changes = append(changes, Change{Op: ChangeRemove, Path: "/title", Old: title})
+	default:
+		changes = append(changes, Change{Op: ChangeReplace, Path: "/title", Old: title, New: next.Title})
+	}
+
+	var properties []Change
+	for property, value := range next.Data {
+		if slices.Contains(hidden, property) {
+			continue
+		}
+		old, had := data[property]
+		switch {
+		case !had:
+			properties = append(properties, Change{Op: ChangeAdd, Path: dataPointer(property), New: value})
+		case !reflect.DeepEqual(old, value):
+			properties = append(properties, Change{Op: ChangeReplace, Path: dataPointer(property), Old: old, New: value})
+		}
+	}
+	for property, old := range data {
+		if _, has := next.Data[property]; !has && !slices.Contains(hidden, property) {
+			properties = append(properties, Change{Op: ChangeRemove, Path: dataPointer(property), Old: old})
+		}
+	}
+	slices.SortFunc(properties, func(a, b Change) int { return strings.Compare(a.Path, b.Path) })
+	return append(changes, properties...)
+}
+
+// dataPointer returns the JSON pointer to a data property
+func dataPointer(property string) string {
+	return "/data/" + strings.ReplaceAll(strings.ReplaceAll(property, "~", "~0"), "/", "~1")
+}
+
+// importChange describes what an import row writes over current, which is
+// nil when the row creates the entity
+func importChange(row int, blueprintID string, current, entity *Entity, hidden []string) ImportRowChange {
+	op := "create"
+	if current != nil {
+		op = "update"
+	}
+	return ImportRowChange{Row: row, Blueprint: blueprintID, Identifier: entity.Identifier, Op: op, Changes: Diff(current, entity, hidden)}
+}
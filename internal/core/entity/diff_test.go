@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	current := &Entity{Title: "API", Data: map[string]interface{}{"tier": "gold", "owner": "alice", "replicas": 3.0, "secret": "x"}}
+	tests := []struct {
+		name     string
+		previous *Entity
+		next     *Entity
+		hidden   []string
+		want     []Change
+	}{
+		{
+			name: "create",
+			next: &Entity{Title: "API", Data: map[string]interface{}{"tier": "gold", "a/b": 1.0}},
+			want: []Change{
+				{Op: ChangeAdd, Path: "/title", New: "API"},
+				{Op: ChangeAdd, Path: "/data/a~1b", New: 1.0},
+				{Op: ChangeAdd, Path: "/data/tier", New: "gold"},
+			},
+		},
+		{
+			name:     "update",
+			previous: current,
+			next:     &Entity{Title: "Public API", Data: map[string]interface{}{"tier": "silver", "replicas": 3.0, "lang": "go", "secret": "x"}},
+			want: []Change{
+				{Op: ChangeReplace, Path: "/title", Old: "API", New: "Public API"},
+				{Op: ChangeAdd, Path: "/data/lang", New: "go"},
+				{Op: ChangeRemove, Path: "/data/owner", Old: "alice"},
+				{Op: ChangeReplace, Path: "/data/tier", Old: "gold", New: "silver"},
+			},
+		},
+		{
+			name:     "unchanged",
+			previous: current,
+			next:     &Entity{Title: "API", Data: map[string]interface{}{"tier": "gold", "owner": "alice", "replicas": 3.0, "secret": "x"}},
+			want:     []Change{},
+		},
+		{
+			name:     "hidden",
+			previous: current,
+			next:     &Entity{Title: "API", Data: map[string]interface{}{"tier": "gold", "owner": "alice", "replicas": 3.0, "secret": "y"}},
+			hidden:   []string{"secret"},
+			want:     []Change{},
+		},
+		{
+			name:     "title removed",
+			previous: current,
+			next:     &Entity{Data: current.Data},
+			want:     []Change{{Op: ChangeRemove, Path: "/title", Old: "API"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.previous, tt.next, tt.hidden); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/events"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+const (
+	// expiryBatchSize is how many expired entities a sweep reads at once
+	expiryBatchSize = 100
+
+	// expiryMaxBatches bounds the work of one sweep; the rest waits for the next
+	expiryMaxBatches = 10
+
+	// expiryRecomputeBatch is how many expiry times are written at once
+	// after a policy change
+	expiryRecomputeBatch = 1000
+)
+
+// Expiration is the payload of entity.expired events
+type Expiration struct {
+	Entity    *Entity   `json:"entity"`
+	Action    string    `json:"action"` // delete or archive
+	ExpiredAt time.Time `json:"expired_at"`
+}
+
+// ExpirySweeper deletes or archives entities whose expiry time has passed,
+// as their blueprint's expiry policy says. Expiry times are computed when
+// entities are written; when a policy changes the sweeper recomputes them for
+// the blueprint's existing entities. Every entity is removed with a version
+// check, so replicas sweeping at the same time expire it once and an entity
+// written after the sweep read it is reconsidered instead.
+type ExpirySweeper struct {
+	service *Service
+	now     func() time.Time
+
+	mu      sync.Mutex
+	stale   map[string]rollupTarget
+	lastErr error
+}
+
+func NewExpirySweeper(service *Service) *ExpirySweeper {
+	return &ExpirySweeper{
+		service: service,
+		now:     time.Now,
+		stale:   make(map[string]rollupTarget),
+	}
+}
+
+// Subscribe schedules recomputing expiry times when a blueprint's expiry
+// policy may have changed
+func (w *ExpirySweeper) Subscribe(bus *events.Bus) {
+	if w == nil || bus == nil {
+		return
+	}
+	recompute := func(ctx context.Context, e events.Event) {
+		bp, _ := e.Payload.(*blueprint.Blueprint)
+		previous, _ := e.Previous.(*blueprint.Blueprint)
+		switch {
+		case e.Type == events.BlueprintCreated && bp != nil && bp.ExpiryPolicy == nil:
+			return
+		case bp != nil && previous != nil && reflect.DeepEqual(bp.ExpiryPolicy, previous.ExpiryPolicy):
+			return
+		}
+		w.mu.Lock()
+		w.stale[blueprintKey(e.TeamID, e.BlueprintID)] = rollupTarget{e.TeamID, e.BlueprintID}
+		w.mu.Unlock()
+	}
+	bus.Subscribe(events.BlueprintCreated, recompute)
+	bus.Subscribe(events.BlueprintUpdated, recompute)
+}
+
+// Run recomputes stale expiry times and sweeps expired entities on every
+// interval until ctx is done
+func (w *ExpirySweeper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := w.recompute(ctx)
+			if err == nil {
+				var expired int
+				expired, err = w.Sweep(ctx)
+				if expired > 0 {
+					log.Printf("expiry: expired %d entities", expired)
+				}
+			}
+			if err != nil {
+				log.Printf("ERROR: entity expiry failed: %v", err)
+			}
+			w.mu.Lock()
+			w.lastErr = err
+			w.mu.Unlock()
+		}
+	}
+}
+
+// LastError returns the error of the last run, nil once one succeeds
+func (w *ExpirySweeper) LastError() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+// recompute rewrites the expiry times of blueprints whose policy changed
+func (w *ExpirySweeper) recompute(ctx context.Context) error {
+	w.mu.Lock()
+	stale := w.stale
+	w.stale = make(map[string]rollupTarget)
+	w.mu.Unlock()
+
+	for key, target := range stale {
+		if err := w.recomputeBlueprint(ctx, target.teamID, target.blueprintID); err != nil {
+			w.mu.Lock()
+			w.stale[key] = target
+			w.mu.Unlock()
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *ExpirySweeper) recomputeBlueprint(ctx context.Context, teamID uuid.UUID, blueprintID string) error {
+	repo := w.service.repo
+	bp, err := w.service.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if errors.Is(err, blueprint.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if bp.ExpiryPolicy == nil {
+		return repo.ClearExpiry(ctx, teamID, blueprintID)
+	}
+
+	// Collect the changes first so no write waits for the open cursor. Read
+	// from the primary so entities written moments ago are not recomputed stale.
+	changed := make(map[uuid.UUID]*time.Time)
+	err = repo.ForEach(postgres.WithPrimary(ctx), teamID, blueprintID, func(e *Entity) error {
+		at := bp.ExpiryPolicy.ExpiresAt(e.CreatedAt, e.Data)
+		if !sameExpiry(at, e.ExpiresAt) {
+			changed[e.ID] = at
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	batch := make(map[uuid.UUID]*time.Time, expiryRecomputeBatch)
+	for id, at := range changed {
+		batch[id] = at
+		if len(batch) == expiryRecomputeBatch {
+			if err := repo.SetExpiry(ctx, batch); err != nil {
+				return err
+			}
+			batch = make(map[uuid.UUID]*time.Time, expiryRecomputeBatch)
+		}
+	}
+	return repo.SetExpiry(ctx, batch)
+}
+
+// Sweep expires the entities whose expiry time has passed and returns how
+// many it expired
+func (w *ExpirySweeper) Sweep(ctx context.Context) (int, error) {
+	blueprints := map[string]*blueprint.Blueprint{}
+	expired := 0
+	for i := 0; i < expiryMaxBatches; i++ {
+		now := w.now()
+		batch, err := w.service.repo.ListExpired(ctx, now, expiryBatchSize)
+		if err != nil {
+			return expired, err
+		}
+		for _, e := range batch {
+			key := blueprintKey(e.TeamID, e.BlueprintID)
+			bp, ok := blueprints[key]
+			if !ok {
+				bp, err = w.service.blueprintSvc.Get(ctx, e.TeamID, e.BlueprintID)
+				if err != nil && !errors.Is(err, blueprint.ErrNotFound) {
+					return expired, err
+				}
+				blueprints[key] = bp
+			}
+			done, err := w.expire(ctx, bp, e, now)
+			if err != nil {
+				return expired, err
+			}
+			if done {
+				expired++
+			}
+		}
+		if len(batch) < expiryBatchSize {
+			break
+		}
+	}
+	return expired, nil
+}
+
+// expire deletes or archives an expired entity. An entity whose expiry time
+// is out of date, because its policy changed on another replica, gets the
+// current one instead.
+func (w *ExpirySweeper) expire(ctx context.Context, bp *blueprint.Blueprint, e *Entity, now time.Time) (bool, error) {
+	repo := w.service.repo
+	var policy *blueprint.ExpiryPolicy
+	if bp != nil {
+		policy = bp.ExpiryPolicy
+	}
+	at := policy.ExpiresAt(e.CreatedAt, e.Data)
+	if at == nil || at.After(now) {
+		return false, repo.SetExpiry(ctx, map[uuid.UUID]*time.Time{e.ID: at})
+	}
+
+	action := policy.ActionOrDefault()
+	switch action {
+	case blueprint.ExpireArchive:
+		previous := *e
+		archived := *e
+		archived.Data = make(map[string]interface{}, len(e.Data)+1)
+		for k, v := range e.Data {
+			archived.Data[k] = v
+		}
+		archived.Data[policy.ArchiveProperty] = true
+		archived.Sources = make(map[string]PropertySource, len(e.Sources)+1)
+		for k, v := range e.Sources {
+			archived.Sources[k] = v
+		}
+		archived.Sources[policy.ArchiveProperty] = writeSource(ctx)
+		archived.ExpiresAt = nil
+		if err := repo.Update(ctx, &archived); err != nil {
+			if errors.Is(err, ErrVersionConflict) {
+				return false, nil
+			}
+			return false, err
+		}
+		w.service.publish(ctx, events.EntityUpdated, &archived, &previous)
+		e = &archived
+	default:
+		deleted, err := repo.DeleteVersion(ctx, e.ID, e.Version)
+		if err != nil || !deleted {
+			return false, err
+		}
+		w.service.publish(ctx, events.EntityDeleted, e, nil)
+	}
+
+	id := e.ID
+	w.service.bus.Publish(ctx, events.Event{
+		Type:        events.EntityExpired,
+		TeamID:      e.TeamID,
+		BlueprintID: e.BlueprintID,
+		EntityID:    &id,
+		Payload:     &Expiration{Entity: e, Action: action, ExpiredAt: *at},
+	})
+	return true, nil
+}
+
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
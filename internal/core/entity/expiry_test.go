@@ -0,0 +1,58 @@
+package entity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+func TestExpirySweeper_SubscribeMarksChangedPolicies(t *testing.T) {
+	bus := events.NewBus()
+	w := NewExpirySweeper(nil)
+	w.Subscribe(bus)
+
+	teamID := uuid.New()
+	policy := &blueprint.ExpiryPolicy{TTL: "24h"}
+	publish := func(eventType, id string, bp, previous *blueprint.Blueprint) {
+		e := events.Event{Type: eventType, TeamID: teamID, BlueprintID: id, Payload: bp}
+		if previous != nil {
+			e.Previous = previous
+		}
+		bus.Publish(context.Background(), e)
+	}
+
+	publish(events.BlueprintCreated, "plain", &blueprint.Blueprint{ID: "plain"}, nil)
+	publish(events.BlueprintCreated, "preview", &blueprint.Blueprint{ID: "preview", ExpiryPolicy: policy}, nil)
+	publish(events.BlueprintUpdated, "same", &blueprint.Blueprint{ID: "same", ExpiryPolicy: policy},
+		&blueprint.Blueprint{ID: "same", ExpiryPolicy: &blueprint.ExpiryPolicy{TTL: "24h"}})
+	publish(events.BlueprintUpdated, "removed", &blueprint.Blueprint{ID: "removed"},
+		&blueprint.Blueprint{ID: "removed", ExpiryPolicy: policy})
+	// Bundle imports announce updates without the previous blueprint
+	publish(events.BlueprintUpdated, "imported", &blueprint.Blueprint{ID: "imported"}, nil)
+	publish(events.BlueprintDeleted, "deleted", nil, nil)
+
+	for _, id := range []string{"preview", "removed", "imported"} {
+		if _, ok := w.stale[blueprintKey(teamID, id)]; !ok {
+			t.Errorf("%s should be recomputed", id)
+		}
+	}
+	if len(w.stale) != 3 {
+		t.Errorf("stale = %v, want 3 blueprints", w.stale)
+	}
+}
+
+func TestSameExpiry(t *testing.T) {
+	a := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	b := a.In(time.FixedZone("CET", 3600))
+	if !sameExpiry(nil, nil) || !sameExpiry(&a, &b) {
+		t.Error("equal expiry times should be the same")
+	}
+	if sameExpiry(&a, nil) || sameExpiry(nil, &a) {
+		t.Error("a time and none should differ")
+	}
+}
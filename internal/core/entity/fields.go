@@ -0,0 +1,142 @@
+package entity
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// maxFields caps the fields of a sparse fieldset
+const maxFields = 50
+
+// fieldColumns are the entity fields a sparse fieldset can select, by JSON name
+var fieldColumns = []string{
+	"id", "team_id", "blueprint_id", "identifier", "title", "data",
+	"version", "integration_id", "expires_at", "created_at", "updated_at",
+}
+
+// Fields is a sparse fieldset such as "identifier,title,data.language": the
+// entity fields to return and, as "data.<path>", the data properties. Data
+// paths are selected in SQL, so the rest of the data is never read out of
+// the database. The entity id is always returned.
+type Fields struct {
+	columns []string
+	// data are the selected data paths; allData selects all of data
+	data    [][]string
+	allData bool
+}
+
+// ParseFields reads a comma-separated fieldset. An empty one selects every
+// field and returns nil. Data paths are checked against the schema by
+// FilterCompiler.Fields.
+func ParseFields(raw string) (*Fields, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	items := strings.Split(raw, ",")
+	if len(items) > maxFields {
+		return nil, fmt.Errorf("%w: at most %d fields are allowed", ErrInvalidFilter, maxFields)
+	}
+	f := &Fields{columns: []string{"id"}}
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		column, path, nested := strings.Cut(item, ".")
+		switch {
+		case nested && column == "data":
+			if path == "" {
+				return nil, fmt.Errorf("%w: invalid field %q", ErrInvalidFilter, item)
+			}
+			f.data = append(f.data, strings.Split(path, "."))
+		case nested, !slices.Contains(fieldColumns, column):
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, item)
+		case column == "data":
+			f.allData = true
+		}
+		if !slices.Contains(f.columns, column) {
+			f.columns = append(f.columns, column)
+		}
+	}
+	return f, nil
+}
+
+// Fields parses a fieldset and checks its data paths like filter properties
+func (fc *FilterCompiler) Fields(raw string) (*Fields, error) {
+	f, err := ParseFields(raw)
+	if err != nil || f == nil {
+		return nil, err
+	}
+	for _, path := range f.data {
+		if _, err := fc.Property(strings.Join(path, ".")); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// dataColumn returns the SQL expression selected instead of data
+func (f *Fields) dataColumn(args *queryArgs) string {
+	switch {
+	case f == nil || f.allData:
+		return "data"
+	case len(f.data) == 0:
+		return "'{}'::jsonb"
+	}
+	return fmt.Sprintf("COALESCE(%s, '{}'::jsonb)", projectPaths(args, f.data, nil))
+}
+
+// projectPaths builds the object holding the given paths below prefix, or
+// NULL when none of them exists. Missing properties are left out rather
+// than returned as null.
+func projectPaths(args *queryArgs, paths [][]string, prefix []string) string {
+	var keys []string
+	whole := map[string]bool{}
+	children := map[string][][]string{}
+	for _, path := range paths {
+		key := path[0]
+		if !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+		if len(path) == 1 {
+			whole[key] = true
+		} else {
+			children[key] = append(children[key], path[1:])
+		}
+	}
+
+	rows := make([]string, len(keys))
+	for i, key := range keys {
+		path := append(slices.Clone(prefix), key)
+		var value string
+		if whole[key] {
+			value = fmt.Sprintf("data #> %s::text[]", args.add(path))
+		} else {
+			value = projectPaths(args, children[key], path)
+		}
+		rows[i] = fmt.Sprintf("(%s::text, %s)", args.add(key), value)
+	}
+	return fmt.Sprintf("(SELECT jsonb_object_agg(k, v) FROM (VALUES %s) AS f(k, v) WHERE v IS NOT NULL)", strings.Join(rows, ", "))
+}
+
+// Project returns entities as JSON objects with only the selected fields
+func (f *Fields) Project(entities []*Entity) ([]map[string]interface{}, error) {
+	projected := make([]map[string]interface{}, len(entities))
+	for i, e := range entities {
+		encoded, err := json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+		var full map[string]interface{}
+		if err := json.Unmarshal(encoded, &full); err != nil {
+			return nil, err
+		}
+		out := make(map[string]interface{}, len(f.columns))
+		for _, column := range f.columns {
+			if v, ok := full[column]; ok {
+				out[column] = v
+			}
+		}
+		projected[i] = out
+	}
+	return projected, nil
+}
@@ -0,0 +1,116 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		raw         string
+		wantColumns []string
+		wantData    [][]string
+		wantAll     bool
+		wantErr     bool
+	}{
+		{"identifier,title", []string{"id", "identifier", "title"}, nil, false, false},
+		{" identifier , data.language", []string{"id", "identifier", "data"}, [][]string{{"language"}}, false, false},
+		{"data.metadata.tier,data.language", []string{"id", "data"}, [][]string{{"metadata", "tier"}, {"language"}}, false, false},
+		{"data", []string{"id", "data"}, nil, true, false},
+		{"id,id", []string{"id"}, nil, false, false},
+		{"password", nil, nil, false, true},
+		{"title.x", nil, nil, false, true},
+		{"data.", nil, nil, false, true},
+		{"identifier,,title", nil, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFields(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFields(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("ParseFields(%q) error should wrap ErrInvalidFilter, got %v", tt.raw, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got.columns, tt.wantColumns) || !reflect.DeepEqual(got.data, tt.wantData) || got.allData != tt.wantAll {
+			t.Errorf("ParseFields(%q) = %+v", tt.raw, got)
+		}
+	}
+
+	if got, err := ParseFields(""); got != nil || err != nil {
+		t.Errorf("ParseFields(\"\") = %v, %v; want nil, nil", got, err)
+	}
+	if _, err := ParseFields(strings.Repeat("title,", maxFields) + "title"); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected too many fields to be rejected, got %v", err)
+	}
+}
+
+func TestFilterCompiler_Fields(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+	if _, err := fc.Fields("identifier,data.metadata.tier,data.labels.team"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if _, err := fc.Fields("data.unknown"); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected an undeclared property to be rejected, got %v", err)
+	}
+
+	fc.hidden = []string{"status"}
+	if _, err := fc.Fields("data.status"); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected a hidden property to be rejected, got %v", err)
+	}
+}
+
+func TestFields_DataColumn(t *testing.T) {
+	tests := []struct {
+		raw      string
+		want     string
+		wantArgs int
+	}{
+		{"", "data", 0},
+		{"data,data.status", "data", 0},
+		{"identifier", "'{}'::jsonb", 0},
+		{"data.status", "COALESCE((SELECT jsonb_object_agg(k, v) FROM (VALUES ($2::text, data #> $1::text[])) AS f(k, v) WHERE v IS NOT NULL), '{}'::jsonb)", 2},
+		{"data.metadata.tier,data.metadata.owner",
+			"COALESCE((SELECT jsonb_object_agg(k, v) FROM (VALUES ($5::text, (SELECT jsonb_object_agg(k, v) FROM (VALUES ($2::text, data #> $1::text[]), ($4::text, data #> $3::text[])) AS f(k, v) WHERE v IS NOT NULL))) AS f(k, v) WHERE v IS NOT NULL), '{}'::jsonb)", 5},
+	}
+
+	for _, tt := range tests {
+		fields, err := ParseFields(tt.raw)
+		if err != nil {
+			t.Fatalf("ParseFields(%q): %v", tt.raw, err)
+		}
+		args := newQueryArgs()
+		if got := fields.dataColumn(args); got != tt.want {
+			t.Errorf("dataColumn(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+		if len(args.values) != tt.wantArgs {
+			t.Errorf("dataColumn(%q) added %d args, want %d", tt.raw, len(args.values), tt.wantArgs)
+		}
+	}
+}
+
+func TestFields_Project(t *testing.T) {
+	fields, err := ParseFields("identifier,data.language")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Entity{Identifier: "payments", Title: "Payments", Data: map[string]interface{}{"language": "go"}}
+	projected, err := fields.Project([]*Entity{e})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"id":         e.ID.String(),
+		"identifier": "payments",
+		"data":       map[string]interface{}{"language": "go"},
+	}
+	if !reflect.DeepEqual(projected[0], want) {
+		t.Errorf("Project = %v, want %v", projected[0], want)
+	}
+}
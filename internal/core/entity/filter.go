@@ -0,0 +1,402 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// errUnknownProperty marks filters on properties the blueprint schema does not declare
+var errUnknownProperty = fmt.Errorf("%w: unknown property", ErrInvalidFilter)
+
+const (
+	maxFilters  = 20
+	maxInValues = 100
+	// maxFilterDepth bounds the nesting of filter groups
+	maxFilterDepth = 4
+)
+
+// propertySegment is the allowed shape of one dot-separated property path segment
+var propertySegment = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// timestampPattern matches the RFC 3339 date-times before and after compare.
+// Other values are left out before the cast, which would fail the query.
+const timestampPattern = `^[0-9]{4}-[0-9]{2}-[0-9]{2}[Tt ][0-9]{2}:[0-9]{2}:[0-9]{2}([.][0-9]+)?([Zz]|[+-][0-9]{2}:[0-9]{2})$`
+
+// sortColumns are entity columns that can be ordered by directly
+var sortColumns = map[string]bool{
+	"created_at": true,
+	"updated_at": true,
+	"identifier": true,
+	"title":      true,
+}
+
+// queryArgs collects positional query parameters
+type queryArgs struct {
+	values []interface{}
+}
+
+func newQueryArgs(values ...interface{}) *queryArgs {
+	return &queryArgs{values: values}
+}
+
+// add appends a parameter and returns its placeholder
+func (a *queryArgs) add(v interface{}) string {
+	a.values = append(a.values, v)
+	return fmt.Sprintf("$%d", len(a.values))
+}
+
+// FilterCompiler turns search filters into parameterized SQL conditions over
+// entities.data. Property paths are checked against the blueprint schema and
+// passed as text[] parameters, so no user input is interpolated into SQL.
+type FilterCompiler struct {
+	schema map[string]interface{}
+	// hidden are top-level properties treated as undeclared, the restricted
+	// properties of callers that may not read them
+	hidden []string
+}
+
+func NewFilterCompiler(schema map[string]interface{}) *FilterCompiler {
+	return &FilterCompiler{schema: schema}
+}
+
+// Property validates a dot-separated property path and returns the schema
+// of the addressed property. The returned schema is nil when the path points
+// into a free-form object (one without declared properties).
+func (fc *FilterCompiler) Property(path string) (map[string]interface{}, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: property is required", ErrInvalidFilter)
+	}
+	node := fc.schema
+	for i, segment := range strings.Split(path, ".") {
+		if !propertySegment.MatchString(segment) {
+			return nil, fmt.Errorf("%w: invalid property %q", ErrInvalidFilter, path)
+		}
+		if i == 0 && slices.Contains(fc.hidden, segment) {
+			return nil, fmt.Errorf("%w %q", errUnknownProperty, path)
+		}
+		if node == nil {
+			continue
+		}
+		props, _ := node["properties"].(map[string]interface{})
+		if len(props) == 0 {
+			// Free-form object: anything below it is allowed
+			node = nil
+			continue
+		}
+		child, ok := props[segment].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w %q", errUnknownProperty, path)
+		}
+		node = child
+	}
+	return node, nil
+}
+
+// Where compiles filters into conditions that must all hold. The limit on
+// filters counts the property filters inside groups.
+func (fc *FilterCompiler) Where(args *queryArgs, filters []SearchFilter) ([]string, error) {
+	if len(filterLeaves(filters)) > maxFilters {
+		return nil, fmt.Errorf("%w: at most %d filters are allowed", ErrInvalidFilter, maxFilters)
+	}
+	conditions := make([]string, 0, len(filters))
+	for _, f := range filters {
+		cond, err := fc.condition(args, f, 1)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, cond)
+	}
+	return conditions, nil
+}
+
+// group compiles a filter group into a parenthesized condition. A not group
+// matches the entities its rules, combined with and, do not match, including
+// those where a rule compares to NULL.
+func (fc *FilterCompiler) group(args *queryArgs, g SearchFilter, depth int) (string, error) {
+	if depth > maxFilterDepth {
+		return "", fmt.Errorf("%w: filter groups nest at most %d deep", ErrInvalidFilter, maxFilterDepth)
+	}
+	if g.Property != "" || g.Operator != "" || g.Value != nil {
+		return "", fmt.Errorf("%w: a filter group takes combinator and rules, not property, operator or value", ErrInvalidFilter)
+	}
+	sep := " AND "
+	switch g.Combinator {
+	case "", "and", "not":
+	case "or":
+		sep = " OR "
+	default:
+		return "", fmt.Errorf("%w: unknown combinator %q, use and, or or not", ErrInvalidFilter, g.Combinator)
+	}
+	if len(g.Rules) == 0 {
+		return "", fmt.Errorf("%w: a filter group needs at least one rule", ErrInvalidFilter)
+	}
+	conditions := make([]string, len(g.Rules))
+	for i, rule := range g.Rules {
+		cond, err := fc.condition(args, rule, depth+1)
+		if err != nil {
+			return "", err
+		}
+		conditions[i] = cond
+	}
+	cond := "(" + strings.Join(conditions, sep) + ")"
+	if g.Combinator == "not" {
+		return "NOT COALESCE(" + cond + ", FALSE)", nil
+	}
+	return cond, nil
+}
+
+func (fc *FilterCompiler) condition(args *queryArgs, f SearchFilter, depth int) (string, error) {
+	if f.IsGroup() {
+		return fc.group(args, f, depth)
+	}
+	prop, err := fc.Property(f.Property)
+	if err != nil {
+		return "", err
+	}
+	path := strings.Split(f.Property, ".")
+	invalid := func(format string, a ...interface{}) error {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidFilter, f.Property, fmt.Sprintf(format, a...))
+	}
+
+	switch f.Operator {
+	case "eq", "neq":
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return "", invalid("unsupported value")
+		}
+		if f.Operator == "neq" {
+			return fmt.Sprintf("data #> %s::text[] <> %s::jsonb", args.add(path), args.add(string(value))), nil
+		}
+		cond := fmt.Sprintf("data #> %s::text[] = %s::jsonb", args.add(path), args.add(string(value)))
+		if doc, ok := containmentDoc(f.Property, f.Value); ok {
+			// The containment test can use the GIN index on data; the exact
+			// comparison rechecks rows where containment is looser than equality
+			cond = fmt.Sprintf("data @> %s::jsonb AND %s", args.add(doc), cond)
+		}
+		return cond, nil
+
+	case "gt", "gte", "lt", "lte":
+		op := map[string]string{"gt": ">", "gte": ">=", "lt": "<", "lte": "<="}[f.Operator]
+		switch v := f.Value.(type) {
+		case float64:
+			// Non-numeric values compare as NULL instead of failing the cast
+			p := args.add(path)
+			return fmt.Sprintf("(CASE WHEN jsonb_typeof(data #> %s::text[]) = 'number' THEN (data #>> %s::text[])::numeric END) %s %s",
+				p, p, op, args.add(v)), nil
+		case string:
+			// Lexical comparison, which orders ISO 8601 dates correctly
+			return fmt.Sprintf("data #>> %s::text[] %s %s", args.add(path), op, args.add(v)), nil
+		}
+		return "", invalid("%s requires a number or string value", f.Operator)
+
+	case "before", "after":
+		s, ok := f.Value.(string)
+		if !ok {
+			return "", invalid("%s requires an RFC 3339 date-time value", f.Operator)
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return "", invalid("%s requires an RFC 3339 date-time value", f.Operator)
+		}
+		op := "<"
+		if f.Operator == "after" {
+			op = ">"
+		}
+		p := args.add(path)
+		return fmt.Sprintf("(CASE WHEN data #>> %s::text[] ~ '%s' THEN (data #>> %s::text[])::timestamptz END) %s %s::timestamptz",
+			p, timestampPattern, p, op, args.add(t)), nil
+
+	case "contains":
+		if schemaType(prop) == "array" {
+			value, err := json.Marshal([]interface{}{f.Value})
+			if err != nil {
+				return "", invalid("unsupported value")
+			}
+			return fmt.Sprintf("data #> %s::text[] @> %s::jsonb", args.add(path), args.add(string(value))), nil
+		}
+		s, ok := f.Value.(string)
+		if !ok {
+			return "", invalid("contains requires a string value")
+		}
+		return fmt.Sprintf("data #>> %s::text[] ILIKE %s", args.add(path), args.add("%"+postgres.EscapeLike(s)+"%")), nil
+
+	case "contains_any", "contains_all":
+		if prop != nil && schemaType(prop) != "array" {
+			return "", invalid("%s requires an array property", f.Operator)
+		}
+		values, ok := f.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return "", invalid("%s requires a non-empty array value", f.Operator)
+		}
+		if len(values) > maxInValues {
+			return "", invalid("%s accepts at most %d values", f.Operator, maxInValues)
+		}
+		if f.Operator == "contains_all" {
+			value, err := json.Marshal(values)
+			if err != nil {
+				return "", invalid("unsupported value")
+			}
+			return fmt.Sprintf("data #> %s::text[] @> %s::jsonb", args.add(path), args.add(string(value))), nil
+		}
+		// Each value is wrapped in an array, so @> tests membership rather than
+		// matching a scalar property
+		wrapped := make([]interface{}, len(values))
+		for i, v := range values {
+			wrapped[i] = []interface{}{v}
+		}
+		encoded, err := encodeValues(wrapped)
+		if err != nil {
+			return "", invalid("unsupported value")
+		}
+		return fmt.Sprintf("data #> %s::text[] @> ANY(%s::jsonb[])", args.add(path), args.add(encoded)), nil
+
+	case "exists":
+		exists, ok := f.Value.(bool)
+		if !ok {
+			return "", invalid("exists requires a boolean value")
+		}
+		if exists {
+			if !strings.Contains(f.Property, ".") {
+				// Top-level key existence can use the GIN index on data
+				return fmt.Sprintf("data ? %s", args.add(f.Property)), nil
+			}
+			return fmt.Sprintf("data #> %s::text[] IS NOT NULL", args.add(path)), nil
+		}
+		return fmt.Sprintf("data #> %s::text[] IS NULL", args.add(path)), nil
+
+	case "is_null":
+		isNull, ok := f.Value.(bool)
+		if !ok {
+			return "", invalid("is_null requires a boolean value")
+		}
+		// A missing property counts as null
+		if isNull {
+			return fmt.Sprintf("COALESCE(jsonb_typeof(data #> %s::text[]), 'null') = 'null'", args.add(path)), nil
+		}
+		return fmt.Sprintf("jsonb_typeof(data #> %s::text[]) <> 'null'", args.add(path)), nil
+
+	case "in", "not_in":
+		values, ok := f.Value.([]interface{})
+		if !ok || len(values) == 0 {
+			return "", invalid("%s requires a non-empty array value", f.Operator)
+		}
+		if len(values) > maxInValues {
+			return "", invalid("%s accepts at most %d values", f.Operator, maxInValues)
+		}
+		encoded, err := encodeValues(values)
+		if err != nil {
+			return "", invalid("unsupported value")
+		}
+		if f.Operator == "not_in" {
+			// Like neq, entities without the property do not match
+			return fmt.Sprintf("data #> %s::text[] <> ALL(%s::jsonb[])", args.add(path), args.add(encoded)), nil
+		}
+		return fmt.Sprintf("data #> %s::text[] = ANY(%s::jsonb[])", args.add(path), args.add(encoded)), nil
+	}
+
+	return "", fmt.Errorf("%w: unknown operator %q", ErrInvalidFilter, f.Operator)
+}
+
+// OrderBy compiles a sort expression. Entity columns sort directly; schema
+// properties sort by their JSON value, so numbers order numerically.
+func (fc *FilterCompiler) OrderBy(args *queryArgs, orderBy, orderDir string) (string, error) {
+	dir := "ASC"
+	switch strings.ToLower(orderDir) {
+	case "", "asc":
+	case "desc":
+		dir = "DESC"
+	default:
+		return "", fmt.Errorf("%w: order_dir must be asc or desc", ErrInvalidFilter)
+	}
+
+	if orderBy == "" {
+		return "created_at DESC", nil
+	}
+	if sortColumns[orderBy] {
+		return orderBy + " " + dir, nil
+	}
+	if _, err := fc.Property(orderBy); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("data #> %s::text[] %s NULLS LAST", args.add(strings.Split(orderBy, ".")), dir), nil
+}
+
+// Validate checks filters and ordering without running a search
+func (fc *FilterCompiler) Validate(filters []SearchFilter, orderBy, orderDir string) error {
+	args := newQueryArgs()
+	if _, err := fc.Where(args, filters); err != nil {
+		return err
+	}
+	_, err := fc.OrderBy(args, orderBy, orderDir)
+	return err
+}
+
+// Column validates a list column: an entity column or a schema property
+func (fc *FilterCompiler) Column(column string) error {
+	if sortColumns[column] {
+		return nil
+	}
+	_, err := fc.Property(column)
+	return err
+}
+
+// filterLeaves returns the property filters of filters, including those
+// inside groups
+func filterLeaves(filters []SearchFilter) []SearchFilter {
+	var leaves []SearchFilter
+	for _, f := range filters {
+		if f.IsGroup() {
+			leaves = append(leaves, filterLeaves(f.Rules)...)
+			continue
+		}
+		leaves = append(leaves, f)
+	}
+	return leaves
+}
+
+// containmentDoc builds the document {"a":{"b":value}} for property "a.b".
+// Only scalar values qualify: containment of arrays and objects means subset, not equality.
+func containmentDoc(property string, value interface{}) (string, bool) {
+	switch value.(type) {
+	case nil, string, float64, bool:
+	default:
+		return "", false
+	}
+	segments := strings.Split(property, ".")
+	doc := value
+	for i := len(segments) - 1; i >= 0; i-- {
+		doc = map[string]interface{}{segments[i]: doc}
+	}
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return "", false
+	}
+	return string(encoded), true
+}
+
+// encodeValues JSON-encodes each value for a jsonb[] parameter
+func encodeValues(values []interface{}) ([]string, error) {
+	encoded := make([]string, len(values))
+	for i, v := range values {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		encoded[i] = string(b)
+	}
+	return encoded, nil
+}
+
+func schemaType(prop map[string]interface{}) string {
+	t, _ := prop["type"].(string)
+	return t
+}
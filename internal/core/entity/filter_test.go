@@ -0,0 +1,333 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func filterSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"status":   map[string]interface{}{"type": "string"},
+			"replicas": map[string]interface{}{"type": "integer"},
+			"tags":     map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tier": map[string]interface{}{"type": "integer"},
+				},
+			},
+			"labels": map[string]interface{}{"type": "object"},
+		},
+	}
+}
+
+func TestFilterCompiler_Property(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{"status", false},
+		{"metadata.tier", false},
+		{"labels.team", false}, // free-form object
+		{"labels.team.name", false},
+		{"unknown", true},
+		{"metadata.unknown", true},
+		{"", true},
+		{"status'; DROP TABLE entities; --", true},
+		{"status->>0", true},
+		{"metadata..tier", true},
+	}
+
+	for _, tt := range tests {
+		_, err := fc.Property(tt.path)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Property(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Property(%q) error should wrap ErrInvalidFilter, got %v", tt.path, err)
+		}
+	}
+}
+
+func TestFilterCompiler_SchemaWithoutPropertiesAllowsAnyPath(t *testing.T) {
+	fc := NewFilterCompiler(map[string]interface{}{"type": "object"})
+	if _, err := fc.Property("anything.nested"); err != nil {
+		t.Errorf("expected free-form schema to accept any path, got %v", err)
+	}
+}
+
+func TestFilterCompiler_Where(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+
+	tests := []struct {
+		name     string
+		filter   SearchFilter
+		wantSQL  string
+		wantArgs int
+		wantErr  bool
+	}{
+		{"eq", SearchFilter{Property: "status", Operator: "eq", Value: "active"}, "data @> $5::jsonb AND data #> $3::text[] = $4::jsonb", 3, false},
+		{"eq object", SearchFilter{Property: "labels", Operator: "eq", Value: map[string]interface{}{"a": "b"}}, "data #> $3::text[] = $4::jsonb", 2, false},
+		{"neq", SearchFilter{Property: "status", Operator: "neq", Value: "retired"}, "data #> $3::text[] <> $4::jsonb", 2, false},
+		{"gt number", SearchFilter{Property: "replicas", Operator: "gt", Value: float64(2)}, "THEN (data #>> $3::text[])::numeric END) > $4", 2, false},
+		{"lte string", SearchFilter{Property: "status", Operator: "lte", Value: "m"}, "data #>> $3::text[] <= $4", 2, false},
+		{"gt bool rejected", SearchFilter{Property: "replicas", Operator: "gt", Value: true}, "", 0, true},
+		{"contains array", SearchFilter{Property: "tags", Operator: "contains", Value: "api"}, "data #> $3::text[] @> $4::jsonb", 2, false},
+		{"contains string", SearchFilter{Property: "status", Operator: "contains", Value: "act"}, "data #>> $3::text[] ILIKE $4", 2, false},
+		{"contains non-string", SearchFilter{Property: "status", Operator: "contains", Value: float64(1)}, "", 0, true},
+		{"exists", SearchFilter{Property: "metadata.tier", Operator: "exists", Value: true}, "data #> $3::text[] IS NOT NULL", 1, false},
+		{"exists top-level", SearchFilter{Property: "status", Operator: "exists", Value: true}, "data ? $3", 1, false},
+		{"not exists", SearchFilter{Property: "metadata.tier", Operator: "exists", Value: false}, "data #> $3::text[] IS NULL", 1, false},
+		{"exists non-bool", SearchFilter{Property: "metadata.tier", Operator: "exists", Value: "yes"}, "", 0, true},
+		{"in", SearchFilter{Property: "status", Operator: "in", Value: []interface{}{"a", "b"}}, "data #> $3::text[] = ANY($4::jsonb[])", 2, false},
+		{"in empty", SearchFilter{Property: "status", Operator: "in", Value: []interface{}{}}, "", 0, true},
+		{"not_in", SearchFilter{Property: "status", Operator: "not_in", Value: []interface{}{"a", "b"}}, "data #> $3::text[] <> ALL($4::jsonb[])", 2, false},
+		{"not_in scalar", SearchFilter{Property: "status", Operator: "not_in", Value: "a"}, "", 0, true},
+		{"contains_any", SearchFilter{Property: "tags", Operator: "contains_any", Value: []interface{}{"api", "web"}}, "data #> $3::text[] @> ANY($4::jsonb[])", 2, false},
+		{"contains_all", SearchFilter{Property: "tags", Operator: "contains_all", Value: []interface{}{"api", "web"}}, "data #> $3::text[] @> $4::jsonb", 2, false},
+		{"contains_any free-form", SearchFilter{Property: "labels.teams", Operator: "contains_any", Value: []interface{}{"a"}}, "@> ANY($4::jsonb[])", 2, false},
+		{"contains_any non-array property", SearchFilter{Property: "status", Operator: "contains_any", Value: []interface{}{"a"}}, "", 0, true},
+		{"contains_all empty", SearchFilter{Property: "tags", Operator: "contains_all", Value: []interface{}{}}, "", 0, true},
+		{"before", SearchFilter{Property: "status", Operator: "before", Value: "2024-01-02T03:04:05Z"}, "::timestamptz END) < $4::timestamptz", 2, false},
+		{"after offset", SearchFilter{Property: "status", Operator: "after", Value: "2024-01-02T03:04:05+02:00"}, "::timestamptz END) > $4::timestamptz", 2, false},
+		{"before date only", SearchFilter{Property: "status", Operator: "before", Value: "2024-01-02"}, "", 0, true},
+		{"after number", SearchFilter{Property: "status", Operator: "after", Value: float64(1)}, "", 0, true},
+		{"is_null", SearchFilter{Property: "metadata.tier", Operator: "is_null", Value: true}, "COALESCE(jsonb_typeof(data #> $3::text[]), 'null') = 'null'", 1, false},
+		{"is_null false", SearchFilter{Property: "metadata.tier", Operator: "is_null", Value: false}, "jsonb_typeof(data #> $3::text[]) <> 'null'", 1, false},
+		{"is_null non-bool", SearchFilter{Property: "metadata.tier", Operator: "is_null", Value: "yes"}, "", 0, true},
+		{"unknown operator", SearchFilter{Property: "status", Operator: "regex", Value: ".*"}, "", 0, true},
+		{"unknown property", SearchFilter{Property: "owner", Operator: "eq", Value: "x"}, "", 0, true},
+	}
+
+	for _, tt := range tests {
+		args := newQueryArgs("team", "blueprint")
+		conds, err := fc.Where(args, []SearchFilter{tt.filter})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("%s: error should wrap ErrInvalidFilter, got %v", tt.name, err)
+			}
+			continue
+		}
+		if !strings.Contains(conds[0], tt.wantSQL) {
+			t.Errorf("%s: condition = %q, want it to contain %q", tt.name, conds[0], tt.wantSQL)
+		}
+		if got := len(args.values) - 2; got != tt.wantArgs {
+			t.Errorf("%s: added %d args, want %d", tt.name, got, tt.wantArgs)
+		}
+	}
+}
+
+func TestFilterCompiler_Groups(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+	neq := func(property string, value interface{}) SearchFilter {
+		return SearchFilter{Property: property, Operator: "neq", Value: value}
+	}
+
+	tests := []struct {
+		name    string
+		filter  SearchFilter
+		wantSQL string
+		wantErr bool
+	}{
+		{"or", SearchFilter{Combinator: "or", Rules: []SearchFilter{neq("status", "go"), neq("status", "python")}},
+			"(data #> $1::text[] <> $2::jsonb OR data #> $3::text[] <> $4::jsonb)", false},
+		{"and is the default", SearchFilter{Rules: []SearchFilter{neq("status", "go"), neq("replicas", float64(1))}},
+			"(data #> $1::text[] <> $2::jsonb AND data #> $3::text[] <> $4::jsonb)", false},
+		{"nested", SearchFilter{Combinator: "or", Rules: []SearchFilter{
+			neq("status", "go"),
+			{Combinator: "and", Rules: []SearchFilter{neq("status", "python"), neq("replicas", float64(1))}},
+		}}, "(data #> $1::text[] <> $2::jsonb OR (data #> $3::text[] <> $4::jsonb AND data #> $5::text[] <> $6::jsonb))", false},
+		{"not", SearchFilter{Combinator: "not", Rules: []SearchFilter{neq("status", "go")}},
+			"NOT COALESCE((data #> $1::text[] <> $2::jsonb), FALSE)", false},
+		{"unknown combinator", SearchFilter{Combinator: "xor", Rules: []SearchFilter{neq("status", "go")}}, "", true},
+		{"empty group", SearchFilter{Combinator: "or", Rules: []SearchFilter{}}, "", true},
+		{"group with property", SearchFilter{Property: "status", Combinator: "or", Rules: []SearchFilter{neq("status", "go")}}, "", true},
+		{"invalid rule", SearchFilter{Combinator: "or", Rules: []SearchFilter{neq("unknown", "go")}}, "", true},
+	}
+
+	for _, tt := range tests {
+		conds, err := fc.Where(newQueryArgs(), []SearchFilter{tt.filter})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			if !errors.Is(err, ErrInvalidFilter) {
+				t.Errorf("%s: error should wrap ErrInvalidFilter, got %v", tt.name, err)
+			}
+			continue
+		}
+		if conds[0] != tt.wantSQL {
+			t.Errorf("%s: condition = %q, want %q", tt.name, conds[0], tt.wantSQL)
+		}
+	}
+}
+
+func TestFilterCompiler_GroupLimits(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+
+	deep := SearchFilter{Property: "status", Operator: "eq", Value: "x"}
+	for i := 0; i <= maxFilterDepth; i++ {
+		deep = SearchFilter{Combinator: "or", Rules: []SearchFilter{deep}}
+	}
+	if _, err := fc.Where(newQueryArgs(), []SearchFilter{deep}); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected groups nested too deep to be rejected, got %v", err)
+	}
+
+	rules := make([]SearchFilter, maxFilters)
+	for i := range rules {
+		rules[i] = SearchFilter{Property: "status", Operator: "eq", Value: "x"}
+	}
+	filters := []SearchFilter{{Property: "status", Operator: "eq", Value: "x"}, {Combinator: "or", Rules: rules}}
+	if _, err := fc.Where(newQueryArgs(), filters); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected filters inside groups to count towards the limit, got %v", err)
+	}
+}
+
+func TestSearchFilter_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		filter SearchFilter
+		want   string
+	}{
+		{SearchFilter{Property: "status", Operator: "eq", Value: nil}, `{"property":"status","operator":"eq","value":null}`},
+		{SearchFilter{Combinator: "or", Rules: []SearchFilter{{Property: "tier", Operator: "eq", Value: float64(1)}}},
+			`{"combinator":"or","rules":[{"property":"tier","operator":"eq","value":1}]}`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.filter)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal = %s, want %s", got, tt.want)
+		}
+		var back SearchFilter
+		if err := json.Unmarshal(got, &back); err != nil || back.IsGroup() != tt.filter.IsGroup() {
+			t.Errorf("Unmarshal(%s) = %+v, %v", got, back, err)
+		}
+	}
+}
+
+func TestFilterCompiler_ContainsAnyWrapsValues(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+	args := newQueryArgs()
+	if _, err := fc.Where(args, []SearchFilter{{Property: "tags", Operator: "contains_any", Value: []interface{}{"api", float64(2)}}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, _ := args.values[1].([]string)
+	if len(got) != 2 || got[0] != `["api"]` || got[1] != `[2]` {
+		t.Errorf("values = %v, want each wrapped in an array", args.values[1])
+	}
+}
+
+func TestFilterCompiler_WhereNeverInterpolatesInput(t *testing.T) {
+	fc := NewFilterCompiler(map[string]interface{}{"type": "object"})
+	args := newQueryArgs()
+	conds, err := fc.Where(args, []SearchFilter{{Property: "name", Operator: "contains", Value: "'; DROP TABLE entities; --"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(conds[0], "DROP") || strings.Contains(conds[0], "name") {
+		t.Errorf("condition contains user input: %q", conds[0])
+	}
+}
+
+func TestContainmentDoc(t *testing.T) {
+	tests := []struct {
+		property string
+		value    interface{}
+		want     string
+		ok       bool
+	}{
+		{"status", "active", `{"status":"active"}`, true},
+		{"metadata.tier", float64(2), `{"metadata":{"tier":2}}`, true},
+		{"flag", nil, `{"flag":null}`, true},
+		{"tags", []interface{}{"a"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := containmentDoc(tt.property, tt.value)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("containmentDoc(%q, %v) = %q, %v; want %q, %v", tt.property, tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFilterCompiler_TooManyFilters(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+	filters := make([]SearchFilter, maxFilters+1)
+	for i := range filters {
+		filters[i] = SearchFilter{Property: "status", Operator: "eq", Value: "x"}
+	}
+	if _, err := fc.Where(newQueryArgs(), filters); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestFilterCompiler_OrderBy(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+
+	tests := []struct {
+		orderBy, dir string
+		want         string
+		wantErr      bool
+	}{
+		{"", "", "created_at DESC", false},
+		{"identifier", "desc", "identifier DESC", false},
+		{"title", "", "title ASC", false},
+		{"metadata.tier", "asc", "data #> $1::text[] ASC NULLS LAST", false},
+		{"unknown", "asc", "", true},
+		{"identifier; DROP TABLE entities", "", "", true},
+		{"identifier", "sideways", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := fc.OrderBy(newQueryArgs(), tt.orderBy, tt.dir)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("OrderBy(%q, %q) error = %v, wantErr %v", tt.orderBy, tt.dir, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("OrderBy(%q, %q) = %q, want %q", tt.orderBy, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestFiltersApply(t *testing.T) {
+	fc := NewFilterCompiler(map[string]interface{}{
+		"properties": map[string]interface{}{
+			"lifecycle": map[string]interface{}{"type": "string"},
+		},
+	})
+	tests := []struct {
+		name    string
+		filters []SearchFilter
+		want    bool
+		wantErr bool
+	}{
+		{"no filters", nil, true, false},
+		{"declared property", []SearchFilter{{Property: "lifecycle", Operator: "eq", Value: "production"}}, true, false},
+		{"unknown property skips blueprint", []SearchFilter{{Property: "language", Operator: "eq", Value: "go"}}, false, false},
+		{"malformed property is an error", []SearchFilter{{Property: "life cycle", Operator: "eq", Value: "x"}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := filtersApply(fc, tt.filters)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
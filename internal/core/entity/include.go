@@ -0,0 +1,196 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+var ErrInvalidInclude = errors.New("invalid include")
+
+const (
+	// maxIncludes caps the relations one request can include
+	maxIncludes = 10
+	// maxIncludedTargets caps the entities included per entity and relation
+	maxIncludedTargets = 100
+)
+
+// ParseInclude reads a comma-separated list such as
+// "relations.owner,relations.dependencies,sources" into relation identifiers
+// and whether property sources are included. An empty list includes nothing
+// and returns no relations.
+func ParseInclude(raw string) ([]string, bool, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, false, nil
+	}
+	var relations []string
+	sources := false
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "sources" {
+			sources = true
+			continue
+		}
+		name, ok := strings.CutPrefix(item, "relations.")
+		if !ok || name == "" {
+			return nil, false, fmt.Errorf("%w: %q, use relations.<relation> or sources", ErrInvalidInclude, item)
+		}
+		if !slices.Contains(relations, name) {
+			relations = append(relations, name)
+		}
+	}
+	if len(relations) > maxIncludes {
+		return nil, false, fmt.Errorf("%w: at most %d relations can be included", ErrInvalidInclude, maxIncludes)
+	}
+	return relations, sources, nil
+}
+
+// Inclusion holds the entities a set of entities link to under the
+// included relations, and the sources of their properties
+type Inclusion struct {
+	relations []*Relation
+	// targets are keyed by source entity, then relation
+	targets map[uuid.UUID]map[uuid.UUID][]*Entity
+	// sources are keyed by entity, then property; nil when not included
+	sources map[uuid.UUID]map[string]*PropertyProvenance
+}
+
+// Related returns the "relations" object of an entity: each included
+// relation maps to its target, or null, when the relation is -to-one and to
+// the list of targets otherwise. It returns nil when no relation is
+// included.
+func (in *Inclusion) Related(id uuid.UUID) map[string]interface{} {
+	if len(in.relations) == 0 {
+		return nil
+	}
+	related := make(map[string]interface{}, len(in.relations))
+	for _, rel := range in.relations {
+		targets := in.targets[id][rel.ID]
+		switch {
+		case rel.SingleTarget() && len(targets) == 0:
+			related[rel.Identifier] = nil
+		case rel.SingleTarget():
+			related[rel.Identifier] = targets[0]
+		case targets == nil:
+			related[rel.Identifier] = []*Entity{}
+		default:
+			related[rel.Identifier] = targets
+		}
+	}
+	return related
+}
+
+// Sources returns the "sources" object of an entity, its properties'
+// provenance by property, or nil when sources are not included
+func (in *Inclusion) Sources(id uuid.UUID) map[string]*PropertyProvenance {
+	if in.sources == nil {
+		return nil
+	}
+	if sources, ok := in.sources[id]; ok {
+		return sources
+	}
+	return map[string]*PropertyProvenance{}
+}
+
+// Include fetches the entities that entities of a blueprint link to under
+// the relations named in include, in one query for all of them, and the
+// sources of their properties when include names sources. Included entities
+// are redacted like the entities themselves, and only the sources of
+// properties the entities show are included. It returns nil when include is
+// empty.
+func (s *Service) Include(ctx context.Context, teamID uuid.UUID, blueprintID string, entities []*Entity, include string) (*Inclusion, error) {
+	names, withSources, err := ParseInclude(include)
+	if err != nil || (names == nil && !withSources) {
+		return nil, err
+	}
+
+	in := &Inclusion{targets: make(map[uuid.UUID]map[uuid.UUID][]*Entity)}
+	if names != nil {
+		if err := s.includeRelations(ctx, teamID, blueprintID, entities, names, in); err != nil {
+			return nil, err
+		}
+	}
+	if withSources {
+		if in.sources, err = s.includeSources(ctx, teamID, entities); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+// includeRelations adds the targets of the named relations to in
+func (s *Service) includeRelations(ctx context.Context, teamID uuid.UUID, blueprintID string, entities []*Entity, names []string, in *Inclusion) error {
+	all, err := s.repo.ListRelations(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	relationIDs := make([]uuid.UUID, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(all, func(rel *Relation) bool { return rel.Source == blueprintID && rel.Identifier == name })
+		if i < 0 {
+			return fmt.Errorf("%w: blueprint %s has no relation %q", ErrInvalidInclude, blueprintID, name)
+		}
+		in.relations = append(in.relations, all[i])
+		relationIDs = append(relationIDs, all[i].ID)
+	}
+	if len(entities) == 0 {
+		return nil
+	}
+
+	sourceIDs := make([]uuid.UUID, len(entities))
+	for i, e := range entities {
+		sourceIDs[i] = e.ID
+	}
+	related, err := s.repo.RelatedEntities(ctx, teamID, relationIDs, sourceIDs, maxIncludedTargets)
+	if err != nil {
+		return err
+	}
+	targets := make([]*Entity, len(related))
+	for i, r := range related {
+		targets[i] = r.Entity
+	}
+	if targets, err = s.Redact(ctx, targets...); err != nil {
+		return err
+	}
+	for i, r := range related {
+		if in.targets[r.SourceID] == nil {
+			in.targets[r.SourceID] = make(map[uuid.UUID][]*Entity)
+		}
+		in.targets[r.SourceID][r.RelationID] = append(in.targets[r.SourceID][r.RelationID], targets[i])
+	}
+	return nil
+}
+
+// includeSources returns the provenance of the properties each entity
+// shows, by entity and property
+func (s *Service) includeSources(ctx context.Context, teamID uuid.UUID, entities []*Entity) (map[uuid.UUID]map[string]*PropertyProvenance, error) {
+	synced, err := s.integrationSyncTimes(ctx, teamID, entities)
+	if err != nil {
+		return nil, err
+	}
+	blueprints := map[string]*blueprint.Blueprint{}
+	sources := make(map[uuid.UUID]map[string]*PropertyProvenance, len(entities))
+	for _, e := range entities {
+		bp, ok := blueprints[e.BlueprintID]
+		if !ok {
+			if bp, err = s.blueprintSvc.Get(ctx, e.TeamID, e.BlueprintID); err != nil {
+				return nil, err
+			}
+			blueprints[e.BlueprintID] = bp
+		}
+		provenances := make(map[string]*PropertyProvenance, len(e.Sources))
+		for property, source := range e.Sources {
+			if _, shown := e.Data[property]; shown {
+				provenances[property] = provenance(bp, property, source, synced)
+			}
+		}
+		sources[e.ID] = provenances
+	}
+	return sources, nil
+}
@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestParseInclude(t *testing.T) {
+	tests := []struct {
+		raw         string
+		want        []string
+		wantSources bool
+		wantErr     bool
+	}{
+		{"", nil, false, false},
+		{"relations.owner", []string{"owner"}, false, false},
+		{"relations.owner, relations.dependencies,relations.owner", []string{"owner", "dependencies"}, false, false},
+		{"sources", nil, true, false},
+		{"relations.owner,sources", []string{"owner"}, true, false},
+		{"owner", nil, false, true},
+		{"relations.", nil, false, true},
+		{"relations.owner,", nil, false, true},
+		// duplicates do not count towards the limit
+		{strings.Repeat("relations.a,", maxIncludes) + "relations.b", []string{"a", "b"}, false, false},
+	}
+
+	for _, tt := range tests {
+		got, sources, err := ParseInclude(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseInclude(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if err != nil && !errors.Is(err, ErrInvalidInclude) {
+			t.Errorf("ParseInclude(%q) error should wrap ErrInvalidInclude, got %v", tt.raw, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) || sources != tt.wantSources {
+			t.Errorf("ParseInclude(%q) = %v, %v, want %v, %v", tt.raw, got, sources, tt.want, tt.wantSources)
+		}
+	}
+
+	var many []string
+	for i := 0; i <= maxIncludes; i++ {
+		many = append(many, "relations.r"+strings.Repeat("x", i))
+	}
+	if _, _, err := ParseInclude(strings.Join(many, ",")); !errors.Is(err, ErrInvalidInclude) {
+		t.Errorf("expected more than %d relations to be rejected, got %v", maxIncludes, err)
+	}
+}
+
+func TestInclusion_Related(t *testing.T) {
+	owner := &Relation{ID: uuid.New(), Identifier: "owner", Type: "many-to-one"}
+	deps := &Relation{ID: uuid.New(), Identifier: "dependencies", Type: "many-to-many"}
+	source, other := uuid.New(), uuid.New()
+	team := &Entity{Identifier: "platform"}
+	ledger := &Entity{Identifier: "ledger"}
+
+	in := &Inclusion{
+		relations: []*Relation{owner, deps},
+		targets: map[uuid.UUID]map[uuid.UUID][]*Entity{
+			source: {owner.ID: {team}, deps.ID: {ledger}},
+		},
+	}
+
+	got := in.Related(source)
+	if got["owner"] != team {
+		t.Errorf("owner = %v, want the single target", got["owner"])
+	}
+	if list, ok := got["dependencies"].([]*Entity); !ok || len(list) != 1 || list[0] != ledger {
+		t.Errorf("dependencies = %v, want the list of targets", got["dependencies"])
+	}
+
+	got = in.Related(other)
+	if v, ok := got["owner"]; !ok || v != nil {
+		t.Errorf("owner without a target = %v, want null", v)
+	}
+	if list, ok := got["dependencies"].([]*Entity); !ok || list == nil || len(list) != 0 {
+		t.Errorf("dependencies without targets = %v, want an empty list", got["dependencies"])
+	}
+}
+
+func TestInclusion_Sources(t *testing.T) {
+	id := uuid.New()
+	if got := (&Inclusion{}).Sources(id); got != nil {
+		t.Errorf("Sources without sources included = %v, want nil", got)
+	}
+	if got := (&Inclusion{}).Related(id); got != nil {
+		t.Errorf("Related without relations included = %v, want nil", got)
+	}
+	version := &PropertyProvenance{Property: "version"}
+	in := &Inclusion{sources: map[uuid.UUID]map[string]*PropertyProvenance{id: {"version": version}}}
+	if got := in.Sources(id); got["version"] != version {
+		t.Errorf("Sources = %v", got)
+	}
+	if got := in.Sources(uuid.New()); got == nil || len(got) != 0 {
+		t.Errorf("Sources of an entity without sources = %v, want an empty object", got)
+	}
+}
@@ -1,20 +1,68 @@
 package entity
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/validation"
 )
 
 type Entity struct {
-	ID          uuid.UUID              `json:"id"`
-	TeamID      uuid.UUID              `json:"team_id"`
-	BlueprintID string                 `json:"blueprint_id"`
-	Identifier  string                 `json:"identifier"`
-	Title       string                 `json:"title,omitempty"`
-	Data        map[string]interface{} `json:"data"`
-	CreatedAt   time.Time              `json:"created_at"`
-	UpdatedAt   time.Time              `json:"updated_at"`
+	ID            uuid.UUID              `json:"id"`
+	TeamID        uuid.UUID              `json:"team_id"`
+	BlueprintID   string                 `json:"blueprint_id"`
+	Identifier    string                 `json:"identifier"`
+	Title         string                 `json:"title,omitempty"`
+	Data          map[string]interface{} `json:"data"`
+	Version       int64                  `json:"version"`                  // incremented by every update
+	IntegrationID *uuid.UUID             `json:"integration_id,omitempty"` // the exporter that owns the entity, see Reconcile
+	ExpiresAt     *time.Time             `json:"expires_at,omitempty"`     // set by the blueprint's expiry policy
+	CreatedAt     time.Time              `json:"created_at"`
+	UpdatedAt     time.Time              `json:"updated_at"`
+
+	// Sources records who last wrote each top-level data property. It is
+	// served by the sources endpoint rather than with the entity.
+	Sources map[string]PropertySource `json:"-"`
+}
+
+// Property source types
+const (
+	SourceUser        = "user"
+	SourceAPIKey      = "api_key"
+	SourceIntegration = "integration"
+	SourceSystem      = "system" // background work without an actor
+)
+
+// PropertySource is the writer of a data property. ID is the user, API key
+// or integration; it is empty for system writes.
+type PropertySource struct {
+	Type string     `json:"type"`
+	ID   *uuid.UUID `json:"id,omitempty"`
+	At   time.Time  `json:"at"`
+}
+
+// PropertyProvenance is the source of one property and the merge policy
+// that applies to it
+type PropertyProvenance struct {
+	Property string `json:"property"`
+	PropertySource
+	Policy string `json:"policy"`
+	// Ranking is set for properties under the precedence policy
+	Ranking []string `json:"ranking,omitempty"`
+	// LastSyncedAt is when the integration that wrote the property last
+	// completed a sync, which confirms values it left unchanged
+	LastSyncedAt *time.Time `json:"last_synced_at,omitempty"`
+}
+
+// SourcesResponse lists the sources of an entity's properties by name.
+// Properties written before sources were recorded have none.
+type SourcesResponse struct {
+	EntityID   uuid.UUID             `json:"entity_id"`
+	Properties []*PropertyProvenance `json:"properties"`
 }
 
 type CreateEntityRequest struct {
@@ -24,14 +72,63 @@ type CreateEntityRequest struct {
 }
 
 type UpdateEntityRequest struct {
-	Title string                 `json:"title"`
-	Data  map[string]interface{} `json:"data"`
+	// Identifier may be sent back unchanged; changing it requires a rename
+	Identifier string                 `json:"identifier,omitempty"`
+	Title      string                 `json:"title"`
+	Data       map[string]interface{} `json:"data"`
+}
+
+// ValidationResult is a write checked with validate_only or dry_run: the
+// entity the write would store and what it changes, or why it is invalid
+type ValidationResult struct {
+	Valid   bool                         `json:"valid"`
+	Errors  []validation.ValidationError `json:"errors"`
+	Entity  *Entity                      `json:"entity,omitempty"`
+	Changes []Change                     `json:"changes"`
+}
+
+// RenameEntityRequest changes an entity's identifier. Only blueprints with
+// identifier_mutable set allow it.
+type RenameEntityRequest struct {
+	Identifier string `json:"identifier" binding:"required,max=255"`
 }
 
+// Rename is the payload of entity.renamed events
+type Rename struct {
+	Entity             *Entity `json:"entity"`
+	PreviousIdentifier string  `json:"previous_identifier"`
+}
+
+// SearchFilter is a condition on one property, or a group of filters when
+// Combinator or Rules is set: {"combinator": "or", "rules": [...]}
 type SearchFilter struct {
 	Property string      `json:"property"`
-	Operator string      `json:"operator"` // eq, neq, gt, lt, gte, lte, contains, exists
+	Operator string      `json:"operator"` // eq, neq, gt, lt, gte, lte, before, after, contains, contains_any, contains_all, exists, is_null, in, not_in
 	Value    interface{} `json:"value"`
+
+	Combinator string         `json:"combinator,omitempty"` // and (default), or, not
+	Rules      []SearchFilter `json:"rules,omitempty"`
+}
+
+// IsGroup reports whether the filter combines other filters
+func (f SearchFilter) IsGroup() bool {
+	return f.Combinator != "" || f.Rules != nil
+}
+
+// MarshalJSON writes groups without the property fields, and property
+// filters as before groups existed
+func (f SearchFilter) MarshalJSON() ([]byte, error) {
+	if f.IsGroup() {
+		return json.Marshal(struct {
+			Combinator string         `json:"combinator,omitempty"`
+			Rules      []SearchFilter `json:"rules"`
+		}{f.Combinator, f.Rules})
+	}
+	return json.Marshal(struct {
+		Property string      `json:"property"`
+		Operator string      `json:"operator"`
+		Value    interface{} `json:"value"`
+	}{f.Property, f.Operator, f.Value})
 }
 
 type SearchRequest struct {
@@ -40,11 +137,271 @@ type SearchRequest struct {
 	OrderDir string         `json:"order_dir"` // asc, desc
 	Limit    int            `json:"limit"`
 	Offset   int            `json:"offset"`
+	// Fields is a sparse fieldset, e.g. "identifier,title,data.language"
+	Fields string `json:"fields,omitempty"`
 }
 
 type ListEntitiesResponse struct {
-	Entities []*Entity `json:"entities"`
-	Total    int       `json:"total"`
-	Limit    int       `json:"limit"`
-	Offset   int       `json:"offset"`
+	Entities []*Entity    `json:"entities"`
+	Total    int          `json:"total"`
+	Limit    int          `json:"limit"`
+	Offset   int          `json:"offset"`
+	View     *AppliedView `json:"view,omitempty"`
+}
+
+// AppliedView identifies the saved view a list response was filtered by
+type AppliedView struct {
+	ID      uuid.UUID `json:"id"`
+	Name    string    `json:"name"`
+	Columns []string  `json:"columns"`
+}
+
+// CrossSearchRequest searches every blueprint of a team with the same filters.
+// Blueprints whose schema lacks a filtered property are skipped. Limit applies
+// per blueprint; ordering is restricted to entity columns, which all blueprints share.
+type CrossSearchRequest struct {
+	Blueprints []string       `json:"blueprints"` // optional subset of blueprint IDs
+	Filters    []SearchFilter `json:"filters"`
+	OrderBy    string         `json:"order_by"`
+	OrderDir   string         `json:"order_dir"`
+	Limit      int            `json:"limit"`
+}
+
+// BlueprintResults are the matches of a cross-blueprint search within one blueprint
+type BlueprintResults struct {
+	BlueprintID    string    `json:"blueprint_id"`
+	BlueprintTitle string    `json:"blueprint_title"`
+	Entities       []*Entity `json:"entities"`
+	Total          int       `json:"total"`
+}
+
+type CrossSearchResponse struct {
+	Results []BlueprintResults `json:"results"`
+	Total   int                `json:"total"`
+	Limit   int                `json:"limit"`
+}
+
+// Aggregation functions supported by Aggregate
+const (
+	AggregateCount = "count"
+	AggregateSum   = "sum"
+	AggregateAvg   = "avg"
+	AggregateMin   = "min"
+	AggregateMax   = "max"
+)
+
+// AggregateRequest computes a single aggregate over a blueprint's entities,
+// optionally grouped by a property. Property is required for every function except count.
+type AggregateRequest struct {
+	Function string         `json:"function"`
+	Property string         `json:"property"`
+	GroupBy  string         `json:"group_by"`
+	Filters  []SearchFilter `json:"filters"`
+}
+
+// AggregateBucket is one group of an aggregate. Key is empty when no grouping was requested.
+type AggregateBucket struct {
+	Key   string  `json:"key"`
+	Value float64 `json:"value"`
+}
+
+// Import and export file formats
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// ExportRequest selects the entities of an export. Without filters every
+// entity of the blueprint is exported; without order_by, in creation order.
+type ExportRequest struct {
+	Format   string         `json:"format"`
+	Filters  []SearchFilter `json:"filters,omitempty"`
+	OrderBy  string         `json:"order_by,omitempty"`
+	OrderDir string         `json:"order_dir,omitempty"`
+	// Redacted are the restricted properties left out of the export because
+	// its requester may not read them
+	Redacted []string `json:"redacted,omitempty"`
+}
+
+// Import modes: create rejects rows whose identifier exists, upsert updates them
+const (
+	ImportCreate = "create"
+	ImportUpsert = "upsert"
+)
+
+// ImportOptions control how an import is applied. A dry run validates every
+// row and reports what would change without writing anything.
+type ImportOptions struct {
+	Mode   string
+	DryRun bool
+}
+
+// normalize defaults the mode to create and rejects unknown modes
+func (o *ImportOptions) normalize() error {
+	switch o.Mode {
+	case "":
+		o.Mode = ImportCreate
+	case ImportCreate, ImportUpsert:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidImport, o.Mode)
+	}
+	return nil
+}
+
+// ImportRowError reports why one row was not imported. Row is the CSV record
+// number (the header is row 1) or the NDJSON line number.
+type ImportRowError struct {
+	Row        int                          `json:"row"`
+	Blueprint  string                       `json:"blueprint,omitempty"` // set by catalog imports
+	Identifier string                       `json:"identifier,omitempty"`
+	Error      string                       `json:"error"`
+	Details    []validation.ValidationError `json:"details,omitempty"`
+}
+
+type ImportResult struct {
+	DryRun    bool   `json:"dry_run"`
+	Mode      string `json:"mode"`
+	Total     int    `json:"total"`
+	Created   int    `json:"created"`
+	Updated   int    `json:"updated"`
+	Unchanged int    `json:"unchanged"`
+	Failed    int    `json:"failed"`
+	// Relations counts the relations set by a catalog import
+	Relations int              `json:"relations,omitempty"`
+	Errors    []ImportRowError `json:"errors"`
+	// Changes lists what the rows of a dry run would write; unchanged rows
+	// are left out
+	Changes []ImportRowChange `json:"changes,omitempty"`
+}
+
+// CatalogImportRow is one line of a catalog import: an entity of any of the
+// team's blueprints with the relations it links by. Each relation maps to the
+// identifier of one target entity, or to a list of them.
+type CatalogImportRow struct {
+	Blueprint  string                     `json:"blueprint"`
+	Identifier string                     `json:"identifier"`
+	Title      string                     `json:"title,omitempty"`
+	Data       map[string]interface{}     `json:"data"`
+	Relations  map[string]json.RawMessage `json:"relations,omitempty"`
+}
+
+// Relation is a blueprint relation: entities of Source link to entities of
+// Target under Identifier
+type Relation struct {
+	ID         uuid.UUID
+	Source     string
+	Identifier string
+	Target     string
+	Type       string
+	Required   bool
+}
+
+// SingleTarget reports whether an entity links to at most one target
+func (r *Relation) SingleTarget() bool {
+	return strings.HasSuffix(r.Type, "-to-one")
+}
+
+// HistoryActor is who wrote a revision. Email and name are empty for API keys
+// without a user and for changes made by the server itself.
+type HistoryActor struct {
+	UserID   *uuid.UUID `json:"user_id,omitempty"`
+	APIKeyID *uuid.UUID `json:"api_key_id,omitempty"`
+	Email    string     `json:"email,omitempty"`
+	Name     string     `json:"name,omitempty"`
+}
+
+// Revision is an entity as written by one create, update or delete
+type Revision struct {
+	Version   int64                  `json:"version"`
+	Action    string                 `json:"action"` // created, updated or deleted
+	Title     string                 `json:"title,omitempty"`
+	Data      map[string]interface{} `json:"data"`
+	Actor     HistoryActor           `json:"actor"`
+	ChangedAt time.Time              `json:"changed_at"`
+}
+
+// PropertyChange is a revision that set, changed or removed one property
+type PropertyChange struct {
+	Version   int64        `json:"version"`
+	Action    string       `json:"action"`
+	Value     interface{}  `json:"value"`
+	Present   bool         `json:"present"` // false when the property is absent after this revision
+	Actor     HistoryActor `json:"actor"`
+	ChangedAt time.Time    `json:"changed_at"`
+}
+
+// HistoryResponse lists revisions ([]*Revision), or the changes of one
+// property ([]*PropertyChange) when Property is set, oldest first
+type HistoryResponse struct {
+	EntityID uuid.UUID   `json:"entity_id"`
+	Property string      `json:"property,omitempty"`
+	History  interface{} `json:"history"`
+	Total    int         `json:"total"`
+	Limit    int         `json:"limit"`
+	Offset   int         `json:"offset"`
+}
+
+// PropertyUsage counts how one property was used over a report window
+type PropertyUsage struct {
+	Property  string     `json:"property"`
+	Filter    int64      `json:"filter"`
+	Sort      int64      `json:"sort"`
+	Group     int64      `json:"group"`
+	Aggregate int64      `json:"aggregate"`
+	Column    int64      `json:"column"`
+	Total     int64      `json:"total"`
+	LastUsed  *time.Time `json:"last_used,omitempty"`
+	InSchema  bool       `json:"in_schema"`
+	Indexed   bool       `json:"indexed"`
+	Hint      string     `json:"hint,omitempty"` // consider_index, consider_dropping_index, unused or not_in_schema
+}
+
+// ValueCount is a value of a property and how many sampled entities have it
+type ValueCount struct {
+	Value    interface{} `json:"value"`
+	Count    int64       `json:"count"`
+	Fraction float64     `json:"fraction"`
+}
+
+// ColumnStats describes the values of one property over sampled entities
+type ColumnStats struct {
+	Property string `json:"property"`
+	Type     string `json:"type,omitempty"`
+	InSchema bool   `json:"in_schema"`
+	Indexed  bool   `json:"indexed"`
+	// Uses counts filters and sorts on the property over the usage window;
+	// nil when usage is not tracked
+	Uses         *int64  `json:"uses,omitempty"`
+	NullFraction float64 `json:"null_fraction"`
+	Distinct     int64   `json:"distinct"`
+	// DistinctRatio is distinct values per non-null value, 1 when all differ
+	DistinctRatio float64      `json:"distinct_ratio"`
+	Cardinality   string       `json:"cardinality"` // none, constant, low, high or unique
+	Min           *float64     `json:"min,omitempty"`
+	Max           *float64     `json:"max,omitempty"`
+	TopValues     []ValueCount `json:"top_values"`
+	Hint          string       `json:"hint,omitempty"` // consider_index or low_selectivity
+}
+
+// ColumnStatsResponse summarizes a blueprint's data for query planning
+type ColumnStatsResponse struct {
+	BlueprintID string `json:"blueprint_id"`
+	Entities    int64  `json:"entities"`
+	// Sampled is how many entities the statistics were computed from; Exact
+	// is true when that is all of them
+	Sampled int64 `json:"sampled"`
+	Exact   bool  `json:"exact"`
+	// UsageDays is the window of the properties' uses, 0 when usage is not
+	// tracked
+	UsageDays   int            `json:"usage_days"`
+	Properties  []*ColumnStats `json:"properties"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+// PropertyUsageResponse reports a blueprint's property usage since a day
+type PropertyUsageResponse struct {
+	BlueprintID string           `json:"blueprint_id"`
+	Days        int              `json:"days"`
+	Since       string           `json:"since"`
+	Properties  []*PropertyUsage `json:"properties"`
 }
@@ -0,0 +1,332 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Patch media types accepted by PATCH /entities/:id
+const (
+	MergePatchType = "application/merge-patch+json" // RFC 7386
+	JSONPatchType  = "application/json-patch+json"  // RFC 6902
+)
+
+var (
+	ErrInvalidPatch    = errors.New("invalid patch")
+	ErrPatchTestFailed = errors.New("patch test failed")
+)
+
+// Patch is a parsed merge patch or JSON patch. Both apply to the entity
+// document {"title": ..., "data": {...}}, so paths into the data start with
+// /data, e.g. /data/tags/0.
+type Patch struct {
+	merge      map[string]interface{}
+	operations []patchOperation
+}
+
+// patchOperation is one RFC 6902 operation. Value is kept raw so that an
+// explicit null can be told apart from a missing value.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ParsePatch parses a patch body of the given media type
+func ParsePatch(mediaType string, body []byte) (*Patch, error) {
+	switch mediaType {
+	case MergePatchType:
+		var merge map[string]interface{}
+		if err := json.Unmarshal(body, &merge); err != nil || merge == nil {
+			return nil, fmt.Errorf("%w: a merge patch must be a JSON object", ErrInvalidPatch)
+		}
+		return &Patch{merge: merge}, nil
+	case JSONPatchType:
+		var operations []patchOperation
+		if err := json.Unmarshal(body, &operations); err != nil {
+			return nil, fmt.Errorf("%w: a JSON patch must be an array of operations", ErrInvalidPatch)
+		}
+		for i, op := range operations {
+			if err := op.check(); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+		}
+		return &Patch{operations: operations}, nil
+	}
+	return nil, fmt.Errorf("%w: %q; use %s or %s", ErrUnsupportedFormat, mediaType, MergePatchType, JSONPatchType)
+}
+
+func (op *patchOperation) check() error {
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return fmt.Errorf("%s requires a value", op.Op)
+		}
+	case "move", "copy":
+		if _, err := parsePointer(op.From); err != nil {
+			return fmt.Errorf("from: %v", err)
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	if _, err := parsePointer(op.Path); err != nil {
+		return fmt.Errorf("path: %v", err)
+	}
+	if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+		return errors.New("cannot move a value into one of its children")
+	}
+	return nil
+}
+
+// Apply returns the patched document. doc is not modified. Operations are
+// applied in order and the first failure discards them all.
+func (p *Patch) Apply(doc map[string]interface{}) (map[string]interface{}, error) {
+	var result interface{} = clone(doc)
+	if p.merge != nil {
+		result = mergePatch(result, p.merge)
+	}
+	for i, op := range p.operations {
+		var err error
+		if result, err = op.apply(result); err != nil {
+			if errors.Is(err, ErrPatchTestFailed) {
+				return nil, fmt.Errorf("%w: operation %d: %s", ErrPatchTestFailed, i, op.Path)
+			}
+			return nil, fmt.Errorf("%w: operation %d (%s %s): %v", ErrInvalidPatch, i, op.Op, op.Path, err)
+		}
+	}
+	patched, ok := result.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: the patched document must be an object", ErrInvalidPatch)
+	}
+	return patched, nil
+}
+
+// mergePatch applies an RFC 7386 merge patch: objects merge recursively, null
+// removes a key and any other value replaces the target
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = mergePatch(t[k], v)
+	}
+	return t
+}
+
+func (op *patchOperation) apply(doc interface{}) (interface{}, error) {
+	path, _ := parsePointer(op.Path)
+	switch op.Op {
+	case "add":
+		return addPointer(doc, path, op.value())
+	case "remove":
+		doc, _, err := removePointer(doc, path)
+		return doc, err
+	case "replace":
+		if _, err := getPointer(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return op.value(), nil
+		}
+		return modifyPointer(doc, path, func(parent interface{}, key string) (interface{}, error) {
+			switch p := parent.(type) {
+			case map[string]interface{}:
+				p[key] = op.value()
+			case []interface{}:
+				i, _ := arrayIndex(key, len(p), false)
+				p[i] = op.value()
+			}
+			return parent, nil
+		})
+	case "move":
+		from, _ := parsePointer(op.From)
+		doc, value, err := removePointer(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addPointer(doc, path, value)
+	case "copy":
+		from, _ := parsePointer(op.From)
+		value, err := getPointer(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return addPointer(doc, path, clone(value))
+	case "test":
+		value, err := getPointer(doc, path)
+		if err != nil || !reflect.DeepEqual(value, op.value()) {
+			return nil, ErrPatchTestFailed
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op %q", op.Op)
+}
+
+// value decodes the operation value; check guarantees it is valid JSON
+func (op *patchOperation) value() interface{} {
+	var v interface{}
+	_ = json.Unmarshal(op.Value, &v)
+	return v
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses an array index token. "-" and length itself are only
+// valid when appending.
+func arrayIndex(token string, length int, appending bool) (int, error) {
+	if token == "-" && appending {
+		return length, nil
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || token[0] == '+' || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("%q is not an array index", token)
+	}
+	if i > length || (i == length && !appending) {
+		return 0, fmt.Errorf("index %d is out of range", i)
+	}
+	return i, nil
+}
+
+func getPointer(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		var err error
+		if doc, err = pointerChild(doc, token); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func pointerChild(node interface{}, token string) (interface{}, error) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		v, ok := n[token]
+		if !ok {
+			return nil, fmt.Errorf("%q does not exist", token)
+		}
+		return v, nil
+	case []interface{}:
+		i, err := arrayIndex(token, len(n), false)
+		if err != nil {
+			return nil, err
+		}
+		return n[i], nil
+	}
+	return nil, fmt.Errorf("%q is not inside an object or array", token)
+}
+
+// modifyPointer calls fn with the container holding the last token of path and
+// stores the container it returns, which may be a new slice, in its parent
+func modifyPointer(node interface{}, path []string, fn func(parent interface{}, key string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(node, path[0])
+	}
+	next, err := pointerChild(node, path[0])
+	if err != nil {
+		return nil, err
+	}
+	updated, err := modifyPointer(next, path[1:], fn)
+	if err != nil {
+		return nil, err
+	}
+	switch n := node.(type) {
+	case map[string]interface{}:
+		n[path[0]] = updated
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(n), false)
+		n[i] = updated
+	}
+	return node, nil
+}
+
+func addPointer(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return modifyPointer(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[key] = value
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(key, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			return append(p[:i], append([]interface{}{value}, p[i:]...)...), nil
+		}
+		return nil, fmt.Errorf("%q is not inside an object or array", key)
+	})
+}
+
+// removePointer deletes the value at path and returns it
+func removePointer(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	var removed interface{}
+	doc, err := modifyPointer(doc, path, func(parent interface{}, key string) (interface{}, error) {
+		v, err := pointerChild(parent, key)
+		if err != nil {
+			return nil, err
+		}
+		removed = v
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			delete(p, key)
+			return p, nil
+		case []interface{}:
+			i, _ := arrayIndex(key, len(p), false)
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return parent, nil
+	})
+	return doc, removed, err
+}
+
+// clone deep-copies decoded JSON so patches never modify the stored entity
+func clone(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(t))
+		for k, e := range t {
+			c[k] = clone(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(t))
+		for i, e := range t {
+			c[i] = clone(e)
+		}
+		return c
+	}
+	return v
+}
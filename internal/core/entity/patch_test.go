@@ -0,0 +1,147 @@
+package entity
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func decodeDoc(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatalf("bad test document %s: %v", s, err)
+	}
+	return doc
+}
+
+func TestPatch_Apply(t *testing.T) {
+	const doc = `{"title":"API","data":{"owner":"team-a","tags":["go","api"],"links":{"docs":"https://d","repo":"https://r"}}}`
+
+	tests := []struct {
+		name      string
+		mediaType string
+		patch     string
+		want      string
+		wantErr   error
+	}{
+		{
+			name:      "merge replaces and deletes keys",
+			mediaType: MergePatchType,
+			patch:     `{"data":{"owner":"team-b","links":{"docs":null}}}`,
+			want:      `{"title":"API","data":{"owner":"team-b","tags":["go","api"],"links":{"repo":"https://r"}}}`,
+		},
+		{
+			name:      "merge replaces arrays whole",
+			mediaType: MergePatchType,
+			patch:     `{"data":{"tags":["rust"]},"title":"Gateway"}`,
+			want:      `{"title":"Gateway","data":{"owner":"team-a","tags":["rust"],"links":{"docs":"https://d","repo":"https://r"}}}`,
+		},
+		{
+			name:      "merge patch must be an object",
+			mediaType: MergePatchType,
+			patch:     `["data"]`,
+			wantErr:   ErrInvalidPatch,
+		},
+		{
+			name:      "json patch edits nested arrays",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"add","path":"/data/tags/1","value":"grpc"},{"op":"add","path":"/data/tags/-","value":"v2"},{"op":"remove","path":"/data/tags/0"}]`,
+			want:      `{"title":"API","data":{"owner":"team-a","tags":["grpc","api","v2"],"links":{"docs":"https://d","repo":"https://r"}}}`,
+		},
+		{
+			name:      "json patch removes, replaces, moves and copies",
+			mediaType: JSONPatchType,
+			patch: `[{"op":"remove","path":"/data/links/docs"},{"op":"replace","path":"/data/owner","value":null},` +
+				`{"op":"move","from":"/data/links/repo","path":"/data/repo"},{"op":"copy","from":"/data/tags","path":"/data/labels"}]`,
+			want: `{"title":"API","data":{"owner":null,"tags":["go","api"],"labels":["go","api"],"links":{},"repo":"https://r"}}`,
+		},
+		{
+			name:      "json pointer escapes",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"add","path":"/data/a~1b~0c","value":1}]`,
+			want:      `{"title":"API","data":{"owner":"team-a","tags":["go","api"],"links":{"docs":"https://d","repo":"https://r"},"a/b~c":1}}`,
+		},
+		{
+			name:      "passing test applies",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"test","path":"/data/tags","value":["go","api"]},{"op":"replace","path":"/title","value":"Checked"}]`,
+			want:      `{"title":"Checked","data":{"owner":"team-a","tags":["go","api"],"links":{"docs":"https://d","repo":"https://r"}}}`,
+		},
+		{
+			name:      "failing test",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"test","path":"/data/owner","value":"team-b"},{"op":"remove","path":"/data/owner"}]`,
+			wantErr:   ErrPatchTestFailed,
+		},
+		{
+			name:      "remove missing key",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"remove","path":"/data/missing"}]`,
+			wantErr:   ErrInvalidPatch,
+		},
+		{
+			name:      "replace requires an existing value",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"replace","path":"/data/tags/2","value":"x"}]`,
+			wantErr:   ErrInvalidPatch,
+		},
+		{
+			name:      "index with leading zero",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"add","path":"/data/tags/01","value":"x"}]`,
+			wantErr:   ErrInvalidPatch,
+		},
+		{
+			name:      "add requires a value",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"add","path":"/data/x"}]`,
+			wantErr:   ErrInvalidPatch,
+		},
+		{
+			name:      "move into own child",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"move","from":"/data","path":"/data/nested"}]`,
+			wantErr:   ErrInvalidPatch,
+		},
+		{
+			name:      "unknown op",
+			mediaType: JSONPatchType,
+			patch:     `[{"op":"merge","path":"/data"}]`,
+			wantErr:   ErrInvalidPatch,
+		},
+		{
+			name:      "unsupported media type",
+			mediaType: "application/json",
+			patch:     `{}`,
+			wantErr:   ErrUnsupportedFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := decodeDoc(t, doc)
+			patch, err := ParsePatch(tt.mediaType, []byte(tt.patch))
+			var got map[string]interface{}
+			if err == nil {
+				got, err = patch.Apply(original)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := decodeDoc(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(original, decodeDoc(t, doc)) {
+				t.Error("Apply modified the original document")
+			}
+		})
+	}
+}
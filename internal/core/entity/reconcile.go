@@ -0,0 +1,80 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+var ErrInvalidReconcile = errors.New("invalid reconcile")
+
+// maxReconcileIdentifiers bounds the identifier set of one reconcile request
+const maxReconcileIdentifiers = 100000
+
+// ReconcileRequest is an exporter's complete set of identifiers for one
+// blueprint
+type ReconcileRequest struct {
+	BlueprintID string   `json:"blueprint_id" binding:"required"`
+	Identifiers []string `json:"identifiers"`
+	// Delete removes the stale entities instead of only listing them
+	Delete bool `json:"delete"`
+	// AllowEmpty confirms that an empty set with delete really means every
+	// owned entity is gone, rather than an upstream outage
+	AllowEmpty bool `json:"allow_empty"`
+}
+
+// ReconcileResponse lists the integration's entities that are no longer
+// upstream. Claimed counts entities in the set that the integration took
+// ownership of.
+type ReconcileResponse struct {
+	IntegrationID uuid.UUID `json:"integration_id"`
+	BlueprintID   string    `json:"blueprint_id"`
+	Claimed       int64     `json:"claimed"`
+	Stale         []*Entity `json:"stale"`
+	Deleted       bool      `json:"deleted"`
+}
+
+// Reconcile compares the entities an integration owns in a blueprint with the
+// identifiers its exporter reports. Unowned entities in the set are claimed;
+// owned entities outside it are stale and, with Delete, removed. Entities
+// owned by another integration are left alone.
+func (s *Service) Reconcile(ctx context.Context, teamID, integrationID uuid.UUID, req *ReconcileRequest) (*ReconcileResponse, error) {
+	if len(req.Identifiers) > maxReconcileIdentifiers {
+		return nil, fmt.Errorf("%w: at most %d identifiers are allowed", ErrInvalidReconcile, maxReconcileIdentifiers)
+	}
+	if req.Delete && len(req.Identifiers) == 0 && !req.AllowEmpty {
+		return nil, fmt.Errorf("%w: an empty identifier set would delete every entity the integration owns; set allow_empty to confirm", ErrInvalidReconcile)
+	}
+	if _, err := s.blueprintSvc.Get(ctx, teamID, req.BlueprintID); err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
+		return nil, err
+	}
+
+	claimed, stale, err := s.repo.Reconcile(ctx, teamID, req.BlueprintID, integrationID, req.Identifiers, req.Delete)
+	if err != nil {
+		return nil, err
+	}
+	if stale == nil {
+		stale = []*Entity{}
+	}
+	if req.Delete {
+		for _, e := range stale {
+			s.publish(ctx, events.EntityDeleted, e, nil)
+		}
+	}
+
+	return &ReconcileResponse{
+		IntegrationID: integrationID,
+		BlueprintID:   req.BlueprintID,
+		Claimed:       claimed,
+		Stale:         stale,
+		Deleted:       req.Delete,
+	}, nil
+}
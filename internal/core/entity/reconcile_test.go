@@ -0,0 +1,30 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// Requests are checked before anything is read, so a bare service suffices
+func TestReconcile_RejectsUnsafeRequests(t *testing.T) {
+	s := &Service{}
+	tests := []struct {
+		name string
+		req  *ReconcileRequest
+	}{
+		{"empty set with delete", &ReconcileRequest{BlueprintID: "service", Delete: true}},
+		{"empty list with delete", &ReconcileRequest{BlueprintID: "service", Identifiers: []string{}, Delete: true}},
+		{"too many identifiers", &ReconcileRequest{BlueprintID: "service", Identifiers: make([]string, maxReconcileIdentifiers+1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Reconcile(context.Background(), uuid.New(), uuid.New(), tt.req)
+			if !errors.Is(err, ErrInvalidReconcile) {
+				t.Errorf("Reconcile() error = %v, want ErrInvalidReconcile", err)
+			}
+		})
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"regexp"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 
+	"github.com/baseplate/baseplate/internal/events"
 	"github.com/baseplate/baseplate/internal/storage/postgres"
 )
 
@@ -26,50 +28,151 @@ func (r *Repository) Create(ctx context.Context, entity *Entity) error {
 	if err != nil {
 		return err
 	}
+	sources, err := marshalSources(entity.Sources)
+	if err != nil {
+		return err
+	}
 
 	query := `
-		INSERT INTO entities (id, team_id, blueprint_id, identifier, title, data)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING created_at, updated_at`
+		WITH created AS (
+			INSERT INTO entities (id, team_id, blueprint_id, identifier, title, data, property_sources, expires_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $9, $10)
+			RETURNING ` + historyColumns + `
+		), ` + recordWrite("created", "$7", "$8") + `
+		SELECT version, created_at, updated_at FROM created`
 
+	userID, apiKeyID := historyActor(ctx)
 	return r.db.DB.QueryRowContext(ctx, query,
-		entity.ID, entity.TeamID, entity.BlueprintID, entity.Identifier, entity.Title, data,
-	).Scan(&entity.CreatedAt, &entity.UpdatedAt)
+		entity.ID, entity.TeamID, entity.BlueprintID, entity.Identifier, entity.Title, data, userID, apiKeyID, sources, entity.ExpiresAt,
+	).Scan(&entity.Version, &entity.CreatedAt, &entity.UpdatedAt)
+}
+
+// importColumns are the columns CreateMany copies into its staging table
+var importColumns = []string{"id", "team_id", "blueprint_id", "identifier", "title", "data", "property_sources", "expires_at"}
+
+// CreateMany creates entities in one transaction, streaming them to the
+// server with COPY. It records history and events like Create, and fails as
+// a whole if any entity cannot be inserted.
+func (r *Repository) CreateMany(ctx context.Context, entities []*Entity) error {
+	if len(entities) == 0 {
+		return nil
+	}
+	rows := make([][]interface{}, len(entities))
+	byID := make(map[uuid.UUID]*Entity, len(entities))
+	for i, entity := range entities {
+		data, err := json.Marshal(entity.Data)
+		if err != nil {
+			return err
+		}
+		sources, err := marshalSources(entity.Sources)
+		if err != nil {
+			return err
+		}
+		rows[i] = []interface{}{entity.ID, entity.TeamID, entity.BlueprintID, entity.Identifier, entity.Title, data, sources, entity.ExpiresAt}
+		byID[entity.ID] = entity
+	}
+
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE entity_import (LIKE entities INCLUDING DEFAULTS) ON COMMIT DROP`); err != nil {
+		return err
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"entity_import"}, importColumns, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+
+	query := `
+		WITH created AS (
+			INSERT INTO entities (id, team_id, blueprint_id, identifier, title, data, property_sources, expires_at)
+			SELECT id, team_id, blueprint_id, identifier, title, data, property_sources, expires_at FROM entity_import
+			RETURNING ` + historyColumns + `
+		), ` + recordWrite("created", "$1", "$2") + `
+		SELECT id, version, created_at, updated_at FROM created`
+
+	userID, apiKeyID := historyActor(ctx)
+	created, err := tx.Query(ctx, query, userID, apiKeyID)
+	if err != nil {
+		return err
+	}
+	for created.Next() {
+		var id uuid.UUID
+		var version int64
+		var createdAt, updatedAt time.Time
+		if err := created.Scan(&id, &version, &createdAt, &updatedAt); err != nil {
+			created.Close()
+			return err
+		}
+		if entity := byID[id]; entity != nil {
+			entity.Version, entity.CreatedAt, entity.UpdatedAt = version, createdAt, updatedAt
+		}
+	}
+	created.Close()
+	if err := created.Err(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
 }
 
 func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Entity, error) {
 	query := `
-		SELECT id, team_id, blueprint_id, identifier, title, data, created_at, updated_at
+		SELECT id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
 		FROM entities
 		WHERE id = $1`
 
-	return r.scanEntity(r.db.DB.QueryRowContext(ctx, query, id))
+	return r.scanEntity(r.db.Reader(ctx).QueryRowContext(ctx, query, id))
 }
 
 func (r *Repository) GetByIdentifier(ctx context.Context, teamID uuid.UUID, blueprintID, identifier string) (*Entity, error) {
 	query := `
-		SELECT id, team_id, blueprint_id, identifier, title, data, created_at, updated_at
+		SELECT id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
 		FROM entities
 		WHERE team_id = $1 AND blueprint_id = $2 AND identifier = $3`
 
-	return r.scanEntity(r.db.DB.QueryRowContext(ctx, query, teamID, blueprintID, identifier))
+	return r.scanEntity(r.db.Reader(ctx).QueryRowContext(ctx, query, teamID, blueprintID, identifier))
 }
 
-func (r *Repository) List(ctx context.Context, teamID uuid.UUID, blueprintID string, limit, offset int) ([]*Entity, int, error) {
+// GetByAlias returns the entity a former identifier of the blueprint now
+// belongs to, or nil when no entity was renamed from it
+func (r *Repository) GetByAlias(ctx context.Context, teamID uuid.UUID, blueprintID, identifier string) (*Entity, error) {
+	query := `
+		SELECT e.id, e.team_id, e.blueprint_id, e.identifier, e.title, e.data, e.version, e.integration_id, e.property_sources, e.expires_at, e.created_at, e.updated_at
+		FROM entity_aliases a
+		JOIN entities e ON e.id = a.entity_id
+		WHERE a.team_id = $1 AND a.blueprint_id = $2 AND a.identifier = $3`
+
+	return r.scanEntity(r.db.Reader(ctx).QueryRowContext(ctx, query, teamID, blueprintID, identifier))
+}
+
+// Count returns the number of entities in a blueprint
+func (r *Repository) Count(ctx context.Context, teamID uuid.UUID, blueprintID string) (int, error) {
+	var count int
+	err := r.db.Reader(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM entities WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID).Scan(&count)
+	return count, err
+}
+
+func (r *Repository) List(ctx context.Context, teamID uuid.UUID, blueprintID string, limit, offset int, fields *Fields) ([]*Entity, int, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
 	countQuery := `SELECT COUNT(*) FROM entities WHERE team_id = $1 AND blueprint_id = $2`
 	var total int
-	if err := r.db.DB.QueryRowContext(ctx, countQuery, teamID, blueprintID).Scan(&total); err != nil {
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, countQuery, teamID, blueprintID).Scan(&total); err != nil {
 		return nil, 0, err
 	}
 
-	query := `
-		SELECT id, team_id, blueprint_id, identifier, title, data, created_at, updated_at
+	args := newQueryArgs(teamID, blueprintID, limit, offset)
+	query := fmt.Sprintf(`
+		SELECT id, team_id, blueprint_id, identifier, title, %s, version, integration_id, property_sources, expires_at, created_at, updated_at
 		FROM entities
 		WHERE team_id = $1 AND blueprint_id = $2
 		ORDER BY created_at DESC
-		LIMIT $3 OFFSET $4`
+		LIMIT $3 OFFSET $4`, fields.dataColumn(args))
 
-	rows, err := r.db.DB.QueryContext(ctx, query, teamID, blueprintID, limit, offset)
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args.values...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -79,64 +182,146 @@ func (r *Repository) List(ctx context.Context, teamID uuid.UUID, blueprintID str
 	return entities, total, err
 }
 
-func (r *Repository) Search(ctx context.Context, teamID uuid.UUID, blueprintID string, req *SearchRequest) ([]*Entity, int, error) {
-	whereClause := []string{"team_id = $1", "blueprint_id = $2"}
-	args := []interface{}{teamID, blueprintID}
-	argIndex := 3
+// ListByIdentifiers returns the blueprint's entities with the given identifiers, keyed by identifier
+func (r *Repository) ListByIdentifiers(ctx context.Context, teamID uuid.UUID, blueprintID string, identifiers []string) (map[string]*Entity, error) {
+	query := `
+		SELECT id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
+		FROM entities
+		WHERE team_id = $1 AND blueprint_id = $2 AND identifier = ANY($3)`
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, blueprintID, identifiers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entities, err := r.scanEntities(rows)
+	if err != nil {
+		return nil, err
+	}
+	byIdentifier := make(map[string]*Entity, len(entities))
+	for _, e := range entities {
+		byIdentifier[e.Identifier] = e
+	}
+	return byIdentifier, nil
+}
+
+// ForEach calls fn for every entity of a blueprint in creation order without
+// loading them all into memory. It stops at the first error fn returns.
+// The statement timeout does not apply, as exports stream for as long as the
+// client keeps reading.
+func (r *Repository) ForEach(ctx context.Context, teamID uuid.UUID, blueprintID string, fn func(*Entity) error) error {
+	return r.ForEachMatching(ctx, teamID, blueprintID, nil, &ExportRequest{}, fn)
+}
 
-	for _, filter := range req.Filters {
-		clause, newArgs, idx := r.buildFilterClause(filter, argIndex)
-		if clause != "" {
-			whereClause = append(whereClause, clause)
-			args = append(args, newArgs...)
-			argIndex = idx
+// ForEachMatching is like ForEach for the entities matching the filters of
+// req, in its order. fc may be nil when req has neither filters nor ordering.
+func (r *Repository) ForEachMatching(ctx context.Context, teamID uuid.UUID, blueprintID string, fc *FilterCompiler, req *ExportRequest, fn func(*Entity) error) error {
+	args := newQueryArgs(teamID, blueprintID)
+	where, err := exportWhere(fc, args, req.Filters)
+	if err != nil {
+		return err
+	}
+	orderClause := "created_at, id"
+	if req.OrderBy != "" {
+		order, err := fc.OrderBy(args, req.OrderBy, req.OrderDir)
+		if err != nil {
+			return err
 		}
+		orderClause = order + ", id"
 	}
+	query := fmt.Sprintf(`
+		SELECT id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
+		FROM entities
+		WHERE %s
+		ORDER BY %s`, where, orderClause)
 
-	where := strings.Join(whereClause, " AND ")
+	tx, err := r.db.Reader(ctx).BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return err
+	}
 
-	// Count total
-	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM entities WHERE %s", where)
-	var total int
-	if err := r.db.DB.QueryRowContext(ctx, countQuery, args...).Scan(&total); err != nil {
-		return nil, 0, err
+	rows, err := tx.QueryContext(ctx, query, args.values...)
+	if err != nil {
+		return err
 	}
+	defer rows.Close()
 
-	// Build order clause
-	orderClause := "created_at DESC"
-	if req.OrderBy != "" {
-		dir := "ASC"
-		if strings.ToUpper(req.OrderDir) == "DESC" {
-			dir = "DESC"
+	for rows.Next() {
+		entity, err := r.scanRow(rows)
+		if err != nil {
+			return err
 		}
-		
-		// Validate allowed column names to prevent SQL injection
-		allowedColumns := map[string]bool{
-			"created_at": true,
-			"updated_at": true,
-			"identifier": true,
-			"title": true,
+		if err := fn(entity); err != nil {
+			return err
 		}
-		
-		if allowedColumns[req.OrderBy] {
-			orderClause = fmt.Sprintf("%s %s", req.OrderBy, dir)
-		} else {
-			// For JSONB properties, use parameterized query or validate property name
-			// This is a simplified approach - consider using a whitelist for production
-			if len(req.OrderBy) > 0 && len(req.OrderBy) < 100 {
-				// Basic validation: alphanumeric and dots only
-				validProperty := true
-				for _, r := range req.OrderBy {
-					if !((r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '_') {
-						validProperty = false
-						break
-					}
-				}
-				if validProperty {
-					orderClause = fmt.Sprintf("data->>'%s' %s", req.OrderBy, dir)
-				}
-			}
+	}
+	return rows.Err()
+}
+
+// CountMatching counts the entities of a blueprint matching filters, up to
+// limit, so sizing a large export does not count all of it
+func (r *Repository) CountMatching(ctx context.Context, teamID uuid.UUID, blueprintID string, fc *FilterCompiler, filters []SearchFilter, limit int) (int, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	args := newQueryArgs(teamID, blueprintID)
+	where, err := exportWhere(fc, args, filters)
+	if err != nil {
+		return 0, err
+	}
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM (SELECT 1 FROM entities WHERE %s LIMIT %s) matching`, where, args.add(limit))
+	var count int
+	err = r.db.Reader(ctx).QueryRowContext(ctx, query, args.values...).Scan(&count)
+	return count, err
+}
+
+// exportWhere returns the conditions selecting the entities of an export
+func exportWhere(fc *FilterCompiler, args *queryArgs, filters []SearchFilter) (string, error) {
+	conditions := []string{"team_id = $1", "blueprint_id = $2"}
+	if len(filters) > 0 {
+		filtered, err := fc.Where(args, filters)
+		if err != nil {
+			return "", err
 		}
+		conditions = append(conditions, filtered...)
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// Search returns entities matching req. Filters, ordering and fields are
+// compiled by fc against the blueprint schema.
+func (r *Repository) Search(ctx context.Context, teamID uuid.UUID, blueprintID string, fc *FilterCompiler, req *SearchRequest) ([]*Entity, int, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	args := newQueryArgs(teamID, blueprintID)
+	conditions, err := fc.Where(args, req.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	// The count query only uses the filter parameters
+	filterArgs := len(args.values)
+	orderClause, err := fc.OrderBy(args, req.OrderBy, req.OrderDir)
+	if err != nil {
+		return nil, 0, err
+	}
+	fields, err := fc.Fields(req.Fields)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where := strings.Join(append([]string{"team_id = $1", "blueprint_id = $2"}, conditions...), " AND ")
+
+	// Count total
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM entities WHERE %s", where)
+	var total int
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, countQuery, args.values[:filterArgs]...).Scan(&total); err != nil {
+		return nil, 0, err
 	}
 
 	limit := req.Limit
@@ -145,15 +330,13 @@ func (r *Repository) Search(ctx context.Context, teamID uuid.UUID, blueprintID s
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, team_id, blueprint_id, identifier, title, data, created_at, updated_at
+		SELECT id, team_id, blueprint_id, identifier, title, %s, version, integration_id, property_sources, expires_at, created_at, updated_at
 		FROM entities
 		WHERE %s
 		ORDER BY %s
-		LIMIT $%d OFFSET $%d`, where, orderClause, argIndex, argIndex+1)
-
-	args = append(args, limit, req.Offset)
+		LIMIT %s OFFSET %s`, fields.dataColumn(args), where, orderClause, args.add(limit), args.add(req.Offset))
 
-	rows, err := r.db.DB.QueryContext(ctx, query, args...)
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args.values...)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -163,111 +346,405 @@ func (r *Repository) Search(ctx context.Context, teamID uuid.UUID, blueprintID s
 	return entities, total, err
 }
 
-func isValidProperty(property string) bool {
-	matched, _ := regexp.MatchString(`^[a-zA-Z0-9_.]+$`, property)
-	return matched
-}
-
-func (r *Repository) buildFilterClause(filter SearchFilter, argIndex int) (string, []interface{}, int) {
-	var clause string
-	var args []interface{}
-
-	if !isValidProperty(filter.Property) {
-		return "", nil, argIndex
-	}
-
-	// Property path - supports nested properties like "metadata.version"
-	propPath := fmt.Sprintf("data->'%s'", strings.Replace(filter.Property, ".", "'->'", -1))
-
-	switch filter.Operator {
-	case "eq":
-		valueJSON, _ := json.Marshal(filter.Value)
-		clause = fmt.Sprintf("%s = $%d", propPath, argIndex)
-		args = append(args, string(valueJSON))
-		argIndex++
-	case "neq":
-		valueJSON, _ := json.Marshal(filter.Value)
-		clause = fmt.Sprintf("%s != $%d", propPath, argIndex)
-		args = append(args, string(valueJSON))
-		argIndex++
-	case "gt":
-		clause = fmt.Sprintf("(%s)::numeric > $%d", propPath, argIndex)
-		args = append(args, filter.Value)
-		argIndex++
-	case "gte":
-		clause = fmt.Sprintf("(%s)::numeric >= $%d", propPath, argIndex)
-		args = append(args, filter.Value)
-		argIndex++
-	case "lt":
-		clause = fmt.Sprintf("(%s)::numeric < $%d", propPath, argIndex)
-		args = append(args, filter.Value)
-		argIndex++
-	case "lte":
-		clause = fmt.Sprintf("(%s)::numeric <= $%d", propPath, argIndex)
-		args = append(args, filter.Value)
-		argIndex++
-	case "contains":
-		// Text contains
-		clause = fmt.Sprintf("%s::text ILIKE $%d", propPath, argIndex)
-		args = append(args, "%"+fmt.Sprint(filter.Value)+"%")
-		argIndex++
-	case "exists":
-		if filter.Value == true {
-			clause = fmt.Sprintf("data ? '%s'", filter.Property)
-		} else {
-			clause = fmt.Sprintf("NOT (data ? '%s')", filter.Property)
-		}
-	case "in":
-		if arr, ok := filter.Value.([]interface{}); ok {
-			placeholders := make([]string, len(arr))
-			for i, v := range arr {
-				valueJSON, _ := json.Marshal(v)
-				placeholders[i] = fmt.Sprintf("$%d", argIndex)
-				args = append(args, string(valueJSON))
-				argIndex++
-			}
-			clause = fmt.Sprintf("%s IN (%s)", propPath, strings.Join(placeholders, ","))
-		}
+// maxAggregateBuckets caps the number of groups returned by Aggregate
+const maxAggregateBuckets = 100
+
+// Aggregate computes req.Function over matching entities. Numeric functions ignore
+// values that are not JSON numbers. Groups are ordered by value, largest first.
+func (r *Repository) Aggregate(ctx context.Context, teamID uuid.UUID, blueprintID string, fc *FilterCompiler, req *AggregateRequest) ([]AggregateBucket, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	args := newQueryArgs(teamID, blueprintID)
+	conditions, err := fc.Where(args, req.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	valueExpr := "COUNT(*)"
+	if req.Function != AggregateCount {
+		p := args.add(strings.Split(req.Property, "."))
+		valueExpr = fmt.Sprintf(
+			"%s(CASE WHEN jsonb_typeof(data #> %s::text[]) = 'number' THEN (data #>> %s::text[])::numeric END)",
+			strings.ToUpper(req.Function), p, p)
+	}
+
+	// Without grouping there is no GROUP BY so an empty result still yields one row
+	keyExpr, groupClause := "''", ""
+	if req.GroupBy != "" {
+		keyExpr = fmt.Sprintf("COALESCE(data #>> %s::text[], '')", args.add(strings.Split(req.GroupBy, ".")))
+		groupClause = "GROUP BY 1"
+	}
+
+	where := strings.Join(append([]string{"team_id = $1", "blueprint_id = $2"}, conditions...), " AND ")
+	query := fmt.Sprintf(`
+		SELECT %s AS key, %s AS value
+		FROM entities
+		WHERE %s
+		%s
+		ORDER BY 2 DESC NULLS LAST
+		LIMIT %s`, keyExpr, valueExpr, where, groupClause, args.add(maxAggregateBuckets))
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, args.values...)
+	if err != nil {
+		return nil, err
 	}
+	defer rows.Close()
 
-	return clause, args, argIndex
+	var buckets []AggregateBucket
+	for rows.Next() {
+		var b AggregateBucket
+		var value sql.NullFloat64
+		if err := rows.Scan(&b.Key, &value); err != nil {
+			return nil, err
+		}
+		b.Value = value.Float64
+		buckets = append(buckets, b)
+	}
+	return buckets, rows.Err()
 }
 
+// Update writes an entity's title and data and increments its version. The
+// row is only written while its version is still entity.Version; otherwise
+// ErrVersionConflict is returned, as it is when the entity was deleted.
 func (r *Repository) Update(ctx context.Context, entity *Entity) error {
+	return r.update(ctx, entity, "")
+}
+
+// Rename writes an entity like Update under its new identifier. In the same
+// statement it keeps previousIdentifier as an alias of the entity, drops the
+// alias of the new identifier and records an entity.renamed event. Relations
+// link entities by ID and need no change.
+func (r *Repository) Rename(ctx context.Context, entity *Entity, previousIdentifier string) error {
+	return r.update(ctx, entity, previousIdentifier)
+}
+
+func (r *Repository) update(ctx context.Context, entity *Entity, previousIdentifier string) error {
 	data, err := json.Marshal(entity.Data)
 	if err != nil {
 		return err
 	}
+	sources, err := marshalSources(entity.Sources)
+	if err != nil {
+		return err
+	}
 
 	query := `
-		UPDATE entities
-		SET title = $2, data = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $1
-		RETURNING updated_at`
+		WITH updated AS (
+			UPDATE entities
+			SET identifier = $7, title = $2, data = $3, property_sources = $8, expires_at = $9, version = version + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND version = $4
+			RETURNING ` + historyColumns + `
+		), ` + recordWrite("updated", "$5", "$6")
+	userID, apiKeyID := historyActor(ctx)
+	args := []interface{}{entity.ID, entity.Title, data, entity.Version, userID, apiKeyID, entity.Identifier, sources, entity.ExpiresAt}
+	if previousIdentifier != "" {
+		query += `, aliased AS (
+			INSERT INTO entity_aliases (team_id, blueprint_id, identifier, entity_id)
+			SELECT team_id, blueprint_id, $10, id FROM updated
+			ON CONFLICT (team_id, blueprint_id, identifier) DO UPDATE SET entity_id = EXCLUDED.entity_id, created_at = NOW()
+		), unaliased AS (
+			DELETE FROM entity_aliases a
+			USING updated u
+			WHERE a.team_id = u.team_id AND a.blueprint_id = u.blueprint_id AND a.identifier = u.identifier
+		), renamed AS (
+			INSERT INTO event_outbox (type, team_id, blueprint_id, entity_id, payload, actor_user_id, actor_api_key_id)
+			SELECT '` + events.EntityRenamed + `', team_id, blueprint_id, id,
+				jsonb_build_object('entity', ` + eventPayload + `, 'previous_identifier', $10::text),
+				$5::uuid, $6::uuid
+			FROM updated
+		)`
+		args = append(args, previousIdentifier)
+	}
+	query += `
+		SELECT version, updated_at FROM updated`
 
-	return r.db.DB.QueryRowContext(ctx, query, entity.ID, entity.Title, data).Scan(&entity.UpdatedAt)
+	err = r.db.DB.QueryRowContext(ctx, query, args...).Scan(&entity.Version, &entity.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrVersionConflict
+	}
+	// A concurrent rename took the identifier
+	if postgres.IsUniqueViolation(err) {
+		return ErrAlreadyExists
+	}
+	return err
 }
 
 func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
-	query := `DELETE FROM entities WHERE id = $1`
-	_, err := r.db.DB.ExecContext(ctx, query, id)
+	query := `
+		WITH deleted AS (
+			DELETE FROM entities WHERE id = $1
+			RETURNING ` + historyColumns + `
+		), ` + recordWrite("deleted", "$2", "$3") + `
+		SELECT COUNT(*) FROM deleted`
+	userID, apiKeyID := historyActor(ctx)
+	_, err := r.db.DB.ExecContext(ctx, query, id, userID, apiKeyID)
 	return err
 }
 
 func (r *Repository) DeleteByBlueprint(ctx context.Context, teamID uuid.UUID, blueprintID string) error {
-	query := `DELETE FROM entities WHERE team_id = $1 AND blueprint_id = $2`
-	_, err := r.db.DB.ExecContext(ctx, query, teamID, blueprintID)
+	userID, apiKeyID := historyActor(ctx)
+	_, err := r.db.DB.ExecContext(ctx, deleteByBlueprintQuery, teamID, blueprintID, userID, apiKeyID)
+	return err
+}
+
+// DeleteByBlueprintTx deletes a blueprint's entities in tx, recording their
+// history and entity.deleted events as DeleteByBlueprint does, and returns
+// how many were deleted. Blueprint deletion uses it to remove the entities
+// in the transaction that removes the blueprint.
+func (r *Repository) DeleteByBlueprintTx(ctx context.Context, tx *sql.Tx, teamID uuid.UUID, blueprintID string) (int64, error) {
+	userID, apiKeyID := historyActor(ctx)
+	var deleted int64
+	err := tx.QueryRowContext(ctx, deleteByBlueprintQuery, teamID, blueprintID, userID, apiKeyID).Scan(&deleted)
+	return deleted, err
+}
+
+var deleteByBlueprintQuery = `
+		WITH deleted AS (
+			DELETE FROM entities WHERE team_id = $1 AND blueprint_id = $2
+			RETURNING ` + historyColumns + `
+		), ` + recordWrite("deleted", "$3", "$4") + `
+		SELECT COUNT(*) FROM deleted`
+
+// ListExpired returns up to limit entities of any team that expired at or
+// before the given time, earliest first
+func (r *Repository) ListExpired(ctx context.Context, before time.Time, limit int) ([]*Entity, error) {
+	query := `
+		SELECT id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
+		FROM entities
+		WHERE expires_at <= $1
+		ORDER BY expires_at
+		LIMIT $2`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return r.scanEntities(rows)
+}
+
+// DeleteVersion deletes an entity while it is still at the given version, so
+// a sweep never deletes an entity that was written after it was read.
+// deleted is false when the entity changed or is gone.
+func (r *Repository) DeleteVersion(ctx context.Context, id uuid.UUID, version int64) (deleted bool, err error) {
+	query := `
+		WITH deleted AS (
+			DELETE FROM entities WHERE id = $1 AND version = $2
+			RETURNING ` + historyColumns + `
+		), ` + recordWrite("deleted", "$3", "$4") + `
+		SELECT COUNT(*) FROM deleted`
+	userID, apiKeyID := historyActor(ctx)
+	var n int
+	if err := r.db.DB.QueryRowContext(ctx, query, id, version, userID, apiKeyID).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// SetExpiry writes the expiry times of entities without changing their
+// version; a nil time means the entity does not expire
+func (r *Repository) SetExpiry(ctx context.Context, expiry map[uuid.UUID]*time.Time) error {
+	if len(expiry) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(expiry))
+	times := make([]string, 0, len(expiry))
+	for id, at := range expiry {
+		ids = append(ids, id.String())
+		if at == nil {
+			times = append(times, "")
+		} else {
+			times = append(times, at.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	_, err := r.db.DB.ExecContext(ctx, `
+		UPDATE entities e SET expires_at = NULLIF(v.expires_at, '')::timestamptz
+		FROM unnest($1::uuid[], $2::text[]) AS v(id, expires_at)
+		WHERE e.id = v.id`,
+		ids, times)
 	return err
 }
 
+// ClearExpiry removes the expiry times of a blueprint's entities
+func (r *Repository) ClearExpiry(ctx context.Context, teamID uuid.UUID, blueprintID string) error {
+	_, err := r.db.DB.ExecContext(ctx,
+		`UPDATE entities SET expires_at = NULL WHERE team_id = $1 AND blueprint_id = $2 AND expires_at IS NOT NULL`,
+		teamID, blueprintID)
+	return err
+}
+
+// historyColumns are the entity columns a write returns for its history row
+// and its event
+const historyColumns = `id, team_id, blueprint_id, identifier, title, data, version, integration_id, expires_at, created_at, updated_at`
+
+// writeEvents are the event types of the writes recorded by recordWrite
+var writeEvents = map[string]string{
+	"created": events.EntityCreated,
+	"updated": events.EntityUpdated,
+	"deleted": events.EntityDeleted,
+}
+
+// recordWrite returns the CTEs that record the entities returned by the CTE
+// named after the action: one entity_history row and one event_outbox row
+// each, attributed to the actor in the given parameters. Being part of the
+// write's statement, they are committed with it or not at all.
+func recordWrite(action, userParam, apiKeyParam string) string {
+	return `history AS (
+			INSERT INTO entity_history (entity_id, team_id, blueprint_id, version, action, title, data, actor_user_id, actor_api_key_id)
+			SELECT id, team_id, blueprint_id, version, '` + action + `', title, data, ` + userParam + `::uuid, ` + apiKeyParam + `::uuid
+			FROM ` + action + `
+		), outbox AS (
+			INSERT INTO event_outbox (type, team_id, blueprint_id, entity_id, payload, actor_user_id, actor_api_key_id)
+			SELECT '` + writeEvents[action] + `', team_id, blueprint_id, id, ` + eventPayload + `,
+				` + userParam + `::uuid, ` + apiKeyParam + `::uuid
+			FROM ` + action + `
+		)`
+}
+
+// eventPayload builds the event payload of an entity from historyColumns
+const eventPayload = `jsonb_build_object(
+				'id', id, 'team_id', team_id, 'blueprint_id', blueprint_id, 'identifier', identifier, 'title', title,
+				'data', data, 'version', version, 'integration_id', integration_id, 'expires_at', expires_at,
+				'created_at', created_at, 'updated_at', updated_at
+			)`
+
+// historyActor returns the actor of the request as query parameters; writes
+// without one, such as background jobs, are recorded without an actor
+func historyActor(ctx context.Context) (userID, apiKeyID *uuid.UUID) {
+	actor, _ := events.ActorFrom(ctx)
+	return actor.UserID, actor.APIKeyID
+}
+
+// History returns an entity's revisions in version order. Each revision
+// carries the whole entity as written, and the user who wrote it.
+func (r *Repository) History(ctx context.Context, teamID, entityID uuid.UUID, limit, offset int) ([]*Revision, int, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM entity_history WHERE team_id = $1 AND entity_id = $2`
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, countQuery, teamID, entityID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT h.version, h.action, h.title, h.data, h.created_at,
+			h.actor_user_id, h.actor_api_key_id, u.email, u.name
+		FROM entity_history h
+		LEFT JOIN users u ON u.id = h.actor_user_id
+		WHERE h.team_id = $1 AND h.entity_id = $2
+		ORDER BY h.version, h.id
+		LIMIT $3 OFFSET $4`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, entityID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	revisions := []*Revision{}
+	for rows.Next() {
+		rev := &Revision{}
+		var title, email, name sql.NullString
+		var data []byte
+		if err := rows.Scan(&rev.Version, &rev.Action, &title, &data, &rev.ChangedAt,
+			&rev.Actor.UserID, &rev.Actor.APIKeyID, &email, &name); err != nil {
+			return nil, 0, err
+		}
+		rev.Title = title.String
+		rev.Actor.Email, rev.Actor.Name = email.String, name.String
+		if err := json.Unmarshal(data, &rev.Data); err != nil {
+			return nil, 0, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, total, rows.Err()
+}
+
+// HistoryBlueprint returns the blueprint of an entity's history, "" when it
+// has none. History outlives the entity, so it is read from the revisions.
+func (r *Repository) HistoryBlueprint(ctx context.Context, teamID, entityID uuid.UUID) (string, error) {
+	var blueprintID string
+	query := `SELECT blueprint_id FROM entity_history WHERE team_id = $1 AND entity_id = $2 LIMIT 1`
+	err := r.db.Reader(ctx).QueryRowContext(ctx, query, teamID, entityID).Scan(&blueprintID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return blueprintID, err
+}
+
+// PropertyHistory returns the revisions in which a data property changed: the
+// first revision, every revision that set, changed or removed the property,
+// and the deletion. Value is null when the property is absent.
+func (r *Repository) PropertyHistory(ctx context.Context, teamID, entityID uuid.UUID, property string, limit, offset int) ([]*PropertyChange, int, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	changes := `
+		WITH timeline AS (
+			SELECT h.id, h.version, h.action, h.data -> $3 AS value, h.data ? $3 AS present, h.created_at,
+				h.actor_user_id, h.actor_api_key_id,
+				LAG(h.data -> $3) OVER w AS previous_value,
+				LAG(h.data ? $3) OVER w AS previous_present
+			FROM entity_history h
+			WHERE h.team_id = $1 AND h.entity_id = $2
+			WINDOW w AS (ORDER BY h.version, h.id)
+		), changes AS (
+			SELECT * FROM timeline
+			WHERE previous_present IS NULL OR action = 'deleted'
+				OR present IS DISTINCT FROM previous_present OR value IS DISTINCT FROM previous_value
+		)`
+
+	var total int
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, changes+` SELECT COUNT(*) FROM changes`, teamID, entityID, property).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := changes + `
+		SELECT c.version, c.action, c.value, c.present, c.created_at,
+			c.actor_user_id, c.actor_api_key_id, u.email, u.name
+		FROM changes c
+		LEFT JOIN users u ON u.id = c.actor_user_id
+		ORDER BY c.version, c.id
+		LIMIT $4 OFFSET $5`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, entityID, property, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	result := []*PropertyChange{}
+	for rows.Next() {
+		change := &PropertyChange{}
+		var value []byte
+		var email, name sql.NullString
+		if err := rows.Scan(&change.Version, &change.Action, &value, &change.Present, &change.ChangedAt,
+			&change.Actor.UserID, &change.Actor.APIKeyID, &email, &name); err != nil {
+			return nil, 0, err
+		}
+		change.Actor.Email, change.Actor.Name = email.String, name.String
+		if value != nil {
+			if err := json.Unmarshal(value, &change.Value); err != nil {
+				return nil, 0, err
+			}
+		}
+		result = append(result, change)
+	}
+	return result, total, rows.Err()
+}
+
 func (r *Repository) scanEntity(row *sql.Row) (*Entity, error) {
 	entity := &Entity{}
 	var data []byte
 	var title sql.NullString
+	var integrationID uuid.NullUUID
+	var sources []byte
+	var expiresAt sql.NullTime
 
 	err := row.Scan(
 		&entity.ID, &entity.TeamID, &entity.BlueprintID,
-		&entity.Identifier, &title, &data,
+		&entity.Identifier, &title, &data, &entity.Version, &integrationID, &sources, &expiresAt,
 		&entity.CreatedAt, &entity.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
@@ -278,32 +755,647 @@ func (r *Repository) scanEntity(row *sql.Row) (*Entity, error) {
 	}
 
 	entity.Title = title.String
+	if integrationID.Valid {
+		entity.IntegrationID = &integrationID.UUID
+	}
+	if expiresAt.Valid {
+		entity.ExpiresAt = &expiresAt.Time
+	}
 	if err := json.Unmarshal(data, &entity.Data); err != nil {
 		return nil, err
 	}
+	if err := json.Unmarshal(sources, &entity.Sources); err != nil {
+		return nil, err
+	}
 	return entity, nil
 }
 
 func (r *Repository) scanEntities(rows *sql.Rows) ([]*Entity, error) {
 	var entities []*Entity
 	for rows.Next() {
-		entity := &Entity{}
-		var data []byte
-		var title sql.NullString
+		entity, err := r.scanRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, rows.Err()
+}
+
+// scanRow scans an entity. extra receive columns selected before the
+// entity's own.
+func (r *Repository) scanRow(rows *sql.Rows, extra ...interface{}) (*Entity, error) {
+	entity := &Entity{}
+	var data []byte
+	var title sql.NullString
+	var integrationID uuid.NullUUID
+	var sources []byte
+	var expiresAt sql.NullTime
+
+	if err := rows.Scan(append(extra,
+		&entity.ID, &entity.TeamID, &entity.BlueprintID,
+		&entity.Identifier, &title, &data, &entity.Version, &integrationID, &sources, &expiresAt,
+		&entity.CreatedAt, &entity.UpdatedAt,
+	)...); err != nil {
+		return nil, err
+	}
+
+	entity.Title = title.String
+	if integrationID.Valid {
+		entity.IntegrationID = &integrationID.UUID
+	}
+	if expiresAt.Valid {
+		entity.ExpiresAt = &expiresAt.Time
+	}
+	if err := json.Unmarshal(data, &entity.Data); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(sources, &entity.Sources); err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// marshalSources encodes property sources; entities without any store an
+// empty object
+func marshalSources(sources map[string]PropertySource) ([]byte, error) {
+	if sources == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(sources)
+}
+
+// ReleaseSource removes the recorded source of one property. It reports
+// whether the entity exists in the team.
+func (r *Repository) ReleaseSource(ctx context.Context, teamID, id uuid.UUID, property string) (bool, error) {
+	query := `UPDATE entities SET property_sources = property_sources - $3 WHERE id = $1 AND team_id = $2`
+	result, err := r.db.DB.ExecContext(ctx, query, id, teamID, property)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// IntegrationExists reports whether an integration belongs to the team
+func (r *Repository) IntegrationExists(ctx context.Context, teamID, integrationID uuid.UUID) (bool, error) {
+	var exists bool
+	query := `SELECT EXISTS(SELECT 1 FROM integrations WHERE id = $1 AND team_id = $2)`
+	err := r.db.DB.QueryRowContext(ctx, query, integrationID, teamID).Scan(&exists)
+	return exists, err
+}
+
+// IntegrationSyncTimes returns when each of the team's integrations last
+// completed a sync; integrations that never did are left out
+func (r *Repository) IntegrationSyncTimes(ctx context.Context, teamID uuid.UUID, integrationIDs []uuid.UUID) (map[uuid.UUID]time.Time, error) {
+	query := `SELECT id, last_sync_at FROM integrations WHERE id = ANY($1::uuid[]) AND team_id = $2 AND last_sync_at IS NOT NULL`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, integrationIDs, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	synced := make(map[uuid.UUID]time.Time, len(integrationIDs))
+	for rows.Next() {
+		var id uuid.UUID
+		var at time.Time
+		if err := rows.Scan(&id, &at); err != nil {
+			return nil, err
+		}
+		synced[id] = at
+	}
+	return synced, rows.Err()
+}
+
+// RollupDimensions returns the dimensions covered by a blueprint's rollups,
+// or nil if they have never been built
+func (r *Repository) RollupDimensions(ctx context.Context, teamID uuid.UUID, blueprintID string) ([]string, error) {
+	var dims postgres.StringArray
+	query := `SELECT dimensions FROM entity_rollup_state WHERE team_id = $1 AND blueprint_id = $2`
+	err := r.db.DB.QueryRowContext(ctx, query, teamID, blueprintID).Scan(&dims)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dims == nil {
+		dims = postgres.StringArray{}
+	}
+	return dims, nil
+}
+
+// ListRollupStates returns the covered dimensions of every built blueprint, keyed by "team/blueprint"
+func (r *Repository) ListRollupStates(ctx context.Context) (map[string][]string, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT team_id, blueprint_id, dimensions FROM entity_rollup_state`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-		if err := rows.Scan(
-			&entity.ID, &entity.TeamID, &entity.BlueprintID,
-			&entity.Identifier, &title, &data,
-			&entity.CreatedAt, &entity.UpdatedAt,
-		); err != nil {
+	states := make(map[string][]string)
+	for rows.Next() {
+		var teamID uuid.UUID
+		var blueprintID string
+		var dims postgres.StringArray
+		if err := rows.Scan(&teamID, &blueprintID, &dims); err != nil {
 			return nil, err
 		}
+		states[blueprintKey(teamID, blueprintID)] = dims
+	}
+	return states, rows.Err()
+}
 
-		entity.Title = title.String
-		if err := json.Unmarshal(data, &entity.Data); err != nil {
+// RollupBuckets returns the non-empty counters of one rollup dimension as aggregate buckets
+func (r *Repository) RollupBuckets(ctx context.Context, teamID uuid.UUID, blueprintID, kind, key string) ([]AggregateBucket, error) {
+	query := `
+		SELECT value, count
+		FROM entity_rollups
+		WHERE team_id = $1 AND blueprint_id = $2 AND kind = $3 AND key = $4 AND (count > 0 OR kind = 'count')
+		ORDER BY count DESC, value
+		LIMIT $5`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, blueprintID, kind, key, maxAggregateBuckets)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []AggregateBucket
+	for rows.Next() {
+		var b AggregateBucket
+		if err := rows.Scan(&b.Key, &b.Value); err != nil {
 			return nil, err
 		}
-		entities = append(entities, entity)
+		buckets = append(buckets, b)
 	}
-	return entities, rows.Err()
+	return buckets, rows.Err()
+}
+
+// ListRollups returns every counter of the given kinds
+func (r *Repository) ListRollups(ctx context.Context, kinds ...string) ([]RollupRow, error) {
+	query := `
+		SELECT team_id, blueprint_id, kind, key, value, count
+		FROM entity_rollups
+		WHERE kind = ANY($1)
+		ORDER BY team_id, blueprint_id, kind, key, value`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, kinds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []RollupRow
+	for rows.Next() {
+		var row RollupRow
+		if err := rows.Scan(&row.TeamID, &row.BlueprintID, &row.Kind, &row.Key, &row.Value, &row.Count); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// ApplyRollupDeltas adds each row's Count to its counter. Rows of blueprints
+// whose rollups have not been built are ignored.
+func (r *Repository) ApplyRollupDeltas(ctx context.Context, deltas []RollupRow) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	teamIDs := make([]string, len(deltas))
+	blueprintIDs := make([]string, len(deltas))
+	kinds := make([]string, len(deltas))
+	keys := make([]string, len(deltas))
+	values := make([]string, len(deltas))
+	counts := make([]int64, len(deltas))
+	for i, d := range deltas {
+		teamIDs[i], blueprintIDs[i], kinds[i], keys[i], values[i], counts[i] = d.TeamID.String(), d.BlueprintID, d.Kind, d.Key, d.Value, d.Count
+	}
+
+	query := `
+		INSERT INTO entity_rollups (team_id, blueprint_id, kind, key, value, count)
+		SELECT d.team_id, d.blueprint_id, d.kind, d.key, d.value, d.count
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::text[], $6::bigint[])
+			AS d(team_id, blueprint_id, kind, key, value, count)
+		WHERE EXISTS (
+			SELECT 1 FROM entity_rollup_state s
+			WHERE s.team_id = d.team_id AND s.blueprint_id = d.blueprint_id
+		)
+		ON CONFLICT (team_id, blueprint_id, kind, key, value)
+		DO UPDATE SET count = entity_rollups.count + EXCLUDED.count`
+
+	_, err := r.db.DB.ExecContext(ctx, query,
+		teamIDs, blueprintIDs, kinds, keys, values, counts)
+	return err
+}
+
+// RebuildRollups recomputes a blueprint's rollups in one transaction
+func (r *Repository) RebuildRollups(ctx context.Context, teamID uuid.UUID, blueprintID string, plan *rollupPlan) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Rebuilding a large blueprint may outlast the statement timeout
+	if _, err := tx.ExecContext(ctx, `SET LOCAL statement_timeout = 0`); err != nil {
+		return err
+	}
+	// Serializes rebuilds of the same blueprint across instances
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, blueprintKey(teamID, blueprintID)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM entity_rollups WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO entity_rollups (team_id, blueprint_id, kind, key, value, count)
+		SELECT $1, $2, 'count', '', '', COUNT(*)
+		FROM entities
+		WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID); err != nil {
+		return fmt.Errorf("failed to count entities: %w", err)
+	}
+
+	for _, path := range plan.enumPaths {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO entity_rollups (team_id, blueprint_id, kind, key, value, count)
+			SELECT $1, $2, 'enum', $3, COALESCE(data #>> $4::text[], ''), COUNT(*)
+			FROM entities
+			WHERE team_id = $1 AND blueprint_id = $2
+			GROUP BY 5`, teamID, blueprintID, path, strings.Split(path, ".")); err != nil {
+			return fmt.Errorf("failed to roll up %s: %w", path, err)
+		}
+	}
+
+	if len(plan.scorecards) > 0 {
+		// Scorecards are evaluated in Go, like the API does
+		scorecardsOnly := &rollupPlan{scorecards: plan.scorecards}
+		counts := make(map[rollupCounter]int64)
+		rows, err := tx.QueryContext(ctx, `SELECT data FROM entities WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var raw []byte
+			var data map[string]interface{}
+			if err := rows.Scan(&raw); err != nil {
+				rows.Close()
+				return err
+			}
+			if err := json.Unmarshal(raw, &data); err != nil {
+				rows.Close()
+				return err
+			}
+			scorecardsOnly.contribute(counts, data, 1)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for counter, count := range counts {
+			if counter.kind == RollupCount {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO entity_rollups (team_id, blueprint_id, kind, key, value, count)
+				VALUES ($1, $2, $3, $4, $5, $6)`,
+				teamID, blueprintID, counter.kind, counter.key, counter.value, count); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO entity_rollup_state (team_id, blueprint_id, dimensions, rebuilt_at)
+		VALUES ($1, $2, $3, NOW())
+		ON CONFLICT (team_id, blueprint_id)
+		DO UPDATE SET dimensions = EXCLUDED.dimensions, rebuilt_at = EXCLUDED.rebuilt_at`,
+		teamID, blueprintID, plan.dimensions()); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteRollups removes a blueprint's rollups
+func (r *Repository) DeleteRollups(ctx context.Context, teamID uuid.UUID, blueprintID string) error {
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM entity_rollup_state WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM entity_rollups WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteOrphanedRollups removes rollups of blueprints that no longer exist
+func (r *Repository) DeleteOrphanedRollups(ctx context.Context) error {
+	for _, table := range []string{"entity_rollup_state", "entity_rollups"} {
+		query := fmt.Sprintf(`
+			DELETE FROM %s r
+			WHERE NOT EXISTS (SELECT 1 FROM blueprints b WHERE b.team_id = r.team_id AND b.id = r.blueprint_id)`, table)
+		if _, err := r.db.DB.ExecContext(ctx, query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddPropertyUsage adds each row's Count to its daily usage counter. Rows of
+// teams deleted since they were recorded are ignored.
+func (r *Repository) AddPropertyUsage(ctx context.Context, rows []UsageRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	teamIDs := make([]string, len(rows))
+	blueprintIDs := make([]string, len(rows))
+	properties := make([]string, len(rows))
+	usages := make([]string, len(rows))
+	days := make([]string, len(rows))
+	counts := make([]int64, len(rows))
+	for i, u := range rows {
+		teamIDs[i], blueprintIDs[i], properties[i], usages[i], days[i], counts[i] =
+			u.TeamID.String(), u.BlueprintID, u.Property, u.Usage, u.Day.Format(time.DateOnly), u.Count
+	}
+
+	query := `
+		INSERT INTO property_usage (team_id, blueprint_id, property, usage, day, count)
+		SELECT u.team_id, u.blueprint_id, u.property, u.usage, u.day, u.count
+		FROM unnest($1::uuid[], $2::text[], $3::text[], $4::text[], $5::date[], $6::bigint[])
+			AS u(team_id, blueprint_id, property, usage, day, count)
+		WHERE EXISTS (SELECT 1 FROM teams t WHERE t.id = u.team_id)
+		ON CONFLICT (team_id, blueprint_id, day, property, usage)
+		DO UPDATE SET count = property_usage.count + EXCLUDED.count`
+
+	_, err := r.db.DB.ExecContext(ctx, query,
+		teamIDs, blueprintIDs, properties, usages, days, counts)
+	return err
+}
+
+// PropertyUsage sums a blueprint's usage counters per property and usage
+// since the given day. Day is set to the last day each counter was used.
+func (r *Repository) PropertyUsage(ctx context.Context, teamID uuid.UUID, blueprintID string, since time.Time) ([]UsageRow, error) {
+	query := `
+		SELECT property, usage, SUM(count), MAX(day)
+		FROM property_usage
+		WHERE team_id = $1 AND blueprint_id = $2 AND day >= $3
+		GROUP BY property, usage`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, blueprintID, since.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usage []UsageRow
+	for rows.Next() {
+		u := UsageRow{TeamID: teamID, BlueprintID: blueprintID}
+		if err := rows.Scan(&u.Property, &u.Usage, &u.Count, &u.Day); err != nil {
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// PrunePropertyUsage removes usage counters of days before the given one
+func (r *Repository) PrunePropertyUsage(ctx context.Context, before time.Time) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM property_usage WHERE day < $1`, before.Format(time.DateOnly))
+	return err
+}
+
+// Reconcile claims the unowned entities of a blueprint whose identifiers are
+// in the set for the integration, and returns, or with del deletes, the
+// entities the integration owns outside the set. Both happen in one
+// transaction so a concurrent reconcile cannot interleave.
+func (r *Repository) Reconcile(ctx context.Context, teamID uuid.UUID, blueprintID string, integrationID uuid.UUID, identifiers []string, del bool) (int64, []*Entity, error) {
+	if identifiers == nil {
+		// ANY(NULL) matches nothing, not even in NOT
+		identifiers = []string{}
+	}
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE entities SET integration_id = $3
+		WHERE team_id = $1 AND blueprint_id = $2 AND integration_id IS NULL AND identifier = ANY($4)`,
+		teamID, blueprintID, integrationID, identifiers)
+	if err != nil {
+		return 0, nil, err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	stale := `
+		SELECT id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
+		FROM entities
+		WHERE team_id = $1 AND blueprint_id = $2 AND integration_id = $3 AND NOT (identifier = ANY($4))
+		ORDER BY identifier`
+	args := []interface{}{teamID, blueprintID, integrationID, identifiers}
+	if del {
+		stale = `
+			WITH deleted AS (
+				DELETE FROM entities
+				WHERE team_id = $1 AND blueprint_id = $2 AND integration_id = $3 AND NOT (identifier = ANY($4))
+				RETURNING id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
+			), ` + recordWrite("deleted", "$5", "$6") + `
+			SELECT id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
+			FROM deleted
+			ORDER BY identifier`
+		userID, apiKeyID := historyActor(ctx)
+		args = append(args, userID, apiKeyID)
+	}
+
+	rows, err := tx.QueryContext(ctx, stale, args...)
+	if err != nil {
+		return 0, nil, err
+	}
+	entities, err := r.scanEntities(rows)
+	rows.Close()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return claimed, entities, tx.Commit()
+}
+
+// ListRelations returns a team's blueprint relations
+func (r *Repository) ListRelations(ctx context.Context, teamID uuid.UUID) ([]*Relation, error) {
+	query := `
+		SELECT id, source_blueprint_id, identifier, target_blueprint_id, relation_type, COALESCE(required, false)
+		FROM blueprint_relations
+		WHERE team_id = $1`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var relations []*Relation
+	for rows.Next() {
+		rel := &Relation{}
+		if err := rows.Scan(&rel.ID, &rel.Source, &rel.Identifier, &rel.Target, &rel.Type, &rel.Required); err != nil {
+			return nil, err
+		}
+		relations = append(relations, rel)
+	}
+	return relations, rows.Err()
+}
+
+// SetRelations replaces the entities an entity links to under a relation
+func (r *Repository) SetRelations(ctx context.Context, relationID, sourceID uuid.UUID, targetIDs []uuid.UUID) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM entity_relations WHERE relation_id = $1 AND source_entity_id = $2`, relationID, sourceID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO entity_relations (relation_id, source_entity_id, target_entity_id)
+		SELECT $1, $2, unnest($3::uuid[])
+		ON CONFLICT DO NOTHING`, relationID, sourceID, targetIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// NextSequence takes the next number of a blueprint property's sequence,
+// starting at 1
+func (r *Repository) NextSequence(ctx context.Context, teamID uuid.UUID, blueprintID, property string) (int64, error) {
+	var value int64
+	err := r.db.DB.QueryRowContext(ctx, `
+		INSERT INTO entity_sequences (team_id, blueprint_id, property, value) VALUES ($1, $2, $3, 1)
+		ON CONFLICT (team_id, blueprint_id, property) DO UPDATE SET value = entity_sequences.value + 1
+		RETURNING value`, teamID, blueprintID, property).Scan(&value)
+	return value, err
+}
+
+// PeekSequence returns the number NextSequence would take, without taking it
+func (r *Repository) PeekSequence(ctx context.Context, teamID uuid.UUID, blueprintID, property string) (int64, error) {
+	var value int64
+	err := r.db.Reader(ctx).QueryRowContext(ctx, `
+		SELECT COALESCE(MAX(value), 0) + 1 FROM entity_sequences
+		WHERE team_id = $1 AND blueprint_id = $2 AND property = $3`, teamID, blueprintID, property).Scan(&value)
+	return value, err
+}
+
+// RelatedEntity is an entity that SourceID links to under RelationID
+type RelatedEntity struct {
+	RelationID uuid.UUID
+	SourceID   uuid.UUID
+	Entity     *Entity
+}
+
+// RelatedEntities returns the entities the sources link to under the
+// relations, at most limit per source and relation, ordered by identifier
+func (r *Repository) RelatedEntities(ctx context.Context, teamID uuid.UUID, relationIDs, sourceIDs []uuid.UUID, limit int) ([]RelatedEntity, error) {
+	ctx, cancel := r.db.WithQueryTimeout(ctx)
+	defer cancel()
+
+	query := `
+		SELECT relation_id, source_entity_id,
+			id, team_id, blueprint_id, identifier, title, data, version, integration_id, property_sources, expires_at, created_at, updated_at
+		FROM (
+			SELECT er.relation_id, er.source_entity_id, e.*,
+				row_number() OVER (PARTITION BY er.relation_id, er.source_entity_id ORDER BY e.identifier) AS n
+			FROM entity_relations er
+			JOIN entities e ON e.id = er.target_entity_id
+			WHERE er.relation_id = ANY($1::uuid[]) AND er.source_entity_id = ANY($2::uuid[]) AND e.team_id = $3
+		) related
+		WHERE n <= $4
+		ORDER BY identifier`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, relationIDs, sourceIDs, teamID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var related []RelatedEntity
+	for rows.Next() {
+		var rel RelatedEntity
+		if rel.Entity, err = r.scanRow(rows, &rel.RelationID, &rel.SourceID); err != nil {
+			return nil, err
+		}
+		related = append(related, rel)
+	}
+	return related, rows.Err()
+}
+
+// RollupParents returns the entities whose rollup over a relation reads a
+// child: the sources linking to it, or with inbound the targets it links to
+func (r *Repository) RollupParents(ctx context.Context, relationID uuid.UUID, inbound bool, childID uuid.UUID) ([]uuid.UUID, error) {
+	query := `SELECT source_entity_id FROM entity_relations WHERE relation_id = $1 AND target_entity_id = $2`
+	if inbound {
+		query = `SELECT target_entity_id FROM entity_relations WHERE relation_id = $1 AND source_entity_id = $2`
+	}
+	rows, err := r.db.DB.QueryContext(ctx, query, relationID, childID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// RollupValues returns the values of a property of a parent's children over
+// a relation: the targets it links to, or with inbound the sources linking to
+// it. Children without the property are left out.
+func (r *Repository) RollupValues(ctx context.Context, relationID uuid.UUID, inbound bool, parentID uuid.UUID, property string) ([]interface{}, error) {
+	query := `
+		SELECT e.data -> $3
+		FROM entity_relations er
+		JOIN entities e ON e.id = er.target_entity_id
+		WHERE er.relation_id = $1 AND er.source_entity_id = $2 AND e.data ? $3`
+	if inbound {
+		query = `
+		SELECT e.data -> $3
+		FROM entity_relations er
+		JOIN entities e ON e.id = er.source_entity_id
+		WHERE er.relation_id = $1 AND er.target_entity_id = $2 AND e.data ? $3`
+	}
+	rows, err := r.db.DB.QueryContext(ctx, query, relationID, parentID, property)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []interface{}
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
 }
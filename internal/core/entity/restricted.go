@@ -0,0 +1,160 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+// ErrRestrictedProperty rejects writes to restricted properties by callers
+// without the entity:restricted permission
+var ErrRestrictedProperty = errors.New("restricted property requires the entity:restricted permission")
+
+// restrictedAccess reports whether the caller behind ctx may read and write
+// restricted properties. Users and API keys need entity:restricted; the
+// server's own work, runners and work outside of a request may.
+func restrictedAccess(ctx context.Context) bool {
+	rc, ok := reqctx.From(ctx)
+	if !ok {
+		return true
+	}
+	switch rc.ActorType {
+	case reqctx.ActorSystem, reqctx.ActorRunner:
+		return true
+	}
+	return rc.Can(auth.PermEntityRestricted)
+}
+
+// hiddenProperties returns the restricted properties of a blueprint the
+// caller behind ctx may not read, nil when it may read them all
+func hiddenProperties(ctx context.Context, bp *blueprint.Blueprint) []string {
+	if restrictedAccess(ctx) {
+		return nil
+	}
+	return blueprint.RestrictedProperties(bp.Schema)
+}
+
+// searchKind returns the search cache kind of a query by the caller behind
+// ctx. Queries of callers that may not read restricted properties are cached
+// apart, so that only queries checked against their hidden properties hit.
+func searchKind(ctx context.Context, kind string) string {
+	if restrictedAccess(ctx) {
+		return kind
+	}
+	return kind + ":redacted"
+}
+
+// Redact returns the entities as the caller behind ctx may see them: copies
+// without the restricted properties it may not read. Entities it may read in
+// full are returned as they are.
+func (s *Service) Redact(ctx context.Context, entities ...*Entity) ([]*Entity, error) {
+	if restrictedAccess(ctx) {
+		return entities, nil
+	}
+	hidden := make(map[string][]string)
+	redacted := make([]*Entity, len(entities))
+	for i, e := range entities {
+		key := e.TeamID.String() + "/" + e.BlueprintID
+		properties, ok := hidden[key]
+		if !ok {
+			bp, err := s.blueprintSvc.Get(ctx, e.TeamID, e.BlueprintID)
+			if err != nil {
+				return nil, err
+			}
+			properties = blueprint.RestrictedProperties(bp.Schema)
+			hidden[key] = properties
+		}
+		redacted[i] = redact(e, properties)
+	}
+	return redacted, nil
+}
+
+// redact returns a copy of e without the hidden properties, or e itself when
+// it has none of them
+func redact(e *Entity, hidden []string) *Entity {
+	if !slices.ContainsFunc(hidden, func(p string) bool { _, ok := e.Data[p]; return ok }) {
+		return e
+	}
+	copied := *e
+	copied.Data = make(map[string]interface{}, len(e.Data))
+	for k, v := range e.Data {
+		if !slices.Contains(hidden, k) {
+			copied.Data[k] = v
+		}
+	}
+	return &copied
+}
+
+// keepHidden checks a write of next over previous by a caller that may not
+// read the hidden properties. Values the caller cannot see are kept when the
+// write leaves them out, e.g. when a patch replaces the whole data object;
+// setting or changing one is rejected.
+func keepHidden(hidden []string, previous, next map[string]interface{}) error {
+	return keepValues(hidden, previous, next, ErrRestrictedProperty)
+}
+
+// keepValues keeps the values of properties a write leaves out, and rejects
+// setting or changing one with errProperty
+func keepValues(properties []string, previous, next map[string]interface{}, errProperty error) error {
+	for _, property := range properties {
+		before, had := previous[property]
+		after, has := next[property]
+		switch {
+		case !has && had:
+			next[property] = before
+		case has && (!had || !reflect.DeepEqual(before, after)):
+			return fmt.Errorf("%w: %s", errProperty, property)
+		}
+	}
+	return nil
+}
+
+// hideSchema returns a copy of a schema without the hidden properties, for
+// CSV columns of callers that may not read them
+func hideSchema(schema map[string]interface{}, hidden []string) map[string]interface{} {
+	if len(hidden) == 0 {
+		return schema
+	}
+	props, _ := schema["properties"].(map[string]interface{})
+	visible := make(map[string]interface{}, len(props))
+	for name, prop := range props {
+		if !slices.Contains(hidden, name) {
+			visible[name] = prop
+		}
+	}
+	copied := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		copied[k] = v
+	}
+	copied["properties"] = visible
+	return copied
+}
+
+// hiddenHistory returns the hidden properties of the blueprint an entity's
+// history belongs to. The history of a deleted blueprint's entities has no
+// schema left to restrict it.
+func (s *Service) hiddenHistory(ctx context.Context, teamID, id uuid.UUID) ([]string, error) {
+	if restrictedAccess(ctx) {
+		return nil, nil
+	}
+	blueprintID, err := s.repo.HistoryBlueprint(ctx, teamID, id)
+	if err != nil || blueprintID == "" {
+		return nil, err
+	}
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if errors.Is(err, blueprint.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return blueprint.RestrictedProperties(bp.Schema), nil
+}
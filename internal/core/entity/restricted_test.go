@@ -0,0 +1,131 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+func TestRestrictedAccess(t *testing.T) {
+	userID := uuid.New()
+	user := func(permissions ...string) context.Context {
+		return reqctx.With(context.Background(), reqctx.RequestContext{ActorType: reqctx.ActorUser, UserID: &userID, Permissions: permissions})
+	}
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want bool
+	}{
+		{"background", context.Background(), true},
+		{"system", reqctx.System(context.Background()), true},
+		{"runner", reqctx.With(context.Background(), reqctx.RequestContext{ActorType: reqctx.ActorRunner}), true},
+		{"user without permission", user(auth.PermEntityRead, auth.PermEntityWrite), false},
+		{"user with permission", user(auth.PermEntityRead, auth.PermEntityRestricted), true},
+		{"api key without permission", reqctx.With(context.Background(), reqctx.RequestContext{ActorType: reqctx.ActorAPIKey}), false},
+		{"anonymous", reqctx.With(context.Background(), reqctx.RequestContext{ActorType: reqctx.ActorAnonymous}), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := restrictedAccess(tt.ctx); got != tt.want {
+				t.Errorf("restrictedAccess() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	e := &Entity{Identifier: "api", Data: map[string]interface{}{"owner": "ops", "cost": 1200}}
+
+	got := redact(e, []string{"cost", "salary"})
+	if want := map[string]interface{}{"owner": "ops"}; !reflect.DeepEqual(got.Data, want) {
+		t.Errorf("redacted data = %v, want %v", got.Data, want)
+	}
+	if got.Identifier != "api" || e.Data["cost"] != 1200 {
+		t.Errorf("redact changed the entity: %+v", e)
+	}
+	if got := redact(e, []string{"salary"}); got != e {
+		t.Error("entity without hidden properties was copied")
+	}
+}
+
+func TestKeepHidden(t *testing.T) {
+	hidden := []string{"cost"}
+	previous := map[string]interface{}{"owner": "ops", "cost": 1200.0}
+
+	// Values left out are kept
+	next := map[string]interface{}{"owner": "platform"}
+	if err := keepHidden(hidden, previous, next); err != nil {
+		t.Fatalf("keepHidden: %v", err)
+	}
+	if next["cost"] != 1200.0 {
+		t.Errorf("cost = %v, want it kept", next["cost"])
+	}
+
+	// Unchanged values pass
+	if err := keepHidden(hidden, previous, map[string]interface{}{"cost": 1200.0}); err != nil {
+		t.Errorf("unchanged value: %v", err)
+	}
+
+	for name, next := range map[string]map[string]interface{}{
+		"changed": {"cost": 5.0},
+		"set":     {"cost": nil},
+	} {
+		if err := keepHidden(hidden, previous, next); !errors.Is(err, ErrRestrictedProperty) {
+			t.Errorf("%s: err = %v, want ErrRestrictedProperty", name, err)
+		}
+	}
+	if err := keepHidden(hidden, nil, map[string]interface{}{"cost": 1.0}); !errors.Is(err, ErrRestrictedProperty) {
+		t.Errorf("new value: err = %v, want ErrRestrictedProperty", err)
+	}
+}
+
+func TestFilterCompiler_Hidden(t *testing.T) {
+	fc := NewFilterCompiler(map[string]interface{}{
+		"properties": map[string]interface{}{
+			"owner": map[string]interface{}{"type": "string"},
+			"billing": map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{"cost": map[string]interface{}{"type": "number"}},
+			},
+		},
+	})
+	fc.hidden = []string{"billing"}
+
+	if _, err := fc.Property("owner"); err != nil {
+		t.Errorf("owner: %v", err)
+	}
+	for _, path := range []string{"billing", "billing.cost"} {
+		if _, err := fc.Property(path); !errors.Is(err, errUnknownProperty) {
+			t.Errorf("%s: err = %v, want unknown property", path, err)
+		}
+	}
+	if err := fc.Validate(nil, "billing.cost", "asc"); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("order by hidden property: err = %v", err)
+	}
+}
+
+func TestHideSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"required": []interface{}{"owner", "cost"},
+		"properties": map[string]interface{}{
+			"owner": map[string]interface{}{"type": "string"},
+			"cost":  map[string]interface{}{"type": "number"},
+		},
+	}
+	var headers []string
+	for _, c := range CSVColumns(hideSchema(schema, []string{"cost"})) {
+		headers = append(headers, c.Header)
+	}
+	if want := []string{"identifier", "title", "owner"}; !reflect.DeepEqual(headers, want) {
+		t.Errorf("columns = %v, want %v", headers, want)
+	}
+	if props := schema["properties"].(map[string]interface{}); len(props) != 2 {
+		t.Errorf("hideSchema changed the schema: %v", props)
+	}
+}
@@ -0,0 +1,474 @@
+package entity
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+// Rollup kinds, see migrations/003_entity_rollups.sql
+const (
+	RollupCount          = "count"
+	RollupEnum           = "enum"
+	RollupScorecard      = "scorecard"
+	RollupScorecardRules = "scorecard_rules"
+)
+
+const (
+	// rollupFlushInterval is how often queued entity changes are applied to the rollup tables
+	rollupFlushInterval = time.Second
+
+	// rollupQueueSize bounds queued changes; on overflow the blueprint is rebuilt instead
+	rollupQueueSize = 4096
+
+	// rollupLockID keeps replicas from running full rebuilds at the same time
+	rollupLockID = 0x62707275 // "bpru"
+)
+
+// RollupRow is one precomputed counter
+type RollupRow struct {
+	TeamID      uuid.UUID
+	BlueprintID string
+	Kind        string
+	Key         string
+	Value       string
+	Count       int64
+}
+
+// rollupCounter identifies a counter within one blueprint
+type rollupCounter struct {
+	kind, key, value string
+}
+
+// rollupPlan lists the dimensions maintained for one blueprint: the entity
+// count, every scalar property with an enum, and every scorecard
+type rollupPlan struct {
+	enumPaths  []string
+	scorecards []*scorecard.Scorecard
+}
+
+func newRollupPlan(schema map[string]interface{}, scorecards []*scorecard.Scorecard) *rollupPlan {
+	p := &rollupPlan{scorecards: scorecards}
+	collectEnumPaths(schema, nil, &p.enumPaths)
+	sort.Strings(p.enumPaths)
+	return p
+}
+
+func collectEnumPaths(schema map[string]interface{}, prefix []string, paths *[]string) {
+	props, _ := schema["properties"].(map[string]interface{})
+	for name, raw := range props {
+		prop, ok := raw.(map[string]interface{})
+		if !ok || !propertySegment.MatchString(name) {
+			continue
+		}
+		path := append(append([]string{}, prefix...), name)
+		switch schemaType(prop) {
+		case "object":
+			collectEnumPaths(prop, path, paths)
+		case "array":
+			// Aggregates group arrays by their whole value, which enums do not describe
+		default:
+			if _, ok := prop["enum"].([]interface{}); ok {
+				*paths = append(*paths, strings.Join(path, "."))
+			}
+		}
+	}
+}
+
+func enumDimension(path string) string {
+	return RollupEnum + ":" + path
+}
+
+func scorecardDimension(identifier string) string {
+	return RollupScorecard + ":" + identifier
+}
+
+// dimensions names what the plan covers, as stored in entity_rollup_state
+func (p *rollupPlan) dimensions() []string {
+	dims := make([]string, 0, len(p.enumPaths)+len(p.scorecards))
+	for _, path := range p.enumPaths {
+		dims = append(dims, enumDimension(path))
+	}
+	for _, sc := range p.scorecards {
+		dims = append(dims, scorecardDimension(sc.Identifier))
+	}
+	return dims
+}
+
+// restrict drops dimensions the last rebuild did not cover, so incremental
+// updates never touch counters that were not built
+func (p *rollupPlan) restrict(dims []string) *rollupPlan {
+	r := &rollupPlan{}
+	for _, path := range p.enumPaths {
+		if slices.Contains(dims, enumDimension(path)) {
+			r.enumPaths = append(r.enumPaths, path)
+		}
+	}
+	for _, sc := range p.scorecards {
+		if slices.Contains(dims, scorecardDimension(sc.Identifier)) {
+			r.scorecards = append(r.scorecards, sc)
+		}
+	}
+	return r
+}
+
+// contribute adds the counters one entity contributes, multiplied by sign
+func (p *rollupPlan) contribute(counts map[rollupCounter]int64, data map[string]interface{}, sign int64) {
+	counts[rollupCounter{RollupCount, "", ""}] += sign
+	for _, path := range p.enumPaths {
+		value, exists := scorecard.LookupPath(data, path)
+		counts[rollupCounter{RollupEnum, path, rollupValue(value, exists)}] += sign
+	}
+	for _, sc := range p.scorecards {
+		result := sc.Evaluate(data)
+		counts[rollupCounter{RollupScorecard, sc.Identifier, result.Level}] += sign
+		counts[rollupCounter{RollupScorecardRules, sc.Identifier, "passed"}] += sign * int64(result.RulesPassed)
+		counts[rollupCounter{RollupScorecardRules, sc.Identifier, "total"}] += sign * int64(result.RulesTotal)
+	}
+}
+
+// rollupValue renders a value the way `data #>> path` does, so rollup buckets
+// match the keys of a grouped aggregate. Missing and null values become "".
+func rollupValue(v interface{}, exists bool) string {
+	if !exists {
+		return ""
+	}
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// entityChange is a queued entity write; before is nil for creates, after for deletes
+type entityChange struct {
+	teamID      uuid.UUID
+	blueprintID string
+	before      map[string]interface{}
+	after       map[string]interface{}
+}
+
+// RollupMaintainer keeps the entity_rollups table current. Entity events are
+// queued and applied in batches; blueprints are rebuilt from scratch after
+// schema changes, when the queue overflows, and on every rebuild interval to
+// correct any drift. A nil *RollupMaintainer maintains nothing and serves no rollups.
+type RollupMaintainer struct {
+	repo       *Repository
+	blueprints *blueprint.Repository
+	scorecards *scorecard.Repository
+	changes    chan entityChange
+
+	mu                    sync.Mutex
+	dirty                 map[string]rollupTarget
+	scorecardsByBlueprint map[string][]*scorecard.Scorecard
+	lastErr               error
+}
+
+type rollupTarget struct {
+	teamID      uuid.UUID
+	blueprintID string
+}
+
+func NewRollupMaintainer(repo *Repository, blueprints *blueprint.Repository, scorecards *scorecard.Repository) *RollupMaintainer {
+	return &RollupMaintainer{
+		repo:                  repo,
+		blueprints:            blueprints,
+		scorecards:            scorecards,
+		changes:               make(chan entityChange, rollupQueueSize),
+		dirty:                 make(map[string]rollupTarget),
+		scorecardsByBlueprint: make(map[string][]*scorecard.Scorecard),
+	}
+}
+
+// Subscribe queues entity changes and schedules rebuilds on blueprint changes
+func (m *RollupMaintainer) Subscribe(bus *events.Bus) {
+	if m == nil || bus == nil {
+		return
+	}
+	bus.Subscribe("entity.*", func(ctx context.Context, e events.Event) {
+		change := entityChange{teamID: e.TeamID, blueprintID: e.BlueprintID}
+		entity, _ := e.Payload.(*Entity)
+		if entity == nil {
+			return
+		}
+		switch e.Type {
+		case events.EntityCreated:
+			change.after = entity.Data
+		case events.EntityDeleted:
+			change.before = entity.Data
+		default:
+			previous, _ := e.Previous.(*Entity)
+			if previous == nil {
+				m.markDirty(e.TeamID, e.BlueprintID)
+				return
+			}
+			change.before, change.after = previous.Data, entity.Data
+		}
+		select {
+		case m.changes <- change:
+		default:
+			m.markDirty(e.TeamID, e.BlueprintID)
+		}
+	})
+	bus.Subscribe("blueprint.*", func(ctx context.Context, e events.Event) {
+		m.markDirty(e.TeamID, e.BlueprintID)
+	})
+}
+
+func (m *RollupMaintainer) markDirty(teamID uuid.UUID, blueprintID string) {
+	m.mu.Lock()
+	m.dirty[blueprintKey(teamID, blueprintID)] = rollupTarget{teamID, blueprintID}
+	m.mu.Unlock()
+}
+
+// Buckets answers an unfiltered count aggregate from the rollups. ok is false
+// when the rollups do not cover the grouping yet; callers then query entities.
+func (m *RollupMaintainer) Buckets(ctx context.Context, teamID uuid.UUID, blueprintID, groupBy string) ([]AggregateBucket, bool, error) {
+	if m == nil {
+		return nil, false, nil
+	}
+	dims, err := m.repo.RollupDimensions(ctx, teamID, blueprintID)
+	if err != nil || dims == nil {
+		return nil, false, err
+	}
+	if groupBy == "" {
+		buckets, err := m.repo.RollupBuckets(ctx, teamID, blueprintID, RollupCount, "")
+		if err != nil {
+			return nil, false, err
+		}
+		if len(buckets) == 0 {
+			buckets = []AggregateBucket{{}}
+		}
+		return buckets, true, nil
+	}
+	if !slices.Contains(dims, enumDimension(groupBy)) {
+		return nil, false, nil
+	}
+	buckets, err := m.repo.RollupBuckets(ctx, teamID, blueprintID, RollupEnum, groupBy)
+	return buckets, err == nil, err
+}
+
+// Run rebuilds every blueprint at start and on every interval, and applies
+// queued changes every second, until ctx is done
+func (m *RollupMaintainer) Run(ctx context.Context, interval time.Duration) {
+	flush := time.NewTicker(rollupFlushInterval)
+	defer flush.Stop()
+	rebuild := time.NewTicker(interval)
+	defer rebuild.Stop()
+
+	m.rebuildAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flush.C:
+			err := m.flush(ctx)
+			if err != nil {
+				log.Printf("ERROR: rollup update failed: %v", err)
+			}
+			m.setLastErr(err)
+		case <-rebuild.C:
+			m.rebuildAll(ctx)
+		}
+	}
+}
+
+func (m *RollupMaintainer) rebuildAll(ctx context.Context) {
+	rebuilt, err := m.Rebuild(ctx)
+	m.setLastErr(err)
+	if err != nil {
+		log.Printf("ERROR: rollup rebuild failed: %v", err)
+		return
+	}
+	if rebuilt > 0 {
+		log.Printf("rollups: rebuilt %d blueprints", rebuilt)
+	}
+}
+
+func (m *RollupMaintainer) setLastErr(err error) {
+	m.mu.Lock()
+	m.lastErr = err
+	m.mu.Unlock()
+}
+
+// Backlog returns the number of queued entity changes, the queue capacity and
+// the error of the last update or rebuild, which is nil once one succeeds
+func (m *RollupMaintainer) Backlog() (queued, capacity int, lastErr error) {
+	if m == nil {
+		return 0, 0, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.changes), cap(m.changes), m.lastErr
+}
+
+func (m *RollupMaintainer) loadScorecards(ctx context.Context) error {
+	all, err := m.scorecards.ListAll(ctx)
+	if err != nil {
+		return err
+	}
+	byBlueprint := make(map[string][]*scorecard.Scorecard)
+	for _, sc := range all {
+		key := blueprintKey(sc.TeamID, sc.BlueprintID)
+		byBlueprint[key] = append(byBlueprint[key], sc)
+	}
+	m.mu.Lock()
+	m.scorecardsByBlueprint = byBlueprint
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *RollupMaintainer) plan(bp *blueprint.Blueprint) *rollupPlan {
+	m.mu.Lock()
+	scorecards := m.scorecardsByBlueprint[blueprintKey(bp.TeamID, bp.ID)]
+	m.mu.Unlock()
+	return newRollupPlan(bp.Schema, scorecards)
+}
+
+// Rebuild reloads the scorecards, so changed rules and levels are picked up,
+// and recomputes every blueprint's rollups
+func (m *RollupMaintainer) Rebuild(ctx context.Context) (int, error) {
+	if err := m.loadScorecards(ctx); err != nil {
+		return 0, err
+	}
+	return m.RebuildAll(ctx)
+}
+
+// RebuildAll recomputes the rollups of every blueprint and returns how many
+// were rebuilt. It does nothing while another instance holds the rebuild lock.
+func (m *RollupMaintainer) RebuildAll(ctx context.Context) (int, error) {
+	conn, err := m.repo.db.DB.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", rollupLockID).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, nil
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", rollupLockID)
+
+	blueprints, err := m.blueprints.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	for _, bp := range blueprints {
+		plan := m.plan(bp)
+		if err := m.repo.RebuildRollups(ctx, bp.TeamID, bp.ID, plan); err != nil {
+			return 0, err
+		}
+	}
+	if err := m.repo.DeleteOrphanedRollups(ctx); err != nil {
+		return 0, err
+	}
+	return len(blueprints), nil
+}
+
+// flush applies queued changes, then rebuilds blueprints marked dirty
+func (m *RollupMaintainer) flush(ctx context.Context) error {
+	var changes []entityChange
+drain:
+	for {
+		select {
+		case c := <-m.changes:
+			changes = append(changes, c)
+		default:
+			break drain
+		}
+	}
+
+	m.mu.Lock()
+	dirty := m.dirty
+	m.dirty = make(map[string]rollupTarget)
+	m.mu.Unlock()
+
+	// Group changes per blueprint; dirty blueprints are rebuilt, so their changes are dropped
+	byBlueprint := make(map[string][]entityChange)
+	for _, c := range changes {
+		key := blueprintKey(c.teamID, c.blueprintID)
+		if _, ok := dirty[key]; !ok {
+			byBlueprint[key] = append(byBlueprint[key], c)
+		}
+	}
+
+	var rows []RollupRow
+	for _, group := range byBlueprint {
+		teamID, blueprintID := group[0].teamID, group[0].blueprintID
+		dims, err := m.repo.RollupDimensions(ctx, teamID, blueprintID)
+		if err != nil {
+			return err
+		}
+		if dims == nil {
+			// Never built: rebuild instead of applying deltas to nothing
+			dirty[blueprintKey(teamID, blueprintID)] = rollupTarget{teamID, blueprintID}
+			continue
+		}
+		bp, err := m.blueprints.GetByID(ctx, teamID, blueprintID)
+		if err != nil {
+			return err
+		}
+		if bp == nil {
+			continue
+		}
+
+		plan := m.plan(bp).restrict(dims)
+		counts := make(map[rollupCounter]int64)
+		for _, c := range group {
+			if c.before != nil {
+				plan.contribute(counts, c.before, -1)
+			}
+			if c.after != nil {
+				plan.contribute(counts, c.after, 1)
+			}
+		}
+		for counter, delta := range counts {
+			if delta != 0 {
+				rows = append(rows, RollupRow{teamID, blueprintID, counter.kind, counter.key, counter.value, delta})
+			}
+		}
+	}
+	if err := m.repo.ApplyRollupDeltas(ctx, rows); err != nil {
+		return err
+	}
+
+	for _, target := range dirty {
+		bp, err := m.blueprints.GetByID(ctx, target.teamID, target.blueprintID)
+		if err != nil {
+			m.markDirty(target.teamID, target.blueprintID)
+			return err
+		}
+		if bp == nil {
+			err = m.repo.DeleteRollups(ctx, target.teamID, target.blueprintID)
+		} else {
+			err = m.repo.RebuildRollups(ctx, bp.TeamID, bp.ID, m.plan(bp))
+		}
+		if err != nil {
+			m.markDirty(target.teamID, target.blueprintID)
+			return err
+		}
+	}
+	return nil
+}
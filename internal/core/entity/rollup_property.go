@@ -0,0 +1,409 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/events"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+// ErrRollupProperty rejects writes to rollup properties, which the server
+// computes from related entities
+var ErrRollupProperty = errors.New("rollup property is computed by the server")
+
+// keepRollups checks a write of next over previous: rollup properties keep
+// their values when the write leaves them out, and setting or changing one
+// is rejected
+func keepRollups(bp *blueprint.Blueprint, previous, next map[string]interface{}) error {
+	rollups := blueprint.Rollups(bp.Schema)
+	if len(rollups) == 0 {
+		return nil
+	}
+	properties := make([]string, len(rollups))
+	for i, r := range rollups {
+		properties[i] = r.Property
+	}
+	return keepValues(properties, previous, next, ErrRollupProperty)
+}
+
+// resolvedRollup is a rollup property with its relation resolved
+type resolvedRollup struct {
+	*blueprint.PropertyRollup
+	parent     string // blueprint with the rollup property
+	child      string // blueprint of the children
+	relationID uuid.UUID
+}
+
+// rollupGraph is a team's rollup properties, by the blueprint that has them
+// and by the blueprint of the children they read
+type rollupGraph struct {
+	blueprints map[string]*blueprint.Blueprint
+	byParent   map[string][]*resolvedRollup
+	byChild    map[string][]*resolvedRollup
+}
+
+// newRollupGraph resolves the relations of the blueprints' rollup
+// properties. Rollups over relations that do not exist are left out.
+func newRollupGraph(blueprints []*blueprint.Blueprint, relations []*Relation) *rollupGraph {
+	g := &rollupGraph{
+		blueprints: make(map[string]*blueprint.Blueprint),
+		byParent:   make(map[string][]*resolvedRollup),
+		byChild:    make(map[string][]*resolvedRollup),
+	}
+	for _, bp := range blueprints {
+		for _, rollup := range blueprint.Rollups(bp.Schema) {
+			resolved := &resolvedRollup{PropertyRollup: rollup, parent: bp.ID}
+			for _, rel := range relations {
+				if rel.Identifier != rollup.Relation {
+					continue
+				}
+				switch {
+				case rollup.Inbound() && rel.Source == rollup.Blueprint && rel.Target == bp.ID:
+					resolved.child, resolved.relationID = rel.Source, rel.ID
+				case !rollup.Inbound() && rel.Source == bp.ID:
+					resolved.child, resolved.relationID = rel.Target, rel.ID
+				}
+			}
+			if resolved.child == "" {
+				continue
+			}
+			g.blueprints[bp.ID] = bp
+			g.byParent[bp.ID] = append(g.byParent[bp.ID], resolved)
+			g.byChild[resolved.child] = append(g.byChild[resolved.child], resolved)
+		}
+	}
+	return g
+}
+
+// RollupPropertyUpdater computes rollup properties, e.g. a system's health
+// as the worst health of its services. Entity changes are queued and applied
+// every second: the rollups of the changed entities and of the entities that
+// read them are recomputed, so changes climb chains of rollups one level at
+// a time. Relations are set without events, so every rollup is also
+// recomputed on an interval. Values are written with a version check and
+// published as entity updates; a write that loses to another is redone when
+// that write's event is applied.
+type RollupPropertyUpdater struct {
+	service    *Service
+	blueprints *blueprint.Repository
+
+	mu      sync.Mutex
+	changed map[uuid.UUID]rollupTarget
+	deleted map[string]rollupTarget
+	stale   map[string]rollupTarget
+	lastErr error
+}
+
+func NewRollupPropertyUpdater(service *Service, blueprints *blueprint.Repository) *RollupPropertyUpdater {
+	return &RollupPropertyUpdater{
+		service:    service,
+		blueprints: blueprints,
+		changed:    make(map[uuid.UUID]rollupTarget),
+		deleted:    make(map[string]rollupTarget),
+		stale:      make(map[string]rollupTarget),
+	}
+}
+
+// Subscribe queues written entities, the blueprints of deleted ones, and
+// blueprints whose rollup properties may have changed
+func (w *RollupPropertyUpdater) Subscribe(bus *events.Bus) {
+	if w == nil || bus == nil {
+		return
+	}
+	written := func(ctx context.Context, e events.Event) {
+		if e.EntityID == nil {
+			return
+		}
+		w.mu.Lock()
+		w.changed[*e.EntityID] = rollupTarget{e.TeamID, e.BlueprintID}
+		w.mu.Unlock()
+	}
+	deleted := func(ctx context.Context, e events.Event) {
+		w.mu.Lock()
+		w.deleted[blueprintKey(e.TeamID, e.BlueprintID)] = rollupTarget{e.TeamID, e.BlueprintID}
+		w.mu.Unlock()
+	}
+	recompute := func(ctx context.Context, e events.Event) {
+		bp, _ := e.Payload.(*blueprint.Blueprint)
+		previous, _ := e.Previous.(*blueprint.Blueprint)
+		switch {
+		case bp == nil:
+			return
+		case previous == nil && len(blueprint.Rollups(bp.Schema)) == 0:
+			return
+		case previous != nil && reflect.DeepEqual(blueprint.Rollups(bp.Schema), blueprint.Rollups(previous.Schema)):
+			return
+		}
+		w.mu.Lock()
+		w.stale[blueprintKey(e.TeamID, e.BlueprintID)] = rollupTarget{e.TeamID, e.BlueprintID}
+		w.mu.Unlock()
+	}
+	bus.Subscribe(events.EntityCreated, written)
+	bus.Subscribe(events.EntityUpdated, written)
+	bus.Subscribe(events.EntityDeleted, deleted)
+	bus.Subscribe(events.BlueprintDeleted, deleted)
+	bus.Subscribe(events.BlueprintCreated, recompute)
+	bus.Subscribe(events.BlueprintUpdated, recompute)
+}
+
+// Run recomputes every rollup property at start and on every interval, and
+// applies queued changes every second, until ctx is done
+func (w *RollupPropertyUpdater) Run(ctx context.Context, interval time.Duration) {
+	flush := time.NewTicker(rollupFlushInterval)
+	defer flush.Stop()
+	recompute := time.NewTicker(interval)
+	defer recompute.Stop()
+
+	w.recomputeAll(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flush.C:
+			err := w.flush(ctx)
+			if err != nil {
+				log.Printf("ERROR: rollup property update failed: %v", err)
+			}
+			w.setLastErr(err)
+		case <-recompute.C:
+			w.recomputeAll(ctx)
+		}
+	}
+}
+
+// LastError returns the error of the last run, nil once one succeeds
+func (w *RollupPropertyUpdater) LastError() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.lastErr
+}
+
+func (w *RollupPropertyUpdater) setLastErr(err error) {
+	w.mu.Lock()
+	w.lastErr = err
+	w.mu.Unlock()
+}
+
+// recomputeAll marks every blueprint with rollup properties stale and
+// recomputes them
+func (w *RollupPropertyUpdater) recomputeAll(ctx context.Context) {
+	blueprints, err := w.blueprints.ListAll(ctx)
+	if err == nil {
+		w.mu.Lock()
+		for _, bp := range blueprints {
+			if len(blueprint.Rollups(bp.Schema)) > 0 {
+				w.stale[blueprintKey(bp.TeamID, bp.ID)] = rollupTarget{bp.TeamID, bp.ID}
+			}
+		}
+		w.mu.Unlock()
+		err = w.flush(ctx)
+	}
+	if err != nil {
+		log.Printf("ERROR: rollup property recompute failed: %v", err)
+	}
+	w.setLastErr(err)
+}
+
+// rollupBatch is a team's queued work
+type rollupBatch struct {
+	changed map[uuid.UUID]string
+	deleted map[string]bool
+	stale   map[string]bool
+}
+
+// flush recomputes the rollups affected by the queued changes. The work of
+// a team that fails is queued again.
+func (w *RollupPropertyUpdater) flush(ctx context.Context) error {
+	w.mu.Lock()
+	batches := make(map[uuid.UUID]*rollupBatch)
+	batch := func(teamID uuid.UUID) *rollupBatch {
+		b, ok := batches[teamID]
+		if !ok {
+			b = &rollupBatch{changed: map[uuid.UUID]string{}, deleted: map[string]bool{}, stale: map[string]bool{}}
+			batches[teamID] = b
+		}
+		return b
+	}
+	for id, target := range w.changed {
+		batch(target.teamID).changed[id] = target.blueprintID
+	}
+	for _, target := range w.deleted {
+		batch(target.teamID).deleted[target.blueprintID] = true
+	}
+	for _, target := range w.stale {
+		batch(target.teamID).stale[target.blueprintID] = true
+	}
+	w.changed = make(map[uuid.UUID]rollupTarget)
+	w.deleted = make(map[string]rollupTarget)
+	w.stale = make(map[string]rollupTarget)
+	w.mu.Unlock()
+
+	var failed error
+	for teamID, b := range batches {
+		if failed == nil {
+			failed = w.flushTeam(ctx, teamID, b)
+			if failed == nil {
+				continue
+			}
+		}
+		w.requeue(teamID, b)
+	}
+	return failed
+}
+
+func (w *RollupPropertyUpdater) requeue(teamID uuid.UUID, b *rollupBatch) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for id, blueprintID := range b.changed {
+		w.changed[id] = rollupTarget{teamID, blueprintID}
+	}
+	for blueprintID := range b.deleted {
+		w.deleted[blueprintKey(teamID, blueprintID)] = rollupTarget{teamID, blueprintID}
+	}
+	for blueprintID := range b.stale {
+		w.stale[blueprintKey(teamID, blueprintID)] = rollupTarget{teamID, blueprintID}
+	}
+}
+
+func (w *RollupPropertyUpdater) flushTeam(ctx context.Context, teamID uuid.UUID, b *rollupBatch) error {
+	repo := w.service.repo
+	blueprints, err := w.blueprints.List(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	if !hasRollups(blueprints) {
+		return nil
+	}
+	relations, err := repo.ListRelations(ctx, teamID)
+	if err != nil {
+		return err
+	}
+	g := newRollupGraph(blueprints, relations)
+
+	// Read from the primary so entities written moments ago are not
+	// recomputed from stale values
+	ctx = postgres.WithPrimary(ctx)
+	parents := make(map[uuid.UUID]bool)
+	for id, blueprintID := range b.changed {
+		if len(g.byParent[blueprintID]) > 0 {
+			parents[id] = true
+		}
+		for _, r := range g.byChild[blueprintID] {
+			ids, err := repo.RollupParents(ctx, r.relationID, r.Inbound(), id)
+			if err != nil {
+				return err
+			}
+			for _, parent := range ids {
+				parents[parent] = true
+			}
+		}
+	}
+	for blueprintID := range b.deleted {
+		for _, r := range g.byChild[blueprintID] {
+			b.stale[r.parent] = true
+		}
+	}
+	for blueprintID := range b.stale {
+		if len(g.byParent[blueprintID]) == 0 {
+			continue
+		}
+		err := repo.ForEach(ctx, teamID, blueprintID, func(e *Entity) error {
+			parents[e.ID] = true
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for id := range parents {
+		if err := w.recompute(ctx, g, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasRollups(blueprints []*blueprint.Blueprint) bool {
+	for _, bp := range blueprints {
+		if len(blueprint.Rollups(bp.Schema)) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// recompute writes an entity's rollup properties when they changed
+func (w *RollupPropertyUpdater) recompute(ctx context.Context, g *rollupGraph, id uuid.UUID) error {
+	repo := w.service.repo
+	e, err := repo.GetByID(ctx, id)
+	if err != nil || e == nil {
+		return err
+	}
+	rollups := g.byParent[e.BlueprintID]
+	if len(rollups) == 0 {
+		return nil
+	}
+	values := make(map[string][]interface{}, len(rollups))
+	for _, r := range rollups {
+		if values[r.Property], err = repo.RollupValues(ctx, r.relationID, r.Inbound(), id, r.From); err != nil {
+			return err
+		}
+	}
+	updated, changed := applyRollups(e, rollups, values, writeSource(ctx))
+	if !changed {
+		return nil
+	}
+	updated.ExpiresAt = g.blueprints[e.BlueprintID].ExpiryPolicy.ExpiresAt(e.CreatedAt, updated.Data)
+	if err := repo.Update(ctx, updated); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			return nil
+		}
+		return err
+	}
+	w.service.publish(ctx, events.EntityUpdated, updated, e)
+	return nil
+}
+
+// applyRollups returns a copy of e with its rollup properties computed from
+// the children's values, and whether any of them changed. A rollup without
+// children in its order is removed.
+func applyRollups(e *Entity, rollups []*resolvedRollup, values map[string][]interface{}, source PropertySource) (*Entity, bool) {
+	updated := *e
+	updated.Data = make(map[string]interface{}, len(e.Data))
+	for k, v := range e.Data {
+		updated.Data[k] = v
+	}
+	updated.Sources = make(map[string]PropertySource, len(e.Sources))
+	for k, v := range e.Sources {
+		updated.Sources[k] = v
+	}
+
+	changed := false
+	for _, r := range rollups {
+		value, ok := r.Compute(values[r.Property])
+		current, has := e.Data[r.Property]
+		switch {
+		case ok && (!has || !reflect.DeepEqual(current, value)):
+			updated.Data[r.Property] = value
+			updated.Sources[r.Property] = source
+			changed = true
+		case !ok && has:
+			delete(updated.Data, r.Property)
+			delete(updated.Sources, r.Property)
+			changed = true
+		}
+	}
+	return &updated, changed
+}
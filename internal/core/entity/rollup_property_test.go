@@ -0,0 +1,101 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+func rollupBlueprint(id string, properties map[string]interface{}) *blueprint.Blueprint {
+	return &blueprint.Blueprint{ID: id, Schema: map[string]interface{}{"type": "object", "properties": properties}}
+}
+
+func TestRollupGraph(t *testing.T) {
+	order := []interface{}{"down", "degraded", "up"}
+	system := rollupBlueprint("system", map[string]interface{}{
+		"health": map[string]interface{}{"type": "string", "rollup": map[string]interface{}{"relation": "system", "blueprint": "service", "property": "health", "order": order}},
+		"deps":   map[string]interface{}{"type": "string", "rollup": map[string]interface{}{"relation": "dependsOn", "property": "health", "order": order}},
+		"broken": map[string]interface{}{"type": "string", "rollup": map[string]interface{}{"relation": "missing", "property": "health", "order": order}},
+	})
+	service := rollupBlueprint("service", map[string]interface{}{"health": map[string]interface{}{"type": "string"}})
+	belongs := &Relation{ID: uuid.New(), Source: "service", Identifier: "system", Target: "system"}
+	depends := &Relation{ID: uuid.New(), Source: "system", Identifier: "dependsOn", Target: "database"}
+
+	g := newRollupGraph([]*blueprint.Blueprint{system, service}, []*Relation{belongs, depends})
+	if got := len(g.byParent["system"]); got != 2 {
+		t.Fatalf("system has %d resolved rollups, want 2", got)
+	}
+	deps, health := g.byParent["system"][0], g.byParent["system"][1]
+	if health.child != "service" || health.relationID != belongs.ID || !health.Inbound() {
+		t.Errorf("health = %+v", health)
+	}
+	if deps.child != "database" || deps.relationID != depends.ID || deps.Inbound() {
+		t.Errorf("deps = %+v", deps)
+	}
+	if len(g.byChild["service"]) != 1 || len(g.byChild["database"]) != 1 || len(g.byParent["service"]) != 0 {
+		t.Errorf("byChild = %+v", g.byChild)
+	}
+}
+
+func TestApplyRollups(t *testing.T) {
+	order := []interface{}{"down", "degraded", "up"}
+	rollups := []*resolvedRollup{
+		{PropertyRollup: &blueprint.PropertyRollup{Property: "health", Order: order}},
+		{PropertyRollup: &blueprint.PropertyRollup{Property: "best", Order: order, Aggregate: blueprint.RollupBest}},
+	}
+	source := PropertySource{Type: SourceSystem}
+	e := &Entity{
+		Data:    map[string]interface{}{"owner": "platform", "health": "up", "best": "up"},
+		Sources: map[string]PropertySource{"health": source, "best": source},
+	}
+
+	updated, changed := applyRollups(e, rollups, map[string][]interface{}{
+		"health": {"up", "down"},
+		"best":   {"up", "down"},
+	}, source)
+	want := map[string]interface{}{"owner": "platform", "health": "down", "best": "up"}
+	if !changed || !reflect.DeepEqual(updated.Data, want) {
+		t.Errorf("applyRollups = %v, %v, want %v", updated.Data, changed, want)
+	}
+	if e.Data["health"] != "up" {
+		t.Error("applyRollups changed the entity it was given")
+	}
+
+	if _, changed := applyRollups(updated, rollups, map[string][]interface{}{"health": {"down"}, "best": {"up"}}, source); changed {
+		t.Error("unchanged rollups reported as changed")
+	}
+
+	cleared, changed := applyRollups(updated, rollups, map[string][]interface{}{"best": {"up"}}, source)
+	if _, has := cleared.Data["health"]; !changed || has {
+		t.Errorf("rollup without children = %v, want it removed", cleared.Data)
+	}
+	if _, has := cleared.Sources["health"]; has {
+		t.Error("source of a removed rollup kept")
+	}
+}
+
+func TestKeepRollups(t *testing.T) {
+	bp := rollupBlueprint("system", map[string]interface{}{
+		"health": map[string]interface{}{"type": "string", "rollup": map[string]interface{}{"relation": "services", "property": "health", "order": []interface{}{"down", "up"}}},
+		"owner":  map[string]interface{}{"type": "string"},
+	})
+	previous := map[string]interface{}{"health": "down", "owner": "a"}
+
+	next := map[string]interface{}{"owner": "b"}
+	if err := keepRollups(bp, previous, next); err != nil || next["health"] != "down" {
+		t.Errorf("omitted rollup: %v, data %v", err, next)
+	}
+	if err := keepRollups(bp, previous, map[string]interface{}{"health": "down"}); err != nil {
+		t.Errorf("unchanged rollup: %v", err)
+	}
+	if err := keepRollups(bp, previous, map[string]interface{}{"health": "up"}); !errors.Is(err, ErrRollupProperty) {
+		t.Errorf("changed rollup: err = %v, want ErrRollupProperty", err)
+	}
+	if err := keepRollups(bp, nil, map[string]interface{}{"health": "up"}); !errors.Is(err, ErrRollupProperty) {
+		t.Errorf("rollup on create: err = %v, want ErrRollupProperty", err)
+	}
+}
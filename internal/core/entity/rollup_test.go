@@ -0,0 +1,102 @@
+package entity
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+)
+
+func rollupSchema() map[string]interface{} {
+	return map[string]interface{}{
+		"properties": map[string]interface{}{
+			"lifecycle": map[string]interface{}{"type": "string", "enum": []interface{}{"production", "experimental"}},
+			"name":      map[string]interface{}{"type": "string"},
+			"tags":      map[string]interface{}{"type": "array", "items": map[string]interface{}{"enum": []interface{}{"a", "b"}}},
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tier": map[string]interface{}{"type": "integer", "enum": []interface{}{1.0, 2.0, 3.0}},
+				},
+			},
+		},
+	}
+}
+
+func rollupScorecard() *scorecard.Scorecard {
+	return &scorecard.Scorecard{
+		Identifier: "production-readiness",
+		Levels:     []scorecard.Level{{Name: "bronze"}, {Name: "gold"}},
+		Rules: []*scorecard.Rule{
+			{LevelName: "bronze", PropertyPath: "lifecycle", Operator: "exists"},
+			{LevelName: "gold", PropertyPath: "metadata.tier", Operator: "eq", Value: 1.0},
+		},
+	}
+}
+
+func TestNewRollupPlan(t *testing.T) {
+	plan := newRollupPlan(rollupSchema(), []*scorecard.Scorecard{rollupScorecard()})
+
+	if want := []string{"lifecycle", "metadata.tier"}; !reflect.DeepEqual(plan.enumPaths, want) {
+		t.Errorf("enumPaths = %v, want %v", plan.enumPaths, want)
+	}
+	want := []string{"enum:lifecycle", "enum:metadata.tier", "scorecard:production-readiness"}
+	if got := plan.dimensions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("dimensions = %v, want %v", got, want)
+	}
+
+	restricted := plan.restrict([]string{"enum:metadata.tier"})
+	if !reflect.DeepEqual(restricted.enumPaths, []string{"metadata.tier"}) || len(restricted.scorecards) != 0 {
+		t.Errorf("restrict kept %v and %d scorecards", restricted.enumPaths, len(restricted.scorecards))
+	}
+}
+
+func TestRollupPlan_Contribute(t *testing.T) {
+	plan := newRollupPlan(rollupSchema(), []*scorecard.Scorecard{rollupScorecard()})
+	counts := make(map[rollupCounter]int64)
+
+	before := map[string]interface{}{"lifecycle": "experimental", "metadata": map[string]interface{}{"tier": 2.0}}
+	after := map[string]interface{}{"lifecycle": "production", "metadata": map[string]interface{}{"tier": 1.0}}
+	plan.contribute(counts, before, -1)
+	plan.contribute(counts, after, 1)
+	plan.contribute(counts, map[string]interface{}{}, 1)
+
+	want := map[rollupCounter]int64{
+		{RollupCount, "", ""}:                                    1,
+		{RollupEnum, "lifecycle", "experimental"}:                -1,
+		{RollupEnum, "lifecycle", "production"}:                  1,
+		{RollupEnum, "lifecycle", ""}:                            1,
+		{RollupEnum, "metadata.tier", "2"}:                       -1,
+		{RollupEnum, "metadata.tier", "1"}:                       1,
+		{RollupEnum, "metadata.tier", ""}:                        1,
+		{RollupScorecard, "production-readiness", "bronze"}:      -1,
+		{RollupScorecard, "production-readiness", "gold"}:        1,
+		{RollupScorecard, "production-readiness", ""}:            1,
+		{RollupScorecardRules, "production-readiness", "passed"}: 1,
+		{RollupScorecardRules, "production-readiness", "total"}:  2,
+	}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts = %v\nwant %v", counts, want)
+	}
+}
+
+func TestRollupValue(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		exists bool
+		want   string
+	}{
+		{"production", true, "production"},
+		{1.0, true, "1"},
+		{2.5, true, "2.5"},
+		{true, true, "true"},
+		{nil, true, ""},
+		{nil, false, ""},
+		{[]interface{}{"a"}, true, `["a"]`},
+	}
+	for _, tt := range tests {
+		if got := rollupValue(tt.value, tt.exists); got != tt.want {
+			t.Errorf("rollupValue(%v, %v) = %q, want %q", tt.value, tt.exists, got, tt.want)
+		}
+	}
+}
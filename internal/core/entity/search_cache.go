@@ -0,0 +1,164 @@
+package entity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+// SearchCache keeps search and aggregate results for a few seconds, so
+// dashboard widgets that re-run the same query do not hit the database each
+// time. Entries are keyed by team, blueprint and a hash of the normalized
+// request, and a blueprint's entries are dropped whenever one of its entities
+// or its schema changes. A nil *SearchCache caches nothing.
+//
+// Cached values are shared between callers and must not be modified.
+type SearchCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu          sync.Mutex
+	entries     map[string]map[string]cacheEntry // blueprint key -> request hash -> entry
+	generations map[string]uint64                // blueprint key -> invalidation count
+	size        int
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+func NewSearchCache(ttl time.Duration, maxEntries int) *SearchCache {
+	return &SearchCache{
+		ttl:         ttl,
+		maxEntries:  maxEntries,
+		now:         time.Now,
+		entries:     make(map[string]map[string]cacheEntry),
+		generations: make(map[string]uint64),
+	}
+}
+
+// Subscribe invalidates cached results on entity and blueprint events
+func (c *SearchCache) Subscribe(bus *events.Bus) {
+	if c == nil || bus == nil {
+		return
+	}
+	invalidate := func(ctx context.Context, e events.Event) {
+		c.Invalidate(e.TeamID, e.BlueprintID)
+	}
+	bus.Subscribe("entity.*", invalidate)
+	bus.Subscribe("blueprint.*", invalidate)
+}
+
+// Len returns the number of cached results, including expired ones not yet pruned
+func (c *SearchCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// Invalidate drops every cached result for a blueprint
+func (c *SearchCache) Invalidate(teamID uuid.UUID, blueprintID string) {
+	if c == nil {
+		return
+	}
+	key := blueprintKey(teamID, blueprintID)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.size -= len(c.entries[key])
+	delete(c.entries, key)
+	c.generations[key]++
+}
+
+// cacheLookup is the result of a lookup; on a miss it remembers the
+// blueprint's generation so a result computed across an invalidation is not stored
+type cacheLookup struct {
+	key        string
+	hash       string
+	generation uint64
+}
+
+// get returns a cached result for the request. kind separates requests of
+// different types that could marshal identically.
+func (c *SearchCache) get(teamID uuid.UUID, blueprintID, kind string, req interface{}) (interface{}, *cacheLookup, bool) {
+	if c == nil {
+		return nil, nil, false
+	}
+	encoded, err := json.Marshal(req)
+	if err != nil {
+		return nil, nil, false
+	}
+	sum := sha256.Sum256(append([]byte(kind+"\x00"), encoded...))
+	lookup := &cacheLookup{key: blueprintKey(teamID, blueprintID), hash: hex.EncodeToString(sum[:])}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lookup.generation = c.generations[lookup.key]
+	entry, ok := c.entries[lookup.key][lookup.hash]
+	if !ok || !c.now().Before(entry.expires) {
+		return nil, lookup, false
+	}
+	return entry.value, lookup, true
+}
+
+// put stores a result computed after a missed lookup. When the cache is full
+// and no entry has expired, the result is not stored.
+func (c *SearchCache) put(lookup *cacheLookup, value interface{}) {
+	if c == nil || lookup == nil {
+		return
+	}
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.generations[lookup.key] != lookup.generation {
+		// The blueprint changed while the query ran
+		return
+	}
+	if _, ok := c.entries[lookup.key][lookup.hash]; !ok && c.size >= c.maxEntries {
+		c.pruneLocked(now)
+		if c.size >= c.maxEntries {
+			return
+		}
+	}
+
+	bucket := c.entries[lookup.key]
+	if bucket == nil {
+		bucket = make(map[string]cacheEntry)
+		c.entries[lookup.key] = bucket
+	}
+	if _, ok := bucket[lookup.hash]; !ok {
+		c.size++
+	}
+	bucket[lookup.hash] = cacheEntry{value: value, expires: now.Add(c.ttl)}
+}
+
+func (c *SearchCache) pruneLocked(now time.Time) {
+	for key, bucket := range c.entries {
+		for hash, entry := range bucket {
+			if !now.Before(entry.expires) {
+				delete(bucket, hash)
+				c.size--
+			}
+		}
+		if len(bucket) == 0 {
+			delete(c.entries, key)
+		}
+	}
+}
+
+func blueprintKey(teamID uuid.UUID, blueprintID string) string {
+	return teamID.String() + "/" + blueprintID
+}
@@ -0,0 +1,113 @@
+package entity
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+func TestSearchCache_HitAndExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewSearchCache(5*time.Second, 10)
+	c.now = func() time.Time { return now }
+	team := uuid.New()
+	req := &SearchRequest{Filters: []SearchFilter{{Property: "lifecycle", Operator: "eq", Value: "production"}}, Limit: 50}
+
+	if _, lookup, ok := c.get(team, "service", "search", req); ok {
+		t.Fatal("empty cache returned a hit")
+	} else {
+		c.put(lookup, "result")
+	}
+
+	same := &SearchRequest{Filters: []SearchFilter{{Property: "lifecycle", Operator: "eq", Value: "production"}}, Limit: 50}
+	if v, _, ok := c.get(team, "service", "search", same); !ok || v != "result" {
+		t.Errorf("identical request: got %v, %v; want hit", v, ok)
+	}
+	if _, _, ok := c.get(team, "service", "aggregate", same); ok {
+		t.Error("different kind should miss")
+	}
+	if _, _, ok := c.get(uuid.New(), "service", "search", same); ok {
+		t.Error("different team should miss")
+	}
+	other := &SearchRequest{Limit: 10}
+	if _, _, ok := c.get(team, "service", "search", other); ok {
+		t.Error("different request should miss")
+	}
+
+	now = now.Add(5 * time.Second)
+	if _, _, ok := c.get(team, "service", "search", req); ok {
+		t.Error("expired entry returned a hit")
+	}
+}
+
+func TestSearchCache_EventInvalidation(t *testing.T) {
+	c := NewSearchCache(time.Minute, 10)
+	bus := events.NewBus()
+	c.Subscribe(bus)
+	team := uuid.New()
+
+	_, lookup, _ := c.get(team, "service", "search", &SearchRequest{})
+	c.put(lookup, "service result")
+	_, lookup, _ = c.get(team, "team", "search", &SearchRequest{})
+	c.put(lookup, "team result")
+
+	bus.Publish(context.Background(), events.Event{Type: events.EntityUpdated, TeamID: team, BlueprintID: "service"})
+
+	if _, _, ok := c.get(team, "service", "search", &SearchRequest{}); ok {
+		t.Error("entry for the changed blueprint survived invalidation")
+	}
+	if _, _, ok := c.get(team, "team", "search", &SearchRequest{}); !ok {
+		t.Error("entry for another blueprint was invalidated")
+	}
+}
+
+func TestSearchCache_StaleResultNotStored(t *testing.T) {
+	c := NewSearchCache(time.Minute, 10)
+	team := uuid.New()
+
+	_, lookup, _ := c.get(team, "service", "search", &SearchRequest{})
+	c.Invalidate(team, "service") // a write lands while the query runs
+	c.put(lookup, "stale")
+
+	if _, _, ok := c.get(team, "service", "search", &SearchRequest{}); ok {
+		t.Error("result computed before an invalidation was cached")
+	}
+}
+
+func TestSearchCache_MaxEntries(t *testing.T) {
+	now := time.Unix(0, 0)
+	c := NewSearchCache(time.Second, 2)
+	c.now = func() time.Time { return now }
+	team := uuid.New()
+
+	for _, limit := range []int{1, 2, 3} {
+		_, lookup, _ := c.get(team, "service", "search", &SearchRequest{Limit: limit})
+		c.put(lookup, limit)
+	}
+	if _, _, ok := c.get(team, "service", "search", &SearchRequest{Limit: 3}); ok {
+		t.Error("full cache stored a new entry")
+	}
+
+	now = now.Add(time.Second)
+	_, lookup, _ := c.get(team, "service", "search", &SearchRequest{Limit: 3})
+	c.put(lookup, 3)
+	if _, _, ok := c.get(team, "service", "search", &SearchRequest{Limit: 3}); !ok {
+		t.Error("entry not stored after expired entries were pruned")
+	}
+	if c.size != 1 {
+		t.Errorf("size = %d, want 1", c.size)
+	}
+}
+
+func TestSearchCache_Nil(t *testing.T) {
+	var c *SearchCache
+	if _, lookup, ok := c.get(uuid.New(), "service", "search", &SearchRequest{}); ok || lookup != nil {
+		t.Error("nil cache returned a lookup")
+	}
+	c.put(nil, "x")
+	c.Invalidate(uuid.New(), "service")
+}
@@ -0,0 +1,237 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/ratelimit"
+)
+
+var ErrSearchThrottled = errors.New("search throttled")
+
+// ThrottledError reports why an expensive search was rejected and when to retry
+type ThrottledError struct {
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return "search throttled: " + e.Reason
+}
+
+func (e *ThrottledError) Unwrap() error {
+	return ErrSearchThrottled
+}
+
+// entityCountTTL is how long blueprint sizes are cached when classifying searches
+const entityCountTTL = time.Minute
+
+// SearchGuard classifies searches by cost and applies per-team rate and
+// concurrency limits to expensive ones. Cheap searches are never limited.
+// A nil *SearchGuard applies no limits.
+type SearchGuard struct {
+	repo   *Repository
+	limits atomic.Pointer[guardLimits]
+
+	mu     sync.Mutex
+	counts map[string]cachedCount
+}
+
+// guardLimits are the limits in effect; UpdateLimits swaps them as a whole
+type guardLimits struct {
+	cfg         config.SearchConfig
+	rate        *ratelimit.Limiter
+	concurrency *ratelimit.Concurrency
+}
+
+type cachedCount struct {
+	count   int
+	expires time.Time
+}
+
+func NewSearchGuard(repo *Repository, cfg config.SearchConfig) *SearchGuard {
+	g := &SearchGuard{
+		repo:   repo,
+		counts: make(map[string]cachedCount),
+	}
+	g.UpdateLimits(cfg)
+	return g
+}
+
+// UpdateLimits applies new search limits. Rate and concurrency state carry
+// over when their limit is unchanged; a changed limit starts from empty
+// buckets, and searches admitted under the old limit release against it.
+func (g *SearchGuard) UpdateLimits(cfg config.SearchConfig) {
+	if g == nil {
+		return
+	}
+	limits := &guardLimits{cfg: cfg}
+	old := g.limits.Load()
+	if cfg.ExpensivePerMinute > 0 {
+		if old != nil && old.cfg.ExpensivePerMinute == cfg.ExpensivePerMinute {
+			limits.rate = old.rate
+		} else {
+			// Allow a short burst so a dashboard loading several panels at once is not throttled
+			limits.rate = ratelimit.NewLimiter(cfg.ExpensivePerMinute, max(1, cfg.ExpensivePerMinute/6))
+		}
+	}
+	if cfg.ExpensiveConcurrency > 0 {
+		if old != nil && old.cfg.ExpensiveConcurrency == cfg.ExpensiveConcurrency {
+			limits.concurrency = old.concurrency
+		} else {
+			limits.concurrency = ratelimit.NewConcurrency(cfg.ExpensiveConcurrency)
+		}
+	}
+	g.limits.Store(limits)
+}
+
+// Search checks a search request and reserves capacity for it. The returned
+// release function must be called once the query has finished.
+func (g *SearchGuard) Search(ctx context.Context, teamID uuid.UUID, blueprintID string, fc *FilterCompiler, req *SearchRequest) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	limits := g.limits.Load()
+	if req.Offset > limits.cfg.MaxOffset {
+		return nil, fmt.Errorf("%w: offset may not exceed %d; narrow the filters instead of paging further", ErrInvalidFilter, limits.cfg.MaxOffset)
+	}
+
+	reasons := expensiveReasons(fc, req)
+	if len(reasons) == 0 {
+		return func() {}, nil
+	}
+	large, err := g.isLarge(ctx, limits, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	if !large {
+		return func() {}, nil
+	}
+	return limits.acquire(teamID, strings.Join(reasons, ", "))
+}
+
+// Aggregate reserves capacity for an aggregate, which scans every matching entity
+func (g *SearchGuard) Aggregate(ctx context.Context, teamID uuid.UUID, blueprintID string) (func(), error) {
+	if g == nil {
+		return func() {}, nil
+	}
+	limits := g.limits.Load()
+	large, err := g.isLarge(ctx, limits, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	if !large {
+		return func() {}, nil
+	}
+	return limits.acquire(teamID, "aggregate over a large blueprint")
+}
+
+func (l *guardLimits) acquire(teamID uuid.UUID, reason string) (func(), error) {
+	key := teamID.String()
+
+	release := func() {}
+	if l.concurrency != nil {
+		r, ok := l.concurrency.TryAcquire(key)
+		if !ok {
+			return nil, &ThrottledError{
+				Reason:     fmt.Sprintf("too many concurrent expensive searches (%s)", reason),
+				RetryAfter: time.Second,
+			}
+		}
+		release = r
+	}
+
+	if l.rate != nil {
+		if ok, wait := l.rate.Allow(key); !ok {
+			release()
+			return nil, &ThrottledError{
+				Reason:     fmt.Sprintf("expensive search rate limit exceeded (%s)", reason),
+				RetryAfter: wait,
+			}
+		}
+	}
+	return release, nil
+}
+
+// isLarge reports whether the blueprint holds more entities than the configured
+// threshold, using a short-lived cached count
+func (g *SearchGuard) isLarge(ctx context.Context, limits *guardLimits, teamID uuid.UUID, blueprintID string) (bool, error) {
+	threshold := limits.cfg.LargeBlueprintEntities
+	if threshold == 0 {
+		return true, nil
+	}
+
+	key := teamID.String() + "/" + blueprintID
+	now := time.Now()
+
+	g.mu.Lock()
+	cached, ok := g.counts[key]
+	g.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.count > threshold, nil
+	}
+
+	count, err := g.repo.Count(ctx, teamID, blueprintID)
+	if err != nil {
+		return false, err
+	}
+
+	g.mu.Lock()
+	for k, c := range g.counts {
+		if now.After(c.expires) {
+			delete(g.counts, k)
+		}
+	}
+	g.counts[key] = cachedCount{count: count, expires: now.Add(entityCountTTL)}
+	g.mu.Unlock()
+
+	return count > threshold, nil
+}
+
+// expensiveReasons lists the parts of a search that cannot use an index and
+// therefore scan the whole blueprint. An empty result means the search is cheap.
+func expensiveReasons(fc *FilterCompiler, req *SearchRequest) []string {
+	reasons := filterReasons(fc, req.Filters)
+	if req.OrderBy != "" && !sortColumns[req.OrderBy] {
+		reasons = append(reasons, "ordering by "+req.OrderBy)
+	}
+	return reasons
+}
+
+func filterReasons(fc *FilterCompiler, filters []SearchFilter) []string {
+	var reasons []string
+	for _, f := range filters {
+		if f.IsGroup() {
+			if f.Combinator == "not" {
+				reasons = append(reasons, "not group")
+			}
+			reasons = append(reasons, filterReasons(fc, f.Rules)...)
+			continue
+		}
+		switch f.Operator {
+		case "contains":
+			if prop, _ := fc.Property(f.Property); schemaType(prop) != "array" {
+				reasons = append(reasons, "substring match on "+f.Property)
+			}
+		case "neq", "not_in":
+			reasons = append(reasons, f.Operator+" on "+f.Property)
+		case "exists":
+			if f.Value == false {
+				reasons = append(reasons, "exists=false on "+f.Property)
+			}
+		case "is_null":
+			if f.Value == true {
+				reasons = append(reasons, "is_null=true on "+f.Property)
+			}
+		}
+	}
+	return reasons
+}
@@ -0,0 +1,137 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+func TestExpensiveReasons(t *testing.T) {
+	fc := NewFilterCompiler(filterSchema())
+
+	tests := []struct {
+		name string
+		req  SearchRequest
+		want int
+	}{
+		{"eq is cheap", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "eq", Value: "active"}}}, 0},
+		{"array contains is cheap", SearchRequest{Filters: []SearchFilter{{Property: "tags", Operator: "contains", Value: "api"}}}, 0},
+		{"column order is cheap", SearchRequest{OrderBy: "identifier"}, 0},
+		{"substring contains", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "contains", Value: "act"}}}, 1},
+		{"neq", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "neq", Value: "x"}}}, 1},
+		{"exists false", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "exists", Value: false}}}, 1},
+		{"not_in", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "not_in", Value: []interface{}{"x"}}}}, 1},
+		{"is_null true", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "is_null", Value: true}}}, 1},
+		{"is_null false is cheap", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "is_null", Value: false}}}, 0},
+		{"neq in group", SearchRequest{Filters: []SearchFilter{{Combinator: "or", Rules: []SearchFilter{{Property: "status", Operator: "neq", Value: "x"}}}}}, 1},
+		{"not group", SearchRequest{Filters: []SearchFilter{{Combinator: "not", Rules: []SearchFilter{{Property: "status", Operator: "eq", Value: "x"}}}}}, 1},
+		{"property order", SearchRequest{OrderBy: "replicas"}, 1},
+		{"combined", SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "neq", Value: "x"}}, OrderBy: "replicas"}, 2},
+	}
+
+	for _, tt := range tests {
+		if got := expensiveReasons(fc, &tt.req); len(got) != tt.want {
+			t.Errorf("%s: reasons = %v, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+// LargeBlueprintEntities = 0 treats every blueprint as large, so no count query is needed
+func testGuard(perMinute, concurrency int) *SearchGuard {
+	return NewSearchGuard(nil, config.SearchConfig{
+		ExpensivePerMinute:   perMinute,
+		ExpensiveConcurrency: concurrency,
+		MaxOffset:            100,
+	})
+}
+
+func TestSearchGuard_Concurrency(t *testing.T) {
+	g := testGuard(0, 1)
+	fc := NewFilterCompiler(filterSchema())
+	req := &SearchRequest{Filters: []SearchFilter{{Property: "status", Operator: "contains", Value: "a"}}}
+	team := uuid.New()
+
+	release, err := g.Search(context.Background(), team, "service", fc, req)
+	if err != nil {
+		t.Fatalf("first expensive search should pass: %v", err)
+	}
+
+	_, err = g.Search(context.Background(), team, "service", fc, req)
+	var throttled *ThrottledError
+	if !errors.As(err, &throttled) || !errors.Is(err, ErrSearchThrottled) || throttled.RetryAfter <= 0 {
+		t.Fatalf("expected ThrottledError with retry, got %v", err)
+	}
+
+	if _, err := g.Search(context.Background(), team, "service", fc, &SearchRequest{}); err != nil {
+		t.Errorf("cheap searches must not be limited: %v", err)
+	}
+	if _, err := g.Search(context.Background(), uuid.New(), "service", fc, req); err != nil {
+		t.Errorf("other teams must not be limited: %v", err)
+	}
+
+	release()
+	if _, err := g.Search(context.Background(), team, "service", fc, req); err != nil {
+		t.Errorf("search should pass after release: %v", err)
+	}
+}
+
+func TestSearchGuard_Rate(t *testing.T) {
+	g := testGuard(6, 0) // burst of 1
+	team := uuid.New()
+
+	release, err := g.Aggregate(context.Background(), team, "service")
+	if err != nil {
+		t.Fatalf("first aggregate should pass: %v", err)
+	}
+	release()
+
+	if _, err := g.Aggregate(context.Background(), team, "service"); !errors.Is(err, ErrSearchThrottled) {
+		t.Errorf("expected rate limit, got %v", err)
+	}
+}
+
+func TestSearchGuard_MaxOffset(t *testing.T) {
+	g := testGuard(0, 0)
+	_, err := g.Search(context.Background(), uuid.New(), "service", NewFilterCompiler(nil), &SearchRequest{Offset: 101})
+	if !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("expected ErrInvalidFilter for deep offset, got %v", err)
+	}
+}
+
+func TestSearchGuard_UpdateLimits(t *testing.T) {
+	g := testGuard(6, 0) // burst of 1
+	team := uuid.New()
+
+	release, err := g.Aggregate(context.Background(), team, "service")
+	if err != nil {
+		t.Fatalf("first aggregate should pass: %v", err)
+	}
+	release()
+
+	// An unchanged rate keeps its buckets
+	g.UpdateLimits(config.SearchConfig{ExpensivePerMinute: 6, MaxOffset: 1000})
+	if _, err := g.Aggregate(context.Background(), team, "service"); !errors.Is(err, ErrSearchThrottled) {
+		t.Errorf("expected rate limit to carry over, got %v", err)
+	}
+	if _, err := g.Search(context.Background(), team, "service", NewFilterCompiler(nil), &SearchRequest{Offset: 500}); err != nil {
+		t.Errorf("expected the new max offset to apply: %v", err)
+	}
+
+	g.UpdateLimits(config.SearchConfig{MaxOffset: 1000})
+	if _, err := g.Aggregate(context.Background(), team, "service"); err != nil {
+		t.Errorf("expected no rate limit after it was disabled: %v", err)
+	}
+}
+
+func TestSearchGuard_NilAppliesNoLimits(t *testing.T) {
+	var g *SearchGuard
+	release, err := g.Search(context.Background(), uuid.New(), "service", NewFilterCompiler(nil), &SearchRequest{Offset: 1 << 20})
+	if err != nil {
+		t.Fatalf("nil guard returned error: %v", err)
+	}
+	release()
+}
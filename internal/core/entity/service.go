@@ -3,35 +3,141 @@ package entity
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/baseplate/baseplate/internal/core/blueprint"
 	"github.com/baseplate/baseplate/internal/core/validation"
+	"github.com/baseplate/baseplate/internal/events"
 )
 
 var (
-	ErrNotFound       = errors.New("entity not found")
-	ErrAlreadyExists  = errors.New("entity already exists")
-	ErrValidation     = errors.New("validation failed")
+	ErrNotFound          = errors.New("entity not found")
+	ErrAlreadyExists     = errors.New("entity already exists")
+	ErrValidation        = errors.New("validation failed")
 	ErrBlueprintNotFound = errors.New("blueprint not found")
+	ErrInvalidAggregate  = errors.New("invalid aggregate")
+	ErrInvalidImport     = errors.New("invalid import")
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	ErrVersionConflict   = errors.New("entity was modified")
+	ErrUsageDisabled     = errors.New("property usage tracking is disabled")
+
+	// ErrIdentifierImmutable rejects renames on blueprints whose identifiers
+	// are immutable, ErrIdentifierChange identifier changes outside a rename
+	ErrIdentifierImmutable = errors.New("entity identifiers of this blueprint are immutable")
+	ErrIdentifierChange    = errors.New("identifier can only be changed through the rename endpoint")
 )
 
+// updateAttempts bounds how often an unconditional update is retried when
+// another writer changes the entity between read and write
+const updateAttempts = 3
+
+// VersionConflictError reports that an entity is no longer at the version an
+// update was based on. Current is the entity as it is now.
+type VersionConflictError struct {
+	Current *Entity
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("entity was modified: current version is %d", e.Current.Version)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return ErrVersionConflict
+}
+
 type Service struct {
-	repo            *Repository
-	blueprintSvc    *blueprint.Service
-	validator       *validation.Validator
+	repo         *Repository
+	blueprintSvc *blueprint.Service
+	validator    *validation.Validator
+	searchGuard  *SearchGuard
+	searchCache  *SearchCache
+	rollups      *RollupMaintainer
+	usage        *UsageRecorder
+	bus          *events.Bus
 }
 
-func NewService(repo *Repository, blueprintSvc *blueprint.Service, validator *validation.Validator) *Service {
+// NewService creates the entity service. searchGuard, searchCache, rollups,
+// usage and bus may be nil; changes are published on bus.
+func NewService(repo *Repository, blueprintSvc *blueprint.Service, validator *validation.Validator, searchGuard *SearchGuard, searchCache *SearchCache, rollups *RollupMaintainer, usage *UsageRecorder, bus *events.Bus) *Service {
 	return &Service{
 		repo:         repo,
 		blueprintSvc: blueprintSvc,
 		validator:    validator,
+		searchGuard:  searchGuard,
+		searchCache:  searchCache,
+		rollups:      rollups,
+		usage:        usage,
+		bus:          bus,
 	}
 }
 
 func (s *Service) Create(ctx context.Context, teamID uuid.UUID, blueprintID string, req *CreateEntityRequest) (*Entity, error) {
+	entity, err := s.prepareCreate(ctx, teamID, blueprintID, req, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, entity); err != nil {
+		return nil, err
+	}
+	s.publish(ctx, events.EntityCreated, entity, nil)
+	return entity, nil
+}
+
+// ValidateCreate checks a create without writing anything: the result holds
+// the entity Create would store, or the validation errors. Sequence numbers
+// are shown without being taken. Other errors are returned as by Create.
+func (s *Service) ValidateCreate(ctx context.Context, teamID uuid.UUID, blueprintID string, req *CreateEntityRequest) (*ValidationResult, error) {
+	entity, err := s.prepareCreate(ctx, teamID, blueprintID, req, true)
+	if err != nil {
+		return validationResult(nil, nil, err, nil)
+	}
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	return validationResult(nil, entity, nil, hiddenProperties(ctx, bp))
+}
+
+// validationResult turns the outcome of a write checked without writing into
+// a ValidationResult with the changes from previous, which is nil for a
+// create; errors other than validation errors are returned
+func validationResult(previous, entity *Entity, err error, hidden []string) (*ValidationResult, error) {
+	if ve := validation.GetValidationErrors(err); ve != nil {
+		return &ValidationResult{Errors: ve.Errors, Changes: []Change{}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ValidationResult{Valid: true, Errors: []validation.ValidationError{}, Entity: entity, Changes: Diff(previous, entity, hidden)}, nil
+}
+
+// validateModify checks a change of an entity as modify would apply it,
+// without writing anything
+func (s *Service) validateModify(ctx context.Context, id uuid.UUID, ifVersion int64, change func(entity *Entity, bp *blueprint.Blueprint) error) (*ValidationResult, error) {
+	var previous *Entity
+	var hidden []string
+	entity, err := s.modify(ctx, id, ifVersion, true, func(entity *Entity, bp *blueprint.Blueprint) error {
+		copied := *entity
+		copied.Data = make(map[string]interface{}, len(entity.Data))
+		for k, v := range entity.Data {
+			copied.Data[k] = v
+		}
+		previous, hidden = &copied, hiddenProperties(ctx, bp)
+		return change(entity, bp)
+	})
+	return validationResult(previous, entity, err, hidden)
+}
+
+// prepareCreate returns the entity a create stores, filling in defaults
+// without taking sequence numbers when preview is set
+func (s *Service) prepareCreate(ctx context.Context, teamID uuid.UUID, blueprintID string, req *CreateEntityRequest, preview bool) (*Entity, error) {
 	// Get blueprint schema
 	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
 	if err != nil {
@@ -41,6 +147,19 @@ func (s *Service) Create(ctx context.Context, teamID uuid.UUID, blueprintID stri
 		return nil, err
 	}
 
+	if err := keepHidden(hiddenProperties(ctx, bp), nil, req.Data); err != nil {
+		return nil, err
+	}
+	if err := keepRollups(bp, nil, req.Data); err != nil {
+		return nil, err
+	}
+	if req.Data == nil {
+		req.Data = map[string]interface{}{}
+	}
+	if err := s.fillDefaults(ctx, bp, req.Data, preview); err != nil {
+		return nil, err
+	}
+
 	// Validate data against schema
 	if err := s.validator.Validate(req.Data, bp.Schema); err != nil {
 		return nil, err
@@ -62,12 +181,9 @@ func (s *Service) Create(ctx context.Context, teamID uuid.UUID, blueprintID stri
 		Identifier:  req.Identifier,
 		Title:       req.Title,
 		Data:        req.Data,
+		Sources:     initialSources(writeSource(ctx), req.Data),
 	}
-
-	if err := s.repo.Create(ctx, entity); err != nil {
-		return nil, err
-	}
-
+	entity.ExpiresAt = bp.ExpiryPolicy.ExpiresAt(time.Now(), entity.Data)
 	return entity, nil
 }
 
@@ -82,23 +198,44 @@ func (s *Service) Get(ctx context.Context, id uuid.UUID) (*Entity, error) {
 	return entity, nil
 }
 
+// GetByIdentifier returns the entity of a blueprint with an identifier, or
+// the entity that was renamed from it
 func (s *Service) GetByIdentifier(ctx context.Context, teamID uuid.UUID, blueprintID, identifier string) (*Entity, error) {
 	entity, err := s.repo.GetByIdentifier(ctx, teamID, blueprintID, identifier)
 	if err != nil {
 		return nil, err
 	}
+	if entity == nil {
+		entity, err = s.repo.GetByAlias(ctx, teamID, blueprintID, identifier)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if entity == nil {
 		return nil, ErrNotFound
 	}
 	return entity, nil
 }
 
-func (s *Service) List(ctx context.Context, teamID uuid.UUID, blueprintID string, limit, offset int) (*ListEntitiesResponse, error) {
+// List returns a page of a blueprint's entities, newest first. fields is a
+// sparse fieldset (see Fields); empty returns every field.
+func (s *Service) List(ctx context.Context, teamID uuid.UUID, blueprintID string, limit, offset int, fields string) (*ListEntitiesResponse, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 50
 	}
 
-	entities, total, err := s.repo.List(ctx, teamID, blueprintID, limit, offset)
+	var fieldset *Fields
+	if fields != "" {
+		fc, err := s.filterCompiler(ctx, teamID, blueprintID)
+		if err != nil {
+			return nil, err
+		}
+		if fieldset, err = fc.Fields(fields); err != nil {
+			return nil, err
+		}
+	}
+
+	entities, total, err := s.repo.List(ctx, teamID, blueprintID, limit, offset, fieldset)
 	if err != nil {
 		return nil, err
 	}
@@ -120,61 +257,858 @@ func (s *Service) Search(ctx context.Context, teamID uuid.UUID, blueprintID stri
 		req.Limit = 50
 	}
 
-	entities, total, err := s.repo.Search(ctx, teamID, blueprintID, req)
+	cached, lookup, ok := s.searchCache.get(teamID, blueprintID, searchKind(ctx, "search"), req)
+	if ok {
+		s.usage.RecordSearch(teamID, blueprintID, req.Filters, req.OrderBy)
+		return cached.(*ListEntitiesResponse), nil
+	}
+
+	fc, err := s.filterCompiler(ctx, teamID, blueprintID)
 	if err != nil {
 		return nil, err
 	}
+	return s.search(ctx, teamID, blueprintID, fc, req, lookup)
+}
+
+// search runs a search whose cache lookup missed
+func (s *Service) search(ctx context.Context, teamID uuid.UUID, blueprintID string, fc *FilterCompiler, req *SearchRequest, lookup *cacheLookup) (*ListEntitiesResponse, error) {
+	release, err := s.searchGuard.Search(ctx, teamID, blueprintID, fc, req)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	entities, total, err := s.repo.Search(ctx, teamID, blueprintID, fc, req)
+	if err != nil {
+		return nil, err
+	}
+	s.usage.RecordSearch(teamID, blueprintID, req.Filters, req.OrderBy)
 
 	if entities == nil {
 		entities = []*Entity{}
 	}
 
-	return &ListEntitiesResponse{
+	resp := &ListEntitiesResponse{
 		Entities: entities,
 		Total:    total,
 		Limit:    req.Limit,
 		Offset:   req.Offset,
+	}
+	s.searchCache.put(lookup, resp)
+	return resp, nil
+}
+
+// SearchAll runs the same search over every blueprint of a team, or the
+// requested subset, and groups the matches per blueprint. Blueprints without
+// matches are omitted.
+func (s *Service) SearchAll(ctx context.Context, teamID uuid.UUID, req *CrossSearchRequest) (*CrossSearchResponse, error) {
+	if req.Limit <= 0 || req.Limit > 50 {
+		req.Limit = 10
+	}
+	if req.OrderBy != "" && !sortColumns[req.OrderBy] {
+		return nil, fmt.Errorf("%w: cross-blueprint search can only order by created_at, updated_at, identifier or title", ErrInvalidFilter)
+	}
+	if len(filterLeaves(req.Filters)) > maxFilters {
+		return nil, fmt.Errorf("%w: at most %d filters are allowed", ErrInvalidFilter, maxFilters)
+	}
+
+	list, err := s.blueprintSvc.List(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range req.Blueprints {
+		if !slices.ContainsFunc(list.Blueprints, func(bp *blueprint.Blueprint) bool { return bp.ID == id }) {
+			return nil, fmt.Errorf("%w: %s", ErrBlueprintNotFound, id)
+		}
+	}
+
+	resp := &CrossSearchResponse{Results: []BlueprintResults{}, Limit: req.Limit}
+	for _, bp := range list.Blueprints {
+		if len(req.Blueprints) > 0 && !slices.Contains(req.Blueprints, bp.ID) {
+			continue
+		}
+
+		fc := NewFilterCompiler(bp.Schema)
+		fc.hidden = hiddenProperties(ctx, bp)
+		applies, err := filtersApply(fc, req.Filters)
+		if err != nil {
+			return nil, err
+		}
+		if !applies {
+			continue
+		}
+
+		sub := &SearchRequest{Filters: req.Filters, OrderBy: req.OrderBy, OrderDir: req.OrderDir, Limit: req.Limit}
+		result, err := s.cachedSearch(ctx, teamID, bp.ID, fc, sub)
+		if err != nil {
+			return nil, fmt.Errorf("blueprint %s: %w", bp.ID, err)
+		}
+		if result.Total == 0 {
+			continue
+		}
+		resp.Results = append(resp.Results, BlueprintResults{
+			BlueprintID:    bp.ID,
+			BlueprintTitle: bp.Title,
+			Entities:       result.Entities,
+			Total:          result.Total,
+		})
+		resp.Total += result.Total
+	}
+
+	return resp, nil
+}
+
+func (s *Service) cachedSearch(ctx context.Context, teamID uuid.UUID, blueprintID string, fc *FilterCompiler, req *SearchRequest) (*ListEntitiesResponse, error) {
+	cached, lookup, ok := s.searchCache.get(teamID, blueprintID, searchKind(ctx, "search"), req)
+	if ok {
+		s.usage.RecordSearch(teamID, blueprintID, req.Filters, req.OrderBy)
+		return cached.(*ListEntitiesResponse), nil
+	}
+	return s.search(ctx, teamID, blueprintID, fc, req, lookup)
+}
+
+// filtersApply reports whether every filtered property, in groups too, exists
+// in the compiler's schema. Malformed filters are still errors.
+func filtersApply(fc *FilterCompiler, filters []SearchFilter) (bool, error) {
+	for _, f := range filterLeaves(filters) {
+		if _, err := fc.Property(f.Property); err != nil {
+			if errors.Is(err, errUnknownProperty) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Aggregate computes an aggregate over a blueprint's entities
+func (s *Service) Aggregate(ctx context.Context, teamID uuid.UUID, blueprintID string, req *AggregateRequest) ([]AggregateBucket, error) {
+	if req.Function == "" {
+		req.Function = AggregateCount
+	}
+
+	cached, lookup, ok := s.searchCache.get(teamID, blueprintID, searchKind(ctx, "aggregate"), req)
+	if ok {
+		s.recordAggregate(teamID, blueprintID, req)
+		return cached.([]AggregateBucket), nil
+	}
+
+	fc, err := s.filterCompiler(ctx, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Function {
+	case AggregateCount:
+	case AggregateSum, AggregateAvg, AggregateMin, AggregateMax:
+		if req.Property == "" {
+			return nil, fmt.Errorf("%w: %s requires a property", ErrInvalidAggregate, req.Function)
+		}
+		if _, err := fc.Property(req.Property); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAggregate, err)
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidAggregate, req.Function)
+	}
+	if req.GroupBy != "" {
+		if _, err := fc.Property(req.GroupBy); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAggregate, err)
+		}
+	}
+	for _, f := range filterLeaves(req.Filters) {
+		if _, err := fc.Property(f.Property); err != nil {
+			return nil, err
+		}
+	}
+	s.recordAggregate(teamID, blueprintID, req)
+
+	// Unfiltered counts are precomputed
+	if req.Function == AggregateCount && len(req.Filters) == 0 {
+		buckets, ok, err := s.rollups.Buckets(ctx, teamID, blueprintID, req.GroupBy)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			s.searchCache.put(lookup, buckets)
+			return buckets, nil
+		}
+	}
+
+	release, err := s.searchGuard.Aggregate(ctx, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	buckets, err := s.repo.Aggregate(ctx, teamID, blueprintID, fc, req)
+	if err != nil {
+		return nil, err
+	}
+	if buckets == nil {
+		buckets = []AggregateBucket{}
+	}
+	s.searchCache.put(lookup, buckets)
+	return buckets, nil
+}
+
+// filterCompiler builds a filter compiler for the blueprint's schema
+func (s *Service) recordAggregate(teamID uuid.UUID, blueprintID string, req *AggregateRequest) {
+	s.usage.RecordSearch(teamID, blueprintID, req.Filters, "")
+	s.usage.Record(teamID, blueprintID, UsageAggregate, req.Property)
+	s.usage.Record(teamID, blueprintID, UsageGroup, req.GroupBy)
+}
+
+// RecordColumns counts the columns a saved view displayed
+func (s *Service) RecordColumns(teamID uuid.UUID, blueprintID string, columns []string) {
+	s.usage.Record(teamID, blueprintID, UsageColumn, columns...)
+}
+
+// PropertyUsage reports how a blueprint's properties were used over the last
+// days, at most the configured retention
+func (s *Service) PropertyUsage(ctx context.Context, teamID uuid.UUID, blueprintID string, days int) (*PropertyUsageResponse, error) {
+	if s.usage == nil {
+		return nil, ErrUsageDisabled
+	}
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
+		return nil, err
+	}
+
+	maxDays := int(s.usage.retention / (24 * time.Hour))
+	if days <= 0 {
+		days = 30
+	}
+	if days > maxDays {
+		days = maxDays
+	}
+	// Today counts as one of the days
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+
+	rows, err := s.repo.PropertyUsage(ctx, teamID, blueprintID, since)
+	if err != nil {
+		return nil, err
+	}
+	return &PropertyUsageResponse{
+		BlueprintID: blueprintID,
+		Days:        days,
+		Since:       since.Format(time.DateOnly),
+		Properties:  buildUsageReport(bp.Schema, rows),
 	}, nil
 }
 
-func (s *Service) Update(ctx context.Context, id uuid.UUID, req *UpdateEntityRequest) (*Entity, error) {
-	entity, err := s.repo.GetByID(ctx, id)
+func (s *Service) filterCompiler(ctx context.Context, teamID uuid.UUID, blueprintID string) (*FilterCompiler, error) {
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
 	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
 		return nil, err
 	}
-	if entity == nil {
-		return nil, ErrNotFound
+	fc := NewFilterCompiler(bp.Schema)
+	fc.hidden = hiddenProperties(ctx, bp)
+	return fc, nil
+}
+
+// ValidateFilters checks search filters against a blueprint's schema
+// without searching
+func (s *Service) ValidateFilters(ctx context.Context, teamID uuid.UUID, blueprintID string, filters []SearchFilter) error {
+	fc, err := s.filterCompiler(ctx, teamID, blueprintID)
+	if err != nil {
+		return err
 	}
+	return fc.Validate(filters, "", "")
+}
 
-	// Get blueprint for validation
-	bp, err := s.blueprintSvc.Get(ctx, entity.TeamID, entity.BlueprintID)
+// CSVTemplate returns the CSV import template rows for a blueprint
+func (s *Service) CSVTemplate(ctx context.Context, teamID uuid.UUID, blueprintID string, withExample bool) ([][]string, error) {
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
+		return nil, err
+	}
+	return CSVTemplate(hideSchema(bp.Schema, hiddenProperties(ctx, bp)), withExample), nil
+}
+
+// Export checks the format and blueprint and returns a function that streams
+// every entity of the blueprint to w. CSV covers the schema's properties only;
+// NDJSON carries the full entities.
+func (s *Service) Export(ctx context.Context, teamID uuid.UUID, blueprintID, format string) (func(w io.Writer) error, error) {
+	export, err := s.PrepareExport(ctx, teamID, blueprintID, &ExportRequest{Format: format})
+	if err != nil {
+		return nil, err
+	}
+	return func(w io.Writer) error {
+		_, err := export.Stream(ctx, w)
+		return err
+	}, nil
+}
+
+// PreparedExport is an export whose format, blueprint and filters were checked
+type PreparedExport struct {
+	repo        *Repository
+	teamID      uuid.UUID
+	blueprintID string
+	req         ExportRequest
+	fc          *FilterCompiler
+	columns     []CSVColumn
+}
+
+// PrepareExport checks the format, blueprint, filters and ordering of an
+// export of the entities matching req
+func (s *Service) PrepareExport(ctx context.Context, teamID uuid.UUID, blueprintID string, req *ExportRequest) (*PreparedExport, error) {
+	if req.Format != FormatCSV && req.Format != FormatNDJSON {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, req.Format)
+	}
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
 	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
+		return nil, err
+	}
+	// Background exports run without the caller; the properties hidden
+	// from it are recorded with the request
+	for _, property := range hiddenProperties(ctx, bp) {
+		if !slices.Contains(req.Redacted, property) {
+			req.Redacted = append(req.Redacted, property)
+		}
+	}
+	fc := NewFilterCompiler(bp.Schema)
+	fc.hidden = req.Redacted
+	if err := fc.Validate(req.Filters, req.OrderBy, req.OrderDir); err != nil {
+		return nil, err
+	}
+	export := &PreparedExport{repo: s.repo, teamID: teamID, blueprintID: blueprintID, req: *req, fc: fc}
+	if req.Format == FormatCSV {
+		export.columns = CSVColumns(hideSchema(bp.Schema, req.Redacted))
+	}
+	return export, nil
+}
+
+// CountUpTo counts the entities of the export, stopping at limit
+func (e *PreparedExport) CountUpTo(ctx context.Context, limit int) (int, error) {
+	return e.repo.CountMatching(ctx, e.teamID, e.blueprintID, e.fc, e.req.Filters, limit)
+}
+
+// Stream writes the entities of the export to w and returns how many it wrote
+func (e *PreparedExport) Stream(ctx context.Context, w io.Writer) (int, error) {
+	count := 0
+	each := func(fn func(*Entity) error) error {
+		return e.repo.ForEachMatching(ctx, e.teamID, e.blueprintID, e.fc, &e.req, func(ent *Entity) error {
+			count++
+			return fn(redact(ent, e.req.Redacted))
+		})
+	}
+	var err error
+	if e.req.Format == FormatNDJSON {
+		err = writeNDJSONExport(w, each)
+	} else {
+		err = writeCSVExport(w, e.columns, each)
+	}
+	return count, err
+}
+
+// importBatchSize is how many new entities Import creates per COPY
+const importBatchSize = 500
+
+// Import creates, and in upsert mode updates, entities from a CSV or NDJSON
+// file. Every row is validated against the blueprint schema; rows that fail
+// are reported and the others are still applied. New entities are created in
+// batches with CreateMany; when a batch fails, its rows are retried one at a
+// time so only the offending rows are reported. Updates merge the row into
+// the existing data like Update does.
+func (s *Service) Import(ctx context.Context, teamID uuid.UUID, blueprintID, format string, r io.Reader, opts ImportOptions) (*ImportResult, error) {
+	if err := opts.normalize(); err != nil {
 		return nil, err
 	}
 
-	// Merge and validate data
-	if req.Data != nil {
-		// Merge existing data with new data
-		for k, v := range req.Data {
-			entity.Data[k] = v
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return nil, ErrBlueprintNotFound
+		}
+		return nil, err
+	}
+
+	rows, err := parseImport(format, bp.Schema, r)
+	if err != nil {
+		return nil, err
+	}
+
+	identifiers := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.identifier != "" {
+			identifiers = append(identifiers, row.identifier)
+		}
+	}
+	existing, err := s.repo.ListByIdentifiers(ctx, teamID, blueprintID, identifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{DryRun: opts.DryRun, Mode: opts.Mode, Total: len(rows), Errors: []ImportRowError{}}
+	fail := func(row importRow, err error) {
+		rowErr := ImportRowError{Row: row.row, Identifier: row.identifier, Error: err.Error()}
+		if ve := validation.GetValidationErrors(err); ve != nil {
+			rowErr.Error = ErrValidation.Error()
+			rowErr.Details = ve.Errors
+		}
+		result.Errors = append(result.Errors, rowErr)
+		result.Failed++
+	}
+
+	var pending []*Entity
+	var pendingRows []importRow
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		if err := s.repo.CreateMany(ctx, pending); err != nil {
+			for i, entity := range pending {
+				if err := s.repo.Create(ctx, entity); err != nil {
+					fail(pendingRows[i], err)
+					continue
+				}
+				s.publish(ctx, events.EntityCreated, entity, nil)
+				result.Created++
+			}
+		} else {
+			for _, entity := range pending {
+				s.publish(ctx, events.EntityCreated, entity, nil)
+			}
+			result.Created += len(pending)
+		}
+		pending, pendingRows = pending[:0], pendingRows[:0]
+	}
+
+	source := writeSource(ctx)
+	hidden := hiddenProperties(ctx, bp)
+	seen := map[string]int{}
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if row.err != nil {
+			fail(row, row.err)
+			continue
+		}
+		if row.identifier == "" {
+			fail(row, errors.New("identifier is required"))
+			continue
+		}
+		if first, ok := seen[row.identifier]; ok {
+			fail(row, fmt.Errorf("duplicate identifier, first used in row %d", first))
+			continue
+		}
+		seen[row.identifier] = row.row
+
+		current := existing[row.identifier]
+		entity, err := s.planImportRow(ctx, bp, row, current, opts, source, hidden)
+		switch {
+		case err != nil:
+			fail(row, err)
+		case entity == nil:
+			result.Unchanged++
+		case current == nil && opts.DryRun:
+			result.Changes = append(result.Changes, importChange(row.row, "", nil, entity, hidden))
+			result.Created++
+		case current == nil:
+			pending = append(pending, entity)
+			pendingRows = append(pendingRows, row)
+			if len(pending) == importBatchSize {
+				flush()
+			}
+		case opts.DryRun:
+			result.Changes = append(result.Changes, importChange(row.row, "", current, entity, hidden))
+			result.Updated++
+		default:
+			if err := s.repo.Update(ctx, entity); err != nil {
+				fail(row, err)
+				continue
+			}
+			s.publish(ctx, events.EntityUpdated, entity, current)
+			result.Updated++
+		}
+	}
+	flush()
+
+	// Rows of failed batches are reported after the rows that failed validation
+	slices.SortStableFunc(result.Errors, func(a, b ImportRowError) int { return a.Row - b.Row })
+	return result, nil
+}
+
+// planImportRow returns the entity an import row writes: a new entity when
+// current is nil, or current updated with the row. It returns nil when the
+// row changes nothing, and an error when the row cannot be applied. Rows may
+// not write the hidden or rollup properties. New entities get the defaults of the
+// properties they leave out.
+func (s *Service) planImportRow(ctx context.Context, bp *blueprint.Blueprint, row importRow, current *Entity, opts ImportOptions, source PropertySource, hidden []string) (*Entity, error) {
+	if current == nil {
+		if err := keepHidden(hidden, nil, row.data); err != nil {
+			return nil, err
+		}
+		if err := keepRollups(bp, nil, row.data); err != nil {
+			return nil, err
+		}
+		if row.data == nil {
+			row.data = map[string]interface{}{}
+		}
+		if err := s.fillDefaults(ctx, bp, row.data, opts.DryRun); err != nil {
+			return nil, err
+		}
+		if err := s.validator.Validate(row.data, bp.Schema); err != nil {
+			return nil, err
+		}
+		entity := &Entity{
+			ID:          uuid.New(),
+			TeamID:      bp.TeamID,
+			BlueprintID: bp.ID,
+			Identifier:  row.identifier,
+			Title:       row.title,
+			Data:        row.data,
+			Sources:     initialSources(source, row.data),
+		}
+		entity.ExpiresAt = bp.ExpiryPolicy.ExpiresAt(time.Now(), entity.Data)
+		return entity, nil
+	}
+
+	if opts.Mode == ImportCreate {
+		return nil, ErrAlreadyExists
+	}
+	updated := *current
+	updated.Data = mergeData(current.Data, row.data)
+	if err := keepHidden(hidden, current.Data, updated.Data); err != nil {
+		return nil, err
+	}
+	if err := keepRollups(bp, current.Data, updated.Data); err != nil {
+		return nil, err
+	}
+	if row.title != "" {
+		updated.Title = row.title
+	}
+	var err error
+	if updated.Sources, _, err = mergeSources(bp.MergePolicy, source, current.Data, updated.Data, current.Sources); err != nil {
+		return nil, err
+	}
+	if updated.Title == current.Title && reflect.DeepEqual(updated.Data, current.Data) {
+		return nil, nil
+	}
+	if err := s.validateChange(bp, current.Data, updated.Data); err != nil {
+		return nil, err
+	}
+	updated.ExpiresAt = bp.ExpiryPolicy.ExpiresAt(updated.CreatedAt, updated.Data)
+	return &updated, nil
+}
+
+// Update merges req into an entity. When ifVersion is non-zero the update
+// only applies to that version of the entity and fails with a
+// *VersionConflictError otherwise. Unconditional updates are retried when
+// another writer gets in between, so that no change is lost.
+func (s *Service) Update(ctx context.Context, id uuid.UUID, req *UpdateEntityRequest, ifVersion int64) (*Entity, error) {
+	return s.modify(ctx, id, ifVersion, false, s.updateChange(req))
+}
+
+// ValidateUpdate checks an update without writing anything: the result holds
+// the entity Update would store, or the validation errors. Other errors are
+// returned as by Update.
+func (s *Service) ValidateUpdate(ctx context.Context, id uuid.UUID, req *UpdateEntityRequest, ifVersion int64) (*ValidationResult, error) {
+	return s.validateModify(ctx, id, ifVersion, s.updateChange(req))
+}
+
+// updateChange merges an update request into an entity
+func (s *Service) updateChange(req *UpdateEntityRequest) func(entity *Entity, bp *blueprint.Blueprint) error {
+	return func(entity *Entity, bp *blueprint.Blueprint) error {
+		if req.Identifier != "" && req.Identifier != entity.Identifier {
+			return ErrIdentifierChange
+		}
+		// Merge the new keys into the existing data
+		if req.Data != nil {
+			previous := make(map[string]interface{}, len(entity.Data))
+			for k, v := range entity.Data {
+				previous[k] = v
+			}
+			for k, v := range req.Data {
+				entity.Data[k] = v
+			}
+			if err := s.validateChange(bp, previous, entity.Data); err != nil {
+				return err
+			}
+		}
+		if req.Title != "" {
+			entity.Title = req.Title
+		}
+		return nil
+	}
+}
+
+// Patch applies a merge patch or JSON patch to the entity's title and data.
+// Unlike Update it can remove keys and edit nested values; the result is
+// validated against the blueprint schema as a whole.
+func (s *Service) Patch(ctx context.Context, id uuid.UUID, patch *Patch, ifVersion int64) (*Entity, error) {
+	return s.modify(ctx, id, ifVersion, false, s.patchChange(ctx, patch))
+}
+
+// ValidatePatch checks a patch without writing anything: the result holds
+// the entity Patch would store, or the validation errors. Other errors are
+// returned as by Patch.
+func (s *Service) ValidatePatch(ctx context.Context, id uuid.UUID, patch *Patch, ifVersion int64) (*ValidationResult, error) {
+	return s.validateModify(ctx, id, ifVersion, s.patchChange(ctx, patch))
+}
+
+// patchChange applies a patch to an entity
+func (s *Service) patchChange(ctx context.Context, patch *Patch) func(entity *Entity, bp *blueprint.Blueprint) error {
+	return func(entity *Entity, bp *blueprint.Blueprint) error {
+		// The patch applies to the data the caller can see
+		hidden := hiddenProperties(ctx, bp)
+		doc, err := patch.Apply(map[string]interface{}{"title": entity.Title, "data": redact(entity, hidden).Data})
+		if err != nil {
+			return err
+		}
+		for key := range doc {
+			if key == "identifier" {
+				return ErrIdentifierChange
+			}
+			if key != "title" && key != "data" {
+				return fmt.Errorf("%w: only /title and /data can be patched, not /%s", ErrInvalidPatch, key)
+			}
+		}
+		title, ok := doc["title"].(string)
+		if !ok && doc["title"] != nil {
+			return fmt.Errorf("%w: title must be a string", ErrInvalidPatch)
+		}
+		data, ok := doc["data"].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: data must be an object", ErrInvalidPatch)
+		}
+		if err := keepHidden(hidden, entity.Data, data); err != nil {
+			return err
+		}
+		if err := s.validateChange(bp, entity.Data, data); err != nil {
+			return err
+		}
+		entity.Title, entity.Data = title, data
+		return nil
+	}
+}
+
+// validateChange validates an update of an entity's data from previous to
+// next. Blueprints with strict_updates validate the whole result. Others
+// validate only the top-level properties the update sets or changes, so
+// entities that predate a newly required property or a tightened rule can
+// still be edited; removing a required property is rejected either way.
+func (s *Service) validateChange(bp *blueprint.Blueprint, previous, next map[string]interface{}) error {
+	if bp.StrictUpdates {
+		return s.validator.Validate(next, bp.Schema)
+	}
+	changed := make(map[string]interface{})
+	for k, v := range next {
+		if before, ok := previous[k]; !ok || !reflect.DeepEqual(before, v) {
+			changed[k] = v
+		}
+	}
+	if err := s.validator.ValidatePartial(changed, bp.Schema); err != nil {
+		return err
+	}
+	var removed []validation.ValidationError
+	required, _ := bp.Schema["required"].([]interface{})
+	for _, raw := range required {
+		property, _ := raw.(string)
+		_, had := previous[property]
+		if _, has := next[property]; had && !has {
+			removed = append(removed, validation.ValidationError{
+				Field:   property,
+				Message: property + " is required",
+				Path:    validation.JSONPointer(property),
+				Type:    "required",
+			})
 		}
+	}
+	if len(removed) > 0 {
+		return &validation.ValidationErrors{Errors: removed}
+	}
+	return nil
+}
 
-		if err := s.validator.Validate(entity.Data, bp.Schema); err != nil {
+// Rename changes an entity's identifier. External systems may reference the
+// old identifier, so blueprints must opt in with identifier_mutable. The old
+// identifier stays an alias that GetByIdentifier resolves to the entity.
+func (s *Service) Rename(ctx context.Context, id uuid.UUID, req *RenameEntityRequest, ifVersion int64) (*Entity, error) {
+	return s.modify(ctx, id, ifVersion, false, func(entity *Entity, bp *blueprint.Blueprint) error {
+		if !bp.IdentifierMutable {
+			return ErrIdentifierImmutable
+		}
+		existing, err := s.repo.GetByIdentifier(ctx, entity.TeamID, entity.BlueprintID, req.Identifier)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.ID != entity.ID {
+			return ErrAlreadyExists
+		}
+		entity.Identifier = req.Identifier
+		return nil
+	})
+}
+
+// modify reads the entity, applies change and writes it back with a version
+// check. Unconditional changes (ifVersion 0) are retried from a fresh read
+// when another writer gets in between. With validateOnly the changed entity
+// is returned without being written.
+func (s *Service) modify(ctx context.Context, id uuid.UUID, ifVersion int64, validateOnly bool, change func(entity *Entity, bp *blueprint.Blueprint) error) (*Entity, error) {
+	for attempt := 1; ; attempt++ {
+		entity, err := s.repo.GetByID(ctx, id)
+		if err != nil {
 			return nil, err
 		}
+		if entity == nil {
+			return nil, ErrNotFound
+		}
+		if ifVersion != 0 && entity.Version != ifVersion {
+			return nil, s.versionConflict(ctx, entity)
+		}
+
+		updated, err := s.update(ctx, entity, validateOnly, change)
+		if !errors.Is(err, ErrVersionConflict) {
+			return updated, err
+		}
+		if ifVersion != 0 || attempt == updateAttempts {
+			return nil, s.conflict(ctx, id)
+		}
 	}
+}
 
-	if req.Title != "" {
-		entity.Title = req.Title
+func (s *Service) update(ctx context.Context, entity *Entity, validateOnly bool, change func(entity *Entity, bp *blueprint.Blueprint) error) (*Entity, error) {
+	// Get blueprint for validation
+	bp, err := s.blueprintSvc.Get(ctx, entity.TeamID, entity.BlueprintID)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.repo.Update(ctx, entity); err != nil {
+	previous := *entity
+	previous.Data = make(map[string]interface{}, len(entity.Data))
+	for k, v := range entity.Data {
+		previous.Data[k] = v
+	}
+
+	if err := change(entity, bp); err != nil {
+		return nil, err
+	}
+	if err := keepHidden(hiddenProperties(ctx, bp), previous.Data, entity.Data); err != nil {
 		return nil, err
 	}
+	if err := keepRollups(bp, previous.Data, entity.Data); err != nil {
+		return nil, err
+	}
+	sources, reverted, err := mergeSources(bp.MergePolicy, writeSource(ctx), previous.Data, entity.Data, previous.Sources)
+	if err != nil {
+		return nil, err
+	}
+	// Mixing kept and new values may break the schema where neither did
+	if reverted {
+		if err := s.validateChange(bp, previous.Data, entity.Data); err != nil {
+			return nil, err
+		}
+	}
+	entity.Sources = sources
+	entity.ExpiresAt = bp.ExpiryPolicy.ExpiresAt(entity.CreatedAt, entity.Data)
+	if validateOnly {
+		return entity, nil
+	}
+
+	if entity.Identifier == previous.Identifier {
+		err = s.repo.Update(ctx, entity)
+	} else {
+		err = s.repo.Rename(ctx, entity, previous.Identifier)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.publish(ctx, events.EntityUpdated, entity, &previous)
+	if entity.Identifier != previous.Identifier {
+		id := entity.ID
+		s.bus.Publish(ctx, events.Event{
+			Type:        events.EntityRenamed,
+			TeamID:      entity.TeamID,
+			BlueprintID: entity.BlueprintID,
+			EntityID:    &id,
+			Payload:     &Rename{Entity: entity, PreviousIdentifier: previous.Identifier},
+		})
+	}
 
 	return entity, nil
 }
 
+// History returns an entity's revisions, oldest first. With property set it
+// returns only the revisions that set, changed or removed that data property.
+// History outlives the entity, so deleted entities can still be audited.
+func (s *Service) History(ctx context.Context, teamID, id uuid.UUID, property string, limit, offset int) (*HistoryResponse, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	hidden, err := s.hiddenHistory(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	if top, _, _ := strings.Cut(property, "."); slices.Contains(hidden, top) {
+		return nil, fmt.Errorf("%w: %s", ErrRestrictedProperty, property)
+	}
+
+	resp := &HistoryResponse{EntityID: id, Property: property, Limit: limit, Offset: offset}
+	if property == "" {
+		var revisions []*Revision
+		revisions, resp.Total, err = s.repo.History(ctx, teamID, id, limit, offset)
+		for _, rev := range revisions {
+			rev.Data = redact(&Entity{Data: rev.Data}, hidden).Data
+		}
+		resp.History = revisions
+	} else {
+		resp.History, resp.Total, err = s.repo.PropertyHistory(ctx, teamID, id, property, limit, offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Total == 0 {
+		// Entities written before history was recorded have none yet
+		entity, err := s.repo.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if entity == nil || entity.TeamID != teamID {
+			return nil, ErrNotFound
+		}
+	}
+	return resp, nil
+}
+
+// conflict describes a lost update race: the entity changed or was deleted
+func (s *Service) conflict(ctx context.Context, id uuid.UUID) error {
+	current, err := s.repo.GetByID(ctx, id)
+	switch {
+	case err != nil:
+		return err
+	case current == nil:
+		return ErrNotFound
+	}
+	return s.versionConflict(ctx, current)
+}
+
+// versionConflict reports the current entity as the caller may see it
+func (s *Service) versionConflict(ctx context.Context, current *Entity) error {
+	redacted, err := s.Redact(ctx, current)
+	if err != nil {
+		return err
+	}
+	return &VersionConflictError{Current: redacted[0]}
+}
+
 func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
 	entity, err := s.repo.GetByID(ctx, id)
 	if err != nil {
@@ -184,9 +1118,33 @@ func (s *Service) Delete(ctx context.Context, id uuid.UUID) error {
 		return ErrNotFound
 	}
 
-	return s.repo.Delete(ctx, id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	s.publish(ctx, events.EntityDeleted, entity, nil)
+	return nil
 }
 
 func (s *Service) DeleteByBlueprint(ctx context.Context, teamID uuid.UUID, blueprintID string) error {
-	return s.repo.DeleteByBlueprint(ctx, teamID, blueprintID)
+	if err := s.repo.DeleteByBlueprint(ctx, teamID, blueprintID); err != nil {
+		return err
+	}
+	s.searchCache.Invalidate(teamID, blueprintID)
+	return nil
+}
+
+// publish announces an entity change. previous is the entity before an update.
+func (s *Service) publish(ctx context.Context, eventType string, entity, previous *Entity) {
+	id := entity.ID
+	e := events.Event{
+		Type:        eventType,
+		TeamID:      entity.TeamID,
+		BlueprintID: entity.BlueprintID,
+		EntityID:    &id,
+		Payload:     entity,
+	}
+	if previous != nil {
+		e.Previous = previous
+	}
+	s.bus.Publish(ctx, e)
 }
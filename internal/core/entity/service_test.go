@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"testing"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/validation"
+)
+
+func TestValidateChange(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"owner", "tier"},
+		"properties": map[string]interface{}{
+			"owner":   map[string]interface{}{"type": "string"},
+			"tier":    map[string]interface{}{"type": "string", "enum": []interface{}{"gold", "silver"}},
+			"version": map[string]interface{}{"type": "string", "minLength": 1},
+		},
+	}
+	s := &Service{validator: validation.NewValidator()}
+	// The entity predates the required tier and has an invalid version
+	previous := map[string]interface{}{"owner": "platform", "version": ""}
+	with := func(changes map[string]interface{}) map[string]interface{} {
+		next := map[string]interface{}{}
+		for k, v := range previous {
+			next[k] = v
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(next, k)
+			} else {
+				next[k] = v
+			}
+		}
+		return next
+	}
+
+	tests := []struct {
+		name    string
+		strict  bool
+		next    map[string]interface{}
+		invalid string
+	}{
+		{name: "partial: one valid field", next: with(map[string]interface{}{"owner": "payments"})},
+		{name: "partial: invalid changed field", next: with(map[string]interface{}{"tier": "bronze"}), invalid: "/tier"},
+		{name: "partial: required field removed", next: with(map[string]interface{}{"owner": nil}), invalid: "/owner"},
+		{name: "partial: optional field removed", next: with(map[string]interface{}{"version": nil})},
+		{name: "strict: one valid field", strict: true, next: with(map[string]interface{}{"owner": "payments"}), invalid: "/tier"},
+		{name: "strict: complete entity", strict: true, next: with(map[string]interface{}{"tier": "gold", "version": "1.0"})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bp := &blueprint.Blueprint{Schema: schema, StrictUpdates: tt.strict}
+			err := s.validateChange(bp, previous, tt.next)
+			if tt.invalid == "" {
+				if err != nil {
+					t.Fatalf("validateChange = %v, want nil", err)
+				}
+				return
+			}
+			ve := validation.GetValidationErrors(err)
+			if ve == nil {
+				t.Fatalf("validateChange = %v, want validation errors", err)
+			}
+			for _, e := range ve.Errors {
+				if e.Path == tt.invalid {
+					return
+				}
+			}
+			t.Errorf("validateChange errors = %+v, want one at %s", ve.Errors, tt.invalid)
+		})
+	}
+}
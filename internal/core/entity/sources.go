@@ -0,0 +1,241 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+var (
+	// ErrPropertyManaged rejects manual writes to properties an integration
+	// holds under the integration_wins merge policy
+	ErrPropertyManaged     = errors.New("property is managed by an integration")
+	ErrIntegrationNotFound = errors.New("integration not found")
+)
+
+// AsIntegration returns ctx with the integration recorded as the writer of
+// the entity changes made with it, for exporters that sync through the
+// entity API. The integration must belong to the team.
+func (s *Service) AsIntegration(ctx context.Context, teamID, integrationID uuid.UUID) (context.Context, error) {
+	exists, err := s.repo.IntegrationExists(ctx, teamID, integrationID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrIntegrationNotFound
+	}
+	actor, _ := events.ActorFrom(ctx)
+	actor.IntegrationID = &integrationID
+	return events.WithActor(ctx, actor), nil
+}
+
+// Sources lists who last wrote each of an entity's data properties, with
+// the merge policy that applies to it
+func (s *Service) Sources(ctx context.Context, teamID, id uuid.UUID) (*SourcesResponse, error) {
+	entity, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entity == nil || entity.TeamID != teamID {
+		return nil, ErrNotFound
+	}
+	bp, err := s.blueprintSvc.Get(ctx, teamID, entity.BlueprintID)
+	if err != nil {
+		return nil, err
+	}
+
+	synced, err := s.integrationSyncTimes(ctx, teamID, []*Entity{entity})
+	if err != nil {
+		return nil, err
+	}
+	resp := &SourcesResponse{EntityID: id, Properties: []*PropertyProvenance{}}
+	for _, property := range slices.Sorted(maps.Keys(entity.Sources)) {
+		resp.Properties = append(resp.Properties, provenance(bp, property, entity.Sources[property], synced))
+	}
+	return resp, nil
+}
+
+// provenance returns the source of a property with the merge policy that
+// applies to it and, for integrations, their last sync
+func provenance(bp *blueprint.Blueprint, property string, source PropertySource, synced map[uuid.UUID]time.Time) *PropertyProvenance {
+	p := &PropertyProvenance{
+		Property:       property,
+		PropertySource: source,
+		Policy:         bp.MergePolicy.For(property),
+	}
+	if p.Policy == blueprint.MergePrecedence {
+		p.Ranking = bp.MergePolicy.Ranking(property)
+	}
+	if source.Type == SourceIntegration && source.ID != nil {
+		if at, ok := synced[*source.ID]; ok {
+			p.LastSyncedAt = &at
+		}
+	}
+	return p
+}
+
+// integrationSyncTimes returns the last syncs of the integrations that wrote
+// properties of entities, in one query
+func (s *Service) integrationSyncTimes(ctx context.Context, teamID uuid.UUID, entities []*Entity) (map[uuid.UUID]time.Time, error) {
+	var ids []uuid.UUID
+	for _, e := range entities {
+		for _, source := range e.Sources {
+			if source.Type == SourceIntegration && source.ID != nil && !slices.Contains(ids, *source.ID) {
+				ids = append(ids, *source.ID)
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	return s.repo.IntegrationSyncTimes(ctx, teamID, ids)
+}
+
+// ReleaseSource forgets who last wrote a property, so that the next write
+// goes through whatever the merge policy. It is how a manual edit is handed
+// back to an integration, and the other way round.
+func (s *Service) ReleaseSource(ctx context.Context, teamID, id uuid.UUID, property string) error {
+	found, err := s.repo.ReleaseSource(ctx, teamID, id, property)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// writeSource is the source of the writes made with ctx
+func writeSource(ctx context.Context) PropertySource {
+	source := PropertySource{Type: SourceSystem, At: time.Now().UTC()}
+	actor, _ := events.ActorFrom(ctx)
+	switch {
+	case actor.IntegrationID != nil:
+		source.Type, source.ID = SourceIntegration, actor.IntegrationID
+	case actor.APIKeyID != nil:
+		source.Type, source.ID = SourceAPIKey, actor.APIKeyID
+	case actor.UserID != nil:
+		source.Type, source.ID = SourceUser, actor.UserID
+	}
+	return source
+}
+
+// initialSources records source as the writer of every property of a new entity
+func initialSources(source PropertySource, data map[string]interface{}) map[string]PropertySource {
+	sources := make(map[string]PropertySource, len(data))
+	for property := range data {
+		sources[property] = source
+	}
+	return sources
+}
+
+// manualSource reports whether a source is a person's edit, made directly or
+// with an API key, rather than a sync or background work
+func manualSource(source PropertySource) bool {
+	return source.Type == SourceUser || source.Type == SourceAPIKey
+}
+
+// mergeSources applies the blueprint's merge policy to a write that turns
+// previous into next, and returns sources with the writer recorded for every
+// top-level property the write sets, changes or removes. Integration changes
+// to properties held by a manual edit under manual_wins are undone in next,
+// which mergeSources reports so the result can be validated again. Manual
+// changes to properties held by an integration under integration_wins fail
+// with ErrPropertyManaged. Under precedence, changes by writers ranked below
+// the property's holder are undone for integrations and fail for users and
+// API keys alike. Properties without a recorded source are never held.
+func mergeSources(policy *blueprint.MergePolicy, source PropertySource, previous, next map[string]interface{}, sources map[string]PropertySource) (map[string]PropertySource, bool, error) {
+	merged := maps.Clone(sources)
+	if merged == nil {
+		merged = map[string]PropertySource{}
+	}
+
+	revert := func(property string) {
+		if value, ok := previous[property]; ok {
+			next[property] = value
+		} else {
+			delete(next, property)
+		}
+	}
+	var managed []string
+	reverted := false
+	for _, property := range changedProperties(previous, next) {
+		if held, ok := sources[property]; ok {
+			switch policy.For(property) {
+			case blueprint.MergeManualWins:
+				if source.Type == SourceIntegration && manualSource(held) {
+					revert(property)
+					reverted = true
+					continue
+				}
+			case blueprint.MergeIntegrationWins:
+				if manualSource(source) && held.Type == SourceIntegration {
+					managed = append(managed, property)
+					continue
+				}
+			case blueprint.MergePrecedence:
+				ranking := policy.Ranking(property)
+				if source.Type != SourceSystem &&
+					blueprint.Rank(ranking, rankedWriter(source)) > blueprint.Rank(ranking, rankedWriter(held)) {
+					if source.Type == SourceIntegration {
+						revert(property)
+						reverted = true
+					} else {
+						managed = append(managed, property)
+					}
+					continue
+				}
+			}
+		}
+		if _, ok := next[property]; ok {
+			merged[property] = source
+		} else {
+			delete(merged, property)
+		}
+	}
+	if len(managed) > 0 {
+		return nil, false, fmt.Errorf("%w: %s", ErrPropertyManaged, strings.Join(managed, ", "))
+	}
+	return merged, reverted, nil
+}
+
+// rankedWriter is how a source appears in a precedence ranking: integrations
+// by ID and users and API keys as blueprint.PrecedenceManual. The server
+// itself is never listed.
+func rankedWriter(source PropertySource) string {
+	switch {
+	case source.Type == SourceIntegration && source.ID != nil:
+		return source.ID.String()
+	case manualSource(source):
+		return blueprint.PrecedenceManual
+	}
+	return ""
+}
+
+// changedProperties returns the top-level properties that differ between two
+// versions of an entity's data, in name order
+func changedProperties(previous, next map[string]interface{}) []string {
+	var changed []string
+	for property, value := range next {
+		if old, ok := previous[property]; !ok || !reflect.DeepEqual(old, value) {
+			changed = append(changed, property)
+		}
+	}
+	for property := range previous {
+		if _, ok := next[property]; !ok {
+			changed = append(changed, property)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
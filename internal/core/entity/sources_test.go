@@ -0,0 +1,253 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+func TestWriteSource(t *testing.T) {
+	userID, keyID, integrationID := uuid.New(), uuid.New(), uuid.New()
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		want   string
+		wantID *uuid.UUID
+	}{
+		{"background", context.Background(), SourceSystem, nil},
+		{"user", events.WithActor(context.Background(), events.Actor{UserID: &userID}), SourceUser, &userID},
+		{"api key", events.WithActor(context.Background(), events.Actor{UserID: &userID, APIKeyID: &keyID}), SourceAPIKey, &keyID},
+		{"integration", events.WithActor(context.Background(), events.Actor{APIKeyID: &keyID, IntegrationID: &integrationID}), SourceIntegration, &integrationID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := writeSource(tt.ctx)
+			if got.Type != tt.want || !reflect.DeepEqual(got.ID, tt.wantID) {
+				t.Errorf("writeSource() = %s %v, want %s %v", got.Type, got.ID, tt.want, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestMergeSources(t *testing.T) {
+	userID, integrationID := uuid.New(), uuid.New()
+	user := PropertySource{Type: SourceUser, ID: &userID}
+	sync := PropertySource{Type: SourceIntegration, ID: &integrationID}
+	system := PropertySource{Type: SourceSystem}
+	policy := &blueprint.MergePolicy{
+		Default:    blueprint.MergeManualWins,
+		Properties: map[string]string{"replicas": blueprint.MergeIntegrationWins, "notes": blueprint.MergeLastWriteWins},
+	}
+	previous := func() map[string]interface{} {
+		return map[string]interface{}{"owner": "alice", "replicas": 3.0, "notes": "a", "tier": "gold"}
+	}
+	held := map[string]PropertySource{"owner": user, "replicas": sync, "notes": user}
+
+	tests := []struct {
+		name         string
+		source       PropertySource
+		next         map[string]interface{}
+		wantData     map[string]interface{}
+		wantSources  map[string]PropertySource
+		wantReverted bool
+		wantErr      error
+	}{
+		{
+			name:        "sync keeps manual edits",
+			source:      sync,
+			next:        map[string]interface{}{"owner": "bob", "replicas": 5.0, "notes": "b", "tier": "gold"},
+			wantData:    map[string]interface{}{"owner": "alice", "replicas": 5.0, "notes": "b", "tier": "gold"},
+			wantSources: map[string]PropertySource{"owner": user, "replicas": sync, "notes": sync},
+			// owner stays with the user; replicas and notes go through
+			wantReverted: true,
+		},
+		{
+			name:        "sync cannot remove a manual edit",
+			source:      sync,
+			next:        map[string]interface{}{"replicas": 3.0, "notes": "a", "tier": "gold"},
+			wantData:    previous(),
+			wantSources: held,
+			// owner is restored
+			wantReverted: true,
+		},
+		{
+			name:        "unheld properties go through",
+			source:      sync,
+			next:        map[string]interface{}{"owner": "alice", "replicas": 3.0, "notes": "a", "tier": "silver", "region": "eu"},
+			wantData:    map[string]interface{}{"owner": "alice", "replicas": 3.0, "notes": "a", "tier": "silver", "region": "eu"},
+			wantSources: map[string]PropertySource{"owner": user, "replicas": sync, "notes": user, "tier": sync, "region": sync},
+		},
+		{
+			name:    "manual edit of a synced property",
+			source:  user,
+			next:    map[string]interface{}{"owner": "alice", "replicas": 1.0, "notes": "a", "tier": "gold"},
+			wantErr: ErrPropertyManaged,
+		},
+		{
+			name:        "manual removal",
+			source:      user,
+			next:        map[string]interface{}{"owner": "alice", "replicas": 3.0, "tier": "gold"},
+			wantData:    map[string]interface{}{"owner": "alice", "replicas": 3.0, "tier": "gold"},
+			wantSources: map[string]PropertySource{"owner": user, "replicas": sync},
+		},
+		{
+			name:        "system writes are held by nothing",
+			source:      system,
+			next:        map[string]interface{}{"owner": "carol", "replicas": 1.0, "notes": "a", "tier": "gold"},
+			wantData:    map[string]interface{}{"owner": "carol", "replicas": 1.0, "notes": "a", "tier": "gold"},
+			wantSources: map[string]PropertySource{"owner": system, "replicas": system, "notes": user},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, reverted, err := mergeSources(policy, tt.source, previous(), tt.next, held)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("mergeSources() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeSources() error = %v", err)
+			}
+			if reverted != tt.wantReverted {
+				t.Errorf("reverted = %v, want %v", reverted, tt.wantReverted)
+			}
+			if !reflect.DeepEqual(tt.next, tt.wantData) {
+				t.Errorf("data = %v, want %v", tt.next, tt.wantData)
+			}
+			if !reflect.DeepEqual(sources, tt.wantSources) {
+				t.Errorf("sources = %v, want %v", sources, tt.wantSources)
+			}
+		})
+	}
+
+	if len(held) != 3 || held["notes"] != user {
+		t.Errorf("mergeSources modified the recorded sources: %v", held)
+	}
+}
+
+func TestMergeSources_NoPolicy(t *testing.T) {
+	userID, integrationID := uuid.New(), uuid.New()
+	user := PropertySource{Type: SourceUser, ID: &userID}
+	sync := PropertySource{Type: SourceIntegration, ID: &integrationID}
+
+	next := map[string]interface{}{"owner": "bob"}
+	sources, reverted, err := mergeSources(nil, sync, map[string]interface{}{"owner": "alice"}, next, map[string]PropertySource{"owner": user})
+	if err != nil || reverted {
+		t.Fatalf("mergeSources() = %v, %v", reverted, err)
+	}
+	if next["owner"] != "bob" || sources["owner"] != sync {
+		t.Errorf("last write did not win: %v %v", next, sources)
+	}
+}
+
+func TestMergeSources_Precedence(t *testing.T) {
+	userID, githubID, pagerdutyID, otherID := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	user := PropertySource{Type: SourceUser, ID: &userID}
+	github := PropertySource{Type: SourceIntegration, ID: &githubID}
+	pagerduty := PropertySource{Type: SourceIntegration, ID: &pagerdutyID}
+	other := PropertySource{Type: SourceIntegration, ID: &otherID}
+	policy := &blueprint.MergePolicy{
+		Default:            blueprint.MergePrecedence,
+		Precedence:         []string{githubID.String(), blueprint.PrecedenceManual},
+		PropertyPrecedence: map[string][]string{"oncall": {pagerdutyID.String()}},
+	}
+	previous := func() map[string]interface{} {
+		return map[string]interface{}{"repo": "a", "oncall": "alice"}
+	}
+
+	tests := []struct {
+		name         string
+		source       PropertySource
+		held         map[string]PropertySource
+		next         map[string]interface{}
+		wantData     map[string]interface{}
+		wantReverted bool
+		wantErr      error
+	}{
+		{
+			name:         "lower-ranked integration is dropped",
+			source:       pagerduty,
+			held:         map[string]PropertySource{"repo": github},
+			next:         map[string]interface{}{"repo": "b", "oncall": "alice"},
+			wantData:     previous(),
+			wantReverted: true,
+		},
+		{
+			name:     "higher-ranked integration overwrites",
+			source:   github,
+			held:     map[string]PropertySource{"repo": user},
+			next:     map[string]interface{}{"repo": "b", "oncall": "alice"},
+			wantData: map[string]interface{}{"repo": "b", "oncall": "alice"},
+		},
+		{
+			name:    "manual edit below an integration fails",
+			source:  user,
+			held:    map[string]PropertySource{"repo": github},
+			next:    map[string]interface{}{"repo": "b", "oncall": "alice"},
+			wantErr: ErrPropertyManaged,
+		},
+		{
+			name:     "unlisted writers tie",
+			source:   other,
+			held:     map[string]PropertySource{"oncall": user},
+			next:     map[string]interface{}{"repo": "a", "oncall": "bob"},
+			wantData: map[string]interface{}{"repo": "a", "oncall": "bob"},
+		},
+		{
+			name:         "property ranking overrides the default",
+			source:       github,
+			held:         map[string]PropertySource{"oncall": pagerduty},
+			next:         map[string]interface{}{"repo": "a", "oncall": "bob"},
+			wantData:     previous(),
+			wantReverted: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, reverted, err := mergeSources(policy, tt.source, previous(), tt.next, tt.held)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("mergeSources() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeSources() error = %v", err)
+			}
+			if reverted != tt.wantReverted {
+				t.Errorf("reverted = %v, want %v", reverted, tt.wantReverted)
+			}
+			if !reflect.DeepEqual(tt.next, tt.wantData) {
+				t.Errorf("data = %v, want %v", tt.next, tt.wantData)
+			}
+		})
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	bp := &blueprint.Blueprint{MergePolicy: &blueprint.MergePolicy{Default: blueprint.MergeManualWins}}
+	integration, user := uuid.New(), uuid.New()
+	syncedAt := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	synced := map[uuid.UUID]time.Time{integration: syncedAt}
+
+	got := provenance(bp, "version", PropertySource{Type: SourceIntegration, ID: &integration}, synced)
+	if got.Policy != blueprint.MergeManualWins || got.LastSyncedAt == nil || !got.LastSyncedAt.Equal(syncedAt) {
+		t.Errorf("provenance of a synced property = %+v", got)
+	}
+	if got := provenance(bp, "team", PropertySource{Type: SourceUser, ID: &user}, synced); got.LastSyncedAt != nil {
+		t.Errorf("last_synced_at of a manual edit = %v, want none", got.LastSyncedAt)
+	}
+	other := uuid.New()
+	if got := provenance(bp, "version", PropertySource{Type: SourceIntegration, ID: &other}, synced); got.LastSyncedAt != nil {
+		t.Errorf("last_synced_at of an integration that never synced = %v, want none", got.LastSyncedAt)
+	}
+}
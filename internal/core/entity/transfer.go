@@ -0,0 +1,223 @@
+package entity
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxImportRows bounds the rows of a single import
+const maxImportRows = 10000
+
+// maxImportLine bounds a single NDJSON line
+const maxImportLine = 1 << 20
+
+// importRow is one parsed row of an import file. err is set when the row
+// could not be parsed; the rest of the file is still imported.
+type importRow struct {
+	row        int
+	identifier string
+	title      string
+	data       map[string]interface{}
+	err        error
+}
+
+// parseImport reads every row of an import file. Errors that make the whole
+// file unusable wrap ErrInvalidImport.
+func parseImport(format string, schema map[string]interface{}, r io.Reader) ([]importRow, error) {
+	switch format {
+	case FormatCSV:
+		return parseCSVImport(schema, r)
+	case FormatNDJSON:
+		return parseNDJSONImport(r)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+}
+
+// parseCSVImport reads a CSV file in the layout of CSVColumns. Columns may
+// appear in any order and optional ones may be left out, but unknown columns
+// are rejected so that a misspelt header does not silently drop data.
+func parseCSVImport(schema map[string]interface{}, r io.Reader) ([]importRow, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidImport, err)
+	}
+	// Spreadsheet exports often start with a byte order mark
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+
+	known := map[string]CSVColumn{}
+	for _, col := range CSVColumns(schema) {
+		known[col.Header] = col
+	}
+	columns := make([]CSVColumn, len(header))
+	seen := map[string]bool{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		col, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidImport, name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%w: duplicate column %q", ErrInvalidImport, name)
+		}
+		seen[name] = true
+		columns[i] = col
+	}
+	if !seen["identifier"] {
+		return nil, fmt.Errorf("%w: missing identifier column", ErrInvalidImport)
+	}
+
+	var rows []importRow
+	for n := 2; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if len(rows) == maxImportRows {
+			return nil, fmt.Errorf("%w: at most %d rows are allowed", ErrInvalidImport, maxImportRows)
+		}
+		if err != nil {
+			if !errors.Is(err, csv.ErrFieldCount) {
+				return nil, fmt.Errorf("%w: row %d: %w", ErrInvalidImport, n, err)
+			}
+			rows = append(rows, importRow{row: n, err: fmt.Errorf("expected %d fields, got %d", len(columns), len(record))})
+			continue
+		}
+		rows = append(rows, parseCSVRecord(n, columns, record))
+	}
+}
+
+func parseCSVRecord(n int, columns []CSVColumn, record []string) importRow {
+	row := importRow{row: n, data: map[string]interface{}{}}
+	for i, cell := range record {
+		col := columns[i]
+		switch {
+		case col.Path == nil && col.Header == "identifier":
+			row.identifier = strings.TrimSpace(cell)
+		case col.Path == nil:
+			row.title = cell
+		case cell == "":
+		default:
+			v, err := col.parse(cell)
+			if err != nil {
+				row.err = err
+				return row
+			}
+			setPath(row.data, col.Path, v)
+		}
+	}
+	return row
+}
+
+// parseNDJSONImport reads one entity per line in the shape of
+// CreateEntityRequest. Extra fields, such as those written by the NDJSON
+// export, are ignored. Blank lines are skipped.
+func parseNDJSONImport(r io.Reader) ([]importRow, error) {
+	var rows []importRow
+	err := scanNDJSON(r, func(n int, line []byte) {
+		row := importRow{row: n}
+		var req CreateEntityRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			row.err = fmt.Errorf("invalid JSON: %v", err)
+		} else {
+			row.identifier = strings.TrimSpace(req.Identifier)
+			row.title = req.Title
+			row.data = req.Data
+			if row.data == nil {
+				row.data = map[string]interface{}{}
+			}
+		}
+		rows = append(rows, row)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// scanNDJSON calls fn with the line number and content of every non-blank
+// line of r, enforcing the import limits
+func scanNDJSON(r io.Reader, fn func(n int, line []byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxImportLine)
+
+	lines := 0
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if lines == maxImportRows {
+			return fmt.Errorf("%w: at most %d rows are allowed", ErrInvalidImport, maxImportRows)
+		}
+		lines++
+		fn(n, line)
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("%w: lines are limited to %d bytes", ErrInvalidImport, maxImportLine)
+		}
+		return err
+	}
+	if lines == 0 {
+		return fmt.Errorf("%w: file is empty", ErrInvalidImport)
+	}
+	return nil
+}
+
+// mergeData returns dst with src merged in. Nested objects are merged key by
+// key so that a CSV row setting "metadata.tier" keeps the other metadata
+// fields. Neither map is modified.
+func mergeData(dst, src map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(dst)+len(src))
+	for k, v := range dst {
+		merged[k] = v
+	}
+	for k, v := range src {
+		srcObj, srcOK := v.(map[string]interface{})
+		dstObj, dstOK := merged[k].(map[string]interface{})
+		if srcOK && dstOK {
+			merged[k] = mergeData(dstObj, srcObj)
+			continue
+		}
+		merged[k] = v
+	}
+	return merged
+}
+
+// writeCSVExport writes the header row followed by one row per entity
+func writeCSVExport(w io.Writer, columns []CSVColumn, each func(func(*Entity) error) error) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.Header
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	err := each(func(e *Entity) error {
+		return cw.Write(CSVRecord(columns, e))
+	})
+	cw.Flush()
+	if err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// writeNDJSONExport writes one JSON-encoded entity per line
+func writeNDJSONExport(w io.Writer, each func(func(*Entity) error) error) error {
+	enc := json.NewEncoder(w)
+	return each(func(e *Entity) error {
+		return enc.Encode(e)
+	})
+}
@@ -0,0 +1,168 @@
+package entity
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCSVExport_RoundTrip(t *testing.T) {
+	schema := testSchema()
+	e := &Entity{
+		Identifier: "payments",
+		Title:      "Payments",
+		Data: map[string]interface{}{
+			"owner":    "team@example.com",
+			"language": "Go",
+			"coverage": 81.5,
+			"on_call":  true,
+			"tags":     []interface{}{"core", "pci"},
+			"metadata": map[string]interface{}{
+				"tier":   float64(1),
+				"labels": map[string]interface{}{"cost-center": "42"},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	each := func(fn func(*Entity) error) error { return fn(e) }
+	if err := writeCSVExport(&buf, CSVColumns(schema), each); err != nil {
+		t.Fatalf("export: %v", err)
+	}
+
+	rows, err := parseCSVImport(schema, &buf)
+	if err != nil {
+		t.Fatalf("import: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	row := rows[0]
+	if row.err != nil {
+		t.Fatalf("row error: %v", row.err)
+	}
+	if row.row != 2 || row.identifier != e.Identifier || row.title != e.Title {
+		t.Errorf("row = %d %q %q", row.row, row.identifier, row.title)
+	}
+	if !reflect.DeepEqual(row.data, e.Data) {
+		t.Errorf("data = %#v, want %#v", row.data, e.Data)
+	}
+}
+
+func TestFormatCSVCell_AmbiguousListsAreJSON(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{[]interface{}{"a", "b"}, "a;b"},
+		{[]interface{}{"a;b", "c"}, `["a;b","c"]`},
+		{[]interface{}{"[x]"}, `["[x]"]`},
+		{[]interface{}{map[string]interface{}{"k": "v"}}, `[{"k":"v"}]`},
+		{[]interface{}{float64(1), true}, "1;true"},
+	}
+	for _, tt := range tests {
+		if got := formatCSVCell(tt.value); got != tt.want {
+			t.Errorf("formatCSVCell(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	col := CSVColumn{Header: "tags", Type: "array", schema: map[string]interface{}{"items": map[string]interface{}{"type": "string"}}}
+	v, err := col.parse(`["a;b","c"]`)
+	if err != nil || !reflect.DeepEqual(v, []interface{}{"a;b", "c"}) {
+		t.Errorf("parse JSON list = %v, %v", v, err)
+	}
+}
+
+func TestParseCSVImport_RowErrors(t *testing.T) {
+	input := "\ufeffidentifier,coverage,metadata.tier\n" +
+		"ok,0.5,2\n" +
+		"bad-number,high,\n" +
+		"short\n" +
+		"empty-cells,,\n"
+
+	rows, err := parseCSVImport(testSchema(), strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+	if rows[0].err != nil || !reflect.DeepEqual(rows[0].data, map[string]interface{}{"coverage": 0.5, "metadata": map[string]interface{}{"tier": float64(2)}}) {
+		t.Errorf("row 2 = %#v, %v", rows[0].data, rows[0].err)
+	}
+	if rows[1].err == nil || !strings.Contains(rows[1].err.Error(), "coverage") {
+		t.Errorf("row 3 error = %v, want coverage error", rows[1].err)
+	}
+	if rows[2].err == nil || rows[2].row != 4 {
+		t.Errorf("row 4 = %d, %v, want field count error", rows[2].row, rows[2].err)
+	}
+	if rows[3].err != nil || len(rows[3].data) != 0 {
+		t.Errorf("empty cells should leave properties unset, got %#v", rows[3].data)
+	}
+}
+
+func TestParseCSVImport_FileErrors(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"unknown column": "identifier,colour\nx,red\n",
+		"duplicate":      "identifier,owner,owner\n",
+		"no identifier":  "title,owner\n",
+	}
+	for name, input := range tests {
+		if _, err := parseCSVImport(testSchema(), strings.NewReader(input)); !errors.Is(err, ErrInvalidImport) {
+			t.Errorf("%s: err = %v, want ErrInvalidImport", name, err)
+		}
+	}
+}
+
+func TestParseNDJSONImport(t *testing.T) {
+	input := `{"identifier":"a","title":"A","data":{"owner":"a@example.com"},"id":"ignored"}
+
+not json
+{"identifier":"b"}
+`
+	rows, err := parseNDJSONImport(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	if rows[0].identifier != "a" || rows[0].data["owner"] != "a@example.com" {
+		t.Errorf("line 1 = %+v", rows[0])
+	}
+	if rows[1].err == nil || rows[1].row != 3 {
+		t.Errorf("line 3 = %d, %v, want JSON error", rows[1].row, rows[1].err)
+	}
+	if rows[2].data == nil {
+		t.Error("missing data should become an empty object")
+	}
+
+	if _, err := parseNDJSONImport(strings.NewReader("\n\n")); !errors.Is(err, ErrInvalidImport) {
+		t.Errorf("blank file: err = %v, want ErrInvalidImport", err)
+	}
+}
+
+func TestMergeData_MergesNestedObjects(t *testing.T) {
+	current := map[string]interface{}{
+		"owner":    "old@example.com",
+		"metadata": map[string]interface{}{"tier": float64(1), "region": "eu"},
+	}
+	merged := mergeData(current, map[string]interface{}{
+		"owner":    "new@example.com",
+		"metadata": map[string]interface{}{"tier": float64(2)},
+	})
+
+	want := map[string]interface{}{
+		"owner":    "new@example.com",
+		"metadata": map[string]interface{}{"tier": float64(2), "region": "eu"},
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged = %#v, want %#v", merged, want)
+	}
+	if current["metadata"].(map[string]interface{})["tier"] != float64(1) {
+		t.Error("mergeData modified its input")
+	}
+}
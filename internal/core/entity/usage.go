@@ -0,0 +1,261 @@
+package entity
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+)
+
+// Property usage kinds, see migrations/009_property_usage.sql
+const (
+	UsageFilter    = "filter"
+	UsageSort      = "sort"
+	UsageGroup     = "group"
+	UsageAggregate = "aggregate"
+	UsageColumn    = "column"
+)
+
+const (
+	// usageFlushInterval is how often recorded usage is written to property_usage
+	usageFlushInterval = time.Minute
+
+	// usageIndexThreshold is the number of filters and sorts over the report
+	// window above which an unindexed property is suggested for indexing
+	usageIndexThreshold = 100
+)
+
+// Usage hints
+const (
+	HintIndex       = "consider_index"
+	HintDropIndex   = "consider_dropping_index"
+	HintUnused      = "unused"
+	HintNotInSchema = "not_in_schema"
+)
+
+// UsageRow is one usage counter. Day is the day the uses were recorded on,
+// or the last day of use in reports.
+type UsageRow struct {
+	TeamID      uuid.UUID
+	BlueprintID string
+	Property    string
+	Usage       string
+	Day         time.Time
+	Count       int64
+}
+
+type usageKey struct {
+	teamID      uuid.UUID
+	blueprintID string
+	property    string
+	usage       string
+}
+
+// UsageRecorder counts how searches, aggregates and saved views use blueprint
+// properties. Counts are kept in memory and added to the daily counters in
+// property_usage every minute, so recording never waits for the database. A
+// nil *UsageRecorder records nothing.
+type UsageRecorder struct {
+	repo      *Repository
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	counts map[usageKey]int64
+}
+
+func NewUsageRecorder(repo *Repository, retentionDays int) *UsageRecorder {
+	return &UsageRecorder{
+		repo:      repo,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		now:       time.Now,
+		counts:    make(map[usageKey]int64),
+	}
+}
+
+// Record counts one use of each property. Entity columns such as title are
+// not blueprint properties and are skipped.
+func (u *UsageRecorder) Record(teamID uuid.UUID, blueprintID, usage string, properties ...string) {
+	if u == nil {
+		return
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, p := range properties {
+		if p == "" || sortColumns[p] {
+			continue
+		}
+		u.counts[usageKey{teamID, blueprintID, p, usage}]++
+	}
+}
+
+// RecordSearch counts the filtered and sorted properties of a search
+func (u *UsageRecorder) RecordSearch(teamID uuid.UUID, blueprintID string, filters []SearchFilter, orderBy string) {
+	if u == nil {
+		return
+	}
+	for _, f := range filterLeaves(filters) {
+		u.Record(teamID, blueprintID, UsageFilter, f.Property)
+	}
+	u.Record(teamID, blueprintID, UsageSort, orderBy)
+}
+
+// Run writes recorded usage every minute and prunes expired counters every
+// day, until ctx is done. Usage recorded since the last write is written
+// before it returns.
+func (u *UsageRecorder) Run(ctx context.Context) {
+	flush := time.NewTicker(usageFlushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(24 * time.Hour)
+	defer prune.Stop()
+
+	u.prune(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			// The request context is gone; give the final write its own
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := u.flush(final); err != nil {
+				log.Printf("ERROR: property usage write failed: %v", err)
+			}
+			cancel()
+			return
+		case <-flush.C:
+			if err := u.flush(ctx); err != nil {
+				log.Printf("ERROR: property usage write failed: %v", err)
+			}
+		case <-prune.C:
+			u.prune(ctx)
+		}
+	}
+}
+
+// flush writes the recorded counts. On failure they are kept for the next
+// attempt.
+func (u *UsageRecorder) flush(ctx context.Context) error {
+	u.mu.Lock()
+	counts := u.counts
+	u.counts = make(map[usageKey]int64)
+	u.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	day := u.now().UTC().Truncate(24 * time.Hour)
+	rows := make([]UsageRow, 0, len(counts))
+	for k, n := range counts {
+		rows = append(rows, UsageRow{
+			TeamID:      k.teamID,
+			BlueprintID: k.blueprintID,
+			Property:    k.property,
+			Usage:       k.usage,
+			Day:         day,
+			Count:       n,
+		})
+	}
+	if err := u.repo.AddPropertyUsage(ctx, rows); err != nil {
+		u.mu.Lock()
+		for k, n := range counts {
+			u.counts[k] += n
+		}
+		u.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (u *UsageRecorder) prune(ctx context.Context) {
+	if err := u.repo.PrunePropertyUsage(ctx, u.now().Add(-u.retention)); err != nil {
+		log.Printf("ERROR: property usage pruning failed: %v", err)
+	}
+}
+
+// buildUsageReport lists every schema property and every property still in
+// use, most used first, with a hint on indexing or deprecation
+func buildUsageReport(schema map[string]interface{}, rows []UsageRow) []*PropertyUsage {
+	indexed := make(map[string]bool)
+	for _, p := range blueprint.IndexedProperties(schema) {
+		indexed[p] = true
+	}
+
+	byProperty := make(map[string]*PropertyUsage)
+	var paths []string
+	collectPropertyPaths(schema, nil, &paths)
+	for _, p := range paths {
+		byProperty[p] = &PropertyUsage{Property: p, InSchema: true, Indexed: indexed[p]}
+	}
+	for _, row := range rows {
+		pu, ok := byProperty[row.Property]
+		if !ok {
+			pu = &PropertyUsage{Property: row.Property}
+			byProperty[row.Property] = pu
+		}
+		switch row.Usage {
+		case UsageFilter:
+			pu.Filter += row.Count
+		case UsageSort:
+			pu.Sort += row.Count
+		case UsageGroup:
+			pu.Group += row.Count
+		case UsageAggregate:
+			pu.Aggregate += row.Count
+		case UsageColumn:
+			pu.Column += row.Count
+		}
+		pu.Total += row.Count
+		if day := row.Day; pu.LastUsed == nil || day.After(*pu.LastUsed) {
+			pu.LastUsed = &day
+		}
+	}
+
+	report := make([]*PropertyUsage, 0, len(byProperty))
+	for _, pu := range byProperty {
+		pu.Hint = usageHint(pu)
+		report = append(report, pu)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Total != report[j].Total {
+			return report[i].Total > report[j].Total
+		}
+		return report[i].Property < report[j].Property
+	})
+	return report
+}
+
+func usageHint(pu *PropertyUsage) string {
+	switch {
+	case !pu.InSchema:
+		return HintNotInSchema
+	case pu.Total == 0:
+		return HintUnused
+	case !pu.Indexed && pu.Filter+pu.Sort >= usageIndexThreshold:
+		return HintIndex
+	case pu.Indexed && pu.Filter+pu.Sort == 0:
+		return HintDropIndex
+	}
+	return ""
+}
+
+// collectPropertyPaths lists the dot-separated paths of a schema's scalar and
+// array properties; objects are descended into
+func collectPropertyPaths(schema map[string]interface{}, prefix []string, paths *[]string) {
+	props, _ := schema["properties"].(map[string]interface{})
+	for name, raw := range props {
+		prop, ok := raw.(map[string]interface{})
+		if !ok || !propertySegment.MatchString(name) {
+			continue
+		}
+		path := append(append([]string{}, prefix...), name)
+		if schemaType(prop) == "object" {
+			collectPropertyPaths(prop, path, paths)
+			continue
+		}
+		*paths = append(*paths, strings.Join(path, "."))
+	}
+}
@@ -0,0 +1,84 @@
+package entity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestUsageRecorder_Record(t *testing.T) {
+	u := NewUsageRecorder(nil, 90)
+	team := uuid.New()
+
+	u.RecordSearch(team, "service", []SearchFilter{{Property: "lifecycle"}, {Property: "lifecycle"}}, "title")
+	u.Record(team, "service", UsageSort, "metadata.tier")
+	u.Record(team, "service", UsageGroup, "")
+
+	if n := u.counts[usageKey{team, "service", "lifecycle", UsageFilter}]; n != 2 {
+		t.Errorf("lifecycle filters = %d, want 2", n)
+	}
+	if n := u.counts[usageKey{team, "service", "metadata.tier", UsageSort}]; n != 1 {
+		t.Errorf("metadata.tier sorts = %d, want 1", n)
+	}
+	// title is an entity column and empty names are no use
+	if len(u.counts) != 2 {
+		t.Errorf("counted %d keys, want 2: %v", len(u.counts), u.counts)
+	}
+
+	var none *UsageRecorder
+	none.Record(team, "service", UsageFilter, "lifecycle")
+}
+
+func TestBuildUsageReport(t *testing.T) {
+	schema := map[string]interface{}{
+		"properties": map[string]interface{}{
+			"lifecycle": map[string]interface{}{"type": "string", "indexed": true},
+			"owner":     map[string]interface{}{"type": "string"},
+			"name":      map[string]interface{}{"type": "string", "indexed": true},
+			"language":  map[string]interface{}{"type": "string"},
+			"metadata": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"tier": map[string]interface{}{"type": "integer"},
+				},
+			},
+		},
+	}
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	rows := []UsageRow{
+		{Property: "owner", Usage: UsageFilter, Count: 80, Day: day},
+		{Property: "owner", Usage: UsageSort, Count: 40, Day: day.AddDate(0, 0, -1)},
+		{Property: "lifecycle", Usage: UsageFilter, Count: 10, Day: day},
+		{Property: "name", Usage: UsageColumn, Count: 5, Day: day},
+		{Property: "language", Usage: UsageGroup, Count: 5, Day: day},
+		{Property: "removed", Usage: UsageFilter, Count: 1, Day: day},
+	}
+
+	report := buildUsageReport(schema, rows)
+
+	want := []struct {
+		property string
+		total    int64
+		hint     string
+	}{
+		{"owner", 120, HintIndex},
+		{"lifecycle", 10, ""},
+		{"language", 5, ""},
+		{"name", 5, HintDropIndex},
+		{"removed", 1, HintNotInSchema},
+		{"metadata.tier", 0, HintUnused},
+	}
+	if len(report) != len(want) {
+		t.Fatalf("got %d properties, want %d", len(report), len(want))
+	}
+	for i, w := range want {
+		got := report[i]
+		if got.Property != w.property || got.Total != w.total || got.Hint != w.hint {
+			t.Errorf("report[%d] = %s total %d hint %q, want %s total %d hint %q", i, got.Property, got.Total, got.Hint, w.property, w.total, w.hint)
+		}
+	}
+	if owner := report[0]; owner.Filter != 80 || owner.Sort != 40 || !owner.LastUsed.Equal(day) {
+		t.Errorf("owner = %+v", owner)
+	}
+}
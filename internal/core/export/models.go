@@ -0,0 +1,83 @@
+package export
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+var (
+	ErrNotFound = errors.New("export not found")
+	ErrNotReady = errors.New("export is not finished")
+	ErrFailed   = errors.New("export failed")
+	ErrExpired  = errors.New("export has expired")
+)
+
+// Export statuses. A pending export waits for its job; a failed attempt
+// keeps it running until the job runs out of attempts and it fails.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Export is an entity export run in the background. Its file can be
+// downloaded once it succeeded, until it expires.
+type Export struct {
+	ID          uuid.UUID            `json:"id"`
+	TeamID      uuid.UUID            `json:"team_id"`
+	BlueprintID string               `json:"blueprint_id"`
+	Format      string               `json:"format"`
+	Request     entity.ExportRequest `json:"request"`
+	Status      string               `json:"status"`
+	EntityCount int                  `json:"entity_count"`
+	SizeBytes   int64                `json:"size_bytes"`
+	Error       string               `json:"error,omitempty"`
+	RequestedBy *uuid.UUID           `json:"requested_by,omitempty"`
+	CreatedAt   time.Time            `json:"created_at"`
+	StartedAt   *time.Time           `json:"started_at,omitempty"`
+	FinishedAt  *time.Time           `json:"finished_at,omitempty"`
+	// ExpiresAt is when the export and its file are deleted, set once it
+	// finished
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ArtifactKey locates the file in the artifact store
+	ArtifactKey string `json:"-"`
+}
+
+// Filename is the name the file is downloaded as
+func (e *Export) Filename() string {
+	return e.BlueprintID + "." + e.Format
+}
+
+// ContentType is the media type of the file
+func (e *Export) ContentType() string {
+	if e.Format == entity.FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+type ListRequest struct {
+	BlueprintID string
+	Limit       int
+	Offset      int
+}
+
+type ListResponse struct {
+	Exports []*Export `json:"exports"`
+	Total   int       `json:"total"`
+}
+
+// Download is how to fetch the file of an export: a short-lived URL to
+// redirect to, or the file itself when the store has no such URLs
+type Download struct {
+	Export *Export
+	URL    string
+	// Body is the file when URL is empty; the caller closes it
+	Body io.ReadCloser
+}
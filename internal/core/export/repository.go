@@ -0,0 +1,156 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const exportColumns = `
+	id, team_id, blueprint_id, format, request, status, entity_count, size_bytes,
+	COALESCE(artifact_key, ''), COALESCE(error, ''), requested_by, created_at, started_at, finished_at, expires_at`
+
+func (r *Repository) Create(ctx context.Context, e *Export) error {
+	request, err := json.Marshal(e.Request)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO entity_exports (id, team_id, blueprint_id, format, request, status, requested_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING created_at`
+	return r.db.DB.QueryRowContext(ctx, query,
+		e.ID, e.TeamID, e.BlueprintID, e.Format, request, e.Status, e.RequestedBy,
+	).Scan(&e.CreatedAt)
+}
+
+// Get returns an export of the team, or nil when there is none
+func (r *Repository) Get(ctx context.Context, teamID, id uuid.UUID) (*Export, error) {
+	return r.get(ctx, `WHERE id = $1 AND team_id = $2`, id, teamID)
+}
+
+// GetByID returns an export of any team, or nil when there is none
+func (r *Repository) GetByID(ctx context.Context, id uuid.UUID) (*Export, error) {
+	return r.get(ctx, `WHERE id = $1`, id)
+}
+
+func (r *Repository) get(ctx context.Context, where string, args ...interface{}) (*Export, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT `+exportColumns+` FROM entity_exports `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	exports, err := scanExports(rows)
+	if err != nil || len(exports) == 0 {
+		return nil, err
+	}
+	return exports[0], nil
+}
+
+func (r *Repository) List(ctx context.Context, teamID uuid.UUID, req *ListRequest) ([]*Export, int, error) {
+	where := ` WHERE team_id = $1`
+	args := []interface{}{teamID}
+	if req.BlueprintID != "" {
+		args = append(args, req.BlueprintID)
+		where += fmt.Sprintf(" AND blueprint_id = $%d", len(args))
+	}
+
+	var total int
+	if err := r.db.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM entity_exports`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, req.Limit, req.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM entity_exports%s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		exportColumns, where, len(args)-1, len(args))
+	rows, err := r.db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	exports, err := scanExports(rows)
+	return exports, total, err
+}
+
+// Start marks an export running. It returns false when the export is gone
+// or already finished, so a job run twice does not redo it.
+func (r *Repository) Start(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE entity_exports SET status = $2, started_at = NOW()
+		WHERE id = $1 AND status IN ($3, $2)`
+	result, err := r.db.DB.ExecContext(ctx, query, id, StatusRunning, StatusPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Succeed records the file of a finished export
+func (r *Repository) Succeed(ctx context.Context, id uuid.UUID, key string, entityCount int, size int64, expiresAt time.Time) error {
+	query := `
+		UPDATE entity_exports
+		SET status = $2, artifact_key = $3, entity_count = $4, size_bytes = $5, error = NULL,
+			finished_at = NOW(), expires_at = $6
+		WHERE id = $1`
+	_, err := r.db.DB.ExecContext(ctx, query, id, StatusSucceeded, key, entityCount, size, expiresAt)
+	return err
+}
+
+// Fail records why an export failed; the record is kept until expiresAt
+func (r *Repository) Fail(ctx context.Context, id uuid.UUID, message string, expiresAt time.Time) error {
+	query := `UPDATE entity_exports SET status = $2, error = $3, finished_at = NOW(), expires_at = $4 WHERE id = $1`
+	_, err := r.db.DB.ExecContext(ctx, query, id, StatusFailed, message, expiresAt)
+	return err
+}
+
+// Expired returns up to limit finished exports that expired before now,
+// oldest first
+func (r *Repository) Expired(ctx context.Context, now time.Time, limit int) ([]*Export, error) {
+	query := `SELECT ` + exportColumns + ` FROM entity_exports WHERE expires_at < $1 ORDER BY expires_at LIMIT $2`
+	rows, err := r.db.DB.QueryContext(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanExports(rows)
+}
+
+// Delete removes the record of an export
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM entity_exports WHERE id = $1`, id)
+	return err
+}
+
+func scanExports(rows *sql.Rows) ([]*Export, error) {
+	var exports []*Export
+	for rows.Next() {
+		e := &Export{}
+		var request []byte
+		if err := rows.Scan(&e.ID, &e.TeamID, &e.BlueprintID, &e.Format, &request, &e.Status, &e.EntityCount, &e.SizeBytes,
+			&e.ArtifactKey, &e.Error, &e.RequestedBy, &e.CreatedAt, &e.StartedAt, &e.FinishedAt, &e.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(request, &e.Request); err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
@@ -0,0 +1,260 @@
+// Package export runs entity exports too large to stream in a response as
+// background jobs. Each job writes the export to a file in the artifact
+// store, which clients download until it expires.
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/artifacts"
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+const (
+	// JobRunExport is the background job that writes the file of one export
+	JobRunExport = "entity.export"
+
+	// maxAttempts bounds the attempts of an export; each one starts over
+	maxAttempts = 3
+
+	// downloadURLTTL is how long a download URL is valid. Clients ask for a
+	// new one on each download, so it only needs to outlast the redirect.
+	downloadURLTTL = 15 * time.Minute
+
+	// expiredBatch is how many expired exports are deleted per query
+	expiredBatch = 100
+
+	// writeBufferSize buffers the file an export is written to
+	writeBufferSize = 1 << 20
+)
+
+// runExportPayload is the payload of JobRunExport jobs
+type runExportPayload struct {
+	ExportID uuid.UUID `json:"export_id"`
+}
+
+type Service struct {
+	repo     *Repository
+	entities *entity.Service
+	store    artifacts.Store
+	queue    *jobs.Queue
+	cfg      config.ExportsConfig
+	now      func() time.Time
+}
+
+// NewService creates the exports service and registers its job with queue
+func NewService(repo *Repository, entities *entity.Service, store artifacts.Store, queue *jobs.Queue, cfg config.ExportsConfig) *Service {
+	s := &Service{repo: repo, entities: entities, store: store, queue: queue, cfg: cfg, now: time.Now}
+	queue.RegisterLong(JobRunExport, s.run)
+	return s
+}
+
+// Start prepares an export of the entities of a blueprint matching req.
+// Exports of up to the async threshold are returned to be streamed by the
+// caller; larger ones, or any when async is set, are queued and returned as
+// a pending Export.
+func (s *Service) Start(ctx context.Context, teamID uuid.UUID, blueprintID string, req *entity.ExportRequest, async bool, requestedBy *uuid.UUID) (*entity.PreparedExport, *Export, error) {
+	prepared, err := s.entities.PrepareExport(ctx, teamID, blueprintID, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !async && s.cfg.AsyncThreshold > 0 {
+		count, err := prepared.CountUpTo(ctx, s.cfg.AsyncThreshold+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		async = count > s.cfg.AsyncThreshold
+	}
+	if !async {
+		return prepared, nil, nil
+	}
+
+	e := &Export{
+		ID:          uuid.New(),
+		TeamID:      teamID,
+		BlueprintID: blueprintID,
+		Format:      req.Format,
+		Request:     *req,
+		Status:      StatusPending,
+		RequestedBy: requestedBy,
+	}
+	if err := s.repo.Create(ctx, e); err != nil {
+		return nil, nil, err
+	}
+	if _, err := s.queue.Enqueue(ctx, JobRunExport, runExportPayload{ExportID: e.ID}, &jobs.EnqueueOptions{MaxAttempts: maxAttempts}); err != nil {
+		if failErr := s.repo.Fail(ctx, e.ID, "could not be queued", s.now().Add(s.cfg.Expiry())); failErr != nil {
+			log.Printf("ERROR: export: %v", failErr)
+		}
+		return nil, nil, err
+	}
+	return nil, e, nil
+}
+
+func (s *Service) Get(ctx context.Context, teamID, id uuid.UUID) (*Export, error) {
+	e, err := s.repo.Get(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return nil, ErrNotFound
+	}
+	return e, nil
+}
+
+func (s *Service) List(ctx context.Context, teamID uuid.UUID, req *ListRequest) (*ListResponse, error) {
+	exports, total, err := s.repo.List(ctx, teamID, req)
+	if err != nil {
+		return nil, err
+	}
+	if exports == nil {
+		exports = []*Export{}
+	}
+	return &ListResponse{Exports: exports, Total: total}, nil
+}
+
+// Download returns how to fetch the file of a succeeded export
+func (s *Service) Download(ctx context.Context, teamID, id uuid.UUID) (*Download, error) {
+	e, err := s.Get(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	switch e.Status {
+	case StatusPending, StatusRunning:
+		return nil, ErrNotReady
+	case StatusFailed:
+		return nil, fmt.Errorf("%w: %s", ErrFailed, e.Error)
+	}
+	remaining := e.ExpiresAt.Sub(s.now())
+	if remaining <= 0 {
+		return nil, ErrExpired
+	}
+
+	url, err := s.store.DownloadURL(e.ArtifactKey, e.Filename(), min(remaining, downloadURLTTL))
+	if err != nil {
+		return nil, err
+	}
+	if url != "" {
+		return &Download{Export: e, URL: url}, nil
+	}
+	body, err := s.store.Open(ctx, e.ArtifactKey)
+	if errors.Is(err, artifacts.ErrNotFound) {
+		return nil, ErrExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &Download{Export: e, Body: body}, nil
+}
+
+// DeleteExpired deletes the exports past their expiry with their files and
+// returns how many it deleted
+func (s *Service) DeleteExpired(ctx context.Context) (int, error) {
+	deleted := 0
+	for {
+		expired, err := s.repo.Expired(ctx, s.now(), expiredBatch)
+		if err != nil {
+			return deleted, err
+		}
+		for _, e := range expired {
+			if e.ArtifactKey != "" {
+				if err := s.store.Delete(ctx, e.ArtifactKey); err != nil {
+					return deleted, err
+				}
+			}
+			if err := s.repo.Delete(ctx, e.ID); err != nil {
+				return deleted, err
+			}
+			deleted++
+		}
+		if len(expired) < expiredBatch {
+			return deleted, nil
+		}
+	}
+}
+
+// run writes the file of an export. Every attempt starts over; a failure is
+// recorded on the export once retrying cannot help.
+func (s *Service) run(ctx context.Context, job *jobs.Job) error {
+	var payload runExportPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	e, err := s.repo.GetByID(ctx, payload.ExportID)
+	if err != nil {
+		return err
+	}
+	if e == nil {
+		// Deleted with its team
+		return nil
+	}
+	started, err := s.repo.Start(ctx, e.ID)
+	if err != nil || !started {
+		return err
+	}
+
+	err = s.write(ctx, e)
+	if err == nil {
+		return nil
+	}
+	permanent := errors.Is(err, entity.ErrBlueprintNotFound) || errors.Is(err, entity.ErrInvalidFilter) ||
+		errors.Is(err, entity.ErrUnsupportedFormat)
+	if permanent || job.Attempts >= job.MaxAttempts {
+		log.Printf("ERROR: export: %s of blueprint %s failed: %v", e.ID, e.BlueprintID, err)
+		if failErr := s.repo.Fail(context.WithoutCancel(ctx), e.ID, err.Error(), s.now().Add(s.cfg.Expiry())); failErr != nil {
+			return failErr
+		}
+	}
+	if permanent {
+		return jobs.Permanent(err)
+	}
+	return err
+}
+
+// write streams an export to a temporary file, then stores the file
+func (s *Service) write(ctx context.Context, e *Export) error {
+	prepared, err := s.entities.PrepareExport(ctx, e.TeamID, e.BlueprintID, &e.Request)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "baseplate-export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	buffered := bufio.NewWriterSize(file, writeBufferSize)
+	count, err := prepared.Stream(ctx, buffered)
+	if err != nil {
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("exports/%s/%s.%s", e.TeamID, e.ID, e.Format)
+	if err := s.store.Put(ctx, key, file, size, e.ContentType()); err != nil {
+		return err
+	}
+	return s.repo.Succeed(context.WithoutCancel(ctx), e.ID, key, count, size, s.now().Add(s.cfg.Expiry()))
+}
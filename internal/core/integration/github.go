@@ -0,0 +1,373 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/integration/github"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+// TypeGitHub integrations sync the repositories of a GitHub App
+// installation into entities of a blueprint
+const TypeGitHub = "github"
+
+const (
+	// JobGitHubSync syncs every repository of a GitHub integration
+	JobGitHubSync = "integration.github_sync"
+	// JobGitHubRepository syncs one repository a webhook told about
+	JobGitHubRepository = "integration.github_repository"
+)
+
+var (
+	ErrNotGitHub        = errors.New("not a GitHub integration")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrInvalidWebhook   = errors.New("invalid webhook")
+)
+
+// githubFields are the repository fields written to entities; each goes to
+// the property of the same name unless the config's properties map it to
+// another, or to "" to leave it out
+var githubFields = []string{
+	"name", "description", "url", "language", "topics", "default_branch",
+	"visibility", "archived", "last_commit", "owners",
+}
+
+// GitHubConfig is the configuration of a GitHub integration with its
+// secrets resolved
+type GitHubConfig struct {
+	Token         string
+	WebhookSecret string
+	BlueprintID   string
+	APIURL        string
+	// Properties maps repository fields to entity properties
+	Properties map[string]string
+}
+
+// repositoryJob is the payload of JobGitHubRepository
+type repositoryJob struct {
+	syncJob
+	Repository  string `json:"repository"`
+	RenamedFrom string `json:"renamed_from,omitempty"`
+	Removed     bool   `json:"removed,omitempty"`
+}
+
+// validateGitHubConfig checks the configuration of a new GitHub
+// integration. The token and webhook secret must be secret references.
+func validateGitHubConfig(config map[string]interface{}) error {
+	if !secret.IsReference(config["token"]) {
+		return fmt.Errorf(`%w: token must be a secret reference, {"$secret": "<name>"}`, ErrInvalidConfig)
+	}
+	if v, ok := config["webhook_secret"]; ok && !secret.IsReference(v) {
+		return fmt.Errorf(`%w: webhook_secret must be a secret reference, {"$secret": "<name>"}`, ErrInvalidConfig)
+	}
+	_, err := parseGitHubConfig(config)
+	return err
+}
+
+// parseGitHubConfig reads a GitHub configuration; the token and webhook
+// secret are read only once resolved
+func parseGitHubConfig(config map[string]interface{}) (*GitHubConfig, error) {
+	cfg := &GitHubConfig{Properties: make(map[string]string, len(githubFields))}
+	cfg.Token, _ = config["token"].(string)
+	cfg.WebhookSecret, _ = config["webhook_secret"].(string)
+
+	cfg.BlueprintID, _ = config["blueprint_id"].(string)
+	if cfg.BlueprintID == "" {
+		return nil, fmt.Errorf("%w: blueprint_id is required", ErrInvalidConfig)
+	}
+	if raw, ok := config["api_url"]; ok {
+		cfg.APIURL, _ = raw.(string)
+		u, err := url.Parse(cfg.APIURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("%w: api_url must be an https URL", ErrInvalidConfig)
+		}
+	}
+
+	for _, field := range githubFields {
+		cfg.Properties[field] = field
+	}
+	if raw, ok := config["properties"]; ok {
+		properties, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: properties must map repository fields to property names", ErrInvalidConfig)
+		}
+		for field, v := range properties {
+			if !slices.Contains(githubFields, field) {
+				return nil, fmt.Errorf("%w: unknown repository field %q, must be one of %s", ErrInvalidConfig, field, strings.Join(githubFields, ", "))
+			}
+			property, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%w: property of %q must be a string", ErrInvalidConfig, field)
+			}
+			cfg.Properties[field] = property
+		}
+	}
+	return cfg, nil
+}
+
+// githubConfig returns the resolved configuration of a GitHub integration
+func (s *Service) githubConfig(ctx context.Context, in *Integration) (*GitHubConfig, error) {
+	if in.Type != TypeGitHub {
+		return nil, ErrNotGitHub
+	}
+	config, err := s.cachedConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return parseGitHubConfig(config)
+}
+
+// GitHubWebhook verifies a webhook delivery of a GitHub integration and
+// queues a sync of each repository it changed. It returns how many it queued.
+func (s *Service) GitHubWebhook(ctx context.Context, id uuid.UUID, event, signature string, body []byte) (int, error) {
+	in, err := s.repo.Find(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	if in == nil {
+		return 0, ErrNotFound
+	}
+	cfg, err := s.githubConfig(ctx, in)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.WebhookSecret == "" || !github.VerifySignature(cfg.WebhookSecret, body, signature) {
+		return 0, ErrInvalidSignature
+	}
+
+	changes, err := github.Changes(event, body)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	for _, change := range changes {
+		payload := repositoryJob{
+			syncJob:     syncJob{IntegrationID: in.ID, TeamID: in.TeamID},
+			Repository:  change.Repository,
+			RenamedFrom: change.RenamedFrom,
+			Removed:     change.Removed,
+		}
+		if _, err := s.queue.Enqueue(ctx, JobGitHubRepository, payload, nil); err != nil {
+			return 0, err
+		}
+	}
+	return len(changes), nil
+}
+
+// loadGitHubJob returns the integration of a GitHub job with its resolved
+// configuration, see loadSyncJob
+func (s *Service) loadGitHubJob(ctx context.Context, job *jobs.Job, payload interface{}) (context.Context, *Integration, *GitHubConfig, error) {
+	ctx, in, err := s.loadSyncJob(ctx, job, payload)
+	if err != nil || in == nil {
+		return nil, nil, nil, err
+	}
+	cfg, err := s.githubConfig(ctx, in)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return ctx, in, cfg, nil
+}
+
+// syncGitHub writes every repository of the installation to the
+// integration's blueprint, and deletes the entities of the integration
+// whose repository is gone
+func (s *Service) syncGitHub(ctx context.Context, job *jobs.Job) error {
+	var payload syncJob
+	ctx, in, cfg, err := s.loadGitHubJob(ctx, job, &payload)
+	if err != nil || in == nil {
+		return err
+	}
+	client := github.NewClient(cfg.APIURL, cfg.Token, s.http)
+	repos, err := client.InstallationRepositories(ctx)
+	if err != nil {
+		return err
+	}
+
+	rows := make([]*entity.CreateEntityRequest, 0, len(repos))
+	identifiers := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		row, err := s.repositoryEntity(ctx, client, cfg, repo)
+		if err != nil {
+			return fmt.Errorf("%s: %w", repo.FullName, err)
+		}
+		rows = append(rows, row)
+		identifiers = append(identifiers, row.Identifier)
+	}
+	result, err := s.writeEntities(ctx, in, cfg.BlueprintID, rows)
+	if err != nil {
+		return err
+	}
+	// No repositories at all is more likely a revoked token than an
+	// installation without any, so nothing is deleted then
+	if _, err := s.Reconcile(ctx, in.TeamID, in.ID, &entity.ReconcileRequest{
+		BlueprintID: cfg.BlueprintID,
+		Identifiers: identifiers,
+		Delete:      len(identifiers) > 0,
+	}); err != nil {
+		return err
+	}
+	return importFailure(in, cfg.BlueprintID, result)
+}
+
+// syncGitHubRepository applies the change of one repository
+func (s *Service) syncGitHubRepository(ctx context.Context, job *jobs.Job) error {
+	var payload repositoryJob
+	ctx, in, cfg, err := s.loadGitHubJob(ctx, job, &payload)
+	if err != nil || in == nil {
+		return err
+	}
+	if payload.Removed {
+		return s.removeRepository(ctx, in, cfg, github.Name(payload.Repository))
+	}
+
+	client := github.NewClient(cfg.APIURL, cfg.Token, s.http)
+	repo, err := client.Repository(ctx, payload.Repository)
+	if errors.Is(err, github.ErrNotFound) {
+		return s.removeRepository(ctx, in, cfg, github.Name(payload.Repository))
+	}
+	if err != nil {
+		return err
+	}
+	if payload.RenamedFrom != "" {
+		if err := s.renameRepository(ctx, in, cfg, github.Name(payload.RenamedFrom), repo.Name); err != nil {
+			return err
+		}
+	}
+	row, err := s.repositoryEntity(ctx, client, cfg, repo)
+	if err != nil {
+		return err
+	}
+	result, err := s.writeEntities(ctx, in, cfg.BlueprintID, []*entity.CreateEntityRequest{row})
+	if err != nil {
+		return err
+	}
+	if err := importFailure(in, cfg.BlueprintID, result); err != nil {
+		return err
+	}
+	// Claims the entity and records the sync; nothing else is deleted
+	_, err = s.Reconcile(ctx, in.TeamID, in.ID, &entity.ReconcileRequest{
+		BlueprintID: cfg.BlueprintID,
+		Identifiers: []string{row.Identifier},
+	})
+	return err
+}
+
+// ownedEntity returns the integration's entity of a repository, or nil
+func (s *Service) ownedEntity(ctx context.Context, in *Integration, cfg *GitHubConfig, name string) (*entity.Entity, error) {
+	e, err := s.entitySvc.GetByIdentifier(ctx, in.TeamID, cfg.BlueprintID, name)
+	if errors.Is(err, entity.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Aliases of renamed entities are not the repository's
+	if e.Identifier != name || e.IntegrationID == nil || *e.IntegrationID != in.ID {
+		return nil, nil
+	}
+	return e, nil
+}
+
+// removeRepository deletes the entity of a repository that is gone, when
+// the integration owns it
+func (s *Service) removeRepository(ctx context.Context, in *Integration, cfg *GitHubConfig, name string) error {
+	e, err := s.ownedEntity(ctx, in, cfg, name)
+	if err != nil || e == nil {
+		return err
+	}
+	if err := s.entitySvc.Delete(ctx, e.ID); err != nil && !errors.Is(err, entity.ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// renameRepository renames the entity of a renamed repository, keeping its
+// history and relations. When the blueprint's identifiers are immutable, or
+// the new name is taken, the old entity is deleted instead.
+func (s *Service) renameRepository(ctx context.Context, in *Integration, cfg *GitHubConfig, from, to string) error {
+	e, err := s.ownedEntity(ctx, in, cfg, from)
+	if err != nil || e == nil {
+		return err
+	}
+	_, err = s.entitySvc.Rename(ctx, e.ID, &entity.RenameEntityRequest{Identifier: to}, 0)
+	if errors.Is(err, entity.ErrIdentifierImmutable) || errors.Is(err, entity.ErrAlreadyExists) {
+		return s.removeRepository(ctx, in, cfg, from)
+	}
+	return err
+}
+
+// repositoryEntity reads what the entity of a repository holds. The head
+// commit and CODEOWNERS are read only when their fields are written.
+func (s *Service) repositoryEntity(ctx context.Context, client *github.Client, cfg *GitHubConfig, repo *github.Repository) (*entity.CreateEntityRequest, error) {
+	var commit *github.Commit
+	var owners []string
+	if cfg.Properties["last_commit"] != "" && repo.DefaultBranch != "" {
+		var err error
+		if commit, err = client.HeadCommit(ctx, repo.FullName, repo.DefaultBranch); err != nil && !errors.Is(err, github.ErrNotFound) {
+			return nil, err
+		}
+	}
+	if cfg.Properties["owners"] != "" && repo.DefaultBranch != "" {
+		codeOwners, err := client.CodeOwners(ctx, repo.FullName, repo.DefaultBranch)
+		if err != nil {
+			return nil, err
+		}
+		owners = github.TeamOwners(codeOwners)
+	}
+	return &entity.CreateEntityRequest{
+		Identifier: repo.Name,
+		Title:      repo.Name,
+		Data:       repositoryData(cfg.Properties, repo, commit, owners),
+	}, nil
+}
+
+// repositoryData maps the fields of a repository to entity properties.
+// Empty descriptions and languages are left out rather than written as
+// null, which typed properties would reject.
+func repositoryData(properties map[string]string, repo *github.Repository, commit *github.Commit, owners []string) map[string]interface{} {
+	data := map[string]interface{}{}
+	set := func(field string, v interface{}) {
+		if property := properties[field]; property != "" {
+			data[property] = v
+		}
+	}
+	set("name", repo.Name)
+	if repo.Description != "" {
+		set("description", repo.Description)
+	}
+	set("url", repo.HTMLURL)
+	if repo.Language != "" {
+		set("language", repo.Language)
+	}
+	topics := repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+	set("topics", topics)
+	set("default_branch", repo.DefaultBranch)
+	if repo.Visibility != "" {
+		set("visibility", repo.Visibility)
+	}
+	set("archived", repo.Archived)
+	if commit != nil {
+		set("last_commit", map[string]interface{}{
+			"sha":          commit.SHA,
+			"message":      commit.Message,
+			"author":       commit.Author,
+			"committed_at": commit.CommittedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	if owners != nil {
+		set("owners", owners)
+	}
+	return data
+}
@@ -0,0 +1,219 @@
+// Package github reads repositories from the GitHub REST API and verifies
+// and decodes the webhooks GitHub sends about them, for the GitHub
+// integration.
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is the API of github.com; GitHub Enterprise Server serves it
+// under https://<host>/api/v3
+const DefaultAPIURL = "https://api.github.com"
+
+// ErrNotFound is returned for repositories, commits and files GitHub does
+// not have, or does not show to the token
+var ErrNotFound = errors.New("not found on GitHub")
+
+// codeOwnersPaths are where GitHub looks for CODEOWNERS, in its order
+var codeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+// Repository is the part of a GitHub repository the integration records
+type Repository struct {
+	Name          string    `json:"name"`
+	FullName      string    `json:"full_name"`
+	Description   string    `json:"description"`
+	HTMLURL       string    `json:"html_url"`
+	Language      string    `json:"language"`
+	Topics        []string  `json:"topics"`
+	DefaultBranch string    `json:"default_branch"`
+	Visibility    string    `json:"visibility"`
+	Archived      bool      `json:"archived"`
+	PushedAt      time.Time `json:"pushed_at"`
+}
+
+// Commit is the head commit of a branch
+type Commit struct {
+	SHA         string    `json:"sha"`
+	Message     string    `json:"message"`
+	Author      string    `json:"author"`
+	CommittedAt time.Time `json:"committed_at"`
+}
+
+// Client calls the GitHub REST API with an installation token, or any token
+// GitHub accepts as a bearer token
+type Client struct {
+	apiURL string
+	token  string
+	http   *http.Client
+}
+
+// NewClient returns a client of the API at apiURL, DefaultAPIURL when empty
+func NewClient(apiURL, token string, httpClient *http.Client) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Client{apiURL: strings.TrimSuffix(apiURL, "/"), token: token, http: httpClient}
+}
+
+// InstallationRepositories returns every repository the installation token
+// can access
+func (c *Client) InstallationRepositories(ctx context.Context) ([]*Repository, error) {
+	var repos []*Repository
+	next := c.apiURL + "/installation/repositories?per_page=100"
+	for next != "" {
+		var page struct {
+			Repositories []*Repository `json:"repositories"`
+		}
+		resp, err := c.get(ctx, next, "application/vnd.github+json", &page)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, page.Repositories...)
+		next = nextPage(resp.Header.Get("Link"))
+	}
+	return repos, nil
+}
+
+// Repository returns a repository by its full name, owner/name
+func (c *Client) Repository(ctx context.Context, fullName string) (*Repository, error) {
+	repo := &Repository{}
+	if _, err := c.get(ctx, c.apiURL+"/repos/"+repoPath(fullName), "application/vnd.github+json", repo); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// HeadCommit returns the last commit of a branch, or nil for an empty
+// repository
+func (c *Client) HeadCommit(ctx context.Context, fullName, branch string) (*Commit, error) {
+	var commit struct {
+		SHA    string `json:"sha"`
+		Commit struct {
+			Message string `json:"message"`
+			Author  struct {
+				Name string `json:"name"`
+			} `json:"author"`
+			Committer struct {
+				Date time.Time `json:"date"`
+			} `json:"committer"`
+		} `json:"commit"`
+	}
+	u := c.apiURL + "/repos/" + repoPath(fullName) + "/commits/" + url.PathEscape(branch)
+	_, err := c.get(ctx, u, "application/vnd.github+json", &commit)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Status == http.StatusConflict {
+		// GitHub answers 409 for a repository without commits
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	message, _, _ := strings.Cut(commit.Commit.Message, "\n")
+	return &Commit{
+		SHA:         commit.SHA,
+		Message:     message,
+		Author:      commit.Commit.Author.Name,
+		CommittedAt: commit.Commit.Committer.Date,
+	}, nil
+}
+
+// CodeOwners returns the CODEOWNERS file of a branch from the first place
+// GitHub looks for it, or "" when there is none
+func (c *Client) CodeOwners(ctx context.Context, fullName, branch string) (string, error) {
+	for _, path := range codeOwnersPaths {
+		u := c.apiURL + "/repos/" + repoPath(fullName) + "/contents/" + path + "?ref=" + url.QueryEscape(branch)
+		var raw rawBody
+		_, err := c.get(ctx, u, "application/vnd.github.raw+json", &raw)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(raw), nil
+	}
+	return "", nil
+}
+
+// StatusError is a GitHub API response that is not a success
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("GitHub API returned %d", e.Status)
+	}
+	return fmt.Sprintf("GitHub API returned %d: %s", e.Status, e.Message)
+}
+
+// maxResponseBytes bounds the responses read, CODEOWNERS files included
+const maxResponseBytes = 10 << 20
+
+// rawBody receives a response body as is
+type rawBody []byte
+
+func (c *Client) get(ctx context.Context, u, accept string, out interface{}) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		return nil, &StatusError{Status: resp.StatusCode, Message: apiErr.Message}
+	}
+	if raw, ok := out.(*rawBody); ok {
+		*raw = body
+		return resp, nil
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return nil, fmt.Errorf("decoding GitHub response: %w", err)
+	}
+	return resp, nil
+}
+
+// repoPath escapes the owner and name of a full repository name
+func repoPath(fullName string) string {
+	owner, name, _ := strings.Cut(fullName, "/")
+	return url.PathEscape(owner) + "/" + url.PathEscape(name)
+}
+
+var linkNext = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextPage returns the URL of the next page from a Link header, or ""
+func nextPage(link string) string {
+	if m := linkNext.FindStringSubmatch(link); m != nil {
+		return m[1]
+	}
+	return ""
+}
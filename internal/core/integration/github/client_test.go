@@ -0,0 +1,94 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_InstallationRepositories(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer tok" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.URL.Query().Get("page") == "2" {
+			fmt.Fprint(w, `{"repositories":[{"name":"web","full_name":"acme/web"}]}`)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/installation/repositories?per_page=100&page=2>; rel="next", <%s/installation/repositories?per_page=100&page=2>; rel="last"`, server.URL, server.URL))
+		fmt.Fprint(w, `{"repositories":[{"name":"api","full_name":"acme/api","topics":["go"],"default_branch":"main"}]}`)
+	}))
+	defer server.Close()
+
+	repos, err := NewClient(server.URL, "tok", server.Client()).InstallationRepositories(context.Background())
+	if err != nil {
+		t.Fatalf("InstallationRepositories: %v", err)
+	}
+	if len(repos) != 2 || repos[0].FullName != "acme/api" || repos[1].FullName != "acme/web" {
+		t.Fatalf("repositories = %+v, want acme/api and acme/web", repos)
+	}
+	if len(repos[0].Topics) != 1 || repos[0].DefaultBranch != "main" {
+		t.Errorf("repository = %+v", repos[0])
+	}
+}
+
+func TestClient_HeadCommit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/empty/commits/main":
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, `{"message":"Git Repository is empty."}`)
+		case "/repos/acme/api/commits/main":
+			fmt.Fprint(w, `{"sha":"abc123","commit":{"message":"Fix login\n\nLonger body","author":{"name":"Ada"},"committer":{"date":"2026-10-01T12:00:00Z"}}}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "tok", server.Client())
+
+	commit, err := client.HeadCommit(context.Background(), "acme/api", "main")
+	if err != nil {
+		t.Fatalf("HeadCommit: %v", err)
+	}
+	if commit.SHA != "abc123" || commit.Message != "Fix login" || commit.Author != "Ada" || commit.CommittedAt.IsZero() {
+		t.Errorf("commit = %+v", commit)
+	}
+
+	if commit, err := client.HeadCommit(context.Background(), "acme/empty", "main"); err != nil || commit != nil {
+		t.Errorf("HeadCommit of an empty repository = %+v, %v; want nil, nil", commit, err)
+	}
+
+	var statusErr *StatusError
+	if _, err := client.HeadCommit(context.Background(), "acme/broken", "main"); !errors.As(err, &statusErr) || statusErr.Status != http.StatusInternalServerError {
+		t.Errorf("HeadCommit error = %v, want a 500 StatusError", err)
+	}
+}
+
+func TestClient_CodeOwners(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/acme/api/contents/CODEOWNERS" && r.URL.Query().Get("ref") == "main" {
+			fmt.Fprint(w, "* @acme/platform\n")
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	client := NewClient(server.URL, "tok", server.Client())
+
+	// .github/CODEOWNERS is missing, so the root file is read
+	got, err := client.CodeOwners(context.Background(), "acme/api", "main")
+	if err != nil || got != "* @acme/platform\n" {
+		t.Errorf("CodeOwners = %q, %v", got, err)
+	}
+	if got, err := client.CodeOwners(context.Background(), "acme/web", "main"); err != nil || got != "" {
+		t.Errorf("CodeOwners without a file = %q, %v; want empty", got, err)
+	}
+	if _, err := client.Repository(context.Background(), "acme/gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Repository error = %v, want ErrNotFound", err)
+	}
+}
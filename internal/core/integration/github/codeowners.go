@@ -0,0 +1,36 @@
+package github
+
+import (
+	"bufio"
+	"slices"
+	"strings"
+)
+
+// catchAllPatterns are CODEOWNERS patterns matching every file of a
+// repository
+var catchAllPatterns = []string{"*", "/*", "/", "**", "/**"}
+
+// TeamOwners returns the teams owning a repository by its CODEOWNERS file:
+// the teams of the last rule matching every file, such as "* @acme/platform".
+// Teams are returned as org/team; users and email owners are left out, as
+// are rules for parts of the repository.
+func TeamOwners(codeOwners string) []string {
+	owners := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(codeOwners))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !slices.Contains(catchAllPatterns, fields[0]) {
+			continue
+		}
+		// A later matching rule overrides the earlier ones, even without owners
+		owners = []string{}
+		for _, owner := range fields[1:] {
+			team, ok := strings.CutPrefix(owner, "@")
+			if ok && strings.Contains(team, "/") && !slices.Contains(owners, team) {
+				owners = append(owners, team)
+			}
+		}
+	}
+	return owners
+}
@@ -0,0 +1,28 @@
+package github
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTeamOwners(t *testing.T) {
+	tests := []struct {
+		name       string
+		codeOwners string
+		want       []string
+	}{
+		{"none", "", []string{}},
+		{"catch-all teams", "* @acme/platform @acme/sre @octocat\n", []string{"acme/platform", "acme/sre"}},
+		{"last catch-all wins", "* @acme/old\n/docs/ @acme/writers\n/** @acme/new # since the split\n", []string{"acme/new"}},
+		{"later rule without owners", "* @acme/platform\n*\n", []string{}},
+		{"only partial rules", "/api/ @acme/api\n*.go @acme/go\n", []string{}},
+		{"comments and emails", "# * @acme/commented\n* dev@acme.com @acme/platform @acme/platform\n", []string{"acme/platform"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TeamOwners(tt.codeOwners); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TeamOwners = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Change is a repository to read again, or one that is gone, as told by a
+// webhook
+type Change struct {
+	// Repository is the full name, owner/name
+	Repository string
+	Removed    bool
+	// RenamedFrom is the full name the repository had before a rename
+	RenamedFrom string
+}
+
+// Name returns a full repository name without its owner
+func Name(fullName string) string {
+	_, name, _ := strings.Cut(fullName, "/")
+	return name
+}
+
+// VerifySignature reports whether the X-Hub-Signature-256 header of a
+// webhook is the HMAC of its body with the webhook secret
+func VerifySignature(secret string, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+type webhookRepository struct {
+	FullName      string `json:"full_name"`
+	DefaultBranch string `json:"default_branch"`
+}
+
+// Changes returns the repository changes of a webhook, by its X-GitHub-Event
+// type. Pushes matter only to a default branch; events that change no
+// repository, such as ping, return none.
+func Changes(event string, body []byte) ([]Change, error) {
+	var payload struct {
+		Action     string            `json:"action"`
+		Ref        string            `json:"ref"`
+		Repository webhookRepository `json:"repository"`
+		Changes    struct {
+			Repository struct {
+				Name struct {
+					From string `json:"from"`
+				} `json:"name"`
+			} `json:"repository"`
+		} `json:"changes"`
+		Added   []webhookRepository `json:"repositories_added"`
+		Removed []webhookRepository `json:"repositories_removed"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", event, err)
+	}
+
+	repo := payload.Repository
+	switch event {
+	case "push":
+		if repo.FullName != "" && payload.Ref == "refs/heads/"+repo.DefaultBranch {
+			return []Change{{Repository: repo.FullName}}, nil
+		}
+	case "repository":
+		switch payload.Action {
+		case "deleted", "transferred":
+			return []Change{{Repository: repo.FullName, Removed: true}}, nil
+		case "renamed":
+			owner, _, _ := strings.Cut(repo.FullName, "/")
+			return []Change{{Repository: repo.FullName, RenamedFrom: owner + "/" + payload.Changes.Repository.Name.From}}, nil
+		default:
+			return []Change{{Repository: repo.FullName}}, nil
+		}
+	case "installation_repositories":
+		var changes []Change
+		for _, r := range payload.Added {
+			changes = append(changes, Change{Repository: r.FullName})
+		}
+		for _, r := range payload.Removed {
+			changes = append(changes, Change{Repository: r.FullName, Removed: true})
+		}
+		return changes, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,89 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"testing"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"zen":"Keep it logically awesome."}`)
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !VerifySignature("s3cr3t", body, signature) {
+		t.Error("valid signature rejected")
+	}
+	for name, header := range map[string]string{
+		"other secret": "sha256=" + hex.EncodeToString(hmac.New(sha256.New, []byte("other")).Sum(nil)),
+		"no prefix":    signature[len("sha256="):],
+		"not hex":      "sha256=zz",
+		"missing":      "",
+	} {
+		if VerifySignature("s3cr3t", body, header) {
+			t.Errorf("%s: signature accepted", name)
+		}
+	}
+	if VerifySignature("s3cr3t", []byte(`{"zen":"changed"}`), signature) {
+		t.Error("signature of another body accepted")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	tests := []struct {
+		name  string
+		event string
+		body  string
+		want  []Change
+	}{
+		{
+			"push to default branch", "push",
+			`{"ref":"refs/heads/main","repository":{"full_name":"acme/api","default_branch":"main"}}`,
+			[]Change{{Repository: "acme/api"}},
+		},
+		{
+			"push to another branch", "push",
+			`{"ref":"refs/heads/feature","repository":{"full_name":"acme/api","default_branch":"main"}}`,
+			nil,
+		},
+		{
+			"renamed", "repository",
+			`{"action":"renamed","repository":{"full_name":"acme/gateway"},"changes":{"repository":{"name":{"from":"api"}}}}`,
+			[]Change{{Repository: "acme/gateway", RenamedFrom: "acme/api"}},
+		},
+		{
+			"deleted", "repository",
+			`{"action":"deleted","repository":{"full_name":"acme/api"}}`,
+			[]Change{{Repository: "acme/api", Removed: true}},
+		},
+		{
+			"edited", "repository",
+			`{"action":"edited","repository":{"full_name":"acme/api"}}`,
+			[]Change{{Repository: "acme/api"}},
+		},
+		{
+			"installation repositories", "installation_repositories",
+			`{"action":"added","repositories_added":[{"full_name":"acme/web"}],"repositories_removed":[{"full_name":"acme/old"}]}`,
+			[]Change{{Repository: "acme/web"}, {Repository: "acme/old", Removed: true}},
+		},
+		{"ping", "ping", `{"zen":"Design for failure."}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Changes(tt.event, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("Changes: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Changes = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Changes("push", []byte("not json")); err == nil {
+		t.Error("invalid payload accepted")
+	}
+}
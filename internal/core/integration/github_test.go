@@ -0,0 +1,90 @@
+package integration
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/integration/github"
+)
+
+func TestValidateGitHubConfig(t *testing.T) {
+	token := map[string]interface{}{"$secret": "github-token"}
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"token": token, "blueprint_id": "repository"}, false},
+		{"full", map[string]interface{}{
+			"token":          token,
+			"webhook_secret": map[string]interface{}{"$secret": "github-webhook"},
+			"blueprint_id":   "repository",
+			"api_url":        "https://github.acme.com/api/v3",
+			"properties":     map[string]interface{}{"language": "lang", "owners": ""},
+		}, false},
+		{"literal token", map[string]interface{}{"token": "ghs_abc", "blueprint_id": "repository"}, true},
+		{"literal webhook secret", map[string]interface{}{"token": token, "webhook_secret": "x", "blueprint_id": "repository"}, true},
+		{"no blueprint", map[string]interface{}{"token": token}, true},
+		{"plain http api", map[string]interface{}{"token": token, "blueprint_id": "repository", "api_url": "http://github.acme.com"}, true},
+		{"unknown field", map[string]interface{}{"token": token, "blueprint_id": "repository", "properties": map[string]interface{}{"stars": "stars"}}, true},
+		{"property not a string", map[string]interface{}{"token": token, "blueprint_id": "repository", "properties": map[string]interface{}{"name": true}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateGitHubConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateGitHubConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestRepositoryData(t *testing.T) {
+	cfg, err := parseGitHubConfig(map[string]interface{}{
+		"blueprint_id": "repository",
+		"properties":   map[string]interface{}{"language": "lang", "visibility": ""},
+	})
+	if err != nil {
+		t.Fatalf("parseGitHubConfig: %v", err)
+	}
+	repo := &github.Repository{
+		Name:          "api",
+		FullName:      "acme/api",
+		HTMLURL:       "https://github.com/acme/api",
+		Language:      "Go",
+		DefaultBranch: "main",
+		Visibility:    "private",
+	}
+	commit := &github.Commit{SHA: "abc123", Message: "Fix login", Author: "Ada", CommittedAt: time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)}
+
+	got := repositoryData(cfg.Properties, repo, commit, []string{"acme/platform"})
+	want := map[string]interface{}{
+		"name":           "api",
+		"url":            "https://github.com/acme/api",
+		"lang":           "Go",
+		"topics":         []string{},
+		"default_branch": "main",
+		"archived":       false,
+		"last_commit": map[string]interface{}{
+			"sha": "abc123", "message": "Fix login", "author": "Ada", "committed_at": "2026-10-01T12:00:00Z",
+		},
+		"owners": []string{"acme/platform"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("repositoryData = %v, want %v", got, want)
+	}
+
+	// Without a commit or CODEOWNERS read, those properties are left alone
+	got = repositoryData(cfg.Properties, repo, nil, nil)
+	if _, ok := got["last_commit"]; ok {
+		t.Error("last_commit written without a commit")
+	}
+	if _, ok := got["owners"]; ok {
+		t.Error("owners written without CODEOWNERS")
+	}
+}
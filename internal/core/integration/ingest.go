@@ -0,0 +1,161 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/integration/mapping"
+	"github.com/baseplate/baseplate/internal/core/secret"
+)
+
+// TypeIngest integrations receive JSON from external systems and write it to
+// entities of a blueprint through a mapping template
+const TypeIngest = "ingest"
+
+// maxIngestItems bounds the items of a payload, as many as an import takes
+const maxIngestItems = 10000
+
+var (
+	ErrNotIngest    = errors.New("not an ingest integration")
+	ErrInvalidToken = errors.New("invalid ingest token")
+)
+
+// IngestConfig is the configuration of an ingest integration with its token
+// resolved
+type IngestConfig struct {
+	Token       string
+	BlueprintID string
+	Mapping     *mapping.Mapping
+}
+
+// validateIngestConfig checks the configuration of a new ingest integration.
+// The token must be a secret reference.
+func validateIngestConfig(config map[string]interface{}) error {
+	if !secret.IsReference(config["token"]) {
+		return fmt.Errorf(`%w: token must be a secret reference, {"$secret": "<name>"}`, ErrInvalidConfig)
+	}
+	_, err := parseIngestConfig(config)
+	return err
+}
+
+// parseIngestConfig reads an ingest configuration; the token is read only
+// once resolved
+func parseIngestConfig(config map[string]interface{}) (*IngestConfig, error) {
+	cfg := &IngestConfig{}
+	cfg.Token, _ = config["token"].(string)
+	cfg.BlueprintID, _ = config["blueprint_id"].(string)
+	if cfg.BlueprintID == "" {
+		return nil, fmt.Errorf("%w: blueprint_id is required", ErrInvalidConfig)
+	}
+	template, ok := config["mapping"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: mapping is required", ErrInvalidConfig)
+	}
+	var err error
+	if cfg.Mapping, err = mapping.Parse(template); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	return cfg, nil
+}
+
+// Ingest maps a JSON payload pushed to an ingest integration into entities
+// and upserts them as the integration. The token must be the integration's.
+// Rows of the result are the items of the payload, counted from 1; items the
+// mapping cannot turn into an entity fail on their own.
+func (s *Service) Ingest(ctx context.Context, id uuid.UUID, token string, body []byte, dryRun bool) (*entity.ImportResult, error) {
+	in, err := s.repo.Find(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if in == nil {
+		return nil, ErrNotFound
+	}
+	if in.Type != TypeIngest {
+		return nil, ErrNotIngest
+	}
+	config, err := s.cachedConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parseIngestConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Token == "" || subtle.ConstantTimeCompare([]byte(cfg.Token), []byte(token)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var payload interface{}
+	if err := decoder.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("%w: body must be JSON: %v", ErrInvalidWebhook, err)
+	}
+	items, err := cfg.Mapping.Items(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	if len(items) > maxIngestItems {
+		return nil, fmt.Errorf("%w: %d items, at most %d are taken at once", ErrInvalidWebhook, len(items), maxIngestItems)
+	}
+
+	result := &entity.ImportResult{DryRun: dryRun, Mode: entity.ImportUpsert, Total: len(items), Errors: []entity.ImportRowError{}}
+	var file bytes.Buffer
+	encoder := json.NewEncoder(&file)
+	// itemRows maps the rows of the import to the items they came from
+	var itemRows []int
+	for i, item := range items {
+		e, err := cfg.Mapping.Entity(item)
+		if err != nil {
+			result.Failed++
+			result.Errors = append(result.Errors, entity.ImportRowError{Row: i + 1, Error: err.Error()})
+			continue
+		}
+		if err := encoder.Encode(&entity.CreateEntityRequest{Identifier: e.Identifier, Title: e.Title, Data: e.Data}); err != nil {
+			return nil, err
+		}
+		itemRows = append(itemRows, i+1)
+	}
+	if len(itemRows) == 0 {
+		return result, nil
+	}
+
+	ctx, err = s.entitySvc.AsIntegration(ctx, in.TeamID, in.ID)
+	if err != nil {
+		return nil, err
+	}
+	imported, err := s.entitySvc.Import(ctx, in.TeamID, cfg.BlueprintID, entity.FormatNDJSON, &file, entity.ImportOptions{Mode: entity.ImportUpsert, DryRun: dryRun})
+	if err != nil {
+		return nil, err
+	}
+	result.Created, result.Updated, result.Unchanged = imported.Created, imported.Updated, imported.Unchanged
+	result.Failed += imported.Failed
+	for _, rowErr := range imported.Errors {
+		if rowErr.Row >= 1 && rowErr.Row <= len(itemRows) {
+			rowErr.Row = itemRows[rowErr.Row-1]
+		}
+		result.Errors = append(result.Errors, rowErr)
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	for _, change := range imported.Changes {
+		if change.Row >= 1 && change.Row <= len(itemRows) {
+			change.Row = itemRows[change.Row-1]
+		}
+		result.Changes = append(result.Changes, change)
+	}
+
+	if !dryRun {
+		if err := s.repo.MarkSynced(ctx, in); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,34 @@
+package integration
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateIngestConfig(t *testing.T) {
+	token := map[string]interface{}{"$secret": "deploys-token"}
+	mapping := map[string]interface{}{"identifier": "{{.service}}-{{.id}}", "properties": map[string]interface{}{"version": ".version"}}
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{"valid", map[string]interface{}{"token": token, "blueprint_id": "deployment", "mapping": mapping}, false},
+		{"literal token", map[string]interface{}{"token": "abc", "blueprint_id": "deployment", "mapping": mapping}, true},
+		{"no token", map[string]interface{}{"blueprint_id": "deployment", "mapping": mapping}, true},
+		{"no blueprint", map[string]interface{}{"token": token, "mapping": mapping}, true},
+		{"no mapping", map[string]interface{}{"token": token, "blueprint_id": "deployment"}, true},
+		{"bad mapping", map[string]interface{}{"token": token, "blueprint_id": "deployment", "mapping": map[string]interface{}{"identifier": "{{id}}"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateIngestConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateIngestConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package integration
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/integration/kubernetes"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+// TypeKubernetes integrations sync the namespaces, deployments and services
+// of a cluster into entities
+const TypeKubernetes = "kubernetes"
+
+// JobKubernetesSync syncs a Kubernetes integration's cluster
+const JobKubernetesSync = "integration.kubernetes_sync"
+
+// kubernetesTimeout bounds each request to a cluster's API
+const kubernetesTimeout = 30 * time.Second
+
+// Kinds of Kubernetes objects, in the order they are synced
+const (
+	KindNamespace  = "namespace"
+	KindDeployment = "deployment"
+	KindService    = "service"
+)
+
+var kubernetesKinds = []string{KindNamespace, KindDeployment, KindService}
+
+// clusterName is a DNS label, which keeps entity identifiers unambiguous
+var clusterName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+// KubernetesConfig is the configuration of a Kubernetes integration
+type KubernetesConfig struct {
+	// Cluster names the cluster in entity identifiers
+	Cluster string
+	// Credentials are set once the config's secrets are resolved
+	Credentials *kubernetes.Credentials
+	// Namespaces limits the sync to these namespaces, all when empty
+	Namespaces []string
+	// Blueprints maps the kinds synced to their blueprint
+	Blueprints map[string]string
+	// LabelsProperty receives each object's labels, "" for none
+	LabelsProperty string
+	// LabelProperties copies single labels to properties
+	LabelProperties map[string]string
+}
+
+// validateKubernetesConfig checks the configuration of a new Kubernetes
+// integration. The kubeconfig or token must be a secret reference.
+func validateKubernetesConfig(config map[string]interface{}) error {
+	if _, ok := config["kubeconfig"]; ok {
+		if !secret.IsReference(config["kubeconfig"]) {
+			return fmt.Errorf(`%w: kubeconfig must be a secret reference, {"$secret": "<name>"}`, ErrInvalidConfig)
+		}
+		if _, ok := config["server"]; ok {
+			return fmt.Errorf("%w: give either kubeconfig or server and token", ErrInvalidConfig)
+		}
+	} else if !secret.IsReference(config["token"]) {
+		return fmt.Errorf(`%w: kubeconfig, or server with token, is required; token must be a secret reference, {"$secret": "<name>"}`, ErrInvalidConfig)
+	}
+	_, err := parseKubernetesConfig(config, false)
+	return err
+}
+
+// parseKubernetesConfig reads a Kubernetes configuration, and its
+// credentials when resolved is set
+func parseKubernetesConfig(config map[string]interface{}, resolved bool) (*KubernetesConfig, error) {
+	cfg := &KubernetesConfig{
+		Blueprints:      map[string]string{},
+		LabelsProperty:  "labels",
+		LabelProperties: map[string]string{},
+	}
+	cfg.Cluster, _ = config["cluster"].(string)
+	if !clusterName.MatchString(cfg.Cluster) {
+		return nil, fmt.Errorf("%w: cluster is required, a lowercase DNS label such as prod-eu-1", ErrInvalidConfig)
+	}
+
+	blueprints, _ := config["blueprints"].(map[string]interface{})
+	for kind, v := range blueprints {
+		if !slices.Contains(kubernetesKinds, kind) {
+			return nil, fmt.Errorf("%w: unknown kind %q in blueprints, must be one of %s", ErrInvalidConfig, kind, strings.Join(kubernetesKinds, ", "))
+		}
+		blueprintID, _ := v.(string)
+		if blueprintID == "" {
+			return nil, fmt.Errorf("%w: blueprint of %q must be a blueprint ID", ErrInvalidConfig, kind)
+		}
+		for other, id := range cfg.Blueprints {
+			if id == blueprintID {
+				return nil, fmt.Errorf("%w: %s and %s must go to different blueprints", ErrInvalidConfig, other, kind)
+			}
+		}
+		cfg.Blueprints[kind] = blueprintID
+	}
+	if len(cfg.Blueprints) == 0 {
+		return nil, fmt.Errorf("%w: blueprints must map at least one of %s to a blueprint", ErrInvalidConfig, strings.Join(kubernetesKinds, ", "))
+	}
+
+	if raw, ok := config["namespaces"]; ok {
+		namespaces, _ := raw.([]interface{})
+		for _, v := range namespaces {
+			namespace, _ := v.(string)
+			if namespace == "" {
+				return nil, fmt.Errorf("%w: namespaces must be a list of namespace names", ErrInvalidConfig)
+			}
+			cfg.Namespaces = append(cfg.Namespaces, namespace)
+		}
+	}
+	if raw, ok := config["labels"]; ok {
+		if cfg.LabelsProperty, ok = raw.(string); !ok {
+			return nil, fmt.Errorf("%w: labels must be a property name, or empty", ErrInvalidConfig)
+		}
+	}
+	if raw, ok := config["label_properties"]; ok {
+		properties, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: label_properties must map labels to property names", ErrInvalidConfig)
+		}
+		for label, v := range properties {
+			property, _ := v.(string)
+			if property == "" {
+				return nil, fmt.Errorf("%w: property of label %q must be a property name", ErrInvalidConfig, label)
+			}
+			cfg.LabelProperties[label] = property
+		}
+	}
+
+	server, _ := config["server"].(string)
+	if _, ok := config["kubeconfig"]; !ok && !strings.HasPrefix(server, "https://") {
+		return nil, fmt.Errorf("%w: server must be the https URL of the cluster's API", ErrInvalidConfig)
+	}
+	caData, _ := config["ca_data"].(string)
+	ca, err := base64.StdEncoding.DecodeString(caData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ca_data must be the base64 of the cluster's CA certificate", ErrInvalidConfig)
+	}
+	if !resolved {
+		return cfg, nil
+	}
+
+	if kubeconfig, ok := config["kubeconfig"].(string); ok {
+		kubeContext, _ := config["context"].(string)
+		if cfg.Credentials, err = kubernetes.ParseKubeconfig([]byte(kubeconfig), kubeContext); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		return cfg, nil
+	}
+	token, _ := config["token"].(string)
+	cfg.Credentials = &kubernetes.Credentials{Server: server, Token: token}
+	if len(ca) > 0 {
+		cfg.Credentials.CAData = ca
+	}
+	return cfg, nil
+}
+
+// kubernetesConfig returns the resolved configuration of a Kubernetes
+// integration
+func (s *Service) kubernetesConfig(ctx context.Context, in *Integration) (*KubernetesConfig, error) {
+	config, err := s.cachedConfig(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	return parseKubernetesConfig(config, true)
+}
+
+// syncKubernetes writes the cluster's namespaces, deployments and services
+// to their blueprints and deletes the integration's entities of objects that
+// are gone. A cluster that cannot be read fails the job before anything is
+// deleted.
+func (s *Service) syncKubernetes(ctx context.Context, job *jobs.Job) error {
+	var payload syncJob
+	ctx, in, err := s.loadSyncJob(ctx, job, &payload)
+	if err != nil || in == nil {
+		return err
+	}
+	cfg, err := s.kubernetesConfig(ctx, in)
+	if errors.Is(err, ErrInvalidConfig) {
+		return jobs.Permanent(err)
+	}
+	if err != nil {
+		return err
+	}
+	client, err := kubernetes.NewClient(cfg.Credentials, kubernetesTimeout)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	rows, err := readCluster(ctx, client, cfg)
+	if err != nil {
+		return err
+	}
+	var failure error
+	for _, kind := range kubernetesKinds {
+		blueprintID, ok := cfg.Blueprints[kind]
+		if !ok {
+			continue
+		}
+		// The cluster was read in full, so an empty kind really has no
+		// objects left
+		kindFailure, err := s.replaceEntities(ctx, in, blueprintID, rows[kind])
+		if err != nil {
+			return err
+		}
+		if failure == nil {
+			failure = kindFailure
+		}
+	}
+	return failure
+}
+
+// readCluster returns the entities of the kinds the configuration syncs
+func readCluster(ctx context.Context, client *kubernetes.Client, cfg *KubernetesConfig) (map[string][]*entity.CreateEntityRequest, error) {
+	rows := map[string][]*entity.CreateEntityRequest{}
+	namespaces := cfg.Namespaces
+	if len(namespaces) == 0 {
+		// "" lists every namespace at once
+		namespaces = []string{""}
+	}
+
+	if _, ok := cfg.Blueprints[KindNamespace]; ok {
+		var all []*kubernetes.Namespace
+		if len(cfg.Namespaces) == 0 {
+			var err error
+			if all, err = client.Namespaces(ctx); err != nil {
+				return nil, fmt.Errorf("listing namespaces: %w", err)
+			}
+		}
+		for _, name := range cfg.Namespaces {
+			ns, err := client.Namespace(ctx, name)
+			if errors.Is(err, kubernetes.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("reading namespace %s: %w", name, err)
+			}
+			all = append(all, ns)
+		}
+		for _, ns := range all {
+			data := objectData(cfg, ns.Metadata)
+			data["phase"] = ns.Status.Phase
+			rows[KindNamespace] = append(rows[KindNamespace], objectEntity(cfg, ns.Metadata, data))
+		}
+	}
+
+	for _, namespace := range namespaces {
+		if _, ok := cfg.Blueprints[KindDeployment]; ok {
+			deployments, err := client.Deployments(ctx, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("listing deployments: %w", err)
+			}
+			for _, d := range deployments {
+				rows[KindDeployment] = append(rows[KindDeployment], objectEntity(cfg, d.Metadata, deploymentData(cfg, d)))
+			}
+		}
+		if _, ok := cfg.Blueprints[KindService]; ok {
+			services, err := client.Services(ctx, namespace)
+			if err != nil {
+				return nil, fmt.Errorf("listing services: %w", err)
+			}
+			for _, svc := range services {
+				rows[KindService] = append(rows[KindService], objectEntity(cfg, svc.Metadata, serviceData(cfg, svc)))
+			}
+		}
+	}
+	return rows, nil
+}
+
+// objectEntity returns the entity of an object. Identifiers are
+// cluster.name for namespaces and cluster.namespace.name for the rest.
+func objectEntity(cfg *KubernetesConfig, meta kubernetes.Metadata, data map[string]interface{}) *entity.CreateEntityRequest {
+	identifier := cfg.Cluster + "." + meta.Name
+	if meta.Namespace != "" {
+		identifier = cfg.Cluster + "." + meta.Namespace + "." + meta.Name
+	}
+	return &entity.CreateEntityRequest{Identifier: identifier, Title: meta.Name, Data: data}
+}
+
+// objectData returns the properties every kind has: the cluster, the
+// namespace of namespaced objects, the creation time and the labels
+func objectData(cfg *KubernetesConfig, meta kubernetes.Metadata) map[string]interface{} {
+	data := map[string]interface{}{
+		"cluster":    cfg.Cluster,
+		"created_at": meta.CreationTimestamp.UTC().Format(time.RFC3339),
+	}
+	if meta.Namespace != "" {
+		data["namespace"] = meta.Namespace
+	}
+	if cfg.LabelsProperty != "" {
+		labels := map[string]interface{}{}
+		for k, v := range meta.Labels {
+			labels[k] = v
+		}
+		data[cfg.LabelsProperty] = labels
+	}
+	for label, property := range cfg.LabelProperties {
+		if v, ok := meta.Labels[label]; ok {
+			data[property] = v
+		}
+	}
+	return data
+}
+
+func deploymentData(cfg *KubernetesConfig, d *kubernetes.Deployment) map[string]interface{} {
+	data := objectData(cfg, d.Metadata)
+	// Kubernetes defaults an unset replica count to 1
+	replicas := 1
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	data["replicas"] = replicas
+	data["ready_replicas"] = d.Status.ReadyReplicas
+	data["available_replicas"] = d.Status.AvailableReplicas
+	data["images"] = d.Images()
+	return data
+}
+
+func serviceData(cfg *KubernetesConfig, svc *kubernetes.Service) map[string]interface{} {
+	data := objectData(cfg, svc.Metadata)
+	data["type"] = svc.Spec.Type
+	if svc.Spec.ClusterIP != "" {
+		data["cluster_ip"] = svc.Spec.ClusterIP
+	}
+	data["ports"] = svc.Ports()
+	selector := map[string]interface{}{}
+	for k, v := range svc.Spec.Selector {
+		selector[k] = v
+	}
+	data["selector"] = selector
+	return data
+}
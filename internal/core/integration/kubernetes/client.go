@@ -0,0 +1,282 @@
+// Package kubernetes reads namespaces, deployments and services from the
+// Kubernetes API for the Kubernetes integration.
+package kubernetes
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for namespaces the cluster does not have
+var ErrNotFound = errors.New("not found in the cluster")
+
+// pageSize is the limit of each list request; the API server returns a
+// continue token for the rest
+const pageSize = 500
+
+// Metadata is the part of an object's metadata the integration records
+type Metadata struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace"`
+	Labels            map[string]string `json:"labels"`
+	CreationTimestamp time.Time         `json:"creationTimestamp"`
+}
+
+// Namespace is a cluster namespace
+type Namespace struct {
+	Metadata Metadata `json:"metadata"`
+	Status   struct {
+		Phase string `json:"phase"`
+	} `json:"status"`
+}
+
+// Deployment is an apps/v1 Deployment
+type Deployment struct {
+	Metadata Metadata `json:"metadata"`
+	Spec     struct {
+		Replicas *int `json:"replicas"`
+		Template struct {
+			Spec struct {
+				Containers []struct {
+					Image string `json:"image"`
+				} `json:"containers"`
+			} `json:"spec"`
+		} `json:"template"`
+	} `json:"spec"`
+	Status struct {
+		ReadyReplicas     int `json:"readyReplicas"`
+		AvailableReplicas int `json:"availableReplicas"`
+	} `json:"status"`
+}
+
+// Images returns the images of the deployment's containers
+func (d *Deployment) Images() []string {
+	images := make([]string, 0, len(d.Spec.Template.Spec.Containers))
+	for _, c := range d.Spec.Template.Spec.Containers {
+		images = append(images, c.Image)
+	}
+	return images
+}
+
+// Service is a core/v1 Service
+type Service struct {
+	Metadata Metadata `json:"metadata"`
+	Spec     struct {
+		Type      string            `json:"type"`
+		ClusterIP string            `json:"clusterIP"`
+		Selector  map[string]string `json:"selector"`
+		Ports     []struct {
+			Port     int    `json:"port"`
+			Protocol string `json:"protocol"`
+		} `json:"ports"`
+	} `json:"spec"`
+}
+
+// Ports returns the service's ports as port/protocol, such as 80/TCP
+func (s *Service) Ports() []string {
+	ports := make([]string, 0, len(s.Spec.Ports))
+	for _, p := range s.Spec.Ports {
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = "TCP"
+		}
+		ports = append(ports, strconv.Itoa(p.Port)+"/"+protocol)
+	}
+	return ports
+}
+
+// Client calls the Kubernetes API of one cluster
+type Client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+// NewClient returns a client of the cluster the credentials are for
+func NewClient(creds *Credentials, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(creds.Server)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("%w: the server must be an https URL", ErrUnsupportedKubeconfig)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: creds.ServerName,
+	}
+	if creds.CAData != nil {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(creds.CAData) {
+			return nil, fmt.Errorf("%w: the CA data holds no PEM certificate", ErrUnsupportedKubeconfig)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if creds.ClientCert != nil {
+		cert, err := tls.X509KeyPair(creds.ClientCert, creds.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("%w: client certificate: %v", ErrUnsupportedKubeconfig, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &Client{
+		server: strings.TrimSuffix(creds.Server, "/"),
+		token:  creds.Token,
+		http:   &http.Client{Timeout: timeout, Transport: transport},
+	}, nil
+}
+
+// Namespaces returns every namespace of the cluster
+func (c *Client) Namespaces(ctx context.Context) ([]*Namespace, error) {
+	var namespaces []*Namespace
+	err := c.list(ctx, "/api/v1/namespaces", func(items json.RawMessage) error {
+		var page []*Namespace
+		err := json.Unmarshal(items, &page)
+		namespaces = append(namespaces, page...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return namespaces, nil
+}
+
+// Namespace returns one namespace
+func (c *Client) Namespace(ctx context.Context, name string) (*Namespace, error) {
+	ns := &Namespace{}
+	if err := c.get(ctx, c.server+"/api/v1/namespaces/"+url.PathEscape(name), ns); err != nil {
+		return nil, err
+	}
+	return ns, nil
+}
+
+// Deployments returns the deployments of a namespace, or of every namespace
+// when namespace is empty
+func (c *Client) Deployments(ctx context.Context, namespace string) ([]*Deployment, error) {
+	var deployments []*Deployment
+	err := c.list(ctx, namespacedPath("/apis/apps/v1", namespace, "deployments"), func(items json.RawMessage) error {
+		var page []*Deployment
+		err := json.Unmarshal(items, &page)
+		deployments = append(deployments, page...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deployments, nil
+}
+
+// Services returns the services of a namespace, or of every namespace when
+// namespace is empty
+func (c *Client) Services(ctx context.Context, namespace string) ([]*Service, error) {
+	var services []*Service
+	err := c.list(ctx, namespacedPath("/api/v1", namespace, "services"), func(items json.RawMessage) error {
+		var page []*Service
+		err := json.Unmarshal(items, &page)
+		services = append(services, page...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// StatusError is a Kubernetes API response that is not a success
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("Kubernetes API returned %d", e.Status)
+	}
+	return fmt.Sprintf("Kubernetes API returned %d: %s", e.Status, e.Message)
+}
+
+// maxResponseBytes bounds each page read
+const maxResponseBytes = 50 << 20
+
+// list reads every page of a list, passing the items of each to add
+func (c *Client) list(ctx context.Context, path string, add func(items json.RawMessage) error) error {
+	next := ""
+	for {
+		query := url.Values{"limit": {strconv.Itoa(pageSize)}}
+		if next != "" {
+			query.Set("continue", next)
+		}
+		var page struct {
+			Metadata struct {
+				Continue string `json:"continue"`
+			} `json:"metadata"`
+			Items json.RawMessage `json:"items"`
+		}
+		if err := c.get(ctx, c.server+path+"?"+query.Encode(), &page); err != nil {
+			return err
+		}
+		if len(page.Items) > 0 {
+			if err := add(page.Items); err != nil {
+				return fmt.Errorf("decoding Kubernetes list: %w", err)
+			}
+		}
+		if next = page.Metadata.Continue; next == "" {
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, u string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var status struct {
+			Message string `json:"message"`
+		}
+		_ = json.Unmarshal(body, &status)
+		return &StatusError{Status: resp.StatusCode, Message: status.Message}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding Kubernetes response: %w", err)
+	}
+	return nil
+}
+
+// namespacedPath returns the path of a resource in a namespace, or in every
+// namespace when namespace is empty
+func namespacedPath(prefix, namespace, resource string) string {
+	if namespace == "" {
+		return prefix + "/" + resource
+	}
+	return prefix + "/namespaces/" + url.PathEscape(namespace) + "/" + resource
+}
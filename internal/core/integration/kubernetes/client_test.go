@@ -0,0 +1,94 @@
+package kubernetes
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewTLSServer(handler)
+	t.Cleanup(server.Close)
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	client, err := NewClient(&Credentials{Server: server.URL, CAData: ca, Token: "sa-token"}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return client
+}
+
+func TestClient_Deployments(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer sa-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if r.URL.Path != "/apis/apps/v1/namespaces/payments/deployments" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"kind":"Status","message":"deployments.apps is forbidden"}`)
+			return
+		}
+		if r.URL.Query().Get("continue") == "" {
+			fmt.Fprint(w, `{"metadata":{"continue":"page2"},"items":[{"metadata":{"name":"api","namespace":"payments","labels":{"team":"core"}},"spec":{"replicas":3,"template":{"spec":{"containers":[{"image":"api:1.2"},{"image":"envoy:1.30"}]}}},"status":{"readyReplicas":2}}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"metadata":{},"items":[{"metadata":{"name":"worker","namespace":"payments"},"spec":{}}]}`)
+	})
+
+	deployments, err := client.Deployments(context.Background(), "payments")
+	if err != nil {
+		t.Fatalf("Deployments: %v", err)
+	}
+	if len(deployments) != 2 || deployments[0].Metadata.Name != "api" || deployments[1].Metadata.Name != "worker" {
+		t.Fatalf("deployments = %+v, want api and worker", deployments)
+	}
+	if got, want := deployments[0].Images(), []string{"api:1.2", "envoy:1.30"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Images = %v, want %v", got, want)
+	}
+
+	var statusErr *StatusError
+	if _, err := client.Deployments(context.Background(), ""); !errors.As(err, &statusErr) || statusErr.Status != http.StatusForbidden {
+		t.Errorf("Deployments of every namespace error = %v, want a 403 StatusError", err)
+	}
+}
+
+func TestClient_Namespace(t *testing.T) {
+	client := testClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/namespaces/payments" {
+			fmt.Fprint(w, `{"metadata":{"name":"payments"},"status":{"phase":"Active"}}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	ns, err := client.Namespace(context.Background(), "payments")
+	if err != nil || ns.Status.Phase != "Active" {
+		t.Errorf("Namespace = %+v, %v", ns, err)
+	}
+	if _, err := client.Namespace(context.Background(), "gone"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Namespace error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestNewClient_RequiresHTTPS(t *testing.T) {
+	if _, err := NewClient(&Credentials{Server: "http://k8s.example.com", Token: "t"}, time.Second); !errors.Is(err, ErrUnsupportedKubeconfig) {
+		t.Errorf("error = %v, want ErrUnsupportedKubeconfig", err)
+	}
+}
+
+func TestService_Ports(t *testing.T) {
+	svc := &Service{}
+	svc.Spec.Ports = []struct {
+		Port     int    `json:"port"`
+		Protocol string `json:"protocol"`
+	}{{Port: 80}, {Port: 53, Protocol: "UDP"}}
+	if got, want := svc.Ports(), []string{"80/TCP", "53/UDP"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Ports = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,149 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+// ErrUnsupportedKubeconfig is returned for kubeconfigs that authenticate in
+// ways a server cannot reproduce, such as files on the author's machine or
+// exec plugins
+var ErrUnsupportedKubeconfig = errors.New("unsupported kubeconfig")
+
+// Credentials are what the client connects to a cluster with: a bearer
+// token or a client certificate, and the CA to trust
+type Credentials struct {
+	Server string
+	// CAData is the PEM of the cluster's CA; the system roots are used
+	// without it
+	CAData     []byte
+	ServerName string
+	Token      string
+	ClientCert []byte
+	ClientKey  []byte
+}
+
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server        string `yaml:"server"`
+			CAData        string `yaml:"certificate-authority-data"`
+			CAFile        string `yaml:"certificate-authority"`
+			TLSServerName string `yaml:"tls-server-name"`
+			Insecure      bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token          string      `yaml:"token"`
+			TokenFile      string      `yaml:"tokenFile"`
+			ClientCertData string      `yaml:"client-certificate-data"`
+			ClientKeyData  string      `yaml:"client-key-data"`
+			ClientCert     string      `yaml:"client-certificate"`
+			ClientKey      string      `yaml:"client-key"`
+			Exec           interface{} `yaml:"exec"`
+			AuthProvider   interface{} `yaml:"auth-provider"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster string `yaml:"cluster"`
+			User    string `yaml:"user"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// ParseKubeconfig returns the credentials of a kubeconfig's context, its
+// current context when context is empty. Certificates and tokens must be
+// inline; file references, exec plugins, auth providers and skipping TLS
+// verification are rejected.
+func ParseKubeconfig(data []byte, context string) (*Credentials, error) {
+	var config kubeconfig
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedKubeconfig, err)
+	}
+	if context == "" {
+		context = config.CurrentContext
+	}
+
+	var clusterName, userName string
+	found := false
+	for _, c := range config.Contexts {
+		if c.Name == context {
+			clusterName, userName, found = c.Context.Cluster, c.Context.User, true
+			break
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: context %q not found", ErrUnsupportedKubeconfig, context)
+	}
+
+	creds := &Credentials{}
+	found = false
+	for _, c := range config.Clusters {
+		if c.Name != clusterName {
+			continue
+		}
+		if c.Cluster.Insecure {
+			return nil, fmt.Errorf("%w: insecure-skip-tls-verify is not supported, give the cluster's CA in certificate-authority-data", ErrUnsupportedKubeconfig)
+		}
+		if c.Cluster.CAFile != "" {
+			return nil, fmt.Errorf("%w: certificate-authority files are not supported, use certificate-authority-data", ErrUnsupportedKubeconfig)
+		}
+		ca, err := decodeData("certificate-authority-data", c.Cluster.CAData)
+		if err != nil {
+			return nil, err
+		}
+		creds.Server, creds.CAData = c.Cluster.Server, ca
+		creds.ServerName = c.Cluster.TLSServerName
+		found = true
+		break
+	}
+	if !found || creds.Server == "" {
+		return nil, fmt.Errorf("%w: cluster %q not found or without a server", ErrUnsupportedKubeconfig, clusterName)
+	}
+
+	for _, u := range config.Users {
+		if u.Name != userName {
+			continue
+		}
+		switch {
+		case u.User.Exec != nil, u.User.AuthProvider != nil:
+			return nil, fmt.Errorf("%w: exec plugins and auth providers are not supported, use a service account token", ErrUnsupportedKubeconfig)
+		case u.User.TokenFile != "", u.User.ClientCert != "", u.User.ClientKey != "":
+			return nil, fmt.Errorf("%w: token and certificate files are not supported, inline them", ErrUnsupportedKubeconfig)
+		}
+		var err error
+		creds.Token = u.User.Token
+		if creds.ClientCert, err = decodeData("client-certificate-data", u.User.ClientCertData); err != nil {
+			return nil, err
+		}
+		if creds.ClientKey, err = decodeData("client-key-data", u.User.ClientKeyData); err != nil {
+			return nil, err
+		}
+		break
+	}
+	if creds.Token == "" && (creds.ClientCert == nil || creds.ClientKey == nil) {
+		return nil, fmt.Errorf("%w: user %q has neither a token nor a client certificate and key", ErrUnsupportedKubeconfig, userName)
+	}
+	return creds, nil
+}
+
+// decodeData decodes a base64 kubeconfig field, nil when empty
+func decodeData(field, v string) ([]byte, error) {
+	if v == "" {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not base64", ErrUnsupportedKubeconfig, field)
+	}
+	return data, nil
+}
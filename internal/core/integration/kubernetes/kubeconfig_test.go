@@ -0,0 +1,82 @@
+package kubernetes
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: prod
+clusters:
+- name: prod-cluster
+  cluster:
+    server: https://prod.k8s.example.com
+    certificate-authority-data: ` + "Q0EgUEVN" + `
+- name: dev-cluster
+  cluster:
+    server: https://dev.k8s.example.com
+contexts:
+- name: prod
+  context:
+    cluster: prod-cluster
+    user: sync
+- name: dev
+  context:
+    cluster: dev-cluster
+    user: dev
+users:
+- name: sync
+  user:
+    token: prod-token
+- name: dev
+  user:
+    exec:
+      command: aws
+`
+
+func TestParseKubeconfig(t *testing.T) {
+	creds, err := ParseKubeconfig([]byte(testKubeconfig), "")
+	if err != nil {
+		t.Fatalf("ParseKubeconfig: %v", err)
+	}
+	if creds.Server != "https://prod.k8s.example.com" || creds.Token != "prod-token" || string(creds.CAData) != "CA PEM" {
+		t.Errorf("credentials = %+v", creds)
+	}
+
+	for name, tt := range map[string]struct {
+		config  string
+		context string
+		want    string
+	}{
+		"exec plugin":     {testKubeconfig, "dev", "exec plugins"},
+		"unknown context": {testKubeconfig, "staging", `context "staging" not found`},
+		"insecure": {strings.Replace(testKubeconfig, "server: https://prod.k8s.example.com",
+			"server: https://prod.k8s.example.com\n    insecure-skip-tls-verify: true", 1), "", "insecure-skip-tls-verify"},
+		"token file":     {strings.Replace(testKubeconfig, "token: prod-token", "tokenFile: /var/run/token", 1), "", "files are not supported"},
+		"no credentials": {strings.Replace(testKubeconfig, "token: prod-token", "username: admin", 1), "", "neither a token"},
+		"not yaml":       {"clusters: [", "", ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseKubeconfig([]byte(tt.config), tt.context)
+			if !errors.Is(err, ErrUnsupportedKubeconfig) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want ErrUnsupportedKubeconfig mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKubeconfig_ClientCertificate(t *testing.T) {
+	config := strings.Replace(testKubeconfig, "token: prod-token",
+		"client-certificate-data: "+base64.StdEncoding.EncodeToString([]byte("CERT"))+
+			"\n    client-key-data: "+base64.StdEncoding.EncodeToString([]byte("KEY")), 1)
+	creds, err := ParseKubeconfig([]byte(config), "prod")
+	if err != nil {
+		t.Fatalf("ParseKubeconfig: %v", err)
+	}
+	if string(creds.ClientCert) != "CERT" || string(creds.ClientKey) != "KEY" || creds.Token != "" {
+		t.Errorf("credentials = %+v", creds)
+	}
+}
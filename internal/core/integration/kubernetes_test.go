@@ -0,0 +1,91 @@
+package integration
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/integration/kubernetes"
+)
+
+func TestValidateKubernetesConfig(t *testing.T) {
+	token := map[string]interface{}{"$secret": "k8s-token"}
+	blueprints := map[string]interface{}{"deployment": "workload", "service": "k8s-service"}
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{"service account", map[string]interface{}{"cluster": "prod-eu-1", "server": "https://k8s.example.com", "token": token, "blueprints": blueprints}, false},
+		{"kubeconfig", map[string]interface{}{
+			"cluster":          "prod-eu-1",
+			"kubeconfig":       map[string]interface{}{"$secret": "prod-kubeconfig"},
+			"context":          "prod",
+			"blueprints":       map[string]interface{}{"namespace": "k8s-namespace"},
+			"namespaces":       []interface{}{"payments"},
+			"labels":           "",
+			"label_properties": map[string]interface{}{"app.kubernetes.io/part-of": "system"},
+		}, false},
+		{"literal token", map[string]interface{}{"cluster": "prod-eu-1", "server": "https://k8s.example.com", "token": "abc", "blueprints": blueprints}, true},
+		{"literal kubeconfig", map[string]interface{}{"cluster": "prod-eu-1", "kubeconfig": "apiVersion: v1", "blueprints": blueprints}, true},
+		{"both", map[string]interface{}{"cluster": "prod-eu-1", "kubeconfig": map[string]interface{}{"$secret": "k"}, "server": "https://k8s.example.com", "blueprints": blueprints}, true},
+		{"plain http", map[string]interface{}{"cluster": "prod-eu-1", "server": "http://k8s.example.com", "token": token, "blueprints": blueprints}, true},
+		{"bad cluster name", map[string]interface{}{"cluster": "Prod.EU", "server": "https://k8s.example.com", "token": token, "blueprints": blueprints}, true},
+		{"no blueprints", map[string]interface{}{"cluster": "prod-eu-1", "server": "https://k8s.example.com", "token": token}, true},
+		{"unknown kind", map[string]interface{}{"cluster": "prod-eu-1", "server": "https://k8s.example.com", "token": token, "blueprints": map[string]interface{}{"pod": "pods"}}, true},
+		{"shared blueprint", map[string]interface{}{"cluster": "prod-eu-1", "server": "https://k8s.example.com", "token": token, "blueprints": map[string]interface{}{"deployment": "workload", "service": "workload"}}, true},
+		{"bad ca_data", map[string]interface{}{"cluster": "prod-eu-1", "server": "https://k8s.example.com", "token": token, "ca_data": "not base64!", "blueprints": blueprints}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKubernetesConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateKubernetesConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestKubernetesEntities(t *testing.T) {
+	cfg := &KubernetesConfig{
+		Cluster:         "prod-eu-1",
+		LabelsProperty:  "labels",
+		LabelProperties: map[string]string{"app.kubernetes.io/part-of": "system"},
+	}
+	created := time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+
+	d := &kubernetes.Deployment{Metadata: kubernetes.Metadata{
+		Name:              "api",
+		Namespace:         "payments",
+		Labels:            map[string]string{"app.kubernetes.io/part-of": "checkout"},
+		CreationTimestamp: created,
+	}}
+	d.Status.ReadyReplicas = 1
+	row := objectEntity(cfg, d.Metadata, deploymentData(cfg, d))
+	if row.Identifier != "prod-eu-1.payments.api" || row.Title != "api" {
+		t.Errorf("entity = %s %q, want prod-eu-1.payments.api titled api", row.Identifier, row.Title)
+	}
+	want := map[string]interface{}{
+		"cluster":            "prod-eu-1",
+		"namespace":          "payments",
+		"created_at":         "2026-09-01T08:00:00Z",
+		"labels":             map[string]interface{}{"app.kubernetes.io/part-of": "checkout"},
+		"system":             "checkout",
+		"replicas":           1,
+		"ready_replicas":     1,
+		"available_replicas": 0,
+		"images":             []string{},
+	}
+	if !reflect.DeepEqual(row.Data, want) {
+		t.Errorf("deployment data = %v, want %v", row.Data, want)
+	}
+
+	ns := kubernetes.Metadata{Name: "payments", CreationTimestamp: created}
+	if row := objectEntity(cfg, ns, objectData(cfg, ns)); row.Identifier != "prod-eu-1.payments" {
+		t.Errorf("namespace identifier = %s, want prod-eu-1.payments", row.Identifier)
+	}
+}
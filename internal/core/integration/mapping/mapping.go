@@ -0,0 +1,305 @@
+// Package mapping turns JSON payloads from external systems into entities
+// with a mapping template, for ingest integrations. Paths use a subset of
+// jq: .a.b selects a field, [n] an array element and . the item itself.
+package mapping
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidMapping is returned for mappings that cannot be parsed
+var ErrInvalidMapping = errors.New("invalid mapping")
+
+// maxProperties bounds the properties a mapping writes
+const maxProperties = 100
+
+// pathSegment is one dot-separated segment of a path: a field name, which
+// may be empty before an index, followed by any array indexes
+var pathSegment = regexp.MustCompile(`^([A-Za-z0-9_@$-]*)((?:\[[0-9]+\])*)$`)
+
+// placeholder is a path in a template, such as {{ .name }}
+var placeholder = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+// step is a field name or, when field is empty, an array index
+type step struct {
+	field string
+	index int
+}
+
+// path selects a value from an item
+type path []step
+
+// Mapping turns payloads into entities
+type Mapping struct {
+	items      path
+	identifier *expression
+	title      *expression
+	properties map[string]*expression
+}
+
+// Entity is the entity an item maps to
+type Entity struct {
+	Identifier string
+	Title      string
+	Data       map[string]interface{}
+}
+
+// expression is a path, a template rendering paths into a string, or a
+// literal value
+type expression struct {
+	path     path
+	template []string // literal text and paths alternating, text first
+	paths    []path
+	literal  interface{}
+}
+
+// Parse reads a mapping template:
+//
+//	{"items": ".deployments", "identifier": "{{.service}}-{{.id}}", "title": ".name",
+//	 "properties": {"version": ".version", "source": "ci"}}
+//
+// Strings starting with a dot are paths, strings with {{ }} placeholders are
+// templates, and other values are written as they are.
+func Parse(config map[string]interface{}) (*Mapping, error) {
+	m := &Mapping{properties: map[string]*expression{}}
+	if raw, ok := config["items"]; ok {
+		s, _ := raw.(string)
+		items, err := parsePath(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: items: %v", ErrInvalidMapping, err)
+		}
+		m.items = items
+	}
+
+	identifier, ok := config["identifier"].(string)
+	if !ok || identifier == "" {
+		return nil, fmt.Errorf("%w: identifier is required, a path or template such as .id", ErrInvalidMapping)
+	}
+	var err error
+	if m.identifier, err = parseExpression(identifier); err != nil {
+		return nil, fmt.Errorf("%w: identifier: %v", ErrInvalidMapping, err)
+	}
+	if raw, ok := config["title"]; ok {
+		title, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: title must be a path or template", ErrInvalidMapping)
+		}
+		if m.title, err = parseExpression(title); err != nil {
+			return nil, fmt.Errorf("%w: title: %v", ErrInvalidMapping, err)
+		}
+	}
+
+	properties, _ := config["properties"].(map[string]interface{})
+	if len(properties) > maxProperties {
+		return nil, fmt.Errorf("%w: at most %d properties", ErrInvalidMapping, maxProperties)
+	}
+	for property, raw := range properties {
+		if property == "" {
+			return nil, fmt.Errorf("%w: property names must not be empty", ErrInvalidMapping)
+		}
+		s, ok := raw.(string)
+		if !ok {
+			m.properties[property] = &expression{literal: raw}
+			continue
+		}
+		if m.properties[property], err = parseExpression(s); err != nil {
+			return nil, fmt.Errorf("%w: property %q: %v", ErrInvalidMapping, property, err)
+		}
+	}
+	return m, nil
+}
+
+// Items returns the items of a payload: the array at the items path, or
+// without one the payload's elements when it is an array and the payload
+// itself otherwise
+func (m *Mapping) Items(payload interface{}) ([]interface{}, error) {
+	if m.items == nil {
+		if items, ok := payload.([]interface{}); ok {
+			return items, nil
+		}
+		return []interface{}{payload}, nil
+	}
+	v, ok := m.items.get(payload)
+	items, isArray := v.([]interface{})
+	if !ok || !isArray {
+		return nil, fmt.Errorf("items %s is not an array", m.items)
+	}
+	return items, nil
+}
+
+// Entity maps an item. Properties whose paths are missing from the item are
+// left out; a missing or empty identifier is an error.
+func (m *Mapping) Entity(item interface{}) (*Entity, error) {
+	identifier, ok := m.identifier.text(item)
+	if !ok || identifier == "" {
+		return nil, errors.New("identifier is missing from the item")
+	}
+	e := &Entity{Identifier: identifier, Data: make(map[string]interface{}, len(m.properties))}
+	if m.title != nil {
+		e.Title, _ = m.title.text(item)
+	}
+	for property, expr := range m.properties {
+		if v, ok := expr.value(item); ok {
+			e.Data[property] = v
+		}
+	}
+	return e, nil
+}
+
+func parseExpression(s string) (*expression, error) {
+	if strings.HasPrefix(s, ".") {
+		p, err := parsePath(s)
+		if err != nil {
+			return nil, err
+		}
+		return &expression{path: p}, nil
+	}
+	matches := placeholder.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		if strings.Contains(s, "{{") {
+			return nil, fmt.Errorf("unclosed placeholder in %q", s)
+		}
+		return &expression{literal: s}, nil
+	}
+	expr := &expression{}
+	last := 0
+	for _, m := range matches {
+		p, err := parsePath(s[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+		expr.template = append(expr.template, s[last:m[0]])
+		expr.paths = append(expr.paths, p)
+		last = m[1]
+	}
+	expr.template = append(expr.template, s[last:])
+	for _, text := range expr.template {
+		if strings.Contains(text, "{{") || strings.Contains(text, "}}") {
+			return nil, fmt.Errorf("unbalanced placeholder in %q", s)
+		}
+	}
+	return expr, nil
+}
+
+// parsePath reads a path such as .a.b[0].c; "." alone is the item
+func parsePath(s string) (path, error) {
+	if !strings.HasPrefix(s, ".") {
+		return nil, fmt.Errorf("path %q must start with a dot", s)
+	}
+	p := path{}
+	if s == "." {
+		return p, nil
+	}
+	for _, segment := range strings.Split(s[1:], ".") {
+		m := pathSegment.FindStringSubmatch(segment)
+		if m == nil || (m[1] == "" && m[2] == "") {
+			return nil, fmt.Errorf("invalid path %q", s)
+		}
+		if m[1] != "" {
+			p = append(p, step{field: m[1]})
+		}
+		for _, index := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			n, err := strconv.Atoi(index)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index in path %q", s)
+			}
+			p = append(p, step{index: n})
+		}
+	}
+	return p, nil
+}
+
+// get returns the value at the path, false when it is missing or null
+func (p path) get(v interface{}) (interface{}, bool) {
+	for _, s := range p {
+		if s.field != "" {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			v = obj[s.field]
+			continue
+		}
+		arr, ok := v.([]interface{})
+		if !ok || s.index >= len(arr) {
+			return nil, false
+		}
+		v = arr[s.index]
+	}
+	return v, v != nil
+}
+
+func (p path) String() string {
+	var b strings.Builder
+	for _, s := range p {
+		if s.field != "" {
+			b.WriteString("." + s.field)
+		} else {
+			fmt.Fprintf(&b, "[%d]", s.index)
+		}
+	}
+	if b.Len() == 0 || strings.HasPrefix(b.String(), "[") {
+		return "." + b.String()
+	}
+	return b.String()
+}
+
+// value evaluates the expression; false when a path it reads is missing
+func (e *expression) value(item interface{}) (interface{}, bool) {
+	switch {
+	case e.path != nil:
+		return e.path.get(item)
+	case e.template != nil:
+		return e.text(item)
+	}
+	return e.literal, true
+}
+
+// text evaluates the expression as a string
+func (e *expression) text(item interface{}) (string, bool) {
+	if e.template == nil {
+		v, ok := e.value(item)
+		if !ok {
+			return "", false
+		}
+		return toText(v), true
+	}
+	var b strings.Builder
+	for i, text := range e.template {
+		b.WriteString(text)
+		if i == len(e.paths) {
+			break
+		}
+		v, ok := e.paths[i].get(item)
+		if !ok {
+			return "", false
+		}
+		b.WriteString(toText(v))
+	}
+	return b.String(), true
+}
+
+// toText renders a value into a template: strings as they are, numbers
+// without exponents where possible, and objects and arrays as JSON
+func toText(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,105 @@
+package mapping
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func decode(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMapping(t *testing.T) {
+	m, err := Parse(map[string]interface{}{
+		"items":      ".data.deployments",
+		"identifier": "{{ .service }}-{{.id}}",
+		"title":      "{{.service}} {{.version}}",
+		"properties": map[string]interface{}{
+			"version":   ".version",
+			"image":     ".containers[0].image",
+			"labels":    ".labels",
+			"commit":    ".git.sha",
+			"source":    "ci",
+			"automated": true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	payload := decode(t, `{"data":{"deployments":[
+		{"id":42,"service":"payments","version":"1.4.0","containers":[{"image":"payments:1.4.0"}],"labels":{"team":"core"}},
+		{"service":"checkout"}
+	]}}`)
+
+	items, err := m.Items(payload)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("Items = %v, %v", items, err)
+	}
+	got, err := m.Entity(items[0])
+	if err != nil {
+		t.Fatalf("Entity: %v", err)
+	}
+	want := &Entity{
+		Identifier: "payments-42",
+		Title:      "payments 1.4.0",
+		Data: map[string]interface{}{
+			"version":   "1.4.0",
+			"image":     "payments:1.4.0",
+			"labels":    map[string]interface{}{"team": "core"},
+			"source":    "ci",
+			"automated": true,
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Entity = %+v, want %+v", got, want)
+	}
+	if _, err := m.Entity(items[1]); err == nil {
+		t.Error("Entity without the identifier's fields succeeded")
+	}
+	if _, err := m.Items(decode(t, `{"data":{}}`)); err == nil {
+		t.Error("Items without the items array succeeded")
+	}
+}
+
+func TestMapping_Items(t *testing.T) {
+	m, err := Parse(map[string]interface{}{"identifier": ".name"})
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if items, _ := m.Items(decode(t, `[{"name":"a"},{"name":"b"}]`)); len(items) != 2 {
+		t.Errorf("Items of an array = %v", items)
+	}
+	items, _ := m.Items(decode(t, `{"name":"a"}`))
+	if len(items) != 1 {
+		t.Fatalf("Items of an object = %v", items)
+	}
+	if e, err := m.Entity(items[0]); err != nil || e.Identifier != "a" || e.Title != "" {
+		t.Errorf("Entity = %+v, %v", e, err)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []map[string]interface{}{
+		{},
+		{"identifier": ""},
+		{"identifier": ".a..b"},
+		{"identifier": ".a[x]"},
+		{"identifier": "{{ name }}"},
+		{"identifier": "{{.name}"},
+		{"identifier": ".id", "title": 3},
+		{"identifier": ".id", "items": "deployments"},
+		{"identifier": ".id", "properties": map[string]interface{}{"version": ".a b"}},
+	}
+	for _, config := range tests {
+		if _, err := Parse(config); !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("Parse(%v) error = %v, want ErrInvalidMapping", config, err)
+		}
+	}
+}
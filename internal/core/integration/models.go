@@ -0,0 +1,39 @@
+package integration
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Integration statuses. An integration becomes active with its first sync,
+// and stale when its exporter stops syncing; the next sync makes it active.
+const (
+	StatusInactive = "inactive"
+	StatusActive   = "active"
+	StatusStale    = "stale"
+)
+
+// Integration is an external system, typically an exporter, that feeds
+// entities into a team's catalog
+type Integration struct {
+	ID         uuid.UUID              `json:"id"`
+	TeamID     uuid.UUID              `json:"team_id"`
+	Type       string                 `json:"type"`
+	Name       string                 `json:"name"`
+	Config     map[string]interface{} `json:"config"`
+	Status     string                 `json:"status"`
+	LastSyncAt *time.Time             `json:"last_sync_at,omitempty"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type CreateIntegrationRequest struct {
+	Type   string                 `json:"type" binding:"required,max=50"`
+	Name   string                 `json:"name" binding:"required,max=100"`
+	Config map[string]interface{} `json:"config"`
+}
+
+type ListIntegrationsResponse struct {
+	Integrations []*Integration `json:"integrations"`
+	Total        int            `json:"total"`
+}
@@ -0,0 +1,296 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/integration/pagerduty"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+// TypePagerDuty integrations sync the services of a PagerDuty account and
+// who is on call for them into entities
+const TypePagerDuty = "pagerduty"
+
+// JobPagerDutySync syncs a PagerDuty integration's services and on-call
+// users
+const JobPagerDutySync = "integration.pagerduty_sync"
+
+// PagerDutyConfig is the configuration of a PagerDuty integration
+type PagerDutyConfig struct {
+	Token  string
+	APIURL string
+	// ServiceBlueprint and UserBlueprint receive the services and the users
+	// on call; users are not synced without a blueprint
+	ServiceBlueprint string
+	UserBlueprint    string
+	// LinkBlueprint's entities name their PagerDuty service in
+	// LinkProperty, and receive its on-call users in OnCallProperty as
+	// service entities do in on_call
+	LinkBlueprint  string
+	LinkProperty   string
+	OnCallProperty string
+}
+
+// validatePagerDutyConfig checks the configuration of a new PagerDuty
+// integration. The token must be a secret reference.
+func validatePagerDutyConfig(config map[string]interface{}) error {
+	if !secret.IsReference(config["token"]) {
+		return fmt.Errorf(`%w: token must be a secret reference, {"$secret": "<name>"}`, ErrInvalidConfig)
+	}
+	_, err := parsePagerDutyConfig(config)
+	return err
+}
+
+// parsePagerDutyConfig reads a PagerDuty configuration; the token is read
+// only once resolved
+func parsePagerDutyConfig(config map[string]interface{}) (*PagerDutyConfig, error) {
+	cfg := &PagerDutyConfig{OnCallProperty: "on_call"}
+	cfg.Token, _ = config["token"].(string)
+	if raw, ok := config["api_url"]; ok {
+		cfg.APIURL, _ = raw.(string)
+		u, err := url.Parse(cfg.APIURL)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return nil, fmt.Errorf("%w: api_url must be an https URL", ErrInvalidConfig)
+		}
+	}
+
+	blueprints, _ := config["blueprints"].(map[string]interface{})
+	for kind, v := range blueprints {
+		blueprintID, _ := v.(string)
+		switch {
+		case kind != "service" && kind != "user":
+			return nil, fmt.Errorf("%w: unknown kind %q in blueprints, must be service or user", ErrInvalidConfig, kind)
+		case blueprintID == "":
+			return nil, fmt.Errorf("%w: blueprint of %q must be a blueprint ID", ErrInvalidConfig, kind)
+		case kind == "service":
+			cfg.ServiceBlueprint = blueprintID
+		default:
+			cfg.UserBlueprint = blueprintID
+		}
+	}
+	if cfg.ServiceBlueprint == "" {
+		return nil, fmt.Errorf("%w: blueprints.service is required", ErrInvalidConfig)
+	}
+	if cfg.ServiceBlueprint == cfg.UserBlueprint {
+		return nil, fmt.Errorf("%w: service and user must go to different blueprints", ErrInvalidConfig)
+	}
+
+	if raw, ok := config["link"]; ok {
+		link, _ := raw.(map[string]interface{})
+		cfg.LinkBlueprint, _ = link["blueprint"].(string)
+		cfg.LinkProperty, _ = link["property"].(string)
+		if cfg.LinkBlueprint == "" || cfg.LinkProperty == "" {
+			return nil, fmt.Errorf("%w: link must name a blueprint and the property holding the PagerDuty service ID", ErrInvalidConfig)
+		}
+		if cfg.LinkBlueprint == cfg.ServiceBlueprint || cfg.LinkBlueprint == cfg.UserBlueprint {
+			return nil, fmt.Errorf("%w: link must be another blueprint than the synced ones", ErrInvalidConfig)
+		}
+	}
+	if raw, ok := config["on_call_property"]; ok {
+		cfg.OnCallProperty, _ = raw.(string)
+		if cfg.OnCallProperty == "" {
+			return nil, fmt.Errorf("%w: on_call_property must be a property name", ErrInvalidConfig)
+		}
+	}
+	if cfg.OnCallProperty == cfg.LinkProperty {
+		return nil, fmt.Errorf("%w: on_call_property must differ from the link property", ErrInvalidConfig)
+	}
+	return cfg, nil
+}
+
+// syncPagerDuty writes the account's services and the users on call to their
+// blueprints, deletes the integration's entities of services and users that
+// are gone or no longer on call, and updates the on-call users of linked
+// entities. The account is read before anything is written.
+func (s *Service) syncPagerDuty(ctx context.Context, job *jobs.Job) error {
+	var payload syncJob
+	ctx, in, err := s.loadSyncJob(ctx, job, &payload)
+	if err != nil || in == nil {
+		return err
+	}
+	config, err := s.cachedConfig(ctx, in)
+	if err != nil {
+		return err
+	}
+	cfg, err := parsePagerDutyConfig(config)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	client := pagerduty.NewClient(cfg.APIURL, cfg.Token, s.http)
+	services, err := client.Services(ctx)
+	if err != nil {
+		return err
+	}
+	onCalls, err := client.OnCalls(ctx)
+	if err != nil {
+		return err
+	}
+	byPolicy := onCallByPolicy(onCalls)
+
+	rows := make([]*entity.CreateEntityRequest, 0, len(services))
+	onCallByService := make(map[string][]interface{}, len(services))
+	for _, svc := range services {
+		onCall := byPolicy[svc.EscalationPolicy.ID]
+		onCallByService[svc.ID] = onCall
+		rows = append(rows, &entity.CreateEntityRequest{Identifier: svc.ID, Title: svc.Name, Data: pagerDutyServiceData(svc, onCall)})
+	}
+	failure, err := s.replaceEntities(ctx, in, cfg.ServiceBlueprint, rows)
+	if err != nil {
+		return err
+	}
+	if cfg.UserBlueprint != "" {
+		userFailure, err := s.replaceEntities(ctx, in, cfg.UserBlueprint, onCallUserEntities(onCalls))
+		if err != nil {
+			return err
+		}
+		if failure == nil {
+			failure = userFailure
+		}
+	}
+	if cfg.LinkBlueprint == "" {
+		return failure
+	}
+
+	linked, err := s.listEntities(ctx, in.TeamID, cfg.LinkBlueprint)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range linked {
+		if err := s.patchData(ctx, e, onCallChanges(cfg, e.Data, onCallByService)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		log.Printf("WARNING: pagerduty integration %s: on-call users of %d %s entities not written, first: %v", in.ID, len(errs), cfg.LinkBlueprint, errs[0])
+		if failure == nil {
+			failure = fmt.Errorf("on-call users of %d %s entities not written, first: %w", len(errs), cfg.LinkBlueprint, errs[0])
+		}
+	}
+	return failure
+}
+
+// onCallByPolicy returns the on-call users of each escalation policy, in
+// the JSON form of the on_call property, by level then name
+func onCallByPolicy(onCalls []*pagerduty.OnCall) map[string][]interface{} {
+	byPolicy := map[string][]*pagerduty.OnCall{}
+	for _, oc := range onCalls {
+		byPolicy[oc.EscalationPolicy.ID] = append(byPolicy[oc.EscalationPolicy.ID], oc)
+	}
+	values := make(map[string][]interface{}, len(byPolicy))
+	for policy, list := range byPolicy {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].EscalationLevel != list[j].EscalationLevel {
+				return list[i].EscalationLevel < list[j].EscalationLevel
+			}
+			return list[i].User.Name < list[j].User.Name
+		})
+		for _, oc := range list {
+			value := map[string]interface{}{
+				"user":  oc.User.ID,
+				"name":  oc.User.Name,
+				"email": oc.User.Email,
+				"level": float64(oc.EscalationLevel),
+			}
+			if oc.End != nil {
+				value["until"] = oc.End.UTC().Format(time.RFC3339)
+			}
+			values[policy] = append(values[policy], value)
+		}
+	}
+	return values
+}
+
+// pagerDutyServiceData returns the properties of a service entity
+func pagerDutyServiceData(svc *pagerduty.Service, onCall []interface{}) map[string]interface{} {
+	teams := make([]interface{}, 0, len(svc.Teams))
+	for _, team := range svc.Teams {
+		teams = append(teams, team.Summary)
+	}
+	if onCall == nil {
+		onCall = []interface{}{}
+	}
+	data := map[string]interface{}{
+		"name":              svc.Name,
+		"status":            svc.Status,
+		"url":               svc.HTMLURL,
+		"escalation_policy": svc.EscalationPolicy.Summary,
+		"teams":             teams,
+		"on_call":           onCall,
+	}
+	if svc.Description != "" {
+		data["description"] = svc.Description
+	}
+	return data
+}
+
+// onCallUserEntities returns an entity per user on call, with the
+// escalation policies they are on call for
+func onCallUserEntities(onCalls []*pagerduty.OnCall) []*entity.CreateEntityRequest {
+	var rows []*entity.CreateEntityRequest
+	byID := map[string]*entity.CreateEntityRequest{}
+	for _, oc := range onCalls {
+		row, ok := byID[oc.User.ID]
+		if !ok {
+			row = &entity.CreateEntityRequest{
+				Identifier: oc.User.ID,
+				Title:      oc.User.Name,
+				Data: map[string]interface{}{
+					"name":                oc.User.Name,
+					"email":               oc.User.Email,
+					"time_zone":           oc.User.TimeZone,
+					"url":                 oc.User.HTMLURL,
+					"escalation_policies": []interface{}{},
+				},
+			}
+			byID[oc.User.ID] = row
+			rows = append(rows, row)
+		}
+		policies := row.Data["escalation_policies"].([]interface{})
+		if policy := oc.EscalationPolicy.Summary; policy != "" && !containsValue(policies, policy) {
+			row.Data["escalation_policies"] = append(policies, policy)
+		}
+	}
+	return rows
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+// onCallChanges returns the merge patch setting a linked entity's on-call
+// users to those of its service, nil when they did not change. Entities
+// that name no service, or one the account does not have, lose the
+// property.
+func onCallChanges(cfg *PagerDutyConfig, current map[string]interface{}, onCallByService map[string][]interface{}) map[string]interface{} {
+	serviceID, _ := current[cfg.LinkProperty].(string)
+	old, exists := current[cfg.OnCallProperty]
+	onCall, linked := onCallByService[serviceID]
+	switch {
+	case !linked && !exists:
+		return nil
+	case !linked:
+		return map[string]interface{}{cfg.OnCallProperty: nil}
+	}
+	if onCall == nil {
+		onCall = []interface{}{}
+	}
+	if exists && reflect.DeepEqual(old, onCall) {
+		return nil
+	}
+	return map[string]interface{}{cfg.OnCallProperty: onCall}
+}
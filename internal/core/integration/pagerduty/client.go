@@ -0,0 +1,179 @@
+// Package pagerduty reads services and on-call users from the PagerDuty REST
+// API for the PagerDuty integration.
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultAPIURL is PagerDuty's US service region; accounts in the EU region
+// use https://api.eu.pagerduty.com
+const DefaultAPIURL = "https://api.pagerduty.com"
+
+// pageSize is the limit of each list request, PagerDuty's maximum
+const pageSize = 100
+
+// Reference is another object a PagerDuty object refers to, named by its
+// summary
+type Reference struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+}
+
+// Service is a PagerDuty service
+type Service struct {
+	ID               string      `json:"id"`
+	Name             string      `json:"name"`
+	Description      string      `json:"description"`
+	Status           string      `json:"status"`
+	HTMLURL          string      `json:"html_url"`
+	EscalationPolicy Reference   `json:"escalation_policy"`
+	Teams            []Reference `json:"teams"`
+}
+
+// User is a PagerDuty user
+type User struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	TimeZone string `json:"time_zone"`
+	HTMLURL  string `json:"html_url"`
+}
+
+// OnCall is a user on call at a level of an escalation policy. End is nil
+// for users on call permanently.
+type OnCall struct {
+	User             User       `json:"user"`
+	EscalationPolicy Reference  `json:"escalation_policy"`
+	EscalationLevel  int        `json:"escalation_level"`
+	End              *time.Time `json:"end"`
+}
+
+// Client calls the PagerDuty REST API with an API key
+type Client struct {
+	apiURL string
+	token  string
+	http   *http.Client
+}
+
+// NewClient returns a client of the API at apiURL, DefaultAPIURL when empty
+func NewClient(apiURL, token string, httpClient *http.Client) *Client {
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+	return &Client{apiURL: strings.TrimSuffix(apiURL, "/"), token: token, http: httpClient}
+}
+
+// Services returns every service of the account
+func (c *Client) Services(ctx context.Context) ([]*Service, error) {
+	var services []*Service
+	err := c.list(ctx, "/services", nil, func(page json.RawMessage) error {
+		var body struct {
+			Services []*Service `json:"services"`
+		}
+		if err := json.Unmarshal(page, &body); err != nil {
+			return err
+		}
+		services = append(services, body.Services...)
+		return nil
+	})
+	return services, err
+}
+
+// OnCalls returns who is on call now, once per user, escalation policy and
+// level
+func (c *Client) OnCalls(ctx context.Context) ([]*OnCall, error) {
+	var onCalls []*OnCall
+	query := url.Values{"include[]": {"users"}, "earliest": {"true"}}
+	err := c.list(ctx, "/oncalls", query, func(page json.RawMessage) error {
+		var body struct {
+			OnCalls []*OnCall `json:"oncalls"`
+		}
+		if err := json.Unmarshal(page, &body); err != nil {
+			return err
+		}
+		onCalls = append(onCalls, body.OnCalls...)
+		return nil
+	})
+	return onCalls, err
+}
+
+// StatusError is a PagerDuty API response that is not a success
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("PagerDuty API returned %d", e.Status)
+	}
+	return fmt.Sprintf("PagerDuty API returned %d: %s", e.Status, e.Message)
+}
+
+// maxResponseBytes bounds the responses read, a page of 100 objects
+const maxResponseBytes = 10 << 20
+
+// list reads every page of a list endpoint, passing each page's body to add
+func (c *Client) list(ctx context.Context, path string, query url.Values, add func(page json.RawMessage) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("limit", strconv.Itoa(pageSize))
+	for offset := 0; ; offset += pageSize {
+		query.Set("offset", strconv.Itoa(offset))
+		body, err := c.get(ctx, c.apiURL+path+"?"+query.Encode())
+		if err != nil {
+			return err
+		}
+		if err := add(body); err != nil {
+			return fmt.Errorf("decoding PagerDuty response: %w", err)
+		}
+		var page struct {
+			More bool `json:"more"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return fmt.Errorf("decoding PagerDuty response: %w", err)
+		}
+		if !page.More {
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context, u string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+	req.Header.Set("Authorization", "Token token="+c.token)
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		return nil, &StatusError{Status: resp.StatusCode, Message: apiErr.Error.Message}
+	}
+	return body, nil
+}
@@ -0,0 +1,77 @@
+package pagerduty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Services(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/services" {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Token token=pd-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.URL.Query().Get("limit"); got != "100" {
+			t.Errorf("limit = %q", got)
+		}
+		switch r.URL.Query().Get("offset") {
+		case "0":
+			fmt.Fprint(w, `{"services":[{"id":"PSVC1","name":"Payments","status":"active","escalation_policy":{"id":"PEP1","summary":"Payments on-call"}}],"more":true}`)
+		case "100":
+			fmt.Fprint(w, `{"services":[{"id":"PSVC2","name":"Checkout","status":"critical","teams":[{"id":"PT1","summary":"Checkout"}]}],"more":false}`)
+		default:
+			t.Errorf("offset = %s", r.URL.Query().Get("offset"))
+		}
+	}))
+	defer server.Close()
+
+	services, err := NewClient(server.URL+"/", "pd-key", server.Client()).Services(context.Background())
+	if err != nil {
+		t.Fatalf("Services: %v", err)
+	}
+	if len(services) != 2 || services[0].EscalationPolicy.ID != "PEP1" || services[1].Teams[0].Summary != "Checkout" {
+		t.Errorf("Services = %+v", services)
+	}
+}
+
+func TestClient_OnCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("include[]") != "users" || query.Get("earliest") != "true" {
+			t.Errorf("query = %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"oncalls":[
+			{"user":{"id":"PU1","name":"Ada","email":"ada@acme.com"},"escalation_policy":{"id":"PEP1"},"escalation_level":1,"end":"2026-10-19T09:00:00Z"},
+			{"user":{"id":"PU2","name":"Grace","email":"grace@acme.com"},"escalation_policy":{"id":"PEP1"},"escalation_level":2,"end":null}
+		],"more":false}`)
+	}))
+	defer server.Close()
+
+	onCalls, err := NewClient(server.URL, "pd-key", server.Client()).OnCalls(context.Background())
+	if err != nil {
+		t.Fatalf("OnCalls: %v", err)
+	}
+	if len(onCalls) != 2 || onCalls[0].User.Email != "ada@acme.com" || onCalls[0].End == nil || onCalls[1].End != nil {
+		t.Errorf("OnCalls = %+v", onCalls)
+	}
+}
+
+func TestClient_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"Unauthorized","code":2006}}`)
+	}))
+	defer server.Close()
+
+	_, err := NewClient(server.URL, "bad", server.Client()).Services(context.Background())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusUnauthorized || statusErr.Message != "Unauthorized" {
+		t.Errorf("Services error = %v, want a 401 StatusError", err)
+	}
+}
@@ -0,0 +1,110 @@
+package integration
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/integration/pagerduty"
+)
+
+func TestValidatePagerDutyConfig(t *testing.T) {
+	token := map[string]interface{}{"$secret": "pagerduty-key"}
+	blueprints := map[string]interface{}{"service": "pagerduty-service", "user": "pagerduty-user"}
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{"services only", map[string]interface{}{"token": token, "blueprints": map[string]interface{}{"service": "pagerduty-service"}}, false},
+		{"linked", map[string]interface{}{
+			"token":            token,
+			"api_url":          "https://api.eu.pagerduty.com",
+			"blueprints":       blueprints,
+			"link":             map[string]interface{}{"blueprint": "service", "property": "pagerduty_service"},
+			"on_call_property": "pager",
+		}, false},
+		{"literal token", map[string]interface{}{"token": "abc", "blueprints": blueprints}, true},
+		{"plain http api", map[string]interface{}{"token": token, "api_url": "http://api.pagerduty.com", "blueprints": blueprints}, true},
+		{"no service blueprint", map[string]interface{}{"token": token, "blueprints": map[string]interface{}{"user": "pagerduty-user"}}, true},
+		{"unknown kind", map[string]interface{}{"token": token, "blueprints": map[string]interface{}{"service": "s", "schedule": "x"}}, true},
+		{"shared blueprint", map[string]interface{}{"token": token, "blueprints": map[string]interface{}{"service": "pd", "user": "pd"}}, true},
+		{"link without property", map[string]interface{}{"token": token, "blueprints": blueprints, "link": map[string]interface{}{"blueprint": "service"}}, true},
+		{"link to synced blueprint", map[string]interface{}{"token": token, "blueprints": blueprints, "link": map[string]interface{}{"blueprint": "pagerduty-user", "property": "id"}}, true},
+		{"on_call is the link", map[string]interface{}{"token": token, "blueprints": blueprints, "link": map[string]interface{}{"blueprint": "service", "property": "pd"}, "on_call_property": "pd"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePagerDutyConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validatePagerDutyConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestPagerDutyEntities(t *testing.T) {
+	end := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	ada := pagerduty.User{ID: "PU1", Name: "Ada", Email: "ada@acme.com", TimeZone: "Europe/London"}
+	grace := pagerduty.User{ID: "PU2", Name: "Grace", Email: "grace@acme.com"}
+	onCalls := []*pagerduty.OnCall{
+		{User: grace, EscalationPolicy: pagerduty.Reference{ID: "PEP1", Summary: "Payments"}, EscalationLevel: 2},
+		{User: ada, EscalationPolicy: pagerduty.Reference{ID: "PEP1", Summary: "Payments"}, EscalationLevel: 1, End: &end},
+		{User: ada, EscalationPolicy: pagerduty.Reference{ID: "PEP2", Summary: "Checkout"}, EscalationLevel: 1},
+	}
+
+	byPolicy := onCallByPolicy(onCalls)
+	want := []interface{}{
+		map[string]interface{}{"user": "PU1", "name": "Ada", "email": "ada@acme.com", "level": float64(1), "until": "2026-10-19T09:00:00Z"},
+		map[string]interface{}{"user": "PU2", "name": "Grace", "email": "grace@acme.com", "level": float64(2)},
+	}
+	if !reflect.DeepEqual(byPolicy["PEP1"], want) {
+		t.Errorf("on call of PEP1 = %v, want %v", byPolicy["PEP1"], want)
+	}
+
+	svc := &pagerduty.Service{ID: "PSVC1", Name: "Payments API", Status: "active", HTMLURL: "https://acme.pagerduty.com/services/PSVC1",
+		EscalationPolicy: pagerduty.Reference{ID: "PEP3", Summary: "Nobody"}, Teams: []pagerduty.Reference{{Summary: "Payments"}}}
+	data := pagerDutyServiceData(svc, byPolicy["PEP3"])
+	if got := data["on_call"]; !reflect.DeepEqual(got, []interface{}{}) {
+		t.Errorf("on_call without anyone on call = %v, want []", got)
+	}
+	if _, ok := data["description"]; ok || data["escalation_policy"] != "Nobody" || !reflect.DeepEqual(data["teams"], []interface{}{"Payments"}) {
+		t.Errorf("service data = %v", data)
+	}
+
+	users := onCallUserEntities(onCalls)
+	if len(users) != 2 || users[1].Identifier != "PU1" {
+		t.Fatalf("user entities = %+v", users)
+	}
+	if got := users[1].Data["escalation_policies"]; !reflect.DeepEqual(got, []interface{}{"Payments", "Checkout"}) {
+		t.Errorf("escalation_policies of Ada = %v", got)
+	}
+}
+
+func TestOnCallChanges(t *testing.T) {
+	cfg := &PagerDutyConfig{LinkProperty: "pagerduty_service", OnCallProperty: "on_call"}
+	ada := map[string]interface{}{"user": "PU1", "name": "Ada", "email": "ada@acme.com", "level": float64(1)}
+	onCallByService := map[string][]interface{}{"PSVC1": {ada}, "PSVC2": nil}
+	tests := []struct {
+		name    string
+		current map[string]interface{}
+		want    map[string]interface{}
+	}{
+		{"new", map[string]interface{}{"pagerduty_service": "PSVC1"}, map[string]interface{}{"on_call": []interface{}{ada}}},
+		{"unchanged", map[string]interface{}{"pagerduty_service": "PSVC1", "on_call": []interface{}{ada}}, nil},
+		{"nobody on call", map[string]interface{}{"pagerduty_service": "PSVC2", "on_call": []interface{}{ada}}, map[string]interface{}{"on_call": []interface{}{}}},
+		{"unknown service", map[string]interface{}{"pagerduty_service": "PGONE", "on_call": []interface{}{ada}}, map[string]interface{}{"on_call": nil}},
+		{"not linked", map[string]interface{}{"tier": "1"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := onCallChanges(cfg, tt.current, onCallByService); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("onCallChanges = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
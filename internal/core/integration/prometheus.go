@@ -0,0 +1,191 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/integration/prometheus"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+// TypePrometheus integrations write the results of PromQL queries into
+// properties of a blueprint's entities
+const TypePrometheus = "prometheus"
+
+// JobPrometheusSync evaluates a Prometheus integration's queries
+const JobPrometheusSync = "integration.prometheus_sync"
+
+const (
+	// identifierPlaceholder is replaced in queries by the entity's
+	// identifier, escaped for a double-quoted PromQL string
+	identifierPlaceholder = "{{identifier}}"
+	// maxPrometheusQueries bounds the queries of an integration, each run
+	// once per entity
+	maxPrometheusQueries = 20
+	// prometheusWorkers is how many entities are queried at once
+	prometheusWorkers = 4
+)
+
+// PrometheusConfig is the configuration of a Prometheus integration
+type PrometheusConfig struct {
+	URL         string
+	Token       string
+	BlueprintID string
+	// Queries maps properties to the PromQL query whose value they receive
+	Queries map[string]string
+}
+
+// validatePrometheusConfig checks the configuration of a new Prometheus
+// integration. A token must be a secret reference.
+func validatePrometheusConfig(config map[string]interface{}) error {
+	if v, ok := config["token"]; ok && !secret.IsReference(v) {
+		return fmt.Errorf(`%w: token must be a secret reference, {"$secret": "<name>"}`, ErrInvalidConfig)
+	}
+	_, err := parsePrometheusConfig(config)
+	return err
+}
+
+// parsePrometheusConfig reads a Prometheus configuration; the token is read
+// only once resolved
+func parsePrometheusConfig(config map[string]interface{}) (*PrometheusConfig, error) {
+	cfg := &PrometheusConfig{Queries: map[string]string{}}
+	cfg.URL, _ = config["url"].(string)
+	u, err := url.Parse(cfg.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be the http(s) URL of the Prometheus server", ErrInvalidConfig)
+	}
+	cfg.Token, _ = config["token"].(string)
+	cfg.BlueprintID, _ = config["blueprint_id"].(string)
+	if cfg.BlueprintID == "" {
+		return nil, fmt.Errorf("%w: blueprint_id is required", ErrInvalidConfig)
+	}
+
+	queries, _ := config["queries"].(map[string]interface{})
+	if len(queries) == 0 || len(queries) > maxPrometheusQueries {
+		return nil, fmt.Errorf("%w: queries must map 1 to %d properties to PromQL queries", ErrInvalidConfig, maxPrometheusQueries)
+	}
+	for property, v := range queries {
+		query, _ := v.(string)
+		if property == "" || strings.TrimSpace(query) == "" {
+			return nil, fmt.Errorf("%w: query of %q must be a PromQL query", ErrInvalidConfig, property)
+		}
+		cfg.Queries[property] = query
+	}
+	return cfg, nil
+}
+
+// entityQuery returns a query for one entity
+func entityQuery(query, identifier string) string {
+	return strings.ReplaceAll(query, identifierPlaceholder, prometheus.QuoteLabelValue(identifier))
+}
+
+// prometheusChanges returns the merge patch of an entity's data with the
+// queries' values: properties whose value changed, and null for properties
+// whose query returned no data, which removes them. It returns nil when
+// nothing changed.
+func prometheusChanges(current map[string]interface{}, values map[string]*float64) map[string]interface{} {
+	changes := map[string]interface{}{}
+	for property, value := range values {
+		old, exists := current[property]
+		switch {
+		case value == nil && exists:
+			changes[property] = nil
+		case value != nil && (!exists || old != *value):
+			changes[property] = *value
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// syncPrometheus evaluates the queries for every entity of the blueprint
+// and writes the values that changed. Queries that fail leave their
+// property alone; when every query fails, Prometheus is taken to be down and
+// the job fails without the integration counting as synced.
+func (s *Service) syncPrometheus(ctx context.Context, job *jobs.Job) error {
+	var payload syncJob
+	ctx, in, err := s.loadSyncJob(ctx, job, &payload)
+	if err != nil || in == nil {
+		return err
+	}
+	config, err := s.cachedConfig(ctx, in)
+	if err != nil {
+		return err
+	}
+	cfg, err := parsePrometheusConfig(config)
+	if err != nil {
+		return jobs.Permanent(err)
+	}
+
+	entities, err := s.listEntities(ctx, in.TeamID, cfg.BlueprintID)
+	if err != nil {
+		return err
+	}
+
+	client := prometheus.NewClient(cfg.URL, cfg.Token, s.http)
+	var (
+		mu        sync.Mutex
+		evaluated int
+		failures  []error
+		work      = make(chan *entity.Entity)
+		wg        sync.WaitGroup
+	)
+	for i := 0; i < prometheusWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range work {
+				n, errs := s.enrichEntity(ctx, client, cfg, e)
+				mu.Lock()
+				evaluated += n
+				failures = append(failures, errs...)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, e := range entities {
+		work <- e
+	}
+	close(work)
+	wg.Wait()
+
+	if len(failures) > 0 {
+		log.Printf("WARNING: prometheus integration %s: %d of %d queries failed, first: %v", in.ID, len(failures), len(failures)+evaluated, failures[0])
+		if evaluated == 0 {
+			return fmt.Errorf("every query failed, first: %w", failures[0])
+		}
+	}
+	return s.repo.MarkSynced(ctx, in)
+}
+
+// enrichEntity evaluates the queries for one entity and patches the values
+// that changed. It returns how many queries it evaluated and the errors of
+// the queries and the write.
+func (s *Service) enrichEntity(ctx context.Context, client *prometheus.Client, cfg *PrometheusConfig, e *entity.Entity) (int, []error) {
+	var errs []error
+	values := map[string]*float64{}
+	for property, query := range cfg.Queries {
+		value, ok, err := client.Query(ctx, entityQuery(query, e.Identifier))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s %s: %w", e.Identifier, property, err))
+			continue
+		}
+		values[property] = nil
+		if ok {
+			values[property] = &value
+		}
+	}
+
+	if err := s.patchData(ctx, e, prometheusChanges(e.Data, values)); err != nil {
+		errs = append(errs, err)
+	}
+	return len(values), errs
+}
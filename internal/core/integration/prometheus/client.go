@@ -0,0 +1,130 @@
+// Package prometheus evaluates PromQL queries against the Prometheus HTTP
+// API, or any API compatible with it, for the Prometheus integration.
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrMultipleSeries is returned for queries that return more than one
+// series, which have no single value to write
+var ErrMultipleSeries = errors.New("query returned more than one series; aggregate it, e.g. with sum()")
+
+// Client calls the query API of one Prometheus server
+type Client struct {
+	url   string
+	token string
+	http  *http.Client
+}
+
+// NewClient returns a client of the server at baseURL. A token is sent as a
+// bearer token when set.
+func NewClient(baseURL, token string, httpClient *http.Client) *Client {
+	return &Client{url: strings.TrimSuffix(baseURL, "/"), token: token, http: httpClient}
+}
+
+// QueryError is a query Prometheus rejected or failed to evaluate
+type QueryError struct {
+	Type    string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("prometheus %s: %s", e.Type, e.Message)
+}
+
+// Query evaluates an instant query and returns its value. ok is false when
+// it returned no data, or a value JSON cannot hold such as NaN.
+func (c *Client) Query(ctx context.Context, query string) (value float64, ok bool, err error) {
+	form := url.Values{"query": {query}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/api/v1/query", strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+		Data      struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&body); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return 0, false, fmt.Errorf("prometheus returned %d", resp.StatusCode)
+		}
+		return 0, false, fmt.Errorf("decoding prometheus response: %w", err)
+	}
+	if body.Status != "success" {
+		return 0, false, &QueryError{Type: body.ErrorType, Message: body.Error}
+	}
+
+	var sample []interface{}
+	switch body.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(body.Data.Result, &sample); err != nil {
+			return 0, false, fmt.Errorf("decoding prometheus scalar: %w", err)
+		}
+	case "vector":
+		var series []struct {
+			Value []interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(body.Data.Result, &series); err != nil {
+			return 0, false, fmt.Errorf("decoding prometheus vector: %w", err)
+		}
+		if len(series) == 0 {
+			return 0, false, nil
+		}
+		if len(series) > 1 {
+			return 0, false, ErrMultipleSeries
+		}
+		sample = series[0].Value
+	default:
+		return 0, false, fmt.Errorf("query returned a %s; instant queries must return a vector or scalar", body.Data.ResultType)
+	}
+
+	// Samples are [<unix time>, "<value>"]
+	if len(sample) != 2 {
+		return 0, false, fmt.Errorf("unexpected prometheus sample %v", sample)
+	}
+	raw, _ := sample[1].(string)
+	value, err = strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("unexpected prometheus value %q", raw)
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false, nil
+	}
+	return value, true, nil
+}
+
+// maxResponseBytes bounds the responses read; single-series results are
+// small
+const maxResponseBytes = 1 << 20
+
+// QuoteLabelValue escapes a value for a double-quoted PromQL string, such as
+// the value of a label matcher
+func QuoteLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,64 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Query(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query" || r.Method != http.MethodPost {
+			t.Errorf("request %s %s", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer prom-token" {
+			t.Errorf("Authorization = %q", got)
+		}
+		switch r.PostFormValue("query") {
+		case "one":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"service":"api"},"value":[1760000000.1,"0.025"]}]}}`)
+		case "none":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[]}}`)
+		case "many":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"value":[1,"1"]},{"value":[1,"2"]}]}}`)
+		case "scalar":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"scalar","result":[1760000000,"42"]}}`)
+		case "nan":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"vector","result":[{"value":[1,"NaN"]}]}}`)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"status":"error","errorType":"bad_data","error":"parse error"}`)
+		}
+	}))
+	defer server.Close()
+	client := NewClient(server.URL+"/", "prom-token", server.Client())
+	ctx := context.Background()
+
+	if v, ok, err := client.Query(ctx, "one"); err != nil || !ok || v != 0.025 {
+		t.Errorf("Query(one) = %v, %v, %v; want 0.025", v, ok, err)
+	}
+	if v, ok, err := client.Query(ctx, "scalar"); err != nil || !ok || v != 42 {
+		t.Errorf("Query(scalar) = %v, %v, %v; want 42", v, ok, err)
+	}
+	for _, query := range []string{"none", "nan"} {
+		if _, ok, err := client.Query(ctx, query); err != nil || ok {
+			t.Errorf("Query(%s) = %v, %v; want no data", query, ok, err)
+		}
+	}
+	if _, _, err := client.Query(ctx, "many"); !errors.Is(err, ErrMultipleSeries) {
+		t.Errorf("Query(many) error = %v, want ErrMultipleSeries", err)
+	}
+	var queryErr *QueryError
+	if _, _, err := client.Query(ctx, "sum("); !errors.As(err, &queryErr) || queryErr.Type != "bad_data" {
+		t.Errorf("Query(invalid) error = %v, want a bad_data QueryError", err)
+	}
+}
+
+func TestQuoteLabelValue(t *testing.T) {
+	if got, want := QuoteLabelValue(`a"b\c`), `a\"b\\c`; got != want {
+		t.Errorf("QuoteLabelValue = %s, want %s", got, want)
+	}
+}
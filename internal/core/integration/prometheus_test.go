@@ -0,0 +1,58 @@
+package integration
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestValidatePrometheusConfig(t *testing.T) {
+	queries := map[string]interface{}{"error_rate": `sum(rate(http_errors_total{service="{{identifier}}"}[5m]))`}
+	tests := []struct {
+		name    string
+		config  map[string]interface{}
+		wantErr bool
+	}{
+		{"minimal", map[string]interface{}{"url": "http://prometheus:9090", "blueprint_id": "service", "queries": queries}, false},
+		{"token", map[string]interface{}{"url": "https://prom.acme.com", "token": map[string]interface{}{"$secret": "prom"}, "blueprint_id": "service", "queries": queries}, false},
+		{"literal token", map[string]interface{}{"url": "https://prom.acme.com", "token": "abc", "blueprint_id": "service", "queries": queries}, true},
+		{"no url", map[string]interface{}{"blueprint_id": "service", "queries": queries}, true},
+		{"no blueprint", map[string]interface{}{"url": "http://prometheus:9090", "queries": queries}, true},
+		{"no queries", map[string]interface{}{"url": "http://prometheus:9090", "blueprint_id": "service"}, true},
+		{"empty query", map[string]interface{}{"url": "http://prometheus:9090", "blueprint_id": "service", "queries": map[string]interface{}{"p95": " "}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validatePrometheusConfig(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validatePrometheusConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidConfig) {
+				t.Errorf("error = %v, want ErrInvalidConfig", err)
+			}
+		})
+	}
+}
+
+func TestEntityQuery(t *testing.T) {
+	got := entityQuery(`rate(errors{service="{{identifier}}"}[5m]) / rate(requests{service="{{identifier}}"}[5m])`, `we"ird`)
+	want := `rate(errors{service="we\"ird"}[5m]) / rate(requests{service="we\"ird"}[5m])`
+	if got != want {
+		t.Errorf("entityQuery = %s, want %s", got, want)
+	}
+}
+
+func TestPrometheusChanges(t *testing.T) {
+	rate, latency := 0.02, 120.0
+	current := map[string]interface{}{"error_rate": 0.02, "p95_latency": 95.0, "saturation": 0.5, "tier": "gold"}
+	values := map[string]*float64{"error_rate": &rate, "p95_latency": &latency, "saturation": nil, "apdex": nil}
+
+	got := prometheusChanges(current, values)
+	want := map[string]interface{}{"p95_latency": 120.0, "saturation": nil}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("prometheusChanges = %v, want %v", got, want)
+	}
+	if got := prometheusChanges(current, map[string]*float64{"error_rate": &rate}); got != nil {
+		t.Errorf("prometheusChanges without changes = %v, want nil", got)
+	}
+}
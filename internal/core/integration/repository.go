@@ -0,0 +1,148 @@
+package integration
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const integrationColumns = `id, team_id, type, name, config, status, last_sync_at, created_at`
+
+func (r *Repository) Create(ctx context.Context, in *Integration) error {
+	config, err := json.Marshal(in.Config)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO integrations (id, team_id, type, name, config, status)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`
+
+	return r.db.DB.QueryRowContext(ctx, query,
+		in.ID, in.TeamID, in.Type, in.Name, config, in.Status,
+	).Scan(&in.CreatedAt)
+}
+
+func (r *Repository) GetByID(ctx context.Context, teamID, id uuid.UUID) (*Integration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM integrations WHERE team_id = $1 AND id = $2`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	integrations, err := scanIntegrations(rows)
+	if err != nil || len(integrations) == 0 {
+		return nil, err
+	}
+	return integrations[0], nil
+}
+
+// Find returns an integration of any team, for requests that identify the
+// team by the integration, such as webhooks
+func (r *Repository) Find(ctx context.Context, id uuid.UUID) (*Integration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM integrations WHERE id = $1`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	integrations, err := scanIntegrations(rows)
+	if err != nil || len(integrations) == 0 {
+		return nil, err
+	}
+	return integrations[0], nil
+}
+
+// ListByType returns the integrations of every team of one type
+func (r *Repository) ListByType(ctx context.Context, integrationType string) ([]*Integration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM integrations WHERE type = $1 ORDER BY created_at, id`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, integrationType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanIntegrations(rows)
+}
+
+func (r *Repository) List(ctx context.Context, teamID uuid.UUID) ([]*Integration, error) {
+	query := `SELECT ` + integrationColumns + ` FROM integrations WHERE team_id = $1 ORDER BY name, id`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanIntegrations(rows)
+}
+
+// Delete removes an integration; its entities stay, without an owner. It
+// reports whether the integration existed.
+func (r *Repository) Delete(ctx context.Context, teamID, id uuid.UUID) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM integrations WHERE team_id = $1 AND id = $2`, teamID, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// MarkSynced records a completed sync
+func (r *Repository) MarkSynced(ctx context.Context, in *Integration) error {
+	query := `
+		UPDATE integrations SET status = $2, last_sync_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING last_sync_at`
+	in.Status = StatusActive
+	return r.db.DB.QueryRowContext(ctx, query, in.ID, in.Status).Scan(&in.LastSyncAt)
+}
+
+// MarkStale marks active integrations last synced before the given time as
+// stale and returns how many it marked
+func (r *Repository) MarkStale(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.DB.ExecContext(ctx,
+		`UPDATE integrations SET status = $1 WHERE status = $2 AND last_sync_at < $3`,
+		StatusStale, StatusActive, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanIntegrations(rows *sql.Rows) ([]*Integration, error) {
+	var integrations []*Integration
+	for rows.Next() {
+		in := &Integration{}
+		var config []byte
+		var status sql.NullString
+		var lastSync sql.NullTime
+		if err := rows.Scan(&in.ID, &in.TeamID, &in.Type, &in.Name, &config, &status, &lastSync, &in.CreatedAt); err != nil {
+			return nil, err
+		}
+		in.Status = status.String
+		if lastSync.Valid {
+			in.LastSyncAt = &lastSync.Time
+		}
+		if err := json.Unmarshal(config, &in.Config); err != nil {
+			return nil, err
+		}
+		integrations = append(integrations, in)
+	}
+	return integrations, rows.Err()
+}
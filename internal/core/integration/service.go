@@ -0,0 +1,145 @@
+package integration
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+var ErrNotFound = errors.New("integration not found")
+
+type Service struct {
+	repo      *Repository
+	entitySvc *entity.Service
+	secrets   *secret.Service
+	queue     *jobs.Queue
+	http      *http.Client
+
+	mu      sync.Mutex
+	configs map[uuid.UUID]cachedConfig
+}
+
+// NewService creates the integration service. Configurations may refer to
+// team secrets, which secrets checks and resolves. The sync jobs of the
+// integration types Baseplate syncs are registered with queue.
+func NewService(repo *Repository, entitySvc *entity.Service, secrets *secret.Service, queue *jobs.Queue) *Service {
+	s := &Service{
+		repo:      repo,
+		entitySvc: entitySvc,
+		secrets:   secrets,
+		queue:     queue,
+		http:      &http.Client{Timeout: 30 * time.Second},
+		configs:   make(map[uuid.UUID]cachedConfig),
+	}
+	queue.RegisterLong(JobGitHubSync, s.syncGitHub)
+	queue.Register(JobGitHubRepository, s.syncGitHubRepository)
+	queue.RegisterLong(JobKubernetesSync, s.syncKubernetes)
+	queue.RegisterLong(JobPrometheusSync, s.syncPrometheus)
+	queue.RegisterLong(JobPagerDutySync, s.syncPagerDuty)
+	return s
+}
+
+func (s *Service) Create(ctx context.Context, teamID uuid.UUID, req *CreateIntegrationRequest) (*Integration, error) {
+	in := &Integration{
+		ID:     uuid.New(),
+		TeamID: teamID,
+		Type:   req.Type,
+		Name:   req.Name,
+		Config: req.Config,
+		Status: StatusInactive,
+	}
+	if in.Config == nil {
+		in.Config = map[string]interface{}{}
+	}
+	if err := validateConfig(in.Type, in.Config); err != nil {
+		return nil, err
+	}
+	if err := s.secrets.CheckReferences(ctx, teamID, in.Config); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, in); err != nil {
+		return nil, err
+	}
+	return in, nil
+}
+
+func (s *Service) Get(ctx context.Context, teamID, id uuid.UUID) (*Integration, error) {
+	in, err := s.repo.GetByID(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	if in == nil {
+		return nil, ErrNotFound
+	}
+	return in, nil
+}
+
+// ResolvedConfig returns an integration's configuration with its secret
+// references replaced by their values, for the exporter that runs it. Each
+// secret read is audited.
+func (s *Service) ResolvedConfig(ctx context.Context, teamID, id uuid.UUID, userID *uuid.UUID, ipAddress, userAgent *string) (map[string]interface{}, error) {
+	in, err := s.Get(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := s.secrets.Resolve(ctx, teamID, in.Config, "integration:"+in.ID.String(), userID, ipAddress, userAgent)
+	if err != nil {
+		return nil, err
+	}
+	config, _ := resolved.(map[string]interface{})
+	return config, nil
+}
+
+func (s *Service) List(ctx context.Context, teamID uuid.UUID) (*ListIntegrationsResponse, error) {
+	integrations, err := s.repo.List(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if integrations == nil {
+		integrations = []*Integration{}
+	}
+	return &ListIntegrationsResponse{Integrations: integrations, Total: len(integrations)}, nil
+}
+
+func (s *Service) Delete(ctx context.Context, teamID, id uuid.UUID) error {
+	found, err := s.repo.Delete(ctx, teamID, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkStale marks integrations of every team whose exporter has not synced
+// within staleAfter as stale, and returns how many it marked
+func (s *Service) MarkStale(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	return s.repo.MarkStale(ctx, time.Now().Add(-staleAfter))
+}
+
+// Reconcile compares the integration's entities with the identifiers its
+// exporter reports, see entity.Service.Reconcile, and records the sync
+func (s *Service) Reconcile(ctx context.Context, teamID, id uuid.UUID, req *entity.ReconcileRequest) (*entity.ReconcileResponse, error) {
+	in, err := s.Get(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.entitySvc.Reconcile(ctx, teamID, in.ID, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.MarkSynced(ctx, in); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
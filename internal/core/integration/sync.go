@@ -0,0 +1,246 @@
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+// configTTL is how long the resolved configuration of an integration that
+// Baseplate syncs is reused, so that syncs and webhooks do not read, and
+// audit, its secrets every time
+const configTTL = 5 * time.Minute
+
+// entityPageSize is the page size the entities of a blueprint are read in
+const entityPageSize = 100
+
+var (
+	ErrInvalidConfig = errors.New("invalid integration config")
+	ErrNotSynced     = errors.New("integration type is not synced by Baseplate, only github, kubernetes, prometheus and pagerduty are")
+)
+
+// syncJobs are the full sync jobs of the integration types Baseplate syncs
+var syncJobs = map[string]string{
+	TypeGitHub:     JobGitHubSync,
+	TypeKubernetes: JobKubernetesSync,
+	TypePrometheus: JobPrometheusSync,
+	TypePagerDuty:  JobPagerDutySync,
+}
+
+// syncJob is the payload of the sync jobs
+type syncJob struct {
+	IntegrationID uuid.UUID `json:"integration_id"`
+	TeamID        uuid.UUID `json:"team_id"`
+}
+
+type cachedConfig struct {
+	config  map[string]interface{}
+	expires time.Time
+}
+
+// validateConfig checks the configuration of a new integration of a type
+// Baseplate runs; the configuration of other types is their exporter's
+func validateConfig(integrationType string, config map[string]interface{}) error {
+	switch integrationType {
+	case TypeGitHub:
+		return validateGitHubConfig(config)
+	case TypeKubernetes:
+		return validateKubernetesConfig(config)
+	case TypePrometheus:
+		return validatePrometheusConfig(config)
+	case TypePagerDuty:
+		return validatePagerDutyConfig(config)
+	case TypeIngest:
+		return validateIngestConfig(config)
+	}
+	return nil
+}
+
+// Sync queues a full sync of an integration Baseplate syncs itself
+func (s *Service) Sync(ctx context.Context, teamID, id uuid.UUID) (*jobs.Job, error) {
+	in, err := s.Get(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	kind, ok := syncJobs[in.Type]
+	if !ok {
+		return nil, ErrNotSynced
+	}
+	return s.queue.Enqueue(ctx, kind, syncJob{IntegrationID: in.ID, TeamID: in.TeamID}, nil)
+}
+
+// QueueSyncs queues a full sync of every integration of a type Baseplate
+// syncs, and returns how many it queued. Integrations created for an
+// exporter before Baseplate synced their type are left to the exporter.
+func (s *Service) QueueSyncs(ctx context.Context, integrationType string) (int, error) {
+	kind, ok := syncJobs[integrationType]
+	if !ok {
+		return 0, ErrNotSynced
+	}
+	integrations, err := s.repo.ListByType(ctx, integrationType)
+	if err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, in := range integrations {
+		if validateConfig(in.Type, in.Config) != nil {
+			continue
+		}
+		if _, err := s.queue.Enqueue(ctx, kind, syncJob{IntegrationID: in.ID, TeamID: in.TeamID}, nil); err != nil {
+			return 0, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// cachedConfig returns the configuration of an integration with its secrets
+// resolved, reusing it for configTTL
+func (s *Service) cachedConfig(ctx context.Context, in *Integration) (map[string]interface{}, error) {
+	s.mu.Lock()
+	cached, ok := s.configs[in.ID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.config, nil
+	}
+
+	resolved, err := s.secrets.Resolve(ctx, in.TeamID, in.Config, "integration:"+in.ID.String(), nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	config, _ := resolved.(map[string]interface{})
+	s.mu.Lock()
+	s.configs[in.ID] = cachedConfig{config: config, expires: time.Now().Add(configTTL)}
+	s.mu.Unlock()
+	return config, nil
+}
+
+// loadSyncJob decodes the payload of a sync job into payload and returns
+// its integration with a context recording the integration as the writer.
+// A deleted integration returns nil.
+func (s *Service) loadSyncJob(ctx context.Context, job *jobs.Job, payload interface{}) (context.Context, *Integration, error) {
+	var ids syncJob
+	if err := json.Unmarshal(job.Payload, &ids); err != nil {
+		return nil, nil, jobs.Permanent(err)
+	}
+	if err := json.Unmarshal(job.Payload, payload); err != nil {
+		return nil, nil, jobs.Permanent(err)
+	}
+	in, err := s.repo.GetByID(ctx, ids.TeamID, ids.IntegrationID)
+	if err != nil || in == nil {
+		return nil, nil, err
+	}
+	ctx, err = s.entitySvc.AsIntegration(ctx, in.TeamID, in.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ctx, in, nil
+}
+
+// writeEntities upserts entities of a blueprint as the integration. A
+// missing blueprint, or more rows than an import takes, fails for good.
+func (s *Service) writeEntities(ctx context.Context, in *Integration, blueprintID string, rows []*entity.CreateEntityRequest) (*entity.ImportResult, error) {
+	if len(rows) == 0 {
+		return &entity.ImportResult{}, nil
+	}
+	var file bytes.Buffer
+	encoder := json.NewEncoder(&file)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	result, err := s.entitySvc.Import(ctx, in.TeamID, blueprintID, entity.FormatNDJSON, &file, entity.ImportOptions{Mode: entity.ImportUpsert})
+	if errors.Is(err, entity.ErrBlueprintNotFound) || errors.Is(err, entity.ErrInvalidImport) {
+		return nil, jobs.Permanent(fmt.Errorf("%s: %w", blueprintID, err))
+	}
+	return result, err
+}
+
+// replaceEntities upserts the entities of a blueprint read in full and
+// deletes the integration's other entities of it. The returned failure
+// reports entities that could not be written.
+func (s *Service) replaceEntities(ctx context.Context, in *Integration, blueprintID string, rows []*entity.CreateEntityRequest) (failure, err error) {
+	identifiers := make([]string, 0, len(rows))
+	for _, row := range rows {
+		identifiers = append(identifiers, row.Identifier)
+	}
+	result, err := s.writeEntities(ctx, in, blueprintID, rows)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.Reconcile(ctx, in.TeamID, in.ID, &entity.ReconcileRequest{
+		BlueprintID: blueprintID,
+		Identifiers: identifiers,
+		Delete:      true,
+		AllowEmpty:  true,
+	}); err != nil {
+		return nil, err
+	}
+	return importFailure(in, blueprintID, result), nil
+}
+
+// importFailure reports entities of a sync that could not be written,
+// typically because the blueprint's schema does not accept them. Retrying
+// cannot fix that, so the error is permanent.
+func importFailure(in *Integration, blueprintID string, result *entity.ImportResult) error {
+	if result.Failed == 0 {
+		return nil
+	}
+	first := result.Errors[0]
+	message := first.Error
+	for _, detail := range first.Details {
+		message += "; " + detail.Message
+	}
+	log.Printf("WARNING: %s integration %s: %d %s entities not written, first %s: %s", in.Type, in.ID, result.Failed, blueprintID, first.Identifier, message)
+	return jobs.Permanent(fmt.Errorf("%d of %d %s entities not written, first %s: %s", result.Failed, result.Total, blueprintID, first.Identifier, message))
+}
+
+// listEntities returns every entity of a blueprint. A missing blueprint
+// fails for good.
+func (s *Service) listEntities(ctx context.Context, teamID uuid.UUID, blueprintID string) ([]*entity.Entity, error) {
+	var entities []*entity.Entity
+	for offset := 0; ; offset += entityPageSize {
+		page, err := s.entitySvc.List(ctx, teamID, blueprintID, entityPageSize, offset, "")
+		if errors.Is(err, entity.ErrBlueprintNotFound) {
+			return nil, jobs.Permanent(fmt.Errorf("%s: %w", blueprintID, err))
+		}
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, page.Entities...)
+		if len(page.Entities) < entityPageSize {
+			return entities, nil
+		}
+	}
+}
+
+// patchData merge-patches changes into an entity's data as the integration;
+// null removes a property. Nil changes write nothing, and an entity deleted
+// since it was read is left alone.
+func (s *Service) patchData(ctx context.Context, e *entity.Entity, changes map[string]interface{}) error {
+	if changes == nil {
+		return nil
+	}
+	body, err := json.Marshal(map[string]interface{}{"data": changes})
+	if err != nil {
+		return err
+	}
+	patch, err := entity.ParsePatch(entity.MergePatchType, body)
+	if err != nil {
+		return err
+	}
+	if _, err := s.entitySvc.Patch(ctx, e.ID, patch, 0); err != nil && !errors.Is(err, entity.ErrNotFound) {
+		return fmt.Errorf("%s: %w", e.Identifier, err)
+	}
+	return nil
+}
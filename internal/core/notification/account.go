@@ -0,0 +1,38 @@
+package notification
+
+import (
+	"context"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/core/auth"
+)
+
+// AccountMailer sends the auth service's account emails through the
+// notification email channel
+type AccountMailer struct {
+	sender Sender
+}
+
+// NewAccountMailer returns the mailer of account emails, or nil when no SMTP
+// host is configured: account emails carry tokens, which must never go to
+// the log
+func NewAccountMailer(cfg config.NotificationsConfig) auth.Mailer {
+	if cfg.SMTPHost == "" {
+		return nil
+	}
+	return &AccountMailer{sender: &SMTPSender{cfg: cfg}}
+}
+
+func (m *AccountMailer) SendEmailVerification(ctx context.Context, user *auth.User, email, token string) error {
+	return m.sender.Send(ctx, email, &Message{
+		Subject: "Confirm your new Baseplate email address",
+		Lines: []string{
+			"A change of the email address of your Baseplate account to " + email + " was requested.",
+			"Confirm it within 24 hours with POST /api/auth/verify-email and this token:",
+			"",
+			token,
+			"",
+			"If you did not ask for this change, ignore this email; your address stays as it is.",
+		},
+	})
+}
@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidSubscription = errors.New("invalid notification subscription")
+	ErrNotFound            = errors.New("notification subscription not found")
+	ErrForbidden           = errors.New("only team managers can manage team-wide subscriptions")
+	ErrUserRequired        = errors.New("personal subscriptions need a user; API keys without one can only manage team-wide subscriptions")
+)
+
+// Events a subscription can notify on
+const (
+	EventEntityDeleted     = "entity.deleted"
+	EventActionFailed      = "action_run.failed"
+	EventScorecardDegraded = "scorecard.degraded"
+)
+
+var knownEvents = []string{EventEntityDeleted, EventActionFailed, EventScorecardDegraded}
+
+// Channels notifications are delivered on
+const (
+	ChannelEmail = "email"
+	ChannelSlack = "slack"
+)
+
+// Digests: immediate subscriptions are delivered within a minute, the others
+// once their oldest pending notification is an hour or a day old, in one
+// message
+const (
+	DigestImmediate = "immediate"
+	DigestHourly    = "hourly"
+	DigestDaily     = "daily"
+)
+
+// Scopes: a personal subscription is delivered to its user, a team one to
+// the recipient set by a team manager
+const (
+	ScopePersonal = "personal"
+	ScopeTeam     = "team"
+)
+
+// Delivery statuses
+const (
+	StatusSent   = "sent"
+	StatusFailed = "failed"
+	// StatusSkipped marks emails written to the server log because no SMTP
+	// server is configured
+	StatusSkipped = "skipped"
+)
+
+// Subscription routes matching catalog events of a team to a channel
+type Subscription struct {
+	ID     uuid.UUID  `json:"id"`
+	TeamID uuid.UUID  `json:"team_id"`
+	Scope  string     `json:"scope"`
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+	Events []string   `json:"events"`
+	// BlueprintID, when set, limits the subscription to one blueprint's events
+	BlueprintID *string `json:"blueprint_id,omitempty"`
+	Channel     string  `json:"channel"`
+	// Email is the recipient of team email subscriptions; personal ones go
+	// to the user's address
+	Email *string `json:"email,omitempty"`
+	// WebhookSecret names the team secret holding a Slack webhook URL
+	WebhookSecret   *string    `json:"webhook_secret,omitempty"`
+	Digest          string     `json:"digest"`
+	CreatedBy       *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty"`
+
+	// userEmail is the address of a personal subscription's user
+	userEmail string
+}
+
+type CreateSubscriptionRequest struct {
+	Scope         string   `json:"scope"`
+	Events        []string `json:"events" binding:"required"`
+	BlueprintID   *string  `json:"blueprint_id"`
+	Channel       string   `json:"channel" binding:"required"`
+	Email         *string  `json:"email"`
+	WebhookSecret *string  `json:"webhook_secret"`
+	Digest        string   `json:"digest"`
+}
+
+type ListSubscriptionsResponse struct {
+	Subscriptions []*Subscription `json:"subscriptions"`
+}
+
+// Subscriber describes the caller managing subscriptions
+type Subscriber struct {
+	UserID *uuid.UUID
+	// Manager can manage team subscriptions and every personal one
+	Manager bool
+}
+
+// Item is a notification of one event
+type Item struct {
+	ID          int64
+	Event       string
+	TeamID      uuid.UUID
+	BlueprintID string
+	Title       string
+	Detail      string
+	OccurredAt  time.Time
+}
+
+// Delivery is the log entry of one message sent to a subscription
+type Delivery struct {
+	ID             int64      `json:"id"`
+	SubscriptionID *uuid.UUID `json:"subscription_id,omitempty"`
+	Channel        string     `json:"channel"`
+	Recipient      string     `json:"recipient"`
+	Notifications  int        `json:"notifications"`
+	Status         string     `json:"status"`
+	Error          string     `json:"error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type ListDeliveriesRequest struct {
+	Status string
+	Limit  int
+	Offset int
+}
+
+type ListDeliveriesResponse struct {
+	Deliveries []*Delivery `json:"deliveries"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+}
+
+// RunResult is what one run of the notifications task did
+type RunResult struct {
+	Collected int `json:"collected"`
+	Delivered int `json:"delivered"`
+	Failed    int `json:"failed"`
+	Skipped   int `json:"skipped"`
+}
@@ -0,0 +1,351 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const subscriptionColumns = `s.id, s.team_id, s.user_id, s.events, s.blueprint_id, s.channel, s.email,
+	s.webhook_secret, s.digest, s.created_by, s.created_at, s.last_delivered_at, COALESCE(u.email, '')`
+
+// Create stores a subscription. A blueprint that is not the team's makes it
+// fail with ErrInvalidSubscription.
+func (r *Repository) Create(ctx context.Context, sub *Subscription) error {
+	query := `
+		INSERT INTO notification_subscriptions (team_id, user_id, events, blueprint_id, channel, email, webhook_secret, digest, created_by)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9
+		WHERE $4::text IS NULL OR EXISTS (SELECT 1 FROM blueprints WHERE id = $4 AND team_id = $1)
+		RETURNING id, created_at`
+	err := r.db.DB.QueryRowContext(ctx, query, sub.TeamID, sub.UserID, sub.Events, sub.BlueprintID,
+		sub.Channel, sub.Email, sub.WebhookSecret, sub.Digest, sub.CreatedBy).Scan(&sub.ID, &sub.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidSubscription
+	}
+	return err
+}
+
+// List returns a team's subscriptions; with userID, only team-wide ones and
+// the user's own
+func (r *Repository) List(ctx context.Context, teamID uuid.UUID, userID *uuid.UUID, all bool) ([]*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+		FROM notification_subscriptions s
+		LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.team_id = $1 AND ($3 OR s.user_id IS NULL OR s.user_id = $2)
+		ORDER BY s.created_at, s.id`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, userID, all)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSubscriptions(rows)
+}
+
+func (r *Repository) Get(ctx context.Context, teamID, id uuid.UUID) (*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+		FROM notification_subscriptions s
+		LEFT JOIN users u ON u.id = s.user_id
+		WHERE s.team_id = $1 AND s.id = $2`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subs, err := scanSubscriptions(rows)
+	if err != nil || len(subs) == 0 {
+		return nil, err
+	}
+	return subs[0], nil
+}
+
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM notification_subscriptions WHERE id = $1`, id)
+	return err
+}
+
+// Enqueue queues an item for every subscription of its team that matches
+// it. Personal subscriptions of users who left the team do not match.
+func (r *Repository) Enqueue(ctx context.Context, item *Item) (int64, error) {
+	query := `
+		INSERT INTO notification_queue (subscription_id, event, title, detail, occurred_at)
+		SELECT s.id, $2, $4, $5, $6
+		FROM notification_subscriptions s
+		WHERE s.team_id = $1 AND $2 = ANY(s.events)
+		  AND (s.blueprint_id IS NULL OR s.blueprint_id = $3)
+		  AND (s.user_id IS NULL OR EXISTS (
+			SELECT 1 FROM team_memberships m WHERE m.team_id = s.team_id AND m.user_id = s.user_id))`
+	result, err := r.db.DB.ExecContext(ctx, query, item.TeamID, item.Event, item.BlueprintID, item.Title, item.Detail, item.OccurredAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Due returns the subscriptions with queued items whose digest is due at
+// now: immediate ones at once, digests once their oldest item is an hour or
+// a day old. Subscriptions whose last delivery failed after retryAfter wait.
+func (r *Repository) Due(ctx context.Context, now, retryAfter time.Time, limit int) ([]*Subscription, error) {
+	query := `SELECT ` + subscriptionColumns + `
+		FROM notification_subscriptions s
+		JOIN (
+			SELECT subscription_id, MIN(created_at) AS oldest FROM notification_queue GROUP BY subscription_id
+		) q ON q.subscription_id = s.id
+		LEFT JOIN users u ON u.id = s.user_id
+		WHERE q.oldest <= $1::timestamptz - CASE s.digest
+			WHEN 'hourly' THEN INTERVAL '1 hour'
+			WHEN 'daily' THEN INTERVAL '1 day'
+			ELSE INTERVAL '0' END
+		  AND NOT EXISTS (
+			SELECT 1 FROM notification_deliveries d
+			WHERE d.subscription_id = s.id AND d.status = 'failed' AND d.created_at > $3
+			  AND d.created_at > COALESCE(s.last_delivered_at, '-infinity'))
+		ORDER BY q.oldest
+		LIMIT $2`
+	rows, err := r.db.DB.QueryContext(ctx, query, now, limit, retryAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSubscriptions(rows)
+}
+
+// Pending returns up to limit queued items of a subscription, oldest first
+func (r *Repository) Pending(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]*Item, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `
+		SELECT id, event, title, detail, occurred_at FROM notification_queue
+		WHERE subscription_id = $1
+		ORDER BY id
+		LIMIT $2`, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Item
+	for rows.Next() {
+		item := &Item{}
+		if err := rows.Scan(&item.ID, &item.Event, &item.Title, &item.Detail, &item.OccurredAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Delivered removes the items of a subscription up to lastID and logs the
+// delivery, in one transaction; only a sent one sets last_delivered_at
+func (r *Repository) Delivered(ctx context.Context, sub *Subscription, lastID int64, d *Delivery) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM notification_queue WHERE subscription_id = $1 AND id <= $2`, sub.ID, lastID); err != nil {
+		return err
+	}
+	if d.Status == StatusSent {
+		if _, err := tx.ExecContext(ctx, `UPDATE notification_subscriptions SET last_delivered_at = NOW() WHERE id = $1`, sub.ID); err != nil {
+			return err
+		}
+	}
+	if err := logDelivery(ctx, tx, sub, d); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LogDelivery records a delivery without touching the queue, for failures
+func (r *Repository) LogDelivery(ctx context.Context, sub *Subscription, d *Delivery) error {
+	return logDelivery(ctx, r.db.DB, sub, d)
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func logDelivery(ctx context.Context, db execer, sub *Subscription, d *Delivery) error {
+	var deliveryErr *string
+	if d.Error != "" {
+		deliveryErr = &d.Error
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO notification_deliveries (team_id, subscription_id, channel, recipient, notifications, status, error)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		sub.TeamID, sub.ID, d.Channel, d.Recipient, d.Notifications, d.Status, deliveryErr)
+	return err
+}
+
+// DropExpired removes queued items older than the given time, which keep
+// failing to be delivered
+func (r *Repository) DropExpired(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM notification_queue WHERE created_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Deliveries returns the delivery log of a team, newest first
+func (r *Repository) Deliveries(ctx context.Context, teamID uuid.UUID, req *ListDeliveriesRequest) ([]*Delivery, int, error) {
+	var total int
+	err := r.db.Reader(ctx).QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM notification_deliveries WHERE team_id = $1 AND ($2 = '' OR status = $2)`,
+		teamID, req.Status).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, `
+		SELECT id, subscription_id, channel, recipient, notifications, status, COALESCE(error, ''), created_at
+		FROM notification_deliveries
+		WHERE team_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`, teamID, req.Status, req.Limit, req.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var deliveries []*Delivery
+	for rows.Next() {
+		d := &Delivery{}
+		if err := rows.Scan(&d.ID, &d.SubscriptionID, &d.Channel, &d.Recipient, &d.Notifications, &d.Status, &d.Error, &d.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, total, rows.Err()
+}
+
+// Cursor returns how far a source has been collected, and false when it
+// never was
+func (r *Repository) Cursor(ctx context.Context, source string) (time.Time, bool, error) {
+	var until time.Time
+	err := r.db.DB.QueryRowContext(ctx,
+		`SELECT collected_until FROM notification_cursors WHERE source = $1`, source).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	return until, err == nil, err
+}
+
+func (r *Repository) SetCursor(ctx context.Context, source string, until time.Time) error {
+	_, err := r.db.DB.ExecContext(ctx, `
+		INSERT INTO notification_cursors (source, collected_until) VALUES ($1, $2)
+		ON CONFLICT (source) DO UPDATE SET collected_until = EXCLUDED.collected_until`, source, until)
+	return err
+}
+
+// FailedRun is an action run that failed
+type FailedRun struct {
+	TeamID      uuid.UUID
+	BlueprintID string
+	Action      string
+	Entity      string
+	Message     string
+	FinishedAt  time.Time
+}
+
+// FailedRuns returns the action runs that failed in (since, until]
+func (r *Repository) FailedRuns(ctx context.Context, since, until time.Time) ([]*FailedRun, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `
+		SELECT r.team_id, COALESCE(a.blueprint_id, ''), a.identifier, COALESCE(e.identifier, ''), COALESCE(r.message, ''), r.finished_at
+		FROM action_runs r
+		JOIN actions a ON a.id = r.action_id
+		LEFT JOIN entities e ON e.id = r.entity_id
+		WHERE r.status = 'failed' AND r.finished_at > $1 AND r.finished_at <= $2
+		ORDER BY r.finished_at`, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*FailedRun
+	for rows.Next() {
+		run := &FailedRun{}
+		if err := rows.Scan(&run.TeamID, &run.BlueprintID, &run.Action, &run.Entity, &run.Message, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// LevelChange is a recorded scorecard result whose level changed
+type LevelChange struct {
+	TeamID      uuid.UUID
+	BlueprintID string
+	Scorecard   string
+	Levels      []scorecard.Level
+	Entity      string
+	Level       string
+	Previous    string
+	EvaluatedAt time.Time
+}
+
+// LevelChanges returns the scorecard results recorded in (since, until]
+// that changed an entity's level
+func (r *Repository) LevelChanges(ctx context.Context, since, until time.Time) ([]*LevelChange, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `
+		SELECT s.team_id, s.blueprint_id, s.title, s.levels, COALESCE(e.identifier, res.entity_id::text),
+			res.level, res.previous_level, res.evaluated_at
+		FROM scorecard_results res
+		JOIN scorecards s ON s.id = res.scorecard_id
+		LEFT JOIN entities e ON e.id = res.entity_id
+		WHERE res.evaluated_at > $1 AND res.evaluated_at <= $2
+		  AND res.previous_level IS NOT NULL AND res.level <> res.previous_level
+		ORDER BY res.evaluated_at, res.id`, since, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []*LevelChange
+	for rows.Next() {
+		c := &LevelChange{}
+		var levels []byte
+		if err := rows.Scan(&c.TeamID, &c.BlueprintID, &c.Scorecard, &levels, &c.Entity, &c.Level, &c.Previous, &c.EvaluatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(levels, &c.Levels); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func scanSubscriptions(rows *sql.Rows) ([]*Subscription, error) {
+	var subs []*Subscription
+	for rows.Next() {
+		sub := &Subscription{}
+		var events postgres.StringArray
+		if err := rows.Scan(&sub.ID, &sub.TeamID, &sub.UserID, &events, &sub.BlueprintID, &sub.Channel, &sub.Email,
+			&sub.WebhookSecret, &sub.Digest, &sub.CreatedBy, &sub.CreatedAt, &sub.LastDeliveredAt, &sub.userEmail); err != nil {
+			return nil, err
+		}
+		sub.Events = events
+		sub.Scope = ScopeTeam
+		if sub.UserID != nil {
+			sub.Scope = ScopePersonal
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
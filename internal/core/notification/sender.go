@@ -0,0 +1,189 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+// Message is what one delivery sends: a single notification or a digest
+type Message struct {
+	Subject string
+	Lines   []string
+}
+
+// Text renders the message as plain text
+func (m *Message) Text() string {
+	var b strings.Builder
+	b.WriteString(m.Subject)
+	for _, line := range m.Lines {
+		b.WriteString("\n")
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+// Sender delivers messages of one channel; to is an email address or a
+// webhook URL
+type Sender interface {
+	Send(ctx context.Context, to string, m *Message) error
+}
+
+// NewEmailSender returns the SMTP sender of cfg, or a LogSender when no
+// SMTP host is configured
+func NewEmailSender(cfg config.NotificationsConfig) Sender {
+	if cfg.SMTPHost == "" {
+		return LogSender{}
+	}
+	return &SMTPSender{cfg: cfg}
+}
+
+// ErrNotDelivered is returned by LogSender: the email was only logged
+var ErrNotDelivered = errors.New("not delivered: no SMTP server is configured, the email was written to the server log")
+
+// LogSender writes emails to the server log instead of sending them
+type LogSender struct{}
+
+func (LogSender) Send(ctx context.Context, to string, m *Message) error {
+	log.Printf("notification email to %s: %s", to, strings.ReplaceAll(m.Text(), "\n", " | "))
+	return ErrNotDelivered
+}
+
+// SMTPSender sends plain text emails through an SMTP server, with STARTTLS
+// when the server offers it
+type SMTPSender struct {
+	cfg config.NotificationsConfig
+}
+
+// smtpTimeout bounds a whole SMTP conversation when ctx has no earlier deadline
+const smtpTimeout = 30 * time.Second
+
+func (s *SMTPSender) Send(ctx context.Context, to string, m *Message) error {
+	addr := net.JoinHostPort(s.cfg.SMTPHost, strconv.Itoa(s.cfg.SMTPPort))
+	ctx, cancel := context.WithTimeout(ctx, smtpTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	// The deadline bounds every read and write; cancelling ctx ends the
+	// conversation at once
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	c, err := smtp.NewClient(conn, s.cfg.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("smtp: %w", err)
+	}
+	defer c.Close()
+	if err := s.send(c, to, m); err != nil {
+		return fmt.Errorf("smtp: %w", err)
+	}
+	return nil
+}
+
+// send runs the conversation of smtp.SendMail on c
+func (s *SMTPSender) send(c *smtp.Client, to string, m *Message) error {
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.SMTPHost}); err != nil {
+			return err
+		}
+	}
+	if s.cfg.SMTPUsername != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("server doesn't support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.SMTPUsername, s.cfg.SMTPPassword, s.cfg.SMTPHost)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.cfg.SMTPFrom); err != nil {
+		return err
+	}
+	if err := c.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(emailBody(s.cfg.SMTPFrom, to, m)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// emailBody builds the email of a message; header values are stripped of
+// line breaks so a title cannot add headers
+func emailBody(from, to string, m *Message) []byte {
+	header := strings.NewReplacer("\r", " ", "\n", " ")
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", header.Replace(from))
+	fmt.Fprintf(&b, "To: %s\r\n", header.Replace(to))
+	fmt.Fprintf(&b, "Subject: %s\r\n", header.Replace(m.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().UTC().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, line := range m.Lines {
+		b.WriteString(strings.ReplaceAll(line, "\n", "\r\n"))
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+// SlackSender posts messages to Slack incoming webhooks
+type SlackSender struct {
+	client *http.Client
+}
+
+func NewSlackSender() *SlackSender {
+	return &SlackSender{client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (s *SlackSender) Send(ctx context.Context, webhookURL string, m *Message) error {
+	body, err := json.Marshal(map[string]string{"text": m.Text()})
+	if err != nil {
+		return err
+	}
+	// errors leave out the URL, which is a secret
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("the webhook secret is not an https URL")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("slack webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("slack webhook returned %s", resp.Status)
+	}
+	return nil
+}
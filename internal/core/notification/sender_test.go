@@ -0,0 +1,120 @@
+package notification
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+// smtpServer listens on a local port and passes each connection to serve
+func smtpServer(t *testing.T, serve func(conn net.Conn)) config.NotificationsConfig {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	addr := l.Addr().(*net.TCPAddr)
+	return config.NotificationsConfig{SMTPHost: "127.0.0.1", SMTPPort: addr.Port, SMTPFrom: "baseplate@example.com"}
+}
+
+func TestNewEmailSender_LogsWithoutSMTP(t *testing.T) {
+	sender := NewEmailSender(config.NotificationsConfig{})
+	if err := sender.Send(context.Background(), "ops@example.com", &Message{Subject: "hello"}); !errors.Is(err, ErrNotDelivered) {
+		t.Errorf("Send() without SMTP error = %v, want ErrNotDelivered", err)
+	}
+}
+
+func TestSMTPSender_Send(t *testing.T) {
+	received := make(chan string, 1)
+	cfg := smtpServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+		var data strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 OK")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unknown")
+			}
+		}
+	})
+
+	err := (&SMTPSender{cfg: cfg}).Send(context.Background(), "ops@example.com", &Message{Subject: "hello", Lines: []string{"one"}})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if body := <-received; !strings.Contains(body, "Subject: hello\r\n") || !strings.HasSuffix(body, "\r\none\r\n") {
+		t.Errorf("server received %q", body)
+	}
+}
+
+func TestSMTPSender_SendGivesUpOnSilentServer(t *testing.T) {
+	// the server accepts connections and never answers
+	cfg := smtpServer(t, func(conn net.Conn) { time.Sleep(5 * time.Second) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := (&SMTPSender{cfg: cfg}).Send(ctx, "ops@example.com", &Message{Subject: "hello"})
+	if err == nil {
+		t.Fatal("Send() to a silent server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() returned after %s, want the ctx deadline to end it", elapsed)
+	}
+}
+
+func TestSMTPSender_SendStopsWhenCancelled(t *testing.T) {
+	cfg := smtpServer(t, func(conn net.Conn) { time.Sleep(5 * time.Second) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	if err := (&SMTPSender{cfg: cfg}).Send(ctx, "ops@example.com", &Message{Subject: "hello"}); err == nil {
+		t.Fatal("Send() succeeded after cancellation")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Send() returned after %s, want cancellation to end it", elapsed)
+	}
+}
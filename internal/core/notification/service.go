@@ -0,0 +1,459 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+	"github.com/baseplate/baseplate/internal/core/secret"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+// ConsumerName is the outbox consumer queuing notifications of entity events
+const ConsumerName = "notifications"
+
+const (
+	// maxDigestItems bounds the notifications of one message; the rest are
+	// sent with the next
+	maxDigestItems = 100
+	// maxDueSubscriptions bounds the subscriptions delivered per run
+	maxDueSubscriptions = 500
+	// maxPendingAge is how long queued notifications are retried
+	maxPendingAge = 72 * time.Hour
+	// retryDelay is how long a subscription waits after a failed delivery
+	retryDelay = 15 * time.Minute
+	// collectLag keeps collection behind writes still being committed
+	collectLag = 10 * time.Second
+)
+
+// Sources collected by the notifications task
+const (
+	sourceActionRuns       = "action_runs"
+	sourceScorecardResults = "scorecard_results"
+)
+
+type Service struct {
+	repo    *Repository
+	secrets *secret.Service
+	email   Sender
+	slack   Sender
+	now     func() time.Time
+}
+
+// NewService creates the notification service. Slack webhook URLs are read
+// from team secrets with secrets, which may be nil when the secrets store is
+// not configured.
+func NewService(repo *Repository, secrets *secret.Service, email, slack Sender) *Service {
+	return &Service{repo: repo, secrets: secrets, email: email, slack: slack, now: time.Now}
+}
+
+// CreateSubscription validates and stores a subscription. Personal
+// subscriptions belong to the caller; team ones need a manager.
+func (s *Service) CreateSubscription(ctx context.Context, teamID uuid.UUID, caller Subscriber, req *CreateSubscriptionRequest) (*Subscription, error) {
+	sub, err := newSubscription(teamID, caller, req)
+	if err != nil {
+		return nil, err
+	}
+	if sub.WebhookSecret != nil {
+		if err := s.secrets.CheckReferences(ctx, teamID, webhookConfig(*sub.WebhookSecret)); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.repo.Create(ctx, sub); err != nil {
+		if err == ErrInvalidSubscription {
+			return nil, fmt.Errorf("%w: blueprint %q not found", ErrInvalidSubscription, *sub.BlueprintID)
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+// newSubscription builds the subscription a request describes
+func newSubscription(teamID uuid.UUID, caller Subscriber, req *CreateSubscriptionRequest) (*Subscription, error) {
+	sub := &Subscription{
+		TeamID:      teamID,
+		Scope:       req.Scope,
+		BlueprintID: req.BlueprintID,
+		Channel:     req.Channel,
+		Digest:      req.Digest,
+		CreatedBy:   caller.UserID,
+	}
+	if sub.Scope == "" {
+		sub.Scope = ScopePersonal
+	}
+	if sub.Digest == "" {
+		sub.Digest = DigestImmediate
+	}
+
+	switch sub.Scope {
+	case ScopePersonal:
+		if caller.UserID == nil {
+			return nil, ErrUserRequired
+		}
+		sub.UserID = caller.UserID
+	case ScopeTeam:
+		if !caller.Manager {
+			return nil, ErrForbidden
+		}
+	default:
+		return nil, fmt.Errorf("%w: scope must be personal or team", ErrInvalidSubscription)
+	}
+
+	if len(req.Events) == 0 {
+		return nil, fmt.Errorf("%w: events must not be empty", ErrInvalidSubscription)
+	}
+	for _, event := range req.Events {
+		if !slices.Contains(knownEvents, event) {
+			return nil, fmt.Errorf("%w: unknown event %q, must be one of %s", ErrInvalidSubscription, event, strings.Join(knownEvents, ", "))
+		}
+		if !slices.Contains(sub.Events, event) {
+			sub.Events = append(sub.Events, event)
+		}
+	}
+
+	switch sub.Digest {
+	case DigestImmediate, DigestHourly, DigestDaily:
+	default:
+		return nil, fmt.Errorf("%w: digest must be immediate, hourly or daily", ErrInvalidSubscription)
+	}
+	if sub.BlueprintID != nil && *sub.BlueprintID == "" {
+		sub.BlueprintID = nil
+	}
+
+	switch sub.Channel {
+	case ChannelEmail:
+		if req.WebhookSecret != nil {
+			return nil, fmt.Errorf("%w: webhook_secret is only used by the slack channel", ErrInvalidSubscription)
+		}
+		if sub.Scope == ScopePersonal {
+			if req.Email != nil {
+				return nil, fmt.Errorf("%w: personal email subscriptions go to the user's address; omit email", ErrInvalidSubscription)
+			}
+			break
+		}
+		if req.Email == nil {
+			return nil, fmt.Errorf("%w: email is required for team email subscriptions", ErrInvalidSubscription)
+		}
+		addr, err := mail.ParseAddress(*req.Email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid email %q", ErrInvalidSubscription, *req.Email)
+		}
+		sub.Email = &addr.Address
+	case ChannelSlack:
+		if req.Email != nil {
+			return nil, fmt.Errorf("%w: email is only used by the email channel", ErrInvalidSubscription)
+		}
+		if req.WebhookSecret == nil || *req.WebhookSecret == "" {
+			return nil, fmt.Errorf("%w: webhook_secret, the team secret holding the Slack webhook URL, is required", ErrInvalidSubscription)
+		}
+		sub.WebhookSecret = req.WebhookSecret
+	default:
+		return nil, fmt.Errorf("%w: channel must be email or slack", ErrInvalidSubscription)
+	}
+	return sub, nil
+}
+
+// ListSubscriptions returns the team subscriptions and the caller's own;
+// managers see everyone's
+func (s *Service) ListSubscriptions(ctx context.Context, teamID uuid.UUID, caller Subscriber) (*ListSubscriptionsResponse, error) {
+	subs, err := s.repo.List(ctx, teamID, caller.UserID, caller.Manager)
+	if err != nil {
+		return nil, err
+	}
+	if subs == nil {
+		subs = []*Subscription{}
+	}
+	return &ListSubscriptionsResponse{Subscriptions: subs}, nil
+}
+
+// DeleteSubscription removes a subscription the caller may manage
+func (s *Service) DeleteSubscription(ctx context.Context, teamID, id uuid.UUID, caller Subscriber) error {
+	sub, err := s.repo.Get(ctx, teamID, id)
+	if err != nil {
+		return err
+	}
+	if sub == nil || (!caller.Manager && sub.UserID != nil && (caller.UserID == nil || *sub.UserID != *caller.UserID)) {
+		return ErrNotFound
+	}
+	if sub.UserID == nil && !caller.Manager {
+		return ErrForbidden
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// ListDeliveries returns a team's delivery log, newest first
+func (s *Service) ListDeliveries(ctx context.Context, teamID uuid.UUID, req *ListDeliveriesRequest) (*ListDeliveriesResponse, error) {
+	if req.Limit <= 0 || req.Limit > 500 {
+		req.Limit = 50
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+	deliveries, total, err := s.repo.Deliveries(ctx, teamID, req)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []*Delivery{}
+	}
+	return &ListDeliveriesResponse{Deliveries: deliveries, Total: total, Limit: req.Limit, Offset: req.Offset}, nil
+}
+
+// Consumer returns the outbox consumer queuing notifications of entity
+// deletions. Redelivered events may be notified twice.
+func (s *Service) Consumer() func(ctx context.Context, e events.Event) error {
+	return func(ctx context.Context, e events.Event) error {
+		if e.Type != events.EntityDeleted {
+			return nil
+		}
+		_, err := s.repo.Enqueue(ctx, deletedItem(e))
+		return err
+	}
+}
+
+func deletedItem(e events.Event) *Item {
+	var entity struct {
+		Identifier string `json:"identifier"`
+		Title      string `json:"title"`
+	}
+	if raw, err := json.Marshal(e.Payload); err == nil {
+		_ = json.Unmarshal(raw, &entity)
+	}
+	return &Item{
+		Event:       EventEntityDeleted,
+		TeamID:      e.TeamID,
+		BlueprintID: e.BlueprintID,
+		Title:       fmt.Sprintf("%s %q was deleted", e.BlueprintID, entity.Identifier),
+		Detail:      entity.Title,
+		OccurredAt:  e.OccurredAt,
+	}
+}
+
+// Run collects action failures and scorecard degradations into the queue,
+// then delivers every due subscription. It is the notifications task.
+func (s *Service) Run(ctx context.Context) (*RunResult, error) {
+	result := &RunResult{}
+	collected, err := s.collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result.Collected = collected
+
+	now := s.now()
+	if dropped, err := s.repo.DropExpired(ctx, now.Add(-maxPendingAge)); err != nil {
+		return nil, err
+	} else if dropped > 0 {
+		log.Printf("WARNING: dropped %d notifications undelivered for %s", dropped, maxPendingAge)
+	}
+
+	due, err := s.repo.Due(ctx, now, now.Add(-retryDelay), maxDueSubscriptions)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range due {
+		status, err := s.deliver(ctx, sub)
+		if err != nil {
+			return nil, err
+		}
+		switch status {
+		case StatusSent:
+			result.Delivered++
+		case StatusFailed:
+			result.Failed++
+		case StatusSkipped:
+			result.Skipped++
+		}
+	}
+	return result, nil
+}
+
+// collect queues the notifications of every source since it was last
+// collected. A source collected for the first time starts from now.
+func (s *Service) collect(ctx context.Context) (int, error) {
+	until := s.now().Add(-collectLag)
+	collected := 0
+	for _, source := range []string{sourceActionRuns, sourceScorecardResults} {
+		since, ok, err := s.repo.Cursor(ctx, source)
+		if err != nil {
+			return collected, err
+		}
+		if ok && since.Before(until) {
+			items, err := s.collectSource(ctx, source, since, until)
+			if err != nil {
+				return collected, err
+			}
+			for _, item := range items {
+				n, err := s.repo.Enqueue(ctx, item)
+				if err != nil {
+					return collected, err
+				}
+				collected += int(n)
+			}
+		}
+		if !ok || since.Before(until) {
+			if err := s.repo.SetCursor(ctx, source, until); err != nil {
+				return collected, err
+			}
+		}
+	}
+	return collected, nil
+}
+
+func (s *Service) collectSource(ctx context.Context, source string, since, until time.Time) ([]*Item, error) {
+	var items []*Item
+	switch source {
+	case sourceActionRuns:
+		runs, err := s.repo.FailedRuns(ctx, since, until)
+		if err != nil {
+			return nil, err
+		}
+		for _, run := range runs {
+			items = append(items, failedRunItem(run))
+		}
+	case sourceScorecardResults:
+		changes, err := s.repo.LevelChanges(ctx, since, until)
+		if err != nil {
+			return nil, err
+		}
+		for _, change := range changes {
+			if degraded(change.Levels, change.Previous, change.Level) {
+				items = append(items, degradedItem(change))
+			}
+		}
+	}
+	return items, nil
+}
+
+func failedRunItem(run *FailedRun) *Item {
+	title := fmt.Sprintf("Action %q failed", run.Action)
+	if run.Entity != "" {
+		title = fmt.Sprintf("Action %q failed on %s %q", run.Action, run.BlueprintID, run.Entity)
+	}
+	return &Item{
+		Event:       EventActionFailed,
+		TeamID:      run.TeamID,
+		BlueprintID: run.BlueprintID,
+		Title:       title,
+		Detail:      run.Message,
+		OccurredAt:  run.FinishedAt,
+	}
+}
+
+func degradedItem(change *LevelChange) *Item {
+	level := change.Level
+	if level == "" {
+		level = "no level"
+	}
+	return &Item{
+		Event:       EventScorecardDegraded,
+		TeamID:      change.TeamID,
+		BlueprintID: change.BlueprintID,
+		Title:       fmt.Sprintf("%s %q dropped from %s to %s on %s", change.BlueprintID, change.Entity, change.Previous, level, change.Scorecard),
+		OccurredAt:  change.EvaluatedAt,
+	}
+}
+
+// degraded reports whether going from one level to another is a drop.
+// Levels are ordered from lowest to highest; no level is below all of them,
+// and a level the scorecard no longer has is not compared.
+func degraded(levels []scorecard.Level, from, to string) bool {
+	rank := func(name string) int {
+		if name == "" {
+			return 0
+		}
+		i := slices.IndexFunc(levels, func(l scorecard.Level) bool { return l.Name == name })
+		if i < 0 {
+			return -1
+		}
+		return i + 1
+	}
+	fromRank, toRank := rank(from), rank(to)
+	return fromRank >= 0 && toRank >= 0 && toRank < fromRank
+}
+
+// deliver sends the pending notifications of a subscription in one message
+// and logs the attempt, returning its status, or "" when nothing is pending.
+// Failed deliveries keep their notifications to be retried after retryDelay;
+// skipped ones, only logged for want of an SMTP server, do not.
+func (s *Service) deliver(ctx context.Context, sub *Subscription) (string, error) {
+	items, err := s.repo.Pending(ctx, sub.ID, maxDigestItems)
+	if err != nil || len(items) == 0 {
+		return "", err
+	}
+	d := &Delivery{Channel: sub.Channel, Notifications: len(items), Status: StatusSent}
+	sendErr := s.send(ctx, sub, d, compose(sub.Digest, items))
+	switch {
+	case errors.Is(sendErr, ErrNotDelivered):
+		d.Status, d.Error = StatusSkipped, sendErr.Error()
+	case sendErr != nil:
+		d.Status, d.Error = StatusFailed, sendErr.Error()
+		log.Printf("WARNING: failed to deliver notifications of subscription %s: %v", sub.ID, sendErr)
+		return d.Status, s.repo.LogDelivery(ctx, sub, d)
+	}
+	return d.Status, s.repo.Delivered(ctx, sub, items[len(items)-1].ID, d)
+}
+
+// send delivers a message on the subscription's channel, setting the
+// recipient of the delivery log
+func (s *Service) send(ctx context.Context, sub *Subscription, d *Delivery, m *Message) error {
+	switch sub.Channel {
+	case ChannelEmail:
+		d.Recipient = sub.userEmail
+		if sub.Email != nil {
+			d.Recipient = *sub.Email
+		}
+		return s.email.Send(ctx, d.Recipient, m)
+	case ChannelSlack:
+		d.Recipient = "secret:" + *sub.WebhookSecret
+		resolved, err := s.secrets.Resolve(ctx, sub.TeamID, webhookConfig(*sub.WebhookSecret),
+			"notification_subscription:"+sub.ID.String(), nil, nil, nil)
+		if err != nil {
+			return err
+		}
+		url, _ := resolved.(map[string]interface{})["url"].(string)
+		return s.slack.Send(ctx, url, m)
+	}
+	return fmt.Errorf("unknown channel %q", sub.Channel)
+}
+
+// webhookConfig is the secret reference of a Slack webhook
+func webhookConfig(name string) map[string]interface{} {
+	return map[string]interface{}{"url": map[string]interface{}{"$secret": name}}
+}
+
+// compose builds the message of a subscription's pending notifications: a
+// single one as is, several as a digest
+func compose(digest string, items []*Item) *Message {
+	if len(items) == 1 {
+		m := &Message{Subject: items[0].Title}
+		if items[0].Detail != "" {
+			m.Lines = []string{items[0].Detail}
+		}
+		return m
+	}
+	subject := fmt.Sprintf("%d catalog notifications", len(items))
+	switch digest {
+	case DigestHourly:
+		subject = "Hourly digest: " + subject
+	case DigestDaily:
+		subject = "Daily digest: " + subject
+	}
+	m := &Message{Subject: subject, Lines: make([]string, len(items))}
+	for i, item := range items {
+		line := fmt.Sprintf("- %s %s", item.OccurredAt.UTC().Format(time.RFC3339), item.Title)
+		if item.Detail != "" {
+			line += ": " + item.Detail
+		}
+		m.Lines[i] = line
+	}
+	return m
+}
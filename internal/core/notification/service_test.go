@@ -0,0 +1,162 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+)
+
+func strPtr(s string) *string { return &s }
+
+func TestNewSubscription(t *testing.T) {
+	userID := uuid.New()
+	member := Subscriber{UserID: &userID}
+	manager := Subscriber{UserID: &userID, Manager: true}
+	apiKey := Subscriber{Manager: true}
+
+	tests := []struct {
+		name   string
+		caller Subscriber
+		req    CreateSubscriptionRequest
+		want   error
+	}{
+		{"personal email", member, CreateSubscriptionRequest{Events: []string{EventEntityDeleted}, Channel: ChannelEmail}, nil},
+		{"personal needs a user", apiKey, CreateSubscriptionRequest{Events: []string{EventEntityDeleted}, Channel: ChannelEmail}, ErrUserRequired},
+		{"team needs a manager", member, CreateSubscriptionRequest{Scope: ScopeTeam, Events: []string{EventActionFailed}, Channel: ChannelEmail, Email: strPtr("ops@example.com")}, ErrForbidden},
+		{"team email", apiKey, CreateSubscriptionRequest{Scope: ScopeTeam, Events: []string{EventActionFailed}, Channel: ChannelEmail, Email: strPtr("Ops <ops@example.com>")}, nil},
+		{"team email needs an address", manager, CreateSubscriptionRequest{Scope: ScopeTeam, Events: []string{EventActionFailed}, Channel: ChannelEmail}, ErrInvalidSubscription},
+		{"invalid address", manager, CreateSubscriptionRequest{Scope: ScopeTeam, Events: []string{EventActionFailed}, Channel: ChannelEmail, Email: strPtr("ops")}, ErrInvalidSubscription},
+		{"personal email sets no address", member, CreateSubscriptionRequest{Events: []string{EventActionFailed}, Channel: ChannelEmail, Email: strPtr("me@example.com")}, ErrInvalidSubscription},
+		{"slack", member, CreateSubscriptionRequest{Events: []string{EventScorecardDegraded}, Channel: ChannelSlack, WebhookSecret: strPtr("slack-hook")}, nil},
+		{"slack needs a secret", member, CreateSubscriptionRequest{Events: []string{EventScorecardDegraded}, Channel: ChannelSlack}, ErrInvalidSubscription},
+		{"unknown channel", member, CreateSubscriptionRequest{Events: []string{EventEntityDeleted}, Channel: "sms"}, ErrInvalidSubscription},
+		{"unknown event", member, CreateSubscriptionRequest{Events: []string{"entity.created"}, Channel: ChannelEmail}, ErrInvalidSubscription},
+		{"no events", member, CreateSubscriptionRequest{Channel: ChannelEmail}, ErrInvalidSubscription},
+		{"unknown digest", member, CreateSubscriptionRequest{Events: []string{EventEntityDeleted}, Channel: ChannelEmail, Digest: "weekly"}, ErrInvalidSubscription},
+		{"unknown scope", member, CreateSubscriptionRequest{Scope: "org", Events: []string{EventEntityDeleted}, Channel: ChannelEmail}, ErrInvalidSubscription},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSubscription(uuid.New(), tt.caller, &tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewSubscription_Defaults(t *testing.T) {
+	userID := uuid.New()
+	sub, err := newSubscription(uuid.New(), Subscriber{UserID: &userID}, &CreateSubscriptionRequest{
+		Events:  []string{EventEntityDeleted, EventActionFailed, EventEntityDeleted},
+		Channel: ChannelEmail,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Scope != ScopePersonal || sub.UserID == nil || *sub.UserID != userID {
+		t.Errorf("scope = %q user = %v, want a personal subscription of the caller", sub.Scope, sub.UserID)
+	}
+	if sub.Digest != DigestImmediate {
+		t.Errorf("digest = %q, want immediate", sub.Digest)
+	}
+	if len(sub.Events) != 2 {
+		t.Errorf("events = %v, want duplicates removed", sub.Events)
+	}
+}
+
+func TestDegraded(t *testing.T) {
+	levels := []scorecard.Level{{Name: "bronze"}, {Name: "silver"}, {Name: "gold"}}
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"gold", "silver", true},
+		{"bronze", "", true},
+		{"silver", "gold", false},
+		{"", "bronze", false},
+		{"silver", "silver", false},
+		// levels the scorecard no longer has are not compared
+		{"platinum", "bronze", false},
+	}
+	for _, tt := range tests {
+		if got := degraded(levels, tt.from, tt.to); got != tt.want {
+			t.Errorf("degraded(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestCompose(t *testing.T) {
+	at := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	one := compose(DigestImmediate, []*Item{{Title: `Action "deploy" failed`, Detail: "exit status 1", OccurredAt: at}})
+	if one.Subject != `Action "deploy" failed` || len(one.Lines) != 1 || one.Lines[0] != "exit status 1" {
+		t.Errorf("single notification = %+v", one)
+	}
+
+	digest := compose(DigestDaily, []*Item{
+		{Title: `Action "deploy" failed`, Detail: "exit status 1", OccurredAt: at},
+		{Title: `service "api" was deleted`, OccurredAt: at.Add(time.Hour)},
+	})
+	if digest.Subject != "Daily digest: 2 catalog notifications" {
+		t.Errorf("subject = %q", digest.Subject)
+	}
+	if len(digest.Lines) != 2 || !strings.HasSuffix(digest.Lines[0], `Action "deploy" failed: exit status 1`) ||
+		!strings.HasPrefix(digest.Lines[1], "- 2026-10-18T10:30:00Z") {
+		t.Errorf("lines = %q", digest.Lines)
+	}
+}
+
+func TestEmailBody_StripsHeaderLineBreaks(t *testing.T) {
+	body := string(emailBody("baseplate@example.com", "ops@example.com", &Message{
+		Subject: "deleted\r\nBcc: attacker@example.com",
+		Lines:   []string{"one", "two"},
+	}))
+	if strings.Contains(body, "\r\nBcc:") {
+		t.Errorf("subject added a header:\n%s", body)
+	}
+	if !strings.HasSuffix(body, "\r\n\r\none\r\ntwo\r\n") {
+		t.Errorf("body = %q", body)
+	}
+}
+
+// recordingSender keeps the messages it is asked to send
+type recordingSender struct {
+	to       []string
+	messages []*Message
+}
+
+func (r *recordingSender) Send(ctx context.Context, to string, m *Message) error {
+	r.to, r.messages = append(r.to, to), append(r.messages, m)
+	return nil
+}
+
+func TestNewAccountMailer(t *testing.T) {
+	if m := NewAccountMailer(config.NotificationsConfig{}); m != nil {
+		t.Errorf("NewAccountMailer without SMTP = %T, want nil", m)
+	}
+	if m := NewAccountMailer(config.NotificationsConfig{SMTPHost: "smtp.example.com", SMTPPort: 587}); m == nil {
+		t.Error("NewAccountMailer with SMTP = nil, want a mailer")
+	}
+}
+
+func TestAccountMailer_SendEmailVerification(t *testing.T) {
+	sender := &recordingSender{}
+	m := &AccountMailer{sender: sender}
+	if err := m.SendEmailVerification(context.Background(), &auth.User{ID: uuid.New()}, "jane@example.com", "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.messages) != 1 || sender.to[0] != "jane@example.com" {
+		t.Fatalf("sent %d messages to %v, want one to jane@example.com", len(sender.messages), sender.to)
+	}
+	if text := sender.messages[0].Text(); !strings.Contains(text, "abc123") {
+		t.Errorf("message does not carry the token:\n%s", text)
+	}
+}
@@ -0,0 +1,50 @@
+package presentation
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Presentation is how frontends render the entities of a blueprint, so every
+// client lays out the catalog the same way. Properties are data property
+// paths or the entity columns identifier, title, created_at and updated_at.
+type Presentation struct {
+	TeamID      uuid.UUID `json:"team_id"`
+	BlueprintID string    `json:"blueprint_id"`
+	// Columns are the default columns of entity tables
+	Columns []string `json:"columns"`
+	// PropertyOrder lists properties in the order forms and detail pages
+	// show them; properties left out follow in alphabetical order
+	PropertyOrder []string `json:"property_order"`
+	// GroupBy is the property entity lists are grouped by, if any
+	GroupBy string `json:"group_by,omitempty"`
+	// Detail is the layout of entity detail pages
+	Detail DetailLayout `json:"detail"`
+	// Configured is false for the defaults of a blueprint without a stored
+	// presentation
+	Configured bool       `json:"configured"`
+	UpdatedBy  *uuid.UUID `json:"updated_by,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// DetailLayout arranges an entity detail page in sections
+type DetailLayout struct {
+	Sections []DetailSection `json:"sections"`
+}
+
+// DetailSection is a titled group of properties on a detail page
+type DetailSection struct {
+	Title      string   `json:"title"`
+	Properties []string `json:"properties"`
+	Collapsed  bool     `json:"collapsed,omitempty"`
+}
+
+// PutPresentationRequest replaces a blueprint's presentation
+type PutPresentationRequest struct {
+	Columns       []string      `json:"columns"`
+	PropertyOrder []string      `json:"property_order"`
+	GroupBy       string        `json:"group_by"`
+	Detail        *DetailLayout `json:"detail"`
+}
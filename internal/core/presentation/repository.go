@@ -0,0 +1,107 @@
+package presentation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+// Get returns a blueprint's stored presentation, or nil
+func (r *Repository) Get(ctx context.Context, teamID uuid.UUID, blueprintID string) (*Presentation, error) {
+	query := `
+		SELECT columns, property_order, group_by, detail, updated_by, created_at, updated_at
+		FROM blueprint_presentations
+		WHERE team_id = $1 AND blueprint_id = $2`
+
+	p := &Presentation{TeamID: teamID, BlueprintID: blueprintID, Configured: true}
+	var columns, order, detail []byte
+	var groupBy sql.NullString
+	var updatedBy uuid.NullUUID
+	var createdAt, updatedAt sql.NullTime
+	err := r.db.Reader(ctx).QueryRowContext(ctx, query, teamID, blueprintID).Scan(
+		&columns, &order, &groupBy, &detail, &updatedBy, &createdAt, &updatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(columns, &p.Columns); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(order, &p.PropertyOrder); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(detail, &p.Detail); err != nil {
+		return nil, err
+	}
+	p.GroupBy = groupBy.String
+	if updatedBy.Valid {
+		p.UpdatedBy = &updatedBy.UUID
+	}
+	if createdAt.Valid {
+		p.CreatedAt = &createdAt.Time
+	}
+	if updatedAt.Valid {
+		p.UpdatedAt = &updatedAt.Time
+	}
+	return p, nil
+}
+
+// Put stores a blueprint's presentation, replacing the previous one
+func (r *Repository) Put(ctx context.Context, p *Presentation) error {
+	columns, err := json.Marshal(p.Columns)
+	if err != nil {
+		return err
+	}
+	order, err := json.Marshal(p.PropertyOrder)
+	if err != nil {
+		return err
+	}
+	detail, err := json.Marshal(p.Detail)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO blueprint_presentations (team_id, blueprint_id, columns, property_order, group_by, detail, updated_by)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)
+		ON CONFLICT (team_id, blueprint_id) DO UPDATE
+		SET columns = EXCLUDED.columns, property_order = EXCLUDED.property_order, group_by = EXCLUDED.group_by,
+		    detail = EXCLUDED.detail, updated_by = EXCLUDED.updated_by, updated_at = NOW()
+		RETURNING created_at, updated_at`
+
+	var createdAt, updatedAt sql.NullTime
+	err = r.db.DB.QueryRowContext(ctx, query,
+		p.TeamID, p.BlueprintID, columns, order, p.GroupBy, detail, p.UpdatedBy,
+	).Scan(&createdAt, &updatedAt)
+	if err != nil {
+		return err
+	}
+	p.CreatedAt, p.UpdatedAt = &createdAt.Time, &updatedAt.Time
+	return nil
+}
+
+// Delete removes a blueprint's presentation and reports whether it had one
+func (r *Repository) Delete(ctx context.Context, teamID uuid.UUID, blueprintID string) (bool, error) {
+	res, err := r.db.DB.ExecContext(ctx,
+		`DELETE FROM blueprint_presentations WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
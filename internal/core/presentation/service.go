@@ -0,0 +1,258 @@
+// Package presentation stores how frontends render each blueprint: default
+// table columns, property order, grouping and the detail page layout. It is
+// a hint for clients; the API serves entities the same way regardless.
+package presentation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+var (
+	ErrNotFound            = errors.New("presentation not found")
+	ErrBlueprintNotFound   = errors.New("blueprint not found")
+	ErrInvalidPresentation = errors.New("invalid presentation")
+)
+
+const (
+	// maxColumns bounds the default columns, as for saved views
+	maxColumns = 50
+
+	// maxProperties bounds the property order and the properties of a section
+	maxProperties = 200
+
+	// maxSections bounds the sections of a detail page
+	maxSections = 20
+
+	// maxSectionTitle bounds the length of section titles
+	maxSectionTitle = 100
+)
+
+// defaultColumns are the columns of blueprints without a presentation
+var defaultColumns = []string{"identifier", "title"}
+
+// groupableTypes are the schema types entity lists can be grouped by
+var groupableTypes = []string{"string", "boolean", "integer", "number"}
+
+type Service struct {
+	repo         *Repository
+	blueprintSvc *blueprint.Service
+}
+
+func NewService(repo *Repository, blueprintSvc *blueprint.Service) *Service {
+	return &Service{repo: repo, blueprintSvc: blueprintSvc}
+}
+
+// Get returns a blueprint's presentation, or the defaults when none is
+// stored. Properties removed from the schema since it was stored are left
+// out.
+func (s *Service) Get(ctx context.Context, teamID uuid.UUID, blueprintID string) (*Presentation, error) {
+	bp, err := s.blueprint(ctx, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.repo.Get(ctx, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return defaults(bp), nil
+	}
+	prune(p, entity.NewFilterCompiler(bp.Schema))
+	return p, nil
+}
+
+// Put validates a presentation against the blueprint schema and stores it
+func (s *Service) Put(ctx context.Context, teamID uuid.UUID, blueprintID string, userID *uuid.UUID, req *PutPresentationRequest) (*Presentation, error) {
+	bp, err := s.blueprint(ctx, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	p := &Presentation{
+		TeamID:        teamID,
+		BlueprintID:   blueprintID,
+		Columns:       req.Columns,
+		PropertyOrder: req.PropertyOrder,
+		GroupBy:       req.GroupBy,
+		Configured:    true,
+		UpdatedBy:     userID,
+	}
+	if req.Detail != nil {
+		p.Detail = *req.Detail
+	}
+	if err := validate(p, bp.Schema); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Put(ctx, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Delete removes a blueprint's presentation, so clients fall back to the
+// defaults
+func (s *Service) Delete(ctx context.Context, teamID uuid.UUID, blueprintID string) error {
+	if _, err := s.blueprint(ctx, teamID, blueprintID); err != nil {
+		return err
+	}
+	deleted, err := s.repo.Delete(ctx, teamID, blueprintID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Service) blueprint(ctx context.Context, teamID uuid.UUID, blueprintID string) (*blueprint.Blueprint, error) {
+	bp, err := s.blueprintSvc.Get(ctx, teamID, blueprintID)
+	if errors.Is(err, blueprint.ErrNotFound) {
+		return nil, ErrBlueprintNotFound
+	}
+	return bp, err
+}
+
+// defaults is the presentation of a blueprint without a stored one: the
+// identifier and title as columns, and the top-level schema properties in
+// alphabetical order in one section
+func defaults(bp *blueprint.Blueprint) *Presentation {
+	props, _ := bp.Schema["properties"].(map[string]interface{})
+	order := make([]string, 0, len(props))
+	for name := range props {
+		order = append(order, name)
+	}
+	sort.Strings(order)
+	return &Presentation{
+		TeamID:        bp.TeamID,
+		BlueprintID:   bp.ID,
+		Columns:       slices.Clone(defaultColumns),
+		PropertyOrder: order,
+		Detail:        DetailLayout{Sections: []DetailSection{{Title: "Properties", Properties: order}}},
+	}
+}
+
+// validate checks a presentation against the blueprint schema and fills in
+// empty lists
+func validate(p *Presentation, schema map[string]interface{}) error {
+	if p.Columns == nil {
+		p.Columns = []string{}
+	}
+	if p.PropertyOrder == nil {
+		p.PropertyOrder = []string{}
+	}
+	if p.Detail.Sections == nil {
+		p.Detail.Sections = []DetailSection{}
+	}
+	if len(p.Columns) > maxColumns {
+		return fmt.Errorf("%w: at most %d columns are allowed", ErrInvalidPresentation, maxColumns)
+	}
+	if len(p.PropertyOrder) > maxProperties {
+		return fmt.Errorf("%w: at most %d properties can be ordered", ErrInvalidPresentation, maxProperties)
+	}
+	if len(p.Detail.Sections) > maxSections {
+		return fmt.Errorf("%w: at most %d detail sections are allowed", ErrInvalidPresentation, maxSections)
+	}
+
+	fc := entity.NewFilterCompiler(schema)
+	if err := checkProperties(fc, "columns", p.Columns); err != nil {
+		return err
+	}
+	if err := checkProperties(fc, "property_order", p.PropertyOrder); err != nil {
+		return err
+	}
+	placed := make(map[string]string)
+	for i, section := range p.Detail.Sections {
+		if section.Title == "" || len(section.Title) > maxSectionTitle {
+			return fmt.Errorf("%w: detail section %d: title must be 1-%d characters", ErrInvalidPresentation, i+1, maxSectionTitle)
+		}
+		if len(section.Properties) > maxProperties {
+			return fmt.Errorf("%w: detail section %q: at most %d properties are allowed", ErrInvalidPresentation, section.Title, maxProperties)
+		}
+		if section.Properties == nil {
+			p.Detail.Sections[i].Properties = []string{}
+		}
+		if err := checkProperties(fc, fmt.Sprintf("detail section %q", section.Title), section.Properties); err != nil {
+			return err
+		}
+		for _, property := range section.Properties {
+			if other, ok := placed[property]; ok {
+				return fmt.Errorf("%w: %s is in detail sections %q and %q", ErrInvalidPresentation, property, other, section.Title)
+			}
+			placed[property] = section.Title
+		}
+	}
+	if p.GroupBy != "" {
+		if err := fc.Column(p.GroupBy); err != nil {
+			return fmt.Errorf("%w: group_by: %v", ErrInvalidPresentation, err)
+		}
+		if prop, _ := fc.Property(p.GroupBy); prop != nil && !groupable(prop) {
+			return fmt.Errorf("%w: group_by: %s is not a string, boolean or number property", ErrInvalidPresentation, p.GroupBy)
+		}
+	}
+	return nil
+}
+
+// checkProperties checks that a list names known properties, each once
+func checkProperties(fc *entity.FilterCompiler, field string, properties []string) error {
+	seen := make(map[string]bool, len(properties))
+	for _, property := range properties {
+		if err := fc.Column(property); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidPresentation, field, err)
+		}
+		if seen[property] {
+			return fmt.Errorf("%w: %s: %s is listed twice", ErrInvalidPresentation, field, property)
+		}
+		seen[property] = true
+	}
+	return nil
+}
+
+// prune leaves out the properties the schema no longer has
+func prune(p *Presentation, fc *entity.FilterCompiler) {
+	known := func(property string) bool { return fc.Column(property) == nil }
+	keep := func(properties []string) []string {
+		kept := []string{}
+		for _, property := range properties {
+			if known(property) {
+				kept = append(kept, property)
+			}
+		}
+		return kept
+	}
+	p.Columns = keep(p.Columns)
+	p.PropertyOrder = keep(p.PropertyOrder)
+	for i := range p.Detail.Sections {
+		p.Detail.Sections[i].Properties = keep(p.Detail.Sections[i].Properties)
+	}
+	if p.Detail.Sections == nil {
+		p.Detail.Sections = []DetailSection{}
+	}
+	if p.GroupBy != "" && !known(p.GroupBy) {
+		p.GroupBy = ""
+	}
+}
+
+// groupable reports whether a schema property holds scalar values
+func groupable(prop map[string]interface{}) bool {
+	switch t := prop["type"].(type) {
+	case string:
+		return slices.Contains(groupableTypes, t)
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok && s != "null" {
+				return slices.Contains(groupableTypes, s)
+			}
+		}
+	}
+	// Untyped and free-form properties may hold anything
+	return true
+}
@@ -0,0 +1,111 @@
+package presentation
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+var testSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"language": map[string]interface{}{"type": "string"},
+		"tier":     map[string]interface{}{"type": []interface{}{"integer", "null"}},
+		"tags":     map[string]interface{}{"type": "array"},
+		"owner": map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"team": map[string]interface{}{"type": "string"}},
+		},
+		"labels": map[string]interface{}{"type": "object"},
+	},
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Presentation
+		want string // substring of the error, "" for valid
+	}{
+		{"empty", Presentation{}, ""},
+		{"full", Presentation{
+			Columns:       []string{"identifier", "title", "language", "owner.team"},
+			PropertyOrder: []string{"tier", "language"},
+			GroupBy:       "tier",
+			Detail: DetailLayout{Sections: []DetailSection{
+				{Title: "Overview", Properties: []string{"language", "tier"}},
+				{Title: "Ownership", Properties: []string{"owner.team"}, Collapsed: true},
+			}},
+		}, ""},
+		{"free-form path", Presentation{Columns: []string{"labels.env"}, GroupBy: "labels.env"}, ""},
+		{"unknown column", Presentation{Columns: []string{"runtime"}}, "columns"},
+		{"duplicate column", Presentation{Columns: []string{"language", "language"}}, "listed twice"},
+		{"unknown ordered property", Presentation{PropertyOrder: []string{"owner.name"}}, "property_order"},
+		{"group by array", Presentation{GroupBy: "tags"}, "not a string, boolean or number"},
+		{"group by unknown", Presentation{GroupBy: "runtime"}, "group_by"},
+		{"untitled section", Presentation{Detail: DetailLayout{Sections: []DetailSection{{Properties: []string{"tier"}}}}}, "title"},
+		{"property in two sections", Presentation{Detail: DetailLayout{Sections: []DetailSection{
+			{Title: "A", Properties: []string{"tier"}},
+			{Title: "B", Properties: []string{"tier"}},
+		}}}, `sections "A" and "B"`},
+		{"too many columns", Presentation{Columns: slices.Repeat([]string{"title"}, maxColumns+1)}, "at most"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validate(&tt.p, testSchema)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("validate() = %v", err)
+				}
+				if tt.p.Columns == nil || tt.p.PropertyOrder == nil || tt.p.Detail.Sections == nil {
+					t.Errorf("lists not filled in: %+v", tt.p)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidPresentation) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	p := &Presentation{
+		Columns:       []string{"identifier", "language", "runtime"},
+		PropertyOrder: []string{"runtime", "tier"},
+		GroupBy:       "runtime",
+		Detail:        DetailLayout{Sections: []DetailSection{{Title: "Overview", Properties: []string{"runtime", "owner.team"}}}},
+	}
+	prune(p, entity.NewFilterCompiler(testSchema))
+
+	if !slices.Equal(p.Columns, []string{"identifier", "language"}) {
+		t.Errorf("columns = %v", p.Columns)
+	}
+	if !slices.Equal(p.PropertyOrder, []string{"tier"}) {
+		t.Errorf("property order = %v", p.PropertyOrder)
+	}
+	if p.GroupBy != "" {
+		t.Errorf("group by = %q", p.GroupBy)
+	}
+	if got := p.Detail.Sections[0].Properties; !slices.Equal(got, []string{"owner.team"}) {
+		t.Errorf("section properties = %v", got)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	p := defaults(&blueprint.Blueprint{ID: "service", Schema: testSchema})
+
+	if p.Configured || !slices.Equal(p.Columns, defaultColumns) {
+		t.Errorf("defaults = %+v", p)
+	}
+	want := []string{"labels", "language", "owner", "tags", "tier"}
+	if !slices.Equal(p.PropertyOrder, want) {
+		t.Errorf("property order = %v, want %v", p.PropertyOrder, want)
+	}
+	if len(p.Detail.Sections) != 1 || !slices.Equal(p.Detail.Sections[0].Properties, want) {
+		t.Errorf("detail = %+v", p.Detail)
+	}
+}
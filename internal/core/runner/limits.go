@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidRunLimits = errors.New("invalid run limits")
+
+// Run limit keys in an action's trigger_config
+const (
+	LimitConcurrent          = "max_concurrent_runs"
+	LimitConcurrentPerEntity = "max_concurrent_runs_per_entity"
+	LimitCooldown            = "cooldown_seconds"
+)
+
+// RunLimits restrict new runs of an action, for actions such as provisioning
+// that must not run twice at once. Pending and running runs are active.
+type RunLimits struct {
+	// MaxConcurrent bounds the action's active runs in the team
+	MaxConcurrent int
+	// MaxConcurrentPerEntity bounds the action's active runs on one entity
+	MaxConcurrentPerEntity int
+	// Cooldown is the least time between triggering the action for one
+	// entity, or for the team when it runs without one. Cancelled runs do
+	// not count.
+	Cooldown time.Duration
+}
+
+// IsZero reports whether the limits allow every run
+func (l *RunLimits) IsZero() bool {
+	return l.MaxConcurrent == 0 && l.MaxConcurrentPerEntity == 0 && l.Cooldown == 0
+}
+
+// ParseRunLimits reads the run limits of an action's trigger config. Limits
+// that are not set, or 0, do not apply.
+func ParseRunLimits(triggerConfig map[string]interface{}) (*RunLimits, error) {
+	limits := &RunLimits{}
+	for key, target := range map[string]*int{
+		LimitConcurrent:          &limits.MaxConcurrent,
+		LimitConcurrentPerEntity: &limits.MaxConcurrentPerEntity,
+	} {
+		n, err := limitValue(triggerConfig, key)
+		if err != nil {
+			return nil, err
+		}
+		*target = n
+	}
+	cooldown, err := limitValue(triggerConfig, LimitCooldown)
+	if err != nil {
+		return nil, err
+	}
+	limits.Cooldown = time.Duration(cooldown) * time.Second
+	return limits, nil
+}
+
+// limitValue returns a non-negative whole number from the trigger config
+func limitValue(triggerConfig map[string]interface{}, key string) (int, error) {
+	raw, ok := triggerConfig[key]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	n, ok := raw.(float64)
+	if !ok || n < 0 || n != math.Trunc(n) || n > math.MaxInt32 {
+		return 0, fmt.Errorf("%w: %s must be a non-negative whole number", ErrInvalidRunLimits, key)
+	}
+	return int(n), nil
+}
+
+// RunLimitError is returned when an action's run limits refuse a new run
+type RunLimitError struct {
+	// Limit is the trigger_config key of the limit that was reached
+	Limit string
+	// Max is the configured limit: runs, or seconds for the cooldown
+	Max int
+	// Runs are the active runs that count against a concurrency limit, or
+	// the run that started the cooldown
+	Runs []uuid.UUID
+	// RetryAfter is how long the cooldown has left; it is zero for
+	// concurrency limits, which last until a run finishes
+	RetryAfter time.Duration
+}
+
+func (e *RunLimitError) Error() string {
+	switch e.Limit {
+	case LimitCooldown:
+		return fmt.Sprintf("the action was triggered less than %d seconds ago; retry in %d seconds",
+			e.Max, int(math.Ceil(e.RetryAfter.Seconds())))
+	case LimitConcurrentPerEntity:
+		return fmt.Sprintf("the action already has %d active %s on this entity", len(e.Runs), plural(len(e.Runs), "run"))
+	default:
+		return fmt.Sprintf("the action already has %d active %s", len(e.Runs), plural(len(e.Runs), "run"))
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return noun
+	}
+	return noun + "s"
+}
+
+// RunIDs returns the runs of the error as strings
+func (e *RunLimitError) RunIDs() []string {
+	ids := make([]string, len(e.Runs))
+	for i, id := range e.Runs {
+		ids[i] = id.String()
+	}
+	return ids
+}
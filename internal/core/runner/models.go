@@ -0,0 +1,215 @@
+package runner
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+// Runner health. A runner is online while it has called the runner API
+// within OfflineAfter.
+const (
+	HealthOnline  = "online"
+	HealthOffline = "offline"
+)
+
+// Runner is an agent that executes a team's action runs from inside a
+// network Baseplate cannot reach. It pulls work with its own token.
+type Runner struct {
+	ID         uuid.UUID  `json:"id"`
+	TeamID     uuid.UUID  `json:"team_id"`
+	Name       string     `json:"name"`
+	Labels     []string   `json:"labels"`
+	Version    string     `json:"version,omitempty"`
+	Hostname   string     `json:"hostname,omitempty"`
+	CreatedBy  *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	// Health is online or offline, as of the request
+	Health string `json:"health"`
+	// ActiveRuns counts the runs the runner is executing
+	ActiveRuns int `json:"active_runs"`
+}
+
+type CreateRunnerRequest struct {
+	Name   string   `json:"name" binding:"required,max=100"`
+	Labels []string `json:"labels"`
+}
+
+// UpdateRunnerRequest renames a runner or replaces its labels; omitted
+// fields are kept
+type UpdateRunnerRequest struct {
+	Name   *string   `json:"name" binding:"omitempty,max=100"`
+	Labels *[]string `json:"labels"`
+}
+
+// HeartbeatRequest is what a runner reports about itself
+type HeartbeatRequest struct {
+	Version  string `json:"version" binding:"max=50"`
+	Hostname string `json:"hostname" binding:"max=255"`
+}
+
+// HeartbeatResponse tells a runner how it is registered
+type HeartbeatResponse struct {
+	Runner *Runner `json:"runner"`
+	// LeaseSeconds is how long runs stay leased without a report or heartbeat
+	LeaseSeconds int `json:"lease_seconds"`
+}
+
+// FleetRunner is a runner in the installation-wide admin view
+type FleetRunner struct {
+	*Runner
+	TeamName string `json:"team_name"`
+}
+
+type FleetRequest struct {
+	TeamID *uuid.UUID
+	Health string
+	Limit  int
+	Offset int
+}
+
+type FleetResponse struct {
+	Runners []*FleetRunner `json:"runners"`
+	Total   int            `json:"total"`
+	Online  int            `json:"online"`
+	Offline int            `json:"offline"`
+}
+
+// CreateRunnerResponse carries the runner token, which is only shown once
+type CreateRunnerResponse struct {
+	Runner *Runner `json:"runner"`
+	Token  string  `json:"token"`
+}
+
+type ListRunnersResponse struct {
+	Runners []*Runner `json:"runners"`
+	Total   int       `json:"total"`
+}
+
+// Run statuses. A run is pending until a runner claims it, running while
+// the runner holds its lease, and then succeeded, failed or cancelled.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+// Run is one execution of an action. Runs a schedule created have its
+// ScheduleID and the time they were ScheduledFor.
+type Run struct {
+	ID             uuid.UUID              `json:"id"`
+	TeamID         uuid.UUID              `json:"team_id"`
+	ActionID       uuid.UUID              `json:"action_id"`
+	Action         string                 `json:"action"`
+	EntityID       *uuid.UUID             `json:"entity_id,omitempty"`
+	Inputs         map[string]interface{} `json:"inputs"`
+	Status         string                 `json:"status"`
+	Message        string                 `json:"message,omitempty"`
+	RunnerID       *uuid.UUID             `json:"runner_id,omitempty"`
+	TriggeredBy    *uuid.UUID             `json:"triggered_by,omitempty"`
+	ScheduleID     *uuid.UUID             `json:"schedule_id,omitempty"`
+	ScheduledFor   *time.Time             `json:"scheduled_for,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	ClaimedAt      *time.Time             `json:"claimed_at,omitempty"`
+	LeaseExpiresAt *time.Time             `json:"lease_expires_at,omitempty"`
+	FinishedAt     *time.Time             `json:"finished_at,omitempty"`
+}
+
+type TriggerRunRequest struct {
+	EntityID *uuid.UUID             `json:"entity_id"`
+	Inputs   map[string]interface{} `json:"inputs"`
+}
+
+type ListRunsRequest struct {
+	Status     string
+	ScheduleID *uuid.UUID
+	Limit      int
+	Offset     int
+}
+
+type ListRunsResponse struct {
+	Runs  []*Run `json:"runs"`
+	Total int    `json:"total"`
+}
+
+// Schedule triggers an action once at RunAt or repeatedly by Cron, in
+// Timezone. Actions of a blueprint run on EntityID or on every entity the
+// Filters select; an empty filter list selects all of the blueprint's
+// entities.
+type Schedule struct {
+	ID        uuid.UUID              `json:"id"`
+	TeamID    uuid.UUID              `json:"team_id"`
+	ActionID  uuid.UUID              `json:"action_id"`
+	Action    string                 `json:"action"`
+	Name      string                 `json:"name"`
+	EntityID  *uuid.UUID             `json:"entity_id,omitempty"`
+	Filters   []entity.SearchFilter  `json:"filters"`
+	Inputs    map[string]interface{} `json:"inputs"`
+	Cron      string                 `json:"cron,omitempty"`
+	RunAt     *time.Time             `json:"run_at,omitempty"`
+	Timezone  string                 `json:"timezone"`
+	Enabled   bool                   `json:"enabled"`
+	NextRunAt *time.Time             `json:"next_run_at,omitempty"`
+	LastRunAt *time.Time             `json:"last_run_at,omitempty"`
+	// LastError says why the last firing created fewer runs than it selected
+	LastError string     `json:"last_error,omitempty"`
+	CreatedBy *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+type CreateScheduleRequest struct {
+	Name     string                 `json:"name" binding:"required,max=100"`
+	EntityID *uuid.UUID             `json:"entity_id"`
+	Filters  []entity.SearchFilter  `json:"filters"`
+	Inputs   map[string]interface{} `json:"inputs"`
+	Cron     string                 `json:"cron"`
+	RunAt    *time.Time             `json:"run_at"`
+	Timezone string                 `json:"timezone"`
+	Enabled  *bool                  `json:"enabled"`
+}
+
+// UpdateScheduleRequest replaces a schedule's settings; the action stays
+type UpdateScheduleRequest = CreateScheduleRequest
+
+type ListSchedulesResponse struct {
+	Schedules []*Schedule `json:"schedules"`
+	Total     int         `json:"total"`
+}
+
+// LogLine is one line a runner reported for a run. Seq orders the lines and
+// lets readers page through them.
+type LogLine struct {
+	Seq       int64     `json:"seq"`
+	Line      string    `json:"line"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RunLogsResponse struct {
+	Lines []*LogLine `json:"lines"`
+	// Next is the after value for the following page
+	Next int64 `json:"next"`
+}
+
+// Job is a claimed run as handed to a runner: the run, and the action's
+// trigger config and steps with their secret references resolved
+type Job struct {
+	Run           *Run                   `json:"run"`
+	Action        string                 `json:"action"`
+	Blueprint     string                 `json:"blueprint,omitempty"`
+	TriggerConfig map[string]interface{} `json:"trigger_config"`
+	Steps         []interface{}          `json:"steps"`
+}
+
+// ReportRequest is a runner's progress report on a run it claimed. Every
+// report extends the lease; a final status ends the run.
+type ReportRequest struct {
+	Status  string   `json:"status" binding:"required"`
+	Message string   `json:"message"`
+	Logs    []string `json:"logs"`
+}
@@ -0,0 +1,783 @@
+package runner
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const runnerColumns = `
+	runners.id, runners.team_id, runners.name, runners.labels, COALESCE(runners.version, ''), COALESCE(runners.hostname, ''),
+	runners.created_by, runners.created_at, runners.last_seen_at,
+	(SELECT COUNT(*) FROM action_runs WHERE action_runs.runner_id = runners.id AND action_runs.status = '` + StatusRunning + `')`
+
+func (r *Repository) CreateRunner(ctx context.Context, runner *Runner, tokenHash string) error {
+	labels, err := json.Marshal(runner.Labels)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO runners (id, team_id, name, labels, token_hash, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at`
+	err = r.db.DB.QueryRowContext(ctx, query,
+		runner.ID, runner.TeamID, runner.Name, labels, tokenHash, runner.CreatedBy,
+	).Scan(&runner.CreatedAt)
+	if postgres.IsUniqueViolation(err) {
+		return ErrRunnerExists
+	}
+	return err
+}
+
+func (r *Repository) ListRunners(ctx context.Context, teamID uuid.UUID) ([]*Runner, error) {
+	query := `SELECT ` + runnerColumns + ` FROM runners WHERE team_id = $1 ORDER BY name`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRunners(rows)
+}
+
+func (r *Repository) GetRunner(ctx context.Context, teamID, id uuid.UUID) (*Runner, error) {
+	query := `SELECT ` + runnerColumns + ` FROM runners WHERE team_id = $1 AND id = $2`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runners, err := scanRunners(rows)
+	if err != nil || len(runners) == 0 {
+		return nil, err
+	}
+	return runners[0], nil
+}
+
+// UpdateRunner renames a runner and replaces its labels; a nil name or
+// labels keeps the current value. It returns nil when there is no such runner.
+func (r *Repository) UpdateRunner(ctx context.Context, teamID, id uuid.UUID, name *string, labels []string) (*Runner, error) {
+	var labelsParam interface{}
+	if labels != nil {
+		encoded, err := json.Marshal(labels)
+		if err != nil {
+			return nil, err
+		}
+		labelsParam = string(encoded)
+	}
+	query := `
+		UPDATE runners SET name = COALESCE($3, name), labels = COALESCE($4, labels)
+		WHERE team_id = $1 AND id = $2
+		RETURNING ` + runnerColumns
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, id, name, labelsParam)
+	if err == nil {
+		defer rows.Close()
+		var runners []*Runner
+		if runners, err = scanRunners(rows); err == nil {
+			if len(runners) == 0 {
+				return nil, nil
+			}
+			return runners[0], nil
+		}
+	}
+	if postgres.IsUniqueViolation(err) {
+		return nil, ErrRunnerExists
+	}
+	return nil, err
+}
+
+// Heartbeat records the version and host a runner reports, keeping the
+// current ones for empty values, and extends the leases of the runs it
+// executes
+func (r *Repository) Heartbeat(ctx context.Context, runnerID uuid.UUID, req *HeartbeatRequest, lease time.Duration) (*Runner, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE action_runs SET lease_expires_at = NOW() + $2 * INTERVAL '1 second'
+		WHERE runner_id = $1 AND status = $3 AND lease_expires_at > NOW()`,
+		runnerID, int64(lease/time.Second), StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE runners
+		SET version = COALESCE(NULLIF($2, ''), version), hostname = COALESCE(NULLIF($3, ''), hostname), last_seen_at = NOW()
+		WHERE id = $1
+		RETURNING ` + runnerColumns
+	rows, err := tx.QueryContext(ctx, query, runnerID, req.Version, req.Hostname)
+	if err != nil {
+		return nil, err
+	}
+	runners, err := scanRunners(rows)
+	rows.Close()
+	if err != nil || len(runners) == 0 {
+		return nil, err
+	}
+	return runners[0], tx.Commit()
+}
+
+// Fleet returns a page of runners across teams, most recently seen first,
+// with their team names. Runners seen within offlineAfter are online. It
+// also returns how many runners match, and how many of the runners of the
+// requested teams are online and offline.
+func (r *Repository) Fleet(ctx context.Context, req *FleetRequest, offlineAfter time.Duration) ([]*FleetRunner, int, int, int, error) {
+	seconds := int64(offlineAfter / time.Second)
+	const online = `(runners.last_seen_at IS NOT NULL AND runners.last_seen_at > NOW() - $2 * INTERVAL '1 second')`
+
+	var onlineCount, offlineCount int
+	err := r.db.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FILTER (WHERE `+online+`), COUNT(*) FILTER (WHERE NOT `+online+`)
+		FROM runners WHERE ($1::uuid IS NULL OR runners.team_id = $1)`,
+		req.TeamID, seconds,
+	).Scan(&onlineCount, &offlineCount)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	total := onlineCount + offlineCount
+	switch req.Health {
+	case HealthOnline:
+		total = onlineCount
+	case HealthOffline:
+		total = offlineCount
+	}
+
+	query := `
+		SELECT ` + runnerColumns + `, teams.name
+		FROM runners JOIN teams ON teams.id = runners.team_id
+		WHERE ($1::uuid IS NULL OR runners.team_id = $1)
+		  AND ($3 = '' OR ($3 = '` + HealthOnline + `') = ` + online + `)
+		ORDER BY runners.last_seen_at DESC NULLS LAST, teams.name, runners.name
+		LIMIT $4 OFFSET $5`
+	rows, err := r.db.DB.QueryContext(ctx, query, req.TeamID, seconds, req.Health, req.Limit, req.Offset)
+	if err != nil {
+		return nil, 0, 0, 0, err
+	}
+	defer rows.Close()
+
+	var fleet []*FleetRunner
+	for rows.Next() {
+		f := &FleetRunner{Runner: &Runner{}}
+		if err := scanRunner(rows, f.Runner, &f.TeamName); err != nil {
+			return nil, 0, 0, 0, err
+		}
+		fleet = append(fleet, f)
+	}
+	return fleet, total, onlineCount, offlineCount, rows.Err()
+}
+
+func (r *Repository) DeleteRunner(ctx context.Context, teamID, id uuid.UUID) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM runners WHERE team_id = $1 AND id = $2`, teamID, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// TouchRunner returns the runner a token hash belongs to and records that it
+// was seen, or nil when there is none
+func (r *Repository) TouchRunner(ctx context.Context, tokenHash string) (*Runner, error) {
+	query := `UPDATE runners SET last_seen_at = NOW() WHERE token_hash = $1 RETURNING ` + runnerColumns
+	rows, err := r.db.DB.QueryContext(ctx, query, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runners, err := scanRunners(rows)
+	if err != nil || len(runners) == 0 {
+		return nil, err
+	}
+	return runners[0], nil
+}
+
+// action is what a run needs to know about its action
+type action struct {
+	ID            uuid.UUID
+	Identifier    string
+	BlueprintID   string
+	TriggerConfig map[string]interface{}
+	Steps         []interface{}
+}
+
+func (r *Repository) ActionByIdentifier(ctx context.Context, teamID uuid.UUID, identifier string) (*action, error) {
+	return r.action(ctx, `team_id = $1 AND identifier = $2`, teamID, identifier)
+}
+
+func (r *Repository) actionByID(ctx context.Context, teamID, id uuid.UUID) (*action, error) {
+	return r.action(ctx, `team_id = $1 AND id = $2`, teamID, id)
+}
+
+func (r *Repository) action(ctx context.Context, where string, args ...interface{}) (*action, error) {
+	query := `
+		SELECT id, identifier, COALESCE(blueprint_id, ''), COALESCE(trigger_config, '{}'), steps
+		FROM actions WHERE ` + where
+	a := &action{}
+	var triggerConfig, steps []byte
+	err := r.db.DB.QueryRowContext(ctx, query, args...).Scan(&a.ID, &a.Identifier, &a.BlueprintID, &triggerConfig, &steps)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(triggerConfig, &a.TriggerConfig); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(steps, &a.Steps); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// EntityBlueprint returns the blueprint of a team's entity, or false when
+// the team has no such entity
+func (r *Repository) EntityBlueprint(ctx context.Context, teamID, entityID uuid.UUID) (string, bool, error) {
+	var blueprintID string
+	err := r.db.DB.QueryRowContext(ctx,
+		`SELECT blueprint_id FROM entities WHERE team_id = $1 AND id = $2`, teamID, entityID,
+	).Scan(&blueprintID)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return blueprintID, err == nil, err
+}
+
+// CreateRun queues a run once the action's limits allow it, or returns a
+// *RunLimitError. A run a schedule already created for the same time and
+// entity is ErrRunScheduled. Locking the action row serializes triggers of the action,
+// so concurrent triggers cannot both pass a limit.
+func (r *Repository) CreateRun(ctx context.Context, run *Run, limits *RunLimits) error {
+	inputs, err := json.Marshal(run.Inputs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// A retried schedule job must not count its own earlier run against the limits
+	if run.ScheduleID != nil {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(SELECT 1 FROM action_runs
+				WHERE schedule_id = $1 AND scheduled_for = $2 AND entity_id IS NOT DISTINCT FROM $3)`,
+			run.ScheduleID, run.ScheduledFor, run.EntityID,
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return ErrRunScheduled
+		}
+	}
+
+	if !limits.IsZero() {
+		if _, err := tx.ExecContext(ctx, `SELECT 1 FROM actions WHERE id = $1 FOR UPDATE`, run.ActionID); err != nil {
+			return err
+		}
+		if err := checkRunLimits(ctx, tx, run, limits); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO action_runs (id, team_id, action_id, entity_id, inputs, status, triggered_by, schedule_id, scheduled_for)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at`
+	err = tx.QueryRowContext(ctx, query,
+		run.ID, run.TeamID, run.ActionID, run.EntityID, inputs, run.Status, run.TriggeredBy, run.ScheduleID, run.ScheduledFor,
+	).Scan(&run.CreatedAt)
+	if run.ScheduleID != nil && postgres.IsUniqueViolation(err) {
+		return ErrRunScheduled
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkRunLimits returns a *RunLimitError when a new run would exceed limits
+func checkRunLimits(ctx context.Context, tx *sql.Tx, run *Run, limits *RunLimits) error {
+	if limits.MaxConcurrent > 0 {
+		active, err := activeRuns(ctx, tx, `action_id = $1`, run.ActionID)
+		if err != nil {
+			return err
+		}
+		if len(active) >= limits.MaxConcurrent {
+			return &RunLimitError{Limit: LimitConcurrent, Max: limits.MaxConcurrent, Runs: active}
+		}
+	}
+	if limits.MaxConcurrentPerEntity > 0 && run.EntityID != nil {
+		active, err := activeRuns(ctx, tx, `action_id = $1 AND entity_id = $2`, run.ActionID, *run.EntityID)
+		if err != nil {
+			return err
+		}
+		if len(active) >= limits.MaxConcurrentPerEntity {
+			return &RunLimitError{Limit: LimitConcurrentPerEntity, Max: limits.MaxConcurrentPerEntity, Runs: active}
+		}
+	}
+	if limits.Cooldown > 0 {
+		var last uuid.UUID
+		var elapsed float64
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, EXTRACT(EPOCH FROM NOW() - created_at)
+			FROM action_runs
+			WHERE action_id = $1 AND entity_id IS NOT DISTINCT FROM $2 AND status <> $3
+			ORDER BY created_at DESC
+			LIMIT 1`,
+			run.ActionID, run.EntityID, StatusCancelled,
+		).Scan(&last, &elapsed)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil {
+			if left := limits.Cooldown - time.Duration(elapsed*float64(time.Second)); left > 0 {
+				return &RunLimitError{Limit: LimitCooldown, Max: int(limits.Cooldown.Seconds()), Runs: []uuid.UUID{last}, RetryAfter: left}
+			}
+		}
+	}
+	return nil
+}
+
+// activeRuns returns the pending and running runs matching where, oldest first
+func activeRuns(ctx context.Context, tx *sql.Tx, where string, args ...interface{}) ([]uuid.UUID, error) {
+	n := len(args)
+	query := fmt.Sprintf(`SELECT id FROM action_runs WHERE %s AND status IN ($%d, $%d) ORDER BY created_at`, where, n+1, n+2)
+	rows, err := tx.QueryContext(ctx, query, append(args, StatusPending, StatusRunning)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+const runColumns = `
+	r.id, r.team_id, r.action_id, a.identifier, r.entity_id, r.inputs, r.status, COALESCE(r.message, ''),
+	r.runner_id, r.triggered_by, r.schedule_id, r.scheduled_for, r.created_at, r.claimed_at, r.lease_expires_at, r.finished_at`
+
+const runFrom = ` FROM action_runs r JOIN actions a ON a.id = r.action_id `
+
+func (r *Repository) GetRun(ctx context.Context, teamID, id uuid.UUID) (*Run, error) {
+	return r.run(ctx, `r.team_id = $1 AND r.id = $2`, teamID, id)
+}
+
+func (r *Repository) run(ctx context.Context, where string, args ...interface{}) (*Run, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT `+runColumns+runFrom+`WHERE `+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs, err := scanRuns(rows)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	return runs[0], nil
+}
+
+// ListRuns returns a page of a team's runs, newest first, and how many
+// match in total
+func (r *Repository) ListRuns(ctx context.Context, teamID uuid.UUID, req *ListRunsRequest) ([]*Run, int, error) {
+	var total int
+	err := r.db.DB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM action_runs
+		WHERE team_id = $1 AND ($2 = '' OR status = $2) AND ($3::uuid IS NULL OR schedule_id = $3)`,
+		teamID, req.Status, req.ScheduleID,
+	).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := `SELECT ` + runColumns + runFrom + `
+		WHERE r.team_id = $1 AND ($2 = '' OR r.status = $2) AND ($3::uuid IS NULL OR r.schedule_id = $3)
+		ORDER BY r.created_at DESC, r.id
+		LIMIT $4 OFFSET $5`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, req.Status, req.ScheduleID, req.Limit, req.Offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	runs, err := scanRuns(rows)
+	return runs, total, err
+}
+
+// CancelRun cancels a run that has not finished. It returns nil when the
+// run does not exist or has already finished.
+func (r *Repository) CancelRun(ctx context.Context, teamID, id uuid.UUID) (*Run, error) {
+	query := `
+		UPDATE action_runs SET status = $3, lease_expires_at = NULL, finished_at = NOW()
+		WHERE team_id = $1 AND id = $2 AND status IN ($4, $5)`
+	result, err := r.db.DB.ExecContext(ctx, query, teamID, id, StatusCancelled, StatusPending, StatusRunning)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return nil, err
+	}
+	return r.GetRun(ctx, teamID, id)
+}
+
+// ExpireLeases fails a team's running runs whose runner stopped reporting
+func (r *Repository) ExpireLeases(ctx context.Context, teamID uuid.UUID) error {
+	query := `
+		UPDATE action_runs
+		SET status = $2, message = $3, lease_expires_at = NULL, finished_at = NOW()
+		WHERE team_id = $1 AND status = $4 AND lease_expires_at < NOW()`
+	_, err := r.db.DB.ExecContext(ctx, query, teamID, StatusFailed, leaseExpiredMessage, StatusRunning)
+	return err
+}
+
+// Claim hands the oldest pending run the runner's labels qualify for to the
+// runner, leasing it for lease. Concurrent claims skip each other's rows.
+// It returns nil when there is nothing to run.
+func (r *Repository) Claim(ctx context.Context, runner *Runner, lease time.Duration) (*Run, *action, error) {
+	labels, err := json.Marshal(runner.Labels)
+	if err != nil {
+		return nil, nil, err
+	}
+	query := `
+		UPDATE action_runs
+		SET status = $3, runner_id = $2, claimed_at = NOW(), lease_expires_at = NOW() + $4 * INTERVAL '1 second'
+		WHERE id = (
+			SELECT r.id` + runFrom + `
+			WHERE r.team_id = $1 AND r.status = $5
+			  AND COALESCE(a.trigger_config->'runner_labels', '[]') <@ $6::jsonb
+			ORDER BY r.created_at
+			LIMIT 1
+			FOR UPDATE OF r SKIP LOCKED
+		)
+		RETURNING id, action_id`
+	var runID, actionID uuid.UUID
+	err = r.db.DB.QueryRowContext(ctx, query,
+		runner.TeamID, runner.ID, StatusRunning, int64(lease/time.Second), StatusPending, labels,
+	).Scan(&runID, &actionID)
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	run, err := r.GetRun(ctx, runner.TeamID, runID)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := r.actionByID(ctx, runner.TeamID, actionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return run, a, nil
+}
+
+// Report records a runner's report on a running run it holds the lease of:
+// it appends the log lines and either extends the lease or, for a final
+// status, ends the run. It returns false when the runner holds no such lease.
+func (r *Repository) Report(ctx context.Context, runnerID, runID uuid.UUID, req *ReportRequest, lease time.Duration) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE action_runs
+		SET status = $3,
+			message = COALESCE(NULLIF($4, ''), message),
+			lease_expires_at = CASE WHEN $3 = $5 THEN NOW() + $6 * INTERVAL '1 second' END,
+			finished_at = CASE WHEN $3 = $5 THEN NULL ELSE NOW() END
+		WHERE id = $1 AND runner_id = $2 AND status = $5 AND lease_expires_at > NOW()`
+	result, err := tx.ExecContext(ctx, query, runID, runnerID, req.Status, req.Message, StatusRunning, int64(lease/time.Second))
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if len(req.Logs) > 0 {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO action_run_logs (run_id, line)
+			SELECT $1, line FROM unnest($2::text[]) WITH ORDINALITY AS l(line, n) ORDER BY n`,
+			runID, req.Logs)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// RunForRunner returns a run the runner has claimed, or nil
+func (r *Repository) RunForRunner(ctx context.Context, runnerID, runID uuid.UUID) (*Run, error) {
+	return r.run(ctx, `r.runner_id = $1 AND r.id = $2`, runnerID, runID)
+}
+
+// Logs returns up to limit log lines of a run after the given sequence number
+func (r *Repository) Logs(ctx context.Context, runID uuid.UUID, after int64, limit int) ([]*LogLine, error) {
+	query := `
+		SELECT id, line, created_at FROM action_run_logs
+		WHERE run_id = $1 AND id > $2
+		ORDER BY id
+		LIMIT $3`
+	rows, err := r.db.DB.QueryContext(ctx, query, runID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []*LogLine
+	for rows.Next() {
+		l := &LogLine{}
+		if err := rows.Scan(&l.Seq, &l.Line, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+const scheduleColumns = `
+	s.id, s.team_id, s.action_id, a.identifier, s.name, s.entity_id, s.filters, s.inputs, COALESCE(s.cron, ''), s.run_at,
+	s.timezone, s.enabled, s.next_run_at, s.last_run_at, COALESCE(s.last_error, ''), s.created_by, s.created_at, s.updated_at`
+
+const scheduleFrom = ` FROM action_schedules s JOIN actions a ON a.id = s.action_id `
+
+func (r *Repository) CreateSchedule(ctx context.Context, schedule *Schedule) error {
+	filters, inputs, err := marshalScheduleTarget(schedule)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO action_schedules (id, team_id, action_id, name, entity_id, filters, inputs, cron, run_at, timezone, enabled, next_run_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), $9, $10, $11, $12, $13)
+		RETURNING created_at, updated_at`
+	err = r.db.DB.QueryRowContext(ctx, query,
+		schedule.ID, schedule.TeamID, schedule.ActionID, schedule.Name, schedule.EntityID, filters, inputs,
+		schedule.Cron, schedule.RunAt, schedule.Timezone, schedule.Enabled, schedule.NextRunAt, schedule.CreatedBy,
+	).Scan(&schedule.CreatedAt, &schedule.UpdatedAt)
+	if postgres.IsUniqueViolation(err) {
+		return ErrScheduleExists
+	}
+	return err
+}
+
+// UpdateSchedule replaces a schedule's settings. It returns false when there
+// is no such schedule.
+func (r *Repository) UpdateSchedule(ctx context.Context, schedule *Schedule) (bool, error) {
+	filters, inputs, err := marshalScheduleTarget(schedule)
+	if err != nil {
+		return false, err
+	}
+	query := `
+		UPDATE action_schedules
+		SET name = $3, entity_id = $4, filters = $5, inputs = $6, cron = NULLIF($7, ''), run_at = $8, timezone = $9,
+			enabled = $10, next_run_at = $11, last_error = NULL, updated_at = NOW()
+		WHERE team_id = $1 AND id = $2
+		RETURNING updated_at`
+	err = r.db.DB.QueryRowContext(ctx, query,
+		schedule.TeamID, schedule.ID, schedule.Name, schedule.EntityID, filters, inputs,
+		schedule.Cron, schedule.RunAt, schedule.Timezone, schedule.Enabled, schedule.NextRunAt,
+	).Scan(&schedule.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if postgres.IsUniqueViolation(err) {
+		return false, ErrScheduleExists
+	}
+	return err == nil, err
+}
+
+func marshalScheduleTarget(schedule *Schedule) (filters, inputs []byte, err error) {
+	if schedule.Filters != nil {
+		if filters, err = json.Marshal(schedule.Filters); err != nil {
+			return nil, nil, err
+		}
+	}
+	inputs, err = json.Marshal(schedule.Inputs)
+	return filters, inputs, err
+}
+
+func (r *Repository) GetSchedule(ctx context.Context, teamID, id uuid.UUID) (*Schedule, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT `+scheduleColumns+scheduleFrom+`WHERE s.team_id = $1 AND s.id = $2`, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	schedules, err := scanSchedules(rows)
+	if err != nil || len(schedules) == 0 {
+		return nil, err
+	}
+	return schedules[0], nil
+}
+
+// ListSchedules returns a team's schedules by name, only those of one action
+// when actionID is set
+func (r *Repository) ListSchedules(ctx context.Context, teamID uuid.UUID, actionID *uuid.UUID) ([]*Schedule, error) {
+	query := `SELECT ` + scheduleColumns + scheduleFrom + `
+		WHERE s.team_id = $1 AND ($2::uuid IS NULL OR s.action_id = $2)
+		ORDER BY s.name`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSchedules(rows)
+}
+
+func (r *Repository) DeleteSchedule(ctx context.Context, teamID, id uuid.UUID) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM action_schedules WHERE team_id = $1 AND id = $2`, teamID, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AdvanceDueSchedules locks up to limit enabled schedules that are due, lets
+// fire act on each and moves the schedule to the next time fire returns;
+// nil ends it. Concurrent callers skip each other's schedules.
+func (r *Repository) AdvanceDueSchedules(ctx context.Context, limit int, fire func(*Schedule) (*time.Time, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `SELECT ` + scheduleColumns + scheduleFrom + `
+		WHERE s.enabled AND s.next_run_at <= NOW()
+		ORDER BY s.next_run_at
+		LIMIT $1
+		FOR UPDATE OF s SKIP LOCKED`
+	rows, err := tx.QueryContext(ctx, query, limit)
+	if err != nil {
+		return 0, err
+	}
+	schedules, err := scanSchedules(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, schedule := range schedules {
+		next, err := fire(schedule)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.ExecContext(ctx, `
+			UPDATE action_schedules SET next_run_at = $2, last_run_at = $3, enabled = enabled AND $2::timestamptz IS NOT NULL
+			WHERE id = $1`,
+			schedule.ID, next, schedule.NextRunAt)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(schedules), tx.Commit()
+}
+
+// SetScheduleError records why a schedule's last firing fell short, or
+// clears it
+func (r *Repository) SetScheduleError(ctx context.Context, id uuid.UUID, lastError string) error {
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE action_schedules SET last_error = NULLIF($2, '') WHERE id = $1`, id, lastError)
+	return err
+}
+
+func scanSchedules(rows *sql.Rows) ([]*Schedule, error) {
+	var schedules []*Schedule
+	for rows.Next() {
+		s := &Schedule{}
+		var filters, inputs []byte
+		if err := rows.Scan(
+			&s.ID, &s.TeamID, &s.ActionID, &s.Action, &s.Name, &s.EntityID, &filters, &inputs, &s.Cron, &s.RunAt,
+			&s.Timezone, &s.Enabled, &s.NextRunAt, &s.LastRunAt, &s.LastError, &s.CreatedBy, &s.CreatedAt, &s.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if filters != nil {
+			if err := json.Unmarshal(filters, &s.Filters); err != nil {
+				return nil, err
+			}
+		}
+		if err := json.Unmarshal(inputs, &s.Inputs); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+	return schedules, rows.Err()
+}
+
+func scanRunners(rows *sql.Rows) ([]*Runner, error) {
+	var runners []*Runner
+	for rows.Next() {
+		runner := &Runner{}
+		if err := scanRunner(rows, runner); err != nil {
+			return nil, err
+		}
+		runners = append(runners, runner)
+	}
+	return runners, rows.Err()
+}
+
+// scanRunner scans runnerColumns, followed by extra columns, into runner
+func scanRunner(rows *sql.Rows, runner *Runner, extra ...interface{}) error {
+	var labels []byte
+	dest := append([]interface{}{
+		&runner.ID, &runner.TeamID, &runner.Name, &labels, &runner.Version, &runner.Hostname,
+		&runner.CreatedBy, &runner.CreatedAt, &runner.LastSeenAt, &runner.ActiveRuns,
+	}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	return json.Unmarshal(labels, &runner.Labels)
+}
+
+func scanRuns(rows *sql.Rows) ([]*Run, error) {
+	var runs []*Run
+	for rows.Next() {
+		run := &Run{}
+		var inputs []byte
+		if err := rows.Scan(
+			&run.ID, &run.TeamID, &run.ActionID, &run.Action, &run.EntityID, &inputs, &run.Status, &run.Message,
+			&run.RunnerID, &run.TriggeredBy, &run.ScheduleID, &run.ScheduledFor, &run.CreatedAt, &run.ClaimedAt, &run.LeaseExpiresAt, &run.FinishedAt,
+		); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(inputs, &run.Inputs); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
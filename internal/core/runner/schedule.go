@@ -0,0 +1,356 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/cron"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrScheduleExists   = errors.New("a schedule with this name already exists")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+	// ErrRunScheduled means a schedule already created the run for this
+	// time and entity, as when its job is retried
+	ErrRunScheduled = errors.New("the schedule already created this run")
+)
+
+// MaxScheduleEntities bounds the runs one firing of a schedule creates; the
+// entities its filters select beyond that are skipped
+const MaxScheduleEntities = 500
+
+// EntitySearcher validates schedule filters and finds the entities they
+// select; entity.Service is one
+type EntitySearcher interface {
+	ValidateFilters(ctx context.Context, teamID uuid.UUID, blueprintID string, filters []entity.SearchFilter) error
+	Search(ctx context.Context, teamID uuid.UUID, blueprintID string, req *entity.SearchRequest) (*entity.ListEntitiesResponse, error)
+}
+
+// CreateSchedule schedules runs of a team's action
+func (s *Service) CreateSchedule(ctx context.Context, teamID uuid.UUID, identifier string, req *CreateScheduleRequest, userID *uuid.UUID, ipAddress, userAgent *string) (*Schedule, error) {
+	a, err := s.repo.ActionByIdentifier(ctx, teamID, identifier)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrActionNotFound
+	}
+
+	schedule := &Schedule{
+		ID:        uuid.New(),
+		TeamID:    teamID,
+		ActionID:  a.ID,
+		Action:    a.Identifier,
+		CreatedBy: userID,
+	}
+	if err := s.applySchedule(ctx, a, schedule, req); err != nil {
+		return nil, err
+	}
+	if err := s.repo.CreateSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+	s.record(teamID, "action_schedule", schedule.ID.String(), "create", scheduleAudit(schedule), userID, ipAddress, userAgent)
+	return schedule, nil
+}
+
+// UpdateSchedule replaces a schedule's target and timing; its next run is
+// computed again from now
+func (s *Service) UpdateSchedule(ctx context.Context, teamID, id uuid.UUID, req *UpdateScheduleRequest, userID *uuid.UUID, ipAddress, userAgent *string) (*Schedule, error) {
+	schedule, err := s.GetSchedule(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	a, err := s.repo.actionByID(ctx, teamID, schedule.ActionID)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrScheduleNotFound
+	}
+
+	if err := s.applySchedule(ctx, a, schedule, req); err != nil {
+		return nil, err
+	}
+	found, err := s.repo.UpdateSchedule(ctx, schedule)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrScheduleNotFound
+	}
+	schedule.LastError = ""
+	s.record(teamID, "action_schedule", schedule.ID.String(), "update", scheduleAudit(schedule), userID, ipAddress, userAgent)
+	return schedule, nil
+}
+
+// applySchedule validates a request and sets the schedule from it
+func (s *Service) applySchedule(ctx context.Context, a *action, schedule *Schedule, req *CreateScheduleRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name must not be empty", ErrInvalidSchedule)
+	}
+
+	switch {
+	case a.BlueprintID == "" && req.Filters != nil:
+		return fmt.Errorf("%w: filters select entities of a blueprint; this action is team-wide", ErrInvalidSchedule)
+	case a.BlueprintID != "" && (req.EntityID == nil) == (req.Filters == nil):
+		return fmt.Errorf("%w: set either entity_id or filters", ErrInvalidSchedule)
+	case req.EntityID != nil:
+		blueprintID, found, err := s.repo.EntityBlueprint(ctx, schedule.TeamID, *req.EntityID)
+		if err != nil {
+			return err
+		}
+		if !found || (a.BlueprintID != "" && blueprintID != a.BlueprintID) {
+			return ErrInvalidEntity
+		}
+	default:
+		if err := s.entities.ValidateFilters(ctx, schedule.TeamID, a.BlueprintID, req.Filters); err != nil {
+			if errors.Is(err, entity.ErrInvalidFilter) {
+				return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+			}
+			return err
+		}
+	}
+
+	timezone := req.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, timezone)
+	}
+	now := s.now()
+	var next *time.Time
+	switch {
+	case (req.Cron == "") == (req.RunAt == nil):
+		return fmt.Errorf("%w: set either cron or run_at", ErrInvalidSchedule)
+	case req.RunAt != nil:
+		if !req.RunAt.After(now) {
+			return fmt.Errorf("%w: run_at must be in the future", ErrInvalidSchedule)
+		}
+		at := *req.RunAt
+		next = &at
+	default:
+		expr, err := cron.Parse(req.Cron)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+		}
+		at := expr.Next(now.In(loc))
+		if at.IsZero() {
+			return fmt.Errorf("%w: cron %q never matches", ErrInvalidSchedule, req.Cron)
+		}
+		next = &at
+	}
+
+	schedule.Name = name
+	schedule.EntityID = req.EntityID
+	schedule.Filters = req.Filters
+	schedule.Inputs = req.Inputs
+	if schedule.Inputs == nil {
+		schedule.Inputs = map[string]interface{}{}
+	}
+	schedule.Cron = strings.TrimSpace(req.Cron)
+	schedule.RunAt = req.RunAt
+	schedule.Timezone = timezone
+	schedule.Enabled = req.Enabled == nil || *req.Enabled
+	schedule.NextRunAt = next
+	return nil
+}
+
+// nextRun returns when a schedule runs after now, or nil when it ran for
+// the last time. Missed cron times are skipped, not made up for.
+func nextRun(schedule *Schedule, now time.Time) *time.Time {
+	if schedule.Cron == "" {
+		return nil
+	}
+	expr, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	at := expr.Next(now.In(loc))
+	if at.IsZero() {
+		return nil
+	}
+	return &at
+}
+
+func scheduleAudit(schedule *Schedule) map[string]any {
+	return map[string]any{
+		"name":      schedule.Name,
+		"action":    schedule.Action,
+		"entity_id": schedule.EntityID,
+		"filters":   schedule.Filters,
+		"cron":      schedule.Cron,
+		"run_at":    schedule.RunAt,
+		"timezone":  schedule.Timezone,
+		"enabled":   schedule.Enabled,
+	}
+}
+
+// ListSchedules returns a team's schedules, only those of one action when
+// identifier is set
+func (s *Service) ListSchedules(ctx context.Context, teamID uuid.UUID, identifier string) (*ListSchedulesResponse, error) {
+	var actionID *uuid.UUID
+	if identifier != "" {
+		a, err := s.repo.ActionByIdentifier(ctx, teamID, identifier)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil {
+			return nil, ErrActionNotFound
+		}
+		actionID = &a.ID
+	}
+	schedules, err := s.repo.ListSchedules(ctx, teamID, actionID)
+	if err != nil {
+		return nil, err
+	}
+	if schedules == nil {
+		schedules = []*Schedule{}
+	}
+	return &ListSchedulesResponse{Schedules: schedules, Total: len(schedules)}, nil
+}
+
+func (s *Service) GetSchedule(ctx context.Context, teamID, id uuid.UUID) (*Schedule, error) {
+	schedule, err := s.repo.GetSchedule(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule == nil {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, nil
+}
+
+// DeleteSchedule stops a schedule; the runs it created are kept
+func (s *Service) DeleteSchedule(ctx context.Context, teamID, id uuid.UUID, userID *uuid.UUID, ipAddress, userAgent *string) error {
+	found, err := s.repo.DeleteSchedule(ctx, teamID, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrScheduleNotFound
+	}
+	s.record(teamID, "action_schedule", id.String(), "delete", nil, userID, ipAddress, userAgent)
+	return nil
+}
+
+// ScheduleRuns returns the runs a schedule created, newest first
+func (s *Service) ScheduleRuns(ctx context.Context, teamID, id uuid.UUID, req *ListRunsRequest) (*ListRunsResponse, error) {
+	if _, err := s.GetSchedule(ctx, teamID, id); err != nil {
+		return nil, err
+	}
+	req.ScheduleID = &id
+	return s.ListRuns(ctx, teamID, req)
+}
+
+// fireSchedule creates the runs of one firing of a schedule. Runs the
+// action's run limits refuse are skipped, and the schedule's last error
+// says how many.
+func (s *Service) fireSchedule(ctx context.Context, teamID, id uuid.UUID, scheduledFor time.Time) error {
+	schedule, err := s.repo.GetSchedule(ctx, teamID, id)
+	if err != nil || schedule == nil {
+		return err
+	}
+	a, err := s.repo.actionByID(ctx, teamID, schedule.ActionID)
+	if err != nil || a == nil {
+		return err
+	}
+
+	var problems []string
+	targets := []*uuid.UUID{schedule.EntityID}
+	if schedule.Filters != nil {
+		var matched int
+		targets, matched, err = s.scheduleTargets(ctx, teamID, a.BlueprintID, schedule.Filters)
+		if errors.Is(err, entity.ErrInvalidFilter) || errors.Is(err, entity.ErrBlueprintNotFound) {
+			// The blueprint changed under the schedule; retrying cannot help
+			return s.repo.SetScheduleError(ctx, id, err.Error())
+		}
+		if err != nil {
+			return err
+		}
+		if matched > len(targets) {
+			problems = append(problems, fmt.Sprintf("the filters matched %d entities; only the first %d ran", matched, len(targets)))
+		}
+	}
+
+	limits, err := ParseRunLimits(a.TriggerConfig)
+	if err != nil {
+		return s.repo.SetScheduleError(ctx, id, err.Error())
+	}
+	if !limits.IsZero() {
+		if err := s.repo.ExpireLeases(ctx, teamID); err != nil {
+			return err
+		}
+	}
+
+	refused := 0
+	for _, entityID := range targets {
+		at := scheduledFor
+		run := &Run{
+			ID:           uuid.New(),
+			TeamID:       teamID,
+			ActionID:     a.ID,
+			Action:       a.Identifier,
+			EntityID:     entityID,
+			Inputs:       schedule.Inputs,
+			Status:       StatusPending,
+			TriggeredBy:  schedule.CreatedBy,
+			ScheduleID:   &schedule.ID,
+			ScheduledFor: &at,
+		}
+		var limited *RunLimitError
+		switch err := s.repo.CreateRun(ctx, run, limits); {
+		case err == nil, errors.Is(err, ErrRunScheduled):
+		case errors.As(err, &limited):
+			refused++
+		default:
+			return err
+		}
+	}
+	if refused > 0 {
+		problems = append(problems, fmt.Sprintf("%d of %d runs were refused by the action's run limits", refused, len(targets)))
+	}
+	return s.repo.SetScheduleError(ctx, id, strings.Join(problems, "; "))
+}
+
+// scheduleTargets returns up to MaxScheduleEntities entities the filters
+// select, oldest first, and how many they select in all
+func (s *Service) scheduleTargets(ctx context.Context, teamID uuid.UUID, blueprintID string, filters []entity.SearchFilter) ([]*uuid.UUID, int, error) {
+	const page = 100
+	var targets []*uuid.UUID
+	total := 0
+	for offset := 0; offset < MaxScheduleEntities; offset += page {
+		resp, err := s.entities.Search(ctx, teamID, blueprintID, &entity.SearchRequest{
+			Filters:  filters,
+			OrderBy:  "created_at",
+			OrderDir: "asc",
+			Limit:    page,
+			Offset:   offset,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		total = resp.Total
+		for _, e := range resp.Entities {
+			id := e.ID
+			targets = append(targets, &id)
+		}
+		if len(resp.Entities) < page {
+			break
+		}
+	}
+	return targets, total, nil
+}
@@ -0,0 +1,98 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+// fakeEntities accepts filters on the "env" property only
+type fakeEntities struct{}
+
+func (fakeEntities) ValidateFilters(ctx context.Context, teamID uuid.UUID, blueprintID string, filters []entity.SearchFilter) error {
+	for _, f := range filters {
+		if f.Property != "env" {
+			return fmt.Errorf("%w: unknown property", entity.ErrInvalidFilter)
+		}
+	}
+	return nil
+}
+
+func (fakeEntities) Search(ctx context.Context, teamID uuid.UUID, blueprintID string, req *entity.SearchRequest) (*entity.ListEntitiesResponse, error) {
+	return &entity.ListEntitiesResponse{Entities: []*entity.Entity{}}, nil
+}
+
+func TestApplySchedule(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 7, 0, 0, time.UTC)
+	s := NewService(nil, nil, fakeEntities{}, nil)
+	s.now = func() time.Time { return now }
+	teamWide := &action{ID: uuid.New(), Identifier: "rotate-keys"}
+	ofBlueprint := &action{ID: uuid.New(), Identifier: "restart", BlueprintID: "service"}
+	future := now.Add(time.Hour)
+	past := now.Add(-time.Hour)
+	disabled := false
+
+	tests := []struct {
+		name    string
+		action  *action
+		req     CreateScheduleRequest
+		wantErr bool
+		want    time.Time
+	}{
+		{"cron", teamWide, CreateScheduleRequest{Name: "nightly", Cron: "0 2 * * *"}, false, time.Date(2026, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"cron in timezone", teamWide, CreateScheduleRequest{Name: "nightly", Cron: "0 2 * * *", Timezone: "America/New_York"}, false, time.Date(2026, 3, 2, 7, 0, 0, 0, time.UTC)},
+		{"once", teamWide, CreateScheduleRequest{Name: "once", RunAt: &future}, false, future},
+		{"disabled", teamWide, CreateScheduleRequest{Name: "paused", Cron: "@hourly", Enabled: &disabled}, false, time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"filters", ofBlueprint, CreateScheduleRequest{Name: "all prod", Cron: "@daily", Filters: []entity.SearchFilter{{Property: "env", Operator: "eq", Value: "prod"}}}, false, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"every entity", ofBlueprint, CreateScheduleRequest{Name: "all", Cron: "@daily", Filters: []entity.SearchFilter{}}, false, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"empty name", teamWide, CreateScheduleRequest{Name: " ", Cron: "@daily"}, true, time.Time{}},
+		{"no timing", teamWide, CreateScheduleRequest{Name: "x"}, true, time.Time{}},
+		{"both timings", teamWide, CreateScheduleRequest{Name: "x", Cron: "@daily", RunAt: &future}, true, time.Time{}},
+		{"past run_at", teamWide, CreateScheduleRequest{Name: "x", RunAt: &past}, true, time.Time{}},
+		{"bad cron", teamWide, CreateScheduleRequest{Name: "x", Cron: "every day"}, true, time.Time{}},
+		{"never matches", teamWide, CreateScheduleRequest{Name: "x", Cron: "0 0 30 2 *"}, true, time.Time{}},
+		{"bad timezone", teamWide, CreateScheduleRequest{Name: "x", Cron: "@daily", Timezone: "Mars/Olympus"}, true, time.Time{}},
+		{"filters on team-wide action", teamWide, CreateScheduleRequest{Name: "x", Cron: "@daily", Filters: []entity.SearchFilter{}}, true, time.Time{}},
+		{"no target", ofBlueprint, CreateScheduleRequest{Name: "x", Cron: "@daily"}, true, time.Time{}},
+		{"bad filter", ofBlueprint, CreateScheduleRequest{Name: "x", Cron: "@daily", Filters: []entity.SearchFilter{{Property: "tier", Operator: "eq", Value: 1}}}, true, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := &Schedule{TeamID: uuid.New()}
+			err := s.applySchedule(context.Background(), tt.action, schedule, &tt.req)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSchedule) {
+					t.Errorf("applySchedule() = %v, want ErrInvalidSchedule", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applySchedule() = %v", err)
+			}
+			if schedule.NextRunAt == nil || !schedule.NextRunAt.Equal(tt.want) {
+				t.Errorf("NextRunAt = %v, want %v", schedule.NextRunAt, tt.want)
+			}
+			if schedule.Enabled != (tt.req.Enabled == nil) || schedule.Inputs == nil || schedule.Timezone == "" {
+				t.Errorf("schedule = %+v", schedule)
+			}
+		})
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 7, 0, 0, time.UTC)
+	if next := nextRun(&Schedule{RunAt: &now, Timezone: "UTC"}, now); next != nil {
+		t.Errorf("a one-time schedule should end, got %v", next)
+	}
+	// Missed times are skipped
+	next := nextRun(&Schedule{Cron: "*/5 * * * *", Timezone: "UTC"}, now)
+	if want := time.Date(2026, 3, 1, 10, 10, 0, 0, time.UTC); next == nil || !next.Equal(want) {
+		t.Errorf("nextRun() = %v, want %v", next, want)
+	}
+}
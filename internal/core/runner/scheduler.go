@@ -0,0 +1,107 @@
+package runner
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+const (
+	// JobFireSchedule is the background job that creates the runs of one
+	// firing of a schedule
+	JobFireSchedule = "action.schedule"
+
+	// SchedulerInterval is how often due schedules are looked for; cron
+	// schedules have minute resolution
+	SchedulerInterval = 15 * time.Second
+
+	// schedulerBatch is how many due schedules one pass advances at once
+	schedulerBatch = 100
+)
+
+// fireSchedulePayload is the payload of JobFireSchedule jobs
+type fireSchedulePayload struct {
+	TeamID       uuid.UUID `json:"team_id"`
+	ScheduleID   uuid.UUID `json:"schedule_id"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+}
+
+// Scheduler fires due action schedules. Each pass locks the due schedules,
+// queues a background job per schedule and moves it to its next time, so
+// replicas never fire a schedule twice for the same time; the job creates the
+// runs, with the retries of the job queue.
+type Scheduler struct {
+	service *Service
+	queue   *jobs.Queue
+
+	mu      sync.Mutex
+	lastErr error
+}
+
+// NewScheduler creates the scheduler and registers its job with queue
+func NewScheduler(service *Service, queue *jobs.Queue) *Scheduler {
+	s := &Scheduler{service: service, queue: queue}
+	queue.Register(JobFireSchedule, s.fire)
+	return s
+}
+
+// Run fires due schedules every interval until ctx is done
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.Tick(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("ERROR: action scheduler failed: %v", err)
+			}
+			s.mu.Lock()
+			s.lastErr = err
+			s.mu.Unlock()
+		}
+	}
+}
+
+// LastError returns the error of the last pass, nil once one succeeds
+func (s *Scheduler) LastError() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastErr
+}
+
+// Tick queues a job for every due schedule
+func (s *Scheduler) Tick(ctx context.Context) error {
+	for {
+		n, err := s.service.repo.AdvanceDueSchedules(ctx, schedulerBatch, func(schedule *Schedule) (*time.Time, error) {
+			payload := fireSchedulePayload{TeamID: schedule.TeamID, ScheduleID: schedule.ID, ScheduledFor: *schedule.NextRunAt}
+			if _, err := s.queue.Enqueue(ctx, JobFireSchedule, payload, nil); err != nil {
+				return nil, err
+			}
+			return nextRun(schedule, s.service.now()), nil
+		})
+		if err != nil || n < schedulerBatch {
+			return err
+		}
+	}
+}
+
+func (s *Scheduler) fire(ctx context.Context, job *jobs.Job) error {
+	var payload fireSchedulePayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	return s.service.fireSchedule(ctx, payload.TeamID, payload.ScheduleID, payload.ScheduledFor)
+}
@@ -0,0 +1,513 @@
+package runner
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/secret"
+)
+
+var (
+	ErrRunnerNotFound = errors.New("runner not found")
+	ErrRunnerExists   = errors.New("a runner with this name already exists")
+	ErrInvalidName    = errors.New("runner name must not be empty")
+	ErrInvalidHealth  = errors.New("health must be online or offline")
+	ErrInvalidLabel   = errors.New("runner labels must start with a letter or digit and contain only letters, digits, '.', '_' and '-'")
+	ErrUnauthorized   = errors.New("invalid runner token")
+	ErrActionNotFound = errors.New("action not found")
+	ErrEntityRequired = errors.New("this action runs on an entity; entity_id is required")
+	ErrInvalidEntity  = errors.New("entity not found in the action's blueprint")
+	ErrRunNotFound    = errors.New("run not found")
+	ErrRunFinished    = errors.New("run has already finished")
+	ErrRunCancelled   = errors.New("run was cancelled")
+	ErrLeaseExpired   = errors.New("the lease on this run has expired")
+	ErrInvalidStatus  = errors.New("status must be running, succeeded or failed")
+	ErrTooManyLines   = errors.New("too many log lines in one report")
+)
+
+const (
+	// LeaseDuration is how long a runner holds a run without reporting
+	// before the run is failed
+	LeaseDuration = 2 * time.Minute
+	// OfflineAfter is how long a runner may go without calling the runner
+	// API before it counts as offline. Runners should heartbeat at least
+	// every 30 seconds.
+	OfflineAfter = 90 * time.Second
+	// MaxReportLines bounds the log lines of one report; longer lines are
+	// truncated to MaxLineBytes
+	MaxReportLines = 500
+	MaxLineBytes   = 8 << 10
+
+	maxLabels           = 20
+	tokenPrefix         = "bpr_"
+	leaseExpiredMessage = "the runner stopped reporting before its lease expired"
+)
+
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// AuditRecorder stores audit log entries; auth.Service is one
+type AuditRecorder interface {
+	CreateAuditLog(ctx context.Context, log *auth.AuditLog) error
+}
+
+type Service struct {
+	repo     *Repository
+	secrets  *secret.Service
+	entities EntitySearcher
+	audit    AuditRecorder
+	now      func() time.Time
+}
+
+// NewService creates the runner service. Secret references in an action's
+// trigger config and steps are resolved by secrets when a runner claims a
+// run; entities selects the entities of filtered schedules. audit may be nil.
+func NewService(repo *Repository, secrets *secret.Service, entities EntitySearcher, audit AuditRecorder) *Service {
+	return &Service{repo: repo, secrets: secrets, entities: entities, audit: audit, now: time.Now}
+}
+
+// CreateRunner registers a runner and returns its token, which is not
+// stored and cannot be shown again
+func (s *Service) CreateRunner(ctx context.Context, teamID uuid.UUID, req *CreateRunnerRequest, userID *uuid.UUID, ipAddress, userAgent *string) (*CreateRunnerResponse, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidName
+	}
+	labels, err := normalizeLabels(req.Labels)
+	if err != nil {
+		return nil, err
+	}
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	token := tokenPrefix + hex.EncodeToString(raw)
+
+	runner := &Runner{
+		ID:        uuid.New(),
+		TeamID:    teamID,
+		Name:      name,
+		Labels:    labels,
+		CreatedBy: userID,
+	}
+	if err := s.repo.CreateRunner(ctx, runner, hashToken(token)); err != nil {
+		return nil, err
+	}
+	s.setHealth(runner)
+	s.record(teamID, "runner", runner.ID.String(), "create", map[string]any{"name": runner.Name, "labels": labels}, userID, ipAddress, userAgent)
+	return &CreateRunnerResponse{Runner: runner, Token: token}, nil
+}
+
+func (s *Service) ListRunners(ctx context.Context, teamID uuid.UUID) (*ListRunnersResponse, error) {
+	runners, err := s.repo.ListRunners(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if runners == nil {
+		runners = []*Runner{}
+	}
+	s.setHealth(runners...)
+	return &ListRunnersResponse{Runners: runners, Total: len(runners)}, nil
+}
+
+func (s *Service) GetRunner(ctx context.Context, teamID, id uuid.UUID) (*Runner, error) {
+	runner, err := s.repo.GetRunner(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	if runner == nil {
+		return nil, ErrRunnerNotFound
+	}
+	s.setHealth(runner)
+	return runner, nil
+}
+
+// UpdateRunner renames a runner or replaces its labels. New labels apply
+// to the runner's next claim.
+func (s *Service) UpdateRunner(ctx context.Context, teamID, id uuid.UUID, req *UpdateRunnerRequest, userID *uuid.UUID, ipAddress, userAgent *string) (*Runner, error) {
+	var name *string
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" {
+			return nil, ErrInvalidName
+		}
+		name = &trimmed
+	}
+	var labels []string
+	if req.Labels != nil {
+		var err error
+		if labels, err = normalizeLabels(*req.Labels); err != nil {
+			return nil, err
+		}
+	}
+
+	runner, err := s.repo.UpdateRunner(ctx, teamID, id, name, labels)
+	if err != nil {
+		return nil, err
+	}
+	if runner == nil {
+		return nil, ErrRunnerNotFound
+	}
+	s.setHealth(runner)
+	s.record(teamID, "runner", id.String(), "update", map[string]any{"name": runner.Name, "labels": runner.Labels}, userID, ipAddress, userAgent)
+	return runner, nil
+}
+
+// Heartbeat records the version and host a runner reports and extends the
+// leases of the runs it executes, so long steps that log nothing keep them
+func (s *Service) Heartbeat(ctx context.Context, runner *Runner, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	updated, err := s.repo.Heartbeat(ctx, runner.ID, req, LeaseDuration)
+	if err != nil {
+		return nil, err
+	}
+	if updated == nil {
+		// Deleted since it authenticated
+		return nil, ErrUnauthorized
+	}
+	s.setHealth(updated)
+	return &HeartbeatResponse{Runner: updated, LeaseSeconds: int(LeaseDuration / time.Second)}, nil
+}
+
+// Fleet lists runners across teams for super admins, with how many are
+// online and offline
+func (s *Service) Fleet(ctx context.Context, req *FleetRequest) (*FleetResponse, error) {
+	switch req.Health {
+	case "", HealthOnline, HealthOffline:
+	default:
+		return nil, ErrInvalidHealth
+	}
+	runners, total, online, offline, err := s.repo.Fleet(ctx, req, OfflineAfter)
+	if err != nil {
+		return nil, err
+	}
+	if runners == nil {
+		runners = []*FleetRunner{}
+	}
+	for _, r := range runners {
+		s.setHealth(r.Runner)
+	}
+	return &FleetResponse{Runners: runners, Total: total, Online: online, Offline: offline}, nil
+}
+
+// setHealth marks runners seen within OfflineAfter as online
+func (s *Service) setHealth(runners ...*Runner) {
+	now := s.now()
+	for _, r := range runners {
+		r.Health = HealthOffline
+		if r.LastSeenAt != nil && now.Sub(*r.LastSeenAt) < OfflineAfter {
+			r.Health = HealthOnline
+		}
+	}
+}
+
+// DeleteRunner revokes a runner's token. Runs it holds fail once their
+// lease expires.
+func (s *Service) DeleteRunner(ctx context.Context, teamID, id uuid.UUID, userID *uuid.UUID, ipAddress, userAgent *string) error {
+	found, err := s.repo.DeleteRunner(ctx, teamID, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrRunnerNotFound
+	}
+	s.record(teamID, "runner", id.String(), "delete", nil, userID, ipAddress, userAgent)
+	return nil
+}
+
+// Authenticate returns the runner a token belongs to
+func (s *Service) Authenticate(ctx context.Context, token string) (*Runner, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return nil, ErrUnauthorized
+	}
+	runner, err := s.repo.TouchRunner(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if runner == nil {
+		return nil, ErrUnauthorized
+	}
+	return runner, nil
+}
+
+// Trigger queues a run of a team's action for a runner to pick up. Actions
+// of a blueprint run on one of its entities; team-wide actions may name any
+// entity of the team, or none. The action's run limits may refuse the run
+// with a *RunLimitError.
+func (s *Service) Trigger(ctx context.Context, teamID uuid.UUID, identifier string, req *TriggerRunRequest, userID *uuid.UUID, ipAddress, userAgent *string) (*Run, error) {
+	a, err := s.repo.ActionByIdentifier(ctx, teamID, identifier)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrActionNotFound
+	}
+	if req.EntityID == nil && a.BlueprintID != "" {
+		return nil, ErrEntityRequired
+	}
+	if req.EntityID != nil {
+		blueprintID, found, err := s.repo.EntityBlueprint(ctx, teamID, *req.EntityID)
+		if err != nil {
+			return nil, err
+		}
+		if !found || (a.BlueprintID != "" && blueprintID != a.BlueprintID) {
+			return nil, ErrInvalidEntity
+		}
+	}
+
+	limits, err := ParseRunLimits(a.TriggerConfig)
+	if err != nil {
+		return nil, err
+	}
+	if !limits.IsZero() {
+		// Runs whose runner died must not hold a concurrency slot
+		if err := s.repo.ExpireLeases(ctx, teamID); err != nil {
+			return nil, err
+		}
+	}
+
+	run := &Run{
+		ID:          uuid.New(),
+		TeamID:      teamID,
+		ActionID:    a.ID,
+		Action:      a.Identifier,
+		EntityID:    req.EntityID,
+		Inputs:      req.Inputs,
+		Status:      StatusPending,
+		TriggeredBy: userID,
+	}
+	if run.Inputs == nil {
+		run.Inputs = map[string]interface{}{}
+	}
+	if err := s.repo.CreateRun(ctx, run, limits); err != nil {
+		return nil, err
+	}
+	s.record(teamID, "action_run", run.ID.String(), "trigger", map[string]any{"action": a.Identifier, "entity_id": run.EntityID}, userID, ipAddress, userAgent)
+	return run, nil
+}
+
+func (s *Service) ListRuns(ctx context.Context, teamID uuid.UUID, req *ListRunsRequest) (*ListRunsResponse, error) {
+	if err := s.repo.ExpireLeases(ctx, teamID); err != nil {
+		return nil, err
+	}
+	runs, total, err := s.repo.ListRuns(ctx, teamID, req)
+	if err != nil {
+		return nil, err
+	}
+	if runs == nil {
+		runs = []*Run{}
+	}
+	return &ListRunsResponse{Runs: runs, Total: total}, nil
+}
+
+func (s *Service) GetRun(ctx context.Context, teamID, id uuid.UUID) (*Run, error) {
+	if err := s.repo.ExpireLeases(ctx, teamID); err != nil {
+		return nil, err
+	}
+	run, err := s.repo.GetRun(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrRunNotFound
+	}
+	return run, nil
+}
+
+// Logs returns up to limit log lines of a run after the sequence number after
+func (s *Service) Logs(ctx context.Context, teamID, runID uuid.UUID, after int64, limit int) (*RunLogsResponse, error) {
+	if _, err := s.GetRun(ctx, teamID, runID); err != nil {
+		return nil, err
+	}
+	lines, err := s.repo.Logs(ctx, runID, after, limit)
+	if err != nil {
+		return nil, err
+	}
+	resp := &RunLogsResponse{Lines: lines, Next: after}
+	if lines == nil {
+		resp.Lines = []*LogLine{}
+	}
+	if len(lines) > 0 {
+		resp.Next = lines[len(lines)-1].Seq
+	}
+	return resp, nil
+}
+
+// Cancel cancels a pending or running run. A runner executing it learns of
+// the cancellation from its next report.
+func (s *Service) Cancel(ctx context.Context, teamID, id uuid.UUID, userID *uuid.UUID, ipAddress, userAgent *string) (*Run, error) {
+	run, err := s.repo.CancelRun(ctx, teamID, id)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		if _, err := s.GetRun(ctx, teamID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrRunFinished
+	}
+	s.record(teamID, "action_run", id.String(), "cancel", nil, userID, ipAddress, userAgent)
+	return run, nil
+}
+
+// Claim hands the runner the oldest pending run it qualifies for, with the
+// action's trigger config and steps resolved, or nil when there is none. A
+// run whose secrets cannot be resolved is failed instead.
+func (s *Service) Claim(ctx context.Context, runner *Runner, ipAddress, userAgent *string) (*Job, error) {
+	if err := s.repo.ExpireLeases(ctx, runner.TeamID); err != nil {
+		return nil, err
+	}
+	run, a, err := s.repo.Claim(ctx, runner, LeaseDuration)
+	if err != nil || run == nil {
+		return nil, err
+	}
+
+	consumer := "action_run:" + run.ID.String()
+	triggerConfig, err := s.secrets.Resolve(ctx, runner.TeamID, a.TriggerConfig, consumer, nil, ipAddress, userAgent)
+	if err == nil {
+		var steps interface{}
+		if steps, err = s.secrets.Resolve(ctx, runner.TeamID, a.Steps, consumer, nil, ipAddress, userAgent); err == nil {
+			job := &Job{Run: run, Action: a.Identifier, Blueprint: a.BlueprintID}
+			job.TriggerConfig, _ = triggerConfig.(map[string]interface{})
+			job.Steps, _ = steps.([]interface{})
+			return job, nil
+		}
+	}
+
+	if !errors.Is(err, secret.ErrUnknownSecret) && !errors.Is(err, secret.ErrUnavailable) {
+		return nil, err
+	}
+	failed := &ReportRequest{Status: StatusFailed, Message: err.Error()}
+	if _, err := s.repo.Report(ctx, runner.ID, run.ID, failed, LeaseDuration); err != nil {
+		return nil, err
+	}
+	log.Printf("WARNING: failed action run %s: %v", run.ID, failed.Message)
+	return nil, nil
+}
+
+// Report records a runner's progress on a run it claimed
+func (s *Service) Report(ctx context.Context, runner *Runner, runID uuid.UUID, req *ReportRequest) (*Run, error) {
+	if err := validateReport(req); err != nil {
+		return nil, err
+	}
+	reported, err := s.repo.Report(ctx, runner.ID, runID, req, LeaseDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := s.repo.RunForRunner(ctx, runner.ID, runID)
+	if err != nil {
+		return nil, err
+	}
+	if run == nil {
+		return nil, ErrRunNotFound
+	}
+	if reported {
+		return run, nil
+	}
+	switch run.Status {
+	case StatusCancelled:
+		return nil, ErrRunCancelled
+	case StatusRunning:
+		if err := s.repo.ExpireLeases(ctx, runner.TeamID); err != nil {
+			return nil, err
+		}
+		return nil, ErrLeaseExpired
+	default:
+		return nil, ErrRunFinished
+	}
+}
+
+// validateReport checks a report's status and log lines, truncating lines
+// longer than MaxLineBytes
+func validateReport(req *ReportRequest) error {
+	switch req.Status {
+	case StatusRunning, StatusSucceeded, StatusFailed:
+	default:
+		return ErrInvalidStatus
+	}
+	if len(req.Logs) > MaxReportLines {
+		return fmt.Errorf("%w (max %d)", ErrTooManyLines, MaxReportLines)
+	}
+	for i, line := range req.Logs {
+		req.Logs[i] = truncate(line, MaxLineBytes)
+	}
+	return nil
+}
+
+// truncate cuts s to at most n bytes without splitting a UTF-8 sequence
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// normalizeLabels validates runner labels and returns them sorted and
+// without duplicates
+func normalizeLabels(labels []string) ([]string, error) {
+	seen := make(map[string]bool, len(labels))
+	out := make([]string, 0, len(labels))
+	for _, label := range labels {
+		label = strings.TrimSpace(label)
+		if !labelPattern.MatchString(label) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidLabel, label)
+		}
+		if !seen[label] {
+			seen[label] = true
+			out = append(out, label)
+		}
+	}
+	if len(out) > maxLabels {
+		return nil, fmt.Errorf("%w: at most %d labels", ErrInvalidLabel, maxLabels)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// record adds a runner or run event to the audit log. Tokens are never
+// recorded.
+func (s *Service) record(teamID uuid.UUID, entityType, entityID, action string, data map[string]any, userID *uuid.UUID, ipAddress, userAgent *string) {
+	if s.audit == nil {
+		return
+	}
+	result := "success"
+	entry := &auth.AuditLog{
+		ID:           uuid.New(),
+		TeamID:       &teamID,
+		UserID:       userID,
+		ActorType:    "team_member",
+		EntityType:   entityType,
+		EntityID:     entityID,
+		Action:       action,
+		NewData:      data,
+		IPAddress:    ipAddress,
+		UserAgent:    userAgent,
+		ResultStatus: &result,
+	}
+	// Log asynchronously to not block the response
+	go func() {
+		if err := s.audit.CreateAuditLog(context.Background(), entry); err != nil {
+			log.Printf("ERROR: failed to create audit log for %s of %s %s: %v", entry.Action, entry.EntityType, entry.EntityID, err)
+		}
+	}()
+}
@@ -0,0 +1,121 @@
+package runner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestNormalizeLabels(t *testing.T) {
+	got, err := normalizeLabels([]string{"eu-private", " linux ", "eu-private", "gpu.v2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"eu-private", "gpu.v2", "linux"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeLabels = %v, want %v", got, want)
+	}
+
+	if got, err := normalizeLabels(nil); err != nil || len(got) != 0 || got == nil {
+		t.Errorf("normalizeLabels(nil) = %#v, %v; want empty, non-nil", got, err)
+	}
+
+	for _, bad := range []string{"", "-leading", "has space", "semi;colon", strings.Repeat("a", 64)} {
+		if _, err := normalizeLabels([]string{bad}); !errors.Is(err, ErrInvalidLabel) {
+			t.Errorf("normalizeLabels(%q) error = %v, want ErrInvalidLabel", bad, err)
+		}
+	}
+
+	many := make([]string, maxLabels+1)
+	for i := range many {
+		many[i] = "l" + strings.Repeat("x", i)
+	}
+	if _, err := normalizeLabels(many); !errors.Is(err, ErrInvalidLabel) {
+		t.Errorf("normalizeLabels(%d labels) error = %v, want ErrInvalidLabel", len(many), err)
+	}
+}
+
+func TestValidateReport(t *testing.T) {
+	for _, status := range []string{StatusRunning, StatusSucceeded, StatusFailed} {
+		if err := validateReport(&ReportRequest{Status: status}); err != nil {
+			t.Errorf("validateReport(%q) = %v", status, err)
+		}
+	}
+	for _, status := range []string{"", StatusPending, StatusCancelled, "done"} {
+		if err := validateReport(&ReportRequest{Status: status}); !errors.Is(err, ErrInvalidStatus) {
+			t.Errorf("validateReport(%q) = %v, want ErrInvalidStatus", status, err)
+		}
+	}
+
+	tooMany := &ReportRequest{Status: StatusRunning, Logs: make([]string, MaxReportLines+1)}
+	if err := validateReport(tooMany); !errors.Is(err, ErrTooManyLines) {
+		t.Errorf("validateReport(%d lines) = %v, want ErrTooManyLines", len(tooMany.Logs), err)
+	}
+
+	long := &ReportRequest{Status: StatusRunning, Logs: []string{"ok", strings.Repeat("é", MaxLineBytes)}}
+	if err := validateReport(long); err != nil {
+		t.Fatal(err)
+	}
+	if long.Logs[0] != "ok" {
+		t.Errorf("short line changed to %q", long.Logs[0])
+	}
+	if n := len(long.Logs[1]); n > MaxLineBytes || n < MaxLineBytes-1 {
+		t.Errorf("long line truncated to %d bytes, want about %d", n, MaxLineBytes)
+	}
+	if !utf8.ValidString(long.Logs[1]) {
+		t.Error("truncated line is not valid UTF-8")
+	}
+}
+
+func TestSetHealth(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	s := &Service{now: func() time.Time { return now }}
+	recent := now.Add(-30 * time.Second)
+	stale := now.Add(-OfflineAfter)
+
+	never, seen, gone := &Runner{}, &Runner{LastSeenAt: &recent}, &Runner{LastSeenAt: &stale}
+	s.setHealth(never, seen, gone)
+	for _, tt := range []struct {
+		name   string
+		runner *Runner
+		want   string
+	}{
+		{"never seen", never, HealthOffline},
+		{"seen 30s ago", seen, HealthOnline},
+		{"seen OfflineAfter ago", gone, HealthOffline},
+	} {
+		if tt.runner.Health != tt.want {
+			t.Errorf("%s: health = %q, want %q", tt.name, tt.runner.Health, tt.want)
+		}
+	}
+}
+
+func TestParseRunLimits(t *testing.T) {
+	limits, err := ParseRunLimits(map[string]interface{}{
+		"runner_labels":          []interface{}{"linux"},
+		LimitConcurrent:          float64(3),
+		LimitConcurrentPerEntity: float64(1),
+		LimitCooldown:            float64(300),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := RunLimits{MaxConcurrent: 3, MaxConcurrentPerEntity: 1, Cooldown: 5 * time.Minute}
+	if *limits != want {
+		t.Errorf("ParseRunLimits = %+v, want %+v", *limits, want)
+	}
+
+	none, err := ParseRunLimits(nil)
+	if err != nil || !none.IsZero() {
+		t.Errorf("ParseRunLimits(nil) = %+v, %v", none, err)
+	}
+
+	for _, value := range []interface{}{float64(-1), 1.5, "10", true} {
+		if _, err := ParseRunLimits(map[string]interface{}{LimitCooldown: value}); !errors.Is(err, ErrInvalidRunLimits) {
+			t.Errorf("ParseRunLimits(%v) = %v, want ErrInvalidRunLimits", value, err)
+		}
+	}
+}
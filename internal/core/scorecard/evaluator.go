@@ -0,0 +1,129 @@
+package scorecard
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Evaluate computes the level an entity with the given data achieves
+func (s *Scorecard) Evaluate(data map[string]interface{}) *Result {
+	result := &Result{RulesTotal: len(s.Rules)}
+
+	failedLevels := make(map[string]bool)
+	for _, rule := range s.Rules {
+		if rule.Evaluate(data) {
+			result.RulesPassed++
+			continue
+		}
+		failedLevels[rule.LevelName] = true
+		result.FailedRules = append(result.FailedRules, rule.ID)
+	}
+
+	// Levels are cumulative: reaching a level requires passing every rule of it and of all lower levels
+	for _, level := range s.Levels {
+		if failedLevels[level.Name] {
+			break
+		}
+		result.Level = level.Name
+	}
+	return result
+}
+
+// Evaluate reports whether the rule holds for the given entity data
+func (r *Rule) Evaluate(data map[string]interface{}) bool {
+	value, exists := LookupPath(data, r.PropertyPath)
+
+	switch r.Operator {
+	case "exists":
+		want := true
+		if b, ok := r.Value.(bool); ok {
+			want = b
+		}
+		return exists == want
+	case "eq":
+		return exists && valuesEqual(value, r.Value)
+	case "neq":
+		return !exists || !valuesEqual(value, r.Value)
+	case "gt", "gte", "lt", "lte":
+		left, lok := toFloat(value)
+		right, rok := toFloat(r.Value)
+		if !exists || !lok || !rok {
+			return false
+		}
+		switch r.Operator {
+		case "gt":
+			return left > right
+		case "gte":
+			return left >= right
+		case "lt":
+			return left < right
+		default:
+			return left <= right
+		}
+	case "contains":
+		if !exists {
+			return false
+		}
+		if arr, ok := value.([]interface{}); ok {
+			for _, item := range arr {
+				if valuesEqual(item, r.Value) {
+					return true
+				}
+			}
+			return false
+		}
+		return strings.Contains(strings.ToLower(fmt.Sprint(value)), strings.ToLower(fmt.Sprint(r.Value)))
+	case "in":
+		arr, ok := r.Value.([]interface{})
+		if !exists || !ok {
+			return false
+		}
+		for _, item := range arr {
+			if valuesEqual(value, item) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// LookupPath resolves a dotted property path (e.g. "metadata.version") in entity data
+func LookupPath(data map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func valuesEqual(a, b interface{}) bool {
+	af, aok := toFloat(a)
+	bf, bok := toFloat(b)
+	if aok && bok {
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,79 @@
+package scorecard
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newTestScorecard() *Scorecard {
+	return &Scorecard{
+		Levels: []Level{{Name: "bronze"}, {Name: "silver"}, {Name: "gold"}},
+		Rules: []*Rule{
+			{ID: uuid.New(), LevelName: "bronze", PropertyPath: "owner", Operator: "exists", Value: true},
+			{ID: uuid.New(), LevelName: "silver", PropertyPath: "coverage", Operator: "gte", Value: float64(80)},
+			{ID: uuid.New(), LevelName: "gold", PropertyPath: "metadata.tier", Operator: "in", Value: []interface{}{"1", "2"}},
+		},
+	}
+}
+
+func TestScorecard_Evaluate(t *testing.T) {
+	sc := newTestScorecard()
+
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		level  string
+		passed int
+	}{
+		{"no rules pass", map[string]interface{}{}, "", 0},
+		{"bronze only", map[string]interface{}{"owner": "team-a", "coverage": float64(50)}, "bronze", 1},
+		{"silver", map[string]interface{}{"owner": "team-a", "coverage": float64(85)}, "silver", 2},
+		{"gold", map[string]interface{}{"owner": "team-a", "coverage": float64(90), "metadata": map[string]interface{}{"tier": "1"}}, "gold", 3},
+		{"higher rule passes but lower fails", map[string]interface{}{"coverage": float64(90), "metadata": map[string]interface{}{"tier": "1"}}, "", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := sc.Evaluate(tt.data)
+			if result.Level != tt.level {
+				t.Errorf("expected level %q, got %q", tt.level, result.Level)
+			}
+			if result.RulesPassed != tt.passed {
+				t.Errorf("expected %d rules passed, got %d", tt.passed, result.RulesPassed)
+			}
+			if result.RulesTotal != 3 {
+				t.Errorf("expected 3 rules total, got %d", result.RulesTotal)
+			}
+		})
+	}
+}
+
+func TestRule_Operators(t *testing.T) {
+	data := map[string]interface{}{
+		"language": "Go",
+		"replicas": float64(3),
+		"tags":     []interface{}{"critical", "backend"},
+	}
+
+	tests := []struct {
+		rule     Rule
+		expected bool
+	}{
+		{Rule{PropertyPath: "language", Operator: "eq", Value: "Go"}, true},
+		{Rule{PropertyPath: "language", Operator: "neq", Value: "Go"}, false},
+		{Rule{PropertyPath: "missing", Operator: "neq", Value: "Go"}, true},
+		{Rule{PropertyPath: "replicas", Operator: "gt", Value: float64(2)}, true},
+		{Rule{PropertyPath: "replicas", Operator: "lt", Value: float64(3)}, false},
+		{Rule{PropertyPath: "language", Operator: "contains", Value: "go"}, true},
+		{Rule{PropertyPath: "tags", Operator: "contains", Value: "critical"}, true},
+		{Rule{PropertyPath: "missing", Operator: "exists", Value: false}, true},
+		{Rule{PropertyPath: "language", Operator: "unknown", Value: "Go"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.Evaluate(data); got != tt.expected {
+			t.Errorf("%s %s %v: expected %v, got %v", tt.rule.PropertyPath, tt.rule.Operator, tt.rule.Value, tt.expected, got)
+		}
+	}
+}
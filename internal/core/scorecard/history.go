@@ -0,0 +1,156 @@
+package scorecard
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrNotFound = errors.New("scorecard not found")
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 500
+)
+
+// ResultRecord is a recorded evaluation of a scorecard against one entity.
+// Results are recorded when the entity's level or passed rules change, so
+// consecutive records of an entity are its transitions.
+type ResultRecord struct {
+	EntityID uuid.UUID `json:"entity_id"`
+	Level    string    `json:"level"`
+	// PreviousLevel is the level of the entity's record before, nil for its first
+	PreviousLevel *string   `json:"previous_level"`
+	RulesPassed   int       `json:"rules_passed"`
+	RulesTotal    int       `json:"rules_total"`
+	EvaluatedAt   time.Time `json:"evaluated_at"`
+}
+
+// HistoryRequest selects recorded results, newest first
+type HistoryRequest struct {
+	EntityID *uuid.UUID
+	Since    *time.Time
+	Limit    int
+	Offset   int
+}
+
+type HistoryResponse struct {
+	ScorecardID uuid.UUID       `json:"scorecard_id"`
+	EntityID    *uuid.UUID      `json:"entity_id,omitempty"`
+	Results     []*ResultRecord `json:"results"`
+	Total       int             `json:"total"`
+	Limit       int             `json:"limit"`
+	Offset      int             `json:"offset"`
+}
+
+// Service records scorecard results over time and serves their history
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// List returns a team's scorecards with their rules
+func (s *Service) List(ctx context.Context, teamID uuid.UUID) ([]*Scorecard, error) {
+	scorecards, err := s.repo.ListByTeam(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if scorecards == nil {
+		scorecards = []*Scorecard{}
+	}
+	return scorecards, nil
+}
+
+// History returns the recorded results of a team's scorecard, or of one
+// entity with req.EntityID
+func (s *Service) History(ctx context.Context, teamID, scorecardID uuid.UUID, req *HistoryRequest) (*HistoryResponse, error) {
+	exists, err := s.repo.Exists(ctx, teamID, scorecardID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+	if req.Limit <= 0 || req.Limit > maxHistoryLimit {
+		req.Limit = defaultHistoryLimit
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	results, total, err := s.repo.History(ctx, scorecardID, req)
+	if err != nil {
+		return nil, err
+	}
+	if results == nil {
+		results = []*ResultRecord{}
+	}
+	return &HistoryResponse{
+		ScorecardID: scorecardID,
+		EntityID:    req.EntityID,
+		Results:     results,
+		Total:       total,
+		Limit:       req.Limit,
+		Offset:      req.Offset,
+	}, nil
+}
+
+// Snapshot evaluates every scorecard against the entities of its blueprint
+// and records the results that changed since the last snapshot. It returns
+// the number of results recorded.
+func (s *Service) Snapshot(ctx context.Context) (int, error) {
+	scorecards, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+	recorded := 0
+	for _, sc := range scorecards {
+		n, err := s.snapshot(ctx, sc)
+		if err != nil {
+			return recorded, err
+		}
+		recorded += n
+	}
+	return recorded, nil
+}
+
+func (s *Service) snapshot(ctx context.Context, sc *Scorecard) (int, error) {
+	latest, err := s.repo.LatestResults(ctx, sc.ID)
+	if err != nil {
+		return 0, err
+	}
+	var changed []*ResultRecord
+	err = s.repo.EachEntity(ctx, sc.TeamID, sc.BlueprintID, func(id uuid.UUID, data map[string]interface{}) {
+		if record := changedResult(latest[id], id, sc.Evaluate(data)); record != nil {
+			changed = append(changed, record)
+		}
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(changed), s.repo.RecordResults(ctx, sc.ID, changed)
+}
+
+// changedResult returns the record of an entity's result, or nil when it
+// matches the entity's last record
+func changedResult(last *ResultRecord, entityID uuid.UUID, result *Result) *ResultRecord {
+	if last != nil && last.Level == result.Level && last.RulesPassed == result.RulesPassed && last.RulesTotal == result.RulesTotal {
+		return nil
+	}
+	record := &ResultRecord{
+		EntityID:    entityID,
+		Level:       result.Level,
+		RulesPassed: result.RulesPassed,
+		RulesTotal:  result.RulesTotal,
+	}
+	if last != nil {
+		previous := last.Level
+		record.PreviousLevel = &previous
+	}
+	return record
+}
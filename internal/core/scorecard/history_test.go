@@ -0,0 +1,34 @@
+package scorecard
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestChangedResult(t *testing.T) {
+	id := uuid.New()
+	last := &ResultRecord{EntityID: id, Level: "bronze", RulesPassed: 1, RulesTotal: 3}
+
+	if got := changedResult(nil, id, &Result{Level: "bronze", RulesPassed: 1, RulesTotal: 3}); got == nil || got.PreviousLevel != nil {
+		t.Errorf("first result = %+v, want a record without a previous level", got)
+	}
+	if got := changedResult(last, id, &Result{Level: "bronze", RulesPassed: 1, RulesTotal: 3}); got != nil {
+		t.Errorf("unchanged result = %+v, want nil", got)
+	}
+
+	got := changedResult(last, id, &Result{Level: "silver", RulesPassed: 2, RulesTotal: 3})
+	if got == nil || got.Level != "silver" || got.PreviousLevel == nil || *got.PreviousLevel != "bronze" {
+		t.Errorf("level transition = %+v, want silver after bronze", got)
+	}
+
+	// a rule passing without changing the level is still recorded
+	got = changedResult(last, id, &Result{Level: "bronze", RulesPassed: 2, RulesTotal: 3})
+	if got == nil || got.RulesPassed != 2 || *got.PreviousLevel != "bronze" {
+		t.Errorf("rules change = %+v, want a record", got)
+	}
+	got = changedResult(last, id, &Result{Level: "bronze", RulesPassed: 1, RulesTotal: 4})
+	if got == nil {
+		t.Error("a new rule should be recorded")
+	}
+}
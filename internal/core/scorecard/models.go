@@ -0,0 +1,41 @@
+package scorecard
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Scorecard struct {
+	ID          uuid.UUID `json:"id"`
+	TeamID      uuid.UUID `json:"team_id"`
+	BlueprintID string    `json:"blueprint_id"`
+	Identifier  string    `json:"identifier"`
+	Title       string    `json:"title"`
+	Levels      []Level   `json:"levels"` // ordered from lowest to highest
+	Rules       []*Rule   `json:"rules"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type Level struct {
+	Name  string `json:"name"`
+	Color string `json:"color,omitempty"`
+}
+
+type Rule struct {
+	ID           uuid.UUID   `json:"id"`
+	ScorecardID  uuid.UUID   `json:"scorecard_id"`
+	LevelName    string      `json:"level_name"`
+	PropertyPath string      `json:"property_path"` // dotted path into entity data, e.g. "metadata.tier"
+	Operator     string      `json:"operator"`      // eq, neq, gt, gte, lt, lte, contains, exists, in
+	Value        interface{} `json:"value,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// Result is the outcome of evaluating a scorecard against one entity
+type Result struct {
+	Level       string      `json:"level,omitempty"` // highest level whose rules (and all lower levels' rules) pass
+	RulesPassed int         `json:"rules_passed"`
+	RulesTotal  int         `json:"rules_total"`
+	FailedRules []uuid.UUID `json:"failed_rules,omitempty"`
+}
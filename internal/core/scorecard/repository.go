@@ -0,0 +1,285 @@
+package scorecard
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+// ListAll returns every scorecard across all teams with its rules loaded
+func (r *Repository) ListAll(ctx context.Context) ([]*Scorecard, error) {
+	query := `
+		SELECT id, team_id, blueprint_id, identifier, title, levels, created_at
+		FROM scorecards
+		ORDER BY team_id, blueprint_id, identifier`
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scorecards, err := r.scanScorecards(rows)
+	if err != nil {
+		return nil, err
+	}
+	return scorecards, r.loadRules(ctx, scorecards)
+}
+
+// ListByTeam returns a team's scorecards with their rules loaded
+func (r *Repository) ListByTeam(ctx context.Context, teamID uuid.UUID) ([]*Scorecard, error) {
+	query := `
+		SELECT id, team_id, blueprint_id, identifier, title, levels, created_at
+		FROM scorecards
+		WHERE team_id = $1
+		ORDER BY blueprint_id, identifier`
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scorecards, err := r.scanScorecards(rows)
+	if err != nil {
+		return nil, err
+	}
+	return scorecards, r.loadRules(ctx, scorecards)
+}
+
+func (r *Repository) scanScorecards(rows *sql.Rows) ([]*Scorecard, error) {
+	var scorecards []*Scorecard
+	for rows.Next() {
+		sc := &Scorecard{}
+		var levels []byte
+		if err := rows.Scan(&sc.ID, &sc.TeamID, &sc.BlueprintID, &sc.Identifier, &sc.Title, &levels, &sc.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(levels, &sc.Levels); err != nil {
+			return nil, err
+		}
+		scorecards = append(scorecards, sc)
+	}
+	return scorecards, rows.Err()
+}
+
+// loadRules fetches the rules of all given scorecards in a single query
+func (r *Repository) loadRules(ctx context.Context, scorecards []*Scorecard) error {
+	if len(scorecards) == 0 {
+		return nil
+	}
+
+	byID := make(map[uuid.UUID]*Scorecard, len(scorecards))
+	ids := make([]string, 0, len(scorecards))
+	for _, sc := range scorecards {
+		byID[sc.ID] = sc
+		ids = append(ids, sc.ID.String())
+	}
+
+	query := `
+		SELECT id, scorecard_id, level_name, property_path, operator, value, created_at
+		FROM scorecard_rules
+		WHERE scorecard_id = ANY($1::uuid[])
+		ORDER BY created_at`
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, ids)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		rule := &Rule{}
+		var value []byte
+		if err := rows.Scan(&rule.ID, &rule.ScorecardID, &rule.LevelName, &rule.PropertyPath, &rule.Operator, &value, &rule.CreatedAt); err != nil {
+			return err
+		}
+		if len(value) > 0 {
+			if err := json.Unmarshal(value, &rule.Value); err != nil {
+				return err
+			}
+		}
+		if sc, ok := byID[rule.ScorecardID]; ok {
+			sc.Rules = append(sc.Rules, rule)
+		}
+	}
+	return rows.Err()
+}
+
+// Exists reports whether a team has the scorecard
+func (r *Repository) Exists(ctx context.Context, teamID, id uuid.UUID) (bool, error) {
+	var exists bool
+	err := r.db.Reader(ctx).QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM scorecards WHERE id = $1 AND team_id = $2)`, id, teamID).Scan(&exists)
+	return exists, err
+}
+
+// LatestResults returns the last recorded result of each entity, by entity
+func (r *Repository) LatestResults(ctx context.Context, scorecardID uuid.UUID) (map[uuid.UUID]*ResultRecord, error) {
+	query := `
+		SELECT DISTINCT ON (entity_id) entity_id, level, previous_level, rules_passed, rules_total, evaluated_at
+		FROM scorecard_results
+		WHERE scorecard_id = $1
+		ORDER BY entity_id, evaluated_at DESC, id DESC`
+	rows, err := r.db.DB.QueryContext(ctx, query, scorecardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[uuid.UUID]*ResultRecord)
+	for rows.Next() {
+		record, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		latest[record.EntityID] = record
+	}
+	return latest, rows.Err()
+}
+
+// EachEntity calls fn with the ID and data of every entity of a blueprint
+func (r *Repository) EachEntity(ctx context.Context, teamID uuid.UUID, blueprintID string, fn func(id uuid.UUID, data map[string]interface{})) error {
+	rows, err := r.db.Reader(ctx).QueryContext(ctx,
+		`SELECT id, data FROM entities WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uuid.UUID
+		var raw []byte
+		if err := rows.Scan(&id, &raw); err != nil {
+			return err
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+		fn(id, data)
+	}
+	return rows.Err()
+}
+
+// RecordResults stores results of a scorecard in one statement
+func (r *Repository) RecordResults(ctx context.Context, scorecardID uuid.UUID, records []*ResultRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	entityIDs := make([]string, len(records))
+	levels := make([]string, len(records))
+	previous := make([]*string, len(records))
+	passed := make([]int64, len(records))
+	total := make([]int64, len(records))
+	for i, record := range records {
+		entityIDs[i] = record.EntityID.String()
+		levels[i] = record.Level
+		previous[i] = record.PreviousLevel
+		passed[i] = int64(record.RulesPassed)
+		total[i] = int64(record.RulesTotal)
+	}
+	_, err := r.db.DB.ExecContext(ctx, `
+		INSERT INTO scorecard_results (scorecard_id, entity_id, level, previous_level, rules_passed, rules_total)
+		SELECT $1, * FROM unnest($2::uuid[], $3::text[], $4::text[], $5::int[], $6::int[])`,
+		scorecardID, entityIDs, levels, previous, passed, total)
+	return err
+}
+
+// History returns the recorded results of a scorecard matching req, newest
+// first, and how many match in total
+func (r *Repository) History(ctx context.Context, scorecardID uuid.UUID, req *HistoryRequest) ([]*ResultRecord, int, error) {
+	where := `scorecard_id = $1 AND ($2::uuid IS NULL OR entity_id = $2) AND ($3::timestamptz IS NULL OR evaluated_at >= $3)`
+	args := []interface{}{scorecardID, req.EntityID, req.Since}
+
+	var total int
+	if err := r.db.Reader(ctx).QueryRowContext(ctx, `SELECT COUNT(*) FROM scorecard_results WHERE `+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, `
+		SELECT entity_id, level, previous_level, rules_passed, rules_total, evaluated_at
+		FROM scorecard_results
+		WHERE `+where+`
+		ORDER BY evaluated_at DESC, id DESC
+		LIMIT $4 OFFSET $5`, append(args, req.Limit, req.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var records []*ResultRecord
+	for rows.Next() {
+		record, err := scanResult(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		records = append(records, record)
+	}
+	return records, total, rows.Err()
+}
+
+// LevelsAt counts the entities by their last recorded level at the given
+// time. Entities deleted since are left out. It returns nil when the
+// scorecard had no results by then.
+func (r *Repository) LevelsAt(ctx context.Context, scorecardID uuid.UUID, at time.Time) (map[string]int, error) {
+	query := `
+		SELECT level, COUNT(*)
+		FROM (
+			SELECT DISTINCT ON (entity_id) entity_id, level
+			FROM scorecard_results
+			WHERE scorecard_id = $1 AND evaluated_at <= $2
+			ORDER BY entity_id, evaluated_at DESC, id DESC
+		) r
+		WHERE EXISTS (SELECT 1 FROM entities e WHERE e.id = r.entity_id)
+		GROUP BY level`
+	var recorded bool
+	err := r.db.Reader(ctx).QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM scorecard_results WHERE scorecard_id = $1 AND evaluated_at <= $2)`,
+		scorecardID, at).Scan(&recorded)
+	if err != nil || !recorded {
+		return nil, err
+	}
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, scorecardID, at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	levels := make(map[string]int)
+	for rows.Next() {
+		var level string
+		var count int
+		if err := rows.Scan(&level, &count); err != nil {
+			return nil, err
+		}
+		levels[level] = count
+	}
+	return levels, rows.Err()
+}
+
+func scanResult(rows *sql.Rows) (*ResultRecord, error) {
+	record := &ResultRecord{}
+	var previous sql.NullString
+	if err := rows.Scan(&record.EntityID, &record.Level, &previous, &record.RulesPassed, &record.RulesTotal, &record.EvaluatedAt); err != nil {
+		return nil, err
+	}
+	if previous.Valid {
+		record.PreviousLevel = &previous.String
+	}
+	return record, nil
+}
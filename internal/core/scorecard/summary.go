@@ -0,0 +1,146 @@
+package scorecard
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// summaryPeriod is how far back summary deltas compare
+const summaryPeriod = 7 * 24 * time.Hour
+
+// TeamSummary is the scorecard dashboard of a team
+type TeamSummary struct {
+	TeamID uuid.UUID `json:"team_id"`
+	// Since is the time deltas compare with
+	Since      time.Time           `json:"since"`
+	Scorecards []*ScorecardSummary `json:"scorecards"`
+}
+
+// ScorecardSummary is where the entities of a scorecard stand now
+type ScorecardSummary struct {
+	ScorecardID uuid.UUID `json:"scorecard_id"`
+	BlueprintID string    `json:"blueprint_id"`
+	Identifier  string    `json:"identifier"`
+	Title       string    `json:"title"`
+	Entities    int       `json:"entities"`
+	// Levels holds "" for entities without a level, then the scorecard's
+	// levels from lowest to highest
+	Levels []LevelCount `json:"levels"`
+	// FailingRules are the rules failed by at least one entity, most failed first
+	FailingRules []FailingRule `json:"failing_rules"`
+}
+
+type LevelCount struct {
+	Level    string `json:"level"`
+	Entities int    `json:"entities"`
+	// Delta is the change since the summary's Since, nil when no results
+	// were recorded by then
+	Delta *int `json:"delta"`
+}
+
+type FailingRule struct {
+	RuleID       uuid.UUID   `json:"rule_id"`
+	LevelName    string      `json:"level_name"`
+	PropertyPath string      `json:"property_path"`
+	Operator     string      `json:"operator"`
+	Value        interface{} `json:"value,omitempty"`
+	Entities     int         `json:"entities"`
+}
+
+// Summary evaluates each scorecard of a team against its entities, and
+// compares the levels with the results recorded a week earlier
+func (s *Service) Summary(ctx context.Context, teamID uuid.UUID) (*TeamSummary, error) {
+	scorecards, err := s.repo.ListByTeam(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	summary := &TeamSummary{
+		TeamID:     teamID,
+		Since:      time.Now().UTC().Add(-summaryPeriod).Truncate(time.Second),
+		Scorecards: make([]*ScorecardSummary, 0, len(scorecards)),
+	}
+	for _, sc := range scorecards {
+		t := newTally(sc)
+		err := s.repo.EachEntity(ctx, sc.TeamID, sc.BlueprintID, func(_ uuid.UUID, data map[string]interface{}) {
+			t.add(sc.Evaluate(data))
+		})
+		if err != nil {
+			return nil, err
+		}
+		baseline, err := s.repo.LevelsAt(ctx, sc.ID, summary.Since)
+		if err != nil {
+			return nil, err
+		}
+		summary.Scorecards = append(summary.Scorecards, t.summary(baseline))
+	}
+	return summary, nil
+}
+
+// tally counts the results of a scorecard's entities
+type tally struct {
+	scorecard *Scorecard
+	entities  int
+	levels    map[string]int
+	failed    map[uuid.UUID]int
+}
+
+func newTally(sc *Scorecard) *tally {
+	return &tally{scorecard: sc, levels: map[string]int{}, failed: map[uuid.UUID]int{}}
+}
+
+func (t *tally) add(result *Result) {
+	t.entities++
+	t.levels[result.Level]++
+	for _, id := range result.FailedRules {
+		t.failed[id]++
+	}
+}
+
+// summary builds the scorecard's summary; baseline holds the entities by
+// level at the summary's Since, nil when no results were recorded by then
+func (t *tally) summary(baseline map[string]int) *ScorecardSummary {
+	sc := t.scorecard
+	summary := &ScorecardSummary{
+		ScorecardID:  sc.ID,
+		BlueprintID:  sc.BlueprintID,
+		Identifier:   sc.Identifier,
+		Title:        sc.Title,
+		Entities:     t.entities,
+		Levels:       make([]LevelCount, 0, len(sc.Levels)+1),
+		FailingRules: []FailingRule{},
+	}
+
+	names := []string{""}
+	for _, level := range sc.Levels {
+		names = append(names, level.Name)
+	}
+	for _, name := range names {
+		count := LevelCount{Level: name, Entities: t.levels[name]}
+		if baseline != nil {
+			delta := count.Entities - baseline[name]
+			count.Delta = &delta
+		}
+		summary.Levels = append(summary.Levels, count)
+	}
+
+	for _, rule := range sc.Rules {
+		if n := t.failed[rule.ID]; n > 0 {
+			summary.FailingRules = append(summary.FailingRules, FailingRule{
+				RuleID:       rule.ID,
+				LevelName:    rule.LevelName,
+				PropertyPath: rule.PropertyPath,
+				Operator:     rule.Operator,
+				Value:        rule.Value,
+				Entities:     n,
+			})
+		}
+	}
+	// rules keep their order among equal counts
+	sort.SliceStable(summary.FailingRules, func(i, j int) bool {
+		return summary.FailingRules[i].Entities > summary.FailingRules[j].Entities
+	})
+	return summary
+}
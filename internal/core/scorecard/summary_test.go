@@ -0,0 +1,65 @@
+package scorecard
+
+import "testing"
+
+func TestTally_Summary(t *testing.T) {
+	sc := newTestScorecard()
+	tally := newTally(sc)
+	for _, data := range []map[string]interface{}{
+		{},
+		{"owner": "team-a"},
+		{"owner": "team-a", "coverage": float64(90)},
+		{"owner": "team-b", "coverage": float64(95), "metadata": map[string]interface{}{"tier": "1"}},
+	} {
+		tally.add(sc.Evaluate(data))
+	}
+
+	got := tally.summary(map[string]int{"": 2, "bronze": 1, "silver": 1})
+	if got.Entities != 4 {
+		t.Errorf("entities = %d, want 4", got.Entities)
+	}
+	wantLevels := []struct {
+		level    string
+		entities int
+		delta    int
+	}{{"", 1, -1}, {"bronze", 1, 0}, {"silver", 1, 0}, {"gold", 1, 1}}
+	if len(got.Levels) != len(wantLevels) {
+		t.Fatalf("levels = %+v", got.Levels)
+	}
+	for i, want := range wantLevels {
+		level := got.Levels[i]
+		if level.Level != want.level || level.Entities != want.entities || level.Delta == nil || *level.Delta != want.delta {
+			t.Errorf("levels[%d] = %+v, want %+v", i, level, want)
+		}
+	}
+
+	// the gold rule fails 3 entities, silver 2 and bronze 1
+	if len(got.FailingRules) != 3 {
+		t.Fatalf("failing rules = %+v", got.FailingRules)
+	}
+	for i, want := range []struct {
+		level    string
+		entities int
+	}{{"gold", 3}, {"silver", 2}, {"bronze", 1}} {
+		rule := got.FailingRules[i]
+		if rule.LevelName != want.level || rule.Entities != want.entities {
+			t.Errorf("failing_rules[%d] = %+v, want %s failed by %d", i, rule, want.level, want.entities)
+		}
+	}
+
+	for _, level := range tally.summary(nil).Levels {
+		if level.Delta != nil {
+			t.Errorf("level %q has a delta without a baseline", level.Level)
+		}
+	}
+}
+
+func TestTally_SummaryWithoutEntities(t *testing.T) {
+	got := newTally(newTestScorecard()).summary(nil)
+	if got.FailingRules == nil || len(got.FailingRules) != 0 {
+		t.Errorf("failing rules = %v, want an empty list", got.FailingRules)
+	}
+	if len(got.Levels) != 4 || got.Levels[0].Entities != 0 {
+		t.Errorf("levels = %+v, want every level at 0", got.Levels)
+	}
+}
@@ -0,0 +1,50 @@
+package search
+
+import "github.com/google/uuid"
+
+// Result types
+const (
+	TypeTeam   = "team"
+	TypeUser   = "user"
+	TypeEntity = "entity"
+)
+
+// Types lists the result types in the order results of equal rank are listed
+var Types = []string{TypeTeam, TypeUser, TypeEntity}
+
+// Result is one match of an admin search. Title is the team's name, the
+// user's name or email, or the entity's title or identifier; the other
+// fields are set for their type only.
+type Result struct {
+	Type  string    `json:"type"`
+	ID    uuid.UUID `json:"id"`
+	Title string    `json:"title"`
+	// Teams
+	Slug string `json:"slug,omitempty"`
+	// Users
+	Email  string `json:"email,omitempty"`
+	Status string `json:"status,omitempty"`
+	// Entities
+	TeamID      *uuid.UUID `json:"team_id,omitempty"`
+	TeamName    string     `json:"team_name,omitempty"`
+	BlueprintID string     `json:"blueprint_id,omitempty"`
+	Identifier  string     `json:"identifier,omitempty"`
+}
+
+// Query is an admin search. Types limits the results to those types; empty
+// searches all of them.
+type Query struct {
+	Text   string
+	Types  []string
+	Limit  int
+	Offset int
+}
+
+// Results is a page of search results, best matches first
+type Results struct {
+	Query   string    `json:"query"`
+	Results []*Result `json:"results"`
+	Limit   int       `json:"limit"`
+	Offset  int       `json:"offset"`
+	HasMore bool      `json:"has_more"`
+}
@@ -0,0 +1,99 @@
+package search
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+// rank orders the matches of a column: 0 for the whole value, 1 for a prefix
+// and 2 for any other substring. $1 is the lowercased text and $2 the prefix
+// pattern.
+func rank(column string) string {
+	return fmt.Sprintf("CASE WHEN lower(%[1]s) = $1 THEN 0 WHEN %[1]s ILIKE $2 THEN 1 ELSE 2 END", column)
+}
+
+// searchQueries select the matches of each type; $3 is the substring
+// pattern. Each names and types its columns alike, so that any of them may
+// come first when they are combined.
+var searchQueries = map[string]string{
+	TypeTeam: `
+		SELECT 'team' AS type, 0 AS type_order, t.id, t.name::text AS title, t.slug::text AS slug,
+			NULL::text AS email, NULL::text AS status, NULL::uuid AS team_id, NULL::text AS team_name,
+			NULL::text AS blueprint_id, NULL::text AS identifier,
+			LEAST(` + rank("t.name") + `, ` + rank("t.slug") + `) AS rank
+		FROM teams t
+		WHERE t.name ILIKE $3 OR t.slug ILIKE $3`,
+	// Deleted users are kept anonymized and are left out
+	TypeUser: `
+		SELECT 'user' AS type, 1 AS type_order, u.id, COALESCE(NULLIF(u.name, ''), u.email) AS title, NULL::text AS slug,
+			u.email::text AS email, COALESCE(u.status, 'active')::text AS status, NULL::uuid AS team_id, NULL::text AS team_name,
+			NULL::text AS blueprint_id, NULL::text AS identifier,
+			LEAST(` + rank("u.email") + `, ` + rank("u.name") + `) AS rank
+		FROM users u
+		WHERE u.status IS DISTINCT FROM 'deleted' AND (u.email ILIKE $3 OR u.name ILIKE $3)`,
+	TypeEntity: `
+		SELECT 'entity' AS type, 2 AS type_order, e.id, COALESCE(NULLIF(e.title, ''), e.identifier) AS title, NULL::text AS slug,
+			NULL::text AS email, NULL::text AS status, e.team_id, t.name::text AS team_name,
+			e.blueprint_id::text AS blueprint_id, e.identifier::text AS identifier,
+			LEAST(` + rank("e.identifier") + `, ` + rank("e.title") + `) AS rank
+		FROM entities e
+		JOIN teams t ON t.id = e.team_id
+		WHERE e.identifier ILIKE $3 OR e.title ILIKE $3`,
+}
+
+// Search returns up to limit matches of text among the types, skipping
+// offset, whole matches first, then prefixes, then other substrings
+func (r *Repository) Search(ctx context.Context, text string, types []string, limit, offset int) ([]*Result, error) {
+	parts := make([]string, 0, len(types))
+	for _, t := range Types {
+		for _, wanted := range types {
+			if t == wanted {
+				parts = append(parts, searchQueries[t])
+			}
+		}
+	}
+	query := `
+		SELECT type, id, title, slug, email, status, team_id, team_name, blueprint_id, identifier
+		FROM (` + strings.Join(parts, "\n\t\tUNION ALL") + `
+		) matches
+		ORDER BY rank, type_order, lower(title), id
+		LIMIT $4 OFFSET $5`
+
+	escaped := postgres.EscapeLike(strings.ToLower(text))
+	rows, err := r.db.DB.QueryContext(ctx, query, strings.ToLower(text), escaped+"%", "%"+escaped+"%", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*Result{}
+	for rows.Next() {
+		res := &Result{}
+		var slug, email, status, teamName, blueprintID, identifier sql.NullString
+		var teamID uuid.NullUUID
+		if err := rows.Scan(&res.Type, &res.ID, &res.Title, &slug, &email, &status, &teamID, &teamName, &blueprintID, &identifier); err != nil {
+			return nil, err
+		}
+		res.Slug, res.Email, res.Status = slug.String, email.String, status.String
+		res.TeamName, res.BlueprintID, res.Identifier = teamName.String, blueprintID.String, identifier.String
+		if teamID.Valid {
+			res.TeamID = &teamID.UUID
+		}
+		results = append(results, res)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,66 @@
+// Package search finds teams, users and entities across every team, for
+// super admins.
+package search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrInvalidQuery = errors.New("invalid search query")
+
+const (
+	// MinQueryLength is the shortest text searched for, in characters
+	MinQueryLength = 2
+	// MaxQueryLength bounds the text searched for
+	MaxQueryLength = 100
+)
+
+type Service struct {
+	repo *Repository
+}
+
+func NewService(repo *Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Search returns a page of the teams, users and entities matching q.Text:
+// team names and slugs, user emails and names, and entity identifiers and
+// titles containing it, regardless of case
+func (s *Service) Search(ctx context.Context, q *Query) (*Results, error) {
+	if err := q.normalize(); err != nil {
+		return nil, err
+	}
+	// One more than asked for tells whether there is another page
+	results, err := s.repo.Search(ctx, q.Text, q.Types, q.Limit+1, q.Offset)
+	if err != nil {
+		return nil, err
+	}
+	page := &Results{Query: q.Text, Results: results, Limit: q.Limit, Offset: q.Offset}
+	if len(results) > q.Limit {
+		page.Results, page.HasMore = results[:q.Limit], true
+	}
+	return page, nil
+}
+
+// normalize trims the text, checks its length and the types, and defaults
+// the types to all of them
+func (q *Query) normalize() error {
+	q.Text = strings.TrimSpace(q.Text)
+	if n := utf8.RuneCountInString(q.Text); n < MinQueryLength || n > MaxQueryLength {
+		return fmt.Errorf("%w: q must be %d to %d characters", ErrInvalidQuery, MinQueryLength, MaxQueryLength)
+	}
+	for _, t := range q.Types {
+		if !slices.Contains(Types, t) {
+			return fmt.Errorf("%w: unknown type %q, expected one of %s", ErrInvalidQuery, t, strings.Join(Types, ", "))
+		}
+	}
+	if len(q.Types) == 0 {
+		q.Types = Types
+	}
+	return nil
+}
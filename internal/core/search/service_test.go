@@ -0,0 +1,43 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQuery_Normalize(t *testing.T) {
+	tests := []struct {
+		name      string
+		query     Query
+		wantText  string
+		wantTypes []string
+		wantErr   bool
+	}{
+		{"all types", Query{Text: " payments "}, "payments", Types, false},
+		{"some types", Query{Text: "pay", Types: []string{TypeEntity, TypeTeam}}, "pay", []string{TypeEntity, TypeTeam}, false},
+		{"two characters", Query{Text: "ab"}, "ab", Types, false},
+		{"too short", Query{Text: " a "}, "", nil, true},
+		{"too long", Query{Text: strings.Repeat("a", MaxQueryLength+1)}, "", nil, true},
+		{"unknown type", Query{Text: "pay", Types: []string{"blueprint"}}, "", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := tt.query
+			err := q.normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("error = %v, want ErrInvalidQuery", err)
+				}
+				return
+			}
+			if q.Text != tt.wantText || !reflect.DeepEqual(q.Types, tt.wantTypes) {
+				t.Errorf("normalize() = %q %v, want %q %v", q.Text, q.Types, tt.wantText, tt.wantTypes)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var errCiphertext = errors.New("secret ciphertext is corrupt or was sealed with another key")
+
+// sealer encrypts secret values with AES-256-GCM. The team and name are
+// authenticated with each value, so a ciphertext copied to another row does
+// not decrypt.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+func newSealer(key []byte) (*sealer, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal returns the nonce followed by the sealed value
+func (s *sealer) seal(teamID uuid.UUID, name, value string) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, []byte(value), additionalData(teamID, name)), nil
+}
+
+func (s *sealer) open(teamID uuid.UUID, name string, ciphertext []byte) (string, error) {
+	size := s.aead.NonceSize()
+	if len(ciphertext) < size {
+		return "", errCiphertext
+	}
+	value, err := s.aead.Open(nil, ciphertext[:size], ciphertext[size:], additionalData(teamID, name))
+	if err != nil {
+		return "", errCiphertext
+	}
+	return string(value), nil
+}
+
+func additionalData(teamID uuid.UUID, name string) []byte {
+	return append(teamID[:], name...)
+}
@@ -0,0 +1,43 @@
+package secret
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestSealer_RoundTrip(t *testing.T) {
+	s, err := newSealer(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	team := uuid.New()
+
+	sealed, err := s.seal(team, "github-token", "ghp_abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, []byte("ghp_abc")) {
+		t.Fatal("sealed value contains the plaintext")
+	}
+	if got, err := s.open(team, "github-token", sealed); err != nil || got != "ghp_abc" {
+		t.Errorf("open = %q, %v; want ghp_abc", got, err)
+	}
+
+	// A ciphertext only opens for the team and name it was sealed for
+	if _, err := s.open(uuid.New(), "github-token", sealed); err == nil {
+		t.Error("opened a value sealed for another team")
+	}
+	if _, err := s.open(team, "slack-token", sealed); err == nil {
+		t.Error("opened a value sealed for another name")
+	}
+	if _, err := s.open(team, "github-token", sealed[:4]); err == nil {
+		t.Error("opened a truncated ciphertext")
+	}
+
+	other, _ := newSealer(bytes.Repeat([]byte{8}, 32))
+	if _, err := other.open(team, "github-token", sealed); err == nil {
+		t.Error("opened a value sealed with another key")
+	}
+}
@@ -0,0 +1,52 @@
+package secret
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Secret is a named, encrypted value of a team. The value itself is never
+// part of it; configurations refer to it by name.
+type Secret struct {
+	ID          uuid.UUID  `json:"id"`
+	TeamID      uuid.UUID  `json:"team_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Version     int        `json:"version"`
+	CreatedBy   *uuid.UUID `json:"created_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	RotatedAt   *time.Time `json:"rotated_at,omitempty"`
+	RotatedBy   *uuid.UUID `json:"rotated_by,omitempty"`
+	// LastAccessedAt is when the value was last resolved for a consumer
+	LastAccessedAt *time.Time `json:"last_accessed_at,omitempty"`
+}
+
+type CreateSecretRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Value       string `json:"value" binding:"required"`
+	Description string `json:"description"`
+}
+
+type RotateSecretRequest struct {
+	Value string `json:"value" binding:"required"`
+}
+
+type ListSecretsResponse struct {
+	Secrets []*Secret `json:"secrets"`
+	Total   int       `json:"total"`
+}
+
+// Reference kinds
+const (
+	ReferenceIntegration  = "integration"
+	ReferenceAction       = "action"
+	ReferenceSubscription = "notification_subscription"
+)
+
+// Reference is a configuration that refers to a secret
+type Reference struct {
+	Kind string `json:"kind"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
@@ -0,0 +1,79 @@
+package secret
+
+import (
+	"slices"
+)
+
+// referenceKey marks a secret reference in a configuration: the object
+// {"$secret": "<name>"} stands for the secret's value
+const referenceKey = "$secret"
+
+// References returns the names of the secrets a decoded JSON configuration
+// refers to, sorted and without duplicates
+func References(v interface{}) []string {
+	var names []string
+	walk(v, func(name string) { names = append(names, name) })
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// substitute returns a copy of a decoded JSON configuration with every
+// secret reference replaced by its value in values
+func substitute(v interface{}, values map[string]string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if name, ok := reference(v); ok {
+			return values[name]
+		}
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			out[k] = substitute(item, values)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			out[i] = substitute(item, values)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func walk(v interface{}, fn func(name string)) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if name, ok := reference(v); ok {
+			fn(name)
+			return
+		}
+		for _, item := range v {
+			walk(item, fn)
+		}
+	case []interface{}:
+		for _, item := range v {
+			walk(item, fn)
+		}
+	}
+}
+
+// reference reports whether an object is a secret reference, an object
+// whose only key is "$secret" with a string value
+func reference(v map[string]interface{}) (string, bool) {
+	if len(v) != 1 {
+		return "", false
+	}
+	name, ok := v[referenceKey].(string)
+	return name, ok
+}
+
+// IsReference reports whether a decoded JSON value is a secret reference
+func IsReference(v interface{}) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = reference(m)
+	return ok
+}
@@ -0,0 +1,49 @@
+package secret
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferences(t *testing.T) {
+	config := map[string]interface{}{
+		"url":   "https://api.example.com",
+		"token": map[string]interface{}{"$secret": "api-token"},
+		"steps": []interface{}{
+			map[string]interface{}{"headers": map[string]interface{}{"Authorization": map[string]interface{}{"$secret": "webhook-key"}}},
+			map[string]interface{}{"password": map[string]interface{}{"$secret": "api-token"}},
+		},
+		// Not references: extra keys, or a name that is not a string
+		"literal": map[string]interface{}{"$secret": "x", "note": "kept"},
+		"number":  map[string]interface{}{"$secret": 1.0},
+	}
+
+	if got, want := References(config), []string{"api-token", "webhook-key"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("References = %v, want %v", got, want)
+	}
+	if got := References(map[string]interface{}{"url": "x"}); len(got) != 0 {
+		t.Errorf("References of a plain config = %v, want none", got)
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	config := map[string]interface{}{
+		"url":   "https://api.example.com",
+		"token": map[string]interface{}{"$secret": "api-token"},
+		"list":  []interface{}{map[string]interface{}{"$secret": "api-token"}, 3.0},
+	}
+
+	got := substitute(config, map[string]string{"api-token": "s3cr3t"})
+	want := map[string]interface{}{
+		"url":   "https://api.example.com",
+		"token": "s3cr3t",
+		"list":  []interface{}{"s3cr3t", 3.0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("substitute = %v, want %v", got, want)
+	}
+	// The stored configuration keeps its references
+	if _, ok := config["token"].(map[string]interface{}); !ok {
+		t.Error("substitute modified its input")
+	}
+}
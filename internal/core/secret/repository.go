@@ -0,0 +1,186 @@
+package secret
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const secretColumns = `id, team_id, name, description, version, created_by, created_at, rotated_at, rotated_by, last_accessed_at`
+
+func (r *Repository) Create(ctx context.Context, s *Secret, ciphertext []byte) error {
+	query := `
+		INSERT INTO team_secrets (id, team_id, name, description, ciphertext, created_by)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)
+		RETURNING version, created_at`
+	err := r.db.DB.QueryRowContext(ctx, query,
+		s.ID, s.TeamID, s.Name, s.Description, ciphertext, s.CreatedBy,
+	).Scan(&s.Version, &s.CreatedAt)
+	if postgres.IsUniqueViolation(err) {
+		return ErrSecretExists
+	}
+	return err
+}
+
+func (r *Repository) Get(ctx context.Context, teamID uuid.UUID, name string) (*Secret, error) {
+	query := `SELECT ` + secretColumns + ` FROM team_secrets WHERE team_id = $1 AND name = $2`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets, err := scanSecrets(rows)
+	if err != nil || len(secrets) == 0 {
+		return nil, err
+	}
+	return secrets[0], nil
+}
+
+func (r *Repository) List(ctx context.Context, teamID uuid.UUID) ([]*Secret, error) {
+	query := `SELECT ` + secretColumns + ` FROM team_secrets WHERE team_id = $1 ORDER BY name`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSecrets(rows)
+}
+
+// Names returns which of the given names are secrets of the team
+func (r *Repository) Names(ctx context.Context, teamID uuid.UUID, names []string) (map[string]bool, error) {
+	query := `SELECT name FROM team_secrets WHERE team_id = $1 AND name = ANY($2)`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		found[name] = true
+	}
+	return found, rows.Err()
+}
+
+// Rotate replaces a secret's value and returns the updated secret, or nil
+// when there is no such secret
+func (r *Repository) Rotate(ctx context.Context, teamID uuid.UUID, name string, ciphertext []byte, rotatedBy *uuid.UUID) (*Secret, error) {
+	query := `
+		UPDATE team_secrets
+		SET ciphertext = $3, version = version + 1, rotated_at = NOW(), rotated_by = $4
+		WHERE team_id = $1 AND name = $2
+		RETURNING ` + secretColumns
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, name, ciphertext, rotatedBy)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets, err := scanSecrets(rows)
+	if err != nil || len(secrets) == 0 {
+		return nil, err
+	}
+	return secrets[0], nil
+}
+
+// Delete removes a secret and reports whether it existed
+func (r *Repository) Delete(ctx context.Context, teamID uuid.UUID, name string) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM team_secrets WHERE team_id = $1 AND name = $2`, teamID, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Ciphertexts returns the sealed values of the named secrets with their
+// versions, and marks them accessed. Missing names are left out.
+func (r *Repository) Ciphertexts(ctx context.Context, teamID uuid.UUID, names []string) (map[string][]byte, map[string]int, error) {
+	query := `
+		UPDATE team_secrets SET last_accessed_at = NOW()
+		WHERE team_id = $1 AND name = ANY($2)
+		RETURNING name, ciphertext, version`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, names)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	ciphertexts := map[string][]byte{}
+	versions := map[string]int{}
+	for rows.Next() {
+		var name string
+		var ciphertext []byte
+		var version int
+		if err := rows.Scan(&name, &ciphertext, &version); err != nil {
+			return nil, nil, err
+		}
+		ciphertexts[name] = ciphertext
+		versions[name] = version
+	}
+	return ciphertexts, versions, rows.Err()
+}
+
+// FindReferences returns the integrations, actions and notification
+// subscriptions of the team whose configuration refers to the named secret
+func (r *Repository) FindReferences(ctx context.Context, teamID uuid.UUID, name string) ([]Reference, error) {
+	query := `
+		SELECT 'integration', id::text, name FROM integrations
+		WHERE team_id = $1 AND jsonb_path_exists(config, '$.** ? (@."$secret" == $name)', jsonb_build_object('name', $2::text))
+		UNION ALL
+		SELECT 'action', id::text, identifier FROM actions
+		WHERE team_id = $1 AND (
+			jsonb_path_exists(COALESCE(trigger_config, '{}'), '$.** ? (@."$secret" == $name)', jsonb_build_object('name', $2::text))
+			OR jsonb_path_exists(steps, '$.** ? (@."$secret" == $name)', jsonb_build_object('name', $2::text))
+		)
+		UNION ALL
+		SELECT 'notification_subscription', id::text, channel FROM notification_subscriptions
+		WHERE team_id = $1 AND webhook_secret = $2
+		ORDER BY 1, 3`
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []Reference
+	for rows.Next() {
+		var ref Reference
+		if err := rows.Scan(&ref.Kind, &ref.ID, &ref.Name); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+func scanSecrets(rows *sql.Rows) ([]*Secret, error) {
+	var secrets []*Secret
+	for rows.Next() {
+		s := &Secret{}
+		var description sql.NullString
+		if err := rows.Scan(&s.ID, &s.TeamID, &s.Name, &description, &s.Version,
+			&s.CreatedBy, &s.CreatedAt, &s.RotatedAt, &s.RotatedBy, &s.LastAccessedAt); err != nil {
+			return nil, err
+		}
+		s.Description = description.String
+		secrets = append(secrets, s)
+	}
+	return secrets, rows.Err()
+}
@@ -0,0 +1,241 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/auth"
+)
+
+var (
+	ErrNotFound      = errors.New("secret not found")
+	ErrSecretExists  = errors.New("a secret with this name already exists")
+	ErrInvalidName   = errors.New("secret names must start with a letter and contain only letters, digits, '_' and '-'")
+	ErrValueTooLarge = errors.New("secret value is too large")
+	ErrUnknownSecret = errors.New("unknown secret")
+	ErrUnavailable   = errors.New("the secrets store is not configured (set SECRETS_ENCRYPTION_KEY)")
+)
+
+// maxValueBytes bounds a secret value
+const maxValueBytes = 64 << 10
+
+var namePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]{0,99}$`)
+
+// InUseError is returned when deleting a secret that configurations still
+// refer to
+type InUseError struct {
+	References []Reference
+}
+
+func (e *InUseError) Error() string {
+	return fmt.Sprintf("secret is referenced by %d configuration(s)", len(e.References))
+}
+
+// AuditRecorder stores audit log entries; auth.Service is one
+type AuditRecorder interface {
+	CreateAuditLog(ctx context.Context, log *auth.AuditLog) error
+}
+
+type Service struct {
+	repo   *Repository
+	sealer *sealer
+	audit  AuditRecorder
+}
+
+// NewService creates the secrets service. Without a key, secrets can be
+// listed and references checked, but nothing can be stored or resolved.
+// audit may be nil.
+func NewService(repo *Repository, key []byte, audit AuditRecorder) (*Service, error) {
+	s := &Service{repo: repo, audit: audit}
+	if key != nil {
+		var err error
+		if s.sealer, err = newSealer(key); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Service) Create(ctx context.Context, teamID uuid.UUID, req *CreateSecretRequest, userID *uuid.UUID, ipAddress, userAgent *string) (*Secret, error) {
+	if s.sealer == nil {
+		return nil, ErrUnavailable
+	}
+	if !namePattern.MatchString(req.Name) {
+		return nil, ErrInvalidName
+	}
+	if len(req.Value) > maxValueBytes {
+		return nil, fmt.Errorf("%w (max %d bytes)", ErrValueTooLarge, maxValueBytes)
+	}
+	ciphertext, err := s.sealer.seal(teamID, req.Name, req.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &Secret{
+		ID:          uuid.New(),
+		TeamID:      teamID,
+		Name:        req.Name,
+		Description: strings.TrimSpace(req.Description),
+		CreatedBy:   userID,
+	}
+	if err := s.repo.Create(ctx, secret, ciphertext); err != nil {
+		return nil, err
+	}
+	s.record(teamID, secret.Name, "create", map[string]any{"version": secret.Version}, nil, userID, ipAddress, userAgent)
+	return secret, nil
+}
+
+func (s *Service) List(ctx context.Context, teamID uuid.UUID) (*ListSecretsResponse, error) {
+	secrets, err := s.repo.List(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if secrets == nil {
+		secrets = []*Secret{}
+	}
+	return &ListSecretsResponse{Secrets: secrets, Total: len(secrets)}, nil
+}
+
+func (s *Service) Get(ctx context.Context, teamID uuid.UUID, name string) (*Secret, error) {
+	secret, err := s.repo.Get(ctx, teamID, name)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrNotFound
+	}
+	return secret, nil
+}
+
+// Rotate replaces a secret's value. Configurations refer to the secret by
+// name, so they pick up the new value the next time it is resolved.
+func (s *Service) Rotate(ctx context.Context, teamID uuid.UUID, name string, req *RotateSecretRequest, userID *uuid.UUID, ipAddress, userAgent *string) (*Secret, error) {
+	if s.sealer == nil {
+		return nil, ErrUnavailable
+	}
+	if len(req.Value) > maxValueBytes {
+		return nil, fmt.Errorf("%w (max %d bytes)", ErrValueTooLarge, maxValueBytes)
+	}
+	ciphertext, err := s.sealer.seal(teamID, name, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := s.repo.Rotate(ctx, teamID, name, ciphertext, userID)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrNotFound
+	}
+	s.record(teamID, name, "rotate", map[string]any{"version": secret.Version}, nil, userID, ipAddress, userAgent)
+	return secret, nil
+}
+
+// Delete removes a secret that no integration or action refers to
+func (s *Service) Delete(ctx context.Context, teamID uuid.UUID, name string, userID *uuid.UUID, ipAddress, userAgent *string) error {
+	refs, err := s.repo.FindReferences(ctx, teamID, name)
+	if err != nil {
+		return err
+	}
+	if len(refs) > 0 {
+		return &InUseError{References: refs}
+	}
+	found, err := s.repo.Delete(ctx, teamID, name)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	s.record(teamID, name, "delete", nil, nil, userID, ipAddress, userAgent)
+	return nil
+}
+
+// CheckReferences verifies that every secret a decoded JSON configuration
+// refers to exists in the team. A nil *Service accepts only configurations
+// without references.
+func (s *Service) CheckReferences(ctx context.Context, teamID uuid.UUID, config interface{}) error {
+	names := References(config)
+	if len(names) == 0 {
+		return nil
+	}
+	if s == nil {
+		return ErrUnavailable
+	}
+	found, err := s.repo.Names(ctx, teamID, names)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !found[name] {
+			return fmt.Errorf("%w: %q", ErrUnknownSecret, name)
+		}
+	}
+	return nil
+}
+
+// Resolve returns a copy of a decoded JSON configuration with its secret
+// references replaced by their values. Every secret read is audited with
+// the consumer, e.g. "integration:<id>", it was read for.
+func (s *Service) Resolve(ctx context.Context, teamID uuid.UUID, config interface{}, consumer string, userID *uuid.UUID, ipAddress, userAgent *string) (interface{}, error) {
+	names := References(config)
+	if len(names) == 0 {
+		return config, nil
+	}
+	if s == nil || s.sealer == nil {
+		return nil, ErrUnavailable
+	}
+	ciphertexts, versions, err := s.repo.Ciphertexts(ctx, teamID, names)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(names))
+	for _, name := range names {
+		ciphertext, ok := ciphertexts[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownSecret, name)
+		}
+		if values[name], err = s.sealer.open(teamID, name, ciphertext); err != nil {
+			return nil, fmt.Errorf("secret %q: %w", name, err)
+		}
+	}
+	for _, name := range names {
+		s.record(teamID, name, "access", map[string]any{"version": versions[name]},
+			map[string]any{"consumer": consumer}, userID, ipAddress, userAgent)
+	}
+	return substitute(config, values), nil
+}
+
+// record adds a secret event to the audit log. Values are never recorded.
+func (s *Service) record(teamID uuid.UUID, name, action string, data, requestContext map[string]any, userID *uuid.UUID, ipAddress, userAgent *string) {
+	if s.audit == nil {
+		return
+	}
+	result := "success"
+	entry := &auth.AuditLog{
+		ID:             uuid.New(),
+		TeamID:         &teamID,
+		UserID:         userID,
+		ActorType:      "team_member",
+		EntityType:     "secret",
+		EntityID:       name,
+		Action:         action,
+		NewData:        data,
+		IPAddress:      ipAddress,
+		UserAgent:      userAgent,
+		ResultStatus:   &result,
+		RequestContext: requestContext,
+	}
+	// Log asynchronously to not block the response
+	go func() {
+		if err := s.audit.CreateAuditLog(context.Background(), entry); err != nil {
+			log.Printf("ERROR: failed to create audit log for %s of secret %s: %v", entry.Action, entry.EntityID, err)
+		}
+	}()
+}
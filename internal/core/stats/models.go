@@ -0,0 +1,75 @@
+package stats
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DailyRequests is the request volume of one day. Days without requests are
+// reported with zero counts so the series has no gaps.
+type DailyRequests struct {
+	Day          string `json:"day"` // YYYY-MM-DD, UTC
+	Requests     int64  `json:"requests"`
+	ClientErrors int64  `json:"client_errors"`
+	ServerErrors int64  `json:"server_errors"`
+}
+
+// BlueprintStats is the size of one blueprint's catalog. DataBytes is the
+// stored size of the entities' JSONB data.
+type BlueprintStats struct {
+	BlueprintID string `json:"blueprint_id"`
+	Title       string `json:"title"`
+	Entities    int64  `json:"entities"`
+	DataBytes   int64  `json:"data_bytes"`
+}
+
+// TeamStats is the usage of one team
+type TeamStats struct {
+	TeamID     uuid.UUID         `json:"team_id"`
+	Name       string            `json:"name"`
+	Members    int64             `json:"members"`
+	APIKeys    int64             `json:"api_keys"`
+	Entities   int64             `json:"entities"`
+	DataBytes  int64             `json:"data_bytes"`
+	Blueprints []*BlueprintStats `json:"blueprints"`
+	Requests   []*DailyRequests  `json:"requests"`
+	// RequestCounting is false when the server does not count requests
+	RequestCounting bool      `json:"request_counting"`
+	GeneratedAt     time.Time `json:"generated_at"`
+}
+
+// TeamSummary is one team in the platform statistics. Requests is the total
+// over the requested days.
+type TeamSummary struct {
+	TeamID    uuid.UUID `json:"team_id"`
+	Name      string    `json:"name"`
+	Members   int64     `json:"members"`
+	Entities  int64     `json:"entities"`
+	DataBytes int64     `json:"data_bytes"`
+	Requests  int64     `json:"requests"`
+}
+
+// PlatformStats is the usage of the whole installation
+type PlatformStats struct {
+	Users      map[string]int64 `json:"users"` // by status
+	Teams      int64            `json:"teams"`
+	Blueprints int64            `json:"blueprints"`
+	APIKeys    int64            `json:"api_keys"`
+	Entities   int64            `json:"entities"`
+	DataBytes  int64            `json:"data_bytes"`
+	Requests   []*DailyRequests `json:"requests"`
+	// TopTeams are the largest teams by data size
+	TopTeams        []*TeamSummary `json:"top_teams"`
+	RequestCounting bool           `json:"request_counting"`
+	GeneratedAt     time.Time      `json:"generated_at"`
+}
+
+// RequestRow is one team's request counter for a day
+type RequestRow struct {
+	TeamID       uuid.UUID
+	Day          time.Time
+	Requests     int64
+	ClientErrors int64
+	ServerErrors int64
+}
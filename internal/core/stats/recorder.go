@@ -0,0 +1,139 @@
+package stats
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestFlushInterval is how often counted requests are written to team_request_stats
+const requestFlushInterval = time.Minute
+
+type requestCounts struct {
+	requests     int64
+	clientErrors int64
+	serverErrors int64
+}
+
+// RequestRecorder counts the requests made in each team's context. Counts
+// are kept in memory and added to the daily counters in team_request_stats
+// every minute, so counting never waits for the database. A nil
+// *RequestRecorder counts nothing.
+type RequestRecorder struct {
+	repo      *Repository
+	retention time.Duration
+	now       func() time.Time
+
+	mu     sync.Mutex
+	counts map[uuid.UUID]*requestCounts
+}
+
+func NewRequestRecorder(repo *Repository, retentionDays int) *RequestRecorder {
+	return &RequestRecorder{
+		repo:      repo,
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+		now:       time.Now,
+		counts:    make(map[uuid.UUID]*requestCounts),
+	}
+}
+
+// Record counts one request of a team that completed with the given status
+func (r *RequestRecorder) Record(teamID uuid.UUID, status int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts, ok := r.counts[teamID]
+	if !ok {
+		counts = &requestCounts{}
+		r.counts[teamID] = counts
+	}
+	counts.requests++
+	switch {
+	case status >= http.StatusInternalServerError:
+		counts.serverErrors++
+	case status >= http.StatusBadRequest:
+		counts.clientErrors++
+	}
+}
+
+// Run writes counted requests every minute and prunes expired counters every
+// day, until ctx is done. Requests counted since the last write are written
+// before it returns.
+func (r *RequestRecorder) Run(ctx context.Context) {
+	flush := time.NewTicker(requestFlushInterval)
+	defer flush.Stop()
+	prune := time.NewTicker(24 * time.Hour)
+	defer prune.Stop()
+
+	r.prune(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			// The request context is gone; give the final write its own
+			final, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := r.flush(final); err != nil {
+				log.Printf("ERROR: request stats write failed: %v", err)
+			}
+			cancel()
+			return
+		case <-flush.C:
+			if err := r.flush(ctx); err != nil {
+				log.Printf("ERROR: request stats write failed: %v", err)
+			}
+		case <-prune.C:
+			r.prune(ctx)
+		}
+	}
+}
+
+// flush writes the counted requests. On failure they are kept for the next
+// attempt.
+func (r *RequestRecorder) flush(ctx context.Context) error {
+	r.mu.Lock()
+	counts := r.counts
+	r.counts = make(map[uuid.UUID]*requestCounts)
+	r.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	day := r.now().UTC().Truncate(24 * time.Hour)
+	rows := make([]RequestRow, 0, len(counts))
+	for teamID, c := range counts {
+		rows = append(rows, RequestRow{
+			TeamID:       teamID,
+			Day:          day,
+			Requests:     c.requests,
+			ClientErrors: c.clientErrors,
+			ServerErrors: c.serverErrors,
+		})
+	}
+	if err := r.repo.AddRequests(ctx, rows); err != nil {
+		r.mu.Lock()
+		for teamID, c := range counts {
+			kept, ok := r.counts[teamID]
+			if !ok {
+				r.counts[teamID] = c
+				continue
+			}
+			kept.requests += c.requests
+			kept.clientErrors += c.clientErrors
+			kept.serverErrors += c.serverErrors
+		}
+		r.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+func (r *RequestRecorder) prune(ctx context.Context) {
+	if err := r.repo.PruneRequests(ctx, r.now().Add(-r.retention)); err != nil {
+		log.Printf("ERROR: request stats pruning failed: %v", err)
+	}
+}
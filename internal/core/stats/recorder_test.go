@@ -0,0 +1,28 @@
+package stats
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestRequestRecorder_Record(t *testing.T) {
+	r := NewRequestRecorder(nil, 90)
+	team := uuid.New()
+
+	r.Record(team, 200)
+	r.Record(team, 404)
+	r.Record(team, 503)
+	r.Record(uuid.New(), 201)
+
+	got := r.counts[team]
+	if got == nil || got.requests != 3 || got.clientErrors != 1 || got.serverErrors != 1 {
+		t.Errorf("counts = %+v, want 3 requests, 1 client error, 1 server error", got)
+	}
+	if len(r.counts) != 2 {
+		t.Errorf("counted %d teams, want 2", len(r.counts))
+	}
+
+	var none *RequestRecorder
+	none.Record(team, 200)
+}
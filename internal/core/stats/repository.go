@@ -0,0 +1,201 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+// TeamName returns a team's name, or false when there is no such team
+func (r *Repository) TeamName(ctx context.Context, teamID uuid.UUID) (string, bool, error) {
+	var name string
+	err := r.db.DB.QueryRowContext(ctx, `SELECT name FROM teams WHERE id = $1`, teamID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	return name, err == nil, err
+}
+
+// TeamCounts returns how many members and API keys a team has
+func (r *Repository) TeamCounts(ctx context.Context, teamID uuid.UUID) (members, apiKeys int64, err error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM team_memberships WHERE team_id = $1),
+			(SELECT COUNT(*) FROM api_keys WHERE team_id = $1)`
+	err = r.db.DB.QueryRowContext(ctx, query, teamID).Scan(&members, &apiKeys)
+	return members, apiKeys, err
+}
+
+// BlueprintStats counts each of a team's blueprints' entities and their data
+// size, largest first
+func (r *Repository) BlueprintStats(ctx context.Context, teamID uuid.UUID) ([]*BlueprintStats, error) {
+	query := `
+		SELECT b.id, b.title, COUNT(e.id), COALESCE(SUM(pg_column_size(e.data)), 0)
+		FROM blueprints b
+		LEFT JOIN entities e ON e.blueprint_id = b.id AND e.team_id = b.team_id
+		WHERE b.team_id = $1
+		GROUP BY b.id, b.title
+		ORDER BY COUNT(e.id) DESC, b.id`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	blueprints := []*BlueprintStats{}
+	for rows.Next() {
+		b := &BlueprintStats{}
+		if err := rows.Scan(&b.BlueprintID, &b.Title, &b.Entities, &b.DataBytes); err != nil {
+			return nil, err
+		}
+		blueprints = append(blueprints, b)
+	}
+	return blueprints, rows.Err()
+}
+
+// PlatformCounts fills in the installation-wide totals of stats
+func (r *Repository) PlatformCounts(ctx context.Context, stats *PlatformStats) error {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM teams),
+			(SELECT COUNT(*) FROM blueprints),
+			(SELECT COUNT(*) FROM api_keys),
+			(SELECT COUNT(*) FROM entities),
+			(SELECT COALESCE(SUM(pg_column_size(data)), 0) FROM entities)`
+	err := r.db.DB.QueryRowContext(ctx, query).Scan(
+		&stats.Teams, &stats.Blueprints, &stats.APIKeys, &stats.Entities, &stats.DataBytes)
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT status, COUNT(*) FROM users GROUP BY status`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	stats.Users = map[string]int64{}
+	for rows.Next() {
+		var status string
+		var count int64
+		if err := rows.Scan(&status, &count); err != nil {
+			return err
+		}
+		stats.Users[status] = count
+	}
+	return rows.Err()
+}
+
+// TopTeams returns the teams with the most entity data, with their requests
+// since the given day
+func (r *Repository) TopTeams(ctx context.Context, since time.Time, limit int) ([]*TeamSummary, error) {
+	query := `
+		SELECT t.id, t.name,
+			(SELECT COUNT(*) FROM team_memberships m WHERE m.team_id = t.id),
+			COALESCE(e.entities, 0), COALESCE(e.data_bytes, 0), COALESCE(q.requests, 0)
+		FROM teams t
+		LEFT JOIN (
+			SELECT team_id, COUNT(*) AS entities, SUM(pg_column_size(data)) AS data_bytes
+			FROM entities GROUP BY team_id
+		) e ON e.team_id = t.id
+		LEFT JOIN (
+			SELECT team_id, SUM(requests) AS requests
+			FROM team_request_stats WHERE day >= $1 GROUP BY team_id
+		) q ON q.team_id = t.id
+		ORDER BY COALESCE(e.data_bytes, 0) DESC, t.name
+		LIMIT $2`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, since.Format(time.DateOnly), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	teams := []*TeamSummary{}
+	for rows.Next() {
+		t := &TeamSummary{}
+		if err := rows.Scan(&t.TeamID, &t.Name, &t.Members, &t.Entities, &t.DataBytes, &t.Requests); err != nil {
+			return nil, err
+		}
+		teams = append(teams, t)
+	}
+	return teams, rows.Err()
+}
+
+// Requests returns the daily request counters since the given day, of one
+// team or, with a nil teamID, summed over all teams
+func (r *Repository) Requests(ctx context.Context, teamID *uuid.UUID, since time.Time) ([]RequestRow, error) {
+	query := `
+		SELECT day, SUM(requests), SUM(client_errors), SUM(server_errors)
+		FROM team_request_stats
+		WHERE day >= $1 AND ($2::uuid IS NULL OR team_id = $2)
+		GROUP BY day
+		ORDER BY day`
+
+	rows, err := r.db.DB.QueryContext(ctx, query, since.Format(time.DateOnly), teamID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []RequestRow
+	for rows.Next() {
+		var row RequestRow
+		if err := rows.Scan(&row.Day, &row.Requests, &row.ClientErrors, &row.ServerErrors); err != nil {
+			return nil, err
+		}
+		requests = append(requests, row)
+	}
+	return requests, rows.Err()
+}
+
+// AddRequests adds each row's counts to its daily counter. Rows of teams
+// deleted since they were recorded are ignored.
+func (r *Repository) AddRequests(ctx context.Context, rows []RequestRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	teamIDs := make([]string, len(rows))
+	days := make([]string, len(rows))
+	requests := make([]int64, len(rows))
+	clientErrors := make([]int64, len(rows))
+	serverErrors := make([]int64, len(rows))
+	for i, row := range rows {
+		teamIDs[i], days[i], requests[i], clientErrors[i], serverErrors[i] =
+			row.TeamID.String(), row.Day.Format(time.DateOnly), row.Requests, row.ClientErrors, row.ServerErrors
+	}
+
+	query := `
+		INSERT INTO team_request_stats (team_id, day, requests, client_errors, server_errors)
+		SELECT r.team_id, r.day, r.requests, r.client_errors, r.server_errors
+		FROM unnest($1::uuid[], $2::date[], $3::bigint[], $4::bigint[], $5::bigint[])
+			AS r(team_id, day, requests, client_errors, server_errors)
+		WHERE EXISTS (SELECT 1 FROM teams t WHERE t.id = r.team_id)
+		ON CONFLICT (team_id, day) DO UPDATE SET
+			requests = team_request_stats.requests + EXCLUDED.requests,
+			client_errors = team_request_stats.client_errors + EXCLUDED.client_errors,
+			server_errors = team_request_stats.server_errors + EXCLUDED.server_errors`
+
+	_, err := r.db.DB.ExecContext(ctx, query,
+		teamIDs, days, requests, clientErrors, serverErrors)
+	return err
+}
+
+// PruneRequests removes request counters of days before the given one
+func (r *Repository) PruneRequests(ctx context.Context, before time.Time) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM team_request_stats WHERE day < $1`, before.Format(time.DateOnly))
+	return err
+}
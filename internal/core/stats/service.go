@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrTeamNotFound = errors.New("team not found")
+
+// MaxDays bounds the request history returned by one call
+const MaxDays = 365
+
+type Service struct {
+	repo *Repository
+	// counting is whether the server counts requests at all
+	counting bool
+	now      func() time.Time
+}
+
+func NewService(repo *Repository, counting bool) *Service {
+	return &Service{repo: repo, counting: counting, now: time.Now}
+}
+
+// Team returns a team's member, API key and entity counts, its data size
+// per blueprint, and its daily requests over the last days
+func (s *Service) Team(ctx context.Context, teamID uuid.UUID, days int) (*TeamStats, error) {
+	name, found, err := s.repo.TeamName(ctx, teamID)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrTeamNotFound
+	}
+
+	stats := &TeamStats{TeamID: teamID, Name: name, RequestCounting: s.counting, GeneratedAt: s.now().UTC()}
+	if stats.Members, stats.APIKeys, err = s.repo.TeamCounts(ctx, teamID); err != nil {
+		return nil, err
+	}
+	if stats.Blueprints, err = s.repo.BlueprintStats(ctx, teamID); err != nil {
+		return nil, err
+	}
+	for _, b := range stats.Blueprints {
+		stats.Entities += b.Entities
+		stats.DataBytes += b.DataBytes
+	}
+
+	since := s.firstDay(days)
+	rows, err := s.repo.Requests(ctx, &teamID, since)
+	if err != nil {
+		return nil, err
+	}
+	stats.Requests = dailySeries(rows, since, days)
+	return stats, nil
+}
+
+// Platform returns installation-wide counts, daily requests over the last
+// days summed over all teams, and the largest teams
+func (s *Service) Platform(ctx context.Context, days, topTeams int) (*PlatformStats, error) {
+	stats := &PlatformStats{RequestCounting: s.counting, GeneratedAt: s.now().UTC()}
+	if err := s.repo.PlatformCounts(ctx, stats); err != nil {
+		return nil, err
+	}
+
+	since := s.firstDay(days)
+	rows, err := s.repo.Requests(ctx, nil, since)
+	if err != nil {
+		return nil, err
+	}
+	stats.Requests = dailySeries(rows, since, days)
+	if stats.TopTeams, err = s.repo.TopTeams(ctx, since, topTeams); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// firstDay is the first of the last days days, today included
+func (s *Service) firstDay(days int) time.Time {
+	today := s.now().UTC().Truncate(24 * time.Hour)
+	return today.AddDate(0, 0, 1-days)
+}
+
+// dailySeries lays out request counters as one entry per day from since,
+// with zeros for days without requests
+func dailySeries(rows []RequestRow, since time.Time, days int) []*DailyRequests {
+	byDay := make(map[string]RequestRow, len(rows))
+	for _, row := range rows {
+		byDay[row.Day.Format(time.DateOnly)] = row
+	}
+	series := make([]*DailyRequests, 0, days)
+	for i := 0; i < days; i++ {
+		day := since.AddDate(0, 0, i).Format(time.DateOnly)
+		row := byDay[day]
+		series = append(series, &DailyRequests{
+			Day:          day,
+			Requests:     row.Requests,
+			ClientErrors: row.ClientErrors,
+			ServerErrors: row.ServerErrors,
+		})
+	}
+	return series
+}
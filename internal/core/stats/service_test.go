@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDailySeries(t *testing.T) {
+	since := time.Date(2026, 3, 30, 0, 0, 0, 0, time.UTC)
+	rows := []RequestRow{
+		{Day: since, Requests: 10, ClientErrors: 2},
+		{Day: since.AddDate(0, 0, 2), Requests: 5, ServerErrors: 1},
+	}
+
+	series := dailySeries(rows, since, 4)
+	want := []DailyRequests{
+		{Day: "2026-03-30", Requests: 10, ClientErrors: 2},
+		{Day: "2026-03-31"},
+		{Day: "2026-04-01", Requests: 5, ServerErrors: 1},
+		{Day: "2026-04-02"},
+	}
+	if len(series) != len(want) {
+		t.Fatalf("got %d days, want %d", len(series), len(want))
+	}
+	for i, day := range series {
+		if *day != want[i] {
+			t.Errorf("day %d = %+v, want %+v", i, *day, want[i])
+		}
+	}
+}
+
+func TestService_FirstDay(t *testing.T) {
+	s := &Service{now: func() time.Time { return time.Date(2026, 4, 2, 17, 30, 0, 0, time.UTC) }}
+	if got, want := s.firstDay(30), time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("firstDay(30) = %v, want %v", got, want)
+	}
+	if got, want := s.firstDay(1), time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("firstDay(1) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,95 @@
+package validation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Length limits of slugs. The maximum is the width of the columns team
+// slugs and blueprint IDs are stored in.
+const (
+	MinSlugLength = 2
+	MaxSlugLength = 50
+)
+
+// slugPattern is lowercase letters and digits in words joined by single dashes
+var slugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// reservedSlugs are path segments and words the API and clients give a
+// meaning of their own
+var reservedSlugs = map[string]bool{
+	"admin":      true,
+	"api":        true,
+	"auth":       true,
+	"blueprints": true,
+	"entities":   true,
+	"export":     true,
+	"import":     true,
+	"me":         true,
+	"new":        true,
+	"search":     true,
+	"settings":   true,
+	"system":     true,
+	"teams":      true,
+}
+
+// IsReservedSlug reports whether slug is reserved
+func IsReservedSlug(slug string) bool {
+	return reservedSlugs[slug]
+}
+
+// ValidateSlug checks the format of a team slug or blueprint ID: 2-50
+// lowercase letters and digits in words joined by single dashes, and not a
+// reserved name. The error is a *ValidationErrors for field, suggesting the
+// canonical form when there is a valid one.
+func ValidateSlug(field, value string) error {
+	var message string
+	switch {
+	case len(value) < MinSlugLength || len(value) > MaxSlugLength:
+		message = fmt.Sprintf("must be %d-%d characters", MinSlugLength, MaxSlugLength)
+	case !slugPattern.MatchString(value):
+		message = "must be lowercase letters and digits separated by single dashes"
+	case IsReservedSlug(value):
+		message = fmt.Sprintf("%q is reserved", value)
+	default:
+		return nil
+	}
+	if canonical := CanonicalSlug(value); canonical != value && ValidSlug(canonical) {
+		message += fmt.Sprintf(" (try %q)", canonical)
+	}
+	return &ValidationErrors{Errors: []ValidationError{{Field: field, Message: message}}}
+}
+
+// ValidSlug reports whether value passes ValidateSlug
+func ValidSlug(value string) bool {
+	return len(value) >= MinSlugLength && len(value) <= MaxSlugLength &&
+		slugPattern.MatchString(value) && !IsReservedSlug(value)
+}
+
+// CanonicalSlug returns the slug form of value: lowercased, with every run of
+// other characters than letters and digits replaced by one dash, no leading
+// or trailing dash, and cut to MaxSlugLength. It is idempotent, so stored
+// values may be canonicalized any number of times, and valid slugs are
+// returned unchanged. The result can still be too short or reserved; check
+// it with ValidateSlug.
+func CanonicalSlug(value string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	slug := b.String()
+	if len(slug) > MaxSlugLength {
+		slug = strings.TrimRight(slug[:MaxSlugLength], "-")
+	}
+	return slug
+}
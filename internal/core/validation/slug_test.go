@@ -0,0 +1,67 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSlug(t *testing.T) {
+	tests := []struct {
+		value   string
+		valid   bool
+		message string
+	}{
+		{value: "service", valid: true},
+		{value: "platform-team", valid: true},
+		{value: "k8s-cluster-2", valid: true},
+		{value: "a", message: "must be 2-50 characters"},
+		{value: strings.Repeat("a", 51), message: "must be 2-50 characters (try"},
+		{value: "My_Service", message: `must be lowercase letters and digits separated by single dashes (try "my-service")`},
+		{value: "double--dash", message: `separated by single dashes (try "double-dash")`},
+		{value: "-leading", message: `(try "leading")`},
+		{value: "admin", message: `"admin" is reserved`},
+		{value: "Admin", message: "must be lowercase"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			err := ValidateSlug("slug", tt.value)
+			if tt.valid {
+				if err != nil {
+					t.Fatalf("ValidateSlug(%q) = %v, want nil", tt.value, err)
+				}
+				return
+			}
+			errs := GetValidationErrors(err)
+			if errs == nil || len(errs.Errors) != 1 {
+				t.Fatalf("ValidateSlug(%q) = %v, want one validation error", tt.value, err)
+			}
+			if errs.Errors[0].Field != "slug" || !strings.Contains(errs.Errors[0].Message, tt.message) {
+				t.Errorf("ValidateSlug(%q) = %+v, want slug: %q", tt.value, errs.Errors[0], tt.message)
+			}
+			if strings.Contains(errs.Errors[0].Message, "(try") != strings.Contains(tt.message, "(try") {
+				t.Errorf("ValidateSlug(%q) message %q suggests differently than %q", tt.value, errs.Errors[0].Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestCanonicalSlug(t *testing.T) {
+	tests := map[string]string{
+		"service":                 "service",
+		"My Service":              "my-service",
+		"payments_api.v2":         "payments-api-v2",
+		"  --Edge--Case--  ":      "edge-case",
+		"Équipe":                  "quipe",
+		"!!!":                     "",
+		strings.Repeat("ab-", 30): strings.Repeat("ab-", 16) + "ab",
+	}
+	for in, want := range tests {
+		got := CanonicalSlug(in)
+		if got != want {
+			t.Errorf("CanonicalSlug(%q) = %q, want %q", in, got, want)
+		}
+		if again := CanonicalSlug(got); again != got {
+			t.Errorf("CanonicalSlug is not idempotent on %q: %q", got, again)
+		}
+	}
+}
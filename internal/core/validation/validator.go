@@ -12,6 +12,15 @@ import (
 type ValidationError struct {
 	Field   string `json:"field"`
 	Message string `json:"message"`
+	// Path is the JSON pointer of the invalid value within the validated
+	// document, e.g. /owner/email; empty for the document itself
+	Path string `json:"path,omitempty"`
+	// Type is the failed check, e.g. required, enum, format or invalid_type
+	Type string `json:"type,omitempty"`
+	// Allowed are the values an enum or const accepts
+	Allowed []interface{} `json:"allowed,omitempty"`
+	// Format is the format a string value must have
+	Format string `json:"format,omitempty"`
 }
 
 type ValidationErrors struct {
@@ -59,10 +68,7 @@ func (v *Validator) Validate(data map[string]interface{}, schema map[string]inte
 	if !result.Valid() {
 		var validationErrors []ValidationError
 		for _, desc := range result.Errors() {
-			validationErrors = append(validationErrors, ValidationError{
-				Field:   desc.Field(),
-				Message: desc.Description(),
-			})
+			validationErrors = append(validationErrors, enrich(desc))
 		}
 		return &ValidationErrors{Errors: validationErrors}
 	}
@@ -70,6 +76,66 @@ func (v *Validator) Validate(data map[string]interface{}, schema map[string]inte
 	return nil
 }
 
+// enrich turns a gojsonschema error into a ValidationError with the path of
+// the invalid value and what the schema expected of it
+func enrich(desc gojsonschema.ResultError) ValidationError {
+	ve := ValidationError{
+		Field:   desc.Field(),
+		Message: desc.Description(),
+		Type:    desc.Type(),
+	}
+	// The context is "(root)" followed by the keys and indexes leading to
+	// the value; NUL cannot be confused with a key
+	parts := strings.Split(desc.Context().String("\x00"), "\x00")[1:]
+	details := desc.Details()
+	switch desc.Type() {
+	case "required", "additional_property_not_allowed":
+		if property, ok := details["property"].(string); ok {
+			parts = append(parts, property)
+		}
+	case "enum":
+		// Enum values are listed as JSON joined by ", ", so the list is a
+		// JSON array without its brackets
+		if allowed, ok := details["allowed"].(string); ok {
+			var values []interface{}
+			if json.Unmarshal([]byte("["+allowed+"]"), &values) == nil {
+				ve.Allowed = values
+			}
+		}
+	case "const":
+		if allowed, ok := details["allowed"].(string); ok {
+			var value interface{}
+			if json.Unmarshal([]byte(allowed), &value) == nil {
+				ve.Allowed = []interface{}{value}
+			}
+		}
+	case "format":
+		ve.Format, _ = details["format"].(string)
+	}
+	ve.Path = JSONPointer(parts...)
+	return ve
+}
+
+// JSONPointer returns the RFC 6901 pointer of a path of keys and indexes
+func JSONPointer(parts ...string) string {
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteByte('/')
+		b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(part))
+	}
+	return b.String()
+}
+
+// CheckSchema returns an error when schema is not a JSON Schema that data can
+// be validated against
+func CheckSchema(schema map[string]interface{}) error {
+	if len(schema) == 0 {
+		return nil
+	}
+	_, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(schema))
+	return err
+}
+
 func removeRequiredDeep(schema map[string]interface{}) map[string]interface{} {
 	result := make(map[string]interface{})
 	for k, v := range schema {
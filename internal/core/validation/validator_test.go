@@ -0,0 +1,55 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateEnrichesErrors(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []interface{}{"owner"},
+		"properties": map[string]interface{}{
+			"owner": map[string]interface{}{"type": "string"},
+			"tier":  map[string]interface{}{"type": "string", "enum": []interface{}{"gold", "silver, plus", 3}},
+			"kind":  map[string]interface{}{"const": "service"},
+			"links": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":       "object",
+					"properties": map[string]interface{}{"a/b": map[string]interface{}{"type": "string", "format": "email"}},
+				},
+			},
+		},
+		"additionalProperties": false,
+	}
+	data := map[string]interface{}{
+		"tier":  "bronze",
+		"kind":  "library",
+		"links": []interface{}{map[string]interface{}{"a/b": "ok@example.com"}, map[string]interface{}{"a/b": "nope"}},
+		"extra": true,
+	}
+	err := NewValidator().Validate(data, schema)
+	ve := GetValidationErrors(err)
+	if ve == nil {
+		t.Fatalf("Validate = %v, want validation errors", err)
+	}
+
+	want := map[string]ValidationError{
+		"required":                        {Path: "/owner"},
+		"enum":                            {Path: "/tier", Allowed: []interface{}{"gold", "silver, plus", 3.0}},
+		"const":                           {Path: "/kind", Allowed: []interface{}{"service"}},
+		"format":                          {Path: "/links/1/a~1b", Format: "email"},
+		"additional_property_not_allowed": {Path: "/extra"},
+	}
+	got := map[string]ValidationError{}
+	for _, e := range ve.Errors {
+		if e.Message == "" {
+			t.Errorf("%s error without a message", e.Type)
+		}
+		got[e.Type] = ValidationError{Path: e.Path, Allowed: e.Allowed, Format: e.Format}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("errors = %+v\nwant %+v", got, want)
+	}
+}
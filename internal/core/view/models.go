@@ -0,0 +1,78 @@
+package view
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+// View is a saved search over one blueprint's entities
+type View struct {
+	ID          uuid.UUID             `json:"id"`
+	TeamID      uuid.UUID             `json:"team_id"`
+	BlueprintID string                `json:"blueprint_id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description,omitempty"`
+	Filters     []entity.SearchFilter `json:"filters"`
+	OrderBy     string                `json:"order_by,omitempty"`
+	OrderDir    string                `json:"order_dir,omitempty"`
+	Columns     []string              `json:"columns"`
+	Shared      bool                  `json:"shared"`
+	IsDefault   bool                  `json:"is_default"`
+	CreatedBy   *uuid.UUID            `json:"created_by,omitempty"`
+	CreatedAt   time.Time             `json:"created_at"`
+	UpdatedAt   time.Time             `json:"updated_at"`
+}
+
+// SearchRequest returns the view's search for one page
+func (v *View) SearchRequest(limit, offset int) *entity.SearchRequest {
+	return &entity.SearchRequest{
+		Filters:  v.Filters,
+		OrderBy:  v.OrderBy,
+		OrderDir: v.OrderDir,
+		Limit:    limit,
+		Offset:   offset,
+	}
+}
+
+// Applied describes the view in list responses
+func (v *View) Applied() *entity.AppliedView {
+	return &entity.AppliedView{ID: v.ID, Name: v.Name, Columns: v.Columns}
+}
+
+type CreateViewRequest struct {
+	Name        string                `json:"name" binding:"required,max=100"`
+	Description string                `json:"description"`
+	Filters     []entity.SearchFilter `json:"filters"`
+	OrderBy     string                `json:"order_by"`
+	OrderDir    string                `json:"order_dir"`
+	Columns     []string              `json:"columns"`
+	Shared      bool                  `json:"shared"`
+	IsDefault   bool                  `json:"is_default"`
+}
+
+// UpdateViewRequest changes the fields that are set
+type UpdateViewRequest struct {
+	Name        *string                `json:"name" binding:"omitempty,max=100"`
+	Description *string                `json:"description"`
+	Filters     *[]entity.SearchFilter `json:"filters"`
+	OrderBy     *string                `json:"order_by"`
+	OrderDir    *string                `json:"order_dir"`
+	Columns     *[]string              `json:"columns"`
+	Shared      *bool                  `json:"shared"`
+	IsDefault   *bool                  `json:"is_default"`
+}
+
+type ListViewsResponse struct {
+	Views []*View `json:"views"`
+	Total int     `json:"total"`
+}
+
+// Viewer is the caller reading or changing views
+type Viewer struct {
+	UserID *uuid.UUID // nil for API keys without a user
+	// Curator may set the default view and edit or delete any shared view (blueprint:write)
+	Curator bool
+}
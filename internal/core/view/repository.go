@@ -0,0 +1,192 @@
+package view
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const viewColumns = `id, team_id, blueprint_id, name, description, filters, order_by, order_dir, columns, shared, is_default, created_by, created_at, updated_at`
+
+// Create inserts a view. A default view replaces the blueprint's previous default.
+func (r *Repository) Create(ctx context.Context, v *View) error {
+	filters, columns, err := marshalView(v)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if v.IsDefault {
+		if err := clearDefault(ctx, tx, v); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO entity_views (id, team_id, blueprint_id, name, description, filters, order_by, order_dir, columns, shared, is_default, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING created_at, updated_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		v.ID, v.TeamID, v.BlueprintID, v.Name, v.Description, filters, v.OrderBy, v.OrderDir, columns, v.Shared, v.IsDefault, v.CreatedBy,
+	).Scan(&v.CreatedAt, &v.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) GetByID(ctx context.Context, teamID uuid.UUID, blueprintID string, id uuid.UUID) (*View, error) {
+	query := `SELECT ` + viewColumns + ` FROM entity_views WHERE team_id = $1 AND blueprint_id = $2 AND id = $3`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, blueprintID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views, err := scanViews(rows)
+	if err != nil || len(views) == 0 {
+		return nil, err
+	}
+	return views[0], nil
+}
+
+// GetDefault returns the blueprint's default view, or nil
+func (r *Repository) GetDefault(ctx context.Context, teamID uuid.UUID, blueprintID string) (*View, error) {
+	query := `SELECT ` + viewColumns + ` FROM entity_views WHERE team_id = $1 AND blueprint_id = $2 AND is_default`
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, blueprintID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	views, err := scanViews(rows)
+	if err != nil || len(views) == 0 {
+		return nil, err
+	}
+	return views[0], nil
+}
+
+// List returns the shared views of a blueprint and the private views of userID
+func (r *Repository) List(ctx context.Context, teamID uuid.UUID, blueprintID string, userID *uuid.UUID) ([]*View, error) {
+	query := `
+		SELECT ` + viewColumns + `
+		FROM entity_views
+		WHERE team_id = $1 AND blueprint_id = $2 AND (shared OR created_by = $3)
+		ORDER BY is_default DESC, name`
+
+	rows, err := r.db.Reader(ctx).QueryContext(ctx, query, teamID, blueprintID, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanViews(rows)
+}
+
+// Update saves a view. A default view replaces the blueprint's previous default.
+func (r *Repository) Update(ctx context.Context, v *View) error {
+	filters, columns, err := marshalView(v)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if v.IsDefault {
+		if err := clearDefault(ctx, tx, v); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		UPDATE entity_views
+		SET name = $2, description = $3, filters = $4, order_by = $5, order_dir = $6, columns = $7,
+		    shared = $8, is_default = $9, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING updated_at`
+
+	err = tx.QueryRowContext(ctx, query,
+		v.ID, v.Name, v.Description, filters, v.OrderBy, v.OrderDir, columns, v.Shared, v.IsDefault,
+	).Scan(&v.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *Repository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.DB.ExecContext(ctx, `DELETE FROM entity_views WHERE id = $1`, id)
+	return err
+}
+
+func clearDefault(ctx context.Context, tx *sql.Tx, v *View) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE entity_views SET is_default = FALSE WHERE team_id = $1 AND blueprint_id = $2 AND is_default AND id <> $3`,
+		v.TeamID, v.BlueprintID, v.ID)
+	return err
+}
+
+func marshalView(v *View) ([]byte, []byte, error) {
+	filters, err := json.Marshal(v.Filters)
+	if err != nil {
+		return nil, nil, err
+	}
+	columns, err := json.Marshal(v.Columns)
+	if err != nil {
+		return nil, nil, err
+	}
+	return filters, columns, nil
+}
+
+func scanViews(rows *sql.Rows) ([]*View, error) {
+	var views []*View
+	for rows.Next() {
+		v := &View{}
+		var description, orderBy, orderDir sql.NullString
+		var filters, columns []byte
+		var createdBy uuid.NullUUID
+
+		if err := rows.Scan(
+			&v.ID, &v.TeamID, &v.BlueprintID, &v.Name, &description, &filters, &orderBy, &orderDir,
+			&columns, &v.Shared, &v.IsDefault, &createdBy, &v.CreatedAt, &v.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+
+		v.Description = description.String
+		v.OrderBy = orderBy.String
+		v.OrderDir = orderDir.String
+		if createdBy.Valid {
+			v.CreatedBy = &createdBy.UUID
+		}
+		if err := json.Unmarshal(filters, &v.Filters); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(columns, &v.Columns); err != nil {
+			return nil, err
+		}
+		views = append(views, v)
+	}
+	return views, rows.Err()
+}
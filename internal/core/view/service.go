@@ -0,0 +1,199 @@
+package view
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/entity"
+)
+
+var (
+	ErrNotFound          = errors.New("view not found")
+	ErrForbidden         = errors.New("not allowed to change this view")
+	ErrInvalidView       = errors.New("invalid view")
+	ErrBlueprintNotFound = errors.New("blueprint not found")
+)
+
+// maxColumns bounds the column selection of a view
+const maxColumns = 50
+
+type Service struct {
+	repo         *Repository
+	blueprintSvc *blueprint.Service
+}
+
+func NewService(repo *Repository, blueprintSvc *blueprint.Service) *Service {
+	return &Service{repo: repo, blueprintSvc: blueprintSvc}
+}
+
+// List returns the views the viewer can see: shared views and their own
+func (s *Service) List(ctx context.Context, teamID uuid.UUID, blueprintID string, viewer Viewer) (*ListViewsResponse, error) {
+	views, err := s.repo.List(ctx, teamID, blueprintID, viewer.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if views == nil {
+		views = []*View{}
+	}
+	return &ListViewsResponse{Views: views, Total: len(views)}, nil
+}
+
+// Get returns a view the viewer can see
+func (s *Service) Get(ctx context.Context, teamID uuid.UUID, blueprintID string, id uuid.UUID, viewer Viewer) (*View, error) {
+	v, err := s.repo.GetByID(ctx, teamID, blueprintID, id)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil || !canSee(v, viewer) {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+// Default returns the blueprint's default view, or nil if it has none
+func (s *Service) Default(ctx context.Context, teamID uuid.UUID, blueprintID string) (*View, error) {
+	return s.repo.GetDefault(ctx, teamID, blueprintID)
+}
+
+func (s *Service) Create(ctx context.Context, teamID uuid.UUID, blueprintID string, viewer Viewer, req *CreateViewRequest) (*View, error) {
+	v := &View{
+		ID:          uuid.New(),
+		TeamID:      teamID,
+		BlueprintID: blueprintID,
+		Name:        req.Name,
+		Description: req.Description,
+		Filters:     req.Filters,
+		OrderBy:     req.OrderBy,
+		OrderDir:    req.OrderDir,
+		Columns:     req.Columns,
+		Shared:      req.Shared,
+		IsDefault:   req.IsDefault,
+		CreatedBy:   viewer.UserID,
+	}
+	if err := s.validate(ctx, v, viewer, false); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Create(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *Service) Update(ctx context.Context, teamID uuid.UUID, blueprintID string, id uuid.UUID, viewer Viewer, req *UpdateViewRequest) (*View, error) {
+	v, err := s.Get(ctx, teamID, blueprintID, id, viewer)
+	if err != nil {
+		return nil, err
+	}
+	if !canModify(v, viewer) || (v.IsDefault && !viewer.Curator) {
+		// The default view shapes everyone's entity lists
+		return nil, ErrForbidden
+	}
+	wasDefault := v.IsDefault
+
+	if req.Name != nil {
+		v.Name = *req.Name
+	}
+	if req.Description != nil {
+		v.Description = *req.Description
+	}
+	if req.Filters != nil {
+		v.Filters = *req.Filters
+	}
+	if req.OrderBy != nil {
+		v.OrderBy = *req.OrderBy
+	}
+	if req.OrderDir != nil {
+		v.OrderDir = *req.OrderDir
+	}
+	if req.Columns != nil {
+		v.Columns = *req.Columns
+	}
+	if req.Shared != nil {
+		v.Shared = *req.Shared
+	}
+	if req.IsDefault != nil {
+		v.IsDefault = *req.IsDefault
+	}
+
+	if err := s.validate(ctx, v, viewer, wasDefault); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *Service) Delete(ctx context.Context, teamID uuid.UUID, blueprintID string, id uuid.UUID, viewer Viewer) error {
+	v, err := s.Get(ctx, teamID, blueprintID, id, viewer)
+	if err != nil {
+		return err
+	}
+	if !canModify(v, viewer) || (v.IsDefault && !viewer.Curator) {
+		return ErrForbidden
+	}
+	return s.repo.Delete(ctx, v.ID)
+}
+
+// validate checks a view against the blueprint schema and the viewer's rights.
+// wasDefault is true when an existing default view is being updated.
+func (s *Service) validate(ctx context.Context, v *View, viewer Viewer, wasDefault bool) error {
+	if v.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidView)
+	}
+	if v.IsDefault && !v.Shared {
+		return fmt.Errorf("%w: the default view must be shared", ErrInvalidView)
+	}
+	if !v.Shared && v.CreatedBy == nil {
+		return fmt.Errorf("%w: API keys without a user can only create shared views", ErrInvalidView)
+	}
+	if v.IsDefault != wasDefault && !viewer.Curator {
+		return fmt.Errorf("%w: changing the default view requires blueprint:write", ErrForbidden)
+	}
+	if len(v.Columns) > maxColumns {
+		return fmt.Errorf("%w: at most %d columns are allowed", ErrInvalidView, maxColumns)
+	}
+	if v.Filters == nil {
+		v.Filters = []entity.SearchFilter{}
+	}
+	if v.Columns == nil {
+		v.Columns = []string{}
+	}
+
+	bp, err := s.blueprintSvc.Get(ctx, v.TeamID, v.BlueprintID)
+	if err != nil {
+		if errors.Is(err, blueprint.ErrNotFound) {
+			return ErrBlueprintNotFound
+		}
+		return err
+	}
+	fc := entity.NewFilterCompiler(bp.Schema)
+	if err := fc.Validate(v.Filters, v.OrderBy, v.OrderDir); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidView, err)
+	}
+	for _, column := range v.Columns {
+		if err := fc.Column(column); err != nil {
+			return fmt.Errorf("%w: column: %v", ErrInvalidView, err)
+		}
+	}
+	return nil
+}
+
+// canSee reports whether the viewer may read a view
+func canSee(v *View, viewer Viewer) bool {
+	return v.Shared || isOwner(v, viewer)
+}
+
+// canModify reports whether the viewer may edit or delete a view: owners can
+// change their own views, curators any shared view
+func canModify(v *View, viewer Viewer) bool {
+	return isOwner(v, viewer) || (v.Shared && viewer.Curator)
+}
+
+func isOwner(v *View, viewer Viewer) bool {
+	return v.CreatedBy != nil && viewer.UserID != nil && *v.CreatedBy == *viewer.UserID
+}
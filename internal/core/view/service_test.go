@@ -0,0 +1,35 @@
+package view
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestViewAccess(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+
+	tests := []struct {
+		name             string
+		view             View
+		viewer           Viewer
+		canSee, canWrite bool
+	}{
+		{"owner of private view", View{CreatedBy: &owner}, Viewer{UserID: &owner}, true, true},
+		{"other user on private view", View{CreatedBy: &owner}, Viewer{UserID: &other}, false, false},
+		{"curator on private view", View{CreatedBy: &owner}, Viewer{UserID: &other, Curator: true}, false, false},
+		{"other user on shared view", View{CreatedBy: &owner, Shared: true}, Viewer{UserID: &other}, true, false},
+		{"curator on shared view", View{CreatedBy: &owner, Shared: true}, Viewer{UserID: &other, Curator: true}, true, true},
+		{"API key on shared view without owner", View{Shared: true}, Viewer{}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := canSee(&tt.view, tt.viewer); got != tt.canSee {
+				t.Errorf("canSee = %v, want %v", got, tt.canSee)
+			}
+			if got := canModify(&tt.view, tt.viewer); got != tt.canWrite {
+				t.Errorf("canModify = %v, want %v", got, tt.canWrite)
+			}
+		})
+	}
+}
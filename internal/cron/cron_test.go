@@ -0,0 +1,65 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * foo *",
+	} {
+		if _, err := Parse(expr); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalid", expr, err)
+		}
+	}
+}
+
+func TestExpression_Next(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone data")
+	}
+	// A Sunday
+	from := time.Date(2026, 3, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		{"* * * * *", from, time.Date(2026, 3, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", from, time.Date(2026, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"0 9 * * *", from, time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)},
+		{"@hourly", from, time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * mon-fri", from, time.Date(2026, 3, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", from, time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", from, time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", from, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{"0 12 15 * fri", from, time.Date(2026, 3, 6, 12, 0, 0, 0, time.UTC)},
+		{"0 0 31 2 *", from, time.Time{}},
+		// Matching happens in the location of the given time
+		{"0 9 * * *", from.In(berlin), time.Date(2026, 3, 2, 9, 0, 0, 0, berlin)},
+	}
+	for _, tt := range tests {
+		c, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", tt.expr, err)
+		}
+		if got := c.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q.Next(%v) = %v, want %v", tt.expr, tt.from, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Severity string
+
+const (
+	SeverityOK   Severity = "ok"
+	SeverityWarn Severity = "warn"
+	SeverityFail Severity = "fail"
+)
+
+// Finding is the result of a single diagnostic check
+type Finding struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Hint     string   `json:"hint,omitempty"`
+}
+
+const (
+	// MinJWTSecretEntropyBits is the estimated entropy below which a secret is considered guessable
+	MinJWTSecretEntropyBits = 128
+
+	// MinPostgresVersion is the oldest supported server_version_num
+	MinPostgresVersion = 130000
+
+	clockSkewWarn = 5 * time.Second
+	clockSkewFail = 60 * time.Second
+)
+
+var weakSecrets = map[string]bool{
+	"secret":          true,
+	"changeme":        true,
+	"password":        true,
+	"your-secret-key": true,
+	"jwt-secret":      true,
+}
+
+func ok(check, msg string) Finding {
+	return Finding{Check: check, Severity: SeverityOK, Message: msg}
+}
+
+func warn(check, msg, hint string) Finding {
+	return Finding{Check: check, Severity: SeverityWarn, Message: msg, Hint: hint}
+}
+
+func fail(check, msg, hint string) Finding {
+	return Finding{Check: check, Severity: SeverityFail, Message: msg, Hint: hint}
+}
+
+// HasFailures reports whether any finding is a failure
+func HasFailures(findings []Finding) bool {
+	for _, f := range findings {
+		if f.Severity == SeverityFail {
+			return true
+		}
+	}
+	return false
+}
+
+// EstimateEntropyBits returns a Shannon-entropy based estimate of the total entropy of s in bits.
+// It is a heuristic: repeated or low-variety secrets score low even if they are long.
+func EstimateEntropyBits(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	freq := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		freq[r]++
+		total++
+	}
+	var perChar float64
+	for _, n := range freq {
+		p := float64(n) / float64(total)
+		perChar -= p * math.Log2(p)
+	}
+	return perChar * float64(total)
+}
+
+// CheckJWTSecret verifies the JWT signing secret is present, long enough and not trivially guessable
+func CheckJWTSecret(secret string) Finding {
+	const check = "jwt.secret"
+	const hint = "generate a secret with: openssl rand -base64 48"
+
+	if secret == "" {
+		return fail(check, "JWT_SECRET is not set", hint)
+	}
+	if weakSecrets[strings.ToLower(secret)] {
+		return fail(check, "JWT_SECRET is a well-known placeholder value", hint)
+	}
+	if len(secret) < config.MinJWTSecretLength {
+		return fail(check, fmt.Sprintf("JWT_SECRET is %d bytes; at least %d are required", len(secret), config.MinJWTSecretLength), hint)
+	}
+	if bits := EstimateEntropyBits(secret); bits < MinJWTSecretEntropyBits {
+		return warn(check, fmt.Sprintf("JWT_SECRET has low estimated entropy (%.0f bits)", bits), hint)
+	}
+	return ok(check, fmt.Sprintf("JWT_SECRET is %d bytes", len(secret)))
+}
+
+// CheckConfig performs static sanity checks on the loaded configuration.
+// Fields rejected by Config.Validate are reported as failures; the remaining
+// checks flag values that are valid but risky in production.
+func CheckConfig(cfg *config.Config) []Finding {
+	var findings []Finding
+
+	var verr *config.ValidationError
+	if err := cfg.Validate(); errors.As(err, &verr) {
+		for _, f := range verr.Fields {
+			if f.Field == "jwt.secret" {
+				// Reported in more detail by CheckJWTSecret
+				continue
+			}
+			findings = append(findings, fail("config."+f.Field, f.Message, "set "+f.Env))
+		}
+	}
+
+	switch cfg.Server.Mode {
+	case "release":
+		findings = append(findings, ok("config.gin_mode", "GIN_MODE is release"))
+	case "debug", "test":
+		findings = append(findings, warn("config.gin_mode", fmt.Sprintf("GIN_MODE is %q", cfg.Server.Mode), "set GIN_MODE=release in production"))
+	}
+
+	if cfg.Database.Password == "password" {
+		findings = append(findings, warn("config.db_password", "DB_PASSWORD uses the development default", "set a strong DB_PASSWORD"))
+	}
+
+	local := cfg.Database.Host == "localhost" || cfg.Database.Host == "127.0.0.1" || cfg.Database.Host == "::1"
+	if cfg.Database.SSLMode == "disable" && !local {
+		findings = append(findings, warn("config.db_ssl_mode", fmt.Sprintf("TLS is disabled for remote database host %s", cfg.Database.Host), "set DB_SSL_MODE=require or verify-full"))
+	}
+
+	if cfg.JWT.ExpirationHours > 168 {
+		findings = append(findings, warn("config.jwt_expiration", fmt.Sprintf("tokens live for %d hours", cfg.JWT.ExpirationHours), "keep JWT_EXPIRATION_HOURS at or below 168 (one week)"))
+	}
+
+	return findings
+}
+
+// CheckDatabase verifies connectivity and the server version
+func CheckDatabase(ctx context.Context, db *postgres.Client) []Finding {
+	if err := db.DB.PingContext(ctx); err != nil {
+		return []Finding{fail("database.connectivity", fmt.Sprintf("cannot reach database: %v", err), "check DB_HOST, DB_PORT and credentials")}
+	}
+	findings := []Finding{ok("database.connectivity", "database reachable")}
+
+	var versionNum string
+	if err := db.DB.QueryRowContext(ctx, "SHOW server_version_num").Scan(&versionNum); err != nil {
+		return append(findings, warn("database.version", fmt.Sprintf("cannot read server version: %v", err), ""))
+	}
+	version, _ := strconv.Atoi(versionNum)
+	if version < MinPostgresVersion {
+		return append(findings, fail("database.version", fmt.Sprintf("PostgreSQL %s is older than the supported minimum", versionNum), "upgrade to PostgreSQL 13 or newer"))
+	}
+	return append(findings, ok("database.version", fmt.Sprintf("PostgreSQL %d.%d", version/10000, version%10000)))
+}
+
+// CheckExtensions verifies required PostgreSQL extensions are installed
+func CheckExtensions(ctx context.Context, db *postgres.Client) Finding {
+	missing, err := db.MissingExtensions(ctx)
+	if err != nil {
+		return fail("database.extensions", fmt.Sprintf("cannot list extensions: %v", err), "")
+	}
+	if len(missing) > 0 {
+		return fail("database.extensions", "missing extensions: "+strings.Join(missing, ", "),
+			fmt.Sprintf("run: CREATE EXTENSION IF NOT EXISTS \"%s\";", missing[0]))
+	}
+	return ok("database.extensions", "required extensions installed")
+}
+
+// CheckMigrations verifies every known migration has been applied
+func CheckMigrations(ctx context.Context, db *postgres.Client) Finding {
+	states, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return fail("database.migrations", fmt.Sprintf("cannot determine migration status: %v", err), "")
+	}
+	var pending []string
+	latest := ""
+	for _, s := range states {
+		if s.Applied {
+			latest = s.Version
+			continue
+		}
+		pending = append(pending, s.Version+"_"+s.Name)
+	}
+	if len(pending) > 0 {
+		return fail("database.migrations", "pending migrations: "+strings.Join(pending, ", "),
+			"apply them with: psql -f migrations/<version>_<name>.sql")
+	}
+	return ok("database.migrations", "schema at migration "+latest)
+}
+
+// CheckIndexes verifies indexes that hot query paths depend on
+func CheckIndexes(ctx context.Context, db *postgres.Client) Finding {
+	missing, err := db.MissingIndexes(ctx)
+	if err != nil {
+		return fail("database.indexes", fmt.Sprintf("cannot list indexes: %v", err), "")
+	}
+	if len(missing) > 0 {
+		return warn("database.indexes", "missing indexes: "+strings.Join(missing, ", "),
+			"re-run the migrations that create them; searches and auth lookups will be slow")
+	}
+	return ok("database.indexes", "required indexes present")
+}
+
+// CheckClockSkew compares the local clock with the database clock. JWT expiry and
+// audit timestamps assume both agree.
+func CheckClockSkew(ctx context.Context, db *postgres.Client) Finding {
+	const check = "clock.skew"
+
+	before := time.Now()
+	var dbNow time.Time
+	if err := db.DB.QueryRowContext(ctx, "SELECT now()").Scan(&dbNow); err != nil {
+		return warn(check, fmt.Sprintf("cannot read database time: %v", err), "")
+	}
+	after := time.Now()
+
+	// Compare against the midpoint of the round trip
+	local := before.Add(after.Sub(before) / 2)
+	skew := local.Sub(dbNow)
+	if skew < 0 {
+		skew = -skew
+	}
+
+	msg := fmt.Sprintf("clock differs from database by %s", skew.Round(time.Millisecond))
+	hint := "enable NTP (chrony or systemd-timesyncd) on the application and database hosts"
+	switch {
+	case skew >= clockSkewFail:
+		return fail(check, msg, hint)
+	case skew >= clockSkewWarn:
+		return warn(check, msg, hint)
+	}
+	return ok(check, msg)
+}
+
+// RunAll executes every check. Database checks are skipped when no client is available.
+func RunAll(ctx context.Context, cfg *config.Config, db *postgres.Client) []Finding {
+	findings := []Finding{CheckJWTSecret(cfg.JWT.Secret)}
+	findings = append(findings, CheckConfig(cfg)...)
+
+	if db == nil {
+		return findings
+	}
+
+	dbFindings := CheckDatabase(ctx, db)
+	findings = append(findings, dbFindings...)
+	if HasFailures(dbFindings[:1]) {
+		return findings
+	}
+
+	findings = append(findings,
+		CheckExtensions(ctx, db),
+		CheckMigrations(ctx, db),
+		CheckIndexes(ctx, db),
+		CheckClockSkew(ctx, db),
+	)
+	return findings
+}
+
+// Startup runs the checks the server performs before accepting traffic. In
+// release mode a low-entropy JWT secret is a failure rather than a warning,
+// since a guessable secret lets anyone mint tokens.
+func Startup(ctx context.Context, cfg *config.Config, db *postgres.Client) []Finding {
+	findings := RunAll(ctx, cfg, db)
+	if cfg.Server.Mode != "release" {
+		return findings
+	}
+	for i, f := range findings {
+		if f.Check == "jwt.secret" && f.Severity == SeverityWarn {
+			findings[i].Severity = SeverityFail
+		}
+	}
+	return findings
+}
+
+// Summarize lists the findings of the given severity, one per line, with
+// their hints
+func Summarize(findings []Finding, severity Severity) string {
+	var b strings.Builder
+	for _, f := range findings {
+		if f.Severity != severity {
+			continue
+		}
+		fmt.Fprintf(&b, "\n  %s: %s", f.Check, f.Message)
+		if f.Hint != "" {
+			fmt.Fprintf(&b, " (%s)", f.Hint)
+		}
+	}
+	return b.String()
+}
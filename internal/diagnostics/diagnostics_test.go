@@ -0,0 +1,116 @@
+package diagnostics
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+func TestCheckJWTSecret(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		expected Severity
+	}{
+		{"empty", "", SeverityFail},
+		{"placeholder", "your-secret-key", SeverityFail},
+		{"short", "abc123", SeverityFail},
+		{"long but repetitive", strings.Repeat("a", 64), SeverityWarn},
+		{"strong", "q8Vx2LmN0pRt7YzKb4Wc9HsJd3Fg6Ae1Uo5Ii8Pl2Mn7Bv0Cx", SeverityOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := CheckJWTSecret(tt.secret)
+			if f.Severity != tt.expected {
+				t.Errorf("expected %s, got %s (%s)", tt.expected, f.Severity, f.Message)
+			}
+		})
+	}
+}
+
+func TestEstimateEntropyBits(t *testing.T) {
+	if bits := EstimateEntropyBits(""); bits != 0 {
+		t.Errorf("expected 0 bits for empty string, got %f", bits)
+	}
+	if bits := EstimateEntropyBits("aaaa"); bits != 0 {
+		t.Errorf("expected 0 bits for a single repeated character, got %f", bits)
+	}
+	if EstimateEntropyBits("abcdefgh") <= EstimateEntropyBits("aabbaabb") {
+		t.Error("more varied strings should score higher")
+	}
+}
+
+func TestCheckConfig_InvalidPortAndMode(t *testing.T) {
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Port: "http", Mode: "production"},
+		Database: config.DatabaseConfig{Host: "localhost", Port: "5432", User: "user", DBName: "baseplate", SSLMode: "disable"},
+		JWT:      config.JWTConfig{ExpirationHours: 24},
+	}
+
+	findings := CheckConfig(cfg)
+	failed := map[string]bool{}
+	for _, f := range findings {
+		if f.Severity == SeverityFail {
+			failed[f.Check] = true
+		}
+	}
+
+	if !failed["config.server.port"] {
+		t.Error("expected invalid port to fail")
+	}
+	if !failed["config.server.mode"] {
+		t.Error("expected invalid gin mode to fail")
+	}
+}
+
+func TestCheckConfig_RemoteHostWithoutTLS(t *testing.T) {
+	cfg := &config.Config{
+		Server:   config.ServerConfig{Port: "8080", Mode: "release"},
+		Database: config.DatabaseConfig{Host: "db.internal", SSLMode: "disable"},
+		JWT:      config.JWTConfig{ExpirationHours: 24},
+	}
+
+	for _, f := range CheckConfig(cfg) {
+		if f.Check == "config.db_ssl_mode" && f.Severity == SeverityWarn {
+			return
+		}
+	}
+	t.Error("expected a TLS warning for a remote database host")
+}
+
+func TestStartup_ReleaseModeRejectsWeakSecret(t *testing.T) {
+	cfg := config.Defaults()
+	cfg.JWT.Secret = strings.Repeat("a", 64)
+
+	cfg.Server.Mode = "debug"
+	if HasFailures(Startup(context.Background(), cfg, nil)) {
+		t.Error("expected a low-entropy secret to only warn in debug mode")
+	}
+
+	cfg.Server.Mode = "release"
+	if !HasFailures(Startup(context.Background(), cfg, nil)) {
+		t.Error("expected a low-entropy secret to fail in release mode")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	findings := []Finding{
+		ok("database.connectivity", "database reachable"),
+		fail("database.migrations", "pending migrations: 005_entity_versions", "apply them"),
+		warn("database.indexes", "missing indexes: idx_a", ""),
+		fail("database.extensions", "missing extensions: pg_trgm", ""),
+	}
+
+	got := Summarize(findings, SeverityFail)
+	want := "\n  database.migrations: pending migrations: 005_entity_versions (apply them)" +
+		"\n  database.extensions: missing extensions: pg_trgm"
+	if got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+	if Summarize(findings[:1], SeverityFail) != "" {
+		t.Error("expected no output without failures")
+	}
+}
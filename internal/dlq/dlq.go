@@ -0,0 +1,78 @@
+// Package dlq summarises the dead letters of the platform: background jobs
+// and outbox events whose attempts are exhausted. They are kept until a
+// super admin retries or discards them, and alert once they are left too long.
+package dlq
+
+import (
+	"context"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/jobs"
+	"github.com/baseplate/baseplate/internal/outbox"
+)
+
+// Backlog is the dead letters of one source
+type Backlog struct {
+	Dead int `json:"dead"`
+	// OldestDeadAt is when the longest-dead item died
+	OldestDeadAt *time.Time `json:"oldest_dead_at,omitempty"`
+	// Alerting reports that the oldest item is dead for longer than the threshold
+	Alerting bool `json:"alerting"`
+}
+
+type Summary struct {
+	Jobs   Backlog `json:"jobs"`
+	Events Backlog `json:"events"`
+	// AlertAfterHours is the threshold; 0 means age alerts are off
+	AlertAfterHours int  `json:"alert_after_hours"`
+	Alerting        bool `json:"alerting"`
+}
+
+type Service struct {
+	queue      *jobs.Queue
+	dispatcher *outbox.Dispatcher
+	alertAfter time.Duration
+	now        func() time.Time
+}
+
+// NewService creates the service; an alertAfter of 0 turns age alerts off
+func NewService(queue *jobs.Queue, dispatcher *outbox.Dispatcher, alertAfter time.Duration) *Service {
+	return &Service{queue: queue, dispatcher: dispatcher, alertAfter: alertAfter, now: time.Now}
+}
+
+// Summary counts the dead jobs and events and reports whether any have been
+// dead for longer than the alert threshold
+func (s *Service) Summary(ctx context.Context) (*Summary, error) {
+	jobCounts, err := s.queue.Counts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	oldestJob, err := s.queue.OldestDead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	eventCounts, err := s.dispatcher.Counts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	oldestEvent, err := s.dispatcher.OldestDead(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{
+		Jobs:            s.backlog(jobCounts[jobs.StatusDead], oldestJob),
+		Events:          s.backlog(eventCounts[outbox.StatusDead], oldestEvent),
+		AlertAfterHours: int(s.alertAfter / time.Hour),
+	}
+	summary.Alerting = summary.Jobs.Alerting || summary.Events.Alerting
+	return summary, nil
+}
+
+func (s *Service) backlog(dead int, oldest *time.Time) Backlog {
+	return Backlog{
+		Dead:         dead,
+		OldestDeadAt: oldest,
+		Alerting:     s.alertAfter > 0 && oldest != nil && s.now().Sub(*oldest) > s.alertAfter,
+	}
+}
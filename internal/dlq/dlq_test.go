@@ -0,0 +1,30 @@
+package dlq
+
+import (
+	"testing"
+	"time"
+)
+
+func TestService_Backlog(t *testing.T) {
+	now := time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-time.Hour)
+	old := now.Add(-25 * time.Hour)
+
+	tests := []struct {
+		name       string
+		alertAfter time.Duration
+		oldest     *time.Time
+		want       bool
+	}{
+		{"nothing dead", 24 * time.Hour, nil, false},
+		{"dead within the threshold", 24 * time.Hour, &recent, false},
+		{"dead past the threshold", 24 * time.Hour, &old, true},
+		{"alerts off", 0, &old, false},
+	}
+	for _, tt := range tests {
+		s := &Service{alertAfter: tt.alertAfter, now: func() time.Time { return now }}
+		if got := s.backlog(1, tt.oldest); got.Alerting != tt.want {
+			t.Errorf("%s: alerting = %v, want %v", tt.name, got.Alerting, tt.want)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Package events is an in-process publish/subscribe bus for domain events.
+// Delivery is synchronous: Publish returns after every matching handler ran,
+// so subscribers such as caches observe a write before the request completes.
+package events
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+// Event types
+const (
+	EntityCreated    = "entity.created"
+	EntityUpdated    = "entity.updated"
+	EntityDeleted    = "entity.deleted"
+	BlueprintCreated = "blueprint.created"
+	BlueprintUpdated = "blueprint.updated"
+	BlueprintDeleted = "blueprint.deleted"
+
+	// Published after the entity.deleted or entity.updated event of an entity
+	// removed or archived by its blueprint's expiry policy; the payload is
+	// the expiration
+	EntityExpired = "entity.expired"
+
+	// Published after the entity.updated event of a rename; the payload is
+	// the rename
+	EntityRenamed = "entity.renamed"
+
+	// Access changes; team payloads are the team, membership payloads the
+	// membership, role payloads the role
+	TeamUpdated       = "team.updated"
+	TeamDeleted       = "team.deleted"
+	RoleUpdated       = "role.updated"
+	RoleDeleted       = "role.deleted"
+	MembershipCreated = "membership.created"
+	MembershipUpdated = "membership.updated"
+	MembershipDeleted = "membership.deleted"
+)
+
+// Event describes a change to a team's catalog or to who can access it
+type Event struct {
+	ID          uuid.UUID   `json:"id"`
+	Type        string      `json:"type"`
+	TeamID      uuid.UUID   `json:"team_id"`
+	BlueprintID string      `json:"blueprint_id,omitempty"`
+	EntityID    *uuid.UUID  `json:"entity_id,omitempty"`
+	Payload     interface{} `json:"payload,omitempty"`
+	Previous    interface{} `json:"previous,omitempty"` // state before an update
+	Actor       *Actor      `json:"actor,omitempty"`
+	OccurredAt  time.Time   `json:"occurred_at"`
+}
+
+// Actor identifies who made a change: a user, or an API key and the user who
+// created it. IntegrationID is set when the change is an integration's sync
+// made with those credentials.
+type Actor struct {
+	UserID        *uuid.UUID `json:"user_id,omitempty"`
+	APIKeyID      *uuid.UUID `json:"api_key_id,omitempty"`
+	IntegrationID *uuid.UUID `json:"integration_id,omitempty"`
+}
+
+// WithActor returns ctx with the actor of its request context replaced
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return reqctx.Update(ctx, func(rc *reqctx.RequestContext) {
+		rc.UserID, rc.APIKeyID, rc.IntegrationID = actor.UserID, actor.APIKeyID, actor.IntegrationID
+		switch {
+		case actor.APIKeyID != nil:
+			rc.ActorType = reqctx.ActorAPIKey
+		case actor.UserID != nil:
+			rc.ActorType = reqctx.ActorUser
+		}
+	})
+}
+
+// ActorFrom returns the actor of the request context carried by ctx;
+// background work and unauthenticated requests have none
+func ActorFrom(ctx context.Context) (Actor, bool) {
+	rc, _ := reqctx.From(ctx)
+	if rc.UserID == nil && rc.APIKeyID == nil && rc.IntegrationID == nil {
+		return Actor{}, false
+	}
+	return Actor{UserID: rc.UserID, APIKeyID: rc.APIKeyID, IntegrationID: rc.IntegrationID}, true
+}
+
+// Handler processes an event. Handlers run on the publisher's goroutine and must be quick.
+type Handler func(ctx context.Context, e Event)
+
+type subscription struct {
+	id      uint64
+	pattern string
+	handler Handler
+}
+
+// Bus dispatches events to subscribers. A nil *Bus discards events.
+type Bus struct {
+	mu     sync.RWMutex
+	nextID uint64
+	subs   []subscription
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers h for events matching pattern: an exact type
+// ("entity.created"), a prefix wildcard ("entity.*") or "*" for everything.
+// The returned function removes the subscription.
+func (b *Bus) Subscribe(pattern string, h Handler) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.subs = append(b.subs, subscription{id: id, pattern: pattern, handler: h})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, s := range b.subs {
+			if s.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers e to every matching subscriber. ID, OccurredAt and the actor
+// from ctx are filled in when empty. A panicking handler is logged and does
+// not affect the others.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	if b == nil {
+		return
+	}
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now()
+	}
+	if actor, ok := ActorFrom(ctx); ok && e.Actor == nil {
+		e.Actor = &actor
+	}
+
+	b.mu.RLock()
+	var handlers []Handler
+	for _, s := range b.subs {
+		if Matches(s.pattern, e.Type) {
+			handlers = append(handlers, s.handler)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, h := range handlers {
+		deliver(ctx, h, e)
+	}
+}
+
+func deliver(ctx context.Context, h Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("ERROR: event handler for %s panicked: %v", e.Type, r)
+		}
+	}()
+	h(ctx, e)
+}
+
+// Matches reports whether an event type matches a subscription pattern
+func Matches(pattern, eventType string) bool {
+	if pattern == "*" || pattern == eventType {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(eventType, prefix)
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		pattern, eventType string
+		want               bool
+	}{
+		{"*", EntityCreated, true},
+		{EntityCreated, EntityCreated, true},
+		{EntityCreated, EntityUpdated, false},
+		{"entity.*", EntityDeleted, true},
+		{"entity.*", BlueprintCreated, false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.pattern, tt.eventType); got != tt.want {
+			t.Errorf("Matches(%q, %q) = %v, want %v", tt.pattern, tt.eventType, got, tt.want)
+		}
+	}
+}
+
+func TestBus_PublishAndUnsubscribe(t *testing.T) {
+	bus := NewBus()
+	var entityEvents, allEvents int
+	unsubscribe := bus.Subscribe("entity.*", func(ctx context.Context, e Event) { entityEvents++ })
+	bus.Subscribe("*", func(ctx context.Context, e Event) {
+		allEvents++
+		if e.ID == uuid.Nil || e.OccurredAt.IsZero() {
+			t.Error("Publish should fill in ID and OccurredAt")
+		}
+	})
+
+	bus.Publish(context.Background(), Event{Type: EntityCreated})
+	bus.Publish(context.Background(), Event{Type: BlueprintUpdated})
+	unsubscribe()
+	bus.Publish(context.Background(), Event{Type: EntityDeleted})
+
+	if entityEvents != 1 || allEvents != 3 {
+		t.Errorf("entityEvents = %d, allEvents = %d; want 1, 3", entityEvents, allEvents)
+	}
+}
+
+func TestBus_PanickingHandlerDoesNotStopOthers(t *testing.T) {
+	bus := NewBus()
+	delivered := false
+	bus.Subscribe("*", func(ctx context.Context, e Event) { panic("boom") })
+	bus.Subscribe("*", func(ctx context.Context, e Event) { delivered = true })
+
+	bus.Publish(context.Background(), Event{Type: EntityCreated})
+	if !delivered {
+		t.Error("second handler was not called")
+	}
+}
+
+func TestBus_NilDiscards(t *testing.T) {
+	var bus *Bus
+	bus.Publish(context.Background(), Event{Type: EntityCreated})
+}
+
+func TestActorFrom(t *testing.T) {
+	if _, ok := ActorFrom(context.Background()); ok {
+		t.Error("background context has an actor")
+	}
+	if _, ok := ActorFrom(reqctx.With(context.Background(), reqctx.RequestContext{RequestID: "req-1"})); ok {
+		t.Error("unauthenticated request has an actor")
+	}
+
+	userID, keyID := uuid.New(), uuid.New()
+	ctx := reqctx.With(context.Background(), reqctx.RequestContext{RequestID: "req-1"})
+	ctx = WithActor(ctx, Actor{UserID: &userID, APIKeyID: &keyID})
+	actor, ok := ActorFrom(ctx)
+	if !ok || *actor.UserID != userID || *actor.APIKeyID != keyID {
+		t.Errorf("ActorFrom = %+v, %v", actor, ok)
+	}
+	rc, _ := reqctx.From(ctx)
+	if rc.RequestID != "req-1" || rc.ActorType != reqctx.ActorAPIKey {
+		t.Errorf("request context = %+v", rc)
+	}
+}
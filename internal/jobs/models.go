@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound      = errors.New("job not found")
+	ErrNotDead       = errors.New("only dead jobs can be retried or discarded")
+	ErrInvalidStatus = errors.New("invalid status: must be pending, running, succeeded or dead")
+	ErrUnknownKind   = errors.New("no handler is registered for this job kind")
+)
+
+// Job statuses. Pending jobs wait for their run_at; a failed attempt makes
+// the job pending again until it runs out of attempts and is dead.
+const (
+	StatusPending   = "pending"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusDead      = "dead"
+)
+
+// Job is a unit of background work of a registered kind
+type Job struct {
+	ID             uuid.UUID       `json:"id"`
+	Kind           string          `json:"kind"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	MaxAttempts    int             `json:"max_attempts"`
+	RunAt          time.Time       `json:"run_at"`
+	LeaseExpiresAt *time.Time      `json:"lease_expires_at,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	FinishedAt     *time.Time      `json:"finished_at,omitempty"`
+}
+
+// EnqueueOptions change how a job is run; zero values use the defaults
+type EnqueueOptions struct {
+	// RunAt delays the first attempt
+	RunAt time.Time
+	// MaxAttempts overrides the queue's JOBS_MAX_ATTEMPTS
+	MaxAttempts int
+}
+
+type ListRequest struct {
+	Status string
+	Kind   string
+	Limit  int
+	Offset int
+}
+
+type ListResponse struct {
+	Jobs  []*Job `json:"jobs"`
+	Total int    `json:"total"`
+	// Counts are the jobs of every kind by status
+	Counts map[string]int `json:"counts"`
+	Limit  int            `json:"limit"`
+	Offset int            `json:"offset"`
+}
@@ -0,0 +1,417 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/reqctx"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+const (
+	// Lease is how long a worker holds a job. A handler's context ends when
+	// the lease does; a job still running after that may run twice. Kinds
+	// registered with RegisterLong renew their lease instead.
+	Lease = 5 * time.Minute
+
+	// renewInterval is how often the lease of a long job is renewed
+	renewInterval = Lease / 3
+
+	// retryBase and retryMax bound the exponential backoff between attempts
+	retryBase = 10 * time.Second
+	retryMax  = time.Hour
+
+	// pruneInterval is how often succeeded jobs past retention are deleted
+	pruneInterval = time.Hour
+)
+
+// Handler runs a job. Returning an error retries the job with backoff until
+// it runs out of attempts; wrap the error with Permanent to stop at once.
+// Jobs run at least once, so handlers must tolerate running twice.
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a job error as one retrying cannot fix, such as a payload
+// that does not decode, so the job is dead after this attempt
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Backoff returns how long a job waits after its given failed attempt:
+// 10 seconds after the first, doubling up to an hour
+func Backoff(attempt int) time.Duration {
+	delay := retryBase
+	for i := 1; i < attempt && delay < retryMax; i++ {
+		delay *= 2
+	}
+	if delay > retryMax {
+		delay = retryMax
+	}
+	return delay
+}
+
+// Queue runs background work of registered kinds from the jobs table. Any
+// instance may queue a job and any instance's workers may run it; workers
+// claim jobs with FOR UPDATE SKIP LOCKED, so each attempt runs once.
+type Queue struct {
+	repo *Repository
+	cfg  config.JobsConfig
+	now  func() time.Time
+
+	// wake tells idle workers a job was queued by this instance
+	wake chan struct{}
+
+	mu       sync.Mutex
+	handlers map[string]Handler
+	// long holds the kinds whose jobs renew their lease while they run
+	long    map[string]bool
+	lastErr error
+}
+
+func NewQueue(repo *Repository, cfg config.JobsConfig) *Queue {
+	return &Queue{
+		repo:     repo,
+		cfg:      cfg,
+		now:      time.Now,
+		wake:     make(chan struct{}, 1),
+		handlers: make(map[string]Handler),
+		long:     make(map[string]bool),
+	}
+}
+
+// Register sets the handler of a job kind. Every kind must be registered,
+// on every instance, before Run; registering a kind twice panics.
+func (q *Queue) Register(kind string, handler Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.handlers[kind]; ok {
+		panic(fmt.Sprintf("jobs: kind %q registered twice", kind))
+	}
+	q.handlers[kind] = handler
+}
+
+// RegisterLong is like Register for jobs that may run longer than Lease,
+// such as exports. The worker renews the lease while the handler runs, so the
+// job runs again only when its worker stops; the handler's context ends on
+// shutdown or when the lease is lost.
+func (q *Queue) RegisterLong(kind string, handler Handler) {
+	q.Register(kind, handler)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.long[kind] = true
+}
+
+func (q *Queue) handler(kind string) (Handler, bool, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	h, ok := q.handlers[kind]
+	return h, q.long[kind], ok
+}
+
+func (q *Queue) kinds() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// Enqueue queues a job of a registered kind with its payload encoded as JSON;
+// opts may be nil
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload interface{}, opts *EnqueueOptions) (*Job, error) {
+	if _, _, ok := q.handler(kind); !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKind, kind)
+	}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	job := &Job{
+		ID:          uuid.New(),
+		Kind:        kind,
+		Payload:     encoded,
+		Status:      StatusPending,
+		MaxAttempts: q.cfg.MaxAttempts,
+		RunAt:       q.now(),
+	}
+	if opts != nil {
+		if opts.MaxAttempts > 0 {
+			job.MaxAttempts = opts.MaxAttempts
+		}
+		if !opts.RunAt.IsZero() {
+			job.RunAt = opts.RunAt
+		}
+	}
+	if err := q.repo.Create(ctx, job); err != nil {
+		return nil, err
+	}
+	q.notify()
+	return job, nil
+}
+
+func (q *Queue) notify() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run starts the workers and prunes succeeded jobs until ctx is done, then
+// waits for running jobs to return. Jobs interrupted by shutdown run again
+// once their lease expires.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.cfg.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	q.prune(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		case <-prune.C:
+			q.prune(ctx)
+		}
+	}
+}
+
+// work runs due jobs one after another, and waits for the poll interval or
+// a newly queued job when there are none
+func (q *Queue) work(ctx context.Context) {
+	poll := time.NewTicker(q.cfg.PollInterval())
+	defer poll.Stop()
+	kinds := q.kinds()
+	for {
+		ran, err := q.RunNext(ctx, kinds)
+		q.mu.Lock()
+		q.lastErr = err
+		q.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("ERROR: jobs: %v", err)
+		}
+		if ran && err == nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// RunNext claims and runs one due job of the given kinds. It returns false
+// when none was due.
+func (q *Queue) RunNext(ctx context.Context, kinds []string) (bool, error) {
+	if len(kinds) == 0 {
+		return false, nil
+	}
+	job, err := q.repo.Claim(ctx, kinds, Lease)
+	if err != nil || job == nil {
+		return false, err
+	}
+
+	status, runAt, lastError := q.attempt(ctx, job)
+	finished, err := q.repo.Finish(ctx, job, status, runAt, lastError)
+	if err != nil {
+		return true, fmt.Errorf("recording %s job %s: %w", job.Kind, job.ID, err)
+	}
+	if !finished {
+		log.Printf("WARNING: jobs: %s job %s outlived its lease and was claimed again", job.Kind, job.ID)
+	}
+	return true, nil
+}
+
+// attempt runs a claimed job and returns what becomes of it
+func (q *Queue) attempt(ctx context.Context, job *Job) (status string, runAt time.Time, lastError string) {
+	err := q.call(ctx, job)
+	now := q.now()
+	if err == nil {
+		return StatusSucceeded, now, ""
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts {
+		log.Printf("ERROR: jobs: %s job %s is dead after %d attempts: %v", job.Kind, job.ID, job.Attempts, err)
+		return StatusDead, now, err.Error()
+	}
+	log.Printf("WARNING: jobs: %s job %s failed attempt %d of %d: %v", job.Kind, job.ID, job.Attempts, job.MaxAttempts, err)
+	return StatusPending, now.Add(Backoff(job.Attempts)), err.Error()
+}
+
+// call runs the job's handler within its lease, turning a panic into an error
+func (q *Queue) call(ctx context.Context, job *Job) (err error) {
+	handler, long, ok := q.handler(job.Kind)
+	if !ok {
+		return Permanent(fmt.Errorf("%w: %s", ErrUnknownKind, job.Kind))
+	}
+	// Handlers usually write what they read, so they skip the read replica
+	ctx = postgres.WithPrimary(reqctx.System(ctx))
+	var cancel context.CancelFunc
+	if long {
+		ctx, cancel = context.WithCancel(ctx)
+		go q.renew(ctx, job, cancel)
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, Lease)
+	}
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// renew extends the lease of a running job until ctx ends, and cancels the
+// job when its lease was lost
+func (q *Queue) renew(ctx context.Context, job *Job, cancel context.CancelFunc) {
+	ticker := time.NewTicker(renewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		held, err := q.repo.Renew(ctx, job, Lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("WARNING: jobs: renewing the lease of %s job %s failed: %v", job.Kind, job.ID, err)
+			}
+			continue
+		}
+		if !held {
+			log.Printf("WARNING: jobs: %s job %s lost its lease and is stopped", job.Kind, job.ID)
+			cancel()
+			return
+		}
+	}
+}
+
+func (q *Queue) prune(ctx context.Context) {
+	if q.cfg.RetentionDays <= 0 {
+		return
+	}
+	retention := time.Duration(q.cfg.RetentionDays) * 24 * time.Hour
+	if _, err := q.repo.PruneSucceeded(ctx, q.now().Add(-retention)); err != nil && ctx.Err() == nil {
+		log.Printf("ERROR: jobs: pruning succeeded jobs failed: %v", err)
+	}
+}
+
+// LastError returns the error of the latest claim, nil once one succeeds
+func (q *Queue) LastError() error {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.lastErr
+}
+
+// Workers returns how many jobs this instance runs at once
+func (q *Queue) Workers() int {
+	return q.cfg.Workers
+}
+
+// Counts returns how many jobs there are by status
+func (q *Queue) Counts(ctx context.Context) (map[string]int, error) {
+	return q.repo.Counts(ctx)
+}
+
+// List returns jobs for the admin API, newest first, with the counts of all
+// jobs by status
+func (q *Queue) List(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	switch req.Status {
+	case "", StatusPending, StatusRunning, StatusSucceeded, StatusDead:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	jobs, total, err := q.repo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if jobs == nil {
+		jobs = []*Job{}
+	}
+	counts, err := q.repo.Counts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &ListResponse{Jobs: jobs, Total: total, Counts: counts, Limit: req.Limit, Offset: req.Offset}, nil
+}
+
+func (q *Queue) Get(ctx context.Context, id uuid.UUID) (*Job, error) {
+	job, err := q.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil {
+		return nil, ErrNotFound
+	}
+	return job, nil
+}
+
+// Retry queues a dead job again with fresh attempts
+func (q *Queue) Retry(ctx context.Context, id uuid.UUID) (*Job, error) {
+	requeued, err := q.repo.Requeue(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !requeued {
+		if _, err := q.Get(ctx, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotDead
+	}
+	q.notify()
+	return q.Get(ctx, id)
+}
+
+// Discard deletes a dead job for good
+func (q *Queue) Discard(ctx context.Context, id uuid.UUID) error {
+	deleted, err := q.repo.DeleteDead(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		if _, err := q.Get(ctx, id); err != nil {
+			return err
+		}
+		return ErrNotDead
+	}
+	return nil
+}
+
+// OldestDead returns when the longest-dead job died, nil when none is dead
+func (q *Queue) OldestDead(ctx context.Context) (*time.Time, error) {
+	return q.repo.OldestDead(ctx)
+}
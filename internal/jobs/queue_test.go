@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/baseplate/baseplate/config"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{9, 2560 * time.Second},
+		{10, time.Hour},
+		{100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempt); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+		}
+	}
+}
+
+func TestQueue_Attempt(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q := NewQueue(nil, config.JobsConfig{MaxAttempts: 3})
+	q.now = func() time.Time { return now }
+	failure := errors.New("upstream unavailable")
+	q.Register("ok", func(ctx context.Context, job *Job) error { return nil })
+	q.Register("fails", func(ctx context.Context, job *Job) error { return failure })
+	q.Register("bad payload", func(ctx context.Context, job *Job) error { return Permanent(failure) })
+	q.Register("panics", func(ctx context.Context, job *Job) error { panic("nil map") })
+
+	tests := []struct {
+		name       string
+		kind       string
+		attempts   int
+		wantStatus string
+		wantRunAt  time.Time
+	}{
+		{"success", "ok", 1, StatusSucceeded, now},
+		{"failure is retried", "fails", 1, StatusPending, now.Add(10 * time.Second)},
+		{"backoff grows", "fails", 2, StatusPending, now.Add(20 * time.Second)},
+		{"last attempt", "fails", 3, StatusDead, now},
+		{"permanent", "bad payload", 1, StatusDead, now},
+		{"panic is retried", "panics", 1, StatusPending, now.Add(10 * time.Second)},
+		{"unregistered kind", "gone", 1, StatusDead, now},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &Job{Kind: tt.kind, Attempts: tt.attempts, MaxAttempts: 3}
+			status, runAt, lastError := q.attempt(context.Background(), job)
+			if status != tt.wantStatus || !runAt.Equal(tt.wantRunAt) {
+				t.Errorf("attempt() = %s at %v, want %s at %v", status, runAt, tt.wantStatus, tt.wantRunAt)
+			}
+			if (status == StatusSucceeded) != (lastError == "") {
+				t.Errorf("lastError = %q", lastError)
+			}
+		})
+	}
+}
+
+func TestQueue_RegisterTwice(t *testing.T) {
+	q := NewQueue(nil, config.JobsConfig{})
+	q.Register("sync", func(ctx context.Context, job *Job) error { return nil })
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a kind twice should panic")
+		}
+	}()
+	q.Register("sync", func(ctx context.Context, job *Job) error { return nil })
+}
+
+func TestQueue_EnqueueUnknownKind(t *testing.T) {
+	q := NewQueue(nil, config.JobsConfig{MaxAttempts: 3})
+	if _, err := q.Enqueue(context.Background(), "sync", nil, nil); !errors.Is(err, ErrUnknownKind) {
+		t.Errorf("Enqueue() = %v, want ErrUnknownKind", err)
+	}
+}
+
+func TestQueue_ListInvalidStatus(t *testing.T) {
+	q := NewQueue(nil, config.JobsConfig{})
+	if _, err := q.List(context.Background(), &ListRequest{Status: "failed"}); !errors.Is(err, ErrInvalidStatus) {
+		t.Errorf("List() = %v, want ErrInvalidStatus", err)
+	}
+}
+
+func TestQueue_LongJobsHaveNoDeadline(t *testing.T) {
+	q := NewQueue(nil, config.JobsConfig{})
+	deadlines := map[string]bool{}
+	record := func(ctx context.Context, job *Job) error {
+		_, deadlines[job.Kind] = ctx.Deadline()
+		return nil
+	}
+	q.Register("sync", record)
+	q.RegisterLong("export", record)
+
+	for _, kind := range []string{"sync", "export"} {
+		if err := q.call(context.Background(), &Job{Kind: kind}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !deadlines["sync"] || deadlines["export"] {
+		t.Errorf("deadlines = %v, want one for sync only", deadlines)
+	}
+}
@@ -0,0 +1,237 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const jobColumns = `
+	id, kind, payload, status, attempts, max_attempts, run_at, lease_expires_at,
+	COALESCE(last_error, ''), created_at, updated_at, finished_at`
+
+func (r *Repository) Create(ctx context.Context, job *Job) error {
+	query := `
+		INSERT INTO jobs (id, kind, payload, status, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING created_at, updated_at`
+	return r.db.DB.QueryRowContext(ctx, query,
+		job.ID, job.Kind, []byte(job.Payload), job.Status, job.MaxAttempts, job.RunAt,
+	).Scan(&job.CreatedAt, &job.UpdatedAt)
+}
+
+// Claim leases the oldest due job of one of the given kinds and counts the
+// attempt. Running jobs whose lease expired, because their worker stopped,
+// are due again. It returns nil when no job is due.
+func (r *Repository) Claim(ctx context.Context, kinds []string, lease time.Duration) (*Job, error) {
+	query := `
+		UPDATE jobs
+		SET status = $1, attempts = attempts + 1, lease_expires_at = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM jobs
+			WHERE kind = ANY($3)
+			  AND ((status = $4 AND run_at <= NOW()) OR (status = $1 AND lease_expires_at < NOW()))
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+	rows, err := r.db.DB.QueryContext(ctx, query, StatusRunning, int64(lease/time.Second), kinds, StatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs, err := scanJobs(rows)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], nil
+}
+
+// Finish ends an attempt of a running job: with status succeeded or dead the
+// job is done, with pending it runs again at runAt. It returns false when the
+// job's lease was lost to another worker.
+func (r *Repository) Finish(ctx context.Context, job *Job, status string, runAt time.Time, lastError string) (bool, error) {
+	query := `
+		UPDATE jobs
+		SET status = $3, run_at = $4, last_error = NULLIF($5, ''), lease_expires_at = NULL, updated_at = NOW(),
+			finished_at = CASE WHEN $3 = $6 THEN NULL ELSE NOW() END
+		WHERE id = $1 AND status = $7 AND attempts = $2`
+	result, err := r.db.DB.ExecContext(ctx, query,
+		job.ID, job.Attempts, status, runAt, lastError, StatusPending, StatusRunning)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Renew extends the lease of a running job's attempt. It returns false when
+// the lease was already lost to another worker.
+func (r *Repository) Renew(ctx context.Context, job *Job, lease time.Duration) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx, `
+		UPDATE jobs SET lease_expires_at = NOW() + $3 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = $1 AND status = $4 AND attempts = $2`,
+		job.ID, job.Attempts, int64(lease/time.Second), StatusRunning)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *Repository) Get(ctx context.Context, id uuid.UUID) (*Job, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs, err := scanJobs(rows)
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return jobs[0], nil
+}
+
+// List returns a page of jobs, newest first, and how many match
+func (r *Repository) List(ctx context.Context, req *ListRequest) ([]*Job, int, error) {
+	var conditions []string
+	var args []interface{}
+	if req.Status != "" {
+		args = append(args, req.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if req.Kind != "" {
+		args = append(args, req.Kind)
+		conditions = append(conditions, fmt.Sprintf("kind = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM jobs`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, req.Limit, req.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM jobs%s ORDER BY created_at DESC LIMIT $%d OFFSET $%d`,
+		jobColumns, where, len(args)-1, len(args))
+	rows, err := r.db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	jobs, err := scanJobs(rows)
+	return jobs, total, err
+}
+
+// Counts returns how many jobs there are by status
+func (r *Repository) Counts(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT status, COUNT(*) FROM jobs GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{StatusPending: 0, StatusRunning: 0, StatusSucceeded: 0, StatusDead: 0}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// Requeue makes a dead job pending again with fresh attempts. It returns
+// false when there is no such dead job.
+func (r *Repository) Requeue(ctx context.Context, id uuid.UUID) (bool, error) {
+	query := `
+		UPDATE jobs
+		SET status = $2, attempts = 0, run_at = NOW(), finished_at = NULL, updated_at = NOW()
+		WHERE id = $1 AND status = $3`
+	result, err := r.db.DB.ExecContext(ctx, query, id, StatusPending, StatusDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteDead deletes a dead job. It returns false when there is no such dead job.
+func (r *Repository) DeleteDead(ctx context.Context, id uuid.UUID) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx, `DELETE FROM jobs WHERE id = $1 AND status = $2`, id, StatusDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// OldestDead returns when the longest-dead job died, nil when none is dead
+func (r *Repository) OldestDead(ctx context.Context) (*time.Time, error) {
+	var oldest sql.NullTime
+	err := r.db.DB.QueryRowContext(ctx,
+		`SELECT MIN(finished_at) FROM jobs WHERE status = $1`, StatusDead,
+	).Scan(&oldest)
+	if err != nil || !oldest.Valid {
+		return nil, err
+	}
+	return &oldest.Time, nil
+}
+
+// PruneSucceeded deletes jobs that succeeded before the given time
+func (r *Repository) PruneSucceeded(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.DB.ExecContext(ctx,
+		`DELETE FROM jobs WHERE status = $1 AND finished_at < $2`, StatusSucceeded, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanJobs(rows *sql.Rows) ([]*Job, error) {
+	var jobs []*Job
+	for rows.Next() {
+		job := &Job{}
+		var payload []byte
+		var leaseExpiresAt, finishedAt sql.NullTime
+		err := rows.Scan(
+			&job.ID, &job.Kind, &payload, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAt, &leaseExpiresAt,
+			&job.LastError, &job.CreatedAt, &job.UpdatedAt, &finishedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		job.Payload = payload
+		if leaseExpiresAt.Valid {
+			job.LeaseExpiresAt = &leaseExpiresAt.Time
+		}
+		if finishedAt.Valid {
+			job.FinishedAt = &finishedAt.Time
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
@@ -0,0 +1,161 @@
+// Package logging routes the server's logs through log/slog with a level and
+// format that can be changed while the server runs.
+//
+// Existing log.Printf calls keep working: a message prefixed with "ERROR:",
+// "WARNING:", "WARN:" or "DEBUG:" is logged at that level, anything else at
+// info. Attributes whose names look like secrets (passwords, tokens,
+// Authorization headers) are redacted before they are written.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// Log formats
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+var levels = map[string]slog.Level{
+	"debug": slog.LevelDebug,
+	"info":  slog.LevelInfo,
+	"warn":  slog.LevelWarn,
+	"error": slog.LevelError,
+}
+
+// Controller owns the process-wide logger and switches its level and format
+type Controller struct {
+	level slog.LevelVar
+	json  atomic.Bool
+	out   io.Writer
+}
+
+// New creates a controller writing to out with the given level and format
+func New(out io.Writer, level, format string) (*Controller, error) {
+	c := &Controller{out: out}
+	if err := c.Set(level, format); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Install makes the controller's logger the slog default and sends the
+// standard log package through it
+func (c *Controller) Install() {
+	logger := c.Logger()
+	slog.SetDefault(logger)
+	// slog.SetDefault points the log package at the handler with a fixed
+	// level; map the message prefixes instead
+	log.SetFlags(0)
+	log.SetOutput(&stdWriter{logger: logger})
+}
+
+// Logger returns a logger that follows the controller's level and format
+func (c *Controller) Logger() *slog.Logger {
+	opts := &slog.HandlerOptions{Level: &c.level, ReplaceAttr: scrubAttr}
+	return slog.New(&switchHandler{
+		c:    c,
+		json: slog.NewJSONHandler(c.out, opts),
+		text: slog.NewTextHandler(c.out, opts),
+	})
+}
+
+// Set changes the minimum level (debug, info, warn or error) and the output
+// format (json or console). Nothing changes when either is invalid.
+func (c *Controller) Set(level, format string) error {
+	l, ok := levels[strings.ToLower(level)]
+	if !ok {
+		return fmt.Errorf("unknown log level %q; use debug, info, warn or error", level)
+	}
+	format = strings.ToLower(format)
+	if format != FormatJSON && format != FormatConsole {
+		return fmt.Errorf("unknown log format %q; use json or console", format)
+	}
+	c.level.Set(l)
+	c.json.Store(format == FormatJSON)
+	return nil
+}
+
+// Level returns the current level name
+func (c *Controller) Level() string {
+	return strings.ToLower(c.level.Level().String())
+}
+
+// Format returns the current format name
+func (c *Controller) Format() string {
+	if c.json.Load() {
+		return FormatJSON
+	}
+	return FormatConsole
+}
+
+// switchHandler writes each record with the handler for the current format.
+// Both handlers share the controller's level.
+type switchHandler struct {
+	c    *Controller
+	json slog.Handler
+	text slog.Handler
+}
+
+func (h *switchHandler) current() slog.Handler {
+	if h.c.json.Load() {
+		return h.json
+	}
+	return h.text
+}
+
+func (h *switchHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.c.level.Level()
+}
+
+func (h *switchHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.current().Handle(ctx, r)
+}
+
+func (h *switchHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &switchHandler{c: h.c, json: h.json.WithAttrs(attrs), text: h.text.WithAttrs(attrs)}
+}
+
+func (h *switchHandler) WithGroup(name string) slog.Handler {
+	return &switchHandler{c: h.c, json: h.json.WithGroup(name), text: h.text.WithGroup(name)}
+}
+
+// prefixes maps the message prefixes used with the log package to levels
+var prefixes = []struct {
+	prefix string
+	level  slog.Level
+}{
+	{"ERROR:", slog.LevelError},
+	{"WARNING:", slog.LevelWarn},
+	{"WARN:", slog.LevelWarn},
+	{"DEBUG:", slog.LevelDebug},
+}
+
+// parseLine splits a log package line into its level and message
+func parseLine(line string) (slog.Level, string) {
+	line = strings.TrimRight(line, "\n")
+	for _, p := range prefixes {
+		if strings.HasPrefix(line, p.prefix) {
+			return p.level, strings.TrimSpace(line[len(p.prefix):])
+		}
+	}
+	return slog.LevelInfo, line
+}
+
+// stdWriter receives the output of the log package
+type stdWriter struct {
+	logger *slog.Logger
+}
+
+func (w *stdWriter) Write(p []byte) (int, error) {
+	level, msg := parseLine(string(p))
+	w.logger.Log(context.Background(), level, msg)
+	return len(p), nil
+}
@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		level slog.Level
+		msg   string
+	}{
+		{"ERROR: failed to list teams: timeout\n", slog.LevelError, "failed to list teams: timeout"},
+		{"WARNING: startup checks: x\n", slog.LevelWarn, "startup checks: x"},
+		{"WARN: slow query", slog.LevelWarn, "slow query"},
+		{"DEBUG: cache miss", slog.LevelDebug, "cache miss"},
+		{"Starting server on port 8080\n", slog.LevelInfo, "Starting server on port 8080"},
+	}
+
+	for _, tt := range tests {
+		level, msg := parseLine(tt.line)
+		if level != tt.level || msg != tt.msg {
+			t.Errorf("parseLine(%q) = %v %q, want %v %q", tt.line, level, msg, tt.level, tt.msg)
+		}
+	}
+}
+
+func TestController_SwitchesLevelAndFormat(t *testing.T) {
+	var out bytes.Buffer
+	c, err := New(&out, "info", "console")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := c.Logger().With("component", "test")
+
+	logger.Debug("hidden")
+	logger.Info("shown")
+	if strings.Contains(out.String(), "hidden") || !strings.Contains(out.String(), "msg=shown component=test") {
+		t.Fatalf("unexpected console output %q", out.String())
+	}
+
+	if err := c.Set("debug", "json"); err != nil {
+		t.Fatal(err)
+	}
+	out.Reset()
+	logger.Debug("visible")
+	var record map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("expected JSON output, got %q", out.String())
+	}
+	if record["msg"] != "visible" || record["level"] != "DEBUG" || record["component"] != "test" {
+		t.Errorf("unexpected record %v", record)
+	}
+	if c.Level() != "debug" || c.Format() != FormatJSON {
+		t.Errorf("expected debug/json, got %s/%s", c.Level(), c.Format())
+	}
+}
+
+func TestController_SetRejectsInvalidValues(t *testing.T) {
+	c, err := New(&bytes.Buffer{}, "warn", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Set("debug", "xml"); err == nil {
+		t.Fatal("expected an unknown format to be rejected")
+	}
+	if err := c.Set("verbose", "console"); err == nil {
+		t.Fatal("expected an unknown level to be rejected")
+	}
+	if c.Level() != "warn" || c.Format() != FormatJSON {
+		t.Errorf("expected nothing to change, got %s/%s", c.Level(), c.Format())
+	}
+}
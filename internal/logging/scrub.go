@@ -0,0 +1,110 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the value of a sensitive field
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are matched against lower-cased names with dashes turned into
+// underscores, so "X-API-Key" and "client_secret" are both caught
+var sensitiveKeys = []string{
+	"authorization",
+	"cookie",
+	"password",
+	"passwd",
+	"secret",
+	"token",
+	"api_key",
+	"apikey",
+	"private_key",
+	"credential",
+}
+
+// Sensitive reports whether a header, query parameter, property or log
+// attribute name is likely to hold a secret
+func Sensitive(name string) bool {
+	name = strings.ReplaceAll(strings.ToLower(name), "-", "_")
+	for _, key := range sensitiveKeys {
+		if strings.Contains(name, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrubHeaders flattens request headers for logging with sensitive values
+// redacted
+func ScrubHeaders(h http.Header) map[string]string {
+	headers := make(map[string]string, len(h))
+	for name, values := range h {
+		if Sensitive(name) {
+			headers[name] = Redacted
+			continue
+		}
+		headers[name] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
+// ScrubQuery returns a raw query string with the values of sensitive
+// parameters redacted. Parameter order is kept.
+func ScrubQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		name, _, _ := strings.Cut(pair, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if Sensitive(name) {
+			pairs[i] = url.QueryEscape(name) + "=" + Redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// ScrubJSON decodes a JSON payload and redacts sensitive properties at any
+// depth. It reports false for a payload that is not valid JSON, which cannot
+// be scrubbed and must not be logged.
+func ScrubJSON(body []byte) (interface{}, bool) {
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, false
+	}
+	return scrubValue(payload), true
+}
+
+func scrubValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, e := range t {
+			if Sensitive(k) {
+				t[k] = Redacted
+				continue
+			}
+			t[k] = scrubValue(e)
+		}
+	case []interface{}:
+		for i, e := range t {
+			t[i] = scrubValue(e)
+		}
+	}
+	return v
+}
+
+// scrubAttr redacts log attributes with a sensitive key wherever they are
+// logged, as a safety net for log calls that pass secrets by mistake
+func scrubAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() != slog.KindGroup && Sensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSensitive(t *testing.T) {
+	for _, name := range []string{"Authorization", "password", "new_password", "client_secret", "X-API-Key", "refresh_token", "Cookie"} {
+		if !Sensitive(name) {
+			t.Errorf("Sensitive(%q) = false", name)
+		}
+	}
+	for _, name := range []string{"name", "email", "status", "client_ip", "path"} {
+		if Sensitive(name) {
+			t.Errorf("Sensitive(%q) = true", name)
+		}
+	}
+}
+
+func TestScrubQuery(t *testing.T) {
+	got := ScrubQuery("limit=10&access_token=abc&q=svc&Password=x")
+	want := "limit=10&access_token=[REDACTED]&q=svc&Password=[REDACTED]"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestScrubHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc")
+	h.Set("Content-Type", "application/json")
+	got := ScrubHeaders(h)
+	if got["Authorization"] != Redacted || got["Content-Type"] != "application/json" {
+		t.Errorf("unexpected headers %v", got)
+	}
+}
+
+func TestScrubJSON(t *testing.T) {
+	got, ok := ScrubJSON([]byte(`{"email":"a@b.c","password":"hunter2","data":{"db":{"secret":"s","host":"h"}},"items":[{"api_key":"k"}]}`))
+	if !ok {
+		t.Fatal("valid JSON was not scrubbed")
+	}
+	want := map[string]interface{}{
+		"email":    "a@b.c",
+		"password": Redacted,
+		"data":     map[string]interface{}{"db": map[string]interface{}{"secret": Redacted, "host": "h"}},
+		"items":    []interface{}{map[string]interface{}{"api_key": Redacted}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, ok := ScrubJSON([]byte(`{"password":"hun`)); ok {
+		t.Error("truncated JSON must not be logged")
+	}
+}
+
+func TestController_RedactsSensitiveAttributes(t *testing.T) {
+	var out bytes.Buffer
+	c, err := New(&out, "info", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Logger().Info("login", "email", "a@b.c", "password", "hunter2")
+	if strings.Contains(out.String(), "hunter2") || !strings.Contains(out.String(), Redacted) {
+		t.Errorf("password was not redacted: %s", out.String())
+	}
+}
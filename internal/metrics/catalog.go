@@ -0,0 +1,287 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+// CatalogCollector exports catalog-domain metrics (entity counts, scorecard pass
+// ratios, integration sync lag). The queries scan whole tables, so results are
+// cached for the refresh interval rather than recomputed on every scrape.
+// Scorecard ratios come from entity rollups when rollups is set and covers the scorecard.
+type CatalogCollector struct {
+	db         *postgres.Client
+	scorecards *scorecard.Repository
+	rollups    *entity.Repository
+	refresh    time.Duration
+
+	mu       sync.Mutex
+	cached   []Family
+	cachedAt time.Time
+}
+
+func NewCatalogCollector(db *postgres.Client, scorecards *scorecard.Repository, rollups *entity.Repository, refresh time.Duration) *CatalogCollector {
+	return &CatalogCollector{db: db, scorecards: scorecards, rollups: rollups, refresh: refresh}
+}
+
+func (c *CatalogCollector) Collect(ctx context.Context) ([]Family, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && time.Since(c.cachedAt) < c.refresh {
+		return c.cached, nil
+	}
+
+	var families []Family
+	for _, collect := range []func(context.Context) ([]Family, error){
+		c.collectEntityCounts,
+		c.collectScorecards,
+		c.collectIntegrations,
+	} {
+		f, err := collect(ctx)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, f...)
+	}
+
+	c.cached = families
+	c.cachedAt = time.Now()
+	return families, nil
+}
+
+func (c *CatalogCollector) collectEntityCounts(ctx context.Context) ([]Family, error) {
+	entities := Family{Name: "baseplate_catalog_entities", Help: "Number of entities per blueprint.", Type: TypeGauge}
+	blueprints := Family{Name: "baseplate_catalog_blueprints", Help: "Number of blueprints per team.", Type: TypeGauge}
+
+	rows, err := c.db.DB.QueryContext(ctx, `
+		SELECT b.team_id, b.id, COUNT(e.id)
+		FROM blueprints b
+		LEFT JOIN entities e ON e.team_id = b.team_id AND e.blueprint_id = b.id
+		GROUP BY b.team_id, b.id
+		ORDER BY b.team_id, b.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	perTeam := make(map[string]int)
+	var teams []string
+	for rows.Next() {
+		var teamID, blueprintID string
+		var count int64
+		if err := rows.Scan(&teamID, &blueprintID, &count); err != nil {
+			return nil, err
+		}
+		entities.Samples = append(entities.Samples, Sample{
+			Labels: []Label{{"team_id", teamID}, {"blueprint", blueprintID}},
+			Value:  float64(count),
+		})
+		if _, seen := perTeam[teamID]; !seen {
+			teams = append(teams, teamID)
+		}
+		perTeam[teamID]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, teamID := range teams {
+		blueprints.Samples = append(blueprints.Samples, Sample{
+			Labels: []Label{{"team_id", teamID}},
+			Value:  float64(perTeam[teamID]),
+		})
+	}
+	return []Family{entities, blueprints}, nil
+}
+
+func (c *CatalogCollector) collectScorecards(ctx context.Context) ([]Family, error) {
+	ratio := Family{
+		Name: "baseplate_scorecard_pass_ratio",
+		Help: "Fraction of a blueprint's entities that reach at least the given scorecard level.",
+		Type: TypeGauge,
+	}
+	rulesRatio := Family{
+		Name: "baseplate_scorecard_rule_pass_ratio",
+		Help: "Fraction of all rule checks of a scorecard that pass.",
+		Type: TypeGauge,
+	}
+
+	scorecards, err := c.scorecards.ListAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rollups, err := c.loadScorecardRollups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sc := range scorecards {
+		reached := make([]int, len(sc.Levels))
+		levelIndex := make(map[string]int, len(sc.Levels))
+		for i, l := range sc.Levels {
+			levelIndex[l.Name] = i
+		}
+		addLevel := func(level string, n int) {
+			if idx, ok := levelIndex[level]; ok {
+				for i := 0; i <= idx; i++ {
+					reached[i] += n
+				}
+			}
+		}
+
+		var total, checksPassed, checksTotal int
+		if counts, ok := rollups[scorecardRollupKey(sc)]; ok {
+			for _, row := range counts {
+				switch {
+				case row.Kind == entity.RollupScorecard:
+					total += int(row.Count)
+					addLevel(row.Value, int(row.Count))
+				case row.Value == "passed":
+					checksPassed = int(row.Count)
+				case row.Value == "total":
+					checksTotal = int(row.Count)
+				}
+			}
+		} else {
+			err := c.forEachEntityData(ctx, sc.TeamID.String(), sc.BlueprintID, func(data map[string]interface{}) {
+				total++
+				result := sc.Evaluate(data)
+				checksPassed += result.RulesPassed
+				checksTotal += result.RulesTotal
+				addLevel(result.Level, 1)
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		base := []Label{{"team_id", sc.TeamID.String()}, {"blueprint", sc.BlueprintID}, {"scorecard", sc.Identifier}}
+		for i, level := range sc.Levels {
+			value := 0.0
+			if total > 0 {
+				value = float64(reached[i]) / float64(total)
+			}
+			labels := append(append([]Label{}, base...), Label{"level", level.Name})
+			ratio.Samples = append(ratio.Samples, Sample{Labels: labels, Value: value})
+		}
+
+		value := 0.0
+		if checksTotal > 0 {
+			value = float64(checksPassed) / float64(checksTotal)
+		}
+		rulesRatio.Samples = append(rulesRatio.Samples, Sample{Labels: base, Value: value})
+	}
+
+	return []Family{ratio, rulesRatio}, nil
+}
+
+// loadScorecardRollups returns the rollup counters of every scorecard the
+// rollups cover, keyed by scorecardRollupKey; nil when rollups are disabled
+func (c *CatalogCollector) loadScorecardRollups(ctx context.Context) (map[string][]entity.RollupRow, error) {
+	if c.rollups == nil {
+		return nil, nil
+	}
+	states, err := c.rollups.ListRollupStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.rollups.ListRollups(ctx, entity.RollupScorecard, entity.RollupScorecardRules)
+	if err != nil {
+		return nil, err
+	}
+
+	covered := make(map[string]bool)
+	for bp, dims := range states {
+		for _, dim := range dims {
+			if identifier, ok := strings.CutPrefix(dim, entity.RollupScorecard+":"); ok {
+				covered[bp+"/"+identifier] = true
+			}
+		}
+	}
+	result := make(map[string][]entity.RollupRow)
+	for key := range covered {
+		result[key] = nil
+	}
+	for _, row := range rows {
+		key := row.TeamID.String() + "/" + row.BlueprintID + "/" + row.Key
+		if covered[key] {
+			result[key] = append(result[key], row)
+		}
+	}
+	return result, nil
+}
+
+func scorecardRollupKey(sc *scorecard.Scorecard) string {
+	return sc.TeamID.String() + "/" + sc.BlueprintID + "/" + sc.Identifier
+}
+
+func (c *CatalogCollector) forEachEntityData(ctx context.Context, teamID, blueprintID string, fn func(map[string]interface{})) error {
+	rows, err := c.db.DB.QueryContext(ctx, `SELECT data FROM entities WHERE team_id = $1 AND blueprint_id = $2`, teamID, blueprintID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return err
+		}
+		var data map[string]interface{}
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return err
+		}
+		fn(data)
+	}
+	return rows.Err()
+}
+
+func (c *CatalogCollector) collectIntegrations(ctx context.Context) ([]Family, error) {
+	lag := Family{
+		Name: "baseplate_integration_sync_lag_seconds",
+		Help: "Seconds since the integration last completed a sync.",
+		Type: TypeGauge,
+	}
+	lastSync := Family{
+		Name: "baseplate_integration_last_sync_timestamp_seconds",
+		Help: "Unix time of the last completed sync (0 if never synced).",
+		Type: TypeGauge,
+	}
+
+	rows, err := c.db.DB.QueryContext(ctx, `
+		SELECT id, team_id, type, name, status, last_sync_at
+		FROM integrations
+		ORDER BY team_id, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		var id, teamID, typ, name string
+		var status sql.NullString
+		var last sql.NullTime
+		if err := rows.Scan(&id, &teamID, &typ, &name, &status, &last); err != nil {
+			return nil, err
+		}
+		labels := []Label{{"team_id", teamID}, {"integration", name}, {"type", typ}, {"status", status.String}}
+
+		if !last.Valid {
+			lastSync.Samples = append(lastSync.Samples, Sample{Labels: labels})
+			continue
+		}
+		lastSync.Samples = append(lastSync.Samples, Sample{Labels: labels, Value: float64(last.Time.Unix())})
+		lag.Samples = append(lag.Samples, Sample{Labels: labels, Value: now.Sub(last.Time).Seconds()})
+	}
+	return []Family{lag, lastSync}, rows.Err()
+}
@@ -0,0 +1,16 @@
+package metrics
+
+// HTTPMetrics holds the server-level request instruments
+type HTTPMetrics struct {
+	Requests *CounterVec
+	Duration *HistogramVec
+}
+
+func NewHTTPMetrics(reg *Registry) *HTTPMetrics {
+	return &HTTPMetrics{
+		Requests: reg.NewCounterVec("baseplate_http_requests_total",
+			"Total HTTP requests by method, route and status code.", "method", "route", "status"),
+		Duration: reg.NewHistogramVec("baseplate_http_request_duration_seconds",
+			"HTTP request latency by method and route.", DefaultDurationBuckets, "method", "route"),
+	}
+}
@@ -0,0 +1,164 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+// CounterVec is a monotonically increasing counter partitioned by labels
+type CounterVec struct {
+	mu         sync.Mutex
+	name       string
+	help       string
+	labelNames []string
+	values     map[string]*counterValue
+}
+
+// Add increases the counter for the given label values by delta
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := labelKey(labelValues)
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: append([]string{}, labelValues...)}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) family() Family {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return Family{Name: c.name, Help: c.help, Type: TypeCounter, Samples: sortedSamples(c.labelNames, c.values)}
+}
+
+// GaugeVec is a value that can go up and down, partitioned by labels
+type GaugeVec struct {
+	mu         sync.Mutex
+	name       string
+	help       string
+	labelNames []string
+	values     map[string]*counterValue
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := labelKey(labelValues)
+	v, ok := g.values[key]
+	if !ok {
+		v = &counterValue{labels: append([]string{}, labelValues...)}
+		g.values[key] = v
+	}
+	v.value = value
+}
+
+func (g *GaugeVec) Add(delta float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	key := labelKey(labelValues)
+	v, ok := g.values[key]
+	if !ok {
+		v = &counterValue{labels: append([]string{}, labelValues...)}
+		g.values[key] = v
+	}
+	v.value += delta
+}
+
+func (g *GaugeVec) family() Family {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return Family{Name: g.name, Help: g.help, Type: TypeGauge, Samples: sortedSamples(g.labelNames, g.values)}
+}
+
+func sortedSamples(names []string, values map[string]*counterValue) []Sample {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	samples := make([]Sample, 0, len(keys))
+	for _, k := range keys {
+		v := values[k]
+		samples = append(samples, Sample{Labels: pairLabels(names, v.labels), Value: v.value})
+	}
+	return samples
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec counts observations into cumulative buckets, partitioned by labels
+type HistogramVec struct {
+	mu         sync.Mutex
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+	values     map[string]*histogramValue
+}
+
+// DefaultDurationBuckets suit HTTP request latencies in seconds
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	key := labelKey(labelValues)
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labels: append([]string{}, labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			v.counts[i]++
+		}
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *HistogramVec) family() Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.values))
+	for k := range h.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var samples []Sample
+	for _, k := range keys {
+		v := h.values[k]
+		base := pairLabels(h.labelNames, v.labels)
+		for i, upper := range h.buckets {
+			labels := append(append([]Label{}, base...), Label{Name: "le", Value: strconv.FormatFloat(upper, 'g', -1, 64)})
+			samples = append(samples, Sample{Suffix: "_bucket", Labels: labels, Value: float64(v.counts[i])})
+		}
+		labels := append(append([]Label{}, base...), Label{Name: "le", Value: formatValue(math.Inf(1))})
+		samples = append(samples,
+			Sample{Suffix: "_bucket", Labels: labels, Value: float64(v.count)},
+			Sample{Suffix: "_sum", Labels: base, Value: v.sum},
+			Sample{Suffix: "_count", Labels: base, Value: float64(v.count)},
+		)
+	}
+	return Family{Name: h.name, Help: h.help, Type: TypeHistogram, Samples: samples}
+}
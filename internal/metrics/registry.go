@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type MetricType string
+
+const (
+	TypeCounter   MetricType = "counter"
+	TypeGauge     MetricType = "gauge"
+	TypeHistogram MetricType = "histogram"
+)
+
+// Label is a single name/value pair attached to a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is one time series value. Suffix is appended to the family name
+// (e.g. "_bucket" for histograms).
+type Sample struct {
+	Suffix string
+	Labels []Label
+	Value  float64
+}
+
+// Family groups samples sharing a metric name
+type Family struct {
+	Name    string
+	Help    string
+	Type    MetricType
+	Samples []Sample
+}
+
+// Collector produces metric families on demand, e.g. by querying the database
+type Collector interface {
+	Collect(ctx context.Context) ([]Family, error)
+}
+
+// Registry holds in-process instruments and collectors and renders them in the
+// Prometheus text exposition format (version 0.0.4).
+type Registry struct {
+	mu         sync.RWMutex
+	counters   []*CounterVec
+	histograms []*HistogramVec
+	gauges     []*GaugeVec
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	c := &CounterVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterValue)}
+	r.mu.Lock()
+	r.counters = append(r.counters, c)
+	r.mu.Unlock()
+	return c
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	g := &GaugeVec{name: name, help: help, labelNames: labelNames, values: make(map[string]*counterValue)}
+	r.mu.Lock()
+	r.gauges = append(r.gauges, g)
+	r.mu.Unlock()
+	return g
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{name: name, help: help, buckets: sorted, labelNames: labelNames, values: make(map[string]*histogramValue)}
+	r.mu.Lock()
+	r.histograms = append(r.histograms, h)
+	r.mu.Unlock()
+	return h
+}
+
+// Register adds a collector that is invoked on every scrape
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// Gather returns all families, in-process instruments first
+func (r *Registry) Gather(ctx context.Context) []Family {
+	r.mu.RLock()
+	counters := append([]*CounterVec{}, r.counters...)
+	gauges := append([]*GaugeVec{}, r.gauges...)
+	histograms := append([]*HistogramVec{}, r.histograms...)
+	collectors := append([]Collector{}, r.collectors...)
+	r.mu.RUnlock()
+
+	var families []Family
+	for _, c := range counters {
+		families = append(families, c.family())
+	}
+	for _, g := range gauges {
+		families = append(families, g.family())
+	}
+	for _, h := range histograms {
+		families = append(families, h.family())
+	}
+	for _, c := range collectors {
+		collected, err := c.Collect(ctx)
+		if err != nil {
+			// A failing collector must not break the whole scrape
+			log.Printf("ERROR: metrics collector failed: %v", err)
+			continue
+		}
+		families = append(families, collected...)
+	}
+	return families
+}
+
+// WriteText renders families in the Prometheus text format
+func WriteText(w io.Writer, families []Family) error {
+	for _, f := range families {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.Name, escapeHelp(f.Help), f.Name, f.Type); err != nil {
+			return err
+		}
+		for _, s := range f.Samples {
+			if _, err := fmt.Fprintf(w, "%s%s%s %s\n", f.Name, s.Suffix, formatLabels(s.Labels), formatValue(s.Value)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func formatLabels(labels []Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	parts := make([]string, len(labels))
+	for i, l := range labels {
+		parts[i] = l.Name + `="` + escapeLabelValue(l.Value) + `"`
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(v string) string { return labelEscaper.Replace(v) }
+func escapeHelp(v string) string       { return helpEscaper.Replace(v) }
+
+// labelKey joins label values into a map key; \xff cannot appear in valid UTF-8
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+func pairLabels(names, values []string) []Label {
+	labels := make([]Label, len(names))
+	for i := range names {
+		labels[i] = Label{Name: names[i], Value: values[i]}
+	}
+	return labels
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+type staticCollector struct{ families []Family }
+
+func (s staticCollector) Collect(ctx context.Context) ([]Family, error) { return s.families, nil }
+
+func TestWriteText_CounterAndCollector(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounterVec("test_requests_total", "Requests.", "route")
+	requests.Inc("/a")
+	requests.Inc("/a")
+	requests.Inc(`/b"quoted"`)
+	reg.Register(staticCollector{families: []Family{{
+		Name: "test_entities", Help: "Entities.", Type: TypeGauge,
+		Samples: []Sample{{Labels: []Label{{"blueprint", "service"}}, Value: 42}},
+	}}})
+
+	var buf bytes.Buffer
+	if err := WriteText(&buf, reg.Gather(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{route="/a"} 2`,
+		`test_requests_total{route="/b\"quoted\""} 1`,
+		"# TYPE test_entities gauge",
+		`test_entities{blueprint="service"} 42`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q\n%s", want, out)
+		}
+	}
+}
+
+func TestHistogram_CumulativeBuckets(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.NewHistogramVec("test_duration_seconds", "Duration.", []float64{0.1, 1}, "route")
+	h.Observe(0.05, "/a")
+	h.Observe(0.5, "/a")
+	h.Observe(5, "/a")
+
+	var buf bytes.Buffer
+	WriteText(&buf, reg.Gather(context.Background()))
+	out := buf.String()
+
+	for _, want := range []string{
+		`test_duration_seconds_bucket{route="/a",le="0.1"} 1`,
+		`test_duration_seconds_bucket{route="/a",le="1"} 2`,
+		`test_duration_seconds_bucket{route="/a",le="+Inf"} 3`,
+		`test_duration_seconds_count{route="/a"} 3`,
+		`test_duration_seconds_sum{route="/a"} 5.55`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,363 @@
+// Package outbox delivers the events of committed writes. Entity and
+// blueprint writes insert their events into the event_outbox table in the
+// same statement as the write, so an event is recorded exactly when its
+// write commits. The dispatcher delivers them to its consumers at least
+// once, retrying a consumer that failed until the event runs out of attempts.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/events"
+	"github.com/baseplate/baseplate/internal/jobs"
+)
+
+const (
+	// Lease is how long a dispatcher holds the events it claimed. A consumer's
+	// context ends when the lease does; events still being delivered after
+	// that may be delivered twice.
+	Lease = time.Minute
+
+	// pruneInterval is how often dispatched events past retention are deleted
+	pruneInterval = time.Hour
+
+	// JobReplay is the background job kind that replays events to a consumer
+	JobReplay = "outbox.replay"
+
+	// replayPage is how many events a replay reads at a time
+	replayPage = 500
+)
+
+// Consumer receives the events of the outbox; the payload is the written
+// row as JSON. Events are delivered at least once and in outbox order as far
+// as retries allow, so consumers must tolerate duplicates. Returning an error
+// delivers the event to this consumer again with backoff.
+type Consumer func(ctx context.Context, e events.Event) error
+
+// Dispatcher delivers pending outbox events to the registered consumers.
+// Any number of instances may dispatch; each claims its own batch with FOR
+// UPDATE SKIP LOCKED.
+type Dispatcher struct {
+	repo  *Repository
+	queue *jobs.Queue
+	cfg   config.OutboxConfig
+	now   func() time.Time
+
+	mu        sync.Mutex
+	consumers map[string]Consumer
+	lastErr   error
+}
+
+func NewDispatcher(repo *Repository, queue *jobs.Queue, cfg config.OutboxConfig) *Dispatcher {
+	d := &Dispatcher{
+		repo:      repo,
+		queue:     queue,
+		cfg:       cfg,
+		now:       time.Now,
+		consumers: make(map[string]Consumer),
+	}
+	queue.Register(JobReplay, d.runReplay)
+	return d
+}
+
+// Register adds a named consumer. Every consumer must be registered, on
+// every instance, before Run; registering a name twice panics.
+func (d *Dispatcher) Register(name string, consumer Consumer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.consumers[name]; ok {
+		panic(fmt.Sprintf("outbox: consumer %q registered twice", name))
+	}
+	d.consumers[name] = consumer
+}
+
+// names returns the registered consumers in a stable order
+func (d *Dispatcher) names() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	names := make([]string, 0, len(d.consumers))
+	for name := range d.consumers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (d *Dispatcher) consumer(name string) (Consumer, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, ok := d.consumers[name]
+	return c, ok
+}
+
+// Run dispatches pending events and prunes dispatched ones until ctx is
+// done. A full batch is followed by the next at once.
+func (d *Dispatcher) Run(ctx context.Context) {
+	poll := time.NewTicker(d.cfg.PollInterval())
+	defer poll.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	d.prune(ctx)
+	for {
+		n, err := d.DispatchNext(ctx)
+		d.mu.Lock()
+		d.lastErr = err
+		d.mu.Unlock()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("ERROR: outbox: %v", err)
+		}
+		if err == nil && n == d.cfg.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-poll.C:
+		case <-prune.C:
+			d.prune(ctx)
+		}
+	}
+}
+
+// DispatchNext claims a batch of due events and delivers each in order. It
+// returns how many events it claimed.
+func (d *Dispatcher) DispatchNext(ctx context.Context) (int, error) {
+	records, err := d.repo.Claim(ctx, d.cfg.BatchSize, Lease)
+	if err != nil {
+		return 0, err
+	}
+	for _, rec := range records {
+		status, nextAttempt, failed, lastError := d.deliver(ctx, rec)
+		finished, err := d.repo.Finish(context.WithoutCancel(ctx), rec, status, nextAttempt, failed, lastError)
+		if err != nil {
+			return len(records), fmt.Errorf("recording event %s: %w", rec.Event.ID, err)
+		}
+		if !finished {
+			log.Printf("WARNING: outbox: event %s outlived its lease and was claimed again", rec.Event.ID)
+		}
+	}
+	return len(records), nil
+}
+
+// deliver hands a claimed event to the consumers still due to receive it and
+// returns what becomes of it. A consumer no longer registered is skipped.
+func (d *Dispatcher) deliver(ctx context.Context, rec *Record) (status string, nextAttempt time.Time, failed []string, lastError string) {
+	targets := rec.FailedConsumers
+	if targets == nil {
+		targets = d.names()
+	}
+
+	var errs []string
+	for _, name := range targets {
+		consumer, ok := d.consumer(name)
+		if !ok {
+			continue
+		}
+		if err := call(ctx, consumer, rec.Event); err != nil {
+			failed = append(failed, name)
+			errs = append(errs, name+": "+err.Error())
+		}
+	}
+
+	now := d.now()
+	if len(failed) == 0 {
+		return StatusDispatched, now, nil, ""
+	}
+	lastError = strings.Join(errs, "; ")
+	if rec.Attempts >= d.cfg.MaxAttempts {
+		log.Printf("ERROR: outbox: %s event %s is dead after %d attempts: %s", rec.Event.Type, rec.Event.ID, rec.Attempts, lastError)
+		return StatusDead, now, failed, lastError
+	}
+	log.Printf("WARNING: outbox: %s event %s failed attempt %d of %d: %s", rec.Event.Type, rec.Event.ID, rec.Attempts, d.cfg.MaxAttempts, lastError)
+	return StatusPending, now.Add(jobs.Backoff(rec.Attempts)), failed, lastError
+}
+
+// call runs a consumer within the lease, turning a panic into an error
+func call(ctx context.Context, consumer Consumer, e events.Event) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, Lease)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return consumer(ctx, e)
+}
+
+func (d *Dispatcher) prune(ctx context.Context) {
+	if d.cfg.RetentionDays <= 0 {
+		return
+	}
+	retention := time.Duration(d.cfg.RetentionDays) * 24 * time.Hour
+	if _, err := d.repo.PruneDispatched(ctx, d.now().Add(-retention)); err != nil && ctx.Err() == nil {
+		log.Printf("ERROR: outbox: pruning dispatched events failed: %v", err)
+	}
+}
+
+// LastError returns the error of the latest batch, nil once one succeeds
+func (d *Dispatcher) LastError() error {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.lastErr
+}
+
+// Consumers returns the names of the registered consumers
+func (d *Dispatcher) Consumers() []string {
+	return d.names()
+}
+
+// Counts returns how many events there are by status
+func (d *Dispatcher) Counts(ctx context.Context) (map[string]int, error) {
+	return d.repo.Counts(ctx)
+}
+
+// Overview returns the registered consumers, the events by status and how
+// far back a replay can reach
+func (d *Dispatcher) Overview(ctx context.Context) (*Overview, error) {
+	counts, err := d.repo.Counts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	oldest, err := d.repo.Oldest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Overview{Consumers: d.names(), Counts: counts, Oldest: oldest}, nil
+}
+
+// Replay queues a background job that delivers the events of a time range to
+// one consumer again, whether or not they were delivered before. Events are
+// replayed in outbox order; the consumer's usual deliveries continue
+// meanwhile. Only events still in the outbox can be replayed.
+func (d *Dispatcher) Replay(ctx context.Context, req *ReplayRequest) (*jobs.Job, error) {
+	if err := d.validateReplay(req); err != nil {
+		return nil, err
+	}
+	return d.queue.Enqueue(ctx, JobReplay, req, nil)
+}
+
+func (d *Dispatcher) validateReplay(req *ReplayRequest) error {
+	if _, ok := d.consumer(req.Consumer); !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownConsumer, req.Consumer)
+	}
+	now := d.now()
+	if req.To.IsZero() {
+		req.To = now
+	}
+	if !req.From.Before(req.To) || req.To.After(now) {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// runReplay delivers a replay's events page by page. A failed delivery fails
+// the job, whose retry replays the range from the start.
+func (d *Dispatcher) runReplay(ctx context.Context, job *jobs.Job) error {
+	var req ReplayRequest
+	if err := json.Unmarshal(job.Payload, &req); err != nil {
+		return jobs.Permanent(err)
+	}
+	consumer, ok := d.consumer(req.Consumer)
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("%w: %s", ErrUnknownConsumer, req.Consumer))
+	}
+
+	var after int64
+	replayed := 0
+	for {
+		records, err := d.repo.Range(ctx, &req, after, replayPage)
+		if err != nil {
+			return err
+		}
+		for _, rec := range records {
+			if err := call(ctx, consumer, rec.Event); err != nil {
+				return fmt.Errorf("replaying event %s to %s: %w", rec.Event.ID, req.Consumer, err)
+			}
+			after = rec.Seq
+		}
+		replayed += len(records)
+		if len(records) < replayPage {
+			break
+		}
+	}
+	log.Printf("outbox: replayed %d events from %s to %s to %s",
+		replayed, req.From.Format(time.RFC3339), req.To.Format(time.RFC3339), req.Consumer)
+	return nil
+}
+
+// ListEvents returns outbox events for the admin API, newest first
+func (d *Dispatcher) ListEvents(ctx context.Context, req *ListRequest) (*ListResponse, error) {
+	switch req.Status {
+	case "", StatusPending, StatusDispatched, StatusDead:
+	default:
+		return nil, ErrInvalidStatus
+	}
+	records, total, err := d.repo.List(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []*Record{}
+	}
+	return &ListResponse{Events: records, Total: total, Limit: req.Limit, Offset: req.Offset}, nil
+}
+
+func (d *Dispatcher) GetEvent(ctx context.Context, eventID uuid.UUID) (*Record, error) {
+	rec, err := d.repo.Get(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrNotFound
+	}
+	return rec, nil
+}
+
+// Retry delivers a dead event again, with fresh attempts, to the consumers
+// that failed it
+func (d *Dispatcher) Retry(ctx context.Context, eventID uuid.UUID) (*Record, error) {
+	requeued, err := d.repo.Requeue(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if !requeued {
+		if _, err := d.GetEvent(ctx, eventID); err != nil {
+			return nil, err
+		}
+		return nil, ErrNotDead
+	}
+	return d.GetEvent(ctx, eventID)
+}
+
+// Discard deletes a dead event for good
+func (d *Dispatcher) Discard(ctx context.Context, eventID uuid.UUID) error {
+	deleted, err := d.repo.DeleteDead(ctx, eventID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		if _, err := d.GetEvent(ctx, eventID); err != nil {
+			return err
+		}
+		return ErrNotDead
+	}
+	return nil
+}
+
+// OldestDead returns when the longest-dead event died, nil when none is dead
+func (d *Dispatcher) OldestDead(ctx context.Context) (*time.Time, error) {
+	return d.repo.OldestDead(ctx)
+}
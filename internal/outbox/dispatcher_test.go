@@ -0,0 +1,122 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/config"
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+func TestDispatcher_Deliver(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := &Dispatcher{cfg: config.OutboxConfig{MaxAttempts: 3}, now: func() time.Time { return now }, consumers: map[string]Consumer{}}
+	var delivered []string
+	record := func(name string, err error) Consumer {
+		return func(ctx context.Context, e events.Event) error {
+			delivered = append(delivered, name)
+			return err
+		}
+	}
+	d.Register("cache", record("cache", nil))
+	d.Register("search", record("search", errors.New("index unavailable")))
+	d.Register("panics", func(ctx context.Context, e events.Event) error { panic("nil map") })
+
+	tests := []struct {
+		name            string
+		failedConsumers []string
+		attempts        int
+		wantStatus      string
+		wantNext        time.Time
+		wantFailed      []string
+		wantDelivered   []string
+	}{
+		{"first attempt delivers to all", nil, 1, StatusPending, now.Add(10 * time.Second), []string{"panics", "search"}, []string{"cache", "search"}},
+		{"retry delivers to failed only", []string{"search"}, 2, StatusPending, now.Add(20 * time.Second), []string{"search"}, []string{"search"}},
+		{"retry succeeds", []string{"cache"}, 2, StatusDispatched, now, nil, []string{"cache"}},
+		{"removed consumer is skipped", []string{"gone"}, 2, StatusDispatched, now, nil, nil},
+		{"last attempt", []string{"search"}, 3, StatusDead, now, []string{"search"}, []string{"search"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delivered = nil
+			rec := &Record{Event: events.Event{ID: uuid.New(), Type: events.EntityCreated}, Attempts: tt.attempts, FailedConsumers: tt.failedConsumers}
+			status, next, failed, lastError := d.deliver(context.Background(), rec)
+			if status != tt.wantStatus || !next.Equal(tt.wantNext) {
+				t.Errorf("got %s at %v, want %s at %v", status, next, tt.wantStatus, tt.wantNext)
+			}
+			if !reflect.DeepEqual(failed, tt.wantFailed) {
+				t.Errorf("failed = %v, want %v", failed, tt.wantFailed)
+			}
+			if !reflect.DeepEqual(delivered, tt.wantDelivered) {
+				t.Errorf("delivered to %v, want %v", delivered, tt.wantDelivered)
+			}
+			if (lastError == "") != (tt.wantFailed == nil) {
+				t.Errorf("lastError = %q with failed %v", lastError, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestDispatcher_ValidateReplay(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	d := &Dispatcher{now: func() time.Time { return now }, consumers: map[string]Consumer{}}
+	d.Register(ConsumerNotify, func(ctx context.Context, e events.Event) error { return nil })
+
+	tests := []struct {
+		name    string
+		req     ReplayRequest
+		wantErr error
+		wantTo  time.Time
+	}{
+		{"to defaults to now", ReplayRequest{Consumer: ConsumerNotify, From: now.Add(-time.Hour)}, nil, now},
+		{"explicit range", ReplayRequest{Consumer: ConsumerNotify, From: now.Add(-2 * time.Hour), To: now.Add(-time.Hour)}, nil, now.Add(-time.Hour)},
+		{"unknown consumer", ReplayRequest{Consumer: "webhooks", From: now.Add(-time.Hour)}, ErrUnknownConsumer, time.Time{}},
+		{"empty range", ReplayRequest{Consumer: ConsumerNotify, From: now.Add(-time.Hour), To: now.Add(-time.Hour)}, ErrInvalidRange, time.Time{}},
+		{"future", ReplayRequest{Consumer: ConsumerNotify, From: now.Add(-time.Hour), To: now.Add(time.Hour)}, ErrInvalidRange, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := d.validateReplay(&tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && !tt.req.To.Equal(tt.wantTo) {
+				t.Errorf("to = %v, want %v", tt.req.To, tt.wantTo)
+			}
+		})
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	entityID := uuid.New()
+	e := events.Event{
+		ID:          uuid.New(),
+		Type:        events.EntityUpdated,
+		TeamID:      uuid.New(),
+		BlueprintID: "service",
+		EntityID:    &entityID,
+		Payload:     json.RawMessage(`{"title":"checkout"}`),
+		OccurredAt:  time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	message, err := envelope(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(message, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["entity_id"] != entityID.String() || got["type"] != events.EntityUpdated || got["blueprint_id"] != "service" {
+		t.Errorf("unexpected envelope %s", message)
+	}
+	if _, ok := got["payload"]; ok {
+		t.Errorf("envelope carries the payload: %s", message)
+	}
+}
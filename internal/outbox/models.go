@@ -0,0 +1,102 @@
+package outbox
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+var (
+	ErrUnknownConsumer = errors.New("no consumer is registered with this name")
+	ErrInvalidRange    = errors.New("invalid range: from must be before to, and neither in the future")
+	ErrNotFound        = errors.New("event not found")
+	ErrNotDead         = errors.New("only dead events can be retried or discarded")
+	ErrInvalidStatus   = errors.New("invalid status: must be pending, dispatched or dead")
+)
+
+// Outbox statuses. Pending events wait for their next attempt; an event
+// whose consumers keep failing is dead once it runs out of attempts.
+const (
+	StatusPending    = "pending"
+	StatusDispatched = "dispatched"
+	StatusDead       = "dead"
+)
+
+// Record is an event in the outbox and the state of its delivery
+type Record struct {
+	// Seq orders the outbox; events are claimed in this order
+	Seq      int64        `json:"-"`
+	Event    events.Event `json:"event"`
+	Status   string       `json:"status"`
+	Attempts int          `json:"attempts"`
+	// NextAttemptAt is when a pending event is due, and when a dead one died
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	// FailedConsumers are the consumers a retry delivers to; nil delivers to all
+	FailedConsumers []string   `json:"failed_consumers,omitempty"`
+	LastError       string     `json:"last_error,omitempty"`
+	DispatchedAt    *time.Time `json:"dispatched_at,omitempty"`
+}
+
+type ListRequest struct {
+	Status string
+	Type   string
+	TeamID *uuid.UUID
+	Limit  int
+	Offset int
+}
+
+type ListResponse struct {
+	Events []*Record `json:"events"`
+	Total  int       `json:"total"`
+	Limit  int       `json:"limit"`
+	Offset int       `json:"offset"`
+}
+
+// Envelope identifies an event without its payload, for consumers such as
+// NOTIFY whose messages are small; the payload is read from the outbox
+type Envelope struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	TeamID      string    `json:"team_id"`
+	BlueprintID string    `json:"blueprint_id,omitempty"`
+	EntityID    string    `json:"entity_id,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+func envelope(e events.Event) ([]byte, error) {
+	env := Envelope{
+		ID:          e.ID.String(),
+		Type:        e.Type,
+		TeamID:      e.TeamID.String(),
+		BlueprintID: e.BlueprintID,
+		OccurredAt:  e.OccurredAt,
+	}
+	if e.EntityID != nil {
+		env.EntityID = e.EntityID.String()
+	}
+	return json.Marshal(env)
+}
+
+// ReplayRequest delivers the outbox events that occurred in [From, To) to
+// one consumer again, optionally only those of a team or of some types
+type ReplayRequest struct {
+	Consumer string     `json:"consumer" binding:"required"`
+	From     time.Time  `json:"from" binding:"required"`
+	To       time.Time  `json:"to"` // defaults to now
+	TeamID   *uuid.UUID `json:"team_id,omitempty"`
+	Types    []string   `json:"types,omitempty"`
+}
+
+// Overview is the state of the outbox for the admin API
+type Overview struct {
+	Consumers []string `json:"consumers"`
+	// Counts are the events by status
+	Counts map[string]int `json:"counts"`
+	// Oldest is when the oldest event still in the outbox occurred; replays
+	// cannot reach further back
+	Oldest *time.Time `json:"oldest,omitempty"`
+}
@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/baseplate/baseplate/internal/events"
+)
+
+// ConsumerNotify is the name of the consumer registered by Notify
+const ConsumerNotify = "notify"
+
+// Notify returns a consumer that announces every event on a PostgreSQL
+// channel. The message is the event's Envelope: NOTIFY payloads are limited
+// to 8000 bytes, so listeners read the payload from the outbox by id.
+func Notify(repo *Repository, channel string) Consumer {
+	return func(ctx context.Context, e events.Event) error {
+		message, err := envelope(e)
+		if err != nil {
+			return err
+		}
+		return repo.Notify(ctx, channel, message)
+	}
+}
@@ -0,0 +1,286 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/baseplate/baseplate/internal/events"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const recordColumns = `
+	id, event_id, type, team_id, COALESCE(blueprint_id, ''), entity_id, payload,
+	actor_user_id, actor_api_key_id, occurred_at, status, attempts, next_attempt_at,
+	failed_consumers, COALESCE(last_error, ''), dispatched_at`
+
+// Claim takes up to limit due pending events, oldest first, and counts the
+// attempt. A claimed event is not due again until the lease ends, so events
+// of a dispatcher that stopped are claimed again by another.
+func (r *Repository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*Record, error) {
+	query := `
+		UPDATE event_outbox
+		SET attempts = attempts + 1, next_attempt_at = NOW() + $1 * INTERVAL '1 second'
+		WHERE id IN (
+			SELECT id FROM event_outbox
+			WHERE status = $2 AND next_attempt_at <= NOW()
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + recordColumns
+	rows, err := r.db.DB.QueryContext(ctx, query, int64(lease/time.Second), StatusPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records, err := scanRecords(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING does not keep the order of the subquery
+	sort.Slice(records, func(i, j int) bool { return records[i].Seq < records[j].Seq })
+	return records, nil
+}
+
+// Finish records the outcome of a claimed attempt: dispatched or dead ends
+// delivery, pending retries the failed consumers at nextAttempt. It returns
+// false when the claim was lost to another dispatcher.
+func (r *Repository) Finish(ctx context.Context, rec *Record, status string, nextAttempt time.Time, failed []string, lastError string) (bool, error) {
+	var failedConsumers interface{}
+	if failed != nil {
+		failedConsumers = failed
+	}
+	query := `
+		UPDATE event_outbox
+		SET status = $3, next_attempt_at = $4, failed_consumers = $5, last_error = NULLIF($6, ''),
+			dispatched_at = CASE WHEN $3 = $7 THEN NOW() ELSE NULL END
+		WHERE id = $1 AND attempts = $2 AND status = $8`
+	result, err := r.db.DB.ExecContext(ctx, query,
+		rec.Seq, rec.Attempts, status, nextAttempt, failedConsumers, lastError, StatusDispatched, StatusPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func (r *Repository) Get(ctx context.Context, eventID uuid.UUID) (*Record, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT `+recordColumns+` FROM event_outbox WHERE event_id = $1`, eventID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records, err := scanRecords(rows)
+	if err != nil || len(records) == 0 {
+		return nil, err
+	}
+	return records[0], nil
+}
+
+// List returns a page of events, newest first, and how many match
+func (r *Repository) List(ctx context.Context, req *ListRequest) ([]*Record, int, error) {
+	var conditions []string
+	var args []interface{}
+	if req.Status != "" {
+		args = append(args, req.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if req.Type != "" {
+		args = append(args, req.Type)
+		conditions = append(conditions, fmt.Sprintf("type = $%d", len(args)))
+	}
+	if req.TeamID != nil {
+		args = append(args, *req.TeamID)
+		conditions = append(conditions, fmt.Sprintf("team_id = $%d", len(args)))
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM event_outbox`+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	args = append(args, req.Limit, req.Offset)
+	query := fmt.Sprintf(`SELECT %s FROM event_outbox%s ORDER BY id DESC LIMIT $%d OFFSET $%d`,
+		recordColumns, where, len(args)-1, len(args))
+	rows, err := r.db.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	records, err := scanRecords(rows)
+	return records, total, err
+}
+
+// Requeue makes a dead event pending again with fresh attempts; it is
+// delivered to the consumers that failed. It returns false when there is no
+// such dead event.
+func (r *Repository) Requeue(ctx context.Context, eventID uuid.UUID) (bool, error) {
+	query := `
+		UPDATE event_outbox
+		SET status = $2, attempts = 0, next_attempt_at = NOW()
+		WHERE event_id = $1 AND status = $3`
+	result, err := r.db.DB.ExecContext(ctx, query, eventID, StatusPending, StatusDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteDead deletes a dead event. It returns false when there is no such dead event.
+func (r *Repository) DeleteDead(ctx context.Context, eventID uuid.UUID) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx,
+		`DELETE FROM event_outbox WHERE event_id = $1 AND status = $2`, eventID, StatusDead)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// OldestDead returns when the longest-dead event died, nil when none is dead
+func (r *Repository) OldestDead(ctx context.Context) (*time.Time, error) {
+	var oldest sql.NullTime
+	err := r.db.DB.QueryRowContext(ctx,
+		`SELECT MIN(next_attempt_at) FROM event_outbox WHERE status = $1`, StatusDead,
+	).Scan(&oldest)
+	if err != nil || !oldest.Valid {
+		return nil, err
+	}
+	return &oldest.Time, nil
+}
+
+// Counts returns how many events there are by status
+func (r *Repository) Counts(ctx context.Context) (map[string]int, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT status, COUNT(*) FROM event_outbox GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{StatusPending: 0, StatusDispatched: 0, StatusDead: 0}
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+	return counts, rows.Err()
+}
+
+// PruneDispatched deletes events dispatched before the given time
+func (r *Repository) PruneDispatched(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.DB.ExecContext(ctx,
+		`DELETE FROM event_outbox WHERE status = $1 AND dispatched_at < $2`, StatusDispatched, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Range returns up to limit events that occurred in [from, to) with a
+// sequence after the given one, in sequence order, whatever their status
+func (r *Repository) Range(ctx context.Context, req *ReplayRequest, after int64, limit int) ([]*Record, error) {
+	query := `SELECT ` + recordColumns + ` FROM event_outbox
+		WHERE occurred_at >= $1 AND occurred_at < $2 AND id > $3
+		  AND ($4::uuid IS NULL OR team_id = $4)
+		  AND ($5::text[] IS NULL OR type = ANY($5))
+		ORDER BY id
+		LIMIT $6`
+	var types interface{}
+	if len(req.Types) > 0 {
+		types = req.Types
+	}
+	rows, err := r.db.DB.QueryContext(ctx, query, req.From, req.To, after, req.TeamID, types, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// Oldest returns when the oldest event in the outbox occurred, nil when it is empty
+func (r *Repository) Oldest(ctx context.Context) (*time.Time, error) {
+	var oldest sql.NullTime
+	if err := r.db.DB.QueryRowContext(ctx, `SELECT MIN(occurred_at) FROM event_outbox`).Scan(&oldest); err != nil {
+		return nil, err
+	}
+	if !oldest.Valid {
+		return nil, nil
+	}
+	return &oldest.Time, nil
+}
+
+// Notify announces a message on a PostgreSQL channel
+func (r *Repository) Notify(ctx context.Context, channel string, message []byte) error {
+	_, err := r.db.DB.ExecContext(ctx, `SELECT pg_notify($1, $2)`, channel, string(message))
+	return err
+}
+
+func scanRecords(rows *sql.Rows) ([]*Record, error) {
+	var records []*Record
+	for rows.Next() {
+		rec := &Record{}
+		var payload []byte
+		var entityID, userID, apiKeyID uuid.NullUUID
+		var failed postgres.StringArray
+		var dispatchedAt sql.NullTime
+		err := rows.Scan(
+			&rec.Seq, &rec.Event.ID, &rec.Event.Type, &rec.Event.TeamID, &rec.Event.BlueprintID, &entityID, &payload,
+			&userID, &apiKeyID, &rec.Event.OccurredAt, &rec.Status, &rec.Attempts, &rec.NextAttemptAt,
+			&failed, &rec.LastError, &dispatchedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if entityID.Valid {
+			rec.Event.EntityID = &entityID.UUID
+		}
+		if payload != nil {
+			rec.Event.Payload = json.RawMessage(payload)
+		}
+		if userID.Valid || apiKeyID.Valid {
+			actor := &events.Actor{}
+			if userID.Valid {
+				actor.UserID = &userID.UUID
+			}
+			if apiKeyID.Valid {
+				actor.APIKeyID = &apiKeyID.UUID
+			}
+			rec.Event.Actor = actor
+		}
+		if failed != nil {
+			rec.FailedConsumers = []string(failed)
+		}
+		if dispatchedAt.Valid {
+			rec.DispatchedAt = &dispatchedAt.Time
+		}
+		records = append(records, rec)
+	}
+	return records, rows.Err()
+}
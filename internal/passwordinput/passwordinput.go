@@ -0,0 +1,24 @@
+// Package passwordinput reads passwords given to the command-line tools on
+// stdin or in a password file.
+package passwordinput
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Read reads the first line of r, stripping the trailing newline
+func Read(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", errors.New("password input is empty")
+	}
+	return password, nil
+}
@@ -0,0 +1,27 @@
+package passwordinput
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{input: "s3cret\n", want: "s3cret"},
+		{input: "s3cret\r\nignored\n", want: "s3cret"},
+		{input: "no newline", want: "no newline"},
+		{input: " spaces kept \n", want: " spaces kept "},
+		{input: "\n", wantErr: true},
+		{input: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := Read(strings.NewReader(tt.input))
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("Read(%q) = %q, %v; want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Package ratelimit provides in-process, per-key request limiting.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// maxIdleBuckets bounds memory: once exceeded, buckets that have refilled are dropped
+const maxIdleBuckets = 10000
+
+// Limiter is a token bucket per key. Each key may burst up to burst requests
+// and then proceeds at perMinute requests per minute.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens per second
+	burst   float64
+	buckets map[string]*bucket
+	now     func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(perMinute, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token for key. When none is available it returns false and
+// how long until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.prune(now)
+		}
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Minute
+	}
+	wait := time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	return false, wait
+}
+
+// Ready reports whether Allow would take a token for key, without taking
+// one, and how long until a token is available when not. It lets callers
+// spend tokens only on failures while refusing callers who ran out.
+func (l *Limiter) Ready(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return true, 0
+	}
+	tokens := math.Min(l.burst, b.tokens+l.now().Sub(b.last).Seconds()*l.rate)
+	if tokens >= 1 {
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Minute
+	}
+	return false, time.Duration((1 - tokens) / l.rate * float64(time.Second))
+}
+
+// prune drops buckets that would be full by now; they behave like new ones
+func (l *Limiter) prune(now time.Time) {
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Concurrency caps the number of in-flight operations per key
+type Concurrency struct {
+	mu    sync.Mutex
+	max   int
+	inUse map[string]int
+}
+
+func NewConcurrency(max int) *Concurrency {
+	return &Concurrency{max: max, inUse: make(map[string]int)}
+}
+
+// TryAcquire reserves a slot for key without waiting. The returned release
+// function must be called exactly once when the operation finishes.
+func (c *Concurrency) TryAcquire(key string) (release func(), ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.inUse[key] >= c.max {
+		return nil, false
+	}
+	c.inUse[key]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.inUse[key]--; c.inUse[key] <= 0 {
+				delete(c.inUse, key)
+			}
+		})
+	}, true
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_BurstThenRefill(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(60, 2) // one token per second
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("team"); !ok {
+			t.Fatalf("request %d should be allowed within burst", i+1)
+		}
+	}
+	ok, wait := l.Allow("team")
+	if ok {
+		t.Fatal("request beyond burst should be denied")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("wait = %v, want (0, 1s]", wait)
+	}
+
+	if ok, _ := l.Allow("other"); !ok {
+		t.Error("keys should be limited independently")
+	}
+
+	now = now.Add(time.Second)
+	if ok, _ := l.Allow("team"); !ok {
+		t.Error("request should be allowed after refill")
+	}
+}
+
+func TestLimiter_Ready(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := NewLimiter(60, 1)
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.Ready("ip"); !ok {
+		t.Fatal("an unseen key should be ready")
+	}
+	if ok, _ := l.Ready("ip"); !ok {
+		t.Fatal("Ready must not take a token")
+	}
+	l.Allow("ip")
+	if ok, wait := l.Ready("ip"); ok || wait <= 0 || wait > time.Second {
+		t.Errorf("got ok=%v wait=%v, want not ready within 1s", ok, wait)
+	}
+	now = now.Add(time.Second)
+	if ok, _ := l.Ready("ip"); !ok {
+		t.Error("key should be ready after refill")
+	}
+}
+
+func TestLimiter_ZeroRate(t *testing.T) {
+	l := NewLimiter(0, 1)
+	l.Allow("k")
+	if ok, wait := l.Allow("k"); ok || wait <= 0 {
+		t.Errorf("zero-rate limiter should deny with a wait, got ok=%v wait=%v", ok, wait)
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	c := NewConcurrency(2)
+
+	r1, ok1 := c.TryAcquire("team")
+	_, ok2 := c.TryAcquire("team")
+	_, ok3 := c.TryAcquire("team")
+	if !ok1 || !ok2 || ok3 {
+		t.Fatalf("acquire results = %v %v %v, want true true false", ok1, ok2, ok3)
+	}
+	if _, ok := c.TryAcquire("other"); !ok {
+		t.Error("keys should be limited independently")
+	}
+
+	r1()
+	r1() // releasing twice must not free a second slot
+	if _, ok := c.TryAcquire("team"); !ok {
+		t.Error("slot should be free after release")
+	}
+	if _, ok := c.TryAcquire("team"); ok {
+		t.Error("double release freed an extra slot")
+	}
+}
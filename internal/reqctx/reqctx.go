@@ -0,0 +1,143 @@
+// Package reqctx carries what is known about the request behind a piece of
+// work through context.Context: how to trace it, who makes it and for which
+// team. Middleware fills it in as it authenticates the request; services and
+// repositories read it from their ctx to attribute audit entries, entity
+// history and events the same way in every subsystem, rather than each
+// handler passing the parts they need. Background work carries none unless
+// it runs on behalf of a request.
+package reqctx
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ActorType is the kind of credential a request was made with
+type ActorType string
+
+const (
+	ActorAnonymous ActorType = "anonymous" // not authenticated (yet)
+	ActorUser      ActorType = "user"
+	ActorAPIKey    ActorType = "api_key"
+	ActorRunner    ActorType = "runner"
+	ActorSystem    ActorType = "system" // background work of the server itself
+)
+
+// maxLocaleLength caps the language tag taken from Accept-Language
+const maxLocaleLength = 35
+
+// RequestContext describes the request a piece of work is done for. IDs are
+// nil when they do not apply, e.g. UserID for API keys without a user.
+type RequestContext struct {
+	RequestID string
+	ActorType ActorType
+	UserID    *uuid.UUID
+	APIKeyID  *uuid.UUID
+	RunnerID  *uuid.UUID
+	// IntegrationID is set when the request is an integration's sync made
+	// with the request's credentials
+	IntegrationID *uuid.UUID
+	// ImpersonatorID is the super admin acting as UserID, if any
+	ImpersonatorID *uuid.UUID
+	TeamID         *uuid.UUID
+	// Permissions are the actor's permissions in TeamID, set once the team
+	// is resolved
+	Permissions []string
+	// Locale is the language tag the client prefers, "" when it sent none
+	Locale    string
+	ClientIP  string
+	UserAgent string
+}
+
+type key struct{}
+
+// With returns ctx carrying rc
+func With(ctx context.Context, rc RequestContext) context.Context {
+	return context.WithValue(ctx, key{}, rc)
+}
+
+// From returns the RequestContext carried by ctx. Work outside of a request
+// gets the zero value and false.
+func From(ctx context.Context) (RequestContext, bool) {
+	rc, ok := ctx.Value(key{}).(RequestContext)
+	return rc, ok
+}
+
+// Update returns ctx carrying a copy of its RequestContext changed by fn.
+// Contexts derived earlier keep the previous values.
+func Update(ctx context.Context, fn func(rc *RequestContext)) context.Context {
+	rc, ok := From(ctx)
+	if !ok {
+		rc.ActorType = ActorAnonymous
+	}
+	fn(&rc)
+	return With(ctx, rc)
+}
+
+// System returns ctx marked as the server's own work, for background jobs
+// and tasks that write on no one's behalf
+func System(ctx context.Context) context.Context {
+	return With(ctx, RequestContext{ActorType: ActorSystem})
+}
+
+// RequestID returns the ID of the request behind ctx, "" outside of one
+func RequestID(ctx context.Context) string {
+	rc, _ := From(ctx)
+	return rc.RequestID
+}
+
+// TeamID returns the team of the request behind ctx
+func TeamID(ctx context.Context) (uuid.UUID, bool) {
+	rc, _ := From(ctx)
+	if rc.TeamID == nil {
+		return uuid.Nil, false
+	}
+	return *rc.TeamID, true
+}
+
+// Can reports whether the actor holds a team permission
+func (rc RequestContext) Can(permission string) bool {
+	return slices.Contains(rc.Permissions, permission)
+}
+
+// LogAttrs returns the attributes that tie a log line to the request
+func (rc RequestContext) LogAttrs() []slog.Attr {
+	var attrs []slog.Attr
+	if rc.RequestID != "" {
+		attrs = append(attrs, slog.String("request_id", rc.RequestID))
+	}
+	if rc.ActorType != "" {
+		attrs = append(attrs, slog.String("actor_type", string(rc.ActorType)))
+	}
+	if rc.UserID != nil {
+		attrs = append(attrs, slog.String("user_id", rc.UserID.String()))
+	}
+	if rc.APIKeyID != nil {
+		attrs = append(attrs, slog.String("api_key_id", rc.APIKeyID.String()))
+	}
+	if rc.TeamID != nil {
+		attrs = append(attrs, slog.String("team_id", rc.TeamID.String()))
+	}
+	return attrs
+}
+
+// ParseLocale returns the first language tag of an Accept-Language header,
+// or "" when it has none that is well-formed
+func ParseLocale(header string) string {
+	tag, _, _ := strings.Cut(header, ",")
+	tag, _, _ = strings.Cut(tag, ";")
+	tag = strings.TrimSpace(tag)
+	if tag == "" || tag == "*" || len(tag) > maxLocaleLength {
+		return ""
+	}
+	for _, r := range tag {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+			return ""
+		}
+	}
+	return tag
+}
@@ -0,0 +1,96 @@
+package reqctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdate(t *testing.T) {
+	userID, teamID := uuid.New(), uuid.New()
+	base := With(context.Background(), RequestContext{RequestID: "req-1", ActorType: ActorAnonymous})
+	authenticated := Update(base, func(rc *RequestContext) {
+		rc.ActorType, rc.UserID = ActorUser, &userID
+	})
+	scoped := Update(authenticated, func(rc *RequestContext) {
+		rc.TeamID = &teamID
+	})
+
+	rc, ok := From(scoped)
+	if !ok || rc.RequestID != "req-1" || rc.ActorType != ActorUser || *rc.UserID != userID || *rc.TeamID != teamID {
+		t.Errorf("From(scoped) = %+v, %v", rc, ok)
+	}
+	if rc, _ := From(authenticated); rc.TeamID != nil {
+		t.Errorf("earlier context got team %v", rc.TeamID)
+	}
+	if rc, _ := From(base); rc.UserID != nil || rc.ActorType != ActorAnonymous {
+		t.Errorf("base context changed: %+v", rc)
+	}
+	if got, ok := TeamID(scoped); !ok || got != teamID {
+		t.Errorf("TeamID = %v, %v", got, ok)
+	}
+}
+
+func TestUpdate_WithoutRequest(t *testing.T) {
+	ctx := Update(context.Background(), func(rc *RequestContext) {})
+	rc, ok := From(ctx)
+	if !ok || rc.ActorType != ActorAnonymous || rc.RequestID != "" {
+		t.Errorf("From = %+v, %v", rc, ok)
+	}
+	if _, ok := From(context.Background()); ok {
+		t.Error("background context has a request context")
+	}
+	if rc, _ := From(System(context.Background())); rc.ActorType != ActorSystem {
+		t.Errorf("System actor type = %q", rc.ActorType)
+	}
+}
+
+func TestCan(t *testing.T) {
+	rc := RequestContext{Permissions: []string{"entity:read", "entity:write"}}
+	if !rc.Can("entity:write") || rc.Can("entity:delete") {
+		t.Errorf("Can with %v", rc.Permissions)
+	}
+	if (RequestContext{}).Can("entity:read") {
+		t.Error("context without permissions can read")
+	}
+}
+
+func TestLogAttrs(t *testing.T) {
+	userID := uuid.New()
+	attrs := RequestContext{RequestID: "req-1", ActorType: ActorUser, UserID: &userID}.LogAttrs()
+	got := make(map[string]string)
+	for _, a := range attrs {
+		got[a.Key] = a.Value.String()
+	}
+	want := map[string]string{"request_id": "req-1", "actor_type": "user", "user_id": userID.String()}
+	if len(got) != len(want) {
+		t.Fatalf("LogAttrs = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if attrs := (RequestContext{}).LogAttrs(); len(attrs) != 0 {
+		t.Errorf("empty context has attrs %v", attrs)
+	}
+}
+
+func TestParseLocale(t *testing.T) {
+	tests := map[string]string{
+		"":                              "",
+		"de":                            "de",
+		"en-US,en;q=0.9":                "en-US",
+		"fr-CH;q=0.9, fr;q=0.8":         "fr-CH",
+		"  pt-BR ":                      "pt-BR",
+		"*":                             "",
+		"en_US":                         "",
+		"x-" + string(make([]byte, 40)): "",
+	}
+	for header, want := range tests {
+		if got := ParseLocale(header); got != want {
+			t.Errorf("ParseLocale(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package status
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/cache"
+	"github.com/baseplate/baseplate/internal/core/auth"
+	"github.com/baseplate/baseplate/internal/core/blueprint"
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/runner"
+	"github.com/baseplate/baseplate/internal/dlq"
+	"github.com/baseplate/baseplate/internal/jobs"
+	"github.com/baseplate/baseplate/internal/outbox"
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+	"github.com/baseplate/baseplate/internal/tasks"
+)
+
+// backlogDegraded is the fill ratio at which a queue is reported as degraded
+const backlogDegraded = 0.9
+
+// Database pings PostgreSQL and its read replica, if any, and reports
+// connection pool usage. An unreachable replica degrades the component.
+func Database(db *postgres.Client) Check {
+	return func(ctx context.Context) Component {
+		start := time.Now()
+		err := db.DB.PingContext(ctx)
+		latency := time.Since(start).Milliseconds()
+		if err != nil {
+			log.Printf("ERROR: status: database ping failed: %v", err)
+			return Component{Status: StateDown, Message: "database is unreachable"}
+		}
+		stats := db.DB.Stats()
+		component := Component{
+			Status:    StateOK,
+			LatencyMS: &latency,
+			Details: map[string]interface{}{
+				"open_connections": stats.OpenConnections,
+				"in_use":           stats.InUse,
+			},
+		}
+		if db.Replica == nil {
+			return component
+		}
+
+		replica := db.Replica.Stats()
+		component.Details["replica_open_connections"] = replica.OpenConnections
+		component.Details["replica_in_use"] = replica.InUse
+		if err := db.Replica.PingContext(ctx); err != nil {
+			log.Printf("ERROR: status: read replica ping failed: %v", err)
+			component.Status = StateDegraded
+			component.Message = "the read replica is unreachable"
+		}
+		return component
+	}
+}
+
+// Cache reports the in-process search and permission caches and the lookup
+// cache store, pinging it; any of them may be nil
+func Cache(search *entity.SearchCache, permissions *auth.PermissionCache, lookups cache.Store) Check {
+	return func(ctx context.Context) Component {
+		if search == nil && permissions == nil && lookups == nil {
+			return Component{Status: StateDisabled}
+		}
+		details := map[string]interface{}{}
+		if search != nil {
+			details["search_entries"] = search.Len()
+		}
+		if permissions != nil {
+			details["permission_entries"] = permissions.Len()
+		}
+		if lookups == nil {
+			return Component{Status: StateOK, Details: details}
+		}
+		details["lookup_backend"] = lookups.Backend()
+		if memory, ok := lookups.(*cache.Memory); ok {
+			details["lookup_entries"] = memory.Len()
+		}
+		if err := lookups.Ping(ctx); err != nil {
+			log.Printf("ERROR: status: lookup cache ping failed: %v", err)
+			return Component{Status: StateDegraded, Message: "the lookup cache is unreachable", Details: details}
+		}
+		return Component{Status: StateOK, Details: details}
+	}
+}
+
+// Queue reports the rollup update queue, which may be nil
+func Queue(rollups *entity.RollupMaintainer) Check {
+	return func(ctx context.Context) Component {
+		if rollups == nil {
+			return Component{Status: StateDisabled}
+		}
+		queued, capacity, err := rollups.Backlog()
+		component := Component{
+			Status:  StateOK,
+			Details: map[string]interface{}{"queued": queued, "capacity": capacity},
+		}
+		switch {
+		case err != nil:
+			component.Status, component.Message = StateDegraded, "the last rollup update failed"
+		case float64(queued) >= backlogDegraded*float64(capacity):
+			component.Status, component.Message = StateDegraded, "the rollup queue is nearly full"
+		}
+		return component
+	}
+}
+
+// Jobs reports the background job queue: how many jobs wait, run and are
+// dead, and whether this instance's workers can claim jobs
+func Jobs(queue *jobs.Queue) Check {
+	return func(ctx context.Context) Component {
+		counts, err := queue.Counts(ctx)
+		if err != nil {
+			log.Printf("ERROR: status: job counts failed: %v", err)
+			return Component{Status: StateDegraded, Message: "job counts are unavailable"}
+		}
+		component := Component{
+			Status: StateOK,
+			Details: map[string]interface{}{
+				"workers": queue.Workers(),
+				"pending": counts[jobs.StatusPending],
+				"running": counts[jobs.StatusRunning],
+				"dead":    counts[jobs.StatusDead],
+			},
+		}
+		if queue.LastError() != nil {
+			component.Status, component.Message = StateDegraded, "the last job claim failed"
+		}
+		return component
+	}
+}
+
+// Outbox reports the event outbox backlog and whether the last dispatch failed
+func Outbox(dispatcher *outbox.Dispatcher) Check {
+	return func(ctx context.Context) Component {
+		counts, err := dispatcher.Counts(ctx)
+		if err != nil {
+			log.Printf("ERROR: status: outbox counts failed: %v", err)
+			return Component{Status: StateDegraded, Message: "outbox counts are unavailable"}
+		}
+		component := Component{
+			Status: StateOK,
+			Details: map[string]interface{}{
+				"consumers": dispatcher.Consumers(),
+				"pending":   counts[outbox.StatusPending],
+				"dead":      counts[outbox.StatusDead],
+			},
+		}
+		if dispatcher.LastError() != nil {
+			component.Status, component.Message = StateDegraded, "the last event dispatch failed"
+		}
+		return component
+	}
+}
+
+// DeadLetters reports the dead jobs and events, degraded once any has been
+// dead for longer than the alert threshold
+func DeadLetters(service *dlq.Service) Check {
+	return func(ctx context.Context) Component {
+		summary, err := service.Summary(ctx)
+		if err != nil {
+			log.Printf("ERROR: status: dead letter summary failed: %v", err)
+			return Component{Status: StateDegraded, Message: "dead letters are unavailable"}
+		}
+		component := Component{
+			Status: StateOK,
+			Details: map[string]interface{}{
+				"dead_jobs":   summary.Jobs.Dead,
+				"dead_events": summary.Events.Dead,
+			},
+		}
+		if summary.Alerting {
+			component.Status = StateDegraded
+			component.Message = fmt.Sprintf("dead letters are older than %d hours", summary.AlertAfterHours)
+		}
+		return component
+	}
+}
+
+// Schedules reports whether the last pass of the action scheduler failed
+func Schedules(scheduler *runner.Scheduler) Check {
+	return func(ctx context.Context) Component {
+		if scheduler.LastError() != nil {
+			return Component{Status: StateDegraded, Message: "the last schedule pass failed"}
+		}
+		return Component{Status: StateOK}
+	}
+}
+
+// Tasks reports the built-in maintenance tasks whose last run failed, and
+// whether the last pass of the task engine failed
+func Tasks(engine *tasks.Engine) Check {
+	return func(ctx context.Context) Component {
+		list, err := engine.List(ctx)
+		if err != nil {
+			log.Printf("ERROR: status: listing tasks failed: %v", err)
+			return Component{Status: StateDegraded, Message: "tasks are unavailable"}
+		}
+		failed := []string{}
+		for _, task := range list.Tasks {
+			if task.Enabled && task.LastStatus == tasks.StatusFailed {
+				failed = append(failed, task.Name)
+			}
+		}
+		component := Component{
+			Status:  StateOK,
+			Details: map[string]interface{}{"tasks": list.Total, "failed": failed},
+		}
+		switch {
+		case engine.LastError() != nil:
+			component.Status, component.Message = StateDegraded, "the last task pass failed"
+		case len(failed) > 0:
+			component.Status, component.Message = StateDegraded, "a task failed its last run"
+		}
+		return component
+	}
+}
+
+// Search reports the entity search backend. Searches run on PostgreSQL, so
+// only index maintenance, when enabled, can fail on its own.
+func Search(indexes *blueprint.IndexMaintainer) Check {
+	return func(ctx context.Context) Component {
+		component := Component{
+			Status:  StateOK,
+			Details: map[string]interface{}{"backend": "postgres", "index_maintenance": indexes != nil},
+		}
+		if indexes.LastError() != nil {
+			component.Status, component.Message = StateDegraded, "the last index maintenance run failed"
+		}
+		return component
+	}
+}
+
+// Integrations reports which built-in integrations are enabled
+func Integrations(enabled map[string]bool) Check {
+	return func(ctx context.Context) Component {
+		details := make(map[string]interface{}, len(enabled))
+		for name, on := range enabled {
+			state := StateDisabled
+			if on {
+				state = StateOK
+			}
+			details[name] = state
+		}
+		return Component{Status: StateOK, Details: details}
+	}
+}
@@ -0,0 +1,133 @@
+// Package status reports the health of the server and the components it
+// depends on, for uptime monitors and the UI.
+package status
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type State string
+
+const (
+	StateOK       State = "ok"
+	StateDegraded State = "degraded"
+	StateDown     State = "down"
+	StateDisabled State = "disabled"
+)
+
+const (
+	// checkTimeout bounds each component check
+	checkTimeout = 2 * time.Second
+
+	// reportTTL is how long a report is reused; the endpoint is public, so
+	// polling it must not turn into a database ping per request
+	reportTTL = 5 * time.Second
+)
+
+// Component is the health of one dependency. Messages are safe to show to
+// anonymous callers; error details are logged instead.
+type Component struct {
+	Name      string                 `json:"name"`
+	Status    State                  `json:"status"`
+	Message   string                 `json:"message,omitempty"`
+	LatencyMS *int64                 `json:"latency_ms,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
+
+// Report is the overall status. Status is down when a critical component is
+// down, degraded when any other component is degraded or down, and ok otherwise.
+type Report struct {
+	Status        State       `json:"status"`
+	Version       string      `json:"version"`
+	StartedAt     time.Time   `json:"started_at"`
+	UptimeSeconds int64       `json:"uptime_seconds"`
+	CheckedAt     time.Time   `json:"checked_at"`
+	Components    []Component `json:"components"`
+}
+
+// Check reports the health of one component. Name is filled in by the Service.
+type Check func(ctx context.Context) Component
+
+type registeredCheck struct {
+	name     string
+	critical bool
+	check    Check
+}
+
+type Service struct {
+	version   string
+	startedAt time.Time
+	now       func() time.Time
+	checks    []registeredCheck
+
+	mu       sync.Mutex
+	cached   *Report
+	cachedAt time.Time
+}
+
+func NewService(version string, startedAt time.Time) *Service {
+	return &Service{version: version, startedAt: startedAt, now: time.Now}
+}
+
+// Register adds a component check. The server is down when a critical
+// component is down.
+func (s *Service) Register(name string, critical bool, check Check) {
+	s.checks = append(s.checks, registeredCheck{name: name, critical: critical, check: check})
+}
+
+// Report runs every check concurrently, reusing a report younger than reportTTL
+func (s *Service) Report(ctx context.Context) *Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.cached != nil && now.Sub(s.cachedAt) < reportTTL {
+		report := *s.cached
+		report.UptimeSeconds = int64(now.Sub(s.startedAt).Seconds())
+		return &report
+	}
+
+	components := make([]Component, len(s.checks))
+	var wg sync.WaitGroup
+	for i, rc := range s.checks {
+		wg.Add(1)
+		go func(i int, rc registeredCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			component := rc.check(checkCtx)
+			component.Name = rc.name
+			components[i] = component
+		}(i, rc)
+	}
+	wg.Wait()
+
+	report := &Report{
+		Status:        overall(s.checks, components),
+		Version:       s.version,
+		StartedAt:     s.startedAt.UTC(),
+		UptimeSeconds: int64(now.Sub(s.startedAt).Seconds()),
+		CheckedAt:     now.UTC(),
+		Components:    components,
+	}
+	s.cached, s.cachedAt = report, now
+	return report
+}
+
+func overall(checks []registeredCheck, components []Component) State {
+	state := StateOK
+	for i, component := range components {
+		switch component.Status {
+		case StateDown:
+			if checks[i].critical {
+				return StateDown
+			}
+			state = StateDegraded
+		case StateDegraded:
+			state = StateDegraded
+		}
+	}
+	return state
+}
@@ -0,0 +1,69 @@
+package status
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func fixed(state State) Check {
+	return func(ctx context.Context) Component { return Component{Status: state} }
+}
+
+func TestReport_Overall(t *testing.T) {
+	tests := []struct {
+		name     string
+		database State
+		queue    State
+		want     State
+	}{
+		{"healthy", StateOK, StateOK, StateOK},
+		{"disabled is healthy", StateOK, StateDisabled, StateOK},
+		{"optional component down", StateOK, StateDown, StateDegraded},
+		{"optional component degraded", StateOK, StateDegraded, StateDegraded},
+		{"critical component down", StateDown, StateOK, StateDown},
+		{"critical component degraded", StateDegraded, StateOK, StateDegraded},
+	}
+	for _, tt := range tests {
+		s := NewService("test", time.Now())
+		s.Register("database", true, fixed(tt.database))
+		s.Register("queue", false, fixed(tt.queue))
+
+		report := s.Report(context.Background())
+		if report.Status != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, report.Status, tt.want)
+		}
+		if len(report.Components) != 2 || report.Components[0].Name != "database" || report.Components[1].Name != "queue" {
+			t.Errorf("%s: components = %+v", tt.name, report.Components)
+		}
+	}
+}
+
+func TestReport_ReusedWithinTTL(t *testing.T) {
+	started := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := started.Add(time.Minute)
+	calls := 0
+
+	s := NewService("test", started)
+	s.now = func() time.Time { return now }
+	s.Register("database", true, func(ctx context.Context) Component {
+		calls++
+		return Component{Status: StateOK}
+	})
+
+	s.Report(context.Background())
+	now = now.Add(reportTTL / 2)
+	report := s.Report(context.Background())
+	if calls != 1 {
+		t.Errorf("checks ran %d times within the TTL, want 1", calls)
+	}
+	if report.UptimeSeconds != int64((time.Minute + reportTTL/2).Seconds()) {
+		t.Errorf("uptime = %d, want it to advance for reused reports", report.UptimeSeconds)
+	}
+
+	now = now.Add(reportTTL)
+	s.Report(context.Background())
+	if calls != 2 {
+		t.Errorf("checks ran %d times after the TTL, want 2", calls)
+	}
+}
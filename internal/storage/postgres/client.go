@@ -3,41 +3,144 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 
 	"github.com/baseplate/baseplate/config"
 )
 
+// Client holds the connection pools. Pool is the pgx pool, for COPY and
+// other pgx-only features; DB is a database/sql handle drawing connections
+// from the same pool, used by most repositories.
 type Client struct {
-	DB *sql.DB
+	Pool *pgxpool.Pool
+	DB   *sql.DB
+	// Replica serves reads that can tolerate replication lag; nil when no
+	// replica is configured, in which case every query goes to DB
+	Replica     *sql.DB
+	replicaPool *pgxpool.Pool
+	// queryTimeout bounds the queries run under WithQueryTimeout
+	queryTimeout time.Duration
+}
+
+type primaryKey struct{}
+
+// WithPrimary marks a context so Reader returns the primary. Use it for reads
+// that must see the request's own writes, such as read-modify-write updates.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// UsesPrimary reports whether WithPrimary was applied to ctx
+func UsesPrimary(ctx context.Context) bool {
+	forced, _ := ctx.Value(primaryKey{}).(bool)
+	return forced
 }
 
 func NewClient(cfg *config.DatabaseConfig) (*Client, error) {
-	db, err := sql.Open("postgres", cfg.ConnectionString())
+	pool, err := open(cfg.ConnectionString(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{Pool: pool, DB: stdlib.OpenDBFromPool(pool), queryTimeout: cfg.QueryTimeout()}
+
+	if cfg.ReplicaURL != "" {
+		replica, err := open(cfg.ReplicaURL, cfg)
+		if err != nil {
+			client.Close()
+			return nil, fmt.Errorf("read replica: %w", err)
+		}
+		client.replicaPool = replica
+		client.Replica = stdlib.OpenDBFromPool(replica)
+	}
+
+	return client, nil
+}
+
+// open connects a pool. Statements are prepared once per connection and
+// reused while they stay in its cache. Unless disabled, every connection
+// starts with the configured statement_timeout.
+func open(dsn string, cfg *config.DatabaseConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	poolConfig.MaxConns = 25
+	poolConfig.MaxConnLifetime = 5 * time.Minute
+	poolConfig.MaxConnIdleTime = 1 * time.Minute
+	poolConfig.ConnConfig.StatementCacheCapacity = cfg.StatementCacheSize
+	if cfg.StatementCacheSize == 0 {
+		poolConfig.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+	}
+	if cfg.StatementTimeoutSeconds > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout().Milliseconds(), 10)
+	}
 
-	if err := db.Ping(); err != nil {
-		db.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
+	return pool, nil
+}
 
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
-	db.SetConnMaxIdleTime(1 * time.Minute)
+// Reader returns the pool for read-only queries: the replica when one is
+// configured and ctx was not marked WithPrimary, the primary otherwise
+func (c *Client) Reader(ctx context.Context) *sql.DB {
+	if c.Replica == nil || UsesPrimary(ctx) {
+		return c.DB
+	}
+	return c.Replica
+}
 
-	return &Client{DB: db}, nil
+// WithQueryTimeout bounds the queries run with the returned context by the
+// configured query timeout. Repositories wrap their expensive catalog reads
+// with it; the caller must call cancel.
+func (c *Client) WithQueryTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.queryTimeout)
 }
 
 func (c *Client) Close() error {
-	return c.DB.Close()
+	if c.Replica != nil {
+		c.Replica.Close()
+		c.replicaPool.Close()
+	}
+	err := c.DB.Close()
+	c.Pool.Close()
+	return err
 }
 
 func (c *Client) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
 	return c.DB.BeginTx(ctx, opts)
 }
+
+// IsTimeout reports whether err is a query that ran out of time: its context
+// deadline passed, or the server cancelled it after statement_timeout
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "57014"
+}
+
+// IsUniqueViolation reports whether err is a unique constraint violation
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
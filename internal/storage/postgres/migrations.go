@@ -0,0 +1,255 @@
+package postgres
+
+import (
+	"context"
+)
+
+// Migration describes a schema migration shipped in the migrations directory.
+// Migrations are applied by the Docker init scripts or `make migrate`, so there is
+// no bookkeeping table; instead each migration has a probe query that returns true
+// once the objects it creates are present.
+type Migration struct {
+	Version string
+	Name    string
+	Probe   string
+}
+
+// Migrations lists every migration in order. Append new migrations here.
+var Migrations = []Migration{
+	{
+		Version: "001",
+		Name:    "initial",
+		Probe:   `SELECT to_regclass('public.entities') IS NOT NULL AND to_regclass('public.audit_logs') IS NOT NULL`,
+	},
+	{
+		Version: "002",
+		Name:    "super_admin",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'is_super_admin')`,
+	},
+	{
+		Version: "003",
+		Name:    "entity_rollups",
+		Probe:   `SELECT to_regclass('public.entity_rollups') IS NOT NULL AND to_regclass('public.entity_rollup_state') IS NOT NULL`,
+	},
+	{
+		Version: "004",
+		Name:    "entity_views",
+		Probe:   `SELECT to_regclass('public.entity_views') IS NOT NULL`,
+	},
+	{
+		Version: "005",
+		Name:    "entity_versions",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'entities' AND column_name = 'version')`,
+	},
+	{
+		Version: "006",
+		Name:    "entity_history",
+		Probe:   `SELECT to_regclass('public.entity_history') IS NOT NULL`,
+	},
+	{
+		Version: "007",
+		Name:    "email_verification",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'pending_email')`,
+	},
+	{
+		Version: "008",
+		Name:    "user_lifecycle",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'sessions_revoked_at')`,
+	},
+	{
+		Version: "009",
+		Name:    "property_usage",
+		Probe:   `SELECT to_regclass('public.property_usage') IS NOT NULL`,
+	},
+	{
+		Version: "010",
+		Name:    "identifier_mutability",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'blueprints' AND column_name = 'identifier_mutable')`,
+	},
+	{
+		Version: "011",
+		Name:    "entity_ownership",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'entities' AND column_name = 'integration_id')`,
+	},
+	{
+		Version: "012",
+		Name:    "two_factor",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_name = 'user_backup_codes')`,
+	},
+	{
+		Version: "013",
+		Name:    "property_sources",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'entities' AND column_name = 'property_sources')`,
+	},
+	{
+		Version: "014",
+		Name:    "impersonation",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_name = 'impersonation_sessions')`,
+	},
+	{
+		Version: "015",
+		Name:    "team_request_stats",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_name = 'team_request_stats')`,
+	},
+	{
+		Version: "016",
+		Name:    "team_deletion",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'teams' AND column_name = 'deletion_token_hash')`,
+	},
+	{
+		Version: "017",
+		Name:    "team_secrets",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.tables WHERE table_name = 'team_secrets')`,
+	},
+	{
+		Version: "018",
+		Name:    "action_runners",
+		Probe:   `SELECT to_regclass('public.runners') IS NOT NULL AND to_regclass('public.action_run_logs') IS NOT NULL`,
+	},
+	{
+		Version: "019",
+		Name:    "runner_fleet",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'runners' AND column_name = 'hostname')`,
+	},
+	{
+		Version: "020",
+		Name:    "entity_expiry",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'entities' AND column_name = 'expires_at')`,
+	},
+	{
+		Version: "021",
+		Name:    "action_run_limits",
+		Probe:   `SELECT to_regclass('public.idx_action_runs_action') IS NOT NULL`,
+	},
+	{
+		Version: "022",
+		Name:    "jobs",
+		Probe:   `SELECT to_regclass('public.jobs') IS NOT NULL`,
+	},
+	{
+		Version: "023",
+		Name:    "action_schedules",
+		Probe:   `SELECT to_regclass('public.action_schedules') IS NOT NULL`,
+	},
+	{
+		Version: "024",
+		Name:    "system_tasks",
+		Probe:   `SELECT to_regclass('public.system_tasks') IS NOT NULL`,
+	},
+	{
+		Version: "025",
+		Name:    "event_outbox",
+		Probe:   `SELECT to_regclass('public.event_outbox') IS NOT NULL`,
+	},
+	{
+		Version: "026",
+		Name:    "entity_exports",
+		Probe:   `SELECT to_regclass('public.entity_exports') IS NOT NULL`,
+	},
+	{
+		Version: "027",
+		Name:    "entity_aliases",
+		Probe:   `SELECT to_regclass('public.entity_aliases') IS NOT NULL`,
+	},
+	{
+		Version: "028",
+		Name:    "blueprint_presentations",
+		Probe:   `SELECT to_regclass('public.blueprint_presentations') IS NOT NULL`,
+	},
+	{
+		Version: "029",
+		Name:    "catalog_docs",
+		Probe:   `SELECT to_regclass('public.catalog_doc_versions') IS NOT NULL`,
+	},
+	{
+		Version: "030",
+		Name:    "entity_sequences",
+		Probe:   `SELECT to_regclass('public.entity_sequences') IS NOT NULL`,
+	},
+	{
+		Version: "031",
+		Name:    "strict_updates",
+		Probe:   `SELECT EXISTS(SELECT 1 FROM information_schema.columns WHERE table_name = 'blueprints' AND column_name = 'strict_updates')`,
+	},
+	{
+		Version: "032",
+		Name:    "scorecard_results",
+		Probe:   `SELECT to_regclass('public.scorecard_results') IS NOT NULL`,
+	},
+	{
+		Version: "033",
+		Name:    "notifications",
+		Probe:   `SELECT to_regclass('public.notification_subscriptions') IS NOT NULL`,
+	},
+	{
+		Version: "034",
+		Name:    "admin_search",
+		Probe:   `SELECT to_regclass('public.idx_entities_title_trgm') IS NOT NULL`,
+	},
+}
+
+// RequiredExtensions lists the PostgreSQL extensions the schema depends on
+var RequiredExtensions = []string{"uuid-ossp", "pg_trgm"}
+
+// RequiredIndexes lists indexes that hot query paths rely on
+var RequiredIndexes = []string{
+	"idx_entities_team",
+	"idx_entities_blueprint",
+	"idx_entities_data",
+	"idx_api_keys_hash",
+	"idx_team_memberships_user",
+	"idx_team_memberships_team",
+	"idx_users_super_admin",
+	"idx_audit_logs_actor_type",
+	"idx_entity_history_entity",
+}
+
+type MigrationState struct {
+	Migration
+	Applied bool
+}
+
+// MigrationStatus probes every known migration and reports whether it has been applied
+func (c *Client) MigrationStatus(ctx context.Context) ([]MigrationState, error) {
+	states := make([]MigrationState, 0, len(Migrations))
+	for _, m := range Migrations {
+		var applied bool
+		if err := c.DB.QueryRowContext(ctx, m.Probe).Scan(&applied); err != nil {
+			return nil, err
+		}
+		states = append(states, MigrationState{Migration: m, Applied: applied})
+	}
+	return states, nil
+}
+
+// MissingExtensions returns the required extensions that are not installed
+func (c *Client) MissingExtensions(ctx context.Context) ([]string, error) {
+	var missing []string
+	for _, ext := range RequiredExtensions {
+		var exists bool
+		query := `SELECT EXISTS(SELECT 1 FROM pg_extension WHERE extname = $1)`
+		if err := c.DB.QueryRowContext(ctx, query, ext).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, ext)
+		}
+	}
+	return missing, nil
+}
+
+// MissingIndexes returns the required indexes that do not exist in the public schema
+func (c *Client) MissingIndexes(ctx context.Context) ([]string, error) {
+	var missing []string
+	for _, idx := range RequiredIndexes {
+		var exists bool
+		query := `SELECT EXISTS(SELECT 1 FROM pg_indexes WHERE schemaname = 'public' AND indexname = $1)`
+		if err := c.DB.QueryRowContext(ctx, query, idx).Scan(&exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			missing = append(missing, idx)
+		}
+	}
+	return missing, nil
+}
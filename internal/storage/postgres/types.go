@@ -0,0 +1,94 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StringArray scans a one-dimensional text array. Slices can be passed as
+// query arguments directly; only scanning needs this type.
+type StringArray []string
+
+func (a *StringArray) Scan(src interface{}) error {
+	var text string
+	switch v := src.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringArray", src)
+	}
+
+	if len(text) < 2 || text[0] != '{' || text[len(text)-1] != '}' {
+		return fmt.Errorf("invalid array %q", text)
+	}
+	body := text[1 : len(text)-1]
+	out := StringArray{}
+	if body == "" {
+		*a = out
+		return nil
+	}
+
+	var element strings.Builder
+	quoted, wasQuoted, escaped := false, false, false
+	for i := 0; i < len(body); i++ {
+		ch := body[i]
+		switch {
+		case escaped:
+			element.WriteByte(ch)
+			escaped = false
+		case ch == '\\':
+			escaped = true
+		case ch == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case ch == ',' && !quoted:
+			out = appendElement(out, element.String(), wasQuoted)
+			element.Reset()
+			wasQuoted = false
+		default:
+			element.WriteByte(ch)
+		}
+	}
+	if quoted || escaped {
+		return fmt.Errorf("invalid array %q", text)
+	}
+	*a = appendElement(out, element.String(), wasQuoted)
+	return nil
+}
+
+// appendElement adds an element; an unquoted NULL is a null element, which
+// has no representation in []string and becomes ""
+func appendElement(out StringArray, element string, quoted bool) StringArray {
+	if !quoted && element == "NULL" {
+		element = ""
+	}
+	return append(out, element)
+}
+
+// QuoteIdentifier quotes a name for use as an SQL identifier
+func QuoteIdentifier(name string) string {
+	return pgx.Identifier{name}.Sanitize()
+}
+
+// QuoteLiteral quotes a string for use as an SQL literal, for statements
+// such as CREATE INDEX that cannot take parameters
+func QuoteLiteral(literal string) string {
+	literal = strings.ReplaceAll(literal, `'`, `''`)
+	if strings.Contains(literal, `\`) {
+		return ` E'` + strings.ReplaceAll(literal, `\`, `\\`) + `'`
+	}
+	return `'` + literal + `'`
+}
+
+// EscapeLike escapes the LIKE wildcards and escape character of s, so a
+// LIKE or ILIKE pattern built from it matches s literally
+func EscapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStringArray_Scan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want StringArray
+	}{
+		{nil, nil},
+		{"{}", StringArray{}},
+		{"{a,b}", StringArray{"a", "b"}},
+		{`{"a b","c,d","e\"f","g\\h",""}`, StringArray{"a b", "c,d", `e"f`, `g\h`, ""}},
+		{[]byte(`{x,NULL,"NULL"}`), StringArray{"x", "", "NULL"}},
+	}
+	for _, tt := range tests {
+		var got StringArray
+		if err := got.Scan(tt.src); err != nil {
+			t.Errorf("Scan(%v): %v", tt.src, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Scan(%v) = %#v, want %#v", tt.src, got, tt.want)
+		}
+	}
+
+	for _, bad := range []interface{}{"a,b", `{"a}`, 42} {
+		var got StringArray
+		if err := got.Scan(bad); err == nil {
+			t.Errorf("Scan(%v) = %v, want an error", bad, got)
+		}
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got := QuoteIdentifier(`idx_"x"`); got != `"idx_""x"""` {
+		t.Errorf("QuoteIdentifier = %s", got)
+	}
+	if got := QuoteLiteral("it's"); got != `'it''s'` {
+		t.Errorf("QuoteLiteral = %s", got)
+	}
+	if got := QuoteLiteral(`a\b`); got != ` E'a\\b'` {
+		t.Errorf("QuoteLiteral = %s", got)
+	}
+}
+
+func TestEscapeLike(t *testing.T) {
+	if got, want := EscapeLike(`100%_a\b`), `100\%\_a\\b`; got != want {
+		t.Errorf("EscapeLike() = %q, want %q", got, want)
+	}
+	if got, want := EscapeLike(`50%_off\`), `50\%\_off\\`; got != want {
+		t.Errorf("EscapeLike() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,118 @@
+package tasks
+
+import (
+	"context"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/core/advisor"
+	"github.com/baseplate/baseplate/internal/core/entity"
+	"github.com/baseplate/baseplate/internal/core/export"
+	"github.com/baseplate/baseplate/internal/core/integration"
+	"github.com/baseplate/baseplate/internal/core/notification"
+	"github.com/baseplate/baseplate/internal/core/scorecard"
+	"github.com/baseplate/baseplate/internal/core/stats"
+)
+
+// Built-in tasks
+const (
+	TaskScorecards     = "scorecards.recalculate"
+	TaskIntegrations   = "integrations.check_syncs"
+	TaskUsageReport    = "reports.usage"
+	TaskExports        = "exports.delete_expired"
+	TaskIndexAdvisor   = "indexes.advise"
+	TaskNotifications  = "notifications.deliver"
+	TaskGitHubSync     = "integrations.sync_github"
+	TaskKubernetesSync = "integrations.sync_kubernetes"
+	TaskPrometheusSync = "integrations.sync_prometheus"
+	TaskPagerDutySync  = "integrations.sync_pagerduty"
+)
+
+// usageReportDays and usageReportTeams size the usage report
+const (
+	usageReportDays  = 7
+	usageReportTeams = 10
+)
+
+// RecalculateScorecards evaluates every scorecard against its entities,
+// recording the results that changed for the scorecard history, and rebuilds
+// the entity rollups, which hold the scorecard levels reported by
+// aggregations and metrics. rollups is nil while rollups are disabled.
+// Nothing is rebuilt while another instance holds the rebuild lock.
+func RecalculateScorecards(scorecards *scorecard.Service, rollups *entity.RollupMaintainer) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		recorded, err := scorecards.Snapshot(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result := map[string]int{"results": recorded}
+		if rollups == nil {
+			return result, nil
+		}
+		if result["blueprints"], err = rollups.Rebuild(ctx); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+// CheckIntegrationSyncs marks integrations whose exporter has not synced
+// within staleAfter as stale. Exporters push their data, so this is how a
+// stopped exporter shows.
+func CheckIntegrationSyncs(integrations *integration.Service, staleAfter time.Duration) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		marked, err := integrations.MarkStale(ctx, staleAfter)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int64{"marked_stale": marked}, nil
+	}
+}
+
+// SyncIntegrations queues a full sync of every integration of a type
+// Baseplate syncs itself, github, kubernetes, prometheus or pagerduty
+func SyncIntegrations(integrations *integration.Service, integrationType string) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		queued, err := integrations.QueueSyncs(ctx, integrationType)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int{"queued": queued}, nil
+	}
+}
+
+// UsageReport generates the platform usage report of the last week, which
+// is kept as the task's last result
+func UsageReport(usage *stats.Service) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		return usage.Platform(ctx, usageReportDays, usageReportTeams)
+	}
+}
+
+// DeleteExpiredExports deletes the background exports past their expiry,
+// with their files
+func DeleteExpiredExports(exports *export.Service) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		deleted, err := exports.DeleteExpired(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]int{"deleted": deleted}, nil
+	}
+}
+
+// AdviseIndexes computes the index recommendations of all teams and, when
+// auto-apply is on, marks the recommended properties indexed
+func AdviseIndexes(indexes *advisor.Service) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		return indexes.Advise(ctx)
+	}
+}
+
+// DeliverNotifications queues the action failures and scorecard degradations
+// since the last run and delivers the subscriptions whose notifications are
+// due
+func DeliverNotifications(notifications *notification.Service) Func {
+	return func(ctx context.Context) (interface{}, error) {
+		return notifications.Run(ctx)
+	}
+}
@@ -0,0 +1,360 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/cron"
+	"github.com/baseplate/baseplate/internal/jobs"
+	"github.com/baseplate/baseplate/internal/reqctx"
+)
+
+const (
+	// JobRunTask is the background job that runs one firing of a task
+	JobRunTask = "system.task"
+
+	// Interval is how often due tasks are looked for
+	Interval = 30 * time.Second
+)
+
+// Func runs a task and returns a summary of what it did, which is kept as
+// the task's last result. Runs end with the job lease.
+type Func func(ctx context.Context) (interface{}, error)
+
+type definition struct {
+	name        string
+	description string
+	cron        string
+	fn          Func
+}
+
+type runTaskPayload struct {
+	Name string `json:"name"`
+}
+
+// Engine runs the built-in maintenance tasks on their cron schedules. Each
+// pass locks the due tasks, queues a background job per task and moves it to
+// its next time, so one instance fires each run; a run that finds the task
+// still running from an earlier firing is skipped.
+type Engine struct {
+	repo  *Repository
+	queue *jobs.Queue
+	now   func() time.Time
+
+	mu      sync.Mutex
+	tasks   map[string]*definition
+	lastErr error
+}
+
+// NewEngine creates the engine and registers its job with queue
+func NewEngine(repo *Repository, queue *jobs.Queue) *Engine {
+	e := &Engine{repo: repo, queue: queue, now: time.Now, tasks: make(map[string]*definition)}
+	queue.Register(JobRunTask, e.runJob)
+	return e
+}
+
+// Register adds a task with its configured schedule, a cron expression in
+// UTC or Off. Every task must be registered before Run; registering a name
+// twice panics.
+func (e *Engine) Register(name, description, schedule string, fn Func) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.tasks[name]; ok {
+		panic(fmt.Sprintf("tasks: %q registered twice", name))
+	}
+	e.tasks[name] = &definition{name: name, description: description, cron: strings.TrimSpace(schedule), fn: fn}
+}
+
+func (e *Engine) definition(name string) (*definition, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	def, ok := e.tasks[name]
+	return def, ok
+}
+
+func (e *Engine) names() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	names := make([]string, 0, len(e.tasks))
+	for name := range e.tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run reschedules tasks whose configured schedule changed, then fires due
+// tasks every interval until ctx is done
+func (e *Engine) Run(ctx context.Context, interval time.Duration) {
+	if err := e.Sync(ctx); err != nil && ctx.Err() == nil {
+		log.Printf("ERROR: tasks: scheduling failed: %v", err)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := e.Tick(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("ERROR: tasks: %v", err)
+			}
+			e.mu.Lock()
+			e.lastErr = err
+			e.mu.Unlock()
+		}
+	}
+}
+
+// LastError returns the error of the last pass, nil once one succeeds
+func (e *Engine) LastError() error {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.lastErr
+}
+
+// Sync adds the rows of new tasks and computes the next run of every task
+// whose schedule is not the one its next run was computed from
+func (e *Engine) Sync(ctx context.Context) error {
+	names := e.names()
+	if err := e.repo.Ensure(ctx, names); err != nil {
+		return err
+	}
+	rows, err := e.repo.List(ctx, names)
+	if err != nil {
+		return err
+	}
+	for _, task := range rows {
+		def, _ := e.definition(task.Name)
+		schedule, _ := effective(def, task)
+		if schedule == task.schedule {
+			continue
+		}
+		task.schedule = schedule
+		task.NextRunAt = nextRun(schedule, e.now())
+		if err := e.repo.Update(ctx, task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tick queues a job for every due task
+func (e *Engine) Tick(ctx context.Context) error {
+	_, err := e.repo.AdvanceDue(ctx, e.names(), func(task *Task) (*time.Time, error) {
+		if err := e.enqueue(ctx, task.Name); err != nil {
+			return nil, err
+		}
+		return nextRun(task.schedule, e.now()), nil
+	})
+	return err
+}
+
+// A task's run is its only attempt; the next firing is its retry
+func (e *Engine) enqueue(ctx context.Context, name string) error {
+	_, err := e.queue.Enqueue(ctx, JobRunTask, runTaskPayload{Name: name}, &jobs.EnqueueOptions{MaxAttempts: 1})
+	return err
+}
+
+func (e *Engine) runJob(ctx context.Context, job *jobs.Job) error {
+	var payload runTaskPayload
+	if err := json.Unmarshal(job.Payload, &payload); err != nil {
+		return jobs.Permanent(err)
+	}
+	def, ok := e.definition(payload.Name)
+	if !ok {
+		return jobs.Permanent(fmt.Errorf("%w: %s", ErrNotFound, payload.Name))
+	}
+
+	started, err := e.repo.Start(ctx, def.name, jobs.Lease)
+	if err != nil {
+		return err
+	}
+	if !started {
+		log.Printf("WARNING: tasks: %s is still running, skipping this run", def.name)
+		return e.repo.Skipped(ctx, def.name)
+	}
+
+	begin := e.now()
+	status, lastError, result := execute(ctx, def)
+	if lastError != "" {
+		log.Printf("ERROR: tasks: %s failed: %s", def.name, lastError)
+	}
+	// Record the outcome even when the run used up its lease
+	return e.repo.Finish(context.WithoutCancel(ctx), def.name, status, lastError, e.now().Sub(begin), result)
+}
+
+// execute runs a task, turning a panic into a failure
+func execute(ctx context.Context, def *definition) (status, lastError string, result json.RawMessage) {
+	var out interface{}
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		out, err = def.fn(reqctx.System(ctx))
+		return err
+	}()
+	if err != nil {
+		return StatusFailed, err.Error(), nil
+	}
+	if out != nil {
+		if result, err = json.Marshal(out); err != nil {
+			return StatusFailed, fmt.Sprintf("encoding result: %v", err), nil
+		}
+	}
+	return StatusSucceeded, "", result
+}
+
+// List returns every registered task by name
+func (e *Engine) List(ctx context.Context) (*ListTasksResponse, error) {
+	rows, err := e.repo.List(ctx, e.names())
+	if err != nil {
+		return nil, err
+	}
+	tasks := make([]*Task, 0, len(rows))
+	for _, task := range rows {
+		def, _ := e.definition(task.Name)
+		tasks = append(tasks, e.view(def, task))
+	}
+	return &ListTasksResponse{Tasks: tasks, Total: len(tasks)}, nil
+}
+
+func (e *Engine) Get(ctx context.Context, name string) (*Task, error) {
+	def, ok := e.definition(name)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	task, err := e.repo.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		// The engine has not synced since the task was registered
+		if err := e.Sync(ctx); err != nil {
+			return nil, err
+		}
+		if task, err = e.repo.Get(ctx, name); err != nil {
+			return nil, err
+		}
+		if task == nil {
+			return nil, ErrNotFound
+		}
+	}
+	return e.view(def, task), nil
+}
+
+// Update overrides a task's schedule or enables and disables it, and
+// computes its next run
+func (e *Engine) Update(ctx context.Context, name string, req *UpdateTaskRequest) (*Task, error) {
+	def, ok := e.definition(name)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	task, err := e.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if req.Cron != nil {
+		override := strings.TrimSpace(*req.Cron)
+		if override != "" {
+			if err := ValidateSchedule(override); err != nil {
+				return nil, err
+			}
+		}
+		task.override = override
+	}
+	if req.Enabled != nil {
+		task.Enabled = *req.Enabled
+	}
+	task.schedule, _ = effective(def, task)
+	task.NextRunAt = nextRun(task.schedule, e.now())
+	if err := e.repo.Update(ctx, task); err != nil {
+		return nil, err
+	}
+	return e.view(def, task), nil
+}
+
+// Trigger queues a run of a task now, outside its schedule
+func (e *Engine) Trigger(ctx context.Context, name string) (*Task, error) {
+	task, err := e.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if task.Running {
+		return nil, ErrRunning
+	}
+	if err := e.enqueue(ctx, name); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// view completes a task's row with its definition and schedule
+func (e *Engine) view(def *definition, task *Task) *Task {
+	task.Description = def.description
+	schedule, source := effective(def, task)
+	task.Source = source
+	task.Cron = ""
+	if schedule != Off {
+		task.Cron = schedule
+	}
+	task.Running = task.RunningSince != nil && e.now().Sub(*task.RunningSince) < jobs.Lease
+	return task
+}
+
+// effective returns the schedule in effect for a task and where it is set
+func effective(def *definition, task *Task) (schedule, source string) {
+	if task.override != "" {
+		return task.override, SourceAdmin
+	}
+	if def.cron == "" {
+		return Off, SourceConfig
+	}
+	return def.cron, SourceConfig
+}
+
+// ValidateSchedule checks a task schedule: Off or a cron expression that
+// matches
+func ValidateSchedule(schedule string) error {
+	if schedule == Off {
+		return nil
+	}
+	expr, err := cron.Parse(schedule)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if expr.Next(time.Now().UTC()).IsZero() {
+		return fmt.Errorf("%w: cron %q never matches", ErrInvalid, schedule)
+	}
+	return nil
+}
+
+// nextRun returns when a schedule runs after now, in UTC, or nil when it is
+// off. Missed times are skipped, not made up for.
+func nextRun(schedule string, now time.Time) *time.Time {
+	if schedule == "" || schedule == Off {
+		return nil
+	}
+	expr, err := cron.Parse(schedule)
+	if err != nil {
+		return nil
+	}
+	at := expr.Next(now.UTC())
+	if at.IsZero() {
+		return nil
+	}
+	return &at
+}
@@ -0,0 +1,86 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestExecute(t *testing.T) {
+	tests := []struct {
+		name       string
+		fn         Func
+		wantStatus string
+		wantError  string
+		wantResult string
+	}{
+		{"result", func(ctx context.Context) (interface{}, error) { return map[string]int{"blueprints": 3}, nil }, StatusSucceeded, "", `{"blueprints":3}`},
+		{"no result", func(ctx context.Context) (interface{}, error) { return nil, nil }, StatusSucceeded, "", ""},
+		{"error", func(ctx context.Context) (interface{}, error) { return nil, errors.New("boom") }, StatusFailed, "boom", ""},
+		{"panic", func(ctx context.Context) (interface{}, error) { panic("oops") }, StatusFailed, "panic: oops", ""},
+	}
+	for _, tt := range tests {
+		status, lastError, result := execute(context.Background(), &definition{name: tt.name, fn: tt.fn})
+		if status != tt.wantStatus || lastError != tt.wantError || string(result) != tt.wantResult {
+			t.Errorf("%s: execute() = %q, %q, %s", tt.name, status, lastError, result)
+		}
+	}
+}
+
+func TestView(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 7, 0, 0, time.UTC)
+	e := &Engine{now: func() time.Time { return now }}
+	def := &definition{name: TaskUsageReport, description: "report", cron: "0 6 * * mon"}
+	started := now.Add(-time.Minute)
+	stuck := now.Add(-time.Hour)
+
+	tests := []struct {
+		name        string
+		task        *Task
+		wantCron    string
+		wantSource  string
+		wantRunning bool
+	}{
+		{"configured", &Task{}, "0 6 * * mon", SourceConfig, false},
+		{"override", &Task{override: "@daily"}, "@daily", SourceAdmin, false},
+		{"overridden off", &Task{override: Off}, "", SourceAdmin, false},
+		{"running", &Task{RunningSince: &started}, "0 6 * * mon", SourceConfig, true},
+		// A run past the job lease died with its instance
+		{"lease expired", &Task{RunningSince: &stuck}, "0 6 * * mon", SourceConfig, false},
+	}
+	for _, tt := range tests {
+		task := e.view(def, tt.task)
+		if task.Cron != tt.wantCron || task.Source != tt.wantSource || task.Running != tt.wantRunning || task.Description != "report" {
+			t.Errorf("%s: view() = %+v", tt.name, task)
+		}
+	}
+
+	if task := e.view(&definition{}, &Task{}); task.Cron != "" {
+		t.Errorf("a task without a configured schedule should be off, got %q", task.Cron)
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	for _, schedule := range []string{Off, "@hourly", "*/15 * * * *"} {
+		if err := ValidateSchedule(schedule); err != nil {
+			t.Errorf("ValidateSchedule(%q) = %v", schedule, err)
+		}
+	}
+	for _, schedule := range []string{"weekly", "0 0 30 2 *", "* * *"} {
+		if err := ValidateSchedule(schedule); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ValidateSchedule(%q) = %v, want ErrInvalid", schedule, err)
+		}
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	now := time.Date(2026, 3, 1, 10, 7, 0, 0, time.UTC)
+	if next := nextRun(Off, now); next != nil {
+		t.Errorf("nextRun(off) = %v, want nil", next)
+	}
+	want := time.Date(2026, 3, 2, 6, 0, 0, 0, time.UTC)
+	if next := nextRun("0 6 * * mon", now); next == nil || !next.Equal(want) {
+		t.Errorf("nextRun() = %v, want %v", next, want)
+	}
+}
@@ -0,0 +1,68 @@
+package tasks
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("task not found")
+	ErrRunning  = errors.New("the task is already running")
+	ErrInvalid  = errors.New("invalid task schedule")
+)
+
+// Run outcomes
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Schedule sources: the configuration, or an override set by a super admin
+const (
+	SourceConfig = "config"
+	SourceAdmin  = "admin"
+)
+
+// Off disables a task when used as its schedule
+const Off = "off"
+
+// Task is a built-in maintenance task with its schedule and the outcome of
+// its last run
+type Task struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Cron is the schedule in effect, in UTC; empty when the task is off
+	Cron           string          `json:"cron,omitempty"`
+	Source         string          `json:"source"`
+	Enabled        bool            `json:"enabled"`
+	NextRunAt      *time.Time      `json:"next_run_at,omitempty"`
+	Running        bool            `json:"running"`
+	RunningSince   *time.Time      `json:"running_since,omitempty"`
+	LastStartedAt  *time.Time      `json:"last_started_at,omitempty"`
+	LastFinishedAt *time.Time      `json:"last_finished_at,omitempty"`
+	LastStatus     string          `json:"last_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	LastDurationMS *int64          `json:"last_duration_ms,omitempty"`
+	LastResult     json.RawMessage `json:"last_result,omitempty"`
+	// LastSkippedAt is when a firing last found the task still running
+	LastSkippedAt *time.Time `json:"last_skipped_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+
+	// override is the cron set through the admin API, schedule the cron
+	// next_run_at was computed from
+	override string
+	schedule string
+}
+
+// UpdateTaskRequest changes a task's schedule. An empty cron removes the
+// override, so the configured schedule applies again; "off" disables it.
+type UpdateTaskRequest struct {
+	Cron    *string `json:"cron"`
+	Enabled *bool   `json:"enabled"`
+}
+
+type ListTasksResponse struct {
+	Tasks []*Task `json:"tasks"`
+	Total int     `json:"total"`
+}
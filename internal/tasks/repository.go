@@ -0,0 +1,157 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/baseplate/baseplate/internal/storage/postgres"
+)
+
+type Repository struct {
+	db *postgres.Client
+}
+
+func NewRepository(db *postgres.Client) *Repository {
+	return &Repository{db: db}
+}
+
+const taskColumns = `
+	name, COALESCE(cron, ''), COALESCE(schedule, ''), enabled, next_run_at, running_since, last_started_at,
+	last_finished_at, COALESCE(last_status, ''), COALESCE(last_error, ''), last_duration_ms, last_result,
+	last_skipped_at, updated_at`
+
+// Ensure adds a row for each task that has none yet
+func (r *Repository) Ensure(ctx context.Context, names []string) error {
+	_, err := r.db.DB.ExecContext(ctx, `
+		INSERT INTO system_tasks (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING`, names)
+	return err
+}
+
+func (r *Repository) Get(ctx context.Context, name string) (*Task, error) {
+	rows, err := r.db.DB.QueryContext(ctx, `SELECT `+taskColumns+` FROM system_tasks WHERE name = $1`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil || len(tasks) == 0 {
+		return nil, err
+	}
+	return tasks[0], nil
+}
+
+// List returns the rows of the given tasks by name
+func (r *Repository) List(ctx context.Context, names []string) ([]*Task, error) {
+	rows, err := r.db.DB.QueryContext(ctx,
+		`SELECT `+taskColumns+` FROM system_tasks WHERE name = ANY($1) ORDER BY name`, names)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanTasks(rows)
+}
+
+// Update stores a task's override, enabled flag and next run
+func (r *Repository) Update(ctx context.Context, task *Task) error {
+	return r.db.DB.QueryRowContext(ctx, `
+		UPDATE system_tasks SET cron = NULLIF($2, ''), schedule = NULLIF($3, ''), enabled = $4, next_run_at = $5, updated_at = NOW()
+		WHERE name = $1
+		RETURNING updated_at`,
+		task.Name, task.override, task.schedule, task.Enabled, task.NextRunAt,
+	).Scan(&task.UpdatedAt)
+}
+
+// AdvanceDue locks enabled tasks of the given names that are due, calls fire
+// for each and stores the next run it returns, all in one transaction, so
+// another instance never fires the same run. It returns how many were due.
+func (r *Repository) AdvanceDue(ctx context.Context, names []string, fire func(*Task) (*time.Time, error)) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+taskColumns+` FROM system_tasks
+		WHERE enabled AND next_run_at <= NOW() AND name = ANY($1)
+		ORDER BY next_run_at
+		FOR UPDATE SKIP LOCKED`, names)
+	if err != nil {
+		return 0, err
+	}
+	tasks, err := scanTasks(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, task := range tasks {
+		next, err := fire(task)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE system_tasks SET next_run_at = $2 WHERE name = $1`, task.Name, next); err != nil {
+			return 0, err
+		}
+	}
+	return len(tasks), tx.Commit()
+}
+
+// Start marks a task running unless a run that started within lease still
+// is. It returns false when the task is running.
+func (r *Repository) Start(ctx context.Context, name string, lease time.Duration) (bool, error) {
+	result, err := r.db.DB.ExecContext(ctx, `
+		UPDATE system_tasks SET running_since = NOW(), last_started_at = NOW()
+		WHERE name = $1 AND (running_since IS NULL OR running_since < NOW() - $2 * INTERVAL '1 second')`,
+		name, int64(lease/time.Second))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Skipped records a firing that found the task still running
+func (r *Repository) Skipped(ctx context.Context, name string) error {
+	_, err := r.db.DB.ExecContext(ctx, `UPDATE system_tasks SET last_skipped_at = NOW() WHERE name = $1`, name)
+	return err
+}
+
+// Finish records the outcome of a run and marks the task idle
+func (r *Repository) Finish(ctx context.Context, name, status, lastError string, duration time.Duration, result json.RawMessage) error {
+	var stored interface{}
+	if result != nil {
+		stored = []byte(result)
+	}
+	_, err := r.db.DB.ExecContext(ctx, `
+		UPDATE system_tasks
+		SET running_since = NULL, last_finished_at = NOW(), last_status = $2, last_error = NULLIF($3, ''),
+			last_duration_ms = $4, last_result = $5
+		WHERE name = $1`,
+		name, status, lastError, duration.Milliseconds(), stored)
+	return err
+}
+
+func scanTasks(rows *sql.Rows) ([]*Task, error) {
+	var tasks []*Task
+	for rows.Next() {
+		task := &Task{}
+		var result []byte
+		if err := rows.Scan(
+			&task.Name, &task.override, &task.schedule, &task.Enabled, &task.NextRunAt, &task.RunningSince, &task.LastStartedAt,
+			&task.LastFinishedAt, &task.LastStatus, &task.LastError, &task.LastDurationMS, &result,
+			&task.LastSkippedAt, &task.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if result != nil {
+			task.LastResult = result
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
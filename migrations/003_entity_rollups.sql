@@ -0,0 +1,28 @@
+-- Entity Rollups Migration
+-- Precomputed counts that keep aggregations fast on large catalogs.
+-- Rows are maintained incrementally from entity events and rebuilt periodically.
+
+-- One counter per blueprint and dimension:
+--   kind 'count'            key ''                  value ''          -> entities in the blueprint
+--   kind 'enum'             key '<property path>'   value '<value>'   -> entities per enum value ('' = missing)
+--   kind 'scorecard'        key '<identifier>'      value '<level>'   -> entities per achieved level ('' = none)
+--   kind 'scorecard_rules'  key '<identifier>'      value 'passed' | 'total' -> rule checks
+CREATE TABLE entity_rollups (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    value TEXT NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (team_id, blueprint_id, kind, key, value)
+);
+
+-- Dimensions covered by the last rebuild of each blueprint's rollups.
+-- Readers only use rollups for dimensions listed here.
+CREATE TABLE entity_rollup_state (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL,
+    dimensions TEXT[] NOT NULL DEFAULT '{}',
+    rebuilt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (team_id, blueprint_id)
+);
@@ -0,0 +1,25 @@
+-- Entity Views Migration
+-- Saved searches per blueprint: filters, sort and column selection.
+-- Private views belong to their creator; shared views are visible to the team.
+-- At most one shared view per blueprint is the default for entity list endpoints.
+
+CREATE TABLE entity_views (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL REFERENCES blueprints(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    filters JSONB NOT NULL DEFAULT '[]',
+    order_by VARCHAR(255),
+    order_dir VARCHAR(4),
+    columns JSONB NOT NULL DEFAULT '[]',
+    shared BOOLEAN NOT NULL DEFAULT FALSE,
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    CHECK (NOT is_default OR shared)
+);
+
+CREATE INDEX idx_entity_views_blueprint ON entity_views(team_id, blueprint_id);
+CREATE UNIQUE INDEX idx_entity_views_default ON entity_views(team_id, blueprint_id) WHERE is_default;
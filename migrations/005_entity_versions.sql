@@ -0,0 +1,6 @@
+-- Entity Versions Migration
+-- Every update increments an entity's version. The API returns it as the ETag
+-- and only applies updates sent with a matching If-Match header, so concurrent
+-- writers cannot silently overwrite each other.
+
+ALTER TABLE entities ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,20 @@
+-- Entity History Migration
+-- One row per entity revision, written in the same statement as the change.
+-- Rows outlive the entity so deletions stay auditable; they are removed with
+-- the team.
+
+CREATE TABLE entity_history (
+    id BIGSERIAL PRIMARY KEY,
+    entity_id UUID NOT NULL,
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL,
+    version BIGINT NOT NULL,
+    action VARCHAR(10) NOT NULL CHECK (action IN ('created', 'updated', 'deleted')),
+    title VARCHAR(255),
+    data JSONB NOT NULL,
+    actor_user_id UUID REFERENCES users(id) ON DELETE SET NULL,
+    actor_api_key_id UUID,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_entity_history_entity ON entity_history(entity_id, version);
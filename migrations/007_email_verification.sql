@@ -0,0 +1,10 @@
+-- Email Verification Migration
+-- A user's email change is held as pending until the new address is confirmed
+-- with the token sent to it. Only the SHA-256 hash of the token is stored.
+
+ALTER TABLE users ADD COLUMN pending_email VARCHAR(255);
+ALTER TABLE users ADD COLUMN email_verification_hash VARCHAR(64);
+ALTER TABLE users ADD COLUMN email_verification_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX idx_users_email_verification ON users(email_verification_hash)
+  WHERE email_verification_hash IS NOT NULL;
@@ -0,0 +1,13 @@
+-- User Lifecycle Migration
+-- Super admins create users with a temporary password or an invite, and can
+-- deactivate or delete them. Tokens issued before sessions_revoked_at are
+-- rejected, which ends a deactivated user's sessions. Only the SHA-256 hash
+-- of an invite token is stored.
+
+ALTER TABLE users ADD COLUMN password_change_required BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN sessions_revoked_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN invite_token_hash VARCHAR(64);
+ALTER TABLE users ADD COLUMN invite_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX idx_users_invite ON users(invite_token_hash)
+  WHERE invite_token_hash IS NOT NULL;
@@ -0,0 +1,16 @@
+-- Property Usage Migration
+-- Daily counts of how often each blueprint property is filtered, sorted,
+-- grouped, aggregated or shown as a saved view column. The counts guide which
+-- properties to index or deprecate; rows older than the retention are pruned.
+
+CREATE TABLE property_usage (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL,
+    property VARCHAR(255) NOT NULL,
+    usage VARCHAR(10) NOT NULL CHECK (usage IN ('filter', 'sort', 'group', 'aggregate', 'column')),
+    day DATE NOT NULL,
+    count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (team_id, blueprint_id, day, property, usage)
+);
+
+CREATE INDEX idx_property_usage_day ON property_usage(day);
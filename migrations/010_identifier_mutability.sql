@@ -0,0 +1,6 @@
+-- Identifier Mutability Migration
+-- External systems reference entities by identifier, so identifiers are
+-- immutable unless a blueprint opts in. Even then they change only through
+-- the rename endpoint, never as a side effect of an update.
+
+ALTER TABLE blueprints ADD COLUMN identifier_mutable BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- Entity Ownership Migration
+-- Exporters claim the entities they report when they reconcile, so entities
+-- that disappear upstream can be found and deleted. Deleting an integration
+-- releases its entities rather than deleting them.
+
+ALTER TABLE entities ADD COLUMN integration_id UUID REFERENCES integrations(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_entities_integration ON entities(integration_id, blueprint_id) WHERE integration_id IS NOT NULL;
@@ -0,0 +1,25 @@
+-- Two-Factor Authentication Migration
+-- Users can enroll a TOTP authenticator and a set of single-use backup codes.
+-- A password login for such a user yields a short-lived challenge that the
+-- second step exchanges for a token. Teams can require two-factor
+-- authentication for members who manage them.
+
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64);
+ALTER TABLE users ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+-- Last accepted time step; codes at or before it are rejected so a code
+-- cannot be replayed
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN login_challenge_hash VARCHAR(64);
+ALTER TABLE users ADD COLUMN login_challenge_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE users ADD COLUMN login_challenge_attempts INT NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX idx_users_login_challenge ON users(login_challenge_hash) WHERE login_challenge_hash IS NOT NULL;
+
+CREATE TABLE user_backup_codes (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash VARCHAR(64) NOT NULL,
+    used_at TIMESTAMP WITH TIME ZONE,
+    PRIMARY KEY (user_id, code_hash)
+);
+
+ALTER TABLE teams ADD COLUMN require_two_factor BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,8 @@
+-- Property Sources Migration
+-- Records which user, API key or integration last wrote each top-level data
+-- property of an entity, and lets blueprints choose whether manual edits or
+-- integration syncs win when both write the same property.
+
+ALTER TABLE entities ADD COLUMN property_sources JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE blueprints ADD COLUMN merge_policy JSONB;
@@ -0,0 +1,18 @@
+-- Impersonation Migration
+-- Super admins can mint a short-lived token that acts as another user to
+-- debug team issues. Each token belongs to a session row, which is checked on
+-- every request so that revoking the session ends the token at once.
+
+CREATE TABLE impersonation_sessions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    admin_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_impersonation_sessions_created ON impersonation_sessions(created_at DESC);
+CREATE INDEX idx_impersonation_sessions_target ON impersonation_sessions(target_user_id);
@@ -0,0 +1,15 @@
+-- Team Request Stats Migration
+-- Daily counts of the API requests made in each team's context, for the
+-- usage statistics shown to super admins. Counts are added by the servers
+-- every minute; rows older than the retention are pruned.
+
+CREATE TABLE team_request_stats (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    requests BIGINT NOT NULL DEFAULT 0,
+    client_errors BIGINT NOT NULL DEFAULT 0,
+    server_errors BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (team_id, day)
+);
+
+CREATE INDEX idx_team_request_stats_day ON team_request_stats(day);
@@ -0,0 +1,8 @@
+-- Team Deletion Migration
+-- Deleting a team takes two steps: a manager first requests deletion and
+-- receives a short-lived confirmation token, which the delete call must
+-- present. Only the token's hash is stored.
+
+ALTER TABLE teams ADD COLUMN deletion_token_hash VARCHAR(64);
+ALTER TABLE teams ADD COLUMN deletion_token_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE teams ADD COLUMN deletion_requested_by UUID REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1,22 @@
+-- Team Secrets Migration
+-- Tokens and passwords that integrations and actions need are stored per team,
+-- encrypted with AES-256-GCM under the server's SECRETS_ENCRYPTION_KEY, and
+-- referenced by name from configurations instead of being embedded in them.
+-- Values are never returned by listings; each read of a value is audited.
+
+CREATE TABLE team_secrets (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    -- GCM nonce followed by the sealed value
+    ciphertext BYTEA NOT NULL,
+    -- Incremented by every rotation
+    version INT NOT NULL DEFAULT 1,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    rotated_at TIMESTAMP WITH TIME ZONE,
+    rotated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    last_accessed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(team_id, name)
+);
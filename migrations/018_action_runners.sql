@@ -0,0 +1,48 @@
+-- Action Runners Migration
+-- Runners are agents inside private networks that execute self-service
+-- actions. They pull pending runs over HTTPS with a runner token, hold a
+-- lease on the run while executing it, and report status and log lines.
+-- Only the SHA-256 hash of a runner token is stored.
+
+CREATE TABLE runners (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    -- Runs of actions whose trigger_config.runner_labels are all among these
+    labels JSONB NOT NULL DEFAULT '[]',
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE(team_id, name)
+);
+
+CREATE TABLE action_runs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    action_id UUID NOT NULL REFERENCES actions(id) ON DELETE CASCADE,
+    entity_id UUID REFERENCES entities(id) ON DELETE SET NULL,
+    inputs JSONB NOT NULL DEFAULT '{}',
+    -- pending, running, succeeded, failed or cancelled
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    message TEXT,
+    runner_id UUID REFERENCES runners(id) ON DELETE SET NULL,
+    triggered_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    claimed_at TIMESTAMP WITH TIME ZONE,
+    -- A running run whose lease expires is failed; reports extend it
+    lease_expires_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_action_runs_team_created ON action_runs(team_id, created_at DESC);
+CREATE INDEX idx_action_runs_pending ON action_runs(team_id, created_at) WHERE status = 'pending';
+
+CREATE TABLE action_run_logs (
+    id BIGSERIAL PRIMARY KEY,
+    run_id UUID NOT NULL REFERENCES action_runs(id) ON DELETE CASCADE,
+    line TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_action_run_logs_run ON action_run_logs(run_id, id);
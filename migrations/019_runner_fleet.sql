@@ -0,0 +1,8 @@
+-- Runner Fleet Migration
+-- Runners report their version and host with each heartbeat, so team
+-- admins and super admins can see which agents are online and what they run.
+
+ALTER TABLE runners ADD COLUMN version VARCHAR(50);
+ALTER TABLE runners ADD COLUMN hostname VARCHAR(255);
+
+CREATE INDEX idx_runners_last_seen ON runners(last_seen_at);
@@ -0,0 +1,9 @@
+-- Entity Expiry Migration
+-- A blueprint's expiry policy gives its entities an expiry time, from a TTL
+-- or a date-time property. The time is computed when an entity is written and
+-- stored in expires_at, so the sweeper finds expired entities with an index.
+
+ALTER TABLE blueprints ADD COLUMN expiry_policy JSONB;
+ALTER TABLE entities ADD COLUMN expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_entities_expires_at ON entities(expires_at) WHERE expires_at IS NOT NULL;
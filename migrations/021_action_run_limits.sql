@@ -0,0 +1,6 @@
+-- Action Run Limits Migration
+-- Actions may limit concurrent runs and set a cooldown in their
+-- trigger_config. Triggering looks up the action's active runs and its last
+-- run, per entity, through this index.
+
+CREATE INDEX idx_action_runs_action ON action_runs(action_id, entity_id, created_at DESC);
@@ -0,0 +1,26 @@
+-- Background Jobs Migration
+-- A Postgres-backed queue for work done outside requests. Workers claim due
+-- jobs with FOR UPDATE SKIP LOCKED and hold a lease while running them; a job
+-- whose worker died is claimed again once its lease expires. Failed jobs are
+-- retried with backoff until max_attempts, then kept as dead for inspection.
+
+CREATE TABLE jobs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    kind VARCHAR(100) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    -- pending, running, succeeded or dead
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    -- When a pending job is due; retries move it into the future
+    run_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    lease_expires_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    finished_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_jobs_due ON jobs(run_at) WHERE status = 'pending';
+CREATE INDEX idx_jobs_leased ON jobs(lease_expires_at) WHERE status = 'running';
+CREATE INDEX idx_jobs_status_created ON jobs(status, created_at DESC);
@@ -0,0 +1,39 @@
+-- Action Schedules Migration
+-- Schedules trigger an action once at run_at or repeatedly by a cron
+-- expression, on one entity or on the entities matching search filters. The
+-- scheduler queues a background job for each due schedule and advances
+-- next_run_at; the job creates the runs. A run remembers its schedule and
+-- the time it was scheduled for, and the unique index makes a retried job
+-- create each run once.
+
+CREATE TABLE action_schedules (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    action_id UUID NOT NULL REFERENCES actions(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    entity_id UUID REFERENCES entities(id) ON DELETE CASCADE,
+    -- Entity search filters; [] selects every entity of the action's blueprint
+    filters JSONB,
+    inputs JSONB NOT NULL DEFAULT '{}',
+    -- Exactly one of cron and run_at is set
+    cron VARCHAR(100),
+    run_at TIMESTAMP WITH TIME ZONE,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    last_run_at TIMESTAMP WITH TIME ZONE,
+    last_error TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE(team_id, name)
+);
+
+CREATE INDEX idx_action_schedules_due ON action_schedules(next_run_at) WHERE enabled;
+
+ALTER TABLE action_runs ADD COLUMN schedule_id UUID REFERENCES action_schedules(id) ON DELETE SET NULL;
+ALTER TABLE action_runs ADD COLUMN scheduled_for TIMESTAMP WITH TIME ZONE;
+
+CREATE UNIQUE INDEX idx_action_runs_schedule ON action_runs(
+    schedule_id, scheduled_for, COALESCE(entity_id, '00000000-0000-0000-0000-000000000000'::uuid)
+) WHERE schedule_id IS NOT NULL;
@@ -0,0 +1,27 @@
+-- System Tasks Migration
+-- State of the built-in maintenance tasks (scorecard recalculation,
+-- integration sync checks, usage reports). Tasks are defined in code and
+-- scheduled by cron from the configuration; cron here overrides it. The
+-- engine locks due rows to queue one background job per firing and marks a
+-- task running_since while it runs, so runs of a task never overlap.
+
+CREATE TABLE system_tasks (
+    name VARCHAR(100) PRIMARY KEY,
+    -- Schedule set through the admin API; NULL uses the configured one
+    cron VARCHAR(100),
+    -- The schedule next_run_at was computed from, to notice changed configuration
+    schedule VARCHAR(100),
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMP WITH TIME ZONE,
+    running_since TIMESTAMP WITH TIME ZONE,
+    last_started_at TIMESTAMP WITH TIME ZONE,
+    last_finished_at TIMESTAMP WITH TIME ZONE,
+    last_status VARCHAR(20),
+    last_error TEXT,
+    last_duration_ms BIGINT,
+    last_result JSONB,
+    last_skipped_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_system_tasks_due ON system_tasks(next_run_at) WHERE enabled;
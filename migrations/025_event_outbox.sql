@@ -0,0 +1,32 @@
+-- Event Outbox Migration
+-- Entity and blueprint writes insert their events here in the same statement
+-- as the write, so an event exists exactly when its write was committed. The
+-- dispatcher claims pending events in id order with FOR UPDATE SKIP LOCKED,
+-- delivers them to its consumers and retries failed deliveries with backoff
+-- until max attempts, then keeps the event as dead for inspection.
+
+CREATE TABLE event_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL UNIQUE DEFAULT uuid_generate_v4(),
+    type VARCHAR(100) NOT NULL,
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(100),
+    entity_id UUID,
+    payload JSONB,
+    actor_user_id UUID,
+    actor_api_key_id UUID,
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    -- pending, dispatched or dead
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- When a pending event is due; a claim moves it past the claim's lease
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    -- Consumers still to deliver to on retry; NULL delivers to all of them
+    failed_consumers TEXT[],
+    last_error TEXT,
+    dispatched_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_event_outbox_pending ON event_outbox(next_attempt_at, id) WHERE status = 'pending';
+CREATE INDEX idx_event_outbox_dispatched ON event_outbox(dispatched_at) WHERE status = 'dispatched';
+CREATE INDEX idx_event_outbox_team_occurred ON event_outbox(team_id, occurred_at);
@@ -0,0 +1,29 @@
+-- Entity Exports Migration
+-- Exports too large to stream in a response run as background jobs, which
+-- write the export to a file in the artifact store (a local directory or an
+-- S3 bucket). The row tracks the job and locates the file until both are
+-- deleted at expires_at. team_id has no foreign key so that the files of a
+-- deleted team are still deleted at their expiry.
+
+CREATE TABLE entity_exports (
+    id UUID PRIMARY KEY,
+    team_id UUID NOT NULL,
+    blueprint_id VARCHAR(50) NOT NULL,
+    format VARCHAR(20) NOT NULL,
+    -- The filters and ordering of the export
+    request JSONB NOT NULL DEFAULT '{}',
+    -- pending, running, succeeded or failed
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    entity_count INTEGER NOT NULL DEFAULT 0,
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    artifact_key TEXT,
+    error TEXT,
+    requested_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMP WITH TIME ZONE,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_entity_exports_team_created ON entity_exports(team_id, created_at DESC);
+CREATE INDEX idx_entity_exports_expires ON entity_exports(expires_at) WHERE expires_at IS NOT NULL;
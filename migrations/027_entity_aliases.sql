@@ -0,0 +1,16 @@
+-- Entity Aliases Migration
+-- Renaming an entity keeps its old identifier as an alias, so lookups by an
+-- identifier external systems still hold find the entity under its new one.
+-- A live identifier always wins over an alias; renaming an entity to an
+-- identifier drops the alias of that identifier.
+
+CREATE TABLE entity_aliases (
+    team_id UUID NOT NULL,
+    blueprint_id VARCHAR(50) NOT NULL,
+    identifier VARCHAR(255) NOT NULL,
+    entity_id UUID NOT NULL REFERENCES entities(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (team_id, blueprint_id, identifier)
+);
+
+CREATE INDEX idx_entity_aliases_entity ON entity_aliases(entity_id);
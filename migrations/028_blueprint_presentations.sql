@@ -0,0 +1,17 @@
+-- Blueprint Presentations Migration
+-- How frontends render a blueprint's entities: default table columns,
+-- property order, grouping and the detail page layout. One row per
+-- blueprint; a blueprint without one is shown with defaults.
+
+CREATE TABLE blueprint_presentations (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL REFERENCES blueprints(id) ON DELETE CASCADE,
+    columns JSONB NOT NULL DEFAULT '[]',
+    property_order JSONB NOT NULL DEFAULT '[]',
+    group_by VARCHAR(255),
+    detail JSONB NOT NULL DEFAULT '{"sections": []}',
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (team_id, blueprint_id)
+);
@@ -0,0 +1,30 @@
+-- Catalog Docs Migration
+-- Markdown documentation pages of blueprints and entities, in place of
+-- external wikis. A blueprint's page has no entity_id. Every save is kept
+-- as a version; the current content is indexed for full-text search.
+
+CREATE TABLE catalog_docs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL REFERENCES blueprints(id) ON DELETE CASCADE,
+    entity_id UUID REFERENCES entities(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL DEFAULT 1,
+    content TEXT NOT NULL,
+    search TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', content)) STORED,
+    updated_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_catalog_docs_blueprint ON catalog_docs(team_id, blueprint_id) WHERE entity_id IS NULL;
+CREATE UNIQUE INDEX idx_catalog_docs_entity ON catalog_docs(entity_id) WHERE entity_id IS NOT NULL;
+CREATE INDEX idx_catalog_docs_search ON catalog_docs USING GIN(search);
+
+CREATE TABLE catalog_doc_versions (
+    doc_id UUID NOT NULL REFERENCES catalog_docs(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    author UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (doc_id, version)
+);
@@ -0,0 +1,12 @@
+-- Entity Sequences Migration
+-- Counters of blueprint properties marked "generated": "sequence". Each
+-- entity created without the property takes the next number; numbers of
+-- creates that fail are not reused.
+
+CREATE TABLE entity_sequences (
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    blueprint_id VARCHAR(50) NOT NULL REFERENCES blueprints(id) ON DELETE CASCADE,
+    property VARCHAR(255) NOT NULL,
+    value BIGINT NOT NULL,
+    PRIMARY KEY (team_id, blueprint_id, property)
+);
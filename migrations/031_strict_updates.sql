@@ -0,0 +1,6 @@
+-- Strict Updates Migration
+-- Updates validate only the properties they set or change, so entities that
+-- predate a newly required property or a tightened rule can still be edited.
+-- Blueprints opt in to validating the whole entity on every update.
+
+ALTER TABLE blueprints ADD COLUMN strict_updates BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,19 @@
+-- Scorecard Results Migration
+-- Scorecard evaluations over time. The scorecards.recalculate task evaluates
+-- every scorecard and records a result for an entity when its level or its
+-- passed rules changed since the last one, so the table holds each entity's
+-- transitions. Results outlive their entity, like its history.
+
+CREATE TABLE scorecard_results (
+    id BIGSERIAL PRIMARY KEY,
+    scorecard_id UUID NOT NULL REFERENCES scorecards(id) ON DELETE CASCADE,
+    entity_id UUID NOT NULL,
+    level VARCHAR(50) NOT NULL DEFAULT '',
+    previous_level VARCHAR(50),
+    rules_passed INTEGER NOT NULL,
+    rules_total INTEGER NOT NULL,
+    evaluated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_scorecard_results_entity ON scorecard_results(scorecard_id, entity_id, evaluated_at DESC);
+CREATE INDEX idx_scorecard_results_time ON scorecard_results(scorecard_id, evaluated_at DESC);
@@ -0,0 +1,62 @@
+-- Notifications Migration
+-- Subscriptions to catalog events, delivered by email or Slack webhook
+-- either at once or in hourly or daily digests. Matching events wait in
+-- notification_queue until the notifications.deliver task sends them; every
+-- attempt is logged in notification_deliveries. notification_cursors holds
+-- how far action failures and scorecard results have been collected.
+
+CREATE TABLE notification_subscriptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    -- set for a personal subscription, NULL for a team-wide one
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    events TEXT[] NOT NULL,
+    blueprint_id VARCHAR(50) REFERENCES blueprints(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL,
+    -- email recipient of a team-wide email subscription
+    email VARCHAR(255),
+    -- team secret holding the Slack webhook URL
+    webhook_secret VARCHAR(100),
+    digest VARCHAR(20) NOT NULL DEFAULT 'immediate',
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_delivered_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_notification_subscriptions_team ON notification_subscriptions(team_id);
+
+CREATE TABLE notification_queue (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id UUID NOT NULL REFERENCES notification_subscriptions(id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_notification_queue_subscription ON notification_queue(subscription_id, id);
+
+CREATE TABLE notification_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    team_id UUID NOT NULL REFERENCES teams(id) ON DELETE CASCADE,
+    subscription_id UUID REFERENCES notification_subscriptions(id) ON DELETE SET NULL,
+    channel VARCHAR(20) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    notifications INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_notification_deliveries_team ON notification_deliveries(team_id, created_at DESC);
+CREATE INDEX idx_notification_deliveries_subscription ON notification_deliveries(subscription_id, created_at DESC);
+
+CREATE TABLE notification_cursors (
+    source VARCHAR(50) PRIMARY KEY,
+    collected_until TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- Collection reads failed runs and level changes by time across all teams
+CREATE INDEX idx_action_runs_failed ON action_runs(finished_at) WHERE status = 'failed';
+CREATE INDEX idx_scorecard_results_changes ON scorecard_results(evaluated_at) WHERE previous_level IS NOT NULL;
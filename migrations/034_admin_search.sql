@@ -0,0 +1,11 @@
+-- Admin Search Migration
+-- Trigram indexes for the super admin search across teams, which matches
+-- any substring of user emails and names and of entity identifiers and
+-- titles. Teams are few enough to be scanned.
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX idx_users_email_trgm ON users USING GIN (email gin_trgm_ops);
+CREATE INDEX idx_users_name_trgm ON users USING GIN (name gin_trgm_ops);
+CREATE INDEX idx_entities_identifier_trgm ON entities USING GIN (identifier gin_trgm_ops);
+CREATE INDEX idx_entities_title_trgm ON entities USING GIN (title gin_trgm_ops);